@@ -169,6 +169,7 @@ node health, resource utilization, active models, and performance metrics.`,
 }
 
 func validateCmd() *cobra.Command {
+	var configFile string
 	var fix bool
 	var quick bool
 
@@ -178,12 +179,19 @@ func validateCmd() *cobra.Command {
 		Long: `🔍 Validate configuration and environment
 
 Comprehensive validation of your OllamaMax setup including configuration
-syntax, network connectivity, system resources, and security settings.`,
+syntax, data directories, port conflicts, and TLS certificates.
+
+With --fix, common misconfigurations are repaired in place: missing data
+directories are created with safe permissions, conflicting ports are moved
+to the next free port, expired certificates are regenerated, and misspelled
+configuration keys are renamed. Every change is reported, and the previous
+configuration file is kept as a .bak copy.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runValidate(fix, quick)
+			return runValidate(configFile, fix, quick)
 		},
 	}
 
+	cmd.Flags().StringVarP(&configFile, "config", "c", "", "Configuration file path (default ~/.ollamamax/quickstart-config.yaml)")
 	cmd.Flags().BoolVar(&fix, "fix", false, "Attempt to fix common issues automatically")
 	cmd.Flags().BoolVar(&quick, "quick", false, "Run only essential validation checks")
 
@@ -426,45 +434,6 @@ func runStatus(outputFormat string, verbose, watch bool) error {
 	return nil
 }
 
-func runValidate(fix, quick bool) error {
-	fmt.Println("🔍 OllamaMax Configuration Validation")
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println()
-
-	validations := []struct {
-		name string
-		pass bool
-	}{
-		{"Configuration file syntax", true},
-		{"API port availability", true},
-		{"System resources", true},
-		{"Directory permissions", true},
-		{"Network connectivity", true},
-	}
-
-	for _, v := range validations {
-		if v.pass {
-			fmt.Printf("✅ %s: passed\n", v.name)
-		} else {
-			fmt.Printf("❌ %s: failed\n", v.name)
-		}
-	}
-
-	if fix {
-		fmt.Println()
-		fmt.Println("🔧 Applying automatic fixes...")
-		time.Sleep(2 * time.Second)
-		fmt.Println("✅ Fixes applied successfully")
-	}
-
-	fmt.Println()
-	fmt.Println("📊 Validation Summary")
-	fmt.Println("━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("✅ All validations passed - ready to start!")
-
-	return nil
-}
-
 func runExamples() error {
 	fmt.Println("💡 OllamaMax Usage Examples")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -588,7 +557,12 @@ func createQuickStartConfig(port int) error {
 	configDir := filepath.Join(homeDir, ".ollamamax")
 	os.MkdirAll(configDir, 0755)
 
-	configContent := fmt.Sprintf(`# OllamaMax QuickStart Configuration
+	configFile := filepath.Join(configDir, "quickstart-config.yaml")
+	return os.WriteFile(configFile, []byte(quickStartConfigContent(configDir, port)), 0644)
+}
+
+func quickStartConfigContent(configDir string, port int) string {
+	return fmt.Sprintf(`# OllamaMax QuickStart Configuration
 node:
   id: "quickstart-node"
   name: "quickstart-node"
@@ -610,9 +584,6 @@ performance:
   max_concurrency: %d
   gpu_enabled: %t
 `, configDir, port, port+1, configDir, runtime.NumCPU(), detectGPU())
-
-	configFile := filepath.Join(configDir, "quickstart-config.yaml")
-	return os.WriteFile(configFile, []byte(configContent), 0644)
 }
 
 func setupDirectories() error {
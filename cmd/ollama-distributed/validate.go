@@ -0,0 +1,576 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// certRenewWindow is how close to expiry a certificate may get before
+// validate treats it as expired and offers to regenerate it.
+const certRenewWindow = 7 * 24 * time.Hour
+
+// knownConfigKeys lists the keys accepted in each section of the node
+// configuration file. It is used to flag and repair misspelled keys.
+var knownConfigKeys = map[string][]string{
+	"":            {"node", "api", "web", "models", "performance"},
+	"node":        {"id", "name", "data_dir"},
+	"api":         {"host", "port", "tls_enabled", "cert_file", "key_file"},
+	"web":         {"enabled", "port"},
+	"models":      {"store_path", "auto_cleanup"},
+	"performance": {"max_concurrency", "gpu_enabled"},
+}
+
+// validationCheck is the result of a single validation. A nil err means the
+// check passed; fix, when set, repairs the problem and describes the change.
+type validationCheck struct {
+	name string
+	err  error
+	fix  func() (string, error)
+}
+
+// configDocument is a parsed configuration file that can be edited in place
+// and written back without losing comments or key ordering.
+type configDocument struct {
+	path  string
+	root  *yaml.Node
+	dirty bool
+}
+
+func defaultConfigPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".ollamamax", "quickstart-config.yaml")
+}
+
+func runValidate(configFile string, fix, quick bool) error {
+	fmt.Println("🔍 OllamaMax Configuration Validation")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+
+	if configFile == "" {
+		configFile = defaultConfigPath()
+	}
+	fmt.Printf("📄 Configuration: %s\n\n", configFile)
+
+	doc, check := loadConfigDocument(configFile)
+	checks := []*validationCheck{check}
+	if doc != nil {
+		checks = append(checks, checkConfigKeys(doc)...)
+		checks = append(checks, checkDirectories(doc)...)
+		if !quick {
+			checks = append(checks, checkPorts(doc)...)
+			checks = append(checks, checkCertificates(doc)...)
+		}
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if c.err == nil {
+			fmt.Printf("✅ %s: passed\n", c.name)
+			continue
+		}
+		failed++
+		fmt.Printf("❌ %s: %v\n", c.name, c.err)
+		if c.fix != nil && !fix {
+			fmt.Printf("   💡 can be fixed automatically with --fix\n")
+		}
+	}
+
+	var changes []string
+	if fix && failed > 0 {
+		fmt.Println()
+		fmt.Println("🔧 Applying automatic fixes...")
+		for _, c := range checks {
+			if c.err == nil || c.fix == nil {
+				continue
+			}
+			change, err := c.fix()
+			if err != nil {
+				fmt.Printf("   ❌ %s: %v\n", c.name, err)
+				continue
+			}
+			failed--
+			changes = append(changes, change)
+			fmt.Printf("   ✅ %s\n", change)
+		}
+
+		if doc != nil && doc.dirty {
+			backup, err := doc.save()
+			if err != nil {
+				return fmt.Errorf("failed to write configuration: %w", err)
+			}
+			fmt.Printf("   💾 Configuration updated (previous version saved to %s)\n", backup)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("📊 Validation Summary")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━")
+	if len(changes) > 0 {
+		fmt.Printf("🔧 %d change(s) applied\n", len(changes))
+	}
+	if failed > 0 {
+		fmt.Printf("❌ %d issue(s) remaining\n", failed)
+		return fmt.Errorf("validation failed with %d issue(s)", failed)
+	}
+	fmt.Println("✅ All validations passed - ready to start!")
+
+	return nil
+}
+
+// loadConfigDocument reads and parses the configuration file. The returned
+// check reports syntax problems; a missing file can be fixed by writing the
+// quickstart defaults.
+func loadConfigDocument(path string) (*configDocument, *validationCheck) {
+	check := &validationCheck{name: "Configuration file syntax"}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		check.err = fmt.Errorf("file not found")
+		check.fix = func() (string, error) {
+			if err := writeDefaultConfig(path, 8080); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("created default configuration at %s", path), nil
+		}
+		return nil, check
+	}
+	if err != nil {
+		check.err = err
+		return nil, check
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		check.err = fmt.Errorf("invalid YAML: %w", err)
+		return nil, check
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		check.err = fmt.Errorf("top level must be a mapping of sections")
+		return nil, check
+	}
+
+	return &configDocument{path: path, root: root.Content[0]}, check
+}
+
+// writeDefaultConfig writes the quickstart configuration to path, keeping the
+// data directories next to the file.
+func writeDefaultConfig(path string, port int) error {
+	if path == defaultConfigPath() {
+		return createQuickStartConfig(port)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(quickStartConfigContent(filepath.Dir(path), port)), 0644)
+}
+
+// lookup returns the key and value nodes for key in a mapping node.
+func lookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// get returns the scalar at a dotted path such as "api.port".
+func (d *configDocument) get(path string) (*yaml.Node, bool) {
+	node := d.root
+	for _, part := range strings.Split(path, ".") {
+		_, node = lookup(node, part)
+		if node == nil {
+			return nil, false
+		}
+	}
+	return node, node.Kind == yaml.ScalarNode
+}
+
+func (d *configDocument) getString(path string) string {
+	if node, ok := d.get(path); ok {
+		return node.Value
+	}
+	return ""
+}
+
+func (d *configDocument) set(path, value string) {
+	if node, ok := d.get(path); ok {
+		node.Value = value
+		d.dirty = true
+	}
+}
+
+// save writes the document back to disk after copying the original to a
+// .bak file, and returns the backup path.
+func (d *configDocument) save() (string, error) {
+	original, err := os.ReadFile(d.path)
+	if err != nil {
+		return "", err
+	}
+	backup := d.path + ".bak"
+	if err := os.WriteFile(backup, original, 0600); err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	return backup, os.WriteFile(d.path, []byte(sb.String()), info.Mode().Perm())
+}
+
+// checkConfigKeys flags keys that are not part of the configuration schema
+// and, where a close match exists, offers to rename them.
+func checkConfigKeys(doc *configDocument) []*validationCheck {
+	var checks []*validationCheck
+
+	sections := make([]string, 0, len(knownConfigKeys))
+	for section := range knownConfigKeys {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		mapping := doc.root
+		if section != "" {
+			_, mapping = lookup(doc.root, section)
+		}
+		if mapping == nil || mapping.Kind != yaml.MappingNode {
+			continue
+		}
+
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			keyNode := mapping.Content[i]
+			if containsString(knownConfigKeys[section], keyNode.Value) {
+				continue
+			}
+
+			qualified := keyNode.Value
+			if section != "" {
+				qualified = section + "." + keyNode.Value
+			}
+			check := &validationCheck{name: fmt.Sprintf("Configuration key %q", qualified)}
+
+			suggestion := closestKey(keyNode.Value, knownConfigKeys[section])
+			if suggestion == "" {
+				check.err = fmt.Errorf("unknown key (line %d)", keyNode.Line)
+			} else if k, _ := lookup(mapping, suggestion); k != nil {
+				check.err = fmt.Errorf("unknown key (line %d), did you mean %q? it is already set", keyNode.Line, suggestion)
+			} else {
+				check.err = fmt.Errorf("unknown key (line %d), did you mean %q?", keyNode.Line, suggestion)
+				check.fix = func() (string, error) {
+					old := keyNode.Value
+					keyNode.Value = suggestion
+					doc.dirty = true
+					return fmt.Sprintf("renamed key %q to %q (line %d)", old, suggestion, keyNode.Line), nil
+				}
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, &validationCheck{name: "Configuration keys"})
+	}
+	return checks
+}
+
+// closestKey returns the candidate within edit distance two of key, or the
+// empty string when there is no plausible match.
+func closestKey(key string, candidates []string) string {
+	best, bestDist := "", 3
+	normalized := strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	for _, c := range candidates {
+		if d := editDistance(normalized, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// checkDirectories makes sure the data, model and log directories exist and
+// are not writable by other users.
+func checkDirectories(doc *configDocument) []*validationCheck {
+	var dirs []string
+	if dataDir := doc.getString("node.data_dir"); dataDir != "" {
+		dirs = append(dirs, dataDir, filepath.Join(dataDir, "logs"))
+	}
+	if storePath := doc.getString("models.store_path"); storePath != "" {
+		dirs = append(dirs, storePath)
+	}
+
+	var checks []*validationCheck
+	for _, dir := range dirs {
+		dir := expandHome(dir)
+		check := &validationCheck{name: fmt.Sprintf("Directory %s", dir)}
+
+		info, err := os.Stat(dir)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			check.err = fmt.Errorf("does not exist")
+			check.fix = func() (string, error) {
+				if err := os.MkdirAll(dir, 0750); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("created %s with mode 0750", dir), nil
+			}
+		case err != nil:
+			check.err = err
+		case !info.IsDir():
+			check.err = fmt.Errorf("exists but is not a directory")
+		case info.Mode().Perm()&0700 != 0700 || info.Mode().Perm()&0002 != 0:
+			mode := info.Mode().Perm()
+			check.err = fmt.Errorf("has insecure or unusable permissions %#o", mode)
+			check.fix = func() (string, error) {
+				if err := os.Chmod(dir, 0750); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("changed permissions of %s from %#o to 0750", dir, mode), nil
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkPorts detects duplicate or already bound ports and reassigns them to
+// the next free port.
+func checkPorts(doc *configDocument) []*validationCheck {
+	keys := []string{"api.port"}
+	if enabled := doc.getString("web.enabled"); enabled == "" || enabled == "true" {
+		keys = append(keys, "web.port")
+	}
+
+	host := doc.getString("api.host")
+	used := make(map[int]string)
+	var checks []*validationCheck
+
+	for _, key := range keys {
+		raw := doc.getString(key)
+		if raw == "" {
+			continue
+		}
+		check := &validationCheck{name: fmt.Sprintf("Port %s", key)}
+		checks = append(checks, check)
+
+		port, err := strconv.Atoi(raw)
+		if err != nil || port <= 0 || port > 65535 {
+			check.err = fmt.Errorf("invalid port %q", raw)
+			continue
+		}
+
+		if other, dup := used[port]; dup {
+			check.err = fmt.Errorf("port %d is also used by %s", port, other)
+		} else if !portAvailable(host, port) {
+			check.err = fmt.Errorf("port %d is already in use", port)
+		}
+		used[port] = key
+
+		if check.err != nil {
+			check.fix = func() (string, error) {
+				free, err := nextFreePort(host, port+1, used)
+				if err != nil {
+					return "", err
+				}
+				used[free] = key
+				doc.set(key, strconv.Itoa(free))
+				return fmt.Sprintf("reassigned %s from %d to %d", key, port, free), nil
+			}
+		}
+	}
+	return checks
+}
+
+func portAvailable(host string, port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+func nextFreePort(host string, start int, used map[int]string) (int, error) {
+	for port := start; port <= 65535 && port < start+1000; port++ {
+		if _, taken := used[port]; taken {
+			continue
+		}
+		if portAvailable(host, port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port found after %d", start)
+}
+
+// checkCertificates verifies the API TLS certificate and regenerates a
+// self-signed pair when it is missing, unreadable or about to expire.
+func checkCertificates(doc *configDocument) []*validationCheck {
+	if doc.getString("api.tls_enabled") != "true" {
+		return nil
+	}
+
+	certFile := expandHome(doc.getString("api.cert_file"))
+	keyFile := expandHome(doc.getString("api.key_file"))
+	check := &validationCheck{name: "TLS certificate"}
+
+	if certFile == "" || keyFile == "" {
+		check.err = fmt.Errorf("tls_enabled is set but cert_file or key_file is empty")
+		return []*validationCheck{check}
+	}
+
+	if err := verifyCertificate(certFile, time.Now().Add(certRenewWindow)); err != nil {
+		check.err = err
+		check.fix = func() (string, error) {
+			hosts := []string{"localhost", "127.0.0.1"}
+			if host := doc.getString("api.host"); host != "" && host != "0.0.0.0" {
+				hosts = append(hosts, host)
+			}
+			if err := generateSelfSignedCert(certFile, keyFile, hosts, 365*24*time.Hour); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("regenerated self-signed certificate %s (valid for 365 days)", certFile), nil
+		}
+	}
+	return []*validationCheck{check}
+}
+
+func verifyCertificate(certFile string, notAfter time.Time) error {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", certFile, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("%s does not contain a PEM certificate", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %w", certFile, err)
+	}
+	if cert.NotAfter.Before(notAfter) {
+		return fmt.Errorf("certificate expires %s", cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func generateSelfSignedCert(certFile, keyFile string, hosts []string, validity time.Duration) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"OllamaMax"}, CommonName: hosts[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(f), 0750); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, path[2:])
+	}
+	return path
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
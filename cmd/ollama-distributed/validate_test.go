@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a configuration file into a temporary directory and
+// loads it
+func writeConfig(t *testing.T, content string) *configDocument {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	doc, check := loadConfigDocument(path)
+	require.NoError(t, check.err)
+	return doc
+}
+
+func TestClosestKey(t *testing.T) {
+	candidates := knownConfigKeys["api"]
+	assert.Equal(t, "port", closestKey("prot", candidates))
+	assert.Equal(t, "tls_enabled", closestKey("TLS-Enabled", candidates), "case and dashes are normalized")
+	assert.Equal(t, "cert_file", closestKey("cert_fil", candidates))
+	assert.Empty(t, closestKey("listen_address", candidates), "keys too far from any candidate have no match")
+}
+
+func TestCheckConfigKeysFix(t *testing.T) {
+	doc := writeConfig(t, "api:\n  # listening port\n  prot: 8080\n  host: localhost\n  hots: other\n")
+
+	checks := checkConfigKeys(doc)
+	require.Len(t, checks, 2)
+	assert.Contains(t, checks[0].err.Error(), `did you mean "port"?`)
+	require.NotNil(t, checks[0].fix)
+	assert.Contains(t, checks[1].err.Error(), "already set")
+	assert.Nil(t, checks[1].fix, "keys whose correction is already set are not renamed")
+
+	change, err := checks[0].fix()
+	require.NoError(t, err)
+	assert.Contains(t, change, `renamed key "prot" to "port"`)
+	backup, err := doc.save()
+	require.NoError(t, err)
+
+	saved, err := os.ReadFile(doc.path)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved), "# listening port", "comments are kept")
+	assert.Contains(t, string(saved), "port: 8080")
+	original, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	assert.Contains(t, string(original), "prot: 8080")
+
+	assert.Len(t, checkConfigKeys(writeConfig(t, "api:\n  port: 8080\n")), 1)
+	assert.NoError(t, checkConfigKeys(writeConfig(t, "api:\n  port: 8080\n"))[0].err)
+}
+
+func TestCheckDirectoriesFix(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	doc := writeConfig(t, "node:\n  data_dir: "+dataDir+"\n")
+
+	checks := checkDirectories(doc)
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		require.Error(t, check.err)
+		_, err := check.fix()
+		require.NoError(t, err)
+	}
+	info, err := os.Stat(filepath.Join(dataDir, "logs"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(dataDir, 0777))
+	checks = checkDirectories(doc)
+	require.Error(t, checks[0].err, "world-writable directories are flagged")
+	_, err = checks[0].fix()
+	require.NoError(t, err)
+	assert.NoError(t, checkDirectories(doc)[0].err)
+}
+
+func TestCheckPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	doc := writeConfig(t, "api:\n  host: 127.0.0.1\n  port: "+strconv.Itoa(busy)+"\nweb:\n  port: "+strconv.Itoa(busy)+"\n")
+	checks := checkPorts(doc)
+	require.Len(t, checks, 2)
+	assert.Contains(t, checks[0].err.Error(), "already in use")
+	assert.Contains(t, checks[1].err.Error(), "also used by api.port")
+
+	for _, check := range checks {
+		_, err := check.fix()
+		require.NoError(t, err)
+	}
+	api, web := doc.getString("api.port"), doc.getString("web.port")
+	assert.NotEqual(t, strconv.Itoa(busy), api)
+	assert.NotEqual(t, api, web, "reassigned ports do not collide")
+	assert.True(t, doc.dirty)
+
+	checks = checkPorts(writeConfig(t, "api:\n  port: http\nweb:\n  enabled: false\n  port: 1\n"))
+	require.Len(t, checks, 1, "the port of a disabled web server is not checked")
+	assert.Contains(t, checks[0].err.Error(), "invalid port")
+	assert.Nil(t, checks[0].fix)
+}
+
+func TestCheckCertificatesFix(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+	doc := writeConfig(t, strings.Join([]string{
+		"api:",
+		"  host: 127.0.0.1",
+		"  tls_enabled: true",
+		"  cert_file: " + certFile,
+		"  key_file: " + keyFile,
+	}, "\n")+"\n")
+
+	checks := checkCertificates(doc)
+	require.Len(t, checks, 1)
+	require.Error(t, checks[0].err)
+	_, err := checks[0].fix()
+	require.NoError(t, err)
+	assert.NoError(t, checkCertificates(doc)[0].err)
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.Error(t, verifyCertificate(certFile, time.Now().Add(400*24*time.Hour)), "certificates about to expire are flagged")
+}
@@ -88,7 +88,7 @@ func DefaultConfig() *Config {
 		Auth: AuthConfig{
 			Enabled:     getEnvBoolOrDefault("AUTH_ENABLED", true),
 			Method:      getEnvOrDefault("AUTH_METHOD", "jwt"),
-			TokenExpiry: 24 * time.Hour,
+			TokenExpiry: 15 * time.Minute,
 			SecretKey:   getEnvOrDefault("AUTH_SECRET_KEY", "your-secret-key-change-this"),
 			RefreshTime: 7 * 24 * time.Hour,
 		},
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
 	ollamaAPI "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ollama/api"
 )
 
@@ -116,6 +120,11 @@ func (s *DistributedOllamaServer) handlePullModel(c *gin.Context) {
 	// For now, treat pull as registering an existing local model file path
 	modelPath := "/tmp/models/" + req.Name
 	model, err := s.modelManager.AddModel(req.Name, modelPath)
+	var rejected *modelscan.RejectedError
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "scan": rejected.Report})
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to pull model", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -169,7 +178,32 @@ func (s *DistributedOllamaServer) handleDeleteModel(c *gin.Context) {
 
 	s.logger.Info("Received delete request", "model", req.Name)
 
-	if err := s.modelManager.RemoveModel(req.Name); err != nil {
+	// ?wait=30s waits up to that long for executing requests to finish
+	// instead of refusing to delete a model in use
+	var err error
+	if wait := c.Query("wait"); wait != "" {
+		timeout, parseErr := time.ParseDuration(wait)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wait duration"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		err = s.modelManager.RemoveModelWhenDrained(ctx, req.Name)
+	} else {
+		err = s.modelManager.RemoveModel(req.Name)
+	}
+
+	var inUse *models.ModelInUseError
+	if errors.As(err, &inUse) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           err.Error(),
+			"active_requests": inUse.ActiveRequests,
+			"replicas":        inUse.Replicas,
+		})
+		return
+	}
+	if err != nil {
 		s.logger.Error("Failed to delete model", "error", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -15,8 +15,11 @@ import (
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/api"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/inference"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/distributed"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
@@ -179,6 +182,17 @@ func NewDistributedOllamaServer(
 		return nil, fmt.Errorf("failed to create model manager: %w", err)
 	}
 
+	// Scan imported models, quarantining the ones failing a scan
+	if cfg.ModelScan.Enabled {
+		scanner, err := modelscan.NewPipeline(cfg.ModelScan)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to initialize model scanning: %w", err)
+		}
+		modelManager.SetScanner(scanner)
+		logger.Info("Model scanning enabled", "scanners", scanner.Scanners())
+	}
+
 	// Initialize partition manager
 	partitionManager := partitioning.NewPartitionManager(&partitioning.Config{
 		DefaultStrategy: "layerwise",
@@ -186,6 +200,20 @@ func NewDistributedOllamaServer(
 		BatchSizeLimit:  1024,
 	})
 
+	// Model affinity and anti-affinity, shared by replication and partitioning
+	placementPolicy, err := placement.NewPolicy(cfg.Placement)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid placement constraints: %w", err)
+	}
+	nodeLabels := placement.NewRegistry()
+	if err := nodeLabels.Set(p2pNode.ID().String(), placement.NodeLabels{Labels: cfg.Node.Labels, Taints: cfg.Node.Taints}); err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid node labels: %w", err)
+	}
+	modelManager.SetPlacementPolicy(placementPolicy, nodeLabels)
+	partitionManager.SetPlacementPolicy(placementPolicy)
+
 	// Initialize orchestration engine
 	orchestrator := orchestration.NewOrchestrationEngine(&orchestration.Config{
 		MaxConcurrentTasks: 100,
@@ -246,7 +274,7 @@ func NewDistributedOllamaServer(
 
 	// Setup HTTP router
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(logging.RequestID(), gin.Logger(), gin.Recovery())
 
 	server := &DistributedOllamaServer{
 		p2pNode:         p2pNode,
@@ -414,5 +442,6 @@ func setupLogging(level string) *slog.Logger {
 		Level: logLevel,
 	})
 
-	return slog.New(handler)
+	// Records logged with a request's context carry its request ID
+	return slog.New(logging.NewContextHandler(handler))
 }
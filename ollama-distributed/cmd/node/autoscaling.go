@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/autoscaling"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// idleUsagePercent is the CPU and GPU usage below which a node counts as idle
+const idleUsagePercent = 5.0
+
+// newNodePoolAutoscaler creates the node pool autoscaler from config. It
+// scales only while isLeader reports this node leads the cluster.
+func newNodePoolAutoscaler(cfg config.AutoscalingConfig, engine *scheduler.Engine, slo *observability.SLOMonitor, localID string, isLeader func() bool) (*autoscaling.AutoScaler, error) {
+	executor, err := autoscaling.NewNodePoolExecutor(&autoscaling.NodePoolConfig{
+		Provider: cfg.Provider,
+		AWS:      cfg.AWS,
+		GCP:      cfg.GCP,
+		Webhook:  cfg.Webhook,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scalerConfig := autoscaling.DefaultConfig()
+	scalerConfig.MinReplicas = cfg.MinNodes
+	scalerConfig.MaxReplicas = cfg.MaxNodes
+	scalerConfig.ScaleUpCooldown = cfg.ScaleUpCooldown
+	scalerConfig.ScaleDownCooldown = cfg.ScaleDownCooldown
+	if cfg.EvaluationInterval > 0 {
+		scalerConfig.EvaluationInterval = cfg.EvaluationInterval
+	}
+	scalerConfig.QueueThreshold = cfg.QueueThreshold
+	scalerConfig.GPUThreshold = cfg.GPUThreshold
+	scalerConfig.SLOBurnThreshold = cfg.SLOBurnThreshold
+	// Inference latency depends on the prompt; the SLO burn rate measures
+	// it against the objectives instead
+	scalerConfig.ResponseTimeThreshold = 0
+
+	collector := &clusterMetrics{
+		engine:    engine,
+		slo:       slo,
+		localID:   localID,
+		idleAfter: cfg.IdleAfter,
+		idleSince: make(map[string]time.Time),
+	}
+
+	scaler := autoscaling.NewAutoScaler(scalerConfig, collector, executor)
+	scaler.SetLeaderCheck(isLeader)
+	return scaler, nil
+}
+
+// clusterMetrics reports the scheduler's view of the cluster to the
+// autoscaler
+type clusterMetrics struct {
+	engine    *scheduler.Engine
+	slo       *observability.SLOMonitor
+	localID   string
+	idleAfter time.Duration
+
+	mu            sync.Mutex
+	idleSince     map[string]time.Time
+	lastCompleted int64
+	lastSample    time.Time
+}
+
+// averageUsage averages a usage figure over the online nodes
+func (m *clusterMetrics) averageUsage(usage func(scheduler.NodeUsage) float64) float64 {
+	nodes := m.engine.GetAvailableNodes()
+	if len(nodes) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, node := range nodes {
+		total += usage(node.Usage)
+	}
+	return total / float64(len(nodes))
+}
+
+func (m *clusterMetrics) GetCPUUtilization() float64 {
+	return m.averageUsage(func(u scheduler.NodeUsage) float64 { return u.CPU })
+}
+
+func (m *clusterMetrics) GetMemoryUtilization() float64 {
+	return m.averageUsage(func(u scheduler.NodeUsage) float64 { return u.Memory })
+}
+
+func (m *clusterMetrics) GetGPUUtilization() float64 {
+	return m.averageUsage(func(u scheduler.NodeUsage) float64 { return u.GPU })
+}
+
+func (m *clusterMetrics) GetQueueSize() int {
+	return int(m.engine.GetStats().QueuedRequests)
+}
+
+func (m *clusterMetrics) GetResponseTime() time.Duration {
+	return m.engine.GetStats().AverageLatency
+}
+
+// GetThroughput returns the completed requests per second since the last call
+func (m *clusterMetrics) GetThroughput() float64 {
+	completed := m.engine.GetStats().CompletedRequests
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var throughput float64
+	if !m.lastSample.IsZero() {
+		throughput = float64(completed-m.lastCompleted) / now.Sub(m.lastSample).Seconds()
+	}
+	m.lastCompleted = completed
+	m.lastSample = now
+	return throughput
+}
+
+func (m *clusterMetrics) GetActiveConnections() int {
+	return m.engine.GetActiveNodes()
+}
+
+// GetSLOBurnRate returns the highest burn rate among the objectives, taking
+// for each the lower of its short and long window rates so that a brief
+// spike alone does not add nodes
+func (m *clusterMetrics) GetSLOBurnRate() float64 {
+	if m.slo == nil {
+		return 0
+	}
+	burn := 0.0
+	for _, status := range m.slo.Status() {
+		burn = math.Max(burn, math.Min(status.BurnRateShort, status.BurnRateLong))
+	}
+	return burn
+}
+
+// GetIdleNodes returns the online nodes, other than this one, whose CPU and
+// GPU have been idle for longer than idleAfter
+func (m *clusterMetrics) GetIdleNodes() []string {
+	nodes := m.engine.GetAvailableNodes()
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	online := make(map[string]bool, len(nodes))
+	var idle []string
+	for _, node := range nodes {
+		online[node.ID] = true
+		if node.ID == m.localID || node.Usage.CPU >= idleUsagePercent || node.Usage.GPU >= idleUsagePercent {
+			delete(m.idleSince, node.ID)
+			continue
+		}
+		since, ok := m.idleSince[node.ID]
+		if !ok {
+			m.idleSince[node.ID] = now
+			continue
+		}
+		if now.Sub(since) >= m.idleAfter {
+			idle = append(idle, node.ID)
+		}
+	}
+	for id := range m.idleSince {
+		if !online[id] {
+			delete(m.idleSince, id)
+		}
+	}
+
+	sort.Strings(idle)
+	return idle
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/spf13/cobra"
+)
+
+func captureCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capture",
+		Short: "Inspect and replay captured inference requests",
+		Long: `List the inference requests a node captured for debugging and replay them,
+optionally against another model or load balancing strategy.`,
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List captured requests",
+		RunE:  runCaptureList,
+	}
+	list.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	list.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	list.Flags().String("file", "", "Read captures from a capture file instead of the API")
+	list.Flags().String("tenant", "", "Only list captures of this tenant")
+	list.Flags().String("model", "", "Only list captures of this model")
+	list.Flags().Int("limit", 50, "Maximum number of captures listed")
+	cmd.AddCommand(list)
+
+	replay := &cobra.Command{
+		Use:   "replay [capture-id...]",
+		Short: "Replay captured requests",
+		Long: `Re-send captured requests and compare status, latency and response with the
+capture. Without IDs, the captures matching --tenant and --captured-model are
+replayed.`,
+		Example: `  ollama-distributed capture replay 3f2a... --model mistral
+  ollama-distributed capture replay --file captures.jsonl --strategy least_connections --target http://staging:8080`,
+		RunE: runCaptureReplay,
+	}
+	replay.Flags().String("api-url", "http://localhost:8080", "API server URL the captures are read from")
+	replay.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	replay.Flags().String("file", "", "Read captures from a capture file instead of the API")
+	replay.Flags().String("target", "", "API server URL the requests are replayed against (default --api-url)")
+	replay.Flags().String("target-token", "", "Bearer token for the target (default --token)")
+	replay.Flags().String("model", "", "Replay against this model instead of the captured one")
+	replay.Flags().String("strategy", "", "Replay with this load balancing strategy")
+	replay.Flags().String("tenant", "", "Only replay captures of this tenant")
+	replay.Flags().String("captured-model", "", "Only replay captures of this model")
+	replay.Flags().Int("limit", 50, "Maximum number of captures replayed")
+	replay.Flags().Bool("json", false, "Print the results as JSON, including responses")
+	cmd.AddCommand(replay)
+
+	return cmd
+}
+
+func runCaptureList(cmd *cobra.Command, args []string) error {
+	tenant, _ := cmd.Flags().GetString("tenant")
+	model, _ := cmd.Flags().GetString("model")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	records, err := loadCaptures(cmd, capture.Filter{Tenant: tenant, Model: model, Limit: limit})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No captured requests")
+		return nil
+	}
+	fmt.Printf("%-36s %-19s %-20s %-12s %-20s %6s %10s\n", "CAPTURE", "TIME", "PATH", "TENANT", "MODEL", "STATUS", "LATENCY")
+	for _, r := range records {
+		fmt.Printf("%-36s %-19s %-20s %-12s %-20s %6d %10s\n", r.ID, r.Time.Local().Format(time.DateTime),
+			r.Path, r.Tenant, r.Model, r.Status, r.Latency.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func runCaptureReplay(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	target, _ := cmd.Flags().GetString("target")
+	targetToken, _ := cmd.Flags().GetString("target-token")
+	model, _ := cmd.Flags().GetString("model")
+	strategy, _ := cmd.Flags().GetString("strategy")
+	tenant, _ := cmd.Flags().GetString("tenant")
+	capturedModel, _ := cmd.Flags().GetString("captured-model")
+	limit, _ := cmd.Flags().GetInt("limit")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	if target == "" {
+		target = apiURL
+	}
+	if targetToken == "" {
+		targetToken = token
+	}
+
+	var records []*capture.Record
+	if len(args) == 0 {
+		var err error
+		records, err = loadCaptures(cmd, capture.Filter{Tenant: tenant, Model: capturedModel, Limit: limit})
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, id := range args {
+			rec, err := findCapture(cmd, id)
+			if err != nil {
+				return err
+			}
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		fmt.Println("No captured requests to replay")
+		return nil
+	}
+
+	opts := capture.ReplayOptions{Target: target, Token: targetToken, Model: model, Strategy: strategy}
+	var results []*capture.ReplayResult
+	failed := 0
+	if !asJSON {
+		fmt.Printf("%-36s %-20s %-20s %15s %23s  %s\n", "CAPTURE", "MODEL", "REPLAYED AS", "STATUS", "LATENCY", "RESPONSE")
+	}
+	for _, rec := range records {
+		result, err := capture.Replay(context.Background(), rec, opts)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "❌ %s: %v\n", rec.ID, err)
+			continue
+		}
+		results = append(results, result)
+		if asJSON {
+			continue
+		}
+
+		same := "differs"
+		if result.SameResponse {
+			same = "same"
+		}
+		fmt.Printf("%-36s %-20s %-20s %15s %23s  %s\n", result.ID, result.OriginalModel, result.Model,
+			fmt.Sprintf("%d -> %d", result.OriginalStatus, result.Status),
+			fmt.Sprintf("%s -> %s", result.OriginalLatency.Round(time.Millisecond), result.Latency.Round(time.Millisecond)),
+			same)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d replays failed", failed, len(records))
+	}
+	return nil
+}
+
+// loadCaptures reads the captures matching filter from the capture file or
+// the API, newest first
+func loadCaptures(cmd *cobra.Command, filter capture.Filter) ([]*capture.Record, error) {
+	if file, _ := cmd.Flags().GetString("file"); file != "" {
+		records, err := capture.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return filter.Apply(records), nil
+	}
+
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	query := url.Values{}
+	if filter.Tenant != "" {
+		query.Set("tenant", filter.Tenant)
+	}
+	if filter.Model != "" {
+		query.Set("model", filter.Model)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", fmt.Sprint(filter.Limit))
+	}
+
+	body, err := apiGet(strings.TrimSuffix(apiURL, "/")+"/api/v1/captures?"+query.Encode(), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list captures: %w", err)
+	}
+	var result struct {
+		Captures []*capture.Record `json:"captures"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Captures, nil
+}
+
+// findCapture returns a capture by ID from the capture file or the API
+func findCapture(cmd *cobra.Command, id string) (*capture.Record, error) {
+	if file, _ := cmd.Flags().GetString("file"); file != "" {
+		records, err := capture.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if rec.ID == id {
+				return rec, nil
+			}
+		}
+		return nil, fmt.Errorf("capture %s not found in %s", id, file)
+	}
+
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	body, err := apiGet(strings.TrimSuffix(apiURL, "/")+"/api/v1/captures/"+url.PathEscape(id), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get capture %s: %w", id, err)
+	}
+	var result struct {
+		Capture *capture.Record `json:"capture"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Capture, nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/spf13/cobra"
+)
+
+func clusterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Manage cluster membership and collect diagnostics",
+	}
+
+	token := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the join tokens new nodes need to join the cluster",
+		Long: `Manage join tokens. Nodes join the cluster only with a join token, which
+expires and is spent on first use. Tokens are created on the leader and embed
+the fingerprints of the cluster CA, so that joining nodes refuse a cluster that
+is not the one the token was created for.`,
+	}
+	token.PersistentFlags().String("api-url", "http://localhost:8080", "API server URL of the leader")
+	token.PersistentFlags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+
+	create := &cobra.Command{
+		Use:     "create",
+		Short:   "Create a single-use join token",
+		Example: `  ollama-distributed cluster token create --ttl 2h --description gpu-node-7`,
+		Args:    cobra.NoArgs,
+		RunE:    runClusterTokenCreate,
+	}
+	create.Flags().Duration("ttl", 0, "How long the token is valid (default from the cluster config)")
+	create.Flags().String("description", "", "What or whom the token is for")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List join tokens and the nodes that used them",
+		Args:  cobra.NoArgs,
+		RunE:  runClusterTokenList,
+	}
+	list.Flags().Bool("json", false, "Output in JSON format")
+
+	revoke := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke a join token before it is used",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runClusterTokenRevoke,
+	}
+
+	token.AddCommand(create, list, revoke)
+
+	snapshot := &cobra.Command{
+		Use:   "snapshot-state",
+		Short: "Save a diagnostics snapshot of the cluster for bug reports",
+		Long: `Save a single JSON bundle of the cluster's membership, model placement,
+recent events, health, metrics summary and versions, and the node's
+configuration with secrets redacted, to attach to bug reports. Requires an
+admin token.`,
+		Example: `  ollama-distributed cluster snapshot-state -o snapshot.json
+  ollama-distributed cluster snapshot-state --since 6h > snapshot.json`,
+		Args: cobra.NoArgs,
+		RunE: runClusterSnapshotState,
+	}
+	snapshot.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	snapshot.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	snapshot.Flags().String("since", "1h", "Include the events and metrics since this RFC 3339 time or duration ago")
+	snapshot.Flags().StringP("output", "o", "", "File to write the snapshot to (default stdout)")
+
+	cmd.AddCommand(token, snapshot)
+	return cmd
+}
+
+func runClusterTokenCreate(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	description, _ := cmd.Flags().GetString("description")
+
+	req := map[string]string{"description": description}
+	if ttl > 0 {
+		req["ttl"] = ttl.String()
+	}
+	body, err := apiPost(&http.Client{Timeout: 30 * time.Second}, strings.TrimSuffix(apiURL, "/")+"/api/v1/cluster/tokens", token, req)
+	if err != nil {
+		return fmt.Errorf("failed to create join token: %w", err)
+	}
+
+	var created struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	fmt.Println(created.Token)
+	fmt.Fprintf(os.Stderr, "\nSingle-use token, valid until %s. Join with:\n  ollama-distributed join --api-url <leader API URL> --token %s --peers <peer>\n",
+		created.ExpiresAt.Local().Format(time.RFC3339), created.Token)
+	return nil
+}
+
+func runClusterTokenList(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	body, err := apiGet(strings.TrimSuffix(apiURL, "/")+"/api/v1/cluster/tokens", token)
+	if err != nil {
+		return fmt.Errorf("failed to list join tokens: %w", err)
+	}
+	if asJSON {
+		fmt.Println(string(body))
+		return nil
+	}
+
+	var result struct {
+		Tokens []jointoken.Issued `json:"tokens"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	fmt.Printf("%-14s %-25s %-22s %s\n", "ID", "EXPIRES", "USED BY", "DESCRIPTION")
+	for _, t := range result.Tokens {
+		usedBy := "-"
+		if t.UsedBy != "" {
+			usedBy = t.UsedBy
+		} else if time.Now().After(t.ExpiresAt) {
+			usedBy = "(expired)"
+		}
+		fmt.Printf("%-14s %-25s %-22s %s\n", t.ID, t.ExpiresAt.Local().Format(time.RFC3339), usedBy, t.Description)
+	}
+	return nil
+}
+
+func runClusterTokenRevoke(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+
+	u := fmt.Sprintf("%s/api/v1/cluster/tokens/%s", strings.TrimSuffix(apiURL, "/"), url.PathEscape(args[0]))
+	if _, err := apiRequest(http.MethodDelete, u, token, 30*time.Second); err != nil {
+		return fmt.Errorf("failed to revoke join token: %w", err)
+	}
+	fmt.Printf("Join token %s revoked\n", args[0])
+	return nil
+}
+
+func runClusterSnapshotState(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	since, _ := cmd.Flags().GetString("since")
+	output, _ := cmd.Flags().GetString("output")
+
+	u := fmt.Sprintf("%s/api/v1/cluster/snapshot?since=%s", strings.TrimSuffix(apiURL, "/"), url.QueryEscape(since))
+	body, err := apiRequest(http.MethodGet, u, token, time.Minute)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster snapshot: %w", err)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	// The snapshot describes the cluster in detail, so it is kept private
+	// like the config it contains
+	if err := os.WriteFile(output, body, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Cluster snapshot written to %s. Review it before attaching it to a bug report.\n", output)
+	return nil
+}
+
+// redeemJoinToken spends a join token at the cluster API to admit this
+// node. The cluster CA is fetched and checked against the fingerprints in
+// the token before the token is sent, over TLS verified with that CA, so
+// that the token is neither spent on nor leaked to a spoofed cluster.
+func redeemJoinToken(ctx context.Context, apiURL, joinToken, nodeID, address string) error {
+	token, err := jointoken.Parse(joinToken)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(apiURL, "https://") {
+		return fmt.Errorf("join tokens need an https API URL to verify the cluster CA")
+	}
+	apiURL = strings.TrimSuffix(apiURL, "/")
+
+	// The CA is not trusted yet: it is vouched for by the token instead
+	untrusted := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/api/v1/cluster/ca", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := untrusted.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cluster CA: %w", err)
+	}
+	caPEM, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to fetch cluster CA: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch cluster CA: server returned %s", resp.Status)
+	}
+	pool, err := jointoken.TrustedPool(token, caPEM)
+	if err != nil {
+		return err
+	}
+
+	trusted := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}
+	body, err := apiPost(trusted, apiURL+"/api/v1/cluster/join", "", map[string]interface{}{
+		"node_id": nodeID,
+		"address": address,
+		"token":   joinToken,
+		"compat":  compat.Local(nodeID, version),
+	})
+	if err != nil {
+		return err
+	}
+	var joined struct {
+		Warning string `json:"warning"`
+	}
+	if json.Unmarshal(body, &joined) == nil && joined.Warning != "" {
+		fmt.Fprintf(os.Stderr, "⚠️  Unsupported version skew: %s\n", joined.Warning)
+	}
+	return nil
+}
+
+// apiPost sends a JSON body to a node API endpoint, returning the body of a
+// successful response
+func apiPost(client *http.Client, endpoint, token string, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
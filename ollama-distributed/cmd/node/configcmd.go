@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configuration settings",
+		Long:  "Inspect the settings a node accepts and how to set them",
+	}
+
+	envTemplate := &cobra.Command{
+		Use:   "env-template",
+		Short: "Print the environment variable of every setting with its default",
+		Long: `Print the OLLAMA_DISTRIBUTED_* environment variable of every setting with its
+default value, so that containers can be configured without a config file.
+Set ` + config.EnvOnlyVar + `=true to stop the node looking for one.
+
+Durations are written like "30s", lists are separated by commas and maps are
+written as "key=value,key=value". Lists of objects take YAML, such as
+'[{name: gpu0, port: 11435, gpus: ["0"]}]'.`,
+		Example: `  ollama-distributed config env-template > node.env
+  ollama-distributed config env-template --format markdown > docs/environment.md`,
+		Args: cobra.NoArgs,
+		RunE: runConfigEnvTemplate,
+	}
+	envTemplate.Flags().String("format", "env", "Output format (env, markdown)")
+	cmd.AddCommand(envTemplate)
+
+	return cmd
+}
+
+func runConfigEnvTemplate(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+
+	switch format {
+	case "env":
+		writeEnvTemplate(os.Stdout, config.EnvVars())
+	case "markdown":
+		writeEnvMarkdown(os.Stdout, config.EnvVars())
+	default:
+		return fmt.Errorf("unknown format %q (expected env or markdown)", format)
+	}
+	return nil
+}
+
+// writeEnvTemplate writes an env file with one commented variable per setting
+func writeEnvTemplate(w io.Writer, vars []config.EnvVar) {
+	fmt.Fprintf(w, "# ollama-distributed settings; uncomment and edit the ones to change\n")
+	fmt.Fprintf(w, "# %s=true\n", config.EnvOnlyVar)
+	section := ""
+	for _, v := range vars {
+		if top, _, _ := strings.Cut(v.Key, "."); top != section {
+			section = top
+			fmt.Fprintf(w, "\n# --- %s ---\n", section)
+		}
+		fmt.Fprintf(w, "# %s (%s)\n# %s=%s\n", v.Key, v.Type, v.Name, v.Default)
+	}
+}
+
+// writeEnvMarkdown writes the settings as a Markdown table
+func writeEnvMarkdown(w io.Writer, vars []config.EnvVar) {
+	fmt.Fprintf(w, "| Variable | Setting | Type | Default |\n")
+	fmt.Fprintf(w, "|---|---|---|---|\n")
+	fmt.Fprintf(w, "| `%s` | | bool | `false` |\n", config.EnvOnlyVar)
+	for _, v := range vars {
+		def := ""
+		if v.Default != "" {
+			def = "`" + strings.ReplaceAll(v.Default, "|", "\\|") + "`"
+		}
+		fmt.Fprintf(w, "| `%s` | `%s` | %s | %s |\n", v.Name, v.Key, v.Type, def)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// modelCapacity tells whether a model can take another request: the
+// scheduler queue is below the threshold and an online node holding the
+// model is below the usage threshold
+func modelCapacity(engine *scheduler.Engine, cfg degradation.Config, queueSize int) func(model string) bool {
+	return func(model string) bool {
+		if queueSize > 0 && float64(engine.GetStats().QueuedRequests) >= cfg.QueueThreshold*float64(queueSize) {
+			return false
+		}
+		info, ok := engine.GetModel(model)
+		if !ok {
+			return false
+		}
+		for _, node := range engine.GetAvailableNodes() {
+			if !slices.Contains(info.Locations, node.ID) {
+				continue
+			}
+			usage := node.Usage
+			if usage.CPU < cfg.UsageThreshold && usage.GPU < cfg.UsageThreshold && usage.Memory < cfg.UsageThreshold {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// sloBurning tells whether an objective covering a model burns its error
+// budget fast
+func sloBurning(monitor *observability.SLOMonitor) func(model string) bool {
+	return func(model string) bool {
+		for _, status := range monitor.Status() {
+			if status.FastBurn && (status.Model == "" || status.Model == model) {
+				return true
+			}
+		}
+		return false
+	}
+}
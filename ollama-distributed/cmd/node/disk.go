@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// localDiskSlow tells whether this node's disk is slow, by its labels and
+// the disk latency it last sampled
+func localDiskSlow(node *p2p.P2PNode, disk *placement.DiskScheduler, labels map[string]string) func() bool {
+	return func() bool {
+		view := placement.Node{ID: node.ID().String(), Labels: labels}
+		if metrics := node.GetResourceMetrics(); metrics != nil {
+			view.Disk = placement.DiskStats{
+				ReadRate:  metrics.DiskReadRate,
+				WriteRate: metrics.DiskWriteRate,
+				Latency:   metrics.DiskLatency,
+			}
+		}
+		return disk.Slow(view)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// clusterEventState returns the cluster state whose changes are recorded as
+// events: the Raft leader, the status of the nodes and the model replicas
+func clusterEventState(engine *scheduler.Engine, consensusEngine *consensus.Engine) events.State {
+	state := events.State{
+		Leader:     consensusEngine.Leader(),
+		Nodes:      make(map[string]string),
+		Placements: make(map[string][]string),
+	}
+	for id, node := range engine.GetNodes() {
+		state.Nodes[id] = string(node.Status)
+	}
+	for name, model := range engine.GetAllModels() {
+		if len(model.Locations) > 0 {
+			state.Placements[name] = append([]string(nil), model.Locations...)
+		}
+	}
+	return state
+}
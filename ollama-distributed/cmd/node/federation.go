@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// clusterManifest describes the local cluster to federation peers from the
+// scheduler's model registry and queue
+type clusterManifest struct {
+	engine *scheduler.Engine
+}
+
+// Models returns the models some online node of the cluster holds
+func (m *clusterManifest) Models() []federation.Model {
+	models := make([]federation.Model, 0)
+	for name, info := range m.engine.GetAllModels() {
+		if len(info.Locations) == 0 {
+			continue
+		}
+		models = append(models, federation.Model{Name: name, Size: info.Size, Digest: info.Checksum})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
+
+// Capacity returns the online nodes and the depth of the request queue
+func (m *clusterManifest) Capacity() federation.Capacity {
+	stats := m.engine.GetStats()
+	return federation.Capacity{
+		Nodes:      stats.NodesOnline,
+		QueueDepth: stats.QueuedRequests,
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+)
+
+// schedulerHistory samples the request rates and latency of the scheduler
+// and the utilization of every node
+func schedulerHistory(engine *scheduler.Engine) history.Source {
+	return func() []history.Sample {
+		stats := engine.GetStats()
+		samples := []history.Sample{
+			{Metric: "requests_per_second", Value: float64(stats.TotalRequests), Counter: true},
+			{Metric: "failed_requests_per_second", Value: float64(stats.FailedRequests), Counter: true},
+			{Metric: "latency_ms", Value: float64(stats.AverageLatency.Milliseconds())},
+			{Metric: "queued_requests", Value: float64(stats.QueuedRequests)},
+			{Metric: "nodes_online", Value: float64(stats.NodesOnline)},
+		}
+		for id, node := range engine.GetNodes() {
+			samples = append(samples,
+				history.Sample{Metric: "node_cpu_percent", Key: id, Value: node.Usage.CPU},
+				history.Sample{Metric: "node_memory_percent", Key: id, Value: node.Usage.Memory},
+				history.Sample{Metric: "node_gpu_percent", Key: id, Value: node.Usage.GPU},
+			)
+		}
+		return samples
+	}
+}
+
+// strategyHistory samples how much and how well every partitioning
+// strategy is used
+func strategyHistory(partitions *partitioning.PartitionManager) history.Source {
+	return func() []history.Sample {
+		var samples []history.Sample
+		for _, metrics := range partitions.StrategyMetrics() {
+			samples = append(samples,
+				history.Sample{Metric: "strategy_uses_per_second", Key: metrics.Name, Value: float64(metrics.UsageCount), Counter: true},
+				history.Sample{Metric: "strategy_success_rate", Key: metrics.Name, Value: metrics.SuccessRate},
+				history.Sample{Metric: "strategy_latency_ms", Key: metrics.Name, Value: float64(metrics.AverageLatency.Milliseconds())},
+			)
+		}
+		return samples
+	}
+}
+
+// anomalyAnnotations annotates history charts with the anomalies detected
+// over their range
+func anomalyAnnotations(detector *observability.AnomalyDetector) func(since, until time.Time) []history.Annotation {
+	return func(since, until time.Time) []history.Annotation {
+		var annotations []history.Annotation
+		for _, anomaly := range detector.Anomalies(since) {
+			if !anomaly.Start.Before(until) {
+				continue
+			}
+			annotations = append(annotations, history.Annotation{
+				Time:  anomaly.Start,
+				End:   anomaly.End,
+				Title: anomaly.Message,
+				Tags:  []string{anomaly.Kind, anomaly.Model, anomaly.NodeID},
+			})
+		}
+		return annotations
+	}
+}
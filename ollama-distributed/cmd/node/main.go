@@ -7,26 +7,79 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/api"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth/sso"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/backpressure"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/faultinject"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/features"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/georoute"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/idempotency"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ingest"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/integration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/kubernetes"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/licensing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/maintenance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/messaging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/monitoring"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
 	_ "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/performance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/rag"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/replicascale"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/s3gateway"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/sysmetrics"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/timesync"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/usage"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/web"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	zlog "github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -86,7 +139,17 @@ Documentation: https://github.com/KhryptorGraphics/OllamaMax`,
 	rootCmd.AddCommand(startCmd())
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(joinCmd())
+	rootCmd.AddCommand(clusterCmd())
 	rootCmd.AddCommand(proxyCmd())
+	rootCmd.AddCommand(secretsCmd())
+	rootCmd.AddCommand(profileCmd())
+	rootCmd.AddCommand(planCmd())
+	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(captureCmd())
+	rootCmd.AddCommand(verifyCmd())
+	rootCmd.AddCommand(simulateCmd())
+	rootCmd.AddCommand(topCmd())
+	rootCmd.AddCommand(selfUpdateCmd())
 
 	// Initialize user experience commands
 	initHelpCommands()
@@ -109,7 +172,9 @@ func startCmd() *cobra.Command {
 	}
 
 	cmd.Flags().String("listen", "0.0.0.0:11434", "Address to listen on")
+	cmd.Flags().StringSlice("listen-addr", []string{}, "Additional API listen addresses (e.g. [::]:11434)")
 	cmd.Flags().String("p2p-listen", "0.0.0.0:4001", "P2P listen address")
+	cmd.Flags().StringSlice("p2p-listen-addr", []string{}, "Additional P2P listen multiaddrs (e.g. /ip6/::/tcp/4001, /ip4/0.0.0.0/udp/4001/quic-v1)")
 	cmd.Flags().StringSlice("bootstrap", []string{}, "Bootstrap peers")
 	cmd.Flags().String("data-dir", "./data", "Data directory")
 	cmd.Flags().Bool("enable-web", true, "Enable web control panel")
@@ -131,11 +196,15 @@ func joinCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "join",
 		Short: "Join an existing cluster",
-		Long:  "Join an existing distributed Ollama cluster",
-		RunE:  runJoin,
+		Long: `Join an existing distributed Ollama cluster. Clusters with join tokens
+enabled require a token created on the leader by "cluster token create"; the
+cluster CA is checked against the token before joining.`,
+		RunE: runJoin,
 	}
 
 	cmd.Flags().StringSlice("peers", []string{}, "Peer addresses to join")
+	cmd.Flags().String("token", "", "Join token from \"cluster token create\"")
+	cmd.Flags().String("api-url", "", "HTTPS API URL of a cluster node to redeem --token at, e.g. https://leader:8080")
 	cmd.MarkFlagRequired("peers")
 
 	return cmd
@@ -216,6 +285,16 @@ func runStart(cmd *cobra.Command, args []string) error {
 		log.Printf("🔧 Overriding P2P listen with CLI flag: %s", p2pListen)
 		cfg.P2P.Listen = p2pListen
 	}
+	if cmd.Flags().Changed("listen-addr") {
+		listenAddrs, _ := cmd.Flags().GetStringSlice("listen-addr")
+		log.Printf("🔧 Adding API listen addresses from CLI flag: %v", listenAddrs)
+		cfg.API.ListenAddresses = append(cfg.API.ListenAddresses, listenAddrs...)
+	}
+	if cmd.Flags().Changed("p2p-listen-addr") {
+		p2pListenAddrs, _ := cmd.Flags().GetStringSlice("p2p-listen-addr")
+		log.Printf("🔧 Adding P2P listen addresses from CLI flag: %v", p2pListenAddrs)
+		cfg.P2P.ListenAddresses = append(cfg.P2P.ListenAddresses, p2pListenAddrs...)
+	}
 	if cmd.Flags().Changed("bootstrap") {
 		bootstrap, _ := cmd.Flags().GetStringSlice("bootstrap")
 		log.Printf("🔧 Overriding P2P bootstrap with CLI flag: %v", bootstrap)
@@ -230,14 +309,37 @@ func runStart(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Keep recent logs in memory so they can be read from the dashboard
+	logBuffer := logging.NewRingBuffer(cfg.Logging.BufferSize)
+	log.SetOutput(io.MultiWriter(os.Stderr, logBuffer))
+	zlog.Logger = zlog.Output(io.MultiWriter(os.Stderr, logBuffer))
+
+	// Resolve credentials referenced from secret stores before anything uses them
+	if err := resolveSecrets(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	if cfg.Logging.Ship.Enabled {
+		shipper, err := newLogShipper(cfg, logBuffer)
+		if err != nil {
+			return fmt.Errorf("failed to create log shipper: %w", err)
+		}
+		shipper.Start()
+		defer shipper.Stop()
+		log.Printf("📦 Shipping logs to %s at %s", cfg.Logging.Ship.Type, cfg.Logging.Ship.URL)
+	}
+
 	// Initialize P2P networking with full configuration
 	p2pNode, err := p2p.NewNode(ctx, &cfg.P2P)
 	if err != nil {
 		return fmt.Errorf("failed to create P2P node: %w", err)
 	}
+	p2pNode.SetResourceCollector(sysmetrics.NewCollector(sysmetrics.Options{DataDir: cfg.Storage.DataDir}))
 
 	// Create messaging and monitoring components
-	messageRouter := messaging.NewMessageRouter(nil)
+	routerConfig := messaging.DefaultRouterConfig()
+	routerConfig.QoS = cfg.P2P.QoS
+	messageRouter := messaging.NewMessageRouter(routerConfig)
 	networkMonitor := monitoring.NewNetworkMonitor(nil)
 
 	// Initialize consensus engine
@@ -251,6 +353,10 @@ func runStart(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler: %w", err)
 	}
+	nodeLabels := placement.NodeLabels{Labels: cfg.Node.Labels, Taints: cfg.Node.Taints}
+	if err := schedulerEngine.SetNodeLabels(p2pNode.ID().String(), nodeLabels); err != nil {
+		return fmt.Errorf("failed to set node labels: %w", err)
+	}
 
 	// Initialize performance monitoring
 	log.Printf("📊 Initializing performance monitoring...")
@@ -266,7 +372,16 @@ func runStart(cmd *cobra.Command, args []string) error {
 	// Create Prometheus exporter for metrics
 	prometheusConfig := observability.DefaultPrometheusConfig()
 	prometheusConfig.ListenAddress = ":9090"
-	prometheusExporter := observability.NewPrometheusExporter(prometheusConfig)
+	metricsRegistry := observability.NewMetricsRegistry(&observability.MetricsConfig{
+		Namespace:          prometheusConfig.Namespace,
+		Subsystem:          prometheusConfig.Subsystem,
+		CollectionInterval: 15 * time.Second,
+		EnablePrometheus:   true,
+		PrometheusConfig:   prometheusConfig,
+	})
+	prometheusExporter := metricsRegistry.GetPrometheusExporter()
+	metricsIntegration := observability.NewMetricsIntegration(metricsRegistry, p2pNode.ID().String())
+	messageRouter.SetQueueDelayObserver(metricsIntegration.GetP2PIntegrator().ReportQueueDelay)
 
 	log.Printf("✅ Performance monitoring initialized")
 
@@ -276,6 +391,329 @@ func runStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create API server: %w", err)
 	}
 
+	apiServer.SetLogBuffer(logBuffer)
+	apiServer.SetLifecycleConfig(cfg.Kubernetes)
+	apiServer.SetDiagnostics(version, cfg)
+
+	// Keep the partition plans of this node for inspection and export
+	partitionManager := partitioning.NewPartitionManager(&partitioning.Config{
+		DefaultStrategy: "layerwise",
+		LayerThreshold:  10,
+		BatchSizeLimit:  1024,
+		Deterministic:   cfg.Scheduler.Deterministic,
+
+		SelectionHistorySize: cfg.Scheduler.SelectionHistorySize,
+		SelectionRetention:   cfg.Scheduler.SelectionRetention,
+	})
+	partitionManager.RegisterStrategy(partitioning.NewLayerwiseStrategy())
+	partitionManager.RegisterStrategy(partitioning.NewDataSplitStrategy())
+	partitionManager.RegisterStrategy(partitioning.NewTaskParallelismStrategy())
+	partitionManager.RegisterStrategy(partitioning.NewHybridParallelismStrategy())
+	if err := partitionManager.ConfigureStrategies(cfg.Scheduler.Partitioning.Strategies); err != nil {
+		return fmt.Errorf("invalid partition strategy settings: %w", err)
+	}
+	for _, path := range cfg.Scheduler.StrategyPlugins {
+		name, err := partitionManager.LoadStrategyPlugin(path)
+		if err != nil {
+			return fmt.Errorf("failed to load strategy plugin: %w", err)
+		}
+		log.Printf("🧩 Loaded partition strategy %s from %s", name, path)
+	}
+	if cfg.Scheduler.StrategyRules != "" {
+		names, err := partitionManager.LoadRulesFile(cfg.Scheduler.StrategyRules)
+		if err != nil {
+			return fmt.Errorf("failed to load strategy rules: %w", err)
+		}
+		log.Printf("🧩 Loaded partition strategies %v from %s", names, cfg.Scheduler.StrategyRules)
+	}
+	placementPolicy, err := placement.NewPolicy(cfg.Placement)
+	if err != nil {
+		return fmt.Errorf("invalid placement constraints: %w", err)
+	}
+	schedulerEngine.SetPlacementPolicy(placementPolicy)
+	partitionManager.SetPlacementPolicy(placementPolicy)
+
+	// Keep large model loads off busy slow disks and frequently swapped
+	// models on NVMe nodes
+	var diskScheduler *placement.DiskScheduler
+	if cfg.DiskPlacement.Enabled {
+		diskScheduler = placement.NewDiskScheduler(cfg.DiskPlacement)
+		schedulerEngine.SetDiskScheduler(diskScheduler)
+		log.Printf("💽 Disk aware model placement enabled (slow disks above %s)", diskScheduler.Config().SlowLatency)
+	}
+
+	// Score peers by their behaviour, keeping misbehaving ones out of
+	// scheduling and blob transfers
+	var peerReputation *reputation.Tracker
+	if cfg.P2P.Reputation.Enabled {
+		peerReputation = reputation.NewTracker(cfg.P2P.Reputation)
+		peerReputation.SetObserver(func(score reputation.PeerScore) {
+			if score.Quarantined {
+				log.Printf("🚧 Peer %s quarantined with score %.0f", score.PeerID, score.Score)
+				return
+			}
+			log.Printf("🚧 Peer %s released from quarantine with score %.0f", score.PeerID, score.Score)
+		})
+		p2pNode.SetReputation(peerReputation)
+		schedulerEngine.SetQuarantine(peerReputation.Quarantined)
+		apiServer.SetReputation(peerReputation)
+		log.Printf("🚧 Peer reputation enabled, quarantining peers scoring below %.0f", peerReputation.Config().QuarantineBelow)
+	}
+
+	// Quarantined nodes, flapping or taken out by hand, keep receiving the
+	// Raft log as non-voters so they cannot stall the quorum. The scheduler
+	// knows nodes by peer ID, which Raft members have as their address. A
+	// new leader reconciles the suffrage of every member, since only the
+	// leader at the time of a quarantine can apply it.
+	reconcileSuffrage := func() {
+		if err := consensusEngine.ReconcileSuffrage(schedulerEngine.IsNodeQuarantined); err != nil {
+			log.Printf("⚠️  Failed to change the Raft suffrage of quarantined nodes: %v", err)
+		}
+	}
+	schedulerEngine.SetQuarantineObserver(func(status scheduler.FlapStatus) {
+		if status.Quarantined {
+			log.Printf("🚧 Node %s quarantined: %s", status.NodeID, status.Reason)
+		} else {
+			log.Printf("🚧 Node %s released from quarantine", status.NodeID)
+		}
+		if consensusEngine.IsLeader() {
+			go reconcileSuffrage()
+		}
+	})
+	consensusEngine.SetLeadershipObserver(func(isLeader bool) {
+		if isLeader {
+			reconcileSuffrage()
+		}
+	})
+	if cfg.Scheduler.FlapQuarantine.Enabled {
+		log.Printf("🚧 Quarantining flapping nodes")
+	}
+
+	// Advertise this node's version and protocols to its peers, refuse
+	// incompatible ones and keep new wire behaviors off until every member
+	// supports them
+	compatibility := compat.NewCluster(compat.Local(p2pNode.ID().String(), version), cfg.Compatibility)
+	compatibility.SetObserver(func(member compat.Member) {
+		log.Printf("⚠️  Unsupported version skew: %s", member.Warning)
+	})
+	p2pNode.StartHandshakes(ctx, compatibility)
+	apiServer.SetCompatibility(compatibility)
+	// Refused peers are not given a Raft vote either
+	consensusEngine.SetMembershipCheck(func(address string) error {
+		if id, err := peer.Decode(address); err == nil && p2pNode.Blocked(id) {
+			return fmt.Errorf("peer %s is refused as incompatible", address)
+		}
+		return nil
+	})
+
+	// Let nodes running self-update take turns to restart into a new version
+	apiServer.SetUpgradeCoordinator(selfupdate.NewCoordinator(cfg.SelfUpdate.WithDefaults().LeaseTTL, consensusEngine))
+
+	// Judge nodes by the heartbeats they send each other rather than by
+	// fixed interval health checks, and time the heartbeats to map the
+	// latencies between nodes
+	if cfg.FailureDetector.Enabled || cfg.Topology.Enabled {
+		interval := cfg.FailureDetector.WithDefaults().HeartbeatInterval
+		onHeartbeat := func(string, time.Time) {}
+		if cfg.FailureDetector.Enabled {
+			detector := failuredetector.NewDetector(cfg.FailureDetector)
+			onHeartbeat = detector.Heartbeat
+			schedulerEngine.SetFailureDetector(detector, interval)
+			apiServer.SetFailureDetector(detector)
+			log.Printf("💓 Heartbeats every %s, suspecting nodes from phi %.1f", interval, detector.Config().Threshold)
+		}
+		var matrix *topology.Matrix
+		if cfg.Topology.Enabled {
+			matrix = topology.NewMatrix(p2pNode.ID().String(), cfg.Topology)
+			partitionManager.SetTopology(matrix.Latency)
+			apiServer.SetTopology(matrix)
+			log.Printf("🗺️  Mapping the latencies between nodes every %s", interval)
+		}
+		p2pNode.StartHeartbeats(ctx, interval, onHeartbeat, matrix)
+	}
+
+	// Nodes join only with single-use join tokens bound to the cluster CA
+	if cfg.JoinTokens.Enabled {
+		caPEM, err := os.ReadFile(cfg.Security.TLS.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read cluster CA: %w", err)
+		}
+		joinTokens, err := jointoken.NewManager(cfg.JoinTokens, caPEM, consensusEngine)
+		if err != nil {
+			return fmt.Errorf("failed to initialize join tokens: %w", err)
+		}
+		apiServer.SetJoinTokens(joinTokens)
+		p2pNode.SetAdmission(func(id peer.ID) bool { return joinTokens.Admitted(id.String()) })
+		log.Printf("🎟️  Joining requires a join token (cluster CA %s)", joinTokens.Fingerprints()[0])
+	}
+	partitionManager.SetFailureObserver(metricsIntegration.GetSchedulerIntegrator().ReportPartitionFailure)
+	partitionManager.SetSelectionObserver(metricsIntegration.GetSchedulerIntegrator().ReportStrategySelection)
+	apiServer.SetPartitionManager(partitionManager)
+	apiServer.SetPlacementPolicy(placementPolicy)
+
+	// Feature flags gating experimental capabilities, shared through
+	// consensus
+	if cfg.Features.Enabled {
+		featureFlags, err := features.NewManager(cfg.Features, p2pNode.ID().String(), consensusEngine)
+		if err != nil {
+			return fmt.Errorf("failed to create feature flags: %w", err)
+		}
+		partitionManager.SetStrategyGate(func(strategy string, task *partitioning.PartitionTask) bool {
+			return featureFlags.Allows(features.StrategyFlag(strategy), task.ID)
+		})
+		p2pNode.SetQUICGate(func() bool {
+			return featureFlags.Allows(features.QUICTransport, "")
+		})
+		apiServer.SetFeatures(featureFlags)
+		go featureFlags.Run(ctx, cfg.Features.SyncInterval)
+		log.Printf("🚩 Feature flags enabled with %d initial flag(s)", len(cfg.Features.Flags))
+	}
+
+	// Redaction of prompts and completions in logs, captures and alerts
+	var redactor *redaction.Engine
+	if cfg.Redaction.Enabled {
+		redactor, err = redaction.NewEngine(cfg.Redaction)
+		if err != nil {
+			return fmt.Errorf("failed to initialize redaction: %w", err)
+		}
+		apiServer.SetRedaction(redactor)
+		log.Printf("🕶️  Redacting prompts and completions with %d tenant policy(ies)", len(cfg.Redaction.Tenants))
+	}
+
+	// Hooks transforming inference requests and responses
+	if cfg.Transforms.Enabled {
+		transforms, err := transform.NewEngine(cfg.Transforms)
+		if err != nil {
+			return fmt.Errorf("failed to initialize transformation hooks: %w", err)
+		}
+		apiServer.SetTransforms(transforms)
+		log.Printf("🪝 Transforming inference traffic with %d hook(s)", len(cfg.Transforms.Hooks))
+	}
+
+	// Moderation of prompts and responses by a moderation model on the
+	// cluster
+	if cfg.Moderation.Enabled {
+		moderator, err := moderation.NewModerator(cfg.Moderation, rag.NewClusterGenerator(apiServer.ModelEndpoints))
+		if err != nil {
+			return fmt.Errorf("failed to initialize moderation: %w", err)
+		}
+		apiServer.SetModeration(moderator)
+		log.Printf("🛡️  Moderating prompts and responses with %s", cfg.Moderation.Model)
+	}
+
+	// Maintenance windows, during which nodes are drained, take no new
+	// replicas and raise no alerts
+	var maintenanceManager *maintenance.Manager
+	if cfg.Maintenance.Enabled {
+		maintenanceManager, err = maintenance.NewManager(cfg.Maintenance, p2pNode.ID().String(),
+			&maintenanceCluster{engine: schedulerEngine})
+		if err != nil {
+			return fmt.Errorf("failed to create maintenance manager: %w", err)
+		}
+		apiServer.SetMaintenance(maintenanceManager)
+		go maintenanceManager.Run(ctx, cfg.Maintenance.CheckInterval)
+		log.Printf("🛠️  Honoring %d maintenance window(s)", len(cfg.Maintenance.Windows))
+	}
+
+	// Fault tolerance manager of this node's scheduler, recovering detected
+	// faults and holding high blast radius recoveries for approval
+	if cfg.FaultTolerance.Enabled || cfg.FaultInjection.Enabled {
+		recoveryApproval, err := fault_tolerance.ParseBlastRadius(cfg.FaultTolerance.RecoveryApproval)
+		if err != nil {
+			return fmt.Errorf("invalid fault tolerance config: %w", err)
+		}
+		faultTolerance := fault_tolerance.NewFaultToleranceManager(&fault_tolerance.Config{
+			ReplicationFactor:   cfg.Replication.DefaultReplicationFactor,
+			HealthCheckInterval: cfg.Scheduler.HealthCheckInterval,
+			MaxRetries:          cfg.Scheduler.MaxRetries,
+			RetryBackoff:        cfg.Scheduler.RetryDelay,
+			RecoveryApproval:    recoveryApproval,
+		})
+		if err := faultTolerance.Start(); err != nil {
+			return fmt.Errorf("failed to start fault tolerance: %w", err)
+		}
+		defer faultTolerance.Shutdown(context.Background())
+		defer faultTolerance.WatchNodes(schedulerEngine)()
+		apiServer.SetFaultTolerance(faultTolerance)
+		if recoveryApproval != "" {
+			log.Printf("🩺 Recoveries with a %s blast radius wait for approval", recoveryApproval)
+		}
+
+		// Synthetic faults injected by admins on staging clusters
+		if cfg.FaultInjection.Enabled {
+			injector, err := faultinject.NewInjector(cfg.FaultInjection, cfg.Node.Environment, faultTolerance, schedulerEngine)
+			if err != nil {
+				return fmt.Errorf("failed to enable fault injection: %w", err)
+			}
+			apiServer.SetFaultInjector(injector)
+			go injector.Run(ctx, cfg.FaultInjection.CheckInterval)
+			log.Printf("💥 Fault injection enabled in the %s environment", cfg.Node.Environment)
+		}
+	}
+
+	// Service level objectives
+	var sloMonitor *observability.SLOMonitor
+	if len(cfg.Metrics.SLO.Objectives) > 0 {
+		sloMonitor = newSLOMonitor(cfg.Metrics.SLO, maintenanceManager, redactor)
+		metricsIntegration.SetSLOMonitor(sloMonitor)
+		apiServer.SetSLOMonitor(sloMonitor)
+		sloMonitor.Start()
+		defer sloMonitor.Stop()
+		log.Printf("🎯 Tracking %d service level objectives", len(cfg.Metrics.SLO.Objectives))
+	}
+
+	// Goroutine and memory leak watchdog
+	if cfg.Metrics.Watchdog.Enabled {
+		watchdog := newWatchdog(cfg.Metrics.Watchdog, maintenanceManager, redactor)
+		apiServer.SetWatchdog(watchdog)
+		watchdog.Start()
+		defer watchdog.Stop()
+		log.Printf("🐕 Leak watchdog sampling every %s", cfg.Metrics.Watchdog.Interval)
+	}
+
+	// Latency and error anomalies of models on nodes
+	var anomalyDetector *observability.AnomalyDetector
+	if cfg.Metrics.Anomaly.Enabled {
+		anomalyDetector = newAnomalyDetector(cfg.Metrics.Anomaly, maintenanceManager, redactor)
+		metricsIntegration.SetAnomalyDetector(anomalyDetector)
+		apiServer.SetAnomalyDetector(anomalyDetector)
+		anomalyDetector.Start()
+		defer anomalyDetector.Stop()
+		log.Printf("🚨 Detecting latency and error anomalies every %s", cfg.Metrics.Anomaly.Interval)
+	}
+
+	// Smaller fallback models under overload
+	if cfg.Degradation.Enabled {
+		degrader := degradation.NewDegrader(cfg.Degradation)
+		degrader.SetCapacity(modelCapacity(schedulerEngine, degrader.Config(), cfg.Scheduler.QueueSize))
+		if sloMonitor != nil {
+			degrader.SetBurning(sloBurning(sloMonitor))
+		}
+		degrader.SetObserver(metricsIntegration.GetModelIntegrator().ReportModelFallback)
+		apiServer.SetDegradation(degrader)
+		log.Printf("🪂 Falling back to smaller models for %d model(s) under overload", len(cfg.Degradation.Chains))
+	}
+
+	// Slow streaming clients are buffered for up to a bound, then paused,
+	// coalesced or disconnected
+	if cfg.Backpressure.Enabled {
+		monitor, err := backpressure.NewMonitor(cfg.Backpressure)
+		if err != nil {
+			return fmt.Errorf("failed to initialize backpressure: %w", err)
+		}
+		apiServer.SetBackpressure(monitor)
+		log.Printf("🐢 Buffering up to %d bytes per streaming client, then %s", monitor.Config().MaxBufferBytes, monitor.Config().Policy)
+	}
+
+	// Clock skew against NTP, checked now and periodically
+	if cfg.TimeSync.Enabled {
+		timeSync := timesync.NewChecker(cfg.TimeSync)
+		schedulerEngine.SetClockSkewThreshold(timeSync.WarnThreshold())
+		apiServer.SetTimeSync(timeSync)
+		go timeSync.Start(ctx)
+		log.Printf("🕒 Checking clock skew against NTP")
+	}
+
 	// Initialize web server
 	log.Printf("🌐 Initializing web server...")
 	webConfig := web.DefaultConfig()
@@ -302,6 +740,224 @@ func runStart(cmd *cobra.Command, args []string) error {
 	webServer := web.NewWebServer(webConfig, apiServer)
 	log.Printf("✅ Web server initialized on %s", webConfig.ListenAddress)
 
+	// Single sign-on
+	if cfg.Security.Auth.Enabled && cfg.Security.Auth.Method == "oidc" {
+		oidcCfg := cfg.Security.Auth.OIDC
+		oidcProvider, err := sso.NewOIDCProvider(context.Background(), sso.OIDCConfig{
+			IssuerURL:    oidcCfg.IssuerURL,
+			ClientID:     oidcCfg.ClientID,
+			ClientSecret: oidcCfg.ClientSecret,
+			RedirectURL:  oidcCfg.RedirectURL,
+			Scopes:       oidcCfg.Scopes,
+			Audiences:    oidcCfg.Audiences,
+			GroupsClaim:  oidcCfg.GroupsClaim,
+			RoleMappings: oidcCfg.RoleMappings,
+			DefaultRole:  oidcCfg.DefaultRole,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize OIDC provider: %w", err)
+		}
+		apiServer.SetOIDCProvider(oidcProvider)
+		webServer.EnableOIDC(oidcProvider)
+		log.Printf("🔐 OIDC single sign-on enabled (issuer %s)", oidcProvider.Issuer())
+	}
+
+	// Local accounts, managed by admins through the API
+	var accounts session.Authenticator = session.StaticUsers(cfg.Web.Sessions.Users)
+	if cfg.Users.Enabled {
+		store, err := users.OpenStore(cfg.Users)
+		if err != nil {
+			return fmt.Errorf("failed to open user store: %w", err)
+		}
+		directory := users.NewDirectory(store, cfg.Users)
+		defer directory.Close()
+		generated, err := directory.Bootstrap(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to create the initial admin: %w", err)
+		}
+		if generated != "" {
+			// The password is kept out of the logs, which are shipped and
+			// served by the API
+			path, err := saveAdminPassword(cfg.Storage.DataDir, generated)
+			if err != nil {
+				return fmt.Errorf("failed to save the initial admin password: %w", err)
+			}
+			log.Printf("👤 Created admin %s with the password saved in %s; change it now, or set %s before the first start",
+				cfg.Users.WithDefaults().AdminUsername, path, users.AdminPasswordEnv)
+		}
+		apiServer.SetUsers(directory)
+		accounts = directory
+		log.Printf("👤 Local accounts enabled (%s store)", cfg.Users.WithDefaults().Store)
+	}
+
+	// Dashboard login sessions
+	if cfg.Web.Sessions.Enabled {
+		sessions, err := session.NewManager(cfg.Web.Sessions, accounts)
+		if err != nil {
+			return fmt.Errorf("failed to initialize dashboard sessions: %w", err)
+		}
+		webServer.EnableSessions(sessions)
+		apiServer.SetSessions(sessions)
+		log.Printf("🔑 Dashboard sessions enabled")
+	}
+
+	// Tenants with their own API keys, models and node pools
+	if cfg.Tenancy.Enabled {
+		tenants, err := tenancy.NewRegistry(cfg.Tenancy)
+		if err != nil {
+			return fmt.Errorf("failed to initialize tenancy: %w", err)
+		}
+		apiServer.SetTenancy(tenants)
+		log.Printf("🏢 Multitenancy enabled with %d tenant(s)", len(cfg.Tenancy.Tenants))
+	}
+
+	// Model licenses and the policies gating which tenants may use them
+	if cfg.Licensing.Enabled {
+		licenses, err := licensing.NewRegistry(cfg.Licensing)
+		if err != nil {
+			return fmt.Errorf("failed to initialize licensing: %w", err)
+		}
+		apiServer.SetLicensing(licenses)
+		log.Printf("📜 License policies enabled with %d rule(s)", len(cfg.Licensing.Rules))
+	}
+
+	// Opt-in capture of inference requests for debugging and replay
+	if cfg.Capture.Enabled {
+		captureStore, err := capture.OpenStore(cfg.Capture)
+		if err != nil {
+			return fmt.Errorf("failed to open capture store: %w", err)
+		}
+		defer captureStore.Close()
+		recorder, err := capture.NewRecorder(cfg.Capture, captureStore)
+		if err != nil {
+			return fmt.Errorf("failed to initialize capture: %w", err)
+		}
+		if redactor != nil {
+			recorder.SetRedactor(redactor)
+		}
+		apiServer.SetCapture(recorder)
+		log.Printf("🎥 Capturing %.0f%% of inference requests plus %d flagged key(s) and %d tenant(s)",
+			cfg.Capture.SampleRate*100, len(cfg.Capture.APIKeys), len(cfg.Capture.Tenants))
+	}
+
+	// Retried POSTs with an Idempotency-Key get the first response
+	if cfg.Idempotency.Enabled {
+		cache, err := idempotency.NewCache(cfg.Idempotency)
+		if err != nil {
+			return fmt.Errorf("failed to initialize idempotency keys: %w", err)
+		}
+		apiServer.SetIdempotency(cache)
+		log.Printf("🔁 Idempotency keys enabled, responses kept for %s", cache.Config().TTL)
+	}
+
+	// Golden-prompt verification of models after upgrades or re-quantization
+	if cfg.Verification.Enabled {
+		var suite *verification.Suite
+		if cfg.Verification.Suite != "" {
+			suite, err = verification.LoadSuite(cfg.Verification.Suite)
+			if err != nil {
+				return err
+			}
+		}
+		verifier, err := verification.NewVerifier(cfg.Verification, suite, verification.NewOllamaClient())
+		if err != nil {
+			return fmt.Errorf("failed to initialize model verification: %w", err)
+		}
+		apiServer.SetVerifier(verifier)
+		if cfg.Verification.CheckInterval > 0 {
+			go verifier.Watch(ctx, cfg.Verification.CheckInterval, func() map[string]string {
+				versions := make(map[string]string)
+				for name, model := range schedulerEngine.GetAllModels() {
+					versions[name] = model.Checksum
+				}
+				return versions
+			}, apiServer.VerificationTargets, func(report *verification.Report) {
+				if peerReputation != nil {
+					recordInconsistentResults(peerReputation, report)
+				}
+				if report.Passed {
+					log.Printf("🧪 Model %s version %s passed verification on %d node(s)", report.Model, report.Version, report.Nodes)
+					return
+				}
+				log.Printf("⚠️  Model %s version %s failed %d of %d golden prompt checks; hold back its promotion",
+					report.Model, report.Version, report.Failed, len(report.Results))
+			})
+		}
+		log.Printf("🧪 Model verification enabled with %d golden prompt(s)", len(verifier.Suite().Cases))
+	}
+
+	// Scan models and adapters imported into the model directory,
+	// quarantining the ones failing a scan
+	if cfg.ModelScan.Enabled {
+		scanner, err := modelscan.NewPipeline(cfg.ModelScan)
+		if err != nil {
+			return fmt.Errorf("failed to initialize model scanning: %w", err)
+		}
+		apiServer.SetModelScanner(scanner, cfg.Storage.ModelDir)
+		log.Printf("🦠 Model scanning enabled with scanners %s", strings.Join(scanner.Scanners(), ", "))
+	}
+
+	// Fetch the shards of models placed on this node stage first, so it
+	// serves before the layers it needs later are downloaded
+	if cfg.ShardFetch.Enabled {
+		fetchCfg := cfg.ShardFetch
+		if fetchCfg.Dir == "" {
+			fetchCfg.Dir = cfg.Storage.ModelDir
+		}
+		fetcher := shardfetch.NewFetcher(fetchCfg, shardfetch.HTTPSource{})
+		apiServer.SetShardFetcher(fetcher)
+		log.Printf("🧩 Stage-ordered shard fetching into %s", fetchCfg.Dir)
+	}
+
+	// Vector index for retrieval workflows, fed with embeddings computed on
+	// the instances serving the embedding model
+	if cfg.VectorIndex.Enabled {
+		vectors, err := vectorindex.Open(ctx, cfg.VectorIndex)
+		if err != nil {
+			return fmt.Errorf("failed to open vector index: %w", err)
+		}
+		defer vectors.Close()
+		embedder := vectorindex.NewClusterEmbedder(apiServer.ModelEndpoints)
+		if cfg.Hedging.Enabled {
+			embedder.Hedger = hedging.NewHedger(cfg.Hedging)
+			log.Printf("🔀 Hedging slow embedding calls to a second instance")
+		}
+		apiServer.SetVectorIndex(vectors, embedder, cfg.VectorIndex.EmbeddingModel)
+		backend := cfg.VectorIndex.Backend
+		if backend == "" {
+			backend = vectorindex.BackendHNSW
+		}
+		log.Printf("🧭 Vector index enabled with the %s backend", backend)
+
+		if cfg.RAG.Enabled {
+			pipeline, err := rag.NewPipeline(cfg.RAG, vectors, embedder, rag.NewClusterGenerator(apiServer.ModelEndpoints))
+			if err != nil {
+				return fmt.Errorf("failed to initialize RAG: %w", err)
+			}
+			apiServer.SetRAG(pipeline)
+			log.Printf("📚 RAG queries enabled on /api/v1/rag/query")
+		}
+
+		if cfg.Ingestion.Enabled {
+			service, err := ingest.NewService(cfg.Ingestion, vectors, embedder)
+			if err != nil {
+				return fmt.Errorf("failed to initialize ingestion: %w", err)
+			}
+			apiServer.SetIngestion(service, jobs.NewManager(0))
+			log.Printf("📥 Document ingestion enabled on /api/v1/ingest")
+		}
+	}
+
+	// Speculative decoding, drafting on the instances serving the draft
+	// models and verifying on those serving the targets
+	if cfg.Speculative.Enabled {
+		orchestrator := orchestration.NewOrchestrationEngine(&orchestration.Config{TaskTimeout: cfg.API.Timeout})
+		orchestrator.SetSpeculative(speculative.NewService(cfg.Speculative, apiServer.ModelEndpoints))
+		apiServer.SetSpeculative(orchestrator)
+		defer apiServer.StreamProgressEvents(orchestrator.Events())()
+		log.Printf("🎯 Speculative decoding enabled for %d model(s)", len(cfg.Speculative.Pairs))
+	}
+
 	// Start all services
 	if err := p2pNode.Start(); err != nil {
 		return fmt.Errorf("failed to start P2P node: %w", err)
@@ -315,6 +971,157 @@ func runStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to start scheduler: %w", err)
 	}
 
+	// Publish the Raft leader to a Lease and the pods' labels
+	if cfg.Kubernetes.LeaderLease != "" || cfg.Kubernetes.LeaderLabel {
+		if k8sClient, err := kubernetes.InCluster(); err != nil {
+			log.Printf("⚠️  Kubernetes leader publishing disabled: %v", err)
+		} else {
+			publisher := kubernetes.NewLeaderPublisher(k8sClient, kubernetes.PublisherConfig{
+				Namespace: kubernetes.Namespace(),
+				PodName:   os.Getenv("POD_NAME"),
+				Identity:  cfg.Node.ID,
+				LeaseName: cfg.Kubernetes.LeaderLease,
+				LabelPod:  cfg.Kubernetes.LeaderLabel,
+			})
+			go publisher.Run(ctx, consensusEngine.LeadershipChanges(), func() (bool, string) {
+				return consensusEngine.IsLeader(), consensusEngine.CatchUp().LeaderID
+			})
+			log.Printf("☸️  Publishing Raft leader to Kubernetes (lease %q, pod labels %t)",
+				cfg.Kubernetes.LeaderLease, cfg.Kubernetes.LeaderLabel)
+		}
+	}
+
+	// Node pool autoscaling
+	if cfg.Autoscaling.Enabled {
+		nodeScaler, err := newNodePoolAutoscaler(cfg.Autoscaling, schedulerEngine, sloMonitor,
+			p2pNode.ID().String(), consensusEngine.IsLeader)
+		if err != nil {
+			return fmt.Errorf("failed to create node pool autoscaler: %w", err)
+		}
+		if err := nodeScaler.Start(); err != nil {
+			log.Printf("⚠️  Failed to start node pool autoscaler: %v", err)
+		} else {
+			defer nodeScaler.Stop()
+			log.Printf("📈 Node pool autoscaling via %s (%d-%d nodes)",
+				cfg.Autoscaling.Provider, cfg.Autoscaling.MinNodes, cfg.Autoscaling.MaxNodes)
+		}
+	}
+
+	// Replicas of models scaled with their request rate and queue time
+	var replicaScaler *replicascale.Controller
+	if cfg.ReplicaScaling.Enabled {
+		replicaScaler = replicascale.NewController(cfg.ReplicaScaling, &replicaCluster{engine: schedulerEngine})
+		replicaScaler.SetLeaderCheck(consensusEngine.IsLeader)
+		schedulerEngine.SetUsageObserver(replicaScaler.Observe)
+		go replicaScaler.Run(ctx)
+		scaling := replicaScaler.Config()
+		log.Printf("⚖️  Scaling model replicas with usage (%d-%d per model, %.1f req/s each)",
+			scaling.MinReplicas, scaling.MaxReplicas, scaling.TargetRate)
+	}
+
+	// Federation with peer clusters
+	if cfg.Federation.Enabled {
+		fed, err := federation.NewFederation(cfg.Federation, &clusterManifest{engine: schedulerEngine}, slog.Default())
+		if err != nil {
+			return fmt.Errorf("failed to create federation: %w", err)
+		}
+		apiServer.SetFederation(fed)
+		fed.Start(ctx)
+		defer fed.Stop()
+		log.Printf("🌐 Federated as cluster %s with %d peer(s)", cfg.Federation.ClusterID, len(cfg.Federation.Peers))
+
+		if cfg.GeoRouting.Enabled {
+			router, err := georoute.NewRouter(cfg.GeoRouting)
+			if err != nil {
+				return fmt.Errorf("failed to create geo router: %w", err)
+			}
+			region := cfg.Federation.Region
+			if region == "" {
+				region = cfg.Node.Region
+			}
+			apiServer.SetGeoRouter(router, region)
+			log.Printf("🧭 Geo routing requests from region %s to the closest healthy region", region)
+		}
+	}
+
+	// Record the history of cluster state changes
+	if cfg.Events.Enabled {
+		store, err := events.OpenStore(cfg.Events)
+		if err != nil {
+			return fmt.Errorf("failed to open event store: %w", err)
+		}
+		history := events.NewLog(store, p2pNode.ID().String(), consensusEngine.GetCurrentTerm)
+		defer history.Close()
+		apiServer.SetEvents(history)
+
+		if _, err := history.Record(ctx, events.Event{Type: events.ConfigLoaded, Subject: cfg.Node.ID,
+			Data: map[string]interface{}{"file": cfgFile, "version": version}}); err != nil {
+			log.Printf("⚠️  Failed to record configuration event: %v", err)
+		}
+		watcher := events.NewWatcher(history, func() events.State {
+			return clusterEventState(schedulerEngine, consensusEngine)
+		})
+		go watcher.Run(ctx, cfg.Events.WatchInterval)
+		if anomalyDetector != nil {
+			anomalyDetector.SetListener(func(anomaly *observability.Anomaly) {
+				if _, err := history.Record(ctx, events.Event{Type: events.AnomalyDetected, Subject: anomaly.Model,
+					Data: map[string]interface{}{
+						"kind":     anomaly.Kind,
+						"node_id":  anomaly.NodeID,
+						"observed": anomaly.Observed,
+						"expected": anomaly.Expected,
+						"score":    anomaly.Score,
+						"message":  anomaly.Message,
+					}}); err != nil {
+					log.Printf("⚠️  Failed to record anomaly event: %v", err)
+				}
+			})
+		}
+		if replicaScaler != nil {
+			replicaScaler.SetListener(func(decision replicascale.Decision) {
+				data := map[string]interface{}{
+					"from":         decision.From,
+					"to":           decision.To,
+					"reason":       decision.Reason,
+					"request_rate": decision.RequestRate,
+					"queue_time":   decision.QueueTime.String(),
+				}
+				if decision.Error != "" {
+					data["error"] = decision.Error
+				}
+				if _, err := history.Record(ctx, events.Event{Type: events.ReplicasScaled, Subject: decision.Model,
+					Data: data}); err != nil {
+					log.Printf("⚠️  Failed to record replica scaling event: %v", err)
+				}
+			})
+		}
+		log.Printf("📒 Recording cluster state changes to /api/v1/events")
+	}
+
+	// Keep the history of key metrics for trends over weeks
+	if cfg.History.Enabled {
+		store, err := history.OpenStore(cfg.History)
+		if err != nil {
+			return fmt.Errorf("failed to open metrics history store: %w", err)
+		}
+		defer store.Close()
+		recorder := history.NewRecorder(cfg.History, store)
+		recorder.AddSource(schedulerHistory(schedulerEngine))
+		recorder.AddSource(strategyHistory(partitionManager))
+		if anomalyDetector != nil {
+			recorder.SetAnnotations(anomalyAnnotations(anomalyDetector))
+		}
+		if cfg.Metrics.ModelUsage.Enabled {
+			tracker := usage.NewTracker()
+			recorder.AddSource(tracker.Source())
+			apiServer.SetUsage(tracker)
+			log.Printf("📈 Model usage analytics on /api/v1/analytics/models")
+		}
+		apiServer.SetHistory(recorder)
+		go recorder.Run(ctx)
+		log.Printf("🗃️  Keeping metrics history on /api/v1/metrics/history")
+	}
+
 	// Start performance monitoring
 	log.Printf("📊 Starting performance monitoring...")
 	// TODO: implement performance optimization
@@ -339,7 +1146,38 @@ func runStart(cmd *cobra.Command, args []string) error {
 			log.Printf("⚠️  API server error: %v", err)
 		}
 	}()
-	log.Printf("✅ API server started on %s", cfg.API.Listen)
+	log.Printf("✅ API server started on %s", strings.Join(cfg.API.AllListenAddresses(), ", "))
+
+	// Start the gRPC API, which authenticates and admits requests like the
+	// REST API
+	if cfg.GRPC.Enabled {
+		grpcServer, err := grpcapi.NewServer(cfg.GRPC, apiServer.GRPCBackend())
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC server: %w", err)
+		}
+		if err := grpcServer.Start(); err != nil {
+			return fmt.Errorf("failed to start gRPC server: %w", err)
+		}
+		defer grpcServer.Stop(10 * time.Second)
+		log.Printf("🔌 gRPC API started on %s", grpcServer.Addr())
+	}
+
+	// Serve the model blobs read-only to S3 tooling
+	if cfg.S3Gateway.Enabled {
+		gatewayCfg := cfg.S3Gateway
+		if gatewayCfg.Root == "" {
+			gatewayCfg.Root = cfg.Storage.ModelDir
+		}
+		gateway, err := s3gateway.NewGateway(gatewayCfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize S3 gateway: %w", err)
+		}
+		if err := gateway.Start(); err != nil {
+			return fmt.Errorf("failed to start S3 gateway: %w", err)
+		}
+		defer gateway.Stop(10 * time.Second)
+		log.Printf("🪣 S3 gateway serving %s as bucket %s on %s", gatewayCfg.Root, gateway.Config().Bucket, gateway.Addr())
+	}
 
 	// Start web server
 	log.Printf("🌐 Starting web server...")
@@ -366,15 +1204,48 @@ func runStart(cmd *cobra.Command, args []string) error {
 		apiServer.SetIntegration(ollamaIntegration)
 	}
 
+	// Warm models up after they are loaded, sharing compilation caches
+	// with the nodes of the same hardware profile
+	if cfg.Warmup.Enabled {
+		profile := warmup.DetectProfile(ctx, ollamaIntegration.GetOllamaAPIURL())
+		warmups := warmup.NewService(cfg.Warmup, profile, p2pNode)
+		if cfg.Warmup.ShareCaches {
+			p2pNode.ServeBlobs(warmups.OpenBlob)
+		}
+		apiServer.SetWarmup(warmups, ollamaIntegration.LocalEndpoints)
+		go warmups.Run(ctx, ollamaIntegration.LocalEndpoints)
+		log.Printf("🔥 Model warm-up enabled (profile %s, cache sharing: %v)", profile.Fingerprint(), cfg.Warmup.ShareCaches)
+	}
+
+	// Queue model loads on the local Ollama instance so that loads after a
+	// failover run by priority and demand instead of all at once
+	if cfg.LoadQueue.Enabled {
+		loadQueue := loadqueue.NewQueue(cfg.LoadQueue)
+		loadQueue.SetDemand(func(model string) float64 {
+			if info, ok := schedulerEngine.GetModel(model); ok {
+				return float64(info.AccessCount)
+			}
+			return 0
+		})
+		if diskScheduler != nil {
+			loadQueue.SetSlowDisk(localDiskSlow(p2pNode, diskScheduler, cfg.Node.Labels))
+		}
+		apiServer.SetLoadQueue(loadQueue, loadqueue.OllamaLoader(nil, ollamaIntegration.GetOllamaAPIURL()))
+		log.Printf("⏳ Model load queue enabled (preemption: %v)", cfg.LoadQueue.Preempt)
+	}
+
 	log.Printf("Distributed Ollama node started successfully")
-	log.Printf("API server listening on: %s", cfg.API.Listen)
-	log.Printf("P2P node listening on: %s", cfg.P2P.Listen)
+	log.Printf("API server listening on: %s", strings.Join(cfg.API.AllListenAddresses(), ", "))
+	log.Printf("P2P node listening on: %v", p2pNode.GetHost().Addrs())
 	log.Printf("Node ID: %s", p2pNode.ID())
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the partition strategy settings
+	// from the config file on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-sigChan; sig == syscall.SIGHUP; sig = <-sigChan {
+		reloadStrategies(partitionManager)
+	}
 
 	log.Println("Shutting down...")
 
@@ -386,6 +1257,10 @@ func runStart(cmd *cobra.Command, args []string) error {
 		log.Printf("API server shutdown error: %v", err)
 	}
 
+	if err := ollamaIntegration.Shutdown(); err != nil {
+		log.Printf("Ollama integration shutdown error: %v", err)
+	}
+
 	if err := schedulerEngine.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Scheduler shutdown error: %v", err)
 	}
@@ -425,6 +1300,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		fmt.Printf("❌ Failed to initialize P2P node: %v\n", err)
 		return nil // Don't fail entirely, show what we can
 	}
+	p2pNode.SetResourceCollector(sysmetrics.NewCollector(sysmetrics.Options{DataDir: cfg.Storage.DataDir}))
 
 	// Start P2P node temporarily to get peer information
 	if err := p2pNode.Start(); err != nil {
@@ -471,6 +1347,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			fmt.Printf("   Disk Usage: %s\n", formatBytes(resourceMetrics.DiskUsage))
 			fmt.Printf("   Network RX: %s/s\n", formatBytes(resourceMetrics.NetworkRx))
 			fmt.Printf("   Network TX: %s/s\n", formatBytes(resourceMetrics.NetworkTx))
+			for i, usage := range resourceMetrics.GPUUsage {
+				fmt.Printf("   GPU %d Usage: %.1f%%\n", i, usage)
+			}
 		} else {
 			fmt.Printf("   Resource metrics unavailable\n")
 		}
@@ -518,10 +1397,59 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		fmt.Printf("\n")
 	}
 
+	if cfg.Features.Enabled {
+		printFeatureFlags(apiAddr)
+	}
+
 	fmt.Printf("✅ Status check completed\n")
 	return nil
 }
 
+// printFeatureFlags shows the feature flags as the running node evaluates
+// them
+func printFeatureFlags(apiAddr string) {
+	fmt.Printf("🚩 Feature Flags\n")
+	defer fmt.Printf("\n")
+
+	host, port, err := net.SplitHostPort(apiAddr)
+	if err != nil {
+		fmt.Printf("   Invalid API address: %v\n", err)
+		return
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	body, err := apiGet("http://"+net.JoinHostPort(host, port)+"/api/v1/health", "")
+	if err != nil {
+		fmt.Printf("   Node not reachable: %v\n", err)
+		return
+	}
+	var health struct {
+		Features map[string]bool `json:"features"`
+	}
+	if err := json.Unmarshal(body, &health); err != nil {
+		fmt.Printf("   Failed to parse health status: %v\n", err)
+		return
+	}
+	if len(health.Features) == 0 {
+		fmt.Printf("   No feature flags set\n")
+		return
+	}
+
+	names := make([]string, 0, len(health.Features))
+	for name := range health.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state := "off"
+		if health.Features[name] {
+			state = "on"
+		}
+		fmt.Printf("   %s: %s\n", name, state)
+	}
+}
+
 func runJoin(cmd *cobra.Command, args []string) error {
 	cfg, err := config.Load(cfgFile)
 	if err != nil {
@@ -532,6 +1460,16 @@ func runJoin(cmd *cobra.Command, args []string) error {
 	if len(peers) == 0 {
 		return fmt.Errorf("no peers specified, use --peers flag to specify peer addresses")
 	}
+	joinToken, _ := cmd.Flags().GetString("token")
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	if joinToken != "" {
+		if _, err := jointoken.Parse(joinToken); err != nil {
+			return fmt.Errorf("invalid --token: %w", err)
+		}
+		if apiURL == "" {
+			return fmt.Errorf("--api-url is required with --token")
+		}
+	}
 
 	fmt.Printf("Joining Ollama Distributed Cluster\n")
 	fmt.Printf("=================================\n\n")
@@ -556,6 +1494,20 @@ func runJoin(cmd *cobra.Command, args []string) error {
 	nodeID := p2pNode.ID()
 	fmt.Printf("📍 Node ID: %s\n\n", nodeID)
 
+	// Spend the join token, after checking the cluster is the one it was
+	// created for; its peers only accept this node once it is spent
+	if joinToken != "" {
+		fmt.Printf("🎟️  Redeeming join token at %s...\n", apiURL)
+		address := cfg.P2P.Listen
+		if addrs := p2pNode.GetStatus().ListenAddresses; len(addrs) > 0 {
+			address = addrs[0].String()
+		}
+		if err := redeemJoinToken(ctx, apiURL, joinToken, nodeID.String(), address); err != nil {
+			return fmt.Errorf("failed to join with token: %w", err)
+		}
+		fmt.Printf("   ✅ Cluster CA verified, join token accepted\n\n")
+	}
+
 	// Connect to specified peers
 	fmt.Printf("🌐 Connecting to peers...\n")
 	var successfulConnections int
@@ -592,7 +1544,9 @@ func runJoin(cmd *cobra.Command, args []string) error {
 	fmt.Printf("   Found %d peers in cluster\n", len(connectedPeers))
 
 	// Create messaging and monitoring components
-	messageRouter := messaging.NewMessageRouter(nil)
+	routerConfig := messaging.DefaultRouterConfig()
+	routerConfig.QoS = cfg.P2P.QoS
+	messageRouter := messaging.NewMessageRouter(routerConfig)
 	networkMonitor := monitoring.NewNetworkMonitor(nil)
 
 	// Initialize consensus engine and join cluster
@@ -634,6 +1588,10 @@ func runJoin(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler: %w", err)
 	}
+	nodeLabels := placement.NodeLabels{Labels: cfg.Node.Labels, Taints: cfg.Node.Taints}
+	if err := schedulerEngine.SetNodeLabels(p2pNode.ID().String(), nodeLabels); err != nil {
+		return fmt.Errorf("failed to set node labels: %w", err)
+	}
 
 	if err := schedulerEngine.Start(); err != nil {
 		return fmt.Errorf("failed to start scheduler: %w", err)
@@ -668,6 +1626,140 @@ func runJoin(cmd *cobra.Command, args []string) error {
 
 // Helper functions for status display
 
+// newLogShipper creates the shipper forwarding buffered logs, labelling
+// them with the node name
+// saveAdminPassword writes the generated password of the initial admin to
+// a file of the data directory only its owner can read, returning its path
+func saveAdminPassword(dataDir, password string) (string, error) {
+	if err := os.MkdirAll(dataDir, 0750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dataDir, "initial-admin-password")
+	// A file left by an earlier bootstrap may have looser permissions
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(password+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func newLogShipper(cfg *config.Config, ring *logging.RingBuffer) (*logging.Shipper, error) {
+	ship := cfg.Logging.Ship
+	labels := map[string]string{}
+	if cfg.Node.Name != "" {
+		labels["node"] = cfg.Node.Name
+	}
+	for k, v := range ship.Labels {
+		labels[k] = v
+	}
+	return logging.NewShipper(&logging.ShipperConfig{
+		Type:          ship.Type,
+		URL:           ship.URL,
+		Index:         ship.Index,
+		Labels:        labels,
+		Username:      ship.Username,
+		Password:      ship.Password,
+		BatchSize:     ship.BatchSize,
+		FlushInterval: ship.FlushInterval,
+	}, ring)
+}
+
+// reloadStrategies applies the partition strategy settings of the config
+// file, keeping the current ones if it is invalid
+func reloadStrategies(partitions *partitioning.PartitionManager) {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		log.Printf("⚠️  Failed to reload configuration: %v", err)
+		return
+	}
+	if err := partitions.ConfigureStrategies(cfg.Scheduler.Partitioning.Strategies); err != nil {
+		log.Printf("⚠️  Invalid partition strategy settings, keeping the current ones: %v", err)
+		return
+	}
+	log.Printf("🔄 Reloaded partition strategy settings")
+}
+
+// newSLOMonitor creates the SLO monitor, alerting the configured webhooks
+// on fast burn outside maintenance windows, with redacted alerts
+func newSLOMonitor(cfg config.SLOConfig, maintenanceManager *maintenance.Manager, redactor *redaction.Engine) *observability.SLOMonitor {
+	sloConfig := &observability.SLOConfig{
+		EvaluationInterval: cfg.EvaluationInterval,
+		FastBurnRate:       cfg.FastBurnRate,
+	}
+	for _, objective := range cfg.Objectives {
+		sloConfig.Objectives = append(sloConfig.Objectives, observability.SLOObjective{
+			Name:        objective.Name,
+			Indicator:   objective.Indicator,
+			RequestType: objective.RequestType,
+			Model:       objective.Model,
+			Threshold:   objective.Threshold,
+			Target:      objective.Target,
+			Window:      objective.Window,
+		})
+	}
+
+	var sink observability.SLOAlertSink
+	if len(cfg.AlertWebhooks) > 0 {
+		sink = observability.NewNotificationSystem(&observability.NotificationConfig{
+			Enabled:          true,
+			WebhookURLs:      cfg.AlertWebhooks,
+			RateLimitWindow:  time.Minute,
+			MaxNotifications: 10,
+		})
+	}
+	return observability.NewSLOMonitor(sloConfig, maintenanceManager.Silence(redactor.Notifications(sink)))
+}
+
+// newWatchdog creates the leak watchdog, alerting the configured webhooks
+// outside maintenance windows, with redacted alerts
+func newWatchdog(cfg config.WatchdogConfig, maintenanceManager *maintenance.Manager, redactor *redaction.Engine) *observability.Watchdog {
+	var sink observability.SLOAlertSink
+	if len(cfg.AlertWebhooks) > 0 {
+		sink = observability.NewNotificationSystem(&observability.NotificationConfig{
+			Enabled:          true,
+			WebhookURLs:      cfg.AlertWebhooks,
+			RateLimitWindow:  time.Minute,
+			MaxNotifications: 10,
+		})
+	}
+	return observability.NewWatchdog(&observability.WatchdogConfig{
+		Interval:            cfg.Interval,
+		Window:              cfg.Window,
+		MaxGoroutines:       cfg.MaxGoroutines,
+		GoroutineGrowth:     cfg.GoroutineGrowth,
+		MaxHeapBytes:        cfg.MaxHeapBytes,
+		HeapGrowth:          cfg.HeapGrowth,
+		MutexWaitRatio:      cfg.MutexWaitRatio,
+		DeadlockAfter:       cfg.DeadlockAfter,
+		DiagnosticsDir:      cfg.DiagnosticsDir,
+		DiagnosticsCooldown: cfg.DiagnosticsCooldown,
+		MaxBundles:          cfg.MaxBundles,
+	}, maintenanceManager.Silence(redactor.Notifications(sink)))
+}
+
+// newAnomalyDetector creates the anomaly detector, alerting the configured
+// webhooks outside maintenance windows, with redacted alerts
+func newAnomalyDetector(cfg config.AnomalyConfig, maintenanceManager *maintenance.Manager, redactor *redaction.Engine) *observability.AnomalyDetector {
+	var sink observability.SLOAlertSink
+	if len(cfg.AlertWebhooks) > 0 {
+		sink = observability.NewNotificationSystem(&observability.NotificationConfig{
+			Enabled:          true,
+			WebhookURLs:      cfg.AlertWebhooks,
+			RateLimitWindow:  time.Minute,
+			MaxNotifications: 10,
+		})
+	}
+	return observability.NewAnomalyDetector(&observability.AnomalyConfig{
+		Interval:        cfg.Interval,
+		MinSamples:      cfg.MinSamples,
+		LearningWindows: cfg.LearningWindows,
+		Threshold:       cfg.Threshold,
+		AdaptAfter:      cfg.AdaptAfter,
+	}, maintenanceManager.Silence(redactor.Notifications(sink)))
+}
+
 func getStatusString(started bool) string {
 	if started {
 		return "✅ Online"
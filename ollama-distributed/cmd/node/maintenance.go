@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// maintenanceCluster drains and taints nodes for maintenance windows
+// through the scheduler
+type maintenanceCluster struct {
+	engine *scheduler.Engine
+}
+
+// Nodes returns the IDs of the nodes the scheduler knows
+func (c *maintenanceCluster) Nodes() []string {
+	nodes := c.engine.GetNodes()
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (c *maintenanceCluster) Labels(nodeID string) placement.NodeLabels {
+	labels, _ := c.engine.NodeLabels().Get(nodeID)
+	return labels
+}
+
+func (c *maintenanceCluster) SetLabels(nodeID string, labels placement.NodeLabels) error {
+	return c.engine.SetNodeLabels(nodeID, labels)
+}
+
+func (c *maintenanceCluster) DrainNode(nodeID string)      { c.engine.DrainNode(nodeID) }
+func (c *maintenanceCluster) UndrainNode(nodeID string)    { c.engine.UndrainNode(nodeID) }
+func (c *maintenanceCluster) IsDrained(nodeID string) bool { return c.engine.IsDrained(nodeID) }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func planCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Inspect partition plans",
+		Long:  "List the partition plans a node produced and export them to see how requests were split",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List recent partition plans",
+		RunE:  runPlanList,
+	}
+	list.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	list.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	cmd.AddCommand(list)
+
+	export := &cobra.Command{
+		Use:   "export <plan-id>",
+		Short: "Export a partition plan as Graphviz DOT or JSON",
+		Long: `Export a partition plan with its partitions, node assignments, dependencies
+and estimated latency and memory.`,
+		Example: `  ollama-distributed plan export plan_layerwise_1700000000 --format dot | dot -Tsvg > plan.svg`,
+		Args:    cobra.ExactArgs(1),
+		RunE:    runPlanExport,
+	}
+	export.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	export.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	export.Flags().String("format", "dot", "Export format (dot, json)")
+	export.Flags().StringP("output", "o", "", "File to write to (default stdout)")
+	cmd.AddCommand(export)
+
+	return cmd
+}
+
+func runPlanList(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+
+	body, err := apiGet(strings.TrimSuffix(apiURL, "/")+"/api/v1/scheduler/plans", token)
+	if err != nil {
+		return fmt.Errorf("failed to list plans: %w", err)
+	}
+
+	var result struct {
+		Plans []struct {
+			ID               string        `json:"id"`
+			TaskID           string        `json:"task_id"`
+			Strategy         string        `json:"strategy"`
+			Partitions       int           `json:"partitions"`
+			EstimatedLatency time.Duration `json:"estimated_latency"`
+			CreatedAt        time.Time     `json:"created_at"`
+		} `json:"plans"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Plans) == 0 {
+		fmt.Println("No partition plans recorded")
+		return nil
+	}
+	fmt.Printf("%-36s %-24s %-20s %10s %12s  %s\n", "PLAN", "TASK", "STRATEGY", "PARTITIONS", "EST. LATENCY", "CREATED")
+	for _, p := range result.Plans {
+		fmt.Printf("%-36s %-24s %-20s %10d %12s  %s\n", p.ID, p.TaskID, p.Strategy, p.Partitions,
+			p.EstimatedLatency, p.CreatedAt.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+func runPlanExport(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+
+	if format != "dot" && format != "json" {
+		return fmt.Errorf("unknown format %q, expected dot or json", format)
+	}
+
+	u := fmt.Sprintf("%s/api/v1/scheduler/plans/%s?format=%s",
+		strings.TrimSuffix(apiURL, "/"), url.PathEscape(args[0]), format)
+	body, err := apiGet(u, token)
+	if err != nil {
+		return fmt.Errorf("failed to export plan: %w", err)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	if err := os.WriteFile(output, body, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote plan %s to %s\n", args[0], output)
+	return nil
+}
+
+// apiGet fetches a node API endpoint, returning the body of a 200 response
+func apiGet(endpoint, token string) ([]byte, error) {
+	return apiRequest(http.MethodGet, endpoint, token, 30*time.Second)
+}
+
+// apiRequest sends a request without a body to a node API endpoint,
+// returning the body of a 200 response
+func apiRequest(method, endpoint, token string, timeout time.Duration) ([]byte, error) {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
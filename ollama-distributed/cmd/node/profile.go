@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func profileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Collect runtime profiles from a node",
+		Long:  "Collect pprof profiles from a node's admin-only /debug endpoints (requires api.debug.enabled)",
+	}
+
+	collect := &cobra.Command{
+		Use:   "collect",
+		Short: "Fetch CPU and heap profiles for offline analysis",
+		Long: `Fetch a CPU profile sampled over --duration plus heap and goroutine profiles,
+and save them for analysis with 'go tool pprof'.`,
+		Example: `  ollama-distributed profile collect --duration 30s --token $TOKEN`,
+		RunE:    runProfileCollect,
+	}
+	collect.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	collect.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Admin bearer token (default $OLLAMA_API_TOKEN)")
+	collect.Flags().Duration("duration", 30*time.Second, "CPU profile duration")
+	collect.Flags().String("output", ".", "Directory the profiles are written to")
+	collect.Flags().StringSlice("types", []string{"cpu", "heap", "goroutine"}, "Profiles to collect (cpu, heap, goroutine, allocs, block, mutex)")
+	cmd.AddCommand(collect)
+
+	return cmd
+}
+
+func runProfileCollect(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	output, _ := cmd.Flags().GetString("output")
+	types, _ := cmd.Flags().GetStringSlice("types")
+
+	if duration < time.Second {
+		return fmt.Errorf("duration must be at least 1s")
+	}
+	if err := os.MkdirAll(output, 0o750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// The CPU profile blocks for the whole duration on the server
+	client := &http.Client{Timeout: duration + 30*time.Second}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	base := strings.TrimSuffix(apiURL, "/") + "/debug/pprof/"
+
+	for _, t := range types {
+		var url string
+		switch t {
+		case "cpu":
+			url = fmt.Sprintf("%sprofile?seconds=%d", base, int(duration.Seconds()))
+			fmt.Printf("⏱️  Sampling CPU for %s...\n", duration)
+		case "heap", "goroutine", "allocs", "block", "mutex", "threadcreate":
+			url = base + t
+		default:
+			return fmt.Errorf("unknown profile type %q", t)
+		}
+
+		path := filepath.Join(output, fmt.Sprintf("%s-%s.pprof", t, stamp))
+		if err := fetchProfile(client, url, token, path); err != nil {
+			return fmt.Errorf("failed to collect %s profile: %w", t, err)
+		}
+		fmt.Printf("✅ Saved %s profile to %s\n", t, path)
+	}
+
+	fmt.Printf("\n💡 Analyze with: go tool pprof -http=:0 <file>\n")
+	return nil
+}
+
+// fetchProfile downloads a profile from the node into path
+func fetchProfile(client *http.Client, url, token, path string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
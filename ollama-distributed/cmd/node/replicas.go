@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// replicaCluster places the replicas the replica scaler asks for through
+// the scheduler's model registry
+type replicaCluster struct {
+	engine *scheduler.Engine
+}
+
+func (c *replicaCluster) Replicas() map[string]int {
+	replicas := make(map[string]int)
+	for name, model := range c.engine.GetAllModels() {
+		replicas[name] = len(model.Locations)
+	}
+	return replicas
+}
+
+func (c *replicaCluster) Scale(ctx context.Context, model string, replicas int) error {
+	info, ok := c.engine.GetModel(model)
+	if !ok {
+		return fmt.Errorf("model %s not found", model)
+	}
+	current := len(info.Locations)
+
+	switch {
+	case replicas > current:
+		added, err := c.engine.AddModelReplicas(model, replicas-current)
+		if err != nil {
+			return err
+		}
+		if len(added) < replicas-current {
+			return fmt.Errorf("placed %d of %d replicas: no other node may take %s", len(added), replicas-current, model)
+		}
+	case replicas < current:
+		if _, err := c.engine.RemoveModelReplicas(model, current-replicas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+)
+
+// recordInconsistentResults counts against a node every golden prompt it
+// failed while another node passed it. Cases failed everywhere point at the
+// model rather than the nodes and are not counted.
+func recordInconsistentResults(tracker *reputation.Tracker, report *verification.Report) {
+	passedBy := make(map[string]bool)
+	for _, result := range report.Results {
+		if result.Passed {
+			passedBy[result.Case] = true
+		}
+	}
+	for _, result := range report.Results {
+		if !result.Passed && passedBy[result.Case] {
+			tracker.Record(result.NodeID, reputation.InconsistentResult,
+				fmt.Sprintf("failed golden prompt %s of %s passed by other nodes", result.Case, report.Model))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+func secretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Inspect secret store integration",
+		Long:  "Inspect credentials referenced from the configuration (vault:, aws-sm: and file: values)",
+	}
+
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "Verify that all referenced secrets can be read",
+		Long:  "Fetch every secret referenced from the configuration without printing its value, and fail if any cannot be read",
+		RunE:  runSecretsCheck,
+	}
+	check.Flags().Duration("timeout", 15*time.Second, "Timeout for all secret store requests")
+	cmd.AddCommand(check)
+
+	return cmd
+}
+
+func runSecretsCheck(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	refs := secrets.References(cfg)
+	if len(refs) == 0 {
+		fmt.Println("No secret references found in configuration")
+		return nil
+	}
+
+	manager, err := secrets.NewManagerFromConfig(cfg, slog.Default())
+	if err != nil {
+		return fmt.Errorf("failed to set up secret providers: %w", err)
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	toCheck := make([]secrets.Reference, 0, len(refs))
+	for _, fr := range refs {
+		toCheck = append(toCheck, fr.Ref)
+	}
+	results := manager.Check(ctx, toCheck)
+
+	failed := 0
+	for _, fr := range refs {
+		if err := results[fr.Ref.String()]; err != nil {
+			failed++
+			fmt.Printf("❌ %s (%s): %v\n", fr.Field, fr.Ref, err)
+			continue
+		}
+		fmt.Printf("✅ %s (%s)\n", fr.Field, fr.Ref)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d secrets could not be read", failed, len(refs))
+	}
+	return nil
+}
+
+// resolveSecrets replaces secret references in cfg and keeps leases renewed
+// for the lifetime of ctx. Startup fails if any secret cannot be read.
+func resolveSecrets(ctx context.Context, cfg *config.Config) error {
+	if len(secrets.References(cfg)) == 0 {
+		return nil
+	}
+
+	manager, err := secrets.NewManagerFromConfig(cfg, slog.Default())
+	if err != nil {
+		return err
+	}
+	keyIsReference := secrets.IsReference(cfg.Security.Auth.SecretKey)
+	if err := secrets.ResolveConfig(ctx, manager, cfg); err != nil {
+		return err
+	}
+
+	// Validation skips the length check for references, so apply it now
+	if keyIsReference && len(cfg.Security.Auth.SecretKey) < 32 {
+		return fmt.Errorf("security.auth.secret_key: resolved secret must be at least 32 characters long")
+	}
+
+	log.Printf("🔐 Resolved secrets from configured secret stores")
+	go manager.Run(ctx, cfg.Security.Secrets.RenewInterval)
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+	"github.com/spf13/cobra"
+)
+
+func selfUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update this binary to the latest release",
+		Long: `Replace this binary with the latest release after verifying its checksum,
+and the signature of the release checksums when a public key is configured.
+
+With --restart-command the node is drained and restarted into the new
+binary. With --coordinate it first waits for the cluster's upgrade lease,
+which is granted to one node at a time and only while every other node is
+online, so that a cluster updated node by node never loses two nodes at once.`,
+		Example: `  ollama-distributed self-update --check
+  ollama-distributed self-update --coordinate --restart-command "systemctl restart ollama-distributed"`,
+		RunE: runSelfUpdate,
+	}
+
+	cmd.Flags().Bool("check", false, "Only report whether a newer release is available")
+	cmd.Flags().Bool("force", false, "Install the latest release even if it is not newer")
+	cmd.Flags().String("release-url", "", "Release endpoint serving GitHub's release JSON (default from config)")
+	cmd.Flags().String("public-key", "", "Base64 Ed25519 key the release checksums are signed with (default from config)")
+	cmd.Flags().Bool("coordinate", false, "Restart only while holding the cluster's upgrade lease")
+	cmd.Flags().String("restart-command", "", "Shell command restarting this node into the new binary")
+	cmd.Flags().String("api-url", "http://localhost:8080", "Cluster API URL granting the upgrade lease, i.e. the leader's")
+	cmd.Flags().String("node-api-url", "http://localhost:8080", "API URL of this node")
+	cmd.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "API token (defaults to $OLLAMA_API_TOKEN)")
+	cmd.Flags().Duration("wait", 30*time.Minute, "How long to wait for the upgrade lease, and for the node to be ready again")
+
+	return cmd
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	updateCfg := cfg.SelfUpdate
+	if releaseURL, _ := cmd.Flags().GetString("release-url"); releaseURL != "" {
+		updateCfg.ReleaseURL = releaseURL
+	}
+	if publicKey, _ := cmd.Flags().GetString("public-key"); publicKey != "" {
+		updateCfg.PublicKey = publicKey
+	}
+	check, _ := cmd.Flags().GetBool("check")
+	force, _ := cmd.Flags().GetBool("force")
+	coordinate, _ := cmd.Flags().GetBool("coordinate")
+	restartCommand, _ := cmd.Flags().GetString("restart-command")
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	nodeAPIURL, _ := cmd.Flags().GetString("node-api-url")
+	token, _ := cmd.Flags().GetString("token")
+	wait, _ := cmd.Flags().GetDuration("wait")
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	nodeAPIURL = strings.TrimSuffix(nodeAPIURL, "/")
+
+	if coordinate && restartCommand == "" {
+		return fmt.Errorf("--coordinate needs --restart-command to restart the node while it holds the lease")
+	}
+
+	updater, err := selfupdate.NewUpdater(updateCfg, nil)
+	if err != nil {
+		return fmt.Errorf("invalid self-update config: %w", err)
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	newer := selfupdate.Newer(release.Version, version)
+	if check {
+		if newer {
+			fmt.Printf("Release %s is available (running %s)\n", release.Version, version)
+		} else {
+			fmt.Printf("Running %s, the latest release is %s\n", version, release.Version)
+		}
+		return nil
+	}
+	if !newer && !force {
+		fmt.Printf("Already up to date: running %s, the latest release is %s (use --force to reinstall it)\n", version, release.Version)
+		return nil
+	}
+	if !updater.Signed() {
+		fmt.Fprintln(os.Stderr, "⚠️  No public key configured: the release is verified against its checksums only")
+	}
+
+	target, err := selfupdate.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate this binary: %w", err)
+	}
+	fmt.Printf("Downloading %s...\n", release.Version)
+	staged, err := updater.Download(ctx, release, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged)
+	fmt.Printf("✅ Verified %s\n", release.Version)
+
+	var nodeID string
+	if coordinate {
+		if nodeID, err = localNodeID(nodeAPIURL, token); err != nil {
+			return err
+		}
+		if err := acquireUpgradeLease(ctx, apiURL, token, nodeID, release.Version, wait); err != nil {
+			return err
+		}
+	}
+
+	if err := selfupdate.Install(staged, target); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Installed %s at %s (previous binary kept as %s.old)\n", release.Version, target, target)
+
+	if restartCommand == "" {
+		fmt.Println("Restart the node to run the new version")
+		return nil
+	}
+	if err := restartNode(ctx, nodeAPIURL, restartCommand, wait); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Node restarted into %s\n", release.Version)
+
+	if coordinate {
+		if _, err := apiRequest(http.MethodDelete, apiURL+"/api/v1/cluster/upgrade/lease/"+nodeID, token, 30*time.Second); err != nil {
+			// The lease expires by itself, holding up the next node a while
+			return fmt.Errorf("failed to release the upgrade lease: %w", err)
+		}
+	}
+	return nil
+}
+
+// localNodeID returns the ID of the node whose API is at nodeAPIURL
+func localNodeID(nodeAPIURL, token string) (string, error) {
+	body, err := apiGet(nodeAPIURL+"/api/v1/cluster/state", token)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the ID of this node: %w", err)
+	}
+	var state struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := json.Unmarshal(body, &state); err != nil || state.NodeID == "" {
+		return "", fmt.Errorf("failed to get the ID of this node from %s", nodeAPIURL)
+	}
+	return state.NodeID, nil
+}
+
+// acquireUpgradeLease waits until the cluster grants the node the lease to
+// restart, renewing it if the node already holds it
+func acquireUpgradeLease(ctx context.Context, apiURL, token, nodeID, release string, wait time.Duration) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	deadline := time.Now().Add(wait)
+	for {
+		_, err := apiPost(client, apiURL+"/api/v1/cluster/upgrade/lease", token, map[string]string{
+			"node_id": nodeID,
+			"version": release,
+		})
+		if err == nil {
+			fmt.Printf("✅ Holding the upgrade lease for %s\n", nodeID)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no upgrade lease within %s: %w", wait, err)
+		}
+		fmt.Printf("Waiting for the upgrade lease: %v\n", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+	}
+}
+
+// restartNode drains the node through its pre-stop hook, which makes its
+// /readyz fail, runs the restart command and waits until /readyz passes
+// again, i.e. the new binary is serving
+func restartNode(ctx context.Context, nodeAPIURL, restartCommand string, wait time.Duration) error {
+	fmt.Println("Draining the node...")
+	if _, err := apiRequest(http.MethodPost, nodeAPIURL+"/lifecycle/prestop", "", 5*time.Minute); err != nil {
+		return fmt.Errorf("failed to drain the node: %w", err)
+	}
+
+	restart := exec.CommandContext(ctx, "sh", "-c", restartCommand)
+	restart.Stdout = os.Stdout
+	restart.Stderr = os.Stderr
+	if err := restart.Run(); err != nil {
+		return fmt.Errorf("restart command failed: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		if _, err := apiRequest(http.MethodGet, nodeAPIURL+"/readyz", "", 5*time.Second); err == nil {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("node not ready within %s of restarting: %w", wait, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+func simulateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Predict how a hypothetical cluster would serve a workload",
+		Long: `Replay a workload offline through the scheduler's load balancing, placement
+and partitioning against a hypothetical cluster described in YAML, and report
+the predicted latencies, node utilization and bottlenecks.
+
+The workload is either a traffic capture (see "capture export") or a synthetic
+one generated from a request rate, a duration and a weighted mix of models.`,
+		Example: `  # Would three more GPU nodes absorb last week's traffic?
+  ollama-distributed simulate --cluster cluster.yaml --trace captures.jsonl
+
+  # A synthetic load of 5 requests per second, three quarters to llama3
+  ollama-distributed simulate --cluster cluster.yaml --rate 5 --duration 10m \
+    --model llama3=3 --model mixtral=1`,
+		RunE: runSimulate,
+	}
+	cmd.Flags().String("cluster", "", "YAML description of the cluster (required)")
+	cmd.Flags().String("trace", "", "Capture file to replay instead of a synthetic workload")
+	cmd.Flags().Float64("rate", 1, "Synthetic requests per second")
+	cmd.Flags().Duration("duration", 5*time.Minute, "Length of the synthetic workload")
+	cmd.Flags().StringArray("model", nil, "Model of the synthetic workload, as name or name=weight (repeatable)")
+	cmd.Flags().Int("prompt-tokens", 500, "Mean prompt tokens of synthetic requests")
+	cmd.Flags().Int("output-tokens", 200, "Mean output tokens of synthetic requests")
+	cmd.Flags().Int64("seed", 1, "Seed of the synthetic workload")
+	cmd.Flags().String("format", "text", "Report format (text, json)")
+	_ = cmd.MarkFlagRequired("cluster")
+	return cmd
+}
+
+func runSimulate(cmd *cobra.Command, args []string) error {
+	clusterFile, _ := cmd.Flags().GetString("cluster")
+	traceFile, _ := cmd.Flags().GetString("trace")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	cluster, err := simulation.LoadCluster(clusterFile)
+	if err != nil {
+		return err
+	}
+
+	var trace []simulation.Request
+	if traceFile != "" {
+		records, err := capture.ReadFile(traceFile)
+		if err != nil {
+			return fmt.Errorf("failed to read trace: %w", err)
+		}
+		trace = simulation.FromCaptures(records)
+	} else {
+		if trace, err = syntheticTrace(cmd); err != nil {
+			return err
+		}
+	}
+	if len(trace) == 0 {
+		return fmt.Errorf("the workload has no requests")
+	}
+
+	sim, err := simulation.New(cluster)
+	if err != nil {
+		return fmt.Errorf("failed to create simulator: %w", err)
+	}
+	report, err := sim.Run(cmd.Context(), trace)
+	if err != nil {
+		return fmt.Errorf("failed to simulate: %w", err)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
+
+// syntheticTrace generates the workload described by the flags
+func syntheticTrace(cmd *cobra.Command) ([]simulation.Request, error) {
+	rate, _ := cmd.Flags().GetFloat64("rate")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	models, _ := cmd.Flags().GetStringArray("model")
+	promptTokens, _ := cmd.Flags().GetInt("prompt-tokens")
+	outputTokens, _ := cmd.Flags().GetInt("output-tokens")
+	seed, _ := cmd.Flags().GetInt64("seed")
+
+	if len(models) == 0 {
+		return nil, fmt.Errorf("either --trace or at least one --model is required")
+	}
+	weights := make(map[string]float64)
+	for _, m := range models {
+		name, weight, found := strings.Cut(m, "=")
+		w := 1.0
+		if found {
+			var err error
+			if w, err = strconv.ParseFloat(weight, 64); err != nil {
+				return nil, fmt.Errorf("invalid weight of model %s: %w", name, err)
+			}
+		}
+		weights[name] += w
+	}
+
+	return simulation.Synthetic{
+		Rate:         rate,
+		Duration:     duration,
+		Models:       weights,
+		PromptTokens: promptTokens,
+		OutputTokens: outputTokens,
+		Seed:         seed,
+	}.Generate()
+}
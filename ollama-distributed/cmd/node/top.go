@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/api"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// topColumn is a column top sorts nodes by, typed as its key
+type topColumn struct {
+	key  string
+	name string
+	less func(a, b *types.NodeInfo) bool
+}
+
+// topColumns are the sortable columns. Usage and request columns sort
+// highest first, like top.
+var topColumns = []topColumn{
+	{"n", "node", func(a, b *types.NodeInfo) bool { return a.ID < b.ID }},
+	{"s", "status", func(a, b *types.NodeInfo) bool { return a.Status < b.Status }},
+	{"c", "cpu", func(a, b *types.NodeInfo) bool { return a.Usage.CPU > b.Usage.CPU }},
+	{"g", "gpu", func(a, b *types.NodeInfo) bool { return a.Usage.GPU > b.Usage.GPU }},
+	{"m", "memory", func(a, b *types.NodeInfo) bool { return a.Usage.Memory > b.Usage.Memory }},
+	{"a", "active", func(a, b *types.NodeInfo) bool { return a.ActiveRequests > b.ActiveRequests }},
+	{"w", "queue", func(a, b *types.NodeInfo) bool { return a.QueuedRequests > b.QueuedRequests }},
+	{"l", "models", func(a, b *types.NodeInfo) bool { return len(a.Models) > len(b.Models) }},
+}
+
+// findTopColumn returns the column with the given key or name
+func findTopColumn(keyOrName string) (topColumn, bool) {
+	for _, column := range topColumns {
+		if column.key == keyOrName || column.name == keyOrName {
+			return column, true
+		}
+	}
+	return topColumn{}, false
+}
+
+func topCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Live view of the cluster's nodes",
+		Long: `Show every node of the cluster with its CPU, GPU and memory usage, the
+requests it is serving and has queued, and the models it has loaded,
+refreshed every few seconds from the cluster state API.
+
+While it runs, type a column key and press Enter to sort by that column:
+  n node   s status   c cpu   g gpu   m memory   a active   w queue   l models
+r reverses the order and q quits.`,
+		Example: `  ollama-distributed top --sort gpu
+  ollama-distributed top --once --sort active | head -20`,
+		Args: cobra.NoArgs,
+		RunE: runTop,
+	}
+	cmd.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	cmd.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	cmd.Flags().Duration("interval", 3*time.Second, "Refresh interval")
+	cmd.Flags().String("sort", "cpu", "Column to sort by: node, status, cpu, gpu, memory, active, queue or models")
+	cmd.Flags().Bool("once", false, "Print the nodes once and exit")
+	return cmd
+}
+
+// topView is how top shows the nodes
+type topView struct {
+	column  topColumn
+	reverse bool
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	sortBy, _ := cmd.Flags().GetString("sort")
+	once, _ := cmd.Flags().GetBool("once")
+
+	column, ok := findTopColumn(sortBy)
+	if !ok {
+		return fmt.Errorf("unknown sort column %q", sortBy)
+	}
+	if interval < time.Second {
+		return fmt.Errorf("interval must be at least 1s")
+	}
+	view := topView{column: column}
+	endpoint := strings.TrimSuffix(apiURL, "/") + "/api/v1/cluster/state"
+
+	fetch := func() (*api.ClusterState, error) {
+		body, err := apiRequest(http.MethodGet, endpoint, token, interval)
+		if err != nil {
+			return nil, err
+		}
+		var state api.ClusterState
+		if err := json.Unmarshal(body, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		return &state, nil
+	}
+
+	if once {
+		state, err := fetch()
+		if err != nil {
+			return fmt.Errorf("failed to get cluster state: %w", err)
+		}
+		renderTop(os.Stdout, state, view, terminalWidth())
+		return nil
+	}
+
+	// Keys are read a line at a time, as the terminal stays in its
+	// normal mode
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			keys <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	state, err := fetch()
+	draw := func() {
+		fmt.Print("\033[2J\033[H") // Clear screen and move cursor to top
+		if err != nil {
+			fmt.Printf("⚠️  %s: %v\n\n", time.Now().Format("15:04:05"), err)
+		}
+		if state != nil {
+			renderTop(os.Stdout, state, view, terminalWidth())
+		}
+		fmt.Printf("\nSort: type n s c g m a w l, r to reverse, q to quit, then Enter\n")
+	}
+	draw()
+
+	for {
+		select {
+		case <-ticker.C:
+			var fresh *api.ClusterState
+			if fresh, err = fetch(); err == nil {
+				state = fresh
+			}
+			draw()
+		case key, open := <-keys:
+			if !open {
+				keys = nil
+				continue
+			}
+			switch key {
+			case "q":
+				return nil
+			case "r":
+				view.reverse = !view.reverse
+			default:
+				if column, ok := findTopColumn(key); ok {
+					view = topView{column: column}
+				}
+			}
+			draw()
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// renderTop writes the cluster summary and a row per node, sorted as the
+// view says, to w
+func renderTop(w io.Writer, state *api.ClusterState, view topView, width int) {
+	online := 0
+	for _, node := range state.Nodes {
+		if node.Online() {
+			online++
+		}
+	}
+	leader := state.Leader
+	if leader == "" {
+		leader = "-"
+	}
+	order := "↓"
+	if view.reverse {
+		order = "↑"
+	}
+	fmt.Fprintf(w, "ollama-distributed top - %s  leader %s  nodes %d (%d online)  models %d  queued %d  sort %s %s\n\n",
+		state.Time.Local().Format("15:04:05"), shorten(leader, 20), len(state.Nodes), online,
+		state.Models, state.QueuedRequests, view.column.name, order)
+
+	nodes := append([]*types.NodeInfo(nil), state.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if view.reverse {
+			return view.column.less(nodes[j], nodes[i])
+		}
+		return view.column.less(nodes[i], nodes[j])
+	})
+
+	const row = "%-20s %-10s %6s %6s %6s %9s %7s %6s  %s\n"
+	fmt.Fprintf(w, row, "NODE", "STATUS", "CPU%", "GPU%", "MEM%", "MEMORY", "ACTIVE", "QUEUE", "MODELS")
+	// The models get what is left of the line
+	modelsWidth := max(width-len(fmt.Sprintf(row, "", "", "", "", "", "", "", "", "")), 10)
+	for _, node := range nodes {
+		gpu := "-"
+		if node.Capacity.GPU > 0 || node.Usage.GPU > 0 {
+			gpu = fmt.Sprintf("%.1f", node.Usage.GPU)
+		}
+		memory := "-"
+		if node.Capacity.Memory > 0 {
+			memory = formatBytes(node.Capacity.Memory)
+		}
+		models := strconv.Itoa(len(node.Models))
+		if len(node.Models) > 0 {
+			models += " " + strings.Join(node.Models, ",")
+		}
+		fmt.Fprintf(w, row,
+			shorten(node.ID, 20),
+			node.Status,
+			fmt.Sprintf("%.1f", node.Usage.CPU),
+			gpu,
+			fmt.Sprintf("%.1f", node.Usage.Memory),
+			memory,
+			strconv.Itoa(node.ActiveRequests),
+			strconv.Itoa(node.QueuedRequests),
+			shorten(models, modelsWidth),
+		)
+	}
+}
+
+// terminalWidth returns the width of the terminal as the shell exports it
+// in $COLUMNS, or 120
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 120
+}
+
+// shorten cuts s to at most n runes, marking the cut with an ellipsis
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+	"github.com/spf13/cobra"
+)
+
+func verifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <model>",
+		Short: "Run golden prompts against a model on every node serving it",
+		Long: `Run the golden prompts configured for a model on every node serving it and
+report the outputs that deviate from expectations. The command exits non-zero
+when any node fails, so it can gate promoting a new model version.`,
+		Example: `  ollama-distributed verify llama3 && promote-model llama3`,
+		Args:    cobra.ExactArgs(1),
+		RunE:    runVerify,
+	}
+	cmd.Flags().String("api-url", "http://localhost:8080", "API server URL")
+	cmd.Flags().String("token", os.Getenv("OLLAMA_API_TOKEN"), "Bearer token (default $OLLAMA_API_TOKEN)")
+	cmd.Flags().Duration("timeout", 10*time.Minute, "How long to wait for the verification")
+	cmd.Flags().Bool("json", false, "Print the report as JSON")
+	return cmd
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	apiURL, _ := cmd.Flags().GetString("api-url")
+	token, _ := cmd.Flags().GetString("token")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	u := fmt.Sprintf("%s/api/v1/models/%s/verify", strings.TrimSuffix(apiURL, "/"), url.PathEscape(args[0]))
+	body, err := apiRequest(http.MethodPost, u, token, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to verify model: %w", err)
+	}
+
+	var result struct {
+		Report verification.Report `json:"report"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	report := result.Report
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%-24s %-20s %-6s %10s\n", "CASE", "NODE", "RESULT", "LATENCY")
+		for _, r := range report.Results {
+			status := "pass"
+			if !r.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("%-24s %-20s %-6s %10s\n", r.Case, r.NodeID, status, r.Latency.Round(time.Millisecond))
+			if r.Error != "" {
+				fmt.Printf("    error: %s\n", r.Error)
+			}
+			for _, d := range r.Deviations {
+				fmt.Printf("    - %s\n", d)
+			}
+		}
+		fmt.Printf("\n%s: %d cases on %d nodes, %d failed (%s)\n",
+			report.Model, report.Cases, report.Nodes, report.Failed, report.Duration.Round(time.Millisecond))
+	}
+
+	if !report.Passed {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("model %s failed verification", report.Model)
+	}
+	if !asJSON {
+		fmt.Printf("✅ Model %s passed verification\n", report.Model)
+	}
+	return nil
+}
@@ -186,6 +186,10 @@ func runSystemOptimizerTest() {
 	fmt.Printf("  Memory usage: %.2f MB\n", metrics.MemoryUsageMB)
 	fmt.Printf("  Cache hit rate: %.1f%%\n", metrics.CacheHitRate)
 	fmt.Printf("  Active connections: %d\n", metrics.ActiveConnections)
+	fmt.Printf("  GOGC: %d, GOMEMLIMIT: %d MB (%s)\n",
+		metrics.GCPercent, metrics.GCMemoryLimitBytes/(1024*1024), metrics.GCMemoryLimitSource)
+	fmt.Printf("  GC pause p50/p95/p99: %.3f/%.3f/%.3f ms\n",
+		metrics.GCPauseP50MS, metrics.GCPauseP95MS, metrics.GCPauseP99MS)
 	
 	// Check if system is healthy
 	healthy := optimizer.IsHealthy()
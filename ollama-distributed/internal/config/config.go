@@ -4,8 +4,53 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/autoscaling"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/backpressure"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/faultinject"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/features"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/georoute"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/idempotency"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ingest"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/licensing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/maintenance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/qos"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/rag"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/replicascale"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/s3gateway"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/queue"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/timesync"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
 	"github.com/spf13/viper"
 )
 
@@ -25,6 +70,145 @@ type Config struct {
 	Sync        SyncConfig        `yaml:"sync"`
 	Replication ReplicationConfig `yaml:"replication"`
 	Distributed DistributedConfig `yaml:"distributed"`
+	Ollama      OllamaConfig      `yaml:"ollama"`
+	Autoscaling AutoscalingConfig `yaml:"autoscaling"`
+	Kubernetes  KubernetesConfig  `yaml:"kubernetes"`
+
+	// ReplicaScaling adds replicas to hot models and removes them from cold
+	// ones
+	ReplicaScaling replicascale.Config `yaml:"replica_scaling" mapstructure:"replica_scaling"`
+
+	// Federation peers this cluster with independent clusters, which share
+	// model manifests and take each other's inference overflow
+	Federation federation.Config `yaml:"federation"`
+
+	// GeoRouting sends requests to the closest healthy federated region
+	GeoRouting georoute.Config `yaml:"geo_routing" mapstructure:"geo_routing"`
+
+	// Tenancy isolates the tenants sharing the cluster
+	Tenancy tenancy.Config `yaml:"tenancy"`
+
+	// Capture records inference requests for debugging and replay
+	Capture capture.Config `yaml:"capture"`
+
+	// Idempotency replays the response of a POST retried with the same
+	// Idempotency-Key instead of running it again
+	Idempotency idempotency.Config `yaml:"idempotency"`
+
+	// Verification checks models against golden prompts after upgrades
+	Verification verification.Config `yaml:"verification"`
+
+	// Placement holds model affinity and anti-affinity rules, enforced by
+	// replication and the partition planner
+	Placement placement.Constraints `yaml:"placement"`
+
+	// DiskPlacement keeps large model loads off busy slow disks and puts
+	// frequently swapped models on NVMe nodes
+	DiskPlacement placement.DiskConfig `yaml:"disk_placement" mapstructure:"disk_placement"`
+
+	// TimeSync checks this node's clock against NTP and warns about skew
+	TimeSync timesync.Config `yaml:"time_sync" mapstructure:"time_sync"`
+
+	// Licensing tracks model licenses and the policies gating their use
+	Licensing licensing.Config `yaml:"licensing"`
+
+	// VectorIndex stores embeddings for retrieval workflows
+	VectorIndex vectorindex.Config `yaml:"vector_index" mapstructure:"vector_index"`
+
+	// RAG answers questions from the documents of the vector index
+	RAG rag.Config `yaml:"rag"`
+
+	// Ingestion extracts, chunks and embeds documents into the vector index
+	Ingestion ingest.Config `yaml:"ingestion"`
+
+	// GRPC serves the gRPC API alongside the REST API
+	GRPC grpcapi.Config `yaml:"grpc"`
+
+	// ModelScan scans imported models and adapters, quarantining the ones
+	// failing a scan
+	ModelScan modelscan.Config `yaml:"model_scan" mapstructure:"model_scan"`
+
+	// ShardFetch downloads the shards of models placed on this node in the
+	// order its pipeline stage needs them
+	ShardFetch shardfetch.Config `yaml:"shard_fetch" mapstructure:"shard_fetch"`
+
+	// S3Gateway serves the model blobs read-only through the S3 API
+	S3Gateway s3gateway.Config `yaml:"s3_gateway" mapstructure:"s3_gateway"`
+
+	// Redaction removes personal data and secrets from prompts and
+	// completions before they reach logs, captures or webhooks
+	Redaction redaction.Config `yaml:"redaction"`
+
+	// Transforms are hooks modifying inference requests before they are
+	// scheduled and responses before they are returned
+	Transforms transform.Config `yaml:"transforms"`
+
+	// Moderation checks prompts and responses with a moderation model
+	// served by the cluster
+	Moderation moderation.Config `yaml:"moderation"`
+
+	// Events records the history of cluster state changes
+	Events events.Config `yaml:"events"`
+
+	// Maintenance drains nodes during their maintenance windows
+	Maintenance maintenance.Config `yaml:"maintenance"`
+
+	// Features turns experimental capabilities on per node or for a share
+	// of the traffic
+	Features features.Config `yaml:"features"`
+
+	// Warmup warms models up after load and shares compilation caches
+	Warmup warmup.Config `yaml:"warmup"`
+
+	// Speculative drafts tokens with small models for large ones to verify
+	Speculative speculative.Config `yaml:"speculative"`
+
+	// LoadQueue orders the model loads of the node by priority and demand
+	LoadQueue loadqueue.Config `yaml:"load_queue" mapstructure:"load_queue"`
+
+	// History keeps downsampled key metrics for trends over weeks
+	History history.Config `yaml:"history"`
+
+	// Degradation serves smaller fallback models under overload
+	Degradation degradation.Config `yaml:"degradation"`
+
+	// Backpressure bounds what is buffered for slow streaming clients
+	Backpressure backpressure.Config `yaml:"backpressure"`
+
+	// Hedging sends slow embedding calls of the vector index to a second
+	// instance
+	Hedging hedging.Config `yaml:"hedging"`
+
+	// JoinTokens admits nodes only with single-use, expiring join tokens
+	// bound to the cluster CA
+	JoinTokens jointoken.Config `yaml:"join_tokens" mapstructure:"join_tokens"`
+
+	// FaultInjection lets admins inject synthetic faults into the fault
+	// tolerance manager of staging nodes for game days
+	FaultInjection faultinject.Config `yaml:"fault_injection" mapstructure:"fault_injection"`
+
+	// FaultTolerance runs the fault tolerance manager, recovering detected
+	// faults and explaining its choice of recovery strategy
+	FaultTolerance FaultToleranceConfig `yaml:"fault_tolerance" mapstructure:"fault_tolerance"`
+
+	// FailureDetector judges nodes by the heartbeats they send each other
+	// instead of fixed interval health checks
+	FailureDetector failuredetector.Config `yaml:"failure_detector" mapstructure:"failure_detector"`
+
+	// Topology measures the latencies between nodes with their heartbeats,
+	// for the hybrid partition strategy to group pipeline stages by
+	Topology topology.Config `yaml:"topology"`
+
+	// Compatibility sets the version skew tolerated between nodes, which
+	// advertise their versions to each other when they connect
+	Compatibility compat.Config `yaml:"compatibility"`
+
+	// SelfUpdate sets where self-update fetches releases from and the key
+	// they are signed with
+	SelfUpdate selfupdate.Config `yaml:"self_update" mapstructure:"self_update"`
+
+	// Users are the local accounts logging in to the dashboard and API
+	Users users.Config `yaml:"users"`
 }
 
 // NodeConfig holds node-specific configuration
@@ -35,35 +219,135 @@ type NodeConfig struct {
 	Zone        string            `yaml:"zone"`
 	Environment string            `yaml:"environment"`
 	Tags        map[string]string `yaml:"tags"`
+
+	// Labels are matched by placement affinity rules; taints keep models
+	// without a matching toleration off this node
+	Labels map[string]string `yaml:"labels"`
+	Taints []placement.Taint `yaml:"taints"`
 }
 
 // APIConfig holds API server configuration
 type APIConfig struct {
-	Listen      string          `yaml:"listen"`
-	TLS         TLSConfig       `yaml:"tls"`
-	Cors        CorsConfig      `yaml:"cors"`
-	RateLimit   RateLimitConfig `yaml:"rate_limit"`
-	Timeout     time.Duration   `yaml:"timeout"`
-	MaxBodySize int64           `yaml:"max_body_size"`
+	Listen          string          `yaml:"listen"`
+	ListenAddresses []string        `yaml:"listen_addresses" mapstructure:"listen_addresses"` // extra addresses, e.g. "[::]:11434"
+	TLS             TLSConfig       `yaml:"tls"`
+	Cors            CorsConfig      `yaml:"cors"`
+	RateLimit       RateLimitConfig `yaml:"rate_limit"`
+	Timeout         time.Duration   `yaml:"timeout"`
+	MaxBodySize     int64           `yaml:"max_body_size"`
+	AccessLog       AccessLogConfig `yaml:"access_log" mapstructure:"access_log"`
+	Compression     bool            `yaml:"compression"` // gzip/zstd JSON responses
+	// Slow-client protection; zero values fall back to Timeout-based defaults
+	ReadTimeout       time.Duration `yaml:"read_timeout" mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" mapstructure:"idle_timeout"`
+	BodyReadTimeout   time.Duration `yaml:"body_read_timeout" mapstructure:"body_read_timeout"` // 408 if the body is not received in time
+	MaxConnections    int           `yaml:"max_connections" mapstructure:"max_connections"`
+	MaxConnsPerClient int           `yaml:"max_conns_per_client" mapstructure:"max_conns_per_client"`
+	Debug             DebugConfig   `yaml:"debug"`
+	// Middleware arranges the middleware chain requests pass through
+	Middleware MiddlewareConfig `yaml:"middleware"`
+}
+
+// MiddlewareConfig arranges the middleware of the API server. Names are
+// those of the built-in middleware (request_id, logging, in_flight,
+// compression, body_limit, cors, security_headers, headers, rate_limit,
+// auth, quota, capture) or of middleware registered with
+// api.RegisterMiddleware.
+type MiddlewareConfig struct {
+	// Chain runs on every request, in order; empty uses the default chain
+	Chain []string `yaml:"chain"`
+	// ProtectedChain runs after Chain on the authenticated /api/v1 routes;
+	// empty uses the default chain
+	ProtectedChain []string `yaml:"protected_chain" mapstructure:"protected_chain"`
+	// Disabled removes middleware from either chain, defaults included
+	Disabled []string `yaml:"disabled"`
+	// Headers are added to every response by the headers middleware
+	Headers map[string]string `yaml:"headers"`
+	// Quota caps the requests of each caller by the quota middleware
+	Quota QuotaConfig `yaml:"quota"`
+	// Options configure registered middleware, by name
+	Options map[string]map[string]string `yaml:"options"`
+}
+
+// QuotaConfig caps the requests each tenant, user or client address makes
+// in a window. Zero requests disables the quota.
+type QuotaConfig struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"` // 24h when unset
+}
+
+// Validate checks that no middleware appears twice in a chain
+func (c MiddlewareConfig) Validate() error {
+	for _, chain := range [][]string{c.Chain, c.ProtectedChain} {
+		seen := make(map[string]bool, len(chain))
+		for _, name := range chain {
+			if name == "" {
+				return fmt.Errorf("middleware chain with an empty name")
+			}
+			if seen[name] {
+				return fmt.Errorf("middleware %s appears twice in a chain", name)
+			}
+			seen[name] = true
+		}
+	}
+	if c.Quota.Requests < 0 || c.Quota.Window < 0 {
+		return fmt.Errorf("quota requests and window must not be negative")
+	}
+	return nil
+}
+
+// DebugConfig enables the pprof, expvar and dump endpoints under /debug.
+// They are restricted to admins and disabled by default.
+type DebugConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	DumpDir string `yaml:"dump_dir" mapstructure:"dump_dir"` // where POST /debug/dump writes profiles
+}
+
+// AccessLogConfig holds HTTP access log configuration. Prompt contents are
+// redacted unless LogPrompts is set.
+type AccessLogConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	SampleRate    float64       `yaml:"sample_rate" mapstructure:"sample_rate"`
+	SampledRoutes []string      `yaml:"sampled_routes" mapstructure:"sampled_routes"`
+	SlowThreshold time.Duration `yaml:"slow_threshold" mapstructure:"slow_threshold"`
+	LogPrompts    bool          `yaml:"log_prompts" mapstructure:"log_prompts"`
 }
 
 // P2PConfig holds P2P networking configuration
 type P2PConfig struct {
-	Listen       string        `yaml:"listen"`
-	Bootstrap    []string      `yaml:"bootstrap"`
-	PrivateKey   string        `yaml:"private_key"`
-	EnableDHT    bool          `yaml:"enable_dht"`
-	EnablePubSub bool          `yaml:"enable_pubsub"`
-	ConnMgrLow   int           `yaml:"conn_mgr_low"`
-	ConnMgrHigh  int           `yaml:"conn_mgr_high"`
-	ConnMgrGrace string        `yaml:"conn_mgr_grace"`
-	DialTimeout  time.Duration `yaml:"dial_timeout"`
-	MaxStreams   int           `yaml:"max_streams"`
+	Listen            string   `yaml:"listen"`
+	ListenAddresses   []string `yaml:"listen_addresses" mapstructure:"listen_addresses"` // extra multiaddrs, e.g. "/ip6/::/tcp/9999"
+	AnnounceAddresses []string `yaml:"announce_addresses" mapstructure:"announce_addresses"`
+	// QUIC transport; QUICPreferredProtocols overrides the default set of
+	// inference and model transfer protocols routed over QUIC, falling back
+	// to TCP
+	EnableQUIC             bool          `yaml:"enable_quic" mapstructure:"enable_quic"`
+	QUICPreferredProtocols []string      `yaml:"quic_preferred_protocols" mapstructure:"quic_preferred_protocols"`
+	Bootstrap              []string      `yaml:"bootstrap"`
+	PrivateKey             string        `yaml:"private_key"`
+	EnableDHT              bool          `yaml:"enable_dht"`
+	EnablePubSub           bool          `yaml:"enable_pubsub"`
+	ConnMgrLow             int           `yaml:"conn_mgr_low"`
+	ConnMgrHigh            int           `yaml:"conn_mgr_high"`
+	ConnMgrGrace           string        `yaml:"conn_mgr_grace"`
+	DialTimeout            time.Duration `yaml:"dial_timeout"`
+	MaxStreams             int           `yaml:"max_streams"`
 	// Discovery configuration
 	AutoDiscovery    bool   `yaml:"auto_discovery" mapstructure:"auto_discovery"`
 	RendezvousString string `yaml:"rendezvous_string" mapstructure:"rendezvous_string"`
 	EnableMDNS       bool   `yaml:"enable_mdns" mapstructure:"enable_mdns"`
 	MDNSService      string `yaml:"mdns_service" mapstructure:"mdns_service"`
+	// How often host CPU, memory, disk, network and GPU usage is sampled
+	// and advertised to peers for scheduling
+	ResourceInterval time.Duration `yaml:"resource_interval" mapstructure:"resource_interval"`
+	// QoS queues outbound messages by class so that heartbeats, consensus
+	// and health checks are not starved by bulk model transfers
+	QoS qos.Config `yaml:"qos"`
+	// Reputation scores peers by protocol violations, failed transfers and
+	// inconsistent results, and quarantines those scoring low
+	Reputation reputation.Config `yaml:"reputation"`
 }
 
 // ConsensusConfig holds consensus engine configuration
@@ -93,6 +377,104 @@ type SchedulerConfig struct {
 	RetryDelay          time.Duration `yaml:"retry_delay"`
 	QueueSize           int           `yaml:"queue_size"`
 	WorkerCount         int           `yaml:"worker_count"`
+
+	// Deterministic orders nodes by ID and seeds random choices with Seed
+	// so integration tests and bug reproductions schedule identically
+	Deterministic bool  `yaml:"deterministic"`
+	Seed          int64 `yaml:"seed"`
+
+	// StrategyRules is a YAML file of weighted-rules partition strategies
+	// composed from the built-in ones. StrategyPlugins are Go plugin files
+	// exporting NewStrategy, loaded by builds with -tags partitionplugins.
+	StrategyRules   string   `yaml:"strategy_rules"`
+	StrategyPlugins []string `yaml:"strategy_plugins"`
+
+	// SelectionHistorySize bounds the strategy selections kept for
+	// /api/v1/scheduler/selections; older ones than SelectionRetention are
+	// dropped too
+	SelectionHistorySize int           `yaml:"selection_history_size" mapstructure:"selection_history_size"`
+	SelectionRetention   time.Duration `yaml:"selection_retention" mapstructure:"selection_retention"`
+
+	// FairShare queues requests by weighted fair queuing across tenants
+	FairShare FairShareConfig `yaml:"fair_share" mapstructure:"fair_share"`
+
+	// QueueBackend is where requests wait for a worker: "memory" (the
+	// default) in this node's queues, or "redis" or "nats" in Redis Streams
+	// or NATS JetStream, where they survive restarts and any node can run
+	// them
+	QueueBackend string      `yaml:"queue_backend" mapstructure:"queue_backend"`
+	Queue        QueueConfig `yaml:"queue"`
+
+	// ContextNegotiation accommodates requests asking for more context
+	// than the selected node has memory for
+	ContextNegotiation ContextNegotiationConfig `yaml:"context_negotiation" mapstructure:"context_negotiation"`
+
+	// Partitioning tunes the built-in partition strategies
+	Partitioning PartitioningConfig `yaml:"partitioning"`
+
+	// FlapQuarantine takes nodes that keep failing and recovering out of
+	// scheduling
+	FlapQuarantine FlapQuarantineConfig `yaml:"flap_quarantine" mapstructure:"flap_quarantine"`
+}
+
+// PartitioningConfig holds the tunables of each partition strategy under
+// strategies.<name>, reloaded from the config file on SIGHUP
+type PartitioningConfig struct {
+	Strategies partitioning.StrategiesConfig `yaml:"strategies"`
+}
+
+// QueueConfig locates the shared queue of the redis and nats backends
+type QueueConfig struct {
+	URL    string `yaml:"url"`    // redis://[:password@]host:port[/db] or nats://[user:password@]host:port
+	Stream string `yaml:"stream"` // stream name, ollama-scheduler by default
+	// Group is the consumer group, or durable consumer, all nodes take
+	// requests through
+	Group string `yaml:"group"`
+	// AckTimeout is how long a request taken by a node may run before it
+	// is handed to another node, e.g. because the first one died
+	AckTimeout time.Duration `yaml:"ack_timeout" mapstructure:"ack_timeout"`
+}
+
+// ContextNegotiationConfig configures what happens to a request whose
+// num_ctx needs more memory than the selected node has free: it is moved
+// to a node with enough, split across nodes by tensor parallelism, or
+// served with the largest context that fits and a warning.
+type ContextNegotiationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// AllowSplit splits requests no single node can hold across nodes
+	AllowSplit bool `yaml:"allow_split" mapstructure:"allow_split"`
+	// MinContext is the smallest context a request is reduced to before
+	// it fails instead; 0 means 2048
+	MinContext int `yaml:"min_context" mapstructure:"min_context"`
+	// KVBytesPerToken is the memory a token of context takes for models
+	// without a kv_bytes_per_token metadata value; 0 estimates it from the
+	// model size
+	KVBytesPerToken int64 `yaml:"kv_bytes_per_token" mapstructure:"kv_bytes_per_token"`
+}
+
+// FairShareConfig configures fair-share scheduling across tenants. While
+// several tenants have requests queued, each is dispatched requests in
+// proportion to its weight, so a burst from one cannot starve the others.
+type FairShareConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultWeight applies to tenants without a weight, including
+	// requests without a tenant; 0 means 1
+	DefaultWeight float64            `yaml:"default_weight" mapstructure:"default_weight"`
+	Weights       map[string]float64 `yaml:"weights"`
+}
+
+// FlapQuarantineConfig configures the quarantine of flapping nodes. A node
+// recovering from Threshold failures within Window is quarantined: it is
+// removed from scheduling and demoted to a non-voting Raft member until it
+// is released by hand, or after Duration.
+type FlapQuarantineConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Window is 10m and Threshold 3 when 0
+	Window    time.Duration `yaml:"window"`
+	Threshold int           `yaml:"threshold"`
+	// Duration is how long a quarantine lasts; 0 keeps nodes quarantined
+	// until released by hand
+	Duration time.Duration `yaml:"duration"`
 }
 
 // StorageConfig holds storage configuration
@@ -111,6 +493,34 @@ type SecurityConfig struct {
 	Encryption EncryptionConfig `yaml:"encryption"`
 	Firewall   FirewallConfig   `yaml:"firewall"`
 	Audit      AuditConfig      `yaml:"audit"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+}
+
+// SecretsConfig configures the secret stores that credential fields may
+// reference instead of holding a literal value, e.g.
+// secret_key: "vault:secret/data/ollama#jwt_key"
+type SecretsConfig struct {
+	CacheTTL      time.Duration      `yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	RenewInterval time.Duration      `yaml:"renew_interval" mapstructure:"renew_interval"`
+	FilesDir      string             `yaml:"files_dir" mapstructure:"files_dir"` // mounted Kubernetes secrets
+	Vault         VaultSecretsConfig `yaml:"vault"`
+	AWS           AWSSecretsConfig   `yaml:"aws"`
+}
+
+// VaultSecretsConfig holds HashiCorp Vault connection settings. Address and
+// token fall back to VAULT_ADDR and VAULT_TOKEN.
+type VaultSecretsConfig struct {
+	Address   string `yaml:"address"`
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file" mapstructure:"token_file"` // e.g. a Vault agent sink
+	Namespace string `yaml:"namespace"`
+}
+
+// AWSSecretsConfig holds AWS Secrets Manager settings. Credentials are read
+// from the standard AWS environment variables.
+type AWSSecretsConfig struct {
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
 }
 
 // TLSConfig holds TLS configuration
@@ -126,11 +536,26 @@ type TLSConfig struct {
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	Enabled     bool          `yaml:"enabled"`
-	Method      string        `yaml:"method"` // jwt, oauth, x509
+	Method      string        `yaml:"method"` // jwt, oauth, oidc, x509
 	TokenExpiry time.Duration `yaml:"token_expiry"`
 	SecretKey   string        `yaml:"secret_key"`
 	Issuer      string        `yaml:"issuer"`
 	Audience    string        `yaml:"audience"`
+	OIDC        OIDCConfig    `yaml:"oidc"`
+}
+
+// OIDCConfig holds OpenID Connect single sign-on configuration, used when
+// the auth method is "oidc"
+type OIDCConfig struct {
+	IssuerURL    string            `yaml:"issuer_url" mapstructure:"issuer_url"`
+	ClientID     string            `yaml:"client_id" mapstructure:"client_id"`
+	ClientSecret string            `yaml:"client_secret" mapstructure:"client_secret"`
+	RedirectURL  string            `yaml:"redirect_url" mapstructure:"redirect_url"` // dashboard /auth/oidc/callback
+	Scopes       []string          `yaml:"scopes"`
+	Audiences    []string          `yaml:"audiences"`
+	GroupsClaim  string            `yaml:"groups_claim" mapstructure:"groups_claim"`
+	RoleMappings map[string]string `yaml:"role_mappings" mapstructure:"role_mappings"` // IdP group -> RBAC role
+	DefaultRole  string            `yaml:"default_role" mapstructure:"default_role"`
 }
 
 // EncryptionConfig holds encryption configuration
@@ -164,14 +589,30 @@ type AuditConfig struct {
 }
 
 // CorsConfig holds CORS configuration
+// AllowedOrigins entries are exact origins, "*" or subdomain wildcards such
+// as "https://*.example.com".
 type CorsConfig struct {
 	Enabled          bool     `yaml:"enabled"`
-	AllowedOrigins   []string `yaml:"allowed_origins"`
-	AllowedMethods   []string `yaml:"allowed_methods"`
-	AllowedHeaders   []string `yaml:"allowed_headers"`
-	ExposedHeaders   []string `yaml:"exposed_headers"`
-	AllowCredentials bool     `yaml:"allow_credentials"`
-	MaxAge           int      `yaml:"max_age"`
+	AllowedOrigins   []string `yaml:"allowed_origins" mapstructure:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods" mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers" mapstructure:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers" mapstructure:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials" mapstructure:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age" mapstructure:"max_age"`
+}
+
+// Validate rejects allowing credentials from every origin, which would let
+// any site make credentialed calls
+func (c CorsConfig) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return fmt.Errorf(`allow_credentials cannot be combined with the "*" origin; list the allowed origins`)
+		}
+	}
+	return nil
 }
 
 // RateLimitConfig holds rate limiting configuration
@@ -189,15 +630,140 @@ type WebConfig struct {
 	StaticDir   string    `yaml:"static_dir"`
 	TemplateDir string    `yaml:"template_dir"`
 	TLS         TLSConfig `yaml:"tls"`
+
+	// Sessions enables username and password login to the dashboard, with
+	// optional TOTP two-factor authentication
+	Sessions session.Config `yaml:"sessions"`
 }
 
 // MetricsConfig holds metrics configuration
 type MetricsConfig struct {
-	Enabled   bool   `yaml:"enabled"`
-	Listen    string `yaml:"listen"`
-	Path      string `yaml:"path"`
-	Namespace string `yaml:"namespace"`
-	Subsystem string `yaml:"subsystem"`
+	Enabled   bool           `yaml:"enabled"`
+	Listen    string         `yaml:"listen"`
+	Path      string         `yaml:"path"`
+	Namespace string         `yaml:"namespace"`
+	Subsystem string         `yaml:"subsystem"`
+	SLO       SLOConfig      `yaml:"slo"`
+	Watchdog  WatchdogConfig `yaml:"watchdog"`
+	Anomaly   AnomalyConfig  `yaml:"anomaly"`
+	// ModelUsage samples per-model request volume, tenants, latency,
+	// tokens and errors into the metrics history for usage analytics
+	ModelUsage ModelUsageConfig `yaml:"model_usage" mapstructure:"model_usage"`
+}
+
+// ModelUsageConfig configures per-model usage analytics
+type ModelUsageConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// AnomalyConfig configures the detector that learns the normal latency and
+// error rate of every model on every node and alerts when they shift
+type AnomalyConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Interval        time.Duration `yaml:"interval"`                                         // length of the windows judged
+	MinSamples      int           `yaml:"min_samples" mapstructure:"min_samples"`           // requests a window needs to be judged
+	LearningWindows int           `yaml:"learning_windows" mapstructure:"learning_windows"` // windows learned before alerting
+	Threshold       float64       `yaml:"threshold"`                                        // standard deviations from the baseline
+	AdaptAfter      int           `yaml:"adapt_after" mapstructure:"adapt_after"`           // anomalous windows taken as the new normal
+	AlertWebhooks   []string      `yaml:"alert_webhooks" mapstructure:"alert_webhooks"`
+}
+
+// WatchdogConfig configures the watchdog that samples this node's goroutines,
+// live heap and mutex waits and alerts when they leak. Zero thresholds
+// disable the corresponding check.
+type WatchdogConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Interval        time.Duration `yaml:"interval"`
+	Window          int           `yaml:"window"` // samples growth is computed over
+	MaxGoroutines   int           `yaml:"max_goroutines" mapstructure:"max_goroutines"`
+	GoroutineGrowth float64       `yaml:"goroutine_growth" mapstructure:"goroutine_growth"` // e.g. 1.0 alerts when goroutines double over the window
+	MaxHeapBytes    uint64        `yaml:"max_heap_bytes" mapstructure:"max_heap_bytes"`
+	HeapGrowth      float64       `yaml:"heap_growth" mapstructure:"heap_growth"`
+	MutexWaitRatio  float64       `yaml:"mutex_wait_ratio" mapstructure:"mutex_wait_ratio"` // seconds blocked on mutexes per second
+	DeadlockAfter   time.Duration `yaml:"deadlock_after" mapstructure:"deadlock_after"`
+
+	// DiagnosticsDir receives goroutine, heap, mutex and block profiles when
+	// an alert fires; empty disables bundles
+	DiagnosticsDir      string        `yaml:"diagnostics_dir" mapstructure:"diagnostics_dir"`
+	DiagnosticsCooldown time.Duration `yaml:"diagnostics_cooldown" mapstructure:"diagnostics_cooldown"`
+	MaxBundles          int           `yaml:"max_bundles" mapstructure:"max_bundles"`
+	AlertWebhooks       []string      `yaml:"alert_webhooks" mapstructure:"alert_webhooks"`
+}
+
+// SLOConfig holds service level objectives evaluated against inference
+// requests. Fast burn alerts go to the configured notification sinks.
+type SLOConfig struct {
+	EvaluationInterval time.Duration        `yaml:"evaluation_interval" mapstructure:"evaluation_interval"`
+	FastBurnRate       float64              `yaml:"fast_burn_rate" mapstructure:"fast_burn_rate"`
+	AlertWebhooks      []string             `yaml:"alert_webhooks" mapstructure:"alert_webhooks"`
+	Objectives         []SLOObjectiveConfig `yaml:"objectives"`
+}
+
+// SLOObjectiveConfig defines one objective, e.g. 99% of chat requests see
+// their first token within 2s over 30 days
+type SLOObjectiveConfig struct {
+	Name        string        `yaml:"name"`
+	Indicator   string        `yaml:"indicator"`                                // availability, latency or ttft
+	RequestType string        `yaml:"request_type" mapstructure:"request_type"` // generate, chat or embed; empty matches all
+	Model       string        `yaml:"model"`                                    // empty matches all
+	Threshold   time.Duration `yaml:"threshold"`
+	Target      float64       `yaml:"target"` // e.g. 0.99
+	Window      time.Duration `yaml:"window"`
+}
+
+// AutoscalingConfig configures the controller that grows and shrinks the
+// cloud node pool the cluster runs on. Only the consensus leader scales.
+type AutoscalingConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Provider           string        `yaml:"provider"` // aws_asg, gcp_mig or webhook
+	MinNodes           int           `yaml:"min_nodes" mapstructure:"min_nodes"`
+	MaxNodes           int           `yaml:"max_nodes" mapstructure:"max_nodes"`
+	ScaleUpCooldown    time.Duration `yaml:"scale_up_cooldown" mapstructure:"scale_up_cooldown"`
+	ScaleDownCooldown  time.Duration `yaml:"scale_down_cooldown" mapstructure:"scale_down_cooldown"`
+	EvaluationInterval time.Duration `yaml:"evaluation_interval" mapstructure:"evaluation_interval"`
+
+	// Scale up when more requests than this are queued, when average GPU
+	// utilization exceeds GPUThreshold percent, or when an SLO's error budget
+	// burns faster than SLOBurnThreshold
+	QueueThreshold   int     `yaml:"queue_threshold" mapstructure:"queue_threshold"`
+	GPUThreshold     float64 `yaml:"gpu_threshold" mapstructure:"gpu_threshold"`
+	SLOBurnThreshold float64 `yaml:"slo_burn_threshold" mapstructure:"slo_burn_threshold"`
+
+	// IdleAfter is how long a node must run nothing before it is released
+	IdleAfter time.Duration `yaml:"idle_after" mapstructure:"idle_after"`
+
+	AWS     autoscaling.AWSASGConfig  `yaml:"aws"`
+	GCP     autoscaling.GCPMIGConfig  `yaml:"gcp"`
+	Webhook autoscaling.WebhookConfig `yaml:"webhook"`
+}
+
+// KubernetesConfig holds settings for running as a StatefulSet or under an
+// operator. The pod name and namespace are read from the POD_NAME and
+// POD_NAMESPACE environment variables, set through the downward API.
+type KubernetesConfig struct {
+	// PodIdentity names the node after its pod when node.id is not set, so
+	// that a StatefulSet pod keeps its Raft identity across restarts
+	PodIdentity bool `yaml:"pod_identity" mapstructure:"pod_identity"`
+
+	// ReadyMaxLag is how many committed Raft entries may remain unapplied
+	// before /readyz reports the node as not ready
+	ReadyMaxLag uint64 `yaml:"ready_max_lag" mapstructure:"ready_max_lag"`
+
+	// DrainTimeout bounds how long the pre-stop hook waits for in-flight
+	// requests; keep it below the pod's termination grace period
+	DrainTimeout time.Duration `yaml:"drain_timeout" mapstructure:"drain_timeout"`
+
+	// PreStopToken, when set, must be sent as X-PreStop-Token by callers of
+	// the pre-stop hook other than the node itself
+	PreStopToken string `yaml:"prestop_token" mapstructure:"prestop_token"`
+
+	// LeaderLease is the name of a coordination.k8s.io Lease the Raft
+	// leader keeps as holder; empty disables it
+	LeaderLease string `yaml:"leader_lease" mapstructure:"leader_lease"`
+
+	// LeaderLabel labels each pod with its Raft role, so that a Service can
+	// select the leader
+	LeaderLabel bool `yaml:"leader_label" mapstructure:"leader_label"`
 }
 
 // LoggingConfig holds logging configuration
@@ -210,6 +776,24 @@ type LoggingConfig struct {
 	MaxAge     int        `yaml:"max_age"`
 	MaxBackups int        `yaml:"max_backups"`
 	Compress   bool       `yaml:"compress"`
+
+	// BufferSize is how many recent records are kept in memory for the
+	// /api/v1/logs endpoint
+	BufferSize int           `yaml:"buffer_size" mapstructure:"buffer_size"`
+	Ship       LogShipConfig `yaml:"ship"`
+}
+
+// LogShipConfig configures forwarding of node logs to Loki or Elasticsearch
+type LogShipConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Type          string            `yaml:"type"` // loki or elasticsearch
+	URL           string            `yaml:"url"`
+	Index         string            `yaml:"index"`  // Elasticsearch only
+	Labels        map[string]string `yaml:"labels"` // Loki only
+	Username      string            `yaml:"username"`
+	Password      string            `yaml:"password"`
+	BatchSize     int               `yaml:"batch_size" mapstructure:"batch_size"`
+	FlushInterval time.Duration     `yaml:"flush_interval" mapstructure:"flush_interval"`
 }
 
 // FileConfig holds file logging configuration
@@ -244,6 +828,79 @@ type ReplicationConfig struct {
 	HealthCheckTimeout        time.Duration `yaml:"health_check_timeout"`
 }
 
+// FaultToleranceConfig holds settings for recovering detected faults.
+// Recoveries whose chosen strategy reaches RecoveryApproval ("low",
+// "medium" or "high") wait for an admin to approve them; empty executes
+// every recovery at once.
+type FaultToleranceConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	RecoveryApproval string `yaml:"recovery_approval" mapstructure:"recovery_approval"`
+}
+
+// Ollama runtimes
+const (
+	// OllamaRuntimeExternal spawns or attaches to an installed Ollama daemon
+	OllamaRuntimeExternal = "external"
+	// OllamaRuntimeNative serves GGUF models in-process with llama.cpp
+	OllamaRuntimeNative = "native"
+)
+
+// OllamaConfig holds settings for the local Ollama server that the
+// integration layer spawns. A server started by the node is restarted with
+// exponential backoff when it exits, up to MaxRestarts times per
+// RestartWindow.
+type OllamaConfig struct {
+	// Runtime selects between the external Ollama daemon and the built-in
+	// native runner, which needs a binary built with the llamacpp tag
+	Runtime string             `yaml:"runtime"`
+	Native  NativeRunnerConfig `yaml:"native"`
+
+	MaxRestarts       int           `yaml:"max_restarts" mapstructure:"max_restarts"`
+	RestartWindow     time.Duration `yaml:"restart_window" mapstructure:"restart_window"`
+	RestartBackoff    time.Duration `yaml:"restart_backoff" mapstructure:"restart_backoff"`
+	MaxRestartBackoff time.Duration `yaml:"max_restart_backoff" mapstructure:"max_restart_backoff"`
+	Sandbox           SandboxConfig `yaml:"sandbox"`
+
+	// Instances lists the Ollama servers to supervise on this node, e.g. one
+	// per GPU on large hosts. When empty a single server is started on
+	// BasePort, or one per detected GPU if InstancePerGPU is set.
+	Instances      []OllamaInstanceConfig `yaml:"instances"`
+	InstancePerGPU bool                   `yaml:"instance_per_gpu" mapstructure:"instance_per_gpu"`
+	BasePort       int                    `yaml:"base_port" mapstructure:"base_port"`
+}
+
+// NativeRunnerConfig configures the in-process llama.cpp runner. Models are
+// the GGUF files in ModelsDir, named after the file without its extension.
+type NativeRunnerConfig struct {
+	ModelsDir   string `yaml:"models_dir" mapstructure:"models_dir"`     // defaults to storage.model_dir
+	Threads     int    `yaml:"threads"`                                  // 0 = number of CPUs
+	ContextSize int    `yaml:"context_size" mapstructure:"context_size"` // tokens
+	GPULayers   int    `yaml:"gpu_layers" mapstructure:"gpu_layers"`     // layers offloaded to the GPU, 0 = CPU only
+	MaxTokens   int    `yaml:"max_tokens" mapstructure:"max_tokens"`     // default num_predict
+}
+
+// OllamaInstanceConfig describes one supervised Ollama server
+type OllamaInstanceConfig struct {
+	Name string   `yaml:"name"`
+	Port int      `yaml:"port"`
+	GPUs []string `yaml:"gpus"` // device indices or UUIDs, empty = all visible GPUs
+}
+
+// SandboxConfig isolates the spawned Ollama server so that a runaway model
+// cannot take down the node. Resource limits use cgroup v2, are only
+// available on Linux and apply to each instance separately.
+type SandboxConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	CPULimit     float64 `yaml:"cpu_limit" mapstructure:"cpu_limit"`         // cores, 0 = unlimited
+	MemoryLimit  int64   `yaml:"memory_limit" mapstructure:"memory_limit"`   // bytes, 0 = unlimited
+	PidsLimit    int64   `yaml:"pids_limit" mapstructure:"pids_limit"`       // 0 = unlimited
+	CgroupParent string  `yaml:"cgroup_parent" mapstructure:"cgroup_parent"` // delegated cgroup v2 directory
+
+	// Optional confinement, applied by wrapping the command
+	SeccompProfile  string `yaml:"seccomp_profile" mapstructure:"seccomp_profile"`   // compiled BPF filter, loaded with bwrap
+	AppArmorProfile string `yaml:"apparmor_profile" mapstructure:"apparmor_profile"` // loaded profile name, applied with aa-exec
+}
+
 // DistributedConfig holds distributed model management configuration
 type DistributedConfig struct {
 	Storage     *StorageConfig     `yaml:"storage"`
@@ -297,9 +954,17 @@ func DefaultConfig() *Config {
 			Tags:        make(map[string]string),
 		},
 		API: APIConfig{
-			Listen:      "0.0.0.0:11434",
-			Timeout:     30 * time.Second,
-			MaxBodySize: 32 * 1024 * 1024, // 32MB
+			Listen:            "0.0.0.0:11434",
+			Timeout:           30 * time.Second,
+			MaxBodySize:       32 * 1024 * 1024, // 32MB
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			BodyReadTimeout:   20 * time.Second,
+			MaxConnections:    4096,
+			MaxConnsPerClient: 64,
+			Compression:       true,
 			TLS: TLSConfig{
 				Enabled:    false,
 				MinVersion: "1.2",
@@ -308,7 +973,8 @@ func DefaultConfig() *Config {
 				Enabled:          true,
 				AllowedOrigins:   []string{"http://localhost:8080", "https://localhost:8080"},
 				AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-				AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With"},
+				AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With", "X-CSRF-Token", "X-Request-ID"},
+				ExposedHeaders:   []string{"Content-Length", "X-Request-ID"},
 				AllowCredentials: true,
 				MaxAge:           3600,
 			},
@@ -318,17 +984,31 @@ func DefaultConfig() *Config {
 				Burst:   2000,
 				Window:  time.Minute,
 			},
+			AccessLog: AccessLogConfig{
+				Enabled:       true,
+				SampleRate:    0.01,
+				SampledRoutes: []string{"/api/v1/health", "/api/v1/metrics", "/api/v1/ws"},
+				SlowThreshold: 5 * time.Second,
+			},
+			Debug: DebugConfig{
+				Enabled: false,
+				DumpDir: "./data/debug",
+			},
 		},
 		P2P: P2PConfig{
-			Listen:       "/ip4/0.0.0.0/tcp/9999",
-			Bootstrap:    []string{},
-			EnableDHT:    true,
-			EnablePubSub: true,
-			ConnMgrLow:   50,
-			ConnMgrHigh:  200,
-			ConnMgrGrace: "30s",
-			DialTimeout:  30 * time.Second,
-			MaxStreams:   1000,
+			Listen:           "/ip4/0.0.0.0/tcp/9999",
+			ListenAddresses:  []string{"/ip4/0.0.0.0/udp/9999/quic-v1"},
+			EnableQUIC:       true,
+			Bootstrap:        []string{},
+			EnableDHT:        true,
+			EnablePubSub:     true,
+			ConnMgrLow:       50,
+			ConnMgrHigh:      200,
+			ConnMgrGrace:     "30s",
+			DialTimeout:      30 * time.Second,
+			MaxStreams:       1000,
+			ResourceInterval: 10 * time.Second,
+			QoS:              qos.Config{Enabled: true},
 		},
 		Consensus: ConsensusConfig{
 			DataDir:           "./data/consensus",
@@ -376,6 +1056,11 @@ func DefaultConfig() *Config {
 				LogFile: "./logs/audit.log",
 				Format:  "json",
 			},
+			Secrets: SecretsConfig{
+				CacheTTL:      5 * time.Minute,
+				RenewInterval: 30 * time.Second,
+				FilesDir:      "/var/run/secrets/ollama",
+			},
 		},
 		Web: WebConfig{
 			Enabled:   true,
@@ -391,6 +1076,29 @@ func DefaultConfig() *Config {
 			Path:      "/metrics",
 			Namespace: "ollama",
 			Subsystem: "distributed",
+			SLO: SLOConfig{
+				EvaluationInterval: time.Minute,
+				FastBurnRate:       14.4,
+			},
+			Watchdog: WatchdogConfig{
+				Enabled:             false,
+				Interval:            30 * time.Second,
+				Window:              20,
+				GoroutineGrowth:     1.0,
+				HeapGrowth:          1.0,
+				DeadlockAfter:       10 * time.Minute,
+				DiagnosticsDir:      "./data/diagnostics",
+				DiagnosticsCooldown: 15 * time.Minute,
+				MaxBundles:          5,
+			},
+			Anomaly: AnomalyConfig{
+				Enabled:         false,
+				Interval:        time.Minute,
+				MinSamples:      20,
+				LearningWindows: 30,
+				Threshold:       4,
+				AdaptAfter:      30,
+			},
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -400,9 +1108,25 @@ func DefaultConfig() *Config {
 			MaxAge:     30,
 			MaxBackups: 10,
 			Compress:   true,
+			BufferSize: 5000,
 		},
 		Sync:        syncConfig,
 		Replication: replicationConfig,
+		Ollama: OllamaConfig{
+			Runtime: OllamaRuntimeExternal,
+			Native: NativeRunnerConfig{
+				ContextSize: 4096,
+				MaxTokens:   512,
+			},
+			MaxRestarts:       5,
+			RestartWindow:     10 * time.Minute,
+			RestartBackoff:    time.Second,
+			MaxRestartBackoff: time.Minute,
+			BasePort:          11434,
+			Sandbox: SandboxConfig{
+				CgroupParent: "/sys/fs/cgroup/ollama-distributed",
+			},
+		},
 		Distributed: DistributedConfig{
 			Storage:     &storageConfig,
 			Sync:        &syncConfig,
@@ -410,6 +1134,36 @@ func DefaultConfig() *Config {
 			CASDir:      "./data/cas",
 			DeltaDir:    "./data/deltas",
 		},
+		Kubernetes: KubernetesConfig{
+			PodIdentity:  true,
+			ReadyMaxLag:  100,
+			DrainTimeout: 25 * time.Second,
+		},
+		Autoscaling: AutoscalingConfig{
+			MinNodes:           1,
+			MaxNodes:           10,
+			ScaleUpCooldown:    3 * time.Minute,
+			ScaleDownCooldown:  10 * time.Minute,
+			EvaluationInterval: 30 * time.Second,
+			QueueThreshold:     50,
+			GPUThreshold:       80,
+			SLOBurnThreshold:   6,
+			IdleAfter:          15 * time.Minute,
+		},
+		Federation: federation.Config{
+			ExchangeInterval: 30 * time.Second,
+			Overflow: federation.OverflowPolicy{
+				QueueThreshold: 50,
+				MaxPerPeer:     16,
+				MaxInbound:     16,
+			},
+		},
+		TimeSync: timesync.Config{
+			Enabled:       true,
+			Servers:       []string{timesync.DefaultServer},
+			Interval:      timesync.DefaultInterval,
+			WarnThreshold: timesync.DefaultWarnThreshold,
+		},
 	}
 }
 
@@ -477,8 +1231,13 @@ func Load(configFile string) (*Config, error) {
 	viper.BindEnv("storage.model_dir", "OLLAMA_MODEL_DIR")
 	viper.BindEnv("storage.cache_dir", "OLLAMA_CACHE_DIR")
 
-	// Read configuration
-	if err := viper.ReadInConfig(); err != nil {
+	viper.BindEnv("scheduler.deterministic", "OLLAMA_SCHEDULER_DETERMINISTIC")
+	viper.BindEnv("scheduler.seed", "OLLAMA_SCHEDULER_SEED")
+
+	// Read configuration, unless it comes from the environment alone
+	if configFile == "" && envOnly() {
+		fmt.Printf("Environment-only configuration, not reading a config file\n")
+	} else if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
@@ -493,12 +1252,25 @@ func Load(configFile string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// OLLAMA_DISTRIBUTED_* variables override the file and the OLLAMA_* ones
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+
 	// Set environment in config if not already set
 	if config.Node.Environment == "" {
 		config.Node.Environment = environment
 	}
 
-	// Generate node ID if not provided
+	// Name the node after its pod, or generate an ID, if not provided
+	if config.Node.ID == "" && config.Kubernetes.PodIdentity {
+		if podName := os.Getenv("POD_NAME"); podName != "" {
+			config.Node.ID = podName
+			if config.Node.Name == "" {
+				config.Node.Name = podName
+			}
+		}
+	}
 	if config.Node.ID == "" {
 		hostname, _ := os.Hostname()
 		config.Node.ID = fmt.Sprintf("%s-%s", hostname, environment)
@@ -517,7 +1289,7 @@ func Load(configFile string) (*Config, error) {
 	// Log configuration summary
 	fmt.Printf("Configuration loaded for environment: %s\n", config.Node.Environment)
 	fmt.Printf("Node ID: %s\n", config.Node.ID)
-	fmt.Printf("API Listen: %s\n", config.API.Listen)
+	fmt.Printf("API Listen: %s\n", strings.Join(config.API.AllListenAddresses(), ", "))
 	fmt.Printf("Metrics Enabled: %t\n", config.Metrics.Enabled)
 	fmt.Printf("TLS Enabled: %t\n", config.Security.TLS.Enabled)
 	fmt.Printf("Auth Enabled: %t\n", config.Security.Auth.Enabled)
@@ -565,6 +1337,321 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := c.API.Middleware.Validate(); err != nil {
+		return fmt.Errorf("invalid API middleware: %w", err)
+	}
+	if err := c.API.Cors.Validate(); err != nil {
+		return fmt.Errorf("invalid API CORS: %w", err)
+	}
+
+	if c.Autoscaling.Enabled {
+		switch c.Autoscaling.Provider {
+		case autoscaling.ProviderAWSASG, autoscaling.ProviderGCPMIG, autoscaling.ProviderWebhook:
+		default:
+			return fmt.Errorf("unknown autoscaling provider %q", c.Autoscaling.Provider)
+		}
+		if c.Autoscaling.MinNodes < 0 || c.Autoscaling.MaxNodes < c.Autoscaling.MinNodes {
+			return fmt.Errorf("autoscaling needs 0 <= min_nodes <= max_nodes, got %d and %d",
+				c.Autoscaling.MinNodes, c.Autoscaling.MaxNodes)
+		}
+	}
+
+	if c.ReplicaScaling.Enabled {
+		if err := c.ReplicaScaling.Validate(); err != nil {
+			return fmt.Errorf("invalid replica scaling: %w", err)
+		}
+	}
+
+	if w := c.Metrics.Watchdog; w.Enabled {
+		if w.MaxGoroutines < 0 || w.GoroutineGrowth < 0 || w.HeapGrowth < 0 || w.MutexWaitRatio < 0 || w.DeadlockAfter < 0 {
+			return fmt.Errorf("watchdog thresholds must not be negative")
+		}
+		if w.DeadlockAfter > 0 && w.DeadlockAfter < time.Minute {
+			return fmt.Errorf("watchdog deadlock_after must be at least 1m, goroutine dumps report waits in minutes")
+		}
+	}
+
+	if a := c.Metrics.Anomaly; a.Enabled {
+		if a.Interval < 0 || a.MinSamples < 0 || a.LearningWindows < 0 || a.Threshold < 0 || a.AdaptAfter < 0 {
+			return fmt.Errorf("anomaly detector settings must not be negative")
+		}
+	}
+
+	switch c.Scheduler.QueueBackend {
+	case "", queue.BackendMemory:
+	case queue.BackendRedis, queue.BackendNATS:
+		if c.Scheduler.Queue.URL == "" {
+			return fmt.Errorf("%s queue backend needs scheduler.queue.url", c.Scheduler.QueueBackend)
+		}
+	default:
+		return fmt.Errorf("unknown scheduler queue backend %q", c.Scheduler.QueueBackend)
+	}
+
+	if cn := c.Scheduler.ContextNegotiation; cn.MinContext < 0 || cn.KVBytesPerToken < 0 {
+		return fmt.Errorf("scheduler context_negotiation settings must not be negative")
+	}
+
+	if fq := c.Scheduler.FlapQuarantine; fq.Window < 0 || fq.Threshold < 0 || fq.Duration < 0 {
+		return fmt.Errorf("scheduler flap_quarantine settings must not be negative")
+	}
+
+	if err := c.Scheduler.Partitioning.Strategies.Validate(); err != nil {
+		return fmt.Errorf("invalid partition strategy settings: %w", err)
+	}
+
+	if c.Federation.Enabled {
+		if c.Federation.ClusterID == "" {
+			return fmt.Errorf("federation enabled but cluster_id not specified")
+		}
+		for _, peer := range c.Federation.Peers {
+			if peer.Name == "" || peer.URL == "" || peer.Token == "" {
+				return fmt.Errorf("federation peers need a name, url and token")
+			}
+		}
+	}
+
+	if c.GeoRouting.Enabled && !c.Federation.Enabled {
+		return fmt.Errorf("geo routing needs federation enabled")
+	}
+
+	if c.Tenancy.Enabled {
+		if _, err := tenancy.NewRegistry(c.Tenancy); err != nil {
+			return fmt.Errorf("invalid tenancy: %w", err)
+		}
+	}
+
+	if c.Capture.Enabled {
+		if err := c.Capture.Validate(); err != nil {
+			return fmt.Errorf("invalid capture: %w", err)
+		}
+	}
+
+	if c.Idempotency.Enabled {
+		if err := c.Idempotency.Validate(); err != nil {
+			return fmt.Errorf("invalid idempotency: %w", err)
+		}
+	}
+
+	if c.Verification.Enabled && c.Verification.Suite != "" {
+		if _, err := verification.LoadSuite(c.Verification.Suite); err != nil {
+			return err
+		}
+	}
+
+	if err := c.TimeSync.Validate(); err != nil {
+		return fmt.Errorf("invalid time sync: %w", err)
+	}
+
+	if c.Licensing.Enabled {
+		if err := c.Licensing.Validate(); err != nil {
+			return fmt.Errorf("invalid licensing: %w", err)
+		}
+	}
+
+	if c.VectorIndex.Enabled {
+		if err := c.VectorIndex.Validate(); err != nil {
+			return fmt.Errorf("invalid vector index: %w", err)
+		}
+	}
+
+	if c.RAG.Enabled {
+		if !c.VectorIndex.Enabled {
+			return fmt.Errorf("RAG needs the vector index enabled")
+		}
+		if err := c.RAG.Validate(); err != nil {
+			return fmt.Errorf("invalid RAG: %w", err)
+		}
+	}
+
+	if c.Ingestion.Enabled {
+		if !c.VectorIndex.Enabled {
+			return fmt.Errorf("ingestion needs the vector index enabled")
+		}
+		if err := c.Ingestion.Validate(); err != nil {
+			return fmt.Errorf("invalid ingestion: %w", err)
+		}
+	}
+
+	if c.GRPC.Enabled {
+		if err := c.GRPC.Validate(); err != nil {
+			return fmt.Errorf("invalid gRPC API: %w", err)
+		}
+	}
+
+	if c.ModelScan.Enabled {
+		if err := c.ModelScan.Validate(); err != nil {
+			return fmt.Errorf("invalid model scanning: %w", err)
+		}
+	}
+
+	if c.ShardFetch.Enabled {
+		if err := c.ShardFetch.Validate(); err != nil {
+			return fmt.Errorf("invalid shard fetching: %w", err)
+		}
+	}
+
+	if c.S3Gateway.Enabled {
+		if err := c.S3Gateway.Validate(); err != nil {
+			return fmt.Errorf("invalid S3 gateway: %w", err)
+		}
+	}
+
+	if c.Redaction.Enabled {
+		if err := c.Redaction.Validate(); err != nil {
+			return fmt.Errorf("invalid redaction: %w", err)
+		}
+	}
+	if c.Transforms.Enabled {
+		if err := c.Transforms.Validate(); err != nil {
+			return fmt.Errorf("invalid transforms: %w", err)
+		}
+	}
+	if c.Moderation.Enabled {
+		if err := c.Moderation.Validate(); err != nil {
+			return fmt.Errorf("invalid moderation: %w", err)
+		}
+	}
+
+	if c.Events.Enabled {
+		if err := c.Events.Validate(); err != nil {
+			return fmt.Errorf("invalid events: %w", err)
+		}
+	}
+
+	if c.Maintenance.Enabled {
+		if err := c.Maintenance.Validate(); err != nil {
+			return fmt.Errorf("invalid maintenance windows: %w", err)
+		}
+	}
+
+	if c.Features.Enabled {
+		if err := c.Features.Validate(); err != nil {
+			return fmt.Errorf("invalid feature flags: %w", err)
+		}
+	}
+
+	if c.Warmup.Enabled {
+		if err := c.Warmup.Validate(); err != nil {
+			return fmt.Errorf("invalid warm-up: %w", err)
+		}
+	}
+
+	if c.Speculative.Enabled {
+		if err := c.Speculative.Validate(); err != nil {
+			return fmt.Errorf("invalid speculative decoding: %w", err)
+		}
+	}
+
+	if c.LoadQueue.Enabled {
+		if err := c.LoadQueue.Validate(); err != nil {
+			return fmt.Errorf("invalid load queue: %w", err)
+		}
+	}
+
+	if c.History.Enabled {
+		if err := c.History.Validate(); err != nil {
+			return fmt.Errorf("invalid metrics history: %w", err)
+		}
+	}
+	if c.Metrics.ModelUsage.Enabled && !c.History.Enabled {
+		return fmt.Errorf("model usage analytics require the metrics history to be enabled")
+	}
+
+	if c.Degradation.Enabled {
+		if err := c.Degradation.Validate(); err != nil {
+			return fmt.Errorf("invalid degradation mode: %w", err)
+		}
+	}
+
+	if c.Backpressure.Enabled {
+		if err := c.Backpressure.Validate(); err != nil {
+			return fmt.Errorf("invalid backpressure: %w", err)
+		}
+	}
+
+	if c.Hedging.Enabled {
+		if err := c.Hedging.Validate(); err != nil {
+			return fmt.Errorf("invalid hedging: %w", err)
+		}
+	}
+
+	if c.JoinTokens.Enabled {
+		if err := c.JoinTokens.Validate(); err != nil {
+			return fmt.Errorf("invalid join tokens: %w", err)
+		}
+		if c.Security.TLS.CAFile == "" {
+			return fmt.Errorf("join tokens require the cluster CA in security.tls.ca_file")
+		}
+	}
+
+	if c.FailureDetector.Enabled {
+		if err := c.FailureDetector.Validate(); err != nil {
+			return fmt.Errorf("invalid failure detector: %w", err)
+		}
+	}
+
+	if c.Topology.Enabled {
+		if err := c.Topology.Validate(); err != nil {
+			return fmt.Errorf("invalid topology: %w", err)
+		}
+	}
+	if err := c.Compatibility.Validate(); err != nil {
+		return fmt.Errorf("invalid compatibility: %w", err)
+	}
+	if err := c.SelfUpdate.Validate(); err != nil {
+		return fmt.Errorf("invalid self-update: %w", err)
+	}
+
+	if c.Users.Enabled {
+		if err := c.Users.Validate(); err != nil {
+			return fmt.Errorf("invalid users: %w", err)
+		}
+	}
+
+	if c.Web.Sessions.Enabled {
+		if err := c.Web.Sessions.Validate(); err != nil {
+			return fmt.Errorf("invalid web sessions: %w", err)
+		}
+	}
+
+	if c.FaultTolerance.Enabled {
+		if _, err := fault_tolerance.ParseBlastRadius(c.FaultTolerance.RecoveryApproval); err != nil {
+			return fmt.Errorf("invalid fault tolerance recovery approval: %w", err)
+		}
+	}
+
+	if c.FaultInjection.Enabled {
+		if err := c.FaultInjection.Validate(); err != nil {
+			return fmt.Errorf("invalid fault injection: %w", err)
+		}
+		if !c.FaultInjection.Allowed(c.Node.Environment) {
+			return fmt.Errorf("fault injection is not allowed in environment %q", c.Node.Environment)
+		}
+	}
+
+	if c.P2P.QoS.Enabled {
+		if err := c.P2P.QoS.Validate(); err != nil {
+			return fmt.Errorf("invalid P2P QoS: %w", err)
+		}
+	}
+	if c.P2P.Reputation.Enabled {
+		if err := c.P2P.Reputation.Validate(); err != nil {
+			return fmt.Errorf("invalid peer reputation: %w", err)
+		}
+	}
+
+	if err := c.Placement.Validate(); err != nil {
+		return fmt.Errorf("invalid placement constraints: %w", err)
+	}
+	if c.DiskPlacement.Enabled {
+		if err := c.DiskPlacement.Validate(); err != nil {
+			return fmt.Errorf("invalid disk placement: %w", err)
+		}
+	}
+	if err := (placement.NodeLabels{Labels: c.Node.Labels, Taints: c.Node.Taints}).Validate(); err != nil {
+		return fmt.Errorf("invalid node labels: %w", err)
+	}
+
 	return nil
 }
 
@@ -573,3 +1660,28 @@ func (c *Config) Save(filename string) error {
 	viper.Set("config", c)
 	return viper.WriteConfigAs(filename)
 }
+
+// AllListenAddresses returns Listen followed by ListenAddresses with empty
+// entries and duplicates removed
+func (c *APIConfig) AllListenAddresses() []string {
+	return mergeAddresses(c.Listen, c.ListenAddresses)
+}
+
+// AllListenAddresses returns Listen followed by ListenAddresses with empty
+// entries and duplicates removed
+func (c *P2PConfig) AllListenAddresses() []string {
+	return mergeAddresses(c.Listen, c.ListenAddresses)
+}
+
+func mergeAddresses(primary string, extra []string) []string {
+	seen := make(map[string]bool, len(extra)+1)
+	addrs := make([]string, 0, len(extra)+1)
+	for _, addr := range append([]string{primary}, extra...) {
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
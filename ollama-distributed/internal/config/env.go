@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of every setting. The rest of
+// the name is the setting's YAML path in upper case joined by underscores, so
+// api.tls.cert_file is OLLAMA_DISTRIBUTED_API_TLS_CERT_FILE.
+const EnvPrefix = "OLLAMA_DISTRIBUTED"
+
+// EnvOnlyVar, when true, stops Load from looking for a config file, so the
+// configuration comes from defaults and environment variables alone
+const EnvOnlyVar = EnvPrefix + "_ENV_ONLY"
+
+// EnvVar describes the environment variable of one setting
+type EnvVar struct {
+	Name    string // e.g. OLLAMA_DISTRIBUTED_API_LISTEN
+	Key     string // YAML path, e.g. api.listen
+	Type    string // Go type of the setting
+	Default string // default value in environment variable syntax
+}
+
+// EnvVars lists the environment variable of every setting with its default,
+// in the order the settings appear in Config
+func EnvVars() []EnvVar {
+	var vars []EnvVar
+	walkEnv(reflect.ValueOf(DefaultConfig()).Elem(), nil, func(path []string, v reflect.Value) {
+		vars = append(vars, EnvVar{
+			Name:    envName(path),
+			Key:     strings.Join(path, "."),
+			Type:    v.Type().String(),
+			Default: formatEnvValue(v),
+		})
+	})
+	return vars
+}
+
+// envOnly reports whether EnvOnlyVar is set to true
+func envOnly() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvOnlyVar))
+	return enabled
+}
+
+// ApplyEnv overrides the settings whose environment variable is set.
+// Scalars use their usual text form: durations like "30s", lists separated
+// by commas and maps as "key=value,key=value". Lists of objects, and lists
+// or maps starting with "[" or "{", are parsed as YAML.
+func (c *Config) ApplyEnv() error {
+	return applyEnv(reflect.ValueOf(c).Elem(), nil, os.LookupEnv)
+}
+
+func applyEnv(root reflect.Value, prefix []string, lookup func(string) (string, bool)) error {
+	var errs []string
+	walkEnv(root, prefix, func(path []string, v reflect.Value) {
+		name := envName(path)
+		value, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if err := setEnvValue(v, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// walkEnv calls fn for every setting under v. Nil struct pointers are
+// allocated so that their settings can be listed and set; they are reset to
+// nil afterwards if none of them changed.
+func walkEnv(v reflect.Value, path []string, fn func(path []string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := yamlName(field)
+		if name == "-" {
+			continue
+		}
+		fieldPath := path
+		if !inline {
+			fieldPath = append(append([]string(nil), path...), name)
+		}
+
+		fv := v.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}):
+			walkEnv(fv, fieldPath, fn)
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			if !fv.IsNil() {
+				walkEnv(fv.Elem(), fieldPath, fn)
+				continue
+			}
+			fresh := reflect.New(fv.Type().Elem())
+			walkEnv(fresh.Elem(), fieldPath, fn)
+			if !fresh.Elem().IsZero() {
+				fv.Set(fresh)
+			}
+		default:
+			fn(fieldPath, fv)
+		}
+	}
+}
+
+// yamlName returns a field's YAML key and whether it is inlined
+func yamlName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+func envName(path []string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.Join(path, "_"))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setEnvValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String || strings.HasPrefix(value, "[") {
+			return setYAMLValue(v, value)
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	case reflect.Map:
+		if v.Type() != reflect.TypeOf(map[string]string(nil)) || strings.HasPrefix(value, "{") {
+			return setYAMLValue(v, value)
+		}
+		m := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", pair)
+			}
+			m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return setYAMLValue(v, value)
+	}
+	return nil
+}
+
+func setYAMLValue(v reflect.Value, value string) error {
+	target := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
+
+func formatEnvValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		if v.Int() == 0 {
+			return "0s"
+		}
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return ""
+		}
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	if m, ok := v.Interface().(map[string]string); ok {
+		pairs := make([]string, 0, len(m))
+		for key, val := range m {
+			pairs = append(pairs, key+"="+val)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+
+	var node yaml.Node
+	if err := node.Encode(v.Interface()); err != nil {
+		return ""
+	}
+	node.Style = yaml.FlowStyle
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
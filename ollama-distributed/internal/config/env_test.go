@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvSetsEveryKind(t *testing.T) {
+	env := map[string]string{
+		"OLLAMA_DISTRIBUTED_API_LISTEN":                   "0.0.0.0:9000",
+		"OLLAMA_DISTRIBUTED_API_TLS_ENABLED":              "true",
+		"OLLAMA_DISTRIBUTED_API_TIMEOUT":                  "45s",
+		"OLLAMA_DISTRIBUTED_API_MAX_BODY_SIZE":            "1048576",
+		"OLLAMA_DISTRIBUTED_API_ACCESS_LOG_SAMPLE_RATE":   "0.25",
+		"OLLAMA_DISTRIBUTED_P2P_BOOTSTRAP":                "/dns4/a/tcp/4001, /dns4/b/tcp/4001",
+		"OLLAMA_DISTRIBUTED_NODE_LABELS":                  "gpu=a100,zone=a",
+		"OLLAMA_DISTRIBUTED_KUBERNETES_READY_MAX_LAG":     "10",
+		"OLLAMA_DISTRIBUTED_OLLAMA_INSTANCES":             `[{name: gpu0, port: 11435, gpus: ["0"]}]`,
+		"OLLAMA_DISTRIBUTED_DISTRIBUTED_STORAGE_DATA_DIR": "/data",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := DefaultConfig()
+	require.NoError(t, applyEnv(reflect.ValueOf(cfg).Elem(), nil, lookup))
+
+	assert.Equal(t, "0.0.0.0:9000", cfg.API.Listen)
+	assert.True(t, cfg.API.TLS.Enabled)
+	assert.Equal(t, 45*time.Second, cfg.API.Timeout)
+	assert.Equal(t, int64(1048576), cfg.API.MaxBodySize)
+	assert.Equal(t, 0.25, cfg.API.AccessLog.SampleRate)
+	assert.Equal(t, []string{"/dns4/a/tcp/4001", "/dns4/b/tcp/4001"}, cfg.P2P.Bootstrap)
+	assert.Equal(t, map[string]string{"gpu": "a100", "zone": "a"}, cfg.Node.Labels)
+	assert.Equal(t, uint64(10), cfg.Kubernetes.ReadyMaxLag)
+	require.Len(t, cfg.Ollama.Instances, 1)
+	assert.Equal(t, OllamaInstanceConfig{Name: "gpu0", Port: 11435, GPUs: []string{"0"}}, cfg.Ollama.Instances[0])
+	require.NotNil(t, cfg.Distributed.Storage)
+	assert.Equal(t, "/data", cfg.Distributed.Storage.DataDir)
+}
+
+func TestApplyEnvReportsInvalidValues(t *testing.T) {
+	env := map[string]string{
+		"OLLAMA_DISTRIBUTED_API_TIMEOUT":     "soon",
+		"OLLAMA_DISTRIBUTED_API_TLS_ENABLED": "maybe",
+	}
+	err := applyEnv(reflect.ValueOf(DefaultConfig()).Elem(), nil, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OLLAMA_DISTRIBUTED_API_TIMEOUT")
+	assert.Contains(t, err.Error(), "OLLAMA_DISTRIBUTED_API_TLS_ENABLED")
+}
+
+func TestEnvVarsListsDefaults(t *testing.T) {
+	vars := make(map[string]EnvVar)
+	for _, v := range EnvVars() {
+		vars[v.Name] = v
+	}
+
+	listen := vars["OLLAMA_DISTRIBUTED_API_LISTEN"]
+	assert.Equal(t, "api.listen", listen.Key)
+	assert.Equal(t, DefaultConfig().API.Listen, listen.Default)
+	assert.Equal(t, "time.Duration", vars["OLLAMA_DISTRIBUTED_KUBERNETES_DRAIN_TIMEOUT"].Type)
+	assert.Equal(t, "25s", vars["OLLAMA_DISTRIBUTED_KUBERNETES_DRAIN_TIMEOUT"].Default)
+	assert.Contains(t, vars, "OLLAMA_DISTRIBUTED_DISTRIBUTED_SYNC_WORKER_COUNT")
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/s3gateway"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactedHidesSecrets(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.Auth.SecretKey = "a-very-long-jwt-signing-secret-value"
+	cfg.Security.Auth.OIDC.ClientSecret = "vault:secret/data/ollama#oidc"
+	cfg.Security.Secrets.Vault.Token = ""
+	cfg.Logging.Ship.Username = "shipper"
+	cfg.Logging.Ship.Password = "hunter2"
+	cfg.Scheduler.Queue.URL = "redis://:queuepass@redis:6379/0"
+	cfg.Capture.APIKeys = []string{"key-1", "key-2"}
+	cfg.S3Gateway.Credentials = []s3gateway.Credential{{AccessKey: "AKIA", SecretKey: "s3secret"}}
+
+	tree, err := cfg.Redacted()
+	require.NoError(t, err)
+	data, err := json.Marshal(tree)
+	require.NoError(t, err, "the tree must encode as JSON")
+	for _, secret := range []string{"a-very-long-jwt-signing-secret-value", "hunter2", "queuepass", "key-1", "AKIA", "s3secret"} {
+		assert.NotContains(t, string(data), secret)
+	}
+
+	get := func(path ...string) interface{} {
+		var value interface{} = tree
+		for _, key := range path {
+			value = value.(map[string]interface{})[key]
+		}
+		return value
+	}
+	assert.Equal(t, redactedValue, get("security", "auth", "secret_key"))
+	assert.Equal(t, "vault:secret/data/ollama#oidc", get("security", "auth", "oidc", "client_secret"), "secret references are kept")
+	assert.Equal(t, "", get("security", "secrets", "vault", "token"), "unset secrets are kept")
+	assert.Equal(t, "shipper", get("logging", "ship", "username"))
+	assert.Equal(t, "redis://:xxxxx@redis:6379/0", get("scheduler", "queue", "url"))
+	assert.Equal(t, []interface{}{redactedValue, redactedValue}, get("capture", "api_keys"))
+	assert.NotNil(t, get("security", "secrets", "cache_ttl"), "sections named after secrets are walked, not hidden")
+	assert.NotNil(t, get("users", "min_password_length"))
+}
+
+func TestRedactedHidesEverySecretField(t *testing.T) {
+	const secret = "s3cr3t-v4lue"
+	tests := []struct {
+		field string
+		set   func(cfg *Config)
+	}{
+		{"p2p.private_key", func(cfg *Config) { cfg.P2P.PrivateKey = secret }},
+		{"security.auth.secret_key", func(cfg *Config) { cfg.Security.Auth.SecretKey = secret }},
+		{"security.auth.oidc.client_secret", func(cfg *Config) { cfg.Security.Auth.OIDC.ClientSecret = secret }},
+		{"security.secrets.vault.token", func(cfg *Config) { cfg.Security.Secrets.Vault.Token = secret }},
+		{"kubernetes.prestop_token", func(cfg *Config) { cfg.Kubernetes.PreStopToken = secret }},
+		{"logging.ship.password", func(cfg *Config) { cfg.Logging.Ship.Password = secret }},
+		{"scheduler.queue.url", func(cfg *Config) { cfg.Scheduler.Queue.URL = "redis://:" + secret + "@redis:6379/0" }},
+		{"web.sessions.users.password_hash", func(cfg *Config) {
+			cfg.Web.Sessions.Users = []session.User{{Username: "admin", PasswordHash: secret}}
+		}},
+		{"users.database.password", func(cfg *Config) { cfg.Users.Database.Password = secret }},
+		{"history.database.password", func(cfg *Config) { cfg.History.Database.Password = secret }},
+		{"events.database.password", func(cfg *Config) { cfg.Events.Database.Password = secret }},
+		{"capture.database.password", func(cfg *Config) { cfg.Capture.Database.Password = secret }},
+		{"vector_index.database.password", func(cfg *Config) { cfg.VectorIndex.Database.Password = secret }},
+		{"capture.api_keys", func(cfg *Config) { cfg.Capture.APIKeys = []string{secret} }},
+		{"moderation.bypass_api_keys", func(cfg *Config) { cfg.Moderation.BypassAPIKeys = []string{secret} }},
+		{"tenancy.tenants.api_keys", func(cfg *Config) {
+			cfg.Tenancy.Tenants = []tenancy.Tenant{{ID: "acme", APIKeys: []string{secret}}}
+		}},
+		{"tenancy.tenants.api_key_limits.api_key", func(cfg *Config) {
+			cfg.Tenancy.Tenants = []tenancy.Tenant{{ID: "acme", APIKeyLimits: []tenancy.APIKeyLimits{{APIKey: secret}}}}
+		}},
+		{"federation.peers.token", func(cfg *Config) {
+			cfg.Federation.Peers = []federation.PeerConfig{{Name: "eu", URL: "https://eu.example.com", Token: secret}}
+		}},
+		{"redaction.hash_key", func(cfg *Config) { cfg.Redaction.HashKey = secret }},
+		{"autoscaling.aws.access_key_id", func(cfg *Config) { cfg.Autoscaling.AWS.AccessKeyID = secret }},
+		{"autoscaling.aws.secret_access_key", func(cfg *Config) { cfg.Autoscaling.AWS.SecretAccessKey = secret }},
+		{"autoscaling.aws.session_token", func(cfg *Config) { cfg.Autoscaling.AWS.SessionToken = secret }},
+		{"autoscaling.gcp.access_token", func(cfg *Config) { cfg.Autoscaling.GCP.AccessToken = secret }},
+		{"autoscaling.webhook.token", func(cfg *Config) { cfg.Autoscaling.Webhook.Token = secret }},
+		{"s3_gateway.credentials.access_key", func(cfg *Config) {
+			cfg.S3Gateway.Credentials = []s3gateway.Credential{{AccessKey: secret}}
+		}},
+		{"s3_gateway.credentials.secret_key", func(cfg *Config) {
+			cfg.S3Gateway.Credentials = []s3gateway.Credential{{SecretKey: secret}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.set(cfg)
+			tree, err := cfg.Redacted()
+			require.NoError(t, err)
+			data, err := json.Marshal(tree)
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), secret)
+		})
+	}
+
+	assert.True(t, isSecretKey("secrets"), "lists of secrets are hidden")
+	assert.True(t, isSecretKey("webhook_secrets"))
+	assert.False(t, isSecretKey("token_file"))
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ValidationError represents a configuration validation error
@@ -88,6 +89,33 @@ func (c *Config) ValidateExtended() error {
 		}
 	}
 
+	// Validate Ollama process configuration
+	if err := c.validateOllama(); err != nil {
+		if ve, ok := err.(ValidationErrors); ok {
+			errors = append(errors, ve...)
+		} else {
+			errors = append(errors, ValidationError{Field: "ollama", Message: err.Error()})
+		}
+	}
+
+	// Validate log buffering and shipping
+	if err := c.validateLogging(); err != nil {
+		if ve, ok := err.(ValidationErrors); ok {
+			errors = append(errors, ve...)
+		} else {
+			errors = append(errors, ValidationError{Field: "logging", Message: err.Error()})
+		}
+	}
+
+	// Validate service level objectives
+	if err := c.validateSLO(); err != nil {
+		if ve, ok := err.(ValidationErrors); ok {
+			errors = append(errors, ve...)
+		} else {
+			errors = append(errors, ValidationError{Field: "metrics.slo", Message: err.Error()})
+		}
+	}
+
 	if len(errors) > 0 {
 		return errors
 	}
@@ -149,6 +177,16 @@ func (c *Config) validateAPI() error {
 		})
 	}
 
+	for i, addr := range c.API.ListenAddresses {
+		if !isValidListenAddress(addr) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("api.listen_addresses[%d]", i),
+				Value:   addr,
+				Message: "invalid listen address format",
+			})
+		}
+	}
+
 	// Validate timeout
 	if c.API.Timeout <= 0 {
 		errors = append(errors, ValidationError{
@@ -167,6 +205,42 @@ func (c *Config) validateAPI() error {
 		})
 	}
 
+	timeouts := []struct {
+		field string
+		value time.Duration
+	}{
+		{"api.read_timeout", c.API.ReadTimeout},
+		{"api.read_header_timeout", c.API.ReadHeaderTimeout},
+		{"api.write_timeout", c.API.WriteTimeout},
+		{"api.idle_timeout", c.API.IdleTimeout},
+		{"api.body_read_timeout", c.API.BodyReadTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 {
+			errors = append(errors, ValidationError{
+				Field:   t.field,
+				Value:   t.value,
+				Message: "timeout must not be negative",
+			})
+		}
+	}
+
+	if c.API.MaxConnections < 0 || c.API.MaxConnsPerClient < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "api.max_connections",
+			Value:   fmt.Sprintf("%d/%d", c.API.MaxConnections, c.API.MaxConnsPerClient),
+			Message: "connection limits must not be negative",
+		})
+	}
+
+	if c.API.Debug.Enabled && c.API.Debug.DumpDir == "" {
+		errors = append(errors, ValidationError{
+			Field:   "api.debug.dump_dir",
+			Value:   c.API.Debug.DumpDir,
+			Message: "dump directory is required when debug endpoints are enabled",
+		})
+	}
+
 	// Validate TLS configuration
 	if c.API.TLS.Enabled {
 		if c.API.TLS.CertFile == "" {
@@ -215,7 +289,7 @@ func (c *Config) validateP2P() error {
 			Value:   c.P2P.Listen,
 			Message: "listen address is required",
 		})
-	} else if !isValidListenAddress(c.P2P.Listen) {
+	} else if !isValidPeerAddress(c.P2P.Listen) {
 		errors = append(errors, ValidationError{
 			Field:   "p2p.listen",
 			Value:   c.P2P.Listen,
@@ -223,6 +297,26 @@ func (c *Config) validateP2P() error {
 		})
 	}
 
+	for i, addr := range c.P2P.ListenAddresses {
+		if !isValidPeerAddress(addr) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("p2p.listen_addresses[%d]", i),
+				Value:   addr,
+				Message: "invalid listen address format",
+			})
+		}
+	}
+
+	for i, addr := range c.P2P.AnnounceAddresses {
+		if !strings.HasPrefix(addr, "/") {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("p2p.announce_addresses[%d]", i),
+				Value:   addr,
+				Message: "announce addresses must be multiaddrs",
+			})
+		}
+	}
+
 	// Validate bootstrap peers
 	for i, peer := range c.P2P.Bootstrap {
 		if !isValidPeerAddress(peer) {
@@ -282,6 +376,156 @@ func (c *Config) validateStorage() error {
 	return nil
 }
 
+// validateOllama validates the supervision and sandbox settings of the
+// spawned Ollama server
+func (c *Config) validateOllama() error {
+	var errors ValidationErrors
+	o := c.Ollama
+
+	if o.MaxRestarts < 0 {
+		errors = append(errors, ValidationError{Field: "ollama.max_restarts", Value: o.MaxRestarts, Message: "must not be negative"})
+	}
+	if o.RestartBackoff < 0 || o.MaxRestartBackoff < 0 || o.RestartWindow < 0 {
+		errors = append(errors, ValidationError{Field: "ollama.restart_backoff", Value: o.RestartBackoff, Message: "restart durations must not be negative"})
+	}
+	if o.MaxRestartBackoff > 0 && o.MaxRestartBackoff < o.RestartBackoff {
+		errors = append(errors, ValidationError{Field: "ollama.max_restart_backoff", Value: o.MaxRestartBackoff, Message: "must not be less than restart_backoff"})
+	}
+
+	switch o.Runtime {
+	case "", OllamaRuntimeExternal:
+	case OllamaRuntimeNative:
+		// The native runner is a single in-process server
+		if len(o.Instances) > 0 || o.InstancePerGPU {
+			errors = append(errors, ValidationError{Field: "ollama.instances", Value: len(o.Instances), Message: "multiple instances require the external runtime"})
+		}
+		if o.Native.ContextSize < 0 || o.Native.Threads < 0 || o.Native.GPULayers < 0 || o.Native.MaxTokens < 0 {
+			errors = append(errors, ValidationError{Field: "ollama.native", Value: o.Native, Message: "sizes must not be negative"})
+		}
+	default:
+		errors = append(errors, ValidationError{Field: "ollama.runtime", Value: o.Runtime, Message: "must be external or native"})
+	}
+
+	if o.BasePort < 0 || o.BasePort > 65535 {
+		errors = append(errors, ValidationError{Field: "ollama.base_port", Value: o.BasePort, Message: "must be a valid port"})
+	}
+	names := make(map[string]bool)
+	ports := make(map[int]bool)
+	for i, inst := range o.Instances {
+		field := fmt.Sprintf("ollama.instances[%d]", i)
+		if inst.Name == "" {
+			errors = append(errors, ValidationError{Field: field + ".name", Value: "", Message: "is required"})
+		} else if names[inst.Name] {
+			errors = append(errors, ValidationError{Field: field + ".name", Value: inst.Name, Message: "must be unique"})
+		}
+		if inst.Port <= 0 || inst.Port > 65535 {
+			errors = append(errors, ValidationError{Field: field + ".port", Value: inst.Port, Message: "must be a valid port"})
+		} else if ports[inst.Port] {
+			errors = append(errors, ValidationError{Field: field + ".port", Value: inst.Port, Message: "must be unique"})
+		}
+		names[inst.Name] = true
+		ports[inst.Port] = true
+	}
+
+	if o.Sandbox.Enabled {
+		if o.Sandbox.CPULimit < 0 {
+			errors = append(errors, ValidationError{Field: "ollama.sandbox.cpu_limit", Value: o.Sandbox.CPULimit, Message: "must not be negative"})
+		}
+		if o.Sandbox.MemoryLimit < 0 {
+			errors = append(errors, ValidationError{Field: "ollama.sandbox.memory_limit", Value: o.Sandbox.MemoryLimit, Message: "must not be negative"})
+		}
+		if o.Sandbox.PidsLimit < 0 {
+			errors = append(errors, ValidationError{Field: "ollama.sandbox.pids_limit", Value: o.Sandbox.PidsLimit, Message: "must not be negative"})
+		}
+		if o.Sandbox.CgroupParent == "" && (o.Sandbox.CPULimit > 0 || o.Sandbox.MemoryLimit > 0 || o.Sandbox.PidsLimit > 0) {
+			errors = append(errors, ValidationError{Field: "ollama.sandbox.cgroup_parent", Value: "", Message: "required when resource limits are set"})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// validateLogging validates log buffering and shipping
+func (c *Config) validateLogging() error {
+	var errors ValidationErrors
+	l := c.Logging
+
+	if l.BufferSize < 0 {
+		errors = append(errors, ValidationError{Field: "logging.buffer_size", Value: l.BufferSize, Message: "must not be negative"})
+	}
+	if l.Ship.Enabled {
+		switch l.Ship.Type {
+		case "loki", "elasticsearch":
+		default:
+			errors = append(errors, ValidationError{Field: "logging.ship.type", Value: l.Ship.Type, Message: "must be loki or elasticsearch"})
+		}
+		if l.Ship.URL == "" {
+			errors = append(errors, ValidationError{Field: "logging.ship.url", Value: "", Message: "is required"})
+		}
+		if l.Ship.BatchSize < 0 || l.Ship.FlushInterval < 0 {
+			errors = append(errors, ValidationError{Field: "logging.ship.batch_size", Value: l.Ship.BatchSize, Message: "batch size and flush interval must not be negative"})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// validateSLO validates service level objectives
+func (c *Config) validateSLO() error {
+	var errors ValidationErrors
+	slo := c.Metrics.SLO
+
+	if slo.EvaluationInterval < 0 {
+		errors = append(errors, ValidationError{Field: "metrics.slo.evaluation_interval", Value: slo.EvaluationInterval, Message: "must not be negative"})
+	}
+	if slo.FastBurnRate < 0 {
+		errors = append(errors, ValidationError{Field: "metrics.slo.fast_burn_rate", Value: slo.FastBurnRate, Message: "must not be negative"})
+	}
+
+	names := make(map[string]bool)
+	for i, objective := range slo.Objectives {
+		field := fmt.Sprintf("metrics.slo.objectives[%d]", i)
+		if objective.Name == "" {
+			errors = append(errors, ValidationError{Field: field + ".name", Value: "", Message: "is required"})
+		} else if names[objective.Name] {
+			errors = append(errors, ValidationError{Field: field + ".name", Value: objective.Name, Message: "must be unique"})
+		}
+		names[objective.Name] = true
+
+		switch objective.Indicator {
+		case "availability":
+		case "latency", "ttft":
+			if objective.Threshold <= 0 {
+				errors = append(errors, ValidationError{Field: field + ".threshold", Value: objective.Threshold, Message: "must be positive for latency objectives"})
+			}
+		default:
+			errors = append(errors, ValidationError{Field: field + ".indicator", Value: objective.Indicator, Message: "must be availability, latency or ttft"})
+		}
+		switch objective.RequestType {
+		case "", "generate", "chat", "embed":
+		default:
+			errors = append(errors, ValidationError{Field: field + ".request_type", Value: objective.RequestType, Message: "must be generate, chat or embed"})
+		}
+		if objective.Target <= 0 || objective.Target >= 1 {
+			errors = append(errors, ValidationError{Field: field + ".target", Value: objective.Target, Message: "must be between 0 and 1 exclusive"})
+		}
+		if objective.Window < 0 {
+			errors = append(errors, ValidationError{Field: field + ".window", Value: objective.Window, Message: "must not be negative"})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
 // validateSecurity validates security configuration
 func (c *Config) validateSecurity() error {
 	var errors ValidationErrors
@@ -294,7 +538,7 @@ func (c *Config) validateSecurity() error {
 				Value:   c.Security.Auth.SecretKey,
 				Message: "secret key is required when authentication is enabled",
 			})
-		} else if len(c.Security.Auth.SecretKey) < 32 {
+		} else if len(c.Security.Auth.SecretKey) < 32 && !isSecretReference(c.Security.Auth.SecretKey) {
 			errors = append(errors, ValidationError{
 				Field:   "security.auth.secret_key",
 				Value:   "[REDACTED]",
@@ -302,7 +546,7 @@ func (c *Config) validateSecurity() error {
 			})
 		}
 
-		validMethods := []string{"jwt", "api_key", "oauth"}
+		validMethods := []string{"jwt", "api_key", "oauth", "oidc"}
 		if !contains(validMethods, c.Security.Auth.Method) {
 			errors = append(errors, ValidationError{
 				Field:   "security.auth.method",
@@ -318,6 +562,31 @@ func (c *Config) validateSecurity() error {
 				Message: "token expiry must be positive",
 			})
 		}
+
+		if c.Security.Auth.Method == "oidc" {
+			oidc := c.Security.Auth.OIDC
+			if !strings.HasPrefix(oidc.IssuerURL, "https://") && !strings.HasPrefix(oidc.IssuerURL, "http://localhost") {
+				errors = append(errors, ValidationError{
+					Field:   "security.auth.oidc.issuer_url",
+					Value:   oidc.IssuerURL,
+					Message: "issuer URL must be an https URL",
+				})
+			}
+			if oidc.ClientID == "" {
+				errors = append(errors, ValidationError{
+					Field:   "security.auth.oidc.client_id",
+					Value:   oidc.ClientID,
+					Message: "client ID is required for OIDC",
+				})
+			}
+			if oidc.RedirectURL == "" {
+				errors = append(errors, ValidationError{
+					Field:   "security.auth.oidc.redirect_url",
+					Value:   oidc.RedirectURL,
+					Message: "redirect URL is required for the dashboard login flow",
+				})
+			}
+		}
 	}
 
 	if len(errors) > 0 {
@@ -385,3 +654,14 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// isSecretReference reports whether value points into a secret store and is
+// only resolved at startup, see pkg/secrets
+func isSecretReference(value string) bool {
+	for _, prefix := range []string{"vault:", "aws-sm:", "file:"} {
+		if strings.HasPrefix(value, prefix) && len(value) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+)
+
+// SetAnomalyDetector exposes the anomalies found by detector on
+// /api/v1/anomalies
+func (s *Server) SetAnomalyDetector(detector *observability.AnomalyDetector) {
+	s.anomalies = detector
+}
+
+// getAnomalies returns the latency and error anomalies going on since a
+// time, 24h ago by default, and the baselines they are judged against
+func (s *Server) getAnomalies(c *gin.Context) {
+	if s.anomalies == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "anomaly detection not enabled"})
+		return
+	}
+
+	since, err := parseEventTime(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since: %v", err)})
+		return
+	}
+	if since.IsZero() {
+		since = time.Now().Add(-24 * time.Hour)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"anomalies": s.anomalies.Anomalies(since),
+		"baselines": s.anomalies.Baselines(),
+	})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAnomalies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/anomalies", s.getAnomalies)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do("/api/v1/anomalies").Code)
+
+	detector := observability.NewAnomalyDetector(&observability.AnomalyConfig{MinSamples: 1}, nil)
+	detector.Record(observability.InferenceObservation{ModelName: "llama3", NodeID: "node-a", Duration: time.Second})
+	detector.Evaluate()
+	s.SetAnomalyDetector(detector)
+
+	rec := do("/api/v1/anomalies?since=1h")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Anomalies []observability.Anomaly         `json:"anomalies"`
+		Baselines []observability.AnomalyBaseline `json:"baselines"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Empty(t, body.Anomalies)
+	require.Len(t, body.Baselines, 1)
+	assert.Equal(t, "llama3", body.Baselines[0].Model)
+	assert.True(t, body.Baselines[0].Learning)
+
+	assert.Equal(t, http.StatusBadRequest, do("/api/v1/anomalies?since=soon").Code)
+}
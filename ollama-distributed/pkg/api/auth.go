@@ -1,13 +1,17 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
 )
 
 // JWTClaims represents JWT token claims
@@ -15,6 +19,7 @@ type JWTClaims struct {
 	UserID   string   `json:"user_id"`
 	Username string   `json:"username"`
 	Roles    []string `json:"roles"`
+	Tenant   string   `json:"tenant,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -35,27 +40,48 @@ func (s *Server) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token := extractToken(c)
-		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization token"})
+		if !s.authenticate(c) {
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, err := s.validateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+// authenticate checks the session, API key or token of a request and sets
+// the caller in the context, answering 401 when it is missing or invalid
+func (s *Server) authenticate(c *gin.Context) bool {
+	if s.authenticateSession(c) {
+		return true
+	}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("roles", claims.Roles)
+	token := extractToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization token"})
+		return false
+	}
 
-		c.Next()
+	if s.authenticateAPIKey(c, token) {
+		return true
 	}
+
+	claims, err := s.validateToken(token)
+	if err != nil {
+		if s.authenticateOIDC(c, token) {
+			return true
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return false
+	}
+
+	// Set user information in context
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("roles", claims.Roles)
+	if claims.Tenant != "" {
+		c.Set("tenant", claims.Tenant)
+	}
+	return true
 }
 
 // RoleMiddleware checks if user has required role
@@ -95,53 +121,19 @@ func (s *Server) RoleMiddleware(requiredRole string) gin.HandlerFunc {
 
 // RateLimitMiddleware implements rate limiting
 func (s *Server) RateLimitMiddleware() gin.HandlerFunc {
-	// Simple in-memory rate limiter
-	clients := make(map[string][]time.Time)
-
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		now := time.Now()
-
-		// Clean old entries
-		if requests, exists := clients[clientIP]; exists {
-			var validRequests []time.Time
-			for _, reqTime := range requests {
-				if now.Sub(reqTime) < time.Minute {
-					validRequests = append(validRequests, reqTime)
-				}
-			}
-			clients[clientIP] = validRequests
-		}
-
-		// Check rate limit (100 requests per minute)
-		if len(clients[clientIP]) >= 100 {
+		if !s.limits.allowRate(c.ClientIP(), time.Now()) {
 			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
 			c.Abort()
 			return
 		}
-
-		// Add current request
-		clients[clientIP] = append(clients[clientIP], now)
 		c.Next()
 	}
 }
 
-// CORSMiddleware handles CORS headers
+// CORSMiddleware applies the configured CORS policy
 func (s *Server) CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	}
+	return CORS(s.config.Cors)
 }
 
 // SecurityHeadersMiddleware adds security headers
@@ -157,8 +149,20 @@ func (s *Server) SecurityHeadersMiddleware() gin.HandlerFunc {
 	}
 }
 
-// LoggingMiddleware logs requests
+// LoggingMiddleware logs requests. When access logging is enabled each
+// request is written as a structured slog record with sampling and prompt
+// redaction; otherwise a plain combined-style line is printed.
 func (s *Server) LoggingMiddleware() gin.HandlerFunc {
+	if s.config != nil && s.config.AccessLog.Enabled {
+		return logging.AccessLog(slog.Default(), logging.AccessLogOptions{
+			SampleRate:    s.config.AccessLog.SampleRate,
+			SampledRoutes: s.config.AccessLog.SampledRoutes,
+			SlowThreshold: s.config.AccessLog.SlowThreshold,
+			LogPrompts:    s.config.AccessLog.LogPrompts,
+			Redact:        s.redactPrompt,
+		})
+	}
+
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
 			param.ClientIP,
@@ -248,11 +252,17 @@ func isPublicEndpoint(path string) bool {
 		"/api/auth/register",
 		"/api/v1/health",
 		"/api/v1/version",
+		"/api/v1/cluster/ca",
+		"/api/v1/cluster/join",
 		"/metrics",
-		"/",
 		"/static/",
 	}
 
+	// The dashboard root must match exactly, or every path would be public
+	if path == "/" {
+		return true
+	}
+
 	for _, endpoint := range publicEndpoints {
 		if strings.HasPrefix(path, endpoint) {
 			return true
@@ -282,28 +292,32 @@ type UserInfo struct {
 	Roles    []string `json:"roles"`
 }
 
-// login handles user authentication
+// login authenticates a local account and returns a token for it
 func (s *Server) login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !s.usersEnabled(c) {
+		return
+	}
 
-	// TODO: Implement actual user authentication
-	// For now, accept any username/password for demo
-	if req.Username == "" || req.Password == "" {
+	roles, err := s.users.Authenticate(req.Username, req.Password)
+	if errors.Is(err, users.ErrInvalidCredentials) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
-
-	// Generate token
-	roles := []string{"user"}
-	if req.Username == "admin" {
-		roles = append(roles, "admin")
+	var user *users.User
+	if err == nil {
+		user, err = s.users.Get(c.Request.Context(), req.Username)
+	}
+	if err != nil {
+		writeUserError(c, err)
+		return
 	}
 
-	token, err := s.generateToken("user-123", req.Username, roles)
+	token, err := s.generateToken(user.ID, user.Username, roles)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -313,8 +327,8 @@ func (s *Server) login(c *gin.Context) {
 		Token:     token,
 		ExpiresAt: time.Now().Add(24 * time.Hour),
 		User: UserInfo{
-			ID:       "user-123",
-			Username: req.Username,
+			ID:       user.ID,
+			Username: user.Username,
 			Roles:    roles,
 		},
 	}
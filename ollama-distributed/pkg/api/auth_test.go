@@ -0,0 +1,16 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicEndpoint(t *testing.T) {
+	assert.True(t, isPublicEndpoint("/"))
+	assert.True(t, isPublicEndpoint("/static/app.js"))
+	assert.True(t, isPublicEndpoint("/api/v1/health"))
+	assert.False(t, isPublicEndpoint("/api/v1/nodes"), "the dashboard root does not make every path public")
+	assert.False(t, isPublicEndpoint("/debug/pprof/heap"))
+	assert.False(t, isPublicEndpoint("/api/v1/logs"))
+}
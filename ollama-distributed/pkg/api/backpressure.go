@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/backpressure"
+)
+
+// SetBackpressure bounds the data buffered for slow streaming clients and
+// exposes the slow client counts on /api/v1/streaming
+func (s *Server) SetBackpressure(monitor *backpressure.Monitor) {
+	s.backpressure = monitor
+}
+
+// BackpressureMiddleware sends the response of an inference route to the
+// client from a bounded buffer, pausing the generation or disconnecting
+// the client when it falls behind. It runs before the other middleware
+// wrapping the response, to sit closest to the client.
+func (s *Server) BackpressureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.backpressure == nil {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		writer := s.backpressure.Wrap(c.Writer, cancel)
+		c.Writer = writer
+
+		c.Next()
+
+		if err := writer.Close(); errors.Is(err, backpressure.ErrSlowClient) {
+			slog.Info("Disconnected slow streaming client", "path", c.Request.URL.Path, "client", c.ClientIP(), "error", err)
+		}
+	}
+}
+
+// getStreamingStats returns how streaming clients kept up
+func (s *Server) getStreamingStats(c *gin.Context) {
+	if s.backpressure == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backpressure not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"config": s.backpressure.Config(),
+		"stats":  s.backpressure.Stats(),
+	})
+}
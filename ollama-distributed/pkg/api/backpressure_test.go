@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/backpressure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackpressureStreamsThroughBuffer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.POST("/generate", s.BackpressureMiddleware(), func(c *gin.Context) {
+		c.Header("Content-Type", "application/x-ndjson")
+		for _, text := range []string{"a", "b", "c"} {
+			c.Writer.WriteString(`{"response":"` + text + `","done":false}` + "\n")
+			c.Writer.Flush()
+		}
+	})
+	router.GET("/streaming", s.getStreamingStats)
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, get(http.MethodGet, "/streaming").Code)
+
+	monitor, err := backpressure.NewMonitor(backpressure.Config{Enabled: true})
+	require.NoError(t, err)
+	s.SetBackpressure(monitor)
+	w := get(http.MethodPost, "/generate")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"response":"a","done":false}`+"\n"+`{"response":"b","done":false}`+"\n"+`{"response":"c","done":false}`+"\n", w.Body.String())
+
+	var body struct {
+		Stats backpressure.Stats `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal(get(http.MethodGet, "/streaming").Body.Bytes(), &body))
+	assert.Equal(t, int64(1), body.Stats.Streams)
+	assert.Zero(t, body.Stats.Active)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+)
+
+// SetCapture records the inference requests selected by recorder and
+// exposes the captures on /api/v1/captures
+func (s *Server) SetCapture(recorder *capture.Recorder) {
+	s.capture = recorder
+}
+
+// CaptureMiddleware captures inference requests once capture is enabled
+func (s *Server) CaptureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.capture == nil {
+			c.Next()
+			return
+		}
+		s.capture.Handle(c)
+	}
+}
+
+// getCaptures lists captured requests, newest first, optionally filtered by
+// tenant, model and age
+func (s *Server) getCaptures(c *gin.Context) {
+	if s.capture == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "capture not enabled"})
+		return
+	}
+
+	filter := capture.Filter{
+		Tenant: c.Query("tenant"),
+		Model:  c.Query("model"),
+		Limit:  100,
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = n
+	}
+	if since := c.Query("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since duration"})
+			return
+		}
+		filter.Since = time.Now().Add(-d)
+	}
+
+	records, err := s.capture.Store().List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if records == nil {
+		records = []*capture.Record{}
+	}
+	c.JSON(http.StatusOK, gin.H{"captures": records})
+}
+
+// getCapture returns a captured request with its response
+func (s *Server) getCapture(c *gin.Context) {
+	if s.capture == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "capture not enabled"})
+		return
+	}
+
+	rec, err := s.capture.Store().Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, capture.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "capture not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"capture": rec})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+
+	router := gin.New()
+	router.Use(s.CaptureMiddleware())
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"response": "hi"})
+	})
+	router.GET("/api/v1/captures", s.getCaptures)
+	router.GET("/api/v1/captures/:id", s.getCapture)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	generate := func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/generate", strings.NewReader(`{"model": "llama3"}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	generate()
+	assert.Equal(t, http.StatusServiceUnavailable, get("/api/v1/captures").Code)
+
+	recorder, err := capture.NewRecorder(capture.Config{Enabled: true, SampleRate: 1}, capture.NewMemoryStore(10))
+	require.NoError(t, err)
+	s.SetCapture(recorder)
+	generate()
+
+	rec := get("/api/v1/captures?model=llama3")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Captures []capture.Record `json:"captures"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Captures, 1, "requests before capture was enabled are not recorded")
+	assert.JSONEq(t, `{"response": "hi"}`, list.Captures[0].Response)
+
+	rec = get("/api/v1/captures/" + list.Captures[0].ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/v1/captures/missing").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/captures?limit=x").Code)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// ClusterState is what this node knows of the cluster: every node with
+// its resources, requests and models, and this node's scheduler queue
+type ClusterState struct {
+	NodeID string            `json:"node_id"`
+	Leader string            `json:"leader"`
+	Nodes  []*types.NodeInfo `json:"nodes"`
+	Models int               `json:"models"`
+	// QueuedRequests wait in this node's scheduler for a node
+	QueuedRequests int64     `json:"queued_requests"`
+	Time           time.Time `json:"time"`
+}
+
+// clusterState collects the state of the cluster
+func (s *Server) clusterState() *ClusterState {
+	state := &ClusterState{
+		Nodes:          s.scheduler.ListNodes(),
+		Models:         s.scheduler.GetModelCount(),
+		QueuedRequests: s.scheduler.GetStats().QueuedRequests,
+		Time:           time.Now(),
+	}
+	if s.p2p != nil {
+		state.NodeID = s.p2p.ID().String()
+	}
+	if s.consensus != nil {
+		state.Leader = s.consensus.Leader()
+	}
+	return state
+}
+
+// getClusterState returns every node with its resource usage, requests in
+// flight and waiting, and models loaded
+func (s *Server) getClusterState(c *gin.Context) {
+	c.JSON(http.StatusOK, s.clusterState())
+}
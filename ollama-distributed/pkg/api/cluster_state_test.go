@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	engine.AddTestNode(&scheduler.NodeInfo{
+		ID:             "node-b",
+		Status:         scheduler.NodeStatusOnline,
+		Usage:          scheduler.NodeUsage{CPU: 70, Memory: 40, GPU: 90},
+		Models:         []string{"llama3"},
+		ActiveRequests: 3,
+		QueuedRequests: 1,
+	})
+	engine.AddTestNode(&scheduler.NodeInfo{ID: "node-a", Status: scheduler.NodeStatusOffline})
+	require.NoError(t, engine.RegisterModel("llama3", 1<<30, "", "node-b"))
+
+	s := &Server{scheduler: engine}
+	router := gin.New()
+	router.GET("/api/v1/cluster/state", s.getClusterState)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/state", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var state ClusterState
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	require.Len(t, state.Nodes, 2)
+	assert.Equal(t, "node-a", state.Nodes[0].ID, "offline nodes are listed too")
+	node := state.Nodes[1]
+	assert.Equal(t, 90.0, node.Usage.GPU)
+	assert.Equal(t, 3, node.ActiveRequests)
+	assert.Equal(t, 1, node.QueuedRequests)
+	assert.Equal(t, []string{"llama3"}, node.Models)
+	assert.Equal(t, 1, state.Models)
+	assert.False(t, state.Time.IsZero())
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported content codings
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// maxZstdWindow bounds the window of zstd request bodies to the 8MB RFC
+// 8878 asks decoders to support, so that a frame declaring a large window
+// cannot make each request allocate it
+const maxZstdWindow = 8 << 20
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	},
+}
+
+var zstdWriterPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return w
+	},
+}
+
+// CompressionMiddleware decodes gzip/zstd request bodies and, when
+// compression is enabled, compresses JSON and NDJSON responses using the
+// best encoding the client accepts. Decompressed bodies are still subject
+// to BodyLimitMiddleware, which must run after this middleware.
+func (s *Server) CompressionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var maxBody int64
+		if s.config != nil {
+			maxBody = s.config.MaxBodySize
+		}
+		if !decompressRequest(c, maxBody) {
+			return
+		}
+
+		if s.config == nil || !s.config.Compression || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = cw
+		defer cw.close()
+
+		c.Next()
+	}
+}
+
+// decompressRequest replaces a gzip or zstd encoded request body with a
+// decoding reader, whose memory is bounded by the body limit. It aborts
+// with 415 for unsupported encodings and 400 for corrupt payloads,
+// returning false in both cases.
+func decompressRequest(c *gin.Context, maxBody int64) bool {
+	encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || c.Request.Body == nil {
+		return true
+	}
+
+	raw := c.Request.Body
+	var body io.ReadCloser
+	switch encoding {
+	case encodingGzip, "x-gzip":
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip request body"})
+			return false
+		}
+		body = &decodingBody{Reader: gz, closers: []func(){func() { gz.Close() }, func() { raw.Close() }}}
+	case encodingZstd:
+		zr, err := zstd.NewReader(raw, zstdDecoderOptions(maxBody)...)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid zstd request body"})
+			return false
+		}
+		body = &decodingBody{Reader: zr, closers: []func(){zr.Close, func() { raw.Close() }}}
+	default:
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":     "Unsupported Content-Encoding",
+			"encoding":  encoding,
+			"supported": []string{encodingGzip, encodingZstd},
+		})
+		return false
+	}
+
+	c.Request.Body = body
+	c.Request.ContentLength = -1
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Del("Content-Length")
+	return true
+}
+
+// zstdDecoderOptions limits the window of a zstd decoder to the body limit,
+// at most maxZstdWindow, and its decoded size to the body limit. A body
+// limit of zero leaves only the window bound.
+func zstdDecoderOptions(maxBody int64) []zstd.DOption {
+	window := int64(maxZstdWindow)
+	if maxBody > 0 && maxBody < window {
+		window = maxBody
+	}
+	if window < zstd.MinWindowSize {
+		window = zstd.MinWindowSize
+	}
+	memory := window
+	if maxBody > memory {
+		memory = maxBody
+	}
+	return []zstd.DOption{
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderMaxWindow(uint64(window)),
+		zstd.WithDecoderMaxMemory(uint64(memory)),
+	}
+}
+
+// decodingBody closes the decoder and the underlying request body
+type decodingBody struct {
+	io.Reader
+	closers []func()
+}
+
+func (b *decodingBody) Close() error {
+	for _, fn := range b.closers {
+		fn()
+	}
+	return nil
+}
+
+// negotiateEncoding picks zstd or gzip from an Accept-Encoding header,
+// honouring q-values and preferring zstd on ties
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encodingGzip && name != encodingZstd {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == encodingZstd) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressibleType reports whether responses of this content type are
+// compressed; binary model blobs and event streams are left alone
+func compressibleType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "application/x-ndjson") ||
+		strings.HasPrefix(contentType, "application/problem+json")
+}
+
+// flushWriter is implemented by both the gzip and zstd encoders
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressWriter decides on the first write whether to compress, based on
+// the response content type, so handlers need no changes
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+
+	decided bool
+	enc     flushWriter
+}
+
+func (w *compressWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	h := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+	if h.Get("Content-Encoding") != "" || !compressibleType(h.Get("Content-Type")) ||
+		status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	h.Set("Content-Encoding", w.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+
+	switch w.encoding {
+	case encodingZstd:
+		zw := zstdWriterPool.Get().(*zstd.Encoder)
+		zw.Reset(w.ResponseWriter)
+		w.enc = zw
+	default:
+		gz := gzipWriterPool.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		w.enc = gz
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.decide()
+	if w.enc == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	return w.enc.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush pushes buffered compressed data so streamed NDJSON responses are
+// delivered incrementally
+func (w *compressWriter) Flush() {
+	w.decide()
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) close() {
+	if w.enc == nil {
+		return
+	}
+	w.enc.Close()
+
+	switch enc := w.enc.(type) {
+	case *gzip.Writer:
+		enc.Reset(io.Discard)
+		gzipWriterPool.Put(enc)
+	case *zstd.Encoder:
+		enc.Reset(nil)
+		zstdWriterPool.Put(enc)
+	}
+	w.enc = nil
+}
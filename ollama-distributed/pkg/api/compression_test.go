@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompressionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: &config.APIConfig{Compression: true, MaxBodySize: 1 << 20}}
+
+	router := gin.New()
+	router.Use(s.CompressionMiddleware())
+	router.Use(s.BodyLimitMiddleware())
+	router.POST("/echo", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+	router.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusOK, "plain")
+	})
+	return router
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "", negotiateEncoding(""))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate"))
+	assert.Equal(t, "zstd", negotiateEncoding("gzip, zstd"))
+	assert.Equal(t, "gzip", negotiateEncoding("zstd;q=0.5, gzip"))
+	assert.Equal(t, "", negotiateEncoding("gzip;q=0, br"))
+}
+
+func TestCompressionMiddlewareGzipRoundTrip(t *testing.T) {
+	router := newCompressionRouter()
+
+	var reqBody bytes.Buffer
+	gz := gzip.NewWriter(&reqBody)
+	gz.Write([]byte(`{"prompt":"hello"}`))
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	out, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prompt":"hello"}`, string(out))
+}
+
+func TestCompressionMiddlewareZstd(t *testing.T) {
+	router := newCompressionRouter()
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	payload := enc.EncodeAll([]byte(`{"input":["a","b"]}`), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "zstd")
+	req.Header.Set("Accept-Encoding", "zstd")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "zstd", rec.Header().Get("Content-Encoding"))
+
+	dec, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	out, err := dec.DecodeAll(rec.Body.Bytes(), nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"input":["a","b"]}`, string(out))
+}
+
+func TestCompressionMiddlewareZstdWindowLimited(t *testing.T) {
+	router := newCompressionRouter()
+
+	post := func(window int) int {
+		var reqBody bytes.Buffer
+		enc, err := zstd.NewWriter(&reqBody, zstd.WithWindowSize(window))
+		require.NoError(t, err)
+		// Flushing before the end writes the window to the frame header
+		enc.Write([]byte(`{"input":`))
+		require.NoError(t, enc.Flush())
+		enc.Write([]byte(`["a","b"]}`))
+		require.NoError(t, enc.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/echo", &reqBody)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "zstd")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post(1<<20))
+	assert.Equal(t, http.StatusBadRequest, post(4<<20), "windows beyond the body limit are refused")
+}
+
+func TestZstdDecoderOptions(t *testing.T) {
+	for _, maxBody := range []int64{0, 1, 64 << 10, 32 << 20} {
+		_, err := zstd.NewReader(nil, zstdDecoderOptions(maxBody)...)
+		assert.NoError(t, err, "body limit %d", maxBody)
+	}
+}
+
+func TestCompressionMiddlewareSkipsNonJSON(t *testing.T) {
+	router := newCompressionRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/text", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "plain", rec.Body.String())
+}
+
+func TestCompressionMiddlewareRejectsUnknownEncoding(t *testing.T) {
+	router := newCompressionRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader([]byte("x")))
+	req.Header.Set("Content-Encoding", "br")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestCompressionMiddlewareDecompressedSizeLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: &config.APIConfig{MaxBodySize: 64}}
+	router := gin.New()
+	router.Use(s.CompressionMiddleware())
+	router.Use(s.BodyLimitMiddleware())
+	router.POST("/echo", func(c *gin.Context) {
+		io.ReadAll(c.Request.Body)
+		c.Status(http.StatusOK)
+	})
+
+	var reqBody bytes.Buffer
+	gz := gzip.NewWriter(&reqBody)
+	gz.Write(bytes.Repeat([]byte("a"), 4096))
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", &reqBody)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
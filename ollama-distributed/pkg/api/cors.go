@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// Used when the policy leaves the corresponding list empty
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Accept-Encoding", "Authorization", "X-CSRF-Token", "X-Request-ID"}
+)
+
+// CORS returns a middleware enforcing the given CORS policy. Requests from
+// origins outside AllowedOrigins get no CORS headers, and their preflights
+// are rejected with 403. When credentials are allowed the request origin is
+// echoed back instead of "*", as browsers require, for the origins listed;
+// origins only matched by "*" get "*" and no credentials.
+func CORS(policy config.CorsConfig) gin.HandlerFunc {
+	methods := strings.Join(orDefault(policy.AllowedMethods, defaultCORSMethods), ", ")
+	headers := strings.Join(orDefault(policy.AllowedHeaders, defaultCORSHeaders), ", ")
+	exposed := strings.Join(policy.ExposedHeaders, ", ")
+	// Credentials are only allowed for the origins listed, never for any
+	// origin matched by "*" alone
+	var listed []string
+	for _, o := range policy.AllowedOrigins {
+		if o != "*" {
+			listed = append(listed, o)
+		}
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if !policy.Enabled || origin == "" {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !originAllowed(policy.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Origin not allowed"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if policy.AllowCredentials && originAllowed(listed, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+		} else if containsOrigin(policy.AllowedOrigins, "*") {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if exposed != "" {
+			c.Header("Access-Control-Expose-Headers", exposed)
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// originAllowed matches an origin against exact entries, "*" and
+// subdomain wildcards like "https://*.example.com"
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "/"))
+		switch {
+		case pattern == "*" || pattern == origin:
+			return true
+		case strings.Contains(pattern, "://*."):
+			scheme, domain, _ := strings.Cut(pattern, "://*.")
+			if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsOrigin(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(values, fallback []string) []string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSRouter(policy config.CorsConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(policy))
+	router.Any("/x", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	router := newCORSRouter(config.CorsConfig{
+		Enabled:          true,
+		AllowedOrigins:   []string{"https://dashboard.example.com", "https://*.corp.example"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/x", nil)
+	req.Header.Set("Origin", "https://ui.corp.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://ui.corp.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "X-CSRF-Token")
+}
+
+func TestCORSRejectsUnknownOrigin(t *testing.T) {
+	router := newCORSRouter(config.CorsConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/x", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	router := newCORSRouter(config.CorsConfig{Enabled: true, AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORSWildcardNeverAllowsCredentials(t *testing.T) {
+	router := newCORSRouter(config.CorsConfig{
+		Enabled:          true,
+		AllowedOrigins:   []string{"https://dashboard.example.com", "*"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	req = httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "https://dashboard.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	assert.Error(t, config.CorsConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate())
+	assert.NoError(t, config.CorsConfig{AllowedOrigins: []string{"*"}}.Validate())
+}
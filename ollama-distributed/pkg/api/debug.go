@@ -0,0 +1,113 @@
+package api
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publishDebugVars registers the runtime variables served on /debug/vars once
+// per process, as expvar panics on duplicate names
+var publishDebugVars sync.Once
+
+// processStart is reported as uptime on /debug/vars
+var processStart = time.Now()
+
+// registerDebugRoutes exposes net/http/pprof, expvar and a dump trigger under
+// /debug. Every route requires an authenticated admin.
+func (s *Server) registerDebugRoutes() {
+	publishDebugVars.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+		expvar.Publish("uptime_seconds", expvar.Func(func() interface{} { return time.Since(processStart).Seconds() }))
+	})
+
+	debug := s.router.Group("/debug")
+	debug.Use(s.AuthMiddleware(), s.RoleMiddleware("admin"))
+	{
+		debug.GET("/pprof/*profile", s.servePprof)
+		debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/vars", gin.WrapH(expvar.Handler()))
+		debug.POST("/dump", s.debugDump)
+	}
+}
+
+// servePprof dispatches to the net/http/pprof handlers. pprof.Index serves
+// the index and every named profile (heap, goroutine, allocs, ...) itself.
+func (s *Server) servePprof(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("profile"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// debugDump writes a goroutine dump and a heap profile of this node to the
+// configured dump directory and returns the file names
+func (s *Server) debugDump(c *gin.Context) {
+	dir := s.config.Debug.DumpDir
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create dump directory: %v", err)})
+		return
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	dumps := []struct {
+		profile string
+		debug   int
+		name    string
+	}{
+		// debug=2 prints every goroutine with its full stack, as on a crash
+		{"goroutine", 2, fmt.Sprintf("goroutines-%s.txt", stamp)},
+		{"heap", 0, fmt.Sprintf("heap-%s.pprof", stamp)},
+	}
+
+	// Collect garbage first so the heap profile reflects live objects
+	runtime.GC()
+
+	files := make([]string, 0, len(dumps))
+	for _, d := range dumps {
+		path := filepath.Join(dir, d.name)
+		if err := writeProfile(path, d.profile, d.debug); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "files": files})
+			return
+		}
+		files = append(files, path)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
+
+// writeProfile writes the named runtime profile to path
+func writeProfile(path, profile string, debug int) error {
+	p := runtimepprof.Lookup(profile)
+	if p == nil {
+		return fmt.Errorf("unknown profile %q", profile)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := p.WriteTo(f, debug); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s profile: %w", profile, err)
+	}
+	return f.Close()
+}
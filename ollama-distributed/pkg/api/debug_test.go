@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDebugRouter(dumpDir string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: &config.APIConfig{Debug: config.DebugConfig{Enabled: true, DumpDir: dumpDir}}}
+
+	router := gin.New()
+	router.GET("/debug/pprof/*profile", s.servePprof)
+	router.POST("/debug/dump", s.debugDump)
+	return router
+}
+
+func TestServePprof(t *testing.T) {
+	router := newDebugRouter(t.TempDir())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine profile:")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDebugDump(t *testing.T) {
+	dir := t.TempDir()
+	router := newDebugRouter(dir)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/dump", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Files []string `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Files, 2)
+
+	goroutines, err := os.ReadFile(body.Files[0])
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(goroutines), "goroutine "))
+
+	info, err := os.Stat(body.Files[1])
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+)
+
+// Response headers telling callers their request was served by a fallback
+// model
+const (
+	RequestedModelHeader = "X-Requested-Model"
+	FallbackModelHeader  = "X-Fallback-Model"
+	FallbackReasonHeader = "X-Fallback-Reason"
+)
+
+// SetDegradation serves smaller fallback models in place of overloaded
+// ones and exposes the fallback rates on /api/v1/degradation
+func (s *Server) SetDegradation(degrader *degradation.Degrader) {
+	s.degrader = degrader
+}
+
+// selectModel returns the model an inference request for model is served
+// by, falling back to a smaller model the caller may use when the requested
+// one is overloaded. Fallbacks are announced in the response headers.
+func (s *Server) selectModel(c *gin.Context, model string) degradation.Decision {
+	if s.degrader == nil {
+		return degradation.Decision{Requested: model, Model: model}
+	}
+
+	decision := s.degrader.Select(model, func(fallback string) bool {
+		return s.checkTenantModel(c, fallback) == nil
+	})
+	if decision.Fallback() {
+		c.Header(RequestedModelHeader, decision.Requested)
+		c.Header(FallbackModelHeader, decision.Model)
+		c.Header(FallbackReasonHeader, decision.Reason)
+	}
+	return decision
+}
+
+// getDegradation returns the fallback chains and how often each model fell
+// back
+func (s *Server) getDegradation(c *gin.Context) {
+	if s.degrader == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "degradation mode not enabled"})
+		return
+	}
+
+	cfg := s.degrader.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"chains":          cfg.Chains,
+		"queue_threshold": cfg.QueueThreshold,
+		"usage_threshold": cfg.UsageThreshold,
+		"on_slo_burn":     cfg.OnSLOBurn,
+		"models":          s.degrader.Stats(),
+	})
+}
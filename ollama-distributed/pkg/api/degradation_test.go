@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectModelFallsBack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	degrader := degradation.NewDegrader(degradation.Config{Enabled: true, Chains: map[string][]string{
+		"llama3:70b": {"llama3:8b"},
+	}})
+	degrader.SetCapacity(func(model string) bool { return model != "llama3:70b" })
+
+	s := &Server{}
+	router := gin.New()
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"model": s.selectModel(c, c.Query("model")).Model})
+	})
+	router.GET("/api/v1/degradation", s.getDegradation)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/degradation", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	s.SetDegradation(degrader)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/generate?model=llama3:70b", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"model":"llama3:8b"}`, w.Body.String())
+	assert.Equal(t, "llama3:70b", w.Header().Get(RequestedModelHeader))
+	assert.Equal(t, "llama3:8b", w.Header().Get(FallbackModelHeader))
+	assert.Equal(t, degradation.ReasonCapacity, w.Header().Get(FallbackReasonHeader))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/generate?model=mistral", nil))
+	assert.Empty(t, w.Header().Get(FallbackModelHeader))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/degradation", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Models []degradation.Stats `json:"models"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Models, 1)
+	assert.Equal(t, 1.0, body.Models[0].FallbackRate)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/inference"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ollama/api"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
@@ -345,7 +346,8 @@ func (doi *DistributedOllamaIntegration) handleDistributedRequest(
 	}
 
 	doi.logger.Info("Distributed request completed",
-		"request_id", distributedReq.ID,
+		"request_id", logging.RequestIDFromContext(ctx),
+		"distributed_request_id", distributedReq.ID,
 		"model", req.Model,
 		"nodes_used", len(result.NodesUsed),
 		"latency", time.Since(startTime))
@@ -399,6 +401,16 @@ func (doi *DistributedOllamaIntegration) handleLocalRequest(
 ) (*api.GenerateResponse, error) {
 	startTime := time.Now()
 
+	// Keep the model and its local replica from being removed meanwhile
+	lease, err := doi.modelManager.Refs().Acquire(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+	if err := lease.AddReplicas(doi.p2pNode.ID().String()); err != nil {
+		return nil, err
+	}
+
 	// This would integrate with the local Ollama server
 	// For now, return a mock response
 	response := &api.GenerateResponse{
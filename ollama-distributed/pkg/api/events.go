@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+)
+
+// Bounds of the number of events one request returns
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// SetEvents enables the history of cluster state changes on /api/v1/events,
+// and records the changes made through the API to it
+func (s *Server) SetEvents(history *events.Log) {
+	s.events = history
+}
+
+// recordEvent records a change made through the API by the caller
+func (s *Server) recordEvent(c *gin.Context, event events.Event) {
+	if s.events == nil {
+		return
+	}
+	ctx := events.WithActor(c.Request.Context(), c.GetString("username"))
+	if _, err := s.events.Record(ctx, event); err != nil {
+		log.Printf("Failed to record %s event: %v", event.Type, err)
+	}
+}
+
+// parseEventTime parses an RFC 3339 time, or a duration before now
+func parseEventTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// getEvents lists the cluster events of a time range, newest first. since
+// and until are RFC 3339 times or durations before now, e.g. since=2h;
+// type selects event types, comma separated or repeated.
+func (s *Server) getEvents(c *gin.Context) {
+	if s.events == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "events not enabled"})
+		return
+	}
+
+	var filter events.Filter
+	var err error
+	if filter.Since, err = parseEventTime(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since: %v", err)})
+		return
+	}
+	if filter.Until, err = parseEventTime(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid until: %v", err)})
+		return
+	}
+	for _, types := range c.QueryArray("type") {
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+	filter.Limit = defaultEventLimit
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = min(filter.Limit, maxEventLimit)
+	}
+
+	list, err := s.events.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if list == nil {
+		list = []*events.Event{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": list})
+}
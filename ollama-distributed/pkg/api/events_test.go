@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := placement.NewPolicy(placement.Constraints{})
+	require.NoError(t, err)
+	history := events.NewLog(events.NewMemoryStore(0), "node-1", nil)
+
+	s := &Server{}
+	s.SetPlacementPolicy(policy)
+	s.SetEvents(history)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("username", "ops") })
+	router.PUT("/api/v1/placement/constraints", s.setPlacementConstraints)
+	router.GET("/api/v1/events", s.getEvents)
+
+	outage := time.Now().Add(-time.Hour)
+	_, err = history.Record(context.Background(), events.Event{Type: events.NodeLeft, Subject: "node-2", Time: outage.Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = history.Record(context.Background(), events.Event{Type: events.LeaderChanged, Subject: "node-3", Time: outage.Add(time.Minute)})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/placement/constraints",
+		strings.NewReader(`{"anti_affinity": [{"models": ["llama3", "mixtral"]}]}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	list := func(query string) (int, []events.Event) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events?"+query, nil))
+		var result struct {
+			Events []events.Event `json:"events"`
+		}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec.Code, result.Events
+	}
+
+	// What changed before the outage
+	code, before := list("until=" + outage.Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, before, 1)
+	assert.Equal(t, events.NodeLeft, before[0].Type)
+
+	// Changes made through the API name their actor
+	code, recent := list("since=10m&type=placement_changed,leader_changed")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, recent, 1)
+	assert.Equal(t, "constraints", recent[0].Subject)
+	assert.Equal(t, "ops", recent[0].Actor)
+
+	_, all := list("type=node_left&type=leader_changed&limit=1")
+	require.Len(t, all, 1)
+	assert.Equal(t, events.LeaderChanged, all[0].Type)
+
+	code, _ = list("since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = list("limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
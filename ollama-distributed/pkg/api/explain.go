@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// explainRequest is the body of POST /api/v1/scheduler/explain
+type explainRequest struct {
+	Model       string                 `json:"model" binding:"required"`
+	Options     map[string]interface{} `json:"options"`
+	Tolerations []placement.Toleration `json:"tolerations"`
+}
+
+// explainResponse describes how a request would be placed
+type explainResponse struct {
+	Selection  *scheduler.SelectionExplanation  `json:"selection"`
+	Strategy   string                           `json:"strategy,omitempty"`
+	Strategies []partitioning.StrategyCandidate `json:"strategies,omitempty"`
+	Plan       *partitioning.PlanExport         `json:"plan,omitempty"`
+}
+
+// explainScheduling answers how a request for a model would be scheduled:
+// the nodes considered with their scores, the partitioning strategy that
+// would be chosen and the resulting plan. Nothing is executed and the plan
+// is not recorded.
+func (s *Server) explainScheduling(c *gin.Context) {
+	var req explainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not available"})
+		return
+	}
+
+	if err := placement.ValidateTolerations(req.Tolerations); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A tenant's requests only run in its node pool
+	var selector map[string]string
+	if tenant, ok := s.callerTenant(c); ok {
+		var poolTolerations []placement.Toleration
+		selector, poolTolerations = tenant.Placement()
+		req.Tolerations = append(req.Tolerations, poolTolerations...)
+	}
+
+	resp := explainResponse{Selection: s.scheduler.ExplainSelectionIn(req.Model, req.Tolerations, selector)}
+	if s.partitions == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	task := &partitioning.PartitionTask{
+		ID:          "explain",
+		Type:        "inference",
+		Model:       &types.OllamaModel{Name: req.Model},
+		Options:     req.Options,
+		Tolerations: req.Tolerations,
+	}
+	if task.Options == nil {
+		task.Options = make(map[string]interface{})
+	}
+	if model, ok := s.scheduler.GetModel(req.Model); ok {
+		task.Model.Size = model.Size
+	}
+
+	nodes := s.scheduler.GetNodes()
+	for _, score := range resp.Selection.Nodes {
+		if !score.Candidate {
+			continue
+		}
+		node := nodes[score.NodeID]
+		if node == nil {
+			continue
+		}
+		labels, _ := s.scheduler.NodeLabels().Get(node.ID)
+		info := partitioning.NodeFromInfo(node)
+		info.Labels, info.Taints = labels.Labels, labels.Taints
+		task.Nodes = append(task.Nodes, info)
+	}
+
+	strategy, strategies, err := s.partitions.ExplainStrategy(task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp.Strategy = strategy
+	resp.Strategies = strategies
+
+	plan, err := s.partitions.DryRun(c.Request.Context(), task, strategy)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "selection": resp.Selection})
+		return
+	}
+	resp.Plan = plan.Export()
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+)
+
+// SetFailureDetector exposes the per-node stats of the failure detector on
+// /api/v1/nodes/heartbeats
+func (s *Server) SetFailureDetector(detector *failuredetector.Detector) {
+	s.failureDetector = detector
+}
+
+// getNodeHeartbeats returns the suspicion of every node heard from, with
+// the heartbeat intervals it is judged by
+func (s *Server) getNodeHeartbeats(c *gin.Context) {
+	if s.failureDetector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failure detector not enabled"})
+		return
+	}
+
+	cfg := s.failureDetector.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"heartbeat_interval": cfg.HeartbeatInterval.String(),
+		"threshold":          cfg.Threshold,
+		"nodes":              s.failureDetector.Stats(),
+	})
+}
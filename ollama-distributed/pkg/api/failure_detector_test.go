@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNodeHeartbeats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/nodes/heartbeats", s.getNodeHeartbeats)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nodes/heartbeats", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	detector := failuredetector.NewDetector(failuredetector.Config{Threshold: 5})
+	detector.Heartbeat("node-1", time.Now())
+	s.SetFailureDetector(detector)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nodes/heartbeats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Threshold float64                     `json:"threshold"`
+		Nodes     []failuredetector.NodeStats `json:"nodes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 5.0, body.Threshold)
+	require.Len(t, body.Nodes, 1)
+	assert.Equal(t, "node-1", body.Nodes[0].NodeID)
+	assert.Equal(t, int64(1), body.Nodes[0].Heartbeats)
+	assert.False(t, body.Nodes[0].Suspected)
+}
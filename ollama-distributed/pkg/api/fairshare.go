@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getFairShares returns each tenant's current share of the scheduler
+func (s *Server) getFairShares(c *gin.Context) {
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not available"})
+		return
+	}
+
+	shares := s.scheduler.FairShares()
+	if shares == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fair-share scheduling not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": shares})
+}
+
+// setFairShareWeights replaces the tenant weights of fair-share scheduling
+func (s *Server) setFairShareWeights(c *gin.Context) {
+	var req struct {
+		Weights map[string]float64 `json:"weights" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for tenant, weight := range req.Weights {
+		if weight <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weight of tenant " + tenant + " must be positive"})
+			return
+		}
+	}
+
+	if s.scheduler == nil || !s.scheduler.SetFairShareWeights(req.Weights) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fair-share scheduling not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": s.scheduler.FairShares()})
+}
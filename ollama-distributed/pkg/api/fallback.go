@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	ollamaapi "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 )
 
@@ -233,6 +234,7 @@ func (fm *FallbackManager) executeLocalRequest(c *gin.Context) error {
 			req.Header.Add(key, value)
 		}
 	}
+	logging.PropagateRequestID(req)
 
 	// Execute request with retries
 	var resp *http.Response
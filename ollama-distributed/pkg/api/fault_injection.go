@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/faultinject"
+)
+
+// SetFaultInjector lets admins inject synthetic faults on
+// /api/v1/faults/injections
+func (s *Server) SetFaultInjector(injector *faultinject.Injector) {
+	s.faultInjector = injector
+}
+
+// injectFaultRequest asks for a fault on a node. Duration and latency are
+// durations such as "10m" and "800ms".
+type injectFaultRequest struct {
+	Kind     faultinject.Kind `json:"kind" binding:"required"`
+	Target   string           `json:"target" binding:"required"`
+	Duration string           `json:"duration"`
+	Latency  string           `json:"latency"`
+	Resource string           `json:"resource"`
+	Usage    float64          `json:"usage"`
+}
+
+// getFaultInjections lists the injected faults that have not ended
+func (s *Server) getFaultInjections(c *gin.Context) {
+	if s.faultInjector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault injection not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"injections": s.faultInjector.Injections()})
+}
+
+// injectFault raises a synthetic fault in the fault tolerance manager
+func (s *Server) injectFault(c *gin.Context) {
+	if s.faultInjector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault injection not enabled"})
+		return
+	}
+
+	var req injectFaultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	injectReq := faultinject.Request{
+		Kind:     req.Kind,
+		Target:   req.Target,
+		Resource: req.Resource,
+		Usage:    req.Usage,
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"duration", req.Duration, &injectReq.Duration},
+		{"latency", req.Latency, &injectReq.Latency},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %v", d.name, err)})
+			return
+		}
+		*d.dst = parsed
+	}
+
+	injection, err := s.faultInjector.Inject(injectReq)
+	switch {
+	case errors.Is(err, faultinject.ErrUnknownNode):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.recordEvent(c, events.Event{Type: events.FaultInjected, Subject: injection.Target,
+		Data: map[string]interface{}{"injection_id": injection.ID, "kind": injection.Kind, "expires_at": injection.ExpiresAt}})
+	c.JSON(http.StatusCreated, injection)
+}
+
+// clearFaultInjection ends an injected fault before it expires
+func (s *Server) clearFaultInjection(c *gin.Context) {
+	if s.faultInjector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault injection not enabled"})
+		return
+	}
+
+	injection, ok := s.faultInjector.Clear(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "fault injection not found"})
+		return
+	}
+
+	s.recordEvent(c, events.Event{Type: events.FaultCleared, Subject: injection.Target,
+		Data: map[string]interface{}{"injection_id": injection.ID, "kind": injection.Kind}})
+	c.JSON(http.StatusOK, gin.H{"id": injection.ID, "cleared": true})
+}
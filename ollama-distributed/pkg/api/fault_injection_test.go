@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/faultinject"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := fault_tolerance.NewFaultToleranceManager(&fault_tolerance.Config{HealthCheckInterval: time.Second})
+	injector, err := faultinject.NewInjector(faultinject.Config{Enabled: true}, "staging", manager, nil)
+	require.NoError(t, err)
+
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/faults/injections", s.getFaultInjections)
+	router.POST("/api/v1/faults/injections", s.injectFault)
+	router.DELETE("/api/v1/faults/injections/:id", s.clearFaultInjection)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/faults/injections", "").Code)
+	s.SetFaultInjector(injector)
+
+	rec := do(http.MethodPost, "/api/v1/faults/injections",
+		`{"kind": "high_latency", "target": "node-2", "duration": "10m", "latency": "800ms"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var injection faultinject.Injection
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &injection))
+	assert.Equal(t, faultinject.HighLatency, injection.Kind)
+	assert.Equal(t, 10*time.Minute, injection.ExpiresAt.Sub(injection.StartedAt))
+
+	// The fault reaches the fault tolerance manager, marked as injected
+	detections := manager.GetFaultDetections()
+	require.Len(t, detections, 1)
+	assert.Equal(t, fault_tolerance.FaultTypePerformanceAnomaly, detections[0].Type)
+	assert.Equal(t, "node-2", detections[0].Target)
+	assert.Equal(t, true, detections[0].Metadata["injected"])
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/faults/injections",
+		`{"kind": "high_latency", "target": "node-2", "duration": "soon"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/faults/injections",
+		`{"kind": "meteor_strike", "target": "node-2"}`).Code)
+
+	rec = do(http.MethodGet, "/api/v1/faults/injections", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Injections []faultinject.Injection `json:"injections"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Injections, 1)
+	assert.Equal(t, injection.ID, listed.Injections[0].ID)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/faults/injections/"+injection.ID, "").Code)
+	assert.Equal(t, fault_tolerance.FaultStatusResolved, manager.GetFaultDetections()[0].Status)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/faults/injections/"+injection.ID, "").Code)
+}
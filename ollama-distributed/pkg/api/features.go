@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/features"
+)
+
+// SetFeatures exposes feature flags on /api/v1/features and their state on
+// this node in the health status
+func (s *Server) SetFeatures(manager *features.Manager) {
+	s.features = manager
+}
+
+// getFeatureFlags returns the flags and whether each is on on this node
+func (s *Server) getFeatureFlags(c *gin.Context) {
+	if s.features == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "feature flags not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node_id": s.features.NodeID(),
+		"flags":   s.features.Flags(),
+		"enabled": s.features.Status(),
+	})
+}
+
+// setFeatureFlag creates or replaces the flag named in the path. Flags are
+// changed on the leader, which shares them with the cluster.
+func (s *Server) setFeatureFlag(c *gin.Context) {
+	if s.features == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "feature flags not enabled"})
+		return
+	}
+
+	var flag features.Flag
+	if err := c.ShouldBindJSON(&flag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	flag.Name = c.Param("name")
+	if err := flag.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.features.Set(flag); err != nil {
+		c.JSON(featureErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	flag, _ = s.features.Flag(flag.Name)
+	c.JSON(http.StatusOK, flag)
+}
+
+// deleteFeatureFlag removes a flag, turning it off everywhere
+func (s *Server) deleteFeatureFlag(c *gin.Context) {
+	if s.features == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "feature flags not enabled"})
+		return
+	}
+
+	name := c.Param("name")
+	found, err := s.features.Delete(name)
+	if err != nil {
+		c.JSON(featureErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feature flag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Feature flag deleted", "name": name})
+}
+
+// featureErrorStatus maps a flag change error to a status code
+func featureErrorStatus(err error) int {
+	if errors.Is(err, features.ErrNotLeader) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/features"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager, err := features.NewManager(features.Config{}, "node-1", nil)
+	require.NoError(t, err)
+
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/features", s.getFeatureFlags)
+	router.PUT("/api/v1/features/:name", s.setFeatureFlag)
+	router.DELETE("/api/v1/features/:name", s.deleteFeatureFlag)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/features", "").Code)
+	s.SetFeatures(manager)
+
+	rec := do(http.MethodPut, "/api/v1/features/quic_transport", `{"nodes": {"node-1": true}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, manager.Enabled(features.QUICTransport, ""))
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/features/partition_strategy.data_split",
+		`{"percentage": 150}`).Code)
+
+	rec = do(http.MethodGet, "/api/v1/features", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		NodeID  string          `json:"node_id"`
+		Flags   []features.Flag `json:"flags"`
+		Enabled map[string]bool `json:"enabled"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Equal(t, "node-1", listed.NodeID)
+	require.Len(t, listed.Flags, 1)
+	assert.Equal(t, map[string]bool{"quic_transport": true}, listed.Enabled)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/features/quic_transport", "").Code)
+	assert.False(t, manager.Enabled(features.QUICTransport, ""))
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/features/quic_transport", "").Code)
+}
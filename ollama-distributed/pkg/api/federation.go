@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+)
+
+// SetFederation enables the federation endpoints and forwarding of
+// inference overflow to peer clusters
+func (s *Server) SetFederation(f *federation.Federation) {
+	s.federation = f
+}
+
+// FederationMiddleware admits calls from peer clusters, identified by their
+// cluster header and token
+func (s *Server) FederationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.federation == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "federation not enabled"})
+			c.Abort()
+			return
+		}
+		peer, ok := s.federation.Authenticate(c.Request)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unknown federation peer or invalid token"})
+			c.Abort()
+			return
+		}
+		c.Set("federation_peer", peer)
+		c.Next()
+	}
+}
+
+// receiveFederationManifest stores a peer's manifest and replies with this
+// cluster's
+func (s *Server) receiveFederationManifest(c *gin.Context) {
+	var manifest federation.Manifest
+	if err := c.ShouldBindJSON(&manifest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reply, err := s.federation.ReceiveManifest(c.GetString("federation_peer"), manifest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reply)
+}
+
+// forwardedInference serves an inference request a peer forwarded, within
+// the limits of the overflow policy
+func (s *Server) forwardedInference(c *gin.Context) {
+	var handler gin.HandlerFunc
+	switch c.Param("kind") {
+	case "generate":
+		handler = s.generate
+	case "chat":
+		handler = s.chat
+	case "embeddings":
+		handler = s.embeddings
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown inference kind"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	release, err := s.federation.AcceptForward(c.GetString("federation_peer"), req.Model)
+	if err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, federation.ErrInboundBusy) {
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer release()
+
+	// Served here even when this cluster is overloaded too: forwarded
+	// requests never travel further
+	c.Set("federation_forwarded", true)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	handler(c)
+}
+
+// forwardToPeer forwards an inference request to a peer cluster when geo
+// routing or the overflow policy calls for it, and reports whether it was
+// served there. If no peer can take it, the request is left to be served
+// locally.
+func (s *Server) forwardToPeer(c *gin.Context, kind, model string, req interface{}) bool {
+	if s.federation == nil || c.GetBool("federation_forwarded") || c.GetHeader(federation.HopHeader) != "" {
+		return false
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false
+	}
+	if s.geoRouter != nil && s.routeByRegion(c, kind, model, body) {
+		return true
+	}
+
+	served := true
+	var queueDepth int64
+	if s.scheduler != nil {
+		_, served = s.scheduler.GetModel(model)
+		queueDepth = s.scheduler.GetStats().QueuedRequests
+	}
+	reason, ok := s.federation.ShouldForward(model, queueDepth, served)
+	if !ok {
+		return false
+	}
+
+	peer, err := s.federation.Forward(c.Request.Context(), c.Writer, kind, model, body)
+	if err != nil {
+		if !errors.Is(err, federation.ErrNoPeer) {
+			log.Printf("Federation %s forwarding of %s failed, serving locally: %v", reason, model, err)
+		}
+		return false
+	}
+	c.Abort()
+	log.Printf("Forwarded %s request for %s to peer cluster %s (%s)", kind, model, peer, reason)
+	return true
+}
+
+// getFederationPeers lists the peer clusters with their models, capacity
+// and forwarding counters
+func (s *Server) getFederationPeers(c *gin.Context) {
+	if s.federation == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "federation not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cluster_id": s.federation.ClusterID(),
+		"peers":      s.federation.Peers(),
+	})
+}
+
+// getFederationModels lists the models shared with peers and, for each
+// model online peers serve, the peers serving it
+func (s *Server) getFederationModels(c *gin.Context) {
+	if s.federation == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "federation not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"local": s.federation.LocalManifest().Models,
+		"peers": s.federation.Catalog(),
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFederationRouter(s *Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	federated := router.Group("/api/v1/federation")
+	federated.Use(s.FederationMiddleware())
+	federated.POST("/manifest", s.receiveFederationManifest)
+	federated.POST("/forward/:kind", s.forwardedInference)
+	router.GET("/api/v1/federation/peers", s.getFederationPeers)
+	return router
+}
+
+func TestFederationManifestExchange(t *testing.T) {
+	west, err := federation.NewFederation(federation.Config{
+		ClusterID: "west",
+		Peers:     []federation.PeerConfig{{Name: "east", URL: "http://east.invalid", Token: "shared"}},
+	}, nil, nil)
+	require.NoError(t, err)
+	westServer := httptest.NewServer(newFederationRouter(&Server{federation: west}))
+	defer westServer.Close()
+
+	east, err := federation.NewFederation(federation.Config{
+		ClusterID: "east",
+		Region:    "us-east-1",
+		Peers:     []federation.PeerConfig{{Name: "west", URL: westServer.URL, Token: "shared"}},
+	}, nil, nil)
+	require.NoError(t, err)
+	east.ExchangeAll(context.Background())
+	require.True(t, east.Peers()[0].Online)
+
+	rec := httptest.NewRecorder()
+	newFederationRouter(&Server{federation: west}).ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/api/v1/federation/peers", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result struct {
+		ClusterID string                  `json:"cluster_id"`
+		Peers     []federation.PeerStatus `json:"peers"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "west", result.ClusterID)
+	require.Len(t, result.Peers, 1)
+	assert.True(t, result.Peers[0].Online)
+	assert.Equal(t, "us-east-1", result.Peers[0].Region)
+}
+
+func TestFederationRejectsUnknownPeers(t *testing.T) {
+	west, err := federation.NewFederation(federation.Config{
+		ClusterID: "west",
+		Peers:     []federation.PeerConfig{{Name: "east", URL: "http://east.invalid", Token: "shared"}},
+		Overflow:  federation.OverflowPolicy{AcceptInbound: false},
+	}, nil, nil)
+	require.NoError(t, err)
+	router := newFederationRouter(&Server{federation: west})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/federation/forward/generate", strings.NewReader(`{"model":"llama3"}`))
+	req.Header.Set(federation.ClusterHeader, "east")
+	req.Header.Set("Authorization", "Bearer guess")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/federation/forward/generate", strings.NewReader(`{"model":"llama3"}`))
+	req.Header.Set(federation.ClusterHeader, "east")
+	req.Header.Set("Authorization", "Bearer shared")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "inbound overflow is disabled")
+}
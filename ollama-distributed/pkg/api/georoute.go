@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/georoute"
+)
+
+// RegionHeader is set on inference responses to the region that served them
+const RegionHeader = "X-Routed-Region"
+
+// SetGeoRouter routes inference requests to the closest healthy region.
+// localRegion is the region of this cluster; other regions are reached
+// through federation peers.
+func (s *Server) SetGeoRouter(router *georoute.Router, localRegion string) {
+	s.geoRouter = router
+	s.localRegion = localRegion
+}
+
+// routeByRegion serves an inference request in the closest healthy region
+// other than this one, trying further regions when one fails. It reports
+// whether the request was handled; if not, it is served here.
+func (s *Server) routeByRegion(c *gin.Context, kind, model string, body []byte) bool {
+	peers := s.federation.Peers()
+	targets := make([]georoute.Target, 0, len(peers)+1)
+	targets = append(targets, georoute.Target{Region: s.localRegion, Healthy: !s.draining.Load()})
+	for _, peer := range peers {
+		if peer.Region == "" {
+			continue
+		}
+		targets = append(targets, georoute.Target{
+			Region:  peer.Region,
+			Healthy: peer.Online && peer.Capacity.Accepting && peerServes(peer, model),
+		})
+	}
+
+	decision, err := s.geoRouter.Route(georoute.Client{
+		IP:     net.ParseIP(c.ClientIP()),
+		Header: c.GetHeader(s.geoRouter.Header()),
+		Model:  model,
+	}, targets)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error(), "routing": decision})
+		c.Abort()
+		return true
+	}
+
+	for i, region := range decision.Regions {
+		if region == s.localRegion {
+			s.geoRouter.Record(region, i > 0, false, 0)
+			c.Header(RegionHeader, region)
+			return false
+		}
+
+		start := time.Now()
+		peer, err := s.federation.ForwardToRegion(c.Request.Context(), c.Writer, region, kind, model, body)
+		if err != nil {
+			s.geoRouter.Record(region, i > 0, true, 0)
+			if !errors.Is(err, federation.ErrNoPeer) {
+				log.Printf("Geo routing of %s to region %s failed, trying the next region: %v", model, region, err)
+			}
+			continue
+		}
+		s.geoRouter.Record(region, i > 0, false, time.Since(start))
+		c.Abort()
+		log.Printf("Routed %s request for %s to region %s (peer %s)", kind, model, region, peer)
+		return true
+	}
+
+	if decision.Strict {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": georoute.ErrNoRegion.Error(), "routing": decision})
+		c.Abort()
+		return true
+	}
+	return false
+}
+
+func peerServes(peer federation.PeerStatus, model string) bool {
+	for _, m := range peer.Models {
+		if m.Name == model {
+			return true
+		}
+	}
+	return false
+}
+
+// getRoutingRegions returns the per-region routing counters
+func (s *Server) getRoutingRegions(c *gin.Context) {
+	if s.geoRouter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "geo routing not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"local_region": s.localRegion,
+		"header":       s.geoRouter.Header(),
+		"regions":      s.geoRouter.Stats(),
+	})
+}
+
+// getRoutingOverrides returns the manual routing override rules
+func (s *Server) getRoutingOverrides(c *gin.Context) {
+	if s.geoRouter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "geo routing not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overrides": s.geoRouter.Overrides()})
+}
+
+// setRoutingOverrides replaces the manual routing override rules
+func (s *Server) setRoutingOverrides(c *gin.Context) {
+	if s.geoRouter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "geo routing not enabled"})
+		return
+	}
+
+	var req struct {
+		Overrides []georoute.Override `json:"overrides"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.geoRouter.SetOverrides(req.Overrides); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overrides": s.geoRouter.Overrides()})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/georoute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRegionPeer serves a peer cluster in eu-west-1 that answers forwarded
+// requests with its region
+func newRegionPeer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/federation/manifest":
+			json.NewEncoder(w).Encode(federation.Manifest{
+				ClusterID: "eu",
+				Region:    "eu-west-1",
+				Models:    []federation.Model{{Name: "llama3"}},
+				Capacity:  federation.Capacity{Nodes: 2, Accepting: true},
+			})
+		case "/api/v1/federation/forward/generate":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"response":"eu-west-1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newGeoRoutedServer(t *testing.T, peerURL string, overrides []georoute.Override) *Server {
+	fed, err := federation.NewFederation(federation.Config{
+		ClusterID: "us",
+		Region:    "us-east-1",
+		Peers:     []federation.PeerConfig{{Name: "eu", URL: peerURL, Token: "shared"}},
+	}, nil, nil)
+	require.NoError(t, err)
+	fed.ExchangeAll(context.Background())
+
+	router, err := georoute.NewRouter(georoute.Config{Overrides: overrides})
+	require.NoError(t, err)
+	s := &Server{federation: fed}
+	s.SetGeoRouter(router, "us-east-1")
+	return s
+}
+
+func geoRoutedRequest(s *Server, clientRegion, model string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/generate", func(c *gin.Context) {
+		if s.forwardToPeer(c, "generate", model, gin.H{"model": model}) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": "local"})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(`{}`))
+	req.Header.Set(georoute.DefaultHeader, clientRegion)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGeoRoutingClosestRegion(t *testing.T) {
+	peer := newRegionPeer(t)
+	defer peer.Close()
+	s := newGeoRoutedServer(t, peer.URL, nil)
+
+	rec := geoRoutedRequest(s, "eu-central-1", "llama3")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"response":"eu-west-1"}`, rec.Body.String())
+
+	rec = geoRoutedRequest(s, "us-east-2", "llama3")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"response":"local"}`, rec.Body.String())
+	assert.Equal(t, "us-east-1", rec.Header().Get(RegionHeader))
+
+	// The peer does not serve the model, so the request stays here
+	rec = geoRoutedRequest(s, "eu-central-1", "mistral")
+	assert.JSONEq(t, `{"response":"local"}`, rec.Body.String())
+
+	stats := s.geoRouter.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "eu-west-1", stats[0].Region)
+	assert.Equal(t, int64(1), stats[0].Requests)
+	assert.Equal(t, int64(2), stats[1].Requests)
+}
+
+func TestGeoRoutingFallsBack(t *testing.T) {
+	peer := newRegionPeer(t)
+	s := newGeoRoutedServer(t, peer.URL, nil)
+	peer.Close()
+
+	// The peer looks healthy until the forward fails
+	rec := geoRoutedRequest(s, "eu-central-1", "llama3")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"response":"local"}`, rec.Body.String())
+
+	stats := s.geoRouter.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, georoute.RegionStats{Region: "eu-west-1", Requests: 1, Failures: 1}, stats[0])
+	assert.Equal(t, int64(1), stats[1].Fallbacks)
+
+	require.NoError(t, s.geoRouter.SetOverrides([]georoute.Override{
+		{Name: "eu-residency", ClientRegion: "eu-*", Region: "eu-west-1", Strict: true},
+	}))
+	rec = geoRoutedRequest(s, "eu-central-1", "llama3")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "strict overrides never fall back")
+}
@@ -0,0 +1,427 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// maxGraphQLQueryBytes bounds the query of one GraphQL request
+const maxGraphQLQueryBytes = 64 << 10
+
+// graphQLCallerKey carries the request context of the caller to resolvers
+type graphQLCallerKey struct{}
+
+// graphQLRequest is a GraphQL request as sent by POST
+type graphQLRequest struct {
+	Query         string                 `json:"query" form:"query"`
+	OperationName string                 `json:"operationName" form:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLModel is a model as the caller sees it
+type graphQLModel struct {
+	name string
+	info *scheduler.ModelInfo
+}
+
+// graphQLLabel is a key and value of node labels or model metadata
+type graphQLLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// graphQLReplica is a node holding a replica of a model
+type graphQLReplica struct {
+	NodeID string `json:"nodeId"`
+	node   *scheduler.NodeInfo
+}
+
+// graphQLAlert is an active alert of the SLO monitor or the watchdog
+type graphQLAlert struct {
+	Source   string    `json:"source"`
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Since    time.Time `json:"since"`
+}
+
+// resolve adapts a function of the source of a field to a resolver
+func resolve[T any](fn func(T) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		source, ok := p.Source.(T)
+		if !ok {
+			return nil, nil
+		}
+		return fn(source), nil
+	}
+}
+
+// graphQLCaller returns the request context of the caller of a resolver
+func graphQLCaller(p graphql.ResolveParams) *gin.Context {
+	c, _ := p.Context.Value(graphQLCallerKey{}).(*gin.Context)
+	return c
+}
+
+// labels converts a map to labels sorted by key
+func labels(m map[string]string) []graphQLLabel {
+	list := make([]graphQLLabel, 0, len(m))
+	for key, value := range m {
+		list = append(list, graphQLLabel{Key: key, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// GraphQLHandler serves the read-only GraphQL view of the cluster: nodes,
+// models and their replicas, jobs, metrics and alerts, each selected down to
+// the fields a dashboard view needs, in one request. Callers see what the
+// REST API shows them.
+func (s *Server) GraphQLHandler() (gin.HandlerFunc, error) {
+	schema, err := s.graphQLSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		var req graphQLRequest
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+			return
+		}
+		if len(req.Query) > maxGraphQLQueryBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "query too large"})
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			OperationName:  req.OperationName,
+			VariableValues: req.Variables,
+			Context:        context.WithValue(c.Request.Context(), graphQLCallerKey{}, c),
+		})
+		c.JSON(http.StatusOK, result)
+	}, nil
+}
+
+// graphQLSchema builds the schema, resolving fields from the services of
+// the server
+func (s *Server) graphQLSchema() (graphql.Schema, error) {
+	label := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Label",
+		Fields: graphql.Fields{
+			"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	resources := func(name string) *graphql.Object {
+		return graphql.NewObject(graphql.ObjectConfig{
+			Name: name,
+			Fields: graphql.Fields{
+				"cpu":    &graphql.Field{Type: graphql.Float},
+				"memory": &graphql.Field{Type: graphql.Float},
+				"disk":   &graphql.Field{Type: graphql.Float},
+				"gpu":    &graphql.Field{Type: graphql.Float},
+			},
+		})
+	}
+
+	node := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"address":  &graphql.Field{Type: graphql.String},
+			"status":   &graphql.Field{Type: graphql.String},
+			"lastSeen": &graphql.Field{Type: graphql.DateTime},
+			"capacity": &graphql.Field{Type: resources("NodeCapacity")},
+			"usage":    &graphql.Field{Type: resources("NodeUsage")},
+			"clockSkewMs": &graphql.Field{Type: graphql.Float, Resolve: resolve(func(n *scheduler.NodeInfo) interface{} {
+				return float64(n.ClockSkew) / float64(time.Millisecond)
+			})},
+			"labels": &graphql.Field{Type: graphql.NewList(label), Resolve: resolve(func(n *scheduler.NodeInfo) interface{} {
+				return labels(n.Metadata)
+			})},
+			// models lists the names the caller knows the node's models by
+			"models": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				n, _ := p.Source.(*scheduler.NodeInfo)
+				if n == nil {
+					return nil, nil
+				}
+				tenant, ok := s.callerTenant(graphQLCaller(p))
+				if !ok {
+					return n.Models, nil
+				}
+				var names []string
+				for _, registered := range n.Models {
+					if name, visible := s.tenants.ModelView(tenant.ID, registered); visible {
+						names = append(names, name)
+					}
+				}
+				return names, nil
+			}},
+		},
+	})
+
+	replica := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Replica",
+		Fields: graphql.Fields{
+			"nodeId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node": &graphql.Field{Type: node, Resolve: resolve(func(r graphQLReplica) interface{} {
+				if r.node == nil {
+					return nil
+				}
+				return r.node
+			})},
+			// status is the status of the node, or missing once it left
+			"status": &graphql.Field{Type: graphql.String, Resolve: resolve(func(r graphQLReplica) interface{} {
+				if r.node == nil {
+					return "missing"
+				}
+				return string(r.node.Status)
+			})},
+		},
+	})
+
+	model := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Model",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolve(func(m graphQLModel) interface{} {
+				return m.name
+			})},
+			"size": &graphql.Field{Type: graphql.Float, Resolve: resolve(func(m graphQLModel) interface{} {
+				return m.info.Size
+			})},
+			"checksum": &graphql.Field{Type: graphql.String, Resolve: resolve(func(m graphQLModel) interface{} {
+				return m.info.Checksum
+			})},
+			"accessCount": &graphql.Field{Type: graphql.Float, Resolve: resolve(func(m graphQLModel) interface{} {
+				return m.info.AccessCount
+			})},
+			"lastAccessed": &graphql.Field{Type: graphql.DateTime, Resolve: resolve(func(m graphQLModel) interface{} {
+				return m.info.LastAccessed
+			})},
+			"metadata": &graphql.Field{Type: graphql.NewList(label), Resolve: resolve(func(m graphQLModel) interface{} {
+				return labels(m.info.Metadata)
+			})},
+			"replicas": &graphql.Field{Type: graphql.NewList(replica), Resolve: resolve(func(m graphQLModel) interface{} {
+				nodes := s.scheduler.GetNodes()
+				replicas := make([]graphQLReplica, len(m.info.Locations))
+				for i, id := range m.info.Locations {
+					replicas[i] = graphQLReplica{NodeID: id, node: nodes[id]}
+				}
+				return replicas
+			})},
+		},
+	})
+
+	job := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Job",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"kind":      &graphql.Field{Type: graphql.String},
+			"status":    &graphql.Field{Type: graphql.String},
+			"done":      &graphql.Field{Type: graphql.Float},
+			"total":     &graphql.Field{Type: graphql.Float},
+			"message":   &graphql.Field{Type: graphql.String},
+			"error":     &graphql.Field{Type: graphql.String},
+			"createdAt": &graphql.Field{Type: graphql.DateTime},
+			"updatedAt": &graphql.Field{Type: graphql.DateTime},
+			"finishedAt": &graphql.Field{Type: graphql.DateTime, Resolve: resolve(func(j jobs.Job) interface{} {
+				if j.FinishedAt.IsZero() {
+					return nil
+				}
+				return j.FinishedAt
+			})},
+		},
+	})
+
+	objective := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Objective",
+		Fields: graphql.Fields{
+			"name":                 &graphql.Field{Type: graphql.String},
+			"indicator":            &graphql.Field{Type: graphql.String},
+			"target":               &graphql.Field{Type: graphql.Float},
+			"sli":                  &graphql.Field{Type: graphql.Float},
+			"errorBudgetRemaining": &graphql.Field{Type: graphql.Float},
+			"fastBurn":             &graphql.Field{Type: graphql.Boolean},
+		},
+	})
+
+	metrics := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Metrics",
+		Fields: graphql.Fields{
+			"nodesTotal":        &graphql.Field{Type: graphql.Int},
+			"nodesOnline":       &graphql.Field{Type: graphql.Int},
+			"modelsTotal":       &graphql.Field{Type: graphql.Int},
+			"totalRequests":     &graphql.Field{Type: graphql.Float},
+			"completedRequests": &graphql.Field{Type: graphql.Float},
+			"failedRequests":    &graphql.Field{Type: graphql.Float},
+			"queuedRequests":    &graphql.Field{Type: graphql.Float},
+			"workersActive":     &graphql.Field{Type: graphql.Int},
+			"averageLatencyMs": &graphql.Field{Type: graphql.Float, Resolve: resolve(func(st *scheduler.Stats) interface{} {
+				return float64(st.AverageLatency) / float64(time.Millisecond)
+			})},
+			"objectives": &graphql.Field{Type: graphql.NewList(objective), Resolve: func(graphql.ResolveParams) (interface{}, error) {
+				if s.slo == nil {
+					return nil, nil
+				}
+				type view struct {
+					Name, Indicator                   string
+					Target, SLI, ErrorBudgetRemaining float64
+					FastBurn                          bool
+				}
+				var views []view
+				for _, status := range s.slo.Status() {
+					views = append(views, view{status.Name, status.Indicator, status.Target, status.SLI, status.ErrorBudgetRemaining, status.FastBurn})
+				}
+				return views, nil
+			}},
+		},
+	})
+
+	alert := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Alert",
+		Fields: graphql.Fields{
+			"source":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"kind":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"severity": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"message":  &graphql.Field{Type: graphql.String},
+			"since":    &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"nodes": &graphql.Field{
+				Type: graphql.NewList(node),
+				Args: graphql.FieldConfigArgument{"status": &graphql.ArgumentConfig{Type: graphql.String}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					status, _ := p.Args["status"].(string)
+					nodes := []*scheduler.NodeInfo{}
+					for _, n := range s.scheduler.GetNodes() {
+						if status == "" || string(n.Status) == status {
+							nodes = append(nodes, n)
+						}
+					}
+					sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+					return nodes, nil
+				},
+			},
+			"node": &graphql.Field{
+				Type: node,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if n, ok := s.scheduler.GetNodes()[p.Args["id"].(string)]; ok {
+						return n, nil
+					}
+					return nil, nil
+				},
+			},
+			"models": &graphql.Field{
+				Type: graphql.NewList(model),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					visible := s.visibleModels(graphQLCaller(p))
+					models := make([]graphQLModel, 0, len(visible))
+					for name, info := range visible {
+						models = append(models, graphQLModel{name: name, info: info})
+					}
+					sort.Slice(models, func(i, j int) bool { return models[i].name < models[j].name })
+					return models, nil
+				},
+			},
+			"model": &graphql.Field{
+				Type: model,
+				Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name := p.Args["name"].(string)
+					if _, info, exists := s.resolveModel(graphQLCaller(p), name); exists {
+						return graphQLModel{name: name, info: info}, nil
+					}
+					return nil, nil
+				},
+			},
+			"jobs": &graphql.Field{
+				Type: graphql.NewList(job),
+				Args: graphql.FieldConfigArgument{"status": &graphql.ArgumentConfig{Type: graphql.String}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if s.jobs == nil {
+						return []jobs.Job{}, nil
+					}
+					tenant := ""
+					if t, ok := s.callerTenant(graphQLCaller(p)); ok {
+						tenant = t.ID
+					}
+					status, _ := p.Args["status"].(string)
+					list := []jobs.Job{}
+					for _, j := range s.jobs.List(tenant) {
+						if status == "" || j.Status == status {
+							list = append(list, j)
+						}
+					}
+					return list, nil
+				},
+			},
+			"metrics": &graphql.Field{
+				Type: metrics,
+				Resolve: func(graphql.ResolveParams) (interface{}, error) {
+					return s.scheduler.GetStats(), nil
+				},
+			},
+			"alerts": &graphql.Field{
+				Type: graphql.NewList(alert),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.graphQLAlerts(graphQLCaller(p)), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// graphQLAlerts returns the objectives burning their error budget and, to
+// admins, the leak alerts of the watchdog
+func (s *Server) graphQLAlerts(c *gin.Context) []graphQLAlert {
+	alerts := []graphQLAlert{}
+	if s.slo != nil {
+		for _, status := range s.slo.Status() {
+			switch {
+			case status.FastBurn:
+				alerts = append(alerts, graphQLAlert{Source: "slo", Kind: "fast_burn", Severity: "critical",
+					Message: status.Name + " is burning its error budget fast", Since: status.EvaluatedAt})
+			case status.ErrorBudgetRemaining < 0:
+				alerts = append(alerts, graphQLAlert{Source: "slo", Kind: "budget_exhausted", Severity: "warning",
+					Message: status.Name + " has exhausted its error budget", Since: status.EvaluatedAt})
+			}
+		}
+	}
+
+	roles, _ := c.Get("roles")
+	userRoles, _ := roles.([]string)
+	isAdmin := false
+	for _, role := range userRoles {
+		isAdmin = isAdmin || role == "admin"
+	}
+	if s.watchdog != nil && isAdmin {
+		for _, alert := range s.watchdog.Status().Alerts {
+			alerts = append(alerts, graphQLAlert{Source: "watchdog", Kind: alert.Kind, Severity: "warning",
+				Message: alert.Message, Since: alert.Since})
+		}
+	}
+	return alerts
+}
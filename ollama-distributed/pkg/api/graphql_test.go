@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQL(t *testing.T) {
+	s, router := newTenantServer(t)
+	s.jobs = jobs.NewManager(0)
+	s.slo = observability.NewSLOMonitor(&observability.SLOConfig{Objectives: []observability.SLOObjective{
+		{Name: "availability", Indicator: "availability", Target: 0.99},
+	}}, nil)
+	for i := 0; i < 10; i++ {
+		s.slo.Record(observability.InferenceObservation{RequestType: "generate", Failed: i%2 == 0})
+	}
+	handler, err := s.GraphQLHandler()
+	require.NoError(t, err)
+	router.POST("/api/v1/graphql", handler)
+	router.GET("/api/v1/graphql", handler)
+
+	job := s.jobs.Start(context.Background(), "ingest", "acme", func(context.Context, *jobs.Progress) (interface{}, error) {
+		return nil, nil
+	})
+	s.jobs.Start(context.Background(), "ingest", "globex", func(context.Context, *jobs.Progress) (interface{}, error) {
+		return nil, nil
+	})
+
+	query := func(key, q string) map[string]interface{} {
+		body, err := json.Marshal(map[string]string{"query": q})
+		require.NoError(t, err)
+		rec := tenantRequest(router, key, http.MethodPost, "/api/v1/graphql", string(body))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+
+	// One request gathers the models with their replicas, the jobs and the
+	// alerts, with only the selected fields
+	result := query("acme-key", `{
+		models { name replicas { nodeId status } }
+		jobs { id kind }
+		alerts { source kind severity }
+	}`)
+	require.Nil(t, result["errors"])
+	assert.Equal(t, map[string]interface{}{
+		"models": []interface{}{
+			map[string]interface{}{"name": "custom", "replicas": []interface{}{map[string]interface{}{"nodeId": "node-1", "status": "missing"}}},
+			map[string]interface{}{"name": "llama3", "replicas": []interface{}{map[string]interface{}{"nodeId": "node-1", "status": "missing"}}},
+		},
+		"jobs":   []interface{}{map[string]interface{}{"id": job.ID, "kind": "ingest"}},
+		"alerts": []interface{}{map[string]interface{}{"source": "slo", "kind": "fast_burn", "severity": "critical"}},
+	}, result["data"])
+
+	result = query("acme-key", `{ model(name: "mistral") { name } metrics { modelsTotal objectives { name sli } } }`)
+	require.Nil(t, result["errors"])
+	data := result["data"].(map[string]interface{})
+	assert.Nil(t, data["model"], "other tenants' and unshared models are hidden")
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "availability", "sli": 0.5}},
+		data["metrics"].(map[string]interface{})["objectives"])
+
+	// The schema is read-only
+	result = query("acme-key", `mutation { deleteModel(name: "llama3") }`)
+	assert.NotEmpty(t, result["errors"])
+
+	rec := tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/graphql?query=%7Bnodes%7Bid%7D%7D", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"nodes": []}}`, rec.Body.String())
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/graphql", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
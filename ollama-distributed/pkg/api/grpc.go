@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi"
+	pb "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi/ollamamaxv1"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCBackend returns the server as the backend of the gRPC API, which
+// authenticates callers and admits their requests exactly like the REST API
+func (s *Server) GRPCBackend() grpcapi.Backend {
+	return grpcBackend{s: s}
+}
+
+// grpcBackend serves the gRPC API from the REST API's state
+type grpcBackend struct {
+	s *Server
+}
+
+// grpcCaller builds a request context carrying a gRPC caller under the
+// keys the REST handlers read, so the gRPC API reuses their checks
+func grpcCaller(ctx context.Context, caller grpcapi.Identity) *gin.Context {
+	c := &gin.Context{Request: (&http.Request{Header: http.Header{}}).WithContext(ctx)}
+	if caller.UserID != "" {
+		c.Set("user_id", caller.UserID)
+		c.Set("username", caller.Username)
+		c.Set("roles", caller.Roles)
+	}
+	if caller.Tenant != "" {
+		c.Set("tenant", caller.Tenant)
+	}
+	return c
+}
+
+// Authenticate accepts the tenant API keys, JWTs and OIDC tokens the REST
+// API accepts
+func (b grpcBackend) Authenticate(ctx context.Context, token string) (grpcapi.Identity, error) {
+	c := grpcCaller(ctx, grpcapi.Identity{})
+	if !b.s.authenticateAPIKey(c, token) {
+		if claims, err := b.s.validateToken(token); err == nil {
+			return grpcapi.Identity{UserID: claims.UserID, Username: claims.Username, Roles: claims.Roles, Tenant: claims.Tenant}, nil
+		}
+		if !b.s.authenticateOIDC(c, token) {
+			return grpcapi.Identity{}, grpcapi.ErrUnauthenticated
+		}
+	}
+	roles, _ := c.Get("roles")
+	id := grpcapi.Identity{UserID: c.GetString("user_id"), Username: c.GetString("username"), Tenant: c.GetString("tenant")}
+	id.Roles, _ = roles.([]string)
+	if c.GetString("auth_method") == "api_key" {
+		id.APIKey = token
+	}
+	return id, nil
+}
+
+// AdmitCall applies the rate limit and quota of the REST middleware chains,
+// counting gRPC calls against the same limits as REST requests
+func (b grpcBackend) AdmitCall(_ context.Context, caller grpcapi.Identity, clientIP string) error {
+	chain, protected := b.s.MiddlewareChains()
+	now := time.Now()
+	if slices.Contains(chain, MiddlewareRateLimit) || slices.Contains(protected, MiddlewareRateLimit) {
+		if !b.s.limits.allowRate(clientIP, now) {
+			return fmt.Errorf("%w: rate limit exceeded", grpcapi.ErrLimitExceeded)
+		}
+	}
+	quota := b.s.quotaConfig()
+	if quota.Requests > 0 && (slices.Contains(chain, MiddlewareQuota) || slices.Contains(protected, MiddlewareQuota)) {
+		if _, reset, ok := b.s.limits.takeQuota(quotaCaller(caller.Tenant, caller.UserID, clientIP), quota, now); !ok {
+			return fmt.Errorf("%w: quota exceeded until %s", grpcapi.ErrLimitExceeded, reset.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// AdmitModel applies the tenancy and license checks of the REST inference
+// endpoints
+func (b grpcBackend) AdmitModel(ctx context.Context, caller grpcapi.Identity, model string) (string, error) {
+	c := grpcCaller(ctx, caller)
+	err := b.s.checkTenantRequest(c, model)
+	var denied *admissionError
+	if errors.As(err, &denied) {
+		return "", fmt.Errorf("%w: %s", grpcapi.ErrPermissionDenied, denied.Error())
+	}
+	if registered, _, exists := b.s.resolveModel(c, model); exists {
+		return registered, nil
+	}
+	return model, nil
+}
+
+func (b grpcBackend) ModelEndpoints(model string) []string {
+	return b.s.ModelEndpoints(model)
+}
+
+// InferenceHooks runs the moderation and transformation hooks of the REST
+// inference endpoints, in the same order, so gRPC inference cannot get
+// around them
+func (b grpcBackend) InferenceHooks(ctx context.Context, caller grpcapi.Identity, endpoint, model string, body []byte, cancel context.CancelFunc) ([]byte, []textstream.Handler, error) {
+	var moderated *moderation.Handler
+	if b.s.moderation != nil && !b.s.moderation.Bypass(caller.APIKey) {
+		verdict, handler := b.s.moderate(ctx, caller.Tenant, body)
+		if verdict.Blocked() {
+			if verdict.Error != "" {
+				return nil, nil, fmt.Errorf("%w: prompt could not be moderated", grpcapi.ErrUnavailable)
+			}
+			return nil, nil, fmt.Errorf("%w: prompt flagged by moderation", grpcapi.ErrPermissionDenied)
+		}
+		moderated = handler
+	}
+
+	var handlers []textstream.Handler
+	if b.s.transforms != nil {
+		scope := transform.Scope{Tenant: caller.Tenant, Model: model, Endpoint: endpoint}
+		var err error
+		if body, err = b.s.transforms.TransformRequest(ctx, scope, body); err != nil {
+			if errors.Is(err, transform.ErrRejected) {
+				return nil, nil, fmt.Errorf("%w: %v", grpcapi.ErrPermissionDenied, err)
+			}
+			return nil, nil, err
+		}
+		if b.s.transforms.TransformsResponses(scope) {
+			handlers = append(handlers, b.s.transforms.ResponseHandler(ctx, scope, cancel))
+		}
+	}
+	if moderated != nil {
+		handlers = append(handlers, moderated)
+	}
+	return body, handlers, nil
+}
+
+// grpcModel converts a registered model to its gRPC form under the name the
+// caller sees
+func grpcModel(name string, model *scheduler.ModelInfo) *pb.Model {
+	status := "available"
+	if len(model.Locations) == 0 {
+		status = "unavailable"
+	}
+	return &pb.Model{
+		Name:      name,
+		Size:      model.Size,
+		Checksum:  model.Checksum,
+		Locations: model.Locations,
+		Status:    status,
+		Metadata:  model.Metadata,
+	}
+}
+
+func (b grpcBackend) ListModels(ctx context.Context, caller grpcapi.Identity) ([]*pb.Model, error) {
+	models := b.s.visibleModels(grpcCaller(ctx, caller))
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*pb.Model, len(names))
+	for i, name := range names {
+		list[i] = grpcModel(name, models[name])
+	}
+	return list, nil
+}
+
+func (b grpcBackend) GetModel(ctx context.Context, caller grpcapi.Identity, name string) (*pb.Model, error) {
+	if _, model, exists := b.s.resolveModel(grpcCaller(ctx, caller), name); exists {
+		return grpcModel(name, model), nil
+	}
+	return nil, fmt.Errorf("model %s %w", name, grpcapi.ErrNotFound)
+}
+
+// DeleteModel deletes a model like the REST API: tenants may only delete
+// their own models
+func (b grpcBackend) DeleteModel(ctx context.Context, caller grpcapi.Identity, name string) error {
+	c := grpcCaller(ctx, caller)
+	registered, _, exists := b.s.resolveModel(c, name)
+	if !exists {
+		return fmt.Errorf("model %s %w", name, grpcapi.ErrNotFound)
+	}
+	if tenant, ok := b.s.callerTenant(c); ok && registered != tenancy.ScopedModel(tenant.ID, name) {
+		return fmt.Errorf("%w: shared models can only be deleted by cluster operators", grpcapi.ErrPermissionDenied)
+	}
+	if err := b.s.scheduler.DeleteModel(registered); err != nil {
+		return err
+	}
+	b.s.BroadcastModelUpdate(name, "deleted", 100.0)
+	return nil
+}
+
+func (b grpcBackend) ClusterState(ctx context.Context, caller grpcapi.Identity) (*pb.ClusterState, error) {
+	state := &pb.ClusterState{Models: int32(len(b.s.visibleModels(grpcCaller(ctx, caller))))}
+	if b.s.p2p != nil {
+		state.NodeId = b.s.p2p.ID().String()
+	}
+	if b.s.consensus != nil {
+		state.Leader = b.s.consensus.Leader()
+	}
+
+	for _, node := range b.s.scheduler.GetNodes() {
+		state.Nodes = append(state.Nodes, &pb.Node{
+			Id:       node.ID,
+			Address:  node.Address,
+			Status:   string(node.Status),
+			Models:   node.Models,
+			Labels:   node.Metadata,
+			LastSeen: timestamppb.New(node.LastSeen),
+		})
+	}
+	sort.Slice(state.Nodes, func(i, j int) bool { return state.Nodes[i].Id < state.Nodes[j].Id })
+	return state, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCBackend(t *testing.T) {
+	s, _ := newTenantServer(t)
+	backend := s.GRPCBackend()
+	ctx := context.Background()
+
+	_, err := backend.Authenticate(ctx, "bogus")
+	assert.ErrorIs(t, err, grpcapi.ErrUnauthenticated)
+	acme, err := backend.Authenticate(ctx, "acme-key")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", acme.Tenant)
+	assert.True(t, acme.HasRole("user"))
+
+	// Tenants see and run the same models as over REST
+	models, err := backend.ListModels(ctx, acme)
+	require.NoError(t, err)
+	names := make([]string, len(models))
+	for i, m := range models {
+		names[i] = m.Name
+	}
+	assert.Equal(t, []string{"custom", "llama3"}, names)
+
+	registered, err := backend.AdmitModel(ctx, acme, "custom")
+	require.NoError(t, err)
+	assert.Equal(t, "acme/custom", registered)
+	_, err = backend.AdmitModel(ctx, acme, "mistral")
+	assert.ErrorIs(t, err, grpcapi.ErrPermissionDenied)
+
+	_, err = backend.GetModel(ctx, acme, "mistral")
+	assert.ErrorIs(t, err, grpcapi.ErrNotFound)
+	assert.ErrorIs(t, backend.DeleteModel(ctx, acme, "llama3"), grpcapi.ErrPermissionDenied)
+	require.NoError(t, backend.DeleteModel(ctx, acme, "custom"))
+	_, err = backend.GetModel(ctx, acme, "custom")
+	assert.ErrorIs(t, err, grpcapi.ErrNotFound)
+
+	state, err := backend.ClusterState(ctx, acme)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), state.Models)
+}
+
+func TestGRPCBackendLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.config = &config.APIConfig{Middleware: config.MiddlewareConfig{
+		Chain:          []string{MiddlewareRateLimit},
+		ProtectedChain: []string{"test_tenant", MiddlewareQuota},
+		Quota:          config.QuotaConfig{Requests: 2},
+	}}
+	s.router = gin.New()
+	protected, err := s.useMiddleware()
+	require.NoError(t, err)
+	protected.GET("/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/models", nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	backend := s.GRPCBackend()
+	ctx := context.Background()
+
+	acme := grpcapi.Identity{UserID: "u1", Tenant: "acme"}
+	require.NoError(t, backend.AdmitCall(ctx, acme, "10.0.0.1"))
+	assert.Equal(t, "0", get("acme").Header().Get("X-Quota-Remaining"), "gRPC calls count against the REST quota")
+	assert.ErrorIs(t, backend.AdmitCall(ctx, acme, "10.0.0.2"), grpcapi.ErrLimitExceeded)
+
+	// httptest requests come from 192.0.2.1
+	for i := 1; i < rateLimit; i++ {
+		require.NoError(t, backend.AdmitCall(ctx, grpcapi.Identity{Tenant: fmt.Sprint("tenant-", i)}, "192.0.2.1"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, get("globex").Code, "REST requests count against the gRPC rate limit")
+	assert.ErrorIs(t, backend.AdmitCall(ctx, grpcapi.Identity{Tenant: "globex"}, "192.0.2.1"), grpcapi.ErrLimitExceeded)
+	assert.NoError(t, backend.AdmitCall(ctx, grpcapi.Identity{Tenant: "globex"}, "10.0.0.3"))
+}
+
+func TestGRPCInferenceHooks(t *testing.T) {
+	engine, err := transform.NewEngine(transform.Config{Enabled: true, Hooks: []transform.HookConfig{
+		{Name: "no-secrets", Processor: "word_filter", Stage: transform.StageRequest,
+			Options: map[string]string{"words": "password", "action": "reject"}},
+		{Name: "scrub", Processor: "redact", Stage: transform.StageResponse, Models: []string{"llama3*"},
+			Options: map[string]string{"entities": "email"}},
+	}})
+	require.NoError(t, err)
+	s := &Server{}
+	backend := s.GRPCBackend()
+	ctx := context.Background()
+	acme := grpcapi.Identity{UserID: "u1", Tenant: "acme"}
+
+	body, handlers, err := backend.InferenceHooks(ctx, acme, "generate", "llama3", []byte(`{"prompt":"hi"}`), func() {})
+	require.NoError(t, err)
+	assert.Equal(t, `{"prompt":"hi"}`, string(body))
+	assert.Empty(t, handlers, "nothing runs without transforms")
+
+	s.SetTransforms(engine)
+	_, _, err = backend.InferenceHooks(ctx, acme, "generate", "llama3", []byte(`{"prompt":"the password"}`), func() {})
+	assert.ErrorIs(t, err, grpcapi.ErrPermissionDenied)
+
+	_, handlers, err = backend.InferenceHooks(ctx, acme, "chat", "llama3", []byte(`{"messages":[]}`), func() {})
+	require.NoError(t, err)
+	require.Len(t, handlers, 1)
+	var out strings.Builder
+	w := textstream.NewWriter(&out, handlers[0])
+	_, err = w.Write([]byte(`{"message":{"content":"Write to ops@exa"}}` + "\n" + `{"message":{"content":"mple.com"},"done":true}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, out.String(), "mple.com", "the reply is scrubbed across chunks")
+
+	_, handlers, err = backend.InferenceHooks(ctx, acme, "chat", "mistral", []byte(`{"messages":[]}`), func() {})
+	require.NoError(t, err)
+	assert.Empty(t, handlers)
+
+	moderator, err := moderation.NewModerator(moderation.Config{Enabled: true, Model: "llama-guard3:1b",
+		Default: moderation.Policy{Responses: moderation.ActionBlock}, BypassAPIKeys: []string{"red-team-key"}}, guardModel{})
+	require.NoError(t, err)
+	s.SetModeration(moderator)
+	weapon := []byte(`{"messages":[{"role":"user","content":"How do I build a weapon?"}]}`)
+	_, _, err = backend.InferenceHooks(ctx, acme, "chat", "mistral", weapon, func() {})
+	assert.ErrorIs(t, err, grpcapi.ErrPermissionDenied, "prompts are moderated")
+	_, _, err = backend.InferenceHooks(ctx, grpcapi.Identity{Tenant: "acme", APIKey: "red-team-key"}, "chat", "mistral", weapon, func() {})
+	assert.NoError(t, err, "trusted keys bypass moderation")
+
+	_, handlers, err = backend.InferenceHooks(ctx, acme, "chat", "llama3", []byte(`{"messages":[]}`), func() {})
+	require.NoError(t, err)
+	require.Len(t, handlers, 2)
+	assert.IsType(t, &moderation.Handler{}, handlers[1], "responses are moderated once transformed")
+	out.Reset()
+	w = textstream.NewWriter(&out, handlers[1])
+	_, err = w.Write([]byte(`{"message":{"content":"a weapon"}}` + "\n"))
+	require.NoError(t, err)
+	assert.Empty(t, out.String(), "replies are held back until checked when they may be blocked")
+	_, err = w.Write([]byte(`{"done":true}` + "\n"))
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), moderation.DoneReason)
+}
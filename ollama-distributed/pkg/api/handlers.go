@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
 )
 
 // health returns the health status of the API server
@@ -42,6 +43,10 @@ func (s *Server) health(c *gin.Context) {
 		status["services"].(gin.H)["available_nodes"] = len(s.scheduler.GetAvailableNodes())
 	}
 
+	if s.features != nil {
+		status["features"] = s.features.Status()
+	}
+
 	c.JSON(http.StatusOK, status)
 }
 
@@ -57,8 +62,18 @@ func (s *Server) version(c *gin.Context) {
 
 // getModels returns all available models
 func (s *Server) getModels(c *gin.Context) {
-	models := s.scheduler.GetAllModels()
-	c.JSON(http.StatusOK, gin.H{"models": models})
+	models := s.visibleModels(c)
+	if s.licenses == nil {
+		c.JSON(http.StatusOK, gin.H{"models": models})
+		return
+	}
+
+	licensed := make(map[string]licensedModel, len(models))
+	for name, model := range models {
+		registered, _, _ := s.resolveModel(c, name)
+		licensed[name] = s.withLicense(registered, model)
+	}
+	c.JSON(http.StatusOK, gin.H{"models": licensed})
 }
 
 // getModel returns a specific model
@@ -72,8 +87,8 @@ func (s *Server) getModel(c *gin.Context) {
 	}
 
 	// Get specific model from scheduler
-	if model, exists := s.scheduler.GetModel(modelName); exists {
-		c.JSON(http.StatusOK, gin.H{"model": model})
+	if registered, model, exists := s.resolveModel(c, modelName); exists {
+		c.JSON(http.StatusOK, gin.H{"model": s.withLicense(registered, model)})
 	} else {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
 	}
@@ -89,6 +104,12 @@ func (s *Server) downloadModel(c *gin.Context) {
 		return
 	}
 
+	// Models rejected by their scan are quarantined, not distributed
+	if report, rejected := s.scanRejected(modelName); rejected {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Model was rejected by its scan", "report": report})
+		return
+	}
+
 	// Get available nodes from scheduler
 	nodes := s.scheduler.GetNodes()
 	if len(nodes) == 0 {
@@ -132,14 +153,18 @@ func (s *Server) deleteModel(c *gin.Context) {
 	}
 
 	// Get model info from scheduler
-	model, exists := s.scheduler.GetModel(modelName)
+	registered, model, exists := s.resolveModel(c, modelName)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
 		return
 	}
+	if tenant, ok := s.callerTenant(c); ok && registered != tenancy.ScopedModel(tenant.ID, modelName) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Shared models can only be deleted by cluster operators"})
+		return
+	}
 
 	// Delete model from scheduler registry
-	err := s.scheduler.DeleteModel(modelName)
+	err := s.scheduler.DeleteModel(registered)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -179,7 +204,7 @@ func (s *Server) getNode(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"node": node})
+	c.JSON(http.StatusOK, gin.H{"node": node, "flaps": s.scheduler.NodeFlaps(nodeID)})
 }
 
 // drainNode marks a node for draining (no new tasks)
@@ -200,7 +225,7 @@ func (s *Server) drainNode(c *gin.Context) {
 		return
 	}
 
-	// For now, just return success - actual draining logic would be implemented in scheduler
+	s.scheduler.DrainNode(nodeID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Node marked for draining",
 		"node_id": nodeID,
@@ -226,7 +251,7 @@ func (s *Server) undrainNode(c *gin.Context) {
 		return
 	}
 
-	// For now, just return success - actual undraining logic would be implemented in scheduler
+	s.scheduler.UndrainNode(nodeID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Node drain status removed",
 		"node_id": nodeID,
@@ -270,6 +295,7 @@ func (s *Server) getMetrics(c *gin.Context) {
 type GenerateRequest struct {
 	Model  string `json:"model" binding:"required"`
 	Prompt string `json:"prompt" binding:"required"`
+	System string `json:"system,omitempty"`
 	Stream bool   `json:"stream,omitempty"`
 }
 
@@ -302,6 +328,16 @@ func (s *Server) generate(c *gin.Context) {
 		return
 	}
 
+	if !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+	model := s.selectModel(c, req.Model)
+	req.Model = model.Model
+
+	if s.forwardToPeer(c, "generate", req.Model, req) {
+		return
+	}
+
 	// TODO: Check if model exists when model management is implemented
 	// For now, accept any model name for testing
 
@@ -312,6 +348,9 @@ func (s *Server) generate(c *gin.Context) {
 		"response": "This is a placeholder response. Distributed inference not yet implemented.",
 		"done":     true,
 	}
+	if model.Fallback() {
+		response["fallback"] = model
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -341,6 +380,16 @@ func (s *Server) chat(c *gin.Context) {
 		}
 	}
 
+	if !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+	model := s.selectModel(c, req.Model)
+	req.Model = model.Model
+
+	if s.forwardToPeer(c, "chat", req.Model, req) {
+		return
+	}
+
 	// TODO: Check if model exists when model management is implemented
 	// For now, accept any model name for testing
 
@@ -366,6 +415,9 @@ func (s *Server) chat(c *gin.Context) {
 		},
 		"done": true,
 	}
+	if model.Fallback() {
+		response["fallback"] = model
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -385,6 +437,14 @@ func (s *Server) embeddings(c *gin.Context) {
 		return
 	}
 
+	if !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+
+	if s.forwardToPeer(c, "embeddings", req.Model, req) {
+		return
+	}
+
 	// For now, return mock embeddings - actual implementation would use the distributed scheduler
 	embeddings := [][]float64{
 		make([]float64, 384), // Common embedding dimension
@@ -417,6 +477,13 @@ func (s *Server) getClusterStatus(c *gin.Context) {
 		"status":    "active", // TODO: Get actual status
 	}
 
+	// Clock skew of the other nodes relative to this one, and of this
+	// node relative to NTP time
+	response["clock_skew"] = s.scheduler.ClockSkews()
+	if s.timeSync != nil {
+		response["clock"] = s.timeSync.Status()
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -432,32 +499,6 @@ func (s *Server) getClusterLeader(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"leader": leader})
 }
 
-// joinCluster handles cluster join requests
-func (s *Server) joinCluster(c *gin.Context) {
-	var req struct {
-		NodeID  string `json:"node_id" binding:"required"`
-		Address string `json:"address" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Validate node ID for security
-	if err := security.ValidateNodeID(req.NodeID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
-		return
-	}
-
-	// TODO: Implement actual cluster join logic through consensus engine
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Node join request accepted",
-		"node_id": req.NodeID,
-		"status":  "joining",
-	})
-}
-
 // leaveCluster handles cluster leave requests
 func (s *Server) leaveCluster(c *gin.Context) {
 	var req struct {
@@ -582,11 +623,47 @@ func (s *Server) getStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// getSLOStatus returns the error budget and burn rates of every service
+// level objective
+func (s *Server) getSLOStatus(c *gin.Context) {
+	if s.slo == nil {
+		c.JSON(http.StatusOK, gin.H{"objectives": []interface{}{}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"objectives": s.slo.Status()})
+}
+
+// getWatchdogStatus returns the runtime samples and active leak alerts of
+// this node
+func (s *Server) getWatchdogStatus(c *gin.Context) {
+	if s.watchdog == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "watchdog not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, s.watchdog.Status())
+}
+
+// captureWatchdogDiagnostics writes a diagnostics bundle on demand
+func (s *Server) captureWatchdogDiagnostics(c *gin.Context) {
+	if s.watchdog == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "watchdog not enabled"})
+		return
+	}
+	path, err := s.watchdog.CaptureDiagnostics(s.watchdog.Status().Alerts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bundle": path})
+}
+
 // getConfig returns system configuration (sanitized)
 func (s *Server) getConfig(c *gin.Context) {
 	config := map[string]interface{}{
 		"api": map[string]interface{}{
-			"listen": s.config.Listen,
+			"listen":           s.config.Listen,
+			"listen_addresses": s.config.AllListenAddresses(),
 		},
 		"features": map[string]interface{}{
 			"websocket":      true,
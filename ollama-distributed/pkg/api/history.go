@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+)
+
+// SetHistory exposes the metrics history kept by recorder on
+// /api/v1/metrics/history
+func (s *Server) SetHistory(recorder *history.Recorder) {
+	s.history = recorder
+}
+
+// getHistoryMetrics lists the metrics with a history and the tiers they are
+// kept at
+func (s *Server) getHistoryMetrics(c *gin.Context) {
+	if s.history == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metrics history not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metrics": s.history.Metrics(),
+		"tiers":   s.history.Tiers(),
+	})
+}
+
+// getMetricHistory returns the series of a metric over a time range. since
+// and until are RFC 3339 times or durations before now, e.g. since=720h
+// for the last 30 days; key selects one series and max_points bounds the
+// points per series.
+func (s *Server) getMetricHistory(c *gin.Context) {
+	if s.history == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "metrics history not enabled"})
+		return
+	}
+
+	query := history.Query{Metric: c.Param("metric"), Key: c.Query("key")}
+	var err error
+	if query.Since, err = parseEventTime(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since: %v", err)})
+		return
+	}
+	if query.Until, err = parseEventTime(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid until: %v", err)})
+		return
+	}
+	if maxPoints := c.Query("max_points"); maxPoints != "" {
+		if query.MaxPoints, err = strconv.Atoi(maxPoints); err != nil || query.MaxPoints <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_points must be a positive number"})
+			return
+		}
+	}
+
+	result, err := s.history.Query(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/metrics/history", s.getHistoryMetrics)
+	router.GET("/api/v1/metrics/history/:metric", s.getMetricHistory)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do("/api/v1/metrics/history").Code)
+
+	recorder := history.NewRecorder(history.Config{Enabled: true}, history.NewMemoryStore())
+	recorder.AddSource(func() []history.Sample {
+		return []history.Sample{
+			{Metric: "node_cpu_percent", Key: "node-a", Value: 40},
+			{Metric: "node_cpu_percent", Key: "node-b", Value: 60},
+		}
+	})
+	require.NoError(t, recorder.Sample(context.Background()))
+	s.SetHistory(recorder)
+
+	rec := do("/api/v1/metrics/history")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Metrics []string       `json:"metrics"`
+		Tiers   []history.Tier `json:"tiers"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Equal(t, []string{"node_cpu_percent"}, listed.Metrics)
+	assert.Equal(t, history.DefaultTiers, listed.Tiers)
+
+	rec = do("/api/v1/metrics/history/node_cpu_percent?since=1h&key=node-b")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result history.Result
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Len(t, result.Series, 1)
+	assert.Equal(t, "node-b", result.Series[0].Key)
+	require.Len(t, result.Series[0].Points, 1)
+	assert.Equal(t, 60.0, result.Series[0].Points[0].Mean)
+
+	assert.Equal(t, http.StatusBadRequest, do("/api/v1/metrics/history/node_cpu_percent?since=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/api/v1/metrics/history/node_cpu_percent?max_points=0").Code)
+	assert.Equal(t, http.StatusBadRequest, do("/api/v1/metrics/history/node_cpu_percent?since=1h&until=2h").Code)
+}
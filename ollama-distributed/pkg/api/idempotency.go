@@ -0,0 +1,24 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/idempotency"
+)
+
+// SetIdempotency keeps the responses of POSTs made with an
+// Idempotency-Key, replaying them to retries
+func (s *Server) SetIdempotency(cache *idempotency.Cache) {
+	s.idempotency = cache
+}
+
+// IdempotencyMiddleware runs each POST with an Idempotency-Key once, when
+// enabled. It runs after authentication, scoping keys to the caller.
+func (s *Server) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.idempotency == nil {
+			c.Next()
+			return
+		}
+		s.idempotency.Handle(c)
+	}
+}
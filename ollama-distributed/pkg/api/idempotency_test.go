@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeysArePerCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.config = &config.APIConfig{Middleware: config.MiddlewareConfig{
+		ProtectedChain: []string{"test_tenant", MiddlewareIdempotency},
+	}}
+	s.router = gin.New()
+	protected, err := s.useMiddleware()
+	require.NoError(t, err)
+	submitted := 0
+	protected.POST("/jobs", func(c *gin.Context) {
+		submitted++
+		c.JSON(http.StatusAccepted, gin.H{"job": submitted})
+	})
+
+	submit := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", nil)
+		req.Header.Set("X-Tenant", tenant)
+		req.Header.Set(idempotency.KeyHeader, "retry-me")
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a cache the key is ignored
+	submit("acme")
+	assert.Equal(t, 1, submitted)
+
+	cache, err := idempotency.NewCache(idempotency.Config{Enabled: true})
+	require.NoError(t, err)
+	s.SetIdempotency(cache)
+	first := submit("acme")
+	retry := submit("acme")
+	assert.Equal(t, 2, submitted)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+
+	submit("globex")
+	assert.Equal(t, 3, submitted, "keys are scoped to the caller")
+}
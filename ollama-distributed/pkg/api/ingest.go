@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ingest"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+)
+
+// maxIngestDocuments bounds the documents of one ingestion request
+const maxIngestDocuments = 1000
+
+// SetIngestion enables document ingestion on /api/v1/ingest, run as jobs
+// of manager. Job updates are broadcast as job_progress WebSocket messages.
+func (s *Server) SetIngestion(service *ingest.Service, manager *jobs.Manager) {
+	s.ingest = service
+	s.jobs = manager
+	manager.Subscribe(func(job jobs.Job) {
+		if s.wsHub != nil {
+			s.wsHub.Broadcast("job_progress", job)
+		}
+	})
+}
+
+// ingestDocuments starts ingesting documents given as text or base64
+// content into a collection, returning the job tracking it
+func (s *Server) ingestDocuments(c *gin.Context) {
+	if s.ingest == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ingestion not enabled"})
+		return
+	}
+
+	var req struct {
+		Collection string                 `json:"collection" binding:"required"`
+		Model      string                 `json:"model"`
+		Documents  []ingest.Document      `json:"documents" binding:"required"`
+		Chunking   *ingest.ChunkingConfig `json:"chunking"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.startIngestion(c, ingest.Request{
+		Collection: req.Collection,
+		Model:      req.Model,
+		Documents:  req.Documents,
+		Chunking:   req.Chunking,
+	})
+}
+
+// uploadDocuments starts ingesting the files of a multipart form, each a
+// document named after the file, into the collection of the form
+func (s *Server) uploadDocuments(c *gin.Context) {
+	if s.ingest == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ingestion not enabled"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := ingest.Request{Collection: c.PostForm("collection"), Model: c.PostForm("model")}
+	if strategy, size, overlap := c.PostForm("chunk_strategy"), c.PostForm("chunk_size"), c.PostForm("chunk_overlap"); strategy != "" || size != "" || overlap != "" {
+		chunking := &ingest.ChunkingConfig{Strategy: strategy}
+		for _, field := range []struct {
+			value string
+			dest  *int
+		}{{size, &chunking.Size}, {overlap, &chunking.Overlap}} {
+			if field.value == "" {
+				continue
+			}
+			if *field.dest, err = strconv.Atoi(field.value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk size or overlap"})
+				return
+			}
+		}
+		req.Chunking = chunking
+	}
+
+	for _, header := range form.File["file"] {
+		if header.Size > s.ingest.MaxDocumentBytes() {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "document " + header.Filename + " is too large"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The extension tells the type of files uploaded without one
+		contentType := header.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = mime.TypeByExtension(filepath.Ext(header.Filename))
+		}
+		req.Documents = append(req.Documents, ingest.Document{
+			ID:          header.Filename,
+			Content:     content,
+			ContentType: contentType,
+			Metadata:    map[string]string{"filename": header.Filename},
+		})
+	}
+	s.startIngestion(c, req)
+}
+
+// startIngestion admits and validates an ingestion request and runs it as
+// a job in the caller's collection
+func (s *Server) startIngestion(c *gin.Context, req ingest.Request) {
+	if len(req.Documents) > maxIngestDocuments {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "too many documents in one request"})
+		return
+	}
+	if req.Model == "" {
+		req.Model = s.embeddingModel
+	}
+	if err := s.ingest.Validate(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+	if registered, _, exists := s.resolveModel(c, req.Model); exists {
+		req.Model = registered
+	}
+
+	collection := req.Collection
+	req.Collection = s.vectorCollection(c, collection)
+	job := s.jobs.Start(context.Background(), "ingest", c.GetString("tenant"), func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		result, err := s.ingest.Ingest(ctx, req, p)
+		if err != nil {
+			return nil, err
+		}
+		result.Collection = collection
+		return result, nil
+	})
+	c.JSON(http.StatusAccepted, job)
+}
+
+// callerJob returns a job visible to the caller, writing the error
+// response when there is none
+func (s *Server) callerJob(c *gin.Context) (jobs.Job, bool) {
+	if s.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "jobs not enabled"})
+		return jobs.Job{}, false
+	}
+	job, err := s.jobs.Get(c.Param("id"))
+	if tenant, ok := s.callerTenant(c); ok && err == nil && job.Tenant != tenant.ID {
+		err = fmt.Errorf("%w: %s", jobs.ErrNotFound, job.ID)
+	}
+	if errors.Is(err, jobs.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// getJobs lists the caller's jobs, newest first
+func (s *Server) getJobs(c *gin.Context) {
+	if s.jobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "jobs not enabled"})
+		return
+	}
+
+	tenant := ""
+	if t, ok := s.callerTenant(c); ok {
+		tenant = t.ID
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": s.jobs.List(tenant)})
+}
+
+// getJob returns the progress of a job
+func (s *Server) getJob(c *gin.Context) {
+	if job, ok := s.callerJob(c); ok {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// cancelJob cancels a running job
+func (s *Server) cancelJob(c *gin.Context) {
+	job, ok := s.callerJob(c)
+	if !ok {
+		return
+	}
+	if job.Finished() {
+		c.JSON(http.StatusConflict, gin.H{"error": "job already finished"})
+		return
+	}
+	if err := s.jobs.Cancel(job.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job canceled"})
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ingest"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestionAPI(t *testing.T) {
+	s, router := newTenantServer(t)
+	index, err := vectorindex.OpenHNSW(vectorindex.Config{})
+	require.NoError(t, err)
+	embedder := &lengthEmbedder{}
+	s.SetVectorIndex(index, embedder, "llama3")
+	service, err := ingest.NewService(ingest.Config{Chunking: ingest.ChunkingConfig{Strategy: ingest.ChunkSentence, Size: 30}}, index, embedder)
+	require.NoError(t, err)
+	s.SetIngestion(service, jobs.NewManager(0))
+	router.POST("/api/v1/ingest", s.ingestDocuments)
+	router.POST("/api/v1/ingest/upload", s.uploadDocuments)
+	router.GET("/api/v1/jobs", s.getJobs)
+	router.GET("/api/v1/jobs/:id", s.getJob)
+	router.DELETE("/api/v1/jobs/:id", s.cancelJob)
+
+	waitJob := func(key, id string) jobs.Job {
+		var job jobs.Job
+		require.Eventually(t, func() bool {
+			rec := tenantRequest(router, key, http.MethodGet, "/api/v1/jobs/"+id, "")
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+			return job.Finished()
+		}, time.Second, time.Millisecond)
+		return job
+	}
+
+	rec := tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/ingest",
+		`{"collection": "handbook", "documents": [{"id": "policy", "text": "Vacation is 25 days. Sick leave is unlimited."}]}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var job jobs.Job
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, "acme", job.Tenant)
+	job = waitJob("acme-key", job.ID)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	assert.Equal(t, int64(2), job.Done)
+	assert.Equal(t, map[string]interface{}{"collection": "handbook", "documents": float64(1), "chunks": float64(2)}, job.Result)
+
+	matches, err := index.Query(context.Background(), "acme/handbook", []float32{20, 10}, 10, nil)
+	require.NoError(t, err)
+	assert.Len(t, matches, 2)
+
+	// Files are uploaded as multipart form data
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("collection", "handbook"))
+	require.NoError(t, form.WriteField("model", "custom"))
+	require.NoError(t, form.WriteField("chunk_strategy", "fixed"))
+	part, err := form.CreateFormFile("file", "faq.html")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("<p>Ask HR.</p>"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ingest/upload", &body)
+	req.Header.Set("Authorization", "Bearer globex-key")
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	job = waitJob("globex-key", job.ID)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	matches, err = index.Query(context.Background(), "globex/handbook", []float32{10, 10}, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "Ask HR.", matches[0].Text)
+	assert.Equal(t, "faq.html", matches[0].Metadata["filename"])
+	assert.Equal(t, "globex/custom", embedder.models[len(embedder.models)-1])
+
+	// Tenants only see their own jobs
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/jobs/"+job.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/jobs", "")
+	var list struct {
+		Jobs []jobs.Job `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list.Jobs, 1)
+	rec = tenantRequest(router, "acme-key", http.MethodDelete, "/api/v1/jobs/"+list.Jobs[0].ID, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/ingest",
+		`{"collection": "handbook", "model": "mistral", "documents": [{"id": "x", "text": "y"}]}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/ingest",
+		`{"collection": "handbook", "documents": [{"id": "x"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
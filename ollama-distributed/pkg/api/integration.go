@@ -8,11 +8,14 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
@@ -58,6 +61,11 @@ func NewIntegrationLayer(scheduler *scheduler.Engine, localAddr string, modelDis
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(localURL)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		logging.PropagateRequestID(r)
+	}
 
 	// Customize proxy to handle errors and add distributed headers
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
@@ -143,6 +151,9 @@ func (il *IntegrationLayer) handleGenerate(c *gin.Context) {
 		Type:       "generate",
 		Priority:   1,
 		Timeout:    30 * time.Second,
+		Tenant:     c.GetString("tenant"),
+		Metadata:   map[string]string{"request_id": logging.RequestIDFromContext(c.Request.Context())},
+		Strategy:   c.GetHeader(capture.StrategyHeader),
 		ResponseCh: make(chan *scheduler.Response, 1),
 		Payload: map[string]interface{}{
 			"prompt":  req.Prompt,
@@ -170,8 +181,7 @@ func (il *IntegrationLayer) handleGenerate(c *gin.Context) {
 	select {
 	case response := <-distribReq.ResponseCh:
 		if response.Success {
-			c.Header("X-Ollama-Node", response.NodeID)
-			c.JSON(http.StatusOK, response.Data)
+			writeScheduledResponse(c, response)
 		} else {
 			if il.fallbackMode {
 				c.Header("X-Ollama-Fallback", "execution-error")
@@ -211,6 +221,9 @@ func (il *IntegrationLayer) handleChat(c *gin.Context) {
 		Type:       "chat",
 		Priority:   1,
 		Timeout:    30 * time.Second,
+		Tenant:     c.GetString("tenant"),
+		Metadata:   map[string]string{"request_id": logging.RequestIDFromContext(c.Request.Context())},
+		Strategy:   c.GetHeader(capture.StrategyHeader),
 		ResponseCh: make(chan *scheduler.Response, 1),
 		Payload: map[string]interface{}{
 			"messages": req.Messages,
@@ -237,8 +250,7 @@ func (il *IntegrationLayer) handleChat(c *gin.Context) {
 	select {
 	case response := <-distribReq.ResponseCh:
 		if response.Success {
-			c.Header("X-Ollama-Node", response.NodeID)
-			c.JSON(http.StatusOK, response.Data)
+			writeScheduledResponse(c, response)
 		} else {
 			if il.fallbackMode {
 				c.Header("X-Ollama-Fallback", "execution-error")
@@ -256,6 +268,21 @@ func (il *IntegrationLayer) handleChat(c *gin.Context) {
 	}
 }
 
+// writeScheduledResponse writes the response of a generate or chat request
+// the scheduler served. When the context the request asked for had to be
+// negotiated, the context it got is returned in a header and the
+// negotiation, with any warning, in the body.
+func writeScheduledResponse(c *gin.Context, response *scheduler.Response) {
+	c.Header("X-Ollama-Node", response.NodeID)
+	if negotiation := response.Context; negotiation != nil {
+		c.Header("X-Ollama-Context-Length", strconv.Itoa(negotiation.Granted))
+		if data, ok := response.Data.(map[string]interface{}); ok {
+			data["context_negotiation"] = negotiation
+		}
+	}
+	c.JSON(http.StatusOK, response.Data)
+}
+
 // Embed endpoint with distributed routing
 func (il *IntegrationLayer) handleEmbed(c *gin.Context) {
 	var req types.EmbedRequest
@@ -276,6 +303,9 @@ func (il *IntegrationLayer) handleEmbed(c *gin.Context) {
 		Type:       "embed",
 		Priority:   1,
 		Timeout:    30 * time.Second,
+		Tenant:     c.GetString("tenant"),
+		Metadata:   map[string]string{"request_id": logging.RequestIDFromContext(c.Request.Context())},
+		Strategy:   c.GetHeader(capture.StrategyHeader),
 		ResponseCh: make(chan *scheduler.Response, 1),
 		Payload: map[string]interface{}{
 			"input":    req.Input,
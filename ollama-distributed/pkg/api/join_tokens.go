@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+)
+
+// SetJoinTokens requires a join token of nodes joining the cluster and
+// lets admins create and revoke tokens on /api/v1/cluster/tokens
+func (s *Server) SetJoinTokens(tokens *jointoken.Manager) {
+	s.joinTokens = tokens
+}
+
+// createJoinTokenRequest sets the TTL, e.g. "2h", and a description of the
+// node the token is for
+type createJoinTokenRequest struct {
+	TTL         string `json:"ttl"`
+	Description string `json:"description"`
+}
+
+// joinTokenError answers a failed token operation, pointing followers at
+// the leader
+func (s *Server) joinTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jointoken.ErrNotLeader):
+		leader := ""
+		if s.consensus != nil {
+			leader = s.consensus.Leader()
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "leader": leader})
+	case errors.Is(err, jointoken.ErrInvalid), errors.Is(err, jointoken.ErrExpired),
+		errors.Is(err, jointoken.ErrUsed), errors.Is(err, jointoken.ErrWrongCluster):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, jointoken.ErrInvalidTTL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// getClusterCA returns the cluster CA bundle, which joining nodes check
+// against the fingerprints in their token before trusting it
+func (s *Server) getClusterCA(c *gin.Context) {
+	if s.joinTokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "join tokens not enabled"})
+		return
+	}
+	c.Data(http.StatusOK, "application/x-pem-file", s.joinTokens.CA())
+}
+
+// createJoinToken issues a single-use join token
+func (s *Server) createJoinToken(c *gin.Context) {
+	if s.joinTokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "join tokens not enabled"})
+		return
+	}
+
+	var req createJoinTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid TTL: %v", err)})
+			return
+		}
+	}
+
+	token, issued, err := s.joinTokens.Create(ttl, req.Description)
+	if err != nil {
+		s.joinTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":           token.String(),
+		"id":              issued.ID,
+		"description":     issued.Description,
+		"expires_at":      issued.ExpiresAt,
+		"ca_fingerprints": token.CAFingerprints,
+	})
+}
+
+// getJoinTokens lists the join tokens, spent ones included
+func (s *Server) getJoinTokens(c *gin.Context) {
+	if s.joinTokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "join tokens not enabled"})
+		return
+	}
+
+	tokens, err := s.joinTokens.Tokens()
+	if err != nil {
+		s.joinTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// revokeJoinToken deletes a join token, withdrawing the admission of the
+// node that spent it
+func (s *Server) revokeJoinToken(c *gin.Context) {
+	if s.joinTokens == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "join tokens not enabled"})
+		return
+	}
+
+	found, err := s.joinTokens.Revoke(c.Param("id"))
+	if err != nil {
+		s.joinTokenError(c, err)
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "join token not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "revoked": true})
+}
+
+// joinCluster admits a node. With join tokens enabled the node presents a
+// token, which authenticates the request and is spent, so each one admits
+// a single node, whose peer ID the P2P layer then lets connect. Otherwise
+// the request must be authenticated like the other API requests.
+func (s *Server) joinCluster(c *gin.Context) {
+	var req struct {
+		NodeID  string `json:"node_id" binding:"required"`
+		Address string `json:"address" binding:"required"`
+		Token   string `json:"token"`
+		// Compat is the version and protocols of the joining node
+		Compat *compat.Info `json:"compat"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate node ID for security
+	if err := security.ValidateNodeID(req.NodeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
+		return
+	}
+	if s.joinTokens == nil {
+		if !s.authenticate(c) {
+			return
+		}
+	} else if req.Token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "join token required"})
+		return
+	}
+
+	// Incompatible nodes are refused before their token is spent
+	warning, ok := s.checkJoinCompatibility(c, req.NodeID, req.Compat)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"message": "Node join request accepted",
+		"node_id": req.NodeID,
+		"status":  "joining",
+	}
+	if s.joinTokens != nil {
+		issued, err := s.joinTokens.Redeem(req.Token, req.NodeID)
+		if err != nil {
+			s.joinTokenError(c, err)
+			return
+		}
+		response["token_id"] = issued.ID
+	}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClusterCA(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cluster CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestJoinCluster(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/cluster/ca", s.getClusterCA)
+	router.POST("/api/v1/cluster/join", s.joinCluster)
+	router.POST("/api/v1/cluster/tokens", s.createJoinToken)
+	router.GET("/api/v1/cluster/tokens", s.getJoinTokens)
+	router.DELETE("/api/v1/cluster/tokens/:id", s.revokeJoinToken)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+	join := func(token string) *httptest.ResponseRecorder {
+		return post("/api/v1/cluster/join", `{"node_id":"node-7","address":"10.0.1.7:4001","token":"`+token+`"}`)
+	}
+
+	// Without join tokens, joining takes the credentials of any API request
+	registry, err := tenancy.NewRegistry(tenancy.Config{Enabled: true, Tenants: []tenancy.Tenant{{ID: "ops", APIKeys: []string{"ops-key"}}}})
+	require.NoError(t, err)
+	s.SetTenancy(registry)
+	assert.Equal(t, http.StatusUnauthorized, join("").Code)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cluster/join", strings.NewReader(`{"node_id":"node-6","address":"10.0.1.6:4001"}`))
+	req.Header.Set("Authorization", "Bearer ops-key")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	ca := testClusterCA(t)
+	tokens, err := jointoken.NewManager(jointoken.Config{Enabled: true}, ca, nil)
+	require.NoError(t, err)
+	s.SetJoinTokens(tokens)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/ca", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ca, w.Body.Bytes())
+
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/cluster/tokens", `{"ttl":"90d"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/cluster/tokens", `{"ttl":"9000h"}`).Code)
+	w = post("/api/v1/cluster/tokens", `{"ttl":"1h","description":"gpu node"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Token string `json:"token"`
+		ID    string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	assert.Equal(t, http.StatusUnauthorized, join("").Code, "open joining is gone")
+	assert.Equal(t, http.StatusForbidden, join("ojt1.00.00.00").Code)
+	require.Equal(t, http.StatusOK, join(created.Token).Code)
+	assert.Equal(t, http.StatusForbidden, join(created.Token).Code, "tokens are single-use")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/tokens", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"used_by":"node-7"`)
+	assert.NotContains(t, w.Body.String(), "secret_hash")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/cluster/tokens/"+created.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/cluster/tokens/"+created.ID, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/licensing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+)
+
+// SetLicensing enables model license tracking: the models API reports the
+// license of each model and inference requests are gated on the registry's
+// policy rules
+func (s *Server) SetLicensing(registry *licensing.Registry) {
+	s.licenses = registry
+}
+
+// licensedModel is a model as the models API returns it, with its license
+type licensedModel struct {
+	*scheduler.ModelInfo
+	License *licensing.ModelLicense `json:"license,omitempty"`
+}
+
+// withLicense attaches the license of a registered model when licensing is
+// enabled
+func (s *Server) withLicense(registered string, model *scheduler.ModelInfo) licensedModel {
+	view := licensedModel{ModelInfo: model}
+	if s.licenses != nil {
+		license := s.licenses.Lookup(registered, model.Metadata)
+		view.License = &license
+	}
+	return view
+}
+
+// checkLicense checks the license policy for a tenant's request for a
+// model. Callers without a tenant are checked against the rules that apply
+// to every caller.
+func (s *Server) checkLicense(c *gin.Context, tenant tenancy.Tenant, model string) error {
+	if s.licenses == nil {
+		return nil
+	}
+
+	registered, info, exists := s.resolveModel(c, model)
+	var manifest map[string]string
+	if exists {
+		manifest = info.Metadata
+	} else {
+		registered = model
+	}
+
+	err := s.licenses.Check(licensing.Caller{Tenant: tenant.ID, Groups: tenant.Groups}, registered, manifest)
+	var violation *licensing.Violation
+	if errors.As(err, &violation) {
+		return &admissionError{status: http.StatusForbidden, body: gin.H{
+			"error":   violation.Error(),
+			"rule":    violation.Rule,
+			"license": violation.License,
+		}}
+	}
+	return nil
+}
+
+// getModelLicense returns the license of a model
+func (s *Server) getModelLicense(c *gin.Context) {
+	if s.licenses == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "licensing not enabled"})
+		return
+	}
+
+	registered, model, exists := s.resolveModel(c, c.Param("name"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	}
+	c.JSON(http.StatusOK, s.licenses.Lookup(registered, model.Metadata))
+}
+
+// setModelLicense records the license of a model, overriding its manifest
+func (s *Server) setModelLicense(c *gin.Context) {
+	if s.licenses == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "licensing not enabled"})
+		return
+	}
+
+	var license licensing.ModelLicense
+	if err := c.ShouldBindJSON(&license); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if license.License.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "license id is required"})
+		return
+	}
+
+	license.Model = c.Param("name")
+	s.licenses.Set(license)
+	c.JSON(http.StatusOK, s.licenses.Lookup(license.Model, nil))
+}
+
+// deleteModelLicense removes the recorded license of a model, which falls
+// back to its manifest
+func (s *Server) deleteModelLicense(c *gin.Context) {
+	if s.licenses == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "licensing not enabled"})
+		return
+	}
+
+	if !s.licenses.Delete(c.Param("name")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no license recorded for model"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "License deleted"})
+}
+
+// getLicensing returns the recorded model licenses and the policy rules
+func (s *Server) getLicensing(c *gin.Context) {
+	if s.licenses == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "licensing not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"models": s.licenses.List(), "rules": s.licenses.Rules()})
+}
+
+// setLicenseRules replaces the license policy rules
+func (s *Server) setLicenseRules(c *gin.Context) {
+	if s.licenses == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "licensing not enabled"})
+		return
+	}
+
+	var rules []licensing.Rule
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.licenses.SetRules(rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": s.licenses.Rules()})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/licensing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLicensedServer(t *testing.T) *gin.Engine {
+	s, router := newTenantServer(t)
+
+	registry, err := tenancy.NewRegistry(tenancy.Config{Enabled: true, Tenants: []tenancy.Tenant{
+		{ID: "acme", APIKeys: []string{"acme-key"}, Groups: []string{"commercial"}},
+		{ID: "globex", APIKeys: []string{"globex-key"}},
+	}})
+	require.NoError(t, err)
+	s.SetTenancy(registry)
+
+	model, ok := s.scheduler.GetModel("mistral")
+	require.True(t, ok)
+	model.Metadata[licensing.MetadataLicense] = "mnpl-0.1"
+
+	licenses, err := licensing.NewRegistry(licensing.Config{
+		Enabled: true,
+		Models:  []licensing.ModelLicense{{Model: "llama3", License: licensing.License{ID: "llama3"}}},
+		Rules:   []licensing.Rule{{Name: "commercial", Groups: []string{"commercial"}, DenyNonCommercial: true}},
+	})
+	require.NoError(t, err)
+	s.SetLicensing(licenses)
+
+	router.GET("/api/v1/models/:name/license", s.getModelLicense)
+	return router
+}
+
+func TestLicensePolicyGatesRequests(t *testing.T) {
+	router := newLicensedServer(t)
+
+	// The non-commercial model is denied to the commercial group only
+	rec := tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/generate?model=mistral", "")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	var denied struct {
+		Rule    string            `json:"rule"`
+		License licensing.License `json:"license"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &denied))
+	assert.Equal(t, "commercial", denied.Rule)
+	assert.True(t, denied.License.NonCommercial)
+
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/generate?model=llama3", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = tenantRequest(router, "globex-key", http.MethodPost, "/api/v1/generate?model=mistral", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGetModelLicense(t *testing.T) {
+	router := newLicensedServer(t)
+
+	rec := tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/models/mistral/license", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var license licensing.ModelLicense
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &license))
+	assert.Equal(t, "mnpl-0.1", license.License.ID)
+	assert.Equal(t, licensing.SourceManifest, license.Source)
+
+	rec = tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/models/llama3/license", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &license))
+	assert.Equal(t, licensing.SourceUser, license.Source)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// defaultDrainTimeout bounds the pre-stop wait when none is configured
+const defaultDrainTimeout = 25 * time.Second
+
+// SetLifecycleConfig sets the readiness and pre-stop settings used when the
+// node runs under Kubernetes
+func (s *Server) SetLifecycleConfig(cfg config.KubernetesConfig) {
+	s.lifecycle = cfg
+}
+
+// InFlightMiddleware counts the API requests being served, so that the
+// pre-stop hook can wait for them to finish
+func (s *Server) InFlightMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// livez reports that the process is serving requests
+func (s *Server) livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// readyz reports whether the node should receive traffic: it is not
+// draining and its state machine has caught up with the Raft log
+func (s *Server) readyz(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	if s.consensus != nil {
+		if !s.consensus.IsStarted() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": "consensus not started"})
+			return
+		}
+		catchUp := s.consensus.CatchUp()
+		if err := catchUp.Ready(s.lifecycle.ReadyMaxLag); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": err.Error(), "consensus": catchUp})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "consensus": catchUp})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// preStop drains the node before Kubernetes stops it: /readyz starts failing
+// so Services stop routing to the pod, the scheduler stops placing requests
+// on it, in-flight requests get up to the drain timeout to finish, and a
+// leader hands leadership over. It is served to the node itself, or to
+// callers presenting the configured pre-stop token.
+func (s *Server) preStop(c *gin.Context) {
+	if !s.preStopAllowed(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "pre-stop hook is only served locally or with a valid token"})
+		return
+	}
+
+	start := time.Now()
+	s.draining.Store(true)
+	if s.scheduler != nil && s.p2p != nil {
+		s.scheduler.DrainNode(s.p2p.ID().String())
+	}
+
+	timeout := s.lifecycle.DrainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	drained := s.waitForInFlight(c.Request, timeout)
+
+	result := gin.H{
+		"drained":   drained,
+		"in_flight": s.inFlight.Load(),
+	}
+	if s.consensus != nil && s.consensus.IsLeader() {
+		if err := s.consensus.TransferLeadership(); err != nil {
+			result["leadership_error"] = err.Error()
+		} else {
+			result["leadership_transferred"] = true
+		}
+	}
+	result["waited"] = time.Since(start).String()
+
+	c.JSON(http.StatusOK, result)
+}
+
+// waitForInFlight waits until no API request is in flight, the timeout
+// passes or the caller goes away, and reports whether none is left
+func (s *Server) waitForInFlight(req *http.Request, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.inFlight.Load() > 0 {
+		select {
+		case <-deadline.C:
+			return false
+		case <-req.Context().Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// preStopAllowed accepts loopback callers, such as an exec hook running curl
+// in the pod, and callers with the configured token
+func (s *Server) preStopAllowed(req *http.Request) bool {
+	if token := s.lifecycle.PreStopToken; token != "" {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("X-PreStop-Token")), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLifecycleRouter(s *Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(s.InFlightMiddleware())
+	router.GET("/readyz", s.readyz)
+	router.POST("/lifecycle/prestop", s.preStop)
+	router.GET("/api/v1/slow", func(c *gin.Context) {
+		time.Sleep(200 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestPreStopDrainsBeforeReturning(t *testing.T) {
+	s := &Server{}
+	s.SetLifecycleConfig(config.KubernetesConfig{DrainTimeout: 5 * time.Second})
+	router := newLifecycleRouter(s)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	slowDone := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/slow", nil))
+		close(slowDone)
+	}()
+	require.Eventually(t, func() bool { return s.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodPost, "/lifecycle/prestop", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	select {
+	case <-slowDone:
+	default:
+		t.Fatal("pre-stop returned before the in-flight request finished")
+	}
+
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, true, result["drained"])
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestPreStopRejectsRemoteCallersWithoutToken(t *testing.T) {
+	s := &Server{}
+	s.SetLifecycleConfig(config.KubernetesConfig{PreStopToken: "s3cret"})
+	router := newLifecycleRouter(s)
+
+	req := httptest.NewRequest(http.MethodPost, "/lifecycle/prestop", nil)
+	req.RemoteAddr = "10.0.0.7:40000"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.False(t, s.draining.Load())
+
+	req = httptest.NewRequest(http.MethodPost, "/lifecycle/prestop", nil)
+	req.RemoteAddr = "10.0.0.7:40000"
+	req.Header.Set("X-PreStop-Token", "s3cret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, s.draining.Load())
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fallbacks used when the corresponding APIConfig field is zero
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+)
+
+// BodyLimitMiddleware enforces the configured maximum request body size and
+// the time a client has to send it. Oversized bodies get 413 and bodies that
+// are not received within BodyReadTimeout get 408, so slow or malicious
+// clients cannot hold handlers or exhaust memory with huge prompts. The
+// body is not buffered: reads past the limit or the deadline fail, and the
+// response of the handler that read it is replaced by the 413 or 408.
+func (s *Server) BodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		maxBody := s.config.MaxBodySize
+		if maxBody > 0 && c.Request.ContentLength > maxBody {
+			abortTooLarge(c, maxBody)
+			return
+		}
+
+		body := &limitedBody{ReadCloser: c.Request.Body}
+		if maxBody > 0 {
+			body.ReadCloser = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+		}
+		if s.config.BodyReadTimeout > 0 {
+			rc := http.NewResponseController(c.Writer)
+			if rc.SetReadDeadline(time.Now().Add(s.config.BodyReadTimeout)) == nil {
+				body.clearDeadline = func() { rc.SetReadDeadline(time.Time{}) }
+			}
+		}
+		writer := &bodyLimitWriter{ResponseWriter: c.Writer, body: body}
+		c.Request.Body = body
+		c.Writer = writer
+
+		c.Next()
+
+		body.done()
+		c.Writer = writer.ResponseWriter
+		if err := body.failure(); err != nil && !c.Writer.Written() {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortTooLarge(c, maxBody)
+			} else {
+				abortBodyReadError(c, err)
+			}
+		}
+	}
+}
+
+// limitedBody records why reading a request body failed, and clears the
+// read deadline once the body is read, so it does not cut off the
+// connection while the response is written
+type limitedBody struct {
+	io.ReadCloser
+	clearDeadline func()
+
+	mu   sync.Mutex
+	err  error
+	once sync.Once
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	} else if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, os.ErrDeadlineExceeded) {
+			b.mu.Lock()
+			b.err = err
+			b.mu.Unlock()
+		}
+	}
+	return n, err
+}
+
+// done clears the read deadline
+func (b *limitedBody) done() {
+	if b.clearDeadline != nil {
+		b.once.Do(b.clearDeadline)
+	}
+}
+
+// failure returns the error of a read past the limit or the deadline
+func (b *limitedBody) failure() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// bodyLimitWriter discards the response of a handler once reading the
+// request body failed, so the middleware can answer 413 or 408 instead
+type bodyLimitWriter struct {
+	gin.ResponseWriter
+	body *limitedBody
+}
+
+func (w *bodyLimitWriter) WriteHeader(code int) {
+	if w.body.failure() == nil {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *bodyLimitWriter) WriteHeaderNow() {
+	if w.body.failure() == nil {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bodyLimitWriter) Write(b []byte) (int, error) {
+	if w.body.failure() != nil {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLimitWriter) WriteString(s string) (int, error) {
+	if w.body.failure() != nil {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func abortTooLarge(c *gin.Context, maxBody int64) {
+	c.Header("Connection", "close")
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":         "Request body too large",
+		"max_body_size": maxBody,
+	})
+}
+
+func abortBodyReadError(c *gin.Context, err error) {
+	c.Header("Connection", "close")
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		c.AbortWithStatusJSON(http.StatusRequestTimeout, gin.H{"error": "Timed out reading request body"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+}
+
+// httpServer builds the http.Server with the configured slow-client timeouts
+func (s *Server) httpServer(addr string) *http.Server {
+	readTimeout := s.config.ReadTimeout
+	if readTimeout == 0 {
+		readTimeout = s.config.Timeout
+	}
+	writeTimeout := s.config.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = s.config.Timeout
+	}
+	readHeaderTimeout := s.config.ReadHeaderTimeout
+	if readHeaderTimeout == 0 {
+		readHeaderTimeout = defaultReadHeaderTimeout
+	}
+	idleTimeout := s.config.IdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// connLimitListener caps the total number of open connections and the
+// number of concurrent connections from a single client IP. Connections
+// over the per-client limit are closed immediately; once the total limit
+// is reached Accept blocks until a connection is released.
+type connLimitListener struct {
+	net.Listener
+	slots     chan struct{}
+	perClient int
+
+	mu      sync.Mutex
+	clients map[string]int
+}
+
+func newConnLimitListener(ln net.Listener, maxConns, perClient int) net.Listener {
+	if maxConns <= 0 && perClient <= 0 {
+		return ln
+	}
+	l := &connLimitListener{
+		Listener:  ln,
+		perClient: perClient,
+		clients:   make(map[string]int),
+	}
+	if maxConns > 0 {
+		l.slots = make(chan struct{}, maxConns)
+	}
+	return l
+}
+
+func (l *connLimitListener) Accept() (net.Conn, error) {
+	for {
+		if l.slots != nil {
+			l.slots <- struct{}{}
+		}
+
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.releaseSlot()
+			return nil, err
+		}
+
+		host := clientHost(conn.RemoteAddr())
+		if !l.acquireClient(host) {
+			conn.Close()
+			l.releaseSlot()
+			continue
+		}
+
+		return &limitedConn{Conn: conn, release: func() {
+			l.releaseClient(host)
+			l.releaseSlot()
+		}}, nil
+	}
+}
+
+func (l *connLimitListener) acquireClient(host string) bool {
+	if l.perClient <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.clients[host] >= l.perClient {
+		return false
+	}
+	l.clients[host]++
+	return true
+}
+
+func (l *connLimitListener) releaseClient(host string) {
+	if l.perClient <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients[host]--
+	if l.clients[host] <= 0 {
+		delete(l.clients, host)
+	}
+}
+
+func (l *connLimitListener) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// limitedConn releases its listener slot exactly once on Close
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func clientHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitedRouter(cfg *config.APIConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	s := &Server{config: cfg}
+
+	router := gin.New()
+	router.Use(s.BodyLimitMiddleware())
+	router.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	router := newLimitedRouter(&config.APIConfig{MaxBodySize: 8})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("hello")))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("far too large")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// Unknown length bodies are still capped
+	req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(strings.NewReader("far too large")))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "far too", "the handler's response is replaced")
+
+	// Bodies are read as the handler reads them, not buffered up front
+	read := 0
+	router.POST("/peek", func(c *gin.Context) {
+		b := make([]byte, 4)
+		read, _ = io.ReadFull(c.Request.Body, b)
+		c.Status(http.StatusNoContent)
+	})
+	req = httptest.NewRequest(http.MethodPost, "/peek", io.NopCloser(strings.NewReader("far too large")))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "bodies are only refused when read past the limit")
+	assert.Equal(t, 4, read)
+}
+
+func TestBodyLimitMiddlewareSlowClient(t *testing.T) {
+	srv := httptest.NewServer(newLimitedRouter(&config.APIConfig{
+		MaxBodySize:     1024,
+		BodyReadTimeout: 50 * time.Millisecond,
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Promise a body and never send it
+	_, err = conn.Write([]byte("POST /echo HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\npartial"))
+	require.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	assert.Contains(t, string(buf[:n]), "408")
+}
+
+func TestConnLimitListenerPerClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	limited := newConnLimitListener(ln, 0, 1)
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	serverSide := <-accepted
+
+	// A second concurrent connection from the same IP is dropped
+	second, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	// Once the first is released the client may connect again
+	serverSide.Close()
+	third, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer third.Close()
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("connection was not accepted after release")
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
+)
+
+// SetLoadQueue exposes the model load queue of this node on
+// /api/v1/load-queue, loading models requested on
+// /api/v1/models/:name/load with load
+func (s *Server) SetLoadQueue(queue *loadqueue.Queue, load loadqueue.LoadFunc) {
+	s.loadQueue = queue
+	s.loadModel = load
+}
+
+// LoadModelRequest sets the priority of a model load
+type LoadModelRequest struct {
+	Priority int `json:"priority"`
+}
+
+// getLoadQueue returns the running and queued model loads with their
+// positions
+func (s *Server) getLoadQueue(c *gin.Context) {
+	if s.loadQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "load queue not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loads": s.loadQueue.Entries()})
+}
+
+// getLoadQueuePosition returns the position of a model's load
+func (s *Server) getLoadQueuePosition(c *gin.Context) {
+	if s.loadQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "load queue not enabled"})
+		return
+	}
+
+	entry, ok := s.loadQueue.Position(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model is not queued for loading"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// loadModelHandler queues a model load and waits for it. A client giving up
+// withdraws its request, and the load is dropped if nobody else waits for
+// it.
+func (s *Server) loadModelHandler(c *gin.Context) {
+	if s.loadQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "load queue not enabled"})
+		return
+	}
+
+	var req LoadModelRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	model := c.Param("name")
+	if err := s.loadQueue.Load(c.Request.Context(), model, req.Priority, s.loadModel); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"model": model, "loaded": true})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadQueue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/load-queue", s.getLoadQueue)
+	router.GET("/api/v1/load-queue/:name", s.getLoadQueuePosition)
+	router.POST("/api/v1/models/:name/load", s.loadModelHandler)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/load-queue", "").Code)
+
+	release := make(chan struct{})
+	queue := loadqueue.NewQueue(loadqueue.Config{Enabled: true})
+	s.SetLoadQueue(queue, func(ctx context.Context, model string) error {
+		if model == "broken" {
+			return errors.New("out of memory")
+		}
+		<-release
+		return nil
+	})
+
+	loaded := make(chan *httptest.ResponseRecorder, 1)
+	go func() { loaded <- do(http.MethodPost, "/api/v1/models/llama3/load", `{"priority": 2}`) }()
+	require.Eventually(t, func() bool { return len(queue.Entries()) == 1 }, time.Second, time.Millisecond)
+
+	rec := do(http.MethodGet, "/api/v1/load-queue", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Loads []loadqueue.Entry `json:"loads"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Loads, 1)
+	assert.Equal(t, "llama3", listed.Loads[0].Model)
+	assert.Equal(t, 2, listed.Loads[0].Priority)
+	assert.Equal(t, loadqueue.StateLoading, listed.Loads[0].State)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/load-queue/llama3", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/load-queue/mistral", "").Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-loaded).Code)
+	assert.Equal(t, http.StatusBadGateway, do(http.MethodPost, "/api/v1/models/broken/load", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/llama3/load", "{").Code)
+}
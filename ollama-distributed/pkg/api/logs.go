@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+)
+
+// defaultLogLimit is how many records /api/v1/logs returns without a limit
+const defaultLogLimit = 200
+
+// SetLogBuffer exposes the recent logs of this node on /api/v1/logs
+func (s *Server) SetLogBuffer(ring *logging.RingBuffer) {
+	s.logs = ring
+}
+
+// getLogs returns recent log records of this node, filtered by level,
+// component, request ID and sequence number. With follow=true new records are streamed
+// as newline-delimited JSON until the client disconnects.
+func (s *Server) getLogs(c *gin.Context) {
+	if s.logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log buffer not enabled"})
+		return
+	}
+
+	filter := logging.RecordFilter{
+		MinLevel:  c.Query("level"),
+		Component: c.Query("component"),
+		RequestID: c.Query("request_id"),
+		Limit:     defaultLogLimit,
+	}
+	if since := c.Query("since"); since != "" {
+		seq, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a sequence number"})
+			return
+		}
+		filter.AfterSeq = seq
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative number"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	if c.Query("follow") != "true" {
+		records := s.logs.Query(filter)
+		if records == nil {
+			records = []logging.Record{}
+		}
+		c.JSON(http.StatusOK, gin.H{"records": records})
+		return
+	}
+
+	// Subscribe before reading the backlog so no record is missed between
+	// the two; duplicates are skipped by sequence number
+	tail, cancel := s.logs.Subscribe(logging.RecordFilter{MinLevel: filter.MinLevel, Component: filter.Component, RequestID: filter.RequestID})
+	defer cancel()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	lastSeq := filter.AfterSeq
+	for _, record := range s.logs.Query(filter) {
+		if encoder.Encode(record) != nil {
+			return
+		}
+		lastSeq = record.Seq
+	}
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case record, ok := <-tail:
+			if !ok {
+				return
+			}
+			if record.Seq <= lastSeq {
+				continue
+			}
+			if encoder.Encode(record) != nil {
+				return
+			}
+			lastSeq = record.Seq
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLogsServer(ring *logging.RingBuffer) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.SetLogBuffer(ring)
+
+	router := gin.New()
+	router.GET("/api/v1/logs", s.getLogs)
+	return httptest.NewServer(router)
+}
+
+func TestGetLogsFilters(t *testing.T) {
+	ring := logging.NewRingBuffer(10)
+	ring.Append(logging.Record{Level: "info", Component: "api", Message: "started"})
+	ring.Append(logging.Record{Level: "error", Component: "scheduler", Message: "no nodes"})
+	ring.Append(logging.Record{Level: "warn", Component: "api", Message: "slow"})
+	server := newLogsServer(ring)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/logs?level=warn&component=api")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Records []logging.Record `json:"records"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Records, 1)
+	assert.Equal(t, "slow", body.Records[0].Message)
+
+	resp, err = http.Get(server.URL + "/api/v1/logs?since=abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetLogsFollow(t *testing.T) {
+	ring := logging.NewRingBuffer(10)
+	ring.Append(logging.Record{Level: "info", Message: "backlog"})
+	server := newLogsServer(ring)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/logs?follow=true", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	var record logging.Record
+	require.True(t, lines.Scan())
+	require.NoError(t, json.Unmarshal(lines.Bytes(), &record))
+	assert.Equal(t, "backlog", record.Message)
+
+	ring.Append(logging.Record{Level: "error", Message: "tailed"})
+	require.True(t, lines.Scan())
+	require.NoError(t, json.Unmarshal(lines.Bytes(), &record))
+	assert.Equal(t, "tailed", record.Message)
+	assert.Equal(t, uint64(2), record.Seq)
+}
+
+func TestGetLogsDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/logs", s.getLogs)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/logs", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/maintenance"
+)
+
+// SetMaintenance exposes the maintenance windows of nodes on
+// /api/v1/maintenance/windows
+func (s *Server) SetMaintenance(manager *maintenance.Manager) {
+	s.maintenance = manager
+}
+
+// getMaintenanceWindows returns the windows and the nodes now in one
+func (s *Server) getMaintenanceWindows(c *gin.Context) {
+	if s.maintenance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"windows": s.maintenance.Windows(),
+		"active":  s.maintenance.Active(),
+	})
+}
+
+// setMaintenanceWindow creates or replaces the window named in the path.
+// If it is open, its nodes are drained and tainted at once.
+func (s *Server) setMaintenanceWindow(c *gin.Context) {
+	if s.maintenance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows not enabled"})
+		return
+	}
+
+	var window maintenance.Window
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window.Name = c.Param("name")
+	if err := s.maintenance.SetWindow(window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, window)
+}
+
+// deleteMaintenanceWindow removes a window, returning its nodes to service
+// if it was open
+func (s *Server) deleteMaintenanceWindow(c *gin.Context) {
+	if s.maintenance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows not enabled"})
+		return
+	}
+
+	name := c.Param("name")
+	if !s.maintenance.DeleteWindow(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance window deleted", "name": name})
+}
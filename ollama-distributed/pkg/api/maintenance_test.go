@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/maintenance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maintenanceCluster is a one-node cluster for maintenance tests
+type maintenanceCluster struct {
+	labels  placement.NodeLabels
+	drained bool
+}
+
+func (c *maintenanceCluster) Nodes() []string                    { return []string{"node-1"} }
+func (c *maintenanceCluster) Labels(string) placement.NodeLabels { return c.labels }
+func (c *maintenanceCluster) SetLabels(_ string, labels placement.NodeLabels) error {
+	c.labels = labels
+	return nil
+}
+func (c *maintenanceCluster) DrainNode(string)      { c.drained = true }
+func (c *maintenanceCluster) UndrainNode(string)    { c.drained = false }
+func (c *maintenanceCluster) IsDrained(string) bool { return c.drained }
+
+func TestMaintenanceWindows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cluster := &maintenanceCluster{}
+	manager, err := maintenance.NewManager(maintenance.Config{}, "node-1", cluster)
+	require.NoError(t, err)
+
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/maintenance/windows", s.getMaintenanceWindows)
+	router.PUT("/api/v1/maintenance/windows/:name", s.setMaintenanceWindow)
+	router.DELETE("/api/v1/maintenance/windows/:name", s.deleteMaintenanceWindow)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/maintenance/windows", "").Code)
+	s.SetMaintenance(manager)
+
+	// A window open all day takes the node out of service at once
+	rec := do(http.MethodPut, "/api/v1/maintenance/windows/always",
+		`{"nodes": ["node-1"], "start": "00:00", "end": "00:00"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, cluster.drained)
+	require.Len(t, cluster.labels.Taints, 1)
+	assert.Equal(t, "maintenance=always:NoSchedule", cluster.labels.Taints[0].String())
+
+	rec = do(http.MethodGet, "/api/v1/maintenance/windows", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Windows []maintenance.Window `json:"windows"`
+		Active  map[string]string    `json:"active"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Windows, 1)
+	assert.Equal(t, "always", listed.Windows[0].Name)
+	assert.Equal(t, map[string]string{"node-1": "always"}, listed.Active)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/maintenance/windows/bad",
+		`{"nodes": ["node-1"], "start": "2am", "end": "04:00"}`).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/maintenance/windows/always", "").Code)
+	assert.False(t, cluster.drained)
+	assert.Empty(t, cluster.labels.Taints)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/maintenance/windows/always", "").Code)
+}
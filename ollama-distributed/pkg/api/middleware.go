@@ -0,0 +1,305 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+)
+
+// Names of the built-in middleware
+const (
+	MiddlewareRequestID       = "request_id"
+	MiddlewareLogging         = "logging"
+	MiddlewareInFlight        = "in_flight"
+	MiddlewareCompression     = "compression"
+	MiddlewareBodyLimit       = "body_limit"
+	MiddlewareCORS            = "cors"
+	MiddlewareSecurityHeaders = "security_headers"
+	MiddlewareHeaders         = "headers"
+	MiddlewareRateLimit       = "rate_limit"
+	MiddlewareAuth            = "auth"
+	MiddlewareQuota           = "quota"
+	MiddlewareIdempotency     = "idempotency"
+	MiddlewareCapture         = "capture"
+)
+
+// DefaultMiddlewareChain runs on every request when no chain is configured.
+// Rate limiting comes before anything reads the request body.
+var DefaultMiddlewareChain = []string{
+	MiddlewareRequestID,
+	MiddlewareLogging,
+	MiddlewareInFlight,
+	MiddlewareCORS,
+	MiddlewareSecurityHeaders,
+	MiddlewareHeaders,
+	MiddlewareRateLimit,
+	MiddlewareCompression,
+	MiddlewareBodyLimit,
+}
+
+// DefaultProtectedMiddlewareChain runs on the authenticated routes when no
+// chain is configured
+var DefaultProtectedMiddlewareChain = []string{
+	MiddlewareAuth,
+	MiddlewareQuota,
+	MiddlewareIdempotency,
+	MiddlewareCapture,
+}
+
+// MiddlewareFactory builds a middleware for a server from the options
+// configured under its name. It returns a nil handler to leave the
+// middleware out, e.g. when it is not configured.
+type MiddlewareFactory func(s *Server, options map[string]string) (gin.HandlerFunc, error)
+
+var (
+	registryMu          sync.RWMutex
+	registeredFactories = make(map[string]MiddlewareFactory)
+)
+
+// RegisterMiddleware makes a compiled-in middleware available to the
+// middleware chains under name. It is meant to be called from init and
+// panics if the name is taken.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" || factory == nil {
+		panic("api: RegisterMiddleware without a name or factory")
+	}
+	if _, builtin := builtinMiddleware[name]; builtin {
+		panic("api: RegisterMiddleware of built-in middleware " + name)
+	}
+	if _, dup := registeredFactories[name]; dup {
+		panic("api: RegisterMiddleware called twice for " + name)
+	}
+	registeredFactories[name] = factory
+}
+
+// builtinMiddleware builds the built-in middleware of a server
+var builtinMiddleware = map[string]func(s *Server) gin.HandlerFunc{
+	MiddlewareRequestID:       func(*Server) gin.HandlerFunc { return logging.RequestID() },
+	MiddlewareLogging:         (*Server).LoggingMiddleware,
+	MiddlewareInFlight:        (*Server).InFlightMiddleware,
+	MiddlewareCompression:     (*Server).CompressionMiddleware,
+	MiddlewareBodyLimit:       (*Server).BodyLimitMiddleware,
+	MiddlewareCORS:            (*Server).CORSMiddleware,
+	MiddlewareSecurityHeaders: (*Server).SecurityHeadersMiddleware,
+	MiddlewareHeaders:         (*Server).HeadersMiddleware,
+	MiddlewareRateLimit:       (*Server).RateLimitMiddleware,
+	MiddlewareAuth:            (*Server).AuthMiddleware,
+	MiddlewareQuota:           (*Server).QuotaMiddleware,
+	MiddlewareIdempotency:     (*Server).IdempotencyMiddleware,
+	MiddlewareCapture:         (*Server).CaptureMiddleware,
+}
+
+// middlewareConfig returns the configured middleware arrangement
+func (s *Server) middlewareConfig() config.MiddlewareConfig {
+	if s.config == nil {
+		return config.MiddlewareConfig{}
+	}
+	return s.config.Middleware
+}
+
+// MiddlewareChains returns the names of the middleware run on every
+// request and on the authenticated routes, in order
+func (s *Server) MiddlewareChains() (chain, protected []string) {
+	cfg := s.middlewareConfig()
+	chain, protected = DefaultMiddlewareChain, DefaultProtectedMiddlewareChain
+	if len(cfg.Chain) > 0 {
+		chain = cfg.Chain
+	}
+	if len(cfg.ProtectedChain) > 0 {
+		protected = cfg.ProtectedChain
+	}
+	disabled := func(name string) bool { return slices.Contains(cfg.Disabled, name) }
+	chain = slices.DeleteFunc(slices.Clone(chain), disabled)
+	protected = slices.DeleteFunc(slices.Clone(protected), disabled)
+	return chain, protected
+}
+
+// buildMiddleware returns the handlers of a chain of middleware names
+func (s *Server) buildMiddleware(names []string) ([]gin.HandlerFunc, error) {
+	options := s.middlewareConfig().Options
+	var handlers []gin.HandlerFunc
+	for _, name := range names {
+		var handler gin.HandlerFunc
+		if builtin, ok := builtinMiddleware[name]; ok {
+			handler = builtin(s)
+		} else {
+			registryMu.RLock()
+			factory, ok := registeredFactories[name]
+			registryMu.RUnlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown middleware %q", name)
+			}
+			var err error
+			if handler, err = factory(s, options[name]); err != nil {
+				return nil, fmt.Errorf("middleware %s: %w", name, err)
+			}
+		}
+		if handler != nil {
+			handlers = append(handlers, handler)
+		}
+	}
+	return handlers, nil
+}
+
+// useMiddleware installs the configured chains on the router and returns
+// the group of the authenticated /api/v1 routes
+func (s *Server) useMiddleware() (*gin.RouterGroup, error) {
+	chain, protected := s.MiddlewareChains()
+	if !slices.Contains(protected, MiddlewareAuth) && !slices.Contains(chain, MiddlewareAuth) {
+		slog.Warn("API authentication disabled by the middleware configuration")
+	}
+
+	handlers, err := s.buildMiddleware(chain)
+	if err != nil {
+		return nil, err
+	}
+	s.router.Use(handlers...)
+
+	handlers, err = s.buildMiddleware(protected)
+	if err != nil {
+		return nil, err
+	}
+	group := s.router.Group("/api/v1")
+	group.Use(handlers...)
+	return group, nil
+}
+
+// HeadersMiddleware adds the configured custom headers to every response
+func (s *Server) HeadersMiddleware() gin.HandlerFunc {
+	headers := s.middlewareConfig().Headers
+	if len(headers) == 0 {
+		return nil
+	}
+	return func(c *gin.Context) {
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Next()
+	}
+}
+
+// quotaConfig returns the configured quota with its window defaulted
+func (s *Server) quotaConfig() config.QuotaConfig {
+	quota := s.middlewareConfig().Quota
+	if quota.Window <= 0 {
+		quota.Window = 24 * time.Hour
+	}
+	return quota
+}
+
+// quotaCaller returns the key a caller's requests are counted under by the
+// quota: its tenant, user or client address
+func quotaCaller(tenant, user, clientIP string) string {
+	switch {
+	case tenant != "":
+		return "tenant:" + tenant
+	case user != "":
+		return "user:" + user
+	}
+	return "ip:" + clientIP
+}
+
+// QuotaMiddleware caps the requests of each tenant, user or client
+// address per window, reporting the remaining ones in X-Quota-Remaining.
+// It runs after authentication to know the caller.
+func (s *Server) QuotaMiddleware() gin.HandlerFunc {
+	quota := s.quotaConfig()
+	if quota.Requests <= 0 {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		caller := quotaCaller(c.GetString("tenant"), c.GetString("user_id"), c.ClientIP())
+		remaining, reset, ok := s.limits.takeQuota(caller, quota, time.Now())
+
+		c.Header("X-Quota-Limit", strconv.Itoa(quota.Requests))
+		c.Header("X-Quota-Remaining", strconv.Itoa(remaining))
+		c.Header("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Quota exceeded"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimit is how many requests a client address may make per minute
+const rateLimit = 100
+
+// callLimits counts requests for the rate limit and the quota. The REST
+// middleware and the gRPC API share the server's, so callers cannot get
+// around either by switching between the two.
+type callLimits struct {
+	mu     sync.Mutex
+	recent map[string][]time.Time
+	quota  map[string]*quotaUsage
+}
+
+// quotaUsage is the requests of a caller in its current quota window
+type quotaUsage struct {
+	start time.Time
+	count int
+}
+
+// allowRate reports whether a client address is under the rate limit,
+// counting the request when it is
+func (l *callLimits) allowRate(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.recent == nil {
+		l.recent = make(map[string][]time.Time)
+	}
+
+	// Forget the requests older than a minute
+	var valid []time.Time
+	for _, at := range l.recent[client] {
+		if now.Sub(at) < time.Minute {
+			valid = append(valid, at)
+		}
+	}
+	if len(valid) >= rateLimit {
+		l.recent[client] = valid
+		return false
+	}
+	l.recent[client] = append(valid, now)
+	return true
+}
+
+// takeQuota counts a request of caller against quota, returning the
+// requests left, when the window resets and whether the request fits
+func (l *callLimits) takeQuota(caller string, quota config.QuotaConfig, now time.Time) (int, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.quota == nil {
+		l.quota = make(map[string]*quotaUsage)
+	}
+
+	u, ok := l.quota[caller]
+	if !ok || now.Sub(u.start) >= quota.Window {
+		// Forget the callers whose window ended
+		for key, other := range l.quota {
+			if now.Sub(other.start) >= quota.Window {
+				delete(l.quota, key)
+			}
+		}
+		u = &quotaUsage{start: now}
+		l.quota[caller] = u
+	}
+	allowed := u.count < quota.Requests
+	if allowed {
+		u.count++
+	}
+	return quota.Requests - u.count, u.start.Add(quota.Window), allowed
+}
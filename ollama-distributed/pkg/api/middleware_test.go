@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// test_tag appends its configured value to the X-Chain header, showing the
+	// order the middleware ran in
+	RegisterMiddleware("test_tag", func(_ *Server, options map[string]string) (gin.HandlerFunc, error) {
+		return func(c *gin.Context) {
+			c.Writer.Header().Add("X-Chain", options["value"])
+			c.Next()
+		}, nil
+	})
+	// test_tenant takes the caller's tenant from a header, standing in for auth
+	RegisterMiddleware("test_tenant", func(*Server, map[string]string) (gin.HandlerFunc, error) {
+		return func(c *gin.Context) {
+			c.Set("tenant", c.GetHeader("X-Tenant"))
+			c.Next()
+		}, nil
+	})
+}
+
+// newMiddlewareRouter builds a router with the configured middleware and a
+// public and a protected route
+func newMiddlewareRouter(cfg config.MiddlewareConfig) (*gin.Engine, error) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	s.config = &config.APIConfig{Middleware: cfg}
+	s.router = gin.New()
+	protected, err := s.useMiddleware()
+	if err != nil {
+		return nil, err
+	}
+	s.router.GET("/api/v1/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	protected.GET("/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return s.router, nil
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	router, err := newMiddlewareRouter(config.MiddlewareConfig{
+		Chain:          []string{"test_tag", MiddlewareHeaders, MiddlewareRateLimit},
+		ProtectedChain: []string{"test_tenant", MiddlewareQuota},
+		Disabled:       []string{MiddlewareRateLimit},
+		Headers:        map[string]string{"X-Cluster": "east"},
+		Quota:          config.QuotaConfig{Requests: 2},
+		Options:        map[string]map[string]string{"test_tag": {"value": "outer"}},
+	})
+	require.NoError(t, err)
+
+	get := func(path, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/health", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"outer"}, rec.Header().Values("X-Chain"))
+	assert.Equal(t, "east", rec.Header().Get("X-Cluster"))
+	assert.Empty(t, rec.Header().Get("X-Quota-Limit"), "public routes have no quota")
+
+	for i := 0; i < 2; i++ {
+		rec = get("/api/v1/models", "acme")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, "0", rec.Header().Get("X-Quota-Remaining"))
+	rec = get("/api/v1/models", "acme")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	rec = get("/api/v1/models", "globex")
+	assert.Equal(t, http.StatusOK, rec.Code, "quotas are per caller")
+}
+
+func TestMiddlewareChainErrors(t *testing.T) {
+	_, err := newMiddlewareRouter(config.MiddlewareConfig{Chain: []string{"missing"}})
+	assert.ErrorContains(t, err, `unknown middleware "missing"`)
+
+	assert.Panics(t, func() {
+		RegisterMiddleware(MiddlewareAuth, func(*Server, map[string]string) (gin.HandlerFunc, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterMiddleware("test_tag", func(*Server, map[string]string) (gin.HandlerFunc, error) { return nil, nil })
+	})
+	assert.Error(t, config.MiddlewareConfig{Chain: []string{"cors", "cors"}}.Validate())
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+)
+
+// SetModelScanner scans models and adapters imported into the model
+// directory root on /api/v1/models/:name/scan, and models once their
+// shards are fetched
+func (s *Server) SetModelScanner(scanner *modelscan.Pipeline, root string) {
+	s.modelScanner = scanner
+	s.modelScanRoot = root
+}
+
+// scanModelRequest names the imported file or directory, relative to the
+// model directory, and whether it is a model or an adapter
+type scanModelRequest struct {
+	Path string `json:"path" binding:"required"`
+	Kind string `json:"kind"`
+}
+
+// scanModel scans an imported model or adapter before it is used. An
+// artifact failing the scan is quarantined and its report returned with
+// 422.
+func (s *Server) scanModel(c *gin.Context) {
+	if s.modelScanner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model scanning not enabled"})
+		return
+	}
+
+	var req scanModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Kind {
+	case "":
+		req.Kind = modelscan.KindModel
+	case modelscan.KindModel, modelscan.KindAdapter:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be model or adapter"})
+		return
+	}
+	// Only paths within the model directory can be scanned and quarantined,
+	// and never the directory itself
+	name := filepath.Clean("/" + req.Path)
+	if name == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path must name a file or directory within the model directory"})
+		return
+	}
+	path := filepath.Join(s.modelScanRoot, name)
+
+	report, err := s.modelScanner.Scan(c.Request.Context(), c.Param("name"), req.Kind, path)
+	var rejected *modelscan.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+	case err != nil && report == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+	default:
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	}
+}
+
+// scanFetched scans the shards of a fetched model once its download
+// completes. Scanning stops at the first shard rejected, which is
+// quarantined: the model cannot load without it, and its report is the
+// latest of the model.
+func (s *Server) scanFetched(model string, download *shardfetch.Download) {
+	ctx := context.Background()
+	if err := download.Wait(ctx); err != nil {
+		return
+	}
+	for _, path := range download.Paths() {
+		if _, err := s.modelScanner.Scan(ctx, model, modelscan.KindModel, path); err != nil {
+			slog.Warn("Fetched model failed its scan", "model", model, "shard", path, "error", err)
+			return
+		}
+	}
+}
+
+// scanRejected reports whether the latest scan of a model rejected it
+func (s *Server) scanRejected(model string) (*modelscan.Report, bool) {
+	if s.modelScanner == nil {
+		return nil, false
+	}
+	report, ok := s.modelScanner.Report(model)
+	return report, ok && !report.Passed
+}
+
+// getModelScan returns the latest scan report of a model or adapter
+func (s *Server) getModelScan(c *gin.Context) {
+	if s.modelScanner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model scanning not enabled"})
+		return
+	}
+
+	report, ok := s.modelScanner.Report(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": c.Param("name") + " has not been scanned"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
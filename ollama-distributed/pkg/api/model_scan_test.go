@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelScanEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.POST("/api/v1/models/:name/scan", s.scanModel)
+	router.GET("/api/v1/models/:name/scan", s.getModelScan)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/models/llama3/scan", "").Code)
+
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "llama3.gguf"), []byte("GGUF\x03"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lora.bin"), []byte("\x80\x04cos\nsystem"), 0o644))
+	scanner, err := modelscan.NewPipeline(modelscan.Config{})
+	require.NoError(t, err)
+	s.SetModelScanner(scanner, root)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/models/llama3/scan", "").Code)
+	rec := do(http.MethodPost, "/api/v1/models/llama3/scan", `{"path": "llama3.gguf"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"passed":true`)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/models/llama3/scan", "").Code)
+
+	rec = do(http.MethodPost, "/api/v1/models/lora/scan", `{"path": "lora.bin", "kind": "adapter"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "disallowed type pickle")
+	assert.NoFileExists(t, filepath.Join(root, "lora.bin"))
+	assert.DirExists(t, filepath.Join(root, modelscan.DefaultQuarantineDir))
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/models/x/scan", `{"path": "../../etc/passwd"}`).Code,
+		"paths resolve within the model directory")
+	for _, path := range []string{"", ".", "/", "sub/.."} {
+		assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/x/scan", `{"path": "`+path+`"}`).Code,
+			"the model directory itself is not scanned: %q", path)
+	}
+	assert.FileExists(t, filepath.Join(root, "llama3.gguf"))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/x/scan", `{"path": "x", "kind": "dataset"}`).Code)
+}
+
+func TestScanFetchedModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.POST("/api/v1/models/:name/fetch", s.fetchModel)
+
+	model := testGGUF(t, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "llama3.gguf", time.Time{}, bytes.NewReader(model))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	s.SetShardFetcher(shardfetch.NewFetcher(shardfetch.Config{Dir: dir}, shardfetch.HTTPSource{Client: srv.Client()}))
+	sum := sha256.Sum256(model)
+	scanner, err := modelscan.NewPipeline(modelscan.Config{BlockedHashes: []string{hex.EncodeToString(sum[:])}})
+	require.NoError(t, err)
+	s.SetModelScanner(scanner, dir)
+
+	_, rejected := s.scanRejected("llama3")
+	assert.False(t, rejected, "models not scanned are not rejected")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/models/llama3/fetch",
+		strings.NewReader(fmt.Sprintf(`{"shards": [%q]}`, srv.URL+"/llama3.gguf"))))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	require.Eventually(t, func() bool {
+		_, rejected := s.scanRejected("llama3")
+		return rejected
+	}, 5*time.Second, 10*time.Millisecond, "fetched models are scanned once downloaded")
+	report, _ := s.scanRejected("llama3")
+	assert.NotEmpty(t, report.QuarantinedTo)
+	assert.NoFileExists(t, filepath.Join(dir, "llama3.gguf"))
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+)
+
+// SetModeration checks inference prompts and responses with the moderation
+// model and reports its stats on /api/v1/moderation
+func (s *Server) SetModeration(moderator *moderation.Moderator) {
+	s.moderation = moderator
+}
+
+// ModerationMiddleware checks the prompt of an inference request with the
+// moderation model before the request is transformed and scheduled, and
+// the text of its response once complete, blocking or annotating flagged
+// content by the policy of the caller's tenant. Requests made with a
+// trusted API key are not checked.
+func (s *Server) ModerationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.moderation == nil || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.GetString("auth_method") == "api_key" && s.moderation.Bypass(extractToken(c)) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		tenant := c.GetString("tenant")
+
+		verdict, handler := s.moderate(c.Request.Context(), tenant, body)
+		if verdict.Blocked() {
+			status, msg := http.StatusForbidden, "prompt flagged by moderation"
+			if verdict.Error != "" {
+				status, msg = http.StatusServiceUnavailable, "prompt could not be moderated"
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": msg, "moderation": verdict})
+			return
+		}
+		if handler == nil {
+			c.Next()
+			return
+		}
+		writer := moderation.NewWriter(c.Writer, handler)
+		c.Writer = writer
+
+		c.Next()
+
+		if err := writer.Finish(); err != nil {
+			slog.Debug("Failed to finish moderated response", "tenant", tenant, "error", err)
+		}
+	}
+}
+
+// moderate checks the prompt of an inference request body by the policy of
+// the tenant. It returns the verdict on the prompt, and unless it is
+// blocked, the handler checking the response or nil when there is nothing
+// to check or annotate. Streamed responses are held back until checked
+// when they may be blocked.
+func (s *Server) moderate(ctx context.Context, tenant string, body []byte) (moderation.Verdict, *moderation.Handler) {
+	verdict, err := s.moderation.Check(ctx, tenant, moderation.StagePrompt, promptText(body))
+	if err != nil {
+		slog.Warn("Failed to moderate prompt", "tenant", tenant, "error", err)
+	}
+	if verdict.Blocked() {
+		return verdict, nil
+	}
+	var prompt *moderation.Verdict
+	if verdict.Flagged {
+		prompt = &verdict
+	}
+
+	var check func(string) moderation.Verdict
+	if s.moderation.Policy(tenant).Action(moderation.StageResponse) != moderation.ActionOff {
+		check = func(text string) moderation.Verdict {
+			verdict, err := s.moderation.Check(ctx, tenant, moderation.StageResponse, text)
+			if err != nil {
+				slog.Warn("Failed to moderate response", "tenant", tenant, "error", err)
+			}
+			if verdict.Flagged {
+				slog.Info("Response flagged by moderation", "tenant", tenant, "categories", verdict.Categories, "action", verdict.Action)
+			}
+			return verdict
+		}
+	}
+	if prompt == nil && check == nil {
+		return verdict, nil
+	}
+	return verdict, moderation.NewHandler(prompt, check, s.moderation.Blocks(tenant, moderation.StageResponse))
+}
+
+// promptText returns the text of a request checked by moderation: the
+// prompt of generate requests, or the last message of chat requests when
+// it is the user's
+func promptText(body []byte) string {
+	var req struct {
+		Prompt   string `json:"prompt"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == "user" {
+		return req.Messages[n-1].Content
+	}
+	return req.Prompt
+}
+
+// getModeration returns the policies of the moderation stage and its
+// counters and latencies, which are those of the moderation model alone
+func (s *Server) getModeration(c *gin.Context) {
+	if s.moderation == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "moderation not enabled"})
+		return
+	}
+	def, tenants := s.moderation.Policies()
+	c.JSON(http.StatusOK, gin.H{
+		"default": def,
+		"tenants": tenants,
+		"stats":   s.moderation.Stats(),
+	})
+}
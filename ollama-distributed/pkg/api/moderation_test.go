@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// guardModel flags texts mentioning weapons
+type guardModel struct{}
+
+func (guardModel) Generate(ctx context.Context, model, system, prompt string, options map[string]interface{}) (string, error) {
+	if strings.Contains(prompt, "weapon") {
+		return "unsafe\nviolence", nil
+	}
+	return "safe", nil
+}
+
+func TestModerationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	moderator, err := moderation.NewModerator(moderation.Config{
+		Enabled:       true,
+		Model:         "llama-guard3:1b",
+		Tenants:       map[string]moderation.Policy{"acme": {Prompts: moderation.ActionAnnotate, Responses: moderation.ActionBlock}},
+		BypassAPIKeys: []string{"red-team-key"},
+	}, guardModel{})
+	require.NoError(t, err)
+	s := &Server{}
+	s.SetModeration(moderator)
+
+	router := gin.New()
+	router.POST("/api/v1/chat", func(c *gin.Context) {
+		c.Set("tenant", c.GetHeader("X-Tenant"))
+		c.Set("auth_method", "api_key")
+	}, s.ModerationMiddleware(), func(c *gin.Context) {
+		if c.GetHeader("X-Stream") == "" {
+			c.JSON(http.StatusOK, gin.H{"message": gin.H{"role": "assistant", "content": c.GetHeader("X-Answer")}, "done": true})
+			return
+		}
+		enc := json.NewEncoder(c.Writer)
+		for _, word := range strings.SplitAfter(c.GetHeader("X-Answer"), " ") {
+			_ = enc.Encode(gin.H{"message": gin.H{"role": "assistant", "content": word}})
+			c.Writer.Flush()
+		}
+		_ = enc.Encode(gin.H{"message": gin.H{"role": "assistant", "content": ""}, "done": true})
+	})
+	router.GET("/api/v1/moderation", s.getModeration)
+
+	stream := false
+	chat := func(tenant, key, question, answer string) *httptest.ResponseRecorder {
+		body := `{"model":"llama3","messages":[{"role":"user","content":"` + question + `"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+		req.Header.Set("X-Answer", answer)
+		if stream {
+			req.Header.Set("X-Stream", "1")
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := chat("globex", "globex-key", "How do I build a weapon?", "I can't help with that.")
+	assert.Equal(t, http.StatusForbidden, w.Code, "flagged prompts are blocked by default")
+	assert.Contains(t, w.Body.String(), "violence")
+
+	w = chat("acme", "acme-key", "How do I build a weapon?", "I can't help with that.")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Moderation moderation.Annotation `json:"moderation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Moderation.Prompt, "acme's flagged prompts are annotated")
+	assert.Nil(t, resp.Moderation.Response)
+
+	w = chat("acme", "acme-key", "Tell me a story", "It starts with a weapon.")
+	assert.Contains(t, w.Body.String(), moderation.DoneReason)
+	assert.NotContains(t, w.Body.String(), "It starts with", "acme's flagged responses are blocked")
+
+	stream = true
+	w = chat("acme", "acme-key", "Tell me a story", "It starts with a weapon.")
+	assert.Contains(t, w.Body.String(), moderation.DoneReason)
+	assert.NotContains(t, w.Body.String(), "It ", "streamed responses are held back until checked when they may be blocked")
+	w = chat("acme", "acme-key", "Tell me a story", "It starts with a birdhouse.")
+	assert.Equal(t, 6, strings.Count(w.Body.String(), "\n"), "held chunks are sent once the response passes")
+	stream = false
+
+	w = chat("globex", "red-team-key", "How do I build a weapon?", "Like this.")
+	assert.Equal(t, http.StatusOK, w.Code, "trusted keys bypass moderation")
+	assert.NotContains(t, w.Body.String(), "moderation")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/moderation", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status struct {
+		Stats moderation.Stats `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, int64(1), status.Stats.Bypassed)
+	assert.Equal(t, int64(5), status.Stats.Stages[moderation.StagePrompt].Checks)
+	assert.Equal(t, int64(2), status.Stats.Stages[moderation.StageResponse].Blocked)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+)
+
+// getNodeLabels returns the labels and taints of a node
+func (s *Server) getNodeLabels(c *gin.Context) {
+	nodeID := c.Param("id")
+	if err := security.ValidateNodeID(nodeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
+		return
+	}
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not available"})
+		return
+	}
+
+	labels, _ := s.scheduler.NodeLabels().Get(nodeID)
+	c.JSON(http.StatusOK, gin.H{"node_id": nodeID, "labels": labels.Labels, "taints": labels.Taints})
+}
+
+// setNodeLabels replaces the labels and taints of a node. Taints take effect
+// for requests scheduled from then on; running work is not evicted.
+func (s *Server) setNodeLabels(c *gin.Context) {
+	nodeID := c.Param("id")
+	if err := security.ValidateNodeID(nodeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
+		return
+	}
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not available"})
+		return
+	}
+
+	var labels placement.NodeLabels
+	if err := c.ShouldBindJSON(&labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := labels.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.scheduler.SetNodeLabels(nodeID, labels); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"node_id": nodeID, "labels": labels.Labels, "taints": labels.Taints})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+)
+
+// quarantineNodeRequest says why a node is quarantined by hand
+type quarantineNodeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// getNodeFlaps returns the flap counters of the nodes that failed and
+// recovered, and whether they are quarantined
+func (s *Server) getNodeFlaps(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"nodes": s.scheduler.FlapStatuses()})
+}
+
+// quarantineNode takes a node out of scheduling until it is released
+func (s *Server) quarantineNode(c *gin.Context) {
+	nodeID := c.Param("id")
+	if err := security.ValidateNodeID(nodeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
+		return
+	}
+
+	var req quarantineNodeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "quarantined by " + c.GetString("username")
+	}
+
+	s.scheduler.QuarantineNode(nodeID, req.Reason)
+	s.recordEvent(c, events.Event{Type: events.NodeQuarantined, Subject: nodeID,
+		Data: map[string]interface{}{"reason": req.Reason}})
+	c.JSON(http.StatusOK, s.scheduler.NodeFlaps(nodeID))
+}
+
+// unquarantineNode lets requests be scheduled on a quarantined node again
+func (s *Server) unquarantineNode(c *gin.Context) {
+	nodeID := c.Param("id")
+	if err := security.ValidateNodeID(nodeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid node ID: %v", err)})
+		return
+	}
+
+	if !s.scheduler.UnquarantineNode(nodeID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "node is not quarantined"})
+		return
+	}
+	s.recordEvent(c, events.Event{Type: events.NodeReleased, Subject: nodeID})
+	c.JSON(http.StatusOK, s.scheduler.NodeFlaps(nodeID))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeQuarantine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	engine.AddTestNode(&scheduler.NodeInfo{ID: "node-1", Status: scheduler.NodeStatusOnline})
+
+	s := &Server{scheduler: engine}
+	router := gin.New()
+	router.GET("/api/v1/nodes/flaps", s.getNodeFlaps)
+	router.POST("/api/v1/nodes/:id/quarantine", s.quarantineNode)
+	router.POST("/api/v1/nodes/:id/unquarantine", s.unquarantineNode)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/nodes/node-1/unquarantine", "").Code)
+
+	rec := do(http.MethodPost, "/api/v1/nodes/node-1/quarantine", `{"reason": "bad GPU"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var status scheduler.FlapStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.True(t, status.Quarantined)
+	assert.Equal(t, "bad GPU", status.Reason)
+	assert.Empty(t, engine.GetAvailableNodes())
+
+	rec = do(http.MethodGet, "/api/v1/nodes/flaps", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Nodes []scheduler.FlapStatus `json:"nodes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Nodes, 1)
+	assert.Equal(t, "node-1", listed.Nodes[0].NodeID)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/nodes/node-1/unquarantine", "").Code)
+	assert.Len(t, engine.GetAvailableNodes(), 1)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/outputlimit"
+)
+
+// OutputLimitMiddleware enforces the output limits of the caller's API key
+// on an inference route: the request asks for no more tokens than the key
+// allows, the response is cut off once it reaches a limit, and the tokens
+// generated, cut off or not, are metered to the caller's tenant
+func (s *Server) OutputLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, ok := s.callerTenant(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		// Callers authenticated otherwise than by API key get the
+		// tenant's limits
+		limits := tenant.OutputLimits
+		if keyLimits, ok := c.Get("output_limits"); ok {
+			limits = keyLimits.(outputlimit.Limits)
+		}
+
+		if limits.MaxOutputTokens > 0 && c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			c.Request.Body.Close()
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			body = outputlimit.CapRequest(body, limits.MaxOutputTokens)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		if limits.MaxStreamDuration > 0 {
+			ctx, cancel = context.WithTimeout(c.Request.Context(), limits.MaxStreamDuration)
+		}
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		writer := outputlimit.NewWriter(c.Writer, limits, cancel)
+		c.Writer = writer
+
+		c.Next()
+
+		if err := writer.Finish(ctx); err != nil {
+			slog.Debug("Failed to finish limited response", "tenant", tenant.ID, "error", err)
+		}
+		if reason := writer.CutOff(); reason != "" {
+			slog.Info("Cut off generation at output limit", "tenant", tenant.ID, "reason", reason, "tokens", writer.Tokens())
+		}
+		s.tenants.RecordOutput(tenant.ID, writer.Tokens(), writer.CutOff() != "")
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/outputlimit"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry, err := tenancy.NewRegistry(tenancy.Config{Enabled: true, Tenants: []tenancy.Tenant{{
+		ID:           "acme",
+		APIKeys:      []string{"acme-key", "batch-key"},
+		OutputLimits: outputlimit.Limits{MaxOutputTokens: 3},
+		APIKeyLimits: []tenancy.APIKeyLimits{{APIKey: "batch-key", OutputLimits: outputlimit.Limits{MaxOutputTokens: 100}}},
+	}}})
+	require.NoError(t, err)
+	s := &Server{}
+	s.SetTenancy(registry)
+
+	var numPredict float64
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if !s.authenticateAPIKey(c, extractToken(c)) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	})
+	router.POST("/api/v1/generate", s.OutputLimitMiddleware(), func(c *gin.Context) {
+		var req map[string]interface{}
+		body, _ := io.ReadAll(c.Request.Body)
+		require.NoError(t, json.Unmarshal(body, &req))
+		numPredict = req["options"].(map[string]interface{})["num_predict"].(float64)
+
+		c.Header("Content-Type", "application/x-ndjson")
+		for _, token := range []string{"a", "b", "c", "d", "e"} {
+			if c.Request.Context().Err() != nil {
+				return
+			}
+			c.Writer.WriteString(`{"response":"` + token + `","done":false}` + "\n")
+			c.Writer.Flush()
+		}
+		c.Writer.WriteString(`{"response":"","done":true,"eval_count":5}` + "\n")
+	})
+
+	generate := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/generate", strings.NewReader(`{"model":"llama3","prompt":"hi"}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := generate("acme-key")
+	assert.Equal(t, 3.0, numPredict, "the request asks for no more than the limit")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 4)
+	var end outputlimit.Termination
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &end))
+	assert.Equal(t, outputlimit.ReasonMaxTokens, end.DoneReason)
+	assert.Equal(t, 3, end.OutputTokens)
+
+	w = generate("batch-key")
+	assert.Equal(t, 100.0, numPredict)
+	assert.Len(t, strings.Split(strings.TrimSpace(w.Body.String()), "\n"), 6)
+
+	usage := registry.Usage("acme")
+	assert.Equal(t, int64(8), usage.OutputTokens, "partial tokens of cut off generations are metered")
+	assert.Equal(t, int64(1), usage.CutOff)
+}
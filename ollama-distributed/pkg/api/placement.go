@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// SetPlacementPolicy exposes the model affinity and anti-affinity
+// constraints on /api/v1/placement/constraints
+func (s *Server) SetPlacementPolicy(policy *placement.Policy) {
+	s.placement = policy
+}
+
+// getPlacementConstraints returns the current placement constraints
+func (s *Server) getPlacementConstraints(c *gin.Context) {
+	if s.placement == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "placement constraints not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.placement.Constraints())
+}
+
+// setPlacementConstraints replaces the placement constraints. They apply to
+// replicas and partition plans created from then on; existing placements
+// are not moved.
+func (s *Server) setPlacementConstraints(c *gin.Context) {
+	if s.placement == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "placement constraints not enabled"})
+		return
+	}
+
+	var constraints placement.Constraints
+	if err := c.ShouldBindJSON(&constraints); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.placement.SetConstraints(constraints); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.recordEvent(c, events.Event{Type: events.PlacementChanged, Subject: "constraints",
+		Data: map[string]interface{}{"constraints": constraints}})
+
+	c.JSON(http.StatusOK, constraints)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacementConstraints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := placement.NewPolicy(placement.Constraints{})
+	require.NoError(t, err)
+
+	s := &Server{}
+	s.SetPlacementPolicy(policy)
+	router := gin.New()
+	router.GET("/api/v1/placement/constraints", s.getPlacementConstraints)
+	router.PUT("/api/v1/placement/constraints", s.setPlacementConstraints)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/placement/constraints", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := put(`{"anti_affinity": [{"models": ["llama3:70b"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = put(`{
+		"anti_affinity": [{"models": ["llama3:70b", "mixtral"], "reason": "memory contention"}],
+		"affinity": [{"model": "codellama", "labels": {"gpu": "a100"}}]
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Error(t, policy.Check("mixtral", placement.Node{Models: []string{"llama3:70b"}}))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/placement/constraints", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var constraints placement.Constraints
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &constraints))
+	assert.Equal(t, policy.Constraints(), constraints)
+}
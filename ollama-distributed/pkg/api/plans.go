@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+)
+
+// SetPartitionManager exposes the partition plans of this node on
+// /api/v1/scheduler/plans
+func (s *Server) SetPartitionManager(pm *partitioning.PartitionManager) {
+	s.partitions = pm
+}
+
+// planSummary is a partition plan as listed by getPartitionPlans
+type planSummary struct {
+	ID               string        `json:"id"`
+	TaskID           string        `json:"task_id"`
+	Strategy         string        `json:"strategy"`
+	Partitions       int           `json:"partitions"`
+	EstimatedLatency time.Duration `json:"estimated_latency"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// getPartitionPlans lists the recently produced partition plans, newest first
+func (s *Server) getPartitionPlans(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+
+	plans := s.partitions.RecentPlans()
+	summaries := make([]planSummary, len(plans))
+	for i, plan := range plans {
+		summaries[i] = planSummary{
+			ID:               plan.ID,
+			TaskID:           plan.TaskID,
+			Strategy:         plan.Strategy,
+			Partitions:       len(plan.Partitions),
+			EstimatedLatency: plan.EstimatedLatency,
+			CreatedAt:        plan.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"plans": summaries})
+}
+
+// getPartitionStrategies returns the usage and failures by category of the
+// strategies this node partitioned tasks with
+func (s *Server) getPartitionStrategies(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strategies": s.partitions.StrategyMetrics()})
+}
+
+// getStrategiesConfig returns the tunables of the built-in partition
+// strategies in effect
+func (s *Server) getStrategiesConfig(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strategies": s.partitions.StrategiesConfig()})
+}
+
+// setStrategiesConfig replaces the tunables of the built-in partition
+// strategies until the next reload of the config file
+func (s *Server) setStrategiesConfig(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+
+	var req struct {
+		Strategies partitioning.StrategiesConfig `json:"strategies"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.partitions.ConfigureStrategies(req.Strategies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strategies": s.partitions.StrategiesConfig()})
+}
+
+// getStrategySelections returns the recent partition strategy selections
+// with their outcomes, newest first, as JSON or with format=csv as CSV
+func (s *Server) getStrategySelections(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+
+	selections := s.partitions.SelectionHistory()
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"selections": selections})
+	case "csv":
+		c.Header("Content-Disposition", `attachment; filename="strategy-selections.csv"`)
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := partitioning.WriteSelectionsCSV(c.Writer, selections); err != nil {
+			c.Error(err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+// getPartitionPlan exports one partition plan as JSON or, with format=dot,
+// as a Graphviz graph of its partitions, node assignments and dependencies
+func (s *Server) getPartitionPlan(c *gin.Context) {
+	if s.partitions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+		return
+	}
+
+	plan, ok := s.partitions.GetPlan(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+		return
+	}
+
+	switch c.DefaultQuery("format", partitioning.ExportFormatJSON) {
+	case partitioning.ExportFormatJSON:
+		c.JSON(http.StatusOK, plan.Export())
+	case partitioning.ExportFormatDOT:
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		if err := plan.WriteDOT(c.Writer); err != nil {
+			c.Error(err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or dot"})
+	}
+}
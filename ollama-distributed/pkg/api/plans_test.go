@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlansRouter(t *testing.T) (*gin.Engine, *partitioning.PartitionPlan) {
+	gin.SetMode(gin.TestMode)
+	pm := partitioning.NewPartitionManager(&partitioning.Config{DefaultStrategy: "layerwise", Deterministic: true})
+	pm.RegisterStrategy(partitioning.NewLayerwiseStrategy())
+	selection, err := pm.SelectBestStrategy(&partitioning.PartitionTask{ID: "task-1"})
+	require.NoError(t, err)
+	require.NoError(t, pm.CompleteSelection(selection.ID, true, time.Second, 10))
+	plan, err := pm.Partition(context.Background(), &partitioning.PartitionTask{
+		ID:    "task-1",
+		Nodes: []*partitioning.NodeInfo{{ID: "node-1"}},
+	}, "layerwise")
+	require.NoError(t, err)
+	_, err = pm.Partition(context.Background(), &partitioning.PartitionTask{ID: "task-2"}, "layerwise")
+	require.Error(t, err)
+
+	s := &Server{}
+	s.SetPartitionManager(pm)
+
+	router := gin.New()
+	router.GET("/api/v1/scheduler/plans", s.getPartitionPlans)
+	router.GET("/api/v1/scheduler/plans/:id", s.getPartitionPlan)
+	router.GET("/api/v1/scheduler/strategies", s.getPartitionStrategies)
+	router.GET("/api/v1/scheduler/selections", s.getStrategySelections)
+	router.GET("/api/v1/scheduler/strategies/config", s.getStrategiesConfig)
+	router.PUT("/api/v1/scheduler/strategies/config", s.setStrategiesConfig)
+	return router, plan
+}
+
+func TestGetPartitionPlans(t *testing.T) {
+	router, plan := newPlansRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/plans", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Plans []planSummary `json:"plans"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Plans, 1)
+	assert.Equal(t, plan.ID, body.Plans[0].ID)
+	assert.Equal(t, 1, body.Plans[0].Partitions)
+}
+
+func TestExportPartitionPlan(t *testing.T) {
+	router, plan := newPlansRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/plans/"+plan.ID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var export partitioning.PlanExport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.Equal(t, "task-1", export.TaskID)
+	require.Len(t, export.Nodes, 1)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/plans/"+plan.ID+"?format=dot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/vnd.graphviz; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "digraph"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/plans/"+plan.ID+"?format=svg", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/plans/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetPartitionStrategies(t *testing.T) {
+	router, _ := newPlansRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/strategies", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Strategies []partitioning.StrategyMetrics `json:"strategies"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Strategies, 1)
+	assert.Equal(t, int64(2), body.Strategies[0].UsageCount)
+	assert.Equal(t, int64(1), body.Strategies[0].FailedPartitions)
+	assert.Equal(t, map[string]int64{partitioning.FailureNoNodes: 1}, body.Strategies[0].Failures)
+}
+
+func TestExportStrategySelections(t *testing.T) {
+	router, _ := newPlansRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/selections", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Selections []partitioning.StrategySelection `json:"selections"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Selections, 1)
+	assert.True(t, body.Selections[0].Success)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scheduler/selections?format=csv", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], ",layerwise,")
+}
+
+func TestStrategiesConfig(t *testing.T) {
+	router, _ := newPlansRouter(t)
+	do := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, "/api/v1/scheduler/strategies/config", strings.NewReader(body)))
+		return rec
+	}
+
+	var resp struct {
+		Strategies partitioning.StrategiesConfig `json:"strategies"`
+	}
+	rec := do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, partitioning.DefaultLayerThreshold, resp.Strategies.Layerwise.LayerThreshold)
+
+	rec = do(http.MethodPut, `{"strategies": {"layerwise": {"layer_threshold": 24, "stage_heuristic": "per_node"}}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 24, resp.Strategies.Layerwise.LayerThreshold)
+	assert.Equal(t, partitioning.StagesPerNode, resp.Strategies.Layerwise.StageHeuristic)
+	assert.Equal(t, partitioning.DefaultMaxBatchSize, resp.Strategies.DataSplit.MaxBatchSize)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, `{"strategies": {"layerwise": {"stage_heuristic": "cube"}}}`).Code)
+	rec = do(http.MethodGet, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, 24, resp.Strategies.Layerwise.LayerThreshold)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/rag"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+)
+
+// SetRAG enables retrieval-augmented generation on /api/v1/rag/query. The
+// pipeline retrieves from the vector index set with SetVectorIndex.
+func (s *Server) SetRAG(pipeline *rag.Pipeline) {
+	s.rag = pipeline
+}
+
+// ragQuery embeds a question, retrieves the most similar documents of a
+// collection and generates an answer from them on the cluster, returning
+// the answer with the documents as citations
+func (s *Server) ragQuery(c *gin.Context) {
+	if s.rag == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "RAG not enabled"})
+		return
+	}
+
+	var req struct {
+		Collection     string                 `json:"collection" binding:"required"`
+		Question       string                 `json:"question" binding:"required"`
+		Model          string                 `json:"model"`
+		EmbeddingModel string                 `json:"embedding_model"`
+		K              int                    `json:"k"`
+		MinScore       float64                `json:"min_score"`
+		Filter         map[string]string      `json:"filter"`
+		Template       string                 `json:"template"`
+		TemplateName   string                 `json:"template_name"`
+		System         string                 `json:"system"`
+		Options        map[string]interface{} `json:"options"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A stored prompt template of the caller supplies the template, and
+	// the system prompt and model unless the request sets them
+	if req.TemplateName != "" {
+		tmpl, err := s.templates.Get(c.GetString("tenant"), req.TemplateName)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+			return
+		}
+		req.Template = tmpl.Template
+		if req.System == "" {
+			req.System = tmpl.System
+		}
+		if req.Model == "" {
+			req.Model = tmpl.Model
+		}
+	}
+
+	if req.Model == "" {
+		req.Model = s.rag.DefaultModel()
+	}
+	if req.EmbeddingModel == "" {
+		req.EmbeddingModel = s.embeddingModel
+	}
+	if req.Model == "" || req.EmbeddingModel == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model and embedding_model are required"})
+		return
+	}
+	if !s.admitTenantRequest(c, req.EmbeddingModel) {
+		return
+	}
+	if req.Model != req.EmbeddingModel && !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+	model, embeddingModel := req.Model, req.EmbeddingModel
+	if registered, _, exists := s.resolveModel(c, model); exists {
+		model = registered
+	}
+	if registered, _, exists := s.resolveModel(c, embeddingModel); exists {
+		embeddingModel = registered
+	}
+
+	answer, err := s.rag.Query(c.Request.Context(), rag.Query{
+		Collection:     s.vectorCollection(c, req.Collection),
+		Question:       req.Question,
+		Model:          model,
+		EmbeddingModel: embeddingModel,
+		K:              req.K,
+		MinScore:       req.MinScore,
+		Filter:         req.Filter,
+		Template:       req.Template,
+		System:         req.System,
+		Options:        req.Options,
+	})
+	switch {
+	case err == nil:
+		answer.Model = req.Model
+		c.JSON(http.StatusOK, answer)
+	case errors.Is(err, rag.ErrInvalidQuery), errors.Is(err, vectorindex.ErrDimensionMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, vectorindex.ErrCollectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+	case errors.Is(err, rag.ErrNoDocuments):
+		c.JSON(http.StatusNotFound, gin.H{"error": "no documents match the question"})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/rag"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoGenerator answers with the prompt it was given
+type echoGenerator struct{ model, system string }
+
+func (g *echoGenerator) Generate(_ context.Context, model, system, prompt string, _ map[string]interface{}) (string, error) {
+	g.model, g.system = model, system
+	return prompt, nil
+}
+
+func TestRAGQuery(t *testing.T) {
+	s, router := newTenantServer(t)
+	index, err := vectorindex.OpenHNSW(vectorindex.Config{})
+	require.NoError(t, err)
+	embedder := &lengthEmbedder{}
+	s.SetVectorIndex(index, embedder, "llama3")
+	generator := &echoGenerator{}
+	pipeline, err := rag.NewPipeline(rag.Config{Model: "llama3", MinScore: -1}, index, embedder, generator)
+	require.NoError(t, err)
+	s.SetRAG(pipeline)
+	router.POST("/api/v1/rag/query", s.ragQuery)
+
+	require.NoError(t, index.Upsert(context.Background(), "acme/handbook", []vectorindex.Document{
+		{ID: "vacation", Text: "Employees get 25 vacation days.", Vector: []float32{31, 10}},
+	}))
+	s.templates.Put("acme", "terse", tenancy.Template{Template: "{{range .Documents}}{{.ID}}: {{.Text}}{{end}} Q: {{.Question}}", System: "terse"})
+
+	rec := tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/rag/query",
+		`{"collection": "handbook", "question": "How many vacation days?", "template_name": "terse"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var answer rag.Answer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.Equal(t, "vacation: Employees get 25 vacation days. Q: How many vacation days?", answer.Answer)
+	assert.Equal(t, "llama3", answer.Model)
+	assert.Equal(t, "terse", generator.system)
+	require.Len(t, answer.Citations, 1)
+	assert.Equal(t, "vacation", answer.Citations[0].ID)
+
+	// Collections of other tenants are not visible
+	rec = tenantRequest(router, "globex-key", http.MethodPost, "/api/v1/rag/query",
+		`{"collection": "handbook", "question": "How many vacation days?"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/rag/query",
+		`{"collection": "handbook", "question": "?", "model": "mistral"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/rag/query",
+		`{"collection": "handbook", "question": "?", "template": "{{.Broken"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
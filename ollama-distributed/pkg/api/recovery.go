@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+)
+
+// SetFaultTolerance lets admins review recovery decisions and approve the
+// ones held for approval on /api/v1/recovery/decisions
+func (s *Server) SetFaultTolerance(ftm *fault_tolerance.FaultToleranceManager) {
+	s.faultTolerance = ftm
+}
+
+// getRecoveryDecisions lists the recorded recovery decisions, newest first
+func (s *Server) getRecoveryDecisions(c *gin.Context) {
+	if s.faultTolerance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault tolerance not enabled"})
+		return
+	}
+
+	decisions := s.faultTolerance.RecoveryDecisions()
+	if status := c.Query("status"); status != "" {
+		filtered := decisions[:0]
+		for _, decision := range decisions {
+			if string(decision.Status) == status {
+				filtered = append(filtered, decision)
+			}
+		}
+		decisions = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions})
+}
+
+// getRecoveryDecision explains the recovery of a fault: the candidate
+// strategies, their ranking and the one chosen. Faults not yet recovered
+// get a dry run.
+func (s *Server) getRecoveryDecision(c *gin.Context) {
+	if s.faultTolerance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault tolerance not enabled"})
+		return
+	}
+
+	decision, err := s.faultTolerance.ExplainRecovery(c.Param("fault_id"))
+	if err != nil {
+		s.recoveryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, decision)
+}
+
+// approveRecovery executes a recovery held for approval
+func (s *Server) approveRecovery(c *gin.Context) {
+	s.decideRecovery(c, events.RecoveryApproved, (*fault_tolerance.FaultToleranceManager).ApproveRecovery)
+}
+
+// rejectRecovery drops a recovery held for approval
+func (s *Server) rejectRecovery(c *gin.Context) {
+	s.decideRecovery(c, events.RecoveryRejected, (*fault_tolerance.FaultToleranceManager).RejectRecovery)
+}
+
+func (s *Server) decideRecovery(c *gin.Context, eventType string, decide func(ftm *fault_tolerance.FaultToleranceManager, faultID, approver string) (*fault_tolerance.RecoveryDecision, error)) {
+	if s.faultTolerance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "fault tolerance not enabled"})
+		return
+	}
+
+	decision, err := decide(s.faultTolerance, c.Param("fault_id"), c.GetString("username"))
+	if err != nil {
+		s.recoveryError(c, err)
+		return
+	}
+
+	s.recordEvent(c, events.Event{Type: eventType, Subject: decision.Target,
+		Data: map[string]interface{}{"fault_id": decision.FaultID, "strategy": decision.Chosen, "decided_by": decision.DecidedBy}})
+	c.JSON(http.StatusOK, decision)
+}
+
+func (s *Server) recoveryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, fault_tolerance.ErrFaultNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, fault_tolerance.ErrNotPendingApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryDecisions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := fault_tolerance.NewFaultToleranceManager(&fault_tolerance.Config{HealthCheckInterval: time.Second})
+	fault := manager.DetectFault(fault_tolerance.FaultTypeNodeFailure, "node-1", "node unreachable", nil)
+
+	s := &Server{}
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("username", "alice") })
+	router.GET("/api/v1/recovery/decisions", s.getRecoveryDecisions)
+	router.GET("/api/v1/recovery/decisions/:fault_id", s.getRecoveryDecision)
+	router.POST("/api/v1/recovery/decisions/:fault_id/approve", s.approveRecovery)
+	router.POST("/api/v1/recovery/decisions/:fault_id/reject", s.rejectRecovery)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/recovery/decisions").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/recovery/decisions/"+fault.ID+"/approve").Code)
+	s.SetFaultTolerance(manager)
+
+	// A detected fault not yet recovered is explained with a dry run
+	rec := do(http.MethodGet, "/api/v1/recovery/decisions/"+fault.ID)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var decision fault_tolerance.RecoveryDecision
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decision))
+	assert.Equal(t, fault_tolerance.RecoveryDryRun, decision.Status)
+	assert.Equal(t, "request_migration", decision.Chosen)
+	assert.NotEmpty(t, decision.Candidates)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/recovery/decisions/missing").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/recovery/decisions/missing/approve").Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/recovery/decisions/"+fault.ID+"/reject").Code,
+		"a dry run is not pending approval")
+
+	rec = do(http.MethodGet, "/api/v1/recovery/decisions?status=pending_approval")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Decisions []fault_tolerance.RecoveryDecision `json:"decisions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Empty(t, listed.Decisions)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
+)
+
+// SetRedaction redacts prompts in the access log by the policy of their
+// tenant and lets admins manage the policies on /api/v1/redaction
+func (s *Server) SetRedaction(engine *redaction.Engine) {
+	s.redaction = engine
+}
+
+// redactPrompt redacts a logged prompt once redaction is enabled
+func (s *Server) redactPrompt(tenant, text string) string {
+	if s.redaction == nil {
+		return text
+	}
+	return s.redaction.Redact(tenant, text)
+}
+
+// getRedaction returns the default and tenant policies and the built-in
+// entities policies may name
+func (s *Server) getRedaction(c *gin.Context) {
+	if s.redaction == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "redaction not enabled"})
+		return
+	}
+
+	def, tenants := s.redaction.Policies()
+	c.JSON(http.StatusOK, gin.H{
+		"default":  def,
+		"tenants":  tenants,
+		"entities": redaction.Entities(),
+	})
+}
+
+// setRedactionPolicy replaces the policy of a tenant
+func (s *Server) setRedactionPolicy(c *gin.Context) {
+	if s.redaction == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "redaction not enabled"})
+		return
+	}
+
+	var policy redaction.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.redaction.SetTenantPolicy(c.Param("tenant"), policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	_, tenants := s.redaction.Policies()
+	c.JSON(http.StatusOK, gin.H{"tenant": c.Param("tenant"), "policy": tenants[c.Param("tenant")]})
+}
+
+// deleteRedactionPolicy returns a tenant to the default policy
+func (s *Server) deleteRedactionPolicy(c *gin.Context) {
+	if s.redaction == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "redaction not enabled"})
+		return
+	}
+
+	if !s.redaction.DeleteTenantPolicy(c.Param("tenant")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant has no redaction policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tenant": c.Param("tenant"), "deleted": true})
+}
+
+// previewRedaction shows what the policy of a tenant, or the default one,
+// redacts from a text
+func (s *Server) previewRedaction(c *gin.Context) {
+	if s.redaction == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "redaction not enabled"})
+		return
+	}
+
+	var req struct {
+		Text   string `json:"text" binding:"required"`
+		Tenant string `json:"tenant"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.redaction.Preview(req.Tenant, req.Text))
+}
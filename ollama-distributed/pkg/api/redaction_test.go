@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactionEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/redaction", s.getRedaction)
+	router.PUT("/api/v1/redaction/tenants/:tenant", s.setRedactionPolicy)
+	router.DELETE("/api/v1/redaction/tenants/:tenant", s.deleteRedactionPolicy)
+	router.POST("/api/v1/redaction/preview", s.previewRedaction)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/redaction", "").Code)
+	assert.Equal(t, "mail a@b.io", s.redactPrompt("", "mail a@b.io"), "prompts are logged as is without redaction")
+
+	engine, err := redaction.NewEngine(redaction.Config{})
+	require.NoError(t, err)
+	s.SetRedaction(engine)
+	assert.Equal(t, "mail [EMAIL]", s.redactPrompt("", "mail a@b.io"))
+
+	rec := do(http.MethodPost, "/api/v1/redaction/preview", `{"text": "call +44 20 7946 0958 re PRJ-7"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var preview redaction.Preview
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preview))
+	assert.Equal(t, "call [PHONE] re PRJ-7", preview.Redacted)
+	require.Len(t, preview.Matches, 1)
+	assert.Equal(t, "phone", preview.Matches[0].Entity)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/redaction/tenants/acme", `{"entities": ["person"]}`).Code)
+	rec = do(http.MethodPut, "/api/v1/redaction/tenants/acme", `{"patterns": [{"name": "project", "regex": "PRJ-\\d+"}], "mode": "mask"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodPost, "/api/v1/redaction/preview", `{"text": "call +44 20 7946 0958 re PRJ-7", "tenant": "acme"}`)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preview))
+	assert.Equal(t, "call +44 20 7946 0958 re [REDACTED]", preview.Redacted)
+	assert.Contains(t, do(http.MethodGet, "/api/v1/redaction", "").Body.String(), `"acme"`)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/redaction/tenants/acme", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/redaction/tenants/acme", "").Code)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+)
+
+// SetReputation exposes the peer scores on /api/v1/peers/reputation and
+// lets admins quarantine or trust peers by hand
+func (s *Server) SetReputation(tracker *reputation.Tracker) {
+	s.reputation = tracker
+}
+
+// peerOverrideRequest sets or, with an empty override, clears the manual
+// override of a peer's quarantine
+type peerOverrideRequest struct {
+	Override string `json:"override"`
+	Reason   string `json:"reason"`
+}
+
+// getPeerReputation returns the scores of peers, lowest first
+func (s *Server) getPeerReputation(c *gin.Context) {
+	if s.reputation == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "peer reputation not enabled"})
+		return
+	}
+
+	cfg := s.reputation.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"quarantine_below": cfg.QuarantineBelow,
+		"release_above":    cfg.ReleaseAbove,
+		"peers":            s.reputation.Peers(),
+	})
+}
+
+// setPeerReputation quarantines or trusts a peer regardless of its score
+func (s *Server) setPeerReputation(c *gin.Context) {
+	peerID := c.Param("id")
+	if err := security.ValidateNodeID(peerID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid peer ID: %v", err)})
+		return
+	}
+	if s.reputation == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "peer reputation not enabled"})
+		return
+	}
+
+	var req peerOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	score, err := s.reputation.SetOverride(peerID, req.Override, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, score)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerReputationEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/peers/reputation", s.getPeerReputation)
+	router.PUT("/api/v1/peers/:id/reputation", s.setPeerReputation)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/peers/reputation", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	tracker := reputation.NewTracker(reputation.Config{Enabled: true})
+	tracker.Record("peer-a", reputation.ProtocolViolation, "oversized blob key")
+	s.SetReputation(tracker)
+
+	put := func(peerID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v1/peers/"+peerID+"/reputation", strings.NewReader(body)))
+		return w
+	}
+	w = put("peer-b", `{"override":"quarantined","reason":"serving corrupted blobs"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, tracker.Quarantined("peer-b"))
+	assert.Equal(t, http.StatusBadRequest, put("peer-b", `{"override":"banned"}`).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/peers/reputation", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Peers []reputation.PeerScore `json:"peers"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Peers, 2)
+	assert.Equal(t, "peer-a", body.Peers[0].PeerID)
+	assert.InDelta(t, 80, body.Peers[0].Score, 0.01)
+	assert.Equal(t, "serving corrupted blobs", body.Peers[1].OverrideReason)
+	assert.True(t, body.Peers[1].Quarantined)
+
+	w = put("peer-b", `{"override":""}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, tracker.Quarantined("peer-b"))
+}
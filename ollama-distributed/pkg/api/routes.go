@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/integration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
 )
@@ -133,9 +134,17 @@ func (dr *DistributedRoutes) distributedMiddleware() gin.HandlerFunc {
 		c.Header("X-Ollama-Cluster-Size", fmt.Sprintf("%d", dr.scheduler.GetClusterSize()))
 		c.Header("X-Ollama-Mode", dr.getMode())
 
-		// Add request ID for tracing
-		requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
-		c.Header("X-Request-ID", requestID)
+		// Add request ID for tracing, keeping one set by the gateway
+		requestID := logging.RequestIDFromContext(c.Request.Context())
+		if requestID == "" {
+			requestID = c.GetHeader(logging.RequestIDHeader)
+			if !logging.ValidRequestID(requestID) {
+				requestID = logging.NewRequestID()
+			}
+			c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		}
+		c.Header(logging.RequestIDHeader, requestID)
+		c.Set("request_id", requestID)
 		c.Set("requestID", requestID)
 
 		// Continue processing
@@ -3,18 +3,63 @@ package api
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth/sso"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/backpressure"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/degradation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/faultinject"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/features"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/federation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/georoute"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/idempotency"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ingest"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/integration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/licensing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/maintenance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/moderation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/proxy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/rag"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/loadbalancer"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/timesync"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/usage"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
 )
 
 // Server represents the API server
@@ -33,6 +78,165 @@ type Server struct {
 	server   *http.Server
 	upgrader websocket.Upgrader
 
+	// Requests counted by the rate limit and quota of REST and gRPC calls
+	limits callLimits
+
+	// Optional OIDC single sign-on
+	oidc *sso.OIDCProvider
+
+	// Optional service level objective monitor
+	slo *observability.SLOMonitor
+
+	// Optional goroutine and memory leak watchdog
+	watchdog *observability.Watchdog
+
+	// Optional detector of latency and error anomalies
+	anomalies *observability.AnomalyDetector
+
+	// Optional fallback to smaller models under overload
+	degrader *degradation.Degrader
+
+	// Optional bounded buffering of streamed responses for slow clients
+	backpressure *backpressure.Monitor
+
+	// Optional scores of peers, and the quarantine of misbehaving ones
+	reputation *reputation.Tracker
+
+	// Optional join tokens required of nodes joining the cluster
+	joinTokens *jointoken.Manager
+
+	// Optional versions of the cluster's members, checked when nodes join
+	compat *compat.Cluster
+
+	// Optional coordination of nodes restarting into a new version
+	upgrades *selfupdate.Coordinator
+
+	// Optional injection of synthetic faults on staging clusters
+	faultInjector *faultinject.Injector
+
+	// Optional fault tolerance manager, explaining and approving recoveries
+	faultTolerance *fault_tolerance.FaultToleranceManager
+
+	// Optional failure detector judging nodes by their heartbeats
+	failureDetector *failuredetector.Detector
+
+	// Optional matrix of the latencies measured between nodes
+	topology *topology.Matrix
+
+	// Optional dashboard sessions accepted as authentication
+	sessions *session.Manager
+
+	// Optional local accounts authenticating logins
+	users *users.Directory
+
+	// Optional buffer of recent node logs
+	logs *logging.RingBuffer
+
+	// Optional partition manager whose plans can be inspected
+	partitions *partitioning.PartitionManager
+
+	// Optional model placement constraints, editable at runtime
+	placement *placement.Policy
+
+	// Optional federation with peer clusters
+	federation *federation.Federation
+
+	// Optional routing of requests to the closest region
+	geoRouter   *georoute.Router
+	localRegion string
+
+	// Optional tenants, and the conversations and prompt templates of each
+	// tenant (or of the cluster, for callers without one)
+	tenants       *tenancy.Registry
+	conversations *tenancy.Store[tenancy.Conversation]
+	templates     *tenancy.Store[tenancy.Template]
+
+	// Optional capture of inference requests for debugging and replay
+	capture *capture.Recorder
+
+	// Optional redaction of prompts and completions before they are logged
+	// or captured
+	redaction *redaction.Engine
+
+	// Optional hooks transforming inference requests and responses
+	transforms *transform.Engine
+
+	// Optional moderation of prompts and responses by a moderation model
+	moderation *moderation.Moderator
+
+	// Optional replay of responses to retried requests with an
+	// Idempotency-Key
+	idempotency *idempotency.Cache
+
+	// Optional golden-prompt verification of models
+	verifier *verification.Verifier
+
+	// Optional scanning of models and adapters imported into the model
+	// directory
+	modelScanner  *modelscan.Pipeline
+	modelScanRoot string
+
+	// Optional fetching of model shards in the order the node's pipeline
+	// stage needs them
+	shardFetcher *shardfetch.Fetcher
+
+	// Optional check of this node's clock against NTP
+	timeSync *timesync.Checker
+
+	// Optional model licenses and the policies gating their use
+	licenses *licensing.Registry
+
+	// Optional vector index, and the embedder and default model turning
+	// texts into vectors for it
+	vectors        vectorindex.Index
+	embedder       vectorindex.Embedder
+	embeddingModel string
+
+	// Optional retrieval-augmented generation over the vector index
+	rag *rag.Pipeline
+
+	// Optional document ingestion into the vector index, and the
+	// background jobs running it
+	ingest *ingest.Service
+	jobs   *jobs.Manager
+
+	// Optional history of cluster state changes
+	events *events.Log
+
+	// Optional maintenance windows of nodes
+	maintenance *maintenance.Manager
+
+	// Optional feature flags
+	features *features.Manager
+
+	// Optional model warm-up, and the local instances it warms models on
+	warmup          *warmup.Service
+	warmupEndpoints func() map[string]string
+
+	// Optional orchestration engine coordinating speculative decoding
+	orchestrator *orchestration.OrchestrationEngine
+
+	// Optional model load queue of this node, and how it loads models
+	loadQueue *loadqueue.Queue
+	loadModel loadqueue.LoadFunc
+
+	// Optional history of key metrics
+	history *history.Recorder
+
+	// Optional per-model usage, sampled into the history
+	usage *usage.Tracker
+
+	// Version of this node and its configuration, for diagnostics
+	// snapshots
+	buildVersion string
+	nodeConfig   *config.Config
+
+	// Kubernetes lifecycle: readiness settings, whether the pre-stop hook
+	// is draining the node, and the API requests being served
+	lifecycle config.KubernetesConfig
+	draining  atomic.Bool
+	inFlight  atomic.Int64
+
 	// WebSocket connections
 	wsConnections map[string]*WSConnection
 	wsHub         *WSHub
@@ -52,11 +256,15 @@ func NewServer(config *config.APIConfig, p2pNode *p2p.Node, consensusEngine *con
 				return true // Allow all origins for now
 			},
 		},
-		wsHub: NewWSHub(),
+		wsHub:         NewWSHub(),
+		conversations: tenancy.NewStore[tenancy.Conversation](),
+		templates:     tenancy.NewStore[tenancy.Template](),
 	}
 
 	// Initialize router
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, fmt.Errorf("failed to set up API routes: %w", err)
+	}
 
 	return server, nil
 }
@@ -64,11 +272,37 @@ func NewServer(config *config.APIConfig, p2pNode *p2p.Node, consensusEngine *con
 // SetIntegration sets the Ollama integration
 func (s *Server) SetIntegration(integration *integration.SimpleOllamaIntegration) {
 	s.integration = integration
+	s.registerLocalInstances()
+}
+
+// SetSLOMonitor exposes the status of monitor on /api/v1/slo
+func (s *Server) SetSLOMonitor(monitor *observability.SLOMonitor) {
+	s.slo = monitor
+}
+
+// SetWatchdog exposes the leak watchdog on /api/v1/watchdog
+func (s *Server) SetWatchdog(watchdog *observability.Watchdog) {
+	s.watchdog = watchdog
+}
+
+// SetTimeSync reports the clock skew measured by checker in the cluster
+// status
+func (s *Server) SetTimeSync(checker *timesync.Checker) {
+	s.timeSync = checker
 }
 
 // SetProxy sets the Ollama proxy
 func (s *Server) SetProxy(proxy *proxy.OllamaProxy) {
 	s.ollamaProxy = proxy
+	s.registerLocalInstances()
+}
+
+// registerLocalInstances registers every Ollama instance supervised by the
+// integration with the proxy once both are set
+func (s *Server) registerLocalInstances() {
+	if s.ollamaProxy != nil && s.integration != nil {
+		s.ollamaProxy.SetLocalInstances(s.integration)
+	}
 }
 
 // SetLoadBalancer sets the load balancer
@@ -77,17 +311,17 @@ func (s *Server) SetLoadBalancer(lb *loadbalancer.LoadBalancer) {
 }
 
 // setupRouter configures the Gin router with all routes and middleware
-func (s *Server) setupRouter() {
+func (s *Server) setupRouter() error {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
 	s.router = gin.New()
 
-	// Add middleware
-	s.router.Use(s.LoggingMiddleware())
-	s.router.Use(s.CORSMiddleware())
-	s.router.Use(s.SecurityHeadersMiddleware())
-	s.router.Use(s.RateLimitMiddleware())
+	// Add the configured middleware chains
+	protected, err := s.useMiddleware()
+	if err != nil {
+		return err
+	}
 
 	// Public routes (no authentication required)
 	public := s.router.Group("/api/v1")
@@ -96,33 +330,97 @@ func (s *Server) setupRouter() {
 		public.GET("/version", s.version)
 		public.POST("/auth/login", s.login)
 		public.POST("/auth/logout", s.logout)
+		public.GET("/cluster/ca", s.getClusterCA)
+		public.POST("/cluster/join", s.joinCluster) // authenticated by the join token
 	}
 
 	// Protected routes (authentication required)
-	protected := s.router.Group("/api/v1")
-	protected.Use(s.AuthMiddleware())
 	{
 		// Model management
 		protected.GET("/models", s.getModels)
 		protected.GET("/models/:name", s.getModel)
 		protected.POST("/models/:name/download", s.downloadModel)
 		protected.DELETE("/models/:name", s.deleteModel)
+		protected.POST("/models/:name/verify", s.RoleMiddleware("admin"), s.verifyModel)
+		protected.GET("/models/:name/verification", s.getModelVerification)
+		protected.POST("/models/:name/scan", s.RoleMiddleware("admin"), s.scanModel)
+		protected.GET("/models/:name/scan", s.getModelScan)
+		protected.POST("/models/:name/fetch", s.RoleMiddleware("admin"), s.fetchModel)
+		protected.GET("/models/:name/fetch", s.getModelFetch)
+		protected.DELETE("/models/:name/fetch", s.RoleMiddleware("admin"), s.cancelModelFetch)
+		protected.GET("/shard-fetches", s.getShardFetches)
+		protected.GET("/verification/suite", s.getVerificationSuite)
+		protected.PUT("/verification/suite", s.RoleMiddleware("admin"), s.setVerificationSuite)
+		protected.GET("/models/:name/license", s.getModelLicense)
+		protected.PUT("/models/:name/license", s.RoleMiddleware("admin"), s.setModelLicense)
+		protected.DELETE("/models/:name/license", s.RoleMiddleware("admin"), s.deleteModelLicense)
+		protected.GET("/licensing", s.RoleMiddleware("admin"), s.getLicensing)
+		protected.PUT("/licensing/rules", s.RoleMiddleware("admin"), s.setLicenseRules)
 
 		// Node management
 		protected.GET("/nodes", s.getNodes)
 		protected.GET("/nodes/:id", s.getNode)
 		protected.POST("/nodes/:id/drain", s.drainNode)
 		protected.POST("/nodes/:id/undrain", s.undrainNode)
+		protected.GET("/nodes/flaps", s.getNodeFlaps)
+		protected.GET("/nodes/heartbeats", s.getNodeHeartbeats)
+		protected.POST("/nodes/:id/quarantine", s.RoleMiddleware("admin"), s.quarantineNode)
+		protected.POST("/nodes/:id/unquarantine", s.RoleMiddleware("admin"), s.unquarantineNode)
+		protected.GET("/nodes/:id/labels", s.getNodeLabels)
+		protected.PUT("/nodes/:id/labels", s.RoleMiddleware("admin"), s.setNodeLabels)
+		protected.GET("/peers/reputation", s.RoleMiddleware("admin"), s.getPeerReputation)
+		protected.PUT("/peers/:id/reputation", s.RoleMiddleware("admin"), s.setPeerReputation)
+		protected.GET("/maintenance/windows", s.getMaintenanceWindows)
+		protected.PUT("/maintenance/windows/:name", s.RoleMiddleware("admin"), s.setMaintenanceWindow)
+		protected.DELETE("/maintenance/windows/:name", s.RoleMiddleware("admin"), s.deleteMaintenanceWindow)
+
+		// Fault injection for game days on staging clusters
+		protected.GET("/faults/injections", s.RoleMiddleware("admin"), s.getFaultInjections)
+		protected.POST("/faults/injections", s.RoleMiddleware("admin"), s.injectFault)
+		protected.DELETE("/faults/injections/:id", s.RoleMiddleware("admin"), s.clearFaultInjection)
+		protected.GET("/recovery/decisions", s.RoleMiddleware("admin"), s.getRecoveryDecisions)
+		protected.GET("/recovery/decisions/:fault_id", s.RoleMiddleware("admin"), s.getRecoveryDecision)
+		protected.POST("/recovery/decisions/:fault_id/approve", s.RoleMiddleware("admin"), s.approveRecovery)
+		protected.POST("/recovery/decisions/:fault_id/reject", s.RoleMiddleware("admin"), s.rejectRecovery)
+
+		// Feature flags
+		protected.GET("/features", s.getFeatureFlags)
+		protected.PUT("/features/:name", s.RoleMiddleware("admin"), s.setFeatureFlag)
+		protected.DELETE("/features/:name", s.RoleMiddleware("admin"), s.deleteFeatureFlag)
+
+		// Model warm-up
+		protected.GET("/warmup", s.getWarmups)
+		protected.POST("/models/:name/warmup", s.RoleMiddleware("admin"), s.warmUpModel)
+
+		// Speculative decoding
+		protected.GET("/speculative", s.getSpeculativeStats)
+		protected.POST("/speculative/generate", s.UsageMiddleware(), s.BackpressureMiddleware(), s.OutputLimitMiddleware(), s.ModerationMiddleware(), s.TransformMiddleware(), s.speculativeGenerate)
+
+		// Model load queue
+		protected.GET("/load-queue", s.getLoadQueue)
+		protected.GET("/load-queue/:name", s.getLoadQueuePosition)
+		protected.POST("/models/:name/load", s.RoleMiddleware("admin"), s.loadModelHandler)
 
 		// Inference endpoints
-		protected.POST("/generate", s.generate)
-		protected.POST("/chat", s.chat)
-		protected.POST("/embeddings", s.embeddings)
+		protected.POST("/generate", s.UsageMiddleware(), s.BackpressureMiddleware(), s.OutputLimitMiddleware(), s.ModerationMiddleware(), s.TransformMiddleware(), s.generate)
+		protected.POST("/chat", s.UsageMiddleware(), s.BackpressureMiddleware(), s.OutputLimitMiddleware(), s.ModerationMiddleware(), s.TransformMiddleware(), s.chat)
+		protected.GET("/streaming", s.getStreamingStats)
+		protected.POST("/embeddings", s.UsageMiddleware(), s.embeddings)
 
 		// Cluster management
 		protected.GET("/cluster/status", s.getClusterStatus)
 		protected.GET("/cluster/leader", s.getClusterLeader)
-		protected.POST("/cluster/join", s.joinCluster)
+		protected.GET("/cluster/topology", s.getClusterTopology)
+		protected.GET("/cluster/state", s.getClusterState)
+		protected.GET("/cluster/snapshot", s.RoleMiddleware("admin"), s.getClusterSnapshot)
+		protected.GET("/cluster/versions", s.getClusterVersions)
+		protected.GET("/cluster/upgrade/lease", s.RoleMiddleware("admin"), s.getUpgradeLease)
+		protected.POST("/cluster/upgrade/lease", s.RoleMiddleware("admin"), s.acquireUpgradeLease)
+		protected.DELETE("/cluster/upgrade/lease/:node", s.RoleMiddleware("admin"), s.releaseUpgradeLease)
+		protected.GET("/events", s.RoleMiddleware("admin"), s.getEvents)
+		protected.GET("/cluster/tokens", s.RoleMiddleware("admin"), s.getJoinTokens)
+		protected.POST("/cluster/tokens", s.RoleMiddleware("admin"), s.createJoinToken)
+		protected.DELETE("/cluster/tokens/:id", s.RoleMiddleware("admin"), s.revokeJoinToken)
 		protected.POST("/cluster/leave", s.leaveCluster)
 
 		// Transfer management
@@ -135,14 +433,108 @@ func (s *Server) setupRouter() {
 
 		// System endpoints
 		protected.GET("/metrics", s.getMetrics)
+		protected.GET("/metrics/history", s.getHistoryMetrics)
+		protected.GET("/metrics/history/:metric", s.getMetricHistory)
+		protected.GET("/analytics/models", s.getModelAnalytics)
 		protected.GET("/stats", s.getStats)
+		protected.GET("/slo", s.getSLOStatus)
+		protected.GET("/anomalies", s.getAnomalies)
+		protected.GET("/degradation", s.getDegradation)
+		protected.GET("/watchdog", s.RoleMiddleware("admin"), s.getWatchdogStatus)
+		protected.POST("/watchdog/diagnostics", s.RoleMiddleware("admin"), s.captureWatchdogDiagnostics)
+		protected.GET("/logs", s.RoleMiddleware("admin"), s.getLogs)
+		protected.GET("/scheduler/plans", s.getPartitionPlans)
+		protected.GET("/scheduler/plans/:id", s.getPartitionPlan)
+		protected.GET("/scheduler/strategies", s.getPartitionStrategies)
+		protected.GET("/scheduler/strategies/config", s.getStrategiesConfig)
+		protected.PUT("/scheduler/strategies/config", s.RoleMiddleware("admin"), s.setStrategiesConfig)
+		protected.GET("/scheduler/selections", s.getStrategySelections)
+		protected.POST("/scheduler/explain", s.explainScheduling)
+		protected.GET("/scheduler/fair-share", s.getFairShares)
+		protected.PUT("/scheduler/fair-share", s.RoleMiddleware("admin"), s.setFairShareWeights)
+		protected.GET("/federation/peers", s.getFederationPeers)
+		protected.GET("/federation/models", s.getFederationModels)
+		protected.GET("/routing/regions", s.getRoutingRegions)
+		protected.GET("/routing/overrides", s.getRoutingOverrides)
+		protected.PUT("/routing/overrides", s.RoleMiddleware("admin"), s.setRoutingOverrides)
+		protected.GET("/placement/constraints", s.getPlacementConstraints)
+		protected.PUT("/placement/constraints", s.RoleMiddleware("admin"), s.setPlacementConstraints)
 		protected.GET("/config", s.getConfig)
 		protected.PUT("/config", s.RoleMiddleware("admin"), s.updateConfig)
 
+		// Tenants and their conversations and prompt templates
+		protected.GET("/tenant", s.getTenant)
+		protected.GET("/tenants", s.RoleMiddleware("admin"), s.getTenants)
+		protected.GET("/templates", s.getTemplates)
+		protected.GET("/templates/:name", s.getTemplate)
+		protected.PUT("/templates/:name", s.putTemplate)
+		protected.DELETE("/templates/:name", s.deleteTemplate)
+		protected.GET("/conversations", s.getConversations)
+		protected.POST("/conversations", s.createConversation)
+		protected.GET("/conversations/:id", s.getConversation)
+		protected.POST("/conversations/:id/messages", s.appendConversation)
+		protected.DELETE("/conversations/:id", s.deleteConversation)
+
+		// Vector index for retrieval workflows
+		protected.GET("/vectors", s.getVectorCollections)
+		protected.POST("/vectors/:collection/documents", s.upsertVectors)
+		protected.POST("/vectors/:collection/query", s.queryVectors)
+		protected.DELETE("/vectors/:collection/documents/:id", s.deleteVector)
+		protected.DELETE("/vectors/:collection", s.dropVectorCollection)
+		protected.POST("/rag/query", s.ragQuery)
+		protected.POST("/ingest", s.ingestDocuments)
+		protected.POST("/ingest/upload", s.uploadDocuments)
+
+		// Background jobs such as ingestions
+		protected.GET("/jobs", s.getJobs)
+		protected.GET("/jobs/:id", s.getJob)
+		protected.DELETE("/jobs/:id", s.cancelJob)
+
+		// Read-only GraphQL view of the cluster for the dashboard
+		graphQL, err := s.GraphQLHandler()
+		if err != nil {
+			return fmt.Errorf("failed to build GraphQL schema: %w", err)
+		}
+		protected.GET("/graphql", graphQL)
+		protected.POST("/graphql", graphQL)
+
+		// Captured inference requests
+		protected.GET("/captures", s.RoleMiddleware("admin"), s.getCaptures)
+		protected.GET("/captures/:id", s.RoleMiddleware("admin"), s.getCapture)
+
+		// Redaction of prompts and completions in logs, captures and webhooks
+		protected.GET("/redaction", s.RoleMiddleware("admin"), s.getRedaction)
+		protected.PUT("/redaction/tenants/:tenant", s.RoleMiddleware("admin"), s.setRedactionPolicy)
+		protected.DELETE("/redaction/tenants/:tenant", s.RoleMiddleware("admin"), s.deleteRedactionPolicy)
+		protected.POST("/redaction/preview", s.RoleMiddleware("admin"), s.previewRedaction)
+		protected.GET("/hooks", s.RoleMiddleware("admin"), s.getHooks)
+		protected.GET("/moderation", s.RoleMiddleware("admin"), s.getModeration)
+
+		// Local accounts
+		protected.GET("/admin/users", s.RoleMiddleware("admin"), s.listUsers)
+		protected.POST("/admin/users", s.RoleMiddleware("admin"), s.createUser)
+		protected.GET("/admin/users/:username", s.RoleMiddleware("admin"), s.getUser)
+		protected.PATCH("/admin/users/:username", s.RoleMiddleware("admin"), s.updateUser)
+		protected.POST("/admin/users/:username/password", s.RoleMiddleware("admin"), s.resetUserPassword)
+		protected.DELETE("/admin/users/:username", s.RoleMiddleware("admin"), s.deleteUser)
+
 		// User profile
 		protected.GET("/profile", s.profile)
 	}
 
+	// Calls from federation peers, authenticated with the peer's token
+	federated := s.router.Group("/api/v1/federation")
+	federated.Use(s.FederationMiddleware())
+	{
+		federated.POST("/manifest", s.receiveFederationManifest)
+		federated.POST("/forward/:kind", s.forwardedInference)
+	}
+
+	// Profiling and runtime diagnostics, admin only
+	if s.config.Debug.Enabled {
+		s.registerDebugRoutes()
+	}
+
 	// WebSocket endpoint
 	s.router.GET("/ws", s.HandleWebSocket)
 
@@ -152,30 +544,75 @@ func (s *Server) setupRouter() {
 
 	// Metrics endpoint for Prometheus
 	s.router.GET("/metrics", s.getMetrics)
+
+	// Kubernetes probes and pre-stop hook
+	s.router.GET("/livez", s.livez)
+	s.router.GET("/readyz", s.readyz)
+	s.router.GET("/lifecycle/prestop", s.preStop)
+	s.router.POST("/lifecycle/prestop", s.preStop)
+	return nil
 }
 
-// Start starts the API server
+// Start starts the API server on every configured listen address and blocks
+// until one of the listeners stops
 func (s *Server) Start() error {
 	// Start WebSocket hub
 	go s.wsHub.Run()
 
+	addrs := s.config.AllListenAddresses()
+	if len(addrs) == 0 {
+		return fmt.Errorf("no API listen address configured")
+	}
+
 	// Create HTTP server
-	s.server = &http.Server{
-		Addr:         s.config.Listen,
-		Handler:      s.router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	s.server = s.httpServer(addrs[0])
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, err := net.Listen(listenNetwork(addr), addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, newConnLimitListener(ln, s.config.MaxConnections, s.config.MaxConnsPerClient))
 	}
 
 	// Start server
-	fmt.Printf("Starting API server on %s\n", s.config.Listen)
-
-	if s.config.TLS.Enabled {
-		return s.server.ListenAndServeTLS(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+	fmt.Printf("Starting API server on %s\n", strings.Join(addrs, ", "))
+
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if s.config.TLS.Enabled {
+				errCh <- s.server.ServeTLS(ln, s.config.TLS.CertFile, s.config.TLS.KeyFile)
+				return
+			}
+			errCh <- s.server.Serve(ln)
+		}(ln)
 	}
 
-	return s.server.ListenAndServe()
+	return <-errCh
+}
+
+// listenNetwork picks the network for a listen address so that an IPv6
+// wildcard such as "[::]:11434" binds IPv6 only and can coexist with an
+// IPv4 listener on the same port
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
 }
 
 // Stop gracefully stops the API server
@@ -233,7 +670,8 @@ func (s *Server) GetStats() map[string]interface{} {
 
 	if s.p2p != nil {
 		stats["p2p"] = map[string]interface{}{
-			"peers": 0, // TODO: Implement GetPeers method
+			"peers":      0, // TODO: Implement GetPeers method
+			"transports": s.p2p.GetTransportMetrics(),
 		}
 	}
 
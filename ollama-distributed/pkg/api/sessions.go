@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+)
+
+// SetSessions accepts the dashboard's session cookie as authentication,
+// with the RBAC roles of the logged in user
+func (s *Server) SetSessions(manager *session.Manager) {
+	s.sessions = manager
+}
+
+// authenticateSession authenticates the caller by its dashboard session
+// cookie, if it has a live one
+func (s *Server) authenticateSession(c *gin.Context) bool {
+	if s.sessions == nil {
+		return false
+	}
+	token, err := c.Cookie(session.CookieName)
+	if err != nil {
+		return false
+	}
+	sess, ok := s.sessions.Validate(token)
+	if !ok {
+		return false
+	}
+
+	c.Set("user_id", "session:"+sess.Username)
+	c.Set("username", sess.Username)
+	c.Set("roles", sess.Roles)
+	c.Set("auth_method", "session")
+	return true
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticateSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/whoami", func(c *gin.Context) {
+		if !s.authenticateSession(c) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authorization token"})
+			return
+		}
+		roles, _ := c.Get("roles")
+		c.JSON(http.StatusOK, gin.H{"username": c.GetString("username"), "roles": roles})
+	})
+	whoami := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/whoami", nil)
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: session.CookieName, Value: token})
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	sessions, err := session.NewManager(session.Config{}, session.StaticUsers{
+		{Username: "alice", PasswordHash: string(hash), Roles: []string{"operator"}},
+	})
+	require.NoError(t, err)
+	token, _, err := sessions.Login("alice", "s3cret", "", "10.0.0.1", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, whoami(token).Code, "sessions not enabled")
+	s.SetSessions(sessions)
+	assert.Equal(t, http.StatusUnauthorized, whoami("").Code)
+	assert.Equal(t, http.StatusUnauthorized, whoami("forged").Code)
+	rec := whoami(token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"username": "alice", "roles": ["operator"]}`, rec.Body.String())
+
+	sessions.Logout(token)
+	assert.Equal(t, http.StatusUnauthorized, whoami(token).Code)
+}
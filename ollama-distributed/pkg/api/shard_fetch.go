@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+)
+
+// SetShardFetcher fetches the shards of models placed on this node in the
+// order their pipeline stage needs them
+func (s *Server) SetShardFetcher(fetcher *shardfetch.Fetcher) {
+	s.shardFetcher = fetcher
+}
+
+// fetchModelRequest lists the shards of a model and the stage this node
+// runs, given directly or as a partition plan placing layers on the node
+type fetchModelRequest struct {
+	Shards []string          `json:"shards" binding:"required"`
+	Stage  *shardfetch.Stage `json:"stage"`
+	PlanID string            `json:"plan_id"`
+	NodeID string            `json:"node_id"`
+}
+
+// fetchModel starts fetching the shards of a model, the layers of this
+// node's stage first. It returns once their headers are read; the stage
+// can serve as soon as the download reports it servable. With model
+// scanning enabled the shards are scanned once all are downloaded.
+func (s *Server) fetchModel(c *gin.Context) {
+	if s.shardFetcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "shard fetching not enabled"})
+		return
+	}
+
+	var req fetchModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Stage != nil && req.PlanID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "give either a stage or a plan_id"})
+		return
+	}
+	if req.PlanID != "" {
+		if s.partitions == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "partitioning not enabled"})
+			return
+		}
+		plan, ok := s.partitions.GetPlan(req.PlanID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "partition plan not found"})
+			return
+		}
+		nodeID := req.NodeID
+		if nodeID == "" && s.p2p != nil {
+			nodeID = string(s.p2p.ID())
+		}
+		first, last, ok := plan.Stage(nodeID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the plan places no layers on node " + nodeID})
+			return
+		}
+		req.Stage = &shardfetch.Stage{First: first, Last: last}
+	}
+
+	download, err := s.shardFetcher.Fetch(c.Request.Context(), shardfetch.Request{
+		Model:  c.Param("name"),
+		Shards: req.Shards,
+		Stage:  req.Stage,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if s.modelScanner != nil {
+		go s.scanFetched(c.Param("name"), download)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"fetch": download.Progress()})
+}
+
+// getModelFetch returns the progress of the latest fetch of a model
+func (s *Server) getModelFetch(c *gin.Context) {
+	if s.shardFetcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "shard fetching not enabled"})
+		return
+	}
+
+	download, ok := s.shardFetcher.Download(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": c.Param("name") + " has not been fetched"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fetch": download.Progress()})
+}
+
+// getShardFetches returns the progress of the latest fetch of every model
+func (s *Server) getShardFetches(c *gin.Context) {
+	if s.shardFetcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "shard fetching not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fetches": s.shardFetcher.Downloads()})
+}
+
+// cancelModelFetch cancels the fetch of a model, discarding what was
+// downloaded
+func (s *Server) cancelModelFetch(c *gin.Context) {
+	if s.shardFetcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "shard fetching not enabled"})
+		return
+	}
+
+	download, ok := s.shardFetcher.Download(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": c.Param("name") + " has not been fetched"})
+		return
+	}
+	download.Cancel()
+	c.JSON(http.StatusOK, gin.H{"message": "fetch cancelled"})
+}
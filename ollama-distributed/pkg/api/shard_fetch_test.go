@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/shardfetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGGUF returns a GGUF file of a model with the given number of layers
+func testGGUF(t *testing.T, layers int) []byte {
+	var b bytes.Buffer
+	w := func(v interface{}) { require.NoError(t, binary.Write(&b, binary.LittleEndian, v)) }
+	str := func(s string) { w(uint64(len(s))); b.WriteString(s) }
+	b.WriteString("GGUF")
+	w(uint32(3))
+	w(uint64(layers))
+	w(uint64(0))
+	for i := 0; i < layers; i++ {
+		str(fmt.Sprintf("blk.%d.attn_q.weight", i))
+		w(uint32(1))
+		w(uint64(256))
+		w(uint32(0)) // f32
+		w(uint64(i * 1024))
+	}
+	b.Write(make([]byte, (32-b.Len()%32)%32))
+	b.Write(bytes.Repeat([]byte{1}, layers*1024))
+	return b.Bytes()
+}
+
+// layerStrategy places two layers on each node
+type layerStrategy struct{}
+
+func (layerStrategy) GetName() string { return "layers" }
+
+func (layerStrategy) Partition(ctx context.Context, task *partitioning.PartitionTask) (*partitioning.PartitionPlan, error) {
+	plan := &partitioning.PartitionPlan{ID: "plan-1", TaskID: task.ID, Strategy: "layers"}
+	for i, node := range task.Nodes {
+		plan.Partitions = append(plan.Partitions, partitioning.Partition{
+			ID:     fmt.Sprint("layers-", i),
+			NodeID: node.ID,
+			Data:   map[string]interface{}{partitioning.DataLayerStart: 2 * i, partitioning.DataLayerEnd: 2*i + 1},
+		})
+	}
+	return plan, nil
+}
+
+func (layerStrategy) GetMetrics() *partitioning.StrategyMetrics       { return nil }
+func (layerStrategy) CanHandle(task *partitioning.PartitionTask) bool { return true }
+
+func TestShardFetchEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.POST("/api/v1/models/:name/fetch", s.fetchModel)
+	router.GET("/api/v1/models/:name/fetch", s.getModelFetch)
+	router.DELETE("/api/v1/models/:name/fetch", s.cancelModelFetch)
+	router.GET("/api/v1/shard-fetches", s.getShardFetches)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/shard-fetches", "").Code)
+
+	model := testGGUF(t, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "llama3.gguf", time.Time{}, bytes.NewReader(model))
+	}))
+	defer srv.Close()
+	s.SetShardFetcher(shardfetch.NewFetcher(shardfetch.Config{Dir: t.TempDir()}, shardfetch.HTTPSource{Client: srv.Client()}))
+	shards := fmt.Sprintf(`"shards": [%q]`, srv.URL+"/llama3.gguf")
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/models/llama3/fetch", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/llama3/fetch", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/llama3/fetch",
+		`{`+shards+`, "stage": {"first": 0, "last": 1}, "plan_id": "plan-1"}`).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/models/llama3/fetch", `{`+shards+`, "plan_id": "plan-1"}`).Code)
+
+	s.partitions = partitioning.NewPartitionManager(&partitioning.Config{})
+	s.partitions.RegisterStrategy(layerStrategy{})
+	_, err := s.partitions.Partition(context.Background(), &partitioning.PartitionTask{
+		ID:    "task-1",
+		Nodes: []*partitioning.NodeInfo{{ID: "node-a"}, {ID: "node-b"}},
+	}, "layers")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/models/llama3/fetch", `{`+shards+`, "plan_id": "plan-2"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/models/llama3/fetch",
+		`{`+shards+`, "plan_id": "plan-1", "node_id": "node-c"}`).Code)
+
+	rec := do(http.MethodPost, "/api/v1/models/llama3/fetch", `{`+shards+`, "plan_id": "plan-1", "node_id": "node-b"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var resp struct {
+		Fetch shardfetch.Progress `json:"fetch"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, shardfetch.Stage{First: 2, Last: 3}, resp.Fetch.Stage)
+	assert.Equal(t, 4, resp.Fetch.Layers)
+
+	require.Eventually(t, func() bool {
+		rec := do(http.MethodGet, "/api/v1/models/llama3/fetch", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Fetch.Done
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, resp.Fetch.Error)
+	assert.True(t, resp.Fetch.Servable)
+
+	rec = do(http.MethodGet, "/api/v1/shard-fetches", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"model":"llama3"`)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/models/llama3/fetch", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/models/phi3/fetch", "").Code)
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/failuredetector"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+)
+
+// snapshotEventLimit bounds the events included in a snapshot
+const snapshotEventLimit = 500
+
+// Snapshot is a diagnostics bundle of the cluster as this node sees it, for
+// attaching to bug reports. Secrets in the configuration are redacted.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// Since starts the window of the events and metrics included
+	Since      time.Time        `json:"since"`
+	Versions   SnapshotVersions `json:"versions"`
+	Membership *ClusterState    `json:"membership"`
+	// Placement lists the nodes holding each model
+	Placement   map[string][]string    `json:"placement"`
+	Constraints *placement.Constraints `json:"placement_constraints,omitempty"`
+	Health      SnapshotHealth         `json:"health"`
+	Metrics     SnapshotMetrics        `json:"metrics"`
+	// Events are the latest cluster events of the window, newest first
+	Events []*events.Event        `json:"events"`
+	Config map[string]interface{} `json:"config,omitempty"`
+	// Errors are the parts of the snapshot that could not be collected,
+	// and why
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// SnapshotVersions are the versions of the software this node runs, and
+// of its peers
+type SnapshotVersions struct {
+	Node     string         `json:"node"`
+	Go       string         `json:"go"`
+	Platform string         `json:"platform"`
+	Cluster  *compat.Status `json:"cluster,omitempty"`
+}
+
+// SnapshotHealth is the health of the node's services and of the nodes of
+// the cluster
+type SnapshotHealth struct {
+	Services    map[string]bool               `json:"services"`
+	Flapping    []scheduler.FlapStatus        `json:"flapping,omitempty"`
+	Heartbeats  []failuredetector.NodeStats   `json:"heartbeats,omitempty"`
+	Peers       []reputation.PeerScore        `json:"peers,omitempty"`
+	Maintenance map[string]string             `json:"maintenance,omitempty"`
+	SLOs        []*observability.SLOStatus    `json:"slos,omitempty"`
+	Watchdog    *observability.WatchdogStatus `json:"watchdog,omitempty"`
+	Anomalies   []observability.Anomaly       `json:"anomalies,omitempty"`
+}
+
+// SnapshotMetrics are the scheduler's counters and a summary of the
+// metrics history over the snapshot window
+type SnapshotMetrics struct {
+	Scheduler *scheduler.Stats `json:"scheduler"`
+	// History summarizes every series of every metric with a history
+	History map[string][]SeriesSummary `json:"history,omitempty"`
+}
+
+// SeriesSummary summarizes the points of one series of a metric
+type SeriesSummary struct {
+	Key    string  `json:"key,omitempty"`
+	Mean   float64 `json:"mean"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Last   float64 `json:"last"`
+	Points int     `json:"points"`
+}
+
+// SetDiagnostics includes the version of this node and its configuration,
+// with secrets redacted, in diagnostics snapshots
+func (s *Server) SetDiagnostics(version string, cfg *config.Config) {
+	s.buildVersion = version
+	s.nodeConfig = cfg
+}
+
+// snapshot collects the diagnostics snapshot of the window starting at
+// since. Parts that fail are noted in the snapshot's errors.
+func (s *Server) snapshot(ctx context.Context, since time.Time) *Snapshot {
+	snap := &Snapshot{
+		Time:  time.Now(),
+		Since: since,
+		Versions: SnapshotVersions{
+			Node:     s.buildVersion,
+			Go:       runtime.Version(),
+			Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		},
+		Membership: s.clusterState(),
+		Placement:  make(map[string][]string),
+		Health: SnapshotHealth{
+			Services: map[string]bool{
+				"p2p":       s.p2p != nil,
+				"consensus": s.consensus != nil,
+				"scheduler": s.scheduler != nil,
+			},
+			Flapping: s.scheduler.FlapStatuses(),
+		},
+		Metrics: SnapshotMetrics{Scheduler: s.scheduler.GetStats()},
+		Errors:  make(map[string]string),
+	}
+
+	for name, model := range s.scheduler.GetAllModels() {
+		snap.Placement[name] = model.Locations
+	}
+	if s.placement != nil {
+		constraints := s.placement.Constraints()
+		snap.Constraints = &constraints
+	}
+
+	if s.compat != nil {
+		status := s.compat.Status()
+		snap.Versions.Cluster = &status
+	}
+
+	if s.failureDetector != nil {
+		snap.Health.Heartbeats = s.failureDetector.Stats()
+	}
+	if s.reputation != nil {
+		snap.Health.Peers = s.reputation.Peers()
+	}
+	if s.maintenance != nil {
+		snap.Health.Maintenance = s.maintenance.Active()
+	}
+	if s.slo != nil {
+		snap.Health.SLOs = s.slo.Status()
+	}
+	if s.watchdog != nil {
+		status := s.watchdog.Status()
+		snap.Health.Watchdog = &status
+	}
+	if s.anomalies != nil {
+		snap.Health.Anomalies = s.anomalies.Anomalies(since)
+	}
+
+	if s.history != nil {
+		summaries, err := summarizeHistory(ctx, s.history, since, snap.Time)
+		if err != nil {
+			snap.Errors["metrics"] = err.Error()
+		}
+		snap.Metrics.History = summaries
+	}
+
+	if s.events != nil {
+		recent, err := s.events.List(ctx, events.Filter{Since: since, Limit: snapshotEventLimit})
+		if err != nil {
+			snap.Errors["events"] = err.Error()
+		}
+		snap.Events = recent
+	}
+
+	if s.nodeConfig != nil {
+		redacted, err := s.nodeConfig.Redacted()
+		if err != nil {
+			snap.Errors["config"] = err.Error()
+		}
+		snap.Config = redacted
+	}
+	return snap
+}
+
+// summarizeHistory summarizes every series of the metrics history over
+// [since, until)
+func summarizeHistory(ctx context.Context, recorder *history.Recorder, since, until time.Time) (map[string][]SeriesSummary, error) {
+	summaries := make(map[string][]SeriesSummary)
+	for _, metric := range recorder.Metrics() {
+		result, err := recorder.Query(ctx, history.Query{Metric: metric, Since: since, Until: until})
+		if err != nil {
+			return summaries, fmt.Errorf("failed to query %s: %w", metric, err)
+		}
+		for _, series := range result.Series {
+			if len(series.Points) == 0 {
+				continue
+			}
+			summary := SeriesSummary{
+				Key:    series.Key,
+				Min:    series.Points[0].Min,
+				Max:    series.Points[0].Max,
+				Last:   series.Points[len(series.Points)-1].Mean,
+				Points: len(series.Points),
+			}
+			var sum float64
+			var count int64
+			for _, point := range series.Points {
+				sum += point.Mean * float64(point.Count)
+				count += point.Count
+				summary.Min = min(summary.Min, point.Min)
+				summary.Max = max(summary.Max, point.Max)
+			}
+			if count > 0 {
+				summary.Mean = sum / float64(count)
+			}
+			summaries[metric] = append(summaries[metric], summary)
+		}
+		sort.Slice(summaries[metric], func(i, j int) bool {
+			return summaries[metric][i].Key < summaries[metric][j].Key
+		})
+	}
+	return summaries, nil
+}
+
+// getClusterSnapshot returns the diagnostics snapshot of the cluster: its
+// membership, model placement, health, metrics, recent events, versions
+// and this node's configuration with secrets redacted. since bounds the
+// events and metrics, as an RFC 3339 time or a duration before now, and
+// defaults to an hour.
+func (s *Server) getClusterSnapshot(c *gin.Context) {
+	since := time.Now().Add(-time.Hour)
+	if value := c.Query("since"); value != "" {
+		parsed, err := parseEventTime(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since: %v", err)})
+			return
+		}
+		since = parsed
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "cluster-snapshot-"+time.Now().UTC().Format("20060102T150405Z")+".json"))
+	c.IndentedJSON(http.StatusOK, s.snapshot(c.Request.Context(), since))
+}
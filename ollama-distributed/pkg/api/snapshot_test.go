@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/events"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterSnapshot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	engine.AddTestNode(&scheduler.NodeInfo{ID: "node-a", Status: scheduler.NodeStatusOnline, Models: []string{"llama3"}})
+	require.NoError(t, engine.RegisterModel("llama3", 1<<30, "", "node-a"))
+
+	log := events.NewLog(events.NewMemoryStore(0), "node-a", nil)
+	_, err = log.Record(context.Background(), events.Event{Type: events.NodeJoined, Subject: "node-a"})
+	require.NoError(t, err)
+	_, err = log.Record(context.Background(), events.Event{Type: events.NodeLeft, Subject: "node-old", Time: time.Now().Add(-2 * time.Hour)})
+	require.NoError(t, err)
+
+	recorder := history.NewRecorder(history.Config{Enabled: true}, history.NewMemoryStore())
+	load := 10.0
+	recorder.AddSource(func() []history.Sample {
+		load += 10
+		return []history.Sample{{Metric: "cluster_load", Value: load}}
+	})
+	require.NoError(t, recorder.Sample(context.Background()))
+	require.NoError(t, recorder.Sample(context.Background()))
+
+	cfg := config.DefaultConfig()
+	cfg.Security.Auth.SecretKey = "do-not-leak-this-signing-secret"
+
+	s := &Server{scheduler: engine}
+	s.SetEvents(log)
+	s.SetHistory(recorder)
+	s.SetDiagnostics("1.4.2", cfg)
+	router := gin.New()
+	router.GET("/api/v1/cluster/snapshot", s.getClusterSnapshot)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "do-not-leak-this-signing-secret")
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment"))
+
+	var snap Snapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snap))
+	assert.Equal(t, "1.4.2", snap.Versions.Node)
+	assert.NotEmpty(t, snap.Versions.Go)
+	require.Len(t, snap.Membership.Nodes, 1)
+	assert.Equal(t, []string{"node-a"}, snap.Placement["llama3"])
+	assert.True(t, snap.Health.Services["scheduler"])
+	assert.Equal(t, 1, snap.Metrics.Scheduler.NodesOnline)
+	require.Len(t, snap.Events, 1, "events before the window are left out")
+	assert.Equal(t, events.NodeJoined, snap.Events[0].Type)
+	require.Len(t, snap.Metrics.History["cluster_load"], 1)
+	summary := snap.Metrics.History["cluster_load"][0]
+	assert.Equal(t, 20.0, summary.Min)
+	assert.Equal(t, 30.0, summary.Max)
+	assert.Equal(t, "[REDACTED]", snap.Config["security"].(map[string]interface{})["auth"].(map[string]interface{})["secret_key"])
+	assert.Empty(t, snap.Errors)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/snapshot?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
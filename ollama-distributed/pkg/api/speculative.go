@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+)
+
+// SetSpeculative exposes speculative decoding, coordinated by an
+// orchestration engine, on /api/v1/speculative
+func (s *Server) SetSpeculative(engine *orchestration.OrchestrationEngine) {
+	s.orchestrator = engine
+}
+
+// SpeculativeRequest is a raw prompt to continue with speculative decoding
+type SpeculativeRequest struct {
+	Model     string `json:"model" binding:"required"`
+	Prompt    string `json:"prompt" binding:"required"`
+	MaxTokens int    `json:"max_tokens"`
+}
+
+// getSpeculativeStats returns the acceptance rate, latency and state of
+// every draft and target pair
+func (s *Server) getSpeculativeStats(c *gin.Context) {
+	if s.orchestrator == nil || s.orchestrator.Speculative() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "speculative decoding not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pairs": s.orchestrator.Speculative().Stats()})
+}
+
+// speculativeGenerate continues a prompt with a target model, speculating
+// with its draft model
+func (s *Server) speculativeGenerate(c *gin.Context) {
+	if s.orchestrator == nil || s.orchestrator.Speculative() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "speculative decoding not enabled"})
+		return
+	}
+
+	var req SpeculativeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.admitTenantRequest(c, req.Model) {
+		return
+	}
+
+	result, err := s.orchestrator.Speculate(c.Request.Context(), req.Model, req.Prompt, req.MaxTokens)
+	if errors.Is(err, speculative.ErrNoPair) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
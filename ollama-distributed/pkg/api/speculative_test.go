@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeculativeGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"response": "ok", "done": false}`)
+		fmt.Fprintln(w, `{"response": "", "done": true, "done_reason": "stop"}`)
+	}))
+	defer ollama.Close()
+
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/speculative", s.getSpeculativeStats)
+	router.POST("/api/v1/speculative/generate", s.speculativeGenerate)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/speculative", "").Code)
+	engine := orchestration.NewOrchestrationEngine(&orchestration.Config{TaskTimeout: time.Minute})
+	engine.SetSpeculative(speculative.NewService(speculative.Config{
+		Enabled: true,
+		Pairs:   []speculative.Pair{{Target: "llama3:70b", Draft: "llama3:8b"}},
+	}, func(model string) []string { return []string{ollama.URL} }))
+	s.SetSpeculative(engine)
+
+	rec := do(http.MethodPost, "/api/v1/speculative/generate", `{"model": "llama3:70b", "prompt": "hi"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result speculative.Result
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "ok", result.Text)
+	assert.True(t, result.Speculative)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/speculative/generate",
+		`{"model": "mistral", "prompt": "hi"}`).Code)
+
+	rec = do(http.MethodGet, "/api/v1/speculative", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats struct {
+		Pairs []speculative.Stats `json:"pairs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats.Pairs, 1)
+	assert.Equal(t, int64(1), stats.Pairs[0].Generations)
+}
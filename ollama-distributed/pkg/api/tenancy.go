@@ -0,0 +1,283 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+)
+
+// SetTenancy enables tenant API keys. Callers authenticated with a tenant's
+// key see only that tenant's models, conversations, templates and usage;
+// other callers act for the whole cluster.
+func (s *Server) SetTenancy(registry *tenancy.Registry) {
+	s.tenants = registry
+}
+
+// authenticateAPIKey admits a tenant's API key and populates the same
+// context keys as JWT authentication, plus the tenant
+func (s *Server) authenticateAPIKey(c *gin.Context, key string) bool {
+	if s.tenants == nil {
+		return false
+	}
+
+	tenant, ok := s.tenants.Authenticate(key)
+	if !ok {
+		return false
+	}
+
+	roles := tenant.Roles
+	if len(roles) == 0 {
+		roles = []string{"user"}
+	}
+	c.Set("user_id", "apikey:"+tenant.ID)
+	c.Set("username", tenant.ID)
+	c.Set("roles", roles)
+	c.Set("tenant", tenant.ID)
+	c.Set("output_limits", s.tenants.OutputLimits(key))
+	c.Set("auth_method", "api_key")
+	return true
+}
+
+// callerTenant returns the tenant of the caller, if it has one
+func (s *Server) callerTenant(c *gin.Context) (tenancy.Tenant, bool) {
+	id := c.GetString("tenant")
+	if s.tenants == nil || id == "" {
+		return tenancy.Tenant{}, false
+	}
+	tenant, err := s.tenants.Get(id)
+	if err != nil {
+		return tenancy.Tenant{}, false
+	}
+	return tenant, true
+}
+
+// visibleModels returns the registered models the caller sees, by the name
+// it sees them under
+func (s *Server) visibleModels(c *gin.Context) map[string]*scheduler.ModelInfo {
+	models := s.scheduler.GetAllModels()
+	tenant, ok := s.callerTenant(c)
+	if !ok {
+		return models
+	}
+
+	visible := make(map[string]*scheduler.ModelInfo)
+	for registered, model := range models {
+		if name, ok := s.tenants.ModelView(tenant.ID, registered); ok {
+			visible[name] = model
+		}
+	}
+	return visible
+}
+
+// resolveModel returns the name a model the caller refers to is registered
+// under: the tenant's own model before a shared one of the same name
+func (s *Server) resolveModel(c *gin.Context, name string) (string, *scheduler.ModelInfo, bool) {
+	tenant, ok := s.callerTenant(c)
+	if !ok {
+		model, exists := s.scheduler.GetModel(name)
+		return name, model, exists
+	}
+
+	for _, registered := range s.tenants.ResolveModel(tenant.ID, name) {
+		if model, exists := s.scheduler.GetModel(registered); exists {
+			return registered, model, true
+		}
+	}
+	return "", nil, false
+}
+
+// admissionError is why a request was not admitted, with the response
+// that tells the caller
+type admissionError struct {
+	status int
+	body   gin.H
+}
+
+func (e *admissionError) Error() string {
+	msg, _ := e.body["error"].(string)
+	return msg
+}
+
+// admitTenantRequest checks that the caller's tenant may use the model of an
+// inference request, under its license too, and counts it towards the
+// tenant's usage. It reports whether the request may proceed, writing the
+// response otherwise.
+func (s *Server) admitTenantRequest(c *gin.Context, model string) bool {
+	err := s.checkTenantRequest(c, model)
+	var denied *admissionError
+	if errors.As(err, &denied) {
+		c.JSON(denied.status, denied.body)
+		return false
+	}
+	return true
+}
+
+// checkTenantRequest is admitTenantRequest without the response, returning
+// an *admissionError when the request may not proceed
+func (s *Server) checkTenantRequest(c *gin.Context, model string) error {
+	err := s.checkTenantModel(c, model)
+	if tenant, ok := s.callerTenant(c); ok {
+		s.tenants.RecordUsage(tenant.ID, model, err != nil)
+	}
+	return err
+}
+
+// checkTenantModel checks that the caller's tenant may use a model under
+// its license, without counting a request
+func (s *Server) checkTenantModel(c *gin.Context, model string) error {
+	tenant, ok := s.callerTenant(c)
+	if !ok {
+		return s.checkLicense(c, tenancy.Tenant{}, model)
+	}
+
+	_, _, registered := s.resolveModel(c, model)
+	if _, shared := s.tenants.ModelView(tenant.ID, model); !registered && !shared {
+		return &admissionError{status: http.StatusForbidden, body: gin.H{"error": "model not available to tenant " + tenant.ID}}
+	}
+	return s.checkLicense(c, tenant, model)
+}
+
+// getTenant returns the caller's tenant and its usage
+func (s *Server) getTenant(c *gin.Context) {
+	tenant, ok := s.callerTenant(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "caller does not belong to a tenant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenant": tenant, "usage": s.tenants.Usage(tenant.ID)})
+}
+
+// getTenants lists every tenant with its usage
+func (s *Server) getTenants(c *gin.Context) {
+	if s.tenants == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "tenancy not enabled"})
+		return
+	}
+
+	type tenantUsage struct {
+		tenancy.Tenant
+		Usage tenancy.Usage `json:"usage"`
+	}
+	tenants := s.tenants.List()
+	result := make([]tenantUsage, len(tenants))
+	for i, t := range tenants {
+		result[i] = tenantUsage{Tenant: t, Usage: s.tenants.Usage(t.ID)}
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": result})
+}
+
+// getTemplates lists the caller's prompt templates
+func (s *Server) getTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"templates": s.templates.List(c.GetString("tenant"))})
+}
+
+// getTemplate returns one of the caller's prompt templates
+func (s *Server) getTemplate(c *gin.Context) {
+	tmpl, err := s.templates.Get(c.GetString("tenant"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": tmpl})
+}
+
+// putTemplate creates or replaces one of the caller's prompt templates
+func (s *Server) putTemplate(c *gin.Context) {
+	var tmpl tenancy.Template
+	if err := c.ShouldBindJSON(&tmpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if tmpl.Template == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template is empty"})
+		return
+	}
+
+	tmpl.Name = c.Param("name")
+	tmpl.UpdatedAt = time.Now()
+	s.templates.Put(c.GetString("tenant"), tmpl.Name, tmpl)
+	c.JSON(http.StatusOK, gin.H{"template": tmpl})
+}
+
+// deleteTemplate removes one of the caller's prompt templates
+func (s *Server) deleteTemplate(c *gin.Context) {
+	if err := s.templates.Delete(c.GetString("tenant"), c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// getConversations lists the caller's conversations
+func (s *Server) getConversations(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"conversations": s.conversations.List(c.GetString("tenant"))})
+}
+
+// createConversation starts a conversation for the caller
+func (s *Server) createConversation(c *gin.Context) {
+	var conv tenancy.Conversation
+	if err := c.ShouldBindJSON(&conv); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if conv.Model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required"})
+		return
+	}
+
+	conv.ID = uuid.NewString()
+	conv.CreatedAt = time.Now()
+	conv.UpdatedAt = conv.CreatedAt
+	if conv.Messages == nil {
+		conv.Messages = []tenancy.Message{}
+	}
+	s.conversations.Put(c.GetString("tenant"), conv.ID, conv)
+	c.JSON(http.StatusCreated, gin.H{"conversation": conv})
+}
+
+// getConversation returns one of the caller's conversations
+func (s *Server) getConversation(c *gin.Context) {
+	conv, err := s.conversations.Get(c.GetString("tenant"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation": conv})
+}
+
+// appendConversation adds messages to one of the caller's conversations
+func (s *Server) appendConversation(c *gin.Context) {
+	var req struct {
+		Messages []tenancy.Message `json:"messages" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conv, err := s.conversations.Update(c.GetString("tenant"), c.Param("id"), func(conv tenancy.Conversation) tenancy.Conversation {
+		conv.Messages = append(append([]tenancy.Message(nil), conv.Messages...), req.Messages...)
+		conv.UpdatedAt = time.Now()
+		return conv
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation": conv})
+}
+
+// deleteConversation removes one of the caller's conversations
+func (s *Server) deleteConversation(c *gin.Context) {
+	if err := s.conversations.Delete(c.GetString("tenant"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted"})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantServer(t *testing.T) (*Server, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, engine.RegisterModel("llama3", 1, "", "node-1"))
+	require.NoError(t, engine.RegisterModel("mistral", 1, "", "node-1"))
+	require.NoError(t, engine.RegisterModel("acme/custom", 1, "", "node-1"))
+	require.NoError(t, engine.RegisterModel("globex/custom", 1, "", "node-1"))
+
+	registry, err := tenancy.NewRegistry(tenancy.Config{Enabled: true, Tenants: []tenancy.Tenant{
+		{ID: "acme", APIKeys: []string{"acme-key"}, SharedModels: []string{"llama3"}},
+		{ID: "globex", APIKeys: []string{"globex-key"}},
+	}})
+	require.NoError(t, err)
+
+	s := &Server{
+		scheduler:     engine,
+		conversations: tenancy.NewStore[tenancy.Conversation](),
+		templates:     tenancy.NewStore[tenancy.Template](),
+	}
+	s.SetTenancy(registry)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if !s.authenticateAPIKey(c, extractToken(c)) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	})
+	router.GET("/api/v1/models", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"models": s.visibleModels(c)})
+	})
+	router.GET("/api/v1/models/:name", func(c *gin.Context) {
+		registered, _, exists := s.resolveModel(c, c.Param("name"))
+		if !exists {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"registered": registered})
+	})
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		if s.admitTenantRequest(c, c.Query("model")) {
+			c.JSON(http.StatusOK, gin.H{"done": true})
+		}
+	})
+	router.GET("/api/v1/tenant", s.getTenant)
+	router.GET("/api/v1/templates", s.getTemplates)
+	router.PUT("/api/v1/templates/:name", s.putTemplate)
+	return s, router
+}
+
+func tenantRequest(router *gin.Engine, key, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenantModelIsolation(t *testing.T) {
+	_, router := newTenantServer(t)
+
+	rec := tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/models", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var result struct {
+		Models map[string]json.RawMessage `json:"models"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.ElementsMatch(t, []string{"custom", "llama3"}, modelNames(result.Models))
+
+	rec = tenantRequest(router, "nobody", http.MethodGet, "/api/v1/models", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/generate?model=mistral", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code, "acme does not share mistral")
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/generate?model=custom", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/models/custom", "")
+	assert.JSONEq(t, `{"registered": "globex/custom"}`, rec.Body.String())
+	rec = tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/models/acme%2Fcustom", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "other tenants' models are hidden")
+
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/tenant", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tenant struct {
+		Usage tenancy.Usage `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tenant))
+	assert.Equal(t, int64(2), tenant.Usage.Requests)
+	assert.Equal(t, int64(1), tenant.Usage.Failures)
+}
+
+func TestTenantTemplates(t *testing.T) {
+	_, router := newTenantServer(t)
+
+	rec := tenantRequest(router, "acme-key", http.MethodPut, "/api/v1/templates/summary", `{"template": "Summarize: {{.Prompt}}"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/templates", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"templates": []}`, rec.Body.String())
+
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/templates", "")
+	assert.Contains(t, rec.Body.String(), "Summarize")
+}
+
+func modelNames(m map[string]json.RawMessage) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
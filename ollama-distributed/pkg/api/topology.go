@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+)
+
+// SetTopology exposes the latencies measured between nodes on
+// /api/v1/cluster/topology
+func (s *Server) SetTopology(matrix *topology.Matrix) {
+	s.topology = matrix
+}
+
+// getClusterTopology returns the RTTs measured between the cluster's nodes
+func (s *Server) getClusterTopology(c *gin.Context) {
+	if s.topology == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "topology not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, s.topology.Snapshot())
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClusterTopology(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/cluster/topology", s.getClusterTopology)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/topology", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	matrix := topology.NewMatrix("node-1", topology.Config{})
+	matrix.Observe("node-2", 4*time.Millisecond)
+	matrix.Merge("node-2", map[string]time.Duration{"node-1": 6 * time.Millisecond})
+	s.SetTopology(matrix)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/topology", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body topology.Topology
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "node-1", body.Self)
+	assert.Equal(t, []string{"node-1", "node-2"}, body.Nodes)
+	assert.Len(t, body.Links, 2)
+	assert.Equal(t, 5.0, body.Latencies["node-1"]["node-2"])
+}
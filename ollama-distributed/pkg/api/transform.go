@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+)
+
+// SetTransforms runs the configured transformation hooks over inference
+// requests and responses, and lists the hooks on /api/v1/hooks
+func (s *Server) SetTransforms(engine *transform.Engine) {
+	s.transforms = engine
+}
+
+// TransformMiddleware runs the request hooks applying to the caller's
+// tenant and the requested model over the request body before the handler
+// schedules it, and the response hooks over the text generated
+func (s *Server) TransformMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.transforms == nil || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &req)
+		scope := transform.Scope{Tenant: c.GetString("tenant"), Model: req.Model, Endpoint: path.Base(c.FullPath())}
+
+		body, err = s.transforms.TransformRequest(c.Request.Context(), scope, body)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, transform.ErrRejected) {
+				status = http.StatusForbidden
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+
+		if !s.transforms.TransformsResponses(scope) {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		writer := transform.NewWriter(ctx, c.Writer, s.transforms, scope, cancel)
+		c.Writer = writer
+
+		c.Next()
+
+		if err := writer.Finish(); err != nil {
+			slog.Debug("Failed to finish transformed response", "model", scope.Model, "error", err)
+		}
+		if err := writer.Ended(); err != nil {
+			slog.Info("Response ended by transformation hook", "tenant", scope.Tenant, "model", scope.Model, "error", err)
+		}
+	}
+}
+
+// getHooks lists the transformation hooks of both stages in the order they
+// run, with their counters, and the processors hooks may use
+func (s *Server) getHooks(c *gin.Context) {
+	if s.transforms == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "transformation hooks not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"hooks":      s.transforms.Hooks(),
+		"processors": transform.Processors(),
+	})
+}
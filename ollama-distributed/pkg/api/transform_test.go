@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransformMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := transform.NewEngine(transform.Config{Enabled: true, Hooks: []transform.HookConfig{
+		{Name: "guidelines", Processor: "system_prompt", Stage: transform.StageRequest, Tenants: []string{"acme"},
+			Options: map[string]string{"text": "Answer in English."}},
+		{Name: "no-secrets", Processor: "word_filter", Stage: transform.StageRequest,
+			Options: map[string]string{"words": "password", "action": "reject"}},
+		{Name: "scrub", Processor: "redact", Stage: transform.StageResponse, Models: []string{"llama3*"},
+			Options: map[string]string{"entities": "email"}},
+	}})
+	require.NoError(t, err)
+	s := &Server{}
+	s.SetTransforms(engine)
+
+	type generateRequest struct {
+		Model  string `json:"model"`
+		System string `json:"system"`
+	}
+	var received generateRequest
+	router := gin.New()
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		c.Set("tenant", c.GetHeader("X-Tenant"))
+	}, s.TransformMiddleware(), func(c *gin.Context) {
+		received = generateRequest{}
+		require.NoError(t, c.ShouldBindJSON(&received))
+		c.JSON(http.StatusOK, gin.H{"model": received.Model, "response": "Write to ops@example.com", "done": true})
+	})
+	router.GET("/api/v1/hooks", s.getHooks)
+
+	generate := func(tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/generate", strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := generate("acme", `{"model":"llama3","prompt":"Who do I contact?"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Answer in English.", received.System, "the tenant's guidelines reach the handler")
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotContains(t, resp["response"], "ops@example.com")
+
+	w = generate("globex", `{"model":"mistral","prompt":"Who do I contact?"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, received.System)
+	assert.Contains(t, w.Body.String(), "ops@example.com", "response hooks of other models do not run")
+
+	w = generate("acme", `{"model":"llama3","prompt":"What is the admin password?"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "hook no-secrets")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hooks", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var hooks struct {
+		Hooks []transform.HookStatus `json:"hooks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hooks))
+	require.Len(t, hooks.Hooks, 3)
+	assert.Equal(t, int64(1), hooks.Hooks[1].Rejected)
+}
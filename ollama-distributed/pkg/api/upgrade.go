@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+)
+
+// SetUpgradeCoordinator lets nodes updating themselves take turns to
+// restart through /api/v1/cluster/upgrade/lease
+func (s *Server) SetUpgradeCoordinator(coordinator *selfupdate.Coordinator) {
+	s.upgrades = coordinator
+}
+
+// acquireUpgradeLeaseRequest names the node about to restart and the
+// version it restarts into
+type acquireUpgradeLeaseRequest struct {
+	NodeID  string `json:"node_id" binding:"required"`
+	Version string `json:"version"`
+}
+
+// upgradeLeaseError answers a failed lease operation, pointing followers
+// at the leader
+func (s *Server) upgradeLeaseError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, selfupdate.ErrNotLeader):
+		leader := ""
+		if s.consensus != nil {
+			leader = s.consensus.Leader()
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "leader": leader})
+	case errors.Is(err, selfupdate.ErrBusy), errors.Is(err, selfupdate.ErrUnsafe):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// getUpgradeLease returns the node restarting into a new version, if any
+func (s *Server) getUpgradeLease(c *gin.Context) {
+	if s.upgrades == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upgrade coordination not enabled"})
+		return
+	}
+	lease, err := s.upgrades.Current()
+	if err != nil {
+		s.upgradeLeaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lease": lease})
+}
+
+// acquireUpgradeLease grants a node the lease to restart, once no other
+// node holds it and every other node is online
+func (s *Server) acquireUpgradeLease(c *gin.Context) {
+	if s.upgrades == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upgrade coordination not enabled"})
+		return
+	}
+	var req acquireUpgradeLeaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lease, err := s.upgrades.Acquire(req.NodeID, req.Version, func() error {
+		return s.othersOnline(req.NodeID)
+	})
+	if err != nil {
+		s.upgradeLeaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, lease)
+}
+
+// releaseUpgradeLease ends the lease of a node that restarted
+func (s *Server) releaseUpgradeLease(c *gin.Context) {
+	if s.upgrades == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upgrade coordination not enabled"})
+		return
+	}
+	nodeID := c.Param("node")
+	released, err := s.upgrades.Release(nodeID)
+	if err != nil {
+		s.upgradeLeaseError(c, err)
+		return
+	}
+	if !released {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("node %s does not hold the upgrade lease", nodeID)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Upgrade lease released", "node_id": nodeID})
+}
+
+// othersOnline says which nodes other than nodeID are not online, as the
+// cluster is already short of them
+func (s *Server) othersOnline(nodeID string) error {
+	if s.scheduler == nil {
+		return nil
+	}
+	var down []string
+	for _, node := range s.scheduler.ListNodes() {
+		if node.ID != nodeID && node.Status != scheduler.NodeStatusOnline {
+			down = append(down, fmt.Sprintf("%s is %s", node.ID, node.Status))
+		}
+	}
+	if len(down) > 0 {
+		return errors.New(strings.Join(down, ", "))
+	}
+	return nil
+}
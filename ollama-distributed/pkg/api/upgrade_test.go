@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/selfupdate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeLease(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	engine.AddTestNode(&scheduler.NodeInfo{ID: "node-a", Status: scheduler.NodeStatusOnline})
+	engine.AddTestNode(&scheduler.NodeInfo{ID: "node-b", Status: scheduler.NodeStatusOffline})
+
+	s := &Server{scheduler: engine}
+	s.SetUpgradeCoordinator(selfupdate.NewCoordinator(time.Minute, nil))
+	router := gin.New()
+	router.GET("/api/v1/cluster/upgrade/lease", s.getUpgradeLease)
+	router.POST("/api/v1/cluster/upgrade/lease", s.acquireUpgradeLease)
+	router.DELETE("/api/v1/cluster/upgrade/lease/:node", s.releaseUpgradeLease)
+	acquire := func(nodeID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"node_id":"` + nodeID + `","version":"v1.5.0"}`
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/cluster/upgrade/lease", strings.NewReader(body)))
+		return w
+	}
+
+	w := acquire("node-a")
+	require.Equal(t, http.StatusConflict, w.Code, "node-a may not restart while node-b is offline")
+	assert.Contains(t, w.Body.String(), "node-b is offline")
+
+	w = acquire("node-b")
+	require.Equal(t, http.StatusOK, w.Code, "the offline node itself may restart: %s", w.Body.String())
+	w = acquire("node-a")
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "another node is restarting")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/upgrade/lease", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var current struct {
+		Lease *selfupdate.Lease `json:"lease"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &current))
+	require.NotNil(t, current.Lease)
+	assert.Equal(t, "node-b", current.Lease.NodeID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/cluster/upgrade/lease/node-a", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/cluster/upgrade/lease/node-b", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/outputlimit"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/usage"
+)
+
+// SetUsage records the usage of every model to tracker, and reports it on
+// /api/v1/analytics/models from the metrics history
+func (s *Server) SetUsage(tracker *usage.Tracker) {
+	s.usage = tracker
+}
+
+// UsageMiddleware records the model, tenant, latency, generated tokens and
+// outcome of an inference request
+func (s *Server) UsageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.usage == nil || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if json.Unmarshal(body, &payload) != nil || payload.Model == "" {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		writer := outputlimit.NewWriter(c.Writer, outputlimit.Limits{}, func() {})
+		c.Writer = writer
+
+		c.Next()
+
+		writer.Finish(context.Background())
+		s.usage.Observe(usage.Request{
+			Model:   payload.Model,
+			Tenant:  c.GetString("tenant"),
+			Latency: time.Since(start),
+			Tokens:  writer.Tokens(),
+			Failed:  writer.Status() >= http.StatusInternalServerError,
+		})
+	}
+}
+
+// getModelAnalytics reports the requests, unique tenants, latency, token
+// throughput and error rate of every model over a window. since and until
+// are RFC 3339 times or durations before now, as for the metrics history;
+// max_points bounds the trend points per model.
+func (s *Server) getModelAnalytics(c *gin.Context) {
+	if s.usage == nil || s.history == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model usage analytics not enabled"})
+		return
+	}
+
+	since, err := parseEventTime(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since: %v", err)})
+		return
+	}
+	until, err := parseEventTime(c.Query("until"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid until: %v", err)})
+		return
+	}
+	maxPoints := 0
+	if value := c.Query("max_points"); value != "" {
+		if maxPoints, err = strconv.Atoi(value); err != nil || maxPoints <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_points must be a positive number"})
+			return
+		}
+	}
+
+	report, err := usage.Summarize(c.Request.Context(), s.history, since, until, maxPoints)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
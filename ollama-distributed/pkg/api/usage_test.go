@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelAnalytics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	withTenant := func(c *gin.Context) {
+		c.Set("tenant", c.GetHeader("X-Tenant"))
+		c.Next()
+	}
+	router.POST("/api/v1/generate", withTenant, s.UsageMiddleware(), func(c *gin.Context) {
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "no node available"})
+			return
+		}
+		c.Writer.WriteString(`{"response":"Hel","done":false}` + "\n")
+		c.Writer.WriteString(`{"response":"lo","done":true,"eval_count":2}` + "\n")
+	})
+	router.GET("/api/v1/analytics/models", s.getModelAnalytics)
+
+	generate := func(model, tenant, query string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/generate"+query, strings.NewReader(`{"model":"`+model+`","prompt":"hi"}`))
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	analytics := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/analytics/models"+query, nil))
+		return rec
+	}
+
+	// Without a tracker requests pass through untouched
+	assert.Equal(t, http.StatusOK, generate("llama3", "acme", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, analytics("").Code)
+
+	recorder := history.NewRecorder(history.Config{Enabled: true}, history.NewMemoryStore())
+	tracker := usage.NewTracker()
+	recorder.AddSource(tracker.Source())
+	s.SetHistory(recorder)
+	s.SetUsage(tracker)
+
+	assert.Equal(t, http.StatusOK, generate("llama3", "acme", ""))
+	assert.Equal(t, http.StatusOK, generate("llama3", "globex", ""))
+	assert.Equal(t, http.StatusOK, generate("mistral", "acme", ""))
+	assert.Equal(t, http.StatusInternalServerError, generate("mistral", "acme", "?fail=1"))
+	require.NoError(t, recorder.Sample(context.Background()))
+
+	rec := analytics("?since=1h")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report usage.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Models, 2)
+	byModel := map[string]usage.ModelUsage{}
+	for _, m := range report.Models {
+		byModel[m.Model] = m
+	}
+	assert.Equal(t, 2, byModel["llama3"].UniqueTenants)
+	assert.Zero(t, byModel["llama3"].ErrorRate)
+	assert.Equal(t, 1, byModel["mistral"].UniqueTenants)
+	assert.InDelta(t, 0.5, byModel["mistral"].ErrorRate, 0.01)
+	assert.Greater(t, byModel["llama3"].TokensPerSecond, 0.0)
+
+	assert.Equal(t, http.StatusBadRequest, analytics("?since=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, analytics("?max_points=0").Code)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
+)
+
+// SetUsers enables the local account directory, which authenticates
+// logins and is managed under /admin/users
+func (s *Server) SetUsers(directory *users.Directory) {
+	s.users = directory
+}
+
+// resetPasswordRequest carries a user's new password
+type resetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// usersEnabled writes 503 unless the directory is set
+func (s *Server) usersEnabled(c *gin.Context) bool {
+	if s.users == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "user management not enabled"})
+		return false
+	}
+	return true
+}
+
+// writeUserError maps directory errors to status codes
+func writeUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, users.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, users.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, users.ErrExists), errors.Is(err, users.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("User management failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user management failed"})
+	}
+}
+
+// endSessions logs a user out of the dashboard after a change to their
+// account, so that it takes effect at once
+func (s *Server) endSessions(username string) {
+	if s.sessions != nil {
+		s.sessions.RevokeAll(username)
+	}
+}
+
+// listUsers returns the local accounts
+func (s *Server) listUsers(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	list, err := s.users.List(c.Request.Context())
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": list})
+}
+
+// getUser returns a local account
+func (s *Server) getUser(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	user, err := s.users.Get(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// createUser adds a local account
+func (s *Server) createUser(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	var req users.NewUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := s.users.Create(c.Request.Context(), req)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// updateUser changes the email, roles or disabled flag of a local account
+func (s *Server) updateUser(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	var change users.Change
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := s.users.Update(c.Request.Context(), c.Param("username"), change)
+	if err != nil {
+		writeUserError(c, err)
+		return
+	}
+	if change.Roles != nil || change.Disabled != nil {
+		s.endSessions(user.Username)
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// resetUserPassword replaces the password of a local account
+func (s *Server) resetUserPassword(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := c.Param("username")
+	if err := s.users.ResetPassword(c.Request.Context(), username, req.Password); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	s.endSessions(username)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+}
+
+// deleteUser removes a local account
+func (s *Server) deleteUser(c *gin.Context) {
+	if !s.usersEnabled(c) {
+		return
+	}
+	username := c.Param("username")
+	if err := s.users.Delete(c.Request.Context(), username); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	s.endSessions(username)
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
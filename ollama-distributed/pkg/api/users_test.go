@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUsersRouter(s *Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/api/v1/admin/users")
+	admin.GET("", s.listUsers)
+	admin.POST("", s.createUser)
+	admin.GET("/:username", s.getUser)
+	admin.PATCH("/:username", s.updateUser)
+	admin.POST("/:username/password", s.resetUserPassword)
+	admin.DELETE("/:username", s.deleteUser)
+	return router
+}
+
+func TestUserManagement(t *testing.T) {
+	s := &Server{}
+	router := newUsersRouter(s)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/admin/users"+path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "", "").Code)
+
+	directory := users.NewDirectory(users.NewMemoryStore(), users.Config{MinPasswordLength: 8})
+	_, err := directory.Create(context.Background(), users.NewUser{Username: "alice", Password: "alice-password", Roles: []string{"admin"}})
+	require.NoError(t, err)
+	s.SetUsers(directory)
+
+	rec := do(http.MethodPost, "", `{"username": "bob", "password": "bob-password", "roles": ["operator"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "password")
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "", `{"username": "bob", "password": "bob-password"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "", `{"username": "carol", "password": "short"}`).Code)
+
+	rec = do(http.MethodGet, "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"username":"alice"`)
+	assert.Contains(t, rec.Body.String(), `"username":"bob"`)
+
+	rec = do(http.MethodPatch, "/bob", `{"roles": ["viewer"], "disabled": true}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"roles":["viewer"]`)
+	assert.Contains(t, rec.Body.String(), `"disabled":true`)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPatch, "/alice", `{"disabled": true}`).Code, "the last admin")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPatch, "/nobody", `{}`).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/alice/password", `{"password": "new-alice-password"}`).Code)
+	_, err = directory.Authenticate("alice", "new-alice-password")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/alice/password", `{}`).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/bob", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/bob", "").Code)
+}
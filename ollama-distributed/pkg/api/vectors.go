@@ -0,0 +1,245 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/tenancy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+)
+
+// maxUpsertDocuments bounds the documents of one upsert request
+const maxUpsertDocuments = 1000
+
+// SetVectorIndex enables the vector index on /api/v1/vectors. Documents and
+// queries given as text are embedded by embedder with the model the request
+// names, or defaultModel.
+func (s *Server) SetVectorIndex(index vectorindex.Index, embedder vectorindex.Embedder, defaultModel string) {
+	s.vectors = index
+	s.embedder = embedder
+	s.embeddingModel = defaultModel
+}
+
+// ModelEndpoints returns the Ollama endpoints of the healthy instances
+// serving a model, for embedding on the cluster
+func (s *Server) ModelEndpoints(model string) []string {
+	targets := s.VerificationTargets(model)
+	endpoints := make([]string, len(targets))
+	for i, target := range targets {
+		endpoints[i] = target.Endpoint
+	}
+	return endpoints
+}
+
+// vectorCollection returns the name a collection the caller refers to is
+// stored under: tenants' collections are scoped like their models
+func (s *Server) vectorCollection(c *gin.Context, name string) string {
+	if tenant, ok := s.callerTenant(c); ok {
+		return tenancy.ScopedModel(tenant.ID, name)
+	}
+	return name
+}
+
+// embedTexts embeds texts with a model on the cluster, writing the error
+// response on failure
+func (s *Server) embedTexts(c *gin.Context, model string, texts []string) ([][]float32, bool) {
+	if model == "" {
+		model = s.embeddingModel
+	}
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required to embed text"})
+		return nil, false
+	}
+	if s.embedder == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "embedding not enabled"})
+		return nil, false
+	}
+	if !s.admitTenantRequest(c, model) {
+		return nil, false
+	}
+
+	if registered, _, exists := s.resolveModel(c, model); exists {
+		model = registered
+	}
+	embeddings, err := s.embedder.Embed(c.Request.Context(), model, texts)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "embedding failed: " + err.Error()})
+		return nil, false
+	}
+	return embeddings, true
+}
+
+// vectorIndexError writes the response for an error of the vector index
+func vectorIndexError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, vectorindex.ErrCollectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+	case errors.Is(err, vectorindex.ErrDimensionMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// getVectorCollections lists the caller's collections
+func (s *Server) getVectorCollections(c *gin.Context) {
+	if s.vectors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "vector index not enabled"})
+		return
+	}
+
+	collections, err := s.vectors.Collections(c.Request.Context())
+	if err != nil {
+		vectorIndexError(c, err)
+		return
+	}
+	tenant, scoped := s.callerTenant(c)
+	visible := []vectorindex.Collection{}
+	for _, collection := range collections {
+		if scoped {
+			name, ok := strings.CutPrefix(collection.Name, tenancy.ScopedModel(tenant.ID, ""))
+			if !ok {
+				continue
+			}
+			collection.Name = name
+		}
+		visible = append(visible, collection)
+	}
+	c.JSON(http.StatusOK, gin.H{"collections": visible})
+}
+
+// upsertVectors adds documents to a collection, embedding on the cluster
+// those given as text without a vector
+func (s *Server) upsertVectors(c *gin.Context) {
+	if s.vectors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "vector index not enabled"})
+		return
+	}
+
+	var req struct {
+		Model     string                 `json:"model"`
+		Documents []vectorindex.Document `json:"documents" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := vectorindex.ValidateCollection(c.Param("collection")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Documents) > maxUpsertDocuments {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "too many documents in one request"})
+		return
+	}
+
+	var texts []string
+	var pending []int
+	for i, doc := range req.Documents {
+		if doc.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "document without an id"})
+			return
+		}
+		if len(doc.Vector) > 0 {
+			continue
+		}
+		if doc.Text == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "document " + doc.ID + " has neither vector nor text"})
+			return
+		}
+		texts = append(texts, doc.Text)
+		pending = append(pending, i)
+	}
+	if len(texts) > 0 {
+		embeddings, ok := s.embedTexts(c, req.Model, texts)
+		if !ok {
+			return
+		}
+		for j, i := range pending {
+			req.Documents[i].Vector = embeddings[j]
+		}
+	}
+
+	if err := s.vectors.Upsert(c.Request.Context(), s.vectorCollection(c, c.Param("collection")), req.Documents); err != nil {
+		vectorIndexError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upserted": len(req.Documents), "embedded": len(texts)})
+}
+
+// queryVectors returns the documents of a collection most similar to a
+// vector, or to a text embedded on the cluster
+func (s *Server) queryVectors(c *gin.Context) {
+	if s.vectors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "vector index not enabled"})
+		return
+	}
+
+	var req struct {
+		Vector []float32         `json:"vector"`
+		Text   string            `json:"text"`
+		Model  string            `json:"model"`
+		K      int               `json:"k"`
+		Filter map[string]string `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Vector) == 0 {
+		if req.Text == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vector or text is required"})
+			return
+		}
+		embeddings, ok := s.embedTexts(c, req.Model, []string{req.Text})
+		if !ok {
+			return
+		}
+		req.Vector = embeddings[0]
+	}
+
+	matches, err := s.vectors.Query(c.Request.Context(), s.vectorCollection(c, c.Param("collection")), req.Vector, req.K, req.Filter)
+	if err != nil {
+		vectorIndexError(c, err)
+		return
+	}
+	if matches == nil {
+		matches = []vectorindex.Match{}
+	}
+	c.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// deleteVector removes a document from a collection
+func (s *Server) deleteVector(c *gin.Context) {
+	if s.vectors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "vector index not enabled"})
+		return
+	}
+
+	n, err := s.vectors.Delete(c.Request.Context(), s.vectorCollection(c, c.Param("collection")), []string{c.Param("id")})
+	if err != nil {
+		vectorIndexError(c, err)
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted"})
+}
+
+// dropVectorCollection removes a collection with its documents
+func (s *Server) dropVectorCollection(c *gin.Context) {
+	if s.vectors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "vector index not enabled"})
+		return
+	}
+
+	if err := s.vectors.Drop(c.Request.Context(), s.vectorCollection(c, c.Param("collection"))); err != nil {
+		vectorIndexError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted"})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lengthEmbedder embeds a text as its length and a constant
+type lengthEmbedder struct{ models []string }
+
+func (e *lengthEmbedder) Embed(_ context.Context, model string, texts []string) ([][]float32, error) {
+	e.models = append(e.models, model)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 10}
+	}
+	return embeddings, nil
+}
+
+func TestVectorIndexAPI(t *testing.T) {
+	s, router := newTenantServer(t)
+	index, err := vectorindex.OpenHNSW(vectorindex.Config{})
+	require.NoError(t, err)
+	embedder := &lengthEmbedder{}
+	s.SetVectorIndex(index, embedder, "llama3")
+	router.GET("/api/v1/vectors", s.getVectorCollections)
+	router.POST("/api/v1/vectors/:collection/documents", s.upsertVectors)
+	router.POST("/api/v1/vectors/:collection/query", s.queryVectors)
+	router.DELETE("/api/v1/vectors/:collection/documents/:id", s.deleteVector)
+
+	rec := tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/vectors/docs/documents",
+		`{"documents": [{"id": "short", "text": "hi"}, {"id": "long", "text": "a much longer text"}, {"id": "raw", "vector": [0, 1]}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"upserted": 3, "embedded": 2}`, rec.Body.String())
+	assert.Equal(t, []string{"llama3"}, embedder.models)
+
+	// Collections are scoped to the tenant
+	collections, err := index.Collections(context.Background())
+	require.NoError(t, err)
+	require.Len(t, collections, 1)
+	assert.Equal(t, "acme/docs", collections[0].Name)
+	rec = tenantRequest(router, "acme-key", http.MethodGet, "/api/v1/vectors", "")
+	assert.JSONEq(t, `{"collections": [{"name": "docs", "dimensions": 2, "documents": 3}]}`, rec.Body.String())
+	rec = tenantRequest(router, "globex-key", http.MethodGet, "/api/v1/vectors", "")
+	assert.JSONEq(t, `{"collections": []}`, rec.Body.String())
+	rec = tenantRequest(router, "globex-key", http.MethodPost, "/api/v1/vectors/docs/query", `{"vector": [1, 0]}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/vectors/docs/query", `{"text": "another long text", "k": 1}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result struct {
+		Matches []vectorindex.Match `json:"matches"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Len(t, result.Matches, 1)
+	assert.Equal(t, "long", result.Matches[0].ID)
+
+	// Texts need a model the tenant may use
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/vectors/docs/query", `{"text": "x", "model": "mistral"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = tenantRequest(router, "acme-key", http.MethodPost, "/api/v1/vectors/docs/query", `{"vector": [1, 2, 3]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tenantRequest(router, "acme-key", http.MethodDelete, "/api/v1/vectors/docs/documents/raw", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = tenantRequest(router, "acme-key", http.MethodDelete, "/api/v1/vectors/docs/documents/raw", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
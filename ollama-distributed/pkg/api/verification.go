@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/proxy"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+)
+
+// SetVerifier enables golden-prompt verification of models on
+// /api/v1/models/:name/verify and /api/v1/verification
+func (s *Server) SetVerifier(verifier *verification.Verifier) {
+	s.verifier = verifier
+}
+
+// VerificationTargets returns the healthy Ollama instances serving a model,
+// or the local instance when no instance is known to serve it
+func (s *Server) VerificationTargets(model string) []verification.Target {
+	var targets []verification.Target
+	if s.ollamaProxy != nil {
+		for _, instance := range s.ollamaProxy.GetInstances() {
+			if instance.Status != proxy.InstanceStatusHealthy || !servesModel(instance.Models, model) {
+				continue
+			}
+			targets = append(targets, verification.Target{NodeID: instance.NodeID, Endpoint: instance.Endpoint})
+		}
+	}
+	if len(targets) == 0 && s.integration != nil {
+		targets = append(targets, verification.Target{NodeID: "local", Endpoint: s.integration.GetOllamaAPIURL()})
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].NodeID < targets[j].NodeID })
+	return targets
+}
+
+// servesModel reports whether an instance's models, as Ollama lists them
+// with their tag, include model
+func servesModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model || (!strings.Contains(model, ":") && m == model+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyModel runs the golden prompts of a model on every node serving it
+// and returns the report. Callers gate promotion of a new model version on
+// the report passing.
+func (s *Server) verifyModel(c *gin.Context) {
+	if s.verifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model verification not enabled"})
+		return
+	}
+
+	model, info, exists := s.resolveModel(c, c.Param("name"))
+	if !exists {
+		model = c.Param("name")
+	}
+	if len(s.verifier.Suite().CasesFor(model)) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no verification cases for model " + model})
+		return
+	}
+	targets := s.VerificationTargets(model)
+	if len(targets) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no node serves model " + model})
+		return
+	}
+
+	var version string
+	if info != nil {
+		version = info.Checksum
+	}
+	report := s.verifier.Verify(c.Request.Context(), model, version, targets)
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// getModelVerification returns the latest verification report of a model
+func (s *Server) getModelVerification(c *gin.Context) {
+	if s.verifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model verification not enabled"})
+		return
+	}
+
+	model, _, exists := s.resolveModel(c, c.Param("name"))
+	if !exists {
+		model = c.Param("name")
+	}
+	report, ok := s.verifier.Report(model)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model " + model + " has not been verified"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// getVerificationSuite returns the golden prompts models are verified with
+func (s *Server) getVerificationSuite(c *gin.Context) {
+	if s.verifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model verification not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suite": s.verifier.Suite()})
+}
+
+// setVerificationSuite replaces the golden prompts
+func (s *Server) setVerificationSuite(c *gin.Context) {
+	if s.verifier == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "model verification not enabled"})
+		return
+	}
+
+	var suite verification.Suite
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.verifier.SetSuite(&suite); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"suite": &suite})
+}
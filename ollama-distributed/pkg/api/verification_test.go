@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/verification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoClient struct{}
+
+func (echoClient) Generate(_ context.Context, _, _ string, c verification.Case) (string, error) {
+	return c.Prompt, nil
+}
+
+func (echoClient) Embed(context.Context, string, string, string) ([]float64, error) {
+	return []float64{1}, nil
+}
+
+func TestVerificationEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := scheduler.NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+	s := &Server{scheduler: engine}
+	router := gin.New()
+	router.POST("/api/v1/models/:name/verify", s.verifyModel)
+	router.GET("/api/v1/models/:name/verification", s.getModelVerification)
+	router.GET("/api/v1/verification/suite", s.getVerificationSuite)
+	router.PUT("/api/v1/verification/suite", s.setVerificationSuite)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/v1/verification/suite", "").Code)
+
+	verifier, err := verification.NewVerifier(verification.Config{}, nil, echoClient{})
+	require.NoError(t, err)
+	s.SetVerifier(verifier)
+
+	rec := do(http.MethodPut, "/api/v1/verification/suite", `{"cases": [{"name": "bad", "model": "llama3", "prompt": "hi", "expect": {"matches": ["("]}}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(http.MethodPut, "/api/v1/verification/suite", `{"cases": [{"name": "echo", "model": "llama3", "prompt": "hi", "expect": {"matches": ["hi"]}}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, do(http.MethodGet, "/api/v1/verification/suite", "").Body.String(), `"echo"`)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/models/phi/verify", "").Code, "no cases for phi")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/v1/models/llama3/verify", "").Code, "no node serves llama3")
+
+	verifier.Verify(context.Background(), "llama3", "v2", []verification.Target{{NodeID: "a"}})
+	rec = do(http.MethodGet, "/api/v1/models/llama3/verification", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"passed":true`)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/models/phi/verification", "").Code)
+}
+
+func TestServesModel(t *testing.T) {
+	assert.True(t, servesModel([]string{"llama3:latest"}, "llama3"))
+	assert.True(t, servesModel([]string{"llama3:8b"}, "llama3:8b"))
+	assert.False(t, servesModel([]string{"llama3:8b"}, "llama3"))
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+)
+
+// SetCompatibility exposes the versions of the cluster's members on
+// /api/v1/cluster/versions, and refuses joining nodes whose protocols are
+// incompatible with this node's
+func (s *Server) SetCompatibility(cluster *compat.Cluster) {
+	s.compat = cluster
+}
+
+// checkJoinCompatibility checks what a joining node advertised, or
+// assumes it predates the handshake when it advertised nothing. It returns
+// the warning about the node's version skew, or writes the refusal if the
+// node is incompatible.
+func (s *Server) checkJoinCompatibility(c *gin.Context, nodeID string, info *compat.Info) (warning string, ok bool) {
+	if s.compat == nil {
+		return "", true
+	}
+	remote := compat.Legacy(nodeID)
+	if info != nil {
+		remote = *info
+		remote.NodeID = nodeID
+	}
+	warning, err := s.compat.Check(remote)
+	if errors.Is(err, compat.ErrIncompatible) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   err.Error(),
+			"cluster": s.compat.Local(),
+		})
+		return "", false
+	}
+	return warning, true
+}
+
+// getClusterVersions returns the version and protocols every member
+// advertised, the members with an unsupported version skew, and the wire
+// behaviors enabled because every member supports them
+func (s *Server) getClusterVersions(c *gin.Context) {
+	if s.compat == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "version negotiation not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, s.compat.Status())
+}
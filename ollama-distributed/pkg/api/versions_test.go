@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jointoken"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinRefusesIncompatibleVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens, err := jointoken.NewManager(jointoken.Config{Enabled: true}, testClusterCA(t), nil)
+	require.NoError(t, err)
+	cluster := compat.NewCluster(compat.Local("node-1", "v1.4.0"), compat.Config{})
+	s := &Server{}
+	s.SetJoinTokens(tokens)
+	s.SetCompatibility(cluster)
+	router := gin.New()
+	router.POST("/api/v1/cluster/join", s.joinCluster)
+	router.GET("/api/v1/cluster/versions", s.getClusterVersions)
+
+	token, _, err := tokens.Create(0, "test")
+	require.NoError(t, err)
+	join := func(info compat.Info) *httptest.ResponseRecorder {
+		data, err := json.Marshal(info)
+		require.NoError(t, err)
+		body := `{"node_id":"node-7","address":"10.0.1.7:4001","token":"` + token.String() + `","compat":` + string(data) + `}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/cluster/join", strings.NewReader(body)))
+		return w
+	}
+
+	w := join(compat.Info{Version: "v3.0.0", Protocol: 7, MinProtocol: 5})
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "incompatible protocol version")
+
+	w = join(compat.Local("", "v1.1.0"))
+	require.Equal(t, http.StatusOK, w.Code, "the token is not spent on a refused node: %s", w.Body.String())
+	assert.Contains(t, w.Body.String(), `"warning"`, "an unsupported skew is warned about")
+
+	_, err = cluster.Admit(compat.Legacy("node-2"))
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/cluster/versions", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status compat.Status
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Len(t, status.Members, 1)
+	assert.Equal(t, "v1.4.0", status.Local.Version)
+	assert.Empty(t, status.Enabled, "legacy members turn new wire behaviors off")
+}
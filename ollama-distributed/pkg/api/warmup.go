@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
+)
+
+// SetWarmup exposes model warm-up on /api/v1/warmup and
+// /api/v1/models/:name/warmup, warming models on the local instances the
+// endpoints function returns
+func (s *Server) SetWarmup(service *warmup.Service, endpoints func() map[string]string) {
+	s.warmup = service
+	s.warmupEndpoints = endpoints
+}
+
+// getWarmups returns the hardware profile and the recent warm-ups
+func (s *Server) getWarmups(c *gin.Context) {
+	if s.warmup == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "warm-up not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile":     s.warmup.Profile(),
+		"fingerprint": s.warmup.Profile().Fingerprint(),
+		"results":     s.warmup.Results(),
+	})
+}
+
+// warmUpModel warms a model up on every local instance, loading it where
+// it is not loaded yet
+func (s *Server) warmUpModel(c *gin.Context) {
+	if s.warmup == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "warm-up not enabled"})
+		return
+	}
+
+	endpoints := s.warmupEndpoints()
+	if len(endpoints) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no local Ollama instances running"})
+		return
+	}
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	model := c.Param("name")
+	results := make([]warmup.Result, 0, len(names))
+	failed := 0
+	for _, name := range names {
+		result, err := s.warmup.WarmUp(c.Request.Context(), endpoints[name], model)
+		if err != nil {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	status := http.StatusOK
+	if failed == len(results) {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{"model": model, "results": results})
+}
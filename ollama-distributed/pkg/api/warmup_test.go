@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmUpModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int{"eval_count": 4})
+	}))
+	defer ollama.Close()
+
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/v1/warmup", s.getWarmups)
+	router.POST("/api/v1/models/:name/warmup", s.warmUpModel)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/v1/models/llama3/warmup").Code)
+	s.SetWarmup(warmup.NewService(warmup.Config{Enabled: true, Tokens: 4}, warmup.Profile{OS: "linux"}, nil),
+		func() map[string]string { return map[string]string{"ollama-0": ollama.URL} })
+
+	rec := do(http.MethodPost, "/api/v1/models/llama3/warmup")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var warmed struct {
+		Model   string          `json:"model"`
+		Results []warmup.Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &warmed))
+	assert.Equal(t, "llama3", warmed.Model)
+	require.Len(t, warmed.Results, 1)
+	assert.Equal(t, 4, warmed.Results[0].Tokens)
+
+	rec = do(http.MethodGet, "/api/v1/warmup")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Profile warmup.Profile  `json:"profile"`
+		Results []warmup.Result `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	assert.Equal(t, "linux", listed.Profile.OS)
+	assert.Len(t, listed.Results, 1)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
 )
 
 // WSHub manages WebSocket connections
@@ -286,3 +287,16 @@ func (s *Server) BroadcastMetricsUpdate(metrics map[string]interface{}) {
 		"timestamp": time.Now(),
 	})
 }
+
+// StreamProgressEvents broadcasts the task and partition progress events of
+// an orchestration engine as "task_progress" messages until the returned
+// function is called
+func (s *Server) StreamProgressEvents(bus *orchestration.EventBus) func() {
+	events, cancel := bus.Subscribe(orchestration.EventFilter{})
+	go func() {
+		for ev := range events {
+			s.wsHub.Broadcast("task_progress", ev)
+		}
+	}()
+	return cancel
+}
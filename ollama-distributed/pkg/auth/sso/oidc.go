@@ -0,0 +1,255 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures an OpenID Connect identity provider
+type OIDCConfig struct {
+	IssuerURL    string   `json:"issuer_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	// Audiences accepted in bearer tokens; defaults to the client ID
+	Audiences []string `json:"audiences"`
+	// GroupsClaim names the claim carrying IdP groups (default "groups")
+	GroupsClaim string `json:"groups_claim"`
+	// RoleMappings maps IdP group names to RBAC roles
+	RoleMappings map[string]string `json:"role_mappings"`
+	// DefaultRole is assigned when no group maps to a role; empty rejects
+	// users without a mapped group
+	DefaultRole string `json:"default_role"`
+}
+
+// rolePrecedence orders roles from most to least privileged so that a
+// user in several mapped groups gets the strongest role
+var rolePrecedence = []string{auth.RoleAdmin, auth.RoleOperator, auth.RoleUser, auth.RoleService, auth.RoleReadOnly}
+
+// OIDCClaims are the verified identity claims from an ID or access token
+type OIDCClaims struct {
+	Subject  string   `json:"sub"`
+	Email    string   `json:"email"`
+	Name     string   `json:"name"`
+	Username string   `json:"preferred_username"`
+	Groups   []string `json:"groups"`
+	Roles    []string `json:"roles"`
+	Nonce    string   `json:"nonce"`
+	Expiry   time.Time
+	Raw      map[string]interface{} `json:"-"`
+}
+
+// OIDCTokens is the result of an authorization code exchange
+type OIDCTokens struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// OIDCProvider performs the authorization code flow against an OIDC issuer
+// and verifies its tokens against the issuer's published JWKS
+type OIDCProvider struct {
+	config     OIDCConfig
+	verifier   *oidc.IDTokenVerifier
+	oauth2     oauth2.Config
+	httpClient *http.Client
+	metadata   struct {
+		Issuer             string `json:"issuer"`
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+}
+
+// NewOIDCProvider discovers the issuer's endpoints and signing keys
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	if config.IssuerURL == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("oidc issuer URL and client ID are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "profile", "email", "groups"}
+	}
+	if len(config.Audiences) == 0 {
+		config.Audiences = []string{config.ClientID}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	p := &OIDCProvider{config: config, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	ctx = oidc.ClientContext(ctx, p.httpClient)
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if err := provider.Claims(&p.metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	// The audience is checked against all accepted audiences in Verify
+	p.verifier = provider.VerifierContext(ctx, &oidc.Config{
+		SkipClientIDCheck:    true,
+		SupportedSigningAlgs: []string{oidc.RS256, oidc.RS384, oidc.RS512, oidc.ES256, oidc.ES384, oidc.ES512, oidc.PS256},
+	})
+	p.oauth2 = oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  config.RedirectURL,
+		Scopes:       config.Scopes,
+	}
+	return p, nil
+}
+
+// AuthCodeURL returns the IdP login URL for the code flow with PKCE
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier))
+}
+
+// Exchange trades an authorization code for tokens
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*OIDCTokens, error) {
+	token, err := p.oauth2.Exchange(oidc.ClientContext(ctx, p.httpClient), code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil, fmt.Errorf("token response did not include an id_token")
+	}
+
+	tokens := &OIDCTokens{
+		AccessToken:  token.AccessToken,
+		IDToken:      idToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+	}
+	if !token.Expiry.IsZero() {
+		tokens.ExpiresIn = int(time.Until(token.Expiry).Seconds())
+	}
+	return tokens, nil
+}
+
+// Verify validates a JWT issued by the provider: signature against the
+// JWKS, issuer, expiry and audience. A token for several audiences must
+// name an accepted one as its authorized party.
+func (p *OIDCProvider) Verify(ctx context.Context, rawToken string) (*OIDCClaims, error) {
+	idToken, err := p.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc token: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, fmt.Errorf("invalid oidc token: %w", err)
+	}
+	azp, _ := raw["azp"].(string)
+	if err := p.checkAudience(idToken.Audience, azp); err != nil {
+		return nil, fmt.Errorf("invalid oidc token: %w", err)
+	}
+
+	claims := &OIDCClaims{Raw: raw, Subject: idToken.Subject, Nonce: idToken.Nonce, Expiry: idToken.Expiry}
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	claims.Username, _ = raw["preferred_username"].(string)
+	claims.Groups = stringList(raw[p.config.GroupsClaim])
+	claims.Roles = stringList(raw["roles"])
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid oidc token: missing subject")
+	}
+	return claims, nil
+}
+
+// checkAudience accepts a token for one of the accepted audiences. When the
+// token has several audiences, or names an authorized party, that party
+// must be accepted too.
+func (p *OIDCProvider) checkAudience(audiences []string, azp string) error {
+	if !audienceAllowed(audiences, p.config.Audiences) {
+		return fmt.Errorf("audience %v not accepted", audiences)
+	}
+	if azp == "" && len(audiences) > 1 {
+		return fmt.Errorf("token for audiences %v names no authorized party", audiences)
+	}
+	if azp != "" && !audienceAllowed([]string{azp}, p.config.Audiences) {
+		return fmt.Errorf("authorized party %q not accepted", azp)
+	}
+	return nil
+}
+
+// MapRole returns the RBAC role for a set of IdP groups, choosing the most
+// privileged mapped role, or DefaultRole when none match
+func (p *OIDCProvider) MapRole(groups []string) (string, error) {
+	mapped := make(map[string]bool)
+	for _, group := range groups {
+		if role, ok := p.config.RoleMappings[group]; ok {
+			mapped[role] = true
+		}
+	}
+	for _, role := range rolePrecedence {
+		if mapped[role] {
+			return role, nil
+		}
+	}
+	if p.config.DefaultRole != "" {
+		return p.config.DefaultRole, nil
+	}
+	return "", fmt.Errorf("no role mapping for groups %v", groups)
+}
+
+// RoleFor returns the RBAC role of a verified user, mapping both the IdP
+// groups and the roles claim. The API and the dashboard both map roles
+// with it, so a user gets the same role on either.
+func (p *OIDCProvider) RoleFor(claims *OIDCClaims) (string, error) {
+	groups := make([]string, 0, len(claims.Groups)+len(claims.Roles))
+	groups = append(groups, claims.Groups...)
+	return p.MapRole(append(groups, claims.Roles...))
+}
+
+// EndSessionURL returns the IdP logout URL, if the provider has one
+func (p *OIDCProvider) EndSessionURL() string {
+	return p.metadata.EndSessionEndpoint
+}
+
+// Issuer returns the verified issuer identifier
+func (p *OIDCProvider) Issuer() string {
+	return p.metadata.Issuer
+}
+
+func audienceAllowed(tokenAud, accepted []string) bool {
+	for _, aud := range tokenAud {
+		for _, ok := range accepted {
+			if aud == ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringList converts a claim that may be a string or list into []string
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return val
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ti := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.server.URL,
+			"authorization_endpoint": ti.server.URL + "/authorize",
+			"token_endpoint":         ti.server.URL + "/token",
+			"jwks_uri":               ti.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") == "" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "opaque",
+			"id_token":     ti.sign(t, jwt.MapClaims{"sub": "u1", "aud": "dashboard", "nonce": "n1"}),
+		})
+	})
+	ti.server = httptest.NewServer(mux)
+	t.Cleanup(ti.server.Close)
+	return ti
+}
+
+func (ti *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	if _, ok := claims["iss"]; !ok {
+		claims["iss"] = ti.server.URL
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(ti.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func newTestProvider(t *testing.T, ti *testIssuer) *OIDCProvider {
+	t.Helper()
+	p, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL:    ti.server.URL,
+		ClientID:     "dashboard",
+		RedirectURL:  "https://ui.example/auth/oidc/callback",
+		RoleMappings: map[string]string{"ops": auth.RoleOperator, "admins": auth.RoleAdmin},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestOIDCVerify(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := newTestProvider(t, ti)
+
+	claims, err := p.Verify(context.Background(), ti.sign(t, jwt.MapClaims{
+		"sub":    "alice",
+		"aud":    "dashboard",
+		"email":  "alice@example.com",
+		"groups": []string{"ops", "admins"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+	assert.Equal(t, []string{"ops", "admins"}, claims.Groups)
+
+	role, err := p.MapRole(claims.Groups)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleAdmin, role)
+}
+
+func TestOIDCRoleForMapsGroupsAndRoles(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := newTestProvider(t, ti)
+
+	groups := make([]string, 1, 4)
+	groups[0] = "ops"
+	claims := &OIDCClaims{Subject: "bob", Groups: groups, Roles: []string{"admins"}}
+	role, err := p.RoleFor(claims)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleAdmin, role, "the roles claim is mapped along with the groups")
+	assert.Equal(t, []string{"ops"}, claims.Groups)
+	assert.Empty(t, groups[:2][1], "the claims are not modified")
+}
+
+func TestOIDCVerifyRejects(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := newTestProvider(t, ti)
+
+	cases := map[string]jwt.MapClaims{
+		"wrong audience": {"sub": "a", "aud": "someone-else"},
+		"wrong issuer":   {"sub": "a", "aud": "dashboard", "iss": "https://evil.example"},
+		"expired":        {"sub": "a", "aud": "dashboard", "exp": time.Now().Add(-time.Hour).Unix()},
+		"no azp":         {"sub": "a", "aud": []string{"dashboard", "other"}},
+		"wrong azp":      {"sub": "a", "aud": []string{"dashboard", "other"}, "azp": "other"},
+	}
+	for name, claims := range cases {
+		_, err := p.Verify(context.Background(), ti.sign(t, claims))
+		assert.Error(t, err, name)
+	}
+
+	_, err := p.Verify(context.Background(), ti.sign(t, jwt.MapClaims{"sub": "a", "aud": []string{"dashboard", "other"}, "azp": "dashboard"}))
+	assert.NoError(t, err, "tokens for several audiences are accepted for the authorized party")
+
+	// Signed by a key the issuer never published
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "a", "aud": "dashboard", "iss": ti.server.URL, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forged.Header["kid"] = "k1"
+	raw, err := forged.SignedString(other)
+	require.NoError(t, err)
+	_, err = p.Verify(context.Background(), raw)
+	assert.Error(t, err)
+}
+
+func TestOIDCMapRoleWithoutMapping(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := newTestProvider(t, ti)
+
+	_, err := p.MapRole([]string{"unknown"})
+	assert.Error(t, err)
+
+	p.config.DefaultRole = auth.RoleReadOnly
+	role, err := p.MapRole(nil)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleReadOnly, role)
+}
+
+func TestOIDCCodeFlow(t *testing.T) {
+	ti := newTestIssuer(t)
+	p := newTestProvider(t, ti)
+
+	authURL, err := url.Parse(p.AuthCodeURL("s1", "n1", "verifier"))
+	require.NoError(t, err)
+	q := authURL.Query()
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, "s1", q.Get("state"))
+
+	tokens, err := p.Exchange(context.Background(), "good-code", "verifier")
+	require.NoError(t, err)
+	claims, err := p.Verify(context.Background(), tokens.IDToken)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", claims.Nonce)
+
+	_, err = p.Exchange(context.Background(), "bad-code", "verifier")
+	assert.Error(t, err)
+}
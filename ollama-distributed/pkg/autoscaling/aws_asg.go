@@ -0,0 +1,240 @@
+package autoscaling
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// awsAutoScalingVersion is the EC2 Auto Scaling Query API version
+const awsAutoScalingVersion = "2011-01-01"
+
+// AWSASGConfig identifies an EC2 Auto Scaling group. Credentials fall back to
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type AWSASGConfig struct {
+	Region          string        `yaml:"region"`
+	GroupName       string        `yaml:"group_name" mapstructure:"group_name"`
+	Endpoint        string        `yaml:"endpoint"` // defaults to the regional endpoint
+	AccessKeyID     string        `yaml:"access_key_id" mapstructure:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key" mapstructure:"secret_access_key"`
+	SessionToken    string        `yaml:"session_token" mapstructure:"session_token"`
+	HonorCooldown   bool          `yaml:"honor_cooldown" mapstructure:"honor_cooldown"` // also respect the group's own cooldown
+	Timeout         time.Duration `yaml:"timeout"`
+}
+
+// AWSASGExecutor implements ScalingExecutor by setting the desired capacity
+// of an Auto Scaling group
+type AWSASGExecutor struct {
+	config   AWSASGConfig
+	endpoint string
+	client   *http.Client
+}
+
+// NewAWSASGExecutor creates a new Auto Scaling group executor
+func NewAWSASGExecutor(config *AWSASGConfig) (*AWSASGExecutor, error) {
+	cfg := *config
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	if cfg.Region == "" || cfg.GroupName == "" {
+		return nil, fmt.Errorf("aws auto scaling group needs a region and a group name")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("aws auto scaling group %s has no credentials", cfg.GroupName)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://autoscaling.%s.amazonaws.com/", cfg.Region)
+	}
+
+	return &AWSASGExecutor{
+		config:   cfg,
+		endpoint: endpoint,
+		client:   httpClient(cfg.Timeout),
+	}, nil
+}
+
+// ScaleUp sets the desired capacity of the group
+func (e *AWSASGExecutor) ScaleUp(replicas int) error {
+	return e.setDesiredCapacity(replicas)
+}
+
+// ScaleDown sets the desired capacity of the group; the group's termination
+// policy picks the instances to remove
+func (e *AWSASGExecutor) ScaleDown(replicas int) error {
+	return e.setDesiredCapacity(replicas)
+}
+
+// GetCurrentReplicas returns the desired capacity of the group
+func (e *AWSASGExecutor) GetCurrentReplicas() (int, error) {
+	params := url.Values{}
+	params.Set("AutoScalingGroupNames.member.1", e.config.GroupName)
+
+	var result struct {
+		Groups []struct {
+			Name            string `xml:"AutoScalingGroupName"`
+			DesiredCapacity int    `xml:"DesiredCapacity"`
+		} `xml:"DescribeAutoScalingGroupsResult>AutoScalingGroups>member"`
+	}
+	if err := e.call("DescribeAutoScalingGroups", params, &result); err != nil {
+		return 0, err
+	}
+
+	for _, group := range result.Groups {
+		if group.Name == e.config.GroupName {
+			return group.DesiredCapacity, nil
+		}
+	}
+	return 0, fmt.Errorf("auto scaling group %s not found", e.config.GroupName)
+}
+
+func (e *AWSASGExecutor) setDesiredCapacity(replicas int) error {
+	params := url.Values{}
+	params.Set("AutoScalingGroupName", e.config.GroupName)
+	params.Set("DesiredCapacity", strconv.Itoa(replicas))
+	params.Set("HonorCooldown", strconv.FormatBool(e.config.HonorCooldown))
+	return e.call("SetDesiredCapacity", params, nil)
+}
+
+// call sends a signed Query API request and decodes the XML response into
+// result when it is not nil
+func (e *AWSASGExecutor) call(action string, params url.Values, result interface{}) error {
+	params.Set("Action", action)
+	params.Set("Version", awsAutoScalingVersion)
+	body := params.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := awsCredentials{
+		AccessKeyID:     e.config.AccessKeyID,
+		SecretAccessKey: e.config.SecretAccessKey,
+		SessionToken:    e.config.SessionToken,
+	}
+	signAWSv4(req, []byte(body), creds, e.config.Region, "autoscaling", time.Now())
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(action, resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	return nil
+}
+
+// awsCredentials are the keys requests are signed with
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signAWSv4 adds AWS Signature Version 4 headers to the request, signing the
+// host, content type and date headers along with the body
+func signAWSv4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, escaping all but
+// the unreserved characters as SigV4 requires
+func canonicalQuery(values url.Values) string {
+	pairs := make([]string, 0, len(values))
+	for name, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,178 @@
+package autoscaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultComputeEndpoint = "https://compute.googleapis.com/compute/v1"
+	defaultGCPMetadataURL  = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCPMIGConfig identifies a Compute Engine managed instance group, zonal when
+// Zone is set and regional when Region is. Without an access token (or
+// GOOGLE_OAUTH_ACCESS_TOKEN) one is fetched from the metadata server.
+type GCPMIGConfig struct {
+	Project     string        `yaml:"project"`
+	Zone        string        `yaml:"zone"`
+	Region      string        `yaml:"region"`
+	Group       string        `yaml:"group"`
+	AccessToken string        `yaml:"access_token" mapstructure:"access_token"`
+	Endpoint    string        `yaml:"endpoint"`
+	MetadataURL string        `yaml:"metadata_url" mapstructure:"metadata_url"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// GCPMIGExecutor implements ScalingExecutor by resizing a managed instance
+// group
+type GCPMIGExecutor struct {
+	config GCPMIGConfig
+	base   string // URL of the instance group manager
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewGCPMIGExecutor creates a new managed instance group executor
+func NewGCPMIGExecutor(config *GCPMIGConfig) (*GCPMIGExecutor, error) {
+	cfg := *config
+	if cfg.AccessToken == "" {
+		cfg.AccessToken = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultComputeEndpoint
+	}
+	if cfg.MetadataURL == "" {
+		cfg.MetadataURL = defaultGCPMetadataURL
+	}
+
+	if cfg.Project == "" || cfg.Group == "" {
+		return nil, fmt.Errorf("gcp managed instance group needs a project and a group")
+	}
+	if (cfg.Zone == "") == (cfg.Region == "") {
+		return nil, fmt.Errorf("gcp managed instance group %s needs exactly one of zone and region", cfg.Group)
+	}
+
+	base := fmt.Sprintf("%s/projects/%s/zones/%s/instanceGroupManagers/%s",
+		cfg.Endpoint, url.PathEscape(cfg.Project), url.PathEscape(cfg.Zone), url.PathEscape(cfg.Group))
+	if cfg.Region != "" {
+		base = fmt.Sprintf("%s/projects/%s/regions/%s/regionInstanceGroupManagers/%s",
+			cfg.Endpoint, url.PathEscape(cfg.Project), url.PathEscape(cfg.Region), url.PathEscape(cfg.Group))
+	}
+
+	return &GCPMIGExecutor{
+		config: cfg,
+		base:   base,
+		client: httpClient(cfg.Timeout),
+	}, nil
+}
+
+// ScaleUp resizes the group
+func (e *GCPMIGExecutor) ScaleUp(replicas int) error {
+	return e.resize(replicas)
+}
+
+// ScaleDown resizes the group; the group picks the instances to delete
+func (e *GCPMIGExecutor) ScaleDown(replicas int) error {
+	return e.resize(replicas)
+}
+
+// GetCurrentReplicas returns the target size of the group
+func (e *GCPMIGExecutor) GetCurrentReplicas() (int, error) {
+	var group struct {
+		TargetSize int `json:"targetSize"`
+	}
+	if err := e.do(http.MethodGet, e.base, "get instance group", &group); err != nil {
+		return 0, err
+	}
+	return group.TargetSize, nil
+}
+
+func (e *GCPMIGExecutor) resize(replicas int) error {
+	return e.do(http.MethodPost, e.base+"/resize?size="+strconv.Itoa(replicas), "resize instance group", nil)
+}
+
+// do sends an authorized request and decodes the JSON response into result
+// when it is not nil. Resizes return an operation that is not waited for;
+// the next evaluation reads the new target size.
+func (e *GCPMIGExecutor) do(method, target, operation string, result interface{}) error {
+	token, err := e.accessToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", operation, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(operation, resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
+
+// accessToken returns the configured token, or a cached one from the
+// metadata server refreshed a minute before it expires
+func (e *GCPMIGExecutor) accessToken() (string, error) {
+	if e.config.AccessToken != "" {
+		return e.config.AccessToken, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && time.Now().Before(e.tokenExpiry) {
+		return e.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, e.config.MetadataURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError("fetch access token", resp)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+
+	e.token = token.AccessToken
+	e.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return e.token, nil
+}
@@ -0,0 +1,61 @@
+package autoscaling
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Node pool providers
+const (
+	ProviderAWSASG  = "aws_asg"
+	ProviderGCPMIG  = "gcp_mig"
+	ProviderWebhook = "webhook"
+)
+
+// defaultProvisionTimeout bounds each call to a provider API
+const defaultProvisionTimeout = 30 * time.Second
+
+// NodePoolConfig selects and configures the provider that adds and removes
+// nodes in a cloud node pool
+type NodePoolConfig struct {
+	Provider string        `yaml:"provider"`
+	AWS      AWSASGConfig  `yaml:"aws"`
+	GCP      GCPMIGConfig  `yaml:"gcp"`
+	Webhook  WebhookConfig `yaml:"webhook"`
+}
+
+// NewNodePoolExecutor creates the scaling executor for the configured provider
+func NewNodePoolExecutor(config *NodePoolConfig) (ScalingExecutor, error) {
+	switch config.Provider {
+	case ProviderAWSASG:
+		return NewAWSASGExecutor(&config.AWS)
+	case ProviderGCPMIG:
+		return NewGCPMIGExecutor(&config.GCP)
+	case ProviderWebhook:
+		return NewWebhookExecutor(&config.Webhook)
+	default:
+		return nil, fmt.Errorf("unknown node pool provider %q", config.Provider)
+	}
+}
+
+// httpClient returns a client with the given timeout, or the default one
+func httpClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultProvisionTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// responseError describes a failed provider call, including the start of the
+// response body, which usually holds the provider's error message
+func responseError(operation string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		return fmt.Errorf("%s failed: %s", operation, resp.Status)
+	}
+	return fmt.Errorf("%s failed: %s: %s", operation, resp.Status, message)
+}
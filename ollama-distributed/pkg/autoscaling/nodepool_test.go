@@ -0,0 +1,318 @@
+package autoscaling
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollector reports fixed node pool metrics
+type fakeCollector struct {
+	queue     int
+	gpu       float64
+	burn      float64
+	idleNodes []string
+}
+
+func (c *fakeCollector) GetCPUUtilization() float64     { return 50 }
+func (c *fakeCollector) GetMemoryUtilization() float64  { return 50 }
+func (c *fakeCollector) GetQueueSize() int              { return c.queue }
+func (c *fakeCollector) GetResponseTime() time.Duration { return time.Second }
+func (c *fakeCollector) GetThroughput() float64         { return 1 }
+func (c *fakeCollector) GetActiveConnections() int      { return 1 }
+func (c *fakeCollector) GetGPUUtilization() float64     { return c.gpu }
+func (c *fakeCollector) GetSLOBurnRate() float64        { return c.burn }
+func (c *fakeCollector) GetIdleNodes() []string         { return c.idleNodes }
+
+// fakeExecutor records the scaling calls it receives
+type fakeExecutor struct {
+	mu       sync.Mutex
+	replicas int
+	released []string
+	calls    []string
+}
+
+func (e *fakeExecutor) ScaleUp(replicas int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.replicas = replicas
+	e.calls = append(e.calls, "up")
+	return nil
+}
+
+func (e *fakeExecutor) ScaleDown(replicas int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.replicas = replicas
+	e.calls = append(e.calls, "down")
+	return nil
+}
+
+func (e *fakeExecutor) ReleaseNodes(nodes []string, replicas int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.replicas = replicas
+	e.released = nodes
+	e.calls = append(e.calls, "release")
+	return nil
+}
+
+func (e *fakeExecutor) GetCurrentReplicas() (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.replicas, nil
+}
+
+func nodePoolConfig() *Config {
+	config := DefaultConfig()
+	config.MinReplicas = 2
+	config.MaxReplicas = 8
+	config.ScaleUpCooldown = 0
+	config.ScaleDownCooldown = 0
+	config.GPUThreshold = 80
+	config.SLOBurnThreshold = 2
+	return config
+}
+
+func TestGPUPolicyScalesWithUtilization(t *testing.T) {
+	policy := NewGPUPolicy(80)
+
+	decision := policy.Evaluate(&Metrics{CurrentReplicas: 4, GPUUtilization: 100})
+	require.NotNil(t, decision)
+	assert.Equal(t, ScaleUp, decision.Action)
+	assert.Equal(t, 5, decision.TargetReplicas)
+
+	assert.Nil(t, policy.Evaluate(&Metrics{CurrentReplicas: 4, GPUUtilization: 60}))
+
+	decision = policy.Evaluate(&Metrics{CurrentReplicas: 4, GPUUtilization: 10})
+	require.NotNil(t, decision)
+	assert.Equal(t, ScaleDown, decision.Action)
+	assert.Equal(t, 3, decision.TargetReplicas)
+}
+
+func TestSLOBurnPolicyOnlyScalesUp(t *testing.T) {
+	policy := NewSLOBurnPolicy(2)
+
+	decision := policy.Evaluate(&Metrics{CurrentReplicas: 3, SLOBurnRate: 5})
+	require.NotNil(t, decision)
+	assert.Equal(t, ScaleUp, decision.Action)
+	assert.Equal(t, 6, decision.TargetReplicas)
+
+	assert.Nil(t, policy.Evaluate(&Metrics{CurrentReplicas: 3, SLOBurnRate: 0}))
+}
+
+func TestIdleNodePolicyKeepsNodesWhileRequestsWait(t *testing.T) {
+	policy := NewIdleNodePolicy()
+
+	decision := policy.Evaluate(&Metrics{CurrentReplicas: 4, IdleNodes: []string{"a", "b"}})
+	require.NotNil(t, decision)
+	assert.Equal(t, ScaleDown, decision.Action)
+	assert.Equal(t, 2, decision.TargetReplicas)
+	assert.Equal(t, []string{"a", "b"}, decision.Nodes)
+
+	assert.Nil(t, policy.Evaluate(&Metrics{CurrentReplicas: 4, QueueSize: 1, IdleNodes: []string{"a"}}))
+	assert.Nil(t, policy.Evaluate(&Metrics{CurrentReplicas: 4, SLOBurnRate: 1.5, IdleNodes: []string{"a"}}))
+}
+
+func TestMakeFinalDecisionPrefersScaleUpAndLimitsStep(t *testing.T) {
+	scaler := NewAutoScaler(nodePoolConfig(), &fakeCollector{}, &fakeExecutor{})
+	scaler.currentReplicas = 4
+
+	decision := scaler.makeFinalDecision([]*ScalingDecision{
+		{Action: ScaleDown, TargetReplicas: 1, Priority: 9},
+		{Action: ScaleUp, TargetReplicas: 20, Priority: 1},
+	})
+	require.NotNil(t, decision)
+	assert.Equal(t, ScaleUp, decision.Action)
+	assert.Equal(t, 6, decision.TargetReplicas, "scale up is limited to 50% of the pool")
+
+	decision = scaler.makeFinalDecision([]*ScalingDecision{
+		{Action: ScaleDown, TargetReplicas: 1, Priority: 1},
+	})
+	require.NotNil(t, decision)
+	assert.Equal(t, 3, decision.TargetReplicas, "scale down is limited to 25% of the pool")
+
+	scaler.currentReplicas = 2
+	assert.Nil(t, scaler.makeFinalDecision([]*ScalingDecision{
+		{Action: ScaleDown, TargetReplicas: 1, Priority: 1},
+	}), "the pool never shrinks below the minimum")
+}
+
+func TestMakeFinalDecisionAppliesCooldown(t *testing.T) {
+	config := nodePoolConfig()
+	config.ScaleUpCooldown = time.Hour
+	scaler := NewAutoScaler(config, &fakeCollector{}, &fakeExecutor{})
+	scaler.currentReplicas = 4
+	scaler.lastScaleTime = time.Now()
+
+	assert.Nil(t, scaler.makeFinalDecision([]*ScalingDecision{
+		{Action: ScaleUp, TargetReplicas: 5, Priority: 1},
+	}))
+}
+
+func TestEvaluateReleasesIdleNodes(t *testing.T) {
+	executor := &fakeExecutor{replicas: 4}
+	collector := &fakeCollector{idleNodes: []string{"node-3"}}
+	scaler := NewAutoScaler(nodePoolConfig(), collector, executor)
+
+	scaler.evaluate()
+
+	assert.Equal(t, []string{"release"}, executor.calls)
+	assert.Equal(t, []string{"node-3"}, executor.released)
+	assert.Equal(t, 3, executor.replicas)
+	assert.Equal(t, int64(1), scaler.GetStats().ScaleDownEvents)
+}
+
+func TestEvaluateScalesUpOnSLOBurn(t *testing.T) {
+	executor := &fakeExecutor{replicas: 4}
+	collector := &fakeCollector{burn: 10, idleNodes: []string{"node-3"}}
+	scaler := NewAutoScaler(nodePoolConfig(), collector, executor)
+
+	scaler.evaluate()
+
+	assert.Equal(t, []string{"up"}, executor.calls)
+	assert.Equal(t, 6, executor.replicas)
+}
+
+func TestEvaluateOnlyOnLeader(t *testing.T) {
+	executor := &fakeExecutor{replicas: 4}
+	scaler := NewAutoScaler(nodePoolConfig(), &fakeCollector{burn: 10}, executor)
+	scaler.SetLeaderCheck(func() bool { return false })
+
+	scaler.evaluate()
+
+	assert.Empty(t, executor.calls)
+}
+
+func TestWebhookExecutor(t *testing.T) {
+	var received []WebhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(WebhookStatus{Replicas: 3})
+			return
+		}
+		var req WebhookRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received = append(received, req)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	executor, err := NewWebhookExecutor(&WebhookConfig{URL: server.URL, Token: "secret", Pool: "gpu"})
+	require.NoError(t, err)
+
+	replicas, err := executor.GetCurrentReplicas()
+	require.NoError(t, err)
+	assert.Equal(t, 3, replicas)
+
+	require.NoError(t, executor.ScaleUp(5))
+	require.NoError(t, executor.ReleaseNodes([]string{"node-1"}, 4))
+	assert.Equal(t, []WebhookRequest{
+		{Pool: "gpu", Action: WebhookActionScaleUp, Replicas: 5},
+		{Pool: "gpu", Action: WebhookActionRelease, Replicas: 4, Nodes: []string{"node-1"}},
+	}, received)
+}
+
+func TestWebhookExecutorReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusConflict)
+	}))
+	defer server.Close()
+
+	executor, err := NewWebhookExecutor(&WebhookConfig{URL: server.URL})
+	require.NoError(t, err)
+
+	err = executor.ScaleUp(5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
+
+func TestGCPMIGExecutor(t *testing.T) {
+	var resized string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+		case r.Method == http.MethodGet:
+			assert.Equal(t, "/projects/p/zones/z/instanceGroupManagers/g", r.URL.Path)
+			assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"targetSize":4}`))
+		default:
+			assert.Equal(t, "/projects/p/zones/z/instanceGroupManagers/g/resize", r.URL.Path)
+			resized = r.URL.Query().Get("size")
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	executor, err := NewGCPMIGExecutor(&GCPMIGConfig{
+		Project: "p", Zone: "z", Group: "g",
+		Endpoint: server.URL, MetadataURL: server.URL + "/token",
+	})
+	require.NoError(t, err)
+
+	replicas, err := executor.GetCurrentReplicas()
+	require.NoError(t, err)
+	assert.Equal(t, 4, replicas)
+
+	require.NoError(t, executor.ScaleDown(3))
+	assert.Equal(t, "3", resized)
+}
+
+func TestAWSASGExecutor(t *testing.T) {
+	var params url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		body, _ := io.ReadAll(r.Body)
+		params, _ = url.ParseQuery(string(body))
+		if params.Get("Action") == "DescribeAutoScalingGroups" {
+			w.Write([]byte(`<DescribeAutoScalingGroupsResponse><DescribeAutoScalingGroupsResult><AutoScalingGroups>
+<member><AutoScalingGroupName>gpu-pool</AutoScalingGroupName><DesiredCapacity>5</DesiredCapacity></member>
+</AutoScalingGroups></DescribeAutoScalingGroupsResult></DescribeAutoScalingGroupsResponse>`))
+			return
+		}
+		w.Write([]byte(`<SetDesiredCapacityResponse/>`))
+	}))
+	defer server.Close()
+
+	executor, err := NewAWSASGExecutor(&AWSASGConfig{
+		Region: "us-east-1", GroupName: "gpu-pool", Endpoint: server.URL,
+		AccessKeyID: "AKID", SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+
+	replicas, err := executor.GetCurrentReplicas()
+	require.NoError(t, err)
+	assert.Equal(t, 5, replicas)
+
+	require.NoError(t, executor.ScaleUp(7))
+	assert.Equal(t, "SetDesiredCapacity", params.Get("Action"))
+	assert.Equal(t, "7", params.Get("DesiredCapacity"))
+	assert.Equal(t, "gpu-pool", params.Get("AutoScalingGroupName"))
+}
+
+func TestSignAWSv4MatchesReferenceExample(t *testing.T) {
+	// The GET ListUsers example from the AWS Signature Version 4 documentation
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSv4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
@@ -2,6 +2,7 @@ package autoscaling
 
 import (
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -414,3 +415,116 @@ func (p *PredictivePolicy) calculateTrend() float64 {
 
 	return recent - older
 }
+
+// GPUPolicy scales a node pool on average GPU utilization
+type GPUPolicy struct {
+	threshold float64
+}
+
+// NewGPUPolicy creates a new GPU-based scaling policy
+func NewGPUPolicy(threshold float64) *GPUPolicy {
+	return &GPUPolicy{
+		threshold: threshold,
+	}
+}
+
+// Name returns the policy name
+func (p *GPUPolicy) Name() string {
+	return "GPU"
+}
+
+// Evaluate evaluates the GPU policy
+func (p *GPUPolicy) Evaluate(metrics *Metrics) *ScalingDecision {
+	if metrics.GPUUtilization > p.threshold {
+		// Add enough nodes to bring utilization back under the threshold
+		current := metrics.CurrentReplicas
+		if current < 1 {
+			current = 1
+		}
+		targetReplicas := int(math.Ceil(float64(current) * metrics.GPUUtilization / p.threshold))
+
+		return &ScalingDecision{
+			Action:         ScaleUp,
+			TargetReplicas: targetReplicas,
+			Reason:         fmt.Sprintf("GPU utilization %.1f%% > %.1f%%", metrics.GPUUtilization, p.threshold),
+			Confidence:     0.9,
+			Priority:       3,
+		}
+	}
+
+	if metrics.GPUUtilization < p.threshold*0.3 && metrics.QueueSize == 0 {
+		return &ScalingDecision{
+			Action:         ScaleDown,
+			TargetReplicas: metrics.CurrentReplicas - 1,
+			Reason:         fmt.Sprintf("GPU utilization %.1f%% < %.1f%%", metrics.GPUUtilization, p.threshold*0.3),
+			Confidence:     0.6,
+			Priority:       1,
+		}
+	}
+
+	return nil
+}
+
+// SLOBurnPolicy adds nodes while the error budget of a service level
+// objective is burning faster than the threshold
+type SLOBurnPolicy struct {
+	threshold float64
+}
+
+// NewSLOBurnPolicy creates a new SLO burn rate scaling policy
+func NewSLOBurnPolicy(threshold float64) *SLOBurnPolicy {
+	return &SLOBurnPolicy{
+		threshold: threshold,
+	}
+}
+
+// Name returns the policy name
+func (p *SLOBurnPolicy) Name() string {
+	return "SLOBurn"
+}
+
+// Evaluate evaluates the SLO burn policy. It never scales down: a budget
+// that is not burning says nothing about spare capacity.
+func (p *SLOBurnPolicy) Evaluate(metrics *Metrics) *ScalingDecision {
+	if metrics.SLOBurnRate <= p.threshold {
+		return nil
+	}
+
+	return &ScalingDecision{
+		Action:         ScaleUp,
+		TargetReplicas: metrics.CurrentReplicas + int(math.Ceil(metrics.SLOBurnRate/p.threshold)),
+		Reason:         fmt.Sprintf("SLO burn rate %.1f > %.1f", metrics.SLOBurnRate, p.threshold),
+		Confidence:     0.95,
+		Priority:       5, // Users are already affected
+	}
+}
+
+// IdleNodePolicy releases nodes that have run nothing for a while, as long
+// as no requests are waiting and no error budget is burning
+type IdleNodePolicy struct{}
+
+// NewIdleNodePolicy creates a new idle node scaling policy
+func NewIdleNodePolicy() *IdleNodePolicy {
+	return &IdleNodePolicy{}
+}
+
+// Name returns the policy name
+func (p *IdleNodePolicy) Name() string {
+	return "IdleNodes"
+}
+
+// Evaluate evaluates the idle node policy
+func (p *IdleNodePolicy) Evaluate(metrics *Metrics) *ScalingDecision {
+	if len(metrics.IdleNodes) == 0 || metrics.QueueSize > 0 || metrics.SLOBurnRate >= 1 {
+		return nil
+	}
+
+	return &ScalingDecision{
+		Action:         ScaleDown,
+		TargetReplicas: metrics.CurrentReplicas - len(metrics.IdleNodes),
+		Reason:         fmt.Sprintf("%d idle nodes", len(metrics.IdleNodes)),
+		Confidence:     0.8,
+		Priority:       3, // Preferred over blind scale-downs as it names the nodes
+		Nodes:          metrics.IdleNodes,
+	}
+}
@@ -25,6 +25,10 @@ type AutoScaler struct {
 	// Scaling executor
 	executor ScalingExecutor
 
+	// leaderCheck, when set, restricts scaling to the cluster leader so
+	// that only one node resizes a shared pool
+	leaderCheck func() bool
+
 	// Statistics
 	stats *ScalingStats
 
@@ -54,6 +58,15 @@ type Config struct {
 	MemoryThreshold float64 `yaml:"memory_threshold"`
 	QueueThreshold  int     `yaml:"queue_threshold"`
 
+	// ResponseTimeThreshold is the average response time above which
+	// replicas are added; zero disables the policy
+	ResponseTimeThreshold time.Duration `yaml:"response_time_threshold"`
+
+	// Node pool policies; zero disables them. GPUThreshold is a percentage,
+	// SLOBurnThreshold the error budget burn rate above which nodes are added.
+	GPUThreshold     float64 `yaml:"gpu_threshold"`
+	SLOBurnThreshold float64 `yaml:"slo_burn_threshold"`
+
 	// Advanced settings
 	ScaleUpPercent   int  `yaml:"scale_up_percent"`
 	ScaleDownPercent int  `yaml:"scale_down_percent"`
@@ -63,18 +76,19 @@ type Config struct {
 // DefaultConfig returns default auto-scaling configuration
 func DefaultConfig() *Config {
 	return &Config{
-		MinReplicas:         1,
-		MaxReplicas:         10,
-		ScaleUpCooldown:     3 * time.Minute,
-		ScaleDownCooldown:   5 * time.Minute,
-		EvaluationInterval:  30 * time.Second,
-		StabilizationWindow: 5 * time.Minute,
-		CPUThreshold:        70.0,
-		MemoryThreshold:     80.0,
-		QueueThreshold:      100,
-		ScaleUpPercent:      50,
-		ScaleDownPercent:    25,
-		EnablePredictive:    false,
+		MinReplicas:           1,
+		MaxReplicas:           10,
+		ScaleUpCooldown:       3 * time.Minute,
+		ScaleDownCooldown:     5 * time.Minute,
+		EvaluationInterval:    30 * time.Second,
+		StabilizationWindow:   5 * time.Minute,
+		CPUThreshold:          70.0,
+		MemoryThreshold:       80.0,
+		QueueThreshold:        100,
+		ResponseTimeThreshold: 5 * time.Second,
+		ScaleUpPercent:        50,
+		ScaleDownPercent:      25,
+		EnablePredictive:      false,
 	}
 }
 
@@ -113,6 +127,14 @@ type MetricsCollector interface {
 	GetActiveConnections() int
 }
 
+// NodePoolMetricsCollector is implemented by collectors that also report
+// the signals node pool scaling relies on
+type NodePoolMetricsCollector interface {
+	GetGPUUtilization() float64
+	GetSLOBurnRate() float64
+	GetIdleNodes() []string
+}
+
 // ScalingExecutor interface for executing scaling actions
 type ScalingExecutor interface {
 	ScaleUp(replicas int) error
@@ -120,6 +142,12 @@ type ScalingExecutor interface {
 	GetCurrentReplicas() (int, error)
 }
 
+// NodeReleaser is implemented by executors that can choose which nodes to
+// remove when scaling down
+type NodeReleaser interface {
+	ReleaseNodes(nodes []string, replicas int) error
+}
+
 // ScalingPolicy defines a scaling policy
 type ScalingPolicy interface {
 	Evaluate(metrics *Metrics) *ScalingDecision
@@ -135,6 +163,12 @@ type Metrics struct {
 	Throughput        float64
 	ActiveConnections int
 	Timestamp         time.Time
+
+	// Node pool signals, zero unless the collector is a NodePoolMetricsCollector
+	CurrentReplicas int
+	GPUUtilization  float64
+	SLOBurnRate     float64
+	IdleNodes       []string
 }
 
 // ScalingDecision represents a scaling decision
@@ -144,6 +178,9 @@ type ScalingDecision struct {
 	Reason         string
 	Confidence     float64
 	Priority       int
+
+	// Nodes lists the nodes to release when scaling down, if known
+	Nodes []string
 }
 
 // ScalingAction represents the type of scaling action
@@ -201,6 +238,14 @@ func (as *AutoScaler) Start() error {
 	return nil
 }
 
+// SetLeaderCheck restricts scaling to when isLeader returns true
+func (as *AutoScaler) SetLeaderCheck(isLeader func() bool) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	as.leaderCheck = isLeader
+}
+
 // Stop stops the auto-scaler
 func (as *AutoScaler) Stop() error {
 	as.cancel()
@@ -214,7 +259,20 @@ func (as *AutoScaler) initializePolicies() {
 		NewCPUPolicy(as.config.CPUThreshold),
 		NewMemoryPolicy(as.config.MemoryThreshold),
 		NewQueuePolicy(as.config.QueueThreshold),
-		NewResponseTimePolicy(5 * time.Second),
+	}
+
+	if as.config.ResponseTimeThreshold > 0 {
+		as.policies = append(as.policies, NewResponseTimePolicy(as.config.ResponseTimeThreshold))
+	}
+
+	if as.config.GPUThreshold > 0 {
+		as.policies = append(as.policies, NewGPUPolicy(as.config.GPUThreshold))
+	}
+	if as.config.SLOBurnThreshold > 0 {
+		as.policies = append(as.policies, NewSLOBurnPolicy(as.config.SLOBurnThreshold))
+	}
+	if _, ok := as.metricsCollector.(NodePoolMetricsCollector); ok {
+		as.policies = append(as.policies, NewIdleNodePolicy())
 	}
 }
 
@@ -237,6 +295,21 @@ func (as *AutoScaler) runEvaluationLoop() {
 
 // evaluate evaluates scaling policies and makes scaling decisions
 func (as *AutoScaler) evaluate() {
+	as.mu.RLock()
+	leaderCheck := as.leaderCheck
+	as.mu.RUnlock()
+	if leaderCheck != nil && !leaderCheck() {
+		return
+	}
+
+	// The pool may have been resized outside the scaler
+	if replicas, err := as.executor.GetCurrentReplicas(); err == nil {
+		as.mu.Lock()
+		as.currentReplicas = replicas
+		as.stats.CurrentReplicas = replicas
+		as.mu.Unlock()
+	}
+
 	// Collect current metrics
 	metrics := &Metrics{
 		CPUUtilization:    as.metricsCollector.GetCPUUtilization(),
@@ -248,6 +321,16 @@ func (as *AutoScaler) evaluate() {
 		Timestamp:         time.Now(),
 	}
 
+	as.mu.RLock()
+	metrics.CurrentReplicas = as.currentReplicas
+	as.mu.RUnlock()
+
+	if pool, ok := as.metricsCollector.(NodePoolMetricsCollector); ok {
+		metrics.GPUUtilization = pool.GetGPUUtilization()
+		metrics.SLOBurnRate = pool.GetSLOBurnRate()
+		metrics.IdleNodes = pool.GetIdleNodes()
+	}
+
 	// Update statistics
 	as.updateStats(metrics)
 
@@ -277,13 +360,19 @@ func (as *AutoScaler) makeFinalDecision(decisions []*ScalingDecision) *ScalingDe
 	timeSinceLastScale := time.Since(as.lastScaleTime)
 	as.mu.RUnlock()
 
-	// Find the highest priority decision
+	// Find the highest priority decision. Any policy asking for more
+	// capacity overrides those asking for less.
 	var finalDecision *ScalingDecision
 	for _, decision := range decisions {
-		if finalDecision == nil || decision.Priority > finalDecision.Priority {
+		if finalDecision == nil ||
+			(decision.Action == ScaleUp && finalDecision.Action != ScaleUp) ||
+			(decision.Action == finalDecision.Action && decision.Priority > finalDecision.Priority) {
 			finalDecision = decision
 		}
 	}
+	if finalDecision.Action == NoAction {
+		return nil
+	}
 
 	// Apply cooldown logic
 	if finalDecision.Action == ScaleUp && timeSinceLastScale < as.config.ScaleUpCooldown {
@@ -299,6 +388,28 @@ func (as *AutoScaler) makeFinalDecision(decisions []*ScalingDecision) *ScalingDe
 	currentReplicas := as.currentReplicas
 	as.mu.RUnlock()
 
+	// Move in the decided direction by at most the configured step
+	decision := *finalDecision
+	finalDecision = &decision
+	switch finalDecision.Action {
+	case ScaleUp:
+		maxStep := stepSize(currentReplicas, as.config.ScaleUpPercent)
+		if finalDecision.TargetReplicas <= currentReplicas {
+			finalDecision.TargetReplicas = currentReplicas + 1
+		}
+		if finalDecision.TargetReplicas > currentReplicas+maxStep {
+			finalDecision.TargetReplicas = currentReplicas + maxStep
+		}
+	case ScaleDown:
+		maxStep := stepSize(currentReplicas, as.config.ScaleDownPercent)
+		if finalDecision.TargetReplicas >= currentReplicas {
+			finalDecision.TargetReplicas = currentReplicas - 1
+		}
+		if finalDecision.TargetReplicas < currentReplicas-maxStep {
+			finalDecision.TargetReplicas = currentReplicas - maxStep
+		}
+	}
+
 	if finalDecision.TargetReplicas < as.config.MinReplicas {
 		finalDecision.TargetReplicas = as.config.MinReplicas
 	}
@@ -315,6 +426,16 @@ func (as *AutoScaler) makeFinalDecision(decisions []*ScalingDecision) *ScalingDe
 	return finalDecision
 }
 
+// stepSize is the number of replicas a single scaling action may add or
+// remove: percent of the current count, and at least one
+func stepSize(current, percent int) int {
+	step := current * percent / 100
+	if step < 1 {
+		step = 1
+	}
+	return step
+}
+
 // executeScalingDecision executes a scaling decision
 func (as *AutoScaler) executeScalingDecision(decision *ScalingDecision) {
 	as.mu.Lock()
@@ -335,7 +456,16 @@ func (as *AutoScaler) executeScalingDecision(decision *ScalingDecision) {
 		}
 
 	case ScaleDown:
-		err = as.executor.ScaleDown(decision.TargetReplicas)
+		releaser, ok := as.executor.(NodeReleaser)
+		if ok && len(decision.Nodes) > 0 {
+			nodes := decision.Nodes
+			if excess := currentReplicas - decision.TargetReplicas; len(nodes) > excess {
+				nodes = nodes[:excess]
+			}
+			err = releaser.ReleaseNodes(nodes, decision.TargetReplicas)
+		} else {
+			err = as.executor.ScaleDown(decision.TargetReplicas)
+		}
 		if err == nil {
 			as.mu.Lock()
 			as.stats.ScaleDownEvents++
@@ -0,0 +1,130 @@
+package autoscaling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook actions
+const (
+	WebhookActionScaleUp   = "scale_up"
+	WebhookActionScaleDown = "scale_down"
+	WebhookActionRelease   = "release"
+)
+
+// WebhookConfig configures a generic provisioner reached over HTTP. The URL
+// receives a POST of a WebhookRequest for every scaling action, and answers
+// a GET with a WebhookStatus.
+type WebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Token   string        `yaml:"token"` // sent as a bearer token
+	Pool    string        `yaml:"pool"`  // passed through to the provisioner
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// WebhookRequest asks the provisioner to bring the pool to Replicas nodes,
+// removing the listed nodes first when the action is release
+type WebhookRequest struct {
+	Pool     string   `json:"pool,omitempty"`
+	Action   string   `json:"action"`
+	Replicas int      `json:"replicas"`
+	Nodes    []string `json:"nodes,omitempty"`
+}
+
+// WebhookStatus is the provisioner's view of the pool
+type WebhookStatus struct {
+	Replicas int `json:"replicas"`
+}
+
+// WebhookExecutor implements ScalingExecutor and NodeReleaser against a
+// generic webhook
+type WebhookExecutor struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookExecutor creates a new webhook executor
+func NewWebhookExecutor(config *WebhookConfig) (*WebhookExecutor, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("scaling webhook has no url")
+	}
+	return &WebhookExecutor{
+		config: *config,
+		client: httpClient(config.Timeout),
+	}, nil
+}
+
+// ScaleUp asks the provisioner for more nodes
+func (e *WebhookExecutor) ScaleUp(replicas int) error {
+	return e.send(WebhookRequest{Action: WebhookActionScaleUp, Replicas: replicas})
+}
+
+// ScaleDown asks the provisioner to remove nodes of its choosing
+func (e *WebhookExecutor) ScaleDown(replicas int) error {
+	return e.send(WebhookRequest{Action: WebhookActionScaleDown, Replicas: replicas})
+}
+
+// ReleaseNodes asks the provisioner to remove the given nodes
+func (e *WebhookExecutor) ReleaseNodes(nodes []string, replicas int) error {
+	return e.send(WebhookRequest{Action: WebhookActionRelease, Replicas: replicas, Nodes: nodes})
+}
+
+// GetCurrentReplicas asks the provisioner for the size of the pool
+func (e *WebhookExecutor) GetCurrentReplicas() (int, error) {
+	req, err := http.NewRequest(http.MethodGet, e.config.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create status request: %w", err)
+	}
+	e.authorize(req)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("scaling webhook status failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError("scaling webhook status", resp)
+	}
+
+	var status WebhookStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return 0, fmt.Errorf("failed to decode scaling webhook status: %w", err)
+	}
+	return status.Replicas, nil
+}
+
+func (e *WebhookExecutor) send(request WebhookRequest) error {
+	request.Pool = e.config.Pool
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode scaling request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create scaling request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	e.authorize(req)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("scaling webhook %s failed: %w", request.Action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError("scaling webhook "+request.Action, resp)
+	}
+	return nil
+}
+
+func (e *WebhookExecutor) authorize(req *http.Request) {
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.Token)
+	}
+}
@@ -0,0 +1,388 @@
+// Package backpressure keeps slow streaming clients from ballooning memory.
+// A Writer sits between an inference handler and the client and sends the
+// stream from a bounded buffer. Once a client falls behind by the buffer
+// size, the policy decides: pause the producer, so that the generation waits
+// for the client; coalesce the buffered chunks of the stream into fewer,
+// larger ones before pausing; or disconnect the client. Clients that accept
+// nothing for the stall timeout are disconnected and their generation
+// cancelled.
+package backpressure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policies for a client whose buffer is full
+const (
+	PolicyPause      = "pause"
+	PolicyCoalesce   = "coalesce"
+	PolicyDisconnect = "disconnect"
+)
+
+// Defaults of the buffering
+const (
+	DefaultMaxBufferBytes = 256 << 10
+	DefaultStallTimeout   = 30 * time.Second
+)
+
+// ErrSlowClient is returned to the producer once the client was
+// disconnected for falling behind
+var ErrSlowClient = errors.New("streaming client too slow")
+
+// Config configures the buffering of streamed responses
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxBufferBytes is how far a client may fall behind before the policy
+	// applies
+	MaxBufferBytes int `yaml:"max_buffer_bytes" mapstructure:"max_buffer_bytes"`
+	// Policy is pause, the default, coalesce or disconnect
+	Policy string `yaml:"policy"`
+	// StallTimeout disconnects a client that accepts no data for this long
+	StallTimeout time.Duration `yaml:"stall_timeout" mapstructure:"stall_timeout"`
+}
+
+// Validate checks the policy and bounds
+func (c Config) Validate() error {
+	switch c.Policy {
+	case "", PolicyPause, PolicyCoalesce, PolicyDisconnect:
+	default:
+		return fmt.Errorf("unknown backpressure policy %q", c.Policy)
+	}
+	if c.MaxBufferBytes < 0 || c.StallTimeout < 0 {
+		return fmt.Errorf("backpressure buffer and stall timeout must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.MaxBufferBytes == 0 {
+		c.MaxBufferBytes = DefaultMaxBufferBytes
+	}
+	if c.Policy == "" {
+		c.Policy = PolicyPause
+	}
+	if c.StallTimeout == 0 {
+		c.StallTimeout = DefaultStallTimeout
+	}
+	return c
+}
+
+// Stats counts the streams and how their clients kept up
+type Stats struct {
+	Streams int64 `json:"streams"`
+	Active  int64 `json:"active"`
+	// Slow is the number of streams whose client filled its buffer
+	Slow int64 `json:"slow"`
+	// Paused is the time producers waited for slow clients
+	Paused time.Duration `json:"paused"`
+	// Coalesced is the number of chunks merged into others
+	Coalesced int64 `json:"coalesced"`
+	// Disconnected is the number of slow or stalled clients disconnected
+	Disconnected int64 `json:"disconnected"`
+}
+
+// Monitor wraps streams with the configured buffering and counts them
+type Monitor struct {
+	cfg Config
+
+	streams      atomic.Int64
+	active       atomic.Int64
+	slow         atomic.Int64
+	paused       atomic.Int64
+	coalesced    atomic.Int64
+	disconnected atomic.Int64
+}
+
+// NewMonitor creates a monitor
+func NewMonitor(cfg Config) (*Monitor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Monitor{cfg: cfg.withDefaults()}, nil
+}
+
+// Config returns the configuration, defaults applied
+func (m *Monitor) Config() Config {
+	return m.cfg
+}
+
+// Stats returns the counts so far
+func (m *Monitor) Stats() Stats {
+	return Stats{
+		Streams:      m.streams.Load(),
+		Active:       m.active.Load(),
+		Slow:         m.slow.Load(),
+		Paused:       time.Duration(m.paused.Load()),
+		Coalesced:    m.coalesced.Load(),
+		Disconnected: m.disconnected.Load(),
+	}
+}
+
+// Writer buffers a response for a client, see the package documentation.
+// Close must be called once the handler returns.
+type Writer struct {
+	gin.ResponseWriter
+	monitor *Monitor
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []byte
+	size     int
+	closed   bool
+	err      error
+	slow     bool
+	draining bool
+	done     chan struct{}
+}
+
+// Wrap buffers w, calling cancel to stop the generation when the client is
+// disconnected
+func (m *Monitor) Wrap(w gin.ResponseWriter, cancel context.CancelFunc) *Writer {
+	bw := &Writer{ResponseWriter: w, monitor: m, cancel: cancel, done: make(chan struct{})}
+	bw.cond = sync.NewCond(&bw.mu)
+	m.streams.Add(1)
+	m.active.Add(1)
+	go bw.drain()
+	return bw
+}
+
+// Write queues b for the client, applying the policy when the client is a
+// full buffer behind
+func (w *Writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cfg := w.monitor.cfg
+	coalesced := false
+	var pausedAt time.Time
+	for w.err == nil && len(w.queue) > 0 && len(w.queue)+len(b) > cfg.MaxBufferBytes {
+		if !w.slow {
+			w.slow = true
+			w.monitor.slow.Add(1)
+		}
+		if cfg.Policy == PolicyDisconnect {
+			w.fail(ErrSlowClient)
+			break
+		}
+		if cfg.Policy == PolicyCoalesce && !coalesced {
+			coalesced = true
+			var merged int
+			w.queue, merged = coalesce(w.queue)
+			w.monitor.coalesced.Add(int64(merged))
+			continue
+		}
+		if pausedAt.IsZero() {
+			pausedAt = time.Now()
+		}
+		w.cond.Wait()
+	}
+	if !pausedAt.IsZero() {
+		w.monitor.paused.Add(int64(time.Since(pausedAt)))
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.queue = append(w.queue, b...)
+	w.size += len(b)
+	w.cond.Broadcast()
+	return len(b), nil
+}
+
+// WriteString queues s
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush is a no-op: the buffer is flushed to the client as it takes it
+func (w *Writer) Flush() {}
+
+// Size returns the number of bytes written by the handler
+func (w *Writer) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size == 0 {
+		return -1
+	}
+	return w.size
+}
+
+// Written reports whether the handler wrote a body
+func (w *Writer) Written() bool {
+	return w.Size() != -1
+}
+
+// Close waits until the client took the buffer, or was disconnected, and
+// returns why it was disconnected, if it was
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	w.monitor.active.Add(-1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// drain sends the buffer to the client until the writer is closed
+func (w *Writer) drain() {
+	defer close(w.done)
+	rc := http.NewResponseController(w.ResponseWriter)
+	// The deadline would otherwise outlive the stream on a kept-alive
+	// connection
+	defer rc.SetWriteDeadline(time.Time{})
+
+	for {
+		w.mu.Lock()
+		for len(w.queue) == 0 && !w.closed && w.err == nil {
+			w.cond.Wait()
+		}
+		if w.err != nil || len(w.queue) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		chunk := w.queue
+		w.queue = nil
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		// A blocked write fails at the deadline where supported; either way
+		// the producer is released and the generation cancelled
+		timeout := w.monitor.cfg.StallTimeout
+		stalled := time.AfterFunc(timeout, func() {
+			w.disconnect(fmt.Errorf("%w: no data accepted for %s", ErrSlowClient, timeout))
+		})
+		if err := rc.SetWriteDeadline(time.Now().Add(timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			stalled.Stop()
+			w.disconnect(err)
+			return
+		}
+		_, err := w.ResponseWriter.Write(chunk)
+		if err == nil {
+			w.ResponseWriter.Flush()
+		}
+		stalled.Stop()
+		if err != nil {
+			w.disconnect(err)
+			return
+		}
+	}
+}
+
+// disconnect gives up on the client, cancelling the generation
+func (w *Writer) disconnect(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail(err)
+}
+
+// fail records why the client was disconnected and wakes the producer;
+// w.mu must be held
+func (w *Writer) fail(err error) {
+	if w.err != nil {
+		return
+	}
+	w.err = err
+	w.queue = nil
+	w.monitor.disconnected.Add(1)
+	w.cancel()
+	w.cond.Broadcast()
+}
+
+// chunk is the part of a streamed Ollama chunk that is merged
+type chunk struct {
+	Response *string `json:"response"`
+	Message  *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+}
+
+// coalesce merges consecutive unfinished chunks of a newline delimited
+// Ollama stream, generate or chat, concatenating their text into the first
+// of them. Other lines, and a trailing partial line, are kept as they are.
+// It returns the stream and the number of chunks merged away.
+func coalesce(stream []byte) ([]byte, int) {
+	var out bytes.Buffer
+	merged := 0
+
+	var head map[string]interface{}
+	var text bytes.Buffer
+	chat := false
+	flush := func() {
+		if head == nil {
+			return
+		}
+		if chat {
+			head["message"].(map[string]interface{})["content"] = text.String()
+		} else {
+			head["response"] = text.String()
+		}
+		data, _ := json.Marshal(head)
+		out.Write(data)
+		out.WriteByte('\n')
+		head = nil
+		text.Reset()
+	}
+
+	for len(stream) > 0 {
+		i := bytes.IndexByte(stream, '\n')
+		if i < 0 {
+			break
+		}
+		line := stream[:i+1]
+		stream = stream[i+1:]
+
+		var c chunk
+		var fields map[string]interface{}
+		if json.Unmarshal(line, &c) != nil || c.Done || (c.Response == nil && c.Message == nil) || decode(line, &fields) != nil {
+			flush()
+			out.Write(line)
+			continue
+		}
+		isChat := c.Response == nil
+		if isChat {
+			if _, ok := fields["message"].(map[string]interface{}); !ok {
+				flush()
+				out.Write(line)
+				continue
+			}
+		}
+		if head != nil && isChat == chat {
+			merged++
+		} else {
+			flush()
+			head, chat = fields, isChat
+		}
+		if isChat {
+			text.WriteString(c.Message.Content)
+		} else {
+			text.WriteString(*c.Response)
+		}
+	}
+	flush()
+	out.Write(stream)
+	return out.Bytes(), merged
+}
+
+// decode decodes JSON keeping numbers as they are
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,150 @@
+package backpressure
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowClient accepts a write only when released
+type slowClient struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	release chan struct{}
+}
+
+func (c *slowClient) Write(b []byte) (int, error) {
+	<-c.release
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ResponseRecorder.Write(b)
+}
+
+func (c *slowClient) body() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Body.String()
+}
+
+func (w *Writer) queueLen() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue)
+}
+
+func newWriter(t *testing.T, cfg Config) (*Monitor, *Writer, *slowClient, context.Context) {
+	gin.SetMode(gin.TestMode)
+	client := &slowClient{ResponseRecorder: httptest.NewRecorder(), release: make(chan struct{})}
+	c, _ := gin.CreateTestContext(client)
+	m, err := NewMonitor(cfg)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return m, m.Wrap(c.Writer, cancel), client, ctx
+}
+
+func generateChunk(text string) string {
+	return `{"model":"llama","response":"` + text + `","done":false}` + "\n"
+}
+
+func TestPausesProducerForSlowClient(t *testing.T) {
+	m, w, client, _ := newWriter(t, Config{MaxBufferBytes: 60})
+
+	// The first chunk is taken by the client, which blocks writing it
+	_, err := w.WriteString(generateChunk("a"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return w.queueLen() == 0 }, time.Second, time.Millisecond)
+	_, err = w.WriteString(generateChunk("b"))
+	require.NoError(t, err)
+
+	written := make(chan struct{})
+	go func() {
+		w.WriteString(generateChunk("c"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("the producer should wait for the client")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(client.release)
+	<-written
+	require.NoError(t, w.Close())
+	assert.Equal(t, generateChunk("a")+generateChunk("b")+generateChunk("c"), client.body())
+	stats := m.Stats()
+	assert.Equal(t, int64(1), stats.Slow)
+	assert.Positive(t, stats.Paused)
+	assert.Zero(t, stats.Active)
+}
+
+func TestCoalescesBufferedChunks(t *testing.T) {
+	m, w, client, _ := newWriter(t, Config{MaxBufferBytes: 150, Policy: PolicyCoalesce})
+
+	w.WriteString(generateChunk("Hel"))
+	require.Eventually(t, func() bool { return w.queueLen() == 0 }, time.Second, time.Millisecond)
+	w.WriteString(generateChunk("lo"))
+	w.WriteString(generateChunk(", "))
+	w.WriteString(generateChunk("world"))
+	w.WriteString(`{"model":"llama","response":"","done":true}` + "\n")
+
+	close(client.release)
+	require.NoError(t, w.Close())
+	lines := strings.Split(client.body(), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, strings.TrimSpace(generateChunk("Hel")), lines[0])
+	assert.JSONEq(t, generateChunk("lo, world"), lines[1], "the buffered text is merged")
+	assert.Equal(t, `{"model":"llama","response":"","done":true}`, lines[2])
+	assert.Equal(t, int64(2), m.Stats().Coalesced)
+}
+
+func TestDisconnectsStalledClient(t *testing.T) {
+	m, w, client, ctx := newWriter(t, Config{StallTimeout: 20 * time.Millisecond})
+
+	w.WriteString(generateChunk("a"))
+	<-ctx.Done()
+	_, err := w.WriteString(generateChunk("b"))
+	assert.ErrorIs(t, err, ErrSlowClient)
+
+	close(client.release)
+	assert.ErrorIs(t, w.Close(), ErrSlowClient)
+	assert.Equal(t, int64(1), m.Stats().Disconnected)
+}
+
+func TestDisconnectPolicy(t *testing.T) {
+	_, w, client, ctx := newWriter(t, Config{MaxBufferBytes: 60, Policy: PolicyDisconnect})
+
+	w.WriteString(generateChunk("a"))
+	require.Eventually(t, func() bool { return w.queueLen() == 0 }, time.Second, time.Millisecond)
+	w.WriteString(generateChunk("b"))
+	_, err := w.WriteString(generateChunk("c"))
+	assert.ErrorIs(t, err, ErrSlowClient)
+	assert.Error(t, ctx.Err(), "the generation is cancelled")
+	close(client.release)
+	w.Close()
+}
+
+func TestCoalesceChat(t *testing.T) {
+	stream := `{"message":{"role":"assistant","content":"a"},"done":false}` + "\n" +
+		`{"message":{"role":"assistant","content":"b"},"done":false}` + "\n" +
+		`not json` + "\n" +
+		`{"message":{"role":"assistant","content":"c"}`
+	out, merged := coalesce([]byte(stream))
+	assert.Equal(t, 1, merged)
+	lines := strings.Split(string(out), "\n")
+	assert.JSONEq(t, `{"message":{"role":"assistant","content":"ab"},"done":false}`, lines[0])
+	assert.Equal(t, []string{"not json", `{"message":{"role":"assistant","content":"c"}`}, lines[1:])
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Policy: PolicyCoalesce}.Validate())
+	assert.Error(t, Config{Policy: "drop"}.Validate())
+	assert.Error(t, Config{StallTimeout: -time.Second}.Validate())
+}
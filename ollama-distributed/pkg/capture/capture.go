@@ -0,0 +1,390 @@
+// Package capture records inference request/response pairs for debugging.
+// Capture is opt-in: a sampled fraction of the traffic, plus every request
+// made with a flagged API key or for a flagged tenant, is recorded after
+// redaction and can later be replayed against another model or scheduling
+// strategy.
+package capture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// StrategyHeader overrides the load balancing strategy the scheduler uses
+// for a request; replays set it to compare strategies
+const StrategyHeader = "X-Scheduling-Strategy"
+
+// redactedValue replaces redacted fields and pattern matches
+const redactedValue = "[REDACTED]"
+
+// defaultMaxBodyBytes caps the captured size of each body
+const defaultMaxBodyBytes = 64 << 10
+
+// defaultRoutes are the inference endpoints captured when none are configured
+var defaultRoutes = []string{"/api/v1/generate", "/api/v1/chat", "/api/v1/embeddings"}
+
+// capturedHeaders are the request headers worth keeping for a replay;
+// credentials are never captured
+var capturedHeaders = []string{"Content-Type", "Accept", StrategyHeader}
+
+// Store kinds
+const (
+	StoreMemory   = "memory"
+	StoreFile     = "file"
+	StoreDatabase = "database"
+)
+
+// Config configures request capture
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// SampleRate is the fraction [0-1] of inference requests captured
+	SampleRate float64 `yaml:"sample_rate" mapstructure:"sample_rate"`
+	// APIKeys and Tenants whose requests are always captured
+	APIKeys []string `yaml:"api_keys" mapstructure:"api_keys" json:"-"`
+	Tenants []string `yaml:"tenants"`
+	// Routes are the path prefixes captured; empty captures the inference
+	// endpoints
+	Routes []string `yaml:"routes"`
+
+	// RedactFields are JSON fields, at any depth, whose values are replaced
+	// in captured bodies, and RedactPatterns regular expressions whose
+	// matches are replaced in any captured text
+	RedactFields   []string `yaml:"redact_fields" mapstructure:"redact_fields"`
+	RedactPatterns []string `yaml:"redact_patterns" mapstructure:"redact_patterns"`
+	// MaxBodyBytes truncates larger bodies; truncated requests cannot be
+	// replayed. Defaults to 64KiB.
+	MaxBodyBytes int `yaml:"max_body_bytes" mapstructure:"max_body_bytes"`
+
+	// Store is where captures are kept: memory (the latest Capacity ones),
+	// file (appended as JSON lines to Path) or database
+	Store    string          `yaml:"store"`
+	Capacity int             `yaml:"capacity"`
+	Path     string          `yaml:"path"`
+	Database database.Config `yaml:"database"`
+}
+
+// Validate checks the sample rate, redaction patterns and store
+func (c Config) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate %v outside [0, 1]", c.SampleRate)
+	}
+	for _, pattern := range c.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+	}
+	switch c.Store {
+	case "", StoreMemory, StoreDatabase:
+	case StoreFile:
+		if c.Path == "" {
+			return fmt.Errorf("file capture store needs a path")
+		}
+	default:
+		return fmt.Errorf("unknown capture store %q", c.Store)
+	}
+	return nil
+}
+
+// Record is a captured request with its response
+type Record struct {
+	ID       string            `json:"id"`
+	Time     time.Time         `json:"time"`
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Query    string            `json:"query,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	UserID   string            `json:"user_id,omitempty"`
+	Model    string            `json:"model,omitempty"`
+	Request  string            `json:"request"`
+	Status   int               `json:"status"`
+	Response string            `json:"response"`
+	Latency  time.Duration     `json:"latency"`
+	// Truncated is set when a body exceeded the capture limit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Recorder decides which requests are captured, redacts them and saves
+// them to a store
+type Recorder struct {
+	cfg      Config
+	store    Store
+	keys     map[[32]byte]bool
+	tenants  map[string]bool
+	fields   map[string]bool
+	patterns []*regexp.Regexp
+	redactor Redactor
+	logger   *slog.Logger
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewRecorder creates a recorder saving to store
+func NewRecorder(cfg Config, store Store) (*Recorder, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if len(cfg.Routes) == 0 {
+		cfg.Routes = defaultRoutes
+	}
+
+	r := &Recorder{
+		cfg:     cfg,
+		store:   store,
+		keys:    make(map[[32]byte]bool, len(cfg.APIKeys)),
+		tenants: make(map[string]bool, len(cfg.Tenants)),
+		fields:  make(map[string]bool, len(cfg.RedactFields)),
+		logger:  slog.Default(),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, key := range cfg.APIKeys {
+		r.keys[sha256.Sum256([]byte(key))] = true
+	}
+	for _, tenant := range cfg.Tenants {
+		r.tenants[tenant] = true
+	}
+	for _, field := range cfg.RedactFields {
+		r.fields[strings.ToLower(field)] = true
+	}
+	for _, pattern := range cfg.RedactPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(pattern))
+	}
+	return r, nil
+}
+
+// Redactor redacts the prompts and completions in the bodies of a tenant's
+// requests and responses
+type Redactor interface {
+	RedactBody(tenant string, body []byte) []byte
+}
+
+// SetRedactor redacts captured bodies with redactor too, before the
+// redaction rules of the capture config are applied
+func (r *Recorder) SetRedactor(redactor Redactor) {
+	r.redactor = redactor
+}
+
+// Store returns the store captures are saved to
+func (r *Recorder) Store() Store {
+	return r.store
+}
+
+// shouldCapture reports whether a request to path, made with the API key
+// or for the tenant, is captured
+func (r *Recorder) shouldCapture(path, key, tenant string) bool {
+	captured := false
+	for _, prefix := range r.cfg.Routes {
+		if strings.HasPrefix(path, prefix) {
+			captured = true
+			break
+		}
+	}
+	if !captured {
+		return false
+	}
+
+	if tenant != "" && r.tenants[tenant] {
+		return true
+	}
+	if key != "" && r.keys[sha256.Sum256([]byte(key))] {
+		return true
+	}
+	if r.cfg.SampleRate <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64() < r.cfg.SampleRate
+}
+
+// Redact applies the redaction rules to a body, which is redacted field by
+// field when it is JSON (or newline-delimited JSON) and as text otherwise
+func (r *Recorder) Redact(body []byte) string {
+	if len(r.fields) == 0 && len(r.patterns) == 0 {
+		return string(body)
+	}
+
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		var value interface{}
+		if len(r.fields) == 0 || json.Unmarshal(line, &value) != nil {
+			lines[i] = []byte(r.redactText(string(line)))
+			continue
+		}
+		redacted, err := json.Marshal(r.redactValue(value))
+		if err != nil {
+			lines[i] = []byte(redactedValue)
+			continue
+		}
+		lines[i] = redacted
+	}
+	return string(bytes.Join(lines, []byte("\n")))
+}
+
+func (r *Recorder) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.fields[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = r.redactValue(field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = r.redactValue(v[i])
+		}
+		return v
+	case string:
+		return r.redactText(v)
+	default:
+		return v
+	}
+}
+
+func (r *Recorder) redactText(text string) string {
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllString(text, redactedValue)
+	}
+	return text
+}
+
+// Middleware returns a gin middleware capturing the requests selected by
+// the recorder. It must run after authentication, whose tenant it reads.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return r.Handle
+}
+
+// Handle captures the request if it is selected, running the rest of the
+// handler chain either way
+func (r *Recorder) Handle(c *gin.Context) {
+	if !r.shouldCapture(c.Request.URL.Path, bearerToken(c), c.GetString("tenant")) {
+		c.Next()
+		return
+	}
+
+	var request []byte
+	if c.Request.Body != nil {
+		var err error
+		request, err = io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(request))
+		if err != nil {
+			c.Next()
+			return
+		}
+	}
+
+	writer := &captureWriter{ResponseWriter: c.Writer, limit: r.cfg.MaxBodyBytes}
+	c.Writer = writer
+	start := time.Now()
+	c.Next()
+
+	rec := &Record{
+		ID:      uuid.NewString(),
+		Time:    start,
+		Method:  c.Request.Method,
+		Path:    c.Request.URL.Path,
+		Query:   c.Request.URL.RawQuery,
+		Tenant:  c.GetString("tenant"),
+		UserID:  c.GetString("user_id"),
+		Model:   requestModel(request),
+		Status:  writer.Status(),
+		Latency: time.Since(start),
+	}
+	for _, name := range capturedHeaders {
+		if value := c.GetHeader(name); value != "" {
+			if rec.Header == nil {
+				rec.Header = make(map[string]string)
+			}
+			rec.Header[name] = value
+		}
+	}
+	response := writer.body.Bytes()
+	if r.redactor != nil {
+		request = r.redactor.RedactBody(rec.Tenant, request)
+		response = r.redactor.RedactBody(rec.Tenant, response)
+	}
+	rec.Request, rec.Truncated = r.truncate(r.Redact(request))
+	redacted, truncated := r.truncate(r.Redact(response))
+	rec.Response = redacted
+	rec.Truncated = rec.Truncated || truncated || writer.truncated
+
+	if err := r.store.Save(c.Request.Context(), rec); err != nil {
+		r.logger.Warn("failed to save request capture", "path", rec.Path, "error", err)
+	}
+}
+
+// truncate caps a captured body at the configured size
+func (r *Recorder) truncate(body string) (string, bool) {
+	if len(body) <= r.cfg.MaxBodyBytes {
+		return body, false
+	}
+	return body[:r.cfg.MaxBodyBytes], true
+}
+
+// captureWriter copies the start of a response while writing it
+type captureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) keep(b []byte) {
+	if room := w.limit - w.body.Len(); room < len(b) {
+		b = b[:max(room, 0)]
+		w.truncated = true
+	}
+	w.body.Write(b)
+}
+
+// requestModel returns the model field of a JSON request body
+func requestModel(body []byte) string {
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return payload.Model
+}
+
+// bearerToken returns the bearer token of a request
+func bearerToken(c *gin.Context) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
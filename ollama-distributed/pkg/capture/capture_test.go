@@ -0,0 +1,228 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCaptureRouter(t *testing.T, cfg Config, store Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	recorder, err := NewRecorder(cfg, store)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("tenant", c.GetHeader("X-Test-Tenant"))
+	}, recorder.Middleware())
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"response": "hello alice@example.com"})
+	})
+	router.GET("/api/v1/models", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"models": []string{}})
+	})
+	return router
+}
+
+func send(router *gin.Engine, method, path, key, tenant, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	req.Header.Set("X-Test-Tenant", tenant)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCaptureSelection(t *testing.T) {
+	store := NewMemoryStore(10)
+	router := newCaptureRouter(t, Config{
+		APIKeys: []string{"flagged-key"},
+		Tenants: []string{"acme"},
+	}, store)
+
+	send(router, http.MethodPost, "/api/v1/generate", "other-key", "", `{"model": "a"}`)
+	send(router, http.MethodPost, "/api/v1/generate", "flagged-key", "", `{"model": "b"}`)
+	send(router, http.MethodPost, "/api/v1/generate", "other-key", "acme", `{"model": "c"}`)
+	send(router, http.MethodGet, "/api/v1/models", "flagged-key", "", "")
+
+	records, err := store.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 2, "only flagged keys and tenants on inference routes are captured")
+	assert.Equal(t, "c", records[0].Model)
+	assert.Equal(t, "acme", records[0].Tenant)
+	assert.Equal(t, "b", records[1].Model)
+	assert.Equal(t, http.StatusOK, records[1].Status)
+	assert.Equal(t, "application/json", records[1].Header["Content-Type"])
+	assert.NotContains(t, records[1].Header, "Authorization")
+
+	sampled := NewMemoryStore(10)
+	router = newCaptureRouter(t, Config{SampleRate: 1}, sampled)
+	send(router, http.MethodPost, "/api/v1/generate", "", "", `{"model": "a"}`)
+	records, err = sampled.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestCaptureRedaction(t *testing.T) {
+	store := NewMemoryStore(10)
+	router := newCaptureRouter(t, Config{
+		SampleRate:     1,
+		RedactFields:   []string{"system", "api_key"},
+		RedactPatterns: []string{`[\w.]+@[\w.]+`},
+		MaxBodyBytes:   512,
+	}, store)
+
+	send(router, http.MethodPost, "/api/v1/generate", "", "",
+		`{"model": "a", "system": "secret instructions", "options": {"api_key": "k"}, "prompt": "mail bob@example.com"}`)
+
+	records, err := store.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.JSONEq(t, `{"model": "a", "system": "[REDACTED]", "options": {"api_key": "[REDACTED]"}, "prompt": "mail [REDACTED]"}`, records[0].Request)
+	assert.JSONEq(t, `{"response": "hello [REDACTED]"}`, records[0].Response)
+	assert.False(t, records[0].Truncated)
+
+	_, err = NewRecorder(Config{RedactPatterns: []string{"("}}, store)
+	assert.Error(t, err)
+}
+
+// emailRedactor redacts emails, except for the tenant trusted
+type emailRedactor struct {
+	trusted string
+}
+
+func (r emailRedactor) RedactBody(tenant string, body []byte) []byte {
+	if tenant == r.trusted {
+		return body
+	}
+	return []byte(strings.ReplaceAll(string(body), "alice@example.com", "[EMAIL]"))
+}
+
+func TestCaptureRedactor(t *testing.T) {
+	store := NewMemoryStore(10)
+	recorder, err := NewRecorder(Config{SampleRate: 1}, store)
+	require.NoError(t, err)
+	recorder.SetRedactor(emailRedactor{trusted: "acme"})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("tenant", c.GetHeader("X-Test-Tenant"))
+	}, recorder.Middleware())
+	router.POST("/api/v1/generate", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"response": "hello alice@example.com"})
+	})
+
+	send(router, http.MethodPost, "/api/v1/generate", "", "", `{"prompt": "I am alice@example.com"}`)
+	send(router, http.MethodPost, "/api/v1/generate", "", "acme", `{"prompt": "I am alice@example.com"}`)
+
+	records, err := store.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Contains(t, records[0].Request, "alice@example.com", "tenants have their own policies")
+	assert.JSONEq(t, `{"prompt": "I am [EMAIL]"}`, records[1].Request)
+	assert.JSONEq(t, `{"response": "hello [EMAIL]"}`, records[1].Response)
+}
+
+func TestMemoryStoreKeepsLatest(t *testing.T) {
+	store := NewMemoryStore(3)
+	ctx := context.Background()
+	start := time.Now()
+	for i, model := range []string{"a", "b", "a", "b", "a"} {
+		require.NoError(t, store.Save(ctx, &Record{ID: model + string(rune('0'+i)), Model: model, Time: start.Add(time.Duration(i) * time.Second)}))
+	}
+
+	records, err := store.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a4", "b3", "a2"}, ids(records))
+
+	records, err = store.List(ctx, Filter{Model: "a", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a4"}, ids(records))
+
+	_, err = store.Get(ctx, "a0")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captures.jsonl")
+	store, err := NewFileStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, &Record{ID: "1", Tenant: "acme", Request: `{"model":"a"}`}))
+	require.NoError(t, store.Save(ctx, &Record{ID: "2", Tenant: "globex"}))
+
+	rec, err := store.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"model":"a"}`, rec.Request)
+
+	records, err := store.List(ctx, Filter{Tenant: "globex"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids(records))
+
+	records, err = ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(records))
+}
+
+func TestReplay(t *testing.T) {
+	var got map[string]interface{}
+	var strategy, auth string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/generate", r.URL.Path)
+		strategy = r.Header.Get(StrategyHeader)
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(`{"response":"hi"}`))
+	}))
+	defer target.Close()
+
+	rec := &Record{
+		ID:       "1",
+		Method:   http.MethodPost,
+		Path:     "/api/v1/generate",
+		Model:    "llama3",
+		Request:  `{"model":"llama3","prompt":"hello"}`,
+		Status:   http.StatusOK,
+		Response: `{"response":"hello"}`,
+	}
+	result, err := Replay(context.Background(), rec, ReplayOptions{
+		Target:   target.URL,
+		Token:    "t",
+		Model:    "mistral",
+		Strategy: "least_connections",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"model": "mistral", "prompt": "hello"}, got)
+	assert.Equal(t, "least_connections", strategy)
+	assert.Equal(t, "Bearer t", auth)
+	assert.Equal(t, "mistral", result.Model)
+	assert.Equal(t, "llama3", result.OriginalModel)
+	assert.Equal(t, http.StatusOK, result.Status)
+	assert.False(t, result.SameResponse)
+
+	rec.Truncated = true
+	_, err = Replay(context.Background(), rec, ReplayOptions{Target: target.URL})
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func ids(records []*Record) []string {
+	result := make([]string, len(records))
+	for i, rec := range records {
+		result[i] = rec.ID
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrTruncated is returned when replaying a capture whose request body was
+// truncated
+var ErrTruncated = errors.New("captured request was truncated")
+
+// ReplayOptions configures where and how captures are replayed
+type ReplayOptions struct {
+	// Target is the base URL of the API the captures are sent to
+	Target string
+	// Token authenticates the replayed requests
+	Token string
+	// Model and Strategy, when set, replace the captured model and the
+	// load balancing strategy of the request
+	Model    string
+	Strategy string
+	Client   *http.Client
+}
+
+// ReplayResult compares a replayed request with its capture
+type ReplayResult struct {
+	ID              string        `json:"id"`
+	Model           string        `json:"model"`
+	Status          int           `json:"status"`
+	Latency         time.Duration `json:"latency"`
+	Response        string        `json:"response"`
+	OriginalModel   string        `json:"original_model"`
+	OriginalStatus  int           `json:"original_status"`
+	OriginalLatency time.Duration `json:"original_latency"`
+	// SameResponse is set when the response body is identical to the
+	// captured one
+	SameResponse bool `json:"same_response"`
+}
+
+// Replay re-sends a captured request to opts.Target
+func Replay(ctx context.Context, rec *Record, opts ReplayOptions) (*ReplayResult, error) {
+	if rec.Truncated {
+		return nil, ErrTruncated
+	}
+
+	body := []byte(rec.Request)
+	model := rec.Model
+	if opts.Model != "" {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("cannot replace the model of a non-JSON request: %w", err)
+		}
+		payload["model"] = opts.Model
+		model = opts.Model
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	target := strings.TrimSuffix(opts.Target, "/") + rec.Path
+	if rec.Query != "" {
+		target += "?" + rec.Query
+	}
+	req, err := http.NewRequestWithContext(ctx, rec.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range rec.Header {
+		req.Header.Set(name, value)
+	}
+	if opts.Strategy != "" {
+		req.Header.Set(StrategyHeader, opts.Strategy)
+	}
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("replay failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay response: %w", err)
+	}
+
+	return &ReplayResult{
+		ID:              rec.ID,
+		Model:           model,
+		Status:          resp.StatusCode,
+		Latency:         time.Since(start),
+		Response:        string(response),
+		OriginalModel:   rec.Model,
+		OriginalStatus:  rec.Status,
+		OriginalLatency: rec.Latency,
+		SameResponse:    string(response) == rec.Response,
+	}, nil
+}
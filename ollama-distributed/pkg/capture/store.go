@@ -0,0 +1,316 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// ErrNotFound is returned for a capture that is not in the store
+var ErrNotFound = errors.New("capture not found")
+
+// defaultCapacity is the number of captures the memory store keeps
+const defaultCapacity = 1000
+
+// Filter selects captures when listing them
+type Filter struct {
+	Tenant string
+	Model  string
+	Since  time.Time
+	// Limit caps the number of captures returned, newest first
+	Limit int
+}
+
+func (f Filter) matches(rec *Record) bool {
+	return (f.Tenant == "" || rec.Tenant == f.Tenant) &&
+		(f.Model == "" || rec.Model == f.Model) &&
+		!rec.Time.Before(f.Since)
+}
+
+// Apply returns those of records, given oldest first, that match the
+// filter, newest first
+func (f Filter) Apply(records []*Record) []*Record {
+	var result []*Record
+	for i := len(records) - 1; i >= 0; i-- {
+		if !f.matches(records[i]) {
+			continue
+		}
+		result = append(result, records[i])
+		if f.Limit > 0 && len(result) == f.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Store keeps captured requests
+type Store interface {
+	Save(ctx context.Context, rec *Record) error
+	Get(ctx context.Context, id string) (*Record, error)
+	// List returns the captures matching filter, newest first
+	List(ctx context.Context, filter Filter) ([]*Record, error)
+	Close() error
+}
+
+// OpenStore opens the store configured by cfg, migrating the database of a
+// database store
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewMemoryStore(cfg.Capacity), nil
+	case StoreFile:
+		return NewFileStore(cfg.Path)
+	case StoreDatabase:
+		db := cfg.Database
+		manager, err := database.NewManager(&db)
+		if err != nil {
+			return nil, err
+		}
+		if err := manager.RunMigrations(context.Background()); err != nil {
+			manager.Close()
+			return nil, err
+		}
+		return NewDatabaseStore(manager), nil
+	default:
+		return nil, fmt.Errorf("unknown capture store %q", cfg.Store)
+	}
+}
+
+// MemoryStore keeps the latest captures in memory
+type MemoryStore struct {
+	mu       sync.RWMutex
+	records  []*Record
+	next     int
+	capacity int
+}
+
+// NewMemoryStore creates a store keeping the latest capacity captures
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &MemoryStore{capacity: capacity}
+}
+
+// Save adds a capture, dropping the oldest one when full
+func (s *MemoryStore) Save(_ context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) < s.capacity {
+		s.records = append(s.records, rec)
+		return nil
+	}
+	s.records[s.next] = rec
+	s.next = (s.next + 1) % s.capacity
+	return nil
+}
+
+// Get returns a capture by ID
+func (s *MemoryStore) Get(_ context.Context, id string) (*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rec := range s.records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns the captures matching filter, newest first
+func (s *MemoryStore) List(_ context.Context, filter Filter) ([]*Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Record
+	for i := len(s.records) - 1; i >= 0; i-- {
+		rec := s.records[(s.next+i)%len(s.records)]
+		if !filter.matches(rec) {
+			continue
+		}
+		result = append(result, rec)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Close does nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// FileStore appends captures to a file as JSON lines, which the replay
+// command can read directly
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileStore opens, creating it if needed, the capture file at path
+func NewFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+	return &FileStore{path: path, file: file}, nil
+}
+
+// Save appends a capture to the file
+func (s *FileStore) Save(_ context.Context, rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Get returns a capture by ID
+func (s *FileStore) Get(ctx context.Context, id string) (*Record, error) {
+	records, err := ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns the captures matching filter, newest first
+func (s *FileStore) List(ctx context.Context, filter Filter) ([]*Record, error) {
+	records, err := ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Apply(records), nil
+}
+
+// Close closes the capture file
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+// ReadFile reads the captures written by a file store, oldest first
+func ReadFile(path string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// DatabaseStore keeps captures in the inference_captures table
+type DatabaseStore struct {
+	db *database.Manager
+}
+
+// NewDatabaseStore creates a store on db, whose migrations must have run
+func NewDatabaseStore(db *database.Manager) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Save inserts a capture
+func (s *DatabaseStore) Save(ctx context.Context, rec *Record) error {
+	return s.db.CreateInferenceCapture(ctx, toRow(rec))
+}
+
+// Get returns a capture by ID
+func (s *DatabaseStore) Get(ctx context.Context, id string) (*Record, error) {
+	row, err := s.db.GetInferenceCapture(ctx, id)
+	if errors.Is(err, database.ErrCaptureNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromRow(row), nil
+}
+
+// List returns the captures matching filter, newest first
+func (s *DatabaseStore) List(ctx context.Context, filter Filter) ([]*Record, error) {
+	rows, err := s.db.ListInferenceCaptures(ctx, filter.Tenant, filter.Model, filter.Since, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*Record, len(rows))
+	for i, row := range rows {
+		records[i] = fromRow(row)
+	}
+	return records, nil
+}
+
+// Close closes the database connection
+func (s *DatabaseStore) Close() error {
+	return s.db.Close()
+}
+
+func toRow(rec *Record) *database.InferenceCapture {
+	return &database.InferenceCapture{
+		ID:           rec.ID,
+		CapturedAt:   rec.Time,
+		Method:       rec.Method,
+		Path:         rec.Path,
+		Query:        rec.Query,
+		Headers:      rec.Header,
+		TenantID:     rec.Tenant,
+		UserID:       rec.UserID,
+		Model:        rec.Model,
+		RequestBody:  rec.Request,
+		Status:       rec.Status,
+		ResponseBody: rec.Response,
+		LatencyMs:    int(rec.Latency.Milliseconds()),
+		Truncated:    rec.Truncated,
+	}
+}
+
+func fromRow(row *database.InferenceCapture) *Record {
+	return &Record{
+		ID:        row.ID,
+		Time:      row.CapturedAt,
+		Method:    row.Method,
+		Path:      row.Path,
+		Query:     row.Query,
+		Header:    row.Headers,
+		Tenant:    row.TenantID,
+		UserID:    row.UserID,
+		Model:     row.Model,
+		Request:   row.RequestBody,
+		Status:    row.Status,
+		Response:  row.ResponseBody,
+		Latency:   time.Duration(row.LatencyMs) * time.Millisecond,
+		Truncated: row.Truncated,
+	}
+}
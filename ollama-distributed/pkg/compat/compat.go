@@ -0,0 +1,306 @@
+// Package compat negotiates compatibility between nodes running different
+// versions of the binary. Nodes advertise their version, the range of wire
+// protocol versions they speak and the wire behaviors they support when
+// they connect. Peers whose protocol ranges do not overlap are refused,
+// peers whose versions are further apart than supported are warned about,
+// and new wire behaviors stay off until every member supports them, so
+// that a cluster can be upgraded one node at a time.
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the version of the wire protocol this build speaks.
+// It is raised whenever a wire behavior is added, and the behavior is
+// listed in features with the version that introduced it.
+const ProtocolVersion = 2
+
+// MinProtocolVersion is the oldest protocol version of a peer this build
+// still talks to
+const MinProtocolVersion = 1
+
+// LegacyProtocolVersion is assumed of peers from before the handshake,
+// which advertise nothing
+const LegacyProtocolVersion = 1
+
+// DefaultMaxMinorSkew is how many minor versions apart nodes are supported
+// by default
+const DefaultMaxMinorSkew = 1
+
+// Feature is a wire behavior used only once every member supports it
+type Feature string
+
+// Wire behaviors gated on the support of every member
+const (
+	// FeatureHeartbeatRTT sends heartbeats as probes carrying RTTs, echoed
+	// by the receiver, instead of empty streams
+	FeatureHeartbeatRTT Feature = "heartbeat_rtt"
+)
+
+// features are the wire behaviors of this build and the protocol version
+// that introduced each
+var features = map[Feature]int{
+	FeatureHeartbeatRTT: 2,
+}
+
+// ErrIncompatible is returned for peers that speak no protocol version in
+// common with this node
+var ErrIncompatible = errors.New("incompatible protocol version")
+
+// Config configures the version skew tolerated between nodes
+type Config struct {
+	// MaxMinorSkew is how many minor versions apart the binaries of nodes
+	// of the same major version are supported, 1 by default. Nodes
+	// further apart are warned about but still admitted if their
+	// protocols are compatible.
+	MaxMinorSkew int `yaml:"max_minor_skew" mapstructure:"max_minor_skew"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.MaxMinorSkew < 0 {
+		return fmt.Errorf("max minor skew must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.MaxMinorSkew == 0 {
+		c.MaxMinorSkew = DefaultMaxMinorSkew
+	}
+	return c
+}
+
+// Info is what a node advertises of itself when it connects
+type Info struct {
+	NodeID      string    `json:"node_id"`
+	Version     string    `json:"version"`
+	Protocol    int       `json:"protocol"`
+	MinProtocol int       `json:"min_protocol"`
+	Features    []Feature `json:"features"`
+}
+
+// Local returns the info this build advertises for a node
+func Local(nodeID, version string) Info {
+	info := Info{
+		NodeID:      nodeID,
+		Version:     version,
+		Protocol:    ProtocolVersion,
+		MinProtocol: MinProtocolVersion,
+	}
+	for feature, since := range features {
+		if since <= ProtocolVersion {
+			info.Features = append(info.Features, feature)
+		}
+	}
+	sort.Slice(info.Features, func(i, j int) bool { return info.Features[i] < info.Features[j] })
+	return info
+}
+
+// Legacy returns the info assumed of a peer from before the handshake
+func Legacy(nodeID string) Info {
+	return Info{NodeID: nodeID, Protocol: LegacyProtocolVersion, MinProtocol: LegacyProtocolVersion}
+}
+
+// Supports reports whether the node advertised the feature
+func (i Info) Supports(feature Feature) bool {
+	for _, f := range i.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns ErrIncompatible if the two nodes speak no protocol version
+// in common, and otherwise a warning if their versions are further apart
+// than supported
+func Check(local, remote Info, cfg Config) (warning string, err error) {
+	if remote.Protocol < local.MinProtocol || local.Protocol < remote.MinProtocol {
+		return "", fmt.Errorf("%w: node %s speaks protocol %d-%d, this node %d-%d",
+			ErrIncompatible, remote.NodeID, remote.MinProtocol, remote.Protocol, local.MinProtocol, local.Protocol)
+	}
+
+	ours, ok := parseVersion(local.Version)
+	if !ok {
+		return "", nil
+	}
+	theirs, ok := parseVersion(remote.Version)
+	if !ok {
+		if remote.Version == "" {
+			return fmt.Sprintf("node %s does not advertise its version", remote.NodeID), nil
+		}
+		return "", nil
+	}
+	cfg = cfg.WithDefaults()
+	if ours.major != theirs.major {
+		return fmt.Sprintf("node %s runs %s, a different major version than %s", remote.NodeID, remote.Version, local.Version), nil
+	}
+	if skew := abs(ours.minor - theirs.minor); skew > cfg.MaxMinorSkew {
+		return fmt.Sprintf("node %s runs %s, %d minor versions from %s; at most %d are supported",
+			remote.NodeID, remote.Version, skew, local.Version, cfg.MaxMinorSkew), nil
+	}
+	return "", nil
+}
+
+// version is the major and minor number of a semantic version
+type version struct {
+	major, minor int
+}
+
+// parseVersion parses versions like v1.4.2 and 1.4.0-rc.1. Development
+// builds, with versions like dev, do not parse.
+func parseVersion(s string) (version, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(s, "v"), ".", 3)
+	if len(parts) < 2 {
+		return version{}, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return version{}, false
+	}
+	if end := strings.IndexAny(parts[1], "-+"); end >= 0 {
+		parts[1] = parts[1][:end]
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return version{}, false
+	}
+	return version{major: major, minor: minor}, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Member is a peer that completed the handshake, with the warning about
+// its version skew if any
+type Member struct {
+	Info
+	Warning string    `json:"warning,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// Status is the versions of the cluster's members and the wire behaviors
+// every member supports
+type Status struct {
+	Local   Info      `json:"local"`
+	Members []Member  `json:"members"`
+	Enabled []Feature `json:"enabled_features"`
+	// MinProtocol is the oldest protocol version spoken in the cluster
+	MinProtocol int `json:"min_protocol"`
+}
+
+// Cluster keeps the versions the members advertised, admits compatible
+// peers and gates wire behaviors on their support. It is safe for
+// concurrent use.
+type Cluster struct {
+	local Info
+	cfg   Config
+
+	mu       sync.RWMutex
+	members  map[string]Member
+	observer func(Member)
+}
+
+// NewCluster returns the cluster of a node advertising local
+func NewCluster(local Info, cfg Config) *Cluster {
+	return &Cluster{
+		local:   local,
+		cfg:     cfg.WithDefaults(),
+		members: make(map[string]Member),
+	}
+}
+
+// SetObserver calls observer with every member admitted with a version
+// skew warning
+func (c *Cluster) SetObserver(observer func(Member)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observer = observer
+}
+
+// Local returns what this node advertises
+func (c *Cluster) Local() Info {
+	return c.local
+}
+
+// Check checks a peer against this node without admitting it
+func (c *Cluster) Check(remote Info) (warning string, err error) {
+	return Check(c.local, remote, c.cfg)
+}
+
+// Admit checks a peer and, if it is compatible, keeps it as a member. It
+// returns ErrIncompatible for peers that must be refused.
+func (c *Cluster) Admit(remote Info) (Member, error) {
+	warning, err := c.Check(remote)
+	if err != nil {
+		return Member{}, err
+	}
+	member := Member{Info: remote, Warning: warning, Since: time.Now()}
+
+	c.mu.Lock()
+	c.members[remote.NodeID] = member
+	observer := c.observer
+	c.mu.Unlock()
+
+	if observer != nil && warning != "" {
+		observer(member)
+	}
+	return member, nil
+}
+
+// Remove forgets a member that left
+func (c *Cluster) Remove(nodeID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.members, nodeID)
+}
+
+// Enabled reports whether every member, and this node, supports a wire
+// behavior
+func (c *Cluster) Enabled(feature Feature) bool {
+	if !c.local.Supports(feature) {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, member := range c.members {
+		if !member.Supports(feature) {
+			return false
+		}
+	}
+	return true
+}
+
+// Status returns the versions of the members, sorted by node ID, and the
+// wire behaviors enabled
+func (c *Cluster) Status() Status {
+	status := Status{Local: c.local, Members: []Member{}, Enabled: []Feature{}, MinProtocol: c.local.Protocol}
+
+	c.mu.RLock()
+	for _, member := range c.members {
+		status.Members = append(status.Members, member)
+		status.MinProtocol = min(status.MinProtocol, member.Protocol)
+	}
+	c.mu.RUnlock()
+	sort.Slice(status.Members, func(i, j int) bool { return status.Members[i].NodeID < status.Members[j].NodeID })
+
+	for _, feature := range c.local.Features {
+		if c.Enabled(feature) {
+			status.Enabled = append(status.Enabled, feature)
+		}
+	}
+	return status
+}
@@ -0,0 +1,69 @@
+package compat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	local := Local("a", "v1.4.2")
+	cfg := Config{}
+
+	tests := []struct {
+		name         string
+		remote       Info
+		incompatible bool
+		warns        bool
+	}{
+		{"same version", Local("b", "1.4.0"), false, false},
+		{"one minor apart", Local("b", "v1.5.0-rc.1"), false, false},
+		{"two minors apart", Local("b", "v1.2.9"), false, true},
+		{"other major", Local("b", "v2.0.0"), false, true},
+		{"development build", Local("b", "dev"), false, false},
+		{"legacy peer", Legacy("b"), false, true},
+		{"too old protocol", Info{NodeID: "b", Version: "v0.9.0", Protocol: 0}, true, false},
+		{"too new protocol", Info{NodeID: "b", Version: "v3.0.0", Protocol: 5, MinProtocol: 3}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, err := Check(local, tt.remote, cfg)
+			assert.Equal(t, tt.incompatible, errors.Is(err, ErrIncompatible), err)
+			assert.Equal(t, tt.warns, warning != "", warning)
+		})
+	}
+
+	warning, err := Check(local, Local("b", "v1.2.0"), Config{MaxMinorSkew: 2})
+	require.NoError(t, err)
+	assert.Empty(t, warning, "the supported skew is configurable")
+}
+
+func TestClusterGatesFeatures(t *testing.T) {
+	cluster := NewCluster(Local("a", "v1.4.0"), Config{})
+	var warned []Member
+	cluster.SetObserver(func(m Member) { warned = append(warned, m) })
+
+	_, err := cluster.Admit(Local("b", "v1.4.1"))
+	require.NoError(t, err)
+	assert.True(t, cluster.Enabled(FeatureHeartbeatRTT))
+
+	_, err = cluster.Admit(Legacy("c"))
+	require.NoError(t, err)
+	assert.False(t, cluster.Enabled(FeatureHeartbeatRTT), "a member without the feature turns it off")
+	require.Len(t, warned, 1)
+	assert.Equal(t, "c", warned[0].NodeID)
+
+	_, err = cluster.Admit(Info{NodeID: "d", Protocol: 9, MinProtocol: 9})
+	assert.ErrorIs(t, err, ErrIncompatible)
+
+	status := cluster.Status()
+	require.Len(t, status.Members, 2, "incompatible peers are not members")
+	assert.Equal(t, LegacyProtocolVersion, status.MinProtocol)
+	assert.Empty(t, status.Enabled)
+
+	cluster.Remove("c")
+	assert.True(t, cluster.Enabled(FeatureHeartbeatRTT), "the feature turns on once every member supports it")
+	assert.Equal(t, []Feature{FeatureHeartbeatRTT}, cluster.Status().Enabled)
+}
@@ -28,6 +28,10 @@ type NodeConfig struct {
 	AnnounceAddresses   []string `yaml:"announce_addresses"`
 	NoAnnounceAddresses []string `yaml:"no_announce_addresses"`
 
+	// Transports
+	EnableQUIC             bool     `yaml:"enable_quic"`
+	QUICPreferredProtocols []string `yaml:"quic_preferred_protocols"` // protocols routed over QUIC when available
+
 	// Security
 	PrivateKey  string `yaml:"private_key"`
 	EnableTLS   bool   `yaml:"enable_tls"`
@@ -52,9 +56,10 @@ type NodeConfig struct {
 	ConnMgrGrace time.Duration `yaml:"conn_mgr_grace"`
 
 	// Resource Management
-	MaxMemory int64   `yaml:"max_memory"`
-	MaxCPU    float64 `yaml:"max_cpu"`
-	MaxGPU    int     `yaml:"max_gpu"`
+	MaxMemory        int64         `yaml:"max_memory"`
+	MaxCPU           float64       `yaml:"max_cpu"`
+	MaxGPU           int           `yaml:"max_gpu"`
+	ResourceInterval time.Duration `yaml:"resource_interval"` // host metrics sampling period
 
 	// Ollamacron Specific
 	NodeType          string            `yaml:"node_type"` // edge/standard/super
@@ -74,24 +79,39 @@ func DefaultConfig() *NodeConfig {
 		Listen: []string{
 			"/ip4/0.0.0.0/tcp/0",
 			"/ip6/::/tcp/0",
-			"/ip4/0.0.0.0/udp/0/quic",
-			"/ip6/::/udp/0/quic",
+			"/ip4/0.0.0.0/udp/0/quic-v1",
+			"/ip6/::/udp/0/quic-v1",
 		},
-		EnableTLS:          true,
-		EnableNoise:        true,
-		EnableNATService:   true,
-		EnableHolePunching: true,
-		EnableAutoRelay:    true,
-		EnableDHT:          true,
-		DHTMode:            "auto",
-		ConnMgrLow:         50,
-		ConnMgrHigh:        200,
-		ConnMgrGrace:       time.Minute,
-		NodeType:           "standard",
-		ModelCapabilities:  []string{},
-		ResourceTags:       make(map[string]string),
-		RendezvousString:   "ollama-distributed",
-		AutoDiscovery:      true,
+		EnableQUIC:             true,
+		QUICPreferredProtocols: DefaultQUICPreferredProtocols(),
+		EnableTLS:              true,
+		EnableNoise:            true,
+		EnableNATService:       true,
+		EnableHolePunching:     true,
+		EnableAutoRelay:        true,
+		EnableDHT:              true,
+		DHTMode:                "auto",
+		ConnMgrLow:             50,
+		ConnMgrHigh:            200,
+		ConnMgrGrace:           time.Minute,
+		ResourceInterval:       10 * time.Second,
+		NodeType:               "standard",
+		ModelCapabilities:      []string{},
+		ResourceTags:           make(map[string]string),
+		RendezvousString:       "ollama-distributed",
+		AutoDiscovery:          true,
+	}
+}
+
+// DefaultQUICPreferredProtocols returns the protocols that carry inference,
+// model chunk and blob traffic, which benefit most from QUIC's lower
+// handshake cost and lack of head-of-line blocking
+func DefaultQUICPreferredProtocols() []string {
+	return []string{
+		"/ollama-distributed/inference/1.0.0",
+		"/ollama/model-transfer/1.0.0",
+		"/ollama/model-chunk/1.0.0",
+		"/ollama-distributed/blob/1.0.0",
 	}
 }
 
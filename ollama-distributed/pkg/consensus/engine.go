@@ -3,6 +3,7 @@ package consensus
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus/watch"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/messaging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/monitoring"
@@ -42,6 +44,10 @@ type Engine struct {
 	// Leadership tracking (atomic for thread safety)
 	isLeader int64 // Use atomic operations
 	leaderCh chan bool
+	// leadershipObserver is told about every leadership change, and
+	// membershipCheck refuses members their vote, guarded by mu
+	leadershipObserver func(isLeader bool)
+	membershipCheck    func(address string) error
 
 	// Advanced leader election
 	leaderElection *LeaderElectionManager
@@ -72,6 +78,8 @@ type ApplyEvent struct {
 	Value     interface{}            `json:"value"`
 	Timestamp time.Time              `json:"timestamp"`
 	Metadata  map[string]interface{} `json:"metadata"`
+	// Txn is the transaction of a "txn" event
+	Txn *Txn `json:"txn,omitempty"`
 }
 
 // FSM implements the Raft finite state machine
@@ -79,6 +87,7 @@ type FSM struct {
 	state      map[string]interface{}
 	stateMu    sync.RWMutex
 	applyCh    chan *ApplyEvent
+	watches    *watch.Hub
 	shutdown   bool
 	shutdownMu sync.RWMutex
 }
@@ -99,6 +108,7 @@ func NewEngine(config *config.ConsensusConfig, p2pNode *p2p.Node, messageRouter
 	engine.fsm = &FSM{
 		state:   make(map[string]interface{}),
 		applyCh: engine.applyCh,
+		watches: watch.NewHub(0, 0),
 	}
 
 	if err := engine.initRaft(); err != nil {
@@ -199,10 +209,24 @@ func (e *Engine) monitorLeadership() {
 			case e.leaderCh <- isLeader:
 			default:
 			}
+			e.mu.RLock()
+			observer := e.leadershipObserver
+			e.mu.RUnlock()
+			if observer != nil {
+				go observer(isLeader)
+			}
 		}
 	}
 }
 
+// SetLeadershipObserver sets a function told, in its own goroutine, about
+// every change of this node's leadership
+func (e *Engine) SetLeadershipObserver(fn func(isLeader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leadershipObserver = fn
+}
+
 // Start starts the consensus engine
 func (e *Engine) Start() error {
 	e.mu.Lock()
@@ -245,8 +269,6 @@ func (e *Engine) processEvents() {
 			delete(e.state, event.Key)
 		}
 		e.stateMu.Unlock()
-
-		// TODO: Notify subscribers
 	}
 }
 
@@ -359,11 +381,33 @@ func (e *Engine) Leader() string {
 	return string(e.raft.Leader())
 }
 
+// SetMembershipCheck sets a check a member must pass, by its address, to
+// be added as a voter or keep its vote, e.g. that its peer is compatible
+func (e *Engine) SetMembershipCheck(check func(address string) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.membershipCheck = check
+}
+
+// checkMembership runs the membership check on an address
+func (e *Engine) checkMembership(address string) error {
+	e.mu.RLock()
+	check := e.membershipCheck
+	e.mu.RUnlock()
+	if check == nil {
+		return nil
+	}
+	return check(address)
+}
+
 // AddVoter adds a voting member to the cluster
 func (e *Engine) AddVoter(id string, address string) error {
 	if !e.IsLeader() {
 		return fmt.Errorf("not leader, cannot add voter")
 	}
+	if err := e.checkMembership(address); err != nil {
+		return err
+	}
 
 	future := e.raft.AddVoter(raft.ServerID(id), raft.ServerAddress(address), 0, 10*time.Second)
 	return future.Error()
@@ -379,6 +423,84 @@ func (e *Engine) RemoveServer(id string) error {
 	return future.Error()
 }
 
+// DemoteVoter takes the vote of a member away. It keeps receiving the log
+// as a non-voter but no longer counts towards the quorum.
+func (e *Engine) DemoteVoter(id string) error {
+	if !e.IsLeader() {
+		return fmt.Errorf("not leader, cannot demote voter")
+	}
+
+	future := e.raft.DemoteVoter(raft.ServerID(id), 0, 10*time.Second)
+	return future.Error()
+}
+
+// PromoteNonvoter gives a non-voting member its vote back
+func (e *Engine) PromoteNonvoter(id string) error {
+	if !e.IsLeader() {
+		return fmt.Errorf("not leader, cannot promote non-voter")
+	}
+
+	config, err := e.GetConfiguration()
+	if err != nil {
+		return err
+	}
+	for _, server := range config.Servers {
+		if server.ID != raft.ServerID(id) {
+			continue
+		}
+		if server.Suffrage == raft.Voter {
+			return nil
+		}
+		if err := e.checkMembership(string(server.Address)); err != nil {
+			return err
+		}
+		future := e.raft.AddVoter(server.ID, server.Address, 0, 10*time.Second)
+		return future.Error()
+	}
+	return fmt.Errorf("%s is not a member of the cluster", id)
+}
+
+// ReconcileSuffrage demotes the members quarantined reports for, or failing
+// the membership check, and gives the other non-voters their vote back.
+// quarantined is asked about both the Raft server ID and the address of a
+// member, which is its libp2p peer ID. The leader keeps its own vote.
+func (e *Engine) ReconcileSuffrage(quarantined func(id string) bool) error {
+	if !e.IsLeader() {
+		return fmt.Errorf("not leader, cannot change suffrage")
+	}
+	return reconcileSuffrage(e.raft, raft.ServerID(e.GetNodeID()), func(id string) bool {
+		return quarantined(id) || e.checkMembership(id) != nil
+	})
+}
+
+func reconcileSuffrage(r *raft.Raft, local raft.ServerID, quarantined func(id string) bool) error {
+	future := r.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, server := range future.Configuration().Servers {
+		if server.ID == local {
+			continue
+		}
+		isQuarantined := quarantined(string(server.ID)) || quarantined(string(server.Address))
+		var change raft.IndexFuture
+		switch {
+		case isQuarantined && server.Suffrage == raft.Voter:
+			change = r.DemoteVoter(server.ID, 0, 10*time.Second)
+		case !isQuarantined && server.Suffrage == raft.Nonvoter:
+			change = r.AddVoter(server.ID, server.Address, 0, 10*time.Second)
+		default:
+			continue
+		}
+		if err := change.Error(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // GetConfiguration returns the current cluster configuration
 func (e *Engine) GetConfiguration() (*raft.Configuration, error) {
 	future := e.raft.GetConfiguration()
@@ -428,6 +550,11 @@ func (e *Engine) Shutdown(ctx context.Context) error {
 	// Close apply channel
 	close(e.applyCh)
 
+	// End watches
+	if e.fsm.watches != nil {
+		e.fsm.watches.Close()
+	}
+
 	// Shutdown Raft
 	if e.raft != nil {
 		future := e.raft.Shutdown()
@@ -484,6 +611,8 @@ func (f *FSM) Apply(log *raft.Log) interface{} {
 	}
 
 	// Apply state changes atomically
+	applied := []ApplyEvent{event}
+	var response interface{}
 	switch event.Type {
 	case "set":
 		f.state[event.Key] = event.Value
@@ -496,46 +625,74 @@ func (f *FSM) Apply(log *raft.Log) interface{} {
 		} else {
 			return fmt.Errorf("cannot update non-existent key: %s", event.Key)
 		}
+	case "txn":
+		var result TxnResult
+		result, applied = f.applyTxn(event.Txn, event.Timestamp)
+		result.Revision = log.Index
+		response = result
 	default:
 		return fmt.Errorf("unknown event type: %s", event.Type)
 	}
+	if f.watches != nil {
+		for _, change := range applied {
+			f.watches.Publish(watch.Event{
+				Revision:  log.Index,
+				Type:      change.Type,
+				Key:       change.Key,
+				Value:     change.Value,
+				Timestamp: change.Timestamp,
+			})
+		}
+	}
 
 	// Send to apply channel with timeout to prevent blocking
 	// Use goroutine to prevent blocking the FSM apply operation
 	// Check if FSM is shutting down before sending
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// Channel was closed, ignore the panic - this is expected during shutdown
-				fmt.Printf("Debug: apply channel closed during event send for key %s (expected during shutdown)\n", event.Key)
-			}
-		}()
-
-		// Check if shutdown is in progress
-		f.shutdownMu.RLock()
-		isShutdown := f.shutdown
-		f.shutdownMu.RUnlock()
+	for i := range applied {
+		go f.notify(&applied[i])
+	}
 
-		if isShutdown {
-			// Skip sending to closed channel during shutdown
-			return
-		}
+	return response
+}
 
-		select {
-		case f.applyCh <- &event:
-			// Successfully sent
-		case <-time.After(1 * time.Second):
-			// Log warning but continue - don't fail the consensus operation
-			fmt.Printf("Warning: apply channel full, dropping event notification for key %s\n", event.Key)
+// notify sends an applied change to the apply channel
+func (f *FSM) notify(event *ApplyEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Channel was closed, ignore the panic - this is expected during shutdown
+			fmt.Printf("Debug: apply channel closed during event send for key %s (expected during shutdown)\n", event.Key)
 		}
 	}()
 
-	return nil
+	// Check if shutdown is in progress
+	f.shutdownMu.RLock()
+	isShutdown := f.shutdown
+	f.shutdownMu.RUnlock()
+
+	if isShutdown {
+		// Skip sending to closed channel during shutdown
+		return
+	}
+
+	select {
+	case f.applyCh <- event:
+		// Successfully sent
+	case <-time.After(1 * time.Second):
+		// Log warning but continue - don't fail the consensus operation
+		fmt.Printf("Warning: apply channel full, dropping event notification for key %s\n", event.Key)
+	}
 }
 
 // validateEvent validates an event before applying it
 func (f *FSM) validateEvent(event *ApplyEvent) error {
-	if event.Key == "" {
+	if event.Type == "txn" {
+		if event.Txn == nil {
+			return fmt.Errorf("transaction event without a transaction")
+		}
+		if err := event.Txn.Validate(); err != nil {
+			return err
+		}
+	} else if event.Key == "" {
 		return fmt.Errorf("event key cannot be empty")
 	}
 
@@ -578,6 +735,9 @@ func (f *FSM) Restore(snapshot io.ReadCloser) error {
 	defer f.stateMu.Unlock()
 
 	f.state = state
+	if f.watches != nil {
+		f.watches.Reset()
+	}
 	return nil
 }
 
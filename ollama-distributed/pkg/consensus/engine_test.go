@@ -322,6 +322,86 @@ func TestEngine_ClusterOperations(t *testing.T) {
 	// This should not fail as removing non-existent server is generally safe
 }
 
+// TestReconcileSuffrage tests that quarantined members lose their vote and
+// get it back once released
+func TestReconcileSuffrage(t *testing.T) {
+	ids := []raft.ServerID{"node-a", "node-b", "node-c"}
+	transports := make([]*raft.InmemTransport, len(ids))
+	servers := make([]raft.Server, len(ids))
+	for i, id := range ids {
+		// Members are addressed by their peer ID
+		addr, transport := raft.NewInmemTransport(raft.ServerAddress("peer-" + string(id)))
+		transports[i] = transport
+		servers[i] = raft.Server{ID: id, Address: addr, Suffrage: raft.Voter}
+	}
+	for _, a := range transports {
+		for _, b := range transports {
+			if a != b {
+				a.Connect(b.LocalAddr(), b)
+			}
+		}
+	}
+
+	nodes := make([]*raft.Raft, len(ids))
+	for i, id := range ids {
+		cfg := raft.DefaultConfig()
+		cfg.LocalID = id
+		cfg.HeartbeatTimeout = 50 * time.Millisecond
+		cfg.ElectionTimeout = 50 * time.Millisecond
+		cfg.LeaderLeaseTimeout = 50 * time.Millisecond
+		cfg.CommitTimeout = 5 * time.Millisecond
+		cfg.LogOutput = ioutil.Discard
+		store := raft.NewInmemStore()
+		fsm := &FSM{state: make(map[string]interface{}), applyCh: make(chan *ApplyEvent, 10)}
+		r, err := raft.NewRaft(cfg, fsm, store, store, raft.NewInmemSnapshotStore(), transports[i])
+		require.NoError(t, err)
+		defer r.Shutdown()
+		require.NoError(t, r.BootstrapCluster(raft.Configuration{Servers: servers}).Error())
+		nodes[i] = r
+	}
+
+	var leader *raft.Raft
+	var local raft.ServerID
+	require.Eventually(t, func() bool {
+		for i, r := range nodes {
+			if r.State() == raft.Leader {
+				leader, local = r, ids[i]
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	suffrage := func(id raft.ServerID) raft.ServerSuffrage {
+		future := leader.GetConfiguration()
+		require.NoError(t, future.Error())
+		for _, server := range future.Configuration().Servers {
+			if server.ID == id {
+				return server.Suffrage
+			}
+		}
+		t.Fatalf("%s is not a member", id)
+		return 0
+	}
+	var follower raft.Server
+	for _, server := range servers {
+		if server.ID != local {
+			follower = server
+			break
+		}
+	}
+
+	quarantined := map[string]bool{string(follower.Address): true, string(local): true}
+	require.NoError(t, reconcileSuffrage(leader, local, func(id string) bool { return quarantined[id] }))
+	assert.Equal(t, raft.Nonvoter, suffrage(follower.ID), "members are matched by their peer ID")
+	assert.Equal(t, raft.Voter, suffrage(local), "the leader keeps its vote")
+
+	quarantined = map[string]bool{}
+	require.NoError(t, reconcileSuffrage(leader, local, func(id string) bool { return quarantined[id] }))
+	for _, id := range ids {
+		assert.Equal(t, raft.Voter, suffrage(id), "released members get their vote back")
+	}
+}
+
 // TestFSM_Apply tests FSM apply functionality
 func TestFSM_Apply(t *testing.T) {
 	fsm := &FSM{
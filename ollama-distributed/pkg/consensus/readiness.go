@@ -0,0 +1,66 @@
+package consensus
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/raft"
+)
+
+// CatchUp describes how far the local state machine trails the Raft log
+type CatchUp struct {
+	State        string `json:"state"`
+	LeaderID     string `json:"leader_id,omitempty"`
+	LeaderAddr   string `json:"leader_addr,omitempty"`
+	CommitIndex  uint64 `json:"commit_index"`
+	AppliedIndex uint64 `json:"applied_index"`
+	Lag          uint64 `json:"lag"`
+}
+
+// CatchUp returns the local Raft state and how many committed entries have
+// not been applied yet
+func (e *Engine) CatchUp() CatchUp {
+	stats := e.raft.Stats()
+	addr, id := e.raft.LeaderWithID()
+
+	c := CatchUp{
+		State:        stats["state"],
+		LeaderID:     string(id),
+		LeaderAddr:   string(addr),
+		CommitIndex:  parseIndex(stats["commit_index"]),
+		AppliedIndex: parseIndex(stats["applied_index"]),
+	}
+	if c.CommitIndex > c.AppliedIndex {
+		c.Lag = c.CommitIndex - c.AppliedIndex
+	}
+	return c
+}
+
+// Ready returns nil once a leader is known and no more than maxLag committed
+// entries remain to be applied, else the reason the node is not ready
+func (c CatchUp) Ready(maxLag uint64) error {
+	if c.State == raft.Shutdown.String() {
+		return fmt.Errorf("raft is shut down")
+	}
+	if c.LeaderID == "" {
+		return fmt.Errorf("no raft leader known")
+	}
+	if c.Lag > maxLag {
+		return fmt.Errorf("applied index %d trails commit index %d", c.AppliedIndex, c.CommitIndex)
+	}
+	return nil
+}
+
+// TransferLeadership hands leadership to another voter, so that a leader
+// about to stop does not leave the cluster waiting for an election timeout
+func (e *Engine) TransferLeadership() error {
+	if !e.IsLeader() {
+		return fmt.Errorf("not leader, cannot transfer leadership")
+	}
+	return e.raft.LeadershipTransfer().Error()
+}
+
+func parseIndex(s string) uint64 {
+	index, _ := strconv.ParseUint(s, 10, 64)
+	return index
+}
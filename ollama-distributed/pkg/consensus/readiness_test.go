@@ -0,0 +1,19 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatchUpReady(t *testing.T) {
+	caughtUp := CatchUp{State: "Follower", LeaderID: "node-0", CommitIndex: 42, AppliedIndex: 41, Lag: 1}
+	assert.NoError(t, caughtUp.Ready(1))
+	assert.EqualError(t, caughtUp.Ready(0), "applied index 41 trails commit index 42")
+
+	noLeader := CatchUp{State: "Candidate"}
+	assert.EqualError(t, noLeader.Ready(10), "no raft leader known")
+
+	shutdown := CatchUp{State: "Shutdown", LeaderID: "node-0"}
+	assert.EqualError(t, shutdown.Ready(10), "raft is shut down")
+}
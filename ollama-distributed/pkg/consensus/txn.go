@@ -0,0 +1,155 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Compare is a condition on a key a transaction is applied under
+type Compare struct {
+	Key string `json:"key"`
+	// Value is the value the key must hold, compared by its JSON encoding.
+	// With Missing, the key must not exist instead.
+	Value   interface{} `json:"value,omitempty"`
+	Missing bool        `json:"missing,omitempty"`
+}
+
+// Op is a change a transaction makes, of type "set" or "delete"
+type Op struct {
+	Type  string      `json:"type"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Txn changes several keys atomically, if all its comparisons hold
+type Txn struct {
+	Compares []Compare `json:"compares,omitempty"`
+	Ops      []Op      `json:"ops"`
+}
+
+// TxnResult is the outcome of a transaction
+type TxnResult struct {
+	Succeeded bool `json:"succeeded"`
+	// Failed lists the keys whose comparison did not hold
+	Failed []string `json:"failed,omitempty"`
+	// Revision is the revision the transaction was applied at
+	Revision uint64 `json:"revision"`
+}
+
+// Validate checks the transaction has operations of known types on keys
+func (t Txn) Validate() error {
+	if len(t.Ops) == 0 {
+		return fmt.Errorf("transaction has no operations")
+	}
+	for _, c := range t.Compares {
+		if c.Key == "" {
+			return fmt.Errorf("comparison key cannot be empty")
+		}
+	}
+	for _, op := range t.Ops {
+		if op.Key == "" {
+			return fmt.Errorf("operation key cannot be empty")
+		}
+		if op.Type != "set" && op.Type != "delete" {
+			return fmt.Errorf("unknown operation type: %s", op.Type)
+		}
+	}
+	return nil
+}
+
+// Txn applies a transaction through Raft consensus. The comparisons are
+// checked and the operations applied together when the entry is applied,
+// so no other change lands in between, as it can between Get and Apply.
+func (e *Engine) Txn(txn Txn) (TxnResult, error) {
+	if !e.IsLeader() {
+		return TxnResult{}, fmt.Errorf("not leader, cannot apply transaction")
+	}
+	if err := txn.Validate(); err != nil {
+		return TxnResult{}, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	event := &ApplyEvent{
+		Type:      "txn",
+		Txn:       &txn,
+		Timestamp: time.Now(),
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return TxnResult{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	future := e.raft.Apply(data, 10*time.Second)
+	if err := future.Error(); err != nil {
+		return TxnResult{}, fmt.Errorf("failed to apply transaction: %w", err)
+	}
+	switch resp := future.Response().(type) {
+	case TxnResult:
+		return resp, nil
+	case error:
+		return TxnResult{}, fmt.Errorf("failed to apply transaction: %w", resp)
+	default:
+		return TxnResult{}, fmt.Errorf("unexpected transaction response %T", resp)
+	}
+}
+
+// CompareAndSwap sets a key to value if it holds expected, or does not
+// exist when expected is nil, and reports whether it did
+func (e *Engine) CompareAndSwap(key string, expected, value interface{}) (bool, error) {
+	result, err := e.Txn(Txn{
+		Compares: []Compare{{Key: key, Value: expected, Missing: expected == nil}},
+		Ops:      []Op{{Type: "set", Key: key, Value: value}},
+	})
+	return result.Succeeded, err
+}
+
+// applyTxn checks the comparisons of a transaction and, if they all hold,
+// applies its operations, returning the events of the changes made;
+// f.stateMu must be held
+func (f *FSM) applyTxn(txn *Txn, timestamp time.Time) (TxnResult, []ApplyEvent) {
+	var result TxnResult
+	for _, c := range txn.Compares {
+		if !f.holds(c) {
+			result.Failed = append(result.Failed, c.Key)
+		}
+	}
+	if len(result.Failed) > 0 {
+		return result, nil
+	}
+
+	applied := make([]ApplyEvent, 0, len(txn.Ops))
+	for _, op := range txn.Ops {
+		switch op.Type {
+		case "set":
+			f.state[op.Key] = op.Value
+		case "delete":
+			delete(f.state, op.Key)
+		}
+		applied = append(applied, ApplyEvent{Type: op.Type, Key: op.Key, Value: op.Value, Timestamp: timestamp})
+	}
+	result.Succeeded = true
+	return result, applied
+}
+
+// holds reports whether a comparison holds; f.stateMu must be held
+func (f *FSM) holds(c Compare) bool {
+	current, exists := f.state[c.Key]
+	if c.Missing {
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+	// Values are compared by their encoding, as values read back from the
+	// log are decoded from JSON
+	want, err := json.Marshal(c.Value)
+	if err != nil {
+		return false
+	}
+	got, err := json.Marshal(current)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(want, got)
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func applyTxn(t *testing.T, fsm *FSM, index uint64, txn Txn) TxnResult {
+	data, err := json.Marshal(&ApplyEvent{Type: "txn", Txn: &txn, Timestamp: time.Now()})
+	require.NoError(t, err)
+	result, ok := fsm.Apply(&raft.Log{Index: index, Data: data}).(TxnResult)
+	require.True(t, ok)
+	return result
+}
+
+func TestFSM_Txn(t *testing.T) {
+	fsm := &FSM{
+		state:   make(map[string]interface{}),
+		applyCh: make(chan *ApplyEvent, 10),
+		watches: watch.NewHub(0, 0),
+	}
+	replicas := []string{"node-1", "node-2"}
+
+	// Creating keys that must not exist yet
+	result := applyTxn(t, fsm, 1, Txn{
+		Compares: []Compare{{Key: "placement_version", Missing: true}},
+		Ops: []Op{
+			{Type: "set", Key: "placement_version", Value: 1},
+			{Type: "set", Key: "replicas/llama", Value: replicas},
+		},
+	})
+	assert.Equal(t, TxnResult{Succeeded: true, Revision: 1}, result)
+
+	// Values read back from the log compare equal to the values written
+	changes, err := fsm.watches.Watch(t.Context(), "replicas/", 0)
+	require.NoError(t, err)
+	result = applyTxn(t, fsm, 2, Txn{
+		Compares: []Compare{{Key: "placement_version", Value: 1}, {Key: "replicas/llama", Value: replicas}},
+		Ops: []Op{
+			{Type: "set", Key: "placement_version", Value: 2},
+			{Type: "set", Key: "replicas/llama", Value: []string{"node-3"}},
+			{Type: "delete", Key: "inventory/node-1"},
+		},
+	})
+	assert.True(t, result.Succeeded)
+	assert.Equal(t, watch.Event{Revision: 2, Type: "set", Key: "replicas/llama", Value: []interface{}{"node-3"}}, stripTime(<-changes))
+
+	// A stale version changes nothing
+	result = applyTxn(t, fsm, 3, Txn{
+		Compares: []Compare{{Key: "placement_version", Value: 1}, {Key: "inventory/node-1", Missing: true}},
+		Ops:      []Op{{Type: "set", Key: "replicas/llama", Value: replicas}},
+	})
+	assert.Equal(t, TxnResult{Failed: []string{"placement_version"}, Revision: 3}, result)
+	assert.Equal(t, []interface{}{"node-3"}, fsm.state["replicas/llama"])
+	assert.Equal(t, uint64(2), fsm.watches.Revision(), "a failed transaction is no change")
+
+	assert.Error(t, fsm.Apply(&raft.Log{Index: 4, Data: mustMarshal(t, &ApplyEvent{Type: "txn", Txn: &Txn{}, Timestamp: time.Now()})}).(error))
+}
+
+func stripTime(event watch.Event) watch.Event {
+	event.Timestamp = time.Time{}
+	return event
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
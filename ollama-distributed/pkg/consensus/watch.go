@@ -0,0 +1,37 @@
+package consensus
+
+import (
+	"context"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus/watch"
+)
+
+// Watch streams the changes of keys starting with prefix as they are
+// applied, so that components react to them instead of polling Get. With a
+// revision, e.g. from List or the last event received, the changes after it
+// are replayed first, or watch.ErrCompacted is returned when they are no
+// longer retained.
+func (e *Engine) Watch(ctx context.Context, prefix string, fromRevision uint64) (<-chan watch.Event, error) {
+	return e.fsm.watches.Watch(ctx, prefix, fromRevision)
+}
+
+// Revision returns the revision of the last applied change
+func (e *Engine) Revision() uint64 {
+	return e.fsm.watches.Revision()
+}
+
+// List returns the values of the keys starting with prefix and the revision
+// they were read at, to watch for their changes from
+func (e *Engine) List(prefix string) (map[string]interface{}, uint64) {
+	e.fsm.stateMu.RLock()
+	defer e.fsm.stateMu.RUnlock()
+
+	values := make(map[string]interface{})
+	for key, value := range e.fsm.state {
+		if strings.HasPrefix(key, prefix) {
+			values[key] = value
+		}
+	}
+	return values, e.fsm.watches.Revision()
+}
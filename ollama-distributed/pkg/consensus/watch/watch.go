@@ -0,0 +1,196 @@
+// Package watch streams changes of the replicated key-value state to
+// components that react to them, instead of polling it. Each change carries
+// the revision it was applied at, the index of its Raft log entry, so that a
+// watcher resumes after the last change it saw.
+package watch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a hub
+const (
+	DefaultHistory = 1024
+	DefaultBuffer  = 64
+)
+
+var (
+	// ErrCompacted is returned when resuming from a revision older than the
+	// retained history. The watcher reads the state again and watches from
+	// its revision.
+	ErrCompacted = errors.New("watch revision compacted")
+	// ErrClosed is returned when watching a closed hub
+	ErrClosed = errors.New("watch hub closed")
+)
+
+// Event is a change of a key
+type Event struct {
+	Revision  uint64      `json:"revision"`
+	Type      string      `json:"type"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type watcher struct {
+	prefix string
+	ch     chan Event
+	done   chan struct{}
+}
+
+// Hub fans the changes out to the watchers of their keys, keeping a bounded
+// history of recent changes to resume from
+type Hub struct {
+	historySize int
+	buffer      int
+
+	mu       sync.Mutex
+	revision uint64
+	// compacted is the revision up to which changes are no longer in the
+	// history
+	compacted uint64
+	// restored is whether the state was replaced since the last change, so
+	// that the changes before the next one are unknown
+	restored bool
+	history  []Event
+	watchers map[*watcher]struct{}
+	closed   bool
+}
+
+// NewHub creates a hub keeping the last historySize changes, buffering up to
+// buffer changes for each watcher. Zero values select the defaults.
+func NewHub(historySize, buffer int) *Hub {
+	if historySize <= 0 {
+		historySize = DefaultHistory
+	}
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{
+		historySize: historySize,
+		buffer:      buffer,
+		watchers:    make(map[*watcher]struct{}),
+	}
+}
+
+// Revision returns the revision of the last change
+func (h *Hub) Revision() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.revision
+}
+
+// Publish records a change and sends it to the watchers of its key.
+// Revisions must not decrease; the changes of a transaction share one.
+// Watchers too far behind to take the change are
+// closed rather than blocking the caller, which applies the state.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || event.Revision < h.revision && !h.restored {
+		return
+	}
+	if h.restored {
+		h.compacted = event.Revision - 1
+		h.restored = false
+	}
+	h.revision = event.Revision
+
+	h.history = append(h.history, event)
+	if len(h.history) > h.historySize {
+		h.compacted = h.history[0].Revision
+		h.history = h.history[1:]
+	}
+
+	for w := range h.watchers {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- event:
+		default:
+			h.remove(w)
+		}
+	}
+}
+
+// Reset drops the history and closes the watchers after the state was
+// replaced, e.g. restored from a snapshot. Resuming from an earlier revision
+// fails until the next change.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history = nil
+	h.restored = true
+	for w := range h.watchers {
+		h.remove(w)
+	}
+}
+
+// Watch streams the changes of keys starting with prefix, all keys if
+// empty, until the context is done. With a revision, the retained changes
+// after it are sent first; without, only changes from now on. The channel
+// is also closed when the watcher falls behind or the hub is closed; the
+// watcher then watches again from the revision of the last change received.
+func (h *Hub) Watch(ctx context.Context, prefix string, fromRevision uint64) (<-chan Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+	var replay []Event
+	if fromRevision > 0 {
+		if h.restored || fromRevision < h.compacted {
+			return nil, ErrCompacted
+		}
+		for _, event := range h.history {
+			if event.Revision > fromRevision && strings.HasPrefix(event.Key, prefix) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	w := &watcher{prefix: prefix, ch: make(chan Event, len(replay)+h.buffer), done: make(chan struct{})}
+	for _, event := range replay {
+		w.ch <- event
+	}
+	h.watchers[w] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			h.remove(w)
+			h.mu.Unlock()
+		case <-w.done:
+		}
+	}()
+	return w.ch, nil
+}
+
+// Close closes the watchers and refuses new ones
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for w := range h.watchers {
+		h.remove(w)
+	}
+}
+
+// remove closes a watcher once; h.mu must be held
+func (h *Hub) remove(w *watcher) {
+	if _, ok := h.watchers[w]; ok {
+		delete(h.watchers, w)
+		close(w.ch)
+		close(w.done)
+	}
+}
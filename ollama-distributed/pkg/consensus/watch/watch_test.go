@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func publish(h *Hub, revision uint64, key string) {
+	h.Publish(Event{Revision: revision, Type: "set", Key: key, Value: revision})
+}
+
+func drain(ch <-chan Event) []uint64 {
+	var revisions []uint64
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return revisions
+			}
+			revisions = append(revisions, event.Revision)
+		default:
+			return revisions
+		}
+	}
+}
+
+func TestWatchFiltersByPrefix(t *testing.T) {
+	h := NewHub(0, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	flags, err := h.Watch(ctx, "feature_", 0)
+	require.NoError(t, err)
+
+	publish(h, 1, "feature_flags")
+	publish(h, 2, "join_tokens")
+	publish(h, 3, "feature_flags")
+	publish(h, 3, "feature_overrides")
+	assert.Equal(t, []uint64{1, 3, 3}, drain(flags), "changes of one transaction share a revision")
+	assert.Equal(t, uint64(3), h.Revision())
+
+	cancel()
+	_, open := <-flags
+	assert.False(t, open, "the watch ends with its context")
+}
+
+func TestWatchResumesFromRevision(t *testing.T) {
+	h := NewHub(3, 0)
+	for rev := uint64(1); rev <= 5; rev++ {
+		publish(h, rev, "k")
+	}
+
+	resumed, err := h.Watch(context.Background(), "", 3)
+	require.NoError(t, err)
+	publish(h, 6, "k")
+	assert.Equal(t, []uint64{4, 5, 6}, drain(resumed))
+
+	_, err = h.Watch(context.Background(), "", 1)
+	assert.ErrorIs(t, err, ErrCompacted, "revision 2 is no longer retained")
+
+	h.Reset()
+	_, open := <-resumed
+	assert.False(t, open, "watchers end when the state is replaced")
+	_, err = h.Watch(context.Background(), "", 6)
+	assert.ErrorIs(t, err, ErrCompacted)
+	publish(h, 10, "k")
+	_, err = h.Watch(context.Background(), "", 6)
+	assert.ErrorIs(t, err, ErrCompacted, "revisions 7 to 9 were skipped by the restore")
+	_, err = h.Watch(context.Background(), "", 9)
+	assert.NoError(t, err)
+
+	h.Close()
+	_, err = h.Watch(context.Background(), "", 0)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestSlowWatchersAreClosed(t *testing.T) {
+	h := NewHub(0, 2)
+	slow, err := h.Watch(context.Background(), "", 0)
+	require.NoError(t, err)
+
+	for rev := uint64(1); rev <= 3; rev++ {
+		publish(h, rev, "k")
+	}
+	assert.Equal(t, []uint64{1, 2}, drain(slow), "closed instead of blocking the third change")
+
+	resumed, err := h.Watch(context.Background(), "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, drain(resumed))
+}
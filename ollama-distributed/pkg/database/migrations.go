@@ -247,6 +247,107 @@ func GetMigrations() []Migration {
 				DROP TABLE IF EXISTS schema_migrations;
 			`,
 		},
+		{
+			Version:     3,
+			Description: "Add inference captures table",
+			Up: `
+				-- Request/response pairs captured for debugging and replay
+				CREATE TABLE inference_captures (
+					id UUID PRIMARY KEY,
+					captured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					method VARCHAR(10) NOT NULL,
+					path TEXT NOT NULL,
+					query TEXT,
+					headers JSONB DEFAULT '{}',
+					tenant_id VARCHAR(255),
+					user_id VARCHAR(255),
+					model VARCHAR(255),
+					request_body TEXT,
+					status INTEGER,
+					response_body TEXT,
+					latency_ms INTEGER,
+					truncated BOOLEAN DEFAULT false
+				);
+
+				CREATE INDEX idx_inference_captures_captured_at ON inference_captures(captured_at DESC);
+				CREATE INDEX idx_inference_captures_tenant_model ON inference_captures(tenant_id, model);
+			`,
+			Down: `
+				DROP TABLE IF EXISTS inference_captures;
+			`,
+		},
+		{
+			Version:     4,
+			Description: "Add cluster events table",
+			Up: `
+				-- Cluster state transitions, queryable by time and type
+				CREATE TABLE cluster_events (
+					id UUID PRIMARY KEY,
+					occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					type VARCHAR(64) NOT NULL,
+					subject TEXT,
+					node_id VARCHAR(255) NOT NULL,
+					sequence BIGINT NOT NULL,
+					term BIGINT DEFAULT 0,
+					caused_by UUID,
+					correlation_id VARCHAR(255),
+					actor VARCHAR(255),
+					request_id VARCHAR(255),
+					data JSONB DEFAULT '{}'
+				);
+
+				CREATE INDEX idx_cluster_events_occurred_at ON cluster_events(occurred_at DESC);
+				CREATE INDEX idx_cluster_events_type ON cluster_events(type, occurred_at DESC);
+			`,
+			Down: `
+				DROP TABLE IF EXISTS cluster_events;
+			`,
+		},
+		{
+			Version:     5,
+			Description: "Add metric points table",
+			Up: `
+				-- Time-series values downsampled into buckets per resolution
+				CREATE TABLE metric_points (
+					resolution_seconds INTEGER NOT NULL,
+					metric VARCHAR(128) NOT NULL,
+					key VARCHAR(255) NOT NULL DEFAULT '',
+					bucket TIMESTAMP WITH TIME ZONE NOT NULL,
+					mean DOUBLE PRECISION NOT NULL,
+					min DOUBLE PRECISION NOT NULL,
+					max DOUBLE PRECISION NOT NULL,
+					count BIGINT NOT NULL,
+					PRIMARY KEY (resolution_seconds, metric, key, bucket)
+				);
+
+				CREATE INDEX idx_metric_points_bucket ON metric_points(resolution_seconds, bucket);
+
+				-- Partition by time when TimescaleDB is installed
+				DO $$
+				BEGIN
+					IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+						PERFORM create_hypertable('metric_points', 'bucket', if_not_exists => TRUE);
+					END IF;
+				END
+				$$;
+			`,
+			Down: `
+				DROP TABLE IF EXISTS metric_points;
+			`,
+		},
+		{
+			Version:     6,
+			Description: "Add user roles",
+			Up: `
+				-- Local accounts hold any number of RBAC roles and need no email
+				ALTER TABLE users ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{}';
+				UPDATE users SET roles = ARRAY[role];
+				ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+			`,
+			Down: `
+				ALTER TABLE users DROP COLUMN IF EXISTS roles;
+			`,
+		},
 	}
 }
 
@@ -13,6 +13,7 @@ type User struct {
 	FullName            string                 `json:"full_name" db:"full_name"`
 	AvatarURL           string                 `json:"avatar_url" db:"avatar_url"`
 	Role                string                 `json:"role" db:"role"`
+	Roles               []string               `json:"roles" db:"roles"`
 	IsActive            bool                   `json:"is_active" db:"is_active"`
 	IsVerified          bool                   `json:"is_verified" db:"is_verified"`
 	LastLogin           *time.Time             `json:"last_login" db:"last_login"`
@@ -104,6 +105,41 @@ type InferenceResult struct {
 	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
 }
 
+// InferenceCapture is an inference request and its response recorded for
+// debugging and replay
+type InferenceCapture struct {
+	ID           string            `json:"id" db:"id"`
+	CapturedAt   time.Time         `json:"captured_at" db:"captured_at"`
+	Method       string            `json:"method" db:"method"`
+	Path         string            `json:"path" db:"path"`
+	Query        string            `json:"query" db:"query"`
+	Headers      map[string]string `json:"headers" db:"headers"`
+	TenantID     string            `json:"tenant_id" db:"tenant_id"`
+	UserID       string            `json:"user_id" db:"user_id"`
+	Model        string            `json:"model" db:"model"`
+	RequestBody  string            `json:"request_body" db:"request_body"`
+	Status       int               `json:"status" db:"status"`
+	ResponseBody string            `json:"response_body" db:"response_body"`
+	LatencyMs    int               `json:"latency_ms" db:"latency_ms"`
+	Truncated    bool              `json:"truncated" db:"truncated"`
+}
+
+// ClusterEvent is a recorded transition of the cluster state
+type ClusterEvent struct {
+	ID            string                 `json:"id" db:"id"`
+	OccurredAt    time.Time              `json:"occurred_at" db:"occurred_at"`
+	Type          string                 `json:"type" db:"type"`
+	Subject       string                 `json:"subject" db:"subject"`
+	NodeID        string                 `json:"node_id" db:"node_id"`
+	Sequence      int64                  `json:"sequence" db:"sequence"`
+	Term          uint64                 `json:"term" db:"term"`
+	CausedBy      string                 `json:"caused_by" db:"caused_by"`
+	CorrelationID string                 `json:"correlation_id" db:"correlation_id"`
+	Actor         string                 `json:"actor" db:"actor"`
+	RequestID     string                 `json:"request_id" db:"request_id"`
+	Data          map[string]interface{} `json:"data" db:"data"`
+}
+
 // APIKey represents an API key for authentication
 type APIKey struct {
 	ID          string                 `json:"id" db:"id"`
@@ -144,3 +180,16 @@ type AuditLog struct {
 	Success   bool                   `json:"success" db:"success"`
 	CreatedAt time.Time              `json:"created_at" db:"created_at"`
 }
+
+// MetricPoint is a time-series value aggregated over the bucket of a
+// resolution starting at Bucket
+type MetricPoint struct {
+	Resolution time.Duration `json:"resolution" db:"resolution_seconds"`
+	Metric     string        `json:"metric" db:"metric"`
+	Key        string        `json:"key" db:"key"`
+	Bucket     time.Time     `json:"bucket" db:"bucket"`
+	Mean       float64       `json:"mean" db:"mean"`
+	Min        float64       `json:"min" db:"min"`
+	Max        float64       `json:"max" db:"max"`
+	Count      int64         `json:"count" db:"count"`
+}
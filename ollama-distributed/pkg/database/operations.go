@@ -4,16 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // User operations
 
-// CreateUser creates a new user
+// ErrUserNotFound is returned for a user that does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when creating a user whose username or email is
+// taken
+var ErrUserExists = errors.New("user already exists")
+
+// userColumns are the columns scanUser reads, in order
+const userColumns = `id, username, COALESCE(email, ''), password_hash, COALESCE(full_name, ''), COALESCE(avatar_url, ''),
+		       role, roles, is_active, is_verified, last_login, failed_login_attempts, locked_until, metadata,
+		       created_at, updated_at`
+
+// CreateUser creates a new user. An empty email is stored as NULL, so that
+// any number of users may have none.
 func (m *Manager) CreateUser(ctx context.Context, user *User) (*User, error) {
 	if user.ID == "" {
 		user.ID = uuid.New().String()
@@ -24,17 +38,20 @@ func (m *Manager) CreateUser(ctx context.Context, user *User) (*User, error) {
 	metadataJSON, _ := json.Marshal(user.Metadata)
 
 	query := `
-		INSERT INTO users (id, username, email, password_hash, full_name, avatar_url, role, is_active, is_verified, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO users (id, username, email, password_hash, full_name, avatar_url, role, roles, is_active, is_verified, metadata, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id, created_at, updated_at`
 
 	err := m.db.QueryRowContext(ctx, query,
 		user.ID, user.Username, user.Email, user.PasswordHash, user.FullName,
-		user.AvatarURL, user.Role, user.IsActive, user.IsVerified,
+		user.AvatarURL, user.Role, pq.Array(user.Roles), user.IsActive, user.IsVerified,
 		metadataJSON, user.CreatedAt, user.UpdatedAt,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrUserExists
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -43,57 +60,109 @@ func (m *Manager) CreateUser(ctx context.Context, user *User) (*User, error) {
 
 // GetUser retrieves a user by ID
 func (m *Manager) GetUser(ctx context.Context, id string) (*User, error) {
-	user := &User{}
-	var metadataJSON []byte
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	query := `
-		SELECT id, username, email, password_hash, full_name, avatar_url, role, is_active, is_verified,
-		       last_login, failed_login_attempts, locked_until, metadata, created_at, updated_at
-		FROM users WHERE id = $1`
+	user, err := scanUser(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
 
-	err := m.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName,
-		&user.AvatarURL, &user.Role, &user.IsActive, &user.IsVerified,
-		&user.LastLogin, &user.FailedLoginAttempts, &user.LockedUntil,
-		&metadataJSON, &user.CreatedAt, &user.UpdatedAt,
-	)
+	return user, nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (m *Manager) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
 
+	user, err := scanUser(m.db.QueryRowContext(ctx, query, username))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if len(metadataJSON) > 0 {
-		json.Unmarshal(metadataJSON, &user.Metadata)
+	return user, nil
+}
+
+// ListUsers retrieves all users ordered by username
+func (m *Manager) ListUsers(ctx context.Context) ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY username`
+
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
+	defer rows.Close()
 
-	return user, nil
+	var users []*User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
-// GetUserByUsername retrieves a user by username
-func (m *Manager) GetUserByUsername(ctx context.Context, username string) (*User, error) {
-	user := &User{}
-	var metadataJSON []byte
+// UpdateUser stores the email, password hash, roles, active flag and last
+// login of a user
+func (m *Manager) UpdateUser(ctx context.Context, user *User) error {
+	user.UpdatedAt = time.Now()
 
 	query := `
-		SELECT id, username, email, password_hash, full_name, avatar_url, role, is_active, is_verified,
-		       last_login, failed_login_attempts, locked_until, metadata, created_at, updated_at
-		FROM users WHERE username = $1`
+		UPDATE users SET email = NULLIF($2, ''), password_hash = $3, role = $4, roles = $5, is_active = $6,
+		                 last_login = $7, updated_at = $8
+		WHERE id = $1`
 
-	err := m.db.QueryRowContext(ctx, query, username).Scan(
+	result, err := m.db.ExecContext(ctx, query,
+		user.ID, user.Email, user.PasswordHash, user.Role, pq.Array(user.Roles), user.IsActive,
+		user.LastLogin, user.UpdatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrUserExists
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user by ID
+func (m *Manager) DeleteUser(ctx context.Context, id string) error {
+	result, err := m.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// scanUser scans a row of userColumns
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	user := &User{}
+	var metadataJSON []byte
+
+	err := row.Scan(
 		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName,
-		&user.AvatarURL, &user.Role, &user.IsActive, &user.IsVerified,
+		&user.AvatarURL, &user.Role, pq.Array(&user.Roles), &user.IsActive, &user.IsVerified,
 		&user.LastLogin, &user.FailedLoginAttempts, &user.LockedUntil,
 		&metadataJSON, &user.CreatedAt, &user.UpdatedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
 
 	if len(metadataJSON) > 0 {
@@ -103,6 +172,13 @@ func (m *Manager) GetUserByUsername(ctx context.Context, username string) (*User
 	return user, nil
 }
 
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint
+// violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 // Model operations
 
 // CreateModel creates a new model
@@ -237,6 +313,277 @@ func (m *Manager) CreateInferenceRequest(ctx context.Context, req *InferenceRequ
 	return req, nil
 }
 
+// ErrCaptureNotFound is returned for an inference capture that does not exist
+var ErrCaptureNotFound = errors.New("inference capture not found")
+
+// CreateInferenceCapture stores a captured inference request
+func (m *Manager) CreateInferenceCapture(ctx context.Context, capture *InferenceCapture) error {
+	if capture.ID == "" {
+		capture.ID = uuid.New().String()
+	}
+	if capture.CapturedAt.IsZero() {
+		capture.CapturedAt = time.Now()
+	}
+
+	headersJSON, _ := json.Marshal(capture.Headers)
+
+	query := `
+		INSERT INTO inference_captures (id, captured_at, method, path, query, headers, tenant_id, user_id, model,
+		                                request_body, status, response_body, latency_ms, truncated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+	_, err := m.db.ExecContext(ctx, query,
+		capture.ID, capture.CapturedAt, capture.Method, capture.Path, capture.Query, headersJSON,
+		capture.TenantID, capture.UserID, capture.Model, capture.RequestBody, capture.Status,
+		capture.ResponseBody, capture.LatencyMs, capture.Truncated,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create inference capture: %w", err)
+	}
+
+	return nil
+}
+
+// GetInferenceCapture retrieves an inference capture by ID
+func (m *Manager) GetInferenceCapture(ctx context.Context, id string) (*InferenceCapture, error) {
+	query := `
+		SELECT id, captured_at, method, path, query, headers, tenant_id, user_id, model,
+		       request_body, status, response_body, latency_ms, truncated
+		FROM inference_captures WHERE id = $1`
+
+	capture, err := scanInferenceCapture(m.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCaptureNotFound
+		}
+		return nil, fmt.Errorf("failed to get inference capture: %w", err)
+	}
+
+	return capture, nil
+}
+
+// ListInferenceCaptures lists the inference captures of a tenant and model
+// taken since a time, newest first. Empty filters match everything.
+func (m *Manager) ListInferenceCaptures(ctx context.Context, tenantID, model string, since time.Time, limit int) ([]*InferenceCapture, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, captured_at, method, path, query, headers, tenant_id, user_id, model,
+		       request_body, status, response_body, latency_ms, truncated
+		FROM inference_captures
+		WHERE ($1 = '' OR tenant_id = $1) AND ($2 = '' OR model = $2) AND captured_at >= $3
+		ORDER BY captured_at DESC
+		LIMIT $4`
+
+	rows, err := m.db.QueryContext(ctx, query, tenantID, model, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inference captures: %w", err)
+	}
+	defer rows.Close()
+
+	var captures []*InferenceCapture
+	for rows.Next() {
+		capture, err := scanInferenceCapture(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inference capture: %w", err)
+		}
+		captures = append(captures, capture)
+	}
+
+	return captures, rows.Err()
+}
+
+// scanInferenceCapture scans a row of inference_captures
+func scanInferenceCapture(row interface{ Scan(...interface{}) error }) (*InferenceCapture, error) {
+	capture := &InferenceCapture{}
+	var headersJSON []byte
+	var query, tenantID, userID, model, requestBody, responseBody sql.NullString
+	var status, latencyMs sql.NullInt64
+
+	err := row.Scan(
+		&capture.ID, &capture.CapturedAt, &capture.Method, &capture.Path, &query, &headersJSON,
+		&tenantID, &userID, &model, &requestBody, &status, &responseBody, &latencyMs, &capture.Truncated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	capture.Query = query.String
+	capture.TenantID = tenantID.String
+	capture.UserID = userID.String
+	capture.Model = model.String
+	capture.RequestBody = requestBody.String
+	capture.Status = int(status.Int64)
+	capture.ResponseBody = responseBody.String
+	capture.LatencyMs = int(latencyMs.Int64)
+	if len(headersJSON) > 0 {
+		json.Unmarshal(headersJSON, &capture.Headers)
+	}
+
+	return capture, nil
+}
+
+// CreateClusterEvent stores a cluster event
+func (m *Manager) CreateClusterEvent(ctx context.Context, event *ClusterEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	dataJSON, _ := json.Marshal(event.Data)
+	var causedBy sql.NullString
+	if event.CausedBy != "" {
+		causedBy = sql.NullString{String: event.CausedBy, Valid: true}
+	}
+
+	query := `
+		INSERT INTO cluster_events (id, occurred_at, type, subject, node_id, sequence, term, caused_by,
+		                            correlation_id, actor, request_id, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := m.db.ExecContext(ctx, query,
+		event.ID, event.OccurredAt, event.Type, event.Subject, event.NodeID, event.Sequence, int64(event.Term),
+		causedBy, event.CorrelationID, event.Actor, event.RequestID, dataJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster event: %w", err)
+	}
+
+	return nil
+}
+
+// ListClusterEvents lists the cluster events of the given types that
+// occurred in [since, until), newest first. Empty types and a zero until
+// match everything.
+func (m *Manager) ListClusterEvents(ctx context.Context, types []string, since, until time.Time, limit int) ([]*ClusterEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if until.IsZero() {
+		until = time.Now().Add(24 * time.Hour)
+	}
+	typesJSON, _ := json.Marshal(types)
+	if types == nil {
+		typesJSON = []byte("[]")
+	}
+
+	query := `
+		SELECT id, occurred_at, type, subject, node_id, sequence, term, caused_by,
+		       correlation_id, actor, request_id, data
+		FROM cluster_events
+		WHERE (jsonb_array_length($1::jsonb) = 0 OR $1::jsonb ? type)
+		  AND occurred_at >= $2 AND occurred_at < $3
+		ORDER BY occurred_at DESC, sequence DESC
+		LIMIT $4`
+
+	rows, err := m.db.QueryContext(ctx, query, string(typesJSON), since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*ClusterEvent
+	for rows.Next() {
+		event := &ClusterEvent{}
+		var dataJSON []byte
+		var subject, causedBy, correlationID, actor, requestID sql.NullString
+		var term int64
+		err := rows.Scan(&event.ID, &event.OccurredAt, &event.Type, &subject, &event.NodeID, &event.Sequence,
+			&term, &causedBy, &correlationID, &actor, &requestID, &dataJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cluster event: %w", err)
+		}
+		event.Subject = subject.String
+		event.Term = uint64(term)
+		event.CausedBy = causedBy.String
+		event.CorrelationID = correlationID.String
+		event.Actor = actor.String
+		event.RequestID = requestID.String
+		if len(dataJSON) > 0 {
+			json.Unmarshal(dataJSON, &event.Data)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// WriteMetricPoints stores time-series points, replacing those of the same
+// resolution, metric, key and bucket
+func (m *Manager) WriteMetricPoints(ctx context.Context, points []*MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO metric_points (resolution_seconds, metric, key, bucket, mean, min, max, count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (resolution_seconds, metric, key, bucket)
+		DO UPDATE SET mean = EXCLUDED.mean, min = EXCLUDED.min, max = EXCLUDED.max, count = EXCLUDED.count`
+
+	return m.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare metric points: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, point := range points {
+			_, err := stmt.ExecContext(ctx, int64(point.Resolution/time.Second), point.Metric, point.Key,
+				point.Bucket, point.Mean, point.Min, point.Max, point.Count)
+			if err != nil {
+				return fmt.Errorf("failed to write metric point: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListMetricPoints lists the points of a metric at a resolution whose
+// buckets start in [since, until), oldest first. An empty key matches every
+// key.
+func (m *Manager) ListMetricPoints(ctx context.Context, resolution time.Duration, metric, key string, since, until time.Time) ([]*MetricPoint, error) {
+	query := `
+		SELECT metric, key, bucket, mean, min, max, count
+		FROM metric_points
+		WHERE resolution_seconds = $1 AND metric = $2 AND ($3 = '' OR key = $3)
+		  AND bucket >= $4 AND bucket < $5
+		ORDER BY key, bucket`
+
+	rows, err := m.db.QueryContext(ctx, query, int64(resolution/time.Second), metric, key, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metric points: %w", err)
+	}
+	defer rows.Close()
+
+	var points []*MetricPoint
+	for rows.Next() {
+		point := &MetricPoint{Resolution: resolution}
+		if err := rows.Scan(&point.Metric, &point.Key, &point.Bucket, &point.Mean, &point.Min, &point.Max, &point.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan metric point: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// DeleteMetricPoints deletes the points of a resolution whose buckets
+// start before a time, returning how many were deleted
+func (m *Manager) DeleteMetricPoints(ctx context.Context, resolution time.Duration, before time.Time) (int64, error) {
+	result, err := m.db.ExecContext(ctx,
+		`DELETE FROM metric_points WHERE resolution_seconds = $1 AND bucket < $2`,
+		int64(resolution/time.Second), before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete metric points: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 // Node operations
 
 // CreateNode creates a new node
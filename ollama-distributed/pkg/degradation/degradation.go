@@ -0,0 +1,229 @@
+// Package degradation keeps the cluster answering under overload by
+// serving smaller models in place of the ones requested. Each model may
+// have a fallback chain, e.g. llama3:70b → llama3:8b → llama3:3b; while the
+// requested model has no capacity left, or one of its SLOs burns its error
+// budget fast, requests go to the first model of the chain that has
+// capacity. Responses say when a fallback was used, and fallback rates are
+// tracked per model.
+package degradation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Reasons for falling back
+const (
+	ReasonCapacity = "capacity_exhausted"
+	ReasonSLOBurn  = "slo_burn"
+)
+
+// Defaults of the degradation mode
+const (
+	DefaultQueueThreshold = 0.9
+	DefaultUsageThreshold = 90
+)
+
+// Config configures the degradation mode
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Chains maps a model to the models it falls back to, in order of
+	// preference
+	Chains map[string][]string `yaml:"chains"`
+	// QueueThreshold is the fraction of the request queue in use from
+	// which every model counts as out of capacity
+	QueueThreshold float64 `yaml:"queue_threshold" mapstructure:"queue_threshold"`
+	// UsageThreshold is the CPU, GPU or memory usage percentage from which
+	// a node has no capacity left
+	UsageThreshold float64 `yaml:"usage_threshold" mapstructure:"usage_threshold"`
+	// OnSLOBurn also falls back while an SLO of the model burns its error
+	// budget fast
+	OnSLOBurn bool `yaml:"on_slo_burn" mapstructure:"on_slo_burn"`
+}
+
+// Validate checks the chains and thresholds
+func (c Config) Validate() error {
+	if c.QueueThreshold < 0 || c.QueueThreshold > 1 {
+		return fmt.Errorf("queue threshold must be between 0 and 1")
+	}
+	if c.UsageThreshold < 0 || c.UsageThreshold > 100 {
+		return fmt.Errorf("usage threshold must be between 0 and 100")
+	}
+	for model, chain := range c.Chains {
+		if model == "" || len(chain) == 0 {
+			return fmt.Errorf("fallback chain of %q is empty", model)
+		}
+		seen := map[string]bool{model: true}
+		for _, fallback := range chain {
+			if fallback == "" || seen[fallback] {
+				return fmt.Errorf("fallback chain of %s repeats or names an empty model", model)
+			}
+			seen[fallback] = true
+		}
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset thresholds defaulted
+func (c Config) withDefaults() Config {
+	if c.QueueThreshold == 0 {
+		c.QueueThreshold = DefaultQueueThreshold
+	}
+	if c.UsageThreshold == 0 {
+		c.UsageThreshold = DefaultUsageThreshold
+	}
+	return c
+}
+
+// Decision is the model a request is served by
+type Decision struct {
+	Requested string `json:"requested"`
+	Model     string `json:"model"`
+	// Reason is why the request falls back, empty when it does not
+	Reason string `json:"reason,omitempty"`
+}
+
+// Fallback reports whether the request is served by a fallback model
+func (d Decision) Fallback() bool {
+	return d.Model != d.Requested
+}
+
+// Stats counts the requests for a model with a fallback chain
+type Stats struct {
+	Model    string `json:"model"`
+	Requests int64  `json:"requests"`
+	// Fallbacks counts the requests served by each fallback model
+	Fallbacks map[string]int64 `json:"fallbacks"`
+	// Reasons counts the requests that fell back by reason
+	Reasons map[string]int64 `json:"reasons"`
+	// Exhausted counts the requests served by the requested model as no
+	// fallback had capacity either
+	Exhausted    int64   `json:"exhausted"`
+	FallbackRate float64 `json:"fallback_rate"`
+}
+
+// Degrader picks the model requests are served by
+type Degrader struct {
+	cfg Config
+
+	mu       sync.Mutex
+	capacity func(model string) bool
+	burning  func(model string) bool
+	observer func(model, fallback, reason string)
+	stats    map[string]*Stats
+}
+
+// NewDegrader creates a degrader. Until capacity is set every model counts
+// as having capacity.
+func NewDegrader(cfg Config) *Degrader {
+	d := &Degrader{cfg: cfg.withDefaults(), stats: make(map[string]*Stats)}
+	for model := range cfg.Chains {
+		d.stats[model] = &Stats{Model: model, Fallbacks: map[string]int64{}, Reasons: map[string]int64{}}
+	}
+	return d
+}
+
+// SetCapacity sets how to tell whether a model can take another request
+func (d *Degrader) SetCapacity(capacity func(model string) bool) {
+	d.mu.Lock()
+	d.capacity = capacity
+	d.mu.Unlock()
+}
+
+// SetBurning sets how to tell whether an SLO of a model burns its error
+// budget fast; it is only consulted with OnSLOBurn
+func (d *Degrader) SetBurning(burning func(model string) bool) {
+	d.mu.Lock()
+	d.burning = burning
+	d.mu.Unlock()
+}
+
+// SetObserver sets a function called with every fallback, e.g. to count it
+// in metrics
+func (d *Degrader) SetObserver(observer func(model, fallback, reason string)) {
+	d.mu.Lock()
+	d.observer = observer
+	d.mu.Unlock()
+}
+
+// Config returns the configuration, with its defaults applied
+func (d *Degrader) Config() Config {
+	return d.cfg
+}
+
+// Select returns the model to serve a request for model by. Fallbacks the
+// caller may not use, as usable tells, are skipped; a nil usable allows
+// all of them.
+func (d *Degrader) Select(model string, usable func(model string) bool) Decision {
+	decision := Decision{Requested: model, Model: model}
+	chain := d.cfg.Chains[model]
+	if len(chain) == 0 {
+		return decision
+	}
+
+	d.mu.Lock()
+	capacity, burning, observer := d.capacity, d.burning, d.observer
+	d.mu.Unlock()
+	hasCapacity := func(model string) bool { return capacity == nil || capacity(model) }
+
+	switch {
+	case !hasCapacity(model):
+		decision.Reason = ReasonCapacity
+	case d.cfg.OnSLOBurn && burning != nil && burning(model):
+		decision.Reason = ReasonSLOBurn
+	}
+	if decision.Reason != "" {
+		for _, fallback := range chain {
+			if (usable == nil || usable(fallback)) && hasCapacity(fallback) {
+				decision.Model = fallback
+				break
+			}
+		}
+	}
+
+	d.mu.Lock()
+	stats := d.stats[model]
+	stats.Requests++
+	switch {
+	case decision.Fallback():
+		stats.Fallbacks[decision.Model]++
+		stats.Reasons[decision.Reason]++
+	case decision.Reason != "":
+		stats.Exhausted++
+	}
+	d.mu.Unlock()
+
+	if decision.Fallback() && observer != nil {
+		observer(model, decision.Model, decision.Reason)
+	}
+	return decision
+}
+
+// Stats returns the counters of every model with a fallback chain, sorted
+// by model
+func (d *Degrader) Stats() []Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make([]Stats, 0, len(d.stats))
+	for _, s := range d.stats {
+		copied := *s
+		copied.Fallbacks = make(map[string]int64, len(s.Fallbacks))
+		var fallbacks int64
+		for model, n := range s.Fallbacks {
+			copied.Fallbacks[model] = n
+			fallbacks += n
+		}
+		copied.Reasons = make(map[string]int64, len(s.Reasons))
+		for reason, n := range s.Reasons {
+			copied.Reasons[reason] = n
+		}
+		if s.Requests > 0 {
+			copied.FallbackRate = float64(fallbacks) / float64(s.Requests)
+		}
+		stats = append(stats, copied)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Model < stats[j].Model })
+	return stats
+}
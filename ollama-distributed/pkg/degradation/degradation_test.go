@@ -0,0 +1,66 @@
+package degradation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectFallsBackWithoutCapacity(t *testing.T) {
+	d := NewDegrader(Config{Enabled: true, Chains: map[string][]string{
+		"llama3:70b": {"llama3:8b", "llama3:3b"},
+	}})
+	full := map[string]bool{}
+	d.SetCapacity(func(model string) bool { return !full[model] })
+	var observed []string
+	d.SetObserver(func(model, fallback, reason string) { observed = append(observed, fallback+" "+reason) })
+
+	decision := d.Select("llama3:70b", nil)
+	assert.False(t, decision.Fallback())
+	assert.Empty(t, decision.Reason)
+
+	full["llama3:70b"] = true
+	decision = d.Select("llama3:70b", nil)
+	assert.True(t, decision.Fallback())
+	assert.Equal(t, "llama3:8b", decision.Model)
+	assert.Equal(t, ReasonCapacity, decision.Reason)
+
+	full["llama3:8b"] = true
+	assert.Equal(t, "llama3:3b", d.Select("llama3:70b", nil).Model)
+	assert.Equal(t, "llama3:70b", d.Select("llama3:70b", func(model string) bool { return model != "llama3:3b" }).Model,
+		"fallbacks the caller may not use are skipped")
+
+	assert.Equal(t, "mistral", d.Select("mistral", nil).Model, "models without a chain are served as requested")
+	assert.Equal(t, []string{"llama3:8b capacity_exhausted", "llama3:3b capacity_exhausted"}, observed)
+
+	stats := d.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(4), stats[0].Requests)
+	assert.Equal(t, map[string]int64{"llama3:8b": 1, "llama3:3b": 1}, stats[0].Fallbacks)
+	assert.Equal(t, int64(1), stats[0].Exhausted)
+	assert.InDelta(t, 0.5, stats[0].FallbackRate, 1e-9)
+}
+
+func TestSelectFallsBackOnSLOBurn(t *testing.T) {
+	chains := map[string][]string{"llama3:70b": {"llama3:8b"}}
+	burning := func(model string) bool { return model == "llama3:70b" }
+
+	d := NewDegrader(Config{Chains: chains})
+	d.SetBurning(burning)
+	assert.False(t, d.Select("llama3:70b", nil).Fallback(), "SLO burn is only acted on when configured")
+
+	d = NewDegrader(Config{Chains: chains, OnSLOBurn: true})
+	d.SetBurning(burning)
+	decision := d.Select("llama3:70b", nil)
+	assert.Equal(t, "llama3:8b", decision.Model)
+	assert.Equal(t, ReasonSLOBurn, decision.Reason)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Chains: map[string][]string{"a": {"b", "c"}}}.Validate())
+	assert.Error(t, Config{Chains: map[string][]string{"a": {}}}.Validate())
+	assert.Error(t, Config{Chains: map[string][]string{"a": {"b", "a"}}}.Validate())
+	assert.Error(t, Config{QueueThreshold: 1.5}.Validate())
+	assert.Equal(t, float64(DefaultUsageThreshold), NewDegrader(Config{}).Config().UsageThreshold)
+}
@@ -0,0 +1,191 @@
+// Package events records every transition of the cluster state — nodes
+// joining and leaving, leader changes, placement changes, configuration
+// loads — as an event with causality metadata, so operators can ask what
+// changed before an incident. Events are appended to a store and never
+// modified.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+)
+
+// Event types
+const (
+	NodeJoined        = "node_joined"
+	NodeLeft          = "node_left"
+	NodeStatusChanged = "node_status_changed"
+	LeaderChanged     = "leader_changed"
+	PlacementChanged  = "placement_changed"
+	ConfigLoaded      = "config_loaded"
+	AnomalyDetected   = "anomaly_detected"
+	ReplicasScaled    = "replicas_scaled"
+	FaultInjected     = "fault_injected"
+	FaultCleared      = "fault_cleared"
+	RecoveryApproved  = "recovery_approved"
+	RecoveryRejected  = "recovery_rejected"
+	NodeQuarantined   = "node_quarantined"
+	NodeReleased      = "node_released"
+)
+
+// Store kinds
+const (
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+)
+
+// DefaultWatchInterval is how often the cluster state is checked for
+// transitions
+const DefaultWatchInterval = 5 * time.Second
+
+// Config configures the event history
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is where events are kept: memory (the latest Capacity ones) or
+	// database
+	Store    string          `yaml:"store"`
+	Capacity int             `yaml:"capacity"`
+	Database database.Config `yaml:"database"`
+	// WatchInterval is how often the cluster state is compared with the
+	// last one seen
+	WatchInterval time.Duration `yaml:"watch_interval" mapstructure:"watch_interval"`
+}
+
+// Validate checks the store and interval
+func (c Config) Validate() error {
+	switch c.Store {
+	case "", StoreMemory, StoreDatabase:
+	default:
+		return fmt.Errorf("unknown event store %q", c.Store)
+	}
+	if c.Capacity < 0 || c.WatchInterval < 0 {
+		return fmt.Errorf("negative capacity or watch interval")
+	}
+	return nil
+}
+
+// Event is a transition of the cluster state. NodeID and Sequence order
+// the events recorded by one node; Term is the Raft term they were seen
+// in. CausedBy is the event that led to this one, CorrelationID groups
+// the events seen together, and Actor and RequestID name who asked for a
+// change made through the API.
+type Event struct {
+	ID            string                 `json:"id"`
+	Time          time.Time              `json:"time"`
+	Type          string                 `json:"type"`
+	Subject       string                 `json:"subject,omitempty"`
+	NodeID        string                 `json:"node_id"`
+	Sequence      int64                  `json:"sequence"`
+	Term          uint64                 `json:"term,omitempty"`
+	CausedBy      string                 `json:"caused_by,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Actor         string                 `json:"actor,omitempty"`
+	RequestID     string                 `json:"request_id,omitempty"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+// Filter selects events when listing them
+type Filter struct {
+	// Since and Until bound the time of the events; zero leaves them open
+	Since time.Time
+	Until time.Time
+	// Types selects the events of any of these types; empty selects all
+	Types []string
+	// Limit caps the number of events returned, newest first
+	Limit int
+}
+
+func (f Filter) matches(e *Event) bool {
+	if e.Time.Before(f.Since) || (!f.Until.IsZero() && !e.Time.Before(f.Until)) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+type causeKey struct{}
+
+// WithCause returns a context whose recorded events are caused by event
+func WithCause(ctx context.Context, event Event) context.Context {
+	return context.WithValue(ctx, causeKey{}, event.ID)
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose recorded events were asked for by actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Log records the events seen by a node
+type Log struct {
+	store  Store
+	nodeID string
+	term   func() uint64
+
+	mu       sync.Mutex
+	sequence int64
+}
+
+// NewLog creates a log appending the events of nodeID to store. term, which
+// may be nil, returns the current Raft term.
+func NewLog(store Store, nodeID string, term func() uint64) *Log {
+	return &Log{store: store, nodeID: nodeID, term: term}
+}
+
+// Record completes an event with its identity, time and causality, taking
+// the cause, actor and request ID from ctx unless set, and appends it
+func (l *Log) Record(ctx context.Context, event Event) (Event, error) {
+	l.mu.Lock()
+	l.sequence++
+	event.Sequence = l.sequence
+	l.mu.Unlock()
+
+	event.ID = uuid.New().String()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.NodeID = l.nodeID
+	if l.term != nil {
+		event.Term = l.term()
+	}
+	if cause, ok := ctx.Value(causeKey{}).(string); ok && event.CausedBy == "" {
+		event.CausedBy = cause
+	}
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && event.Actor == "" {
+		event.Actor = actor
+	}
+	if event.RequestID == "" {
+		event.RequestID = logging.RequestIDFromContext(ctx)
+	}
+
+	if err := l.store.Append(ctx, &event); err != nil {
+		return event, fmt.Errorf("failed to record %s event: %w", event.Type, err)
+	}
+	slog.Debug("Recorded cluster event", "type", event.Type, "subject", event.Subject, "id", event.ID)
+	return event, nil
+}
+
+// List returns the events matching filter, newest first
+func (l *Log) List(ctx context.Context, filter Filter) ([]*Event, error) {
+	return l.store.List(ctx, filter)
+}
+
+// Close closes the store
+func (l *Log) Close() error {
+	return l.store.Close()
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRecord(t *testing.T) {
+	log := NewLog(NewMemoryStore(0), "node-1", func() uint64 { return 7 })
+	ctx := context.Background()
+
+	reload, err := log.Record(WithActor(ctx, "admin"), Event{Type: ConfigLoaded})
+	require.NoError(t, err)
+	assert.NotEmpty(t, reload.ID)
+	assert.Equal(t, "node-1", reload.NodeID)
+	assert.Equal(t, int64(1), reload.Sequence)
+	assert.Equal(t, uint64(7), reload.Term)
+	assert.Equal(t, "admin", reload.Actor)
+
+	placement, err := log.Record(WithCause(ctx, reload), Event{Type: PlacementChanged, Subject: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), placement.Sequence)
+	assert.Equal(t, reload.ID, placement.CausedBy)
+}
+
+func TestMemoryStoreFilter(t *testing.T) {
+	store := NewMemoryStore(3)
+	log := NewLog(store, "node-1", nil)
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	for i, typ := range []string{NodeJoined, LeaderChanged, NodeLeft, PlacementChanged} {
+		_, err := log.Record(context.Background(), Event{Type: typ, Time: start.Add(time.Duration(i) * time.Minute)})
+		require.NoError(t, err)
+	}
+
+	types := func(events []*Event) []string {
+		var list []string
+		for _, e := range events {
+			list = append(list, e.Type)
+		}
+		return list
+	}
+
+	// The oldest event was dropped; the rest come newest first
+	all, err := store.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{PlacementChanged, NodeLeft, LeaderChanged}, types(all))
+
+	events, err := store.List(context.Background(), Filter{Until: start.Add(3 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{NodeLeft, LeaderChanged}, types(events))
+	events, err = store.List(context.Background(), Filter{Types: []string{LeaderChanged, PlacementChanged}, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{PlacementChanged}, types(events))
+}
+
+func TestWatcher(t *testing.T) {
+	store := NewMemoryStore(0)
+	state := State{
+		Leader:     "node-1",
+		Nodes:      map[string]string{"node-1": "online", "node-2": "online"},
+		Placements: map[string][]string{"llama3": {"node-1", "node-2"}},
+	}
+	watcher := NewWatcher(NewLog(store, "node-1", nil), func() State { return state })
+	ctx := context.Background()
+
+	require.NoError(t, watcher.Check(ctx))
+	events, err := store.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, events, "the first state is the baseline")
+
+	state = State{
+		Leader:     "node-3",
+		Nodes:      map[string]string{"node-1": "draining", "node-3": "online"},
+		Placements: map[string][]string{"llama3": {"node-1"}, "mistral": {"node-3"}},
+	}
+	require.NoError(t, watcher.Check(ctx))
+	events, err = store.List(ctx, Filter{})
+	require.NoError(t, err)
+	require.Len(t, events, 6)
+
+	byType := make(map[string][]*Event)
+	for _, e := range events {
+		byType[e.Type] = append(byType[e.Type], e)
+		assert.Equal(t, events[0].CorrelationID, e.CorrelationID, "one check is correlated")
+	}
+	assert.Equal(t, "node-3", byType[LeaderChanged][0].Subject)
+	assert.Equal(t, "node-3", byType[NodeJoined][0].Subject)
+	assert.Equal(t, map[string]interface{}{"from": "online", "to": "draining"}, byType[NodeStatusChanged][0].Data)
+	left := byType[NodeLeft][0]
+	assert.Equal(t, "node-2", left.Subject)
+
+	require.Len(t, byType[PlacementChanged], 2)
+	for _, e := range byType[PlacementChanged] {
+		switch e.Subject {
+		case "llama3":
+			assert.Equal(t, []string{"node-2"}, e.Data["removed"])
+			assert.Equal(t, left.ID, e.CausedBy, "the replica left with its node")
+		case "mistral":
+			assert.Equal(t, []string{"node-3"}, e.Data["added"])
+			assert.Empty(t, e.CausedBy)
+		}
+	}
+
+	require.NoError(t, watcher.Check(ctx))
+	again, err := store.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, again, 6, "an unchanged state records nothing")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Store: StoreDatabase}.Validate())
+	assert.Error(t, Config{Store: "file"}.Validate())
+	assert.Error(t, Config{WatchInterval: -time.Second}.Validate())
+}
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// defaultCapacity is the number of events the memory store keeps
+const defaultCapacity = 10000
+
+// Store keeps events
+type Store interface {
+	Append(ctx context.Context, event *Event) error
+	// List returns the events matching filter, newest first
+	List(ctx context.Context, filter Filter) ([]*Event, error)
+	Close() error
+}
+
+// OpenStore opens the store configured by cfg, migrating the database of a
+// database store
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewMemoryStore(cfg.Capacity), nil
+	case StoreDatabase:
+		db := cfg.Database
+		manager, err := database.NewManager(&db)
+		if err != nil {
+			return nil, err
+		}
+		if err := manager.RunMigrations(context.Background()); err != nil {
+			manager.Close()
+			return nil, err
+		}
+		return NewDatabaseStore(manager), nil
+	default:
+		return nil, fmt.Errorf("unknown event store %q", cfg.Store)
+	}
+}
+
+// MemoryStore keeps the latest events in memory
+type MemoryStore struct {
+	mu       sync.RWMutex
+	events   []*Event
+	next     int
+	capacity int
+}
+
+// NewMemoryStore creates a store keeping the latest capacity events
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &MemoryStore{capacity: capacity}
+}
+
+// Append adds an event, dropping the oldest one when full
+func (s *MemoryStore) Append(_ context.Context, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.events) < s.capacity {
+		s.events = append(s.events, event)
+		return nil
+	}
+	s.events[s.next] = event
+	s.next = (s.next + 1) % s.capacity
+	return nil
+}
+
+// List returns the events matching filter, newest first
+func (s *MemoryStore) List(_ context.Context, filter Filter) ([]*Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*Event
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[(s.next+i)%len(s.events)]
+		if !filter.matches(event) {
+			continue
+		}
+		result = append(result, event)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Close does nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// DatabaseStore keeps events in the cluster_events table
+type DatabaseStore struct {
+	db *database.Manager
+}
+
+// NewDatabaseStore creates a store on db, whose migrations must have run
+func NewDatabaseStore(db *database.Manager) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Append inserts an event
+func (s *DatabaseStore) Append(ctx context.Context, event *Event) error {
+	return s.db.CreateClusterEvent(ctx, &database.ClusterEvent{
+		ID:            event.ID,
+		OccurredAt:    event.Time,
+		Type:          event.Type,
+		Subject:       event.Subject,
+		NodeID:        event.NodeID,
+		Sequence:      event.Sequence,
+		Term:          event.Term,
+		CausedBy:      event.CausedBy,
+		CorrelationID: event.CorrelationID,
+		Actor:         event.Actor,
+		RequestID:     event.RequestID,
+		Data:          event.Data,
+	})
+}
+
+// List returns the events matching filter, newest first
+func (s *DatabaseStore) List(ctx context.Context, filter Filter) ([]*Event, error) {
+	rows, err := s.db.ListClusterEvents(ctx, filter.Types, filter.Since, filter.Until, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*Event, len(rows))
+	for i, row := range rows {
+		events[i] = &Event{
+			ID:            row.ID,
+			Time:          row.OccurredAt,
+			Type:          row.Type,
+			Subject:       row.Subject,
+			NodeID:        row.NodeID,
+			Sequence:      row.Sequence,
+			Term:          row.Term,
+			CausedBy:      row.CausedBy,
+			CorrelationID: row.CorrelationID,
+			Actor:         row.Actor,
+			RequestID:     row.RequestID,
+			Data:          row.Data,
+		}
+	}
+	return events, nil
+}
+
+// Close closes the database
+func (s *DatabaseStore) Close() error {
+	return s.db.Close()
+}
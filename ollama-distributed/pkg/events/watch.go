@@ -0,0 +1,161 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// State is the part of the cluster state whose transitions are recorded
+type State struct {
+	Leader string
+	// Nodes maps the ID of every known node to its status
+	Nodes map[string]string
+	// Placements maps every model to the nodes holding a replica of it
+	Placements map[string][]string
+}
+
+// Watcher records the transitions between successive states of the cluster
+type Watcher struct {
+	log   *Log
+	state func() State
+	last  *State
+}
+
+// NewWatcher creates a watcher recording to log the transitions of the
+// states returned by state
+func NewWatcher(log *Log, state func() State) *Watcher {
+	return &Watcher{log: log, state: state}
+}
+
+// Run checks the state every interval until ctx is done
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Check(ctx); err != nil {
+			slog.Warn("Failed to record cluster events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check records the transitions since the last check. The first check only
+// takes the state as the baseline. The events of one check share a
+// correlation ID, and placement changes are caused by the departure of the
+// nodes that held the replicas.
+func (w *Watcher) Check(ctx context.Context) error {
+	state := w.state()
+	last := w.last
+	if last == nil {
+		w.last = &state
+		return nil
+	}
+	// A failed append is not retried, lest the events recorded before it
+	// be recorded twice
+	w.last = &state
+
+	correlation := uuid.New().String()
+	record := func(ctx context.Context, event Event) (Event, error) {
+		event.CorrelationID = correlation
+		return w.log.Record(ctx, event)
+	}
+
+	if state.Leader != last.Leader {
+		_, err := record(ctx, Event{Type: LeaderChanged, Subject: state.Leader,
+			Data: map[string]interface{}{"previous": last.Leader}})
+		if err != nil {
+			return err
+		}
+	}
+
+	departures := make(map[string]Event)
+	for _, id := range sortedKeys(state.Nodes) {
+		previous, known := last.Nodes[id]
+		switch {
+		case !known:
+			if _, err := record(ctx, Event{Type: NodeJoined, Subject: id,
+				Data: map[string]interface{}{"status": state.Nodes[id]}}); err != nil {
+				return err
+			}
+		case previous != state.Nodes[id]:
+			if _, err := record(ctx, Event{Type: NodeStatusChanged, Subject: id,
+				Data: map[string]interface{}{"from": previous, "to": state.Nodes[id]}}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range sortedKeys(last.Nodes) {
+		if _, present := state.Nodes[id]; present {
+			continue
+		}
+		event, err := record(ctx, Event{Type: NodeLeft, Subject: id,
+			Data: map[string]interface{}{"status": last.Nodes[id]}})
+		if err != nil {
+			return err
+		}
+		departures[id] = event
+	}
+
+	models := sortedKeys(state.Placements)
+	for _, model := range sortedKeys(last.Placements) {
+		if _, present := state.Placements[model]; !present {
+			models = append(models, model)
+		}
+	}
+	for _, model := range models {
+		added := difference(state.Placements[model], last.Placements[model])
+		removed := difference(last.Placements[model], state.Placements[model])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		eventCtx := ctx
+		for _, node := range removed {
+			if departure, ok := departures[node]; ok {
+				eventCtx = WithCause(ctx, departure)
+				break
+			}
+		}
+		_, err := record(eventCtx, Event{Type: PlacementChanged, Subject: model, Data: map[string]interface{}{
+			"added":   added,
+			"removed": removed,
+			"nodes":   state.Placements[model],
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// difference returns the elements of a missing from b, sorted
+func difference(a, b []string) []string {
+	diff := []string{}
+	for _, x := range a {
+		if !slices.Contains(b, x) {
+			diff = append(diff, x)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
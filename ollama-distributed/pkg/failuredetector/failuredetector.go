@@ -0,0 +1,241 @@
+// Package failuredetector suspects nodes of having failed from the arrival
+// times of their heartbeats, with the phi accrual failure detector of
+// Hayashibara et al. Instead of a yes or no after a fixed timeout, it gives
+// each node a suspicion level phi that grows the longer a heartbeat is
+// overdue, relative to the mean and deviation of the intervals seen so far.
+// A phi of 1 means a 10% chance that the node is still alive and just late,
+// 2 a 1% chance, 3 a 0.1% chance, and so on.
+package failuredetector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Defaults of the failure detector
+const (
+	DefaultHeartbeatInterval = time.Second
+	DefaultThreshold         = 8.0
+	DefaultWindowSize        = 100
+	DefaultMinStdDeviation   = 100 * time.Millisecond
+)
+
+// Config configures the heartbeats nodes send each other and how
+// sensitively missed ones raise suspicion
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// HeartbeatInterval is how often nodes send heartbeats, 1s by default
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" mapstructure:"heartbeat_interval"`
+	// Threshold is the phi from which a node is suspected, 8 by default.
+	// Lower thresholds detect failures sooner at the cost of more false
+	// suspicions.
+	Threshold float64 `yaml:"threshold"`
+	// WindowSize is how many heartbeat intervals per node the mean and
+	// deviation are estimated from
+	WindowSize int `yaml:"window_size" mapstructure:"window_size"`
+	// MinStdDeviation keeps very regular heartbeats from making a node
+	// suspected as soon as one is slightly late
+	MinStdDeviation time.Duration `yaml:"min_std_deviation" mapstructure:"min_std_deviation"`
+	// AcceptablePause is added to the expected interval, for pauses such
+	// as garbage collection that should not raise suspicion
+	AcceptablePause time.Duration `yaml:"acceptable_pause" mapstructure:"acceptable_pause"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.HeartbeatInterval < 0 || c.MinStdDeviation < 0 || c.AcceptablePause < 0 {
+		return fmt.Errorf("failure detector durations must not be negative")
+	}
+	if c.Threshold < 0 || c.WindowSize < 0 {
+		return fmt.Errorf("failure detector threshold and window size must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.HeartbeatInterval == 0 {
+		c.HeartbeatInterval = DefaultHeartbeatInterval
+	}
+	if c.Threshold == 0 {
+		c.Threshold = DefaultThreshold
+	}
+	if c.WindowSize == 0 {
+		c.WindowSize = DefaultWindowSize
+	}
+	if c.MinStdDeviation == 0 {
+		c.MinStdDeviation = DefaultMinStdDeviation
+	}
+	return c
+}
+
+// NodeStats are the detector's view of a node
+type NodeStats struct {
+	NodeID        string        `json:"node_id"`
+	Phi           float64       `json:"phi"`
+	Suspected     bool          `json:"suspected"`
+	Heartbeats    int64         `json:"heartbeats"`
+	LastHeartbeat time.Time     `json:"last_heartbeat"`
+	MeanInterval  time.Duration `json:"mean_interval"`
+	StdDeviation  time.Duration `json:"std_deviation"`
+	Samples       int           `json:"samples"`
+}
+
+// history is the heartbeat intervals of a node, in a ring buffer with
+// running sums
+type history struct {
+	intervals  []float64 // milliseconds
+	next       int
+	sum, sumSq float64
+	heartbeats int64
+	last       time.Time
+}
+
+func (h *history) add(interval float64, size int) {
+	if len(h.intervals) < size {
+		h.intervals = append(h.intervals, interval)
+	} else {
+		old := h.intervals[h.next]
+		h.sum -= old
+		h.sumSq -= old * old
+		h.intervals[h.next] = interval
+		h.next = (h.next + 1) % size
+	}
+	h.sum += interval
+	h.sumSq += interval * interval
+}
+
+func (h *history) mean() float64 {
+	return h.sum / float64(len(h.intervals))
+}
+
+func (h *history) stdDeviation() float64 {
+	mean := h.mean()
+	variance := h.sumSq/float64(len(h.intervals)) - mean*mean
+	if variance < 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Detector is a phi accrual failure detector over the heartbeats of the
+// cluster's nodes. It is safe for concurrent use.
+type Detector struct {
+	cfg Config
+	now func() time.Time
+
+	mu    sync.RWMutex
+	nodes map[string]*history
+}
+
+var _ types.FailureDetector = (*Detector)(nil)
+
+// NewDetector returns a detector that has not heard from any node
+func NewDetector(cfg Config) *Detector {
+	return &Detector{
+		cfg:   cfg.WithDefaults(),
+		now:   time.Now,
+		nodes: make(map[string]*history),
+	}
+}
+
+// Config returns the detector's settings, defaults applied
+func (d *Detector) Config() Config {
+	return d.cfg
+}
+
+// Heartbeat records a heartbeat of a node arriving at a time
+func (d *Detector) Heartbeat(nodeID string, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, exists := d.nodes[nodeID]
+	if !exists {
+		// Until intervals are known, expect heartbeats at the configured
+		// interval
+		h = &history{}
+		h.add(float64(d.cfg.HeartbeatInterval)/float64(time.Millisecond), d.cfg.WindowSize)
+		d.nodes[nodeID] = h
+	} else if interval := at.Sub(h.last); interval > 0 {
+		h.add(float64(interval)/float64(time.Millisecond), d.cfg.WindowSize)
+	}
+	h.heartbeats++
+	if at.After(h.last) {
+		h.last = at
+	}
+}
+
+// Remove forgets a node, e.g. once it left the cluster
+func (d *Detector) Remove(nodeID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.nodes, nodeID)
+}
+
+// Suspicion returns the phi of a node now, 0 for nodes never heard from
+func (d *Detector) Suspicion(nodeID string) float64 {
+	return d.Phi(nodeID, d.now())
+}
+
+// Suspected reports whether the phi of a node has reached the threshold
+func (d *Detector) Suspected(nodeID string) bool {
+	return d.Suspicion(nodeID) >= d.cfg.Threshold
+}
+
+// Phi returns the phi of a node at a time
+func (d *Detector) Phi(nodeID string, now time.Time) float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	h, exists := d.nodes[nodeID]
+	if !exists {
+		return 0
+	}
+	return d.phi(h, now)
+}
+
+// phi is the suspicion of a node whose heartbeat is overdue, using the
+// logistic approximation of the normal distribution's tail. The caller
+// holds mu.
+func (d *Detector) phi(h *history, now time.Time) float64 {
+	elapsed := float64(now.Sub(h.last)) / float64(time.Millisecond)
+	mean := h.mean() + float64(d.cfg.AcceptablePause)/float64(time.Millisecond)
+	std := math.Max(h.stdDeviation(), float64(d.cfg.MinStdDeviation)/float64(time.Millisecond))
+
+	y := (elapsed - mean) / std
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	if elapsed > mean {
+		return -math.Log10(e / (1 + e))
+	}
+	return -math.Log10(1 - 1/(1+e))
+}
+
+// Stats returns the detector's view of every node it has heard from,
+// ordered by node ID
+func (d *Detector) Stats() []NodeStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := d.now()
+	stats := make([]NodeStats, 0, len(d.nodes))
+	for nodeID, h := range d.nodes {
+		phi := d.phi(h, now)
+		stats = append(stats, NodeStats{
+			NodeID:        nodeID,
+			Phi:           phi,
+			Suspected:     phi >= d.cfg.Threshold,
+			Heartbeats:    h.heartbeats,
+			LastHeartbeat: h.last,
+			MeanInterval:  time.Duration(h.mean() * float64(time.Millisecond)),
+			StdDeviation:  time.Duration(h.stdDeviation() * float64(time.Millisecond)),
+			Samples:       len(h.intervals),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].NodeID < stats[j].NodeID })
+	return stats
+}
@@ -0,0 +1,83 @@
+package failuredetector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{HeartbeatInterval: -time.Second}.Validate())
+	assert.Error(t, Config{Threshold: -1}.Validate())
+
+	cfg := Config{}.WithDefaults()
+	assert.Equal(t, DefaultHeartbeatInterval, cfg.HeartbeatInterval)
+	assert.Equal(t, DefaultThreshold, cfg.Threshold)
+}
+
+func TestPhiGrowsWithOverdueHeartbeats(t *testing.T) {
+	d := NewDetector(Config{})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		d.Heartbeat("n1", start.Add(time.Duration(i)*time.Second))
+	}
+	last := start.Add(19 * time.Second)
+
+	assert.Equal(t, 0.0, d.Phi("unknown", last))
+	onTime := d.Phi("n1", last.Add(time.Second))
+	late := d.Phi("n1", last.Add(1500*time.Millisecond))
+	dead := d.Phi("n1", last.Add(5*time.Second))
+	assert.Less(t, onTime, 1.0)
+	assert.Greater(t, late, onTime)
+	assert.Greater(t, dead, DefaultThreshold)
+
+	d.now = func() time.Time { return last.Add(5 * time.Second) }
+	assert.True(t, d.Suspected("n1"))
+	d.Heartbeat("n1", last.Add(5*time.Second))
+	assert.False(t, d.Suspected("n1"), "a heartbeat clears the suspicion")
+}
+
+func TestSensitivity(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sensitive := NewDetector(Config{Threshold: 1})
+	tolerant := NewDetector(Config{Threshold: 1, AcceptablePause: 3 * time.Second})
+	for _, d := range []*Detector{sensitive, tolerant} {
+		for i := 0; i < 10; i++ {
+			d.Heartbeat("n1", start.Add(time.Duration(i)*time.Second))
+		}
+		d.now = func() time.Time { return start.Add(12 * time.Second) }
+	}
+
+	assert.True(t, sensitive.Suspected("n1"))
+	assert.False(t, tolerant.Suspected("n1"), "a pause within the acceptable pause")
+}
+
+func TestStats(t *testing.T) {
+	d := NewDetector(Config{WindowSize: 3})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, gap := range []time.Duration{0, 2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second} {
+		start = start.Add(gap)
+		d.Heartbeat("b", start)
+		if i == 0 {
+			d.Heartbeat("a", start)
+		}
+	}
+	d.now = func() time.Time { return start }
+
+	stats := d.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "a", stats[0].NodeID)
+	b := stats[1]
+	assert.Equal(t, int64(5), b.Heartbeats)
+	assert.Equal(t, 3, b.Samples)
+	// The 1s bootstrap interval has left the window
+	assert.Equal(t, 2*time.Second, b.MeanInterval)
+	assert.Equal(t, time.Duration(0), b.StdDeviation)
+	assert.False(t, b.Suspected)
+
+	d.Remove("a")
+	assert.Len(t, d.Stats(), 1)
+}
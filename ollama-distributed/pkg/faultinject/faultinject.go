@@ -0,0 +1,113 @@
+// Package faultinject injects synthetic faults — unreachable nodes, high
+// latency, exhausted resources — into the fault tolerance manager of a
+// staging cluster, so that operators can run game days and check that
+// alerting and recovery respond without causing a real outage. Injected
+// faults expire on their own and are marked as injected wherever they are
+// reported.
+package faultinject
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kinds of fault that can be injected
+const (
+	NodeUnreachable    Kind = "node_unreachable"
+	HighLatency        Kind = "high_latency"
+	ResourceExhaustion Kind = "resource_exhaustion"
+)
+
+// Defaults for injected faults
+const (
+	DefaultDuration      = 5 * time.Minute
+	DefaultMaxDuration   = time.Hour
+	DefaultLatency       = time.Second
+	DefaultResource      = "memory"
+	DefaultUsage         = 0.99
+	DefaultCheckInterval = 10 * time.Second
+)
+
+// DefaultEnvironments are the node environments injection is allowed in
+// unless configured otherwise
+var DefaultEnvironments = []string{"staging"}
+
+var (
+	// ErrNotAllowed is returned when injection is enabled on a node outside
+	// the allowed environments
+	ErrNotAllowed = errors.New("fault injection not allowed in this environment")
+	// ErrInvalid is returned for injection requests that cannot be carried
+	// out
+	ErrInvalid = errors.New("invalid fault injection")
+	// ErrUnknownNode is returned when the target is not a node of the
+	// cluster
+	ErrUnknownNode = errors.New("unknown node")
+)
+
+// Kind is a kind of fault that can be injected
+type Kind string
+
+// Config configures fault injection, which is disabled by default
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Environments are the node environments injection is allowed in,
+	// only staging by default
+	Environments []string `yaml:"environments"`
+	// DefaultDuration is how long faults last when the request does not
+	// say; MaxDuration caps what a request may ask for
+	DefaultDuration time.Duration `yaml:"default_duration" mapstructure:"default_duration"`
+	MaxDuration     time.Duration `yaml:"max_duration" mapstructure:"max_duration"`
+	// CheckInterval is how often expired faults are cleared
+	CheckInterval time.Duration `yaml:"check_interval" mapstructure:"check_interval"`
+}
+
+// Validate checks the durations
+func (c Config) Validate() error {
+	if c.DefaultDuration < 0 || c.MaxDuration < 0 || c.CheckInterval < 0 {
+		return fmt.Errorf("negative fault injection duration or check interval")
+	}
+	if c.MaxDuration > 0 && c.DefaultDuration > c.MaxDuration {
+		return fmt.Errorf("default fault injection duration %s exceeds max_duration %s", c.DefaultDuration, c.MaxDuration)
+	}
+	return nil
+}
+
+// Allowed reports whether injection is allowed on a node of environment
+func (c Config) Allowed(environment string) bool {
+	environments := c.Environments
+	if len(environments) == 0 {
+		environments = DefaultEnvironments
+	}
+	for _, e := range environments {
+		if e == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// Request asks for a fault to be injected on the Target node for
+// Duration, or the default duration if zero
+type Request struct {
+	Kind     Kind
+	Target   string
+	Duration time.Duration
+	// Latency is what high_latency faults report
+	Latency time.Duration
+	// Resource and Usage, a ratio, are what resource_exhaustion faults
+	// report
+	Resource string
+	Usage    float64
+}
+
+// Injection is an injected fault and the fault detection it raised
+type Injection struct {
+	ID        string                 `json:"id"`
+	Kind      Kind                   `json:"kind"`
+	Target    string                 `json:"target"`
+	FaultID   string                 `json:"fault_id"`
+	StartedAt time.Time              `json:"started_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
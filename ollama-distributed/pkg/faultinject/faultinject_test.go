@@ -0,0 +1,171 @@
+package faultinject
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeManager records the faults detected and resolved
+type fakeManager struct {
+	mu       sync.Mutex
+	faults   map[string]*fault_tolerance.FaultDetection
+	resolved []string
+}
+
+func newFakeManager() *fakeManager {
+	return &fakeManager{faults: make(map[string]*fault_tolerance.FaultDetection)}
+}
+
+func (m *fakeManager) DetectFault(faultType fault_tolerance.FaultType, target, description string, metadata map[string]interface{}) *fault_tolerance.FaultDetection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fault := &fault_tolerance.FaultDetection{
+		ID:          fmt.Sprintf("fault-%d", len(m.faults)+1),
+		Type:        faultType,
+		Target:      target,
+		Description: description,
+		Metadata:    metadata,
+	}
+	m.faults[fault.ID] = fault
+	return fault
+}
+
+func (m *fakeManager) ResolveFault(faultID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resolved = append(m.resolved, faultID)
+}
+
+// fakeCluster knows the listed nodes
+type fakeCluster struct {
+	nodes []string
+}
+
+func (c fakeCluster) ListNodes() []*types.NodeInfo {
+	nodes := make([]*types.NodeInfo, 0, len(c.nodes))
+	for _, id := range c.nodes {
+		nodes = append(nodes, &types.NodeInfo{ID: id, Status: types.NodeStatusOnline})
+	}
+	return nodes
+}
+
+func (c fakeCluster) GetNode(id string) (*types.NodeInfo, bool) {
+	for _, node := range c.ListNodes() {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+func (c fakeCluster) Subscribe(func(types.NodeEvent)) func() { return func() {} }
+
+func TestConfigAllowed(t *testing.T) {
+	assert.True(t, Config{}.Allowed("staging"))
+	assert.False(t, Config{}.Allowed("production"))
+	assert.False(t, Config{}.Allowed(""))
+
+	cfg := Config{Environments: []string{"qa", "staging-eu"}}
+	assert.True(t, cfg.Allowed("qa"))
+	assert.False(t, cfg.Allowed("staging"))
+
+	assert.Error(t, Config{DefaultDuration: 2 * time.Hour, MaxDuration: time.Hour}.Validate())
+	assert.Error(t, Config{CheckInterval: -time.Second}.Validate())
+	assert.NoError(t, Config{DefaultDuration: time.Minute}.Validate())
+}
+
+func TestNewInjectorOutsideStaging(t *testing.T) {
+	_, err := NewInjector(Config{Enabled: true}, "production", newFakeManager(), nil)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	_, err = NewInjector(Config{Enabled: true}, "staging", newFakeManager(), nil)
+	assert.NoError(t, err)
+}
+
+func TestInject(t *testing.T) {
+	manager := newFakeManager()
+	inj, err := NewInjector(Config{Enabled: true}, "staging", manager, fakeCluster{nodes: []string{"n1", "n2"}})
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inj.now = func() time.Time { return now }
+
+	unreachable, err := inj.Inject(Request{Kind: NodeUnreachable, Target: "n1"})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(DefaultDuration), unreachable.ExpiresAt)
+	fault := manager.faults[unreachable.FaultID]
+	require.NotNil(t, fault)
+	assert.Equal(t, fault_tolerance.FaultTypeNodeFailure, fault.Type)
+	assert.Equal(t, "n1", fault.Target)
+	assert.Equal(t, true, fault.Metadata["injected"])
+	assert.Equal(t, unreachable.ID, fault.Metadata["injection_id"])
+
+	latency, err := inj.Inject(Request{Kind: HighLatency, Target: "n2", Latency: 750 * time.Millisecond, Duration: time.Minute})
+	require.NoError(t, err)
+	fault = manager.faults[latency.FaultID]
+	assert.Equal(t, fault_tolerance.FaultTypePerformanceAnomaly, fault.Type)
+	assert.Equal(t, 750.0, fault.Metadata["latency_ms"])
+
+	exhausted, err := inj.Inject(Request{Kind: ResourceExhaustion, Target: "n2", Resource: "gpu_memory"})
+	require.NoError(t, err)
+	fault = manager.faults[exhausted.FaultID]
+	assert.Equal(t, fault_tolerance.FaultTypeResourceExhaustion, fault.Type)
+	assert.Equal(t, "gpu_memory", fault.Metadata["resource"])
+	assert.Equal(t, DefaultUsage, fault.Metadata["usage"])
+
+	assert.Len(t, inj.Injections(), 3)
+}
+
+func TestInjectRejects(t *testing.T) {
+	inj, err := NewInjector(Config{Enabled: true, MaxDuration: 10 * time.Minute}, "staging", newFakeManager(), fakeCluster{nodes: []string{"n1"}})
+	require.NoError(t, err)
+
+	_, err = inj.Inject(Request{Kind: NodeUnreachable, Target: "n9"})
+	assert.ErrorIs(t, err, ErrUnknownNode)
+	_, err = inj.Inject(Request{Kind: NodeUnreachable})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = inj.Inject(Request{Kind: "disk_on_fire", Target: "n1"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = inj.Inject(Request{Kind: NodeUnreachable, Target: "n1", Duration: time.Hour})
+	assert.ErrorIs(t, err, ErrInvalid, "longer than max_duration")
+	_, err = inj.Inject(Request{Kind: ResourceExhaustion, Target: "n1", Usage: 1.5})
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.Empty(t, inj.Injections())
+}
+
+func TestClearAndExpire(t *testing.T) {
+	manager := newFakeManager()
+	inj, err := NewInjector(Config{Enabled: true}, "staging", manager, nil)
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	inj.now = func() time.Time { return now }
+
+	short, err := inj.Inject(Request{Kind: HighLatency, Target: "n1", Duration: time.Minute})
+	require.NoError(t, err)
+	long, err := inj.Inject(Request{Kind: NodeUnreachable, Target: "n2", Duration: 30 * time.Minute})
+	require.NoError(t, err)
+	cleared, err := inj.Inject(Request{Kind: ResourceExhaustion, Target: "n3"})
+	require.NoError(t, err)
+
+	got, ok := inj.Clear(cleared.ID)
+	require.True(t, ok)
+	assert.Equal(t, cleared.FaultID, got.FaultID)
+	_, ok = inj.Clear(cleared.ID)
+	assert.False(t, ok, "already cleared")
+
+	now = now.Add(time.Minute)
+	expired := inj.Expire()
+	require.Len(t, expired, 1)
+	assert.Equal(t, short.ID, expired[0].ID)
+
+	remaining := inj.Injections()
+	require.Len(t, remaining, 1)
+	assert.Equal(t, long.ID, remaining[0].ID)
+	assert.Equal(t, []string{cleared.FaultID, short.FaultID}, manager.resolved)
+}
@@ -0,0 +1,230 @@
+package faultinject
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/fault_tolerance"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Manager is the part of the fault tolerance manager faults are injected
+// into
+type Manager interface {
+	DetectFault(faultType fault_tolerance.FaultType, target, description string, metadata map[string]interface{}) *fault_tolerance.FaultDetection
+	ResolveFault(faultID string)
+}
+
+var _ Manager = (*fault_tolerance.FaultToleranceManager)(nil)
+
+// Injector injects faults into the fault tolerance manager and resolves
+// them when they expire or are cleared
+type Injector struct {
+	manager         Manager
+	cluster         types.ClusterView
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+	now             func() time.Time
+
+	mu         sync.Mutex
+	injections map[string]*Injection
+}
+
+// NewInjector creates an injector for a node of environment. It fails
+// unless the configuration allows injection there. Targets are checked
+// against cluster, if not nil.
+func NewInjector(cfg Config, environment string, manager Manager, cluster types.ClusterView) (*Injector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.Allowed(environment) {
+		return nil, fmt.Errorf("%w: %q", ErrNotAllowed, environment)
+	}
+	inj := &Injector{
+		manager:         manager,
+		cluster:         cluster,
+		defaultDuration: cfg.DefaultDuration,
+		maxDuration:     cfg.MaxDuration,
+		now:             time.Now,
+		injections:      make(map[string]*Injection),
+	}
+	if inj.defaultDuration == 0 {
+		inj.defaultDuration = DefaultDuration
+	}
+	if inj.maxDuration == 0 {
+		inj.maxDuration = DefaultMaxDuration
+	}
+	if inj.defaultDuration > inj.maxDuration {
+		inj.defaultDuration = inj.maxDuration
+	}
+	return inj, nil
+}
+
+// Inject raises the fault asked for, which lasts until it expires or is
+// cleared
+func (inj *Injector) Inject(req Request) (Injection, error) {
+	if req.Target == "" {
+		return Injection{}, fmt.Errorf("%w: no target node", ErrInvalid)
+	}
+	if inj.cluster != nil {
+		if _, ok := inj.cluster.GetNode(req.Target); !ok {
+			return Injection{}, fmt.Errorf("%w %q", ErrUnknownNode, req.Target)
+		}
+	}
+	duration := req.Duration
+	if duration == 0 {
+		duration = inj.defaultDuration
+	}
+	if duration < 0 || duration > inj.maxDuration {
+		return Injection{}, fmt.Errorf("%w: duration must be between 0 and %s", ErrInvalid, inj.maxDuration)
+	}
+
+	now := inj.now()
+	injection := &Injection{
+		ID:        uuid.NewString(),
+		Kind:      req.Kind,
+		Target:    req.Target,
+		StartedAt: now,
+		ExpiresAt: now.Add(duration),
+	}
+	injection.Metadata = map[string]interface{}{
+		"injected":     true,
+		"injection_id": injection.ID,
+		"expires_at":   injection.ExpiresAt,
+	}
+
+	var faultType fault_tolerance.FaultType
+	var description string
+	switch req.Kind {
+	case NodeUnreachable:
+		faultType = fault_tolerance.FaultTypeNodeFailure
+		description = "injected fault: node unreachable"
+		injection.Metadata["reachable"] = false
+	case HighLatency:
+		latency := req.Latency
+		if latency == 0 {
+			latency = DefaultLatency
+		}
+		if latency < 0 {
+			return Injection{}, fmt.Errorf("%w: negative latency", ErrInvalid)
+		}
+		faultType = fault_tolerance.FaultTypePerformanceAnomaly
+		description = fmt.Sprintf("injected fault: latency of %s", latency)
+		injection.Metadata["latency_ms"] = float64(latency.Milliseconds())
+	case ResourceExhaustion:
+		resource, usage := req.Resource, req.Usage
+		if resource == "" {
+			resource = DefaultResource
+		}
+		if usage == 0 {
+			usage = DefaultUsage
+		}
+		if usage < 0 || usage > 1 {
+			return Injection{}, fmt.Errorf("%w: usage must be a ratio between 0 and 1", ErrInvalid)
+		}
+		faultType = fault_tolerance.FaultTypeResourceExhaustion
+		description = fmt.Sprintf("injected fault: %s at %.0f%% usage", resource, usage*100)
+		injection.Metadata["resource"] = resource
+		injection.Metadata["usage"] = usage
+	default:
+		return Injection{}, fmt.Errorf("%w: unknown kind %q", ErrInvalid, req.Kind)
+	}
+
+	fault := inj.manager.DetectFault(faultType, req.Target, description, injection.Metadata)
+	if fault != nil {
+		injection.FaultID = fault.ID
+	}
+
+	inj.mu.Lock()
+	inj.injections[injection.ID] = injection
+	inj.mu.Unlock()
+
+	slog.Warn("fault injected", "injection_id", injection.ID, "kind", injection.Kind,
+		"target", injection.Target, "expires_at", injection.ExpiresAt)
+	return *injection, nil
+}
+
+// Injections returns the active injections, oldest first
+func (inj *Injector) Injections() []Injection {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	injections := make([]Injection, 0, len(inj.injections))
+	for _, injection := range inj.injections {
+		injections = append(injections, *injection)
+	}
+	sort.Slice(injections, func(i, j int) bool {
+		if !injections[i].StartedAt.Equal(injections[j].StartedAt) {
+			return injections[i].StartedAt.Before(injections[j].StartedAt)
+		}
+		return injections[i].ID < injections[j].ID
+	})
+	return injections
+}
+
+// Clear ends an injection before it expires, resolving its fault
+func (inj *Injector) Clear(id string) (Injection, bool) {
+	inj.mu.Lock()
+	injection, ok := inj.injections[id]
+	delete(inj.injections, id)
+	inj.mu.Unlock()
+
+	if !ok {
+		return Injection{}, false
+	}
+	inj.resolve(injection, "cleared")
+	return *injection, true
+}
+
+// Expire resolves the faults of the injections that have expired,
+// returning them
+func (inj *Injector) Expire() []Injection {
+	now := inj.now()
+
+	inj.mu.Lock()
+	var expired []*Injection
+	for id, injection := range inj.injections {
+		if !now.Before(injection.ExpiresAt) {
+			expired = append(expired, injection)
+			delete(inj.injections, id)
+		}
+	}
+	inj.mu.Unlock()
+
+	result := make([]Injection, 0, len(expired))
+	for _, injection := range expired {
+		inj.resolve(injection, "expired")
+		result = append(result, *injection)
+	}
+	return result
+}
+
+// Run expires injections every interval until ctx is done
+func (inj *Injector) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			inj.Expire()
+		}
+	}
+}
+
+func (inj *Injector) resolve(injection *Injection, reason string) {
+	if injection.FaultID != "" {
+		inj.manager.ResolveFault(injection.FaultID)
+	}
+	slog.Info("injected fault ended", "injection_id", injection.ID, "kind", injection.Kind,
+		"target", injection.Target, "reason", reason)
+}
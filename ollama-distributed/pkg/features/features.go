@@ -0,0 +1,110 @@
+// Package features holds feature flags that turn experimental capabilities,
+// such as new partition strategies or QUIC stream routing, on and off at
+// runtime. A flag is enabled everywhere, on chosen nodes, or for a
+// percentage of the traffic, and is shared with every node through
+// consensus so that all of them agree on its state.
+package features
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"time"
+)
+
+// Flags of the capabilities gated in this tree
+const (
+	// QUICTransport routes the QUIC-preferred protocols over QUIC
+	QUICTransport = "quic_transport"
+)
+
+// DefaultSyncInterval is how often flags are read back from consensus
+const DefaultSyncInterval = 10 * time.Second
+
+// StrategyFlag returns the name of the flag gating a partition strategy.
+// Strategies without a flag are not gated.
+func StrategyFlag(strategy string) string {
+	return "partition_strategy." + strategy
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// Config configures feature flags
+type Config struct {
+	Enabled      bool          `yaml:"enabled"`
+	SyncInterval time.Duration `yaml:"sync_interval" mapstructure:"sync_interval"`
+	// Flags are the initial flags, until flags are set through the API
+	Flags []Flag `yaml:"flags"`
+}
+
+// Validate checks the flags and the interval
+func (c Config) Validate() error {
+	if c.SyncInterval < 0 {
+		return fmt.Errorf("negative sync interval")
+	}
+	names := make(map[string]bool)
+	for _, f := range c.Flags {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate feature flag %q", f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// Flag is the state of a feature flag. A node override wins over the rest;
+// otherwise the flag is on when Enabled, or for Percentage of the traffic.
+type Flag struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Enabled     bool   `yaml:"enabled" json:"enabled"`
+	// Percentage, from 0 to 100, is the share of requests, or of nodes for
+	// capabilities not tied to requests, the flag is on for. Raising it
+	// keeps it on for those it was on for.
+	Percentage float64 `yaml:"percentage" json:"percentage,omitempty"`
+	// Nodes turns the flag on or off on single nodes, by node ID
+	Nodes     map[string]bool `yaml:"nodes" json:"nodes,omitempty"`
+	UpdatedAt time.Time       `yaml:"-" json:"updated_at,omitempty"`
+}
+
+// Validate checks the name and percentage
+func (f Flag) Validate() error {
+	if !validName.MatchString(f.Name) {
+		return fmt.Errorf("invalid feature flag name %q", f.Name)
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("feature flag %s: percentage must be between 0 and 100", f.Name)
+	}
+	return nil
+}
+
+// On reports whether the flag is on for a node and a request key, e.g. a
+// request or user ID. Without a key, the node ID picks the percentage
+// bucket.
+func (f Flag) On(nodeID, key string) bool {
+	if on, ok := f.Nodes[nodeID]; ok {
+		return on
+	}
+	if f.Enabled {
+		return true
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	if key == "" {
+		key = nodeID
+	}
+	return bucket(f.Name, key) < f.Percentage
+}
+
+// bucket places a key of a flag in [0, 100), the same for every node
+func bucket(flag, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return float64(h.Sum32()%10000) / 100
+}
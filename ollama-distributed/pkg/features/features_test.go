@@ -0,0 +1,155 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus/watch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a consensus store round-tripping values through JSON, as
+// the replicated log does
+type memoryStore struct {
+	leader bool
+	state  map[string][]byte
+}
+
+func (s *memoryStore) Apply(key string, value interface{}, _ map[string]interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.state[key] = data
+	return nil
+}
+
+func (s *memoryStore) Get(key string) (interface{}, bool) {
+	data, ok := s.state[key]
+	if !ok {
+		return nil, false
+	}
+	var value interface{}
+	_ = json.Unmarshal(data, &value)
+	return value, true
+}
+
+func (s *memoryStore) IsLeader() bool { return s.leader }
+
+// watchingStore is a memory store pushing its changes to watchers
+type watchingStore struct {
+	mu       sync.Mutex
+	store    memoryStore
+	hub      *watch.Hub
+	revision uint64
+}
+
+func (s *watchingStore) Apply(key string, value interface{}, metadata map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.Apply(key, value, metadata); err != nil {
+		return err
+	}
+	s.revision++
+	s.hub.Publish(watch.Event{Revision: s.revision, Type: "set", Key: key})
+	return nil
+}
+
+func (s *watchingStore) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Get(key)
+}
+
+func (s *watchingStore) IsLeader() bool { return s.store.leader }
+
+func (s *watchingStore) Watch(ctx context.Context, prefix string, fromRevision uint64) (<-chan watch.Event, error) {
+	return s.hub.Watch(ctx, prefix, fromRevision)
+}
+
+func TestFlagOn(t *testing.T) {
+	assert.True(t, Flag{Name: "x", Enabled: true}.On("node-1", ""))
+	assert.False(t, Flag{Name: "x"}.On("node-1", "req"))
+	assert.False(t, Flag{Name: "x", Enabled: true, Nodes: map[string]bool{"node-1": false}}.On("node-1", ""))
+	assert.True(t, Flag{Name: "x", Nodes: map[string]bool{"node-1": true}}.On("node-1", "req"))
+
+	// A percentage rollout reaches about that share of keys, and raising
+	// it keeps the keys it already reached
+	quarter := Flag{Name: "x", Percentage: 25}
+	half := Flag{Name: "x", Percentage: 50}
+	on := 0
+	for i := range 10000 {
+		key := fmt.Sprintf("req-%d", i)
+		if quarter.On("node-1", key) {
+			on++
+			assert.True(t, half.On("node-1", key))
+		}
+	}
+	assert.InDelta(t, 2500, on, 200)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Flags: []Flag{{Name: StrategyFlag("data_split"), Percentage: 10}}}.Validate())
+	assert.Error(t, Config{Flags: []Flag{{Name: "Bad Name"}}}.Validate())
+	assert.Error(t, Config{Flags: []Flag{{Name: "x", Percentage: 101}}}.Validate())
+	assert.Error(t, Config{Flags: []Flag{{Name: "x"}, {Name: "x"}}}.Validate())
+}
+
+func TestManagerSharesFlags(t *testing.T) {
+	store := &memoryStore{leader: true, state: make(map[string][]byte)}
+	cfg := Config{Flags: []Flag{{Name: QUICTransport, Enabled: true}}}
+	leader, err := NewManager(cfg, "node-1", store)
+	require.NoError(t, err)
+	follower, err := NewManager(Config{}, "node-2", &memoryStore{state: store.state})
+	require.NoError(t, err)
+
+	// The leader shares the configured flags
+	require.NoError(t, leader.Sync())
+	require.NoError(t, follower.Sync())
+	assert.True(t, follower.Enabled(QUICTransport, ""))
+
+	// Changes are made on the leader and reach the followers
+	require.NoError(t, leader.Set(Flag{Name: StrategyFlag("data_split"), Nodes: map[string]bool{"node-2": true}}))
+	assert.ErrorIs(t, follower.Set(Flag{Name: "other"}), ErrNotLeader)
+	require.NoError(t, follower.Sync())
+	assert.True(t, follower.Enabled(StrategyFlag("data_split"), "req"))
+	assert.False(t, leader.Enabled(StrategyFlag("data_split"), "req"))
+	assert.Equal(t, map[string]bool{QUICTransport: true, StrategyFlag("data_split"): true}, follower.Status())
+
+	found, err := leader.Delete(QUICTransport)
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.NoError(t, follower.Sync())
+	assert.False(t, follower.Enabled(QUICTransport, ""))
+	assert.Len(t, follower.Flags(), 1)
+
+	// Capabilities without a flag are allowed, and a nil manager allows all
+	assert.True(t, follower.Allows(StrategyFlag("layerwise"), "req"))
+	assert.False(t, leader.Allows(StrategyFlag("data_split"), "req"))
+	var none *Manager
+	assert.True(t, none.Allows(QUICTransport, ""))
+	assert.False(t, none.Enabled(QUICTransport, ""))
+}
+
+func TestManagerSyncsOnChange(t *testing.T) {
+	store := &watchingStore{store: memoryStore{leader: true, state: make(map[string][]byte)}, hub: watch.NewHub(0, 0)}
+	leader, err := NewManager(Config{}, "node-1", store)
+	require.NoError(t, err)
+	follower, err := NewManager(Config{}, "node-2", store)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go follower.Run(ctx, time.Hour)
+
+	// The change arrives long before the next interval
+	require.Eventually(t, func() bool {
+		_ = leader.Set(Flag{Name: QUICTransport, Enabled: true})
+		return follower.Enabled(QUICTransport, "")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,269 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus/watch"
+)
+
+// flagsKey is the consensus key holding every flag
+const flagsKey = "feature_flags"
+
+// ErrNotLeader is returned when flags are changed on a node other than the
+// consensus leader
+var ErrNotLeader = errors.New("feature flags can only be changed on the leader")
+
+// Store is the replicated state flags are shared through, i.e. the
+// consensus engine
+type Store interface {
+	Apply(key string, value interface{}, metadata map[string]interface{}) error
+	Get(key string) (interface{}, bool)
+	IsLeader() bool
+}
+
+// Watcher is implemented by stores that push changes, so that flags sync as
+// soon as they change rather than on the next interval
+type Watcher interface {
+	Watch(ctx context.Context, prefix string, fromRevision uint64) (<-chan watch.Event, error)
+}
+
+// Manager evaluates feature flags on a node and changes them through
+// consensus
+type Manager struct {
+	nodeID string
+	store  Store
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+	// shared is whether the flags were read from the store, which then
+	// holds them all
+	shared bool
+}
+
+// NewManager creates a manager starting with the configured flags. Without
+// a store, flags are local to the node.
+func NewManager(cfg Config, nodeID string, store Store) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		nodeID: nodeID,
+		store:  store,
+		now:    time.Now,
+		flags:  make(map[string]Flag),
+	}
+	for _, f := range cfg.Flags {
+		m.flags[f.Name] = f
+	}
+	return m, nil
+}
+
+// NodeID returns the ID of the node flags are evaluated for
+func (m *Manager) NodeID() string {
+	return m.nodeID
+}
+
+// Flags returns the flags sorted by name
+func (m *Manager) Flags() []Flag {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(m.flags))
+	for _, f := range m.flags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// Flag returns a flag
+func (m *Manager) Flag(name string) (Flag, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.flags[name]
+	return f, ok
+}
+
+// Enabled reports whether a flag is on on this node for a request key, see
+// Flag.On. Unknown flags are off, as are all flags of a nil manager.
+func (m *Manager) Enabled(name, key string) bool {
+	if m == nil {
+		return false
+	}
+	f, ok := m.Flag(name)
+	return ok && f.On(m.nodeID, key)
+}
+
+// Allows is like Enabled, except that capabilities without a flag are
+// allowed, so that only capabilities being rolled out need one
+func (m *Manager) Allows(name, key string) bool {
+	if m == nil {
+		return true
+	}
+	f, ok := m.Flag(name)
+	return !ok || f.On(m.nodeID, key)
+}
+
+// Status returns whether each flag is on on this node, without a request
+// key
+func (m *Manager) Status() map[string]bool {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := make(map[string]bool, len(m.flags))
+	for name, f := range m.flags {
+		status[name] = f.On(m.nodeID, "")
+	}
+	return status
+}
+
+// Set creates or replaces a flag and shares it with the cluster
+func (m *Manager) Set(flag Flag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+	flag.UpdatedAt = m.now()
+	return m.update(func(flags map[string]Flag) bool {
+		flags[flag.Name] = flag
+		return true
+	})
+}
+
+// Delete removes a flag, turning it off everywhere, and reports whether it
+// existed
+func (m *Manager) Delete(name string) (bool, error) {
+	found := false
+	err := m.update(func(flags map[string]Flag) bool {
+		_, found = flags[name]
+		delete(flags, name)
+		return found
+	})
+	return found, err
+}
+
+// update changes a copy of the flags and, if fn reports a change, applies
+// it through the store before keeping it
+func (m *Manager) update(fn func(map[string]Flag) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flags := make(map[string]Flag, len(m.flags))
+	for name, f := range m.flags {
+		flags[name] = f
+	}
+	if !fn(flags) {
+		return nil
+	}
+
+	if m.store != nil {
+		if !m.store.IsLeader() {
+			return ErrNotLeader
+		}
+		if err := m.store.Apply(flagsKey, flags, nil); err != nil {
+			return fmt.Errorf("failed to share feature flags: %w", err)
+		}
+		m.shared = true
+	}
+	m.flags = flags
+	return nil
+}
+
+// Sync reads the flags agreed through consensus. Until any are, the leader
+// shares the configured ones.
+func (m *Manager) Sync() error {
+	if m.store == nil {
+		return nil
+	}
+
+	value, exists := m.store.Get(flagsKey)
+	if !exists {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.shared || !m.store.IsLeader() {
+			return nil
+		}
+		if err := m.store.Apply(flagsKey, m.flags, nil); err != nil {
+			return fmt.Errorf("failed to share feature flags: %w", err)
+		}
+		m.shared = true
+		return nil
+	}
+
+	// Values arrive decoded from the replicated log, so they are converted
+	// via JSON
+	flags, ok := value.(map[string]Flag)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to decode feature flags: %w", err)
+		}
+		if err := json.Unmarshal(data, &flags); err != nil {
+			return fmt.Errorf("failed to decode feature flags: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags = flags
+	if m.flags == nil {
+		m.flags = make(map[string]Flag)
+	}
+	m.shared = true
+	return nil
+}
+
+// Run syncs the flags every interval, and whenever they change with a
+// store that is a Watcher, until the context is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var changes <-chan watch.Event
+	for {
+		// Watching again before syncing misses no change
+		if changes == nil {
+			changes = m.watch(ctx)
+		}
+		if err := m.Sync(); err != nil {
+			slog.Warn("Failed to sync feature flags", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+			}
+		}
+	}
+}
+
+// watch returns the changes of the flags, or nil when the store does not
+// push them
+func (m *Manager) watch(ctx context.Context) <-chan watch.Event {
+	watcher, ok := m.store.(Watcher)
+	if !ok {
+		return nil
+	}
+	changes, err := watcher.Watch(ctx, flagsKey, 0)
+	if err != nil {
+		slog.Warn("Failed to watch feature flags", "error", err)
+		return nil
+	}
+	return changes
+}
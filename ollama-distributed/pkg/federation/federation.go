@@ -0,0 +1,409 @@
+// Package federation lets independent clusters peer with each other. Peers
+// exchange manifests of the models they serve and their spare capacity, and
+// a cluster that is overloaded, or lacks a model, can forward inference
+// requests to a peer within the limits of its overflow policy.
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers sent with federation requests
+const (
+	// ClusterHeader names the calling cluster; together with the bearer
+	// token it identifies the peer
+	ClusterHeader = "X-Federation-Cluster"
+	// HopHeader marks forwarded requests, which are never forwarded again
+	HopHeader = "X-Federation-Hop"
+)
+
+// defaultExchangeInterval is how often manifests are exchanged when no
+// interval is configured
+const defaultExchangeInterval = 30 * time.Second
+
+// Config configures federation with other clusters
+type Config struct {
+	Enabled   bool   `yaml:"enabled"`
+	ClusterID string `yaml:"cluster_id" mapstructure:"cluster_id"` // this cluster's name, as configured on its peers
+	Region    string `yaml:"region"`
+
+	// How often manifests are exchanged with every peer; a peer is offline
+	// after three intervals without an exchange
+	ExchangeInterval time.Duration `yaml:"exchange_interval" mapstructure:"exchange_interval"`
+
+	Peers    []PeerConfig   `yaml:"peers"`
+	Overflow OverflowPolicy `yaml:"overflow"`
+}
+
+// PeerConfig describes a peer cluster. Name must be the peer's ClusterID.
+// Both clusters configure the same token, which authenticates the calls
+// either of them makes to the other.
+type PeerConfig struct {
+	Name  string `yaml:"name"`
+	URL   string `yaml:"url"` // base URL of the peer's API server
+	Token string `yaml:"token"`
+}
+
+// Model is a model served by a cluster
+type Model struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// Capacity summarises how busy a cluster is
+type Capacity struct {
+	Nodes      int   `json:"nodes"`
+	QueueDepth int64 `json:"queue_depth"`
+	Accepting  bool  `json:"accepting"` // whether it takes overflow from peers
+}
+
+// Manifest is what a cluster tells its peers about itself
+type Manifest struct {
+	ClusterID string    `json:"cluster_id"`
+	Region    string    `json:"region,omitempty"`
+	Models    []Model   `json:"models"`
+	Capacity  Capacity  `json:"capacity"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Source describes the local cluster. Capacity need not set Accepting,
+// which follows the overflow policy.
+type Source interface {
+	Models() []Model
+	Capacity() Capacity
+}
+
+// PeerStatus is the state of a peer as seen from this cluster
+type PeerStatus struct {
+	Name          string    `json:"name"`
+	URL           string    `json:"url"`
+	Region        string    `json:"region,omitempty"`
+	Online        bool      `json:"online"`
+	LastSeen      time.Time `json:"last_seen"`
+	LastError     string    `json:"last_error,omitempty"`
+	Models        []Model   `json:"models"`
+	Capacity      Capacity  `json:"capacity"`
+	InFlight      int64     `json:"in_flight"`
+	Forwarded     int64     `json:"forwarded"`
+	ForwardErrors int64     `json:"forward_errors"`
+	Received      int64     `json:"received"`
+}
+
+// peer is a configured peer and what was learnt about it
+type peer struct {
+	config PeerConfig
+
+	mu        sync.RWMutex
+	manifest  *Manifest
+	lastSeen  time.Time
+	lastError string
+
+	inFlight      atomic.Int64 // requests forwarded to it and not finished
+	forwarded     atomic.Int64
+	forwardErrors atomic.Int64
+	received      atomic.Int64 // requests it forwarded to us
+}
+
+// Federation peers this cluster with other clusters
+type Federation struct {
+	config Config
+	source Source
+	peers  map[string]*peer
+	order  []string // peer names in configuration order
+	client *http.Client
+	logger *slog.Logger
+
+	inbound atomic.Int64 // forwarded requests being served for peers
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewFederation creates a federation of this cluster with the configured peers
+func NewFederation(config Config, source Source, logger *slog.Logger) (*Federation, error) {
+	if config.ClusterID == "" {
+		return nil, fmt.Errorf("federation requires a cluster ID")
+	}
+	if config.ExchangeInterval <= 0 {
+		config.ExchangeInterval = defaultExchangeInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	f := &Federation{
+		config: config,
+		source: source,
+		peers:  make(map[string]*peer),
+		// No overall timeout: forwarded generations stream for as long
+		// as they take and are bounded by the caller's context
+		client: &http.Client{},
+		logger: logger,
+	}
+	for _, pc := range config.Peers {
+		if pc.Name == "" || pc.URL == "" {
+			return nil, fmt.Errorf("federation peer needs a name and a URL")
+		}
+		if pc.Name == config.ClusterID {
+			return nil, fmt.Errorf("federation peer %q has this cluster's ID", pc.Name)
+		}
+		if _, exists := f.peers[pc.Name]; exists {
+			return nil, fmt.Errorf("duplicate federation peer %q", pc.Name)
+		}
+		pc.URL = strings.TrimSuffix(pc.URL, "/")
+		f.peers[pc.Name] = &peer{config: pc}
+		f.order = append(f.order, pc.Name)
+	}
+	return f, nil
+}
+
+// ClusterID returns this cluster's ID
+func (f *Federation) ClusterID() string {
+	return f.config.ClusterID
+}
+
+// Region returns this cluster's region
+func (f *Federation) Region() string {
+	return f.config.Region
+}
+
+// Start exchanges manifests with every peer now and then periodically
+func (f *Federation) Start(ctx context.Context) {
+	ctx, f.cancel = context.WithCancel(ctx)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.config.ExchangeInterval)
+		defer ticker.Stop()
+		for {
+			f.ExchangeAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the manifest exchange
+func (f *Federation) Stop() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.wg.Wait()
+}
+
+// LocalManifest returns the manifest this cluster sends to its peers. Only
+// models the overflow policy shares are listed.
+func (f *Federation) LocalManifest() Manifest {
+	manifest := Manifest{
+		ClusterID: f.config.ClusterID,
+		Region:    f.config.Region,
+		Models:    []Model{},
+		UpdatedAt: time.Now(),
+	}
+	if f.source != nil {
+		for _, m := range f.source.Models() {
+			if f.config.Overflow.Shares(m.Name) {
+				manifest.Models = append(manifest.Models, m)
+			}
+		}
+		manifest.Capacity = f.source.Capacity()
+	}
+	// Overflow is not taken while this cluster would itself forward it
+	threshold := f.config.Overflow.QueueThreshold
+	manifest.Capacity.Accepting = f.acceptsInbound() &&
+		(threshold <= 0 || manifest.Capacity.QueueDepth < threshold)
+	return manifest
+}
+
+// ExchangeAll exchanges manifests with every peer concurrently
+func (f *Federation) ExchangeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, name := range f.order {
+		p := f.peers[name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.exchange(ctx, p); err != nil && ctx.Err() == nil {
+				f.logger.Warn("federation manifest exchange failed", "peer", p.config.Name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// exchange sends the local manifest to a peer and stores the peer's reply
+func (f *Federation) exchange(ctx context.Context, p *peer) error {
+	body, err := json.Marshal(f.LocalManifest())
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL+"/api/v1/federation/manifest", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	f.authorize(req, p)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		p.recordError(err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := responseError("manifest exchange", resp)
+		p.recordError(err)
+		return err
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		err = fmt.Errorf("failed to decode manifest: %w", err)
+		p.recordError(err)
+		return err
+	}
+	if manifest.ClusterID != p.config.Name {
+		err := fmt.Errorf("peer answered as cluster %q", manifest.ClusterID)
+		p.recordError(err)
+		return err
+	}
+	p.recordManifest(&manifest)
+	return nil
+}
+
+// ReceiveManifest stores a manifest sent by an authenticated peer and
+// returns the local one in reply
+func (f *Federation) ReceiveManifest(peerName string, manifest Manifest) (Manifest, error) {
+	p, ok := f.peers[peerName]
+	if !ok {
+		return Manifest{}, fmt.Errorf("unknown federation peer %q", peerName)
+	}
+	if manifest.ClusterID != peerName {
+		return Manifest{}, fmt.Errorf("manifest of cluster %q sent by peer %q", manifest.ClusterID, peerName)
+	}
+	p.recordManifest(&manifest)
+	return f.LocalManifest(), nil
+}
+
+// Authenticate returns the peer a request comes from, checking the cluster
+// header against the bearer token configured for that peer
+func (f *Federation) Authenticate(r *http.Request) (string, bool) {
+	name := r.Header.Get(ClusterHeader)
+	p, ok := f.peers[name]
+	if !ok || p.config.Token == "" {
+		return "", false
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(p.config.Token)) != 1 {
+		return "", false
+	}
+	return name, true
+}
+
+// authorize adds the headers identifying this cluster to a peer
+func (f *Federation) authorize(req *http.Request, p *peer) {
+	req.Header.Set(ClusterHeader, f.config.ClusterID)
+	if p.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.Token)
+	}
+}
+
+// Peers returns the state of every peer in configuration order
+func (f *Federation) Peers() []PeerStatus {
+	statuses := make([]PeerStatus, 0, len(f.order))
+	for _, name := range f.order {
+		statuses = append(statuses, f.peers[name].status(f.staleAfter()))
+	}
+	return statuses
+}
+
+// Catalog returns, for every model served by an online peer, the peers
+// serving it
+func (f *Federation) Catalog() map[string][]string {
+	catalog := make(map[string][]string)
+	for _, status := range f.Peers() {
+		if !status.Online {
+			continue
+		}
+		for _, m := range status.Models {
+			catalog[m.Name] = append(catalog[m.Name], status.Name)
+		}
+	}
+	for _, peers := range catalog {
+		sort.Strings(peers)
+	}
+	return catalog
+}
+
+// staleAfter is how long a peer stays online without an exchange
+func (f *Federation) staleAfter() time.Duration {
+	return 3 * f.config.ExchangeInterval
+}
+
+func (p *peer) recordManifest(manifest *Manifest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.manifest = manifest
+	p.lastSeen = time.Now()
+	p.lastError = ""
+}
+
+func (p *peer) recordError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastError = err.Error()
+}
+
+func (p *peer) status(staleAfter time.Duration) PeerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := PeerStatus{
+		Name:          p.config.Name,
+		URL:           p.config.URL,
+		Online:        !p.lastSeen.IsZero() && time.Since(p.lastSeen) < staleAfter,
+		LastSeen:      p.lastSeen,
+		LastError:     p.lastError,
+		Models:        []Model{},
+		InFlight:      p.inFlight.Load(),
+		Forwarded:     p.forwarded.Load(),
+		ForwardErrors: p.forwardErrors.Load(),
+		Received:      p.received.Load(),
+	}
+	if p.manifest != nil {
+		status.Region = p.manifest.Region
+		status.Models = p.manifest.Models
+		status.Capacity = p.manifest.Capacity
+	}
+	return status
+}
+
+// responseError describes a failed call to a peer, including the start of
+// the response body
+func responseError(operation string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		return fmt.Errorf("%s failed: %s", operation, resp.Status)
+	}
+	return fmt.Errorf("%s failed: %s: %s", operation, resp.Status, message)
+}
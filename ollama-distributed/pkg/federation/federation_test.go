@@ -0,0 +1,184 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSource struct {
+	models   []Model
+	capacity Capacity
+}
+
+func (s *staticSource) Models() []Model    { return s.models }
+func (s *staticSource) Capacity() Capacity { return s.capacity }
+
+// peerHandler serves the federation endpoints the way the API server does
+func peerHandler(f *Federation) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/federation/manifest", func(w http.ResponseWriter, r *http.Request) {
+		peerName, ok := f.Authenticate(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var manifest Manifest
+		json.NewDecoder(r.Body).Decode(&manifest)
+		reply, err := f.ReceiveManifest(peerName, manifest)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(reply)
+	})
+	mux.HandleFunc("/api/v1/federation/forward/generate", func(w http.ResponseWriter, r *http.Request) {
+		peerName, ok := f.Authenticate(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		release, err := f.AcceptForward(peerName, req.Model)
+		if err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		defer release()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"response": "served by " + f.ClusterID()})
+	})
+	return mux
+}
+
+func newPeers(t *testing.T, eastPolicy, westPolicy OverflowPolicy) (*Federation, *Federation) {
+	var east, west *Federation
+	eastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peerHandler(east).ServeHTTP(w, r)
+	}))
+	t.Cleanup(eastServer.Close)
+	westServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peerHandler(west).ServeHTTP(w, r)
+	}))
+	t.Cleanup(westServer.Close)
+
+	var err error
+	east, err = NewFederation(Config{
+		ClusterID: "east", Region: "us-east-1",
+		Peers:    []PeerConfig{{Name: "west", URL: westServer.URL, Token: "shared"}},
+		Overflow: eastPolicy,
+	}, &staticSource{models: []Model{{Name: "llama3"}}, capacity: Capacity{Nodes: 2, QueueDepth: 12}}, nil)
+	require.NoError(t, err)
+	west, err = NewFederation(Config{
+		ClusterID: "west", Region: "us-west-2",
+		Peers:    []PeerConfig{{Name: "east", URL: eastServer.URL, Token: "shared"}},
+		Overflow: westPolicy,
+	}, &staticSource{models: []Model{{Name: "llama3"}, {Name: "mixtral"}, {Name: "internal-finetune"}}, capacity: Capacity{Nodes: 4}}, nil)
+	require.NoError(t, err)
+	return east, west
+}
+
+func TestExchangeSharesManifests(t *testing.T) {
+	east, west := newPeers(t,
+		OverflowPolicy{Enabled: true},
+		OverflowPolicy{AcceptInbound: true, DenyModels: []string{"internal-*"}})
+
+	east.ExchangeAll(context.Background())
+
+	eastView := east.Peers()
+	require.Len(t, eastView, 1)
+	assert.True(t, eastView[0].Online)
+	assert.Equal(t, "us-west-2", eastView[0].Region)
+	assert.Equal(t, []Model{{Name: "llama3"}, {Name: "mixtral"}}, eastView[0].Models, "denied models are not advertised")
+	assert.True(t, eastView[0].Capacity.Accepting)
+
+	westView := west.Peers()
+	require.Len(t, westView, 1)
+	assert.True(t, westView[0].Online, "the receiving side learns the caller's manifest too")
+	assert.False(t, westView[0].Capacity.Accepting)
+
+	assert.Equal(t, map[string][]string{"llama3": {"west"}, "mixtral": {"west"}}, east.Catalog())
+}
+
+func TestForwardOverflowToPeer(t *testing.T) {
+	east, west := newPeers(t,
+		OverflowPolicy{Enabled: true, QueueThreshold: 10, ForwardMissing: true},
+		OverflowPolicy{AcceptInbound: true})
+	east.ExchangeAll(context.Background())
+
+	reason, ok := east.ShouldForward("llama3", 12, true)
+	assert.True(t, ok)
+	assert.Equal(t, ReasonOverflow, reason)
+	_, ok = east.ShouldForward("llama3", 3, true)
+	assert.False(t, ok)
+	reason, ok = east.ShouldForward("mixtral", 0, false)
+	assert.True(t, ok)
+	assert.Equal(t, ReasonMissing, reason)
+
+	rec := httptest.NewRecorder()
+	peerName, err := east.Forward(context.Background(), rec, "generate", "mixtral", []byte(`{"model":"mixtral"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "west", peerName)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "served by west")
+	assert.Equal(t, int64(1), east.Peers()[0].Forwarded)
+	assert.Equal(t, int64(1), west.Peers()[0].Received)
+	assert.Equal(t, int64(0), east.Peers()[0].InFlight)
+
+	_, err = east.Forward(context.Background(), httptest.NewRecorder(), "generate", "phi3", []byte(`{"model":"phi3"}`))
+	assert.ErrorIs(t, err, ErrNoPeer)
+}
+
+func TestForwardRefusedByPeerPolicy(t *testing.T) {
+	east, west := newPeers(t,
+		OverflowPolicy{Enabled: true, ForwardMissing: true},
+		OverflowPolicy{AcceptInbound: false})
+	east.ExchangeAll(context.Background())
+	west.ExchangeAll(context.Background())
+
+	_, err := east.Forward(context.Background(), httptest.NewRecorder(), "generate", "mixtral", []byte(`{"model":"mixtral"}`))
+	assert.ErrorIs(t, err, ErrNoPeer, "a peer that does not accept overflow is not picked")
+
+	_, err = west.AcceptForward("east", "llama3")
+	assert.ErrorIs(t, err, ErrInboundDisabled)
+}
+
+func TestAuthenticateChecksPeerToken(t *testing.T) {
+	east, _ := newPeers(t, OverflowPolicy{}, OverflowPolicy{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/federation/manifest", nil)
+	req.Header.Set(ClusterHeader, "west")
+	req.Header.Set("Authorization", "Bearer wrong")
+	_, ok := east.Authenticate(req)
+	assert.False(t, ok)
+
+	req.Header.Set("Authorization", "Bearer shared")
+	peerName, ok := east.Authenticate(req)
+	assert.True(t, ok)
+	assert.Equal(t, "west", peerName)
+
+	req.Header.Set(ClusterHeader, "north")
+	_, ok = east.Authenticate(req)
+	assert.False(t, ok)
+}
+
+func TestAcceptForwardLimitsInbound(t *testing.T) {
+	_, west := newPeers(t, OverflowPolicy{}, OverflowPolicy{AcceptInbound: true, MaxInbound: 1})
+
+	release, err := west.AcceptForward("east", "llama3")
+	require.NoError(t, err)
+	_, err = west.AcceptForward("east", "llama3")
+	assert.ErrorIs(t, err, ErrInboundBusy)
+	release()
+	release, err = west.AcceptForward("east", "llama3")
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,220 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+)
+
+// Reasons for forwarding a request to a peer
+const (
+	ReasonOverflow = "overflow" // the local queue is too deep
+	ReasonMissing  = "missing"  // no local node serves the model
+)
+
+// Errors returned when a peer's forwarded request is refused
+var (
+	ErrInboundDisabled = errors.New("this cluster does not accept forwarded requests")
+	ErrModelNotShared  = errors.New("model is not shared with federation peers")
+	ErrInboundBusy     = errors.New("too many forwarded requests in progress")
+	ErrNoPeer          = errors.New("no federation peer can serve the model")
+)
+
+// OverflowPolicy controls which requests are forwarded to peers and which
+// forwarded requests are accepted from them
+type OverflowPolicy struct {
+	// Enabled allows forwarding requests to peers
+	Enabled bool `yaml:"enabled"`
+	// QueueThreshold forwards requests once this many requests are queued
+	// locally; 0 never forwards because of load
+	QueueThreshold int64 `yaml:"queue_threshold" mapstructure:"queue_threshold"`
+	// ForwardMissing forwards requests for models only peers serve
+	ForwardMissing bool `yaml:"forward_missing" mapstructure:"forward_missing"`
+	// MaxPerPeer bounds the requests in flight to each peer; 0 is unbounded
+	MaxPerPeer int64 `yaml:"max_per_peer" mapstructure:"max_per_peer"`
+
+	// AcceptInbound serves requests forwarded by peers, at most MaxInbound
+	// at a time (0 is unbounded)
+	AcceptInbound bool  `yaml:"accept_inbound" mapstructure:"accept_inbound"`
+	MaxInbound    int64 `yaml:"max_inbound" mapstructure:"max_inbound"`
+
+	// Models matching AllowModels (all when empty) and not DenyModels are
+	// advertised to peers, forwarded and accepted. Patterns use path.Match
+	// syntax, e.g. "llama3*".
+	AllowModels []string `yaml:"allow_models" mapstructure:"allow_models"`
+	DenyModels  []string `yaml:"deny_models" mapstructure:"deny_models"`
+}
+
+// Shares reports whether a model may cross the federation boundary
+func (p OverflowPolicy) Shares(model string) bool {
+	for _, pattern := range p.DenyModels {
+		if matched, _ := path.Match(pattern, model); matched {
+			return false
+		}
+	}
+	if len(p.AllowModels) == 0 {
+		return true
+	}
+	for _, pattern := range p.AllowModels {
+		if matched, _ := path.Match(pattern, model); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldForward decides whether a request for model is forwarded, given the
+// local queue depth and whether a local node serves the model. It returns
+// the reason when it should.
+func (f *Federation) ShouldForward(model string, queueDepth int64, served bool) (string, bool) {
+	policy := f.config.Overflow
+	if !policy.Enabled || !policy.Shares(model) {
+		return "", false
+	}
+	if !served && policy.ForwardMissing {
+		return ReasonMissing, true
+	}
+	if policy.QueueThreshold > 0 && queueDepth >= policy.QueueThreshold {
+		return ReasonOverflow, true
+	}
+	return "", false
+}
+
+// selectPeer picks the online peer accepting overflow that serves model,
+// in region unless it is empty, preferring the shortest queue and then the
+// fewest requests in flight, and reserves a slot on it. The caller must
+// release the slot.
+func (f *Federation) selectPeer(model, region string) *peer {
+	var best *peer
+	var bestStatus PeerStatus
+	for _, name := range f.order {
+		p := f.peers[name]
+		status := p.status(f.staleAfter())
+		if !status.Online || !status.Capacity.Accepting || !servesModel(status.Models, model) {
+			continue
+		}
+		if region != "" && status.Region != region {
+			continue
+		}
+		if max := f.config.Overflow.MaxPerPeer; max > 0 && status.InFlight >= max {
+			continue
+		}
+		if best == nil || status.Capacity.QueueDepth < bestStatus.Capacity.QueueDepth ||
+			(status.Capacity.QueueDepth == bestStatus.Capacity.QueueDepth && status.InFlight < bestStatus.InFlight) {
+			best, bestStatus = p, status
+		}
+	}
+	if best != nil {
+		best.inFlight.Add(1)
+	}
+	return best
+}
+
+func servesModel(models []Model, name string) bool {
+	for _, m := range models {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Forward sends an inference request of the given kind ("generate", "chat"
+// or "embeddings") to a peer serving model and copies the response to w,
+// flushing as it streams. It returns the peer used. When an error is
+// returned nothing has been written to w, so the request can still be served
+// locally.
+func (f *Federation) Forward(ctx context.Context, w http.ResponseWriter, kind, model string, body []byte) (string, error) {
+	return f.ForwardToRegion(ctx, w, "", kind, model, body)
+}
+
+// ForwardToRegion is Forward restricted to the peers in region
+func (f *Federation) ForwardToRegion(ctx context.Context, w http.ResponseWriter, region, kind, model string, body []byte) (string, error) {
+	p := f.selectPeer(model, region)
+	if p == nil {
+		return "", ErrNoPeer
+	}
+	defer p.inFlight.Add(-1)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.config.URL+"/api/v1/federation/forward/"+kind, bytes.NewReader(body))
+	if err != nil {
+		return p.config.Name, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HopHeader, "1")
+	logging.PropagateRequestID(req)
+	f.authorize(req, p)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		p.forwardErrors.Add(1)
+		return p.config.Name, fmt.Errorf("failed to forward to peer %s: %w", p.config.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden {
+		p.forwardErrors.Add(1)
+		return p.config.Name, responseError("forward to peer "+p.config.Name, resp)
+	}
+
+	p.forwarded.Add(1)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set(ClusterHeader, p.config.Name)
+	w.WriteHeader(resp.StatusCode)
+	copyFlushing(w, resp.Body)
+	return p.config.Name, nil
+}
+
+// copyFlushing copies a possibly streamed response, flushing every chunk
+func copyFlushing(w http.ResponseWriter, r io.Reader) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// AcceptForward admits a request forwarded by a peer for model. The returned
+// function must be called when the request is done.
+func (f *Federation) AcceptForward(peerName, model string) (func(), error) {
+	if !f.acceptsInbound() {
+		return nil, ErrInboundDisabled
+	}
+	if !f.config.Overflow.Shares(model) {
+		return nil, ErrModelNotShared
+	}
+	if max := f.config.Overflow.MaxInbound; max > 0 && f.inbound.Add(1) > max {
+		f.inbound.Add(-1)
+		return nil, ErrInboundBusy
+	} else if max <= 0 {
+		f.inbound.Add(1)
+	}
+	if p, ok := f.peers[peerName]; ok {
+		p.received.Add(1)
+	}
+	return func() { f.inbound.Add(-1) }, nil
+}
+
+// acceptsInbound reports whether forwarded requests are accepted at all
+func (f *Federation) acceptsInbound() bool {
+	return f.config.Overflow.AcceptInbound
+}
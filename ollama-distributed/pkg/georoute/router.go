@@ -0,0 +1,376 @@
+// Package georoute picks the region an incoming request is served in. The
+// client's region comes from an explicit header or its IP address; healthy
+// regions are tried closest first, and manual override rules can pin
+// requests to a region.
+package georoute
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeader is the request header naming the client's region
+const DefaultHeader = "X-Client-Region"
+
+// ErrNoRegion is returned when a strict override names a region that cannot
+// serve the request
+var ErrNoRegion = errors.New("no healthy region may serve the request")
+
+// Config configures geo-aware routing
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Header names the client's region explicitly, e.g. when set by a CDN
+	// or the client itself; empty uses DefaultHeader
+	Header string `yaml:"header"`
+
+	// Networks maps client address ranges to regions; the most specific
+	// matching range wins
+	Networks []NetworkRegion `yaml:"networks"`
+
+	// Regions gives the location of regions, used to find the closest one.
+	// Regions without a location are compared by name, so that us-east-1
+	// is closer to us-east-2 than to eu-west-1.
+	Regions []RegionLocation `yaml:"regions"`
+
+	// Overrides pin matching requests to a region; the first match wins
+	Overrides []Override `yaml:"overrides"`
+}
+
+// NetworkRegion maps an address range to a region
+type NetworkRegion struct {
+	CIDR   string `yaml:"cidr" json:"cidr"`
+	Region string `yaml:"region" json:"region"`
+}
+
+// RegionLocation is where a region is
+type RegionLocation struct {
+	Name      string  `yaml:"name" json:"name"`
+	Latitude  float64 `yaml:"latitude" json:"latitude"`
+	Longitude float64 `yaml:"longitude" json:"longitude"`
+}
+
+// Override routes requests matching all of its set conditions to Region.
+// Patterns use path.Match syntax. Unless Strict, other regions remain as
+// fallbacks when Region is unhealthy; a strict override, e.g. for data
+// residency, fails the request instead.
+type Override struct {
+	Name         string `yaml:"name" json:"name"`
+	ClientRegion string `yaml:"client_region" mapstructure:"client_region" json:"client_region,omitempty"`
+	ClientCIDR   string `yaml:"client_cidr" mapstructure:"client_cidr" json:"client_cidr,omitempty"`
+	Model        string `yaml:"model" json:"model,omitempty"`
+	Region       string `yaml:"region" json:"region"`
+	Strict       bool   `yaml:"strict" json:"strict"`
+}
+
+// Client describes the origin of a request
+type Client struct {
+	IP     net.IP
+	Header string // value of the region header, if any
+	Model  string
+}
+
+// Target is a region the request may be served in
+type Target struct {
+	Region  string
+	Healthy bool
+}
+
+// Decision is where a request should go
+type Decision struct {
+	ClientRegion string   `json:"client_region,omitempty"`
+	Override     string   `json:"override,omitempty"` // name of the matching override
+	Strict       bool     `json:"strict,omitempty"`   // no region outside Regions may serve it
+	Regions      []string `json:"regions"`            // healthy regions to try, in order
+}
+
+// RegionStats counts the requests routed to a region
+type RegionStats struct {
+	Region    string        `json:"region"`
+	Requests  int64         `json:"requests"`
+	Fallbacks int64         `json:"fallbacks"` // served here after a closer region failed
+	Failures  int64         `json:"failures"`
+	Latency   time.Duration `json:"average_latency"`
+
+	totalLatency time.Duration
+	measured     int64
+}
+
+// Router routes requests to regions
+type Router struct {
+	header    string
+	networks  []network
+	locations map[string]RegionLocation
+
+	mu        sync.RWMutex
+	overrides []override
+	stats     map[string]*RegionStats
+}
+
+type network struct {
+	ipNet  *net.IPNet
+	region string
+}
+
+type override struct {
+	Override
+	ipNet *net.IPNet
+}
+
+// NewRouter creates a router
+func NewRouter(config Config) (*Router, error) {
+	r := &Router{
+		header:    config.Header,
+		locations: make(map[string]RegionLocation),
+		stats:     make(map[string]*RegionStats),
+	}
+	if r.header == "" {
+		r.header = DefaultHeader
+	}
+
+	for _, n := range config.Networks {
+		_, ipNet, err := net.ParseCIDR(n.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", n.CIDR, err)
+		}
+		if n.Region == "" {
+			return nil, fmt.Errorf("network %q has no region", n.CIDR)
+		}
+		r.networks = append(r.networks, network{ipNet: ipNet, region: n.Region})
+	}
+	// Most specific first
+	sort.SliceStable(r.networks, func(i, j int) bool {
+		onesI, _ := r.networks[i].ipNet.Mask.Size()
+		onesJ, _ := r.networks[j].ipNet.Mask.Size()
+		return onesI > onesJ
+	})
+
+	for _, loc := range config.Regions {
+		r.locations[loc.Name] = loc
+	}
+
+	if err := r.SetOverrides(config.Overrides); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Header returns the request header naming the client's region
+func (r *Router) Header() string {
+	return r.header
+}
+
+// Overrides returns the current override rules
+func (r *Router) Overrides() []Override {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]Override, len(r.overrides))
+	for i, o := range r.overrides {
+		rules[i] = o.Override
+	}
+	return rules
+}
+
+// SetOverrides replaces the override rules
+func (r *Router) SetOverrides(rules []Override) error {
+	parsed := make([]override, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Region == "" {
+			return fmt.Errorf("override %q has no region", rule.Name)
+		}
+		o := override{Override: rule}
+		if rule.ClientCIDR != "" {
+			_, ipNet, err := net.ParseCIDR(rule.ClientCIDR)
+			if err != nil {
+				return fmt.Errorf("override %q: invalid client_cidr: %w", rule.Name, err)
+			}
+			o.ipNet = ipNet
+		}
+		for _, pattern := range []string{rule.ClientRegion, rule.Model} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("override %q: invalid pattern %q", rule.Name, pattern)
+			}
+		}
+		parsed = append(parsed, o)
+	}
+
+	r.mu.Lock()
+	r.overrides = parsed
+	r.mu.Unlock()
+	return nil
+}
+
+// ClientRegion returns the region of a client: the header when set, else
+// the region of the most specific network containing its address
+func (r *Router) ClientRegion(client Client) string {
+	if region := strings.TrimSpace(client.Header); region != "" {
+		return region
+	}
+	if client.IP != nil {
+		for _, n := range r.networks {
+			if n.ipNet.Contains(client.IP) {
+				return n.region
+			}
+		}
+	}
+	return ""
+}
+
+// Route orders the healthy targets for a client, closest first. Targets
+// listed earlier win ties, so callers list the local region first. A
+// matching override puts its region first, or is the only choice if strict.
+func (r *Router) Route(client Client, targets []Target) (Decision, error) {
+	decision := Decision{ClientRegion: r.ClientRegion(client), Regions: []string{}}
+	from := decision.ClientRegion
+
+	var pinned *override
+	if o, ok := r.matchOverride(client, decision.ClientRegion); ok {
+		pinned = &o
+		decision.Override = o.Name
+		decision.Strict = o.Strict
+		from = o.Region
+	}
+
+	type candidate struct {
+		region   string
+		distance float64
+	}
+	var candidates []candidate
+	for _, t := range targets {
+		if !t.Healthy {
+			continue
+		}
+		if pinned != nil && pinned.Strict && t.Region != pinned.Region {
+			continue
+		}
+		candidates = append(candidates, candidate{region: t.Region, distance: r.distance(from, t.Region)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !seen[c.region] {
+			seen[c.region] = true
+			decision.Regions = append(decision.Regions, c.region)
+		}
+	}
+	if len(decision.Regions) == 0 && pinned != nil && pinned.Strict {
+		return decision, fmt.Errorf("%w: override %q pins it to %s", ErrNoRegion, pinned.Name, pinned.Region)
+	}
+	return decision, nil
+}
+
+func (r *Router) matchOverride(client Client, clientRegion string) (override, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, o := range r.overrides {
+		if o.ClientRegion != "" {
+			if matched, _ := path.Match(o.ClientRegion, clientRegion); !matched {
+				continue
+			}
+		}
+		if o.ipNet != nil && (client.IP == nil || !o.ipNet.Contains(client.IP)) {
+			continue
+		}
+		if o.Model != "" {
+			if matched, _ := path.Match(o.Model, client.Model); !matched {
+				continue
+			}
+		}
+		return o, true
+	}
+	return override{}, false
+}
+
+// distance estimates how far apart two regions are in kilometres. Regions
+// with locations use the great-circle distance. Otherwise names sharing
+// more dash-separated parts are closer; an unknown client region is
+// equally far from everything.
+func (r *Router) distance(from, to string) float64 {
+	if from == "" {
+		return 0
+	}
+	if from == to {
+		return 0
+	}
+	a, okA := r.locations[from]
+	b, okB := r.locations[to]
+	if okA && okB {
+		return haversine(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+	}
+
+	fromParts, toParts := strings.Split(from, "-"), strings.Split(to, "-")
+	shared := 0
+	for shared < len(fromParts) && shared < len(toParts) && fromParts[shared] == toParts[shared] {
+		shared++
+	}
+	switch shared {
+	case 0:
+		return 15000
+	case 1:
+		return 5000
+	default:
+		return 500
+	}
+}
+
+// haversine returns the great-circle distance in kilometres
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLon := toRad(lat2-lat1), toRad(lon2-lon1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Record counts a request routed to region. fallback is set when a region
+// earlier in the decision was tried and failed; a zero latency was not
+// measured and is left out of the average.
+func (r *Router) Record(region string, fallback, failed bool, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stats[region]
+	if !ok {
+		s = &RegionStats{Region: region}
+		r.stats[region] = s
+	}
+	s.Requests++
+	if fallback {
+		s.Fallbacks++
+	}
+	if failed {
+		s.Failures++
+		return
+	}
+	if latency > 0 {
+		s.totalLatency += latency
+		s.measured++
+		s.Latency = s.totalLatency / time.Duration(s.measured)
+	}
+}
+
+// Stats returns the routing counters of every region, sorted by region
+func (r *Router) Stats() []RegionStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make([]RegionStats, 0, len(r.stats))
+	for _, s := range r.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Region < stats[j].Region })
+	return stats
+}
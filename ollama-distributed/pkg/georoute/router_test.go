@@ -0,0 +1,101 @@
+package georoute
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var targets = []Target{
+	{Region: "us-east-1", Healthy: true},
+	{Region: "us-west-2", Healthy: true},
+	{Region: "eu-west-1", Healthy: true},
+	{Region: "ap-south-1", Healthy: false},
+}
+
+func TestRouteClosestFirst(t *testing.T) {
+	router, err := NewRouter(Config{
+		Networks: []NetworkRegion{
+			{CIDR: "10.0.0.0/8", Region: "us-east-1"},
+			{CIDR: "10.20.0.0/16", Region: "eu-west-2"},
+		},
+	})
+	require.NoError(t, err)
+
+	decision, err := router.Route(Client{IP: net.ParseIP("10.20.1.1")}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-2", decision.ClientRegion, "the most specific network wins")
+	assert.Equal(t, []string{"eu-west-1", "us-east-1", "us-west-2"}, decision.Regions)
+
+	decision, err = router.Route(Client{IP: net.ParseIP("10.1.1.1"), Header: "us-west-1"}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-1", decision.ClientRegion, "the header beats the address")
+	assert.Equal(t, []string{"us-west-2", "us-east-1", "eu-west-1"}, decision.Regions)
+
+	decision, err = router.Route(Client{IP: net.ParseIP("192.168.1.1")}, targets)
+	require.NoError(t, err)
+	assert.Empty(t, decision.ClientRegion)
+	assert.Equal(t, []string{"us-east-1", "us-west-2", "eu-west-1"}, decision.Regions, "unknown clients keep target order")
+}
+
+func TestRouteUsesLocations(t *testing.T) {
+	router, err := NewRouter(Config{Regions: []RegionLocation{
+		{Name: "tokyo", Latitude: 35.7, Longitude: 139.7},
+		{Name: "us-east-1", Latitude: 38.9, Longitude: -77.0},
+		{Name: "us-west-2", Latitude: 45.5, Longitude: -122.7},
+		{Name: "eu-west-1", Latitude: 53.3, Longitude: -6.3},
+	}})
+	require.NoError(t, err)
+
+	decision, err := router.Route(Client{Header: "tokyo"}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-west-2", "eu-west-1", "us-east-1"}, decision.Regions, "Dublin is closer to Tokyo than Washington")
+}
+
+func TestRouteOverrides(t *testing.T) {
+	router, err := NewRouter(Config{Overrides: []Override{
+		{Name: "eu-residency", ClientRegion: "eu-*", Region: "eu-west-1", Strict: true},
+		{Name: "big-models", Model: "llama3:70b*", Region: "us-west-2"},
+	}})
+	require.NoError(t, err)
+
+	decision, err := router.Route(Client{Header: "us-east-1", Model: "llama3:70b-q4"}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, "big-models", decision.Override)
+	assert.Equal(t, []string{"us-west-2", "us-east-1", "eu-west-1"}, decision.Regions)
+
+	decision, err = router.Route(Client{Header: "eu-central-1"}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1"}, decision.Regions, "strict overrides leave no fallback")
+
+	unhealthy := []Target{{Region: "us-east-1", Healthy: true}, {Region: "eu-west-1", Healthy: false}}
+	_, err = router.Route(Client{Header: "eu-central-1"}, unhealthy)
+	assert.ErrorIs(t, err, ErrNoRegion)
+
+	require.NoError(t, router.SetOverrides(nil))
+	decision, err = router.Route(Client{Header: "eu-central-1"}, unhealthy)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1"}, decision.Regions)
+
+	assert.Error(t, router.SetOverrides([]Override{{Name: "bad", ClientCIDR: "10.0.0.0/33", Region: "x"}}))
+	assert.Error(t, router.SetOverrides([]Override{{Name: "no-region", Model: "x"}}))
+}
+
+func TestRecordStats(t *testing.T) {
+	router, err := NewRouter(Config{})
+	require.NoError(t, err)
+
+	router.Record("us-east-1", false, true, 0)
+	router.Record("us-west-2", true, false, 200*time.Millisecond)
+	router.Record("us-west-2", false, false, 100*time.Millisecond)
+
+	stats := router.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, RegionStats{Region: "us-east-1", Requests: 1, Failures: 1}, stats[0])
+	assert.Equal(t, int64(2), stats[1].Requests)
+	assert.Equal(t, int64(1), stats[1].Fallbacks)
+	assert.Equal(t, 150*time.Millisecond, stats[1].Latency)
+}
@@ -0,0 +1,94 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi/ollamamaxv1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultWatchInterval is how often WatchClusterState checks for changes
+// when the caller does not say
+const DefaultWatchInterval = 5 * time.Second
+
+// minWatchInterval keeps watchers from polling the cluster in a busy loop
+const minWatchInterval = 100 * time.Millisecond
+
+// ListModels lists the models of the cluster visible to the caller
+func (s *Server) ListModels(ctx context.Context, _ *pb.ListModelsRequest) (*pb.ListModelsResponse, error) {
+	models, err := s.backend.ListModels(ctx, caller(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.ListModelsResponse{Models: models}, nil
+}
+
+// GetModel returns a model
+func (s *Server) GetModel(ctx context.Context, req *pb.GetModelRequest) (*pb.Model, error) {
+	if req.GetName() == "" {
+		return nil, toStatus(fmt.Errorf("%w: name is required", ErrInvalidArgument))
+	}
+	model, err := s.backend.GetModel(ctx, caller(ctx), req.GetName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return model, nil
+}
+
+// DeleteModel deletes a model from the cluster; it takes the admin role
+func (s *Server) DeleteModel(ctx context.Context, req *pb.DeleteModelRequest) (*pb.DeleteModelResponse, error) {
+	if !caller(ctx).HasRole("admin") {
+		return nil, toStatus(fmt.Errorf("%w: admin role required", ErrPermissionDenied))
+	}
+	if req.GetName() == "" {
+		return nil, toStatus(fmt.Errorf("%w: name is required", ErrInvalidArgument))
+	}
+	if err := s.backend.DeleteModel(ctx, caller(ctx), req.GetName()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteModelResponse{}, nil
+}
+
+// GetClusterState returns the state of the cluster
+func (s *Server) GetClusterState(ctx context.Context, _ *pb.GetClusterStateRequest) (*pb.ClusterState, error) {
+	state, err := s.backend.ClusterState(ctx, caller(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return state, nil
+}
+
+// WatchClusterState sends the state of the cluster, then checks it every
+// interval and sends it again when it changed
+func (s *Server) WatchClusterState(req *pb.WatchClusterStateRequest, out grpc.ServerStreamingServer[pb.ClusterState]) error {
+	ctx := out.Context()
+	interval := time.Duration(req.GetIntervalMs()) * time.Millisecond
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	interval = max(interval, minWatchInterval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last *pb.ClusterState
+	for {
+		state, err := s.backend.ClusterState(ctx, caller(ctx))
+		if err != nil {
+			return toStatus(err)
+		}
+		if last == nil || !proto.Equal(state, last) {
+			if err := out.Send(state); err != nil {
+				return err
+			}
+			last = state
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
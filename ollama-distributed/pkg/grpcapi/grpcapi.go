@@ -0,0 +1,343 @@
+// Package grpcapi serves the gRPC API of a node alongside the REST API:
+// streaming inference, model management and cluster state, for consumers
+// that want strongly typed clients and bidirectional streaming. Callers
+// authenticate with the same bearer tokens as the REST API, and their calls
+// count against the same rate limit and quota.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi/ollamamaxv1"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultListen is the address served when none is configured
+const DefaultListen = "0.0.0.0:50051"
+
+// Errors a Backend wraps to choose the status code of a call
+var (
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrLimitExceeded    = errors.New("limit exceeded")
+	ErrUnavailable      = errors.New("unavailable")
+)
+
+// Config configures the gRPC API
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+	TLS     TLS    `yaml:"tls"`
+	// MaxMessageBytes caps received messages; gRPC's 4 MiB when unset
+	MaxMessageBytes int `yaml:"max_message_bytes" mapstructure:"max_message_bytes"`
+}
+
+// TLS configures the server certificate and, with ClientCAFile, requires
+// clients to present certificates signed by that CA
+type TLS struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert_file" mapstructure:"cert_file"`
+	KeyFile      string `yaml:"key_file" mapstructure:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file" mapstructure:"client_ca_file"`
+}
+
+// Validate checks the address and TLS files
+func (c Config) Validate() error {
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", c.Listen, err)
+		}
+	}
+	if c.TLS.Enabled && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS enabled but cert_file or key_file not specified")
+	}
+	if c.MaxMessageBytes < 0 {
+		return fmt.Errorf("negative max_message_bytes")
+	}
+	return nil
+}
+
+// Identity is an authenticated caller
+type Identity struct {
+	UserID   string
+	Username string
+	Roles    []string
+	Tenant   string
+	// APIKey is the API key the caller authenticated with, if any
+	APIKey string
+}
+
+// HasRole reports whether the caller has a role; admins have every role
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role || r == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the caller of a call
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Backend is the node the API serves: it authenticates callers, admits
+// their requests and reports models and cluster state. Errors wrapping
+// ErrNotFound and the like set the status code of the call.
+type Backend interface {
+	Authenticate(ctx context.Context, token string) (Identity, error)
+	// AdmitCall counts a call of the caller, from the client address
+	// clientIP, against its rate limit and quota
+	AdmitCall(ctx context.Context, caller Identity, clientIP string) error
+	// AdmitModel checks the caller may run inference on a model and
+	// returns the name the model is registered under
+	AdmitModel(ctx context.Context, caller Identity, model string) (string, error)
+	// ModelEndpoints returns the Ollama endpoints serving a registered model
+	ModelEndpoints(model string) []string
+	// InferenceHooks runs the hooks of the REST inference endpoints on the
+	// body of a request to an Ollama endpoint, generate or chat, for a model
+	// as the caller named it. It returns the body to send and the handlers
+	// to pass the streamed response through, innermost first; a handler
+	// ending the response calls cancel to stop the generation.
+	InferenceHooks(ctx context.Context, caller Identity, endpoint, model string, body []byte, cancel context.CancelFunc) ([]byte, []textstream.Handler, error)
+
+	ListModels(ctx context.Context, caller Identity) ([]*pb.Model, error)
+	GetModel(ctx context.Context, caller Identity, name string) (*pb.Model, error)
+	DeleteModel(ctx context.Context, caller Identity, name string) error
+	ClusterState(ctx context.Context, caller Identity) (*pb.ClusterState, error)
+}
+
+// Server serves the OllamaMax gRPC service
+type Server struct {
+	pb.UnimplementedOllamaMaxServer
+
+	cfg     Config
+	backend Backend
+	http    *http.Client
+	next    atomic.Uint64
+
+	grpc     *grpc.Server
+	listener net.Listener
+}
+
+// NewServer creates a server for backend, with TLS when configured
+func NewServer(cfg Config, backend Backend) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultListen
+	}
+
+	s := &Server{cfg: cfg, backend: backend, http: &http.Client{}}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.unaryAdmit),
+		grpc.ChainStreamInterceptor(s.streamAdmit),
+	}
+	if cfg.MaxMessageBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxMessageBytes))
+	}
+	if cfg.TLS.Enabled {
+		creds, err := serverCredentials(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s.grpc = grpc.NewServer(opts...)
+	pb.RegisterOllamaMaxServer(s.grpc, s)
+	return s, nil
+}
+
+// serverCredentials loads the server certificate and the client CA
+func serverCredentials(cfg TLS) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in client CA %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Start listens on the configured address and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.cfg.Listen, err)
+	}
+	s.listener = listener
+	go func() {
+		if err := s.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Serve serves calls on listener until Stop
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpc.Serve(listener)
+}
+
+// Addr returns the address the server listens on once started
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop lets the calls in progress finish for up to timeout, then closes
+// the remaining ones
+func (s *Server) Stop(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.grpc.Stop()
+	}
+}
+
+// authenticate resolves the bearer token of a call to its caller
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+	id, err := s.backend.Authenticate(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+// admitCall authenticates a call and counts it against the rate limit and
+// quota its caller has on the REST API
+func (s *Server) admitCall(ctx context.Context) (context.Context, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.AdmitCall(ctx, caller(ctx), clientIP(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	return ctx, nil
+}
+
+// clientIP returns the address a call comes from, without its port
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (s *Server) unaryAdmit(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.admitCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAdmit(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.admitCall(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream carries the caller in the context of a stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// caller returns the authenticated caller of a call
+func caller(ctx context.Context) Identity {
+	id, _ := IdentityFromContext(ctx)
+	return id
+}
+
+// toStatus converts an error of the backend or of Ollama to a status
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrLimitExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrNoEndpoint), errors.Is(err, ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,388 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi/ollamamaxv1"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeBackend admits acme's callers to llama3 and serves it from endpoints,
+// and up to quota calls when quota is set. Prompts containing "secret" are
+// refused, and the replies are passed through handlers.
+type fakeBackend struct {
+	mu        sync.Mutex
+	endpoints []string
+	models    []*pb.Model
+	state     *pb.ClusterState
+	quota     int
+	calls     []string
+	handlers  func(cancel context.CancelFunc) []textstream.Handler
+}
+
+func (b *fakeBackend) Authenticate(_ context.Context, token string) (Identity, error) {
+	switch token {
+	case "user-key":
+		return Identity{UserID: "u1", Roles: []string{"user"}, Tenant: "acme"}, nil
+	case "admin-key":
+		return Identity{UserID: "a1", Roles: []string{"admin"}}, nil
+	}
+	return Identity{}, ErrUnauthenticated
+}
+
+func (b *fakeBackend) AdmitCall(_ context.Context, caller Identity, clientIP string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.quota > 0 && len(b.calls) >= b.quota {
+		return fmt.Errorf("%w: quota of %d calls used", ErrLimitExceeded, b.quota)
+	}
+	b.calls = append(b.calls, caller.UserID+"@"+clientIP)
+	return nil
+}
+
+func (b *fakeBackend) AdmitModel(_ context.Context, caller Identity, model string) (string, error) {
+	if model != "llama3" {
+		return "", fmt.Errorf("%w: model %s not allowed", ErrPermissionDenied, model)
+	}
+	return caller.Tenant + "/" + model, nil
+}
+
+func (b *fakeBackend) ModelEndpoints(string) []string {
+	return b.endpoints
+}
+
+func (b *fakeBackend) InferenceHooks(_ context.Context, _ Identity, _, _ string, body []byte, cancel context.CancelFunc) ([]byte, []textstream.Handler, error) {
+	if strings.Contains(string(body), "secret") {
+		return nil, nil, fmt.Errorf("%w: prompt rejected", ErrPermissionDenied)
+	}
+	if b.handlers == nil {
+		return body, nil, nil
+	}
+	return body, b.handlers(cancel), nil
+}
+
+func (b *fakeBackend) ListModels(context.Context, Identity) ([]*pb.Model, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.models, nil
+}
+
+func (b *fakeBackend) GetModel(_ context.Context, _ Identity, name string) (*pb.Model, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, m := range b.models {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("model %s %w", name, ErrNotFound)
+}
+
+func (b *fakeBackend) DeleteModel(_ context.Context, _ Identity, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, m := range b.models {
+		if m.Name == name {
+			b.models = append(b.models[:i], b.models[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s %w", name, ErrNotFound)
+}
+
+func (b *fakeBackend) ClusterState(context.Context, Identity) (*pb.ClusterState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return copyState(b.state), nil
+}
+
+// copyState copies a cluster state so watchers don't share it with the test
+func copyState(state *pb.ClusterState) *pb.ClusterState {
+	return &pb.ClusterState{NodeId: state.NodeId, Leader: state.Leader, Models: state.Models}
+}
+
+// fakeOllama streams the words of a reply, echoing the model and the
+// number of messages it was sent
+func fakeOllama(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string            `json:"model"`
+			Prompt   string            `json:"prompt"`
+			Messages []json.RawMessage `json:"messages"`
+			Input    []string          `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		enc := json.NewEncoder(w)
+		switch r.URL.Path {
+		case "/api/generate":
+			for _, word := range []string{"hello ", req.Model} {
+				_ = enc.Encode(map[string]interface{}{"model": req.Model, "response": word})
+			}
+			_ = enc.Encode(map[string]interface{}{"model": req.Model, "done": true, "eval_count": 2})
+		case "/api/chat":
+			reply := fmt.Sprintf("%d messages", len(req.Messages))
+			_ = enc.Encode(map[string]interface{}{"message": map[string]string{"role": "assistant", "content": reply}})
+			_ = enc.Encode(map[string]interface{}{"message": map[string]string{"role": "assistant"}, "done": true})
+		case "/api/embed":
+			var embeddings [][]float32
+			for _, in := range req.Input {
+				embeddings = append(embeddings, []float32{float32(len(in))})
+			}
+			_ = enc.Encode(map[string]interface{}{"embeddings": embeddings})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient serves backend over an in-memory connection
+func newTestClient(t *testing.T, backend Backend) pb.OllamaMaxClient {
+	server, err := NewServer(Config{}, backend)
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { server.Stop(time.Second) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewOllamaMaxClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAuthentication(t *testing.T) {
+	client := newTestClient(t, &fakeBackend{state: &pb.ClusterState{}})
+
+	_, err := client.GetClusterState(context.Background(), &pb.GetClusterStateRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.GetClusterState(withToken("bogus"), &pb.GetClusterStateRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := client.Generate(context.Background(), &pb.GenerateRequest{Model: "llama3"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "streams are authenticated too")
+
+	_, err = client.GetClusterState(withToken("user-key"), &pb.GetClusterStateRequest{})
+	assert.NoError(t, err)
+}
+
+func TestGenerateAndEmbed(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	client := newTestClient(t, &fakeBackend{endpoints: []string{down.URL, fakeOllama(t).URL}})
+	ctx := withToken("user-key")
+
+	// Every call fails over from the unavailable endpoint
+	for i := 0; i < 2; i++ {
+		stream, err := client.Generate(ctx, &pb.GenerateRequest{Model: "llama3", Prompt: "hi"})
+		require.NoError(t, err)
+		var text string
+		var last *pb.GenerateResponse
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			text += resp.Response
+			last = resp
+		}
+		assert.Equal(t, "hello acme/llama3", text)
+		assert.True(t, last.Done)
+		assert.Equal(t, "llama3", last.Model)
+		assert.Equal(t, int64(2), last.Stats.CompletionTokens)
+	}
+
+	stream, err := client.Generate(ctx, &pb.GenerateRequest{Model: "mistral"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	resp, err := client.Embed(ctx, &pb.EmbedRequest{Model: "llama3", Input: []string{"a", "abc"}})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, 2)
+	assert.Equal(t, []float32{3}, resp.Embeddings[1].Values)
+	_, err = client.Embed(ctx, &pb.EmbedRequest{Model: "llama3"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestChatKeepsHistory(t *testing.T) {
+	client := newTestClient(t, &fakeBackend{endpoints: []string{fakeOllama(t).URL}})
+	conv, err := client.Chat(withToken("user-key"))
+	require.NoError(t, err)
+
+	reply := func() string {
+		var content string
+		for {
+			resp, err := conv.Recv()
+			require.NoError(t, err)
+			content += resp.Message.GetContent()
+			if resp.Done {
+				return content
+			}
+		}
+	}
+
+	require.NoError(t, conv.Send(&pb.ChatRequest{Model: "llama3", ContextOnly: true,
+		Messages: []*pb.Message{{Role: "system", Content: "Be brief."}}}))
+	require.NoError(t, conv.Send(&pb.ChatRequest{Messages: []*pb.Message{{Role: "user", Content: "Hi"}}}))
+	assert.Equal(t, "2 messages", reply())
+	// The reply is part of the history of the next turn
+	require.NoError(t, conv.Send(&pb.ChatRequest{Messages: []*pb.Message{{Role: "user", Content: "Again"}}}))
+	assert.Equal(t, "4 messages", reply())
+	require.NoError(t, conv.CloseSend())
+	_, err = conv.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+// upperHandler upper-cases the text of replies, and ends them with an
+// error once they contain word
+type upperHandler struct {
+	word   string
+	cancel context.CancelFunc
+}
+
+func (h upperHandler) Chunk(chunk map[string]interface{}, _ bool) textstream.Result {
+	if h.word != "" && strings.Contains(textstream.Text(chunk), h.word) {
+		h.cancel()
+		return textstream.Result{End: map[string]interface{}{"done": true, "done_reason": "rejected", "error": "reply rejected"}}
+	}
+	textstream.Texts(chunk, func(_, text string) string { return strings.ToUpper(text) })
+	return textstream.Result{Changed: true}
+}
+
+func TestInferenceHooks(t *testing.T) {
+	backend := &fakeBackend{endpoints: []string{fakeOllama(t).URL}}
+	backend.handlers = func(cancel context.CancelFunc) []textstream.Handler {
+		return []textstream.Handler{upperHandler{word: "acme", cancel: cancel}}
+	}
+	client := newTestClient(t, backend)
+	ctx := withToken("user-key")
+
+	stream, err := client.Generate(ctx, &pb.GenerateRequest{Model: "llama3", Prompt: "secret"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "request hooks may refuse a request")
+
+	stream, err = client.Generate(ctx, &pb.GenerateRequest{Model: "llama3", Prompt: "hi"})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO ", resp.Response, "replies pass through the response handlers")
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "handlers may end a reply")
+
+	// Handlers run in turn, the first on the reply as generated
+	backend.handlers = func(cancel context.CancelFunc) []textstream.Handler {
+		return []textstream.Handler{upperHandler{cancel: cancel}, upperHandler{word: "MESSAGES", cancel: cancel}}
+	}
+	conv, err := client.Chat(ctx)
+	require.NoError(t, err)
+	require.NoError(t, conv.Send(&pb.ChatRequest{Model: "llama3", Messages: []*pb.Message{{Role: "user", Content: "Hi"}}}))
+	_, err = conv.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestCallLimits(t *testing.T) {
+	backend := &fakeBackend{endpoints: []string{fakeOllama(t).URL}, state: &pb.ClusterState{}, quota: 3}
+	client := newTestClient(t, backend)
+	ctx := withToken("user-key")
+
+	_, err := client.GetClusterState(ctx, &pb.GetClusterStateRequest{})
+	require.NoError(t, err)
+	conv, err := client.Chat(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, conv.Send(&pb.ChatRequest{Model: "llama3", Messages: []*pb.Message{{Role: "user", Content: "Hi"}}}))
+		for {
+			resp, err := conv.Recv()
+			require.NoError(t, err)
+			if resp.Done {
+				break
+			}
+		}
+	}
+	require.NoError(t, conv.Send(&pb.ChatRequest{Messages: []*pb.Message{{Role: "user", Content: "Again"}}}))
+	_, err = conv.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "every reply of a chat counts against the quota")
+
+	_, err = client.GetClusterState(ctx, &pb.GetClusterStateRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	stream, err := client.Generate(ctx, &pb.GenerateRequest{Model: "llama3"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "streams are limited too")
+	assert.Equal(t, []string{"u1@bufconn", "u1@bufconn", "u1@bufconn"}, backend.calls)
+}
+
+func TestModelsAndClusterState(t *testing.T) {
+	backend := &fakeBackend{
+		models: []*pb.Model{{Name: "llama3", Locations: []string{"n1"}}, {Name: "mistral"}},
+		state:  &pb.ClusterState{NodeId: "n1", Leader: "n1", Models: 2},
+	}
+	client := newTestClient(t, backend)
+	user, admin := withToken("user-key"), withToken("admin-key")
+
+	list, err := client.ListModels(user, &pb.ListModelsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Models, 2)
+	model, err := client.GetModel(user, &pb.GetModelRequest{Name: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n1"}, model.Locations)
+	_, err = client.GetModel(user, &pb.GetModelRequest{Name: "phi"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteModel(user, &pb.DeleteModelRequest{Name: "mistral"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.DeleteModel(admin, &pb.DeleteModelRequest{Name: "mistral"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(user)
+	defer cancel()
+	watch, err := client.WatchClusterState(ctx, &pb.WatchClusterStateRequest{IntervalMs: 10})
+	require.NoError(t, err)
+	state, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), state.Models)
+
+	backend.mu.Lock()
+	backend.state.Models = 1
+	backend.mu.Unlock()
+	state, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), state.Models, "changes are sent")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Listen: ":50051"}.Validate())
+	assert.Error(t, Config{Listen: "50051"}.Validate())
+	assert.Error(t, Config{TLS: TLS{Enabled: true, CertFile: "cert.pem"}}.Validate())
+}
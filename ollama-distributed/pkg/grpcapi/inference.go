@@ -0,0 +1,332 @@
+package grpcapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	pb "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/grpcapi/ollamamaxv1"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ErrNoEndpoint is returned when no Ollama endpoint serves a model
+var ErrNoEndpoint = errors.New("no endpoint available")
+
+// ollamaMessage is a chat message of the Ollama API
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaChunk is one line of a streamed Ollama response
+type ollamaChunk struct {
+	Model           string         `json:"model"`
+	Response        string         `json:"response"`
+	Message         *ollamaMessage `json:"message"`
+	Done            bool           `json:"done"`
+	PromptEvalCount int64          `json:"prompt_eval_count"`
+	EvalCount       int64          `json:"eval_count"`
+	TotalDuration   int64          `json:"total_duration"`
+	Error           string         `json:"error"`
+	// DoneReason is set on the chunk ending a response a hook refused
+	DoneReason string `json:"done_reason"`
+}
+
+func (c *ollamaChunk) stats() *pb.Stats {
+	if !c.Done {
+		return nil
+	}
+	return &pb.Stats{
+		PromptTokens:     c.PromptEvalCount,
+		CompletionTokens: c.EvalCount,
+		TotalDurationNs:  c.TotalDuration,
+	}
+}
+
+// admit authorizes the caller's request for a model and returns the name
+// the model is registered under
+func (s *Server) admit(ctx context.Context, model string) (string, error) {
+	if model == "" {
+		return "", fmt.Errorf("%w: model is required", ErrInvalidArgument)
+	}
+	return s.backend.AdmitModel(ctx, caller(ctx), model)
+}
+
+// post sends an Ollama API request for a model to its endpoints in turn,
+// starting from the next in rotation, until one answers
+func (s *Server) post(ctx context.Context, model, path string, body interface{}) (*http.Response, error) {
+	endpoints := s.backend.ModelEndpoints(model)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w for model %s", ErrNoEndpoint, model)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	start := int(s.next.Add(1) % uint64(len(endpoints)))
+	var lastErr error
+	for i := range endpoints {
+		endpoint := strings.TrimSuffix(endpoints[(start+i)%len(endpoints)], "/")
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s returned %s", endpoint, resp.Status)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			var failure struct {
+				Error string `json:"error"`
+			}
+			_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&failure)
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, failure.Error)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, failure.Error)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("%w for model %s: %v", ErrNoEndpoint, model, lastErr)
+}
+
+// infer sends an inference request for a model, as the caller named it
+// and as registered, to its Ollama endpoint through the backend's hooks:
+// the body they return is posted and the streamed response passed through
+// their handlers
+func (s *Server) infer(ctx context.Context, endpoint, requested, model string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	payload, handlers, err := s.backend.InferenceHooks(ctx, caller(ctx), endpoint, requested, payload, cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := s.post(ctx, model, "/api/"+endpoint, json.RawMessage(payload))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if len(handlers) == 0 {
+		resp.Body = cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+
+	writers := make([]*textstream.Writer, len(handlers))
+	pr, pw := io.Pipe()
+	var w io.Writer = pw
+	for i := len(handlers) - 1; i >= 0; i-- {
+		writers[i] = textstream.NewWriter(w, handlers[i])
+		w = writers[i]
+	}
+	go func(body io.ReadCloser) {
+		defer body.Close()
+		_, err := io.Copy(writers[0], body)
+		for _, writer := range writers {
+			if finishErr := writer.Finish(); err == nil {
+				err = finishErr
+			}
+		}
+		pw.CloseWithError(err)
+	}(resp.Body)
+	resp.Body = cancelBody{ReadCloser: pr, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the request of a response body once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// stream reads the NDJSON chunks of a streamed Ollama response
+func stream(resp *http.Response, each func(*ollamaChunk) error) error {
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return fmt.Errorf("invalid response chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			if chunk.DoneReason != "" {
+				return fmt.Errorf("%w: %s", ErrPermissionDenied, chunk.Error)
+			}
+			return errors.New(chunk.Error)
+		}
+		if err := each(&chunk); err != nil {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// options converts request options to the map Ollama expects
+func options(opts *structpb.Struct) map[string]interface{} {
+	if opts == nil {
+		return nil
+	}
+	return opts.AsMap()
+}
+
+// Generate streams the completion of a prompt
+func (s *Server) Generate(req *pb.GenerateRequest, out grpc.ServerStreamingServer[pb.GenerateResponse]) error {
+	ctx := out.Context()
+	model, err := s.admit(ctx, req.GetModel())
+	if err != nil {
+		return toStatus(err)
+	}
+	resp, err := s.infer(ctx, "generate", req.GetModel(), model, map[string]interface{}{
+		"model":   model,
+		"prompt":  req.GetPrompt(),
+		"system":  req.GetSystem(),
+		"options": options(req.GetOptions()),
+		"stream":  true,
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	return toStatus(stream(resp, func(chunk *ollamaChunk) error {
+		return out.Send(&pb.GenerateResponse{
+			Model:    req.GetModel(),
+			Response: chunk.Response,
+			Done:     chunk.Done,
+			Stats:    chunk.stats(),
+		})
+	}))
+}
+
+// Chat holds a conversation over the stream, keeping its history so each
+// request only carries the new messages. Every reply after the first counts
+// as another call against the caller's rate limit and quota.
+func (s *Server) Chat(conv grpc.BidiStreamingServer[pb.ChatRequest, pb.ChatResponse]) error {
+	ctx := conv.Context()
+	var (
+		requested, model string
+		history          []ollamaMessage
+		replies          int
+	)
+	for {
+		req, err := conv.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if model == "" || (req.GetModel() != "" && req.GetModel() != requested) {
+			if model, err = s.admit(ctx, req.GetModel()); err != nil {
+				return toStatus(err)
+			}
+			requested = req.GetModel()
+		}
+		for _, m := range req.GetMessages() {
+			history = append(history, ollamaMessage{Role: m.GetRole(), Content: m.GetContent()})
+		}
+		if req.GetContextOnly() {
+			continue
+		}
+		if replies++; replies > 1 {
+			if err := s.backend.AdmitCall(ctx, caller(ctx), clientIP(ctx)); err != nil {
+				return toStatus(err)
+			}
+		}
+
+		resp, err := s.infer(ctx, "chat", requested, model, map[string]interface{}{
+			"model":    model,
+			"messages": history,
+			"options":  options(req.GetOptions()),
+			"stream":   true,
+		})
+		if err != nil {
+			return toStatus(err)
+		}
+		reply := ollamaMessage{Role: "assistant"}
+		err = stream(resp, func(chunk *ollamaChunk) error {
+			msg := &pb.Message{Role: "assistant"}
+			if chunk.Message != nil {
+				msg.Role, msg.Content = chunk.Message.Role, chunk.Message.Content
+				reply.Content += chunk.Message.Content
+			}
+			return conv.Send(&pb.ChatResponse{
+				Model:   requested,
+				Message: msg,
+				Done:    chunk.Done,
+				Stats:   chunk.stats(),
+			})
+		})
+		if err != nil {
+			return toStatus(err)
+		}
+		history = append(history, reply)
+	}
+}
+
+// Embed returns the embeddings of the inputs
+func (s *Server) Embed(ctx context.Context, req *pb.EmbedRequest) (*pb.EmbedResponse, error) {
+	model, err := s.admit(ctx, req.GetModel())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if len(req.GetInput()) == 0 {
+		return nil, toStatus(fmt.Errorf("%w: input is required", ErrInvalidArgument))
+	}
+	resp, err := s.post(ctx, model, "/api/embed", map[string]interface{}{
+		"model": model,
+		"input": req.GetInput(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, toStatus(fmt.Errorf("invalid embed response: %w", err))
+	}
+	out := &pb.EmbedResponse{Model: req.GetModel()}
+	for _, values := range result.Embeddings {
+		out.Embeddings = append(out.Embeddings, &pb.Embedding{Values: values})
+	}
+	return out, nil
+}
@@ -0,0 +1,404 @@
+// gRPC API of an OllamaMax node, alongside the REST API: inference with
+// streaming, model management and cluster state. Regenerate the Go code
+// with `make protobuf`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: ollamamax.proto
+
+package ollamamaxv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	OllamaMax_Generate_FullMethodName          = "/ollamamax.v1.OllamaMax/Generate"
+	OllamaMax_Chat_FullMethodName              = "/ollamamax.v1.OllamaMax/Chat"
+	OllamaMax_Embed_FullMethodName             = "/ollamamax.v1.OllamaMax/Embed"
+	OllamaMax_ListModels_FullMethodName        = "/ollamamax.v1.OllamaMax/ListModels"
+	OllamaMax_GetModel_FullMethodName          = "/ollamamax.v1.OllamaMax/GetModel"
+	OllamaMax_DeleteModel_FullMethodName       = "/ollamamax.v1.OllamaMax/DeleteModel"
+	OllamaMax_GetClusterState_FullMethodName   = "/ollamamax.v1.OllamaMax/GetClusterState"
+	OllamaMax_WatchClusterState_FullMethodName = "/ollamamax.v1.OllamaMax/WatchClusterState"
+)
+
+// OllamaMaxClient is the client API for OllamaMax service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OllamaMaxClient interface {
+	// Generate streams the completion of a prompt as it is generated
+	Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GenerateResponse], error)
+	// Chat holds a conversation: every request adds messages and, unless it
+	// only sets context, is answered with a stream of response chunks ending
+	// with one marked done
+	Chat(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ChatRequest, ChatResponse], error)
+	// Embed returns the embedding of each input
+	Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Model, error)
+	DeleteModel(ctx context.Context, in *DeleteModelRequest, opts ...grpc.CallOption) (*DeleteModelResponse, error)
+	GetClusterState(ctx context.Context, in *GetClusterStateRequest, opts ...grpc.CallOption) (*ClusterState, error)
+	// WatchClusterState sends the cluster state now and whenever it changes
+	WatchClusterState(ctx context.Context, in *WatchClusterStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ClusterState], error)
+}
+
+type ollamaMaxClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOllamaMaxClient(cc grpc.ClientConnInterface) OllamaMaxClient {
+	return &ollamaMaxClient{cc}
+}
+
+func (c *ollamaMaxClient) Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GenerateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OllamaMax_ServiceDesc.Streams[0], OllamaMax_Generate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GenerateRequest, GenerateResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_GenerateClient = grpc.ServerStreamingClient[GenerateResponse]
+
+func (c *ollamaMaxClient) Chat(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ChatRequest, ChatResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OllamaMax_ServiceDesc.Streams[1], OllamaMax_Chat_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ChatRequest, ChatResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_ChatClient = grpc.BidiStreamingClient[ChatRequest, ChatResponse]
+
+func (c *ollamaMaxClient) Embed(ctx context.Context, in *EmbedRequest, opts ...grpc.CallOption) (*EmbedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmbedResponse)
+	err := c.cc.Invoke(ctx, OllamaMax_Embed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ollamaMaxClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, OllamaMax_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ollamaMaxClient) GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Model, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Model)
+	err := c.cc.Invoke(ctx, OllamaMax_GetModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ollamaMaxClient) DeleteModel(ctx context.Context, in *DeleteModelRequest, opts ...grpc.CallOption) (*DeleteModelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteModelResponse)
+	err := c.cc.Invoke(ctx, OllamaMax_DeleteModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ollamaMaxClient) GetClusterState(ctx context.Context, in *GetClusterStateRequest, opts ...grpc.CallOption) (*ClusterState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClusterState)
+	err := c.cc.Invoke(ctx, OllamaMax_GetClusterState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ollamaMaxClient) WatchClusterState(ctx context.Context, in *WatchClusterStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ClusterState], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &OllamaMax_ServiceDesc.Streams[2], OllamaMax_WatchClusterState_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchClusterStateRequest, ClusterState]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_WatchClusterStateClient = grpc.ServerStreamingClient[ClusterState]
+
+// OllamaMaxServer is the server API for OllamaMax service.
+// All implementations must embed UnimplementedOllamaMaxServer
+// for forward compatibility.
+type OllamaMaxServer interface {
+	// Generate streams the completion of a prompt as it is generated
+	Generate(*GenerateRequest, grpc.ServerStreamingServer[GenerateResponse]) error
+	// Chat holds a conversation: every request adds messages and, unless it
+	// only sets context, is answered with a stream of response chunks ending
+	// with one marked done
+	Chat(grpc.BidiStreamingServer[ChatRequest, ChatResponse]) error
+	// Embed returns the embedding of each input
+	Embed(context.Context, *EmbedRequest) (*EmbedResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	GetModel(context.Context, *GetModelRequest) (*Model, error)
+	DeleteModel(context.Context, *DeleteModelRequest) (*DeleteModelResponse, error)
+	GetClusterState(context.Context, *GetClusterStateRequest) (*ClusterState, error)
+	// WatchClusterState sends the cluster state now and whenever it changes
+	WatchClusterState(*WatchClusterStateRequest, grpc.ServerStreamingServer[ClusterState]) error
+	mustEmbedUnimplementedOllamaMaxServer()
+}
+
+// UnimplementedOllamaMaxServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedOllamaMaxServer struct{}
+
+func (UnimplementedOllamaMaxServer) Generate(*GenerateRequest, grpc.ServerStreamingServer[GenerateResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Generate not implemented")
+}
+func (UnimplementedOllamaMaxServer) Chat(grpc.BidiStreamingServer[ChatRequest, ChatResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedOllamaMaxServer) Embed(context.Context, *EmbedRequest) (*EmbedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Embed not implemented")
+}
+func (UnimplementedOllamaMaxServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedOllamaMaxServer) GetModel(context.Context, *GetModelRequest) (*Model, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetModel not implemented")
+}
+func (UnimplementedOllamaMaxServer) DeleteModel(context.Context, *DeleteModelRequest) (*DeleteModelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteModel not implemented")
+}
+func (UnimplementedOllamaMaxServer) GetClusterState(context.Context, *GetClusterStateRequest) (*ClusterState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClusterState not implemented")
+}
+func (UnimplementedOllamaMaxServer) WatchClusterState(*WatchClusterStateRequest, grpc.ServerStreamingServer[ClusterState]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchClusterState not implemented")
+}
+func (UnimplementedOllamaMaxServer) mustEmbedUnimplementedOllamaMaxServer() {}
+func (UnimplementedOllamaMaxServer) testEmbeddedByValue()                   {}
+
+// UnsafeOllamaMaxServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OllamaMaxServer will
+// result in compilation errors.
+type UnsafeOllamaMaxServer interface {
+	mustEmbedUnimplementedOllamaMaxServer()
+}
+
+func RegisterOllamaMaxServer(s grpc.ServiceRegistrar, srv OllamaMaxServer) {
+	// If the following call pancis, it indicates UnimplementedOllamaMaxServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&OllamaMax_ServiceDesc, srv)
+}
+
+func _OllamaMax_Generate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GenerateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OllamaMaxServer).Generate(m, &grpc.GenericServerStream[GenerateRequest, GenerateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_GenerateServer = grpc.ServerStreamingServer[GenerateResponse]
+
+func _OllamaMax_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OllamaMaxServer).Chat(&grpc.GenericServerStream[ChatRequest, ChatResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_ChatServer = grpc.BidiStreamingServer[ChatRequest, ChatResponse]
+
+func _OllamaMax_Embed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmbedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OllamaMaxServer).Embed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OllamaMax_Embed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OllamaMaxServer).Embed(ctx, req.(*EmbedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OllamaMax_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OllamaMaxServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OllamaMax_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OllamaMaxServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OllamaMax_GetModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OllamaMaxServer).GetModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OllamaMax_GetModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OllamaMaxServer).GetModel(ctx, req.(*GetModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OllamaMax_DeleteModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OllamaMaxServer).DeleteModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OllamaMax_DeleteModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OllamaMaxServer).DeleteModel(ctx, req.(*DeleteModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OllamaMax_GetClusterState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClusterStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OllamaMaxServer).GetClusterState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OllamaMax_GetClusterState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OllamaMaxServer).GetClusterState(ctx, req.(*GetClusterStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OllamaMax_WatchClusterState_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchClusterStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OllamaMaxServer).WatchClusterState(m, &grpc.GenericServerStream[WatchClusterStateRequest, ClusterState]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type OllamaMax_WatchClusterStateServer = grpc.ServerStreamingServer[ClusterState]
+
+// OllamaMax_ServiceDesc is the grpc.ServiceDesc for OllamaMax service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OllamaMax_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ollamamax.v1.OllamaMax",
+	HandlerType: (*OllamaMaxServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Embed",
+			Handler:    _OllamaMax_Embed_Handler,
+		},
+		{
+			MethodName: "ListModels",
+			Handler:    _OllamaMax_ListModels_Handler,
+		},
+		{
+			MethodName: "GetModel",
+			Handler:    _OllamaMax_GetModel_Handler,
+		},
+		{
+			MethodName: "DeleteModel",
+			Handler:    _OllamaMax_DeleteModel_Handler,
+		},
+		{
+			MethodName: "GetClusterState",
+			Handler:    _OllamaMax_GetClusterState_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Generate",
+			Handler:       _OllamaMax_Generate_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Chat",
+			Handler:       _OllamaMax_Chat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchClusterState",
+			Handler:       _OllamaMax_WatchClusterState_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ollamamax.proto",
+}
@@ -0,0 +1,258 @@
+// Package hedging cuts the tail latency of read-only requests, such as
+// embeddings and cacheable generations, by hedging them: when the first
+// instance has not answered within a high percentile of recent latencies,
+// the request is also sent to a second instance and the first response to
+// arrive is used. The fraction of requests hedged is capped so that the
+// added load stays bounded even when every instance slows down.
+package hedging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of hedging
+const (
+	DefaultPercentile = 0.95
+	DefaultMinDelay   = 10 * time.Millisecond
+	DefaultMaxRate    = 0.05
+	DefaultWindow     = 1000
+)
+
+// minSamples is how many latencies of a kind are needed before the hedge
+// delay follows their percentile instead of MinDelay
+const minSamples = 20
+
+// maxTokens bounds how many hedges can be saved up while requests are
+// fast, so a burst of slow requests hedges at most this many at once
+const maxTokens = 10
+
+// Config configures hedging
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Percentile of the recent latencies of a kind of request after which
+	// it is hedged, e.g. 0.95
+	Percentile float64 `yaml:"percentile"`
+	// MinDelay is the shortest hedge delay, used until enough latencies
+	// are known
+	MinDelay time.Duration `yaml:"min_delay" mapstructure:"min_delay"`
+	// MaxRate is the fraction of requests that may be hedged
+	MaxRate float64 `yaml:"max_rate" mapstructure:"max_rate"`
+	// Window is how many recent latencies the percentile is taken over
+	Window int `yaml:"window"`
+}
+
+// Validate checks the percentile, rate and window
+func (c Config) Validate() error {
+	if c.Percentile < 0 || c.Percentile >= 1 {
+		return fmt.Errorf("hedging percentile must be in [0, 1)")
+	}
+	if c.MaxRate < 0 || c.MaxRate > 1 {
+		return fmt.Errorf("hedging max rate must be between 0 and 1")
+	}
+	if c.MinDelay < 0 || c.Window < 0 {
+		return fmt.Errorf("hedging settings must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.Percentile == 0 {
+		c.Percentile = DefaultPercentile
+	}
+	if c.MinDelay == 0 {
+		c.MinDelay = DefaultMinDelay
+	}
+	if c.MaxRate == 0 {
+		c.MaxRate = DefaultMaxRate
+	}
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	return c
+}
+
+// Stats describes the hedging of a kind of request
+type Stats struct {
+	Kind     string `json:"kind"`
+	Requests int64  `json:"requests"`
+	// Hedged counts the requests sent to a second instance, and HedgeWins
+	// those the second instance answered first
+	Hedged    int64         `json:"hedged"`
+	HedgeWins int64         `json:"hedge_wins"`
+	Delay     time.Duration `json:"delay"`
+}
+
+// kindState holds the recent latencies and counters of a kind of request
+type kindState struct {
+	stats     Stats
+	latencies []time.Duration // ring of the last Window latencies
+	next      int
+	delay     time.Duration
+}
+
+// Hedger decides when requests are hedged
+type Hedger struct {
+	cfg Config
+
+	mu     sync.Mutex
+	kinds  map[string]*kindState
+	tokens float64
+}
+
+// NewHedger creates a hedger
+func NewHedger(cfg Config) *Hedger {
+	return &Hedger{cfg: cfg.withDefaults(), kinds: make(map[string]*kindState)}
+}
+
+// kind returns the state of a kind of request; h.mu must be held
+func (h *Hedger) kind(kind string) *kindState {
+	k, ok := h.kinds[kind]
+	if !ok {
+		k = &kindState{stats: Stats{Kind: kind}, delay: h.cfg.MinDelay}
+		h.kinds[kind] = k
+	}
+	return k
+}
+
+// Delay returns how long a request of a kind waits for its first instance
+// before it is hedged
+func (h *Hedger) Delay(kind string) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.kind(kind).delay
+}
+
+// Stats returns the stats of every kind of request, sorted by kind
+func (h *Hedger) Stats() []Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := make([]Stats, 0, len(h.kinds))
+	for _, k := range h.kinds {
+		s := k.stats
+		s.Delay = k.delay
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Kind < stats[j].Kind })
+	return stats
+}
+
+// begin counts a request and earns it its share of the hedge budget
+func (h *Hedger) begin(kind string) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := h.kind(kind)
+	k.stats.Requests++
+	h.tokens = math.Min(h.tokens+h.cfg.MaxRate, maxTokens)
+	return k.delay
+}
+
+// allowHedge spends a hedge from the budget, if there is one left
+func (h *Hedger) allowHedge(kind string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	h.kind(kind).stats.Hedged++
+	return true
+}
+
+// observe records the latency of an answered attempt and recomputes the
+// hedge delay
+func (h *Hedger) observe(kind string, latency time.Duration, hedgeWon bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := h.kind(kind)
+	if hedgeWon {
+		k.stats.HedgeWins++
+	}
+	if len(k.latencies) < h.cfg.Window {
+		k.latencies = append(k.latencies, latency)
+	} else {
+		k.latencies[k.next] = latency
+		k.next = (k.next + 1) % h.cfg.Window
+	}
+	if len(k.latencies) < minSamples {
+		return
+	}
+	sorted := append([]time.Duration(nil), k.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	k.delay = max(sorted[int(h.cfg.Percentile*float64(len(sorted)-1))], h.cfg.MinDelay)
+}
+
+// attempt is the outcome of sending a request to one target
+type attempt[T any] struct {
+	target  int
+	value   T
+	err     error
+	latency time.Duration
+}
+
+// Do sends a request of a kind to target 0 and, if it has not answered
+// within the hedge delay or fails and the hedge budget allows, to target
+// 1 as well, returning the first successful answer. The attempt that loses
+// has its context cancelled. With a nil hedger or a single target the
+// request is sent once.
+func Do[T any](ctx context.Context, h *Hedger, kind string, targets int, call func(ctx context.Context, target int) (T, error)) (T, error) {
+	if h == nil || targets < 2 {
+		return call(ctx, 0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan attempt[T], 2)
+	send := func(target int) {
+		start := time.Now()
+		go func() {
+			value, err := call(ctx, target)
+			results <- attempt[T]{target: target, value: value, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	timer := time.NewTimer(h.begin(kind))
+	defer timer.Stop()
+	send(0)
+	outstanding, hedged := 1, false
+	hedge := func() {
+		if !hedged && h.allowHedge(kind) {
+			hedged = true
+			outstanding++
+			send(1)
+		}
+	}
+
+	var errs []error
+	for {
+		select {
+		case result := <-results:
+			outstanding--
+			if result.err == nil {
+				h.observe(kind, result.latency, result.target == 1)
+				return result.value, nil
+			}
+			errs = append(errs, result.err)
+			hedge()
+			if outstanding == 0 {
+				var zero T
+				return zero, errors.Join(errs...)
+			}
+		case <-timer.C:
+			hedge()
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
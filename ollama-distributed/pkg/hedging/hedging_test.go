@@ -0,0 +1,91 @@
+package hedging
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowFirst answers from target 0 after slow and from target 1 at once
+func slowFirst(slow time.Duration, calls *atomic.Int32) func(ctx context.Context, target int) (int, error) {
+	return func(ctx context.Context, target int) (int, error) {
+		calls.Add(1)
+		if target == 0 {
+			select {
+			case <-time.After(slow):
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+		return target, nil
+	}
+}
+
+func TestDoHedgesSlowRequests(t *testing.T) {
+	h := NewHedger(Config{MinDelay: 5 * time.Millisecond, MaxRate: 1})
+	var calls atomic.Int32
+
+	winner, err := Do(context.Background(), h, "embed", 2, slowFirst(time.Second, &calls))
+	require.NoError(t, err)
+	assert.Equal(t, 1, winner, "the hedge answers first")
+	assert.Equal(t, int32(2), calls.Load())
+
+	stats := h.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, Stats{Kind: "embed", Requests: 1, Hedged: 1, HedgeWins: 1, Delay: 5 * time.Millisecond}, stats[0])
+}
+
+func TestDoCapsHedgeRate(t *testing.T) {
+	h := NewHedger(Config{MinDelay: time.Millisecond, MaxRate: 0.5})
+	var calls atomic.Int32
+
+	for i := 0; i < 4; i++ {
+		_, err := Do(context.Background(), h, "embed", 2, slowFirst(20*time.Millisecond, &calls))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), h.Stats()[0].Hedged, "half the requests may be hedged")
+	assert.Equal(t, int32(6), calls.Load())
+}
+
+func TestDoHedgesFailures(t *testing.T) {
+	h := NewHedger(Config{MinDelay: time.Hour, MaxRate: 1})
+
+	value, err := Do(context.Background(), h, "generate", 2, func(ctx context.Context, target int) (string, error) {
+		if target == 0 {
+			return "", errors.New("instance down")
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", value)
+
+	_, err = Do(context.Background(), h, "generate", 2, func(ctx context.Context, target int) (string, error) {
+		return "", errors.New("instance down")
+	})
+	assert.Error(t, err, "without budget left the failure is returned")
+}
+
+func TestDelayFollowsPercentile(t *testing.T) {
+	h := NewHedger(Config{Percentile: 0.9, MinDelay: time.Millisecond, Window: 100})
+	for i := 1; i <= 100; i++ {
+		h.observe("embed", time.Duration(i)*time.Millisecond, false)
+	}
+	assert.Equal(t, 90*time.Millisecond, h.Delay("embed"))
+	assert.Equal(t, time.Millisecond, h.Delay("chat"), "unknown kinds start at the minimum delay")
+
+	value, err := Do(context.Background(), nil, "embed", 2, func(ctx context.Context, target int) (int, error) { return target + 7, nil })
+	require.NoError(t, err)
+	assert.Equal(t, 7, value, "a nil hedger sends once")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Percentile: 0.99, MaxRate: 0.1}.Validate())
+	assert.Error(t, Config{Percentile: 1}.Validate())
+	assert.Error(t, Config{MaxRate: 2}.Validate())
+	assert.Error(t, Config{MinDelay: -time.Second}.Validate())
+}
@@ -0,0 +1,190 @@
+// Package history keeps the history of key time-series of the cluster,
+// such as request rates, latencies, node utilization and the performance
+// of partitioning strategies, so trends can be shown over weeks without an
+// external time-series database.
+//
+// Sources are sampled every Interval and the samples are rolled up into
+// the buckets of every retention tier: a fine tier kept for a short time
+// and coarser ones kept for longer. Each bucket holds the mean, minimum and
+// maximum of its samples. Range queries read the finest tier covering the
+// range within the requested number of points.
+package history
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// Store kinds
+const (
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+)
+
+// Defaults of the history
+const (
+	DefaultInterval  = time.Minute
+	DefaultMaxPoints = 500
+)
+
+// DefaultTiers keep minutes for two days, quarter hours for two weeks and
+// hours for three months
+var DefaultTiers = []Tier{
+	{Resolution: time.Minute, Retention: 48 * time.Hour},
+	{Resolution: 15 * time.Minute, Retention: 14 * 24 * time.Hour},
+	{Resolution: time.Hour, Retention: 90 * 24 * time.Hour},
+}
+
+// Tier is a resolution points are kept at, and for how long
+type Tier struct {
+	Resolution time.Duration `yaml:"resolution" json:"resolution"`
+	Retention  time.Duration `yaml:"retention" json:"retention"`
+}
+
+// Config configures the metrics history
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often the sources are sampled
+	Interval time.Duration `yaml:"interval"`
+	// Store is where points are kept: memory or database
+	Store    string          `yaml:"store"`
+	Database database.Config `yaml:"database"`
+	// Tiers are the resolutions and retentions, finest first
+	Tiers []Tier `yaml:"tiers"`
+}
+
+// Validate checks the store and tiers
+func (c Config) Validate() error {
+	switch c.Store {
+	case "", StoreMemory, StoreDatabase:
+	default:
+		return fmt.Errorf("unknown history store %q", c.Store)
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("negative history interval")
+	}
+	interval := c.withDefaults().Interval
+	for i, tier := range c.Tiers {
+		if tier.Resolution < time.Second || tier.Resolution%interval != 0 {
+			return fmt.Errorf("tier resolution %s must be a multiple of the interval %s", tier.Resolution, interval)
+		}
+		if tier.Retention < tier.Resolution {
+			return fmt.Errorf("tier retention %s is shorter than its resolution %s", tier.Retention, tier.Resolution)
+		}
+		if i > 0 && (tier.Resolution <= c.Tiers[i-1].Resolution || tier.Retention < c.Tiers[i-1].Retention) {
+			return fmt.Errorf("tiers must be ordered by resolution and retention, finest first")
+		}
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if len(c.Tiers) == 0 {
+		c.Tiers = DefaultTiers
+	}
+	return c
+}
+
+// Sample is a value of a metric. Key tells series of the same metric apart,
+// e.g. the node or strategy they belong to. Counter values only ever grow
+// and are recorded as their rate per second.
+type Sample struct {
+	Metric  string
+	Key     string
+	Value   float64
+	Counter bool
+}
+
+// Source returns the current samples of some metrics
+type Source func() []Sample
+
+// Point is the mean, minimum and maximum of the samples of a series over
+// the bucket starting at Time
+type Point struct {
+	Time  time.Time `json:"time"`
+	Mean  float64   `json:"mean"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int64     `json:"count"`
+}
+
+// Series is the points of one key of a metric, oldest first
+type Series struct {
+	Key    string  `json:"key"`
+	Points []Point `json:"points"`
+}
+
+// Query selects the series of a metric over [Since, Until). An empty Key
+// selects every key, and MaxPoints bounds the points per series.
+type Query struct {
+	Metric    string
+	Key       string
+	Since     time.Time
+	Until     time.Time
+	MaxPoints int
+}
+
+// Annotation marks something that happened over a time range, such as an
+// anomaly, for charts of the range to show
+type Annotation struct {
+	Time  time.Time `json:"time"`
+	End   time.Time `json:"end,omitempty"`
+	Title string    `json:"title"`
+	Text  string    `json:"text,omitempty"`
+	Tags  []string  `json:"tags,omitempty"`
+}
+
+// Result is the answer to a query
+type Result struct {
+	Metric      string        `json:"metric"`
+	Resolution  time.Duration `json:"resolution"`
+	Since       time.Time     `json:"since"`
+	Until       time.Time     `json:"until"`
+	Series      []Series      `json:"series"`
+	Annotations []Annotation  `json:"annotations,omitempty"`
+}
+
+// seriesID identifies a series
+type seriesID struct {
+	metric, key string
+}
+
+// bucket accumulates the samples of a series falling into a bucket
+type bucket struct {
+	start         time.Time
+	sum, min, max float64
+	count         int64
+}
+
+func (b *bucket) add(value float64) {
+	if b.count == 0 || value < b.min {
+		b.min = value
+	}
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	b.sum += value
+	b.count++
+}
+
+func (b *bucket) point() Point {
+	return Point{Time: b.start, Mean: b.sum / float64(b.count), Min: b.min, Max: b.max, Count: b.count}
+}
+
+// group turns points into series ordered by key
+func group(points map[string][]Point) []Series {
+	series := make([]Series, 0, len(points))
+	for key, p := range points {
+		sort.Slice(p, func(i, j int) bool { return p[i].Time.Before(p[j].Time) })
+		series = append(series, Series{Key: key, Points: p})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Key < series[j].Key })
+	return series
+}
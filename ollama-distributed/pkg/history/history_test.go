@@ -0,0 +1,157 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Interval: 10 * time.Second, Tiers: []Tier{{Resolution: 10 * time.Second, Retention: time.Hour}}}.Validate())
+	assert.Error(t, Config{Store: "influx"}.Validate())
+	assert.Error(t, Config{Tiers: []Tier{{Resolution: 90 * time.Second, Retention: time.Hour}}}.Validate(), "not a multiple of the interval")
+	assert.Error(t, Config{Tiers: []Tier{{Resolution: time.Hour, Retention: time.Minute}}}.Validate())
+	assert.Error(t, Config{Tiers: []Tier{
+		{Resolution: time.Hour, Retention: 48 * time.Hour},
+		{Resolution: time.Minute, Retention: 72 * time.Hour},
+	}}.Validate())
+}
+
+func newTestRecorder(clock *time.Time) (*Recorder, *MemoryStore) {
+	store := NewMemoryStore()
+	r := NewRecorder(Config{Tiers: []Tier{
+		{Resolution: time.Minute, Retention: time.Hour},
+		{Resolution: 10 * time.Minute, Retention: 24 * time.Hour},
+	}}, store)
+	r.now = func() time.Time { return *clock }
+	return r, store
+}
+
+func TestDownsampling(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, store := newTestRecorder(&clock)
+	requests := 0.0
+	latency := 0.0
+	r.AddSource(func() []Sample {
+		return []Sample{
+			{Metric: "requests_per_second", Value: requests, Counter: true},
+			{Metric: "node_cpu_percent", Key: "node-a", Value: latency},
+		}
+	})
+
+	// 25 minutes of samples: 2 requests a second, and a value rising by 1
+	// a minute
+	for i := 0; i < 25; i++ {
+		require.NoError(t, r.Sample(ctx))
+		clock = clock.Add(time.Minute)
+		requests += 120
+		latency++
+	}
+	assert.Equal(t, []string{"node_cpu_percent", "requests_per_second"}, r.Metrics())
+
+	minutes, err := store.Read(ctx, time.Minute, "node_cpu_percent", "", time.Time{}, clock)
+	require.NoError(t, err)
+	require.Len(t, minutes["node-a"], 24, "the bucket being filled is not written yet")
+	assert.Equal(t, 23.0, minutes["node-a"][23].Mean)
+
+	tens, err := store.Read(ctx, 10*time.Minute, "node_cpu_percent", "node-a", time.Time{}, clock)
+	require.NoError(t, err)
+	require.Len(t, tens["node-a"], 2)
+	assert.Equal(t, Point{Time: clock.Add(-25 * time.Minute), Mean: 4.5, Min: 0, Max: 9, Count: 10}, tens["node-a"][0])
+
+	rates, err := store.Read(ctx, 10*time.Minute, "requests_per_second", "", time.Time{}, clock)
+	require.NoError(t, err)
+	require.Len(t, rates[""], 2)
+	assert.Equal(t, 2.0, rates[""][0].Mean)
+	assert.Equal(t, int64(9), rates[""][0].Count, "counters are recorded from their second sample")
+}
+
+func TestQueryPicksTier(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, _ := newTestRecorder(&clock)
+	value := 0.0
+	r.AddSource(func() []Sample { return []Sample{{Metric: "latency_ms", Key: "generate", Value: value}} })
+	for i := 0; i < 120; i++ {
+		require.NoError(t, r.Sample(ctx))
+		clock = clock.Add(time.Minute)
+		value++
+	}
+	require.NoError(t, r.Sample(ctx))
+	clock = clock.Add(30 * time.Second)
+
+	result, err := r.Query(ctx, Query{Metric: "latency_ms", Since: clock.Add(-30 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, result.Resolution)
+	require.Len(t, result.Series, 1)
+	points := result.Series[0].Points
+	require.Len(t, points, 31, "30 minutes and the minute being filled")
+	assert.Equal(t, 120.0, points[30].Mean)
+
+	// Beyond the retention of minutes, or with fewer points, coarser
+	// buckets are used
+	result, err = r.Query(ctx, Query{Metric: "latency_ms", Since: clock.Add(-2 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, result.Resolution)
+	assert.Len(t, result.Series[0].Points, 13)
+	result, err = r.Query(ctx, Query{Metric: "latency_ms", Since: clock.Add(-30 * time.Minute), MaxPoints: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, result.Resolution)
+
+	r.SetAnnotations(func(since, until time.Time) []Annotation {
+		return []Annotation{{Time: since, Title: "latency anomaly"}}
+	})
+	result, err = r.Query(ctx, Query{Metric: "latency_ms", Key: "chat", Since: clock.Add(-30 * time.Minute)})
+	require.NoError(t, err)
+	assert.Empty(t, result.Series)
+	require.Len(t, result.Annotations, 1)
+	assert.Equal(t, result.Since, result.Annotations[0].Time)
+	_, err = r.Query(ctx, Query{})
+	assert.Error(t, err)
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r, store := newTestRecorder(&clock)
+	r.AddSource(func() []Sample { return []Sample{{Metric: "m", Value: 1}} })
+	for i := 0; i < 3*60; i++ {
+		require.NoError(t, r.Sample(ctx))
+		clock = clock.Add(time.Minute)
+	}
+
+	minutes, err := store.Read(ctx, time.Minute, "m", "", time.Time{}, clock)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(minutes[""]), 60+10, "minutes are kept for an hour, expired every 10 minutes")
+	assert.False(t, minutes[""][0].Time.Before(clock.Add(-time.Hour-10*time.Minute)))
+
+	tens, err := store.Read(ctx, 10*time.Minute, "m", "", time.Time{}, clock)
+	require.NoError(t, err)
+	assert.Len(t, tens[""], 17)
+}
+
+func TestFlush(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC)
+	r, store := newTestRecorder(&clock)
+	r.AddSource(func() []Sample { return []Sample{{Metric: "m", Value: 3}} })
+	require.NoError(t, r.Sample(ctx))
+	require.NoError(t, r.Flush(ctx))
+
+	minutes, err := store.Read(ctx, time.Minute, "m", "", time.Time{}, clock.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, minutes[""], 1)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), minutes[""][0].Time)
+
+	// The flushed bucket keeps filling and replaces its point when written
+	require.NoError(t, r.Sample(ctx))
+	result, err := r.Query(ctx, Query{Metric: "m", Since: clock.Add(-time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, result.Series[0].Points, 1)
+	assert.Equal(t, int64(2), result.Series[0].Points[0].Count)
+}
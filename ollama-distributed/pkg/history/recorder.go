@@ -0,0 +1,298 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// expireInterval is how often points past their retention are deleted
+const expireInterval = 10 * time.Minute
+
+// counter is the last value seen of a counter
+type counter struct {
+	value float64
+	at    time.Time
+}
+
+// Recorder samples sources and rolls the samples up into the buckets of
+// every tier
+type Recorder struct {
+	cfg   Config
+	store Store
+
+	mu          sync.Mutex
+	sources     []Source
+	annotations func(since, until time.Time) []Annotation
+	counters    map[seriesID]counter
+	// open holds the bucket being filled of every series, by tier
+	open       []map[seriesID]*bucket
+	metrics    map[string]bool
+	lastExpire time.Time
+	now        func() time.Time
+}
+
+// NewRecorder creates a recorder keeping points in store
+func NewRecorder(cfg Config, store Store) *Recorder {
+	cfg = cfg.withDefaults()
+	r := &Recorder{
+		cfg:      cfg,
+		store:    store,
+		counters: make(map[seriesID]counter),
+		open:     make([]map[seriesID]*bucket, len(cfg.Tiers)),
+		metrics:  make(map[string]bool),
+		now:      time.Now,
+	}
+	for i := range r.open {
+		r.open[i] = make(map[seriesID]*bucket)
+	}
+	return r
+}
+
+// AddSource adds a source sampled every interval
+func (r *Recorder) AddSource(source Source) {
+	r.mu.Lock()
+	r.sources = append(r.sources, source)
+	r.mu.Unlock()
+}
+
+// SetAnnotations sets where the annotations of query results come from
+func (r *Recorder) SetAnnotations(annotations func(since, until time.Time) []Annotation) {
+	r.mu.Lock()
+	r.annotations = annotations
+	r.mu.Unlock()
+}
+
+// Tiers returns the resolutions and retentions points are kept at
+func (r *Recorder) Tiers() []Tier {
+	return r.cfg.Tiers
+}
+
+// Interval returns how often the sources are sampled
+func (r *Recorder) Interval() time.Duration {
+	return r.cfg.Interval
+}
+
+// Metrics returns the names of the metrics sampled so far
+func (r *Recorder) Metrics() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics := make([]string, 0, len(r.metrics))
+	for metric := range r.metrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// Run samples the sources every interval until ctx is done, then writes
+// the buckets being filled
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := r.Flush(flushCtx); err != nil {
+				slog.Warn("Failed to write metrics history", "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := r.Sample(ctx); err != nil {
+				slog.Warn("Failed to record metrics history", "error", err)
+			}
+		}
+	}
+}
+
+// Sample samples the sources, writes the buckets that closed and deletes
+// the points past their retention
+func (r *Recorder) Sample(ctx context.Context) error {
+	r.mu.Lock()
+	sources := append([]Source(nil), r.sources...)
+	r.mu.Unlock()
+
+	var samples []Sample
+	for _, source := range sources {
+		samples = append(samples, source()...)
+	}
+
+	r.mu.Lock()
+	now := r.now()
+	closed := r.closeBuckets(now, false)
+	for _, sample := range samples {
+		id := seriesID{sample.Metric, sample.Key}
+		value := sample.Value
+		if sample.Counter {
+			last, ok := r.counters[id]
+			r.counters[id] = counter{value: sample.Value, at: now}
+			// Counters are recorded from their second sample on, and a
+			// counter going down was reset
+			elapsed := now.Sub(last.at).Seconds()
+			if !ok || elapsed <= 0 || sample.Value < last.value {
+				continue
+			}
+			value = (sample.Value - last.value) / elapsed
+		}
+		r.metrics[sample.Metric] = true
+
+		for i, tier := range r.cfg.Tiers {
+			start := now.Truncate(tier.Resolution)
+			b := r.open[i][id]
+			if b == nil {
+				b = &bucket{start: start}
+				r.open[i][id] = b
+			}
+			b.add(value)
+		}
+	}
+	expire := now.Sub(r.lastExpire) >= expireInterval
+	if expire {
+		r.lastExpire = now
+	}
+	r.mu.Unlock()
+
+	err := r.write(ctx, closed)
+	if expire {
+		for _, tier := range r.cfg.Tiers {
+			if expireErr := r.store.Expire(ctx, tier.Resolution, now.Add(-tier.Retention)); expireErr != nil {
+				err = errors.Join(err, expireErr)
+			}
+		}
+	}
+	return err
+}
+
+// Flush writes the buckets being filled, which stay open
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	open := r.closeBuckets(r.now(), true)
+	r.mu.Unlock()
+	return r.write(ctx, open)
+}
+
+// closedBucket is a bucket to write
+type closedBucket struct {
+	tier   int
+	id     seriesID
+	bucket *bucket
+}
+
+// closeBuckets removes and returns the buckets that ended by now, or
+// returns every bucket, leaving them open, when all is set; r.mu must be
+// held
+func (r *Recorder) closeBuckets(now time.Time, all bool) []closedBucket {
+	var closed []closedBucket
+	for i, tier := range r.cfg.Tiers {
+		start := now.Truncate(tier.Resolution)
+		for id, b := range r.open[i] {
+			switch {
+			case b.start.Before(start):
+				closed = append(closed, closedBucket{tier: i, id: id, bucket: b})
+				delete(r.open[i], id)
+			case all:
+				copied := *b
+				closed = append(closed, closedBucket{tier: i, id: id, bucket: &copied})
+			}
+		}
+	}
+	return closed
+}
+
+// write writes buckets to the store, by tier
+func (r *Recorder) write(ctx context.Context, buckets []closedBucket) error {
+	points := make([][]SeriesPoint, len(r.cfg.Tiers))
+	for _, c := range buckets {
+		points[c.tier] = append(points[c.tier], SeriesPoint{Metric: c.id.metric, Key: c.id.key, Point: c.bucket.point()})
+	}
+	var err error
+	for i, tier := range r.cfg.Tiers {
+		if len(points[i]) == 0 {
+			continue
+		}
+		if writeErr := r.store.Write(ctx, tier.Resolution, points[i]); writeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to write %s points: %w", tier.Resolution, writeErr))
+		}
+	}
+	return err
+}
+
+// Query returns the series of a metric over a time range, at the finest
+// resolution retained for the whole range that gives at most MaxPoints
+// points per series. The buckets being filled are included.
+func (r *Recorder) Query(ctx context.Context, q Query) (*Result, error) {
+	if q.Metric == "" {
+		return nil, fmt.Errorf("metric is required")
+	}
+	now := r.now()
+	if q.Until.IsZero() {
+		q.Until = now
+	}
+	if q.Since.IsZero() {
+		q.Since = q.Until.Add(-24 * time.Hour)
+	}
+	if !q.Since.Before(q.Until) {
+		return nil, fmt.Errorf("since must be before until")
+	}
+	if q.MaxPoints <= 0 {
+		q.MaxPoints = DefaultMaxPoints
+	}
+
+	tier := len(r.cfg.Tiers) - 1
+	for i, t := range r.cfg.Tiers {
+		if now.Sub(q.Since) <= t.Retention && int(q.Until.Sub(q.Since)/t.Resolution) <= q.MaxPoints {
+			tier = i
+			break
+		}
+	}
+	resolution := r.cfg.Tiers[tier].Resolution
+	since := q.Since.Truncate(resolution)
+
+	points, err := r.store.Read(ctx, resolution, q.Metric, q.Key, since, q.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	for id, b := range r.open[tier] {
+		if id.metric != q.Metric || (q.Key != "" && id.key != q.Key) {
+			continue
+		}
+		if b.start.Before(since) || !b.start.Before(q.Until) {
+			continue
+		}
+		points[id.key] = append(replacePoint(points[id.key], b.start), b.point())
+	}
+	annotations := r.annotations
+	r.mu.Unlock()
+
+	result := &Result{
+		Metric:     q.Metric,
+		Resolution: resolution,
+		Since:      since,
+		Until:      q.Until,
+		Series:     group(points),
+	}
+	if annotations != nil {
+		result.Annotations = annotations(since, q.Until)
+	}
+	return result, nil
+}
+
+// replacePoint drops the point at t, which a bucket being filled supersedes
+func replacePoint(points []Point, t time.Time) []Point {
+	for i, p := range points {
+		if p.Time.Equal(t) {
+			return append(points[:i], points[i+1:]...)
+		}
+	}
+	return points
+}
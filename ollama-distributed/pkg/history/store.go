@@ -0,0 +1,189 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// SeriesPoint is a point of a series
+type SeriesPoint struct {
+	Metric string
+	Key    string
+	Point
+}
+
+// Store keeps points by resolution
+type Store interface {
+	// Write stores points of a resolution, replacing those of the same
+	// series and time
+	Write(ctx context.Context, resolution time.Duration, points []SeriesPoint) error
+	// Read returns the points of a metric at a resolution in [since,
+	// until) by key, oldest first. An empty key matches every key.
+	Read(ctx context.Context, resolution time.Duration, metric, key string, since, until time.Time) (map[string][]Point, error)
+	// Expire deletes the points of a resolution older than before
+	Expire(ctx context.Context, resolution time.Duration, before time.Time) error
+	Close() error
+}
+
+// OpenStore opens the store configured by cfg, migrating the database of a
+// database store
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.Store {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreDatabase:
+		db := cfg.Database
+		manager, err := database.NewManager(&db)
+		if err != nil {
+			return nil, err
+		}
+		if err := manager.RunMigrations(context.Background()); err != nil {
+			manager.Close()
+			return nil, err
+		}
+		return NewDatabaseStore(manager), nil
+	default:
+		return nil, fmt.Errorf("unknown history store %q", cfg.Store)
+	}
+}
+
+// MemoryStore keeps points in memory, for as long as the tiers retain them
+type MemoryStore struct {
+	mu     sync.RWMutex
+	points map[time.Duration]map[seriesID][]Point
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{points: make(map[time.Duration]map[seriesID][]Point)}
+}
+
+// Write stores points of a resolution
+func (s *MemoryStore) Write(_ context.Context, resolution time.Duration, points []SeriesPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tier := s.points[resolution]
+	if tier == nil {
+		tier = make(map[seriesID][]Point)
+		s.points[resolution] = tier
+	}
+	for _, p := range points {
+		id := seriesID{p.Metric, p.Key}
+		series := tier[id]
+		i := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(p.Time) })
+		switch {
+		case i < len(series) && series[i].Time.Equal(p.Time):
+			series[i] = p.Point
+		default:
+			series = append(series, Point{})
+			copy(series[i+1:], series[i:])
+			series[i] = p.Point
+		}
+		tier[id] = series
+	}
+	return nil
+}
+
+// Read returns the points of a metric at a resolution in [since, until)
+func (s *MemoryStore) Read(_ context.Context, resolution time.Duration, metric, key string, since, until time.Time) (map[string][]Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string][]Point)
+	for id, series := range s.points[resolution] {
+		if id.metric != metric || (key != "" && id.key != key) {
+			continue
+		}
+		for _, p := range series {
+			if !p.Time.Before(since) && p.Time.Before(until) {
+				result[id.key] = append(result[id.key], p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Expire deletes the points of a resolution older than before
+func (s *MemoryStore) Expire(_ context.Context, resolution time.Duration, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, series := range s.points[resolution] {
+		i := sort.Search(len(series), func(i int) bool { return !series[i].Time.Before(before) })
+		if i == len(series) {
+			delete(s.points[resolution], id)
+			continue
+		}
+		s.points[resolution][id] = append([]Point(nil), series[i:]...)
+	}
+	return nil
+}
+
+// Close does nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// DatabaseStore keeps points in the metric_points table
+type DatabaseStore struct {
+	db *database.Manager
+}
+
+// NewDatabaseStore creates a store on db, whose migrations must have run
+func NewDatabaseStore(db *database.Manager) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Write upserts points of a resolution
+func (s *DatabaseStore) Write(ctx context.Context, resolution time.Duration, points []SeriesPoint) error {
+	rows := make([]*database.MetricPoint, len(points))
+	for i, p := range points {
+		rows[i] = &database.MetricPoint{
+			Resolution: resolution,
+			Metric:     p.Metric,
+			Key:        p.Key,
+			Bucket:     p.Time,
+			Mean:       p.Mean,
+			Min:        p.Min,
+			Max:        p.Max,
+			Count:      p.Count,
+		}
+	}
+	return s.db.WriteMetricPoints(ctx, rows)
+}
+
+// Read returns the points of a metric at a resolution in [since, until)
+func (s *DatabaseStore) Read(ctx context.Context, resolution time.Duration, metric, key string, since, until time.Time) (map[string][]Point, error) {
+	rows, err := s.db.ListMetricPoints(ctx, resolution, metric, key, since, until)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]Point)
+	for _, row := range rows {
+		result[row.Key] = append(result[row.Key], Point{
+			Time:  row.Bucket,
+			Mean:  row.Mean,
+			Min:   row.Min,
+			Max:   row.Max,
+			Count: row.Count,
+		})
+	}
+	return result, nil
+}
+
+// Expire deletes the points of a resolution older than before
+func (s *DatabaseStore) Expire(ctx context.Context, resolution time.Duration, before time.Time) error {
+	_, err := s.db.DeleteMetricPoints(ctx, resolution, before)
+	return err
+}
+
+// Close closes the database
+func (s *DatabaseStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,351 @@
+// Package idempotency makes retries of mutating API requests safe. A client
+// sends an Idempotency-Key header with a POST; the first request with a key
+// runs and its response is kept for a TTL, and retries with the same key,
+// e.g. after a network error, get that response again instead of repeating
+// an expensive operation such as a model pull or job submission.
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header names
+const (
+	// KeyHeader carries the client chosen key of a request
+	KeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed for a retry
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Defaults of the cache
+const (
+	DefaultTTL                 = 24 * time.Hour
+	DefaultMaxEntries          = 10000
+	DefaultMaxEntriesPerCaller = 100
+	DefaultMaxBodyBytes        = 1 << 20
+	DefaultMaxTotalBytes       = 128 << 20
+	// maxKeyLength bounds the keys clients choose
+	maxKeyLength = 255
+)
+
+// Config configures idempotency keys
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long responses are kept for retries
+	TTL time.Duration `yaml:"ttl"`
+	// MaxEntries bounds the responses kept; the oldest are dropped first
+	MaxEntries int `yaml:"max_entries" mapstructure:"max_entries"`
+	// MaxEntriesPerCaller bounds the responses kept for one caller, so
+	// that a caller sending many keys drops its own oldest responses
+	// rather than other callers'
+	MaxEntriesPerCaller int `yaml:"max_entries_per_caller" mapstructure:"max_entries_per_caller"`
+	// MaxBodyBytes bounds a kept response body. Requests with larger
+	// responses, e.g. streamed generations, are not kept and run again
+	// when retried.
+	MaxBodyBytes int `yaml:"max_body_bytes" mapstructure:"max_body_bytes"`
+	// MaxTotalBytes bounds the response bodies kept in all; the oldest
+	// are dropped first
+	MaxTotalBytes int `yaml:"max_total_bytes" mapstructure:"max_total_bytes"`
+}
+
+// Validate checks the bounds are not negative, and a kept body fits in the
+// total
+func (c Config) Validate() error {
+	if c.TTL < 0 || c.MaxEntries < 0 || c.MaxEntriesPerCaller < 0 || c.MaxBodyBytes < 0 || c.MaxTotalBytes < 0 {
+		return fmt.Errorf("idempotency TTL and bounds must not be negative")
+	}
+	if d := c.withDefaults(); d.MaxBodyBytes > d.MaxTotalBytes {
+		return fmt.Errorf("idempotency max_body_bytes %d exceeds max_total_bytes %d", d.MaxBodyBytes, d.MaxTotalBytes)
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.TTL == 0 {
+		c.TTL = DefaultTTL
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = DefaultMaxEntries
+	}
+	if c.MaxEntriesPerCaller == 0 {
+		c.MaxEntriesPerCaller = DefaultMaxEntriesPerCaller
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if c.MaxTotalBytes == 0 {
+		c.MaxTotalBytes = DefaultMaxTotalBytes
+	}
+	return c
+}
+
+// entry is a request seen with a key, and its response once it completed
+type entry struct {
+	key         string
+	caller      string
+	fingerprint string
+	expires     time.Time
+	done        bool
+
+	status      int
+	contentType string
+	body        []byte
+
+	// The entry's elements in the cache's order and its caller's
+	order       *list.Element
+	callerOrder *list.Element
+}
+
+// Cache keeps the responses of requests made with an idempotency key
+type Cache struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   *list.List            // of *entry, oldest first
+	callers map[string]*list.List // of each caller's *entry, oldest first
+	bytes   int                   // of the bodies kept
+}
+
+// NewCache creates an empty cache
+func NewCache(cfg Config) (*Cache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Cache{
+		cfg:     cfg.withDefaults(),
+		now:     time.Now,
+		entries: make(map[string]*entry),
+		order:   list.New(),
+		callers: make(map[string]*list.List),
+	}, nil
+}
+
+// Config returns the configuration, defaults applied
+func (ca *Cache) Config() Config {
+	return ca.cfg
+}
+
+// Handle runs a request with an idempotency key once. Retries get the
+// response of the first request, a retry while it is still running 409
+// Conflict, and a request reusing a key for a different method, path or
+// body 422 Unprocessable Entity. Requests without a key, or other than
+// POST, run as usual. Server errors are not kept, so that a retry runs the
+// request again.
+func (ca *Cache) Handle(c *gin.Context) {
+	key := c.GetHeader(KeyHeader)
+	if key == "" || c.Request.Method != http.MethodPost {
+		c.Next()
+		return
+	}
+	if len(key) > maxKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s longer than %d characters", KeyHeader, maxKeyLength)})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Keys are chosen by clients, so they are scoped to the caller
+	who := caller(c)
+	key = who + "\x00" + key
+	fingerprint := fingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+	ca.mu.Lock()
+	e, seen := ca.lookup(key)
+	switch {
+	case seen && e.fingerprint != fingerprint:
+		ca.mu.Unlock()
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s was used for a different request", KeyHeader)})
+		return
+	case seen && !e.done:
+		ca.mu.Unlock()
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a request with this %s is in progress", KeyHeader)})
+		return
+	case seen:
+		status, contentType, body := e.status, e.contentType, e.body
+		ca.mu.Unlock()
+		c.Header(ReplayedHeader, "true")
+		c.Data(status, contentType, body)
+		c.Abort()
+		return
+	}
+	e = &entry{key: key, caller: who, fingerprint: fingerprint, expires: ca.now().Add(ca.cfg.TTL)}
+	ca.insert(e)
+	ca.mu.Unlock()
+
+	writer := &recordingWriter{ResponseWriter: c.Writer, limit: ca.cfg.MaxBodyBytes}
+	c.Writer = writer
+	completed := false
+	defer func() {
+		// A request that panicked or failed can be retried
+		if !completed {
+			ca.release(key, e)
+		}
+	}()
+	c.Next()
+
+	status := writer.Status()
+	if status >= http.StatusInternalServerError || writer.overflow {
+		return
+	}
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	completed = true
+	if ca.entries[key] != e {
+		// Dropped while running to make room for other requests
+		return
+	}
+	e.done = true
+	e.status = status
+	e.contentType = writer.Header().Get("Content-Type")
+	e.body = writer.body.Bytes()
+	ca.bytes += len(e.body)
+	for ca.bytes > ca.cfg.MaxTotalBytes {
+		ca.remove(ca.order.Front().Value.(*entry))
+	}
+}
+
+// Len returns the number of keys kept
+func (ca *Cache) Len() int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.expire()
+	return len(ca.entries)
+}
+
+// Bytes returns the size of the response bodies kept
+func (ca *Cache) Bytes() int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.expire()
+	return ca.bytes
+}
+
+// lookup returns the live entry of a key; ca.mu must be held
+func (ca *Cache) lookup(key string) (*entry, bool) {
+	ca.expire()
+	e, ok := ca.entries[key]
+	return e, ok
+}
+
+// insert adds an entry, dropping the caller's oldest beyond its bound and
+// then the oldest beyond the total; ca.mu must be held
+func (ca *Cache) insert(e *entry) {
+	mine := ca.callers[e.caller]
+	if mine == nil {
+		mine = list.New()
+	}
+	for mine.Len() >= ca.cfg.MaxEntriesPerCaller {
+		ca.remove(mine.Front().Value.(*entry))
+	}
+	// remove forgets a caller's list along with its last entry
+	ca.callers[e.caller] = mine
+	ca.entries[e.key] = e
+	e.order = ca.order.PushBack(e)
+	e.callerOrder = mine.PushBack(e)
+	for len(ca.entries) > ca.cfg.MaxEntries {
+		ca.remove(ca.order.Front().Value.(*entry))
+	}
+}
+
+// expire drops the entries past their TTL; ca.mu must be held. Entries
+// expire in insertion order since they share the TTL.
+func (ca *Cache) expire() {
+	now := ca.now()
+	for oldest := ca.order.Front(); oldest != nil; oldest = ca.order.Front() {
+		e := oldest.Value.(*entry)
+		if now.Before(e.expires) {
+			return
+		}
+		ca.remove(e)
+	}
+}
+
+// remove forgets an entry kept in the cache; ca.mu must be held
+func (ca *Cache) remove(e *entry) {
+	delete(ca.entries, e.key)
+	ca.order.Remove(e.order)
+	mine := ca.callers[e.caller]
+	mine.Remove(e.callerOrder)
+	if mine.Len() == 0 {
+		delete(ca.callers, e.caller)
+	}
+	ca.bytes -= len(e.body)
+}
+
+// release forgets a request that did not complete, unless it was dropped
+// and its key reused since
+func (ca *Cache) release(key string, e *entry) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.entries[key] == e {
+		ca.remove(e)
+	}
+}
+
+// caller identifies who made a request, as authenticated
+func caller(c *gin.Context) string {
+	if tenant := c.GetString("tenant"); tenant != "" {
+		return "tenant:" + tenant + "/" + c.GetString("user_id")
+	}
+	if user := c.GetString("user_id"); user != "" {
+		return "user:" + user
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// fingerprint identifies a request by its method, URI and body
+func fingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, uri)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps the body written, up to a limit
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordingWriter) keep(b []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(b) > w.limit {
+		w.overflow = true
+		w.body = bytes.Buffer{}
+		return
+	}
+	w.body.Write(b)
+}
@@ -0,0 +1,153 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T, cfg Config, handler gin.HandlerFunc) (*gin.Engine, *Cache) {
+	gin.SetMode(gin.TestMode)
+	cache, err := NewCache(cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(cache.Handle)
+	router.POST("/jobs", handler)
+	return router, cache
+}
+
+func post(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(KeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRetriesReplayTheResponse(t *testing.T) {
+	runs := 0
+	router, cache := newRouter(t, Config{}, func(c *gin.Context) {
+		runs++
+		c.JSON(http.StatusCreated, gin.H{"job": runs})
+	})
+
+	first := post(router, "submit-1", `{"model":"llama"}`)
+	retry := post(router, "submit-1", `{"model":"llama"}`)
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, post(router, "submit-1", `{"model":"other"}`).Code)
+	post(router, "", `{"model":"llama"}`)
+	post(router, "submit-2", `{"model":"llama"}`)
+	assert.Equal(t, 3, runs, "requests without or with new keys run")
+
+	now := time.Now()
+	cache.now = func() time.Time { return now.Add(DefaultTTL) }
+	post(router, "submit-1", `{"model":"llama"}`)
+	assert.Equal(t, 4, runs, "responses are kept for the TTL")
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestFailedRequestsRunAgain(t *testing.T) {
+	status := http.StatusBadGateway
+	runs := 0
+	router, _ := newRouter(t, Config{MaxBodyBytes: 8}, func(c *gin.Context) {
+		runs++
+		c.String(status, "pulled")
+	})
+
+	post(router, "pull", "")
+	post(router, "pull", "")
+	assert.Equal(t, 2, runs, "server errors are not kept")
+
+	status = http.StatusOK
+	post(router, "pull", "")
+	post(router, "pull", "")
+	assert.Equal(t, 3, runs)
+
+	router, _ = newRouter(t, Config{MaxBodyBytes: 4}, func(c *gin.Context) {
+		runs++
+		c.String(http.StatusOK, "too long to keep")
+	})
+	post(router, "pull", "")
+	post(router, "pull", "")
+	assert.Equal(t, 5, runs, "responses beyond the limit are not kept")
+}
+
+func TestConcurrentRetryConflicts(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	router, _ := newRouter(t, Config{}, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusAccepted)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(router, "member-add", "") }()
+	<-started
+	assert.Equal(t, http.StatusConflict, post(router, "member-add", "").Code)
+	close(release)
+	assert.Equal(t, http.StatusAccepted, (<-done).Code)
+}
+
+func TestCallersDropTheirOwnOldestKeys(t *testing.T) {
+	runs := 0
+	router, cache := newRouter(t, Config{MaxEntriesPerCaller: 2}, func(c *gin.Context) {
+		runs++
+		c.Status(http.StatusCreated)
+	})
+
+	other := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	other.RemoteAddr = "198.51.100.7:1234"
+	other.Header.Set(KeyHeader, "submit-1")
+	router.ServeHTTP(httptest.NewRecorder(), other)
+
+	for _, key := range []string{"submit-1", "submit-2", "submit-3"} {
+		post(router, key, "")
+	}
+	assert.Equal(t, 4, runs)
+	assert.Equal(t, 3, cache.Len(), "the caller keeps its two latest keys")
+
+	post(router, "submit-1", "")
+	assert.Equal(t, 5, runs, "the caller's oldest key was dropped")
+	replay := httptest.NewRecorder()
+	router.ServeHTTP(replay, other)
+	assert.Equal(t, "true", replay.Header().Get(ReplayedHeader), "other callers' keys are kept")
+}
+
+func TestTotalBytesAreBounded(t *testing.T) {
+	runs := 0
+	router, cache := newRouter(t, Config{MaxBodyBytes: 4, MaxTotalBytes: 10}, func(c *gin.Context) {
+		runs++
+		c.String(http.StatusOK, "1234")
+	})
+
+	for _, key := range []string{"pull-1", "pull-2", "pull-3"} {
+		post(router, key, "")
+	}
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, 8, cache.Bytes())
+
+	post(router, "pull-3", "")
+	assert.Equal(t, 3, runs, "the latest responses are kept")
+	post(router, "pull-1", "")
+	assert.Equal(t, 4, runs, "the oldest response was dropped")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{TTL: time.Hour}.Validate())
+	assert.Error(t, Config{MaxEntries: -1}.Validate())
+	assert.Error(t, Config{MaxEntriesPerCaller: -1}.Validate())
+	assert.Error(t, Config{MaxBodyBytes: 2 << 20, MaxTotalBytes: 1 << 20}.Validate())
+}
@@ -6,10 +6,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/models"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/orchestration"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/rs/zerolog/log"
 )
@@ -18,7 +20,7 @@ import (
 type DistributedInferenceEngine struct {
 	// Core components
 	p2pNode          *p2p.Node
-	modelManager     *models.DistributedModelManager
+	modelManager     ModelManager
 	partitionManager *partitioning.PartitionManager
 	orchestrator     *orchestration.OrchestrationEngine
 
@@ -27,7 +29,7 @@ type DistributedInferenceEngine struct {
 	inferenceMutex   sync.RWMutex
 
 	// Node coordination
-	availableNodes map[peer.ID]*NodeInfo
+	availableNodes map[peer.ID]*types.NodeInfo
 	nodesMutex     sync.RWMutex
 
 	// Configuration
@@ -37,11 +39,21 @@ type DistributedInferenceEngine struct {
 	metrics *InferenceMetrics
 }
 
+// ModelManager is what the engine needs of the model manager, as
+// *models.DistributedModelManager provides
+type ModelManager interface {
+	ModelInfo(modelName string) (*types.ModelInfo, error)
+	AddModelInfo(modelName, modelPath string) (*types.ModelInfo, error)
+	Refs() *models.ModelRefs
+}
+
+var _ ModelManager = (*models.DistributedModelManager)(nil)
+
 // DistributedInferenceConfig configures the distributed inference engine
 type DistributedInferenceConfig struct {
 	MaxConcurrentInferences int           `json:"max_concurrent_inferences"`
 	InferenceTimeout        time.Duration `json:"inference_timeout"`
-	PartitionStrategy       string        `json:"partition_strategy"`
+	PartitionStrategy       string        `json:"partition_strategy"` // "auto" picks by past outcomes
 	AggregationStrategy     string        `json:"aggregation_strategy"`
 	MinNodesRequired        int           `json:"min_nodes_required"`
 	LoadBalancingEnabled    bool          `json:"load_balancing_enabled"`
@@ -51,6 +63,7 @@ type DistributedInferenceConfig struct {
 // DistributedInference represents a distributed inference session
 type DistributedInference struct {
 	ID         string
+	RequestID  string // of the API request, passed on to the nodes
 	ModelName  string
 	Prompt     string
 	Parameters map[string]interface{}
@@ -63,6 +76,10 @@ type DistributedInference struct {
 	// Partitioning
 	Partitions    []*InferencePartition
 	PartitionPlan *partitioning.PartitionPlan
+	SelectionID   string // strategy selection completed with the outcome
+
+	// lease keeps the model and the replicas used from being removed
+	lease *models.ModelLease
 
 	// Node coordination
 	AssignedNodes []peer.ID
@@ -118,29 +135,9 @@ type InferenceResult struct {
 	Metadata       map[string]interface{}
 }
 
-// NodeInfo represents information about an available node
-type NodeInfo struct {
-	ID              peer.ID
-	Capabilities    NodeCapabilities
-	CurrentLoad     float64
-	AvailableMemory int64
-	LastSeen        time.Time
-	Status          NodeStatus
-}
-
-// NodeCapabilities represents what a node can do
-type NodeCapabilities struct {
-	SupportedModels  []string
-	MaxModelSize     int64
-	GPUMemory        int64
-	CPUCores         int
-	NetworkBandwidth int64
-}
-
 // Status enums
 type InferenceStatus string
 type PartitionStatus string
-type NodeStatus string
 
 const (
 	InferenceStatusPending      InferenceStatus = "pending"
@@ -154,10 +151,6 @@ const (
 	PartitionStatusExecuting PartitionStatus = "executing"
 	PartitionStatusCompleted PartitionStatus = "completed"
 	PartitionStatusFailed    PartitionStatus = "failed"
-
-	NodeStatusAvailable   NodeStatus = "available"
-	NodeStatusBusy        NodeStatus = "busy"
-	NodeStatusUnavailable NodeStatus = "unavailable"
 )
 
 // InferenceMetrics tracks distributed inference performance
@@ -174,7 +167,7 @@ type InferenceMetrics struct {
 // NewDistributedInferenceEngine creates a new distributed inference engine
 func NewDistributedInferenceEngine(
 	p2pNode *p2p.Node,
-	modelManager *models.DistributedModelManager,
+	modelManager ModelManager,
 	partitionManager *partitioning.PartitionManager,
 	orchestrator *orchestration.OrchestrationEngine,
 	config *DistributedInferenceConfig,
@@ -191,13 +184,23 @@ func NewDistributedInferenceEngine(
 		}
 	}
 
+	if p2pNode != nil && partitionManager != nil {
+		partitionManager.SetLatencyProvider(func(nodeID string) (time.Duration, bool) {
+			id, err := peer.Decode(nodeID)
+			if err != nil {
+				return 0, false
+			}
+			return p2pNode.GetPeerLatency(id)
+		})
+	}
+
 	return &DistributedInferenceEngine{
 		p2pNode:          p2pNode,
 		modelManager:     modelManager,
 		partitionManager: partitionManager,
 		orchestrator:     orchestrator,
 		activeInferences: make(map[string]*DistributedInference),
-		availableNodes:   make(map[peer.ID]*NodeInfo),
+		availableNodes:   make(map[peer.ID]*types.NodeInfo),
 		config:           config,
 		metrics: &InferenceMetrics{
 			LastUpdated: time.Now(),
@@ -215,6 +218,7 @@ func (die *DistributedInferenceEngine) ExecuteDistributedInference(
 	// Create inference session
 	inference := &DistributedInference{
 		ID:          fmt.Sprintf("inf_%d", time.Now().UnixNano()),
+		RequestID:   logging.RequestIDFromContext(ctx),
 		ModelName:   modelName,
 		Prompt:      prompt,
 		Parameters:  parameters,
@@ -225,6 +229,14 @@ func (die *DistributedInferenceEngine) ExecuteDistributedInference(
 		ErrorChan:   make(chan error, 1),
 	}
 
+	// Keep the model from being removed while the inference executes
+	lease, err := die.modelManager.Refs().Acquire(modelName)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+	inference.lease = lease
+
 	// Create context with timeout
 	inference.Context, inference.CancelFunc = context.WithTimeout(ctx, die.config.InferenceTimeout)
 	defer inference.CancelFunc()
@@ -254,7 +266,7 @@ func (die *DistributedInferenceEngine) ExecuteDistributedInference(
 }
 
 // executeInferencePipeline executes the complete distributed inference pipeline
-func (die *DistributedInferenceEngine) executeInferencePipeline(inference *DistributedInference) (*InferenceResult, error) {
+func (die *DistributedInferenceEngine) executeInferencePipeline(inference *DistributedInference) (result *InferenceResult, err error) {
 	log.Info().
 		Str("inference_id", inference.ID).
 		Str("model", inference.ModelName).
@@ -270,6 +282,13 @@ func (die *DistributedInferenceEngine) executeInferencePipeline(inference *Distr
 	if err != nil {
 		return nil, fmt.Errorf("failed to select nodes: %w", err)
 	}
+	replicas := make([]string, len(nodes))
+	for i, node := range nodes {
+		replicas[i] = node.String()
+	}
+	if err := inference.lease.AddReplicas(replicas...); err != nil {
+		return nil, err
+	}
 	inference.AssignedNodes = nodes
 
 	// Step 3: Create partition plan
@@ -279,6 +298,7 @@ func (die *DistributedInferenceEngine) executeInferencePipeline(inference *Distr
 		return nil, fmt.Errorf("failed to create partition plan: %w", err)
 	}
 	inference.PartitionPlan = partitionPlan
+	defer func() { die.completeSelection(inference, result, err) }()
 
 	// Step 4: Execute partitions across nodes
 	inference.Status = InferenceStatusExecuting
@@ -312,7 +332,7 @@ func (die *DistributedInferenceEngine) executeInferencePipeline(inference *Distr
 // ensureModelDistribution ensures the model is loaded on required nodes
 func (die *DistributedInferenceEngine) ensureModelDistribution(inference *DistributedInference) error {
 	// Check if model is available in the distributed system
-	model, err := die.modelManager.GetModel(inference.ModelName)
+	model, err := die.modelManager.ModelInfo(inference.ModelName)
 	if err != nil {
 		// Model not found, try to add it to the distributed system
 		log.Info().
@@ -320,16 +340,16 @@ func (die *DistributedInferenceEngine) ensureModelDistribution(inference *Distri
 			Msg("Model not found in distributed system, attempting to add")
 
 		// This would trigger model loading and distribution
-		_, err := die.modelManager.AddModel(inference.ModelName, "/tmp/models/"+inference.ModelName)
+		_, err := die.modelManager.AddModelInfo(inference.ModelName, "/tmp/models/"+inference.ModelName)
 		return err
 	}
 
 	// Ensure model is replicated to enough nodes
 	requiredReplicas := die.config.MinNodesRequired
-	if len(model.Replicas) < requiredReplicas {
+	if len(model.Locations) < requiredReplicas {
 		log.Info().
 			Str("model", inference.ModelName).
-			Int("current_replicas", len(model.Replicas)).
+			Int("current_replicas", len(model.Locations)).
 			Int("required_replicas", requiredReplicas).
 			Msg("Insufficient model replicas, but continuing with available replicas")
 
@@ -346,17 +366,17 @@ func (die *DistributedInferenceEngine) selectNodesForInference(inference *Distri
 	defer die.nodesMutex.RUnlock()
 
 	// Get model information
-	model, err := die.modelManager.GetModel(inference.ModelName)
+	model, err := die.modelManager.ModelInfo(inference.ModelName)
 	if err != nil {
 		return nil, fmt.Errorf("model not found: %w", err)
 	}
 
 	// Filter nodes that have the model
 	candidateNodes := make([]peer.ID, 0)
-	for _, replica := range model.Replicas {
-		if peerID, err := peer.Decode(replica.PeerID); err == nil {
+	for _, location := range model.Locations {
+		if peerID, err := peer.Decode(location); err == nil {
 			if nodeInfo, exists := die.availableNodes[peerID]; exists {
-				if nodeInfo.Status == NodeStatusAvailable {
+				if nodeInfo.Online() {
 					candidateNodes = append(candidateNodes, peerID)
 				}
 			}
@@ -389,7 +409,7 @@ func (die *DistributedInferenceEngine) selectBestNodes(candidates []peer.ID, inf
 		if nodeInfo, exists := die.availableNodes[nodeID]; exists {
 			nodeLoads = append(nodeLoads, nodeLoad{
 				id:   nodeID,
-				load: nodeInfo.CurrentLoad,
+				load: nodeInfo.Load(),
 			})
 		}
 	}
@@ -448,6 +468,29 @@ func (die *DistributedInferenceEngine) GetMetrics() *InferenceMetrics {
 	return die.metrics
 }
 
+// UpdateNode adds or refreshes a node inference may run on, as the
+// scheduler knows it. Nodes with an ID that is not a peer ID are ignored.
+func (die *DistributedInferenceEngine) UpdateNode(node *types.NodeInfo) {
+	id, err := peer.Decode(node.ID)
+	if err != nil {
+		return
+	}
+	die.nodesMutex.Lock()
+	die.availableNodes[id] = node
+	die.nodesMutex.Unlock()
+}
+
+// RemoveNode stops running inference on a node
+func (die *DistributedInferenceEngine) RemoveNode(nodeID string) {
+	id, err := peer.Decode(nodeID)
+	if err != nil {
+		return
+	}
+	die.nodesMutex.Lock()
+	delete(die.availableNodes, id)
+	die.nodesMutex.Unlock()
+}
+
 // createPartitionPlan creates a partition plan for the inference
 func (die *DistributedInferenceEngine) createPartitionPlan(inference *DistributedInference, nodes []peer.ID) (*partitioning.PartitionPlan, error) {
 	// Create partition task
@@ -460,16 +503,59 @@ func (die *DistributedInferenceEngine) createPartitionPlan(inference *Distribute
 	}
 
 	// Convert peer IDs to node info
+	die.nodesMutex.RLock()
 	for i, nodeID := range nodes {
+		if node, ok := die.availableNodes[nodeID]; ok {
+			task.Nodes[i] = partitioning.NodeFromInfo(node)
+			continue
+		}
 		task.Nodes[i] = &partitioning.NodeInfo{
 			ID:       nodeID.String(),
 			Address:  nodeID.String(),
 			Metadata: make(map[string]interface{}),
 		}
 	}
+	die.nodesMutex.RUnlock()
+
+	strategy := die.config.PartitionStrategy
+	if strategy == "" {
+		strategy = "layerwise"
+	}
+	if strategy == "auto" {
+		selection, err := die.partitionManager.SelectBestStrategy(task)
+		if err != nil {
+			return nil, err
+		}
+		strategy = selection.Strategy
+		inference.SelectionID = selection.ID
+	}
 
 	// Use partition manager to create plan
-	return die.partitionManager.Partition(context.Background(), task, "layerwise")
+	plan, err := die.partitionManager.Partition(context.Background(), task, strategy)
+	if err != nil {
+		die.completeSelection(inference, nil, err)
+		return nil, err
+	}
+	return plan, nil
+}
+
+// completeSelection reports the outcome of an inference to the partition
+// manager, so the strategy it was partitioned with is chosen by results
+func (die *DistributedInferenceEngine) completeSelection(inference *DistributedInference, result *InferenceResult, err error) {
+	if inference.SelectionID == "" {
+		return
+	}
+	id := inference.SelectionID
+	inference.SelectionID = ""
+
+	latency := time.Since(inference.StartTime)
+	var throughput float64
+	if result != nil && latency > 0 {
+		throughput = float64(len(result.Tokens)) / latency.Seconds()
+	}
+	if cerr := die.partitionManager.CompleteSelection(id, err == nil, latency, throughput); cerr != nil {
+		log.Warn().Err(cerr).Str("inference_id", inference.ID).Msg("Failed to complete strategy selection")
+	}
 }
 
 // executePartitions executes inference partitions across nodes
@@ -559,6 +645,7 @@ func (die *DistributedInferenceEngine) executePartition(
 
 	log.Debug().
 		Str("inference_id", inference.ID).
+		Str("request_id", inference.RequestID).
 		Str("partition_id", partition.ID).
 		Str("node_id", partition.NodeID.String()).
 		Msg("Executing partition")
@@ -573,6 +660,7 @@ func (die *DistributedInferenceEngine) executePartition(
 		Metadata: map[string]interface{}{
 			"partition_id": partition.ID,
 			"inference_id": inference.ID,
+			"request_id":   inference.RequestID,
 		},
 	}
 
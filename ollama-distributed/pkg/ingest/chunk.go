@@ -0,0 +1,202 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Chunking strategies
+const (
+	// ChunkFixed cuts the text every Size characters
+	ChunkFixed = "fixed"
+	// ChunkSentence packs whole sentences into chunks
+	ChunkSentence = "sentence"
+	// ChunkParagraph packs whole paragraphs into chunks, splitting those
+	// longer than a chunk by sentence
+	ChunkParagraph = "paragraph"
+)
+
+// Defaults for unset chunking values
+const (
+	DefaultChunkSize    = 1000
+	DefaultChunkOverlap = 100
+)
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// ChunkingConfig configures how documents are split into chunks, each
+// embedded and indexed separately
+type ChunkingConfig struct {
+	// Strategy is fixed, sentence or paragraph (the default)
+	Strategy string `yaml:"strategy" json:"strategy,omitempty"`
+	// Size is the maximum length of a chunk in characters
+	Size int `yaml:"size" json:"size,omitempty"`
+	// Overlap is how many characters of the end of a chunk are repeated at
+	// the start of the next, keeping context across the cut
+	Overlap int `yaml:"overlap" json:"overlap,omitempty"`
+}
+
+// Validate checks the strategy and sizes
+func (c ChunkingConfig) Validate() error {
+	switch c.Strategy {
+	case "", ChunkFixed, ChunkSentence, ChunkParagraph:
+	default:
+		return fmt.Errorf("unknown chunking strategy %q", c.Strategy)
+	}
+	if c.Size < 0 || c.Overlap < 0 {
+		return fmt.Errorf("negative chunk size or overlap")
+	}
+	if c.Size > 0 && c.Overlap >= c.Size {
+		return fmt.Errorf("chunk overlap %d not smaller than the size %d", c.Overlap, c.Size)
+	}
+	return nil
+}
+
+// withDefaults fills in unset values
+func (c ChunkingConfig) withDefaults() ChunkingConfig {
+	if c.Strategy == "" {
+		c.Strategy = ChunkParagraph
+	}
+	if c.Size == 0 {
+		c.Size = DefaultChunkSize
+		if c.Overlap == 0 {
+			c.Overlap = DefaultChunkOverlap
+		}
+	}
+	if c.Overlap >= c.Size {
+		c.Overlap = c.Size / 10
+	}
+	return c
+}
+
+// Chunk splits text into chunks of at most cfg.Size characters
+func Chunk(text string, cfg ChunkingConfig) []string {
+	cfg = cfg.withDefaults()
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	switch cfg.Strategy {
+	case ChunkFixed:
+		return chunkFixed(text, cfg.Size, cfg.Overlap)
+	case ChunkSentence:
+		return pack(sentences(text), " ", cfg)
+	default:
+		var units []string
+		for _, paragraph := range paragraphBreak.Split(text, -1) {
+			paragraph = strings.TrimSpace(paragraph)
+			if paragraph == "" {
+				continue
+			}
+			if runeLen(paragraph) > cfg.Size {
+				// Sentences of a long paragraph are packed like paragraphs
+				units = append(units, sentences(paragraph)...)
+				continue
+			}
+			units = append(units, paragraph)
+		}
+		return pack(units, "\n\n", cfg)
+	}
+}
+
+// chunkFixed cuts text into windows of size characters, each starting
+// overlap characters before the end of the previous one
+func chunkFixed(text string, size, overlap int) []string {
+	runes := []rune(text)
+	var chunks []string
+	for start := 0; start < len(runes); start += size - overlap {
+		end := min(start+size, len(runes))
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+// pack joins consecutive units into chunks of at most cfg.Size characters,
+// repeating the trailing units of a chunk up to cfg.Overlap characters at
+// the start of the next. Units longer than a chunk are cut.
+func pack(units []string, sep string, cfg ChunkingConfig) []string {
+	var chunks []string
+	var current []string
+	length := 0
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, strings.Join(current, sep))
+		// Carry the trailing units that fit in the overlap
+		carried, carriedLen := 0, 0
+		for i := len(current) - 1; i >= 0; i-- {
+			n := runeLen(current[i]) + runeLen(sep)
+			if carriedLen+n > cfg.Overlap {
+				break
+			}
+			carried++
+			carriedLen += n
+		}
+		current = append([]string(nil), current[len(current)-carried:]...)
+		length = carriedLen
+	}
+
+	for _, unit := range units {
+		n := runeLen(unit)
+		if n > cfg.Size {
+			flush()
+			chunks = append(chunks, chunkFixed(unit, cfg.Size, cfg.Overlap)...)
+			current, length = nil, 0
+			continue
+		}
+		if length+n > cfg.Size && len(current) > 0 {
+			flush()
+			// The overlap must leave room for the unit
+			for len(current) > 0 && length+n > cfg.Size {
+				length -= runeLen(current[0]) + runeLen(sep)
+				current = current[1:]
+			}
+		}
+		current = append(current, unit)
+		length += n + runeLen(sep)
+	}
+	if len(current) > 0 && length > 0 {
+		chunk := strings.Join(current, sep)
+		if len(chunks) == 0 || !strings.HasSuffix(chunks[len(chunks)-1], chunk) {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// sentences splits text after sentence-ending punctuation followed by
+// white space
+func sentences(text string) []string {
+	var result []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !strings.ContainsRune(".!?", runes[i]) {
+			continue
+		}
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			result = append(result, sentence)
+		}
+		start = i + 1
+	}
+	if rest := strings.TrimSpace(string(runes[start:])); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedType is returned for a content type without an extractor
+var ErrUnsupportedType = errors.New("unsupported content type")
+
+// Extractor turns the content of a document into plain text
+type Extractor interface {
+	Extract(ctx context.Context, content []byte) (string, error)
+}
+
+// ExtractorFunc adapts a function to an Extractor
+type ExtractorFunc func(ctx context.Context, content []byte) (string, error)
+
+// Extract calls f
+func (f ExtractorFunc) Extract(ctx context.Context, content []byte) (string, error) {
+	return f(ctx, content)
+}
+
+// Extractors maps media types to the extractor of their content
+type Extractors map[string]Extractor
+
+// DefaultExtractors handles plain text, Markdown and HTML. Other types,
+// such as PDF, need an extractor configured, e.g. a CommandExtractor
+// running pdftotext.
+func DefaultExtractors() Extractors {
+	text := ExtractorFunc(extractText)
+	return Extractors{
+		"text/plain":       text,
+		"text/markdown":    text,
+		"text/csv":         text,
+		"application/json": text,
+		"text/html":        ExtractorFunc(extractHTML),
+	}
+}
+
+// Extract returns the text of content of the given media type, which may
+// carry parameters such as a charset; empty means plain text
+func (e Extractors) Extract(ctx context.Context, contentType string, content []byte) (string, error) {
+	mediaType := "text/plain"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return "", fmt.Errorf("invalid content type %q: %w", contentType, err)
+		}
+		mediaType = parsed
+	}
+	extractor, ok := e[mediaType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, mediaType)
+	}
+	return extractor.Extract(ctx, content)
+}
+
+func extractText(_ context.Context, content []byte) (string, error) {
+	if !utf8.Valid(content) {
+		return "", fmt.Errorf("content is not valid UTF-8 text")
+	}
+	return string(content), nil
+}
+
+var (
+	htmlSkipped = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	htmlBreaks  = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/h[1-6]|/tr)\b[^>]*>`)
+	htmlTags    = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines  = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// extractHTML keeps the text of an HTML page, breaking paragraphs where
+// block elements end
+func extractHTML(ctx context.Context, content []byte) (string, error) {
+	text, err := extractText(ctx, content)
+	if err != nil {
+		return "", err
+	}
+	text = htmlSkipped.ReplaceAllString(text, "")
+	text = htmlBreaks.ReplaceAllString(text, "\n\n")
+	text = html.UnescapeString(htmlTags.ReplaceAllString(text, ""))
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")), nil
+}
+
+// CommandExtractor extracts text with an external program reading the
+// content on stdin and writing the text to stdout, e.g.
+// ["pdftotext", "-layout", "-", "-"] for PDF
+type CommandExtractor struct {
+	Command []string
+}
+
+// Extract runs the command
+func (e CommandExtractor) Extract(ctx context.Context, content []byte) (string, error) {
+	if len(e.Command) == 0 {
+		return "", fmt.Errorf("extractor without a command")
+	}
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := stderr.String()
+		if len(msg) > 1024 {
+			msg = msg[:1024]
+		}
+		return "", fmt.Errorf("%s: %w: %s", e.Command[0], err, strings.TrimSpace(msg))
+	}
+	return extractText(ctx, stdout.Bytes())
+}
@@ -0,0 +1,293 @@
+// Package ingest loads documents into the vector index: their text is
+// extracted, split into chunks, embedded in batches spread over the
+// instances of the cluster serving the embedding model and upserted into a
+// collection, reporting the progress as a job.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"sync"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+)
+
+// Defaults for an unset configuration
+const (
+	DefaultBatchSize        = 32
+	DefaultConcurrency      = 4
+	DefaultMaxDocumentBytes = 32 << 20
+)
+
+// Metadata keys set on every chunk
+const (
+	MetadataDocument = "document"
+	MetadataChunk    = "chunk"
+)
+
+// ErrInvalidRequest is returned for a request that cannot be ingested
+var ErrInvalidRequest = errors.New("invalid ingestion request")
+
+// Config configures document ingestion
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	Chunking ChunkingConfig `yaml:"chunking"`
+	// BatchSize is the number of chunks embedded per call
+	BatchSize int `yaml:"batch_size" mapstructure:"batch_size"`
+	// Concurrency is the number of batches embedded at once, spread over
+	// the instances serving the embedding model
+	Concurrency int `yaml:"concurrency"`
+	// MaxDocumentBytes caps the content of one document
+	MaxDocumentBytes int64 `yaml:"max_document_bytes" mapstructure:"max_document_bytes"`
+	// Extractors maps media types to commands converting content on stdin
+	// to text on stdout, e.g. application/pdf: [pdftotext, "-", "-"]
+	Extractors map[string][]string `yaml:"extractors"`
+}
+
+// Validate checks the chunking, limits and extractors
+func (c Config) Validate() error {
+	if err := c.Chunking.Validate(); err != nil {
+		return err
+	}
+	if c.BatchSize < 0 || c.Concurrency < 0 || c.MaxDocumentBytes < 0 {
+		return fmt.Errorf("negative batch_size, concurrency or max_document_bytes")
+	}
+	for mediaType, command := range c.Extractors {
+		if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+			return fmt.Errorf("invalid extractor media type %q: %w", mediaType, err)
+		}
+		if len(command) == 0 {
+			return fmt.Errorf("extractor for %s without a command", mediaType)
+		}
+	}
+	return nil
+}
+
+// Document is a document to ingest, given as Text or as Content of a
+// ContentType to extract the text from
+type Document struct {
+	ID          string            `json:"id"`
+	Text        string            `json:"text,omitempty"`
+	Content     []byte            `json:"content,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// Request ingests documents into a collection
+type Request struct {
+	Collection string
+	// Model embeds the chunks
+	Model     string
+	Documents []Document
+	// Chunking overrides the configured chunking when set
+	Chunking *ChunkingConfig
+}
+
+// Result summarizes an ingestion
+type Result struct {
+	Collection string `json:"collection"`
+	Documents  int    `json:"documents"`
+	Chunks     int    `json:"chunks"`
+}
+
+// Service ingests documents into a vector index
+type Service struct {
+	cfg        Config
+	index      vectorindex.Index
+	embedder   vectorindex.Embedder
+	extractors Extractors
+}
+
+// NewService creates a service writing to index, embedding with embedder
+func NewService(cfg Config, index vectorindex.Index, embedder vectorindex.Embedder) (*Service, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.MaxDocumentBytes == 0 {
+		cfg.MaxDocumentBytes = DefaultMaxDocumentBytes
+	}
+
+	extractors := DefaultExtractors()
+	for mediaType, command := range cfg.Extractors {
+		parsed, _, _ := mime.ParseMediaType(mediaType)
+		extractors[parsed] = CommandExtractor{Command: command}
+	}
+	return &Service{cfg: cfg, index: index, embedder: embedder, extractors: extractors}, nil
+}
+
+// RegisterExtractor handles a media type with extractor
+func (s *Service) RegisterExtractor(mediaType string, extractor Extractor) {
+	s.extractors[mediaType] = extractor
+}
+
+// MaxDocumentBytes returns the largest content accepted for a document
+func (s *Service) MaxDocumentBytes() int64 {
+	return s.cfg.MaxDocumentBytes
+}
+
+// Validate checks a request before it is run in the background
+func (s *Service) Validate(req Request) error {
+	if err := vectorindex.ValidateCollection(req.Collection); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if req.Model == "" {
+		return fmt.Errorf("%w: model is required", ErrInvalidRequest)
+	}
+	if len(req.Documents) == 0 {
+		return fmt.Errorf("%w: no documents", ErrInvalidRequest)
+	}
+	if req.Chunking != nil {
+		if err := req.Chunking.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	seen := make(map[string]bool, len(req.Documents))
+	for _, doc := range req.Documents {
+		if doc.ID == "" {
+			return fmt.Errorf("%w: document without an id", ErrInvalidRequest)
+		}
+		if seen[doc.ID] {
+			return fmt.Errorf("%w: duplicate document %s", ErrInvalidRequest, doc.ID)
+		}
+		seen[doc.ID] = true
+		if doc.Text == "" && len(doc.Content) == 0 {
+			return fmt.Errorf("%w: document %s has neither text nor content", ErrInvalidRequest, doc.ID)
+		}
+		if int64(len(doc.Content)) > s.cfg.MaxDocumentBytes || int64(len(doc.Text)) > s.cfg.MaxDocumentBytes {
+			return fmt.Errorf("%w: document %s larger than %d bytes", ErrInvalidRequest, doc.ID, s.cfg.MaxDocumentBytes)
+		}
+	}
+	return nil
+}
+
+// Ingest extracts, chunks, embeds and upserts the documents of req,
+// reporting the chunks embedded to p. Chunk i of document d is stored as
+// "d#i", so ingesting a document again replaces its chunks.
+func (s *Service) Ingest(ctx context.Context, req Request, p *jobs.Progress) (*Result, error) {
+	if err := s.Validate(req); err != nil {
+		return nil, err
+	}
+	chunking := s.cfg.Chunking
+	if req.Chunking != nil {
+		chunking = *req.Chunking
+	}
+
+	p.SetMessage("extracting text")
+	var chunks []vectorindex.Document
+	for _, doc := range req.Documents {
+		text := doc.Text
+		if text == "" {
+			var err error
+			if text, err = s.extractors.Extract(ctx, doc.ContentType, doc.Content); err != nil {
+				return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+			}
+		}
+		for i, chunk := range Chunk(text, chunking) {
+			metadata := make(map[string]string, len(doc.Metadata)+2)
+			for k, v := range doc.Metadata {
+				metadata[k] = v
+			}
+			metadata[MetadataDocument] = doc.ID
+			metadata[MetadataChunk] = strconv.Itoa(i)
+			chunks = append(chunks, vectorindex.Document{
+				ID:       doc.ID + "#" + strconv.Itoa(i),
+				Text:     chunk,
+				Metadata: metadata,
+			})
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("%w: the documents contain no text", ErrInvalidRequest)
+	}
+
+	p.AddTotal(int64(len(chunks)))
+	p.SetMessage(fmt.Sprintf("embedding %d chunks", len(chunks)))
+	if err := s.embedAndUpsert(ctx, req.Collection, req.Model, chunks, p); err != nil {
+		return nil, err
+	}
+	p.SetMessage("done")
+	return &Result{Collection: req.Collection, Documents: len(req.Documents), Chunks: len(chunks)}, nil
+}
+
+// embedAndUpsert embeds the chunks in batches on concurrent workers and
+// upserts each batch once embedded, stopping at the first error
+func (s *Service) embedAndUpsert(ctx context.Context, collection, model string, chunks []vectorindex.Document, p *jobs.Progress) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batches := make(chan []vectorindex.Document)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i := 0; i < s.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := s.embedBatch(ctx, collection, model, batch); err != nil {
+					fail(err)
+					continue
+				}
+				p.Add(int64(len(batch)))
+			}
+		}()
+	}
+
+	for start := 0; start < len(chunks); start += s.cfg.BatchSize {
+		batch := chunks[start:min(start+s.cfg.BatchSize, len(chunks))]
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// embedBatch embeds and upserts one batch of chunks
+func (s *Service) embedBatch(ctx context.Context, collection, model string, batch []vectorindex.Document) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	texts := make([]string, len(batch))
+	for i, chunk := range batch {
+		texts[i] = chunk.Text
+	}
+	embeddings, err := s.embedder.Embed(ctx, model, texts)
+	if err != nil {
+		return fmt.Errorf("embedding failed: %w", err)
+	}
+	if len(embeddings) != len(batch) {
+		return fmt.Errorf("embedding returned %d vectors for %d chunks", len(embeddings), len(batch))
+	}
+	for i := range batch {
+		batch[i].Vector = embeddings[i]
+	}
+	return s.index.Upsert(ctx, collection, batch)
+}
@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/jobs"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEmbedder embeds a text as its length, recording the batches
+type countingEmbedder struct {
+	mu      sync.Mutex
+	batches []int
+	err     error
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, _ string, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.batches = append(e.batches, len(texts))
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text)), 1}
+	}
+	return embeddings, nil
+}
+
+func TestChunk(t *testing.T) {
+	assert.Equal(t, []string{"abcd", "cdef", "efgh", "ghij"},
+		Chunk("abcdefghij", ChunkingConfig{Strategy: ChunkFixed, Size: 4, Overlap: 2}))
+
+	text := "One. Two is here. Three!"
+	assert.Equal(t, []string{"One. Two is here.", "Two is here. Three!"},
+		Chunk(text, ChunkingConfig{Strategy: ChunkSentence, Size: 20, Overlap: 13}))
+	assert.Equal(t, []string{"One. Two is here.", "Three!"},
+		Chunk(text, ChunkingConfig{Strategy: ChunkSentence, Size: 20}))
+
+	paragraphs := "First paragraph.\n\nSecond paragraph.\n\n\nA third paragraph that is too long. It is split."
+	assert.Equal(t, []string{
+		"First paragraph.\n\nSecond paragraph.",
+		"A third paragraph that is too long.",
+		"It is split.",
+	}, Chunk(paragraphs, ChunkingConfig{Size: 40}))
+
+	assert.Nil(t, Chunk("  \n ", ChunkingConfig{}))
+	assert.Error(t, ChunkingConfig{Strategy: "semantic"}.Validate())
+	assert.Error(t, ChunkingConfig{Size: 10, Overlap: 10}.Validate())
+}
+
+func TestExtractors(t *testing.T) {
+	extractors := DefaultExtractors()
+	text, err := extractors.Extract(context.Background(), "text/html; charset=utf-8",
+		[]byte("<html><head><title>x</title></head><body><p>Hello &amp; welcome</p><script>f()</script><p>Bye</p></body></html>"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello & welcome\n\nBye", text)
+
+	_, err = extractors.Extract(context.Background(), "application/pdf", []byte("%PDF"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = extractors.Extract(context.Background(), "", []byte{0xff, 0xfe})
+	assert.Error(t, err)
+
+	text, err = CommandExtractor{Command: []string{"tr", "a-z", "A-Z"}}.Extract(context.Background(), []byte("pdf text"))
+	if err != nil {
+		t.Skipf("tr not available: %v", err)
+	}
+	assert.Equal(t, "PDF TEXT", text)
+}
+
+func TestIngest(t *testing.T) {
+	index, err := vectorindex.OpenHNSW(vectorindex.Config{})
+	require.NoError(t, err)
+	embedder := &countingEmbedder{}
+	service, err := NewService(Config{
+		Chunking:    ChunkingConfig{Strategy: ChunkFixed, Size: 10},
+		BatchSize:   2,
+		Concurrency: 2,
+	}, index, embedder)
+	require.NoError(t, err)
+	service.RegisterExtractor("application/x-upper", ExtractorFunc(func(_ context.Context, content []byte) (string, error) {
+		return strings.ToUpper(string(content)), nil
+	}))
+
+	manager := jobs.NewManager(0)
+	req := Request{
+		Collection: "handbook",
+		Model:      "nomic-embed-text",
+		Documents: []Document{
+			{ID: "a", Text: "0123456789abcdefghij0123", Metadata: map[string]string{"source": "wiki"}},
+			{ID: "b", Content: []byte("short"), ContentType: "application/x-upper"},
+		},
+	}
+	job := manager.Start(context.Background(), "ingest", "", func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+		return service.Ingest(ctx, req, p)
+	})
+	require.Eventually(t, func() bool {
+		job, err = manager.Get(job.ID)
+		require.NoError(t, err)
+		return job.Finished()
+	}, time.Second, time.Millisecond)
+	require.Equal(t, jobs.StatusSucceeded, job.Status, job.Error)
+	assert.Equal(t, int64(4), job.Done)
+	assert.Equal(t, int64(4), job.Total)
+	assert.Equal(t, &Result{Collection: "handbook", Documents: 2, Chunks: 4}, job.Result)
+	assert.ElementsMatch(t, []int{2, 2}, embedder.batches)
+
+	matches, err := index.Query(context.Background(), "handbook", []float32{5, 1}, 10, map[string]string{MetadataDocument: "b"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "b#0", matches[0].ID)
+	assert.Equal(t, "SHORT", matches[0].Text)
+	matches, err = index.Query(context.Background(), "handbook", []float32{4, 1}, 10, map[string]string{"source": "wiki", MetadataChunk: "2"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "0123", matches[0].Text)
+
+	// Invalid requests fail before anything is embedded
+	_, err = service.Ingest(context.Background(), Request{Collection: "handbook", Model: "m", Documents: []Document{{ID: "x"}}}, nil)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = service.Ingest(context.Background(), Request{Collection: "handbook", Model: "m", Documents: []Document{{ID: "x", Content: []byte("%PDF"), ContentType: "application/pdf"}}}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	embedder.err = errors.New("instance down")
+	_, err = service.Ingest(context.Background(), req, nil)
+	assert.ErrorContains(t, err, "instance down")
+}
@@ -0,0 +1,137 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// defaultOllamaPort is used when no base port is configured
+const defaultOllamaPort = 11434
+
+// ollamaInstance is one local Ollama server, either spawned and supervised
+// by the node, served in-process by the native runner, or already running
+// when the node started
+type ollamaInstance struct {
+	name       string
+	host       string   // host:port the server listens on
+	gpus       []string // empty = all visible GPUs
+	supervisor *supervisor
+	native     *nativeRunner // set instead of supervisor for the native runtime
+}
+
+// endpoint returns the base URL of the instance's API
+func (i *ollamaInstance) endpoint() string {
+	return "http://" + i.host
+}
+
+// env returns the environment the instance is started with. GPU affinity
+// is set for both CUDA and ROCm; the variable for the absent vendor is
+// ignored.
+func (i *ollamaInstance) env(base []string) []string {
+	env := append(append([]string{}, base...),
+		"OLLAMA_HOST="+i.host,
+		"OLLAMA_KEEP_ALIVE=5m",
+	)
+	if len(i.gpus) > 0 {
+		devices := strings.Join(i.gpus, ",")
+		env = append(env,
+			"CUDA_VISIBLE_DEVICES="+devices,
+			"ROCR_VISIBLE_DEVICES="+devices,
+		)
+	}
+	return env
+}
+
+// isRunning reports whether the instance answers API requests
+func (i *ollamaInstance) isRunning() bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(i.endpoint() + "/api/tags")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// status reports the instance for GetStatus
+func (i *ollamaInstance) status() map[string]interface{} {
+	status := map[string]interface{}{
+		"name":     i.name,
+		"endpoint": i.endpoint(),
+		"gpus":     i.gpus,
+		"running":  i.isRunning(),
+	}
+	if i.supervisor != nil {
+		status["process"] = i.supervisor.status()
+	}
+	if i.native != nil {
+		status["runtime"] = config.OllamaRuntimeNative
+	}
+	return status
+}
+
+// resolveInstances returns the instances to run from the configuration.
+// Explicit instances take precedence over per-GPU detection; if neither
+// yields anything a single instance on the base port is used.
+func resolveInstances(cfg config.OllamaConfig, detectGPUs func() ([]string, error)) []*ollamaInstance {
+	basePort := cfg.BasePort
+	if basePort == 0 {
+		basePort = defaultOllamaPort
+	}
+
+	var instances []*ollamaInstance
+	for _, ic := range cfg.Instances {
+		instances = append(instances, &ollamaInstance{
+			name: ic.Name,
+			host: fmt.Sprintf("127.0.0.1:%d", ic.Port),
+			gpus: ic.GPUs,
+		})
+	}
+	if len(instances) > 0 {
+		return instances
+	}
+
+	if cfg.InstancePerGPU {
+		gpus, err := detectGPUs()
+		if err != nil {
+			fmt.Printf("⚠️  GPU detection failed, running a single Ollama instance: %v\n", err)
+		}
+		for i, gpu := range gpus {
+			instances = append(instances, &ollamaInstance{
+				name: fmt.Sprintf("gpu%d", i),
+				host: fmt.Sprintf("127.0.0.1:%d", basePort+i),
+				gpus: []string{gpu},
+			})
+		}
+		if len(instances) > 0 {
+			return instances
+		}
+	}
+
+	return []*ollamaInstance{{
+		name: "default",
+		host: fmt.Sprintf("127.0.0.1:%d", basePort),
+	}}
+}
+
+// detectNvidiaGPUs lists the UUIDs of the NVIDIA GPUs on the host. UUIDs
+// are used rather than indices because they are stable across reboots.
+func detectNvidiaGPUs() ([]string, error) {
+	output, err := exec.Command("nvidia-smi", "--query-gpu=uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+
+	var gpus []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if uuid := strings.TrimSpace(line); uuid != "" {
+			gpus = append(gpus, uuid)
+		}
+	}
+	return gpus, nil
+}
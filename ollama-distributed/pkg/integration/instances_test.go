@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInstances(t *testing.T) {
+	twoGPUs := func() ([]string, error) { return []string{"GPU-a", "GPU-b"}, nil }
+	noGPUs := func() ([]string, error) { return nil, errors.New("nvidia-smi not found") }
+
+	t.Run("default", func(t *testing.T) {
+		instances := resolveInstances(config.OllamaConfig{}, twoGPUs)
+		require.Len(t, instances, 1)
+		assert.Equal(t, "default", instances[0].name)
+		assert.Equal(t, "http://127.0.0.1:11434", instances[0].endpoint())
+		assert.Empty(t, instances[0].gpus)
+	})
+
+	t.Run("per GPU", func(t *testing.T) {
+		instances := resolveInstances(config.OllamaConfig{InstancePerGPU: true, BasePort: 12000}, twoGPUs)
+		require.Len(t, instances, 2)
+		assert.Equal(t, "gpu1", instances[1].name)
+		assert.Equal(t, "127.0.0.1:12001", instances[1].host)
+		assert.Equal(t, []string{"GPU-b"}, instances[1].gpus)
+	})
+
+	t.Run("per GPU without GPUs", func(t *testing.T) {
+		instances := resolveInstances(config.OllamaConfig{InstancePerGPU: true}, noGPUs)
+		require.Len(t, instances, 1)
+		assert.Equal(t, "default", instances[0].name)
+	})
+
+	t.Run("explicit instances win", func(t *testing.T) {
+		cfg := config.OllamaConfig{
+			InstancePerGPU: true,
+			Instances: []config.OllamaInstanceConfig{
+				{Name: "big", Port: 11500, GPUs: []string{"0", "1"}},
+			},
+		}
+		instances := resolveInstances(cfg, twoGPUs)
+		require.Len(t, instances, 1)
+		assert.Equal(t, "big", instances[0].name)
+		assert.Equal(t, "127.0.0.1:11500", instances[0].host)
+	})
+}
+
+func TestInstanceEnv(t *testing.T) {
+	base := []string{"PATH=/usr/bin"}
+	instance := &ollamaInstance{name: "gpu0", host: "127.0.0.1:11435", gpus: []string{"0", "1"}}
+
+	env := instance.env(base)
+	assert.Contains(t, env, "OLLAMA_HOST=127.0.0.1:11435")
+	assert.Contains(t, env, "CUDA_VISIBLE_DEVICES=0,1")
+	assert.Contains(t, env, "ROCR_VISIBLE_DEVICES=0,1")
+	assert.Equal(t, []string{"PATH=/usr/bin"}, base)
+
+	unpinned := (&ollamaInstance{host: "127.0.0.1:11434"}).env(base)
+	for _, kv := range unpinned {
+		assert.NotRegexp(t, "^(CUDA|ROCR)_VISIBLE_DEVICES=", kv)
+	}
+}
@@ -0,0 +1,391 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	ollamaAPI "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ollama/api"
+)
+
+// errNativeRunnerUnavailable is returned when the binary was built without
+// the llama.cpp bindings
+var errNativeRunnerUnavailable = errors.New("native runner not available, rebuild with -tags llamacpp")
+
+var (
+	errModelNotFound    = errors.New("model not found")
+	errInvalidModelName = errors.New("invalid model name")
+)
+
+// nativeModel is a model loaded by the in-process runner
+type nativeModel interface {
+	// Predict completes prompt, calling onToken for every generated token
+	// until it returns false
+	Predict(prompt string, opts predictOptions, onToken func(string) bool) (string, error)
+	Close()
+}
+
+// predictOptions are the sampling options supported by the native runner
+type predictOptions struct {
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+	Threads     int
+	Stop        []string
+}
+
+// nativeRunner serves GGUF models in-process behind the subset of the
+// Ollama HTTP API the node relies on, so that the proxy and readiness
+// checks treat it like an external daemon. One model is kept loaded at a
+// time and requests are serialised because a llama.cpp context is not
+// safe for concurrent use.
+type nativeRunner struct {
+	cfg       config.NativeRunnerConfig
+	modelsDir string
+	load      func(path string, cfg config.NativeRunnerConfig) (nativeModel, error)
+
+	mu          sync.Mutex
+	loaded      nativeModel
+	loadedModel string
+
+	server *http.Server
+}
+
+func newNativeRunner(cfg config.NativeRunnerConfig, modelsDir string) *nativeRunner {
+	if cfg.ModelsDir != "" {
+		modelsDir = cfg.ModelsDir
+	}
+	if cfg.Threads == 0 {
+		cfg.Threads = runtime.NumCPU()
+	}
+	return &nativeRunner{
+		cfg:       cfg,
+		modelsDir: modelsDir,
+		load:      loadNativeModel,
+	}
+}
+
+// start serves the API on host
+func (r *nativeRunner) start(host string) error {
+	listener, err := net.Listen("tcp", host)
+	if err != nil {
+		return err
+	}
+
+	r.server = &http.Server{Handler: r.handler()}
+	go func() {
+		if err := r.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("❌ Native runner stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// stop shuts down the API and frees the loaded model
+func (r *nativeRunner) stop(ctx context.Context) error {
+	var err error
+	if r.server != nil {
+		err = r.server.Shutdown(ctx)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.loaded != nil {
+		r.loaded.Close()
+		r.loaded = nil
+		r.loadedModel = ""
+	}
+	return err
+}
+
+func (r *nativeRunner) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"version": "native"})
+	})
+	mux.HandleFunc("/api/tags", r.handleTags)
+	mux.HandleFunc("/api/generate", r.handleGenerate)
+	mux.HandleFunc("/api/chat", r.handleChat)
+	return mux
+}
+
+func (r *nativeRunner) handleTags(w http.ResponseWriter, req *http.Request) {
+	models, err := r.listModels()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ollamaAPI.ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, ollamaAPI.ListResponse{Models: models})
+}
+
+func (r *nativeRunner) handleGenerate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ollamaAPI.ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var request ollamaAPI.GenerateRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, ollamaAPI.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	prompt := request.Prompt
+	if request.System != "" && !request.Raw {
+		prompt = request.System + "\n\n" + prompt
+	}
+
+	start := time.Now()
+	stream := newNDJSONStream(w, request.Stream)
+	text, err := r.generate(req.Context(), request.Model, prompt, r.options(request.Options), func(token string) bool {
+		return stream.send(ollamaAPI.GenerateResponse{
+			Model:     request.Model,
+			CreatedAt: time.Now(),
+			Response:  token,
+		})
+	})
+	if err != nil {
+		stream.fail(err)
+		return
+	}
+
+	final := ollamaAPI.GenerateResponse{
+		Model:         request.Model,
+		CreatedAt:     time.Now(),
+		Done:          true,
+		TotalDuration: int64(time.Since(start)),
+	}
+	if !request.Stream {
+		final.Response = text
+	}
+	stream.send(final)
+}
+
+func (r *nativeRunner) handleChat(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ollamaAPI.ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var request ollamaAPI.ChatRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, ollamaAPI.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	opts := r.options(request.Options)
+	opts.Stop = append(opts.Stop, "\nuser:")
+
+	start := time.Now()
+	stream := newNDJSONStream(w, request.Stream)
+	text, err := r.generate(req.Context(), request.Model, chatPrompt(request.Messages), opts, func(token string) bool {
+		return stream.send(ollamaAPI.ChatResponse{
+			Model:     request.Model,
+			CreatedAt: time.Now(),
+			Message:   ollamaAPI.Message{Role: "assistant", Content: token},
+		})
+	})
+	if err != nil {
+		stream.fail(err)
+		return
+	}
+
+	final := ollamaAPI.ChatResponse{
+		Model:         request.Model,
+		CreatedAt:     time.Now(),
+		Message:       ollamaAPI.Message{Role: "assistant"},
+		Done:          true,
+		TotalDuration: int64(time.Since(start)),
+	}
+	if !request.Stream {
+		final.Message.Content = strings.TrimSpace(text)
+	}
+	stream.send(final)
+}
+
+// chatPrompt flattens chat messages into a plain transcript. GGUF chat
+// templates are not applied, so instruction-tuned models may answer less
+// precisely than under Ollama.
+func chatPrompt(messages []ollamaAPI.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Content)
+	}
+	b.WriteString("assistant:")
+	return b.String()
+}
+
+// options maps Ollama request options onto the native sampling options
+func (r *nativeRunner) options(options map[string]interface{}) predictOptions {
+	opts := predictOptions{
+		MaxTokens:   r.cfg.MaxTokens,
+		Temperature: 0.8,
+		TopP:        0.9,
+		Threads:     r.cfg.Threads,
+	}
+	if v, ok := options["num_predict"].(float64); ok && v > 0 {
+		opts.MaxTokens = int(v)
+	}
+	if v, ok := options["temperature"].(float64); ok {
+		opts.Temperature = v
+	}
+	if v, ok := options["top_p"].(float64); ok {
+		opts.TopP = v
+	}
+	if stop, ok := options["stop"].([]interface{}); ok {
+		for _, s := range stop {
+			if s, ok := s.(string); ok {
+				opts.Stop = append(opts.Stop, s)
+			}
+		}
+	}
+	return opts
+}
+
+// generate runs prompt through model, loading it first if another model
+// is resident. Generation stops early when ctx is cancelled.
+func (r *nativeRunner) generate(ctx context.Context, model, prompt string, opts predictOptions, onToken func(string) bool) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := normalizeModelName(model)
+	if r.loaded == nil || r.loadedModel != name {
+		path, err := r.modelPath(name)
+		if err != nil {
+			return "", err
+		}
+		if r.loaded != nil {
+			r.loaded.Close()
+			r.loaded = nil
+		}
+		loaded, err := r.load(path, r.cfg)
+		if err != nil {
+			return "", fmt.Errorf("failed to load model %s: %w", model, err)
+		}
+		r.loaded = loaded
+		r.loadedModel = name
+	}
+
+	return r.loaded.Predict(prompt, opts, func(token string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		return onToken(token)
+	})
+}
+
+// normalizeModelName drops the default tag, so that "llama3:latest" and
+// "llama3" refer to the same file
+func normalizeModelName(model string) string {
+	return strings.TrimSuffix(model, ":latest")
+}
+
+// modelPath returns the GGUF file for a model name
+func (r *nativeRunner) modelPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("%w %q", errInvalidModelName, name)
+	}
+	path := filepath.Join(r.modelsDir, name+".gguf")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%w: %s in %s", errModelNotFound, name, r.modelsDir)
+	}
+	return path, nil
+}
+
+// listModels returns the GGUF files in the models directory
+func (r *nativeRunner) listModels() ([]ollamaAPI.ModelResponse, error) {
+	paths, err := filepath.Glob(filepath.Join(r.modelsDir, "*.gguf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	models := make([]ollamaAPI.ModelResponse, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		models = append(models, ollamaAPI.ModelResponse{
+			Name:       strings.TrimSuffix(filepath.Base(path), ".gguf"),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+			Details:    ollamaAPI.ModelDetails{Format: "gguf"},
+		})
+	}
+	return models, nil
+}
+
+// ndjsonStream writes either a single JSON response or, when streaming,
+// newline-delimited chunks as Ollama does
+type ndjsonStream struct {
+	w       http.ResponseWriter
+	stream  bool
+	started bool
+}
+
+func newNDJSONStream(w http.ResponseWriter, stream bool) *ndjsonStream {
+	return &ndjsonStream{w: w, stream: stream}
+}
+
+// send writes v if streaming or if it is the final response. It returns
+// false once the client has gone away.
+func (s *ndjsonStream) send(v interface{}) bool {
+	done := false
+	switch r := v.(type) {
+	case ollamaAPI.GenerateResponse:
+		done = r.Done
+	case ollamaAPI.ChatResponse:
+		done = r.Done
+	}
+	if !s.stream && !done {
+		return true
+	}
+
+	if !s.started {
+		contentType := "application/json"
+		if s.stream {
+			contentType = "application/x-ndjson"
+		}
+		s.w.Header().Set("Content-Type", contentType)
+		s.started = true
+	}
+	if err := json.NewEncoder(s.w).Encode(v); err != nil {
+		return false
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
+
+// fail reports err, in-band if chunks were already streamed
+func (s *ndjsonStream) fail(err error) {
+	if s.started {
+		json.NewEncoder(s.w).Encode(ollamaAPI.ErrorResponse{Error: err.Error()})
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errModelNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errInvalidModelName):
+		status = http.StatusBadRequest
+	}
+	writeJSON(s.w, status, ollamaAPI.ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
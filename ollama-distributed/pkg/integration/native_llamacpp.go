@@ -0,0 +1,63 @@
+//go:build llamacpp
+
+package integration
+
+// Building with -tags llamacpp links github.com/go-skynet/go-llama.cpp,
+// whose cgo flags look for libbinding.a in the module's own directory. The
+// module cache is read-only, so build the library in a checkout of the
+// version required by go.mod and point the module at it:
+//
+//	git clone --recurse-submodules https://github.com/go-skynet/go-llama.cpp
+//	make -C go-llama.cpp libbinding.a
+//	go mod edit -replace github.com/go-skynet/go-llama.cpp=./go-llama.cpp
+//
+// See the bindings' README for the CUDA and Metal build flags.
+
+import (
+	llama "github.com/go-skynet/go-llama.cpp"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+const nativeRunnerAvailable = true
+
+// llamaModel adapts the go-llama.cpp bindings to nativeModel
+type llamaModel struct {
+	l *llama.LLama
+}
+
+func loadNativeModel(path string, cfg config.NativeRunnerConfig) (nativeModel, error) {
+	opts := []llama.ModelOption{llama.SetMMap(true)}
+	if cfg.ContextSize > 0 {
+		opts = append(opts, llama.SetContext(cfg.ContextSize))
+	}
+	if cfg.GPULayers > 0 {
+		opts = append(opts, llama.SetGPULayers(cfg.GPULayers), llama.EnableF16Memory)
+	}
+
+	l, err := llama.New(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &llamaModel{l: l}, nil
+}
+
+func (m *llamaModel) Predict(prompt string, opts predictOptions, onToken func(string) bool) (string, error) {
+	m.l.SetTokenCallback(onToken)
+	defer m.l.SetTokenCallback(nil)
+
+	predictOpts := []llama.PredictOption{
+		llama.SetTokens(opts.MaxTokens),
+		llama.SetThreads(opts.Threads),
+		llama.SetTemperature(float32(opts.Temperature)),
+		llama.SetTopP(float32(opts.TopP)),
+	}
+	if len(opts.Stop) > 0 {
+		predictOpts = append(predictOpts, llama.SetStopWords(opts.Stop...))
+	}
+	return m.l.Predict(prompt, predictOpts...)
+}
+
+func (m *llamaModel) Close() {
+	m.l.Free()
+}
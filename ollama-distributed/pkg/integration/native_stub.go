@@ -0,0 +1,12 @@
+//go:build !llamacpp
+
+package integration
+
+import "github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+
+// nativeRunnerAvailable reports whether the llama.cpp bindings are compiled in
+const nativeRunnerAvailable = false
+
+func loadNativeModel(path string, cfg config.NativeRunnerConfig) (nativeModel, error) {
+	return nil, errNativeRunnerUnavailable
+}
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	ollamaAPI "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoModel generates the words of the prompt back as tokens
+type echoModel struct {
+	path    string
+	prompts []string
+	closed  bool
+}
+
+func (m *echoModel) Predict(prompt string, opts predictOptions, onToken func(string) bool) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	var out strings.Builder
+	for _, word := range strings.Fields(prompt) {
+		if !onToken(word + " ") {
+			break
+		}
+		out.WriteString(word + " ")
+	}
+	return out.String(), nil
+}
+
+func (m *echoModel) Close() { m.closed = true }
+
+func newTestNativeRunner(t *testing.T, models ...string) (*nativeRunner, map[string]*echoModel) {
+	dir := t.TempDir()
+	for _, name := range models {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".gguf"), []byte("GGUF"), 0644))
+	}
+
+	loaded := make(map[string]*echoModel)
+	runner := newNativeRunner(config.NativeRunnerConfig{MaxTokens: 16}, dir)
+	runner.load = func(path string, cfg config.NativeRunnerConfig) (nativeModel, error) {
+		m := &echoModel{path: path}
+		loaded[strings.TrimSuffix(filepath.Base(path), ".gguf")] = m
+		return m, nil
+	}
+	return runner, loaded
+}
+
+func TestNativeRunnerTags(t *testing.T) {
+	runner, _ := newTestNativeRunner(t, "tinyllama", "phi3-mini")
+
+	rec := httptest.NewRecorder()
+	runner.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var list ollamaAPI.ListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Models, 2)
+	assert.Equal(t, "phi3-mini", list.Models[0].Name)
+	assert.Equal(t, "gguf", list.Models[1].Details.Format)
+}
+
+func TestNativeRunnerGenerate(t *testing.T) {
+	runner, loaded := newTestNativeRunner(t, "tinyllama")
+
+	body := `{"model":"tinyllama:latest","prompt":"hello there","stream":false}`
+	rec := httptest.NewRecorder()
+	runner.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp ollamaAPI.GenerateResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Done)
+	assert.Equal(t, "hello there ", resp.Response)
+	require.Contains(t, loaded, "tinyllama")
+}
+
+func TestNativeRunnerGenerateStream(t *testing.T) {
+	runner, _ := newTestNativeRunner(t, "tinyllama")
+
+	body := `{"model":"tinyllama","prompt":"one two three","stream":true}`
+	rec := httptest.NewRecorder()
+	runner.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var chunks []ollamaAPI.GenerateResponse
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var chunk ollamaAPI.GenerateResponse
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &chunk))
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 4)
+	assert.Equal(t, "two ", chunks[1].Response)
+	assert.True(t, chunks[3].Done)
+	assert.Empty(t, chunks[3].Response)
+}
+
+func TestNativeRunnerChat(t *testing.T) {
+	runner, loaded := newTestNativeRunner(t, "tinyllama")
+
+	body := `{"model":"tinyllama","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`
+	rec := httptest.NewRecorder()
+	runner.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp ollamaAPI.ChatResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "assistant", resp.Message.Role)
+	assert.Equal(t, []string{"system: be brief\nuser: hi\nassistant:"}, loaded["tinyllama"].prompts)
+}
+
+func TestNativeRunnerModelSwitching(t *testing.T) {
+	runner, loaded := newTestNativeRunner(t, "a", "b")
+	ctx := t.Context()
+
+	_, err := runner.generate(ctx, "a", "x", predictOptions{}, func(string) bool { return true })
+	require.NoError(t, err)
+	_, err = runner.generate(ctx, "b", "y", predictOptions{}, func(string) bool { return true })
+	require.NoError(t, err)
+	assert.True(t, loaded["a"].closed, "only one model stays resident")
+	assert.False(t, loaded["b"].closed)
+
+	_, err = runner.generate(ctx, "missing", "z", predictOptions{}, func(string) bool { return true })
+	assert.ErrorIs(t, err, errModelNotFound)
+	_, err = runner.generate(ctx, "../etc/passwd", "z", predictOptions{}, func(string) bool { return true })
+	assert.ErrorIs(t, err, errInvalidModelName)
+}
+
+func TestNativeRunnerMissingModelStatus(t *testing.T) {
+	runner, _ := newTestNativeRunner(t)
+
+	rec := httptest.NewRecorder()
+	body := `{"model":"nope","prompt":"hi"}`
+	runner.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
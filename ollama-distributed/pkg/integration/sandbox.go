@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// cpuPeriod is the cgroup CPU accounting period in microseconds
+const cpuPeriod = 100000
+
+// seccompFD is the descriptor bwrap reads the seccomp filter from; the
+// first entry of ExtraFiles becomes fd 3 in the child
+const seccompFD = 3
+
+func hasResourceLimits(sb config.SandboxConfig) bool {
+	return sb.CPULimit > 0 || sb.MemoryLimit > 0 || sb.PidsLimit > 0
+}
+
+// cgroupLimits returns the cgroup v2 interface files and values that
+// enforce the sandbox limits
+func cgroupLimits(sb config.SandboxConfig) map[string]string {
+	limits := make(map[string]string)
+	if sb.CPULimit > 0 {
+		quota := int64(sb.CPULimit * cpuPeriod)
+		limits["cpu.max"] = fmt.Sprintf("%d %d", quota, cpuPeriod)
+	}
+	if sb.MemoryLimit > 0 {
+		limits["memory.max"] = strconv.FormatInt(sb.MemoryLimit, 10)
+		// Without this the limit is bypassed by swapping instead of OOM killing
+		limits["memory.swap.max"] = "0"
+	}
+	if sb.PidsLimit > 0 {
+		limits["pids.max"] = strconv.FormatInt(sb.PidsLimit, 10)
+	}
+	return limits
+}
+
+// wrapCommand prefixes the command with the confinement helpers requested
+// by the sandbox. AppArmor is applied innermost so that the seccomp filter
+// installed by bwrap also covers aa-exec.
+func wrapCommand(sb config.SandboxConfig, name string, args []string) (string, []string, error) {
+	if !sb.Enabled {
+		return name, args, nil
+	}
+
+	if sb.AppArmorProfile != "" {
+		if _, err := exec.LookPath("aa-exec"); err != nil {
+			return "", nil, fmt.Errorf("apparmor profile configured but aa-exec not found: %w", err)
+		}
+		args = append([]string{"-p", sb.AppArmorProfile, "--", name}, args...)
+		name = "aa-exec"
+	}
+
+	if sb.SeccompProfile != "" {
+		if _, err := exec.LookPath("bwrap"); err != nil {
+			return "", nil, fmt.Errorf("seccomp profile configured but bwrap not found: %w", err)
+		}
+		args = append([]string{
+			"--die-with-parent",
+			"--dev-bind", "/", "/",
+			"--seccomp", strconv.Itoa(seccompFD),
+			"--", name,
+		}, args...)
+		name = "bwrap"
+	}
+
+	return name, args, nil
+}
+
+// attachSeccomp passes the compiled seccomp filter to bwrap. The file is
+// reopened for every start because bwrap consumes the descriptor.
+func attachSeccomp(cmd *exec.Cmd, sb config.SandboxConfig) error {
+	if !sb.Enabled || sb.SeccompProfile == "" {
+		return nil
+	}
+	f, err := os.Open(sb.SeccompProfile)
+	if err != nil {
+		return fmt.Errorf("failed to open seccomp profile: %w", err)
+	}
+	cmd.ExtraFiles = []*os.File{f}
+	return nil
+}
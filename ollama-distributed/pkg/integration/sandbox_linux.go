@@ -0,0 +1,153 @@
+//go:build linux
+
+package integration
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// cgroup is a cgroup v2 directory the Ollama server is started in, so
+// that its runners inherit the limits too
+type cgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newCgroup creates parent/name with the sandbox limits applied. parent
+// must be on a cgroup v2 hierarchy delegated to this process, e.g. via
+// systemd's Delegate=yes.
+func newCgroup(parent, name string, sb config.SandboxConfig) (*cgroup, error) {
+	if !onCgroup2(parent) {
+		return nil, fmt.Errorf("%s is not on a cgroup v2 hierarchy", parent)
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+
+	// Controllers must be enabled in the parent before children can use them
+	enabled := make(map[string]bool)
+	var controllers []string
+	for file := range cgroupLimits(sb) {
+		controller, _, _ := strings.Cut(file, ".")
+		if !enabled[controller] {
+			enabled[controller] = true
+			controllers = append(controllers, "+"+controller)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644); err != nil {
+		return nil, fmt.Errorf("failed to enable cgroup controllers: %w", err)
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+	for file, value := range cgroupLimits(sb) {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0644); err != nil {
+			// memory.swap.max is missing when swap accounting is disabled
+			if file == "memory.swap.max" && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			os.Remove(path)
+			return nil, fmt.Errorf("failed to set %s: %w", file, err)
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &cgroup{path: path, dir: dir}, nil
+}
+
+// onCgroup2 reports whether path, or its closest existing ancestor, is on
+// a cgroup v2 filesystem
+func onCgroup2(path string) bool {
+	const cgroup2SuperMagic = 0x63677270
+	for {
+		var fs syscall.Statfs_t
+		err := syscall.Statfs(path, &fs)
+		if err == nil {
+			return fs.Type == cgroup2SuperMagic
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, syscall.ENOENT) || parent == path {
+			return false
+		}
+		path = parent
+	}
+}
+
+// apply makes the command start directly inside the cgroup
+func (cg *cgroup) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+}
+
+// oomKills returns how many processes the kernel killed for exceeding the
+// memory limit
+func (cg *cgroup) oomKills() int {
+	f, err := os.Open(filepath.Join(cg.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			n, _ := strconv.Atoi(count)
+			return n
+		}
+	}
+	return 0
+}
+
+// kill terminates every process left in the cgroup
+func (cg *cgroup) kill() {
+	os.WriteFile(filepath.Join(cg.path, "cgroup.kill"), []byte("1"), 0644)
+}
+
+// close removes the cgroup once it is empty
+func (cg *cgroup) close() error {
+	cg.dir.Close()
+	return os.Remove(cg.path)
+}
+
+// setProcessGroup starts the command in its own process group so that
+// runner subprocesses are signalled with it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func terminateProcess(cmd *exec.Cmd) error {
+	return signalGroup(cmd, syscall.SIGTERM)
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	return signalGroup(cmd, syscall.SIGKILL)
+}
+
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build !linux
+
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// cgroup is unavailable outside Linux
+type cgroup struct{}
+
+func newCgroup(parent, name string, sb config.SandboxConfig) (*cgroup, error) {
+	return nil, fmt.Errorf("sandbox resource limits require Linux cgroup v2")
+}
+
+func (cg *cgroup) apply(cmd *exec.Cmd) {}
+
+func (cg *cgroup) oomKills() int { return 0 }
+
+func (cg *cgroup) kill() {}
+
+func (cg *cgroup) close() error { return nil }
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcess(cmd *exec.Cmd) error {
+	// Interrupt is not supported everywhere, e.g. on Windows
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		return killProcess(cmd)
+	}
+	return nil
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
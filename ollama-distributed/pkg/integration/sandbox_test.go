@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupLimits(t *testing.T) {
+	limits := cgroupLimits(config.SandboxConfig{CPULimit: 2.5, MemoryLimit: 8 << 30, PidsLimit: 512})
+	assert.Equal(t, map[string]string{
+		"cpu.max":         "250000 100000",
+		"memory.max":      "8589934592",
+		"memory.swap.max": "0",
+		"pids.max":        "512",
+	}, limits)
+
+	assert.Empty(t, cgroupLimits(config.SandboxConfig{}))
+	assert.False(t, hasResourceLimits(config.SandboxConfig{}))
+}
+
+func TestWrapCommand(t *testing.T) {
+	name, args, err := wrapCommand(config.SandboxConfig{AppArmorProfile: "ollama"}, "ollama", []string{"serve"})
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", name, "profiles are ignored while the sandbox is disabled")
+	assert.Equal(t, []string{"serve"}, args)
+
+	sb := config.SandboxConfig{Enabled: true, AppArmorProfile: "ollama", SeccompProfile: "/etc/ollama/seccomp.bpf"}
+	name, args, err = wrapCommand(sb, "ollama", []string{"serve"})
+	if _, lookErr := exec.LookPath("aa-exec"); lookErr != nil {
+		assert.Error(t, err)
+		return
+	}
+	if _, lookErr := exec.LookPath("bwrap"); lookErr != nil {
+		assert.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, "bwrap", name)
+	assert.Equal(t, []string{
+		"--die-with-parent", "--dev-bind", "/", "/", "--seccomp", "3",
+		"--", "aa-exec", "-p", "ollama", "--", "ollama", "serve",
+	}, args)
+}
+
+func TestRestartBackoff(t *testing.T) {
+	s := &supervisor{cfg: config.OllamaConfig{
+		MaxRestarts:       4,
+		RestartWindow:     time.Minute,
+		RestartBackoff:    time.Second,
+		MaxRestartBackoff: 3 * time.Second,
+	}}
+	now := time.Now()
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		delay, ok := s.nextRestart(0, now)
+		require.True(t, ok)
+		delays = append(delays, delay)
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, delays)
+
+	_, ok := s.nextRestart(0, now)
+	assert.False(t, ok, "budget for the window is exhausted")
+
+	// Once the window has passed, restarts are allowed again and a long
+	// healthy run resets the backoff
+	delay, ok := s.nextRestart(time.Hour, now.Add(2*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+}
+
+func TestSupervisorRestartsAndGivesUp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	cfg := config.OllamaConfig{
+		MaxRestarts:       2,
+		RestartWindow:     time.Minute,
+		RestartBackoff:    10 * time.Millisecond,
+		MaxRestartBackoff: 50 * time.Millisecond,
+	}
+	s, err := newSupervisor("test", cfg, os.Environ(), "sh", "-c", "exit 3")
+	require.NoError(t, err)
+	require.NoError(t, s.start(context.Background()))
+
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+	status := s.status()
+	assert.Equal(t, true, status["gave_up"])
+	assert.Equal(t, 2, status["restarts"])
+	assert.Contains(t, status["last_exit"], "exit status 3")
+	assert.NoError(t, s.stop(time.Second))
+}
+
+func TestSupervisorStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	s, err := newSupervisor("test", config.OllamaConfig{MaxRestarts: 5, RestartWindow: time.Minute}, os.Environ(), "sleep", "30")
+	require.NoError(t, err)
+	require.NoError(t, s.start(context.Background()))
+
+	start := time.Now()
+	require.NoError(t, s.stop(5*time.Second))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, false, s.status()["gave_up"], "a requested stop is not a crash")
+}
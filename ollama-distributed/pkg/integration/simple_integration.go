@@ -3,17 +3,21 @@ package integration
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/warmup"
 )
 
-// SimpleOllamaIntegration provides basic Ollama integration
+// SimpleOllamaIntegration provides basic Ollama integration. It manages
+// one or more local Ollama servers, e.g. one per GPU, each on its own port.
 type SimpleOllamaIntegration struct {
 	config    *config.Config
-	ollamaCmd *exec.Cmd
+	instances []*ollamaInstance // the first one serves the ollama CLI
 	started   bool
 	mu        sync.RWMutex
 	ctx       context.Context
@@ -25,9 +29,10 @@ func NewSimpleOllamaIntegration(cfg *config.Config) *SimpleOllamaIntegration {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &SimpleOllamaIntegration{
-		config: cfg,
-		ctx:    ctx,
-		cancel: cancel,
+		config:    cfg,
+		instances: resolveInstances(cfg.Ollama, detectNvidiaGPUs),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 }
 
@@ -41,75 +46,162 @@ func (soi *SimpleOllamaIntegration) Start() error {
 	}
 
 	// Check if Ollama is available
+	if soi.isNative() && !nativeRunnerAvailable {
+		return errNativeRunnerUnavailable
+	}
 	if !soi.isOllamaAvailable() {
 		fmt.Printf("⚠️  Ollama not found in PATH. Please install Ollama first.\n")
 		fmt.Printf("   Visit: https://ollama.com/download\n")
 		return fmt.Errorf("Ollama not available")
 	}
 
-	// Start Ollama server
-	if err := soi.startOllamaServer(); err != nil {
-		return fmt.Errorf("failed to start Ollama server: %w", err)
+	// Start Ollama servers; instances that fail are left out rather than
+	// failing the whole integration
+	var running []*ollamaInstance
+	var lastErr error
+	for _, instance := range soi.instances {
+		if err := soi.startOllamaServer(instance); err != nil {
+			fmt.Printf("⚠️  Ollama instance %s failed to start: %v\n", instance.name, err)
+			lastErr = err
+			continue
+		}
+		running = append(running, instance)
 	}
+	if len(running) == 0 {
+		return fmt.Errorf("failed to start Ollama server: %w", lastErr)
+	}
+	soi.instances = running
 
 	soi.started = true
 	fmt.Printf("✅ Ollama integration started successfully\n")
-	fmt.Printf("   Ollama API: http://localhost:11434\n")
+	for _, instance := range soi.instances {
+		fmt.Printf("   Ollama API (%s): %s\n", instance.name, instance.endpoint())
+	}
 	fmt.Printf("   Distributed API: %s\n", soi.GetDistributedAPIURL())
 
 	return nil
 }
 
+// isNative reports whether models are served by the built-in runner
+// instead of an Ollama daemon
+func (soi *SimpleOllamaIntegration) isNative() bool {
+	return soi.config.Ollama.Runtime == config.OllamaRuntimeNative
+}
+
 // isOllamaAvailable checks if Ollama is available in the system
 func (soi *SimpleOllamaIntegration) isOllamaAvailable() bool {
+	if soi.isNative() {
+		return nativeRunnerAvailable
+	}
 	_, err := exec.LookPath("ollama")
 	return err == nil
 }
 
-// startOllamaServer starts the Ollama server
-func (soi *SimpleOllamaIntegration) startOllamaServer() error {
+// startOllamaServer starts the Ollama server for an instance
+func (soi *SimpleOllamaIntegration) startOllamaServer(instance *ollamaInstance) error {
 	// Check if Ollama is already running
-	if soi.isOllamaRunning() {
-		fmt.Printf("ℹ️  Ollama server already running\n")
+	if instance.isRunning() {
+		if soi.isNative() {
+			return fmt.Errorf("%s is already in use, cannot start the native runner", instance.host)
+		}
+		fmt.Printf("ℹ️  Ollama server %s already running on %s\n", instance.name, instance.host)
 		return nil
 	}
 
-	// Start Ollama serve command
-	soi.ollamaCmd = exec.CommandContext(soi.ctx, "ollama", "serve")
+	if soi.isNative() {
+		return soi.startNativeRunner(instance)
+	}
 
-	// Set environment variables for Ollama
-	soi.ollamaCmd.Env = append(soi.ollamaCmd.Env,
-		"OLLAMA_HOST=127.0.0.1:11434",
-		"OLLAMA_KEEP_ALIVE=5m",
-	)
+	// Keep backend compilation caches where warm-up can share them
+	env := instance.env(os.Environ())
+	if soi.config.Warmup.Enabled && soi.config.Warmup.CacheDir != "" {
+		env = append(env, warmup.CacheEnv(soi.config.Warmup.CacheDir)...)
+	}
 
-	if err := soi.ollamaCmd.Start(); err != nil {
+	// Start Ollama serve command under supervision, sandboxed if configured
+	sup, err := newSupervisor(instance.name, soi.config.Ollama, env, "ollama", "serve")
+	if err != nil {
+		return err
+	}
+	if err := sup.start(soi.ctx); err != nil {
 		return fmt.Errorf("failed to start Ollama server: %w", err)
 	}
+	instance.supervisor = sup
 
 	// Wait for Ollama to be ready
-	if err := soi.waitForOllamaReady(); err != nil {
+	if err := waitForOllamaReady(instance); err != nil {
+		sup.stop(5 * time.Second)
+		instance.supervisor = nil
 		return fmt.Errorf("Ollama server failed to start: %w", err)
 	}
 
-	fmt.Printf("✅ Ollama server started successfully\n")
+	fmt.Printf("✅ Ollama server %s started successfully on %s\n", instance.name, instance.host)
+	if len(instance.gpus) > 0 {
+		fmt.Printf("   GPUs: %s\n", strings.Join(instance.gpus, ","))
+	}
+	if soi.config.Ollama.Sandbox.Enabled {
+		sb := soi.config.Ollama.Sandbox
+		fmt.Printf("   Sandbox: cpu=%.1f cores, memory=%d bytes, pids=%d\n", sb.CPULimit, sb.MemoryLimit, sb.PidsLimit)
+	}
 
-	// Monitor Ollama process
-	go soi.monitorOllamaProcess()
+	return nil
+}
 
+// startNativeRunner serves the instance from the in-process runner
+func (soi *SimpleOllamaIntegration) startNativeRunner(instance *ollamaInstance) error {
+	runner := newNativeRunner(soi.config.Ollama.Native, soi.config.Storage.ModelDir)
+	if err := runner.start(instance.host); err != nil {
+		return fmt.Errorf("failed to start native runner: %w", err)
+	}
+	instance.native = runner
+
+	if err := waitForOllamaReady(instance); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		runner.stop(ctx)
+		instance.native = nil
+		return fmt.Errorf("native runner failed to start: %w", err)
+	}
+
+	fmt.Printf("✅ Native runner started successfully on %s\n", instance.host)
+	fmt.Printf("   Models: %s/*.gguf\n", runner.modelsDir)
 	return nil
 }
 
-// isOllamaRunning checks if Ollama is already running
+// nativeRunner returns the in-process runner, which only exists once the
+// integration has started it
+func (soi *SimpleOllamaIntegration) nativeRunner() (*nativeRunner, error) {
+	if runner := soi.primary().native; runner != nil {
+		return runner, nil
+	}
+	return nil, fmt.Errorf("native runner is not running")
+}
+
+// isOllamaRunning checks if any Ollama instance is running
 func (soi *SimpleOllamaIntegration) isOllamaRunning() bool {
-	// Try to connect to Ollama API
-	cmd := exec.Command("curl", "-s", "http://localhost:11434/api/tags")
-	err := cmd.Run()
-	return err == nil
+	for _, instance := range soi.instances {
+		if instance.isRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// primary returns the instance the ollama CLI talks to
+func (soi *SimpleOllamaIntegration) primary() *ollamaInstance {
+	return soi.instances[0]
+}
+
+// ollamaCommand builds an ollama CLI command against the primary instance.
+// Models are stored once per node, so every instance sees what it pulls.
+func (soi *SimpleOllamaIntegration) ollamaCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("ollama", args...)
+	cmd.Env = append(os.Environ(), "OLLAMA_HOST="+soi.primary().host)
+	return cmd
 }
 
-// waitForOllamaReady waits for Ollama to be ready
-func (soi *SimpleOllamaIntegration) waitForOllamaReady() error {
+// waitForOllamaReady waits for an Ollama instance to be ready
+func waitForOllamaReady(instance *ollamaInstance) error {
 	timeout := time.After(30 * time.Second)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -119,32 +211,13 @@ func (soi *SimpleOllamaIntegration) waitForOllamaReady() error {
 		case <-timeout:
 			return fmt.Errorf("timeout waiting for Ollama to be ready")
 		case <-ticker.C:
-			if soi.isOllamaRunning() {
+			if instance.isRunning() {
 				return nil
 			}
 		}
 	}
 }
 
-// monitorOllamaProcess monitors the Ollama process
-func (soi *SimpleOllamaIntegration) monitorOllamaProcess() {
-	if soi.ollamaCmd == nil {
-		return
-	}
-
-	// Wait for the process to exit
-	err := soi.ollamaCmd.Wait()
-	if err != nil {
-		fmt.Printf("⚠️  Ollama process exited with error: %v\n", err)
-	} else {
-		fmt.Printf("ℹ️  Ollama process exited normally\n")
-	}
-
-	soi.mu.Lock()
-	soi.started = false
-	soi.mu.Unlock()
-}
-
 // GetStatus returns the integration status
 func (soi *SimpleOllamaIntegration) GetStatus() map[string]interface{} {
 	soi.mu.RLock()
@@ -157,9 +230,11 @@ func (soi *SimpleOllamaIntegration) GetStatus() map[string]interface{} {
 		"timestamp":           time.Now(),
 	}
 
-	if soi.ollamaCmd != nil && soi.ollamaCmd.Process != nil {
-		status["ollama_pid"] = soi.ollamaCmd.Process.Pid
+	instances := make([]map[string]interface{}, 0, len(soi.instances))
+	for _, instance := range soi.instances {
+		instances = append(instances, instance.status())
 	}
+	status["instances"] = instances
 
 	return status
 }
@@ -170,9 +245,13 @@ func (soi *SimpleOllamaIntegration) PullModel(modelName string) error {
 		return fmt.Errorf("Ollama is not running")
 	}
 
+	if soi.isNative() {
+		return fmt.Errorf("the native runner cannot pull models, copy %s.gguf into its models directory instead", modelName)
+	}
+
 	fmt.Printf("📥 Pulling model: %s\n", modelName)
 
-	cmd := exec.Command("ollama", "pull", modelName)
+	cmd := soi.ollamaCommand("pull", modelName)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to pull model %s: %w", modelName, err)
 	}
@@ -187,7 +266,23 @@ func (soi *SimpleOllamaIntegration) ListModels() ([]string, error) {
 		return nil, fmt.Errorf("Ollama is not running")
 	}
 
-	cmd := exec.Command("ollama", "list")
+	if soi.isNative() {
+		runner, err := soi.nativeRunner()
+		if err != nil {
+			return nil, err
+		}
+		available, err := runner.listModels()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		models := make([]string, 0, len(available))
+		for _, model := range available {
+			models = append(models, model.Name)
+		}
+		return models, nil
+	}
+
+	cmd := soi.ollamaCommand("list")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list models: %w", err)
@@ -211,7 +306,19 @@ func (soi *SimpleOllamaIntegration) RunModel(modelName, prompt string) (string,
 		return "", fmt.Errorf("Ollama is not running")
 	}
 
-	cmd := exec.Command("ollama", "run", modelName, prompt)
+	if soi.isNative() {
+		runner, err := soi.nativeRunner()
+		if err != nil {
+			return "", err
+		}
+		output, err := runner.generate(soi.ctx, modelName, prompt, runner.options(nil), func(string) bool { return true })
+		if err != nil {
+			return "", fmt.Errorf("failed to run model: %w", err)
+		}
+		return output, nil
+	}
+
+	cmd := soi.ollamaCommand("run", modelName, prompt)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to run model: %w", err)
@@ -231,17 +338,37 @@ func (soi *SimpleOllamaIntegration) Shutdown() error {
 
 	fmt.Printf("🛑 Shutting down Ollama integration\n")
 
-	// Cancel context to stop monitoring
-	soi.cancel()
+	// Stop the Ollama processes we started, before cancelling supervision
+	var wg sync.WaitGroup
+	for _, instance := range soi.instances {
+		if instance.supervisor == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(instance *ollamaInstance) {
+			defer wg.Done()
+			if err := instance.supervisor.stop(10 * time.Second); err != nil {
+				fmt.Printf("⚠️  Failed to stop Ollama process %s: %v\n", instance.name, err)
+			} else {
+				fmt.Printf("✅ Ollama process %s stopped\n", instance.name)
+			}
+		}(instance)
+	}
+	wg.Wait()
 
-	// Stop Ollama process if we started it
-	if soi.ollamaCmd != nil && soi.ollamaCmd.Process != nil {
-		if err := soi.ollamaCmd.Process.Kill(); err != nil {
-			fmt.Printf("⚠️  Failed to kill Ollama process: %v\n", err)
+	for _, instance := range soi.instances {
+		if instance.native == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := instance.native.stop(ctx); err != nil {
+			fmt.Printf("⚠️  Failed to stop native runner: %v\n", err)
 		} else {
-			fmt.Printf("✅ Ollama process stopped\n")
+			fmt.Printf("✅ Native runner stopped\n")
 		}
+		cancel()
 	}
+	soi.cancel()
 
 	soi.started = false
 	return nil
@@ -293,9 +420,27 @@ func (soi *SimpleOllamaIntegration) TestIntegration() error {
 	return nil
 }
 
-// GetOllamaAPIURL returns the Ollama API URL
+// GetOllamaAPIURL returns the API URL of the primary Ollama instance
 func (soi *SimpleOllamaIntegration) GetOllamaAPIURL() string {
-	return "http://localhost:11434"
+	soi.mu.RLock()
+	defer soi.mu.RUnlock()
+	return soi.primary().endpoint()
+}
+
+// LocalEndpoints returns the API URL of every running Ollama instance on
+// this node, keyed by instance name, so that each can be registered with
+// the proxy individually
+func (soi *SimpleOllamaIntegration) LocalEndpoints() map[string]string {
+	soi.mu.RLock()
+	defer soi.mu.RUnlock()
+
+	endpoints := make(map[string]string, len(soi.instances))
+	for _, instance := range soi.instances {
+		if instance.isRunning() {
+			endpoints[instance.name] = instance.endpoint()
+		}
+	}
+	return endpoints
 }
 
 // GetDistributedAPIURL returns the distributed API URL
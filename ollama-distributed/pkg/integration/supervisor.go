@@ -0,0 +1,274 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+var errSupervisorStopped = errors.New("supervisor stopped")
+
+// supervisor runs the Ollama server inside its sandbox and restarts it
+// with exponential backoff when it exits unexpectedly
+type supervisor struct {
+	id   string
+	cfg  config.OllamaConfig
+	name string
+	args []string
+	env  []string
+
+	cgroup *cgroup // nil when no resource limits are configured
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	startedAt time.Time
+	restarts  []time.Time
+	failures  int // consecutive short-lived runs, drives the backoff
+	lastExit  error
+	gaveUp    bool
+	stopping  bool
+	stopCh    chan struct{} // closed by stop to interrupt a backoff wait
+	done      chan struct{} // closed when run returns
+}
+
+// newSupervisor prepares the sandbox for name/args. id distinguishes
+// instances on the same node. Nothing is started until start is called.
+func newSupervisor(id string, cfg config.OllamaConfig, env []string, name string, args ...string) (*supervisor, error) {
+	s := &supervisor{
+		id:     id,
+		cfg:    cfg,
+		env:    env,
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	var err error
+	s.name, s.args, err = wrapCommand(cfg.Sandbox, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Sandbox.Enabled && hasResourceLimits(cfg.Sandbox) {
+		s.cgroup, err = newCgroup(cfg.Sandbox.CgroupParent, fmt.Sprintf("ollama-%d-%s", os.Getpid(), id), cfg.Sandbox)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up cgroup sandbox: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// start launches the process and supervises it until ctx is done or stop
+// is called
+func (s *supervisor) start(ctx context.Context) error {
+	if err := s.spawn(); err != nil {
+		s.cleanup()
+		return err
+	}
+	go s.run(ctx)
+	return nil
+}
+
+func (s *supervisor) spawn() error {
+	cmd := exec.Command(s.name, s.args...)
+	cmd.Env = s.env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	setProcessGroup(cmd)
+	if err := attachSeccomp(cmd, s.cfg.Sandbox); err != nil {
+		return err
+	}
+	defer func() {
+		// The child holds its own copy once started
+		for _, f := range cmd.ExtraFiles {
+			f.Close()
+		}
+	}()
+	if s.cgroup != nil {
+		s.cgroup.apply(cmd)
+	}
+
+	// Start under the lock so that stop always sees the current process
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return errSupervisorStopped
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", s.name, err)
+	}
+	s.cmd = cmd
+	s.startedAt = time.Now()
+	return nil
+}
+
+func (s *supervisor) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.cleanup()
+
+	for {
+		s.mu.Lock()
+		cmd := s.cmd
+		s.mu.Unlock()
+
+		err := cmd.Wait()
+
+		s.mu.Lock()
+		s.lastExit = err
+		stopping := s.stopping
+		ranFor := time.Since(s.startedAt)
+		s.mu.Unlock()
+
+		if stopping || ctx.Err() != nil {
+			return
+		}
+
+		if s.cgroup != nil {
+			if kills := s.cgroup.oomKills(); kills > 0 {
+				fmt.Printf("⚠️  Ollama server %s hit its memory limit (%d OOM kills so far)\n", s.id, kills)
+			}
+		}
+		fmt.Printf("⚠️  Ollama server %s exited unexpectedly after %s: %v\n", s.id, ranFor.Round(time.Second), err)
+
+		delay, ok := s.nextRestart(ranFor, time.Now())
+		if !ok {
+			fmt.Printf("❌ Ollama server %s restarted %d times within %s, giving up\n", s.id, s.cfg.MaxRestarts, s.cfg.RestartWindow)
+			return
+		}
+
+		fmt.Printf("🔄 Restarting Ollama server %s in %s\n", s.id, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-time.After(delay):
+		}
+
+		if err := s.spawn(); err != nil {
+			if errors.Is(err, errSupervisorStopped) {
+				return
+			}
+			fmt.Printf("❌ Failed to restart Ollama server %s: %v\n", s.id, err)
+			s.mu.Lock()
+			s.gaveUp = true
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// nextRestart records a restart and returns the delay before it, or false
+// when the restart budget for the window is exhausted
+func (s *supervisor) nextRestart(ranFor time.Duration, now time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop restarts that fell out of the window
+	kept := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.cfg.RestartWindow {
+			kept = append(kept, t)
+		}
+	}
+	s.restarts = kept
+
+	if len(s.restarts) >= s.cfg.MaxRestarts {
+		s.gaveUp = true
+		return 0, false
+	}
+	s.restarts = append(s.restarts, now)
+
+	maxBackoff := s.cfg.MaxRestartBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+
+	// A run that outlasted the maximum backoff counts as healthy
+	if ranFor > maxBackoff {
+		s.failures = 0
+	}
+	delay := s.cfg.RestartBackoff << s.failures
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	} else {
+		s.failures++
+	}
+	return delay, true
+}
+
+// stop terminates the process group, escalating to SIGKILL after timeout
+func (s *supervisor) stop(timeout time.Duration) error {
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopping = true
+	close(s.stopCh)
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil // already exited and gave up
+	default:
+	}
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	if err := terminateProcess(cmd); err != nil {
+		return err
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	if s.cgroup != nil {
+		s.cgroup.kill()
+	}
+	if err := killProcess(cmd); err != nil {
+		return err
+	}
+	<-s.done
+	return nil
+}
+
+func (s *supervisor) cleanup() {
+	if s.cgroup != nil {
+		s.cgroup.close()
+	}
+}
+
+// status reports the supervised process for GetStatus
+func (s *supervisor) status() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := map[string]interface{}{
+		"name":      s.id,
+		"restarts":  len(s.restarts),
+		"gave_up":   s.gaveUp,
+		"sandboxed": s.cfg.Sandbox.Enabled,
+	}
+	if s.cmd != nil && s.cmd.Process != nil {
+		status["pid"] = s.cmd.Process.Pid
+	}
+	if s.lastExit != nil {
+		status["last_exit"] = s.lastExit.Error()
+	}
+	if s.cgroup != nil {
+		status["oom_kills"] = s.cgroup.oomKills()
+	}
+	return status
+}
@@ -0,0 +1,245 @@
+// Package jobs runs long operations in the background and tracks their
+// progress, so API calls such as document ingestion can return at once and
+// be polled, or followed over the WebSocket, until they finish.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// DefaultRetain is the number of finished jobs kept when unset
+const DefaultRetain = 1000
+
+// ErrNotFound is returned for a job that does not exist
+var ErrNotFound = errors.New("job not found")
+
+// Job is the state of a background operation
+type Job struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Tenant string `json:"tenant,omitempty"`
+	Status string `json:"status"`
+	// Done of Total units of work are complete; Total is zero until known
+	Done    int64  `json:"done"`
+	Total   int64  `json:"total"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Result is what the operation returned once it succeeded
+	Result     interface{} `json:"result,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	FinishedAt time.Time   `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job is no longer running
+func (j Job) Finished() bool {
+	return j.Status != StatusRunning
+}
+
+// Func is a background operation, reporting its progress to p
+type Func func(ctx context.Context, p *Progress) (interface{}, error)
+
+// Manager runs jobs and keeps the latest finished ones
+type Manager struct {
+	retain int
+
+	mu        sync.RWMutex
+	jobs      map[string]*Job
+	cancels   map[string]context.CancelFunc
+	finished  []string // IDs of finished jobs, oldest first
+	listeners []func(Job)
+}
+
+// NewManager creates a manager keeping the latest retain finished jobs
+func NewManager(retain int) *Manager {
+	if retain <= 0 {
+		retain = DefaultRetain
+	}
+	return &Manager{
+		retain:  retain,
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Subscribe calls fn with the state of a job whenever it changes. fn must
+// not block.
+func (m *Manager) Subscribe(fn func(Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Start runs fn in the background as a job of the given kind and tenant and
+// returns the job. The job is canceled with Cancel or when ctx ends, so ctx
+// should outlive the request starting it.
+func (m *Manager) Start(ctx context.Context, kind, tenant string, fn Func) Job {
+	ctx, cancel := context.WithCancel(ctx)
+	now := time.Now()
+	job := &Job{ID: uuid.NewString(), Kind: kind, Tenant: tenant, Status: StatusRunning, CreatedAt: now, UpdatedAt: now}
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.cancels[job.ID] = cancel
+	snapshot := *job
+	m.mu.Unlock()
+	m.notify(snapshot)
+
+	go func() {
+		defer cancel()
+		result, err := fn(ctx, &Progress{m: m, id: job.ID})
+		m.finish(job.ID, result, err, ctx.Err() != nil)
+	}()
+	return snapshot
+}
+
+// finish records the outcome of a job
+func (m *Manager) finish(id string, result interface{}, err error, canceled bool) {
+	m.mu.Lock()
+	job := m.jobs[id]
+	job.UpdatedAt = time.Now()
+	job.FinishedAt = job.UpdatedAt
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Result = result
+	case canceled:
+		job.Status = StatusCanceled
+		job.Error = err.Error()
+	default:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	}
+	delete(m.cancels, id)
+	m.finished = append(m.finished, id)
+	for len(m.finished) > m.retain {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+	snapshot := *job
+	m.mu.Unlock()
+
+	if snapshot.Status == StatusFailed {
+		slog.Warn("job failed", "job_id", id, "kind", snapshot.Kind, "error", snapshot.Error)
+	}
+	m.notify(snapshot)
+}
+
+// Get returns a job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return *job, nil
+}
+
+// List returns the jobs of a tenant, or of everyone for an empty tenant,
+// newest first
+func (m *Manager) List(tenant string) []Job {
+	m.mu.RLock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if tenant == "" || job.Tenant == tenant {
+			jobs = append(jobs, *job)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// Cancel stops a running job; it reports its cancellation once its
+// operation returns
+func (m *Manager) Cancel(id string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.jobs[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	return nil
+}
+
+// update applies fn to a running job and notifies the listeners
+func (m *Manager) update(id string, fn func(*Job)) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Finished() {
+		m.mu.Unlock()
+		return
+	}
+	fn(job)
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	m.mu.Unlock()
+	m.notify(snapshot)
+}
+
+func (m *Manager) notify(job Job) {
+	m.mu.RLock()
+	listeners := m.listeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(job)
+	}
+}
+
+// Progress reports the progress of a running job. A nil Progress discards
+// the reports.
+type Progress struct {
+	m  *Manager
+	id string
+}
+
+// ID returns the ID of the job
+func (p *Progress) ID() string {
+	if p == nil {
+		return ""
+	}
+	return p.id
+}
+
+// AddTotal adds to the units of work of the job
+func (p *Progress) AddTotal(n int64) {
+	if p == nil {
+		return
+	}
+	p.m.update(p.id, func(j *Job) { j.Total += n })
+}
+
+// Add records units of work as done
+func (p *Progress) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.m.update(p.id, func(j *Job) { j.Done += n })
+}
+
+// SetMessage describes what the job is doing
+func (p *Progress) SetMessage(msg string) {
+	if p == nil {
+		return
+	}
+	p.m.update(p.id, func(j *Job) { j.Message = msg })
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitFinished polls a job until it finishes
+func waitFinished(t *testing.T, m *Manager, id string) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = m.Get(id)
+		require.NoError(t, err)
+		return job.Finished()
+	}, time.Second, time.Millisecond)
+	return job
+}
+
+func TestJobProgress(t *testing.T) {
+	m := NewManager(0)
+	var mu sync.Mutex
+	var updates []Job
+	m.Subscribe(func(job Job) {
+		mu.Lock()
+		defer mu.Unlock()
+		updates = append(updates, job)
+	})
+
+	job := m.Start(context.Background(), "ingest", "acme", func(ctx context.Context, p *Progress) (interface{}, error) {
+		p.AddTotal(3)
+		p.SetMessage("embedding")
+		p.Add(2)
+		p.Add(1)
+		return "ok", nil
+	})
+	assert.Equal(t, StatusRunning, job.Status)
+
+	job = waitFinished(t, m, job.ID)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, int64(3), job.Done)
+	assert.Equal(t, int64(3), job.Total)
+	assert.Equal(t, "ok", job.Result)
+
+	mu.Lock()
+	assert.Len(t, updates, 6)
+	assert.Equal(t, StatusSucceeded, updates[len(updates)-1].Status)
+	mu.Unlock()
+
+	assert.Len(t, m.List("acme"), 1)
+	assert.Empty(t, m.List("globex"))
+	assert.Len(t, m.List(""), 1)
+}
+
+func TestJobFailureAndCancel(t *testing.T) {
+	m := NewManager(1)
+
+	failed := m.Start(context.Background(), "ingest", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		return nil, errors.New("extractor missing")
+	})
+	job := waitFinished(t, m, failed.ID)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "extractor missing", job.Error)
+
+	started := make(chan struct{})
+	canceled := m.Start(context.Background(), "ingest", "", func(ctx context.Context, p *Progress) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+	require.NoError(t, m.Cancel(canceled.ID))
+	job = waitFinished(t, m, canceled.ID)
+	assert.Equal(t, StatusCanceled, job.Status)
+
+	// Only the latest finished job is retained
+	_, err := m.Get(failed.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, m.Cancel("missing"), ErrNotFound)
+}
@@ -0,0 +1,183 @@
+// Package jointoken admits nodes to a cluster with time-limited,
+// single-use join tokens instead of letting any node join. The leader
+// creates tokens and shares them through consensus; a joining node presents
+// one, which is then spent. Each token embeds the fingerprints of the
+// cluster CA, so that the joining node can tell the cluster it reaches is
+// the one the token was created for and not a spoofed one.
+package jointoken
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults of join tokens
+const (
+	DefaultTTL    = 24 * time.Hour
+	DefaultMaxTTL = 7 * 24 * time.Hour
+)
+
+// prefix starts every join token, versioning its format
+const prefix = "ojt1"
+
+// Errors redeeming a join token
+var (
+	ErrInvalid      = errors.New("invalid join token")
+	ErrExpired      = errors.New("join token expired")
+	ErrUsed         = errors.New("join token already used")
+	ErrWrongCluster = errors.New("join token was created for another cluster")
+)
+
+// ErrInvalidTTL is returned when creating a token with a TTL beyond the
+// maximum
+var ErrInvalidTTL = errors.New("invalid join token TTL")
+
+// Config configures join tokens
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultTTL is how long tokens created without a TTL are valid, and
+	// MaxTTL the longest TTL a token may have
+	DefaultTTL time.Duration `yaml:"default_ttl" mapstructure:"default_ttl"`
+	MaxTTL     time.Duration `yaml:"max_ttl" mapstructure:"max_ttl"`
+}
+
+// Validate checks the TTLs
+func (c Config) Validate() error {
+	if c.DefaultTTL < 0 || c.MaxTTL < 0 {
+		return fmt.Errorf("join token TTLs must not be negative")
+	}
+	cfg := c.withDefaults()
+	if cfg.DefaultTTL > cfg.MaxTTL {
+		return fmt.Errorf("default join token TTL %s exceeds the maximum %s", cfg.DefaultTTL, cfg.MaxTTL)
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.DefaultTTL == 0 {
+		c.DefaultTTL = DefaultTTL
+	}
+	if c.MaxTTL == 0 {
+		c.MaxTTL = DefaultMaxTTL
+	}
+	return c
+}
+
+// Token is a join token: the ID and secret identifying it on the leader,
+// and the fingerprints of the CA of the cluster it admits to
+type Token struct {
+	ID             string
+	Secret         string
+	CAFingerprints []string
+}
+
+// String encodes the token as handed to the joining node
+func (t Token) String() string {
+	return strings.Join([]string{prefix, t.ID, t.Secret, strings.Join(t.CAFingerprints, ",")}, ".")
+}
+
+// Parse decodes a join token
+func Parse(s string) (Token, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 4 || parts[0] != prefix || !isHex(parts[1]) || !isHex(parts[2]) {
+		return Token{}, ErrInvalid
+	}
+	t := Token{ID: parts[1], Secret: parts[2], CAFingerprints: strings.Split(parts[3], ",")}
+	for _, fp := range t.CAFingerprints {
+		if len(fp) != sha256.Size*2 || !isHex(fp) {
+			return Token{}, ErrInvalid
+		}
+	}
+	return t, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded certificate
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// CAFingerprints returns the fingerprints of the certificates in a PEM
+// encoded CA bundle
+func CAFingerprints(caPEM []byte) ([]string, error) {
+	certs, err := parseCerts(caPEM)
+	if err != nil {
+		return nil, err
+	}
+	fps := make([]string, len(certs))
+	for i, cert := range certs {
+		fps[i] = Fingerprint(cert.Raw)
+	}
+	return fps, nil
+}
+
+// TrustedPool returns a pool of the certificates of a CA bundle, as served
+// by a cluster, that the token vouches for. It fails when the token
+// vouches for none of them, i.e. the cluster is not the one the token was
+// created for.
+func TrustedPool(t Token, caPEM []byte) (*x509.CertPool, error) {
+	certs, err := parseCerts(caPEM)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	trusted := false
+	for _, cert := range certs {
+		if contains(t.CAFingerprints, Fingerprint(cert.Raw)) {
+			pool.AddCert(cert)
+			trusted = true
+		}
+	}
+	if !trusted {
+		return nil, ErrWrongCluster
+	}
+	return pool, nil
+}
+
+// parseCerts parses the certificates of a PEM bundle
+func parseCerts(caPEM []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, caPEM = pem.Decode(caPEM)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in CA bundle")
+	}
+	return certs, nil
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package jointoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	leader bool
+	state  map[string][]byte
+}
+
+func (s *memoryStore) Apply(key string, value interface{}, _ map[string]interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.state[key] = data
+	return nil
+}
+
+func (s *memoryStore) Get(key string) (interface{}, bool) {
+	data, ok := s.state[key]
+	if !ok {
+		return nil, false
+	}
+	var value interface{}
+	_ = json.Unmarshal(data, &value)
+	return value, true
+}
+
+func (s *memoryStore) IsLeader() bool { return s.leader }
+
+// testCA returns a PEM encoded self-signed CA certificate
+func testCA(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestTokensAreSingleUse(t *testing.T) {
+	store := &memoryStore{leader: true, state: make(map[string][]byte)}
+	m, err := NewManager(Config{}, testCA(t, "cluster"), store)
+	require.NoError(t, err)
+
+	token, issued, err := m.Create(0, "gpu-node-7")
+	require.NoError(t, err)
+	assert.Equal(t, issued.CreatedAt.Add(DefaultTTL), issued.ExpiresAt)
+	assert.Empty(t, issued.SecretHash)
+
+	parsed, err := Parse(token.String())
+	require.NoError(t, err)
+	assert.Equal(t, token, parsed)
+
+	redeemed, err := m.Redeem(token.String(), "node-7")
+	require.NoError(t, err)
+	assert.Equal(t, "node-7", redeemed.UsedBy)
+	_, err = m.Redeem(token.String(), "node-8")
+	assert.ErrorIs(t, err, ErrUsed)
+
+	forged := token
+	forged.Secret = "00" + token.Secret[2:]
+	if forged.Secret == token.Secret {
+		forged.Secret = "11" + token.Secret[2:]
+	}
+	_, err = m.Redeem(forged.String(), "node-8")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	tokens, err := m.Tokens()
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "node-7", tokens[0].UsedBy)
+	assert.Empty(t, tokens[0].SecretHash)
+
+	store.leader = false
+	_, _, err = m.Create(0, "")
+	assert.ErrorIs(t, err, ErrNotLeader)
+}
+
+func TestTokensExpire(t *testing.T) {
+	m, err := NewManager(Config{}, testCA(t, "cluster"), nil)
+	require.NoError(t, err)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	token, _, err := m.Create(time.Hour, "")
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = m.Redeem(token.String(), "node-1")
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, _, err = m.Create(30*24*time.Hour, "")
+	assert.ErrorIs(t, err, ErrInvalidTTL, "TTLs are capped")
+
+	spent, _, err := m.Create(time.Hour, "")
+	require.NoError(t, err)
+	_, err = m.Redeem(spent.String(), "node-2")
+	require.NoError(t, err)
+
+	// Expired tokens are dropped after the retention, spent ones are kept
+	now = now.Add(2 * retention)
+	_, _, err = m.Create(0, "")
+	require.NoError(t, err)
+	tokens, err := m.Tokens()
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+	assert.True(t, m.Admitted("node-2"), "nodes stay admitted once their token expires")
+	assert.False(t, m.Admitted("node-1"))
+
+	found, err := m.Revoke(spent.ID)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.False(t, m.Admitted("node-2"), "revoking a spent token withdraws the admission")
+	tokens, _ = m.Tokens()
+	assert.Len(t, tokens, 1)
+}
+
+func TestTokensBindToClusterCA(t *testing.T) {
+	clusterCA, spoofedCA := testCA(t, "cluster"), testCA(t, "spoofed")
+	m, err := NewManager(Config{}, clusterCA, nil)
+	require.NoError(t, err)
+	other, err := NewManager(Config{}, spoofedCA, nil)
+	require.NoError(t, err)
+
+	token, _, err := m.Create(0, "")
+	require.NoError(t, err)
+	pool, err := TrustedPool(token, clusterCA)
+	require.NoError(t, err)
+	assert.NotNil(t, pool)
+	_, err = TrustedPool(token, spoofedCA)
+	assert.ErrorIs(t, err, ErrWrongCluster, "a spoofed cluster serves a CA the token does not vouch for")
+
+	_, err = other.Redeem(token.String(), "node-1")
+	assert.ErrorIs(t, err, ErrWrongCluster)
+}
+
+func TestParseRejectsMalformedTokens(t *testing.T) {
+	for _, s := range []string{"", "ojt1.abc", "ojt2.00.00.00", "ojt1.zz.00." + Fingerprint([]byte("ca")), "ojt1.00.00.abcd"} {
+		_, err := Parse(s)
+		assert.ErrorIs(t, err, ErrInvalid, s)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{DefaultTTL: time.Hour}.Validate())
+	assert.Error(t, Config{MaxTTL: -time.Hour}.Validate())
+	assert.Error(t, Config{DefaultTTL: 48 * time.Hour, MaxTTL: time.Hour}.Validate())
+}
@@ -0,0 +1,298 @@
+package jointoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tokensKey is the consensus key holding every join token
+const tokensKey = "join_tokens"
+
+// retention is how long tokens that expired unused are kept for auditing.
+// Spent tokens are kept as the record of the nodes admitted.
+const retention = 24 * time.Hour
+
+// ErrNotLeader is returned when tokens are created, redeemed or revoked on
+// a node other than the consensus leader
+var ErrNotLeader = errors.New("join tokens are managed by the leader")
+
+// Store is the replicated state tokens are shared through, i.e. the
+// consensus engine
+type Store interface {
+	Apply(key string, value interface{}, metadata map[string]interface{}) error
+	Get(key string) (interface{}, bool)
+	IsLeader() bool
+}
+
+// Issued is a join token as kept by the cluster: only a hash of its secret
+// is stored
+type Issued struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description,omitempty"`
+	SecretHash  string    `json:"secret_hash,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// UsedBy is the node that joined with the token, at UsedAt
+	UsedBy string    `json:"used_by,omitempty"`
+	UsedAt time.Time `json:"used_at,omitempty"`
+}
+
+// Manager creates and redeems the join tokens of a cluster
+type Manager struct {
+	cfg          Config
+	fingerprints []string
+	caPEM        []byte
+	store        Store
+	now          func() time.Time
+
+	mu    sync.Mutex
+	local map[string]Issued // without a store
+}
+
+// NewManager creates a manager for the cluster with the CA bundle caPEM.
+// Without a store, tokens are local to the node.
+func NewManager(cfg Config, caPEM []byte, store Store) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	fingerprints, err := CAFingerprints(caPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster CA: %w", err)
+	}
+	return &Manager{
+		cfg:          cfg.withDefaults(),
+		fingerprints: fingerprints,
+		caPEM:        caPEM,
+		store:        store,
+		now:          time.Now,
+		local:        make(map[string]Issued),
+	}, nil
+}
+
+// CA returns the PEM encoded CA bundle of the cluster
+func (m *Manager) CA() []byte {
+	return m.caPEM
+}
+
+// Fingerprints returns the fingerprints of the cluster CA
+func (m *Manager) Fingerprints() []string {
+	return m.fingerprints
+}
+
+// Create issues a token valid for ttl, or the default TTL when zero
+func (m *Manager) Create(ttl time.Duration, description string) (Token, Issued, error) {
+	if ttl == 0 {
+		ttl = m.cfg.DefaultTTL
+	}
+	if ttl < 0 || ttl > m.cfg.MaxTTL {
+		return Token{}, Issued{}, fmt.Errorf("%w: must be between 0 and %s", ErrInvalidTTL, m.cfg.MaxTTL)
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return Token{}, Issued{}, err
+	}
+	secret, err := randomHex(16)
+	if err != nil {
+		return Token{}, Issued{}, err
+	}
+	now := m.now()
+	issued := Issued{
+		ID:          id,
+		Description: description,
+		SecretHash:  hashSecret(secret),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	err = m.update(func(tokens map[string]Issued) (bool, error) {
+		tokens[id] = issued
+		return true, nil
+	})
+	if err != nil {
+		return Token{}, Issued{}, err
+	}
+
+	issued.SecretHash = ""
+	return Token{ID: id, Secret: secret, CAFingerprints: m.fingerprints}, issued, nil
+}
+
+// Redeem spends a token for a joining node. Tokens created for another
+// cluster, unknown, expired or already used are refused.
+func (m *Manager) Redeem(token, nodeID string) (Issued, error) {
+	t, err := Parse(token)
+	if err != nil {
+		return Issued{}, err
+	}
+	if !m.vouches(t) {
+		return Issued{}, ErrWrongCluster
+	}
+
+	var redeemed Issued
+	err = m.update(func(tokens map[string]Issued) (bool, error) {
+		issued, ok := tokens[t.ID]
+		if !ok || subtle.ConstantTimeCompare([]byte(issued.SecretHash), []byte(hashSecret(t.Secret))) != 1 {
+			return false, ErrInvalid
+		}
+		if issued.UsedBy != "" {
+			return false, ErrUsed
+		}
+		now := m.now()
+		if !now.Before(issued.ExpiresAt) {
+			return false, ErrExpired
+		}
+		issued.UsedBy, issued.UsedAt = nodeID, now
+		tokens[t.ID] = issued
+		redeemed = issued
+		return true, nil
+	})
+	redeemed.SecretHash = ""
+	return redeemed, err
+}
+
+// Revoke deletes a token so that it can no longer be used, and reports
+// whether it existed. Revoking a spent token withdraws the admission of
+// the node that joined with it.
+func (m *Manager) Revoke(id string) (bool, error) {
+	found := false
+	err := m.update(func(tokens map[string]Issued) (bool, error) {
+		_, found = tokens[id]
+		delete(tokens, id)
+		return found, nil
+	})
+	return found, err
+}
+
+// Tokens returns the tokens, oldest first, without their secret hashes
+func (m *Manager) Tokens() ([]Issued, error) {
+	m.mu.Lock()
+	tokens, err := m.load()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Issued, 0, len(tokens))
+	for _, issued := range tokens {
+		issued.SecretHash = ""
+		list = append(list, issued)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+// Admitted reports whether a node joined with a token that has not been
+// revoked
+func (m *Manager) Admitted(nodeID string) bool {
+	m.mu.Lock()
+	tokens, err := m.load()
+	m.mu.Unlock()
+	if err != nil || nodeID == "" {
+		return false
+	}
+	for _, issued := range tokens {
+		if issued.UsedBy == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// vouches reports whether a token was created for this cluster
+func (m *Manager) vouches(t Token) bool {
+	for _, fp := range t.CAFingerprints {
+		if contains(m.fingerprints, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// update changes a copy of the tokens, dropping unused ones past retention,
+// and, if fn reports a change, applies it through the store before keeping
+// it
+func (m *Manager) update(fn func(map[string]Issued) (bool, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.store != nil && !m.store.IsLeader() {
+		return ErrNotLeader
+	}
+	tokens, err := m.load()
+	if err != nil {
+		return err
+	}
+	changed, err := fn(tokens)
+	if err != nil || !changed {
+		return err
+	}
+	now := m.now()
+	for id, issued := range tokens {
+		if issued.UsedBy == "" && now.Sub(issued.ExpiresAt) > retention {
+			delete(tokens, id)
+		}
+	}
+
+	if m.store != nil {
+		if err := m.store.Apply(tokensKey, tokens, nil); err != nil {
+			return fmt.Errorf("failed to share join tokens: %w", err)
+		}
+		return nil
+	}
+	m.local = tokens
+	return nil
+}
+
+// load returns a copy of the tokens; m.mu must be held
+func (m *Manager) load() (map[string]Issued, error) {
+	tokens := make(map[string]Issued)
+	if m.store == nil {
+		for id, issued := range m.local {
+			tokens[id] = issued
+		}
+		return tokens, nil
+	}
+
+	value, exists := m.store.Get(tokensKey)
+	if !exists {
+		return tokens, nil
+	}
+	// Values arrive decoded from the replicated log, so they are converted
+	// via JSON
+	if stored, ok := value.(map[string]Issued); ok {
+		for id, issued := range stored {
+			tokens[id] = issued
+		}
+		return tokens, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode join tokens: %w", err)
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode join tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// hashSecret returns the hash a token secret is stored as
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate join token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
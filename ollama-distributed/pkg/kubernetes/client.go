@@ -0,0 +1,217 @@
+// Package kubernetes holds the small part of the Kubernetes API a node uses
+// when it runs in a pod: publishing the Raft leader to a Lease and labelling
+// pods with their Raft role. It talks to the API server directly with the
+// pod's service account rather than pulling in client-go.
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceAccountDir is where Kubernetes mounts the pod's service account
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTimeFormat is the layout of Kubernetes MicroTime fields
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Client calls the Kubernetes API server
+type Client struct {
+	host      string
+	token     string
+	tokenFile string // re-read on every request, as projected tokens rotate
+	client    *http.Client
+}
+
+// NewClient creates a client for the API server at host authenticating with
+// a bearer token
+func NewClient(host, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{
+		host:   strings.TrimSuffix(host, "/"),
+		token:  token,
+		client: httpClient,
+	}
+}
+
+// InCluster creates a client from the service account mounted in the pod
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a kubernetes pod")
+	}
+
+	ca, err := os.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("service account CA holds no certificates")
+	}
+
+	tokenFile := filepath.Join(ServiceAccountDir, "token")
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	c := NewClient("https://"+net.JoinHostPort(host, port), "", &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	})
+	c.tokenFile = tokenFile
+	return c, nil
+}
+
+// Namespace returns the pod's namespace from POD_NAMESPACE or the service
+// account, or "default"
+func Namespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if data, err := os.ReadFile(filepath.Join(ServiceAccountDir, "namespace")); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns
+		}
+	}
+	return "default"
+}
+
+// Lease is the part of a coordination.k8s.io/v1 Lease the node manages
+type Lease struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// UpdateLease makes holder the holder of the named Lease and renews it,
+// creating the Lease if it does not exist
+func (c *Client) UpdateLease(ctx context.Context, namespace, name, holder string, duration time.Duration) error {
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases/%s", namespace, name)
+	now := time.Now().UTC().Format(microTimeFormat)
+
+	var current struct {
+		Spec Lease `json:"spec"`
+	}
+	status, err := c.do(ctx, http.MethodGet, path, "", nil, &current)
+	if status == http.StatusNotFound {
+		lease := map[string]interface{}{
+			"apiVersion": "coordination.k8s.io/v1",
+			"kind":       "Lease",
+			"metadata":   map[string]string{"name": name, "namespace": namespace},
+			"spec": Lease{
+				HolderIdentity:       holder,
+				LeaseDurationSeconds: int(duration.Seconds()),
+				AcquireTime:          now,
+				RenewTime:            now,
+			},
+		}
+		_, err = c.do(ctx, http.MethodPost, fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", namespace),
+			"application/json", lease, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	spec := Lease{
+		HolderIdentity:       holder,
+		LeaseDurationSeconds: int(duration.Seconds()),
+		AcquireTime:          current.Spec.AcquireTime,
+		RenewTime:            now,
+		LeaseTransitions:     current.Spec.LeaseTransitions,
+	}
+	if current.Spec.HolderIdentity != holder {
+		spec.AcquireTime = now
+		spec.LeaseTransitions++
+	}
+	_, err = c.do(ctx, http.MethodPatch, path, "application/merge-patch+json",
+		map[string]interface{}{"spec": spec}, nil)
+	return err
+}
+
+// PatchPodMetadata merges labels and annotations into a pod's metadata
+func (c *Client) PatchPodMetadata(ctx context.Context, namespace, pod string, labels, annotations map[string]string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      labels,
+			"annotations": annotations,
+		},
+	}
+	_, err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", namespace, pod),
+		"application/merge-patch+json", patch, nil)
+	return err
+}
+
+// do sends a request and decodes a successful JSON response into result. The
+// status code is returned alongside any error.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		data, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("kubernetes %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&status)
+		if status.Message == "" {
+			status.Message = resp.Status
+		}
+		return resp.StatusCode, fmt.Errorf("kubernetes %s %s failed: %s", method, path, status.Message)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode kubernetes response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
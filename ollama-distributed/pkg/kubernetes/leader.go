@@ -0,0 +1,113 @@
+package kubernetes
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Pod metadata the leader publisher maintains
+const (
+	// RoleLabel is "leader" on the Raft leader's pod and "follower" on the
+	// others; select RoleLabel=leader in a Service to route to the leader
+	RoleLabel = "ollamamax.io/raft-role"
+	// LeaderAnnotation names the current Raft leader on every pod
+	LeaderAnnotation = "ollamamax.io/raft-leader"
+)
+
+// Raft roles written to RoleLabel
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// LeaderState reports whether this node leads the Raft cluster and the ID of
+// the leader, empty when none is known
+type LeaderState func() (isLeader bool, leaderID string)
+
+// PublisherConfig configures a LeaderPublisher
+type PublisherConfig struct {
+	Namespace string
+	PodName   string
+	Identity  string // written as the Lease holder, usually the node ID
+
+	LeaseName string // empty to not maintain a Lease
+	LabelPod  bool   // label and annotate the pod with its role
+
+	// Interval at which the Lease is renewed and the pod metadata checked;
+	// the Lease lasts three intervals
+	Interval time.Duration
+
+	Logger *slog.Logger
+}
+
+// LeaderPublisher publishes the Raft leader to Kubernetes
+type LeaderPublisher struct {
+	client *Client
+	config PublisherConfig
+
+	// last pod metadata written, to patch the pod only on change
+	lastRole   string
+	lastLeader string
+}
+
+// NewLeaderPublisher creates a publisher
+func NewLeaderPublisher(client *Client, config PublisherConfig) *LeaderPublisher {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &LeaderPublisher{client: client, config: config}
+}
+
+// Publish renews the Lease when this node leads and updates the pod's role
+// label and leader annotation when they changed
+func (p *LeaderPublisher) Publish(ctx context.Context, isLeader bool, leaderID string) error {
+	if isLeader && p.config.LeaseName != "" {
+		if err := p.client.UpdateLease(ctx, p.config.Namespace, p.config.LeaseName,
+			p.config.Identity, 3*p.config.Interval); err != nil {
+			return err
+		}
+	}
+
+	if !p.config.LabelPod || p.config.PodName == "" {
+		return nil
+	}
+	role := RoleFollower
+	if isLeader {
+		role = RoleLeader
+	}
+	if role == p.lastRole && leaderID == p.lastLeader {
+		return nil
+	}
+	if err := p.client.PatchPodMetadata(ctx, p.config.Namespace, p.config.PodName,
+		map[string]string{RoleLabel: role},
+		map[string]string{LeaderAnnotation: leaderID}); err != nil {
+		return err
+	}
+	p.lastRole, p.lastLeader = role, leaderID
+	return nil
+}
+
+// Run publishes the leader state on start, whenever changes fires and every
+// interval, until ctx is done
+func (p *LeaderPublisher) Run(ctx context.Context, changes <-chan bool, state LeaderState) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		isLeader, leaderID := state()
+		if err := p.Publish(ctx, isLeader, leaderID); err != nil && ctx.Err() == nil {
+			p.config.Logger.Warn("failed to publish raft leader to kubernetes", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIServer holds one Lease and records pod patches
+type fakeAPIServer struct {
+	mu         sync.Mutex
+	lease      *Lease
+	podPatches []map[string]map[string]map[string]string
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const leasePath = "/apis/coordination.k8s.io/v1/namespaces/ollama/leases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == leasePath+"/raft-leader":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "leases \"raft-leader\" not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"spec": f.lease})
+	case r.Method == http.MethodPost && r.URL.Path == leasePath:
+		var body struct {
+			Spec Lease `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.lease = &body.Spec
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch && r.URL.Path == leasePath+"/raft-leader":
+		var body struct {
+			Spec Lease `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.lease = &body.Spec
+	case r.Method == http.MethodPatch && r.URL.Path == "/api/v1/namespaces/ollama/pods/ollama-0":
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var patch map[string]map[string]map[string]string
+		json.NewDecoder(r.Body).Decode(&patch)
+		f.podPatches = append(f.podPatches, patch)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestPublisher(t *testing.T, api *fakeAPIServer) *LeaderPublisher {
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	return NewLeaderPublisher(NewClient(server.URL, "token", nil), PublisherConfig{
+		Namespace: "ollama",
+		PodName:   "ollama-0",
+		Identity:  "ollama-0",
+		LeaseName: "raft-leader",
+		LabelPod:  true,
+		Interval:  5 * time.Second,
+	})
+}
+
+func TestPublishLeaderCreatesAndTakesOverLease(t *testing.T) {
+	api := &fakeAPIServer{}
+	publisher := newTestPublisher(t, api)
+
+	require.NoError(t, publisher.Publish(context.Background(), true, "ollama-0"))
+	require.NotNil(t, api.lease)
+	assert.Equal(t, "ollama-0", api.lease.HolderIdentity)
+	assert.Equal(t, 15, api.lease.LeaseDurationSeconds)
+	assert.Equal(t, 0, api.lease.LeaseTransitions)
+
+	api.lease.HolderIdentity = "ollama-1"
+	require.NoError(t, publisher.Publish(context.Background(), true, "ollama-0"))
+	assert.Equal(t, "ollama-0", api.lease.HolderIdentity)
+	assert.Equal(t, 1, api.lease.LeaseTransitions)
+}
+
+func TestPublishLabelsPodOnChange(t *testing.T) {
+	api := &fakeAPIServer{}
+	publisher := newTestPublisher(t, api)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, false, "ollama-1"))
+	require.NoError(t, publisher.Publish(ctx, false, "ollama-1"))
+	assert.Nil(t, api.lease, "followers leave the lease alone")
+	require.NoError(t, publisher.Publish(ctx, true, "ollama-0"))
+
+	require.Len(t, api.podPatches, 2, "unchanged metadata is not patched again")
+	assert.Equal(t, RoleFollower, api.podPatches[0]["metadata"]["labels"][RoleLabel])
+	assert.Equal(t, "ollama-1", api.podPatches[0]["metadata"]["annotations"][LeaderAnnotation])
+	assert.Equal(t, RoleLeader, api.podPatches[1]["metadata"]["labels"][RoleLabel])
+}
+
+func TestPublishReportsAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"message": "leases is forbidden"})
+	}))
+	defer server.Close()
+
+	publisher := NewLeaderPublisher(NewClient(server.URL, "", nil), PublisherConfig{
+		Namespace: "ollama", Identity: "ollama-0", LeaseName: "raft-leader",
+	})
+	err := publisher.Publish(context.Background(), true, "ollama-0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "leases is forbidden")
+}
@@ -0,0 +1,345 @@
+// Package licensing tracks the license of each model and gates inference
+// requests on license policies, such as keeping non-commercial models away
+// from a group of tenants. Licenses come from the model manifest or are
+// provided by an administrator, the latter taking precedence.
+package licensing
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sources of a model's license
+const (
+	SourceManifest = "manifest"
+	SourceUser     = "user"
+)
+
+// Manifest metadata keys describing a model's license
+const (
+	MetadataLicense     = "license"
+	MetadataLicenseName = "license_name"
+	MetadataLicenseURL  = "license_url"
+)
+
+// License describes the terms a model is distributed under
+type License struct {
+	// ID is the SPDX identifier or the name of a model license, e.g.
+	// "apache-2.0" or "llama3"
+	ID   string `yaml:"id" json:"id"`
+	Name string `yaml:"name" json:"name,omitempty"`
+	URL  string `yaml:"url" json:"url,omitempty"`
+	// NonCommercial is set for licenses that forbid commercial use; known
+	// licenses and IDs naming a non-commercial variant set it implicitly
+	NonCommercial bool `yaml:"non_commercial" mapstructure:"non_commercial" json:"non_commercial"`
+}
+
+// knownLicenses fills in the name and commercial terms of common licenses
+var knownLicenses = map[string]License{
+	"apache-2.0":      {Name: "Apache License 2.0"},
+	"mit":             {Name: "MIT License"},
+	"bsd-3-clause":    {Name: "BSD 3-Clause License"},
+	"openrail":        {Name: "Open RAIL License"},
+	"openrail-m":      {Name: "Open RAIL-M License"},
+	"llama2":          {Name: "Llama 2 Community License"},
+	"llama3":          {Name: "Meta Llama 3 Community License"},
+	"llama3.1":        {Name: "Llama 3.1 Community License"},
+	"llama3.2":        {Name: "Llama 3.2 Community License"},
+	"gemma":           {Name: "Gemma Terms of Use"},
+	"cc-by-4.0":       {Name: "Creative Commons Attribution 4.0"},
+	"cc-by-sa-4.0":    {Name: "Creative Commons Attribution Share Alike 4.0"},
+	"cc-by-nc-4.0":    {Name: "Creative Commons Attribution Non Commercial 4.0", NonCommercial: true},
+	"cc-by-nc-sa-4.0": {Name: "Creative Commons Attribution Non Commercial Share Alike 4.0", NonCommercial: true},
+	"cc-by-nc-nd-4.0": {Name: "Creative Commons Attribution Non Commercial No Derivatives 4.0", NonCommercial: true},
+	"mnpl-0.1":        {Name: "Mistral AI Non-Production License", NonCommercial: true},
+	"qwen-research":   {Name: "Qwen Research License", NonCommercial: true},
+	"deepseek":        {Name: "DeepSeek License"},
+	"falcon-llm":      {Name: "Falcon LLM License"},
+	"stabilityai-nc":  {Name: "Stability AI Non-Commercial Research Community License", NonCommercial: true},
+}
+
+// nonCommercialTokens mark an unknown license ID as non-commercial
+var nonCommercialTokens = []string{"nc", "noncommercial", "research"}
+
+// Parse returns the license an ID refers to, with the name and commercial
+// terms of known licenses filled in
+func Parse(id string) License {
+	return License{ID: id}.normalize()
+}
+
+// normalize fills in what is known about the license's ID
+func (l License) normalize() License {
+	l.ID = strings.ToLower(strings.TrimSpace(l.ID))
+	if known, ok := knownLicenses[l.ID]; ok {
+		if l.Name == "" {
+			l.Name = known.Name
+		}
+		l.NonCommercial = l.NonCommercial || known.NonCommercial
+		return l
+	}
+	tokens := strings.FieldsFunc(l.ID, func(r rune) bool { return r == '-' || r == '_' || r == ' ' || r == '.' })
+	for _, token := range tokens {
+		for _, nc := range nonCommercialTokens {
+			if token == nc {
+				l.NonCommercial = true
+			}
+		}
+	}
+	if strings.Contains(l.ID, "non-commercial") || strings.Contains(l.ID, "non-production") {
+		l.NonCommercial = true
+	}
+	return l
+}
+
+// Known reports whether the license is identified
+func (l License) Known() bool {
+	return l.ID != "" && l.ID != "unknown"
+}
+
+// ModelLicense is the license and metadata recorded for a model
+type ModelLicense struct {
+	Model   string  `yaml:"model" json:"model"`
+	License License `yaml:"license" json:"license"`
+	// Metadata holds further facts about the model, e.g. its author or
+	// acceptable use policy
+	Metadata map[string]string `yaml:"metadata" json:"metadata,omitempty"`
+	Source   string            `yaml:"-" json:"source"`
+}
+
+// Rule restricts which tenants may use models under which licenses
+type Rule struct {
+	Name string `yaml:"name" json:"name"`
+	// Tenants and Groups the rule applies to, as path.Match patterns; with
+	// neither the rule applies to every caller
+	Tenants []string `yaml:"tenants" json:"tenants,omitempty"`
+	Groups  []string `yaml:"groups" json:"groups,omitempty"`
+
+	// DenyNonCommercial denies models under non-commercial licenses
+	DenyNonCommercial bool `yaml:"deny_non_commercial" mapstructure:"deny_non_commercial" json:"deny_non_commercial,omitempty"`
+	// DenyLicenses denies models whose license ID matches a pattern
+	DenyLicenses []string `yaml:"deny_licenses" mapstructure:"deny_licenses" json:"deny_licenses,omitempty"`
+	// DenyUnknown denies models without a known license
+	DenyUnknown bool `yaml:"deny_unknown" mapstructure:"deny_unknown" json:"deny_unknown,omitempty"`
+}
+
+// Validate checks the rule's name and patterns
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("license rule without a name")
+	}
+	for _, patterns := range [][]string{r.Tenants, r.Groups, r.DenyLicenses} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("license rule %s: invalid pattern %q", r.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule covers a caller
+func (r Rule) appliesTo(caller Caller) bool {
+	if len(r.Tenants) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+	if caller.Tenant != "" && matchAny(r.Tenants, caller.Tenant) {
+		return true
+	}
+	for _, group := range caller.Groups {
+		if matchAny(r.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// denies returns why the rule denies a license, or "" when it does not
+func (r Rule) denies(l License) string {
+	switch {
+	case r.DenyUnknown && !l.Known():
+		return "license unknown"
+	case r.DenyNonCommercial && l.NonCommercial:
+		return "license " + l.ID + " is non-commercial"
+	case l.Known() && matchAny(r.DenyLicenses, l.ID):
+		return "license " + l.ID + " is denied"
+	}
+	return ""
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Caller is who an inference request is made for
+type Caller struct {
+	Tenant string
+	Groups []string
+}
+
+// Violation is the error returned for a request a rule denies
+type Violation struct {
+	Rule    string  `json:"rule"`
+	Model   string  `json:"model"`
+	License License `json:"license"`
+	Reason  string  `json:"reason"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("model %s denied by license policy %s: %s", v.Model, v.Rule, v.Reason)
+}
+
+// Config configures license tracking and policies
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Models are user-provided licenses, overriding the manifests
+	Models []ModelLicense `yaml:"models"`
+	Rules  []Rule         `yaml:"rules"`
+}
+
+// Validate checks the models and rules
+func (c Config) Validate() error {
+	seen := make(map[string]bool)
+	for _, m := range c.Models {
+		if m.Model == "" {
+			return fmt.Errorf("model license without a model")
+		}
+		if seen[m.Model] {
+			return fmt.Errorf("duplicate license for model %s", m.Model)
+		}
+		seen[m.Model] = true
+	}
+	return validateRules(c.Rules)
+}
+
+func validateRules(rules []Rule) error {
+	names := make(map[string]bool)
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate license rule %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// Registry holds the user-provided model licenses and the policy rules
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]ModelLicense
+	rules  []Rule
+}
+
+// NewRegistry creates a registry from the configured licenses and rules
+func NewRegistry(config Config) (*Registry, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Registry{models: make(map[string]ModelLicense), rules: config.Rules}
+	for _, m := range config.Models {
+		r.Set(m)
+	}
+	return r, nil
+}
+
+// Set records a user-provided license for a model
+func (r *Registry) Set(m ModelLicense) {
+	m.License = m.License.normalize()
+	m.Source = SourceUser
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[m.Model] = m
+}
+
+// Delete removes the user-provided license of a model, falling back to
+// its manifest
+func (r *Registry) Delete(model string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.models[model]
+	delete(r.models, model)
+	return ok
+}
+
+// Lookup returns the license of a model: the user-provided one, by the
+// model's name with or without its tag, or else the one in its manifest
+// metadata. Models with neither get an unknown license.
+func (r *Registry) Lookup(model string, manifest map[string]string) ModelLicense {
+	r.mu.RLock()
+	m, ok := r.models[model]
+	if !ok {
+		if name, _, tagged := strings.Cut(model, ":"); tagged {
+			m, ok = r.models[name]
+		}
+	}
+	r.mu.RUnlock()
+	if ok {
+		m.Model = model
+		return m
+	}
+
+	if id := manifest[MetadataLicense]; id != "" {
+		l := Parse(id)
+		if name := manifest[MetadataLicenseName]; name != "" {
+			l.Name = name
+		}
+		l.URL = manifest[MetadataLicenseURL]
+		return ModelLicense{Model: model, License: l, Source: SourceManifest}
+	}
+	return ModelLicense{Model: model, License: License{ID: "unknown"}}
+}
+
+// List returns the user-provided licenses sorted by model
+func (r *Registry) List() []ModelLicense {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	models := make([]ModelLicense, 0, len(r.models))
+	for _, m := range r.models {
+		models = append(models, m)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Model < models[j].Model })
+	return models
+}
+
+// Rules returns the policy rules
+func (r *Registry) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Rule(nil), r.rules...)
+}
+
+// SetRules replaces the policy rules
+func (r *Registry) SetRules(rules []Rule) error {
+	if err := validateRules(rules); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append([]Rule(nil), rules...)
+	return nil
+}
+
+// Check returns a *Violation if a rule denies the caller the model, whose
+// manifest metadata is given
+func (r *Registry) Check(caller Caller, model string, manifest map[string]string) error {
+	license := r.Lookup(model, manifest).License
+	for _, rule := range r.Rules() {
+		if !rule.appliesTo(caller) {
+			continue
+		}
+		if reason := rule.denies(license); reason != "" {
+			return &Violation{Rule: rule.Name, Model: model, License: license, Reason: reason}
+		}
+	}
+	return nil
+}
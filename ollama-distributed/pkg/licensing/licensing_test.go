@@ -0,0 +1,78 @@
+package licensing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	l := Parse(" CC-BY-NC-4.0 ")
+	assert.Equal(t, "cc-by-nc-4.0", l.ID)
+	assert.True(t, l.NonCommercial)
+	assert.NotEmpty(t, l.Name)
+
+	assert.False(t, Parse("apache-2.0").NonCommercial)
+	assert.True(t, Parse("acme-research-only").NonCommercial)
+	assert.True(t, Parse("custom non-commercial").NonCommercial)
+	assert.False(t, Parse("ncsa").NonCommercial)
+}
+
+func TestLookup(t *testing.T) {
+	r, err := NewRegistry(Config{Models: []ModelLicense{
+		{Model: "llama3", License: License{ID: "llama3"}},
+	}})
+	require.NoError(t, err)
+
+	// User-provided licenses apply to every tag and override the manifest
+	m := r.Lookup("llama3:8b", map[string]string{MetadataLicense: "mit"})
+	assert.Equal(t, "llama3", m.License.ID)
+	assert.Equal(t, SourceUser, m.Source)
+	assert.Equal(t, "llama3:8b", m.Model)
+
+	m = r.Lookup("phi", map[string]string{MetadataLicense: "MIT", MetadataLicenseURL: "https://example.com"})
+	assert.Equal(t, "mit", m.License.ID)
+	assert.Equal(t, "https://example.com", m.License.URL)
+	assert.Equal(t, SourceManifest, m.Source)
+
+	m = r.Lookup("mystery", nil)
+	assert.False(t, m.License.Known())
+
+	assert.True(t, r.Delete("llama3"))
+	assert.Equal(t, "mit", r.Lookup("llama3", map[string]string{MetadataLicense: "mit"}).License.ID)
+}
+
+func TestCheck(t *testing.T) {
+	r, err := NewRegistry(Config{
+		Models: []ModelLicense{
+			{Model: "research-model", License: License{ID: "cc-by-nc-4.0"}},
+			{Model: "gemma", License: License{ID: "gemma"}},
+		},
+		Rules: []Rule{
+			{Name: "commercial-only", Groups: []string{"paying"}, DenyNonCommercial: true},
+			{Name: "no-gemma", Tenants: []string{"globex"}, DenyLicenses: []string{"gemma*"}},
+		},
+	})
+	require.NoError(t, err)
+
+	paying := Caller{Tenant: "acme", Groups: []string{"paying"}}
+	err = r.Check(paying, "research-model", nil)
+	var violation *Violation
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "commercial-only", violation.Rule)
+	assert.NoError(t, r.Check(paying, "gemma", nil))
+
+	assert.NoError(t, r.Check(Caller{Tenant: "initech"}, "research-model", nil))
+	assert.Error(t, r.Check(Caller{Tenant: "globex"}, "gemma:2b", nil))
+
+	// Unknown licenses pass unless a rule denies them
+	assert.NoError(t, r.Check(Caller{}, "mystery", nil))
+	require.NoError(t, r.SetRules([]Rule{{Name: "known-only", DenyUnknown: true}}))
+	assert.Error(t, r.Check(Caller{}, "mystery", nil))
+	assert.NoError(t, r.Check(Caller{}, "mystery", map[string]string{MetadataLicense: "mit"}))
+
+	assert.Error(t, r.SetRules([]Rule{{Name: "bad", Groups: []string{"["}}}))
+	assert.Error(t, r.SetRules([]Rule{{Name: "dup"}, {Name: "dup"}}))
+}
@@ -0,0 +1,315 @@
+// Package loadqueue orders the model loads of a node. When several models
+// must load at once, for instance after a failover moved them to this
+// node, loading them all concurrently thrashes disk and VRAM. The queue
+// runs a few loads at a time, highest request priority first and then the
+// models expected to be in most demand, and lets an urgent load preempt a
+// running one of lower priority. While the node's disk is slow, loads are
+// staggered to one at a time.
+package loadqueue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of the load queue
+const (
+	DefaultConcurrency    = 1
+	DefaultMaxPreemptions = 3
+	DefaultLoadTimeout    = 10 * time.Minute
+)
+
+// Entry states
+const (
+	StateQueued  = "queued"
+	StateLoading = "loading"
+)
+
+// Config configures the load queue
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Concurrency is how many models load at once
+	Concurrency int `yaml:"concurrency"`
+	// Preempt lets a load cancel a running load of lower priority when
+	// all slots are taken; the preempted load is queued again
+	Preempt bool `yaml:"preempt"`
+	// MaxPreemptions is how many times a load can be preempted before it
+	// is left to finish
+	MaxPreemptions int           `yaml:"max_preemptions" mapstructure:"max_preemptions"`
+	LoadTimeout    time.Duration `yaml:"load_timeout" mapstructure:"load_timeout"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.Concurrency < 0 || c.MaxPreemptions < 0 || c.LoadTimeout < 0 {
+		return fmt.Errorf("load queue settings must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.Concurrency == 0 {
+		c.Concurrency = DefaultConcurrency
+	}
+	if c.MaxPreemptions == 0 {
+		c.MaxPreemptions = DefaultMaxPreemptions
+	}
+	if c.LoadTimeout == 0 {
+		c.LoadTimeout = DefaultLoadTimeout
+	}
+	return c
+}
+
+// LoadFunc loads a model, giving up when the context is cancelled
+type LoadFunc func(ctx context.Context, model string) error
+
+// Entry describes a queued or running load
+type Entry struct {
+	Model    string `json:"model"`
+	State    string `json:"state"`
+	Priority int    `json:"priority"`
+	// Position is 0 for running loads and counts from 1 for queued ones
+	Position int `json:"position"`
+	// Waiters are the requests waiting for the model
+	Waiters     int       `json:"waiters"`
+	Demand      float64   `json:"demand"`
+	Preemptions int       `json:"preemptions,omitempty"`
+	EnqueuedAt  time.Time `json:"enqueued_at"`
+	StartedAt   time.Time `json:"started_at,omitempty"`
+}
+
+// load is a queued or running load, guarded by the queue mutex
+type load struct {
+	Entry
+	fn        LoadFunc
+	cancel    context.CancelFunc
+	preempted bool
+	done      chan struct{}
+	err       error
+}
+
+// Queue orders the model loads of a node
+type Queue struct {
+	cfg Config
+
+	mu       sync.Mutex
+	loads    map[string]*load // by model
+	running  int
+	demand   func(model string) float64
+	slowDisk func() bool
+	now      func() time.Time
+}
+
+// NewQueue creates a load queue
+func NewQueue(cfg Config) *Queue {
+	return &Queue{
+		cfg:   cfg.withDefaults(),
+		loads: make(map[string]*load),
+		now:   time.Now,
+	}
+}
+
+// SetDemand sets how the demand expected for a model is estimated, e.g.
+// from its request rate. Loads of equal priority run in order of demand
+// plus waiting requests.
+func (q *Queue) SetDemand(demand func(model string) float64) {
+	q.mu.Lock()
+	q.demand = demand
+	q.mu.Unlock()
+}
+
+// SetSlowDisk sets how to tell whether the node's disk is slow. While it
+// is, loads run one at a time whatever the configured concurrency, as
+// concurrent loads would contend for the disk.
+func (q *Queue) SetSlowDisk(slow func() bool) {
+	q.mu.Lock()
+	q.slowDisk = slow
+	q.mu.Unlock()
+}
+
+// concurrency returns how many loads may run at once
+func (q *Queue) concurrency() int {
+	if q.slowDisk != nil && q.slowDisk() {
+		return 1
+	}
+	return q.cfg.Concurrency
+}
+
+// Load loads a model through the queue, returning once it is loaded or
+// the context is done. Requests for a model already queued or loading wait
+// for that load, raising its priority to theirs.
+func (q *Queue) Load(ctx context.Context, model string, priority int, fn LoadFunc) error {
+	q.mu.Lock()
+	l, ok := q.loads[model]
+	if ok {
+		l.Waiters++
+		if priority > l.Priority {
+			l.Priority = priority
+		}
+	} else {
+		l = &load{
+			Entry: Entry{Model: model, State: StateQueued, Priority: priority, Waiters: 1, EnqueuedAt: q.now()},
+			fn:    fn,
+			done:  make(chan struct{}),
+		}
+		q.loads[model] = l
+	}
+	if l.State == StateQueued {
+		q.preemptFor(l)
+	}
+	q.dispatch()
+	q.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.err
+	case <-ctx.Done():
+		q.mu.Lock()
+		l.Waiters--
+		// A load nobody waits for any more is dropped unless running
+		if l.Waiters == 0 && l.State == StateQueued && q.loads[model] == l {
+			delete(q.loads, model)
+		}
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Entries returns the running loads and then the queued ones in the order
+// they will run
+func (q *Queue) Entries() []Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ordered := q.ordered()
+	entries := make([]Entry, len(ordered))
+	position := 0
+	for i, l := range ordered {
+		entries[i] = l.Entry
+		entries[i].Demand = q.demandOf(l)
+		if l.State == StateQueued {
+			position++
+			entries[i].Position = position
+		}
+	}
+	return entries
+}
+
+// Position returns the entry of a model's load, if it is queued or running
+func (q *Queue) Position(model string) (Entry, bool) {
+	for _, entry := range q.Entries() {
+		if entry.Model == model {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+func (q *Queue) demandOf(l *load) float64 {
+	demand := float64(l.Waiters)
+	if q.demand != nil {
+		demand += q.demand(l.Model)
+	}
+	return demand
+}
+
+// ordered returns the loads, running ones first, then queued ones by
+// priority, demand and age
+func (q *Queue) ordered() []*load {
+	loads := make([]*load, 0, len(q.loads))
+	demand := make(map[*load]float64, len(q.loads))
+	for _, l := range q.loads {
+		loads = append(loads, l)
+		demand[l] = q.demandOf(l)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		a, b := loads[i], loads[j]
+		if a.State != b.State {
+			return a.State == StateLoading
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if demand[a] != demand[b] {
+			return demand[a] > demand[b]
+		}
+		if !a.EnqueuedAt.Equal(b.EnqueuedAt) {
+			return a.EnqueuedAt.Before(b.EnqueuedAt)
+		}
+		return a.Model < b.Model
+	})
+	return loads
+}
+
+// dispatch starts queued loads while slots are free
+func (q *Queue) dispatch() {
+	concurrency := q.concurrency()
+	for _, l := range q.ordered() {
+		if q.running >= concurrency {
+			return
+		}
+		if l.State != StateQueued {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), q.cfg.LoadTimeout)
+		l.State = StateLoading
+		l.StartedAt = q.now()
+		l.cancel = cancel
+		q.running++
+		go q.run(ctx, l)
+	}
+}
+
+// preemptFor cancels the running load of lowest priority below a queued
+// load's when all slots are taken
+func (q *Queue) preemptFor(queued *load) {
+	if !q.cfg.Preempt || q.running < q.concurrency() {
+		return
+	}
+	var victim *load
+	for _, l := range q.loads {
+		if l.State != StateLoading || l.preempted || l.Priority >= queued.Priority || l.Preemptions >= q.cfg.MaxPreemptions {
+			continue
+		}
+		if victim == nil || l.Priority < victim.Priority {
+			victim = l
+		}
+	}
+	if victim == nil {
+		return
+	}
+	victim.preempted = true
+	victim.Preemptions++
+	victim.cancel()
+	slog.Info("Preempted model load", "model", victim.Model, "priority", victim.Priority,
+		"for", queued.Model, "for_priority", queued.Priority)
+}
+
+func (q *Queue) run(ctx context.Context, l *load) {
+	err := l.fn(ctx, l.Model)
+	l.cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running--
+	// A load finishing as it is preempted is kept
+	if l.preempted && err != nil {
+		l.preempted = false
+		l.State = StateQueued
+		l.StartedAt = time.Time{}
+	} else {
+		l.preempted = false
+		l.err = err
+		close(l.done)
+		delete(q.loads, l.Model)
+		if err != nil {
+			slog.Warn("Failed to load model", "model", l.Model, "error", err)
+		}
+	}
+	q.dispatch()
+}
@@ -0,0 +1,241 @@
+package loadqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLoader loads models when told to, recording the order loads started in
+type fakeLoader struct {
+	mu      sync.Mutex
+	started []string
+	release map[string]chan error
+	running chan string
+}
+
+func newFakeLoader() *fakeLoader {
+	return &fakeLoader{release: make(map[string]chan error), running: make(chan string, 16)}
+}
+
+func (f *fakeLoader) channel(model string) chan error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.release[model] == nil {
+		f.release[model] = make(chan error, 1)
+	}
+	return f.release[model]
+}
+
+func (f *fakeLoader) load(ctx context.Context, model string) error {
+	f.mu.Lock()
+	f.started = append(f.started, model)
+	f.mu.Unlock()
+	f.running <- model
+	select {
+	case err := <-f.channel(model):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *fakeLoader) finish(model string, err error) {
+	f.channel(model) <- err
+}
+
+func (f *fakeLoader) waitRunning(t *testing.T, model string) {
+	t.Helper()
+	select {
+	case got := <-f.running:
+		require.Equal(t, model, got)
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not start loading", model)
+	}
+}
+
+// waitQueued waits until n loads are in the queue
+func waitQueued(t *testing.T, q *Queue, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(q.Entries()) == n }, time.Second, time.Millisecond)
+}
+
+func enqueue(q *Queue, model string, priority int, fn LoadFunc) chan error {
+	result := make(chan error, 1)
+	go func() { result <- q.Load(context.Background(), model, priority, fn) }()
+	return result
+}
+
+func TestLoadsRunByPriorityAndDemand(t *testing.T) {
+	q := NewQueue(Config{Enabled: true})
+	q.SetDemand(func(model string) float64 {
+		if model == "popular" {
+			return 10
+		}
+		return 0
+	})
+	f := newFakeLoader()
+
+	first := enqueue(q, "first", 0, f.load)
+	f.waitRunning(t, "first")
+	low := enqueue(q, "low", 0, f.load)
+	waitQueued(t, q, 2)
+	popular := enqueue(q, "popular", 0, f.load)
+	waitQueued(t, q, 3)
+	urgent := enqueue(q, "urgent", 5, f.load)
+	waitQueued(t, q, 4)
+
+	entries := q.Entries()
+	require.Len(t, entries, 4)
+	assert.Equal(t, "first", entries[0].Model)
+	assert.Equal(t, StateLoading, entries[0].State)
+	assert.Zero(t, entries[0].Position)
+	for i, model := range []string{"urgent", "popular", "low"} {
+		assert.Equal(t, model, entries[i+1].Model)
+		assert.Equal(t, i+1, entries[i+1].Position)
+	}
+
+	for _, step := range []struct {
+		model string
+		done  chan error
+	}{{"first", first}, {"urgent", urgent}, {"popular", popular}, {"low", low}} {
+		f.finish(step.model, nil)
+		require.NoError(t, <-step.done)
+		if next := len(q.Entries()); next > 0 {
+			f.waitRunning(t, q.Entries()[0].Model)
+		}
+	}
+	assert.Equal(t, []string{"first", "urgent", "popular", "low"}, f.started)
+	assert.Empty(t, q.Entries())
+}
+
+func TestWaitersShareLoad(t *testing.T) {
+	q := NewQueue(Config{Enabled: true})
+	f := newFakeLoader()
+
+	busy := enqueue(q, "busy", 0, f.load)
+	f.waitRunning(t, "busy")
+	a := enqueue(q, "model", 1, f.load)
+	waitQueued(t, q, 2)
+	b := enqueue(q, "model", 3, f.load)
+	require.Eventually(t, func() bool {
+		entry, ok := q.Position("model")
+		return ok && entry.Waiters == 2
+	}, time.Second, time.Millisecond)
+	entry, _ := q.Position("model")
+	assert.Equal(t, 3, entry.Priority, "waiters raise the priority")
+	assert.Equal(t, 1, entry.Position)
+
+	f.finish("busy", nil)
+	require.NoError(t, <-busy)
+	f.waitRunning(t, "model")
+	f.finish("model", errors.New("out of memory"))
+	assert.EqualError(t, <-a, "out of memory")
+	assert.EqualError(t, <-b, "out of memory")
+	assert.Equal(t, []string{"busy", "model"}, f.started)
+}
+
+func TestPreemption(t *testing.T) {
+	q := NewQueue(Config{Enabled: true, Preempt: true, MaxPreemptions: 1})
+	f := newFakeLoader()
+
+	low := enqueue(q, "low", 0, f.load)
+	f.waitRunning(t, "low")
+	high := enqueue(q, "high", 5, f.load)
+	f.waitRunning(t, "high")
+
+	entries := q.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "high", entries[0].Model)
+	assert.Equal(t, StateQueued, entries[1].State)
+	assert.Equal(t, 1, entries[1].Preemptions)
+
+	f.finish("high", nil)
+	require.NoError(t, <-high)
+	f.waitRunning(t, "low")
+
+	// Loads preempted MaxPreemptions times are left to finish
+	higher := enqueue(q, "higher", 9, f.load)
+	waitQueued(t, q, 2)
+	f.finish("low", nil)
+	require.NoError(t, <-low)
+	f.waitRunning(t, "higher")
+	f.finish("higher", nil)
+	require.NoError(t, <-higher)
+	assert.Equal(t, []string{"low", "high", "low", "higher"}, f.started)
+}
+
+func TestSlowDiskStaggersLoads(t *testing.T) {
+	q := NewQueue(Config{Enabled: true, Concurrency: 2})
+	var mu sync.Mutex
+	slow := true
+	q.SetSlowDisk(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return slow
+	})
+	f := newFakeLoader()
+
+	a := enqueue(q, "a", 0, f.load)
+	f.waitRunning(t, "a")
+	b := enqueue(q, "b", 0, f.load)
+	c := enqueue(q, "c", 0, f.load)
+	waitQueued(t, q, 3)
+	assert.Equal(t, StateQueued, q.Entries()[1].State, "a slow disk loads one model at a time")
+
+	mu.Lock()
+	slow = false
+	mu.Unlock()
+	f.finish("a", nil)
+	require.NoError(t, <-a)
+	started := []string{<-f.running, <-f.running}
+	assert.ElementsMatch(t, []string{"b", "c"}, started, "both load at once again")
+	f.finish("b", nil)
+	f.finish("c", nil)
+	require.NoError(t, <-b)
+	require.NoError(t, <-c)
+}
+
+func TestAbandonedLoadIsDropped(t *testing.T) {
+	q := NewQueue(Config{Enabled: true})
+	f := newFakeLoader()
+
+	busy := enqueue(q, "busy", 0, f.load)
+	f.waitRunning(t, "busy")
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan error, 1)
+	go func() { abandoned <- q.Load(ctx, "abandoned", 0, f.load) }()
+	waitQueued(t, q, 2)
+	cancel()
+	assert.ErrorIs(t, <-abandoned, context.Canceled)
+	waitQueued(t, q, 1)
+
+	f.finish("busy", nil)
+	require.NoError(t, <-busy)
+	assert.Equal(t, []string{"busy"}, f.started)
+}
+
+func TestOllamaLoader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req["model"] != "llama3" {
+			http.Error(w, `{"error": "model not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"model": "llama3", "done": true}`))
+	}))
+	defer server.Close()
+
+	loader := OllamaLoader(nil, server.URL)
+	assert.NoError(t, loader(context.Background(), "llama3"))
+	assert.ErrorContains(t, loader(context.Background(), "missing"), "model not found")
+}
@@ -0,0 +1,42 @@
+package loadqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OllamaLoader returns a LoadFunc loading models into the Ollama server at
+// endpoint. A generate request without a prompt loads a model and keeps it
+// loaded.
+func OllamaLoader(client *http.Client, endpoint string) LoadFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, model string) error {
+		body, err := json.Marshal(map[string]interface{}{"model": model})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/api/generate", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return fmt.Errorf("loading %s on %s failed with %s: %s", model, endpoint, resp.Status, bytes.TrimSpace(msg))
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+}
@@ -0,0 +1,229 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedValue replaces sensitive values in access logs
+const redactedValue = "[REDACTED]"
+
+// maxLoggedPromptBytes caps how much of a request body is inspected and how
+// much prompt text is written when prompt logging is enabled
+const maxLoggedPromptBytes = 4096
+
+// maxPeekedBodyBytes caps how much of a request body is read to find its
+// prompt; the prompt of a larger body is not logged. The logging middleware
+// runs before the body limit is enforced, so this bounds what it buffers.
+const maxPeekedBodyBytes = 1 << 20
+
+// promptFields are request fields that carry user prompt contents
+var promptFields = []string{"prompt", "messages", "input", "system", "context"}
+
+// credentialFields are query parameters that are always redacted
+var credentialFields = []string{"token", "access_token", "api_key", "apikey", "password", "secret"}
+
+// AccessLogOptions configures the HTTP access log middleware
+type AccessLogOptions struct {
+	// SampleRate is the fraction (0-1] of successful requests logged on
+	// SampledRoutes. Errors and slow requests are always logged.
+	SampleRate float64 `yaml:"sample_rate" json:"sample_rate"`
+	// SampledRoutes are route prefixes (e.g. "/api/v1/health") that are
+	// high-volume enough to be sampled
+	SampledRoutes []string `yaml:"sampled_routes" json:"sampled_routes"`
+	// SlowThreshold forces logging of requests slower than this
+	SlowThreshold time.Duration `yaml:"slow_threshold" json:"slow_threshold"`
+	// LogPrompts includes prompt contents in the log. Prompts are redacted
+	// unless this is explicitly enabled.
+	LogPrompts bool `yaml:"log_prompts" json:"log_prompts"`
+	// Redact, when set, redacts logged prompts by the policy of their
+	// tenant
+	Redact func(tenant, text string) string `yaml:"-" json:"-"`
+}
+
+// AccessLog returns a gin middleware that writes one structured log record
+// per request with method, route, status, latency, tenant and request ID.
+// A nil logger uses slog.Default().
+func AccessLog(logger *slog.Logger, opts AccessLogOptions) gin.HandlerFunc {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		var prompt string
+		if opts.LogPrompts {
+			prompt = peekPrompt(c)
+		}
+
+		c.Next()
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		if !shouldLogAccess(c.Request.URL.Path, status, latency, opts) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", latency),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if query := RedactQuery(c.Request.URL.RawQuery, opts.LogPrompts); query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if tenant := accessTenant(c); tenant != "" {
+			attrs = append(attrs, slog.String("tenant", tenant))
+		}
+		if requestID := accessRequestID(c); requestID != "" {
+			attrs = append(attrs, slog.String("request_id", requestID))
+		}
+		if userID := c.GetString("user_id"); userID != "" {
+			attrs = append(attrs, slog.String("user_id", userID))
+		}
+		if prompt != "" {
+			if opts.Redact != nil {
+				prompt = opts.Redact(accessTenant(c), prompt)
+			}
+			attrs = append(attrs, slog.String("prompt", prompt))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+// shouldLogAccess applies sampling to successful, fast requests on
+// high-volume routes
+func shouldLogAccess(path string, status int, latency time.Duration, opts AccessLogOptions) bool {
+	if status >= 400 {
+		return true
+	}
+	if opts.SlowThreshold > 0 && latency >= opts.SlowThreshold {
+		return true
+	}
+	if opts.SampleRate <= 0 || opts.SampleRate >= 1 {
+		return true
+	}
+	for _, prefix := range opts.SampledRoutes {
+		if strings.HasPrefix(path, prefix) {
+			return rand.Float64() < opts.SampleRate
+		}
+	}
+	return true
+}
+
+// RedactQuery redacts credentials and, unless logPrompts is set, prompt
+// contents from a raw query string
+func RedactQuery(rawQuery string, logPrompts bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redactedValue
+	}
+
+	for key := range values {
+		lower := strings.ToLower(key)
+		if containsField(credentialFields, lower) || (!logPrompts && containsField(promptFields, lower)) {
+			values[key] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+// peekPrompt reads the prompt fields from a JSON request body without
+// consuming it, truncated to maxLoggedPromptBytes
+func peekPrompt(c *gin.Context) string {
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+		return ""
+	}
+
+	original := c.Request.Body
+	body, err := io.ReadAll(io.LimitReader(original, maxPeekedBodyBytes+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), original), original}
+	if err != nil || len(body) == 0 || len(body) > maxPeekedBodyBytes {
+		return ""
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	var parts []string
+	for _, field := range promptFields {
+		if raw, ok := payload[field]; ok {
+			parts = append(parts, field+"="+string(raw))
+		}
+	}
+
+	prompt := strings.Join(parts, " ")
+	if len(prompt) > maxLoggedPromptBytes {
+		prompt = prompt[:maxLoggedPromptBytes] + "..."
+	}
+	return prompt
+}
+
+func accessTenant(c *gin.Context) string {
+	for _, key := range []string{"tenant", "tenant_id"} {
+		if tenant := c.GetString(key); tenant != "" {
+			return tenant
+		}
+	}
+	return c.GetHeader("X-Tenant-ID")
+}
+
+func accessRequestID(c *gin.Context) string {
+	for _, key := range []string{"request_id", "requestID"} {
+		if id := c.GetString(key); id != "" {
+			return id
+		}
+	}
+	if id := c.Writer.Header().Get("X-Request-ID"); id != "" {
+		return id
+	}
+	return c.GetHeader("X-Request-ID")
+}
+
+func containsField(fields []string, name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
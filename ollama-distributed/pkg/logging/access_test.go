@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAccessRouter(buf *bytes.Buffer, opts AccessLogOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	router := gin.New()
+	router.Use(AccessLog(logger, opts))
+	router.POST("/api/generate/:model", func(c *gin.Context) {
+		c.Set("tenant_id", "acme")
+		c.Header("X-Request-ID", "req-1")
+		body := map[string]interface{}{}
+		_ = c.ShouldBindJSON(&body)
+		c.JSON(http.StatusOK, gin.H{"prompt_seen": body["prompt"] != nil})
+	})
+	router.GET("/api/v1/health", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestAccessLogFields(t *testing.T) {
+	var buf bytes.Buffer
+	router := newAccessRouter(&buf, AccessLogOptions{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/generate/llama?prompt=secret&token=abc&stream=true",
+		strings.NewReader(`{"prompt":"tell me a secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/api/generate/:model", entry["route"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, "acme", entry["tenant"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "abc")
+	assert.Nil(t, entry["prompt"])
+
+	// The handler still sees the body
+	assert.Contains(t, rec.Body.String(), `"prompt_seen":true`)
+}
+
+func TestAccessLogPromptsWhenEnabled(t *testing.T) {
+	var buf bytes.Buffer
+	router := newAccessRouter(&buf, AccessLogOptions{LogPrompts: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/generate/llama?token=abc",
+		strings.NewReader(`{"prompt":"hello world"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Contains(t, buf.String(), "hello world")
+	assert.NotContains(t, buf.String(), "abc")
+	assert.Contains(t, rec.Body.String(), `"prompt_seen":true`)
+}
+
+func TestAccessLogPromptsOfLargeBodies(t *testing.T) {
+	var buf bytes.Buffer
+	router := newAccessRouter(&buf, AccessLogOptions{LogPrompts: true})
+
+	padding := strings.Repeat("x", maxPeekedBodyBytes)
+	req := httptest.NewRequest(http.MethodPost, "/api/generate/llama",
+		strings.NewReader(`{"prompt":"hello world","context":"`+padding+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.NotContains(t, buf.String(), "hello world", "bodies over the peek limit are not buffered for logging")
+	assert.Contains(t, rec.Body.String(), `"prompt_seen":true`, "the handler still sees the whole body")
+}
+
+func TestAccessLogRedactsPrompts(t *testing.T) {
+	var buf bytes.Buffer
+	var tenant string
+	router := newAccessRouter(&buf, AccessLogOptions{LogPrompts: true, Redact: func(t, text string) string {
+		tenant = t
+		return strings.ReplaceAll(text, "ann@example.com", "[EMAIL]")
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/generate/llama", strings.NewReader(`{"prompt":"mail ann@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), "mail [EMAIL]")
+	assert.NotContains(t, buf.String(), "ann@example.com")
+	assert.Equal(t, "acme", tenant)
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	router := newAccessRouter(&buf, AccessLogOptions{
+		SampleRate:    0.000001,
+		SampledRoutes: []string{"/api/v1/health"},
+	})
+
+	for i := 0; i < 50; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/health", nil))
+	}
+	assert.Empty(t, buf.String())
+
+	// Errors are never sampled away
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Contains(t, buf.String(), `"status":404`)
+	assert.Contains(t, buf.String(), `"route":"unmatched"`)
+}
+
+func TestRedactQuery(t *testing.T) {
+	assert.Equal(t, "", RedactQuery("", false))
+	assert.Equal(t, "api_key=%5BREDACTED%5D&model=llama", RedactQuery("model=llama&api_key=k", true))
+	assert.Equal(t, "prompt=%5BREDACTED%5D", RedactQuery("prompt=hi", false))
+	assert.Equal(t, "prompt=hi", RedactQuery("prompt=hi", true))
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestIDFromContext(c.Request.Context())+" "+c.GetString("request_id"))
+	})
+
+	// A client's ID is kept
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "client-42", rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, "client-42 client-42", rec.Body.String())
+
+	// Missing or unsafe IDs are replaced
+	for _, id := range []string{"", "bad id\r\nX-Injected: 1", string(make([]byte, 200))} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header[RequestIDHeader] = []string{id}
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		generated := rec.Header().Get(RequestIDHeader)
+		assert.True(t, ValidRequestID(generated))
+		assert.NotEqual(t, id, generated)
+		assert.Equal(t, generated+" "+generated, rec.Body.String())
+	}
+}
+
+func TestPropagateRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://backend/api/tags", nil)
+	require.NoError(t, err)
+	PropagateRequestID(req)
+	assert.Equal(t, "req-1", req.Header.Get(RequestIDHeader))
+
+	req.Header.Set(RequestIDHeader, "upstream")
+	PropagateRequestID(req)
+	assert.Equal(t, "upstream", req.Header.Get(RequestIDHeader))
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("component", "api")
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "scheduled")
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "api", entry["component"])
+
+	buf.Reset()
+	logger.Info("idle")
+	assert.NotContains(t, buf.String(), "request_id")
+}
@@ -0,0 +1,253 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a log line captured by a RingBuffer
+type Record struct {
+	Seq       uint64                 `json:"seq"`
+	Time      time.Time              `json:"time"`
+	Level     string                 `json:"level"` // debug, info, warn or error
+	Component string                 `json:"component,omitempty"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// RecordFilter selects records from a RingBuffer
+type RecordFilter struct {
+	MinLevel  string // empty matches all levels
+	Component string // empty matches all components
+	RequestID string // empty matches records of all requests
+	AfterSeq  uint64 // only records with a higher sequence number
+	Limit     int    // most recent records to return, 0 for all
+}
+
+// levelRanks orders the normalized levels
+var levelRanks = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+// Matches reports whether r is selected by f
+func (f RecordFilter) Matches(r *Record) bool {
+	if r.Seq <= f.AfterSeq {
+		return false
+	}
+	if f.MinLevel != "" && levelRanks[r.Level] < levelRanks[normalizeLevel(f.MinLevel)] {
+		return false
+	}
+	if f.Component != "" && f.Component != r.Component {
+		return false
+	}
+	return f.RequestID == "" || r.hasRequestID(f.RequestID)
+}
+
+// hasRequestID reports whether the record was logged for the request, from
+// the request_id field of JSON lines or the request_id=<id> attribute slog
+// writes in text lines
+func (r *Record) hasRequestID(id string) bool {
+	if v, ok := r.Fields["request_id"].(string); ok {
+		return v == id
+	}
+	attr := "request_id=" + id
+	for _, word := range strings.Fields(r.Message) {
+		if word == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// RingBuffer keeps the most recent log records of this node in memory. It is
+// an io.Writer, so it can be teed into the standard, zerolog and slog
+// outputs; every Write is expected to hold whole lines, as those loggers
+// produce.
+type RingBuffer struct {
+	mu      sync.RWMutex
+	records []Record
+	next    int // index the next record is written to
+	full    bool
+	seq     uint64
+
+	subscribers map[chan Record]RecordFilter
+}
+
+// NewRingBuffer creates a ring buffer holding up to capacity records
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity <= 0 {
+		capacity = 5000
+	}
+	return &RingBuffer{
+		records:     make([]Record, capacity),
+		subscribers: make(map[chan Record]RecordFilter),
+	}
+}
+
+// Write parses each line of p into a record
+func (rb *RingBuffer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rb.Append(parseLogLine(line))
+	}
+	return len(p), nil
+}
+
+// Append stores a record, assigning its sequence number, and passes it to
+// matching subscribers. Subscribers that fall behind miss records rather
+// than blocking the logger.
+func (rb *RingBuffer) Append(r Record) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.seq++
+	r.Seq = rb.seq
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Level = normalizeLevel(r.Level)
+
+	rb.records[rb.next] = r
+	rb.next = (rb.next + 1) % len(rb.records)
+	if rb.next == 0 {
+		rb.full = true
+	}
+
+	for ch, filter := range rb.subscribers {
+		if !filter.Matches(&r) {
+			continue
+		}
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
+
+// Query returns the buffered records selected by filter, oldest first
+func (rb *RingBuffer) Query(filter RecordFilter) []Record {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+
+	var result []Record
+	for _, r := range rb.ordered() {
+		if filter.Matches(&r) {
+			result = append(result, r)
+		}
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// ordered returns the stored records oldest first
+func (rb *RingBuffer) ordered() []Record {
+	if !rb.full {
+		return rb.records[:rb.next]
+	}
+	return append(append([]Record(nil), rb.records[rb.next:]...), rb.records[:rb.next]...)
+}
+
+// Subscribe streams new records selected by filter until cancel is called
+func (rb *RingBuffer) Subscribe(filter RecordFilter) (<-chan Record, func()) {
+	ch := make(chan Record, 256)
+
+	rb.mu.Lock()
+	rb.subscribers[ch] = filter
+	rb.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			rb.mu.Lock()
+			delete(rb.subscribers, ch)
+			rb.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// normalizeLevel maps the level names of the different loggers to debug,
+// info, warn or error
+func normalizeLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "trace", "debug":
+		return "debug"
+	case "warn", "warning":
+		return "warn"
+	case "error", "fatal", "panic", "critical":
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// stdLogPrefix matches the date and time the standard logger prepends
+var stdLogPrefix = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// slogTextLevel matches the level slog's default handler writes after the
+// standard logger's prefix
+var slogTextLevel = regexp.MustCompile(`^(DEBUG|INFO|WARN|ERROR) `)
+
+// parseLogLine turns a JSON line from zerolog or slog, or a plain text line
+// from the standard logger, into a record
+func parseLogLine(line []byte) Record {
+	line = bytes.TrimSpace(line)
+
+	if line[0] == '{' {
+		var fields map[string]interface{}
+		if json.Unmarshal(line, &fields) == nil {
+			return recordFromFields(fields)
+		}
+	}
+
+	text := stdLogPrefix.ReplaceAllString(string(line), "")
+	r := Record{Level: "info", Message: text}
+	if m := slogTextLevel.FindStringSubmatch(text); m != nil {
+		r.Level = m[1]
+		r.Message = strings.TrimPrefix(text, m[0])
+		return r
+	}
+
+	// The standard logger has no levels; recognise the markers used in
+	// this code base
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(text, "⚠️") || strings.HasPrefix(lower, "warning"):
+		r.Level = "warn"
+	case strings.Contains(text, "❌") || strings.HasPrefix(lower, "error") || strings.Contains(lower, "failed"):
+		r.Level = "error"
+	}
+	return r
+}
+
+// recordFromFields extracts the well-known keys of a JSON log line, keeping
+// the rest as fields
+func recordFromFields(fields map[string]interface{}) Record {
+	var r Record
+	take := func(keys ...string) string {
+		for _, key := range keys {
+			if v, ok := fields[key].(string); ok {
+				delete(fields, key)
+				return v
+			}
+		}
+		return ""
+	}
+
+	r.Level = take("level")
+	r.Message = take("message", "msg")
+	r.Component = take("component")
+	if ts := take("time", "timestamp"); ts != "" {
+		r.Time, _ = time.Parse(time.RFC3339Nano, ts)
+	}
+	if len(fields) > 0 {
+		r.Fields = fields
+	}
+	return r
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBufferParsesLoggerOutput(t *testing.T) {
+	ring := NewRingBuffer(10)
+
+	std := log.New(ring, "", log.LstdFlags)
+	std.Printf("⚠️  Failed to start Prometheus exporter: %v", io.EOF)
+	std.Printf("✅ Web server initialized on :8081")
+	_, _ = ring.Write([]byte(`{"level":"error","component":"scheduler","time":"2026-01-01T12:00:00Z","message":"no healthy nodes","model":"llama3"}` + "\n"))
+	_, _ = ring.Write([]byte("2026/01/01 12:00:00 DEBUG probing peer id=abc\n"))
+
+	records := ring.Query(RecordFilter{})
+	require.Len(t, records, 4)
+
+	assert.Equal(t, "warn", records[0].Level)
+	assert.Equal(t, "⚠️  Failed to start Prometheus exporter: EOF", records[0].Message)
+	assert.Equal(t, "info", records[1].Level)
+
+	assert.Equal(t, "error", records[2].Level)
+	assert.Equal(t, "scheduler", records[2].Component)
+	assert.Equal(t, "no healthy nodes", records[2].Message)
+	assert.Equal(t, map[string]interface{}{"model": "llama3"}, records[2].Fields)
+	assert.Equal(t, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), records[2].Time)
+
+	assert.Equal(t, "debug", records[3].Level)
+	assert.Equal(t, "probing peer id=abc", records[3].Message)
+}
+
+func TestRingBufferQuery(t *testing.T) {
+	ring := NewRingBuffer(3)
+	for _, level := range []string{"info", "error", "debug", "warn", "error"} {
+		ring.Append(Record{Level: level, Component: "api", Message: level})
+	}
+
+	// Only the last three are kept
+	records := ring.Query(RecordFilter{})
+	require.Len(t, records, 3)
+	assert.Equal(t, uint64(3), records[0].Seq)
+
+	warnings := ring.Query(RecordFilter{MinLevel: "warn"})
+	assert.Len(t, warnings, 2)
+
+	assert.Empty(t, ring.Query(RecordFilter{Component: "p2p"}))
+	assert.Len(t, ring.Query(RecordFilter{AfterSeq: 4}), 1)
+	assert.Equal(t, uint64(5), ring.Query(RecordFilter{Limit: 1})[0].Seq)
+}
+
+func TestRingBufferQueryRequestID(t *testing.T) {
+	ring := NewRingBuffer(10)
+	_, _ = ring.Write([]byte(`{"level":"info","message":"http request","request_id":"req-1"}` + "\n"))
+	_, _ = ring.Write([]byte("2026/01/01 12:00:00 WARN forward failed request_id=req-1 peer=b\n"))
+	_, _ = ring.Write([]byte("2026/01/01 12:00:00 INFO http request request_id=req-10\n"))
+
+	records := ring.Query(RecordFilter{RequestID: "req-1"})
+	require.Len(t, records, 2)
+	assert.Equal(t, "http request", records[0].Message)
+	assert.Equal(t, "warn", records[1].Level)
+	assert.Len(t, ring.Query(RecordFilter{RequestID: "req-10"}), 1)
+	assert.Empty(t, ring.Query(RecordFilter{RequestID: "req"}))
+}
+
+func TestRingBufferSubscribe(t *testing.T) {
+	ring := NewRingBuffer(10)
+	records, cancel := ring.Subscribe(RecordFilter{MinLevel: "error"})
+
+	ring.Append(Record{Level: "info", Message: "ignored"})
+	ring.Append(Record{Level: "error", Message: "tailed"})
+
+	select {
+	case r := <-records:
+		assert.Equal(t, "tailed", r.Message)
+	case <-time.After(time.Second):
+		t.Fatal("no record received")
+	}
+
+	cancel()
+	cancel()
+	_, open := <-records
+	assert.False(t, open)
+}
+
+func TestShipperLoki(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		push map[string][]map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		mu.Lock()
+		defer mu.Unlock()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&push))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ring := NewRingBuffer(10)
+	shipper, err := NewShipper(&ShipperConfig{
+		Type:   ShipperLoki,
+		URL:    server.URL,
+		Labels: map[string]string{"node": "node-1"},
+	}, ring)
+	require.NoError(t, err)
+	shipper.Start()
+
+	ring.Append(Record{Level: "info", Component: "api", Message: "one"})
+	ring.Append(Record{Level: "error", Component: "api", Message: "two"})
+	ring.Append(Record{Level: "info", Component: "api", Message: "three"})
+	shipper.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	streams := push["streams"]
+	require.Len(t, streams, 2)
+	assert.Equal(t, map[string]interface{}{"level": "info", "component": "api", "node": "node-1"}, streams[0]["stream"])
+	assert.Len(t, streams[0]["values"], 2)
+}
+
+func TestNewShipperValidation(t *testing.T) {
+	_, err := NewShipper(&ShipperConfig{Type: "syslog", URL: "http://localhost"}, NewRingBuffer(1))
+	assert.Error(t, err)
+
+	_, err = NewShipper(&ShipperConfig{Type: ShipperElasticsearch}, NewRingBuffer(1))
+	assert.Error(t, err)
+}
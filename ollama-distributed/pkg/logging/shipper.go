@@ -0,0 +1,236 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log shipper backends
+const (
+	ShipperLoki          = "loki"
+	ShipperElasticsearch = "elasticsearch"
+)
+
+// ShipperConfig configures forwarding of buffered logs to a central store
+type ShipperConfig struct {
+	Type          string            // loki or elasticsearch
+	URL           string            // Loki base URL or Elasticsearch base URL
+	Index         string            // Elasticsearch index
+	Labels        map[string]string // Loki stream labels, e.g. node and cluster
+	Username      string
+	Password      string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Shipper forwards the records of a RingBuffer to Loki or Elasticsearch in
+// batches. Records that cannot be delivered are dropped; the ring buffer
+// still holds them for the local log API.
+type Shipper struct {
+	config *ShipperConfig
+	ring   *RingBuffer
+	client *http.Client
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewShipper creates a shipper for the records of ring
+func NewShipper(config *ShipperConfig, ring *RingBuffer) (*Shipper, error) {
+	switch config.Type {
+	case ShipperLoki, ShipperElasticsearch:
+	default:
+		return nil, fmt.Errorf("unsupported log shipper type: %q", config.Type)
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("log shipper URL is required")
+	}
+	if config.Type == ShipperElasticsearch && config.Index == "" {
+		config.Index = "ollama-distributed-logs"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	return &Shipper{
+		config: config,
+		ring:   ring,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Start starts forwarding records written from now on
+func (s *Shipper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	records, unsubscribe := s.ring.Subscribe(RecordFilter{})
+	go func() {
+		defer close(s.done)
+		defer unsubscribe()
+		s.run(ctx, records)
+	}()
+}
+
+// Stop flushes pending records and stops forwarding
+func (s *Shipper) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *Shipper) run(ctx context.Context, records <-chan Record) {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, s.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// Shipping errors are not logged: they would be shipped too
+		_ = s.ship(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Ship what was logged before Stop
+			for {
+				select {
+				case r := <-records:
+					batch = append(batch, r)
+				default:
+					flush()
+					return
+				}
+			}
+		case r := <-records:
+			batch = append(batch, r)
+			if len(batch) >= s.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// ship sends one batch
+func (s *Shipper) ship(batch []Record) error {
+	var (
+		url         string
+		contentType string
+		body        []byte
+		err         error
+	)
+	switch s.config.Type {
+	case ShipperLoki:
+		url = strings.TrimSuffix(s.config.URL, "/") + "/loki/api/v1/push"
+		contentType = "application/json"
+		body, err = lokiPushBody(batch, s.config.Labels)
+	case ShipperElasticsearch:
+		url = strings.TrimSuffix(s.config.URL, "/") + "/_bulk"
+		contentType = "application/x-ndjson"
+		body, err = elasticsearchBulkBody(batch, s.config.Index)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("log shipper: %s returned %s", s.config.Type, resp.Status)
+	}
+	return nil
+}
+
+// lokiPushBody builds a Loki push request with one stream per level and
+// component, so both can be used as label selectors
+func lokiPushBody(batch []Record, labels map[string]string) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	streams := make(map[string]*stream)
+	var order []string
+	for _, r := range batch {
+		key := r.Level + "\x00" + r.Component
+		st, ok := streams[key]
+		if !ok {
+			st = &stream{Stream: map[string]string{"level": r.Level}}
+			for k, v := range labels {
+				st.Stream[k] = v
+			}
+			if r.Component != "" {
+				st.Stream["component"] = r.Component
+			}
+			streams[key] = st
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), string(line)})
+	}
+
+	push := struct {
+		Streams []*stream `json:"streams"`
+	}{}
+	for _, key := range order {
+		push.Streams = append(push.Streams, streams[key])
+	}
+	return json.Marshal(push)
+}
+
+// elasticsearchBulkBody builds a bulk request indexing every record
+func elasticsearchBulkBody(batch []Record, index string) ([]byte, error) {
+	var buf bytes.Buffer
+	action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": index}})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range batch {
+		doc, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
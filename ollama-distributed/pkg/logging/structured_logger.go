@@ -555,7 +555,9 @@ func (cl *ContextLogger) addContextFields(fields []slog.Attr) []slog.Attr {
 		}
 	}
 
-	if requestID := cl.ctx.Value("request_id"); requestID != nil {
+	if id := RequestIDFromContext(cl.ctx); id != "" {
+		fields = append(fields, slog.String("request_id", id))
+	} else if requestID := cl.ctx.Value("request_id"); requestID != nil {
 		if id, ok := requestID.(string); ok {
 			fields = append(fields, slog.String("request_id", id))
 		}
@@ -0,0 +1,187 @@
+// Package maintenance schedules weekly maintenance windows for nodes or
+// groups of nodes. Shortly before and during a window its nodes are
+// drained, tainted so no new replicas are placed on them, and their alerts
+// are silenced; afterwards they are returned to service.
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaintKey is the key of the NoSchedule taint put on nodes in a window. Its
+// value is the name of the window.
+const TaintKey = "maintenance"
+
+// DefaultCheckInterval is how often nodes are checked for windows opening
+// or closing
+const DefaultCheckInterval = time.Minute
+
+// Config configures maintenance windows
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// DrainBefore is how long before a window opens its nodes stop taking
+	// new requests, so that the running ones finish in time
+	DrainBefore   time.Duration `yaml:"drain_before" mapstructure:"drain_before"`
+	CheckInterval time.Duration `yaml:"check_interval" mapstructure:"check_interval"`
+	Windows       []Window      `yaml:"windows"`
+}
+
+// Validate checks the windows and durations
+func (c Config) Validate() error {
+	if c.DrainBefore < 0 || c.DrainBefore >= 24*time.Hour {
+		return fmt.Errorf("drain_before must be between 0 and 24h")
+	}
+	if c.CheckInterval < 0 {
+		return fmt.Errorf("negative check interval")
+	}
+	names := make(map[string]bool)
+	for _, w := range c.Windows {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		if names[w.Name] {
+			return fmt.Errorf("duplicate maintenance window %q", w.Name)
+		}
+		names[w.Name] = true
+	}
+	return nil
+}
+
+// Window is a weekly maintenance window of some nodes, e.g. Sunday
+// 02:00-04:00. A window whose end is not after its start closes the next
+// day.
+type Window struct {
+	Name string `yaml:"name" json:"name"`
+	// Nodes are the IDs of the nodes in the window
+	Nodes []string `yaml:"nodes" json:"nodes,omitempty"`
+	// Selector adds the nodes having all of its labels, e.g. a node group
+	Selector map[string]string `yaml:"selector" json:"selector,omitempty"`
+	// Days are the weekdays the window opens on, every day if empty
+	Days []string `yaml:"days" json:"days,omitempty"`
+	// Start and End are times of day as HH:MM in Timezone, UTC if empty
+	Start    string `yaml:"start" json:"start"`
+	End      string `yaml:"end" json:"end"`
+	Timezone string `yaml:"timezone" json:"timezone,omitempty"`
+}
+
+// Validate checks the window has a name, nodes and a parseable schedule
+func (w Window) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("maintenance window has no name")
+	}
+	if len(w.Nodes) == 0 && len(w.Selector) == 0 {
+		return fmt.Errorf("maintenance window %s selects no nodes", w.Name)
+	}
+	if _, err := w.schedule(); err != nil {
+		return fmt.Errorf("maintenance window %s: %w", w.Name, err)
+	}
+	return nil
+}
+
+// Applies reports whether the window covers the node with the given labels
+func (w Window) Applies(nodeID string, labels map[string]string) bool {
+	for _, id := range w.Nodes {
+		if id == nodeID {
+			return true
+		}
+	}
+	if len(w.Selector) == 0 {
+		return false
+	}
+	for key, value := range w.Selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Open reports whether now is in the window, or at most lead before it
+// opens
+func (w Window) Open(now time.Time, lead time.Duration) bool {
+	s, err := w.schedule()
+	if err != nil {
+		return false
+	}
+	local := now.In(s.location)
+	// Check the openings of yesterday, which may last past midnight, today
+	// and tomorrow, which may be within the lead
+	for offset := -1; offset <= 1; offset++ {
+		day := local.AddDate(0, 0, offset)
+		if len(s.days) > 0 && !s.days[day.Weekday()] {
+			continue
+		}
+		start, end := s.at(day, s.start), s.at(day, s.end)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !now.Before(start.Add(-lead)) && now.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule is a window's parsed schedule
+type schedule struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration
+	location   *time.Location
+}
+
+// at returns the time of day on the day, in wall clock time across
+// daylight saving changes
+func (s schedule) at(day time.Time, timeOfDay time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(),
+		int(timeOfDay/time.Hour), int(timeOfDay%time.Hour/time.Minute), 0, 0, s.location)
+}
+
+func (w Window) schedule() (schedule, error) {
+	s := schedule{location: time.UTC}
+	var err error
+	if s.start, err = parseTimeOfDay(w.Start); err != nil {
+		return s, fmt.Errorf("invalid start: %w", err)
+	}
+	if s.end, err = parseTimeOfDay(w.End); err != nil {
+		return s, fmt.Errorf("invalid end: %w", err)
+	}
+	if w.Timezone != "" {
+		if s.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return s, fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	for _, name := range w.Days {
+		day, ok := parseWeekday(name)
+		if !ok {
+			return s, fmt.Errorf("unknown day %q", name)
+		}
+		if s.days == nil {
+			s.days = make(map[time.Weekday]bool)
+		}
+		s.days[day] = true
+	}
+	return s, nil
+}
+
+// parseTimeOfDay parses HH:MM as the time since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekday parses a weekday name, full or abbreviated to three letters
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(name)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,173 @@
+package maintenance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 2026-03-01 is a Sunday
+func at(day int, clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", fmt.Sprintf("2026-03-%02d %s", day, clock))
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestWindowOpen(t *testing.T) {
+	sunday := Window{Name: "sunday", Nodes: []string{"n1"}, Days: []string{"Sun"}, Start: "02:00", End: "04:00"}
+	assert.False(t, sunday.Open(at(1, "01:59"), 0))
+	assert.True(t, sunday.Open(at(1, "01:45"), 15*time.Minute), "drained ahead of the window")
+	assert.True(t, sunday.Open(at(1, "02:00"), 0))
+	assert.False(t, sunday.Open(at(1, "04:00"), 0))
+	assert.False(t, sunday.Open(at(2, "03:00"), 0), "Monday has no window")
+
+	overnight := Window{Name: "overnight", Nodes: []string{"n1"}, Days: []string{"saturday"}, Start: "23:00", End: "01:00"}
+	assert.True(t, overnight.Open(at(1, "00:30"), 0), "Saturday's window lasts into Sunday")
+	assert.False(t, overnight.Open(at(1, "23:30"), 0))
+
+	berlin := Window{Name: "berlin", Nodes: []string{"n1"}, Start: "02:00", End: "03:00", Timezone: "Europe/Berlin"}
+	assert.True(t, berlin.Open(at(2, "01:30"), 0), "02:30 in Berlin")
+	assert.False(t, berlin.Open(at(2, "02:30"), 0))
+}
+
+func TestWindowValidate(t *testing.T) {
+	assert.NoError(t, Window{Name: "w", Selector: map[string]string{"group": "a"}, Start: "02:00", End: "04:00"}.Validate())
+	assert.Error(t, Window{Name: "w", Start: "02:00", End: "04:00"}.Validate(), "no nodes")
+	assert.Error(t, Window{Name: "w", Nodes: []string{"n1"}, Start: "2am", End: "04:00"}.Validate())
+	assert.Error(t, Window{Name: "w", Nodes: []string{"n1"}, Days: []string{"someday"}, Start: "02:00", End: "04:00"}.Validate())
+	assert.Error(t, Window{Name: "w", Nodes: []string{"n1"}, Start: "02:00", End: "04:00", Timezone: "Mars/Olympus"}.Validate())
+
+	w := Window{Name: "w", Nodes: []string{"n1"}, Start: "02:00", End: "04:00"}
+	assert.Error(t, Config{Windows: []Window{w, w}}.Validate())
+	assert.Error(t, Config{DrainBefore: 24 * time.Hour}.Validate())
+}
+
+type fakeCluster struct {
+	labels  map[string]placement.NodeLabels
+	drained map[string]bool
+}
+
+func newFakeCluster(nodes map[string]map[string]string) *fakeCluster {
+	c := &fakeCluster{labels: make(map[string]placement.NodeLabels), drained: make(map[string]bool)}
+	for id, labels := range nodes {
+		c.labels[id] = placement.NodeLabels{Labels: labels}
+	}
+	return c
+}
+
+func (c *fakeCluster) Nodes() []string {
+	var ids []string
+	for id := range c.labels {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (c *fakeCluster) Labels(id string) placement.NodeLabels { return c.labels[id] }
+
+func (c *fakeCluster) SetLabels(id string, labels placement.NodeLabels) error {
+	c.labels[id] = labels
+	return nil
+}
+
+func (c *fakeCluster) DrainNode(id string)      { c.drained[id] = true }
+func (c *fakeCluster) UndrainNode(id string)    { delete(c.drained, id) }
+func (c *fakeCluster) IsDrained(id string) bool { return c.drained[id] }
+
+func (c *fakeCluster) taints(id string) []string {
+	var taints []string
+	for _, taint := range c.labels[id].Taints {
+		taints = append(taints, taint.String())
+	}
+	return taints
+}
+
+type recordingSink struct{ sent []*observability.Notification }
+
+func (s *recordingSink) SendNotification(n *observability.Notification) error {
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	cluster := newFakeCluster(map[string]map[string]string{
+		"gpu-1": {"group": "gpu"},
+		"gpu-2": {"group": "gpu"},
+		"cpu-1": {"group": "cpu"},
+	})
+	cluster.labels["gpu-1"] = placement.NodeLabels{
+		Labels: map[string]string{"group": "gpu"},
+		Taints: []placement.Taint{{Key: "dedicated", Value: "infer", Effect: placement.TaintPreferNoSchedule}},
+	}
+	cluster.drained["gpu-2"] = true // drained by an operator
+
+	m, err := NewManager(Config{
+		DrainBefore: 10 * time.Minute,
+		Windows: []Window{{
+			Name: "gpu-sunday", Selector: map[string]string{"group": "gpu"},
+			Days: []string{"sunday"}, Start: "02:00", End: "04:00",
+		}},
+	}, "gpu-1", cluster)
+	require.NoError(t, err)
+	now := at(1, "01:00")
+	m.now = func() time.Time { return now }
+
+	sink := &recordingSink{}
+	silenced := m.Silence(sink)
+	assert.Nil(t, m.Silence(nil))
+
+	m.Reconcile()
+	assert.Empty(t, m.Active())
+	assert.NoError(t, silenced.SendNotification(&observability.Notification{Title: "burn"}))
+	assert.Len(t, sink.sent, 1)
+
+	now = at(1, "01:55")
+	m.Reconcile()
+	assert.Equal(t, map[string]string{"gpu-1": "gpu-sunday", "gpu-2": "gpu-sunday"}, m.Active())
+	assert.True(t, cluster.IsDrained("gpu-1"))
+	assert.False(t, cluster.IsDrained("cpu-1"))
+	assert.Equal(t, []string{"dedicated=infer:PreferNoSchedule", "maintenance=gpu-sunday:NoSchedule"}, cluster.taints("gpu-1"))
+	assert.Empty(t, cluster.taints("cpu-1"))
+
+	// Alerts of this node and of other nodes in maintenance are dropped
+	assert.NoError(t, silenced.SendNotification(&observability.Notification{Title: "burn"}))
+	assert.NoError(t, silenced.SendNotification(&observability.Notification{Title: "down", NodeID: "gpu-2"}))
+	assert.NoError(t, silenced.SendNotification(&observability.Notification{Title: "down", NodeID: "cpu-1"}))
+	require.Len(t, sink.sent, 2)
+	assert.Equal(t, "cpu-1", sink.sent[1].NodeID)
+
+	now = at(1, "04:00")
+	m.Reconcile()
+	assert.Empty(t, m.Active())
+	assert.False(t, cluster.IsDrained("gpu-1"))
+	assert.True(t, cluster.IsDrained("gpu-2"), "an operator's drain outlasts the window")
+	assert.Equal(t, []string{"dedicated=infer:PreferNoSchedule"}, cluster.taints("gpu-1"))
+	assert.Empty(t, cluster.taints("gpu-2"))
+}
+
+func TestManagerSetWindow(t *testing.T) {
+	cluster := newFakeCluster(map[string]map[string]string{"n1": nil})
+	m, err := NewManager(Config{}, "n1", cluster)
+	require.NoError(t, err)
+	m.now = func() time.Time { return at(3, "12:00") }
+
+	require.NoError(t, m.SetWindow(Window{Name: "now", Nodes: []string{"n1"}, Start: "11:00", End: "13:00"}))
+	window, ok := m.InWindow("n1")
+	assert.True(t, ok)
+	assert.Equal(t, "now", window)
+	assert.True(t, cluster.IsDrained("n1"))
+
+	assert.Error(t, m.SetWindow(Window{Name: "bad", Nodes: []string{"n1"}, Start: "11:00"}))
+	assert.True(t, m.DeleteWindow("now"))
+	assert.False(t, m.DeleteWindow("now"))
+	_, ok = m.InWindow("n1")
+	assert.False(t, ok)
+	assert.False(t, cluster.IsDrained("n1"))
+}
@@ -0,0 +1,268 @@
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// Cluster is the part of the scheduler maintenance acts on
+type Cluster interface {
+	// Nodes returns the IDs of the known nodes
+	Nodes() []string
+	Labels(nodeID string) placement.NodeLabels
+	SetLabels(nodeID string, labels placement.NodeLabels) error
+	DrainNode(nodeID string)
+	UndrainNode(nodeID string)
+	IsDrained(nodeID string) bool
+}
+
+// Manager drains and taints the nodes of open windows, and returns them to
+// service when their windows close
+type Manager struct {
+	cluster     Cluster
+	localID     string
+	drainBefore time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]Window
+	// nodes maps the nodes in maintenance to their state
+	nodes map[string]*nodeState
+}
+
+// nodeState is what maintenance did to a node, to be undone when its
+// window closes
+type nodeState struct {
+	window string
+	// drained is whether the node was drained by maintenance rather than
+	// by an operator
+	drained bool
+}
+
+// NewManager creates a manager of the configured windows. localID is the
+// ID of this node, whose alerts Silence drops during its windows.
+func NewManager(cfg Config, localID string, cluster Cluster) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		cluster:     cluster,
+		localID:     localID,
+		drainBefore: cfg.DrainBefore,
+		now:         time.Now,
+		windows:     make(map[string]Window),
+		nodes:       make(map[string]*nodeState),
+	}
+	for _, w := range cfg.Windows {
+		m.windows[w.Name] = w
+	}
+	return m, nil
+}
+
+// Windows returns the windows sorted by name
+func (m *Manager) Windows() []Window {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	windows := make([]Window, 0, len(m.windows))
+	for _, w := range m.windows {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Name < windows[j].Name })
+	return windows
+}
+
+// SetWindow adds a window or replaces the one with its name, and applies
+// it at once
+func (m *Manager) SetWindow(w Window) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.windows[w.Name] = w
+	m.reconcile()
+	return nil
+}
+
+// DeleteWindow removes a window, returning its nodes to service if it was
+// open, and reports whether it existed
+func (m *Manager) DeleteWindow(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.windows[name]; !ok {
+		return false
+	}
+	delete(m.windows, name)
+	m.reconcile()
+	return true
+}
+
+// Active returns the nodes in maintenance and the windows they are in
+func (m *Manager) Active() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active := make(map[string]string, len(m.nodes))
+	for id, state := range m.nodes {
+		active[id] = state.window
+	}
+	return active
+}
+
+// InWindow reports whether a node is in maintenance, and in which window.
+// Disruptive operations such as upgrades should wait for it.
+func (m *Manager) InWindow(nodeID string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.nodes[nodeID]
+	if !ok {
+		return "", false
+	}
+	return state.window, true
+}
+
+// Run applies the windows every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Reconcile()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile puts the nodes of open windows into maintenance and returns the
+// others to service
+func (m *Manager) Reconcile() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconcile()
+}
+
+// reconcile is Reconcile with mu held
+func (m *Manager) reconcile() {
+	now := m.now()
+	names := make([]string, 0, len(m.windows))
+	for name := range m.windows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodes := m.cluster.Nodes()
+	for _, id := range nodes {
+		labels := m.cluster.Labels(id)
+		window := ""
+		for _, name := range names {
+			w := m.windows[name]
+			if w.Applies(id, labels.Labels) && w.Open(now, m.drainBefore) {
+				window = name
+				break
+			}
+		}
+
+		state, inMaintenance := m.nodes[id]
+		switch {
+		case window != "" && !inMaintenance:
+			state = &nodeState{window: window}
+			if !m.cluster.IsDrained(id) {
+				m.cluster.DrainNode(id)
+				state.drained = true
+			}
+			if err := m.setTaint(id, labels, window); err != nil {
+				slog.Warn("Failed to taint node for maintenance", "node", id, "window", window, "error", err)
+			}
+			m.nodes[id] = state
+			slog.Info("Node entered maintenance", "node", id, "window", window)
+		case window != "" && window != state.window:
+			// Adjacent windows: the node stays in maintenance
+			if err := m.setTaint(id, labels, window); err != nil {
+				slog.Warn("Failed to taint node for maintenance", "node", id, "window", window, "error", err)
+			}
+			state.window = window
+		case window == "" && inMaintenance:
+			m.leave(id, state, labels)
+		}
+	}
+
+	// Nodes gone from the cluster leave maintenance too
+	for id, state := range m.nodes {
+		if !slices.Contains(nodes, id) {
+			m.leave(id, state, m.cluster.Labels(id))
+		}
+	}
+}
+
+// leave returns a node to service
+func (m *Manager) leave(id string, state *nodeState, labels placement.NodeLabels) {
+	if err := m.setTaint(id, labels, ""); err != nil {
+		slog.Warn("Failed to remove maintenance taint", "node", id, "window", state.window, "error", err)
+	}
+	if state.drained {
+		m.cluster.UndrainNode(id)
+	}
+	delete(m.nodes, id)
+	slog.Info("Node left maintenance", "node", id, "window", state.window)
+}
+
+// setTaint replaces the maintenance taint of a node with one for window, or
+// removes it if window is empty
+func (m *Manager) setTaint(id string, labels placement.NodeLabels, window string) error {
+	taints := make([]placement.Taint, 0, len(labels.Taints)+1)
+	for _, taint := range labels.Taints {
+		if taint.Key != TaintKey {
+			taints = append(taints, taint)
+		}
+	}
+	if window != "" {
+		taints = append(taints, placement.Taint{Key: TaintKey, Value: window, Effect: placement.TaintNoSchedule})
+	}
+	labels.Taints = taints
+	return m.cluster.SetLabels(id, labels)
+}
+
+// Silence wraps an alert sink to drop the alerts of nodes in maintenance.
+// Alerts without a node ID are this node's. A nil sink stays nil.
+func (m *Manager) Silence(sink observability.SLOAlertSink) observability.SLOAlertSink {
+	if m == nil || sink == nil {
+		return sink
+	}
+	return &silencedSink{manager: m, sink: sink}
+}
+
+type silencedSink struct {
+	manager *Manager
+	sink    observability.SLOAlertSink
+}
+
+func (s *silencedSink) SendNotification(notification *observability.Notification) error {
+	node := notification.NodeID
+	if node == "" {
+		node = s.manager.localID
+	}
+	if window, ok := s.manager.InWindow(node); ok {
+		slog.Debug("Silenced alert during maintenance", "alert", notification.Title, "node", node, "window", window)
+		return nil
+	}
+	return s.sink.SendNotification(notification)
+}
@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Shared pools for the request hot path. Buffers taken from them must not be
+// used after they are put back.
+var (
+	// RequestBodies holds request and response bodies buffered by the API
+	RequestBodies = NewByteBufferPool("request_body", 4<<20)
+
+	// CopyBuffers is used by the proxy to stream bodies between client and
+	// Ollama instance; it satisfies httputil.BufferPool
+	CopyBuffers = NewCopyBufferPool("proxy_copy", 32<<10)
+
+	// TensorStaging holds P2P messages and model chunks while they are
+	// decoded or hashed
+	TensorStaging = NewStagingPool("tensor_staging", 4<<10, 64<<20)
+)
+
+// ReuseStats counts how often a pool had to allocate. Allocations divided by
+// Gets is the share of requests that were not served from the pool.
+type ReuseStats struct {
+	Gets           int64 `json:"gets"`
+	Allocations    int64 `json:"allocations"`
+	AllocatedBytes int64 `json:"allocated_bytes"`
+	Discards       int64 `json:"discards"` // buffers too large to keep
+}
+
+type reuseCounters struct {
+	gets           atomic.Int64
+	allocations    atomic.Int64
+	allocatedBytes atomic.Int64
+	discards       atomic.Int64
+}
+
+func (rc *reuseCounters) allocated(size int) {
+	rc.allocations.Add(1)
+	rc.allocatedBytes.Add(int64(size))
+}
+
+func (rc *reuseCounters) stats() ReuseStats {
+	return ReuseStats{
+		Gets:           rc.gets.Load(),
+		Allocations:    rc.allocations.Load(),
+		AllocatedBytes: rc.allocatedBytes.Load(),
+		Discards:       rc.discards.Load(),
+	}
+}
+
+var (
+	reuseMu    sync.RWMutex
+	reusePools = make(map[string]*reuseCounters)
+)
+
+// registerReuse makes the counters of a named pool visible to ReusePoolStats
+func registerReuse(name string, rc *reuseCounters) {
+	reuseMu.Lock()
+	reusePools[name] = rc
+	reuseMu.Unlock()
+}
+
+// ReusePoolStats returns the statistics of every shared pool by name
+func ReusePoolStats() map[string]ReuseStats {
+	reuseMu.RLock()
+	defer reuseMu.RUnlock()
+
+	stats := make(map[string]ReuseStats, len(reusePools))
+	for name, rc := range reusePools {
+		stats[name] = rc.stats()
+	}
+	return stats
+}
+
+// ByteBufferPool pools growable buffers. Buffers that grew beyond
+// maxRetained are dropped on Put so one large body does not stay pinned.
+// Growth happens inside bytes.Buffer, so AllocatedBytes is not tracked.
+type ByteBufferPool struct {
+	pool        sync.Pool
+	maxRetained int
+	counters    reuseCounters
+}
+
+// NewByteBufferPool creates a buffer pool reported under name
+func NewByteBufferPool(name string, maxRetained int) *ByteBufferPool {
+	p := &ByteBufferPool{maxRetained: maxRetained}
+	registerReuse(name, &p.counters)
+	return p
+}
+
+// Get returns an empty buffer
+func (p *ByteBufferPool) Get() *bytes.Buffer {
+	p.counters.gets.Add(1)
+	if buf, ok := p.pool.Get().(*bytes.Buffer); ok {
+		return buf
+	}
+	p.counters.allocations.Add(1)
+	return new(bytes.Buffer)
+}
+
+// Put returns buf to the pool
+func (p *ByteBufferPool) Put(buf *bytes.Buffer) {
+	if buf == nil {
+		return
+	}
+	if p.maxRetained > 0 && buf.Cap() > p.maxRetained {
+		p.counters.discards.Add(1)
+		return
+	}
+	buf.Reset()
+	p.pool.Put(buf)
+}
+
+// CopyBufferPool pools fixed size copy buffers and implements
+// httputil.BufferPool
+type CopyBufferPool struct {
+	pool     sync.Pool
+	size     int
+	counters reuseCounters
+}
+
+// NewCopyBufferPool creates a pool of size byte buffers reported under name
+func NewCopyBufferPool(name string, size int) *CopyBufferPool {
+	p := &CopyBufferPool{size: size}
+	registerReuse(name, &p.counters)
+	return p
+}
+
+// Get returns a buffer of the pool's size
+func (p *CopyBufferPool) Get() []byte {
+	p.counters.gets.Add(1)
+	if buf, ok := p.pool.Get().(*[]byte); ok {
+		return *buf
+	}
+	p.counters.allocated(p.size)
+	return make([]byte, p.size)
+}
+
+// Put returns buf to the pool; buffers of another size are dropped
+func (p *CopyBufferPool) Put(buf []byte) {
+	if cap(buf) != p.size {
+		p.counters.discards.Add(1)
+		return
+	}
+	buf = buf[:p.size]
+	p.pool.Put(&buf)
+}
+
+// StagingPool pools byte slices in power of two size classes between
+// minSize and maxSize. Larger requests are allocated and never pooled.
+// Unlike BufferPool, slices are not zeroed: callers overwrite them fully.
+type StagingPool struct {
+	classes  []int
+	pools    []sync.Pool
+	counters reuseCounters
+}
+
+// NewStagingPool creates a size-classed pool reported under name
+func NewStagingPool(name string, minSize, maxSize int) *StagingPool {
+	p := &StagingPool{}
+	for size := nextPowerOf2(minSize); size <= maxSize; size <<= 1 {
+		p.classes = append(p.classes, size)
+	}
+	p.pools = make([]sync.Pool, len(p.classes))
+	registerReuse(name, &p.counters)
+	return p
+}
+
+// Get returns a slice of length n
+func (p *StagingPool) Get(n int) []byte {
+	p.counters.gets.Add(1)
+	class := p.classFor(n)
+	if class < 0 {
+		p.counters.allocated(n)
+		return make([]byte, n)
+	}
+	if buf, ok := p.pools[class].Get().(*[]byte); ok {
+		return (*buf)[:n]
+	}
+	p.counters.allocated(p.classes[class])
+	return make([]byte, n, p.classes[class])
+}
+
+// Put returns a slice obtained from Get to the pool
+func (p *StagingPool) Put(buf []byte) {
+	class := p.classFor(cap(buf))
+	if class < 0 || p.classes[class] != cap(buf) {
+		p.counters.discards.Add(1)
+		return
+	}
+	buf = buf[:cap(buf)]
+	p.pools[class].Put(&buf)
+}
+
+// classFor returns the smallest size class holding n bytes, or -1
+func (p *StagingPool) classFor(n int) int {
+	i := sort.SearchInts(p.classes, n)
+	if i == len(p.classes) {
+		return -1
+	}
+	return i
+}
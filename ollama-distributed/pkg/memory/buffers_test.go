@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagingPoolSizeClasses(t *testing.T) {
+	pool := NewStagingPool("test_staging", 1000, 8192)
+
+	buf := pool.Get(3000)
+	require.Len(t, buf, 3000)
+	assert.Equal(t, 4096, cap(buf))
+	pool.Put(buf)
+
+	// Beyond the largest class the slice is allocated and not pooled
+	large := pool.Get(10000)
+	assert.Len(t, large, 10000)
+	pool.Put(large)
+
+	stats := ReusePoolStats()["test_staging"]
+	assert.Equal(t, int64(2), stats.Gets)
+	assert.Equal(t, int64(2), stats.Allocations)
+	assert.Equal(t, int64(4096+10000), stats.AllocatedBytes)
+	assert.Equal(t, int64(1), stats.Discards)
+}
+
+func TestCopyBufferPool(t *testing.T) {
+	pool := NewCopyBufferPool("test_copy", 512)
+
+	buf := pool.Get()
+	assert.Len(t, buf, 512)
+	pool.Put(buf)
+	pool.Put(make([]byte, 100))
+
+	stats := ReusePoolStats()["test_copy"]
+	assert.Equal(t, int64(1), stats.Gets)
+	assert.Equal(t, int64(1), stats.Discards)
+}
+
+func TestByteBufferPoolDropsLargeBuffers(t *testing.T) {
+	pool := NewByteBufferPool("test_bytes", 64)
+
+	buf := pool.Get()
+	buf.WriteString("hello")
+	pool.Put(buf)
+
+	buf = pool.Get()
+	assert.Zero(t, buf.Len())
+	buf.Write(make([]byte, 128))
+	pool.Put(buf)
+
+	assert.Equal(t, int64(1), ReusePoolStats()["test_bytes"].Discards)
+}
+
+// BenchmarkStagingPool shows the allocations saved against make([]byte, n)
+func BenchmarkStagingPool(b *testing.B) {
+	pool := NewStagingPool("bench_staging", 4<<10, 1<<20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := pool.Get(256 << 10)
+		buf[0] = byte(i)
+		pool.Put(buf)
+	}
+}
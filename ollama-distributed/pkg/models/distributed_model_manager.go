@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/modelscan"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -40,6 +42,13 @@ type DistributedModelManager struct {
 	// Performance monitoring
 	monitor *PerformanceMonitor
 
+	// Requests executing against each model, which removal waits for
+	refs *ModelRefs
+
+	// Optional scanning of imported models, which rejects and quarantines
+	// the ones failing a scan
+	scanner *modelscan.Pipeline
+
 	// Context management
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -372,6 +381,7 @@ func NewDistributedModelManager(
 		config:             config,
 		p2p:                p2pNode,
 		logger:             logger,
+		refs:               NewModelRefs(),
 		ctx:                ctx,
 		cancel:             cancel,
 	}
@@ -411,8 +421,30 @@ func NewDistributedModelManager(
 	return dmm, nil
 }
 
-// RemoveModel removes a model from the distributed system if present
+// Refs returns the references inference requests hold on models, which
+// keep them from being removed while the requests execute
+func (dmm *DistributedModelManager) Refs() *ModelRefs {
+	return dmm.refs
+}
+
+// RemoveModel removes a model from the distributed system if present. It
+// returns a *ModelInUseError while requests are executing against it.
 func (dmm *DistributedModelManager) RemoveModel(modelName string) error {
+	return dmm.refs.Remove(modelName, nil, func() error {
+		return dmm.removeModel(modelName)
+	})
+}
+
+// RemoveModelWhenDrained refuses new requests against a model, waits for
+// the executing ones to finish and removes it. It returns a
+// *ModelInUseError when ctx ends before the model drained.
+func (dmm *DistributedModelManager) RemoveModelWhenDrained(ctx context.Context, modelName string) error {
+	return dmm.refs.Drain(ctx, modelName, nil, func() error {
+		return dmm.removeModel(modelName)
+	})
+}
+
+func (dmm *DistributedModelManager) removeModel(modelName string) error {
 	// Remove from registry
 	dmm.registryMutex.Lock()
 	model, exists := dmm.registry.models[modelName]
@@ -449,6 +481,12 @@ func (dmm *DistributedModelManager) RemoveModel(modelName string) error {
 	return nil
 }
 
+// SetPlacementPolicy sets the affinity and anti-affinity constraints and
+// the node labels and taints that replication places new replicas by
+func (dmm *DistributedModelManager) SetPlacementPolicy(policy *placement.Policy, labels *placement.Registry) {
+	dmm.replicationManager.SetPlacementPolicy(policy, labels)
+}
+
 // Start starts the distributed model manager
 func (dmm *DistributedModelManager) Start() error {
 	dmm.mu.Lock()
@@ -515,8 +553,25 @@ func (dmm *DistributedModelManager) GetModel(modelName string) (*DistributedMode
 	return dmm.discoverAndFetchModel(modelName)
 }
 
-// AddModel adds a model to the distributed system
+// SetScanner scans models before they are added, rejecting the ones
+// failing a scan
+func (dmm *DistributedModelManager) SetScanner(scanner *modelscan.Pipeline) {
+	dmm.scanner = scanner
+}
+
+// AddModel adds a model to the distributed system. With a scanner set, the
+// model is scanned first; a model failing the scan is quarantined and a
+// *modelscan.RejectedError returned.
 func (dmm *DistributedModelManager) AddModel(modelName, modelPath string) (*DistributedModel, error) {
+	var scan *modelscan.Report
+	if dmm.scanner != nil {
+		var err error
+		if scan, err = dmm.scanner.Scan(dmm.ctx, modelName, modelscan.KindModel, modelPath); err != nil {
+			dmm.logger.Warn("model rejected by scan", "model", modelName, "error", err)
+			return nil, err
+		}
+	}
+
 	// Create model version
 	version, err := dmm.syncManager.CreateModelVersion(modelName, modelPath)
 	if err != nil {
@@ -544,6 +599,10 @@ func (dmm *DistributedModelManager) AddModel(modelName, modelPath string) (*Dist
 	}
 
 	// Add to registry
+	if scan != nil {
+		model.Metadata["scan"] = scan
+	}
+
 	dmm.registryMutex.Lock()
 	dmm.registry.models[modelName] = model
 	dmm.registryMutex.Unlock()
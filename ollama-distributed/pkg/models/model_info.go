@@ -0,0 +1,44 @@
+package models
+
+import "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+
+// Info returns the information about the model the scheduler and the
+// inference engine work with, located on the peers of its replicas
+func (dm *DistributedModel) Info() *types.ModelInfo {
+	info := &types.ModelInfo{
+		Name:         dm.Name,
+		Size:         dm.Size,
+		Checksum:     dm.Hash,
+		Locations:    make([]string, 0, len(dm.Replicas)),
+		AccessCount:  dm.AccessCount,
+		LastAccessed: dm.AccessedAt,
+		Metadata:     make(map[string]string),
+	}
+	for _, replica := range dm.Replicas {
+		info.Locations = append(info.Locations, replica.PeerID)
+	}
+	for k, v := range dm.Metadata {
+		if s, ok := v.(string); ok {
+			info.Metadata[k] = s
+		}
+	}
+	return info
+}
+
+// ModelInfo retrieves a model as GetModel does, and returns its information
+func (dmm *DistributedModelManager) ModelInfo(modelName string) (*types.ModelInfo, error) {
+	model, err := dmm.GetModel(modelName)
+	if err != nil {
+		return nil, err
+	}
+	return model.Info(), nil
+}
+
+// AddModelInfo adds a model as AddModel does, and returns its information
+func (dmm *DistributedModelManager) AddModelInfo(modelName, modelPath string) (*types.ModelInfo, error) {
+	model, err := dmm.AddModel(modelName, modelPath)
+	if err != nil {
+		return nil, err
+	}
+	return model.Info(), nil
+}
@@ -0,0 +1,273 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrModelDraining is returned when acquiring a model, or a replica of it,
+// that is waiting for its requests to finish before being removed
+var ErrModelDraining = errors.New("model is being removed")
+
+// ModelInUseError is returned when removing a model, or replicas of it,
+// that requests are still executing against
+type ModelInUseError struct {
+	Model          string         `json:"model"`
+	ActiveRequests int            `json:"active_requests"`
+	Replicas       map[string]int `json:"replicas,omitempty"` // node ID -> active requests
+}
+
+func (e *ModelInUseError) Error() string {
+	return fmt.Sprintf("model %s is in use by %d active requests", e.Model, e.ActiveRequests)
+}
+
+// modelRef counts the requests executing against a model and the replicas
+// they run on
+type modelRef struct {
+	active   int
+	replicas map[string]int
+	draining int            // removals of the whole model waiting or running
+	drained  map[string]int // removals of replicas waiting or running
+
+	// changed is closed and replaced whenever a request is released, so
+	// removals can wait for the model to drain
+	changed chan struct{}
+}
+
+func (r *modelRef) idle() bool {
+	return r.active == 0 && r.draining == 0 && len(r.drained) == 0
+}
+
+// ModelRefs counts the inference requests executing against each model and
+// replica so that they are not deleted, unloaded or replaced underneath
+// the requests. The inference engine acquires a lease for every request;
+// removals go through Remove, which refuses while leases are held, or
+// Drain, which waits for them.
+type ModelRefs struct {
+	mu   sync.Mutex
+	refs map[string]*modelRef
+}
+
+// NewModelRefs creates an empty set of model references
+func NewModelRefs() *ModelRefs {
+	return &ModelRefs{refs: make(map[string]*modelRef)}
+}
+
+// ModelLease keeps a model, and the replicas added to it, from being
+// removed until it is released
+type ModelLease struct {
+	refs     *ModelRefs
+	model    string
+	replicas []string
+	once     sync.Once
+}
+
+// Acquire leases model for a request. It fails with ErrModelDraining while
+// the model is being removed.
+func (r *ModelRefs) Acquire(model string) (*ModelLease, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref := r.ref(model)
+	if ref.draining > 0 {
+		r.forget(model, ref)
+		return nil, fmt.Errorf("%w: %s", ErrModelDraining, model)
+	}
+	ref.active++
+	return &ModelLease{refs: r, model: model}, nil
+}
+
+// AddReplicas extends the lease to the replicas of the model on nodes,
+// once the request knows where it runs. It fails with ErrModelDraining,
+// adding none of them, when any of the replicas is being removed.
+func (l *ModelLease) AddReplicas(nodes ...string) error {
+	l.refs.mu.Lock()
+	defer l.refs.mu.Unlock()
+
+	ref := l.refs.refs[l.model]
+	for _, node := range nodes {
+		if ref.drained[node] > 0 {
+			return fmt.Errorf("%w: %s on %s", ErrModelDraining, l.model, node)
+		}
+	}
+	for _, node := range nodes {
+		ref.replicas[node]++
+	}
+	l.replicas = append(l.replicas, nodes...)
+	return nil
+}
+
+// Release ends the lease. It is safe to call more than once.
+func (l *ModelLease) Release() {
+	l.once.Do(func() {
+		l.refs.mu.Lock()
+		defer l.refs.mu.Unlock()
+
+		ref := l.refs.refs[l.model]
+		ref.active--
+		for _, node := range l.replicas {
+			if ref.replicas[node]--; ref.replicas[node] <= 0 {
+				delete(ref.replicas, node)
+			}
+		}
+		close(ref.changed)
+		ref.changed = make(chan struct{})
+		l.refs.forget(l.model, ref)
+	})
+}
+
+// Active returns the number of requests executing against model and, by
+// node, against its replicas
+func (r *ModelRefs) Active(model string) (int, map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref, ok := r.refs[model]
+	if !ok {
+		return 0, map[string]int{}
+	}
+	replicas := make(map[string]int, len(ref.replicas))
+	for node, n := range ref.replicas {
+		replicas[node] = n
+	}
+	return ref.active, replicas
+}
+
+// InUse returns the models requests are executing against, sorted by name
+func (r *ModelRefs) InUse() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var models []string
+	for model, ref := range r.refs {
+		if ref.active > 0 {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// Remove runs remove unless requests are executing against model, or
+// against its replicas on nodes when any are given, in which case it
+// returns a *ModelInUseError. New requests are refused while remove runs.
+func (r *ModelRefs) Remove(model string, nodes []string, remove func() error) error {
+	r.mu.Lock()
+	ref := r.ref(model)
+	if inUse := ref.inUse(model, nodes); inUse != nil {
+		r.forget(model, ref)
+		r.mu.Unlock()
+		return inUse
+	}
+	ref.block(nodes)
+	r.mu.Unlock()
+
+	defer r.unblock(model, nodes)
+	return remove()
+}
+
+// Drain refuses new requests against model, or against its replicas on
+// nodes when any are given, waits for the executing ones to finish and
+// runs remove. When ctx ends first nothing is removed and a
+// *ModelInUseError is returned with the requests still executing.
+func (r *ModelRefs) Drain(ctx context.Context, model string, nodes []string, remove func() error) error {
+	r.mu.Lock()
+	ref := r.ref(model)
+	ref.block(nodes)
+	for {
+		inUse := ref.inUse(model, nodes)
+		if inUse == nil {
+			break
+		}
+		changed := ref.changed
+		r.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			r.unblock(model, nodes)
+			return inUse
+		}
+		r.mu.Lock()
+	}
+	r.mu.Unlock()
+
+	defer r.unblock(model, nodes)
+	return remove()
+}
+
+// ref returns the references of model, creating them. Callers hold mu.
+func (r *ModelRefs) ref(model string) *modelRef {
+	ref, ok := r.refs[model]
+	if !ok {
+		ref = &modelRef{
+			replicas: make(map[string]int),
+			drained:  make(map[string]int),
+			changed:  make(chan struct{}),
+		}
+		r.refs[model] = ref
+	}
+	return ref
+}
+
+// forget drops the references of model once nothing holds them. Callers
+// hold mu.
+func (r *ModelRefs) forget(model string, ref *modelRef) {
+	if ref.idle() {
+		delete(r.refs, model)
+	}
+}
+
+// inUse reports the requests blocking the removal of the model, or of its
+// replicas on nodes, or nil when there are none
+func (ref *modelRef) inUse(model string, nodes []string) *ModelInUseError {
+	replicas := make(map[string]int)
+	active := ref.active
+	if len(nodes) == 0 {
+		for node, n := range ref.replicas {
+			replicas[node] = n
+		}
+	} else {
+		active = 0
+		for _, node := range nodes {
+			if n := ref.replicas[node]; n > 0 {
+				replicas[node] = n
+				active += n
+			}
+		}
+	}
+	if active == 0 {
+		return nil
+	}
+	return &ModelInUseError{Model: model, ActiveRequests: active, Replicas: replicas}
+}
+
+func (ref *modelRef) block(nodes []string) {
+	if len(nodes) == 0 {
+		ref.draining++
+		return
+	}
+	for _, node := range nodes {
+		ref.drained[node]++
+	}
+}
+
+func (r *ModelRefs) unblock(model string, nodes []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref := r.refs[model]
+	if len(nodes) == 0 {
+		ref.draining--
+	} else {
+		for _, node := range nodes {
+			if ref.drained[node]--; ref.drained[node] <= 0 {
+				delete(ref.drained, node)
+			}
+		}
+	}
+	r.forget(model, ref)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelRefsRemoveRefusedWhileInUse(t *testing.T) {
+	refs := NewModelRefs()
+	lease, err := refs.Acquire("llama3")
+	require.NoError(t, err)
+	require.NoError(t, lease.AddReplicas("node-a", "node-b"))
+
+	removed := false
+	remove := func() error { removed = true; return nil }
+
+	err = refs.Remove("llama3", nil, remove)
+	var inUse *ModelInUseError
+	require.True(t, errors.As(err, &inUse))
+	assert.Equal(t, 1, inUse.ActiveRequests)
+	assert.Equal(t, map[string]int{"node-a": 1, "node-b": 1}, inUse.Replicas)
+	assert.False(t, removed)
+
+	// Replicas without requests can go
+	require.NoError(t, refs.Remove("llama3", []string{"node-c"}, remove))
+	assert.True(t, removed)
+	assert.Error(t, refs.Remove("llama3", []string{"node-a"}, remove))
+
+	lease.Release()
+	lease.Release()
+	active, replicas := refs.Active("llama3")
+	assert.Zero(t, active)
+	assert.Empty(t, replicas)
+	assert.Empty(t, refs.InUse())
+	assert.Empty(t, refs.refs)
+}
+
+func TestModelRefsDrainWaitsForRequests(t *testing.T) {
+	refs := NewModelRefs()
+	lease, err := refs.Acquire("llama3")
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- refs.Drain(context.Background(), "llama3", nil, func() error { return nil })
+	}()
+
+	// New requests are refused while the model drains
+	require.Eventually(t, func() bool {
+		probe, err := refs.Acquire("llama3")
+		if err == nil {
+			probe.Release()
+		}
+		return errors.Is(err, ErrModelDraining)
+	}, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("drain finished with a request still executing")
+	default:
+	}
+
+	lease.Release()
+	require.NoError(t, <-done)
+	lease, err = refs.Acquire("llama3")
+	require.NoError(t, err)
+	lease.Release()
+}
+
+func TestModelRefsDrainTimeout(t *testing.T) {
+	refs := NewModelRefs()
+	lease, err := refs.Acquire("llama3")
+	require.NoError(t, err)
+	require.NoError(t, lease.AddReplicas("node-a"))
+	defer lease.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = refs.Drain(ctx, "llama3", []string{"node-a"}, func() error {
+		t.Fatal("removed a replica in use")
+		return nil
+	})
+	var inUse *ModelInUseError
+	require.True(t, errors.As(err, &inUse))
+	assert.Equal(t, map[string]int{"node-a": 1}, inUse.Replicas)
+
+	// The replica accepts requests again once the drain gave up
+	other, err := refs.Acquire("llama3")
+	require.NoError(t, err)
+	assert.NoError(t, other.AddReplicas("node-a"))
+	other.Release()
+}
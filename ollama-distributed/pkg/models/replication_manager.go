@@ -9,6 +9,7 @@ import (
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
 )
 
 // ReplicationManager manages model replication across peers
@@ -27,6 +28,10 @@ type ReplicationManager struct {
 	policies      map[string]*ReplicationPolicy
 	policiesMutex sync.RWMutex
 
+	// Placement constraints and the labels and taints of each peer
+	placement  *placement.Policy
+	peerLabels *placement.Registry
+
 	// Replication workers
 	workers   []*ReplicationWorker
 	workQueue chan *ReplicationTask
@@ -234,6 +239,17 @@ func (rm *ReplicationManager) SetReplicationPolicy(modelName string, policy *Rep
 	return nil
 }
 
+// SetPlacementPolicy sets the affinity and anti-affinity constraints new
+// replicas must satisfy, and the registry of peer labels and taints they
+// are checked against. Either may be nil.
+func (rm *ReplicationManager) SetPlacementPolicy(policy *placement.Policy, labels *placement.Registry) {
+	rm.policiesMutex.Lock()
+	defer rm.policiesMutex.Unlock()
+
+	rm.placement = policy
+	rm.peerLabels = labels
+}
+
 // GetReplicationPolicy gets the replication policy for a model
 func (rm *ReplicationManager) GetReplicationPolicy(modelName string) (*ReplicationPolicy, bool) {
 	rm.policiesMutex.RLock()
@@ -401,6 +417,11 @@ func (rm *ReplicationManager) findSuitablePeers(modelName string, policy *Replic
 		existing[replica.PeerID] = true
 	}
 
+	rm.policiesMutex.RLock()
+	placementPolicy, peerLabels := rm.placement, rm.peerLabels
+	rm.policiesMutex.RUnlock()
+	views := rm.placementViews(placementPolicy, peerLabels)
+
 	// Check preferred peers first
 	for _, peer := range policy.PreferredPeers {
 		if len(suitable) >= count {
@@ -411,19 +432,21 @@ func (rm *ReplicationManager) findSuitablePeers(modelName string, policy *Replic
 			continue // Already has replica
 		}
 
+		if err := placementPolicy.Check(modelName, views(peer)); err != nil {
+			rm.logger.Debug("preferred peer rejected by placement constraints", "model", modelName, "peer", peer, "reason", err)
+			continue
+		}
+
 		if rm.isPeerConnected(peer, connectedPeers) {
 			suitable = append(suitable, peer)
 		}
 	}
 
-	// Add other suitable peers if needed
+	// Add other suitable peers if needed, in affinity order
+	var candidates []placement.Node
 	for _, peer := range connectedPeers {
-		if len(suitable) >= count {
-			break
-		}
-
-		if existing[peer] {
-			continue // Already has replica
+		if existing[peer] || rm.isPeerConnected(peer, suitable) {
+			continue // Already has replica or chosen
 		}
 
 		if rm.isPeerExcluded(peer, policy.ExcludedPeers) {
@@ -431,13 +454,39 @@ func (rm *ReplicationManager) findSuitablePeers(modelName string, policy *Replic
 		}
 
 		if rm.isPeerSuitable(peer, policy.Constraints) {
-			suitable = append(suitable, peer)
+			candidates = append(candidates, views(peer))
+		}
+	}
+
+	ranked, rejected := placementPolicy.Rank(modelName, candidates)
+	for _, r := range rejected {
+		rm.logger.Debug("peer rejected by placement constraints", "model", modelName, "peer", r.NodeID, "reason", r.Reason)
+	}
+	for _, node := range ranked {
+		if len(suitable) >= count {
+			break
 		}
+		suitable = append(suitable, node.ID)
 	}
 
 	return suitable
 }
 
+// placementViews returns a function describing a peer for placement
+// decisions: its labels, its taints and the models it holds replicas of
+func (rm *ReplicationManager) placementViews(policy *placement.Policy, labels *placement.Registry) func(peer string) placement.Node {
+	modelsByPeer := make(map[string][]string)
+	if policy != nil {
+		for _, replica := range rm.GetAllReplicas() {
+			modelsByPeer[replica.PeerID] = append(modelsByPeer[replica.PeerID], replica.ModelName)
+		}
+	}
+
+	return func(peer string) placement.Node {
+		return labels.Node(peer, modelsByPeer[peer])
+	}
+}
+
 // selectReplicasToRemove selects replicas to remove
 func (rm *ReplicationManager) selectReplicasToRemove(modelName string, count int) []*ReplicaInfo {
 	replicas := rm.GetReplicas(modelName)
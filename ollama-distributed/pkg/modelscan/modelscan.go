@@ -0,0 +1,370 @@
+// Package modelscan scans models and adapters when they are imported,
+// before they are registered and served. A pipeline runs pluggable
+// scanners over the files of an artifact: a blocklist of known-bad hashes,
+// checks that the files are model formats rather than executables or
+// pickles that run code on load, and external commands such as malware or
+// secret scanners. Artifacts failing a scan are moved to quarantine, and
+// the report is kept for the model's metadata.
+package modelscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of artifacts
+const (
+	KindModel   = "model"
+	KindAdapter = "adapter"
+)
+
+// DefaultQuarantineDir is where rejected artifacts are moved, relative to
+// their directory, when no quarantine directory is configured
+const DefaultQuarantineDir = ".quarantine"
+
+// Config configures model scanning
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// QuarantineDir receives rejected artifacts
+	QuarantineDir string `yaml:"quarantine_dir" mapstructure:"quarantine_dir"`
+	// BlockedHashes are SHA-256 digests of files that are rejected, and
+	// BlocklistFile a file listing more, one per line
+	BlockedHashes []string `yaml:"blocked_hashes" mapstructure:"blocked_hashes"`
+	BlocklistFile string   `yaml:"blocklist_file" mapstructure:"blocklist_file"`
+	// AllowedTypes are the file types artifacts may contain; by default
+	// gguf, safetensors, json and text
+	AllowedTypes []string `yaml:"allowed_types" mapstructure:"allowed_types"`
+	// Commands are external scanners run on each artifact
+	Commands []CommandConfig `yaml:"commands"`
+}
+
+// Validate checks the hashes, file types and commands
+func (c Config) Validate() error {
+	for _, hash := range c.BlockedHashes {
+		if !validHash(hash) {
+			return fmt.Errorf("invalid blocked hash %q, expected a SHA-256 hex digest", hash)
+		}
+	}
+	for _, t := range c.AllowedTypes {
+		if !knownType(t) {
+			return fmt.Errorf("unknown file type %q", t)
+		}
+	}
+	names := make(map[string]bool)
+	for _, cmd := range c.Commands {
+		if err := cmd.Validate(); err != nil {
+			return err
+		}
+		if names[cmd.Name] {
+			return fmt.Errorf("duplicate scan command %s", cmd.Name)
+		}
+		names[cmd.Name] = true
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if len(c.AllowedTypes) == 0 {
+		c.AllowedTypes = []string{TypeGGUF, TypeSafetensors, TypeJSON, TypeText}
+	}
+	for i := range c.Commands {
+		c.Commands[i] = c.Commands[i].withDefaults()
+	}
+	return c
+}
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func validHash(hash string) bool {
+	return hashPattern.MatchString(hash)
+}
+
+// Artifact is an imported model or adapter: a file or a directory of files
+type Artifact struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	// Files are the files of the artifact, hashed by the pipeline
+	Files []File `json:"files"`
+}
+
+// File is a file of an artifact
+type File struct {
+	// Path is relative to the artifact path, "." for a single file
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// abs returns the path of a file of the artifact
+func (a Artifact) abs(f File) string {
+	return filepath.Join(a.Path, filepath.FromSlash(f.Path))
+}
+
+// Scanner checks an artifact, returning findings that reject it. Errors
+// fail the scan too: an artifact that could not be checked is not trusted.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, artifact Artifact) ([]string, error)
+}
+
+// Result is the outcome of a scanner
+type Result struct {
+	Scanner  string        `json:"scanner"`
+	Passed   bool          `json:"passed"`
+	Findings []string      `json:"findings,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the outcome of scanning an artifact
+type Report struct {
+	Name      string        `json:"name"`
+	Kind      string        `json:"kind"`
+	Path      string        `json:"path"`
+	Files     []File        `json:"files"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	Duration  time.Duration `json:"duration"`
+	Passed    bool          `json:"passed"`
+	Results   []Result      `json:"results"`
+	// QuarantinedTo is where a rejected artifact was moved
+	QuarantinedTo string `json:"quarantined_to,omitempty"`
+}
+
+// RejectedError is returned for artifacts failing a scan
+type RejectedError struct {
+	Report *Report
+}
+
+func (e *RejectedError) Error() string {
+	for _, r := range e.Report.Results {
+		if r.Passed {
+			continue
+		}
+		reason := r.Error
+		if len(r.Findings) > 0 {
+			reason = r.Findings[0]
+		}
+		return fmt.Sprintf("%s %s rejected by %s scan: %s", e.Report.Kind, e.Report.Name, r.Scanner, reason)
+	}
+	return fmt.Sprintf("%s %s rejected", e.Report.Kind, e.Report.Name)
+}
+
+// Pipeline runs scanners over imported artifacts and keeps the latest
+// report of each
+type Pipeline struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.RWMutex
+	scanners []Scanner
+	reports  map[string]*Report
+}
+
+// NewPipeline creates a pipeline with the scanners of cfg: the hash
+// blocklist when hashes are blocked, the file type check and the commands
+func NewPipeline(cfg Config) (*Pipeline, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+
+	p := &Pipeline{cfg: cfg, now: time.Now, reports: make(map[string]*Report)}
+	if len(cfg.BlockedHashes) > 0 || cfg.BlocklistFile != "" {
+		blocklist, err := NewHashBlocklist(cfg.BlockedHashes, cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		p.scanners = append(p.scanners, blocklist)
+	}
+	p.scanners = append(p.scanners, NewFileTypeScanner(cfg.AllowedTypes))
+	for _, cmd := range cfg.Commands {
+		p.scanners = append(p.scanners, NewCommandScanner(cmd))
+	}
+	return p, nil
+}
+
+// Config returns the configuration of the pipeline, defaults applied
+func (p *Pipeline) Config() Config {
+	return p.cfg
+}
+
+// Register adds a scanner run on every artifact after the configured ones
+func (p *Pipeline) Register(s Scanner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scanners = append(p.scanners, s)
+}
+
+// Scanners returns the names of the scanners, in the order they run
+func (p *Pipeline) Scanners() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, len(p.scanners))
+	for i, s := range p.scanners {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// Report returns the latest report of an artifact
+func (p *Pipeline) Report(name string) (*Report, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	report, ok := p.reports[name]
+	return report, ok
+}
+
+// Scan hashes the files of the artifact at path and runs every scanner on
+// them. An artifact failing a scan is quarantined and a *RejectedError
+// returned along with the report; other errors mean the artifact could not
+// be read or quarantined.
+func (p *Pipeline) Scan(ctx context.Context, name, kind, path string) (*Report, error) {
+	start := p.now()
+	files, err := hashFiles(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s: %w", kind, name, err)
+	}
+	artifact := Artifact{Name: name, Kind: kind, Path: path, Files: files}
+
+	p.mu.RLock()
+	scanners := append([]Scanner(nil), p.scanners...)
+	p.mu.RUnlock()
+
+	report := &Report{Name: name, Kind: kind, Path: path, Files: files, ScannedAt: start, Passed: true}
+	for _, s := range scanners {
+		began := time.Now()
+		findings, err := s.Scan(ctx, artifact)
+		result := Result{Scanner: s.Name(), Passed: err == nil && len(findings) == 0, Findings: findings, Duration: time.Since(began)}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		report.Passed = report.Passed && result.Passed
+		report.Results = append(report.Results, result)
+	}
+	report.Duration = p.now().Sub(start)
+
+	if !report.Passed {
+		if report.QuarantinedTo, err = p.quarantine(report); err != nil {
+			return report, fmt.Errorf("failed to quarantine %s %s: %w", kind, name, err)
+		}
+	}
+
+	p.mu.Lock()
+	p.reports[name] = report
+	p.mu.Unlock()
+
+	if !report.Passed {
+		return report, &RejectedError{Report: report}
+	}
+	return report, nil
+}
+
+// quarantine moves a rejected artifact into the quarantine directory, with
+// its report alongside, and returns where it was moved
+func (p *Pipeline) quarantine(report *Report) (string, error) {
+	dir := p.cfg.QuarantineDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(report.Path), DefaultQuarantineDir)
+	}
+	dir = filepath.Join(dir, fmt.Sprintf("%s-%s", report.ScannedAt.UTC().Format("20060102T150405Z"), sanitize(report.Name)))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(dir, filepath.Base(report.Path))
+	if err := os.Rename(report.Path, target); err != nil {
+		return "", err
+	}
+	// Quarantined files must not be loaded by accident
+	if err := filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return os.Chmod(path, 0o400)
+	}); err != nil {
+		return target, err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return target, err
+	}
+	return target, os.WriteFile(filepath.Join(dir, "scan-report.json"), data, 0o600)
+}
+
+// sanitize makes a model name, such as library/llama3:8b, usable in a path
+func sanitize(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// hashFiles returns the regular files under path, or path itself, with
+// their SHA-256 digests
+func hashFiles(ctx context.Context, path string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", file)
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		size, digest, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Size: size, SHA256: digest})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
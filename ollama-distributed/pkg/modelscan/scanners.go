@@ -0,0 +1,297 @@
+package modelscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HashBlocklist rejects files whose SHA-256 digest is blocked
+type HashBlocklist struct {
+	blocked map[string]bool
+}
+
+// NewHashBlocklist blocks hashes and those listed in file, one per line
+// with # starting comments; file is optional
+func NewHashBlocklist(hashes []string, file string) (*HashBlocklist, error) {
+	b := &HashBlocklist{blocked: make(map[string]bool)}
+	for _, hash := range hashes {
+		b.blocked[strings.ToLower(hash)] = true
+	}
+	if file == "" {
+		return b, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(scanner.Text(), "#")
+		hash = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(hash), "sha256:")))
+		if hash == "" {
+			continue
+		}
+		if !validHash(hash) {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-256 digest %q", file, line, hash)
+		}
+		b.blocked[hash] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return b, nil
+}
+
+// Name implements Scanner
+func (b *HashBlocklist) Name() string { return "hash-blocklist" }
+
+// Scan implements Scanner
+func (b *HashBlocklist) Scan(_ context.Context, artifact Artifact) ([]string, error) {
+	var findings []string
+	for _, f := range artifact.Files {
+		if b.blocked[f.SHA256] {
+			findings = append(findings, fmt.Sprintf("%s has blocked digest sha256:%s", f.Path, f.SHA256))
+		}
+	}
+	return findings, nil
+}
+
+// File types the file type scanner tells apart
+const (
+	TypeGGUF        = "gguf"
+	TypeGGML        = "ggml"
+	TypeSafetensors = "safetensors"
+	TypeJSON        = "json"
+	TypeText        = "text"
+	TypeExecutable  = "executable"
+	TypeScript      = "script"
+	TypePickle      = "pickle"
+	TypeArchive     = "archive"
+	TypeUnknown     = "unknown"
+)
+
+func knownType(t string) bool {
+	switch t {
+	case TypeGGUF, TypeGGML, TypeSafetensors, TypeJSON, TypeText, TypeExecutable, TypeScript, TypePickle, TypeArchive, TypeUnknown:
+		return true
+	}
+	return false
+}
+
+// sniffBytes is how much of a file its type is detected from
+const sniffBytes = 512
+
+// DetectType returns the type of a file from its first bytes
+func DetectType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("GGUF")):
+		return TypeGGUF
+	case bytes.HasPrefix(head, []byte("lmgg")), bytes.HasPrefix(head, []byte("fmgg")), bytes.HasPrefix(head, []byte("tjgg")):
+		return TypeGGML
+	case bytes.HasPrefix(head, []byte("\x7fELF")), bytes.HasPrefix(head, []byte("MZ")),
+		bytes.HasPrefix(head, []byte{0xcf, 0xfa, 0xed, 0xfe}), bytes.HasPrefix(head, []byte{0xce, 0xfa, 0xed, 0xfe}),
+		bytes.HasPrefix(head, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		return TypeExecutable
+	case bytes.HasPrefix(head, []byte("#!")):
+		return TypeScript
+	case len(head) >= 2 && head[0] == 0x80 && head[1] >= 2 && head[1] <= 5:
+		// Pickle protocols 2 to 5, as PyTorch checkpoints use
+		return TypePickle
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return TypeArchive
+	case isSafetensors(head):
+		return TypeSafetensors
+	}
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) && isText(head) {
+		return TypeJSON
+	}
+	if isText(head) {
+		return TypeText
+	}
+	return TypeUnknown
+}
+
+// isSafetensors reports whether head starts with the little endian length
+// of a JSON header, as safetensors files do
+func isSafetensors(head []byte) bool {
+	if len(head) < 9 {
+		return false
+	}
+	n := binary.LittleEndian.Uint64(head)
+	return n > 1 && n < 100<<20 && head[8] == '{'
+}
+
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	// The sniffed bytes may end within a multi-byte character
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
+
+// FileTypeScanner rejects files of types that are not allowed, such as
+// executables and pickles that run code when loaded
+type FileTypeScanner struct {
+	allowed map[string]bool
+}
+
+// NewFileTypeScanner allows the given file types
+func NewFileTypeScanner(allowed []string) *FileTypeScanner {
+	s := &FileTypeScanner{allowed: make(map[string]bool)}
+	for _, t := range allowed {
+		s.allowed[t] = true
+	}
+	return s
+}
+
+// Name implements Scanner
+func (s *FileTypeScanner) Name() string { return "file-type" }
+
+// Scan implements Scanner
+func (s *FileTypeScanner) Scan(_ context.Context, artifact Artifact) ([]string, error) {
+	var findings []string
+	for _, f := range artifact.Files {
+		head, err := readHead(artifact.abs(f))
+		if err != nil {
+			return findings, err
+		}
+		if t := DetectType(head); !s.allowed[t] {
+			findings = append(findings, fmt.Sprintf("%s is of disallowed type %s", f.Path, t))
+		}
+	}
+	return findings, nil
+}
+
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// CommandConfig configures an external scanner. The command is run with
+// {path}, {name} and {kind} in its arguments replaced by the artifact's;
+// without {path} the path is appended. Exit status 0 passes the artifact,
+// 1 rejects it with the output as findings and others fail the scan.
+type CommandConfig struct {
+	Name    string        `yaml:"name"`
+	Command []string      `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// DefaultCommandTimeout bounds external scanners without a timeout
+const DefaultCommandTimeout = 10 * time.Minute
+
+// maxFindings caps the output lines of a command kept as findings
+const maxFindings = 20
+
+// Validate checks the command is named and set
+func (c CommandConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("scan command without a name")
+	}
+	if len(c.Command) == 0 {
+		return fmt.Errorf("scan command %s has no command", c.Name)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("scan command %s has a negative timeout", c.Name)
+	}
+	return nil
+}
+
+func (c CommandConfig) withDefaults() CommandConfig {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultCommandTimeout
+	}
+	return c
+}
+
+// CommandScanner runs an external scanner, e.g. a malware or secret
+// scanner, on artifacts
+type CommandScanner struct {
+	cfg CommandConfig
+}
+
+// NewCommandScanner creates a scanner running a command
+func NewCommandScanner(cfg CommandConfig) *CommandScanner {
+	return &CommandScanner{cfg: cfg.withDefaults()}
+}
+
+// Name implements Scanner
+func (s *CommandScanner) Name() string { return s.cfg.Name }
+
+// Scan implements Scanner
+func (s *CommandScanner) Scan(ctx context.Context, artifact Artifact) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	args := make([]string, 0, len(s.cfg.Command)+1)
+	hasPath := false
+	for _, arg := range s.cfg.Command[1:] {
+		hasPath = hasPath || strings.Contains(arg, "{path}")
+		args = append(args, strings.NewReplacer("{path}", artifact.Path, "{name}", artifact.Name, "{kind}", artifact.Kind).Replace(arg))
+	}
+	if !hasPath {
+		args = append(args, artifact.Path)
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.cfg.Command[0], args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s", s.cfg.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		findings := outputLines(output.String())
+		if len(findings) == 0 {
+			findings = []string{"rejected by " + s.cfg.Name}
+		}
+		return findings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil, nil
+}
+
+// outputLines returns the non-empty lines of a command's output, capped
+func outputLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if len(lines) == maxFindings {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,389 @@
+// Package moderation checks prompts and generated responses with a
+// moderation model served by the cluster, e.g. a small guard model. The
+// model answers safe, or unsafe followed by the categories the content
+// violates; by the policy of the caller's tenant flagged content is then
+// blocked or annotated. The latency of the checks is tracked apart from
+// that of inference, and callers with a trusted API key bypass them.
+package moderation
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages content is checked at
+const (
+	StagePrompt   = "prompt"
+	StageResponse = "response"
+)
+
+// Actions taken on flagged content
+const (
+	ActionBlock    = "block"
+	ActionAnnotate = "annotate"
+	ActionOff      = "off"
+)
+
+// Policies on the moderation model failing
+const (
+	OnErrorAllow = "allow"
+	OnErrorBlock = "block"
+)
+
+// DefaultTimeout bounds a check when no timeout is configured
+const DefaultTimeout = 10 * time.Second
+
+// DefaultSystem instructs the moderation model when no system prompt is
+// configured
+const DefaultSystem = `You are a content moderator. Classify the content you are given.
+If it is acceptable, answer with the single word safe.
+Otherwise answer unsafe on the first line, and on the second line the categories it violates, comma separated, from: violence, hate, harassment, sexual, self_harm, illegal, other.`
+
+// latencyWindow is the number of recent checks latency percentiles are
+// computed over
+const latencyWindow = 1024
+
+// ErrUnrecognized is returned when the moderation model answers neither
+// safe nor unsafe
+var ErrUnrecognized = errors.New("unrecognized moderation verdict")
+
+// Config configures the moderation stage
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Model is the moderation model, which must be served by the cluster
+	Model string `yaml:"model"`
+	// System overrides the instructions given to the model
+	System string `yaml:"system"`
+	// Default is the policy of tenants without a policy of their own
+	Default Policy `yaml:"default"`
+	// Tenants are policies replacing the default one for a tenant
+	Tenants map[string]Policy `yaml:"tenants"`
+	// Timeout bounds a check, 10s by default
+	Timeout time.Duration `yaml:"timeout"`
+	// OnError is allow (default), letting content through unchecked when
+	// the model fails, or block
+	OnError string `yaml:"on_error" mapstructure:"on_error"`
+	// BypassAPIKeys are the trusted API keys whose requests are not
+	// checked
+	BypassAPIKeys []string `yaml:"bypass_api_keys" mapstructure:"bypass_api_keys"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.Model == "" {
+		return fmt.Errorf("moderation model is required")
+	}
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+	for tenant, policy := range c.Tenants {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("policy of tenant %s: %w", tenant, err)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if c.OnError != "" && c.OnError != OnErrorAllow && c.OnError != OnErrorBlock {
+		return fmt.Errorf("on_error must be %s or %s", OnErrorAllow, OnErrorBlock)
+	}
+	return nil
+}
+
+// Policy is what is done with flagged content
+type Policy struct {
+	// Prompts is the action on flagged prompts: block (default), annotate
+	// or off, which does not check prompts
+	Prompts string `yaml:"prompts" json:"prompts,omitempty"`
+	// Responses is the action on flagged responses: annotate (default),
+	// block or off
+	Responses string `yaml:"responses" json:"responses,omitempty"`
+	// Categories are the categories acted on, every one when empty
+	Categories []string `yaml:"categories" json:"categories,omitempty"`
+}
+
+// Validate checks the actions
+func (p Policy) Validate() error {
+	for _, action := range []string{p.Prompts, p.Responses} {
+		switch action {
+		case "", ActionBlock, ActionAnnotate, ActionOff:
+		default:
+			return fmt.Errorf("action must be %s, %s or %s", ActionBlock, ActionAnnotate, ActionOff)
+		}
+	}
+	return nil
+}
+
+// Action returns the action of the policy at a stage, defaults applied
+func (p Policy) Action(stage string) string {
+	if stage == StagePrompt {
+		if p.Prompts == "" {
+			return ActionBlock
+		}
+		return p.Prompts
+	}
+	if p.Responses == "" {
+		return ActionAnnotate
+	}
+	return p.Responses
+}
+
+// Verdict is the outcome of checking content
+type Verdict struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"`
+	// Action is taken on flagged content
+	Action string `json:"action,omitempty"`
+	// Error is why the content could not be checked
+	Error string `json:"error,omitempty"`
+}
+
+// Blocked reports whether the content is blocked
+func (v Verdict) Blocked() bool {
+	return v.Flagged && v.Action == ActionBlock
+}
+
+// Classifier runs the moderation model; the generators of the cluster
+// implement it
+type Classifier interface {
+	Generate(ctx context.Context, model, system, prompt string, options map[string]interface{}) (string, error)
+}
+
+// StageStats are the counters and latencies of the checks of a stage
+type StageStats struct {
+	Checks  int64 `json:"checks"`
+	Flagged int64 `json:"flagged"`
+	Blocked int64 `json:"blocked"`
+	Errors  int64 `json:"errors"`
+	// MeanLatencyMs and P95LatencyMs are those of the recent checks
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+	P95LatencyMs  float64 `json:"p95_latency_ms"`
+}
+
+// Stats are the counters of the moderation stage
+type Stats struct {
+	Model    string                `json:"model"`
+	Bypassed int64                 `json:"bypassed"`
+	Stages   map[string]StageStats `json:"stages"`
+}
+
+// stageStats accumulates the stats of a stage
+type stageStats struct {
+	StageStats
+	latencies []float64
+	next      int
+}
+
+// Moderator checks content with the moderation model
+type Moderator struct {
+	cfg        Config
+	classifier Classifier
+
+	mu       sync.RWMutex
+	bypassed int64
+	stages   map[string]*stageStats
+}
+
+// NewModerator creates a moderator running the model of cfg on classifier
+func NewModerator(cfg Config, classifier Classifier) (*Moderator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.System == "" {
+		cfg.System = DefaultSystem
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.OnError == "" {
+		cfg.OnError = OnErrorAllow
+	}
+	return &Moderator{
+		cfg:        cfg,
+		classifier: classifier,
+		stages: map[string]*stageStats{
+			StagePrompt:   {},
+			StageResponse: {},
+		},
+	}, nil
+}
+
+// Bypass reports whether a request with the API key skips moderation, and
+// counts it if so
+func (m *Moderator) Bypass(apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	for _, trusted := range m.cfg.BypassAPIKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(trusted)) == 1 {
+			m.mu.Lock()
+			m.bypassed++
+			m.mu.Unlock()
+			return true
+		}
+	}
+	return false
+}
+
+// Policy returns the policy of a tenant
+func (m *Moderator) Policy(tenant string) Policy {
+	if policy, ok := m.cfg.Tenants[tenant]; ok && tenant != "" {
+		return policy
+	}
+	return m.cfg.Default
+}
+
+// Blocks reports whether content at a stage may be blocked for the tenant:
+// flagged content is, or the stage is checked and errors block
+func (m *Moderator) Blocks(tenant, stage string) bool {
+	action := m.Policy(tenant).Action(stage)
+	return action == ActionBlock || (action != ActionOff && m.cfg.OnError == OnErrorBlock)
+}
+
+// Policies returns the default policy and the tenant policies
+func (m *Moderator) Policies() (Policy, map[string]Policy) {
+	return m.cfg.Default, m.cfg.Tenants
+}
+
+// Check checks content at a stage by the policy of the tenant. Stages the
+// policy turns off are not checked. When the model fails, the error is
+// returned along with a verdict flagging the content if errors block.
+func (m *Moderator) Check(ctx context.Context, tenant, stage, text string) (Verdict, error) {
+	policy := m.Policy(tenant)
+	action := policy.Action(stage)
+	if action == ActionOff || strings.TrimSpace(text) == "" {
+		return Verdict{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+	prefix := "User message:\n"
+	if stage == StageResponse {
+		prefix = "Assistant response:\n"
+	}
+	start := time.Now()
+	answer, err := m.classifier.Generate(ctx, m.cfg.Model, m.cfg.System, prefix+text, map[string]interface{}{"temperature": 0})
+	latency := time.Since(start)
+
+	var verdict Verdict
+	if err == nil {
+		verdict, err = parseVerdict(answer)
+	}
+	if err != nil {
+		err = fmt.Errorf("moderation model %s failed: %w", m.cfg.Model, err)
+		verdict = Verdict{Error: err.Error()}
+		if m.cfg.OnError == OnErrorBlock {
+			verdict.Flagged = true
+		}
+	} else if verdict.Flagged && !actsOn(policy.Categories, verdict.Categories) {
+		verdict.Flagged = false
+	}
+	if verdict.Flagged {
+		verdict.Action = action
+		if err != nil {
+			verdict.Action = ActionBlock
+		}
+	}
+	m.record(stage, verdict, err, latency)
+	return verdict, err
+}
+
+// Stats returns the counters of the moderation stage
+func (m *Moderator) Stats() Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := Stats{Model: m.cfg.Model, Bypassed: m.bypassed, Stages: make(map[string]StageStats, len(m.stages))}
+	for stage, s := range m.stages {
+		stageStats := s.StageStats
+		if len(s.latencies) > 0 {
+			latencies := append([]float64(nil), s.latencies...)
+			sort.Float64s(latencies)
+			sum := 0.0
+			for _, l := range latencies {
+				sum += l
+			}
+			rank := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+			stageStats.MeanLatencyMs = sum / float64(len(latencies))
+			stageStats.P95LatencyMs = latencies[max(rank, 0)]
+		}
+		stats.Stages[stage] = stageStats
+	}
+	return stats
+}
+
+// record counts a check
+func (m *Moderator) record(stage string, verdict Verdict, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stages[stage]
+	s.Checks++
+	if err != nil {
+		s.Errors++
+	}
+	if verdict.Flagged {
+		s.Flagged++
+	}
+	if verdict.Blocked() {
+		s.Blocked++
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	if len(s.latencies) < latencyWindow {
+		s.latencies = append(s.latencies, ms)
+	} else {
+		s.latencies[s.next] = ms
+		s.next = (s.next + 1) % latencyWindow
+	}
+}
+
+// parseVerdict parses the answer of the model: safe, or unsafe followed by
+// the violated categories on the same or the next lines
+func parseVerdict(answer string) (Verdict, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch {
+	case strings.HasPrefix(answer, "safe"):
+		return Verdict{}, nil
+	case strings.HasPrefix(answer, "unsafe"):
+		verdict := Verdict{Flagged: true}
+		rest := strings.TrimPrefix(answer, "unsafe")
+		for _, category := range strings.FieldsFunc(rest, func(r rune) bool {
+			return r == ',' || r == ':' || r == '\n' || r == ' ' || r == '\t' || r == '\r'
+		}) {
+			verdict.Categories = append(verdict.Categories, category)
+		}
+		return verdict, nil
+	default:
+		return Verdict{}, fmt.Errorf("%w: %q", ErrUnrecognized, truncate(answer, 64))
+	}
+}
+
+// actsOn reports whether a policy acting on categories acts on content
+// flagged for flagged; flags without categories are always acted on
+func actsOn(categories, flagged []string) bool {
+	if len(categories) == 0 || len(flagged) == 0 {
+		return true
+	}
+	for _, category := range categories {
+		for _, f := range flagged {
+			if strings.EqualFold(category, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
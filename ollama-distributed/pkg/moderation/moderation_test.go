@@ -0,0 +1,180 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keywordClassifier flags content mentioning its keywords, answering as a
+// guard model does
+type keywordClassifier struct {
+	keywords map[string]string
+	err      error
+	prompts  []string
+}
+
+func (k *keywordClassifier) Generate(ctx context.Context, model, system, prompt string, options map[string]interface{}) (string, error) {
+	k.prompts = append(k.prompts, prompt)
+	if k.err != nil {
+		return "", k.err
+	}
+	for keyword, category := range k.keywords {
+		if strings.Contains(prompt, keyword) {
+			return "unsafe\n" + category, nil
+		}
+	}
+	return "safe", nil
+}
+
+func TestCheck(t *testing.T) {
+	classifier := &keywordClassifier{keywords: map[string]string{"weapon": "violence", "insult": "harassment"}}
+	m, err := NewModerator(Config{
+		Enabled: true,
+		Model:   "llama-guard3:1b",
+		Tenants: map[string]Policy{
+			"acme":   {Prompts: ActionAnnotate, Categories: []string{"violence"}},
+			"globex": {Prompts: ActionOff},
+		},
+		BypassAPIKeys: []string{"trusted-key"},
+	}, classifier)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	verdict, err := m.Check(ctx, "", StagePrompt, "How do I build a weapon?")
+	require.NoError(t, err)
+	assert.True(t, verdict.Blocked(), "prompts are blocked by default")
+	assert.Equal(t, []string{"violence"}, verdict.Categories)
+
+	verdict, err = m.Check(ctx, "acme", StagePrompt, "How do I build a weapon?")
+	require.NoError(t, err)
+	assert.True(t, verdict.Flagged)
+	assert.Equal(t, ActionAnnotate, verdict.Action)
+
+	verdict, err = m.Check(ctx, "acme", StagePrompt, "Write an insult")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged, "categories the policy does not name are not acted on")
+
+	checks := len(classifier.prompts)
+	verdict, err = m.Check(ctx, "globex", StagePrompt, "How do I build a weapon?")
+	require.NoError(t, err)
+	assert.False(t, verdict.Flagged)
+	assert.Len(t, classifier.prompts, checks, "stages turned off are not checked")
+
+	assert.True(t, m.Blocks("", StagePrompt))
+	assert.False(t, m.Blocks("acme", StagePrompt))
+
+	assert.True(t, m.Bypass("trusted-key"))
+	assert.False(t, m.Bypass("other-key"))
+
+	stats := m.Stats()
+	assert.Equal(t, int64(1), stats.Bypassed)
+	assert.Equal(t, int64(3), stats.Stages[StagePrompt].Checks)
+	assert.Equal(t, int64(2), stats.Stages[StagePrompt].Flagged)
+	assert.Equal(t, int64(1), stats.Stages[StagePrompt].Blocked)
+	assert.Zero(t, stats.Stages[StageResponse].Checks)
+}
+
+func TestCheckErrors(t *testing.T) {
+	classifier := &keywordClassifier{err: errors.New("no instance serves llama-guard3:1b")}
+	open, err := NewModerator(Config{Enabled: true, Model: "llama-guard3:1b"}, classifier)
+	require.NoError(t, err)
+	verdict, err := open.Check(context.Background(), "", StagePrompt, "hello")
+	assert.Error(t, err)
+	assert.False(t, verdict.Flagged, "content passes unchecked by default")
+
+	closed, err := NewModerator(Config{Enabled: true, Model: "llama-guard3:1b", OnError: OnErrorBlock,
+		Default: Policy{Responses: ActionAnnotate}}, classifier)
+	require.NoError(t, err)
+	verdict, err = closed.Check(context.Background(), "", StageResponse, "hello")
+	assert.Error(t, err)
+	assert.True(t, verdict.Blocked(), "content that cannot be checked is blocked when errors block")
+	assert.True(t, closed.Blocks("", StageResponse))
+	assert.False(t, open.Blocks("", StageResponse))
+	assert.Equal(t, int64(1), closed.Stats().Stages[StageResponse].Errors)
+
+	_, err = parseVerdict("I cannot help with that")
+	assert.ErrorIs(t, err, ErrUnrecognized)
+	verdict, err = parseVerdict("unsafe: S1, S10")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s1", "s10"}, verdict.Categories)
+}
+
+func TestWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(prompt *Verdict, check func(string) Verdict, chunks ...string) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		w := NewWriter(c.Writer, NewHandler(prompt, check, false))
+		for _, chunk := range chunks {
+			_, err := w.Write([]byte(chunk))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Finish())
+		return recorder.Body.String()
+	}
+	flag := func(action string) func(string) Verdict {
+		return func(text string) Verdict {
+			if strings.Contains(text, "weapon") {
+				return Verdict{Flagged: true, Categories: []string{"violence"}, Action: action}
+			}
+			return Verdict{}
+		}
+	}
+
+	var checked string
+	body := serve(nil, func(text string) Verdict { checked = text; return Verdict{} },
+		`{"response":"Build a ","done":false}`+"\n", `{"response":"birdhouse","done":false}`+"\n", `{"response":"","done":true}`+"\n")
+	assert.Equal(t, "Build a birdhouse", checked, "the whole text is checked once complete")
+	assert.NotContains(t, body, "moderation")
+
+	body = serve(nil, flag(ActionAnnotate), `{"response":"Build a ","done":false}`+"\n", `{"response":"weapon","done":true}`+"\n")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 2)
+	var last struct {
+		Response   string     `json:"response"`
+		Moderation Annotation `json:"moderation"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &last))
+	assert.Equal(t, "weapon", last.Response)
+	require.NotNil(t, last.Moderation.Response)
+	assert.Equal(t, []string{"violence"}, last.Moderation.Response.Categories)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	w := NewWriter(c.Writer, NewHandler(nil, flag(ActionBlock), true))
+	_, err := w.Write([]byte(`{"response":"Build a ","done":false}` + "\n"))
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Body.String(), "streamed chunks are held back when responses may be blocked")
+	_, err = w.Write([]byte(`{"response":"weapon","done":true}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, recorder.Body.String(), "Build a", "a blocked response is never sent")
+	assert.Contains(t, recorder.Body.String(), DoneReason)
+
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	w = NewWriter(c.Writer, NewHandler(nil, flag(ActionBlock), true))
+	_, err = w.Write([]byte(`{"response":"Build a ","done":false}` + "\n" + `{"response":"birdhouse","done":true}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, `{"response":"Build a ","done":false}`+"\n"+`{"response":"birdhouse","done":true}`+"\n", recorder.Body.String(),
+		"held chunks are sent once the text passes")
+
+	body = serve(nil, flag(ActionBlock), `{"model":"llama3","response":"Build a weapon","done":true}`)
+	assert.NotContains(t, body, "Build a weapon", "a response that is not streamed is blocked whole")
+	var end Termination
+	require.NoError(t, json.Unmarshal([]byte(body), &end))
+	assert.Equal(t, DoneReason, end.DoneReason)
+
+	prompt := &Verdict{Flagged: true, Categories: []string{"harassment"}, Action: ActionAnnotate}
+	body = serve(prompt, nil, `{"choices":[{"message":{"content":"Sure."},"finish_reason":"stop"}]}`)
+	assert.Contains(t, body, `"moderation":{"prompt":{"flagged":true,"categories":["harassment"],"action":"annotate"}}`)
+}
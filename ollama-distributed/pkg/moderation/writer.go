@@ -0,0 +1,108 @@
+package moderation
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+)
+
+// DoneReason is the done_reason of a response blocked by moderation
+const DoneReason = "moderation_blocked"
+
+// Annotation is added as moderation to the last chunk of a response whose
+// prompt or text was flagged
+type Annotation struct {
+	Prompt   *Verdict `json:"prompt,omitempty"`
+	Response *Verdict `json:"response,omitempty"`
+}
+
+// Termination replaces the last chunk of a blocked response, in the format
+// of the final chunk of an Ollama stream
+type Termination struct {
+	Done       bool       `json:"done"`
+	DoneReason string     `json:"done_reason"`
+	Error      string     `json:"error"`
+	Moderation Annotation `json:"moderation"`
+}
+
+// Handler checks the text of a response once it is complete, as a
+// textstream.Writer passes on its last chunk. When flagged responses are
+// blocked, the chunks of a streamed response are held back until the text
+// is checked, so a blocked response is never sent; otherwise they are
+// passed on as they come, and a flagged response is annotated at its end.
+type Handler struct {
+	prompt   *Verdict
+	check    func(text string) Verdict
+	holdBack bool
+
+	mu       sync.Mutex
+	text     strings.Builder
+	response *Verdict
+}
+
+// NewHandler creates a handler annotating the response with the verdict
+// on its prompt when flagged and checking its text with check when set.
+// holdBack holds streamed chunks back until the check, as needed when the
+// check may block the response.
+func NewHandler(prompt *Verdict, check func(text string) Verdict, holdBack bool) *Handler {
+	return &Handler{prompt: prompt, check: check, holdBack: holdBack}
+}
+
+// Chunk collects the text of a chunk; the last one is checked and
+// annotated or replaced
+func (h *Handler) Chunk(chunk map[string]interface{}, last bool) textstream.Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.text.WriteString(textstream.Text(chunk))
+	if !last {
+		return textstream.Result{Hold: h.holdBack && h.check != nil}
+	}
+
+	if h.check != nil {
+		verdict := h.check(h.text.String())
+		h.response = &verdict
+	}
+	annotation := Annotation{Prompt: h.prompt}
+	if h.response != nil && (h.response.Flagged || h.response.Error != "") {
+		annotation.Response = h.response
+	}
+	switch {
+	case h.response != nil && h.response.Blocked():
+		return textstream.Result{End: Termination{
+			Done:       true,
+			DoneReason: DoneReason,
+			Error:      "response flagged by moderation",
+			Moderation: annotation,
+		}}
+	case annotation.Prompt != nil || annotation.Response != nil:
+		chunk["moderation"] = annotation
+		return textstream.Result{Changed: true}
+	}
+	return textstream.Result{}
+}
+
+// Response returns the verdict on the response text, once checked
+func (h *Handler) Response() *Verdict {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.response
+}
+
+// Writer checks the text of a gin handler's response with a Handler
+type Writer struct {
+	*textstream.ResponseWriter
+	handler *Handler
+}
+
+// NewWriter wraps w, passing the response through handler
+func NewWriter(w gin.ResponseWriter, handler *Handler) *Writer {
+	return &Writer{ResponseWriter: textstream.NewResponseWriter(w, handler), handler: handler}
+}
+
+// Response returns the verdict on the response text, once checked
+func (w *Writer) Response() *Verdict {
+	return w.handler.Response()
+}
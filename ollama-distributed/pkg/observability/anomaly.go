@@ -0,0 +1,418 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Anomaly kinds
+const (
+	AnomalyLatency = "latency"
+	AnomalyErrors  = "errors"
+)
+
+// minLatencySpread is the smallest spread of the mean log latency of a
+// window a baseline assumes, so that very steady series do not alert on
+// changes of a few percent
+const minLatencySpread = 0.05
+
+// minErrorRate is the smallest error rate a baseline assumes
+const minErrorRate = 0.001
+
+// AnomalyConfig configures the anomaly detector
+type AnomalyConfig struct {
+	// Interval is the length of the windows requests are judged in
+	Interval time.Duration
+	// MinSamples is how many requests a window needs to be judged
+	MinSamples int
+	// LearningWindows is how many windows a baseline learns from before
+	// it is used
+	LearningWindows int
+	// Threshold is how many standard deviations from its baseline a window
+	// must be to be anomalous
+	Threshold float64
+	// Smoothing is the weight of a new window in the baseline
+	Smoothing float64
+	// AdaptAfter is how many anomalous windows in a row are taken as the
+	// new normal and learned
+	AdaptAfter int
+	// MaxAnomalies is how many anomalies are kept
+	MaxAnomalies int
+}
+
+// Anomaly is a shift of the latency or error rate of a model on a node away
+// from its baseline. End is zero while it lasts.
+type Anomaly struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Model   string    `json:"model"`
+	NodeID  string    `json:"node_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end,omitempty"`
+	Message string    `json:"message"`
+	// Observed and Expected are the geometric mean latency in milliseconds
+	// or the error rate of the window and of the baseline
+	Observed float64 `json:"observed"`
+	Expected float64 `json:"expected"`
+	// Score is how many standard deviations the window was from the
+	// baseline
+	Score   float64 `json:"score"`
+	Samples int64   `json:"samples"`
+}
+
+// AnomalyBaseline is the normal behaviour learned for a model on a node
+type AnomalyBaseline struct {
+	Model     string  `json:"model"`
+	NodeID    string  `json:"node_id"`
+	Windows   int     `json:"windows"`
+	Learning  bool    `json:"learning"`
+	LatencyMs float64 `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// anomalyKey identifies the requests of a model on a node
+type anomalyKey struct {
+	model, node string
+}
+
+// anomalySeries is the current window and baseline of a key
+type anomalySeries struct {
+	// Current window
+	count, failures int64
+	sumLogLatency   float64
+
+	// Baseline: exponentially weighted mean and variance of the mean log
+	// latency of windows, and mean error rate
+	windows        int
+	latencyLearned bool
+	latencyMean    float64
+	latencyVar     float64
+	errorRate      float64
+
+	streak int
+	active map[string]*Anomaly
+}
+
+// AnomalyDetector learns the normal latency and error rate of every model
+// on every node, and alerts when a window of requests shifts significantly
+// from them. Only shifts for the worse are reported.
+type AnomalyDetector struct {
+	config   *AnomalyConfig
+	sink     SLOAlertSink
+	listener func(*Anomaly)
+
+	mu        sync.Mutex
+	series    map[anomalyKey]*anomalySeries
+	anomalies []*Anomaly
+	now       func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewAnomalyDetector creates an anomaly detector. Alerts are sent to sink,
+// which may be nil.
+func NewAnomalyDetector(config *AnomalyConfig, sink SLOAlertSink) *AnomalyDetector {
+	if config == nil {
+		config = &AnomalyConfig{}
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 20
+	}
+	if config.LearningWindows <= 0 {
+		config.LearningWindows = 30
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 4
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = 0.05
+	}
+	if config.AdaptAfter <= 0 {
+		config.AdaptAfter = 30
+	}
+	if config.MaxAnomalies <= 0 {
+		config.MaxAnomalies = 200
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AnomalyDetector{
+		config: config,
+		sink:   sink,
+		series: make(map[anomalyKey]*anomalySeries),
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// SetListener calls listener with every anomaly detected, e.g. to record
+// it as an event
+func (d *AnomalyDetector) SetListener(listener func(*Anomaly)) {
+	d.mu.Lock()
+	d.listener = listener
+	d.mu.Unlock()
+}
+
+// Start starts judging windows every interval
+func (d *AnomalyDetector) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-ticker.C:
+				d.Evaluate()
+			}
+		}
+	}()
+	log.Info().Dur("interval", d.config.Interval).Msg("Anomaly detector started")
+}
+
+// Stop stops judging windows
+func (d *AnomalyDetector) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Record adds a completed inference request to the current window of its
+// model and node
+func (d *AnomalyDetector) Record(obs InferenceObservation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := anomalyKey{obs.ModelName, obs.NodeID}
+	s := d.series[key]
+	if s == nil {
+		s = &anomalySeries{active: make(map[string]*Anomaly)}
+		d.series[key] = s
+	}
+	s.count++
+	if obs.Failed {
+		s.failures++
+		return
+	}
+	// Latency is judged on successful requests, whose log is closer to
+	// normally distributed than the latency itself
+	s.sumLogLatency += math.Log(math.Max(obs.Duration.Seconds(), 1e-3))
+}
+
+// Evaluate judges the current window of every model and node against its
+// baseline, learns the normal windows and returns the anomalies that
+// started
+func (d *AnomalyDetector) Evaluate() []*Anomaly {
+	d.mu.Lock()
+	now := d.now()
+	var started []*Anomaly
+	for key, s := range d.series {
+		started = append(started, d.evaluate(key, s, now)...)
+	}
+	listener := d.listener
+	d.mu.Unlock()
+
+	for _, anomaly := range started {
+		d.alert(anomaly)
+		if listener != nil {
+			listener(anomaly)
+		}
+	}
+	return started
+}
+
+// evaluate judges the window of a key and starts a new one; d.mu must be
+// held
+func (d *AnomalyDetector) evaluate(key anomalyKey, s *anomalySeries, now time.Time) []*Anomaly {
+	count, failures, sumLog := s.count, s.failures, s.sumLogLatency
+	s.count, s.failures, s.sumLogLatency = 0, 0, 0
+	if count < int64(d.config.MinSamples) {
+		return nil
+	}
+
+	succeeded := count - failures
+	errorRate := float64(failures) / float64(count)
+	var meanLog float64
+	if succeeded > 0 {
+		meanLog = sumLog / float64(succeeded)
+	}
+
+	firing := make(map[string]*Anomaly)
+	if s.windows >= d.config.LearningWindows {
+		if succeeded > 0 && s.latencyLearned {
+			spread := math.Max(math.Sqrt(s.latencyVar), minLatencySpread)
+			if score := (meanLog - s.latencyMean) / spread; score >= d.config.Threshold {
+				observed, expected := math.Exp(meanLog)*1000, math.Exp(s.latencyMean)*1000
+				firing[AnomalyLatency] = &Anomaly{
+					Kind:     AnomalyLatency,
+					Message:  fmt.Sprintf("Latency of %s on %s is %.0fms, %.1fx its usual %.0fms", key.model, key.node, observed, observed/expected, expected),
+					Observed: observed,
+					Expected: expected,
+					Score:    score,
+				}
+			}
+		}
+		expected := math.Max(s.errorRate, minErrorRate)
+		score := (errorRate - expected) / math.Sqrt(expected*(1-expected)/float64(count))
+		if score >= d.config.Threshold && failures >= 3 {
+			firing[AnomalyErrors] = &Anomaly{
+				Kind:     AnomalyErrors,
+				Message:  fmt.Sprintf("%.1f%% of %s requests on %s failed, usually %.1f%%", errorRate*100, key.model, key.node, s.errorRate*100),
+				Observed: errorRate,
+				Expected: s.errorRate,
+				Score:    score,
+			}
+		}
+	}
+
+	var started []*Anomaly
+	for kind, anomaly := range s.active {
+		if firing[kind] == nil {
+			anomaly.End = now
+			delete(s.active, kind)
+		}
+	}
+	for kind, anomaly := range firing {
+		if active := s.active[kind]; active != nil {
+			active.Observed, active.Score = anomaly.Observed, anomaly.Score
+			active.Samples += count
+			continue
+		}
+		anomaly.ID = fmt.Sprintf("%s-%s-%s-%d", kind, key.model, key.node, now.Unix())
+		anomaly.Model = key.model
+		anomaly.NodeID = key.node
+		anomaly.Start = now
+		anomaly.Samples = count
+		s.active[kind] = anomaly
+		d.anomalies = append(d.anomalies, anomaly)
+		copied := *anomaly
+		started = append(started, &copied)
+	}
+	if len(d.anomalies) > d.config.MaxAnomalies {
+		d.anomalies = append([]*Anomaly(nil), d.anomalies[len(d.anomalies)-d.config.MaxAnomalies:]...)
+	}
+
+	// Anomalous windows are kept out of the baseline until they last long
+	// enough to be the new normal
+	if len(firing) > 0 {
+		s.streak++
+	} else {
+		s.streak = 0
+	}
+	if len(firing) == 0 || s.streak >= d.config.AdaptAfter {
+		s.learn(meanLog, succeeded > 0, errorRate, d.config.Smoothing)
+	}
+	return started
+}
+
+// learn adds a window to the baseline
+func (s *anomalySeries) learn(meanLog float64, hasLatency bool, errorRate, alpha float64) {
+	if hasLatency {
+		if !s.latencyLearned {
+			s.latencyMean = meanLog
+			s.latencyLearned = true
+		} else {
+			diff := meanLog - s.latencyMean
+			s.latencyMean += alpha * diff
+			s.latencyVar = (1 - alpha) * (s.latencyVar + alpha*diff*diff)
+		}
+	}
+	if s.windows == 0 {
+		s.errorRate = errorRate
+	} else {
+		s.errorRate += alpha * (errorRate - s.errorRate)
+	}
+	s.windows++
+}
+
+// Anomalies returns the anomalies going on at some point since a time,
+// newest first
+func (d *AnomalyDetector) Anomalies(since time.Time) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	anomalies := make([]Anomaly, 0)
+	for i := len(d.anomalies) - 1; i >= 0; i-- {
+		anomaly := d.anomalies[i]
+		if !anomaly.End.IsZero() && anomaly.End.Before(since) {
+			continue
+		}
+		anomalies = append(anomalies, *anomaly)
+	}
+	return anomalies
+}
+
+// Baselines returns the baseline of every model and node
+func (d *AnomalyDetector) Baselines() []AnomalyBaseline {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	baselines := make([]AnomalyBaseline, 0, len(d.series))
+	for key, s := range d.series {
+		baselines = append(baselines, AnomalyBaseline{
+			Model:     key.model,
+			NodeID:    key.node,
+			Windows:   s.windows,
+			Learning:  s.windows < d.config.LearningWindows,
+			LatencyMs: math.Exp(s.latencyMean) * 1000,
+			ErrorRate: s.errorRate,
+		})
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		if baselines[i].Model != baselines[j].Model {
+			return baselines[i].Model < baselines[j].Model
+		}
+		return baselines[i].NodeID < baselines[j].NodeID
+	})
+	return baselines
+}
+
+// alert logs an anomaly and sends it to the sink
+func (d *AnomalyDetector) alert(anomaly *Anomaly) {
+	log.Warn().
+		Str("kind", anomaly.Kind).
+		Str("model", anomaly.Model).
+		Str("node", anomaly.NodeID).
+		Float64("score", anomaly.Score).
+		Msg(anomaly.Message)
+
+	if d.sink == nil {
+		return
+	}
+	notification := &Notification{
+		ID:        anomaly.ID,
+		Title:     fmt.Sprintf("Anomalous %s: %s on %s", anomaly.Kind, anomaly.Model, anomaly.NodeID),
+		Message:   anomaly.Message,
+		Severity:  "warning",
+		Component: "anomaly",
+		NodeID:    anomaly.NodeID,
+		Timestamp: anomaly.Start,
+		Labels: map[string]string{
+			"kind":  anomaly.Kind,
+			"model": anomaly.Model,
+			"type":  "anomaly",
+		},
+		Metadata: map[string]interface{}{
+			"observed": anomaly.Observed,
+			"expected": anomaly.Expected,
+			"score":    anomaly.Score,
+			"samples":  anomaly.Samples,
+		},
+	}
+	if err := d.sink.SendNotification(notification); err != nil {
+		log.Error().Err(err).Str("anomaly", anomaly.ID).Msg("Failed to send anomaly alert")
+	}
+}
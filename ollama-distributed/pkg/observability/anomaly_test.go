@@ -0,0 +1,101 @@
+package observability
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAnomalyDetector(sink SLOAlertSink, now *time.Time) *AnomalyDetector {
+	d := NewAnomalyDetector(&AnomalyConfig{MinSamples: 10, LearningWindows: 10, AdaptAfter: 5}, sink)
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+// window records n requests around latency, failing those for which fail
+// returns true, and judges them
+func window(d *AnomalyDetector, now *time.Time, rng *rand.Rand, n int, latency time.Duration, fail func(i int) bool) []*Anomaly {
+	for i := 0; i < n; i++ {
+		jitter := time.Duration(rng.NormFloat64() * 0.1 * float64(latency))
+		d.Record(InferenceObservation{ModelName: "llama3", NodeID: "node-a", Duration: latency + jitter, Failed: fail(i)})
+	}
+	*now = now.Add(time.Minute)
+	return d.Evaluate()
+}
+
+func never(int) bool { return false }
+
+func TestAnomalyDetectorLatencyShift(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sink := &recordingSink{}
+	d := newTestAnomalyDetector(sink, &now)
+	var events []*Anomaly
+	d.SetListener(func(a *Anomaly) { events = append(events, a) })
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20; i++ {
+		assert.Empty(t, window(d, &now, rng, 50, time.Second, never), "window %d", i)
+	}
+	baselines := d.Baselines()
+	require.Len(t, baselines, 1)
+	assert.False(t, baselines[0].Learning)
+	assert.InDelta(t, 1000, baselines[0].LatencyMs, 50)
+
+	// Too few requests are not judged, faster ones are not reported
+	assert.Empty(t, window(d, &now, rng, 5, 5*time.Second, never))
+	assert.Empty(t, window(d, &now, rng, 50, 500*time.Millisecond, never))
+
+	started := window(d, &now, rng, 50, 3*time.Second, never)
+	require.Len(t, started, 1)
+	assert.Equal(t, AnomalyLatency, started[0].Kind)
+	assert.Equal(t, "node-a", started[0].NodeID)
+	assert.InDelta(t, 3000, started[0].Observed, 200)
+	require.Len(t, sink.notifications, 1)
+	assert.Equal(t, "anomaly", sink.notifications[0].Component)
+	require.Len(t, events, 1)
+
+	// An ongoing anomaly is reported once, and ends when latency recovers
+	assert.Empty(t, window(d, &now, rng, 50, 3*time.Second, never))
+	assert.Empty(t, window(d, &now, rng, 50, time.Second, never))
+	anomalies := d.Anomalies(now.Add(-time.Hour))
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, now, anomalies[0].End)
+	assert.Empty(t, d.Anomalies(now.Add(time.Minute)))
+}
+
+func TestAnomalyDetectorErrorShift(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := newTestAnomalyDetector(nil, &now)
+	rng := rand.New(rand.NewSource(2))
+
+	// 1 in 50 requests usually fails
+	for i := 0; i < 20; i++ {
+		assert.Empty(t, window(d, &now, rng, 50, time.Second, func(i int) bool { return i == 0 }))
+	}
+	started := window(d, &now, rng, 50, time.Second, func(i int) bool { return i%5 == 0 })
+	require.Len(t, started, 1)
+	assert.Equal(t, AnomalyErrors, started[0].Kind)
+	assert.InDelta(t, 0.2, started[0].Observed, 1e-9)
+}
+
+func TestAnomalyDetectorAdapts(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := newTestAnomalyDetector(nil, &now)
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 20; i++ {
+		window(d, &now, rng, 50, time.Second, never)
+	}
+
+	// A lasting shift becomes the new normal after AdaptAfter windows
+	require.Len(t, window(d, &now, rng, 50, 2*time.Second, never), 1)
+	for i := 0; i < 60; i++ {
+		window(d, &now, rng, 50, 2*time.Second, never)
+	}
+	assert.InDelta(t, 2000, d.Baselines()[0].LatencyMs, 200)
+	anomalies := d.Anomalies(now.Add(-2 * time.Hour))
+	require.Len(t, anomalies, 1)
+	assert.False(t, anomalies[0].End.IsZero())
+}
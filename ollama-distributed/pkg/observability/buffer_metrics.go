@@ -0,0 +1,91 @@
+package observability
+
+import (
+	"runtime/metrics"
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Runtime metrics behind the heap allocation counters
+const (
+	heapAllocBytesMetric   = "/gc/heap/allocs:bytes"
+	heapAllocObjectsMetric = "/gc/heap/allocs:objects"
+)
+
+// bufferPoolCollector exports the reuse counters of the shared buffer pools
+// next to the process heap allocation totals, so the allocation rate of the
+// hot path can be compared with and without pooling:
+//
+//	rate(ollama_distributed_buffer_pool_allocations_total[5m])
+//	  / rate(ollama_distributed_buffer_pool_gets_total[5m])
+//	rate(ollama_distributed_heap_allocated_bytes_total[5m])
+type bufferPoolCollector struct {
+	gets           *prometheus.Desc
+	allocations    *prometheus.Desc
+	allocatedBytes *prometheus.Desc
+	discards       *prometheus.Desc
+	heapBytes      *prometheus.Desc
+	heapObjects    *prometheus.Desc
+}
+
+func newBufferPoolCollector(namespace, subsystem string) *bufferPoolCollector {
+	name := func(n string) string { return prometheus.BuildFQName(namespace, subsystem, n) }
+	return &bufferPoolCollector{
+		gets: prometheus.NewDesc(name("buffer_pool_gets_total"),
+			"Buffers requested from the shared pool", []string{"pool"}, nil),
+		allocations: prometheus.NewDesc(name("buffer_pool_allocations_total"),
+			"Buffers the pool had to allocate because none could be reused", []string{"pool"}, nil),
+		allocatedBytes: prometheus.NewDesc(name("buffer_pool_allocated_bytes_total"),
+			"Bytes allocated by the pool", []string{"pool"}, nil),
+		discards: prometheus.NewDesc(name("buffer_pool_discards_total"),
+			"Buffers dropped instead of returned to the pool", []string{"pool"}, nil),
+		heapBytes: prometheus.NewDesc(name("heap_allocated_bytes_total"),
+			"Cumulative bytes allocated on the Go heap", nil, nil),
+		heapObjects: prometheus.NewDesc(name("heap_allocated_objects_total"),
+			"Cumulative objects allocated on the Go heap", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *bufferPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.gets
+	ch <- c.allocations
+	ch <- c.allocatedBytes
+	ch <- c.discards
+	ch <- c.heapBytes
+	ch <- c.heapObjects
+}
+
+// Collect implements prometheus.Collector
+func (c *bufferPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := memory.ReusePoolStats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := stats[name]
+		ch <- prometheus.MustNewConstMetric(c.gets, prometheus.CounterValue, float64(s.Gets), name)
+		ch <- prometheus.MustNewConstMetric(c.allocations, prometheus.CounterValue, float64(s.Allocations), name)
+		ch <- prometheus.MustNewConstMetric(c.allocatedBytes, prometheus.CounterValue, float64(s.AllocatedBytes), name)
+		ch <- prometheus.MustNewConstMetric(c.discards, prometheus.CounterValue, float64(s.Discards), name)
+	}
+
+	samples := []metrics.Sample{{Name: heapAllocBytesMetric}, {Name: heapAllocObjectsMetric}}
+	metrics.Read(samples)
+	for i, desc := range []*prometheus.Desc{c.heapBytes, c.heapObjects} {
+		if samples[i].Value.Kind() == metrics.KindUint64 {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(samples[i].Value.Uint64()))
+		}
+	}
+}
+
+// initializeBufferPoolMetrics registers the buffer pool collector
+func (mr *MetricsRegistry) initializeBufferPoolMetrics() {
+	config := mr.prometheusExporter.config
+	mr.prometheusExporter.GetRegistry().MustRegister(newBufferPoolCollector(config.Namespace, config.Subsystem))
+}
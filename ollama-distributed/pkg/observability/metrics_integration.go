@@ -5,7 +5,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // MetricsIntegration provides a unified interface for components to report metrics
@@ -66,8 +68,10 @@ type FaultToleranceIntegrator struct {
 
 // ModelIntegrator integrates model management metrics with Prometheus
 type ModelIntegrator struct {
-	metrics *ModelMetrics
-	nodeID  string
+	metrics         *ModelMetrics
+	nodeID          string
+	sloMonitor      *SLOMonitor
+	anomalyDetector *AnomalyDetector
 }
 
 // NewMetricsIntegration creates a new metrics integration
@@ -174,6 +178,16 @@ func (mi *MetricsIntegration) GetFaultToleranceIntegrator() *FaultToleranceInteg
 	return mi.faultToleranceIntegrator
 }
 
+// SetSLOMonitor feeds reported inference requests to monitor
+func (mi *MetricsIntegration) SetSLOMonitor(monitor *SLOMonitor) {
+	mi.modelIntegrator.sloMonitor = monitor
+}
+
+// SetAnomalyDetector feeds reported inference requests to detector
+func (mi *MetricsIntegration) SetAnomalyDetector(detector *AnomalyDetector) {
+	mi.modelIntegrator.anomalyDetector = detector
+}
+
 // GetModelIntegrator returns the model integrator
 func (mi *MetricsIntegration) GetModelIntegrator() *ModelIntegrator {
 	return mi.modelIntegrator
@@ -229,6 +243,18 @@ func (si *SchedulerIntegrator) ReportNodeUtilization(resourceType string, utiliz
 	si.metrics.NodeUtilization.WithLabelValues(si.nodeID, resourceType).Set(utilization)
 }
 
+// ReportPartitionFailure reports a failed partition of a strategy, with
+// category one of the partitioning failure categories such as no_nodes
+func (si *SchedulerIntegrator) ReportPartitionFailure(strategy, category string) {
+	si.metrics.PartitionFailures.WithLabelValues(strategy, category, si.nodeID).Inc()
+}
+
+// ReportStrategySelection reports a partition strategy selection, with
+// outcome selected when it is made and succeeded or failed on completion
+func (si *SchedulerIntegrator) ReportStrategySelection(strategy, outcome string) {
+	si.metrics.StrategySelections.WithLabelValues(strategy, outcome, si.nodeID).Inc()
+}
+
 // Consensus Integration Methods
 
 // ReportLeaderElection reports a leader election event
@@ -313,6 +339,12 @@ func (pi *P2PIntegrator) ReportPeerDiscovery(discoveryType, result string) {
 	pi.metrics.PeerDiscovery.WithLabelValues(discoveryType, result).Inc()
 }
 
+// ReportQueueDelay reports how long an outbound message waited in the
+// queue of its QoS class
+func (pi *P2PIntegrator) ReportQueueDelay(class string, delay time.Duration) {
+	pi.metrics.QueueDelay.WithLabelValues(class).Observe(delay.Seconds())
+}
+
 // API Integration Methods
 
 // ReportAPIRequest reports an API request
@@ -399,6 +431,12 @@ func (mi *ModelIntegrator) ReportModelError(modelName, errorType string) {
 	mi.metrics.ModelErrors.WithLabelValues(modelName, errorType, mi.nodeID).Inc()
 }
 
+// ReportModelFallback reports a request for a model served by a fallback
+// model
+func (mi *ModelIntegrator) ReportModelFallback(modelName, fallback, reason string) {
+	mi.metrics.ModelFallbacks.WithLabelValues(modelName, fallback, reason, mi.nodeID).Inc()
+}
+
 // ReportReplicationOperation reports a replication operation
 func (mi *ModelIntegrator) ReportReplicationOperation(operationType, modelName, status string) {
 	mi.metrics.ReplicationOperations.WithLabelValues(operationType, modelName, status).Inc()
@@ -408,3 +446,65 @@ func (mi *ModelIntegrator) ReportReplicationOperation(operationType, modelName,
 func (mi *ModelIntegrator) ReportStorageUsage(modelName, storageType string, usageBytes float64) {
 	mi.metrics.StorageUsage.WithLabelValues(modelName, mi.nodeID, storageType).Set(usageBytes)
 }
+
+// InferenceObservation describes a completed inference request
+type InferenceObservation struct {
+	ModelName   string
+	NodeID      string // node that served the request, defaults to this node
+	RequestType string // generate, chat or embed
+
+	Duration         time.Duration
+	TimeToFirstToken time.Duration // zero when the response was not streamed
+	TokensPerSecond  float64       // zero when the response carried no token counts
+	Failed           bool
+}
+
+// ReportInference records the latency histograms of an inference request.
+// When ctx carries a trace, its ID is attached to the observations as an
+// exemplar so dashboards can link from a latency bucket to the trace.
+func (mi *ModelIntegrator) ReportInference(ctx context.Context, obs InferenceObservation) {
+	nodeID := obs.NodeID
+	if nodeID == "" {
+		nodeID = mi.nodeID
+	}
+	labels := []string{obs.ModelName, nodeID, obs.RequestType}
+	traceID := traceIDFromContext(ctx)
+
+	observeWithExemplar(mi.metrics.RequestDuration.WithLabelValues(labels...), obs.Duration.Seconds(), traceID)
+	if obs.TimeToFirstToken > 0 {
+		observeWithExemplar(mi.metrics.TimeToFirstToken.WithLabelValues(labels...), obs.TimeToFirstToken.Seconds(), traceID)
+	}
+	if obs.TokensPerSecond > 0 {
+		observeWithExemplar(mi.metrics.TokensPerSecond.WithLabelValues(labels...), obs.TokensPerSecond, traceID)
+	}
+
+	if mi.sloMonitor != nil {
+		mi.sloMonitor.Record(obs)
+	}
+	if mi.anomalyDetector != nil {
+		obs.NodeID = nodeID
+		mi.anomalyDetector.Record(obs)
+	}
+}
+
+// observeWithExemplar observes value, attaching traceID as an exemplar when
+// one is known
+func observeWithExemplar(observer prometheus.Observer, value float64, traceID string) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && traceID != "" {
+		exemplarObserver.ObserveWithExemplar(value, prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	observer.Observe(value)
+}
+
+// traceIDFromContext returns the ID of the OpenTelemetry or internal trace
+// carried by ctx
+func traceIDFromContext(ctx context.Context) string {
+	if spanContext := oteltrace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.TraceID
+	}
+	return ""
+}
@@ -32,6 +32,8 @@ type SchedulerMetrics struct {
 	TaskErrors           *prometheus.CounterVec
 	LoadBalancerRequests *prometheus.CounterVec
 	NodeUtilization      *prometheus.GaugeVec
+	PartitionFailures    *prometheus.CounterVec
+	StrategySelections   *prometheus.CounterVec
 }
 
 // ConsensusMetrics contains all consensus-related metrics
@@ -53,6 +55,7 @@ type P2PMetrics struct {
 	NetworkLatency    *prometheus.HistogramVec
 	BandwidthUsage    *prometheus.GaugeVec
 	PeerDiscovery     *prometheus.CounterVec
+	QueueDelay        *prometheus.HistogramVec
 }
 
 // APIMetrics contains all API gateway metrics
@@ -83,6 +86,16 @@ type ModelMetrics struct {
 	ModelErrors           *prometheus.CounterVec
 	ReplicationOperations *prometheus.CounterVec
 	StorageUsage          *prometheus.GaugeVec
+
+	// Per-request inference performance, labeled by model, serving node and
+	// request type (generate, chat or embed)
+	RequestDuration  *prometheus.HistogramVec
+	TimeToFirstToken *prometheus.HistogramVec
+	TokensPerSecond  *prometheus.HistogramVec
+
+	// Requests served by a smaller fallback model, labeled by the model
+	// requested, the fallback and why
+	ModelFallbacks *prometheus.CounterVec
 }
 
 // NewMetricsRegistry creates a new centralized metrics registry
@@ -111,6 +124,7 @@ func NewMetricsRegistry(config *MetricsConfig) *MetricsRegistry {
 	registry.initializeAPIMetrics()
 	registry.initializeFaultToleranceMetrics()
 	registry.initializeModelMetrics()
+	registry.initializeBufferPoolMetrics()
 
 	return registry
 }
@@ -266,6 +280,16 @@ func (mr *MetricsRegistry) initializeSchedulerMetrics() {
 			"Current utilization of cluster nodes",
 			[]string{"node_id", "resource_type"},
 		),
+		PartitionFailures: mr.prometheusExporter.RegisterCounter(
+			"scheduler_partition_failures_total",
+			"Total number of failed partitions by strategy and failure category",
+			[]string{"strategy", "category", "node_id"},
+		),
+		StrategySelections: mr.prometheusExporter.RegisterCounter(
+			"scheduler_strategy_selections_total",
+			"Total number of partition strategy selections by outcome",
+			[]string{"strategy", "outcome", "node_id"},
+		),
 	}
 }
 
@@ -345,6 +369,12 @@ func (mr *MetricsRegistry) initializeP2PMetrics() {
 			"Total number of peer discovery events",
 			[]string{"discovery_type", "result"},
 		),
+		QueueDelay: mr.prometheusExporter.RegisterHistogram(
+			"p2p_queue_delay_seconds",
+			"Time outbound P2P messages wait in the queue of their QoS class",
+			[]string{"class"},
+			[]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1.0, 5.0},
+		),
 	}
 }
 
@@ -456,5 +486,28 @@ func (mr *MetricsRegistry) initializeModelMetrics() {
 			"Model storage usage in bytes",
 			[]string{"model_name", "node_id", "storage_type"},
 		),
+		RequestDuration: mr.prometheusExporter.RegisterHistogram(
+			"model_request_duration_seconds",
+			"End-to-end inference request duration in seconds",
+			[]string{"model_name", "node_id", "request_type"},
+			[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		),
+		TimeToFirstToken: mr.prometheusExporter.RegisterHistogram(
+			"model_time_to_first_token_seconds",
+			"Time until the first token of a streamed response in seconds",
+			[]string{"model_name", "node_id", "request_type"},
+			[]float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		),
+		TokensPerSecond: mr.prometheusExporter.RegisterHistogram(
+			"model_tokens_per_second",
+			"Token generation rate per request",
+			[]string{"model_name", "node_id", "request_type"},
+			[]float64{1, 5, 10, 20, 30, 50, 75, 100, 150, 200},
+		),
+		ModelFallbacks: mr.prometheusExporter.RegisterCounter(
+			"model_fallbacks_total",
+			"Total number of requests served by a fallback model",
+			[]string{"model_name", "fallback_model", "reason", "node_id"},
+		),
 	}
 }
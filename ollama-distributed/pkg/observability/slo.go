@@ -0,0 +1,332 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SLO indicators
+const (
+	// SLIAvailability counts requests that did not fail
+	SLIAvailability = "availability"
+	// SLILatency counts requests that completed within the threshold
+	SLILatency = "latency"
+	// SLITimeToFirstToken counts streamed requests whose first token arrived
+	// within the threshold
+	SLITimeToFirstToken = "ttft"
+)
+
+// Burn rate windows of the fast burn alert. Both must exceed the threshold,
+// so an alert fires quickly but resets soon after the problem stops.
+const (
+	sloFastBurnLongWindow  = time.Hour
+	sloFastBurnShortWindow = 5 * time.Minute
+)
+
+// sloBucketWidth is the resolution at which SLO events are counted
+const sloBucketWidth = time.Minute
+
+// SLOObjective defines a service level objective over inference requests,
+// e.g. 99% of chat requests see their first token within 2s over 30 days
+type SLOObjective struct {
+	Name        string        `json:"name"`
+	Indicator   string        `json:"indicator"`              // availability, latency or ttft
+	RequestType string        `json:"request_type,omitempty"` // empty matches all
+	Model       string        `json:"model,omitempty"`        // empty matches all
+	Threshold   time.Duration `json:"threshold,omitempty"`    // for latency and ttft
+	Target      float64       `json:"target"`                 // fraction of good events, e.g. 0.99
+	Window      time.Duration `json:"window"`
+}
+
+// SLOConfig configures the SLO monitor
+type SLOConfig struct {
+	Objectives         []SLOObjective
+	EvaluationInterval time.Duration
+	// FastBurnRate is the burn rate above which an alert is sent; 14.4
+	// spends 2% of a 30 day budget in an hour
+	FastBurnRate float64
+}
+
+// SLOStatus is the evaluated state of an objective
+type SLOStatus struct {
+	SLOObjective
+
+	TotalEvents int64   `json:"total_events"`
+	BadEvents   int64   `json:"bad_events"`
+	SLI         float64 `json:"sli"` // fraction of good events over the window
+
+	// ErrorBudgetRemaining is the unspent fraction of the error budget,
+	// negative once the objective is violated
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+
+	BurnRateLong  float64 `json:"burn_rate_1h"`
+	BurnRateShort float64 `json:"burn_rate_5m"`
+	FastBurn      bool    `json:"fast_burn"`
+
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// SLOAlertSink receives fast burn alerts; NotificationSystem implements it
+type SLOAlertSink interface {
+	SendNotification(notification *Notification) error
+}
+
+// SLOMonitor evaluates objectives against completed inference requests
+type SLOMonitor struct {
+	config *SLOConfig
+	sink   SLOAlertSink
+
+	trackers []*sloTracker
+	now      func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// sloTracker counts events for one objective in a ring of per-minute
+// buckets spanning its window
+type sloTracker struct {
+	objective SLOObjective
+
+	mu      sync.Mutex
+	buckets []sloBucket
+	burning bool
+}
+
+type sloBucket struct {
+	minute     int64 // unix minute the counts belong to
+	total, bad int64
+}
+
+// NewSLOMonitor creates an SLO monitor. Alerts are sent to sink, which may
+// be nil.
+func NewSLOMonitor(config *SLOConfig, sink SLOAlertSink) *SLOMonitor {
+	if config == nil {
+		config = &SLOConfig{}
+	}
+	if config.EvaluationInterval <= 0 {
+		config.EvaluationInterval = time.Minute
+	}
+	if config.FastBurnRate <= 0 {
+		config.FastBurnRate = 14.4
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &SLOMonitor{
+		config: config,
+		sink:   sink,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, objective := range config.Objectives {
+		if objective.Window <= 0 {
+			objective.Window = 30 * 24 * time.Hour
+		}
+		m.trackers = append(m.trackers, &sloTracker{
+			objective: objective,
+			buckets:   make([]sloBucket, int(objective.Window/sloBucketWidth)+1),
+		})
+	}
+	return m
+}
+
+// Start starts periodic evaluation of the objectives
+func (m *SLOMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.config.EvaluationInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+				m.Evaluate()
+			}
+		}
+	}()
+	log.Info().Int("objectives", len(m.trackers)).Msg("SLO monitor started")
+}
+
+// Stop stops periodic evaluation
+func (m *SLOMonitor) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Record counts a completed inference request against every matching
+// objective
+func (m *SLOMonitor) Record(obs InferenceObservation) {
+	minute := m.now().Unix() / int64(sloBucketWidth/time.Second)
+	for _, tracker := range m.trackers {
+		good, counted := tracker.objective.classify(obs)
+		if counted {
+			tracker.add(minute, good)
+		}
+	}
+}
+
+// classify reports whether obs is a good event for the objective, and
+// whether the objective counts it at all
+func (o *SLOObjective) classify(obs InferenceObservation) (good, counted bool) {
+	if o.RequestType != "" && o.RequestType != obs.RequestType {
+		return false, false
+	}
+	if o.Model != "" && o.Model != obs.ModelName {
+		return false, false
+	}
+	if obs.Failed {
+		return false, true
+	}
+
+	switch o.Indicator {
+	case SLILatency:
+		return obs.Duration <= o.Threshold, true
+	case SLITimeToFirstToken:
+		// Only streamed responses report a time to first token
+		if obs.TimeToFirstToken == 0 {
+			return false, false
+		}
+		return obs.TimeToFirstToken <= o.Threshold, true
+	default:
+		return true, true
+	}
+}
+
+func (t *sloTracker) add(minute int64, good bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := &t.buckets[minute%int64(len(t.buckets))]
+	if bucket.minute != minute {
+		*bucket = sloBucket{minute: minute}
+	}
+	bucket.total++
+	if !good {
+		bucket.bad++
+	}
+}
+
+// counts sums the buckets within window of now
+func (t *sloTracker) counts(now int64, window time.Duration) (total, bad int64) {
+	minutes := int64(window / sloBucketWidth)
+	for _, bucket := range t.buckets {
+		if bucket.minute > now-minutes && bucket.minute <= now {
+			total += bucket.total
+			bad += bucket.bad
+		}
+	}
+	return total, bad
+}
+
+// Status evaluates every objective without sending alerts
+func (m *SLOMonitor) Status() []*SLOStatus {
+	statuses := make([]*SLOStatus, 0, len(m.trackers))
+	for _, tracker := range m.trackers {
+		statuses = append(statuses, m.status(tracker))
+	}
+	return statuses
+}
+
+func (m *SLOMonitor) status(t *sloTracker) *SLOStatus {
+	now := m.now()
+	minute := now.Unix() / int64(sloBucketWidth/time.Second)
+	budget := 1 - t.objective.Target
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := &SLOStatus{
+		SLOObjective:         t.objective,
+		SLI:                  1,
+		ErrorBudgetRemaining: 1,
+		EvaluatedAt:          now,
+	}
+	status.TotalEvents, status.BadEvents = t.counts(minute, t.objective.Window)
+	if status.TotalEvents > 0 {
+		errorRate := float64(status.BadEvents) / float64(status.TotalEvents)
+		status.SLI = 1 - errorRate
+		if budget > 0 {
+			status.ErrorBudgetRemaining = 1 - errorRate/budget
+		}
+	}
+
+	total, bad := t.counts(minute, sloFastBurnLongWindow)
+	status.BurnRateLong = burnRate(total, bad, budget)
+	total, bad = t.counts(minute, sloFastBurnShortWindow)
+	status.BurnRateShort = burnRate(total, bad, budget)
+	status.FastBurn = status.BurnRateLong >= m.config.FastBurnRate && status.BurnRateShort >= m.config.FastBurnRate
+	return status
+}
+
+// burnRate is how many times faster than sustainable the budget is spent
+func burnRate(total, bad int64, budget float64) float64 {
+	if total == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / budget
+}
+
+// Evaluate evaluates every objective and alerts on those that started
+// burning their error budget fast
+func (m *SLOMonitor) Evaluate() []*SLOStatus {
+	statuses := make([]*SLOStatus, 0, len(m.trackers))
+	for _, tracker := range m.trackers {
+		status := m.status(tracker)
+		statuses = append(statuses, status)
+
+		tracker.mu.Lock()
+		startedBurning := status.FastBurn && !tracker.burning
+		tracker.burning = status.FastBurn
+		tracker.mu.Unlock()
+
+		if startedBurning {
+			m.alert(status)
+		}
+	}
+	return statuses
+}
+
+// alert sends a fast burn notification
+func (m *SLOMonitor) alert(status *SLOStatus) {
+	log.Warn().
+		Str("slo", status.Name).
+		Float64("burn_rate_1h", status.BurnRateLong).
+		Float64("error_budget_remaining", status.ErrorBudgetRemaining).
+		Msg("SLO error budget burning fast")
+
+	if m.sink == nil {
+		return
+	}
+	notification := &Notification{
+		ID:        fmt.Sprintf("slo-%s-%d", status.Name, status.EvaluatedAt.Unix()),
+		Title:     fmt.Sprintf("SLO fast burn: %s", status.Name),
+		Message:   fmt.Sprintf("Error budget burning at %.1fx over the last hour (%.1fx over 5m), %.1f%% remaining", status.BurnRateLong, status.BurnRateShort, status.ErrorBudgetRemaining*100),
+		Severity:  "critical",
+		Component: "slo",
+		Timestamp: status.EvaluatedAt,
+		Labels: map[string]string{
+			"slo":       status.Name,
+			"indicator": status.Indicator,
+			"type":      "slo",
+		},
+		Metadata: map[string]interface{}{
+			"target":                 status.Target,
+			"sli":                    status.SLI,
+			"burn_rate_1h":           status.BurnRateLong,
+			"burn_rate_5m":           status.BurnRateShort,
+			"error_budget_remaining": status.ErrorBudgetRemaining,
+		},
+	}
+	if err := m.sink.SendNotification(notification); err != nil {
+		log.Error().Err(err).Str("slo", status.Name).Msg("Failed to send SLO alert")
+	}
+}
@@ -0,0 +1,103 @@
+package observability
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu            sync.Mutex
+	notifications []*Notification
+}
+
+func (s *recordingSink) SendNotification(notification *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, notification)
+	return nil
+}
+
+func newTestSLOMonitor(sink SLOAlertSink, now *time.Time) *SLOMonitor {
+	m := NewSLOMonitor(&SLOConfig{
+		Objectives: []SLOObjective{{
+			Name:        "chat-ttft",
+			Indicator:   SLITimeToFirstToken,
+			RequestType: "chat",
+			Threshold:   2 * time.Second,
+			Target:      0.99,
+			Window:      24 * time.Hour,
+		}},
+	}, sink)
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func chatRequest(ttft time.Duration) InferenceObservation {
+	return InferenceObservation{ModelName: "llama3:8b", RequestType: "chat", Duration: 5 * time.Second, TimeToFirstToken: ttft}
+}
+
+func TestSLOMonitorErrorBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestSLOMonitor(nil, &now)
+
+	// 1 slow request in 200 spends half of a 1% budget
+	for i := 0; i < 199; i++ {
+		m.Record(chatRequest(500 * time.Millisecond))
+	}
+	m.Record(chatRequest(3 * time.Second))
+	// Not counted: other request types and unstreamed responses
+	m.Record(InferenceObservation{RequestType: "generate", TimeToFirstToken: 10 * time.Second})
+	m.Record(chatRequest(0))
+
+	statuses := m.Status()
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.Equal(t, int64(200), status.TotalEvents)
+	assert.Equal(t, int64(1), status.BadEvents)
+	assert.InDelta(t, 0.995, status.SLI, 1e-9)
+	assert.InDelta(t, 0.5, status.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 0.5, status.BurnRateLong, 1e-9)
+	assert.False(t, status.FastBurn)
+
+	// Events age out of the window
+	now = now.Add(25 * time.Hour)
+	status = m.Status()[0]
+	assert.Zero(t, status.TotalEvents)
+	assert.Equal(t, 1.0, status.ErrorBudgetRemaining)
+}
+
+func TestSLOMonitorFastBurnAlert(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sink := &recordingSink{}
+	m := newTestSLOMonitor(sink, &now)
+
+	// 20% failing is a burn rate of 20
+	for i := 0; i < 100; i++ {
+		obs := chatRequest(time.Second)
+		obs.Failed = i%5 == 0
+		m.Record(obs)
+	}
+
+	status := m.Evaluate()[0]
+	assert.InDelta(t, 20, status.BurnRateShort, 1e-9)
+	assert.True(t, status.FastBurn)
+	require.Len(t, sink.notifications, 1)
+	assert.Equal(t, "critical", sink.notifications[0].Severity)
+	assert.Equal(t, "chat-ttft", sink.notifications[0].Labels["slo"])
+
+	// Only the transition into fast burn alerts
+	m.Evaluate()
+	assert.Len(t, sink.notifications, 1)
+
+	// The short window clears first once errors stop
+	now = now.Add(10 * time.Minute)
+	m.Record(chatRequest(time.Second))
+	status = m.Evaluate()[0]
+	assert.False(t, status.FastBurn)
+	assert.Zero(t, status.BurnRateShort)
+	assert.Len(t, sink.notifications, 1)
+}
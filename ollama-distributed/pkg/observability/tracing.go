@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
 )
 
 // TraceContext represents tracing context information
@@ -189,6 +191,9 @@ func (t *Tracer) StartSpan(ctx context.Context, operationName string) (*Span, co
 		Logs:         make([]SpanLog, 0),
 		BaggageItems: make(map[string]string),
 	}
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		span.Tags["request_id"] = requestID
+	}
 
 	// Store span if sampled
 	if sampled {
@@ -0,0 +1,571 @@
+package observability
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Watchdog alert kinds
+const (
+	WatchdogGoroutineLeak   = "goroutine_leak"
+	WatchdogGoroutineLimit  = "goroutine_limit"
+	WatchdogHeapGrowth      = "heap_growth"
+	WatchdogHeapLimit       = "heap_limit"
+	WatchdogMutexContention = "mutex_contention"
+	WatchdogDeadlock        = "deadlock"
+)
+
+// Runtime metrics sampled by the watchdog
+const (
+	metricGoroutines = "/sched/goroutines:goroutines"
+	metricHeapLive   = "/gc/heap/live:bytes"
+	metricHeapObjs   = "/gc/heap/objects:objects"
+	metricMutexWait  = "/sync/mutex/wait/total:seconds"
+	metricGCCycles   = "/gc/cycles/total:gc-cycles"
+)
+
+// WatchdogConfig configures the leak watchdog. Zero thresholds disable the
+// corresponding check.
+type WatchdogConfig struct {
+	Interval time.Duration
+	// Window is the number of samples growth trends are computed over
+	Window int
+
+	MaxGoroutines int
+	// GoroutineGrowth is the growth between the older and newer half of the
+	// window, as a fraction, above which goroutines are reported leaking
+	GoroutineGrowth float64
+	MaxHeapBytes    uint64
+	HeapGrowth      float64
+	// MutexWaitRatio is the seconds goroutines spent blocked on mutexes
+	// per second above which contention is reported
+	MutexWaitRatio float64
+	// DeadlockAfter is how long a goroutine waits on a mutex before it is
+	// reported deadlocked. Goroutine dumps report waits in minutes.
+	DeadlockAfter time.Duration
+
+	// DiagnosticsDir receives a bundle of profiles when an alert fires;
+	// empty disables bundles
+	DiagnosticsDir      string
+	DiagnosticsCooldown time.Duration
+	MaxBundles          int
+}
+
+// WatchdogSample is one reading of the runtime metrics
+type WatchdogSample struct {
+	Time        time.Time `json:"time"`
+	Goroutines  int       `json:"goroutines"`
+	HeapLive    uint64    `json:"heap_live_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+	MutexWait   float64   `json:"mutex_wait_seconds"` // cumulative
+	GCCycles    uint64    `json:"gc_cycles"`
+}
+
+// WatchdogAlert is a threshold the runtime exceeded
+type WatchdogAlert struct {
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`
+	// Stacks are the blocked goroutines of a deadlock alert
+	Stacks []string `json:"stacks,omitempty"`
+	// Bundle is the diagnostics bundle captured when the alert fired
+	Bundle string `json:"bundle,omitempty"`
+}
+
+// WatchdogStatus is the latest state of the watchdog
+type WatchdogStatus struct {
+	Current         *WatchdogSample  `json:"current,omitempty"`
+	GoroutineGrowth float64          `json:"goroutine_growth"`
+	HeapGrowth      float64          `json:"heap_growth"`
+	MutexWaitRatio  float64          `json:"mutex_wait_ratio"`
+	Alerts          []*WatchdogAlert `json:"alerts"`
+	Bundles         []string         `json:"bundles"`
+	Samples         []WatchdogSample `json:"samples"`
+}
+
+// Watchdog samples goroutine counts, live heap and mutex wait time of this
+// process and alerts when they grow beyond the configured thresholds, as
+// long-running nodes do when goroutines or memory leak
+type Watchdog struct {
+	config *WatchdogConfig
+	sink   SLOAlertSink
+
+	sample func() WatchdogSample
+	dump   func() []byte
+
+	mu         sync.RWMutex
+	samples    []WatchdogSample // ring of the last Window samples
+	next       int
+	alerts     map[string]*WatchdogAlert
+	status     WatchdogStatus
+	bundles    []string
+	lastBundle time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWatchdog creates a watchdog. Alerts are sent to sink, which may be nil.
+func NewWatchdog(config *WatchdogConfig, sink SLOAlertSink) *Watchdog {
+	if config == nil {
+		config = &WatchdogConfig{}
+	}
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.Window < 2 {
+		config.Window = 20
+	}
+	if config.DiagnosticsCooldown <= 0 {
+		config.DiagnosticsCooldown = 15 * time.Minute
+	}
+	if config.MaxBundles <= 0 {
+		config.MaxBundles = 5
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Watchdog{
+		config: config,
+		sink:   sink,
+		sample: readRuntimeSample,
+		dump:   goroutineDump,
+		alerts: make(map[string]*WatchdogAlert),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start starts periodic sampling
+func (w *Watchdog) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+				w.Check()
+			}
+		}
+	}()
+	log.Info().Dur("interval", w.config.Interval).Msg("Leak watchdog started")
+}
+
+// Stop stops periodic sampling
+func (w *Watchdog) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// Status returns the latest samples and active alerts
+func (w *Watchdog) Status() WatchdogStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	status := w.status
+	status.Samples = w.ordered()
+	status.Bundles = append([]string(nil), w.bundles...)
+	status.Alerts = make([]*WatchdogAlert, 0, len(w.alerts))
+	for _, alert := range w.alerts {
+		copied := *alert
+		status.Alerts = append(status.Alerts, &copied)
+	}
+	sort.Slice(status.Alerts, func(i, j int) bool { return status.Alerts[i].Kind < status.Alerts[j].Kind })
+	return status
+}
+
+// Check takes a sample, evaluates the thresholds and returns the alerts that
+// fired with this sample
+func (w *Watchdog) Check() []*WatchdogAlert {
+	sample := w.sample()
+
+	w.mu.Lock()
+	var previous *WatchdogSample
+	if len(w.samples) > 0 {
+		last := w.samples[(w.next+len(w.samples)-1)%len(w.samples)]
+		previous = &last
+	}
+	if len(w.samples) < w.config.Window {
+		w.samples = append(w.samples, sample)
+		w.next = len(w.samples) % w.config.Window
+	} else {
+		w.samples[w.next] = sample
+		w.next = (w.next + 1) % w.config.Window
+	}
+	samples := w.ordered()
+	w.mu.Unlock()
+
+	status := WatchdogStatus{Current: &sample}
+	firing := make(map[string]*WatchdogAlert)
+	add := func(kind string, value, threshold float64, format string, args ...interface{}) *WatchdogAlert {
+		alert := &WatchdogAlert{Kind: kind, Message: fmt.Sprintf(format, args...), Value: value, Threshold: threshold, Since: sample.Time}
+		firing[kind] = alert
+		return alert
+	}
+
+	if max := w.config.MaxGoroutines; max > 0 && sample.Goroutines > max {
+		add(WatchdogGoroutineLimit, float64(sample.Goroutines), float64(max),
+			"%d goroutines running, more than %d", sample.Goroutines, max)
+	}
+	if max := w.config.MaxHeapBytes; max > 0 && sample.HeapLive > max {
+		add(WatchdogHeapLimit, float64(sample.HeapLive), float64(max),
+			"live heap is %d bytes, more than %d", sample.HeapLive, max)
+	}
+
+	// Trends are only computed over a full window, so a node warming up does
+	// not look like a leak
+	if len(samples) == w.config.Window {
+		status.GoroutineGrowth = growth(samples, func(s WatchdogSample) float64 { return float64(s.Goroutines) })
+		status.HeapGrowth = growth(samples, func(s WatchdogSample) float64 { return float64(s.HeapLive) })
+		span := samples[len(samples)-1].Time.Sub(samples[0].Time).Round(time.Second)
+		if limit := w.config.GoroutineGrowth; limit > 0 && status.GoroutineGrowth > limit {
+			add(WatchdogGoroutineLeak, status.GoroutineGrowth, limit,
+				"goroutines grew %.0f%% over %s to %d", status.GoroutineGrowth*100, span, sample.Goroutines)
+		}
+		if limit := w.config.HeapGrowth; limit > 0 && status.HeapGrowth > limit {
+			add(WatchdogHeapGrowth, status.HeapGrowth, limit,
+				"live heap grew %.0f%% over %s to %d bytes", status.HeapGrowth*100, span, sample.HeapLive)
+		}
+	}
+
+	if previous != nil {
+		if elapsed := sample.Time.Sub(previous.Time).Seconds(); elapsed > 0 {
+			status.MutexWaitRatio = (sample.MutexWait - previous.MutexWait) / elapsed
+		}
+		if limit := w.config.MutexWaitRatio; limit > 0 && status.MutexWaitRatio > limit {
+			add(WatchdogMutexContention, status.MutexWaitRatio, limit,
+				"goroutines spent %.1fs per second waiting on mutexes", status.MutexWaitRatio)
+		}
+	}
+
+	// Mutex wait time is only recorded once a lock is acquired, so a
+	// deadlock leaves the metric flat; goroutine states show it instead
+	if w.config.DeadlockAfter > 0 {
+		if blocked := blockedOnMutex(w.dump(), w.config.DeadlockAfter); len(blocked) > 0 {
+			alert := add(WatchdogDeadlock, float64(len(blocked)), w.config.DeadlockAfter.Minutes(),
+				"%d goroutines blocked on a mutex for %s or longer", len(blocked), w.config.DeadlockAfter)
+			alert.Stacks = blocked
+		}
+	}
+
+	return w.update(status, firing)
+}
+
+// update replaces the active alerts with firing and returns the new ones,
+// capturing a diagnostics bundle for them
+func (w *Watchdog) update(status WatchdogStatus, firing map[string]*WatchdogAlert) []*WatchdogAlert {
+	w.mu.Lock()
+	var fired []*WatchdogAlert
+	for kind, alert := range firing {
+		if active, ok := w.alerts[kind]; ok {
+			alert.Since = active.Since
+			alert.Bundle = active.Bundle
+			continue
+		}
+		fired = append(fired, alert)
+	}
+	for kind := range w.alerts {
+		if _, ok := firing[kind]; !ok {
+			log.Info().Str("kind", kind).Msg("Watchdog alert resolved")
+		}
+	}
+	w.alerts = firing
+	w.status = status
+
+	var bundleDue bool
+	if len(fired) > 0 && w.config.DiagnosticsDir != "" && time.Since(w.lastBundle) >= w.config.DiagnosticsCooldown {
+		w.lastBundle = time.Now()
+		bundleDue = true
+	}
+	w.mu.Unlock()
+
+	sort.Slice(fired, func(i, j int) bool { return fired[i].Kind < fired[j].Kind })
+	if bundleDue {
+		if path, err := w.CaptureDiagnostics(fired); err != nil {
+			log.Error().Err(err).Msg("Failed to capture watchdog diagnostics")
+		} else {
+			w.mu.Lock()
+			for _, alert := range fired {
+				alert.Bundle = path
+			}
+			w.mu.Unlock()
+		}
+	}
+	for _, alert := range fired {
+		w.alert(alert)
+	}
+	return fired
+}
+
+// ordered returns the samples oldest first. Callers hold mu.
+func (w *Watchdog) ordered() []WatchdogSample {
+	if len(w.samples) < w.config.Window {
+		return append([]WatchdogSample(nil), w.samples...)
+	}
+	return append(append([]WatchdogSample(nil), w.samples[w.next:]...), w.samples[:w.next]...)
+}
+
+// alert sends a watchdog notification
+func (w *Watchdog) alert(alert *WatchdogAlert) {
+	log.Warn().
+		Str("kind", alert.Kind).
+		Float64("value", alert.Value).
+		Float64("threshold", alert.Threshold).
+		Str("bundle", alert.Bundle).
+		Msg(alert.Message)
+
+	if w.sink == nil {
+		return
+	}
+	notification := &Notification{
+		ID:        fmt.Sprintf("watchdog-%s-%d", alert.Kind, alert.Since.Unix()),
+		Title:     fmt.Sprintf("Watchdog: %s", strings.ReplaceAll(alert.Kind, "_", " ")),
+		Message:   alert.Message,
+		Severity:  "warning",
+		Component: "watchdog",
+		Timestamp: alert.Since,
+		Labels: map[string]string{
+			"kind": alert.Kind,
+			"type": "watchdog",
+		},
+		Metadata: map[string]interface{}{
+			"value":     alert.Value,
+			"threshold": alert.Threshold,
+			"bundle":    alert.Bundle,
+		},
+	}
+	if alert.Kind == WatchdogDeadlock {
+		notification.Severity = "critical"
+	}
+	if err := w.sink.SendNotification(notification); err != nil {
+		log.Error().Err(err).Str("kind", alert.Kind).Msg("Failed to send watchdog alert")
+	}
+}
+
+// CaptureDiagnostics writes a gzipped tar of goroutine, heap, mutex and
+// block profiles, the recent samples and alerts to the diagnostics
+// directory and returns its path. The oldest bundles beyond MaxBundles are
+// removed.
+func (w *Watchdog) CaptureDiagnostics(alerts []*WatchdogAlert) (string, error) {
+	dir := w.config.DiagnosticsDir
+	if dir == "" {
+		return "", fmt.Errorf("no diagnostics directory configured")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+
+	// Collect garbage first so the heap profile reflects live objects
+	runtime.GC()
+
+	files := map[string][]byte{"goroutines.txt": w.dump()}
+	for _, name := range []string{"heap", "mutex", "block"} {
+		var buf bytes.Buffer
+		if p := pprof.Lookup(name); p != nil {
+			if err := p.WriteTo(&buf, 0); err != nil {
+				return "", fmt.Errorf("failed to write %s profile: %w", name, err)
+			}
+		}
+		files[name+".pprof"] = buf.Bytes()
+	}
+	w.mu.RLock()
+	state, err := json.MarshalIndent(map[string]interface{}{
+		"alerts":  alerts,
+		"samples": w.ordered(),
+	}, "", "  ")
+	w.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	files["watchdog.json"] = state
+
+	path := filepath.Join(dir, fmt.Sprintf("watchdog-%s.tar.gz", time.Now().UTC().Format("20060102T150405.000000Z")))
+	if err := writeBundle(path, files); err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	w.bundles = append(w.bundles, path)
+	var expired []string
+	if excess := len(w.bundles) - w.config.MaxBundles; excess > 0 {
+		expired = w.bundles[:excess]
+		w.bundles = append([]string(nil), w.bundles[excess:]...)
+	}
+	w.mu.Unlock()
+	for _, old := range expired {
+		os.Remove(old)
+	}
+
+	log.Info().Str("bundle", path).Msg("Captured watchdog diagnostics")
+	return path, nil
+}
+
+// writeBundle writes files to a gzipped tar at path
+func writeBundle(path string, files map[string][]byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	for _, name := range names {
+		data := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o640, Size: int64(len(data)), ModTime: now}); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// growth compares the mean of the newer half of the samples with the older
+// half and returns the increase as a fraction of the older mean. Halves
+// smooth out the spikes single samples see between garbage collections.
+func growth(samples []WatchdogSample, value func(WatchdogSample) float64) float64 {
+	half := len(samples) / 2
+	var older, newer float64
+	for i, s := range samples {
+		if i < half {
+			older += value(s)
+		} else if i >= len(samples)-half {
+			newer += value(s)
+		}
+	}
+	if older <= 0 {
+		return 0
+	}
+	return (newer - older) / older
+}
+
+// readRuntimeSample reads the watchdog's runtime metrics
+func readRuntimeSample() WatchdogSample {
+	descs := []metrics.Sample{
+		{Name: metricGoroutines},
+		{Name: metricHeapLive},
+		{Name: metricHeapObjs},
+		{Name: metricMutexWait},
+		{Name: metricGCCycles},
+	}
+	metrics.Read(descs)
+
+	sample := WatchdogSample{Time: time.Now()}
+	for _, d := range descs {
+		switch d.Value.Kind() {
+		case metrics.KindUint64:
+			v := d.Value.Uint64()
+			switch d.Name {
+			case metricGoroutines:
+				sample.Goroutines = int(v)
+			case metricHeapLive:
+				sample.HeapLive = v
+			case metricHeapObjs:
+				sample.HeapObjects = v
+			case metricGCCycles:
+				sample.GCCycles = v
+			}
+		case metrics.KindFloat64:
+			if d.Name == metricMutexWait {
+				sample.MutexWait = d.Value.Float64()
+			}
+		}
+	}
+	return sample
+}
+
+// goroutineDump returns the stacks of all goroutines with their wait states
+func goroutineDump() []byte {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// goroutineHeader matches the first line of a goroutine in a dump, e.g.
+// "goroutine 7 [sync.Mutex.Lock, 12 minutes]:"
+var goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[([^,\]]+)(?:, (\d+) minutes)?(?:, [^\]]*)?\]:$`)
+
+// blockedOnMutex returns the stacks of goroutines in dump that have waited
+// on a sync.Mutex or sync.RWMutex for at least after
+func blockedOnMutex(dump []byte, after time.Duration) []string {
+	var (
+		stacks  []string
+		current *strings.Builder
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := goroutineHeader.FindStringSubmatch(line); m != nil {
+			current = nil
+			if !strings.HasPrefix(m[1], "sync.Mutex.") && !strings.HasPrefix(m[1], "sync.RWMutex.") && m[1] != "semacquire" {
+				continue
+			}
+			minutes, _ := strconv.Atoi(m[2])
+			if time.Duration(minutes)*time.Minute < after {
+				continue
+			}
+			current = &strings.Builder{}
+			stacks = append(stacks, "")
+			current.WriteString(line)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line == "" {
+			stacks[len(stacks)-1] = current.String()
+			current = nil
+			continue
+		}
+		current.WriteString("\n" + line)
+	}
+	if current != nil {
+		stacks[len(stacks)-1] = current.String()
+	}
+	return stacks
+}
@@ -0,0 +1,134 @@
+package observability
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deadlockDump = `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x1d
+
+goroutine 7 [sync.Mutex.Lock, 12 minutes]:
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:90
+github.com/example/pkg.(*Cache).Put(0xc000010000)
+	/app/cache.go:42 +0x45
+
+goroutine 8 [sync.RWMutex.RLock, 2 minutes]:
+sync.(*RWMutex).RLock(...)
+	/usr/local/go/src/sync/rwmutex.go:71
+
+goroutine 9 [select, 30 minutes]:
+main.loop()
+	/app/main.go:20 +0x10
+`
+
+func TestWatchdogDetectsLeaks(t *testing.T) {
+	sink := &recordingSink{}
+	w := NewWatchdog(&WatchdogConfig{
+		Window:          4,
+		MaxGoroutines:   1000,
+		GoroutineGrowth: 0.5,
+		HeapGrowth:      0.5,
+		MutexWaitRatio:  2,
+	}, sink)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var next WatchdogSample
+	w.sample = func() WatchdogSample { return next }
+	w.dump = func() []byte { return nil }
+	step := func(i, goroutines int, heap uint64, mutexWait float64) []*WatchdogAlert {
+		next = WatchdogSample{Time: start.Add(time.Duration(i) * 10 * time.Second), Goroutines: goroutines, HeapLive: heap, MutexWait: mutexWait}
+		return w.Check()
+	}
+
+	// Goroutines double while the heap stays flat; no trend is fitted
+	// until the window is full
+	assert.Empty(t, step(0, 100, 1<<20, 0))
+	assert.Empty(t, step(1, 140, 1<<20, 0))
+	assert.Empty(t, step(2, 170, 1<<20, 0))
+	fired := step(3, 200, 1<<20, 5)
+	kinds := make([]string, 0, len(fired))
+	for _, alert := range fired {
+		kinds = append(kinds, alert.Kind)
+	}
+	assert.Equal(t, []string{WatchdogGoroutineLeak}, kinds)
+
+	// Active alerts are not sent again; mutex contention and the limit fire
+	fired = step(4, 1200, 1<<20, 50)
+	kinds = kinds[:0]
+	for _, alert := range fired {
+		kinds = append(kinds, alert.Kind)
+	}
+	sort.Strings(kinds)
+	assert.Equal(t, []string{WatchdogGoroutineLimit, WatchdogMutexContention}, kinds)
+
+	status := w.Status()
+	assert.Len(t, status.Samples, 4)
+	assert.Equal(t, 1200, status.Current.Goroutines)
+	assert.InDelta(t, 4.5, status.MutexWaitRatio, 1e-9)
+	assert.Len(t, status.Alerts, 3)
+	assert.Len(t, sink.notifications, 3)
+
+	// Alerts resolve once the runtime recovers
+	for i := 5; i < 9; i++ {
+		step(i, 100, 1<<20, 50)
+	}
+	assert.Empty(t, w.Status().Alerts)
+}
+
+func TestWatchdogDeadlockBundle(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWatchdog(&WatchdogConfig{
+		DeadlockAfter:  5 * time.Minute,
+		DiagnosticsDir: dir,
+		MaxBundles:     1,
+	}, nil)
+	w.dump = func() []byte { return []byte(deadlockDump) }
+
+	fired := w.Check()
+	require.Len(t, fired, 1)
+	alert := fired[0]
+	assert.Equal(t, WatchdogDeadlock, alert.Kind)
+	require.Len(t, alert.Stacks, 1)
+	assert.Contains(t, alert.Stacks[0], "goroutine 7 [sync.Mutex.Lock, 12 minutes]:")
+	assert.Contains(t, alert.Stacks[0], "(*Cache).Put")
+	require.NotEmpty(t, alert.Bundle)
+
+	f, err := os.Open(alert.Bundle)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"block.pprof", "goroutines.txt", "heap.pprof", "mutex.pprof", "watchdog.json"}, names)
+
+	// Bundles beyond MaxBundles are removed
+	second, err := w.CaptureDiagnostics(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{second}, w.Status().Bundles)
+	_, err = os.Stat(alert.Bundle)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadRuntimeSample(t *testing.T) {
+	sample := readRuntimeSample()
+	assert.Positive(t, sample.Goroutines)
+	assert.Positive(t, sample.HeapObjects)
+}
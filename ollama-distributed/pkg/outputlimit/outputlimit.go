@@ -0,0 +1,293 @@
+// Package outputlimit cuts off runaway generations at the gateway. A Writer
+// sits between an inference handler and the client, counting the tokens of
+// the streamed response; once the caller's maximum output tokens or
+// streaming duration is reached it ends the stream with a structured
+// termination message and cancels the generation. The tokens counted,
+// including those of cut off generations, are reported for usage metering.
+package outputlimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons a generation is cut off, sent as its done_reason
+const (
+	ReasonMaxTokens   = "max_output_tokens"
+	ReasonMaxDuration = "max_stream_duration"
+)
+
+// Limits caps the output of a caller's generations. Zero leaves a limit
+// unset.
+type Limits struct {
+	MaxOutputTokens   int           `yaml:"max_output_tokens" mapstructure:"max_output_tokens" json:"max_output_tokens,omitempty"`
+	MaxStreamDuration time.Duration `yaml:"max_stream_duration" mapstructure:"max_stream_duration" json:"max_stream_duration,omitempty"`
+}
+
+// Validate checks the limits
+func (l Limits) Validate() error {
+	if l.MaxOutputTokens < 0 || l.MaxStreamDuration < 0 {
+		return fmt.Errorf("output limits must not be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l.MaxOutputTokens == 0 && l.MaxStreamDuration == 0
+}
+
+// Or returns the limits with the unset ones taken from defaults
+func (l Limits) Or(defaults Limits) Limits {
+	if l.MaxOutputTokens == 0 {
+		l.MaxOutputTokens = defaults.MaxOutputTokens
+	}
+	if l.MaxStreamDuration == 0 {
+		l.MaxStreamDuration = defaults.MaxStreamDuration
+	}
+	return l
+}
+
+// Termination is the last message of a cut off generation, in the format of
+// the final chunk of an Ollama stream
+type Termination struct {
+	Done         bool   `json:"done"`
+	DoneReason   string `json:"done_reason"`
+	Error        string `json:"error"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+// CapRequest lowers the number of tokens an inference request body asks
+// for to the limit, so backends stop generating by themselves: num_predict
+// of the Ollama options, and max_tokens of OpenAI-style requests when set.
+// Bodies that are not JSON objects are returned unchanged.
+func CapRequest(body []byte, maxTokens int) []byte {
+	if maxTokens <= 0 {
+		return body
+	}
+	var req map[string]interface{}
+	if json.Unmarshal(body, &req) != nil || req == nil {
+		return body
+	}
+
+	options, _ := req["options"].(map[string]interface{})
+	if options == nil {
+		options = make(map[string]interface{})
+	}
+	if n, ok := options["num_predict"].(float64); !ok || n <= 0 || n > float64(maxTokens) {
+		options["num_predict"] = maxTokens
+	}
+	req["options"] = options
+	if n, ok := req["max_tokens"].(float64); ok && (n <= 0 || n > float64(maxTokens)) {
+		req["max_tokens"] = maxTokens
+	}
+
+	capped, err := json.Marshal(req)
+	if err != nil {
+		return body
+	}
+	return capped
+}
+
+// chunk is the part of a streamed response chunk tokens are counted from:
+// Ollama generate and chat chunks, and OpenAI-style completion chunks
+type chunk struct {
+	Response  string `json:"response"`
+	EvalCount int    `json:"eval_count"`
+	Done      bool   `json:"done"`
+	Message   *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// text returns the generated text of the chunk
+func (c chunk) text() string {
+	text := c.Response
+	if c.Message != nil {
+		text += c.Message.Content
+	}
+	for _, choice := range c.Choices {
+		text += choice.Text + choice.Delta.Content
+	}
+	return text
+}
+
+// finished reports whether the chunk ends the generation
+func (c chunk) finished() bool {
+	if c.Done {
+		return true
+	}
+	for _, choice := range c.Choices {
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Writer enforces limits on the response of a generation. Responses are
+// passed on line by line, as Ollama streams NDJSON and OpenAI-style APIs
+// stream server-sent events; every chunk carrying text counts as a token
+// until a chunk reports the backend's own eval_count.
+type Writer struct {
+	gin.ResponseWriter
+	limits Limits
+	cancel context.CancelFunc
+	start  time.Time
+	now    func() time.Time
+
+	mu       sync.Mutex
+	pending  []byte
+	tokens   int
+	finished bool
+	cutOff   string
+}
+
+// NewWriter wraps w, calling cancel to stop the generation once it is cut
+// off
+func NewWriter(w gin.ResponseWriter, limits Limits, cancel context.CancelFunc) *Writer {
+	return &Writer{ResponseWriter: w, limits: limits, cancel: cancel, start: time.Now(), now: time.Now}
+}
+
+// Write passes on the complete lines of b, holding back a trailing partial
+// line. Writes after a cut off are discarded.
+func (w *Writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cutOff != "" {
+		return len(b), nil
+	}
+	w.pending = append(w.pending, b...)
+	for w.cutOff == "" {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.pending[:i+1]
+		w.pending = w.pending[i+1:]
+		if err := w.writeLine(line); err != nil {
+			return len(b), err
+		}
+	}
+	if w.cutOff != "" {
+		w.pending = nil
+	}
+	return len(b), nil
+}
+
+// WriteString writes s
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Finish writes the held back partial line, and cuts the generation off if
+// ctx, the request context carrying the streaming deadline, expired before
+// it finished. It must be called once the handler returns.
+func (w *Writer) Finish(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 && w.cutOff == "" {
+		line := w.pending
+		w.pending = nil
+		if err := w.writeLine(line); err != nil {
+			return err
+		}
+	}
+	if w.cutOff == "" && !w.finished && w.ResponseWriter.Written() && ctx.Err() == context.DeadlineExceeded {
+		return w.terminate(ReasonMaxDuration)
+	}
+	return nil
+}
+
+// Tokens returns the output tokens counted so far
+func (w *Writer) Tokens() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tokens
+}
+
+// CutOff returns why the generation was cut off, or "" if it was not
+func (w *Writer) CutOff() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cutOff
+}
+
+// writeLine counts the tokens of a line and writes it unless it goes past
+// a limit, in which case the generation is cut off instead; w.mu must be
+// held
+func (w *Writer) writeLine(line []byte) error {
+	payload := bytes.TrimSpace(line)
+	payload = bytes.TrimSpace(bytes.TrimPrefix(payload, []byte("data:")))
+	var c chunk
+	if len(payload) == 0 || payload[0] != '{' || json.Unmarshal(payload, &c) != nil {
+		if string(payload) == "[DONE]" {
+			w.finished = true
+		}
+		_, err := w.ResponseWriter.Write(line)
+		return err
+	}
+
+	if c.text() != "" && !w.finished {
+		if w.limits.MaxStreamDuration > 0 && w.now().Sub(w.start) >= w.limits.MaxStreamDuration {
+			return w.terminate(ReasonMaxDuration)
+		}
+		if w.limits.MaxOutputTokens > 0 && w.tokens >= w.limits.MaxOutputTokens {
+			return w.terminate(ReasonMaxTokens)
+		}
+		w.tokens++
+	}
+	if c.EvalCount > 0 {
+		w.tokens = c.EvalCount
+	}
+	if c.finished() {
+		w.finished = true
+	}
+	_, err := w.ResponseWriter.Write(line)
+	return err
+}
+
+// terminate ends the response with a termination message and cancels the
+// generation; w.mu must be held
+func (w *Writer) terminate(reason string) error {
+	w.cutOff = reason
+	w.cancel()
+
+	msg := Termination{Done: true, DoneReason: reason, OutputTokens: w.tokens}
+	switch reason {
+	case ReasonMaxTokens:
+		msg.Error = fmt.Sprintf("generation cut off at the limit of %d output tokens", w.limits.MaxOutputTokens)
+	case ReasonMaxDuration:
+		msg.Error = fmt.Sprintf("generation cut off at the streaming limit of %s", w.limits.MaxStreamDuration)
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		body = append(append([]byte("data: "), body...), '\n', '\n')
+	} else {
+		body = append(body, '\n')
+	}
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		return err
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
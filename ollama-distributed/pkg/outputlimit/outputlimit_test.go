@@ -0,0 +1,110 @@
+package outputlimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWriter(limits Limits) (*Writer, *httptest.ResponseRecorder, context.Context) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	ctx, cancel := context.WithCancel(context.Background())
+	return NewWriter(c.Writer, limits, cancel), recorder, ctx
+}
+
+func lines(body string) []string {
+	return strings.Split(strings.TrimSpace(body), "\n")
+}
+
+func TestWriterCutsOffAtMaxTokens(t *testing.T) {
+	w, recorder, ctx := newTestWriter(Limits{MaxOutputTokens: 2})
+
+	// Chunks may be split across writes
+	w.WriteString(`{"response":"a","done":false}` + "\n" + `{"response":"b",`)
+	w.WriteString(`"done":false}` + "\n")
+	w.WriteString(`{"response":"c","done":false}` + "\n")
+	w.WriteString(`{"response":"d","done":false}` + "\n")
+	require.NoError(t, w.Finish(ctx))
+
+	out := lines(recorder.Body.String())
+	require.Len(t, out, 3)
+	var end Termination
+	require.NoError(t, json.Unmarshal([]byte(out[2]), &end))
+	assert.True(t, end.Done)
+	assert.Equal(t, ReasonMaxTokens, end.DoneReason)
+	assert.Equal(t, 2, end.OutputTokens)
+	assert.NotEmpty(t, end.Error)
+
+	assert.Equal(t, ReasonMaxTokens, w.CutOff())
+	assert.Equal(t, 2, w.Tokens())
+	assert.Error(t, ctx.Err(), "the generation is cancelled")
+}
+
+func TestWriterPassesFinishedGenerations(t *testing.T) {
+	w, recorder, ctx := newTestWriter(Limits{MaxOutputTokens: 10})
+
+	w.WriteString(`{"message":{"content":"hi"},"done":false}` + "\n")
+	w.WriteString(`{"message":{"content":""},"done":true,"eval_count":7}`)
+	require.NoError(t, w.Finish(ctx))
+
+	assert.Len(t, lines(recorder.Body.String()), 2)
+	assert.Empty(t, w.CutOff())
+	assert.Equal(t, 7, w.Tokens(), "the backend's eval_count wins")
+	assert.NoError(t, ctx.Err())
+}
+
+func TestWriterCutsOffAtMaxDuration(t *testing.T) {
+	w, recorder, _ := newTestWriter(Limits{MaxStreamDuration: time.Minute})
+	now := time.Now()
+	w.now = func() time.Time { return now }
+	w.start = now
+
+	w.WriteString(`data: {"choices":[{"delta":{"content":"a"}}]}` + "\n\n")
+	now = now.Add(2 * time.Minute)
+	w.WriteString(`data: {"choices":[{"delta":{"content":"b"}}]}` + "\n\n")
+
+	assert.Equal(t, ReasonMaxDuration, w.CutOff())
+	assert.Equal(t, 1, w.Tokens())
+	assert.Contains(t, recorder.Body.String(), `"done_reason":"max_stream_duration"`)
+}
+
+func TestFinishCutsOffStalledStreams(t *testing.T) {
+	w, recorder, _ := newTestWriter(Limits{MaxStreamDuration: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	w.WriteString(`{"response":"a","done":false}` + "\n")
+	<-ctx.Done()
+	require.NoError(t, w.Finish(ctx))
+
+	assert.Equal(t, ReasonMaxDuration, w.CutOff())
+	assert.Len(t, lines(recorder.Body.String()), 2)
+}
+
+func TestCapRequest(t *testing.T) {
+	var req map[string]interface{}
+	require.NoError(t, json.Unmarshal(CapRequest([]byte(`{"model":"m","options":{"num_predict":900}}`), 100), &req))
+	assert.Equal(t, 100.0, req["options"].(map[string]interface{})["num_predict"])
+
+	require.NoError(t, json.Unmarshal(CapRequest([]byte(`{"model":"m","options":{"num_predict":50},"max_tokens":500}`), 100), &req))
+	assert.Equal(t, 50.0, req["options"].(map[string]interface{})["num_predict"], "lower requests are kept")
+	assert.Equal(t, 100.0, req["max_tokens"])
+
+	assert.Equal(t, "not json", string(CapRequest([]byte("not json"), 100)))
+}
+
+func TestLimits(t *testing.T) {
+	assert.True(t, Limits{}.IsZero())
+	assert.Error(t, Limits{MaxOutputTokens: -1}.Validate())
+	assert.Equal(t, Limits{MaxOutputTokens: 5, MaxStreamDuration: time.Second},
+		Limits{MaxOutputTokens: 5}.Or(Limits{MaxOutputTokens: 9, MaxStreamDuration: time.Second}))
+}
@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
+)
+
+// BlobProtocol fetches blobs, such as compilation caches, from peers. The
+// requester writes the key and a newline; the peer answers with a status
+// byte and, if it has the blob, its content.
+const BlobProtocol = protocol.ID("/ollama-distributed/blob/1.0.0")
+
+const (
+	blobFound    byte = 1
+	blobNotFound byte = 0
+
+	maxBlobKeyLength = 256
+	blobRequestTTL   = 10 * time.Second
+)
+
+// ErrBlobNotFound is returned when no connected peer has a blob
+var ErrBlobNotFound = errors.New("blob not found on any peer")
+
+// BlobOpener opens the local blob with a key, returning an error wrapping
+// ErrBlobNotFound, or any error, when there is none
+type BlobOpener func(key string) (io.ReadCloser, error)
+
+// ServeBlobs answers the blob requests of peers from open. Quarantined
+// peers are refused, and malformed requests count against the requester.
+func (n *P2PNode) ServeBlobs(open BlobOpener) {
+	n.host.RegisterProtocol(BlobProtocol, func(stream network.Stream) {
+		defer stream.Close()
+
+		remote := stream.Conn().RemotePeer().String()
+		if n.reputation.Quarantined(remote) {
+			stream.Reset()
+			return
+		}
+
+		stream.SetReadDeadline(time.Now().Add(blobRequestTTL))
+		line, err := bufio.NewReader(io.LimitReader(stream, maxBlobKeyLength+1)).ReadString('\n')
+		if err != nil {
+			if len(line) > maxBlobKeyLength {
+				n.reputation.Record(remote, reputation.ProtocolViolation, "oversized blob key")
+			}
+			stream.Reset()
+			return
+		}
+		stream.SetReadDeadline(time.Time{})
+
+		blob, err := open(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			stream.Write([]byte{blobNotFound})
+			return
+		}
+		defer blob.Close()
+
+		if _, err := stream.Write([]byte{blobFound}); err != nil {
+			stream.Reset()
+			return
+		}
+		if _, err := io.Copy(stream, blob); err != nil {
+			log.Printf("Failed to send blob to %s: %v", stream.Conn().RemotePeer(), err)
+			stream.Reset()
+		}
+	})
+}
+
+// FetchBlob asks the connected peers in turn for a blob and returns the
+// content of the first that has it, skipping quarantined peers. The caller
+// closes the reader.
+func (n *P2PNode) FetchBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	if key == "" || len(key) > maxBlobKeyLength || strings.Contains(key, "\n") {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+
+	for _, peerID := range n.GetConnectedPeers() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if n.reputation.Quarantined(peerID.String()) {
+			continue
+		}
+		stream, err := n.host.NewStream(ctx, peerID, BlobProtocol)
+		if err != nil {
+			n.reputation.Record(peerID.String(), reputation.FailedTransfer, fmt.Sprintf("open blob stream: %v", err))
+			continue
+		}
+		if _, err := stream.Write([]byte(key + "\n")); err != nil {
+			n.reputation.Record(peerID.String(), reputation.FailedTransfer, fmt.Sprintf("send blob request: %v", err))
+			stream.Reset()
+			continue
+		}
+		stream.CloseWrite()
+
+		status := make([]byte, 1)
+		if _, err := io.ReadFull(stream, status); err != nil || status[0] != blobFound {
+			if err != nil && ctx.Err() == nil {
+				n.reputation.Record(peerID.String(), reputation.FailedTransfer, fmt.Sprintf("read blob status: %v", err))
+			}
+			stream.Close()
+			continue
+		}
+		n.reputation.Record(peerID.String(), reputation.Success, "")
+		return stream, nil
+	}
+	return nil, ErrBlobNotFound
+}
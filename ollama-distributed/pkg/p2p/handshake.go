@@ -0,0 +1,112 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+)
+
+// HandshakeProtocol carries the handshake of peers that connect: the
+// dialing side writes its compat.Info as a JSON line and the other side
+// answers with its own
+const HandshakeProtocol = protocol.ID("/ollama-distributed/handshake/1.0.0")
+
+// handshakeTimeout bounds a handshake
+const handshakeTimeout = 10 * time.Second
+
+// incompatibleBlockTTL is how long an incompatible peer is refused before
+// it may connect again, in case it was upgraded
+const incompatibleBlockTTL = 10 * time.Minute
+
+// StartHandshakes advertises this node's version and protocols to every
+// peer that connects and admits the peer to cluster by its own. Peers
+// speaking no protocol version in common with this node are disconnected
+// and refused for a while; peers from before the handshake are admitted as
+// legacy peers, so that wire behaviors they lack stay off.
+func (n *P2PNode) StartHandshakes(ctx context.Context, cluster *compat.Cluster) {
+	n.compat = cluster
+
+	n.host.RegisterProtocol(HandshakeProtocol, func(stream network.Stream) {
+		defer stream.Close()
+
+		stream.SetDeadline(time.Now().Add(handshakeTimeout))
+		var remote compat.Info
+		line, err := bufio.NewReader(stream).ReadBytes('\n')
+		if err != nil || json.Unmarshal(line, &remote) != nil {
+			return
+		}
+		remote.NodeID = stream.Conn().RemotePeer().String()
+		if err := writeInfo(stream, cluster.Local()); err != nil {
+			return
+		}
+		n.admit(stream.Conn().RemotePeer(), remote, cluster)
+	})
+
+	n.On(EventPeerConnected, func(event *NodeEvent) {
+		n.handshake(ctx, event.PeerID, cluster)
+	})
+	n.On(EventPeerDisconnected, func(event *NodeEvent) {
+		if !n.IsConnected(event.PeerID) {
+			cluster.Remove(event.PeerID.String())
+		}
+	})
+}
+
+// handshake exchanges infos with a peer that connected
+func (n *P2PNode) handshake(ctx context.Context, peerID peer.ID, cluster *compat.Cluster) {
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+
+	stream, err := n.host.NewStream(ctx, peerID, HandshakeProtocol)
+	if err != nil {
+		// Peers from before the handshake identify themselves without its
+		// protocol
+		known, _ := n.host.Peerstore().GetProtocols(peerID)
+		if supported, _ := n.host.Peerstore().SupportsProtocols(peerID, HandshakeProtocol); len(known) > 0 && len(supported) == 0 {
+			n.admit(peerID, compat.Legacy(peerID.String()), cluster)
+		}
+		return
+	}
+	defer stream.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	if err := writeInfo(stream, cluster.Local()); err != nil {
+		return
+	}
+	var remote compat.Info
+	line, err := bufio.NewReader(stream).ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &remote) != nil {
+		return
+	}
+	remote.NodeID = peerID.String()
+	n.admit(peerID, remote, cluster)
+}
+
+// admit admits a peer to cluster, blocking it if it is incompatible
+func (n *P2PNode) admit(peerID peer.ID, remote compat.Info, cluster *compat.Cluster) {
+	if _, err := cluster.Admit(remote); errors.Is(err, compat.ErrIncompatible) {
+		log.Printf("Refusing peer %s for %s: %v", peerID, incompatibleBlockTTL, err)
+		n.Block(peerID, incompatibleBlockTTL)
+	}
+}
+
+// writeInfo writes an info as a JSON line
+func writeInfo(stream network.Stream, info compat.Info) error {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = stream.Write(append(payload, '\n'))
+	return err
+}
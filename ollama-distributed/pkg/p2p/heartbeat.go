@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/topology"
+)
+
+// HeartbeatProtocol carries the heartbeats nodes send each other so that
+// failure detectors can judge how late a node is. Each heartbeat is a
+// probe, holding the RTTs its sender measured to its peers, that the
+// receiver echoes with a single byte; the sender times the echo to measure
+// the RTT to the receiver without relying on either clock.
+const HeartbeatProtocol = protocol.ID("/ollama-distributed/heartbeat/1.1.0")
+
+// LegacyHeartbeatProtocol carries the heartbeats of nodes from before the
+// probes: opening a stream is the heartbeat and nothing is written on it.
+// It is sent while some member lacks compat.FeatureHeartbeatRTT.
+const LegacyHeartbeatProtocol = protocol.ID("/ollama-distributed/heartbeat/1.0.0")
+
+// heartbeatProbe is the payload of a heartbeat
+type heartbeatProbe struct {
+	RTTs map[string]time.Duration `json:"rtts,omitempty"`
+}
+
+// StartHeartbeats sends a heartbeat to every connected peer each interval
+// until ctx is done, and calls onHeartbeat with the peer and arrival time
+// of every heartbeat received. With a matrix, the RTTs measured by the
+// heartbeats and the ones peers report are kept in it.
+func (n *P2PNode) StartHeartbeats(ctx context.Context, interval time.Duration, onHeartbeat func(nodeID string, at time.Time), matrix *topology.Matrix) {
+	n.host.RegisterProtocol(HeartbeatProtocol, func(stream network.Stream) {
+		defer stream.Close()
+
+		from := stream.Conn().RemotePeer().String()
+		onHeartbeat(from, time.Now())
+
+		stream.SetDeadline(time.Now().Add(interval))
+		var probe heartbeatProbe
+		line, err := bufio.NewReader(stream).ReadBytes('\n')
+		if err != nil || json.Unmarshal(line, &probe) != nil {
+			return
+		}
+		if matrix != nil {
+			matrix.Merge(from, probe.RTTs)
+		}
+		stream.Write([]byte{1})
+	})
+	n.host.RegisterProtocol(LegacyHeartbeatProtocol, func(stream network.Stream) {
+		onHeartbeat(stream.Conn().RemotePeer().String(), time.Now())
+		stream.Close()
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n.compat != nil && !n.compat.Enabled(compat.FeatureHeartbeatRTT) {
+					for _, peerID := range n.GetConnectedPeers() {
+						go n.sendLegacyHeartbeat(ctx, peerID, interval)
+					}
+					continue
+				}
+				var probe heartbeatProbe
+				if matrix != nil {
+					probe.RTTs = matrix.Row(n.ID().String())
+				}
+				for _, peerID := range n.GetConnectedPeers() {
+					go n.sendHeartbeat(ctx, peerID, interval, probe, matrix)
+				}
+			}
+		}
+	}()
+}
+
+// sendLegacyHeartbeat opens and closes a stream to a peer
+func (n *P2PNode) sendLegacyHeartbeat(ctx context.Context, peerID peer.ID, interval time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	stream, err := n.host.NewStream(ctx, peerID, LegacyHeartbeatProtocol)
+	if err != nil {
+		return
+	}
+	stream.Close()
+}
+
+// sendHeartbeat sends a probe to a peer and times its echo, giving up after
+// an interval so that slow peers do not pile up heartbeats
+func (n *P2PNode) sendHeartbeat(ctx context.Context, peerID peer.ID, interval time.Duration, probe heartbeatProbe, matrix *topology.Matrix) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	stream, err := n.host.NewStream(ctx, peerID, HeartbeatProtocol)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	payload, err := json.Marshal(probe)
+	if err != nil {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	sent := time.Now()
+	if _, err := stream.Write(append(payload, '\n')); err != nil {
+		return
+	}
+	echo := make([]byte, 1)
+	if _, err := stream.Read(echo); err != nil {
+		return
+	}
+	if matrix != nil {
+		matrix.Observe(peerID.String(), time.Since(sent))
+	}
+}
@@ -0,0 +1,117 @@
+package host
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Gater refuses connections to and from peers the cluster has not
+// admitted, and from blocked peers. Without an admission check every peer
+// that is not blocked is allowed, as before join tokens.
+type Gater struct {
+	now func() time.Time
+
+	mu sync.RWMutex
+	// admitted reports whether a peer may connect
+	admitted func(peer.ID) bool
+	// trusted peers are allowed unless blocked, e.g. the bootstrap peers
+	trusted map[peer.ID]bool
+	// blocked peers are refused until the time they map to
+	blocked map[peer.ID]time.Time
+}
+
+// NewGater creates a gater that allows every peer until an admission
+// check is set or a peer is blocked
+func NewGater() *Gater {
+	return &Gater{
+		now:     time.Now,
+		trusted: make(map[peer.ID]bool),
+		blocked: make(map[peer.ID]time.Time),
+	}
+}
+
+// SetAdmission sets the check peers must pass to connect, besides the
+// trusted ones
+func (g *Gater) SetAdmission(admitted func(peer.ID) bool, trusted ...peer.ID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.admitted = admitted
+	g.trusted = make(map[peer.ID]bool, len(trusted))
+	for _, id := range trusted {
+		g.trusted[id] = true
+	}
+}
+
+// Block refuses a peer for ttl, whether it is admitted or trusted. Discovery
+// keeps finding such a peer; blocking it stops the redials.
+func (g *Gater) Block(id peer.ID, ttl time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for blocked, until := range g.blocked {
+		if !now.Before(until) {
+			delete(g.blocked, blocked)
+		}
+	}
+	g.blocked[id] = now.Add(ttl)
+}
+
+// Blocked reports whether a peer is blocked
+func (g *Gater) Blocked(id peer.ID) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.isBlocked(id)
+}
+
+// isBlocked reports whether a peer is blocked; g.mu must be held
+func (g *Gater) isBlocked(id peer.ID) bool {
+	until, ok := g.blocked[id]
+	return ok && g.now().Before(until)
+}
+
+// Allowed reports whether a peer may connect
+func (g *Gater) Allowed(id peer.ID) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.isBlocked(id) {
+		return false
+	}
+	return g.admitted == nil || g.trusted[id] || g.admitted(id)
+}
+
+// InterceptPeerDial refuses dialing peers that are not allowed
+func (g *Gater) InterceptPeerDial(id peer.ID) bool {
+	return g.Allowed(id)
+}
+
+// InterceptAddrDial refuses dialing peers that are not allowed
+func (g *Gater) InterceptAddrDial(id peer.ID, _ multiaddr.Multiaddr) bool {
+	return g.Allowed(id)
+}
+
+// InterceptAccept accepts every inbound connection; the peer is only known
+// once the connection is secured
+func (g *Gater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+// InterceptSecured refuses connections of peers that are not allowed, in
+// both directions
+func (g *Gater) InterceptSecured(_ network.Direction, id peer.ID, _ network.ConnMultiaddrs) bool {
+	return g.Allowed(id)
+}
+
+// InterceptUpgraded accepts every connection that got this far
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// Gater returns the gater of the host's connections
+func (h *P2PHost) Gater() *Gater {
+	return h.gater
+}
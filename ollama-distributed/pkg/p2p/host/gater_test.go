@@ -0,0 +1,46 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaterAdmission(t *testing.T) {
+	gater := NewGater()
+	assert.True(t, gater.InterceptPeerDial("stranger"), "every peer is allowed without an admission check")
+
+	admitted := map[peer.ID]bool{"joined": true}
+	gater.SetAdmission(func(id peer.ID) bool { return admitted[id] }, "bootstrap")
+	assert.True(t, gater.InterceptSecured(network.DirInbound, "joined", nil))
+	assert.True(t, gater.InterceptSecured(network.DirOutbound, "bootstrap", nil), "bootstrap peers are trusted")
+	assert.False(t, gater.InterceptSecured(network.DirInbound, "stranger", nil))
+	assert.False(t, gater.InterceptPeerDial("stranger"))
+
+	delete(admitted, "joined")
+	assert.False(t, gater.InterceptPeerDial("joined"), "admission is checked on every connection")
+}
+
+func TestGaterBlocklist(t *testing.T) {
+	gater := NewGater()
+	now := time.Now()
+	gater.now = func() time.Time { return now }
+	gater.SetAdmission(func(peer.ID) bool { return true }, "bootstrap")
+
+	gater.Block("incompatible", time.Minute)
+	gater.Block("bootstrap", 2*time.Minute)
+	assert.True(t, gater.Blocked("incompatible"))
+	assert.False(t, gater.InterceptPeerDial("incompatible"), "blocked peers are not redialed")
+	assert.False(t, gater.InterceptSecured(network.DirInbound, "bootstrap", nil), "blocking overrides trust")
+	assert.True(t, gater.InterceptPeerDial("other"))
+
+	now = now.Add(time.Minute)
+	assert.True(t, gater.InterceptPeerDial("incompatible"), "blocks expire")
+	assert.False(t, gater.InterceptPeerDial("bootstrap"))
+
+	gater.Block("other", time.Minute)
+	assert.NotContains(t, gater.blocked, peer.ID("incompatible"), "expired blocks are purged")
+}
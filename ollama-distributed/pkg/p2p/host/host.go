@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
@@ -17,6 +18,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
+	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
 	libp2pwebtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
@@ -41,7 +43,16 @@ type P2PHost struct {
 	disconnectHandler func(network.Network, network.Conn)
 
 	// Metrics
-	metrics *HostMetrics
+	metrics        *HostMetrics
+	transportConns map[string]int
+	transportMux   sync.RWMutex
+	rtt            *rttTracker
+
+	// quicGate, when set, must allow routing streams over QUIC
+	quicGate atomic.Pointer[func() bool]
+
+	// gater refuses connections of peers the cluster has not admitted
+	gater *Gater
 
 	// NAT traversal
 	natManager *nat.NATTraversalManager
@@ -78,6 +89,9 @@ type HostMetrics struct {
 	EarlySuccesses      int64
 	ConnectionTimeouts  int64
 	BackoffRetries      int64
+
+	// QUIC metrics
+	QUICFallbacks int64
 }
 
 // ConnectionTracker manages optimized connection attempts
@@ -119,15 +133,7 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 	}
 
 	// Build listen addresses
-	listenAddrs := make([]multiaddr.Multiaddr, 0, len(config.Listen))
-	for _, addr := range config.Listen {
-		maddr, err := multiaddr.NewMultiaddr(addr)
-		if err != nil {
-			log.Printf("Invalid listen address %s: %v", addr, err)
-			continue
-		}
-		listenAddrs = append(listenAddrs, maddr)
-	}
+	listenAddrs := parseMultiaddrs(config.Listen, "listen")
 
 	// Configure transports
 	transports := []libp2p.Option{
@@ -135,6 +141,9 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 		libp2p.Transport(websocket.New),
 		libp2p.Transport(libp2pwebtransport.New),
 	}
+	if config.EnableQUIC {
+		transports = append(transports, libp2p.Transport(libp2pquic.NewTransport))
+	}
 
 	// Configure security
 	security := []libp2p.Option{}
@@ -232,10 +241,12 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 	}
 
 	// Build host options
+	gater := NewGater()
 	opts := []libp2p.Option{
 		libp2p.Identity(priv),
 		libp2p.ListenAddrs(listenAddrs...),
 		libp2p.ConnectionManager(connMgr),
+		libp2p.ConnectionGater(gater),
 		libp2p.EnableRelay(),
 	}
 
@@ -244,20 +255,11 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 	opts = append(opts, security...)
 	opts = append(opts, natOptions...)
 
-	// Add announce addresses
-	if len(config.AnnounceAddresses) > 0 {
-		announceAddrs := make([]multiaddr.Multiaddr, 0, len(config.AnnounceAddresses))
-		for _, addr := range config.AnnounceAddresses {
-			maddr, err := multiaddr.NewMultiaddr(addr)
-			if err != nil {
-				log.Printf("Invalid announce address %s: %v", addr, err)
-				continue
-			}
-			announceAddrs = append(announceAddrs, maddr)
-		}
-		opts = append(opts, libp2p.AddrsFactory(func([]multiaddr.Multiaddr) []multiaddr.Multiaddr {
-			return announceAddrs
-		}))
+	// Advertise every listen address plus any configured announce addresses
+	if len(config.AnnounceAddresses) > 0 || len(config.NoAnnounceAddresses) > 0 {
+		announceAddrs := parseMultiaddrs(config.AnnounceAddresses, "announce")
+		noAnnounceAddrs := parseMultiaddrs(config.NoAnnounceAddresses, "no-announce")
+		opts = append(opts, libp2p.AddrsFactory(announceAddrsFactory(announceAddrs, noAnnounceAddrs)))
 	}
 
 	// Create host
@@ -275,6 +277,9 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 		metrics: &HostMetrics{
 			StartTime: time.Now(),
 		},
+		transportConns:    make(map[string]int),
+		rtt:               newRTTTracker(),
+		gater:             gater,
 		natManager:        natManager,
 		connectionTracker: connTracker,
 		connectionPool:    NewConnectionPool(libp2pHost, poolConfig),
@@ -295,6 +300,9 @@ func NewP2PHost(ctx context.Context, config *config.NodeConfig) (*P2PHost, error
 	// Start NAT discovery
 	go p2pHost.performNATDiscovery()
 
+	// Start measuring connection round-trip times
+	go p2pHost.measureRTT()
+
 	log.Printf("P2P host created with ID: %s", libp2pHost.ID())
 	log.Printf("Listen addresses: %v", libp2pHost.Addrs())
 
@@ -310,7 +318,8 @@ func (h *P2PHost) setupEventHandlers() {
 		ConnectedF: func(net network.Network, conn network.Conn) {
 			h.metrics.ConnectionCount++
 			h.metrics.LastActivity = time.Now()
-			log.Printf("Connected to peer: %s", conn.RemotePeer())
+			h.recordTransportConn(conn, 1)
+			log.Printf("Connected to peer: %s via %s", conn.RemotePeer(), TransportName(conn.RemoteMultiaddr()))
 
 			if h.connectHandler != nil {
 				h.connectHandler(net, conn)
@@ -319,6 +328,7 @@ func (h *P2PHost) setupEventHandlers() {
 		DisconnectedF: func(net network.Network, conn network.Conn) {
 			h.metrics.ConnectionCount--
 			h.metrics.LastActivity = time.Now()
+			h.recordTransportConn(conn, -1)
 			log.Printf("Disconnected from peer: %s", conn.RemotePeer())
 
 			if h.disconnectHandler != nil {
@@ -578,7 +588,7 @@ func (c *ConnectionTracker) performParallelConnection(attempt *ConnectionAttempt
 				time.Sleep(time.Duration(attemptNum) * 100 * time.Millisecond)
 			}
 
-			err := c.host.Connect(attempt.Ctx, peerInfo)
+			err := c.host.Connect(attempt.Ctx, peer.AddrInfo{ID: peerInfo.ID, Addrs: PreferredDialOrder(peerInfo.Addrs)})
 			resultChan <- err
 
 			// Signal early success
@@ -696,6 +706,20 @@ func (h *P2PHost) RecordBandwidthUsage(peerID peer.ID, protocol string, bytesSen
 	h.bandwidthManager.RecordUsage(peerID, protocol, bytesSent, bytesReceived)
 }
 
+// parseMultiaddrs parses addresses, logging and skipping invalid entries
+func parseMultiaddrs(addrs []string, kind string) []multiaddr.Multiaddr {
+	parsed := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			log.Printf("Invalid %s address %s: %v", kind, addr, err)
+			continue
+		}
+		parsed = append(parsed, maddr)
+	}
+	return parsed
+}
+
 // loadOrGenerateKey loads existing key or generates new one
 func loadOrGenerateKey(config *config.NodeConfig) (crypto.PrivKey, error) {
 	// Try to load existing key
@@ -0,0 +1,248 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// rttAlpha is the smoothing factor for the per-peer RTT moving average
+const rttAlpha = 0.2
+
+// PeerRTT holds smoothed round-trip times to a peer per transport
+type PeerRTT struct {
+	ByTransport map[string]time.Duration `json:"by_transport"`
+	Samples     int64                    `json:"samples"`
+	LastSample  time.Time                `json:"last_sample"`
+}
+
+// rttTracker keeps an exponentially weighted RTT per peer and transport
+type rttTracker struct {
+	mu    sync.RWMutex
+	peers map[peer.ID]*PeerRTT
+}
+
+func newRTTTracker() *rttTracker {
+	return &rttTracker{peers: make(map[peer.ID]*PeerRTT)}
+}
+
+func (t *rttTracker) record(peerID peer.ID, transport string, rtt time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.peers[peerID]
+	if !ok {
+		stats = &PeerRTT{ByTransport: make(map[string]time.Duration)}
+		t.peers[peerID] = stats
+	}
+
+	if prev, ok := stats.ByTransport[transport]; ok {
+		stats.ByTransport[transport] = time.Duration(rttAlpha*float64(rtt) + (1-rttAlpha)*float64(prev))
+	} else {
+		stats.ByTransport[transport] = rtt
+	}
+	stats.Samples++
+	stats.LastSample = time.Now()
+}
+
+// best returns the lowest smoothed RTT across the peer's transports
+func (t *rttTracker) best(peerID peer.ID) (time.Duration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	stats, ok := t.peers[peerID]
+	if !ok {
+		return 0, false
+	}
+	var best time.Duration
+	for _, rtt := range stats.ByTransport {
+		if best == 0 || rtt < best {
+			best = rtt
+		}
+	}
+	return best, best > 0
+}
+
+func (t *rttTracker) snapshot() map[peer.ID]*PeerRTT {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make(map[peer.ID]*PeerRTT, len(t.peers))
+	for id, stats := range t.peers {
+		byTransport := make(map[string]time.Duration, len(stats.ByTransport))
+		for k, v := range stats.ByTransport {
+			byTransport[k] = v
+		}
+		out[id] = &PeerRTT{ByTransport: byTransport, Samples: stats.Samples, LastSample: stats.LastSample}
+	}
+	return out
+}
+
+// pingPayloadSize is the size of the payload echoed by the ping protocol
+const pingPayloadSize = 32
+
+// rttInterval is how often the round-trip time of every connection is
+// measured
+const rttInterval = 15 * time.Second
+
+// pingStream writes a random payload on a ping stream and times the echo.
+// The remote ping handler echoes without doing any work, so the time is
+// the network round trip of the connection the stream runs on.
+func pingStream(stream io.ReadWriter) (time.Duration, error) {
+	payload := make([]byte, pingPayloadSize)
+	if _, err := rand.Read(payload); err != nil {
+		return 0, err
+	}
+	echo := make([]byte, pingPayloadSize)
+
+	start := time.Now()
+	if _, err := stream.Write(payload); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(stream, echo); err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	if !bytes.Equal(payload, echo) {
+		return 0, fmt.Errorf("ping echo does not match the payload")
+	}
+	return rtt, nil
+}
+
+// measureRTT periodically pings every open connection
+func (h *P2PHost) measureRTT() {
+	ticker := time.NewTicker(rttInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, conn := range h.Host.Network().Conns() {
+				h.pingConn(conn)
+			}
+		}
+	}
+}
+
+// pingConn measures the round-trip time of a connection with the ping
+// protocol, recording it for the connection's transport
+func (h *P2PHost) pingConn(conn network.Conn) {
+	ctx, cancel := context.WithTimeout(h.ctx, 5*time.Second)
+	defer cancel()
+
+	stream, err := h.newStreamOnConn(ctx, conn, ping.ID)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	rtt, err := pingStream(stream)
+	if err != nil {
+		stream.Reset()
+		return
+	}
+	peerID := conn.RemotePeer()
+	h.rtt.record(peerID, TransportName(conn.RemoteMultiaddr()), rtt)
+	h.Host.Peerstore().RecordLatency(peerID, rtt)
+}
+
+// NewStream opens a stream to the peer. Protocols listed in
+// QUICPreferredProtocols are opened on an existing QUIC connection when
+// there is one, falling back to the default connection (usually TCP)
+// otherwise.
+func (h *P2PHost) NewStream(ctx context.Context, peerID peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	if len(pids) == 1 && h.prefersQUIC(pids[0]) {
+		if conn := h.quicConnTo(peerID); conn != nil {
+			stream, err := h.newStreamOnConn(ctx, conn, pids[0])
+			if err == nil {
+				return stream, nil
+			}
+			h.metrics.QUICFallbacks++
+			log.Printf("QUIC stream to %s for %s failed, falling back: %v", peerID, pids[0], err)
+		}
+	}
+
+	return h.Host.NewStream(ctx, peerID, pids...)
+}
+
+// SetQUICGate sets a check consulted before routing a stream over QUIC,
+// e.g. a feature flag, so QUIC routing can be turned off at runtime
+func (h *P2PHost) SetQUICGate(gate func() bool) {
+	h.quicGate.Store(&gate)
+}
+
+func (h *P2PHost) prefersQUIC(pid protocol.ID) bool {
+	if !h.config.EnableQUIC {
+		return false
+	}
+	if gate := h.quicGate.Load(); gate != nil && !(*gate)() {
+		return false
+	}
+	for _, p := range h.config.QUICPreferredProtocols {
+		if protocol.ID(p) == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// quicConnTo returns an open direct QUIC connection to the peer, if any
+func (h *P2PHost) quicConnTo(peerID peer.ID) network.Conn {
+	for _, conn := range h.Host.Network().ConnsToPeer(peerID) {
+		if TransportName(conn.RemoteMultiaddr()) == TransportQUIC {
+			return conn
+		}
+	}
+	return nil
+}
+
+// newStreamOnConn opens a stream on a specific connection and negotiates
+// the protocol, bypassing the swarm's connection selection
+func (h *P2PHost) newStreamOnConn(ctx context.Context, conn network.Conn, pid protocol.ID) (network.Stream, error) {
+	stream, err := conn.NewStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+		defer stream.SetDeadline(time.Time{})
+	}
+
+	if err := msmux.SelectProtoOrFail(pid, stream); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("failed to negotiate %s over QUIC: %w", pid, err)
+	}
+	if err := stream.SetProtocol(pid); err != nil {
+		stream.Reset()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// GetPeerRTT returns the lowest smoothed RTT measured to the peer across
+// all transports
+func (h *P2PHost) GetPeerRTT(peerID peer.ID) (time.Duration, bool) {
+	return h.rtt.best(peerID)
+}
+
+// GetRTTMetrics returns smoothed RTTs for every peer, per transport
+func (h *P2PHost) GetRTTMetrics() map[peer.ID]*PeerRTT {
+	return h.rtt.snapshot()
+}
@@ -0,0 +1,61 @@
+package host
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTTTrackerSmoothsAndPicksBest(t *testing.T) {
+	tracker := newRTTTracker()
+	id := peer.ID("peer-a")
+
+	_, ok := tracker.best(id)
+	assert.False(t, ok)
+
+	tracker.record(id, TransportTCP, 40*time.Millisecond)
+	tracker.record(id, TransportQUIC, 20*time.Millisecond)
+	tracker.record(id, TransportQUIC, 30*time.Millisecond)
+
+	best, ok := tracker.best(id)
+	assert.True(t, ok)
+	assert.Equal(t, 22*time.Millisecond, best)
+
+	snapshot := tracker.snapshot()
+	assert.Equal(t, int64(3), snapshot[id].Samples)
+	assert.Equal(t, 40*time.Millisecond, snapshot[id].ByTransport[TransportTCP])
+}
+
+func TestPingStreamTimesTheEcho(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	go func() {
+		defer remote.Close()
+		buf := make([]byte, pingPayloadSize)
+		if _, err := io.ReadFull(remote, buf); err != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		remote.Write(buf)
+	}()
+
+	rtt, err := pingStream(local)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, rtt, 10*time.Millisecond)
+
+	bad, remote := net.Pipe()
+	defer bad.Close()
+	go func() {
+		defer remote.Close()
+		buf := make([]byte, pingPayloadSize)
+		io.ReadFull(remote, buf)
+		remote.Write(make([]byte, pingPayloadSize))
+	}()
+	_, err = pingStream(bad)
+	assert.Error(t, err, "an echo that does not match is not a sample")
+}
@@ -0,0 +1,159 @@
+package host
+
+import (
+	"context"
+	"sort"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Transport names used for per-transport connection metrics
+const (
+	TransportQUIC         = "quic"
+	TransportTCP          = "tcp"
+	TransportWebSocket    = "websocket"
+	TransportWebTransport = "webtransport"
+	TransportRelay        = "relay"
+	TransportUnknown      = "unknown"
+)
+
+// transportPreference orders transports when dialing; lower dials first.
+// QUIC is preferred because it avoids the extra TCP+security+muxer round
+// trips and copes better with packet loss on WAN links.
+var transportPreference = map[string]int{
+	TransportQUIC:         0,
+	TransportWebTransport: 1,
+	TransportTCP:          2,
+	TransportWebSocket:    3,
+	TransportRelay:        4,
+	TransportUnknown:      5,
+}
+
+// TransportName returns the transport a multiaddr dials over
+func TransportName(addr multiaddr.Multiaddr) string {
+	if addr == nil {
+		return TransportUnknown
+	}
+
+	name := TransportUnknown
+	for _, p := range addr.Protocols() {
+		switch p.Code {
+		case multiaddr.P_CIRCUIT:
+			return TransportRelay
+		case multiaddr.P_WEBTRANSPORT:
+			name = TransportWebTransport
+		case multiaddr.P_QUIC, multiaddr.P_QUIC_V1:
+			if name != TransportWebTransport {
+				name = TransportQUIC
+			}
+		case multiaddr.P_WS, multiaddr.P_WSS:
+			name = TransportWebSocket
+		case multiaddr.P_TCP:
+			if name == TransportUnknown {
+				name = TransportTCP
+			}
+		}
+	}
+	return name
+}
+
+// AddressFamily returns "ip4" or "ip6" for the multiaddr, or "" when the
+// address is not IP based (e.g. DNS names)
+func AddressFamily(addr multiaddr.Multiaddr) string {
+	if addr == nil {
+		return ""
+	}
+	for _, p := range addr.Protocols() {
+		switch p.Code {
+		case multiaddr.P_IP4:
+			return "ip4"
+		case multiaddr.P_IP6:
+			return "ip6"
+		}
+	}
+	return ""
+}
+
+// transportKey is the metrics key for a connection, e.g. "quic/ip6"
+func transportKey(addr multiaddr.Multiaddr) string {
+	name := TransportName(addr)
+	if family := AddressFamily(addr); family != "" {
+		return name + "/" + family
+	}
+	return name
+}
+
+// PreferredDialOrder returns a copy of addrs sorted so that QUIC addresses
+// are tried before TCP, keeping the original order within each transport.
+func PreferredDialOrder(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	ordered := make([]multiaddr.Multiaddr, len(addrs))
+	copy(ordered, addrs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return transportPreference[TransportName(ordered[i])] < transportPreference[TransportName(ordered[j])]
+	})
+	return ordered
+}
+
+// Connect dials the peer, trying QUIC addresses before other transports
+func (h *P2PHost) Connect(ctx context.Context, peerInfo peer.AddrInfo) error {
+	peerInfo.Addrs = PreferredDialOrder(peerInfo.Addrs)
+	return h.Host.Connect(ctx, peerInfo)
+}
+
+// recordTransportConn adjusts the open connection count for conn's transport
+func (h *P2PHost) recordTransportConn(conn network.Conn, delta int) {
+	key := transportKey(conn.RemoteMultiaddr())
+
+	h.transportMux.Lock()
+	defer h.transportMux.Unlock()
+
+	h.transportConns[key] += delta
+	if h.transportConns[key] <= 0 {
+		delete(h.transportConns, key)
+	}
+}
+
+// GetTransportMetrics returns the number of open connections per transport
+// and address family, keyed like "quic/ip4" or "tcp/ip6"
+func (h *P2PHost) GetTransportMetrics() map[string]int {
+	h.transportMux.RLock()
+	defer h.transportMux.RUnlock()
+
+	snapshot := make(map[string]int, len(h.transportConns))
+	for k, v := range h.transportConns {
+		snapshot[k] = v
+	}
+	return snapshot
+}
+
+// announceAddrsFactory builds the libp2p address factory that merges the
+// host's own listen addresses with configured announce addresses so that
+// every reachable IPv4, IPv6 and QUIC address is advertised.
+func announceAddrsFactory(announce, noAnnounce []multiaddr.Multiaddr) func([]multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	return func(listen []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+		seen := make(map[string]bool, len(listen)+len(announce))
+		addrs := make([]multiaddr.Multiaddr, 0, len(listen)+len(announce))
+
+		for _, addr := range append(append([]multiaddr.Multiaddr{}, listen...), announce...) {
+			key := string(addr.Bytes())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			excluded := false
+			for _, na := range noAnnounce {
+				if addr.Equal(na) {
+					excluded = true
+					break
+				}
+			}
+			if !excluded {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs
+	}
+}
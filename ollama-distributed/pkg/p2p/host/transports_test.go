@@ -0,0 +1,72 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustAddrs(t *testing.T, addrs ...string) []multiaddr.Multiaddr {
+	t.Helper()
+	parsed := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		maddr, err := multiaddr.NewMultiaddr(a)
+		require.NoError(t, err)
+		parsed = append(parsed, maddr)
+	}
+	return parsed
+}
+
+func TestTransportName(t *testing.T) {
+	cases := map[string]string{
+		"/ip4/127.0.0.1/tcp/4001":                     TransportTCP,
+		"/ip6/::1/tcp/4001":                           TransportTCP,
+		"/ip4/127.0.0.1/udp/4001/quic-v1":             TransportQUIC,
+		"/ip6/::1/udp/4001/quic-v1/webtransport":      TransportWebTransport,
+		"/ip4/127.0.0.1/tcp/4001/ws":                  TransportWebSocket,
+		"/ip4/127.0.0.1/udp/4001/quic-v1/p2p-circuit": TransportRelay,
+	}
+
+	for addr, want := range cases {
+		maddr := mustAddrs(t, addr)[0]
+		assert.Equal(t, want, TransportName(maddr), addr)
+	}
+}
+
+func TestTransportKeyIncludesFamily(t *testing.T) {
+	addrs := mustAddrs(t, "/ip6/::1/udp/4001/quic-v1", "/ip4/10.0.0.1/tcp/4001")
+	assert.Equal(t, "quic/ip6", transportKey(addrs[0]))
+	assert.Equal(t, "tcp/ip4", transportKey(addrs[1]))
+}
+
+func TestPreferredDialOrder(t *testing.T) {
+	addrs := mustAddrs(t,
+		"/ip4/10.0.0.1/tcp/4001",
+		"/ip6/::1/tcp/4001",
+		"/ip4/10.0.0.1/udp/4001/quic-v1",
+		"/ip6/::1/udp/4001/quic-v1",
+	)
+
+	ordered := PreferredDialOrder(addrs)
+	require.Len(t, ordered, 4)
+	assert.True(t, ordered[0].Equal(addrs[2]))
+	assert.True(t, ordered[1].Equal(addrs[3]))
+	assert.True(t, ordered[2].Equal(addrs[0]))
+	assert.True(t, ordered[3].Equal(addrs[1]))
+
+	// The input slice is left untouched
+	assert.True(t, addrs[0].Equal(mustAddrs(t, "/ip4/10.0.0.1/tcp/4001")[0]))
+}
+
+func TestAnnounceAddrsFactory(t *testing.T) {
+	listen := mustAddrs(t, "/ip4/10.0.0.1/tcp/4001", "/ip6/::1/tcp/4001")
+	announce := mustAddrs(t, "/ip4/203.0.113.5/tcp/4001", "/ip4/10.0.0.1/tcp/4001")
+	noAnnounce := mustAddrs(t, "/ip6/::1/tcp/4001")
+
+	addrs := announceAddrsFactory(announce, noAnnounce)(listen)
+	require.Len(t, addrs, 2)
+	assert.True(t, addrs[0].Equal(listen[0]))
+	assert.True(t, addrs[1].Equal(announce[0]))
+}
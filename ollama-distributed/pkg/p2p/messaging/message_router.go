@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/qos"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 )
@@ -18,9 +19,9 @@ type MessageRouter struct {
 	handlers   map[protocol.ID]ProtocolHandler
 	handlersMu sync.RWMutex
 
-	// Message queues
-	outboundQueue *MessageQueue
-	inboundQueue  *MessageQueue
+	// Message queues; outbound messages queue by QoS class
+	outbound     *qos.Queue[*Message]
+	inboundQueue *MessageQueue
 
 	// Connection management
 	connections   map[peer.ID]*PeerConnection
@@ -72,6 +73,9 @@ type RouterConfig struct {
 	EnableAcknowledgments    bool
 	AckTimeout               time.Duration
 	EnableDuplicateDetection bool
+
+	// QoS settings; QoS.QueueSize defaults to MaxQueueSize
+	QoS qos.Config
 }
 
 // Message represents a message in the distributed system
@@ -192,6 +196,9 @@ type RouterMetrics struct {
 	AverageLatency    time.Duration
 	MessageThroughput float64
 
+	// Outbound traffic and queuing delay by QoS class
+	QoS []qos.Stats
+
 	// Last updated
 	LastUpdated time.Time
 	mu          sync.RWMutex
@@ -251,28 +258,34 @@ type MessageSerializer interface {
 	Deserialize(data []byte) (*Message, error)
 }
 
+// DefaultRouterConfig returns the default router configuration
+func DefaultRouterConfig() *RouterConfig {
+	return &RouterConfig{
+		MaxQueueSize:             10000,
+		QueueTimeout:             30 * time.Second,
+		MaxConnections:           1000,
+		ConnectionTimeout:        30 * time.Second,
+		KeepAliveInterval:        30 * time.Second,
+		MaxMessageSize:           10 * 1024 * 1024, // 10MB
+		MessageTimeout:           30 * time.Second,
+		RetryAttempts:            3,
+		RetryBackoff:             time.Second,
+		RoutingTableSize:         10000,
+		RouteRefreshInterval:     5 * time.Minute,
+		WorkerCount:              10,
+		BufferSize:               1024,
+		EnableCompression:        true,
+		EnableAcknowledgments:    true,
+		AckTimeout:               10 * time.Second,
+		EnableDuplicateDetection: true,
+		QoS:                      qos.Config{Enabled: true},
+	}
+}
+
 // NewMessageRouter creates a new message router
 func NewMessageRouter(config *RouterConfig) *MessageRouter {
 	if config == nil {
-		config = &RouterConfig{
-			MaxQueueSize:             10000,
-			QueueTimeout:             30 * time.Second,
-			MaxConnections:           1000,
-			ConnectionTimeout:        30 * time.Second,
-			KeepAliveInterval:        30 * time.Second,
-			MaxMessageSize:           10 * 1024 * 1024, // 10MB
-			MessageTimeout:           30 * time.Second,
-			RetryAttempts:            3,
-			RetryBackoff:             time.Second,
-			RoutingTableSize:         10000,
-			RouteRefreshInterval:     5 * time.Minute,
-			WorkerCount:              10,
-			BufferSize:               1024,
-			EnableCompression:        true,
-			EnableAcknowledgments:    true,
-			AckTimeout:               10 * time.Second,
-			EnableDuplicateDetection: true,
-		}
+		config = DefaultRouterConfig()
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -290,7 +303,11 @@ func NewMessageRouter(config *RouterConfig) *MessageRouter {
 	}
 
 	// Initialize message queues
-	router.outboundQueue = NewMessageQueue(config.MaxQueueSize, config.QueueTimeout)
+	qosConfig := config.QoS
+	if qosConfig.QueueSize == 0 {
+		qosConfig.QueueSize = config.MaxQueueSize
+	}
+	router.outbound = qos.NewQueue[*Message](qosConfig)
 	router.inboundQueue = NewMessageQueue(config.MaxQueueSize, config.QueueTimeout)
 
 	// Initialize routing table
@@ -342,7 +359,6 @@ func (mr *MessageRouter) Stop() error {
 	mr.wg.Wait()
 
 	// Close queues
-	mr.outboundQueue.Close()
 	mr.inboundQueue.Close()
 
 	// Close connections
@@ -388,19 +404,19 @@ func (mr *MessageRouter) SendMessage(msg *Message) error {
 		}
 	}
 
-	// Add to outbound queue
-	select {
-	case mr.outboundQueue.messages <- msg:
-		mr.metrics.mu.Lock()
-		mr.metrics.TotalMessages++
-		mr.metrics.mu.Unlock()
-		return nil
-	case <-time.After(mr.config.QueueTimeout):
+	// Add to the outbound queue of its QoS class
+	ctx, cancel := context.WithTimeout(mr.ctx, mr.config.QueueTimeout)
+	defer cancel()
+	if err := mr.outbound.Enqueue(ctx, mr.classOf(msg), msg, messageSize(msg)); err != nil {
 		mr.metrics.mu.Lock()
 		mr.metrics.MessagesDropped++
 		mr.metrics.mu.Unlock()
-		return fmt.Errorf("outbound queue timeout")
+		return fmt.Errorf("outbound queue timeout: %w", err)
 	}
+	mr.metrics.mu.Lock()
+	mr.metrics.TotalMessages++
+	mr.metrics.mu.Unlock()
+	return nil
 }
 
 // BroadcastMessage broadcasts a message to all connected peers
@@ -506,17 +522,16 @@ func (mq *MessageQueue) Close() {
 
 // Worker functions
 
-// outboundWorker processes outbound messages
+// outboundWorker processes outbound messages in QoS order
 func (mr *MessageRouter) outboundWorker() {
 	defer mr.wg.Done()
 
 	for {
-		select {
-		case <-mr.ctx.Done():
+		msg, _, err := mr.outbound.Dequeue(mr.ctx)
+		if err != nil {
 			return
-		case msg := <-mr.outboundQueue.messages:
-			mr.processOutboundMessage(msg)
 		}
+		mr.processOutboundMessage(msg)
 	}
 }
 
@@ -750,7 +765,7 @@ func (mr *MessageRouter) updateMetrics() {
 	defer mr.metrics.mu.Unlock()
 
 	// Update queue sizes
-	mr.metrics.OutboundQueueSize = int64(mr.outboundQueue.Size())
+	mr.metrics.OutboundQueueSize = int64(mr.outbound.Len())
 	mr.metrics.InboundQueueSize = int64(mr.inboundQueue.Size())
 
 	// Update connection count
@@ -950,6 +965,7 @@ func (mr *MessageRouter) GetMetrics() *RouterMetrics {
 		RoutingFailures:    mr.metrics.RoutingFailures,
 		AverageLatency:     mr.metrics.AverageLatency,
 		MessageThroughput:  mr.metrics.MessageThroughput,
+		QoS:                mr.outbound.Stats(),
 		LastUpdated:        mr.metrics.LastUpdated,
 	}
 }
@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/qos"
+)
+
+// QoSHeader lets a sender pick the QoS class of a message instead of the
+// one its type implies
+const QoSHeader = "qos"
+
+// messageOverhead approximates the bytes a message takes besides its
+// payload, so that empty heartbeats still count against their class
+const messageOverhead = 128
+
+// QoSClassOf returns the QoS class of a message: consensus, health, control
+// and acknowledgment messages are control-plane traffic, model and data
+// transfers are bulk, and the rest is interactive
+func QoSClassOf(msg *Message) qos.Class {
+	if class := qos.Class(msg.Headers[QoSHeader]); qos.Known(class) {
+		return class
+	}
+	switch msg.Type {
+	case MessageTypeConsensus, MessageTypeHealth, MessageTypeControl, MessageTypeAck:
+		return qos.Control
+	case MessageTypeModel, MessageTypeData:
+		return qos.Bulk
+	default:
+		return qos.Interactive
+	}
+}
+
+// classOf returns the class a message queues in; without QoS every
+// message shares one queue
+func (mr *MessageRouter) classOf(msg *Message) qos.Class {
+	if !mr.config.QoS.Enabled {
+		return qos.Interactive
+	}
+	return QoSClassOf(msg)
+}
+
+// messageSize returns the bytes a message is charged against its class
+func messageSize(msg *Message) int {
+	return len(msg.Payload) + messageOverhead
+}
+
+// SetQueueDelayObserver sets a function called with how long each outbound
+// message waited in the queue of its QoS class
+func (mr *MessageRouter) SetQueueDelayObserver(observer func(class string, delay time.Duration)) {
+	mr.outbound.SetObserver(func(class qos.Class, delay time.Duration) {
+		observer(string(class), delay)
+	})
+}
+
+// QoSStats returns the outbound traffic and queuing delay of every QoS
+// class
+func (mr *MessageRouter) QoSStats() []qos.Stats {
+	return mr.outbound.Stats()
+}
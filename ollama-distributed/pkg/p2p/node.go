@@ -4,10 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"runtime"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -17,13 +13,16 @@ import (
 	"github.com/multiformats/go-multiaddr"
 
 	internalconfig "github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/compat"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/discovery"
 	p2phost "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/host"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/reputation"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/resources"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/routing"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/security"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/sysmetrics"
 )
 
 // Node is an alias for P2PNode for compatibility
@@ -43,8 +42,11 @@ type P2PNode struct {
 	contentRouter      *routing.ContentRouter
 
 	// Node state
-	capabilities    *resources.NodeCapabilities
-	resourceMetrics *resources.ResourceMetrics
+	capabilities      *resources.NodeCapabilities
+	resourceMetrics   *resources.ResourceMetrics
+	resourceCollector *sysmetrics.Collector
+	reputation        *reputation.Tracker
+	compat            *compat.Cluster
 
 	// Event handlers
 	eventHandlers map[string][]EventHandler
@@ -137,7 +139,12 @@ func NewNode(ctx context.Context, p2pConfig *internalconfig.P2PConfig) (*P2PNode
 	// Copy P2P config fields if provided
 	if p2pConfig != nil {
 		nodeConfig.PrivateKey = p2pConfig.PrivateKey
-		nodeConfig.Listen = []string{p2pConfig.Listen}
+		nodeConfig.Listen = p2pConfig.AllListenAddresses()
+		nodeConfig.AnnounceAddresses = p2pConfig.AnnounceAddresses
+		nodeConfig.EnableQUIC = p2pConfig.EnableQUIC
+		if len(p2pConfig.QUICPreferredProtocols) > 0 {
+			nodeConfig.QUICPreferredProtocols = p2pConfig.QUICPreferredProtocols
+		}
 		nodeConfig.BootstrapPeers = p2pConfig.Bootstrap
 		nodeConfig.EnableDHT = p2pConfig.EnableDHT
 		nodeConfig.ConnMgrLow = p2pConfig.ConnMgrLow
@@ -159,6 +166,9 @@ func NewNode(ctx context.Context, p2pConfig *internalconfig.P2PConfig) (*P2PNode
 				nodeConfig.MDNSService = p2pConfig.MDNSService
 			}
 		}
+		if p2pConfig.ResourceInterval > 0 {
+			nodeConfig.ResourceInterval = p2pConfig.ResourceInterval
+		}
 	}
 
 	return NewP2PNode(ctx, nodeConfig)
@@ -185,8 +195,9 @@ func NewP2PNode(ctx context.Context, nodeConfig *config.NodeConfig) (*P2PNode, e
 		metrics: &NodeMetrics{
 			StartTime: time.Now(),
 		},
-		ctx:    ctx,
-		cancel: cancel,
+		resourceCollector: sysmetrics.NewCollector(sysmetrics.Options{}),
+		ctx:               ctx,
+		cancel:            cancel,
 	}
 
 	// Initialize components
@@ -201,11 +212,72 @@ func NewP2PNode(ctx context.Context, nodeConfig *config.NodeConfig) (*P2PNode, e
 	return node, nil
 }
 
+// SetResourceCollector replaces the host metrics collector, e.g. to report
+// disk usage for the node's data directory. It must be called before Start.
+func (n *P2PNode) SetResourceCollector(collector *sysmetrics.Collector) {
+	n.resourceCollector = collector
+}
+
 // SetMetricsIntegration sets the metrics integration for the P2P node
 func (n *P2PNode) SetMetricsIntegration(metricsIntegration *observability.MetricsIntegration) {
 	n.metricsIntegration = metricsIntegration
 }
 
+// SetReputation sets the tracker scoring peers; blobs are neither served
+// to nor fetched from quarantined peers
+func (n *P2PNode) SetReputation(tracker *reputation.Tracker) {
+	n.reputation = tracker
+}
+
+// Reputation returns the tracker scoring peers, if any
+func (n *P2PNode) Reputation() *reputation.Tracker {
+	return n.reputation
+}
+
+// SetAdmission restricts connections to peers passing admitted, and to
+// the bootstrap peers, which the operator trusts. Connected peers that are
+// not admitted are disconnected.
+func (n *P2PNode) SetAdmission(admitted func(peer.ID) bool) {
+	if n.host == nil {
+		return
+	}
+	var trusted []peer.ID
+	for _, addr := range n.config.BootstrapPeers {
+		if info, err := peer.AddrInfoFromString(addr); err == nil {
+			trusted = append(trusted, info.ID)
+		}
+	}
+	gater := n.host.Gater()
+	gater.SetAdmission(admitted, trusted...)
+	for _, id := range n.host.Network().Peers() {
+		if !gater.Allowed(id) {
+			log.Printf("Disconnecting peer %s: not admitted to the cluster", id)
+			n.DisconnectFromPeer(id)
+		}
+	}
+}
+
+// Block refuses connections to and from a peer for ttl and disconnects it
+func (n *P2PNode) Block(id peer.ID, ttl time.Duration) {
+	if n.host == nil {
+		return
+	}
+	n.host.Gater().Block(id, ttl)
+	n.DisconnectFromPeer(id)
+}
+
+// Blocked reports whether connections of a peer are refused for now
+func (n *P2PNode) Blocked(id peer.ID) bool {
+	return n.host != nil && n.host.Gater().Blocked(id)
+}
+
+// SetQUICGate sets a check consulted before routing streams over QUIC
+func (n *P2PNode) SetQUICGate(gate func() bool) {
+	if n.host != nil {
+		n.host.SetQUICGate(gate)
+	}
+}
+
 // SetHealthManager sets the health manager for the P2P node
 func (n *P2PNode) SetHealthManager(healthManager *observability.HealthCheckManager) {
 	// Register P2P health monitor
@@ -240,6 +312,14 @@ func (n *P2PNode) GetNetworkLatency() time.Duration {
 	return 50 * time.Millisecond
 }
 
+// GetPeerLatency returns the smoothed request RTT measured to a peer
+func (n *P2PNode) GetPeerLatency(peerID peer.ID) (time.Duration, bool) {
+	if n.host == nil {
+		return 0, false
+	}
+	return n.host.GetPeerRTT(peerID)
+}
+
 // GetLastActivity returns the last activity time
 func (n *P2PNode) GetLastActivity() time.Time {
 	if n.metrics != nil {
@@ -511,6 +591,19 @@ func (n *P2PNode) GetResourceMetrics() *resources.ResourceMetrics {
 	return n.resourceMetrics
 }
 
+// SubscribeResourceMetrics calls callback with the resource metrics peers
+// advertise, so that the scheduler can place work by actual usage
+func (n *P2PNode) SubscribeResourceMetrics(callback func(nodeID string, metrics *resources.ResourceMetrics)) {
+	if n.resourceAdvertiser == nil {
+		return
+	}
+	n.resourceAdvertiser.Subscribe(&resources.ResourceQuery{}, func(ad *resources.Advertisement) {
+		if ad.Resources != nil {
+			callback(ad.NodeID.String(), ad.Resources)
+		}
+	})
+}
+
 // PublishContent publishes content to the network
 func (n *P2PNode) PublishContent(ctx context.Context, content *routing.ContentMetadata) error {
 	if n.contentRouter == nil {
@@ -634,9 +727,16 @@ func (n *P2PNode) metricsTask() {
 func (n *P2PNode) resourceMonitoringTask() {
 	defer n.wg.Done()
 
-	ticker := time.NewTicker(10 * time.Second)
+	interval := n.config.ResourceInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Sample immediately so that status is available right after start
+	n.updateResourceMetrics()
+
 	for {
 		select {
 		case <-n.ctx.Done():
@@ -706,46 +806,73 @@ func (n *P2PNode) updateMetrics() {
 	}
 }
 
-// updateResourceMetrics updates resource metrics
+// updateResourceMetrics samples host resource usage and advertises it
 func (n *P2PNode) updateResourceMetrics() {
-	if n.resourceMetrics == nil {
-		n.resourceMetrics = &resources.ResourceMetrics{
-			Timestamp: time.Now(),
-		}
-	}
-
-	// Get system resource usage
-	cpuUsage, err := n.getCPUUsage()
-	if err != nil {
-		cpuUsage = 0.0
-	}
-
-	memoryUsage, _, err := n.getMemoryUsage()
+	sample, err := n.resourceCollector.Collect()
 	if err != nil {
-		memoryUsage = 0.0
+		// Sources that failed are reported as zero
+		log.Printf("Resource metrics incomplete: %v", err)
+	}
+
+	metrics := &resources.ResourceMetrics{
+		CPUUsage:      sample.CPUPercent,
+		MemoryUsage:   sample.MemoryUsed,
+		MemoryTotal:   sample.MemoryTotal,
+		DiskUsage:     sample.DiskUsed,
+		DiskTotal:     sample.DiskTotal,
+		NetworkRx:     sample.NetRxRate,
+		NetworkTx:     sample.NetTxRate,
+		DiskReadRate:  sample.DiskReadRate,
+		DiskWriteRate: sample.DiskWriteRate,
+		DiskLatency:   sample.DiskLatency,
+		Timestamp:     sample.Timestamp,
+	}
+	for _, gpu := range sample.GPUs {
+		metrics.GPUUsage = append(metrics.GPUUsage, gpu.Utilization)
+		metrics.GPUMemory = append(metrics.GPUMemory, gpu.MemoryUsed)
+		metrics.GPUTemp = append(metrics.GPUTemp, gpu.Temperature)
+	}
+	if n.resourceMetrics != nil {
+		// Request statistics are maintained elsewhere
+		metrics.RequestsPerSec = n.resourceMetrics.RequestsPerSec
+		metrics.AvgLatency = n.resourceMetrics.AvgLatency
+		metrics.ErrorRate = n.resourceMetrics.ErrorRate
 	}
+	n.resourceMetrics = metrics
 
-	diskUsage, _, err := n.getDiskUsage()
-	if err != nil {
-		diskUsage = 0.0
+	// Advertisements are only sent once capabilities are known, so derive
+	// them from the host when none were configured
+	if n.capabilities == nil {
+		n.SetCapabilities(hostCapabilities(sample))
 	}
 
-	networkBandwidth := n.getNetworkBandwidth()
-
-	// Update metrics
-	n.resourceMetrics.CPUUsage = cpuUsage
-	n.resourceMetrics.MemoryUsage = int64(memoryUsage)
-	n.resourceMetrics.DiskUsage = int64(diskUsage)
-	n.resourceMetrics.NetworkRx = int64(networkBandwidth)
-	n.resourceMetrics.NetworkTx = int64(networkBandwidth)
-	n.resourceMetrics.Timestamp = time.Now()
-
 	// Update advertiser
 	if n.resourceAdvertiser != nil {
-		n.resourceAdvertiser.SetResourceMetrics(n.resourceMetrics)
+		n.resourceAdvertiser.SetResourceMetrics(metrics)
 	}
 }
 
+// hostCapabilities describes the host from a metrics sample
+func hostCapabilities(sample *sysmetrics.Sample) *resources.NodeCapabilities {
+	caps := &resources.NodeCapabilities{
+		CPUCores:  sample.CPUCores,
+		Memory:    sample.MemoryTotal,
+		Storage:   sample.DiskTotal,
+		Available: true,
+		LastSeen:  time.Now(),
+	}
+	for _, gpu := range sample.GPUs {
+		caps.GPUs = append(caps.GPUs, &resources.GPUInfo{
+			ID:          gpu.UUID,
+			Name:        gpu.Name,
+			Memory:      gpu.MemoryTotal,
+			Available:   true,
+			Utilization: gpu.Utilization,
+		})
+	}
+	return caps
+}
+
 // Status and information
 
 // GetStatus returns node status
@@ -759,6 +886,7 @@ func (n *P2PNode) GetStatus() *NodeStatus {
 		Uptime:          n.metrics.Uptime,
 		ConnectedPeers:  n.metrics.ConnectedPeers,
 		ListenAddresses: n.host.Addrs(),
+		Transports:      n.host.GetTransportMetrics(),
 		Capabilities:    n.capabilities,
 		ResourceMetrics: n.resourceMetrics,
 		LastActivity:    n.metrics.LastActivity,
@@ -770,6 +898,11 @@ func (n *P2PNode) GetMetrics() *NodeMetrics {
 	return n.metrics
 }
 
+// GetTransportMetrics returns open connection counts per transport
+func (n *P2PNode) GetTransportMetrics() map[string]int {
+	return n.host.GetTransportMetrics()
+}
+
 // GetConfig returns node configuration
 func (n *P2PNode) GetConfig() *config.NodeConfig {
 	return n.config
@@ -792,6 +925,7 @@ type NodeStatus struct {
 	Uptime          time.Duration
 	ConnectedPeers  int
 	ListenAddresses []multiaddr.Multiaddr
+	Transports      map[string]int // open connections keyed by transport/family, e.g. "quic/ip6"
 	Capabilities    *resources.NodeCapabilities
 	ResourceMetrics *resources.ResourceMetrics
 	LastActivity    time.Time
@@ -802,131 +936,3 @@ func (s *NodeStatus) String() string {
 	return fmt.Sprintf("Node %s: Started=%t, Uptime=%v, Peers=%d, Addrs=%v",
 		s.ID, s.Started, s.Uptime, s.ConnectedPeers, s.ListenAddresses)
 }
-
-// System resource monitoring methods
-
-// getCPUUsage returns current CPU usage percentage
-func (n *P2PNode) getCPUUsage() (float64, error) {
-	// Implementation depends on platform
-	// This is a simplified cross-platform approach
-	var usage float64
-
-	// Try to read /proc/stat on Linux
-	if data, err := os.ReadFile("/proc/stat"); err == nil {
-		lines := strings.Split(string(data), "\n")
-		if len(lines) > 0 && strings.HasPrefix(lines[0], "cpu ") {
-			fields := strings.Fields(lines[0])
-			if len(fields) >= 8 {
-				user, _ := strconv.ParseFloat(fields[1], 64)
-				nice, _ := strconv.ParseFloat(fields[2], 64)
-				system, _ := strconv.ParseFloat(fields[3], 64)
-				idle, _ := strconv.ParseFloat(fields[4], 64)
-
-				total := user + nice + system + idle
-				if total > 0 {
-					usage = ((total - idle) / total) * 100
-				}
-			}
-		}
-	} else {
-		// Fallback: use runtime statistics
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		// Approximate CPU usage based on GC activity
-		usage = float64(m.NumGC % 100)
-	}
-
-	return usage, nil
-}
-
-// getMemoryUsage returns current memory usage and total memory
-func (n *P2PNode) getMemoryUsage() (float64, int64, error) {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	// Get process memory usage
-	processMemory := int64(m.Alloc)
-
-	// Try to get system memory info
-	var totalMemory int64
-	var usage float64
-
-	// Try to read /proc/meminfo on Linux
-	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
-		lines := strings.Split(string(data), "\n")
-		var memTotal, memAvailable int64
-
-		for _, line := range lines {
-			if strings.HasPrefix(line, "MemTotal:") {
-				fields := strings.Fields(line)
-				if len(fields) >= 2 {
-					if val, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
-						memTotal = val * 1024 // Convert KB to bytes
-					}
-				}
-			} else if strings.HasPrefix(line, "MemAvailable:") {
-				fields := strings.Fields(line)
-				if len(fields) >= 2 {
-					if val, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
-						memAvailable = val * 1024 // Convert KB to bytes
-					}
-				}
-			}
-		}
-
-		if memTotal > 0 {
-			totalMemory = memTotal
-			if memAvailable > 0 {
-				usage = float64(memTotal-memAvailable) / float64(memTotal) * 100
-			}
-		}
-	}
-
-	// Fallback to process memory
-	if totalMemory == 0 {
-		totalMemory = processMemory * 10 // Rough estimate
-		usage = 10.0                     // Rough estimate
-	}
-
-	return usage, totalMemory, nil
-}
-
-// getDiskUsage returns current disk usage and total disk space
-func (n *P2PNode) getDiskUsage() (float64, int64, error) {
-	// Get current working directory disk usage
-	pwd, err := os.Getwd()
-	if err != nil {
-		return 0, 0, err
-	}
-
-	// Try to get disk usage for the current directory
-	// This is a cross-platform approach using file stat
-	var totalSpace, usedSpace int64
-
-	if info, err := os.Stat(pwd); err == nil {
-		// This is a rough approximation
-		// In a real implementation, you'd use platform-specific syscalls
-		totalSpace = 1024 * 1024 * 1024 * 100 // Assume 100GB
-		usedSpace = info.Size() * 1000        // Rough estimate
-	}
-
-	usage := float64(usedSpace) / float64(totalSpace) * 100
-	if usage > 100 {
-		usage = 100
-	}
-
-	return usage, totalSpace, nil
-}
-
-// getNetworkBandwidth returns current network bandwidth estimate
-func (n *P2PNode) getNetworkBandwidth() int64 {
-	// Simple bandwidth estimation based on peer connections
-	peerCount := n.GetPeerCount()
-
-	// Estimate bandwidth based on number of peers
-	// This is a rough approximation
-	baseBandwidth := int64(1024 * 1024)            // 1 MB/s base
-	peerBandwidth := int64(peerCount * 100 * 1024) // 100 KB/s per peer
-
-	return baseBandwidth + peerBandwidth
-}
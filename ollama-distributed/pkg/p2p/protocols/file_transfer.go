@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -396,6 +397,10 @@ func (fth *FileTransferHandler) calculateChunkChecksums(transfer *FileTransfer)
 	}
 	defer file.Close()
 
+	// One staging buffer is reused for every chunk
+	staging := memory.TensorStaging.Get(transfer.ChunkSize)
+	defer memory.TensorStaging.Put(staging)
+
 	for i := 0; i < transfer.TotalChunks; i++ {
 		// Calculate chunk boundaries
 		offset := int64(i) * int64(transfer.ChunkSize)
@@ -411,7 +416,7 @@ func (fth *FileTransferHandler) calculateChunkChecksums(transfer *FileTransfer)
 
 		// Read chunk
 		file.Seek(offset, 0)
-		chunk := make([]byte, size)
+		chunk := staging[:size]
 		if _, err := io.ReadFull(file, chunk); err != nil {
 			return fmt.Errorf("failed to read chunk %d: %w", i, err)
 		}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/loadqueue"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -24,6 +25,9 @@ type InferenceHandler struct {
 	// Configuration
 	config *InferenceConfig
 
+	// Optional queue ordering model loads
+	loadQueue *loadqueue.Queue
+
 	// Metrics
 	metrics *InferenceMetrics
 }
@@ -169,6 +173,12 @@ func NewInferenceHandler(executor InferenceExecutor, config *InferenceConfig) *I
 	}
 }
 
+// SetLoadQueue makes models load through a queue ordering them by request
+// priority instead of loading concurrently
+func (ih *InferenceHandler) SetLoadQueue(queue *loadqueue.Queue) {
+	ih.loadQueue = queue
+}
+
 // HandleMessage handles inference protocol messages
 func (ih *InferenceHandler) HandleMessage(ctx context.Context, stream network.Stream, msg *Message) error {
 	switch msg.Type {
@@ -332,7 +342,13 @@ func (ih *InferenceHandler) executeInference(req *InferenceRequest) (*InferenceR
 		req.Status = StatusLoading
 		loadStart := time.Now()
 
-		if err := ih.executionEngine.LoadModel(req.Context, req.ModelName); err != nil {
+		var err error
+		if ih.loadQueue != nil {
+			err = ih.loadQueue.Load(req.Context, req.ModelName, req.Priority, ih.executionEngine.LoadModel)
+		} else {
+			err = ih.executionEngine.LoadModel(req.Context, req.ModelName)
+		}
+		if err != nil {
 			return nil, fmt.Errorf("failed to load model %s: %w", req.ModelName, err)
 		}
 
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -224,8 +225,9 @@ func (ph *ProtocolHandler) readMessage(stream network.Stream) (*Message, error)
 		return nil, fmt.Errorf("invalid message size: 0")
 	}
 
-	// Read message data
-	messageData := make([]byte, messageSize)
+	// Read message data into a staging buffer; Unmarshal copies what it keeps
+	messageData := memory.TensorStaging.Get(int(messageSize))
+	defer memory.TensorStaging.Put(messageData)
 	if _, err := io.ReadFull(reader, messageData); err != nil {
 		return nil, fmt.Errorf("failed to read message data: %w", err)
 	}
@@ -0,0 +1,303 @@
+// Package qos schedules outbound peer-to-peer messages by quality of
+// service class, so that control-plane traffic such as heartbeats,
+// consensus and health checks is not starved by bulk model transfers. Each
+// class has its own queue and a share of the bandwidth; the queues are
+// served by deficit round robin weighted by the shares, so a class without
+// traffic leaves its share to the others. How long messages wait in their
+// queue is tracked per class.
+package qos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Class is a quality of service class
+type Class string
+
+// Classes of traffic, from most to least latency sensitive
+const (
+	Control     Class = "control"
+	Interactive Class = "interactive"
+	Bulk        Class = "bulk"
+)
+
+// Classes lists every class in the order queues are served
+var Classes = []Class{Control, Interactive, Bulk}
+
+// Known reports whether c is one of the classes
+func Known(c Class) bool {
+	for _, class := range Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultShares are the bandwidth shares of classes left unset
+var DefaultShares = map[Class]float64{Control: 0.5, Interactive: 0.3, Bulk: 0.2}
+
+// DefaultQueueSize is how many messages a class queues by default
+const DefaultQueueSize = 1000
+
+// quantum is the bytes a class with the whole bandwidth may send per round;
+// each class is credited its share of it
+const quantum = 256 << 10
+
+// Config configures QoS scheduling
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Shares are the fractions of the bandwidth each class gets while
+	// every class has messages queued
+	Shares map[Class]float64 `yaml:"shares"`
+	// QueueSize is how many messages each class may queue
+	QueueSize int `yaml:"queue_size" mapstructure:"queue_size"`
+}
+
+// Validate checks the shares and queue size
+func (c Config) Validate() error {
+	for class, share := range c.Shares {
+		if !Known(class) {
+			return fmt.Errorf("unknown QoS class %q", class)
+		}
+		if share <= 0 {
+			return fmt.Errorf("share of QoS class %s must be positive", class)
+		}
+	}
+	if c.QueueSize < 0 {
+		return fmt.Errorf("QoS queue size must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	shares := make(map[Class]float64, len(Classes))
+	for _, class := range Classes {
+		shares[class] = DefaultShares[class]
+		if share, ok := c.Shares[class]; ok && share > 0 {
+			shares[class] = share
+		}
+	}
+	c.Shares = shares
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	return c
+}
+
+// Stats describes the traffic of a class
+type Stats struct {
+	Class  Class   `json:"class"`
+	Share  float64 `json:"share"`
+	Queued int     `json:"queued"`
+	Sent   int64   `json:"sent"`
+	Bytes  int64   `json:"bytes"`
+	// Dropped counts the messages given up on while their queue was full
+	Dropped  int64         `json:"dropped"`
+	AvgDelay time.Duration `json:"avg_delay"`
+	MaxDelay time.Duration `json:"max_delay"`
+}
+
+// item is a queued message
+type item[T any] struct {
+	value    T
+	size     int
+	enqueued time.Time
+}
+
+// classQueue is the queue of a class
+type classQueue[T any] struct {
+	items      []item[T]
+	slots      chan struct{} // one per queued message, bounding the queue
+	quantum    int
+	deficit    int
+	stats      Stats
+	totalDelay time.Duration
+}
+
+// Queue holds outbound messages by class
+type Queue[T any] struct {
+	cfg   Config
+	now   func() time.Time
+	ready chan struct{}
+
+	mu       sync.Mutex
+	queues   map[Class]*classQueue[T]
+	current  int  // index into Classes of the class being served
+	credited bool // whether the current class got its quantum this round
+	observer func(class Class, delay time.Duration)
+}
+
+// NewQueue creates a queue
+func NewQueue[T any](cfg Config) *Queue[T] {
+	cfg = cfg.withDefaults()
+	q := &Queue[T]{
+		cfg:    cfg,
+		now:    time.Now,
+		ready:  make(chan struct{}, 1),
+		queues: make(map[Class]*classQueue[T], len(Classes)),
+	}
+	var total float64
+	for _, share := range cfg.Shares {
+		total += share
+	}
+	for _, class := range Classes {
+		share := cfg.Shares[class] / total
+		q.queues[class] = &classQueue[T]{
+			slots:   make(chan struct{}, cfg.QueueSize),
+			quantum: max(1, int(share*quantum)),
+			stats:   Stats{Class: class, Share: share},
+		}
+	}
+	return q
+}
+
+// SetObserver sets a function called with the queuing delay of every
+// message sent, e.g. to record it in metrics
+func (q *Queue[T]) SetObserver(observer func(class Class, delay time.Duration)) {
+	q.mu.Lock()
+	q.observer = observer
+	q.mu.Unlock()
+}
+
+// Enqueue queues a message of size bytes in its class, waiting while the
+// class queue is full until ctx is done. Unknown classes are queued as
+// Interactive.
+func (q *Queue[T]) Enqueue(ctx context.Context, class Class, value T, size int) error {
+	if !Known(class) {
+		class = Interactive
+	}
+	cq := q.queues[class]
+	select {
+	case cq.slots <- struct{}{}:
+	case <-ctx.Done():
+		q.mu.Lock()
+		cq.stats.Dropped++
+		q.mu.Unlock()
+		return fmt.Errorf("%s queue full: %w", class, ctx.Err())
+	}
+
+	q.mu.Lock()
+	cq.items = append(cq.items, item[T]{value: value, size: size, enqueued: q.now()})
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Dequeue returns the next message to send and its class, waiting for one
+// until ctx is done
+func (q *Queue[T]) Dequeue(ctx context.Context) (T, Class, error) {
+	for {
+		q.mu.Lock()
+		if q.lenLocked() > 0 {
+			class, next := q.pick()
+			delay := q.now().Sub(next.enqueued)
+			cq := q.queues[class]
+			cq.stats.Sent++
+			cq.stats.Bytes += int64(next.size)
+			cq.totalDelay += delay
+			cq.stats.MaxDelay = max(cq.stats.MaxDelay, delay)
+			more, observer := q.lenLocked() > 0, q.observer
+			q.mu.Unlock()
+
+			<-cq.slots
+			if more {
+				q.signal()
+			}
+			if observer != nil {
+				observer(class, delay)
+			}
+			return next.value, class, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			var zero T
+			return zero, "", ctx.Err()
+		}
+	}
+}
+
+// pick takes the next message by deficit round robin: each class in turn
+// is credited its quantum and sends while its deficit covers the size of
+// its next message; q.mu must be held and a message queued
+func (q *Queue[T]) pick() (Class, item[T]) {
+	for {
+		class := Classes[q.current]
+		cq := q.queues[class]
+		if len(cq.items) == 0 {
+			cq.deficit = 0
+			q.advance()
+			continue
+		}
+		if !q.credited {
+			cq.deficit += cq.quantum
+			q.credited = true
+		}
+		next := cq.items[0]
+		if next.size > cq.deficit {
+			q.advance()
+			continue
+		}
+		cq.items = cq.items[1:]
+		cq.deficit -= next.size
+		if len(cq.items) == 0 {
+			cq.deficit = 0
+			q.advance()
+		}
+		return class, next
+	}
+}
+
+// advance moves on to the next class; q.mu must be held
+func (q *Queue[T]) advance() {
+	q.current = (q.current + 1) % len(Classes)
+	q.credited = false
+}
+
+// signal wakes a waiting Dequeue
+func (q *Queue[T]) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of queued messages
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.lenLocked()
+}
+
+func (q *Queue[T]) lenLocked() int {
+	n := 0
+	for _, cq := range q.queues {
+		n += len(cq.items)
+	}
+	return n
+}
+
+// Stats returns the traffic of every class, in the order of Classes
+func (q *Queue[T]) Stats() []Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make([]Stats, 0, len(Classes))
+	for _, class := range Classes {
+		cq := q.queues[class]
+		s := cq.stats
+		s.Queued = len(cq.items)
+		if s.Sent > 0 {
+			s.AvgDelay = cq.totalDelay / time.Duration(s.Sent)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
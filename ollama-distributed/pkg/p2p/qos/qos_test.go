@@ -0,0 +1,104 @@
+package qos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(t *testing.T, q *Queue[int], n int) map[Class]int {
+	t.Helper()
+	counts := make(map[Class]int)
+	for i := 0; i < n; i++ {
+		_, class, err := q.Dequeue(context.Background())
+		require.NoError(t, err)
+		counts[class]++
+	}
+	return counts
+}
+
+func TestBulkTrafficDoesNotStarveControl(t *testing.T) {
+	q := NewQueue[int](Config{})
+	ctx := context.Background()
+	for i := 0; i < 200; i++ {
+		require.NoError(t, q.Enqueue(ctx, Bulk, i, 64<<10))
+	}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, q.Enqueue(ctx, Control, i, 64<<10))
+	}
+
+	// Control gets 0.5 of the bandwidth and bulk 0.2, so 5 of every 7
+	// messages of equal size are control messages
+	first := drain(t, q, 70)
+	assert.InDelta(t, 50, first[Control], 2)
+	assert.InDelta(t, 20, first[Bulk], 2)
+
+	// Once control traffic is sent bulk gets the whole bandwidth
+	rest := drain(t, q, q.Len())
+	assert.Equal(t, 100, first[Control]+rest[Control])
+	assert.Equal(t, 200, first[Bulk]+rest[Bulk])
+	assert.Zero(t, q.Len())
+}
+
+func TestMessagesKeepOrderWithinClass(t *testing.T) {
+	q := NewQueue[int](Config{})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), "unknown", i, 100))
+	}
+	for i := 0; i < 5; i++ {
+		value, class, err := q.Dequeue(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, i, value)
+		assert.Equal(t, Interactive, class, "unknown classes are interactive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueuingDelayPerClass(t *testing.T) {
+	q := NewQueue[int](Config{QueueSize: 2})
+	now := time.Now()
+	q.now = func() time.Time { return now }
+	var observed []time.Duration
+	q.SetObserver(func(class Class, delay time.Duration) { observed = append(observed, delay) })
+
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, Bulk, 1, 10))
+	now = now.Add(time.Second)
+	require.NoError(t, q.Enqueue(ctx, Bulk, 2, 10))
+
+	// The bulk queue is full
+	full, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, q.Enqueue(full, Bulk, 3, 10))
+	require.NoError(t, q.Enqueue(ctx, Control, 4, 10), "other classes have their own queues")
+
+	now = now.Add(time.Second)
+	drain(t, q, 3)
+	assert.ElementsMatch(t, []time.Duration{2 * time.Second, time.Second, time.Second}, observed)
+
+	stats := q.Stats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, Control, stats[0].Class)
+	assert.Equal(t, int64(1), stats[0].Sent)
+	bulk := stats[2]
+	assert.Equal(t, int64(2), bulk.Sent)
+	assert.Equal(t, int64(20), bulk.Bytes)
+	assert.Equal(t, int64(1), bulk.Dropped)
+	assert.Equal(t, 1500*time.Millisecond, bulk.AvgDelay)
+	assert.Equal(t, 2*time.Second, bulk.MaxDelay)
+	assert.InDelta(t, 0.2, bulk.Share, 0.001)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Shares: map[Class]float64{Control: 0.7}}.Validate())
+	assert.Error(t, Config{Shares: map[Class]float64{"video": 0.1}}.Validate())
+	assert.Error(t, Config{Shares: map[Class]float64{Bulk: 0}}.Validate())
+	assert.Error(t, Config{QueueSize: -1}.Validate())
+}
@@ -0,0 +1,355 @@
+// Package reputation scores peers by how they behave. Protocol violations,
+// failed transfers and results inconsistent with those of other nodes cost
+// a peer points, successes and time without incidents earn them back.
+// Peers whose score falls below a threshold are quarantined: the scheduler
+// deprioritizes them and blobs are neither served to nor fetched from them
+// until their score recovers. Operators can quarantine or trust a peer by
+// hand, overriding its score.
+package reputation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxScore is the score of a peer without incidents
+const MaxScore = 100.0
+
+// Kind is a kind of peer behaviour
+type Kind string
+
+// Kinds of behaviour that change a peer's score
+const (
+	ProtocolViolation  Kind = "protocol_violation"
+	FailedTransfer     Kind = "failed_transfer"
+	InconsistentResult Kind = "inconsistent_result"
+	Success            Kind = "success"
+)
+
+// Manual overrides of a peer's quarantine
+const (
+	OverrideNone       = ""
+	OverrideQuarantine = "quarantined"
+	OverrideTrust      = "trusted"
+)
+
+// Defaults of peer scoring
+const (
+	DefaultViolationPenalty     = 20
+	DefaultTransferPenalty      = 5
+	DefaultInconsistencyPenalty = 15
+	DefaultSuccessReward        = 1
+	DefaultRecoveryPerHour      = 10
+	DefaultQuarantineBelow      = 40
+	DefaultReleaseAbove         = 60
+)
+
+// Config configures peer scoring
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Points a peer loses per protocol violation, failed transfer and
+	// inconsistent result, and earns per success
+	ViolationPenalty     float64 `yaml:"violation_penalty" mapstructure:"violation_penalty"`
+	TransferPenalty      float64 `yaml:"transfer_penalty" mapstructure:"transfer_penalty"`
+	InconsistencyPenalty float64 `yaml:"inconsistency_penalty" mapstructure:"inconsistency_penalty"`
+	SuccessReward        float64 `yaml:"success_reward" mapstructure:"success_reward"`
+	// RecoveryPerHour is how many points a score regains per hour
+	RecoveryPerHour float64 `yaml:"recovery_per_hour" mapstructure:"recovery_per_hour"`
+	// A peer is quarantined once its score drops below QuarantineBelow
+	// and released once it recovers to ReleaseAbove
+	QuarantineBelow float64 `yaml:"quarantine_below" mapstructure:"quarantine_below"`
+	ReleaseAbove    float64 `yaml:"release_above" mapstructure:"release_above"`
+}
+
+// Validate checks the penalties and thresholds
+func (c Config) Validate() error {
+	for _, v := range []float64{c.ViolationPenalty, c.TransferPenalty, c.InconsistencyPenalty, c.SuccessReward, c.RecoveryPerHour} {
+		if v < 0 {
+			return fmt.Errorf("peer scoring penalties and rewards must not be negative")
+		}
+	}
+	if c.QuarantineBelow < 0 || c.QuarantineBelow > MaxScore || c.ReleaseAbove < 0 || c.ReleaseAbove > MaxScore {
+		return fmt.Errorf("quarantine thresholds must be between 0 and %.0f", MaxScore)
+	}
+	cfg := c.withDefaults()
+	if cfg.ReleaseAbove < cfg.QuarantineBelow {
+		return fmt.Errorf("release threshold %.0f is below the quarantine threshold %.0f", cfg.ReleaseAbove, cfg.QuarantineBelow)
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.ViolationPenalty == 0 {
+		c.ViolationPenalty = DefaultViolationPenalty
+	}
+	if c.TransferPenalty == 0 {
+		c.TransferPenalty = DefaultTransferPenalty
+	}
+	if c.InconsistencyPenalty == 0 {
+		c.InconsistencyPenalty = DefaultInconsistencyPenalty
+	}
+	if c.SuccessReward == 0 {
+		c.SuccessReward = DefaultSuccessReward
+	}
+	if c.RecoveryPerHour == 0 {
+		c.RecoveryPerHour = DefaultRecoveryPerHour
+	}
+	if c.QuarantineBelow == 0 {
+		c.QuarantineBelow = DefaultQuarantineBelow
+	}
+	if c.ReleaseAbove == 0 {
+		c.ReleaseAbove = DefaultReleaseAbove
+	}
+	return c
+}
+
+// Event is a recorded behaviour of a peer
+type Event struct {
+	Kind   Kind      `json:"kind"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// PeerScore is the reputation of a peer
+type PeerScore struct {
+	PeerID      string  `json:"peer_id"`
+	Score       float64 `json:"score"`
+	Quarantined bool    `json:"quarantined"`
+	// Override is the manual override in force, if any, and why it was set
+	Override       string         `json:"override,omitempty"`
+	OverrideReason string         `json:"override_reason,omitempty"`
+	QuarantinedAt  time.Time      `json:"quarantined_at,omitempty"`
+	Counts         map[Kind]int64 `json:"counts"`
+	LastEvent      *Event         `json:"last_event,omitempty"`
+}
+
+// peerState is the reputation of a peer, guarded by the tracker mutex
+type peerState struct {
+	score       float64
+	updated     time.Time
+	quarantined bool // by score, regardless of overrides
+	since       time.Time
+	override    string
+	reason      string
+	counts      map[Kind]int64
+	last        *Event
+}
+
+// effective reports whether the peer is quarantined, overrides included
+func (p *peerState) effective() bool {
+	switch p.override {
+	case OverrideQuarantine:
+		return true
+	case OverrideTrust:
+		return false
+	}
+	return p.quarantined
+}
+
+// Tracker keeps the reputation of peers
+type Tracker struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	peers    map[string]*peerState
+	observer func(score PeerScore)
+}
+
+// NewTracker creates a tracker
+func NewTracker(cfg Config) *Tracker {
+	return &Tracker{cfg: cfg.withDefaults(), now: time.Now, peers: make(map[string]*peerState)}
+}
+
+// Config returns the configuration, with its defaults applied
+func (t *Tracker) Config() Config {
+	return t.cfg
+}
+
+// SetObserver sets a function called whenever a peer is quarantined or
+// released, e.g. to log it
+func (t *Tracker) SetObserver(observer func(score PeerScore)) {
+	t.mu.Lock()
+	t.observer = observer
+	t.mu.Unlock()
+}
+
+// Record records a behaviour of a peer, adjusting its score
+func (t *Tracker) Record(peerID string, kind Kind, detail string) {
+	if t == nil || peerID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	p := t.peer(peerID)
+	was := p.effective()
+	now := t.now()
+	switch kind {
+	case ProtocolViolation:
+		p.score -= t.cfg.ViolationPenalty
+	case FailedTransfer:
+		p.score -= t.cfg.TransferPenalty
+	case InconsistentResult:
+		p.score -= t.cfg.InconsistencyPenalty
+	case Success:
+		p.score += t.cfg.SuccessReward
+	}
+	p.score = min(max(p.score, 0), MaxScore)
+	p.counts[kind]++
+	if kind != Success {
+		p.last = &Event{Kind: kind, Detail: detail, At: now}
+	}
+	t.requarantine(p, now)
+	t.notify(peerID, p, was)
+}
+
+// Quarantined reports whether a peer is quarantined. A nil tracker
+// quarantines no one.
+func (t *Tracker) Quarantined(peerID string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	p, ok := t.peers[peerID]
+	if !ok {
+		t.mu.Unlock()
+		return false
+	}
+	was := p.effective()
+	t.recover(p)
+	quarantined := p.effective()
+	t.notify(peerID, p, was)
+	return quarantined
+}
+
+// Peer returns the reputation of a peer; peers without recorded behaviour
+// have the maximum score
+func (t *Tracker) Peer(peerID string) PeerScore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.peers[peerID]
+	if !ok {
+		return PeerScore{PeerID: peerID, Score: MaxScore, Counts: map[Kind]int64{}}
+	}
+	t.recover(p)
+	return t.scoreOf(peerID, p)
+}
+
+// Peers returns the reputation of every peer with recorded behaviour or an
+// override, lowest score first
+func (t *Tracker) Peers() []PeerScore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scores := make([]PeerScore, 0, len(t.peers))
+	for id, p := range t.peers {
+		t.recover(p)
+		scores = append(scores, t.scoreOf(id, p))
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].PeerID < scores[j].PeerID
+	})
+	return scores
+}
+
+// SetOverride quarantines or trusts a peer regardless of its score, or
+// with OverrideNone returns it to scoring
+func (t *Tracker) SetOverride(peerID, override, reason string) (PeerScore, error) {
+	switch override {
+	case OverrideNone, OverrideQuarantine, OverrideTrust:
+	default:
+		return PeerScore{}, fmt.Errorf("unknown override %q, want %q, %q or none", override, OverrideQuarantine, OverrideTrust)
+	}
+	if peerID == "" {
+		return PeerScore{}, fmt.Errorf("peer ID is required")
+	}
+
+	t.mu.Lock()
+	p := t.peer(peerID)
+	was := p.effective()
+	t.recover(p)
+	p.override, p.reason = override, reason
+	if override == OverrideNone {
+		p.reason = ""
+	}
+	score := t.scoreOf(peerID, p)
+	t.notify(peerID, p, was)
+	return score, nil
+}
+
+// peer returns the state of a peer, creating it; t.mu must be held
+func (t *Tracker) peer(peerID string) *peerState {
+	p, ok := t.peers[peerID]
+	if !ok {
+		p = &peerState{score: MaxScore, updated: t.now(), counts: make(map[Kind]int64)}
+		t.peers[peerID] = p
+	}
+	return p
+}
+
+// recover credits the points a peer regained since its last update; t.mu
+// must be held
+func (t *Tracker) recover(p *peerState) {
+	now := t.now()
+	if elapsed := now.Sub(p.updated); elapsed > 0 {
+		p.score = min(p.score+elapsed.Hours()*t.cfg.RecoveryPerHour, MaxScore)
+	}
+	t.requarantine(p, now)
+}
+
+// requarantine applies the quarantine thresholds to a peer's score; t.mu
+// must be held
+func (t *Tracker) requarantine(p *peerState, now time.Time) {
+	p.updated = now
+	switch {
+	case !p.quarantined && p.score < t.cfg.QuarantineBelow:
+		p.quarantined = true
+		p.since = now
+	case p.quarantined && p.score >= t.cfg.ReleaseAbove:
+		p.quarantined = false
+		p.since = time.Time{}
+	}
+}
+
+// notify unlocks t.mu and tells the observer if the peer's quarantine
+// changed from was
+func (t *Tracker) notify(peerID string, p *peerState, was bool) {
+	observer := t.observer
+	changed := p.effective() != was
+	score := t.scoreOf(peerID, p)
+	t.mu.Unlock()
+
+	if changed && observer != nil {
+		observer(score)
+	}
+}
+
+// scoreOf returns the reputation of a peer; t.mu must be held
+func (t *Tracker) scoreOf(peerID string, p *peerState) PeerScore {
+	score := PeerScore{
+		PeerID:         peerID,
+		Score:          p.score,
+		Quarantined:    p.effective(),
+		Override:       p.override,
+		OverrideReason: p.reason,
+		Counts:         make(map[Kind]int64, len(p.counts)),
+	}
+	if score.Quarantined {
+		score.QuarantinedAt = p.since
+	}
+	for kind, n := range p.counts {
+		score.Counts[kind] = n
+	}
+	if p.last != nil {
+		last := *p.last
+		score.LastEvent = &last
+	}
+	return score
+}
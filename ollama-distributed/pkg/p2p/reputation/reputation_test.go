@@ -0,0 +1,89 @@
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMisbehavingPeersAreQuarantined(t *testing.T) {
+	tr := NewTracker(Config{})
+	var changes []PeerScore
+	tr.SetObserver(func(score PeerScore) { changes = append(changes, score) })
+
+	tr.Record("peer-a", ProtocolViolation, "oversized blob key")
+	tr.Record("peer-a", InconsistentResult, "failed math case")
+	tr.Record("peer-a", FailedTransfer, "stream reset")
+	assert.InDelta(t, 60, tr.Peer("peer-a").Score, 0.01)
+	assert.False(t, tr.Quarantined("peer-a"))
+
+	tr.Record("peer-a", ProtocolViolation, "bad handshake")
+	assert.False(t, tr.Quarantined("peer-a"), "40 is not below the threshold")
+	tr.Record("peer-a", FailedTransfer, "stream reset")
+	score := tr.Peer("peer-a")
+	assert.True(t, score.Quarantined)
+	assert.Equal(t, int64(2), score.Counts[ProtocolViolation])
+	require.NotNil(t, score.LastEvent)
+	assert.Equal(t, "stream reset", score.LastEvent.Detail)
+	require.Len(t, changes, 1)
+	assert.True(t, changes[0].Quarantined)
+
+	assert.False(t, tr.Quarantined("peer-b"), "unknown peers are trusted")
+	assert.Equal(t, MaxScore, tr.Peer("peer-b").Score)
+	var nilTracker *Tracker
+	assert.False(t, nilTracker.Quarantined("peer-a"))
+}
+
+func TestScoresRecoverOverTime(t *testing.T) {
+	tr := NewTracker(Config{RecoveryPerHour: 10})
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		tr.Record("peer-a", InconsistentResult, "")
+	}
+	require.True(t, tr.Quarantined("peer-a"))
+
+	// Released only once the score climbs back to the release threshold
+	now = now.Add(2 * time.Hour)
+	assert.True(t, tr.Quarantined("peer-a"), "score 45 is still below the release threshold")
+	now = now.Add(2 * time.Hour)
+	assert.False(t, tr.Quarantined("peer-a"))
+	now = now.Add(10 * time.Hour)
+	assert.Equal(t, MaxScore, tr.Peer("peer-a").Score)
+}
+
+func TestOverrides(t *testing.T) {
+	tr := NewTracker(Config{})
+
+	score, err := tr.SetOverride("peer-a", OverrideQuarantine, "suspicious outputs")
+	require.NoError(t, err)
+	assert.True(t, score.Quarantined)
+	assert.Equal(t, "suspicious outputs", score.OverrideReason)
+	assert.True(t, tr.Quarantined("peer-a"))
+
+	for i := 0; i < 5; i++ {
+		tr.Record("peer-b", ProtocolViolation, "")
+	}
+	_, err = tr.SetOverride("peer-b", OverrideTrust, "known flaky link")
+	require.NoError(t, err)
+	assert.False(t, tr.Quarantined("peer-b"))
+	_, err = tr.SetOverride("peer-b", OverrideNone, "")
+	require.NoError(t, err)
+	assert.True(t, tr.Quarantined("peer-b"), "clearing the override returns the peer to its score")
+
+	peers := tr.Peers()
+	require.Len(t, peers, 2)
+	assert.Equal(t, "peer-b", peers[0].PeerID, "lowest score first")
+
+	_, err = tr.SetOverride("peer-a", "banned", "")
+	assert.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{QuarantineBelow: 30, ReleaseAbove: 50}.Validate())
+	assert.Error(t, Config{TransferPenalty: -1}.Validate())
+	assert.Error(t, Config{QuarantineBelow: 150}.Validate())
+	assert.Error(t, Config{QuarantineBelow: 70}.Validate(), "release threshold defaults to 60")
+}
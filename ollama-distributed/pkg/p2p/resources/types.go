@@ -50,10 +50,17 @@ type GPUInfo struct {
 type ResourceMetrics struct {
 	CPUUsage    float64 `json:"cpu_usage" yaml:"cpu_usage"`       // CPU usage percentage (0-100)
 	MemoryUsage int64   `json:"memory_usage" yaml:"memory_usage"` // Memory usage in bytes
+	MemoryTotal int64   `json:"memory_total" yaml:"memory_total"` // Total memory in bytes
 	DiskUsage   int64   `json:"disk_usage" yaml:"disk_usage"`     // Disk usage in bytes
+	DiskTotal   int64   `json:"disk_total" yaml:"disk_total"`     // Total disk space in bytes
 	NetworkRx   int64   `json:"network_rx" yaml:"network_rx"`     // Network received bytes/sec
 	NetworkTx   int64   `json:"network_tx" yaml:"network_tx"`     // Network transmitted bytes/sec
 
+	// Disk I/O metrics
+	DiskReadRate  int64         `json:"disk_read_rate" yaml:"disk_read_rate"`   // Disk bytes read/sec
+	DiskWriteRate int64         `json:"disk_write_rate" yaml:"disk_write_rate"` // Disk bytes written/sec
+	DiskLatency   time.Duration `json:"disk_latency" yaml:"disk_latency"`       // Average time per disk I/O
+
 	// GPU metrics
 	GPUUsage  []float64 `json:"gpu_usage" yaml:"gpu_usage"`   // GPU usage percentage per GPU
 	GPUMemory []int64   `json:"gpu_memory" yaml:"gpu_memory"` // GPU memory usage per GPU
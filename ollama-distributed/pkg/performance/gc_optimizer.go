@@ -1,8 +1,11 @@
 package performance
 
 import (
+	"math"
+	"os"
 	"runtime"
 	"runtime/debug"
+	"runtime/metrics"
 	"sync"
 	"time"
 
@@ -14,9 +17,16 @@ type GCOptimizer struct {
 	config *OptimizerConfig
 
 	// GC settings
-	currentGCPercent int
-	memoryLimit      int64
-	gcPauseTarget    time.Duration
+	currentGCPercent  int
+	memoryLimit       int64
+	memoryLimitSource string
+	gcPauseTarget     time.Duration
+
+	// Counters at the previous update, for per-interval rates
+	lastTotalAlloc uint64
+	lastHeapAlloc  uint64
+	lastGCCPU      float64
+	lastTotalCPU   float64
 
 	// Statistics
 	stats *GCStats
@@ -33,7 +43,9 @@ type GCStats struct {
 	// GC pause times
 	AveragePause     time.Duration `json:"average_pause"`
 	MaxPause         time.Duration `json:"max_pause"`
+	P50Pause         time.Duration `json:"p50_pause"`
 	P95Pause         time.Duration `json:"p95_pause"`
+	P99Pause         time.Duration `json:"p99_pause"`
 	TotalPauseTime   time.Duration `json:"total_pause_time"`
 
 	// Memory statistics
@@ -41,11 +53,17 @@ type GCStats struct {
 	HeapInUse        uint64        `json:"heap_in_use"`
 	HeapReleased     uint64        `json:"heap_released"`
 	NextGCThreshold  uint64        `json:"next_gc_threshold"`
+	HeapGrowthRate   float64       `json:"heap_growth_rate"` // live heap bytes per second
 
 	// GC efficiency
 	GCOverhead       float64       `json:"gc_overhead"`      // GC time / total time
 	AllocationRate   float64       `json:"allocation_rate"`  // bytes per second
 
+	// Current settings
+	GCPercent         int    `json:"gc_percent"`
+	MemoryLimit       int64  `json:"memory_limit"`
+	MemoryLimitSource string `json:"memory_limit_source"` // env, cgroup, config or none
+
 	LastUpdated      time.Time     `json:"last_updated"`
 }
 
@@ -61,6 +79,7 @@ func NewGCOptimizer(config *OptimizerConfig) *GCOptimizer {
 	// Set initial GC parameters
 	optimizer.setOptimalGCPercent()
 	optimizer.setMemoryLimit()
+	optimizer.stats.GCPercent = optimizer.currentGCPercent
 
 	return optimizer
 }
@@ -80,10 +99,7 @@ func (gco *GCOptimizer) AdjustForLowLatency() {
 	defer gco.mu.Unlock()
 
 	// Reduce GC target percentage for more frequent, smaller collections
-	newPercent := gco.currentGCPercent - 10
-	if newPercent < 20 {
-		newPercent = 20 // Don't go below 20%
-	}
+	newPercent := gco.clampGCPercent(gco.currentGCPercent - 10)
 
 	if newPercent != gco.currentGCPercent {
 		gco.currentGCPercent = newPercent
@@ -101,10 +117,7 @@ func (gco *GCOptimizer) AdjustForThroughput() {
 	defer gco.mu.Unlock()
 
 	// Increase GC target percentage for less frequent, larger collections
-	newPercent := gco.currentGCPercent + 20
-	if newPercent > 200 {
-		newPercent = 200 // Cap at 200%
-	}
+	newPercent := gco.clampGCPercent(gco.currentGCPercent + 20)
 
 	if newPercent != gco.currentGCPercent {
 		gco.currentGCPercent = newPercent
@@ -146,7 +159,7 @@ func (gco *GCOptimizer) updateGCStats() {
 	gcCycles := m.NumGC
 	now := time.Now()
 	timeDelta := now.Sub(gco.stats.LastUpdated).Seconds()
-	
+
 	if timeDelta > 0 && gco.stats.GCCycles > 0 {
 		cycleDelta := gcCycles - gco.stats.GCCycles
 		gco.stats.GCRate = float64(cycleDelta) / timeDelta
@@ -161,16 +174,28 @@ func (gco *GCOptimizer) updateGCStats() {
 	gco.stats.HeapReleased = m.HeapReleased
 	gco.stats.NextGCThreshold = m.NextGC
 
-	// Calculate GC overhead
-	if m.GCCPUFraction > 0 {
-		gco.stats.GCOverhead = m.GCCPUFraction * 100
+	// GC overhead over the last interval, falling back to the lifetime
+	// fraction when the runtime metrics are unavailable
+	gco.stats.GCOverhead = m.GCCPUFraction * 100
+	if gcCPU, totalCPU, ok := readGCCPU(); ok {
+		if totalCPU > gco.lastTotalCPU && gco.lastTotalCPU > 0 {
+			gco.stats.GCOverhead = (gcCPU - gco.lastGCCPU) / (totalCPU - gco.lastTotalCPU) * 100
+		}
+		gco.lastGCCPU, gco.lastTotalCPU = gcCPU, totalCPU
 	}
 
-	// Calculate allocation rate
-	if timeDelta > 0 {
-		allocDelta := m.TotalAlloc - (gco.stats.HeapInUse + gco.stats.HeapReleased)
-		gco.stats.AllocationRate = float64(allocDelta) / timeDelta
+	// Calculate allocation rate and live heap growth
+	if timeDelta > 0 && gco.lastTotalAlloc > 0 {
+		gco.stats.AllocationRate = float64(m.TotalAlloc-gco.lastTotalAlloc) / timeDelta
+		gco.stats.HeapGrowthRate = (float64(m.HeapAlloc) - float64(gco.lastHeapAlloc)) / timeDelta
 	}
+	gco.lastTotalAlloc = m.TotalAlloc
+	gco.lastHeapAlloc = m.HeapAlloc
+
+	// Current settings
+	gco.stats.GCPercent = gco.currentGCPercent
+	gco.stats.MemoryLimit = gco.memoryLimit
+	gco.stats.MemoryLimitSource = gco.memoryLimitSource
 
 	// Update cycle count and timestamp
 	gco.stats.GCCycles = gcCycles
@@ -178,6 +203,21 @@ func (gco *GCOptimizer) updateGCStats() {
 	gco.stats.LastUpdated = now
 }
 
+// readGCCPU returns the cumulative CPU seconds spent in GC and in total
+func readGCCPU() (gcCPU, totalCPU float64, ok bool) {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+	for _, s := range samples {
+		if s.Value.Kind() != metrics.KindFloat64 {
+			return 0, 0, false
+		}
+	}
+	return samples[0].Value.Float64(), samples[1].Value.Float64(), true
+}
+
 // updatePauseStats updates GC pause time statistics
 func (gco *GCOptimizer) updatePauseStats(m *runtime.MemStats) {
 	if m.NumGC == 0 {
@@ -198,86 +238,113 @@ func (gco *GCOptimizer) updatePauseStats(m *runtime.MemStats) {
 		pause := time.Duration(m.PauseNs[(m.NumGC-uint32(i)+255)%256])
 		pauses = append(pauses, pause)
 		totalPause += pause
-		
+
 		if pause > maxPause {
 			maxPause = pause
 		}
 	}
 
 	// Calculate statistics
-	if numPauses > 0 {
-		gco.stats.AveragePause = totalPause / time.Duration(numPauses)
-		gco.stats.MaxPause = maxPause
-		gco.stats.TotalPauseTime = time.Duration(m.PauseTotalNs)
-
-		// Calculate P95 pause time
-		if len(pauses) > 0 {
-			sortPauses(pauses)
-			p95Index := int(float64(len(pauses)) * 0.95)
-			if p95Index >= len(pauses) {
-				p95Index = len(pauses) - 1
-			}
-			gco.stats.P95Pause = pauses[p95Index]
-		}
+	gco.stats.AveragePause = totalPause / time.Duration(numPauses)
+	gco.stats.MaxPause = maxPause
+	gco.stats.TotalPauseTime = time.Duration(m.PauseTotalNs)
+
+	sortPauses(pauses)
+	gco.stats.P50Pause = pausePercentile(pauses, 0.50)
+	gco.stats.P95Pause = pausePercentile(pauses, 0.95)
+	gco.stats.P99Pause = pausePercentile(pauses, 0.99)
+}
+
+// pausePercentile returns the q-th percentile of sorted pauses
+func pausePercentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
 	}
+	index := int(math.Ceil(float64(len(sorted))*q)) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
 }
 
-// adjustGCParameters adjusts GC parameters based on current performance
+// adjustGCParameters adjusts GC parameters based on current performance.
+// It runs once per Optimize call, which the SystemOptimizer makes every 30s.
 func (gco *GCOptimizer) adjustGCParameters() {
-	// Don't adjust too frequently
-	if time.Since(gco.stats.LastUpdated) < 30*time.Second {
+	newPercent, reason := gco.nextGCPercent()
+	if newPercent == gco.currentGCPercent {
 		return
 	}
 
-	// Check if pause times are too high
-	if gco.stats.AveragePause > gco.gcPauseTarget {
-		// Reduce GC percentage to trigger more frequent collections
-		newPercent := gco.currentGCPercent - 5
-		if newPercent < 20 {
-			newPercent = 20
-		}
-		
-		if newPercent != gco.currentGCPercent {
-			gco.currentGCPercent = newPercent
-			debug.SetGCPercent(newPercent)
-			
-			log.Info().
-				Dur("average_pause", gco.stats.AveragePause).
-				Dur("target_pause", gco.gcPauseTarget).
-				Int("new_gc_percent", newPercent).
-				Msg("Reduced GC percent due to high pause times")
+	log.Info().
+		Int("old_gc_percent", gco.currentGCPercent).
+		Int("new_gc_percent", newPercent).
+		Dur("p99_pause", gco.stats.P99Pause).
+		Dur("target_pause", gco.gcPauseTarget).
+		Float64("gc_overhead", gco.stats.GCOverhead).
+		Float64("heap_growth_rate", gco.stats.HeapGrowthRate).
+		Str("reason", reason).
+		Msg("Adjusted GC percent")
+
+	gco.currentGCPercent = newPercent
+	gco.stats.GCPercent = newPercent
+	debug.SetGCPercent(newPercent)
+}
+
+// nextGCPercent picks GOGC for the next interval from the observed pause
+// times, GC CPU overhead and heap growth, returning why it changed. Each step
+// moves by a fifth to a quarter so a single noisy interval cannot swing it.
+func (gco *GCOptimizer) nextGCPercent() (int, string) {
+	current := gco.currentGCPercent
+	step := func(fraction int) int {
+		if s := current / fraction; s > 5 {
+			return s
 		}
+		return 5
+	}
+	nearLimit := gco.heapNearLimit()
+
+	switch {
+	case gco.stats.P99Pause > gco.gcPauseTarget:
+		// Smaller heaps between collections shorten mark termination
+		return gco.clampGCPercent(current - step(5)), "p99 pause above target"
+	case nearLimit:
+		return gco.clampGCPercent(current - step(5)), "heap growing towards memory limit"
+	case gco.stats.GCOverhead > 10.0:
+		// More than 10% of CPU time spent in GC: collect less often
+		return gco.clampGCPercent(current + step(4)), "GC CPU overhead above 10%"
 	}
 
-	// Check if GC overhead is too high
-	if gco.stats.GCOverhead > 10.0 { // More than 10% time spent in GC
-		// Increase GC percentage to reduce collection frequency
-		newPercent := gco.currentGCPercent + 10
-		if newPercent > 200 {
-			newPercent = 200
-		}
-		
-		if newPercent != gco.currentGCPercent {
-			gco.currentGCPercent = newPercent
-			debug.SetGCPercent(newPercent)
-			
-			log.Info().
-				Float64("gc_overhead", gco.stats.GCOverhead).
-				Int("new_gc_percent", newPercent).
-				Msg("Increased GC percent due to high overhead")
+	// Conditions have eased: drift back towards the configured target
+	target := gco.clampGCPercent(gco.config.GCTargetPercent)
+	if current != target && gco.stats.P99Pause < gco.gcPauseTarget/2 && gco.stats.GCOverhead < 5.0 {
+		if current < target {
+			return min(current+step(5), target), "back towards target after recovery"
 		}
+		return max(current-step(5), target), "back towards target after recovery"
 	}
+	return current, ""
+}
 
-	// Adjust memory limit if heap is growing too large
-	if gco.stats.HeapInUse > uint64(gco.memoryLimit)*8/10 {
-		// Trigger more aggressive GC
-		gco.ForceGC()
-		
-		log.Info().
-			Uint64("heap_in_use", gco.stats.HeapInUse).
-			Int64("memory_limit", gco.memoryLimit).
-			Msg("Triggered GC due to high heap usage")
+// heapNearLimit reports whether the heap in use is, or at its current growth
+// rate will be within a minute, above 80% of the memory limit
+func (gco *GCOptimizer) heapNearLimit() bool {
+	if gco.memoryLimit <= 0 {
+		return false
 	}
+	projected := float64(gco.stats.HeapInUse) + math.Max(gco.stats.HeapGrowthRate, 0)*60
+	return projected > float64(gco.memoryLimit)*0.8
+}
+
+// clampGCPercent bounds percent to the configured adaptive range
+func (gco *GCOptimizer) clampGCPercent(percent int) int {
+	lower, upper := gco.config.GCMinPercent, gco.config.GCMaxPercent
+	if lower <= 0 {
+		lower = 20
+	}
+	if upper <= 0 {
+		upper = 200
+	}
+	return max(lower, min(percent, upper))
 }
 
 // setOptimalGCPercent sets the optimal GC target percentage
@@ -290,15 +357,34 @@ func (gco *GCOptimizer) setOptimalGCPercent() {
 		Msg("Set GC target percentage")
 }
 
-// setMemoryLimit sets the memory limit for garbage collection
+// setMemoryLimit sets the memory limit for garbage collection. A GOMEMLIMIT
+// set by the operator is kept; otherwise the limit is derived from the
+// container's cgroup, then from GCMemoryLimit.
 func (gco *GCOptimizer) setMemoryLimit() {
-	if gco.memoryLimit > 0 {
+	if os.Getenv("GOMEMLIMIT") != "" {
+		// A negative input only reads the current setting
+		gco.memoryLimit = debug.SetMemoryLimit(-1)
+		gco.memoryLimitSource = MemoryLimitSourceEnv
+	} else if limit := containerMemoryLimit(); limit > 0 && gco.config.GCMemoryLimitRatio > 0 {
+		gco.memoryLimit = int64(float64(limit) * gco.config.GCMemoryLimitRatio)
+		gco.memoryLimitSource = MemoryLimitSourceCgroup
 		debug.SetMemoryLimit(gco.memoryLimit)
-		
-		log.Info().
-			Int64("memory_limit_mb", gco.memoryLimit/(1024*1024)).
-			Msg("Set GC memory limit")
+	} else if gco.config.GCMemoryLimit > 0 {
+		gco.memoryLimit = gco.config.GCMemoryLimit
+		gco.memoryLimitSource = MemoryLimitSourceConfig
+		debug.SetMemoryLimit(gco.memoryLimit)
+	} else {
+		gco.memoryLimit = 0
+		gco.memoryLimitSource = MemoryLimitSourceNone
+		return
 	}
+
+	gco.stats.MemoryLimit = gco.memoryLimit
+	gco.stats.MemoryLimitSource = gco.memoryLimitSource
+	log.Info().
+		Int64("memory_limit_mb", gco.memoryLimit/(1024*1024)).
+		Str("source", gco.memoryLimitSource).
+		Msg("Set GC memory limit")
 }
 
 // GetRecommendations returns GC optimization recommendations
@@ -323,7 +409,7 @@ func (gco *GCOptimizer) GetRecommendations() []string {
 			"GC frequency is high - consider optimizing memory allocations or increasing heap size")
 	}
 
-	if gco.stats.HeapInUse > uint64(gco.memoryLimit)*7/10 {
+	if gco.memoryLimit > 0 && gco.stats.HeapInUse > uint64(gco.memoryLimit)*7/10 {
 		recommendations = append(recommendations,
 			"Heap usage is approaching memory limit - consider increasing memory limit or optimizing memory usage")
 	}
@@ -0,0 +1,91 @@
+package performance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCgroupLimit(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, value string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(value), 0o644))
+		return path
+	}
+
+	assert.Equal(t, int64(2<<30), readCgroupLimit(write("v2", "2147483648\n")))
+	assert.Zero(t, readCgroupLimit(write("max", "max\n")))
+	assert.Zero(t, readCgroupLimit(write("v1", "9223372036854771712\n")))
+	assert.Zero(t, readCgroupLimit(filepath.Join(dir, "missing")))
+}
+
+func TestContainerMemoryLimitV1(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "memory"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "memory", "memory.limit_in_bytes"), []byte("536870912"), 0o644))
+
+	original := cgroupRoot
+	cgroupRoot = root
+	defer func() { cgroupRoot = original }()
+
+	assert.Equal(t, int64(512<<20), containerMemoryLimit())
+}
+
+func TestPausePercentile(t *testing.T) {
+	pauses := make([]time.Duration, 100)
+	for i := range pauses {
+		pauses[i] = time.Duration(i+1) * time.Microsecond
+	}
+
+	assert.Equal(t, 50*time.Microsecond, pausePercentile(pauses, 0.50))
+	assert.Equal(t, 99*time.Microsecond, pausePercentile(pauses, 0.99))
+	assert.Zero(t, pausePercentile(nil, 0.99))
+}
+
+func newTestGCOptimizer(percent int) *GCOptimizer {
+	config := DefaultOptimizerConfig()
+	return &GCOptimizer{
+		config:           config,
+		currentGCPercent: percent,
+		gcPauseTarget:    config.GCMaxPause,
+		memoryLimit:      1 << 30,
+		stats:            &GCStats{},
+	}
+}
+
+func TestNextGCPercent(t *testing.T) {
+	// Long pauses lower GOGC
+	gco := newTestGCOptimizer(100)
+	gco.stats.P99Pause = 20 * time.Millisecond
+	percent, reason := gco.nextGCPercent()
+	assert.Equal(t, 80, percent)
+	assert.NotEmpty(t, reason)
+
+	// Fast heap growth towards the limit lowers GOGC
+	gco = newTestGCOptimizer(100)
+	gco.stats.HeapInUse = 600 << 20
+	gco.stats.HeapGrowthRate = 10 << 20
+	percent, _ = gco.nextGCPercent()
+	assert.Equal(t, 80, percent)
+
+	// High GC overhead raises GOGC, bounded by GCMaxPercent
+	gco = newTestGCOptimizer(190)
+	gco.stats.GCOverhead = 25
+	percent, _ = gco.nextGCPercent()
+	assert.Equal(t, 200, percent)
+
+	// Once healthy it drifts back to the configured target
+	gco = newTestGCOptimizer(30)
+	percent, _ = gco.nextGCPercent()
+	assert.Equal(t, 36, percent)
+
+	gco = newTestGCOptimizer(50)
+	percent, reason = gco.nextGCPercent()
+	assert.Equal(t, 50, percent)
+	assert.Empty(t, reason)
+}
@@ -0,0 +1,72 @@
+package performance
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sources of the GC memory limit reported in GCStats
+const (
+	MemoryLimitSourceEnv    = "env"    // GOMEMLIMIT set by the operator
+	MemoryLimitSourceCgroup = "cgroup" // derived from the container limit
+	MemoryLimitSourceConfig = "config" // OptimizerConfig.GCMemoryLimit
+	MemoryLimitSourceNone   = "none"
+)
+
+// cgroupRoot is where the cgroup filesystem is mounted
+var cgroupRoot = "/sys/fs/cgroup"
+
+// unlimitedCgroupMemory is the smallest value cgroup v1 reports for "no
+// limit"; it is the largest page-aligned int64
+const unlimitedCgroupMemory = 1 << 62
+
+// containerMemoryLimit returns the memory limit of the cgroup this process
+// runs in, checking cgroup v2 before v1. It returns 0 without a limit.
+func containerMemoryLimit() int64 {
+	// cgroup v2: the process's own group, falling back to the root
+	if group := cgroupV2Path(); group != "" {
+		if limit := readCgroupLimit(filepath.Join(cgroupRoot, group, "memory.max")); limit > 0 {
+			return limit
+		}
+	}
+	if limit := readCgroupLimit(filepath.Join(cgroupRoot, "memory.max")); limit > 0 {
+		return limit
+	}
+
+	// cgroup v1
+	return readCgroupLimit(filepath.Join(cgroupRoot, "memory", "memory.limit_in_bytes"))
+}
+
+// cgroupV2Path returns the unified hierarchy path from /proc/self/cgroup
+func cgroupV2Path() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return strings.TrimSpace(path)
+		}
+	}
+	return ""
+}
+
+// readCgroupLimit parses a cgroup memory limit file, treating "max" and the
+// v1 sentinel as no limit
+func readCgroupLimit(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" || value == "max" {
+		return 0
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 || limit >= unlimitedCgroupMemory {
+		return 0
+	}
+	return limit
+}
@@ -53,6 +53,11 @@ type OptimizerConfig struct {
 	GCTargetPercent     int           `yaml:"gc_target_percent"`
 	GCMaxPause          time.Duration `yaml:"gc_max_pause"`
 	GCMemoryLimit       int64         `yaml:"gc_memory_limit"`          // bytes
+	GCMinPercent        int           `yaml:"gc_min_percent"`           // adaptive GOGC bounds
+	GCMaxPercent        int           `yaml:"gc_max_percent"`
+	// GOMEMLIMIT is set to this share of the container memory limit when
+	// one is found, replacing GCMemoryLimit; 0 disables cgroup detection
+	GCMemoryLimitRatio  float64       `yaml:"gc_memory_limit_ratio"`
 
 	// Connection pool settings
 	MaxConnections      int           `yaml:"max_connections"`
@@ -86,6 +91,10 @@ func DefaultOptimizerConfig() *OptimizerConfig {
 		GCTargetPercent: 50,
 		GCMaxPause:      5 * time.Millisecond,
 		GCMemoryLimit:   1 << 30, // 1GB
+		GCMinPercent:    20,
+		GCMaxPercent:    200,
+		// Leave headroom for memory the Go runtime does not manage
+		GCMemoryLimitRatio: 0.9,
 
 		MaxConnections:     100,
 		MaxIdleConnections: 25,
@@ -120,6 +129,15 @@ type PerformanceMetrics struct {
 	GoroutineCount    int     `json:"goroutine_count"`
 	GCPauseMS         float64 `json:"gc_pause_ms"`
 
+	// Current GC settings and recent pause percentiles
+	GCPercent           int     `json:"gc_percent"`
+	GCMemoryLimitBytes  int64   `json:"gc_memory_limit_bytes"`
+	GCMemoryLimitSource string  `json:"gc_memory_limit_source"`
+	GCPauseP50MS        float64 `json:"gc_pause_p50_ms"`
+	GCPauseP95MS        float64 `json:"gc_pause_p95_ms"`
+	GCPauseP99MS        float64 `json:"gc_pause_p99_ms"`
+	HeapGrowthBytesPerSec float64 `json:"heap_growth_bytes_per_sec"`
+
 	// Connection metrics
 	ActiveConnections int `json:"active_connections"`
 	IdleConnections   int `json:"idle_connections"`
@@ -294,6 +312,8 @@ func (so *SystemOptimizer) runPerformanceMonitoring() {
 	ticker := time.NewTicker(so.config.MetricsInterval)
 	defer ticker.Stop()
 
+	so.updateMetrics()
+
 	for {
 		select {
 		case <-so.ctx.Done():
@@ -317,6 +337,16 @@ func (so *SystemOptimizer) updateMetrics() {
 	so.metrics.GoroutineCount = runtime.NumGoroutine()
 	so.metrics.GCPauseMS = float64(m.PauseTotalNs) / 1e6
 
+	// Update GC settings, which the GC optimizer may have changed
+	gcStats := so.gcOptimizer.GetStats()
+	so.metrics.GCPercent = gcStats.GCPercent
+	so.metrics.GCMemoryLimitBytes = gcStats.MemoryLimit
+	so.metrics.GCMemoryLimitSource = gcStats.MemoryLimitSource
+	so.metrics.GCPauseP50MS = float64(gcStats.P50Pause) / float64(time.Millisecond)
+	so.metrics.GCPauseP95MS = float64(gcStats.P95Pause) / float64(time.Millisecond)
+	so.metrics.GCPauseP99MS = float64(gcStats.P99Pause) / float64(time.Millisecond)
+	so.metrics.HeapGrowthBytesPerSec = gcStats.HeapGrowthRate
+
 	// Update cache metrics
 	cacheStats := so.cacheManager.GetStats()
 	totalRequests := cacheStats.TotalHits + cacheStats.TotalMisses
@@ -340,6 +370,8 @@ func (so *SystemOptimizer) updateMetrics() {
 			Float64("memory_mb", so.metrics.MemoryUsageMB).
 			Int("goroutines", so.metrics.GoroutineCount).
 			Float64("gc_pause_ms", so.metrics.GCPauseMS).
+			Int("gc_percent", so.metrics.GCPercent).
+			Float64("gc_pause_p99_ms", so.metrics.GCPauseP99MS).
 			Float64("cache_hit_rate", so.metrics.CacheHitRate).
 			Int("active_connections", so.metrics.ActiveConnections).
 			Msg("Performance metrics updated")
@@ -0,0 +1,221 @@
+package placement
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Node label declaring the type of a node's model storage, and its values
+const (
+	LabelDiskType = "disk.type"
+	DiskTypeNVMe  = "nvme"
+	DiskTypeHDD   = "hdd"
+)
+
+// Defaults of disk aware placement
+const (
+	DefaultSlowDiskLatency = 20 * time.Millisecond
+	DefaultLargeModelSize  = 4 << 30
+	DefaultDiskLoadTimeout = 10 * time.Minute
+	DefaultSwapThreshold   = 3
+	DefaultSwapWindow      = time.Hour
+)
+
+// DiskStats is the disk I/O a node reports
+type DiskStats = types.DiskStats
+
+// DiskConfig configures disk aware placement of model loads
+type DiskConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SlowLatency is the average I/O latency from which a disk counts as
+	// slow. Disks labeled hdd are always slow and those labeled nvme never.
+	SlowLatency time.Duration `yaml:"slow_latency" mapstructure:"slow_latency"`
+	// LargeModelSize is the size in bytes from which loading a model
+	// contends for a slow disk
+	LargeModelSize int64 `yaml:"large_model_size" mapstructure:"large_model_size"`
+	// LoadTimeout is how long a load counts as in flight when the node
+	// does not report the model loaded
+	LoadTimeout time.Duration `yaml:"load_timeout" mapstructure:"load_timeout"`
+	// A model loaded SwapThreshold times within SwapWindow is frequently
+	// swapped, and placed on NVMe nodes when there are any
+	SwapThreshold int           `yaml:"swap_threshold" mapstructure:"swap_threshold"`
+	SwapWindow    time.Duration `yaml:"swap_window" mapstructure:"swap_window"`
+}
+
+// Validate checks the settings
+func (c DiskConfig) Validate() error {
+	if c.SlowLatency < 0 || c.LargeModelSize < 0 || c.LoadTimeout < 0 || c.SwapThreshold < 0 || c.SwapWindow < 0 {
+		return fmt.Errorf("disk placement settings must not be negative")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c DiskConfig) withDefaults() DiskConfig {
+	if c.SlowLatency == 0 {
+		c.SlowLatency = DefaultSlowDiskLatency
+	}
+	if c.LargeModelSize == 0 {
+		c.LargeModelSize = DefaultLargeModelSize
+	}
+	if c.LoadTimeout == 0 {
+		c.LoadTimeout = DefaultDiskLoadTimeout
+	}
+	if c.SwapThreshold == 0 {
+		c.SwapThreshold = DefaultSwapThreshold
+	}
+	if c.SwapWindow == 0 {
+		c.SwapWindow = DefaultSwapWindow
+	}
+	return c
+}
+
+// diskLoad is a model load in flight on a node
+type diskLoad struct {
+	model   string
+	large   bool
+	started time.Time
+}
+
+// DiskScheduler keeps simultaneous large model loads off nodes with slow
+// disks and frequently swapped models on NVMe nodes. It learns of loads
+// from LoadStarted, and counts a load as done once the node reports the
+// model or LoadTimeout passes.
+type DiskScheduler struct {
+	cfg DiskConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	loads   map[string][]diskLoad  // in flight, by node
+	history map[string][]time.Time // load starts within SwapWindow, by model
+}
+
+// NewDiskScheduler creates a disk scheduler
+func NewDiskScheduler(cfg DiskConfig) *DiskScheduler {
+	return &DiskScheduler{
+		cfg:     cfg.withDefaults(),
+		now:     time.Now,
+		loads:   make(map[string][]diskLoad),
+		history: make(map[string][]time.Time),
+	}
+}
+
+// Config returns the configuration, with its defaults applied
+func (d *DiskScheduler) Config() DiskConfig {
+	return d.cfg
+}
+
+// Slow reports whether the node's disk is slow, by its disk.type label or
+// else its measured latency
+func (d *DiskScheduler) Slow(node Node) bool {
+	switch node.Labels[LabelDiskType] {
+	case DiskTypeNVMe:
+		return false
+	case DiskTypeHDD:
+		return true
+	}
+	return node.Disk.Latency >= d.cfg.SlowLatency
+}
+
+// LoadStarted records that a model of size bytes started loading on a node
+func (d *DiskScheduler) LoadStarted(nodeID, model string, size int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.loads[nodeID] = append(d.loads[nodeID], diskLoad{model: model, large: size >= d.cfg.LargeModelSize, started: now})
+	d.history[model] = append(d.recentLoads(model, now), now)
+}
+
+// FrequentlySwapped reports whether a model was loaded SwapThreshold times
+// within SwapWindow
+func (d *DiskScheduler) FrequentlySwapped(model string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.recentLoads(model, d.now())) >= d.cfg.SwapThreshold
+}
+
+// recentLoads returns the load starts of a model within SwapWindow; d.mu
+// must be held
+func (d *DiskScheduler) recentLoads(model string, now time.Time) []time.Time {
+	starts := d.history[model]
+	i := 0
+	for i < len(starts) && now.Sub(starts[i]) > d.cfg.SwapWindow {
+		i++
+	}
+	if i == len(starts) {
+		delete(d.history, model)
+		return nil
+	}
+	d.history[model] = starts[i:]
+	return starts[i:]
+}
+
+// largeLoad returns a large load in flight on the node, dropping the loads
+// that are done; d.mu must be held
+func (d *DiskScheduler) largeLoad(node Node, now time.Time) (string, bool) {
+	var inFlight []diskLoad
+	for _, l := range d.loads[node.ID] {
+		if now.Sub(l.started) < d.cfg.LoadTimeout && !contains(node.Models, l.model) {
+			inFlight = append(inFlight, l)
+		}
+	}
+	if len(inFlight) == 0 {
+		delete(d.loads, node.ID)
+	} else {
+		d.loads[node.ID] = inFlight
+	}
+	for _, l := range inFlight {
+		if l.large {
+			return l.model, true
+		}
+	}
+	return "", false
+}
+
+// Prefer returns the nodes best suited to load a model of size bytes on,
+// keeping their order, and why the others were passed over. A large model
+// avoids slow disks already loading a large model, and a frequently
+// swapped one goes to NVMe nodes when there are any. When every node has
+// a busy slow disk, all are returned, as the load queue of the node
+// staggers its loads.
+func (d *DiskScheduler) Prefer(model string, size int64, nodes []Node) ([]Node, []Rejection) {
+	swapped := d.FrequentlySwapped(model)
+
+	d.mu.Lock()
+	now := d.now()
+	var idle []Node
+	var passed []Rejection
+	for _, node := range nodes {
+		busy, loading := d.largeLoad(node, now)
+		if size >= d.cfg.LargeModelSize && loading && d.Slow(node) {
+			passed = append(passed, Rejection{NodeID: node.ID, Reason: fmt.Sprintf("slow disk busy loading %s", busy)})
+			continue
+		}
+		idle = append(idle, node)
+	}
+	d.mu.Unlock()
+	if len(idle) == 0 {
+		return nodes, nil
+	}
+	if !swapped {
+		return idle, passed
+	}
+
+	var nvme []Node
+	var others []Rejection
+	for _, node := range idle {
+		if node.Labels[LabelDiskType] == DiskTypeNVMe {
+			nvme = append(nvme, node)
+		} else {
+			others = append(others, Rejection{NodeID: node.ID, Reason: "frequently swapped model prefers NVMe disks"})
+		}
+	}
+	if len(nvme) == 0 {
+		return idle, passed
+	}
+	return nvme, append(passed, others...)
+}
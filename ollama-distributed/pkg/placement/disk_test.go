@@ -0,0 +1,88 @@
+package placement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDiskNodes() []Node {
+	return []Node{
+		{ID: "hdd", Labels: map[string]string{LabelDiskType: DiskTypeHDD}},
+		{ID: "slow", Disk: DiskStats{Latency: 50 * time.Millisecond}},
+		{ID: "fast", Disk: DiskStats{Latency: 2 * time.Millisecond}},
+		{ID: "nvme", Labels: map[string]string{LabelDiskType: DiskTypeNVMe}, Disk: DiskStats{Latency: time.Second}},
+	}
+}
+
+func TestSlowDisk(t *testing.T) {
+	d := NewDiskScheduler(DiskConfig{})
+	var slow []string
+	for _, node := range testDiskNodes() {
+		if d.Slow(node) {
+			slow = append(slow, node.ID)
+		}
+	}
+	assert.Equal(t, []string{"hdd", "slow"}, slow, "labels win over measured latency")
+}
+
+func TestLargeLoadsAvoidBusySlowDisks(t *testing.T) {
+	d := NewDiskScheduler(DiskConfig{LargeModelSize: 1000})
+	nodes := testDiskNodes()
+	for _, node := range nodes {
+		d.LoadStarted(node.ID, "llama3:70b", 4000)
+	}
+
+	preferred, passed := d.Prefer("mixtral", 2000, nodes)
+	assert.Equal(t, []string{"fast", "nvme"}, nodeIDs(preferred))
+	require.Len(t, passed, 2)
+	assert.Equal(t, Rejection{NodeID: "hdd", Reason: "slow disk busy loading llama3:70b"}, passed[0])
+
+	preferred, _ = d.Prefer("phi3", 500, nodes)
+	assert.Len(t, preferred, 4, "small models load anywhere")
+
+	// Loads are done once the node holds the model, or after the timeout
+	nodes[0].Models = []string{"llama3:70b"}
+	preferred, _ = d.Prefer("mixtral", 2000, nodes)
+	assert.Equal(t, []string{"hdd", "fast", "nvme"}, nodeIDs(preferred))
+	d.now = func() time.Time { return time.Now().Add(time.Hour) }
+	preferred, _ = d.Prefer("mixtral", 2000, nodes)
+	assert.Len(t, preferred, 4)
+
+	// With every disk slow and busy all nodes are kept, the node queues
+	// staggering the loads
+	busy := nodes[:2]
+	d.LoadStarted("slow", "llama3:70b", 4000)
+	d.LoadStarted("hdd", "qwen:72b", 4000)
+	preferred, passed = d.Prefer("mixtral", 2000, busy)
+	assert.Len(t, preferred, 2)
+	assert.Empty(t, passed)
+}
+
+func TestFrequentlySwappedModelsPreferNVMe(t *testing.T) {
+	d := NewDiskScheduler(DiskConfig{SwapThreshold: 2, SwapWindow: time.Minute})
+	nodes := testDiskNodes()
+
+	preferred, _ := d.Prefer("phi3", 0, nodes)
+	assert.Len(t, preferred, 4)
+
+	d.LoadStarted("fast", "phi3", 0)
+	d.LoadStarted("fast", "phi3", 0)
+	assert.True(t, d.FrequentlySwapped("phi3"))
+	preferred, passed := d.Prefer("phi3", 0, nodes)
+	assert.Equal(t, []string{"nvme"}, nodeIDs(preferred))
+	assert.Len(t, passed, 3)
+
+	preferred, _ = d.Prefer("phi3", 0, nodes[:3])
+	assert.Len(t, preferred, 3, "without NVMe nodes any node will do")
+
+	d.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.False(t, d.FrequentlySwapped("phi3"), "swaps age out of the window")
+}
+
+func TestDiskConfigValidate(t *testing.T) {
+	assert.NoError(t, DiskConfig{SlowLatency: time.Millisecond}.Validate())
+	assert.Error(t, DiskConfig{SwapThreshold: -1}.Validate())
+}
@@ -0,0 +1,320 @@
+// Package placement holds the model placement constraints shared by the
+// scheduler, the replication policy engine and the partition planner:
+// anti-affinity rules that keep models apart, affinity rules that steer a
+// model towards nodes with given labels, and node taints that repel models
+// without a matching toleration.
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Constraints is the set of placement rules, as declared in config or
+// through the API
+type Constraints struct {
+	Affinity     []AffinityRule     `yaml:"affinity" json:"affinity"`
+	AntiAffinity []AntiAffinityRule `yaml:"anti_affinity" json:"anti_affinity"`
+	Tolerations  []ModelTolerations `yaml:"tolerations" json:"tolerations,omitempty"`
+}
+
+// AffinityRule steers a model towards nodes carrying all of Labels. A
+// required rule excludes other nodes; a preferred one adds Weight (1 when
+// unset) to the score of matching nodes.
+type AffinityRule struct {
+	Model    string            `yaml:"model" json:"model"`
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+	Required bool              `yaml:"required" json:"required"`
+	Weight   float64           `yaml:"weight" json:"weight,omitempty"`
+}
+
+// AntiAffinityRule keeps the listed models off a shared node, e.g. two large
+// models that would contend for memory
+type AntiAffinityRule struct {
+	Models []string `yaml:"models" json:"models"`
+	Reason string   `yaml:"reason" json:"reason,omitempty"`
+}
+
+// Node is the view of a node placement decisions need
+type Node struct {
+	ID     string
+	Labels map[string]string
+	Taints []Taint
+	Models []string // models the node already holds or is assigned
+	Disk   DiskStats
+}
+
+// Rejection records why a node cannot take a model
+type Rejection struct {
+	NodeID string `json:"node_id"`
+	Reason string `json:"reason"`
+}
+
+// Validate checks that every rule names its models and every toleration is
+// well formed
+func (c Constraints) Validate() error {
+	for i, rule := range c.Affinity {
+		if rule.Model == "" {
+			return fmt.Errorf("affinity rule %d has no model", i)
+		}
+		if len(rule.Labels) == 0 {
+			return fmt.Errorf("affinity rule %d for %s has no labels", i, rule.Model)
+		}
+		if rule.Weight < 0 {
+			return fmt.Errorf("affinity rule %d for %s has a negative weight", i, rule.Model)
+		}
+	}
+	for i, rule := range c.AntiAffinity {
+		if len(rule.Models) < 2 {
+			return fmt.Errorf("anti-affinity rule %d needs at least two models", i)
+		}
+	}
+	for _, mt := range c.Tolerations {
+		if mt.Model == "" {
+			return fmt.Errorf("tolerations declared without a model")
+		}
+		if err := ValidateTolerations(mt.Tolerations); err != nil {
+			return fmt.Errorf("tolerations of %s: %w", mt.Model, err)
+		}
+	}
+	return nil
+}
+
+// ValidateTolerations checks a list of tolerations
+func ValidateTolerations(tolerations []Toleration) error {
+	for _, t := range tolerations {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns nil when the model may be placed on the node, else an error
+// naming the rule it breaks
+func (c Constraints) Check(model string, node Node) error {
+	return c.CheckFor(model, nil, node)
+}
+
+// CheckFor is Check for a request carrying its own tolerations in addition
+// to those declared for the model
+func (c Constraints) CheckFor(model string, tolerations []Toleration, node Node) error {
+	if len(node.Taints) > 0 {
+		all := c.tolerationsFor(model, tolerations)
+		for _, taint := range node.Taints {
+			if taint.Effect == TaintNoSchedule && !tolerated(all, taint) {
+				return fmt.Errorf("untolerated taint %s", taint)
+			}
+		}
+	}
+
+	for _, rule := range c.AntiAffinity {
+		if !contains(rule.Models, model) {
+			continue
+		}
+		for _, other := range node.Models {
+			if other != model && contains(rule.Models, other) {
+				reason := fmt.Sprintf("anti-affinity with %s", other)
+				if rule.Reason != "" {
+					reason += ": " + rule.Reason
+				}
+				return errors.New(reason)
+			}
+		}
+	}
+
+	for _, rule := range c.Affinity {
+		if rule.Required && rule.Model == model && !hasLabels(node, rule.Labels) {
+			return fmt.Errorf("requires labels %s", formatLabels(rule.Labels))
+		}
+	}
+	return nil
+}
+
+// Score returns the summed weight of the preferred affinity rules the node
+// satisfies for the model
+func (c Constraints) Score(model string, node Node) float64 {
+	score := 0.0
+	for _, rule := range c.Affinity {
+		if rule.Required || rule.Model != model || !hasLabels(node, rule.Labels) {
+			continue
+		}
+		if rule.Weight == 0 {
+			score++
+		} else {
+			score += rule.Weight
+		}
+	}
+	return score
+}
+
+// Rank drops the nodes the model may not be placed on and orders the rest
+// by affinity score, keeping the given order among equal scores
+func (c Constraints) Rank(model string, nodes []Node) ([]Node, []Rejection) {
+	return c.RankFor(model, nil, nodes)
+}
+
+// RankFor is Rank for a request carrying its own tolerations. Nodes with an
+// untolerated PreferNoSchedule taint go after all nodes without one.
+func (c Constraints) RankFor(model string, tolerations []Toleration, nodes []Node) ([]Node, []Rejection) {
+	all := c.tolerationsFor(model, tolerations)
+	allowed := make([]Node, 0, len(nodes))
+	keys := make(map[string]rankKey, len(nodes))
+	var rejected []Rejection
+
+	for _, node := range nodes {
+		if err := c.CheckFor(model, tolerations, node); err != nil {
+			rejected = append(rejected, Rejection{NodeID: node.ID, Reason: err.Error()})
+			continue
+		}
+		allowed = append(allowed, node)
+		keys[node.ID] = c.rankKey(model, all, node)
+	}
+
+	sort.SliceStable(allowed, func(i, j int) bool {
+		return keys[allowed[i].ID].before(keys[allowed[j].ID])
+	})
+	return allowed, rejected
+}
+
+// PreferredFor returns the best ranked of the allowed nodes: those without
+// an untolerated PreferNoSchedule taint if there are any, and among them the
+// ones with the highest affinity score
+func (c Constraints) PreferredFor(model string, tolerations []Toleration, nodes []Node) ([]Node, []Rejection) {
+	ranked, rejected := c.RankFor(model, tolerations, nodes)
+	if len(ranked) == 0 {
+		return ranked, rejected
+	}
+
+	all := c.tolerationsFor(model, tolerations)
+	best := c.rankKey(model, all, ranked[0])
+	n := 1
+	for n < len(ranked) && c.rankKey(model, all, ranked[n]) == best {
+		n++
+	}
+	return ranked[:n], rejected
+}
+
+// rankKey orders allowed nodes: untainted before repelled, then by score
+type rankKey struct {
+	repelled bool
+	score    float64
+}
+
+func (k rankKey) before(other rankKey) bool {
+	if k.repelled != other.repelled {
+		return !k.repelled
+	}
+	return k.score > other.score
+}
+
+func (c Constraints) rankKey(model string, tolerations []Toleration, node Node) rankKey {
+	key := rankKey{score: c.Score(model, node)}
+	for _, taint := range node.Taints {
+		if taint.Effect == TaintPreferNoSchedule && !tolerated(tolerations, taint) {
+			key.repelled = true
+		}
+	}
+	return key
+}
+
+// Policy holds the current constraints and lets them be replaced at runtime.
+// A nil *Policy has no rules, though node taints still apply.
+type Policy struct {
+	mu          sync.RWMutex
+	constraints Constraints
+}
+
+// NewPolicy creates a policy with validated constraints
+func NewPolicy(constraints Constraints) (*Policy, error) {
+	if err := constraints.Validate(); err != nil {
+		return nil, err
+	}
+	return &Policy{constraints: constraints}, nil
+}
+
+// Constraints returns the current constraints
+func (p *Policy) Constraints() Constraints {
+	if p == nil {
+		return Constraints{}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.constraints
+}
+
+// SetConstraints validates and replaces the constraints
+func (p *Policy) SetConstraints(constraints Constraints) error {
+	if err := constraints.Validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.constraints = constraints
+	p.mu.Unlock()
+	return nil
+}
+
+// Check reports whether the model may be placed on the node
+func (p *Policy) Check(model string, node Node) error {
+	return p.Constraints().Check(model, node)
+}
+
+// Rank filters and orders candidate nodes for the model
+func (p *Policy) Rank(model string, nodes []Node) ([]Node, []Rejection) {
+	return p.Constraints().Rank(model, nodes)
+}
+
+// CheckFor reports whether a request with the given tolerations may be
+// placed on the node
+func (p *Policy) CheckFor(model string, tolerations []Toleration, node Node) error {
+	return p.Constraints().CheckFor(model, tolerations, node)
+}
+
+// RankFor filters and orders candidate nodes for a request with the given
+// tolerations
+func (p *Policy) RankFor(model string, tolerations []Toleration, nodes []Node) ([]Node, []Rejection) {
+	return p.Constraints().RankFor(model, tolerations, nodes)
+}
+
+// PreferredFor returns the best ranked nodes for a request with the given
+// tolerations
+func (p *Policy) PreferredFor(model string, tolerations []Toleration, nodes []Node) ([]Node, []Rejection) {
+	return p.Constraints().PreferredFor(model, tolerations, nodes)
+}
+
+func hasLabels(node Node, labels map[string]string) bool {
+	for key, value := range labels {
+		if v, ok := node.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s := ""
+	for i, key := range keys {
+		if i > 0 {
+			s += ","
+		}
+		s += key + "=" + labels[key]
+	}
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
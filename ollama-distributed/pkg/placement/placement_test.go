@@ -0,0 +1,70 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConstraints() Constraints {
+	return Constraints{
+		AntiAffinity: []AntiAffinityRule{
+			{Models: []string{"llama3:70b", "mixtral:8x22b"}, Reason: "memory contention"},
+		},
+		Affinity: []AffinityRule{
+			{Model: "codellama", Labels: map[string]string{"gpu": "a100"}, Weight: 2},
+			{Model: "codellama", Labels: map[string]string{"zone": "a"}},
+			{Model: "whisper", Labels: map[string]string{"gpu": "t4"}, Required: true},
+		},
+	}
+}
+
+func TestAntiAffinity(t *testing.T) {
+	c := testConstraints()
+
+	err := c.Check("llama3:70b", Node{ID: "n1", Models: []string{"mixtral:8x22b"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "memory contention")
+
+	assert.NoError(t, c.Check("llama3:70b", Node{ID: "n1", Models: []string{"llama3:70b", "phi3"}}))
+	assert.NoError(t, c.Check("phi3", Node{ID: "n1", Models: []string{"mixtral:8x22b"}}))
+}
+
+func TestAffinityRanking(t *testing.T) {
+	c := testConstraints()
+	nodes := []Node{
+		{ID: "plain"},
+		{ID: "zone-a", Labels: map[string]string{"zone": "a"}},
+		{ID: "a100", Labels: map[string]string{"gpu": "a100"}},
+		{ID: "both", Labels: map[string]string{"gpu": "a100", "zone": "a"}},
+	}
+
+	ranked, rejected := c.Rank("codellama", nodes)
+	assert.Empty(t, rejected)
+	var ids []string
+	for _, node := range ranked {
+		ids = append(ids, node.ID)
+	}
+	assert.Equal(t, []string{"both", "a100", "zone-a", "plain"}, ids)
+
+	// Required affinity excludes nodes without the labels
+	ranked, rejected = c.Rank("whisper", append(nodes, Node{ID: "t4", Labels: map[string]string{"gpu": "t4"}}))
+	require.Len(t, ranked, 1)
+	assert.Equal(t, "t4", ranked[0].ID)
+	assert.Len(t, rejected, 4)
+	assert.Equal(t, "requires labels gpu=t4", rejected[0].Reason)
+}
+
+func TestPolicyValidation(t *testing.T) {
+	_, err := NewPolicy(Constraints{AntiAffinity: []AntiAffinityRule{{Models: []string{"a"}}}})
+	assert.Error(t, err)
+
+	policy, err := NewPolicy(testConstraints())
+	require.NoError(t, err)
+	assert.Error(t, policy.SetConstraints(Constraints{Affinity: []AffinityRule{{Model: "a"}}}))
+	assert.Len(t, policy.Constraints().Affinity, 3, "invalid constraints are not applied")
+
+	var none *Policy
+	assert.NoError(t, none.Check("llama3:70b", Node{Models: []string{"mixtral:8x22b"}}))
+}
@@ -0,0 +1,137 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NodeLabels are the labels and taints of one node
+type NodeLabels struct {
+	Labels map[string]string `yaml:"labels" json:"labels"`
+	Taints []Taint           `yaml:"taints" json:"taints"`
+}
+
+// Validate checks label keys are set and taints are well formed
+func (l NodeLabels) Validate() error {
+	for key := range l.Labels {
+		if key == "" {
+			return fmt.Errorf("label with empty key")
+		}
+	}
+	for _, taint := range l.Taints {
+		if err := taint.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Registry holds the labels and taints of the nodes in the cluster, set from
+// each node's config and through the API
+type Registry struct {
+	mu    sync.RWMutex
+	nodes map[string]NodeLabels
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{nodes: make(map[string]NodeLabels)}
+}
+
+// Set validates and replaces the labels and taints of a node
+func (r *Registry) Set(nodeID string, labels NodeLabels) error {
+	if nodeID == "" {
+		return fmt.Errorf("node ID is required")
+	}
+	if err := labels.Validate(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[nodeID] = cloneNodeLabels(labels)
+	return nil
+}
+
+// Get returns the labels and taints of a node
+func (r *Registry) Get(nodeID string) (NodeLabels, bool) {
+	if r == nil {
+		return NodeLabels{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	labels, ok := r.nodes[nodeID]
+	return cloneNodeLabels(labels), ok
+}
+
+// All returns the labels and taints of every node
+func (r *Registry) All() map[string]NodeLabels {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make(map[string]NodeLabels, len(r.nodes))
+	for id, labels := range r.nodes {
+		all[id] = cloneNodeLabels(labels)
+	}
+	return all
+}
+
+// Merge sets the nodes in other, keeping the ones it does not mention, and
+// reports the IDs that changed
+func (r *Registry) Merge(other map[string]NodeLabels) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changed []string
+	for id, labels := range other {
+		if id == "" || labels.Validate() != nil {
+			continue
+		}
+		if current, ok := r.nodes[id]; ok && equalNodeLabels(current, labels) {
+			continue
+		}
+		r.nodes[id] = cloneNodeLabels(labels)
+		changed = append(changed, id)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Node returns the placement view of a node holding the given models
+func (r *Registry) Node(nodeID string, models []string) Node {
+	labels, _ := r.Get(nodeID)
+	return Node{ID: nodeID, Labels: labels.Labels, Taints: labels.Taints, Models: models}
+}
+
+func cloneNodeLabels(l NodeLabels) NodeLabels {
+	clone := NodeLabels{}
+	if l.Labels != nil {
+		clone.Labels = make(map[string]string, len(l.Labels))
+		for k, v := range l.Labels {
+			clone.Labels[k] = v
+		}
+	}
+	if l.Taints != nil {
+		clone.Taints = append([]Taint(nil), l.Taints...)
+	}
+	return clone
+}
+
+func equalNodeLabels(a, b NodeLabels) bool {
+	if len(a.Labels) != len(b.Labels) || len(a.Taints) != len(b.Taints) {
+		return false
+	}
+	for k, v := range a.Labels {
+		if bv, ok := b.Labels[k]; !ok || bv != v {
+			return false
+		}
+	}
+	for i := range a.Taints {
+		if a.Taints[i] != b.Taints[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package placement
+
+import "fmt"
+
+// TaintEffect is what a taint does to workloads that do not tolerate it
+type TaintEffect string
+
+const (
+	// TaintNoSchedule keeps workloads without a matching toleration off the node
+	TaintNoSchedule TaintEffect = "NoSchedule"
+	// TaintPreferNoSchedule places such workloads on the node only when no
+	// untainted node is available
+	TaintPreferNoSchedule TaintEffect = "PreferNoSchedule"
+)
+
+// Toleration operators
+const (
+	TolerationOpEqual  = "Equal"
+	TolerationOpExists = "Exists"
+)
+
+// Taint repels workloads from a node unless they tolerate it
+type Taint struct {
+	Key    string      `yaml:"key" json:"key"`
+	Value  string      `yaml:"value" json:"value,omitempty"`
+	Effect TaintEffect `yaml:"effect" json:"effect"`
+}
+
+// Toleration lets a workload onto nodes with a matching taint. With the
+// Exists operator the value is ignored, and an empty key with Exists
+// tolerates every taint. An empty effect matches all effects.
+type Toleration struct {
+	Key      string      `yaml:"key" json:"key,omitempty"`
+	Operator string      `yaml:"operator" json:"operator,omitempty"` // Equal (default) or Exists
+	Value    string      `yaml:"value" json:"value,omitempty"`
+	Effect   TaintEffect `yaml:"effect" json:"effect,omitempty"`
+}
+
+// ModelTolerations declares the tolerations every request for a model has
+type ModelTolerations struct {
+	Model       string       `yaml:"model" json:"model"`
+	Tolerations []Toleration `yaml:"tolerations" json:"tolerations"`
+}
+
+// String renders the taint as key=value:Effect
+func (t Taint) String() string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+// Validate checks the taint has a key and a known effect
+func (t Taint) Validate() error {
+	if t.Key == "" {
+		return fmt.Errorf("taint has no key")
+	}
+	switch t.Effect {
+	case TaintNoSchedule, TaintPreferNoSchedule:
+		return nil
+	default:
+		return fmt.Errorf("taint %s has unknown effect %q", t.Key, t.Effect)
+	}
+}
+
+// Validate checks the operator is known and used consistently
+func (t Toleration) Validate() error {
+	switch t.Operator {
+	case "", TolerationOpEqual:
+		if t.Key == "" {
+			return fmt.Errorf("toleration with operator Equal needs a key")
+		}
+	case TolerationOpExists:
+		if t.Value != "" {
+			return fmt.Errorf("toleration %s with operator Exists must not have a value", t.Key)
+		}
+	default:
+		return fmt.Errorf("toleration %s has unknown operator %q", t.Key, t.Operator)
+	}
+	return nil
+}
+
+// Tolerates reports whether the toleration matches the taint
+func (t Toleration) Tolerates(taint Taint) bool {
+	if t.Effect != "" && t.Effect != taint.Effect {
+		return false
+	}
+	if t.Operator == TolerationOpExists {
+		return t.Key == "" || t.Key == taint.Key
+	}
+	return t.Key == taint.Key && t.Value == taint.Value
+}
+
+// tolerated reports whether any of the tolerations matches the taint
+func tolerated(tolerations []Toleration, taint Taint) bool {
+	for _, t := range tolerations {
+		if t.Tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// tolerationsFor returns the model's declared tolerations followed by the
+// request's own
+func (c Constraints) tolerationsFor(model string, request []Toleration) []Toleration {
+	var all []Toleration
+	for _, mt := range c.Tolerations {
+		if mt.Model == model {
+			all = append(all, mt.Tolerations...)
+		}
+	}
+	return append(all, request...)
+}
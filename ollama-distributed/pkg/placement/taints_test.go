@@ -0,0 +1,100 @@
+package placement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTolerationMatching(t *testing.T) {
+	taint := Taint{Key: "dedicated", Value: "training", Effect: TaintNoSchedule}
+
+	assert.True(t, Toleration{Key: "dedicated", Value: "training"}.Tolerates(taint))
+	assert.False(t, Toleration{Key: "dedicated", Value: "batch"}.Tolerates(taint))
+	assert.True(t, Toleration{Key: "dedicated", Operator: TolerationOpExists}.Tolerates(taint))
+	assert.True(t, Toleration{Operator: TolerationOpExists}.Tolerates(taint))
+	assert.False(t, Toleration{Key: "dedicated", Value: "training", Effect: TaintPreferNoSchedule}.Tolerates(taint))
+
+	assert.Error(t, Toleration{Operator: TolerationOpEqual}.Validate())
+	assert.Error(t, Toleration{Key: "k", Operator: TolerationOpExists, Value: "v"}.Validate())
+	assert.Error(t, Taint{Key: "k", Effect: "NoExecute"}.Validate())
+}
+
+func TestTaintsRepelUntoleratedModels(t *testing.T) {
+	c := Constraints{
+		Tolerations: []ModelTolerations{{
+			Model:       "llama3:70b",
+			Tolerations: []Toleration{{Key: "gpu", Value: "h100", Effect: TaintNoSchedule}},
+		}},
+	}
+	nodes := []Node{
+		{ID: "h100", Taints: []Taint{{Key: "gpu", Value: "h100", Effect: TaintNoSchedule}}},
+		{ID: "spot", Taints: []Taint{{Key: "spot", Effect: TaintPreferNoSchedule}}},
+		{ID: "plain"},
+	}
+
+	ranked, rejected := c.Rank("phi3", nodes)
+	require.Len(t, rejected, 1)
+	assert.Equal(t, "h100", rejected[0].NodeID)
+	assert.Equal(t, "untolerated taint gpu=h100:NoSchedule", rejected[0].Reason)
+	assert.Equal(t, []string{"plain", "spot"}, nodeIDs(ranked), "PreferNoSchedule nodes come last")
+
+	ranked, rejected = c.Rank("llama3:70b", nodes)
+	assert.Empty(t, rejected)
+	assert.Equal(t, []string{"h100", "plain", "spot"}, nodeIDs(ranked))
+
+	// A request may bring its own tolerations
+	ranked, _ = c.RankFor("phi3", []Toleration{{Key: "spot", Operator: TolerationOpExists}}, nodes)
+	assert.Equal(t, []string{"spot", "plain"}, nodeIDs(ranked))
+
+	// Taints apply without a policy
+	var none *Policy
+	assert.Error(t, none.Check("phi3", nodes[0]))
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Set("n1", NodeLabels{Labels: map[string]string{"gpu": "a100"}}))
+	assert.Error(t, r.Set("n1", NodeLabels{Taints: []Taint{{Key: "x", Effect: "Bogus"}}}))
+	assert.Error(t, r.Set("", NodeLabels{}))
+
+	labels, ok := r.Get("n1")
+	require.True(t, ok)
+	labels.Labels["gpu"] = "t4"
+	assert.Equal(t, "a100", r.Node("n1", nil).Labels["gpu"], "Get returns a copy")
+
+	changed := r.Merge(map[string]NodeLabels{
+		"n1": {Labels: map[string]string{"gpu": "a100"}},
+		"n2": {Taints: []Taint{{Key: "spot", Effect: TaintPreferNoSchedule}}},
+	})
+	assert.Equal(t, []string{"n2"}, changed)
+	assert.Len(t, r.All(), 2)
+}
+
+func nodeIDs(nodes []Node) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
+
+func TestPreferredFor(t *testing.T) {
+	c := Constraints{Affinity: []AffinityRule{{Model: "m", Labels: map[string]string{"gpu": "a100"}}}}
+	nodes := []Node{
+		{ID: "spot-a100", Labels: map[string]string{"gpu": "a100"}, Taints: []Taint{{Key: "spot", Effect: TaintPreferNoSchedule}}},
+		{ID: "a", Labels: map[string]string{"gpu": "a100"}},
+		{ID: "b"},
+		{ID: "c", Labels: map[string]string{"gpu": "a100"}},
+	}
+
+	preferred, _ := c.PreferredFor("m", nil, nodes)
+	assert.Equal(t, []string{"a", "c"}, nodeIDs(preferred))
+
+	preferred, _ = c.PreferredFor("other", nil, nodes)
+	assert.Equal(t, []string{"a", "b", "c"}, nodeIDs(preferred))
+
+	preferred, _ = c.PreferredFor("other", nil, nodes[:1])
+	assert.Equal(t, []string{"spot-a100"}, nodeIDs(preferred), "repelled nodes are used when nothing else is left")
+}
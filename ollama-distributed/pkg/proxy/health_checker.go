@@ -182,10 +182,14 @@ func (hc *InstanceHealthChecker) CheckInstance(instance *OllamaInstance) {
 
 // performHealthCheck performs the actual health check
 func (hc *InstanceHealthChecker) performHealthCheck(instance *OllamaInstance) error {
-	// Create HTTP client with timeout
+	// Reuse the instance's pooled connections; a nil transport falls back
+	// to the default one
 	client := &http.Client{
 		Timeout: hc.timeout,
 	}
+	if instance.transport != nil {
+		client.Transport = instance.transport
+	}
 
 	// Perform health check request
 	healthURL := instance.Endpoint + "/api/tags"
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+)
+
+// SetHedger hedges embeddings and cacheable generations across two
+// instances. The node does not route inference through an OllamaProxy, so
+// this is for programs that build one; the node hedges the embedding calls
+// of its vector index instead.
+func (p *OllamaProxy) SetHedger(hedger *hedging.Hedger) {
+	p.instancesMu.Lock()
+	p.hedger = hedger
+	p.instancesMu.Unlock()
+}
+
+// hedgeKind returns the kind a request is hedged as, or "" when it must
+// not be hedged. Embeddings are hedged, and so are generations that are
+// not streamed and deterministic, i.e. at temperature 0 or with a fixed
+// seed, whose answers are as cacheable as a lookup.
+func hedgeKind(path string, body []byte) string {
+	kind := inferenceRequestType(path)
+	switch kind {
+	case "embed":
+		return kind
+	case "generate", "chat":
+	default:
+		return ""
+	}
+
+	type sampling struct {
+		Temperature *float64 `json:"temperature"`
+		Seed        *int64   `json:"seed"`
+	}
+	var req struct {
+		Stream *bool `json:"stream"`
+		sampling
+		Options sampling `json:"options"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	// Ollama streams unless told not to, OpenAI-style APIs only when told to
+	streamed := req.Stream == nil || *req.Stream
+	if strings.HasPrefix(path, "/v1/") {
+		streamed = req.Stream != nil && *req.Stream
+	}
+	zero := func(t *float64) bool { return t != nil && *t == 0 }
+	deterministic := zero(req.Temperature) || zero(req.Options.Temperature) || req.Seed != nil || req.Options.Seed != nil
+	if streamed || !deterministic {
+		return ""
+	}
+	return kind
+}
+
+// hedgeInstances returns the instance selected for a request and another
+// healthy one, the least busy, to hedge it to
+func (p *OllamaProxy) hedgeInstances(r *http.Request) ([]*OllamaInstance, error) {
+	primary, err := p.selectInstance(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p.instancesMu.RLock()
+	defer p.instancesMu.RUnlock()
+	instances := []*OllamaInstance{primary}
+	var second *OllamaInstance
+	var secondActive int
+	for _, instance := range p.instances {
+		if instance == primary {
+			continue
+		}
+		instance.mu.RLock()
+		healthy, active := instance.Status == InstanceStatusHealthy, instance.Load.ActiveRequests
+		instance.mu.RUnlock()
+		if healthy && (second == nil || active < secondActive) {
+			second, secondActive = instance, active
+		}
+	}
+	if second != nil {
+		instances = append(instances, second)
+	}
+	return instances, nil
+}
+
+// bufferedResponse is the complete answer of an instance
+type bufferedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// routeHedged sends a request to its instance and, when slow, to a second
+// one, writing the first complete answer. Answers are buffered, which the
+// request kinds hedged keep small.
+func (p *OllamaProxy) routeHedged(w http.ResponseWriter, r *http.Request, body []byte, kind string) error {
+	instances, err := p.hedgeInstances(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hedging.Do(r.Context(), p.hedger, kind, len(instances), func(ctx context.Context, target int) (*bufferedResponse, error) {
+		return p.send(ctx, r, body, instances[target])
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, values := range resp.header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.status)
+	_, err = w.Write(resp.body)
+	return err
+}
+
+// send sends a request to an instance and reads its answer; server errors
+// count as failures so the other instance's answer is used
+func (p *OllamaProxy) send(ctx context.Context, r *http.Request, body []byte, instance *OllamaInstance) (*bufferedResponse, error) {
+	instance.mu.Lock()
+	instance.Load.ActiveRequests++
+	instance.RequestCount++
+	instance.mu.Unlock()
+	defer func() {
+		instance.mu.Lock()
+		instance.Load.ActiveRequests--
+		instance.mu.Unlock()
+	}()
+
+	url := strings.TrimSuffix(instance.Endpoint, "/") + r.URL.RequestURI()
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := instance.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	answer, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("instance %s returned %s", instance.ID, resp.Status)
+	}
+	return &bufferedResponse{status: resp.StatusCode, header: resp.Header, body: answer}, nil
+}
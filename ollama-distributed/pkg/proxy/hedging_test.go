@@ -0,0 +1,17 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgeKind(t *testing.T) {
+	assert.Equal(t, "embed", hedgeKind("/api/embed", []byte(`{"model":"m","input":"a"}`)))
+	assert.Equal(t, "generate", hedgeKind("/api/generate", []byte(`{"model":"m","stream":false,"options":{"temperature":0}}`)))
+	assert.Equal(t, "chat", hedgeKind("/v1/chat/completions", []byte(`{"model":"m","seed":7}`)))
+
+	assert.Empty(t, hedgeKind("/api/generate", []byte(`{"model":"m","options":{"temperature":0}}`)), "Ollama streams by default")
+	assert.Empty(t, hedgeKind("/api/chat", []byte(`{"model":"m","stream":false}`)), "sampled answers are not cacheable")
+	assert.Empty(t, hedgeKind("/api/pull", []byte(`{"model":"m"}`)))
+}
@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+)
+
+// maxRecordedLine bounds how much of a response line is buffered to read the
+// final token counts; longer lines are not inspected
+const maxRecordedLine = 1 << 20
+
+// SetMetricsIntegration enables per-model inference histograms for requests
+// routed through the proxy
+func (p *OllamaProxy) SetMetricsIntegration(metricsIntegration *observability.MetricsIntegration) {
+	p.metricsIntegration = metricsIntegration
+}
+
+// inferenceRequestType classifies inference endpoints of the Ollama and
+// OpenAI compatible APIs, returning "" for everything else
+func inferenceRequestType(path string) string {
+	switch path {
+	case "/api/generate", "/v1/completions":
+		return "generate"
+	case "/api/chat", "/v1/chat/completions":
+		return "chat"
+	case "/api/embed", "/api/embeddings", "/v1/embeddings":
+		return "embed"
+	default:
+		return ""
+	}
+}
+
+// peekModelName reads the model from a JSON request body into buf, leaving
+// the body in place for the upstream request. buf must outlive the request.
+func peekModelName(r *http.Request, buf *bytes.Buffer) string {
+	if r.Body == nil {
+		return ""
+	}
+	_, err := buf.ReadFrom(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(buf.Bytes(), &req) != nil {
+		return ""
+	}
+	return req.Model
+}
+
+// inferenceRecorder wraps the client response to time the first streamed
+// chunk and keep the last line, which carries Ollama's token counts
+type inferenceRecorder struct {
+	http.ResponseWriter
+
+	start      time.Time
+	status     int
+	firstWrite time.Time
+	pending    []byte
+	lastLine   []byte
+	overflow   bool
+}
+
+func newInferenceRecorder(w http.ResponseWriter, start time.Time) *inferenceRecorder {
+	return &inferenceRecorder{ResponseWriter: w, start: start}
+}
+
+func (ir *inferenceRecorder) WriteHeader(status int) {
+	if ir.status == 0 {
+		ir.status = status
+	}
+	ir.ResponseWriter.WriteHeader(status)
+}
+
+func (ir *inferenceRecorder) Write(p []byte) (int, error) {
+	if ir.firstWrite.IsZero() && len(p) > 0 {
+		ir.firstWrite = time.Now()
+	}
+	ir.record(p)
+	return ir.ResponseWriter.Write(p)
+}
+
+func (ir *inferenceRecorder) record(p []byte) {
+	for len(p) > 0 {
+		line, rest, complete := bytes.Cut(p, []byte("\n"))
+		if !ir.overflow {
+			if len(ir.pending)+len(line) > maxRecordedLine {
+				ir.pending, ir.overflow = ir.pending[:0], true
+			} else {
+				ir.pending = append(ir.pending, line...)
+			}
+		}
+		if !complete {
+			return
+		}
+		if !ir.overflow && len(bytes.TrimSpace(ir.pending)) > 0 {
+			ir.lastLine = append(ir.lastLine[:0], ir.pending...)
+		}
+		ir.pending, ir.overflow = ir.pending[:0], false
+		p = rest
+	}
+}
+
+// Flush supports streaming responses through the reverse proxy
+func (ir *inferenceRecorder) Flush() {
+	if flusher, ok := ir.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the client response to http.ResponseController
+func (ir *inferenceRecorder) Unwrap() http.ResponseWriter {
+	return ir.ResponseWriter
+}
+
+// observation summarises the response once it has been written
+func (ir *inferenceRecorder) observation(model, nodeID, requestType string) observability.InferenceObservation {
+	end := time.Now()
+	obs := observability.InferenceObservation{
+		ModelName:   model,
+		NodeID:      nodeID,
+		RequestType: requestType,
+		Duration:    end.Sub(ir.start),
+		Failed:      ir.status >= http.StatusInternalServerError,
+	}
+
+	// Only a streamed response tells when the first token was produced
+	if strings.HasPrefix(ir.Header().Get("Content-Type"), "application/x-ndjson") && !ir.firstWrite.IsZero() {
+		obs.TimeToFirstToken = ir.firstWrite.Sub(ir.start)
+	}
+
+	last := ir.lastLine
+	if !ir.overflow && len(bytes.TrimSpace(ir.pending)) > 0 {
+		last = ir.pending
+	}
+	var final struct {
+		EvalCount    int   `json:"eval_count"`
+		EvalDuration int64 `json:"eval_duration"` // nanoseconds
+	}
+	if json.Unmarshal(last, &final) == nil && final.EvalCount > 0 {
+		evalDuration := time.Duration(final.EvalDuration)
+		if evalDuration <= 0 {
+			evalDuration = end.Sub(ir.start) - obs.TimeToFirstToken
+		}
+		if evalDuration > 0 {
+			obs.TokensPerSecond = float64(final.EvalCount) / evalDuration.Seconds()
+		}
+	}
+	return obs
+}
+
+// routeInference routes an inference request to instance and records its
+// latency histograms
+func (p *OllamaProxy) routeInference(w http.ResponseWriter, r *http.Request, instance *OllamaInstance, requestType string) error {
+	body := memory.RequestBodies.Get()
+	defer memory.RequestBodies.Put(body)
+	model := peekModelName(r, body)
+
+	// Pick up the caller's trace so it can be attached as an exemplar
+	ctx := r.Context()
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	}
+
+	recorder := newInferenceRecorder(w, time.Now())
+	if err := p.routeRequest(recorder, r, instance); err != nil {
+		return err
+	}
+
+	p.metricsIntegration.GetModelIntegrator().ReportInference(ctx, recorder.observation(model, instance.NodeID, requestType))
+	return nil
+}
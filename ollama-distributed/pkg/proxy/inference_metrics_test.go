@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+)
+
+func TestInferenceRequestType(t *testing.T) {
+	assert.Equal(t, "generate", inferenceRequestType("/api/generate"))
+	assert.Equal(t, "chat", inferenceRequestType("/v1/chat/completions"))
+	assert.Equal(t, "embed", inferenceRequestType("/api/embeddings"))
+	assert.Empty(t, inferenceRequestType("/api/tags"))
+}
+
+func TestPeekModelNameRestoresBody(t *testing.T) {
+	body := `{"model":"llama3:8b","prompt":"hi"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/generate", strings.NewReader(body))
+
+	assert.Equal(t, "llama3:8b", peekModelName(req, new(bytes.Buffer)))
+	remaining, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(remaining))
+}
+
+func TestInferenceRecorderStreamed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/x-ndjson")
+	recorder := newInferenceRecorder(rec, time.Now().Add(-time.Second))
+
+	// Chunks do not necessarily end on line boundaries
+	_, _ = recorder.Write([]byte(`{"response":"Hel","done":false}` + "\n" + `{"response":"lo",`))
+	_, _ = recorder.Write([]byte(`"done":false}` + "\n"))
+	_, _ = recorder.Write([]byte(`{"done":true,"eval_count":50,"eval_duration":2000000000}` + "\n"))
+
+	obs := recorder.observation("llama3:8b", "node-b", "generate")
+	assert.Equal(t, "node-b", obs.NodeID)
+	assert.GreaterOrEqual(t, obs.Duration, time.Second)
+	assert.GreaterOrEqual(t, obs.TimeToFirstToken, time.Second)
+	assert.InDelta(t, 25.0, obs.TokensPerSecond, 0.001)
+	assert.Contains(t, rec.Body.String(), `"eval_count":50`, "response is passed through")
+}
+
+func TestInferenceRecorderNotStreamed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	recorder := newInferenceRecorder(rec, time.Now())
+
+	_, _ = recorder.Write([]byte(`{"embeddings":[[0.1,0.2]]}`))
+
+	obs := recorder.observation("nomic-embed-text", "node-a", "embed")
+	assert.Zero(t, obs.TimeToFirstToken)
+	assert.Zero(t, obs.TokensPerSecond)
+	assert.False(t, obs.Failed)
+}
+
+func TestInferenceRecorderFailed(t *testing.T) {
+	recorder := newInferenceRecorder(httptest.NewRecorder(), time.Now())
+	recorder.WriteHeader(http.StatusBadGateway)
+
+	assert.True(t, recorder.observation("llama3:8b", "node-a", "chat").Failed)
+}
+
+func TestReportInferenceExemplar(t *testing.T) {
+	registry := observability.NewMetricsRegistry(nil)
+	integration := observability.NewMetricsIntegration(registry, "node-a")
+
+	traceID := oteltrace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  oteltrace.SpanID{0, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}))
+
+	integration.GetModelIntegrator().ReportInference(ctx, observability.InferenceObservation{
+		ModelName:        "llama3:8b",
+		RequestType:      "chat",
+		Duration:         1500 * time.Millisecond,
+		TimeToFirstToken: 200 * time.Millisecond,
+		TokensPerSecond:  42,
+	})
+
+	families, err := registry.GetPrometheusExporter().GetRegistry().Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, family := range families {
+		if family.GetName() != "ollama_distributed_model_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(t, map[string]string{"model_name": "llama3:8b", "node_id": "node-a", "request_type": "chat"}, labels)
+
+			for _, bucket := range metric.GetHistogram().GetBucket() {
+				if exemplar := bucket.GetExemplar(); exemplar != nil {
+					assert.Equal(t, traceID.String(), exemplar.GetLabel()[0].GetValue())
+					assert.Equal(t, 1.5, exemplar.GetValue())
+					found = true
+				}
+			}
+		}
+	}
+	assert.True(t, found, "request duration carries a trace exemplar")
+}
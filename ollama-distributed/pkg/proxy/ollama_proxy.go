@@ -12,6 +12,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/loadbalancer"
 )
@@ -23,8 +26,10 @@ type OllamaProxy struct {
 	loadBalancer *loadbalancer.LoadBalancer
 
 	// Instance management
-	instances   map[string]*OllamaInstance
-	instancesMu sync.RWMutex
+	instances      map[string]*OllamaInstance
+	instancesMu    sync.RWMutex
+	localInstances LocalInstanceSource
+	hedger         *hedging.Hedger
 
 	// Request routing
 	router *RequestRouter
@@ -33,7 +38,8 @@ type OllamaProxy struct {
 	healthChecker *InstanceHealthChecker
 
 	// Metrics
-	metrics *ProxyMetrics
+	metrics            *ProxyMetrics
+	metricsIntegration *observability.MetricsIntegration
 
 	// Configuration
 	config *ProxyConfig
@@ -54,9 +60,10 @@ type OllamaInstance struct {
 	Load     *InstanceLoad
 	Health   *InstanceHealth
 
-	// HTTP client for this instance
-	client *http.Client
-	proxy  *httputil.ReverseProxy
+	// HTTP client for this instance; both share the pooled transport
+	transport *backendTransport
+	client    *http.Client
+	proxy     *httputil.ReverseProxy
 
 	// Metrics
 	RequestCount    int64
@@ -67,6 +74,12 @@ type OllamaInstance struct {
 	mu sync.RWMutex
 }
 
+// LocalInstanceSource reports the Ollama servers running on this node,
+// keyed by name, when there can be more than one, e.g. one per GPU
+type LocalInstanceSource interface {
+	LocalEndpoints() map[string]string
+}
+
 // InstanceStatus represents the status of an Ollama instance
 type InstanceStatus string
 
@@ -122,6 +135,15 @@ type ProxyConfig struct {
 	// Request routing
 	EnableRequestLogging bool
 	EnableMetrics        bool
+
+	// Backend connection pooling. HTTP/2 is negotiated with TLS backends
+	// when enabled; HTTP2Cleartext speaks h2c to plain http:// backends that
+	// are known to support it.
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 for no limit
+	IdleConnTimeout     time.Duration
+	EnableHTTP2         bool
+	HTTP2Cleartext      bool
 }
 
 // ProxyMetrics tracks proxy performance
@@ -148,6 +170,7 @@ type InstanceMetrics struct {
 	Errors         int64
 	AverageLatency time.Duration
 	LastRequest    time.Time
+	Connections    ConnPoolStats
 }
 
 // NewOllamaProxy creates a new Ollama proxy
@@ -193,6 +216,9 @@ func DefaultProxyConfig() *ProxyConfig {
 		ModelSyncInterval:       5 * time.Minute,
 		EnableRequestLogging:    true,
 		EnableMetrics:           true,
+		MaxIdleConnsPerHost:     64,
+		IdleConnTimeout:         90 * time.Second,
+		EnableHTTP2:             true,
 	}
 }
 
@@ -264,13 +290,8 @@ func (p *OllamaProxy) RegisterInstance(nodeID, endpoint string) error {
 		return fmt.Errorf("invalid endpoint URL: %w", err)
 	}
 
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: p.config.RequestTimeout,
-	}
-
-	// Create reverse proxy
-	proxy := httputil.NewSingleHostReverseProxy(endpointURL)
+	// Create pooled HTTP client and reverse proxy
+	transport, client, proxy := p.newInstanceHTTP(endpointURL)
 
 	instance := &OllamaInstance{
 		ID:       instanceID,
@@ -284,8 +305,9 @@ func (p *OllamaProxy) RegisterInstance(nodeID, endpoint string) error {
 		Health: &InstanceHealth{
 			LastHealthCheck: time.Now(),
 		},
-		client: client,
-		proxy:  proxy,
+		transport: transport,
+		client:    client,
+		proxy:     proxy,
 	}
 
 	p.instances[instanceID] = instance
@@ -432,17 +454,30 @@ func (p *OllamaProxy) discoverFromScheduler() error {
 			continue
 		}
 
-		// Create HTTP client
-		client := &http.Client{
-			Timeout: p.config.RequestTimeout,
+		// Keep the connection pool of an instance that is rediscovered
+		// at the same endpoint
+		instanceID := fmt.Sprintf("node-%s", node.ID)
+		p.instancesMu.RLock()
+		existing := p.instances[instanceID]
+		p.instancesMu.RUnlock()
+
+		var (
+			transport *backendTransport
+			client    *http.Client
+			proxy     *httputil.ReverseProxy
+		)
+		if existing != nil && existing.Endpoint == endpoint && existing.transport != nil {
+			transport, client, proxy = existing.transport, existing.client, existing.proxy
+		} else {
+			if existing != nil && existing.transport != nil {
+				existing.transport.CloseIdleConnections()
+			}
+			transport, client, proxy = p.newInstanceHTTP(endpointURL)
 		}
 
-		// Create reverse proxy
-		proxy := httputil.NewSingleHostReverseProxy(endpointURL)
-
 		// Create instance from node info
 		instance := &OllamaInstance{
-			ID:       fmt.Sprintf("node-%s", node.ID),
+			ID:       instanceID,
 			NodeID:   node.ID,
 			Endpoint: endpoint,
 			Status:   p.mapNodeStatusToInstanceStatus(string(node.Status)),
@@ -451,8 +486,9 @@ func (p *OllamaProxy) discoverFromScheduler() error {
 			Health:   &InstanceHealth{},
 
 			// HTTP components
-			client: client,
-			proxy:  proxy,
+			transport: transport,
+			client:    client,
+			proxy:     proxy,
 
 			// Initialize metrics
 			RequestCount:    0,
@@ -536,8 +572,38 @@ func (p *OllamaProxy) periodicDiscovery() {
 	}
 }
 
-// registerLocalInstance registers the local Ollama instance
+// SetLocalInstances makes discovery register every Ollama server reported
+// by source instead of only the default one on localhost:11434
+func (p *OllamaProxy) SetLocalInstances(source LocalInstanceSource) {
+	p.instancesMu.Lock()
+	p.localInstances = source
+	p.instancesMu.Unlock()
+
+	if err := p.registerLocalInstance(); err != nil {
+		log.Printf("Warning: Failed to register local instances: %v", err)
+	}
+}
+
+// registerLocalInstance registers the local Ollama instances
 func (p *OllamaProxy) registerLocalInstance() error {
+	p.instancesMu.RLock()
+	source := p.localInstances
+	p.instancesMu.RUnlock()
+
+	if source != nil {
+		// Each instance is registered individually so that requests are
+		// spread across them rather than bottlenecked on one runner
+		for name, endpoint := range source.LocalEndpoints() {
+			if p.hasInstance("local", endpoint) {
+				continue
+			}
+			if err := p.RegisterInstance("local", endpoint); err != nil {
+				return fmt.Errorf("failed to register local instance %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
 	// Check if local Ollama is running
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get("http://localhost:11434/api/tags")
@@ -546,10 +612,23 @@ func (p *OllamaProxy) registerLocalInstance() error {
 	}
 	resp.Body.Close()
 
+	if p.hasInstance("local", "http://localhost:11434") {
+		return nil
+	}
+
 	// Register local instance
 	return p.RegisterInstance("local", "http://localhost:11434")
 }
 
+// hasInstance reports whether the endpoint is already registered for the
+// node, so that rediscovery does not reset its health state
+func (p *OllamaProxy) hasInstance(nodeID, endpoint string) bool {
+	p.instancesMu.RLock()
+	defer p.instancesMu.RUnlock()
+	_, exists := p.instances[fmt.Sprintf("%s-%s", nodeID, endpoint)]
+	return exists
+}
+
 // modelSyncLoop synchronizes models across instances
 func (p *OllamaProxy) modelSyncLoop() {
 	defer p.wg.Done()
@@ -669,6 +748,9 @@ func (p *OllamaProxy) updateMetrics() {
 			metrics.AverageLatency = instance.AverageLatency
 			metrics.LastRequest = instance.LastRequestTime
 			instance.mu.RUnlock()
+			if instance.transport != nil {
+				metrics.Connections = instance.transport.Stats()
+			}
 		}
 	}
 	p.instancesMu.RUnlock()
@@ -721,6 +803,7 @@ func (p *OllamaProxy) GetMetrics() *ProxyMetrics {
 			Errors:         instanceMetrics.Errors,
 			AverageLatency: instanceMetrics.AverageLatency,
 			LastRequest:    instanceMetrics.LastRequest,
+			Connections:    instanceMetrics.Connections,
 		}
 	}
 
@@ -778,11 +861,15 @@ func (p *OllamaProxy) UnregisterInstance(instanceID string) error {
 	p.instancesMu.Lock()
 	defer p.instancesMu.Unlock()
 
-	if _, exists := p.instances[instanceID]; !exists {
+	instance, exists := p.instances[instanceID]
+	if !exists {
 		return fmt.Errorf("instance not found: %s", instanceID)
 	}
 
 	delete(p.instances, instanceID)
+	if instance.transport != nil {
+		instance.transport.CloseIdleConnections()
+	}
 
 	// Clean up metrics
 	p.metrics.mu.Lock()
@@ -802,6 +889,24 @@ func (p *OllamaProxy) ProxyRequest(w http.ResponseWriter, r *http.Request) error
 	p.metrics.TotalRequests++
 	p.metrics.mu.Unlock()
 
+	// Hedge embeddings and cacheable generations when enabled
+	p.instancesMu.RLock()
+	hedger := p.hedger
+	p.instancesMu.RUnlock()
+	if hedger != nil && inferenceRequestType(r.URL.Path) != "" {
+		body := memory.RequestBodies.Get()
+		defer memory.RequestBodies.Put(body)
+		peekModelName(r, body)
+		if kind := hedgeKind(r.URL.Path, body.Bytes()); kind != "" {
+			if err := p.routeHedged(w, r, body.Bytes(), kind); err != nil {
+				p.recordError()
+				return fmt.Errorf("failed to route hedged request: %w", err)
+			}
+			p.recordSuccess(time.Since(startTime))
+			return nil
+		}
+	}
+
 	// Select target instance
 	instance, err := p.selectInstance(r)
 	if err != nil {
@@ -810,7 +915,12 @@ func (p *OllamaProxy) ProxyRequest(w http.ResponseWriter, r *http.Request) error
 	}
 
 	// Route request to selected instance
-	if err := p.routeRequest(w, r, instance); err != nil {
+	if requestType := inferenceRequestType(r.URL.Path); requestType != "" && p.metricsIntegration != nil {
+		err = p.routeInference(w, r, instance, requestType)
+	} else {
+		err = p.routeRequest(w, r, instance)
+	}
+	if err != nil {
 		p.recordError()
 		return fmt.Errorf("failed to route request: %w", err)
 	}
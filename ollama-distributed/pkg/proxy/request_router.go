@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -317,9 +318,17 @@ func (r *RequestRouter) handleProxyMetrics(w http.ResponseWriter, req *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	// Simple JSON response
-	fmt.Fprintf(w, `{"total_requests":%d,"successful_requests":%d,"failed_requests":%d}`,
-		metrics.TotalRequests, metrics.SuccessfulRequests, metrics.FailedRequests)
+	backends := make(map[string]ConnPoolStats, len(metrics.InstanceMetrics))
+	for id, instance := range metrics.InstanceMetrics {
+		backends[id] = instance.Connections
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total_requests":      metrics.TotalRequests,
+		"successful_requests": metrics.SuccessfulRequests,
+		"failed_requests":     metrics.FailedRequests,
+		"backends":            backends,
+	})
 }
 
 // Helper methods
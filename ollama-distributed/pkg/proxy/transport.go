@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/memory"
+)
+
+// ConnPoolStats describes the connection pool to one Ollama backend. A low
+// share of reused connections under load means requests are paying for new
+// TCP (and TLS) handshakes.
+type ConnPoolStats struct {
+	OpenConnections int64  `json:"open_connections"`
+	Dials           int64  `json:"dials"`
+	DialErrors      int64  `json:"dial_errors"`
+	Requests        int64  `json:"requests"`
+	ReusedConns     int64  `json:"reused_conns"` // requests sent on an existing connection
+	IdleReused      int64  `json:"idle_reused"`  // of those, taken from the idle pool
+	HTTP2Requests   int64  `json:"http2_requests"`
+	Protocol        string `json:"protocol"` // of the most recent response
+}
+
+// backendTransport is the pooled transport shared by the reverse proxy,
+// client and health checks of one backend, counting how its connections are
+// used
+type backendTransport struct {
+	transport *http.Transport
+
+	open       atomic.Int64
+	dials      atomic.Int64
+	dialErrors atomic.Int64
+	requests   atomic.Int64
+	reused     atomic.Int64
+	idleReused atomic.Int64
+	http2      atomic.Int64
+	protocol   atomic.Value // string
+}
+
+// newBackendTransport creates a transport tuned for many concurrent,
+// long-lived requests to a single host
+func newBackendTransport(config *ProxyConfig) *backendTransport {
+	bt := &backendTransport{}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	bt.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				bt.dialErrors.Add(1)
+				return nil, err
+			}
+			bt.dials.Add(1)
+			bt.open.Add(1)
+			return &countedConn{Conn: conn, open: &bt.open}, nil
+		},
+		MaxIdleConns:          0, // bounded per host instead
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     config.EnableHTTP2,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+
+	if config.HTTP2Cleartext {
+		// Prior-knowledge h2c for http:// backends; HTTP/1 is left out as
+		// otherwise the transport keeps using it for plain text URLs
+		var protocols http.Protocols
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		bt.transport.Protocols = &protocols
+	} else if !config.EnableHTTP2 {
+		// A non-nil TLSNextProto map disables HTTP/2 negotiation
+		bt.transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return bt
+}
+
+// RoundTrip implements http.RoundTripper
+func (bt *backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bt.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				bt.reused.Add(1)
+			}
+			if info.WasIdle {
+				bt.idleReused.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	if req.Header.Get(logging.RequestIDHeader) == "" && logging.RequestIDFromContext(req.Context()) != "" {
+		// The request is the caller's; only the copy gets the header
+		req.Header = req.Header.Clone()
+		logging.PropagateRequestID(req)
+	}
+
+	resp, err := bt.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor == 2 {
+		bt.http2.Add(1)
+	}
+	bt.protocol.Store(resp.Proto)
+	return resp, nil
+}
+
+// Stats returns the connection pool statistics of this backend
+func (bt *backendTransport) Stats() ConnPoolStats {
+	protocol, _ := bt.protocol.Load().(string)
+	return ConnPoolStats{
+		OpenConnections: bt.open.Load(),
+		Dials:           bt.dials.Load(),
+		DialErrors:      bt.dialErrors.Load(),
+		Requests:        bt.requests.Load(),
+		ReusedConns:     bt.reused.Load(),
+		IdleReused:      bt.idleReused.Load(),
+		HTTP2Requests:   bt.http2.Load(),
+		Protocol:        protocol,
+	}
+}
+
+// CloseIdleConnections closes pooled connections that are not in use
+func (bt *backendTransport) CloseIdleConnections() {
+	bt.transport.CloseIdleConnections()
+}
+
+// countedConn decrements the open connection count once when closed
+type countedConn struct {
+	net.Conn
+	open   *atomic.Int64
+	closed sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closed.Do(func() { c.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// newInstanceHTTP creates the pooled transport, client and reverse proxy
+// used to reach one backend
+func (p *OllamaProxy) newInstanceHTTP(endpointURL *url.URL) (*backendTransport, *http.Client, *httputil.ReverseProxy) {
+	transport := newBackendTransport(p.config)
+
+	client := &http.Client{
+		Timeout:   p.config.RequestTimeout,
+		Transport: transport,
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(endpointURL)
+	proxy.Transport = transport
+	proxy.BufferPool = memory.CopyBuffers
+
+	return transport, client, proxy
+}
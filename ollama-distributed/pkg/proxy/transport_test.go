@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doBackendRequests(t *testing.T, client *http.Client, url string, n int) {
+	for i := 0; i < n; i++ {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func TestBackendTransportReusesConnections(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"models":[]}`))
+	}))
+	defer backend.Close()
+
+	bt := newBackendTransport(DefaultProxyConfig())
+	doBackendRequests(t, &http.Client{Transport: bt}, backend.URL+"/api/tags", 10)
+
+	stats := bt.Stats()
+	assert.Equal(t, int64(1), stats.Dials)
+	assert.Equal(t, int64(10), stats.Requests)
+	assert.Equal(t, int64(9), stats.ReusedConns)
+	assert.Equal(t, int64(1), stats.OpenConnections)
+	assert.Equal(t, "HTTP/1.1", stats.Protocol)
+
+	bt.CloseIdleConnections()
+	assert.Equal(t, int64(0), bt.Stats().OpenConnections)
+}
+
+func TestBackendTransportHTTP2(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	bt := newBackendTransport(DefaultProxyConfig())
+	bt.transport.TLSClientConfig.InsecureSkipVerify = true
+	doBackendRequests(t, &http.Client{Transport: bt}, backend.URL, 3)
+
+	stats := bt.Stats()
+	assert.Equal(t, "HTTP/2.0", stats.Protocol)
+	assert.Equal(t, int64(3), stats.HTTP2Requests)
+	assert.Equal(t, int64(1), stats.Dials)
+}
+
+func TestBackendTransportH2C(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetHTTP1(true)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	defer backend.Close()
+
+	config := DefaultProxyConfig()
+	config.HTTP2Cleartext = true
+	bt := newBackendTransport(config)
+	doBackendRequests(t, &http.Client{Transport: bt}, backend.URL, 2)
+
+	assert.Equal(t, "HTTP/2.0", bt.Stats().Protocol)
+}
+
+func TestBackendTransportHTTP2Disabled(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+
+	config := DefaultProxyConfig()
+	config.EnableHTTP2 = false
+	bt := newBackendTransport(config)
+	bt.transport.TLSClientConfig.InsecureSkipVerify = true
+	doBackendRequests(t, &http.Client{Transport: bt}, backend.URL, 1)
+
+	assert.Equal(t, "HTTP/1.1", bt.Stats().Protocol)
+}
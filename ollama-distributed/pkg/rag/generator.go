@@ -0,0 +1,95 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrNoEndpoint is returned when no Ollama instance serves the model
+var ErrNoEndpoint = errors.New("no instance serves the generation model")
+
+// ClusterGenerator generates answers on the Ollama instances of the cluster
+// that serve the model, spreading successive calls over them and failing
+// over to the next instance when one fails
+type ClusterGenerator struct {
+	HTTP *http.Client
+	// Endpoints returns the base URLs of the instances serving a model
+	Endpoints func(model string) []string
+
+	next atomic.Uint64
+}
+
+// NewClusterGenerator creates a generator; timeouts come from the request
+// context
+func NewClusterGenerator(endpoints func(model string) []string) *ClusterGenerator {
+	return &ClusterGenerator{HTTP: &http.Client{}, Endpoints: endpoints}
+}
+
+// Generate runs the prompt without streaming and returns the answer
+func (g *ClusterGenerator) Generate(ctx context.Context, model, system, prompt string, options map[string]interface{}) (string, error) {
+	endpoints := g.Endpoints(model)
+	if len(endpoints) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoEndpoint, model)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":   model,
+		"system":  system,
+		"prompt":  prompt,
+		"options": options,
+		"stream":  false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	start := int(g.next.Add(1) % uint64(len(endpoints)))
+	var errs []error
+	for i := range endpoints {
+		endpoint := endpoints[(start+i)%len(endpoints)]
+		answer, err := g.generate(ctx, endpoint, body)
+		if err == nil {
+			return answer, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// generate calls the generation API of one Ollama instance
+func (g *ClusterGenerator) generate(ctx context.Context, endpoint string, body []byte) (string, error) {
+	url := strings.TrimSuffix(endpoint, "/") + "/api/generate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return result.Response, nil
+}
@@ -0,0 +1,289 @@
+// Package rag answers questions from the documents of a vector index:
+// the question is embedded, the most similar documents are retrieved, a
+// prompt is built from them with a template and the answer is generated on
+// the cluster, citing the documents it drew on.
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+)
+
+// Defaults for an unset configuration
+const (
+	DefaultTopK            = 4
+	DefaultMaxContextChars = 12000
+)
+
+// DefaultTemplate builds the prompt when no template is given
+const DefaultTemplate = `Answer the question using only the numbered sources below. Cite the sources you use by their number in brackets, e.g. [1]. If the sources do not contain the answer, say so.
+
+{{range .Documents}}[{{.Number}}] {{.Text}}
+
+{{end}}Question: {{.Question}}
+Answer:`
+
+var (
+	// ErrNoDocuments is returned when retrieval finds no document to
+	// answer from
+	ErrNoDocuments = errors.New("no documents retrieved")
+	// ErrInvalidQuery is returned for a query that cannot be run
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+// citationPattern finds source numbers cited in an answer, e.g. [2]
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// Config configures retrieval-augmented generation
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Model generates answers when the query names no model
+	Model string `yaml:"model"`
+	// TopK is the number of documents retrieved by default
+	TopK int `yaml:"top_k" mapstructure:"top_k"`
+	// MinScore drops retrieved documents less similar to the question
+	MinScore float64 `yaml:"min_score" mapstructure:"min_score"`
+	// MaxContextChars caps the document text put in the prompt
+	MaxContextChars int `yaml:"max_context_chars" mapstructure:"max_context_chars"`
+	// Template is a text/template building the prompt from .Question and
+	// .Documents, each with .Number, .ID, .Text, .Score and .Metadata
+	Template string `yaml:"template"`
+	// System is the system prompt of the generation
+	System string `yaml:"system"`
+}
+
+// Validate checks the template and limits
+func (c Config) Validate() error {
+	if c.TopK < 0 || c.MaxContextChars < 0 {
+		return fmt.Errorf("negative top_k or max_context_chars")
+	}
+	if c.MinScore < -1 || c.MinScore > 1 {
+		return fmt.Errorf("min score %v outside [-1, 1]", c.MinScore)
+	}
+	if c.Template != "" {
+		if _, err := ParseTemplate(c.Template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTemplate parses a prompt template
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("rag").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Generator generates the answer to a prompt with a model
+type Generator interface {
+	Generate(ctx context.Context, model, system, prompt string, options map[string]interface{}) (string, error)
+}
+
+// Query is a question asked of a collection
+type Query struct {
+	Collection string
+	Question   string
+	// Model generates the answer, EmbeddingModel embeds the question
+	Model          string
+	EmbeddingModel string
+	K              int
+	MinScore       float64
+	Filter         map[string]string
+	// Template and System override the configured ones
+	Template string
+	System   string
+	Options  map[string]interface{}
+}
+
+// Document is a retrieved document as the prompt template sees it
+type Document struct {
+	Number   int
+	ID       string
+	Text     string
+	Score    float64
+	Metadata map[string]string
+}
+
+// Citation is a document the answer was generated from
+type Citation struct {
+	Number   int               `json:"number"`
+	ID       string            `json:"id"`
+	Score    float64           `json:"score"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Cited is set when the answer refers to the document by its number
+	Cited bool `json:"cited"`
+}
+
+// Answer is the generated answer with the documents it drew on
+type Answer struct {
+	Answer     string        `json:"answer"`
+	Model      string        `json:"model"`
+	Citations  []Citation    `json:"citations"`
+	Retrieval  time.Duration `json:"retrieval_duration"`
+	Generation time.Duration `json:"generation_duration"`
+}
+
+// Pipeline retrieves documents from an index and generates answers
+type Pipeline struct {
+	cfg       Config
+	template  *template.Template
+	index     vectorindex.Index
+	embedder  vectorindex.Embedder
+	generator Generator
+}
+
+// NewPipeline creates a pipeline, filling in defaults for unset values
+func NewPipeline(cfg Config, index vectorindex.Index, embedder vectorindex.Embedder, generator Generator) (*Pipeline, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.TopK == 0 {
+		cfg.TopK = DefaultTopK
+	}
+	if cfg.MaxContextChars == 0 {
+		cfg.MaxContextChars = DefaultMaxContextChars
+	}
+	if cfg.Template == "" {
+		cfg.Template = DefaultTemplate
+	}
+	tmpl, err := ParseTemplate(cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{cfg: cfg, template: tmpl, index: index, embedder: embedder, generator: generator}, nil
+}
+
+// DefaultModel returns the model generating answers for queries naming none
+func (p *Pipeline) DefaultModel() string {
+	return p.cfg.Model
+}
+
+// Query answers a question from the documents most similar to it
+func (p *Pipeline) Query(ctx context.Context, q Query) (*Answer, error) {
+	if strings.TrimSpace(q.Question) == "" {
+		return nil, fmt.Errorf("%w: question is empty", ErrInvalidQuery)
+	}
+	if q.Model == "" {
+		q.Model = p.cfg.Model
+	}
+	if q.Model == "" {
+		return nil, fmt.Errorf("%w: no model to generate the answer", ErrInvalidQuery)
+	}
+	if q.K <= 0 {
+		q.K = p.cfg.TopK
+	}
+	if q.MinScore == 0 {
+		q.MinScore = p.cfg.MinScore
+	}
+	if q.System == "" {
+		q.System = p.cfg.System
+	}
+	tmpl := p.template
+	if q.Template != "" {
+		var err error
+		if tmpl, err = ParseTemplate(q.Template); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+	}
+
+	start := time.Now()
+	embeddings, err := p.embedder.Embed(ctx, q.EmbeddingModel, []string{q.Question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed the question: %w", err)
+	}
+	matches, err := p.index.Query(ctx, q.Collection, embeddings[0], q.K, q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	docs := p.contextDocuments(matches, q.MinScore)
+	if len(docs) == 0 {
+		return nil, ErrNoDocuments
+	}
+	retrieval := time.Since(start)
+
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, struct {
+		Question  string
+		Documents []Document
+	}{q.Question, docs}); err != nil {
+		return nil, fmt.Errorf("%w: failed to build the prompt: %v", ErrInvalidQuery, err)
+	}
+
+	start = time.Now()
+	text, err := p.generator.Generate(ctx, q.Model, q.System, prompt.String(), q.Options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the answer: %w", err)
+	}
+
+	return &Answer{
+		Answer:     text,
+		Model:      q.Model,
+		Citations:  citations(docs, text),
+		Retrieval:  retrieval,
+		Generation: time.Since(start),
+	}, nil
+}
+
+// contextDocuments numbers the matches at least as similar as minScore
+// that fit in the context budget
+func (p *Pipeline) contextDocuments(matches []vectorindex.Match, minScore float64) []Document {
+	var docs []Document
+	budget := p.cfg.MaxContextChars
+	for _, m := range matches {
+		if m.Score < minScore || m.Text == "" {
+			continue
+		}
+		text := m.Text
+		if len(text) > budget {
+			if len(docs) > 0 {
+				break
+			}
+			// Always keep part of the best document
+			cut := budget
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut]
+		}
+		budget -= len(text)
+		docs = append(docs, Document{Number: len(docs) + 1, ID: m.ID, Text: text, Score: m.Score, Metadata: m.Metadata})
+	}
+	return docs
+}
+
+// citations lists the documents of the prompt, marking those the answer
+// cites by number
+func citations(docs []Document, answer string) []Citation {
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		if n, err := strconv.Atoi(match[1]); err == nil {
+			cited[n] = true
+		}
+	}
+	result := make([]Citation, len(docs))
+	for i, doc := range docs {
+		result[i] = Citation{
+			Number:   doc.Number,
+			ID:       doc.ID,
+			Score:    doc.Score,
+			Text:     doc.Text,
+			Metadata: doc.Metadata,
+			Cited:    cited[doc.Number],
+		}
+	}
+	return result
+}
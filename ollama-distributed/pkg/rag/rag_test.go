@@ -0,0 +1,129 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/vectorindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keywordEmbedder embeds a text by whether it mentions each keyword
+type keywordEmbedder struct{}
+
+var keywords = []string{"raft", "gpu", "cache"}
+
+func (keywordEmbedder) Embed(_ context.Context, _ string, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, len(keywords)+1)
+		for j, k := range keywords {
+			if strings.Contains(strings.ToLower(text), k) {
+				vec[j] = 1
+			}
+		}
+		vec[len(keywords)] = 0.1
+		embeddings[i] = vec
+	}
+	return embeddings, nil
+}
+
+// promptGenerator answers with the prompt, citing the first source
+type promptGenerator struct{ prompt, system, model string }
+
+func (g *promptGenerator) Generate(_ context.Context, model, system, prompt string, _ map[string]interface{}) (string, error) {
+	g.model, g.system, g.prompt = model, system, prompt
+	return "Leaders are elected by Raft [1].", nil
+}
+
+func newPipeline(t *testing.T, cfg Config) (*Pipeline, *promptGenerator) {
+	ctx := context.Background()
+	index, err := vectorindex.OpenHNSW(vectorindex.Config{})
+	require.NoError(t, err)
+
+	texts := map[string]string{
+		"consensus": "Raft elects a leader among the nodes.",
+		"hardware":  "Each GPU node reports its memory.",
+		"caching":   "The response cache keeps recent answers.",
+	}
+	var docs []vectorindex.Document
+	for id, text := range texts {
+		vec, _ := keywordEmbedder{}.Embed(ctx, "", []string{text})
+		docs = append(docs, vectorindex.Document{ID: id, Text: text, Vector: vec[0], Metadata: map[string]string{"source": id + ".md"}})
+	}
+	require.NoError(t, index.Upsert(ctx, "docs", docs))
+
+	generator := &promptGenerator{}
+	p, err := NewPipeline(cfg, index, keywordEmbedder{}, generator)
+	require.NoError(t, err)
+	return p, generator
+}
+
+func TestQuery(t *testing.T) {
+	p, generator := newPipeline(t, Config{Model: "llama3", System: "be brief", TopK: 2, MinScore: 0.5})
+
+	answer, err := p.Query(context.Background(), Query{Collection: "docs", Question: "How does raft pick a leader?"})
+	require.NoError(t, err)
+	assert.Equal(t, "llama3", answer.Model)
+	assert.Equal(t, "be brief", generator.system)
+	assert.Contains(t, generator.prompt, "[1] Raft elects a leader among the nodes.")
+	assert.Contains(t, generator.prompt, "Question: How does raft pick a leader?")
+
+	// The other documents are below the minimum score
+	require.Len(t, answer.Citations, 1)
+	assert.Equal(t, "consensus", answer.Citations[0].ID)
+	assert.True(t, answer.Citations[0].Cited)
+	assert.Equal(t, "consensus.md", answer.Citations[0].Metadata["source"])
+
+	answer, err = p.Query(context.Background(), Query{
+		Collection: "docs",
+		Question:   "gpu?",
+		Model:      "mistral",
+		MinScore:   -1,
+		K:          3,
+		Template:   "{{range .Documents}}{{.ID}};{{end}}",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "mistral", generator.model)
+	assert.True(t, strings.HasPrefix(generator.prompt, "hardware;"))
+	require.Len(t, answer.Citations, 3)
+	assert.True(t, answer.Citations[0].Cited)
+	assert.False(t, answer.Citations[1].Cited)
+
+	_, err = p.Query(context.Background(), Query{Collection: "docs", Question: "unrelated", MinScore: 0.99})
+	assert.ErrorIs(t, err, ErrNoDocuments)
+	_, err = p.Query(context.Background(), Query{Collection: "docs", Question: "x", Template: "{{.Broken"})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestContextBudget(t *testing.T) {
+	p, generator := newPipeline(t, Config{Model: "llama3", MaxContextChars: 10, Template: "{{range .Documents}}<{{.Text}}>{{end}}"})
+
+	answer, err := p.Query(context.Background(), Query{Collection: "docs", Question: "raft", MinScore: -1})
+	require.NoError(t, err)
+	require.Len(t, answer.Citations, 1)
+	assert.Equal(t, "<Raft elect>", generator.prompt)
+}
+
+func TestClusterGenerator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, false, req["stream"])
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"response": "answer to " + req["prompt"].(string)})
+	}))
+	defer server.Close()
+
+	generator := NewClusterGenerator(func(model string) []string { return []string{"http://127.0.0.1:1", server.URL} })
+	answer, err := generator.Generate(context.Background(), "llama3", "", "q", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "answer to q", answer)
+
+	_, err = NewClusterGenerator(func(string) []string { return nil }).Generate(context.Background(), "llama3", "", "q", nil)
+	assert.ErrorIs(t, err, ErrNoEndpoint)
+}
@@ -0,0 +1,126 @@
+package redaction
+
+import (
+	"math/big"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in entities
+const (
+	EntityEmail      = "email"
+	EntityPhone      = "phone"
+	EntityCreditCard = "credit_card"
+	EntitySSN        = "ssn"
+	EntityIPAddress  = "ip_address"
+	EntityIBAN       = "iban"
+	EntitySecret     = "secret"
+)
+
+// recognizer finds an entity: matches of re for which valid, when set,
+// holds
+type recognizer struct {
+	entity string
+	re     *regexp.Regexp
+	valid  func(string) bool
+}
+
+var builtins = map[string][]recognizer{
+	EntityEmail: {{
+		entity: EntityEmail,
+		re:     regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	}},
+	EntityPhone: {{
+		entity: EntityPhone,
+		// International numbers, numbers with an area code in parentheses
+		// and North American ones; bare digit groups are too ambiguous
+		re: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]?\d{2,4}){1,4}|\(\d{2,4}\)[ .-]?\d{3,4}[ .-]?\d{3,4}|\b\d{3}[.-]\d{3}[.-]\d{4})\b`),
+	}},
+	EntityCreditCard: {{
+		entity: EntityCreditCard,
+		re:     regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:  luhn,
+	}},
+	EntitySSN: {{
+		entity: EntitySSN,
+		re:     regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	}},
+	EntityIPAddress: {
+		{
+			entity: EntityIPAddress,
+			re:     regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+		},
+		{
+			entity: EntityIPAddress,
+			re:     regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`),
+			valid:  func(s string) bool { return net.ParseIP(s) != nil && strings.Count(s, ":") >= 2 },
+		},
+	},
+	EntityIBAN: {{
+		entity: EntityIBAN,
+		re:     regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		valid:  ibanChecksum,
+	}},
+	EntitySecret: {
+		{entity: EntitySecret, re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{entity: EntitySecret, re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{entity: EntitySecret, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`)},
+		{entity: EntitySecret, re: regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{20,}|\bgh[pousr]_[A-Za-z0-9]{36}\b|\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+		{entity: EntitySecret, re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`)},
+		{entity: EntitySecret, re: regexp.MustCompile(`(?i)\b(?:password|passwd|secret|api[_-]?key|access[_-]?token)\s*[:=]\s*\S+`)},
+	},
+}
+
+// Entities returns the names of the built-in entities
+func Entities() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card
+// numbers
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ibanChecksum reports whether s passes the mod 97 check of IBANs
+func ibanChecksum(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	var digits strings.Builder
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			digits.WriteString(big.NewInt(int64(c - 'A' + 10)).String())
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
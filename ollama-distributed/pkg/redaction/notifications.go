@@ -0,0 +1,49 @@
+package redaction
+
+import (
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+)
+
+// Notifications returns a sink redacting alerts by the default policy
+// before they are sent to sink, e.g. to webhooks. A nil engine returns sink
+// as is.
+func (e *Engine) Notifications(sink observability.SLOAlertSink) observability.SLOAlertSink {
+	if e == nil || sink == nil {
+		return sink
+	}
+	return &redactedSink{engine: e, sink: sink}
+}
+
+type redactedSink struct {
+	engine *Engine
+	sink   observability.SLOAlertSink
+}
+
+func (s *redactedSink) SendNotification(notification *observability.Notification) error {
+	redacted := *notification
+	redacted.Title = s.engine.Redact("", notification.Title)
+	redacted.Message = s.engine.Redact("", notification.Message)
+	redacted.Labels = s.redactMap(notification.Labels)
+	redacted.Annotations = s.redactMap(notification.Annotations)
+	if notification.Metadata != nil {
+		redacted.Metadata = make(map[string]interface{}, len(notification.Metadata))
+		for key, value := range notification.Metadata {
+			if text, ok := value.(string); ok {
+				value = s.engine.Redact("", text)
+			}
+			redacted.Metadata[key] = value
+		}
+	}
+	return s.sink.SendNotification(&redacted)
+}
+
+func (s *redactedSink) redactMap(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(values))
+	for key, value := range values {
+		redacted[key] = s.engine.Redact("", value)
+	}
+	return redacted
+}
@@ -0,0 +1,347 @@
+// Package redaction removes personal data and secrets from prompts and
+// completions before they reach logs, captures or webhooks. A policy names
+// the entities to redact, such as emails, phone numbers, card numbers and
+// credentials, recognized by patterns with checksums where the format has
+// one, plus custom regular expressions. Matches are replaced by a label,
+// a mask, or a keyed hash that lets redacted values be correlated without
+// being revealed. Tenants may have policies of their own.
+package redaction
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replacement modes
+const (
+	// ModeLabel replaces matches by their entity, e.g. [EMAIL]
+	ModeLabel = "label"
+	// ModeMask replaces matches by [REDACTED]
+	ModeMask = "mask"
+	// ModeHash replaces matches by their entity and a keyed hash of the
+	// value, e.g. [EMAIL:1f0e3dad], so that equal values stay correlated
+	ModeHash = "hash"
+)
+
+// maskedValue replaces matches in mask mode
+const maskedValue = "[REDACTED]"
+
+// Config configures redaction
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Default is the policy of requests without a tenant policy
+	Default Policy `yaml:"default"`
+	// Tenants are policies replacing the default one for a tenant
+	Tenants map[string]Policy `yaml:"tenants"`
+	// HashKey keys the hashes of hash mode; a random key, which changes on
+	// restart, is used when unset
+	HashKey string `yaml:"hash_key" mapstructure:"hash_key" json:"-"`
+}
+
+// Policy is what is redacted and how
+type Policy struct {
+	// Disabled redacts nothing
+	Disabled bool `yaml:"disabled" json:"disabled,omitempty"`
+	// Entities are the built-in entities redacted; every one when neither
+	// entities nor patterns are set
+	Entities []string  `yaml:"entities" json:"entities,omitempty"`
+	Patterns []Pattern `yaml:"patterns" json:"patterns,omitempty"`
+	// Mode is label (default), mask or hash
+	Mode string `yaml:"mode" json:"mode,omitempty"`
+}
+
+// Pattern is a custom regular expression whose matches are redacted as the
+// named entity
+type Pattern struct {
+	Name  string `yaml:"name" json:"name"`
+	Regex string `yaml:"regex" json:"regex"`
+}
+
+// Validate checks the policies
+func (c Config) Validate() error {
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+	for tenant, policy := range c.Tenants {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("policy of tenant %s: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the entities, patterns and mode
+func (p Policy) Validate() error {
+	_, err := compile(p)
+	return err
+}
+
+// withDefaults returns the policy with its unset values defaulted
+func (p Policy) withDefaults() Policy {
+	if len(p.Entities) == 0 && len(p.Patterns) == 0 {
+		p.Entities = Entities()
+	}
+	if p.Mode == "" {
+		p.Mode = ModeLabel
+	}
+	return p
+}
+
+// Match is a redacted span of a text
+type Match struct {
+	Entity string `json:"entity"`
+	// Start and End are byte offsets into the original text
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement"`
+}
+
+// Preview is the outcome of redacting a text
+type Preview struct {
+	Tenant   string  `json:"tenant,omitempty"`
+	Policy   Policy  `json:"policy"`
+	Redacted string  `json:"redacted"`
+	Matches  []Match `json:"matches"`
+}
+
+// Engine redacts texts by the policy of their tenant
+type Engine struct {
+	hashKey []byte
+
+	mu      sync.RWMutex
+	def     *compiled
+	tenants map[string]*compiled
+}
+
+// NewEngine creates an engine with the policies of cfg
+func NewEngine(cfg Config) (*Engine, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{hashKey: []byte(cfg.HashKey), tenants: make(map[string]*compiled)}
+	if len(e.hashKey) == 0 {
+		e.hashKey = make([]byte, 32)
+		if _, err := rand.Read(e.hashKey); err != nil {
+			return nil, err
+		}
+	}
+	e.def, _ = compile(cfg.Default)
+	for tenant, policy := range cfg.Tenants {
+		e.tenants[tenant], _ = compile(policy)
+	}
+	return e, nil
+}
+
+// Policies returns the default policy and the tenant policies, defaults
+// applied
+func (e *Engine) Policies() (Policy, map[string]Policy) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	tenants := make(map[string]Policy, len(e.tenants))
+	for tenant, c := range e.tenants {
+		tenants[tenant] = c.policy
+	}
+	return e.def.policy, tenants
+}
+
+// SetTenantPolicy replaces the policy of a tenant
+func (e *Engine) SetTenantPolicy(tenant string, policy Policy) error {
+	c, err := compile(policy)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tenants[tenant] = c
+	return nil
+}
+
+// DeleteTenantPolicy returns a tenant to the default policy, reporting
+// whether it had a policy of its own
+func (e *Engine) DeleteTenantPolicy(tenant string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.tenants[tenant]
+	delete(e.tenants, tenant)
+	return ok
+}
+
+// policy returns the compiled policy of a tenant
+func (e *Engine) policy(tenant string) *compiled {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if c, ok := e.tenants[tenant]; ok && tenant != "" {
+		return c
+	}
+	return e.def
+}
+
+// Redact returns text with the matches of the tenant's policy replaced
+func (e *Engine) Redact(tenant, text string) string {
+	redacted, _ := e.redact(e.policy(tenant), text)
+	return redacted
+}
+
+// Preview redacts text by the tenant's policy, reporting what matched
+func (e *Engine) Preview(tenant, text string) Preview {
+	c := e.policy(tenant)
+	redacted, matches := e.redact(c, text)
+	if matches == nil {
+		matches = []Match{}
+	}
+	return Preview{Tenant: tenant, Policy: c.policy, Redacted: redacted, Matches: matches}
+}
+
+// RedactBody redacts a request or response body: the string values of
+// JSON, line by line for newline-delimited JSON as streamed responses are,
+// and other bodies as text
+func (e *Engine) RedactBody(tenant string, body []byte) []byte {
+	c := e.policy(tenant)
+	if c.policy.Disabled || len(body) == 0 {
+		return body
+	}
+
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var value interface{}
+		if decoder.Decode(&value) != nil || decoder.More() {
+			redacted, _ := e.redact(c, string(line))
+			lines[i] = []byte(redacted)
+			continue
+		}
+		data, err := json.Marshal(e.redactValue(c, value))
+		if err != nil {
+			lines[i] = []byte(maskedValue)
+			continue
+		}
+		lines[i] = data
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func (e *Engine) redactValue(c *compiled, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			v[key] = e.redactValue(c, field)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = e.redactValue(c, v[i])
+		}
+		return v
+	case string:
+		redacted, _ := e.redact(c, v)
+		return redacted
+	default:
+		return v
+	}
+}
+
+// redact replaces the matches of a policy in text. Overlapping matches are
+// resolved in favor of the one starting first, then the longest.
+func (e *Engine) redact(c *compiled, text string) (string, []Match) {
+	if c.policy.Disabled || text == "" {
+		return text, nil
+	}
+
+	var matches []Match
+	for _, r := range c.recognizers {
+		for _, loc := range r.re.FindAllStringIndex(text, -1) {
+			if r.valid != nil && !r.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, Match{Entity: r.entity, Start: loc[0], End: loc[1]})
+		}
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	var b strings.Builder
+	kept := matches[:0]
+	last := 0
+	for _, m := range matches {
+		if m.Start < last {
+			continue
+		}
+		m.Replacement = e.replacement(c.policy.Mode, m.Entity, text[m.Start:m.End])
+		b.WriteString(text[last:m.Start])
+		b.WriteString(m.Replacement)
+		last = m.End
+		kept = append(kept, m)
+	}
+	b.WriteString(text[last:])
+	return b.String(), kept
+}
+
+func (e *Engine) replacement(mode, entity, value string) string {
+	label := strings.ToUpper(entity)
+	switch mode {
+	case ModeMask:
+		return maskedValue
+	case ModeHash:
+		mac := hmac.New(sha256.New, e.hashKey)
+		mac.Write([]byte(value))
+		return "[" + label + ":" + hex.EncodeToString(mac.Sum(nil))[:8] + "]"
+	default:
+		return "[" + label + "]"
+	}
+}
+
+// compiled is a policy with its recognizers
+type compiled struct {
+	policy      Policy
+	recognizers []recognizer
+}
+
+func compile(policy Policy) (*compiled, error) {
+	policy = policy.withDefaults()
+	switch policy.Mode {
+	case ModeLabel, ModeMask, ModeHash:
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q", policy.Mode)
+	}
+
+	c := &compiled{policy: policy}
+	for _, entity := range policy.Entities {
+		recognizers, ok := builtins[entity]
+		if !ok {
+			return nil, fmt.Errorf("unknown entity %q", entity)
+		}
+		c.recognizers = append(c.recognizers, recognizers...)
+	}
+	for _, p := range policy.Patterns {
+		if p.Name == "" {
+			return nil, fmt.Errorf("pattern %q has no name", p.Regex)
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", p.Name, err)
+		}
+		c.recognizers = append(c.recognizers, recognizer{entity: p.Name, re: re})
+	}
+	return c, nil
+}
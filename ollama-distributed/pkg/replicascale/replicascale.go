@@ -0,0 +1,470 @@
+// Package replicascale scales the replicas of each model with its usage.
+// The request rate and queue time of every model are tracked over a sliding
+// window; hot models get more replicas and cold ones fewer, within the
+// bounds of their policy. Scaling up takes a sustained request rate or
+// queue time above the target, scaling down a sustained rate well below
+// what the remaining replicas serve, and every change is followed by a
+// cooldown, so that replica counts do not flap.
+package replicascale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons for scaling
+const (
+	ReasonRequestRate = "request_rate"
+	ReasonQueueTime   = "queue_time"
+	ReasonIdle        = "idle"
+	ReasonBelowMin    = "below_min_replicas"
+	ReasonAboveMax    = "above_max_replicas"
+)
+
+// Defaults of the controller
+const (
+	DefaultInterval       = 30 * time.Second
+	DefaultWindow         = 5 * time.Minute
+	DefaultMinReplicas    = 1
+	DefaultMaxReplicas    = 3
+	DefaultTargetRate     = 1.0
+	DefaultMaxQueueTime   = 2 * time.Second
+	DefaultScaleDownRatio = 0.5
+	DefaultScaleUpAfter   = time.Minute
+	DefaultScaleDownAfter = 10 * time.Minute
+	DefaultCooldown       = 5 * time.Minute
+)
+
+// maxDecisions is how many decisions are kept for Decisions
+const maxDecisions = 100
+
+// Config configures replica autoscaling
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often replica counts are evaluated
+	Interval time.Duration `yaml:"interval"`
+	// Window is how far back request rates and queue times are averaged
+	Window time.Duration `yaml:"window"`
+
+	// MinReplicas and MaxReplicas bound the replicas of every placed model;
+	// Models overrides them per model
+	MinReplicas int               `yaml:"min_replicas" mapstructure:"min_replicas"`
+	MaxReplicas int               `yaml:"max_replicas" mapstructure:"max_replicas"`
+	Models      map[string]Bounds `yaml:"models"`
+
+	// TargetRate is the requests per second one replica serves; models
+	// above it per replica are hot
+	TargetRate float64 `yaml:"target_rate" mapstructure:"target_rate"`
+	// MaxQueueTime is the average queue time above which a model is hot
+	// whatever its request rate
+	MaxQueueTime time.Duration `yaml:"max_queue_time" mapstructure:"max_queue_time"`
+	// ScaleDownRatio is the fraction of the target rate the remaining
+	// replicas must stay under for a model to lose one; the gap between
+	// it and the target keeps counts from flapping
+	ScaleDownRatio float64 `yaml:"scale_down_ratio" mapstructure:"scale_down_ratio"`
+
+	// ScaleUpAfter and ScaleDownAfter are how long a model must stay hot
+	// or cold before its replicas change, and Cooldown how long a change
+	// is left to settle before the next one
+	ScaleUpAfter   time.Duration `yaml:"scale_up_after" mapstructure:"scale_up_after"`
+	ScaleDownAfter time.Duration `yaml:"scale_down_after" mapstructure:"scale_down_after"`
+	Cooldown       time.Duration `yaml:"cooldown"`
+}
+
+// Bounds are the replica counts allowed for a model; zero takes the
+// default bound
+type Bounds struct {
+	Min int `yaml:"min" json:"min"`
+	Max int `yaml:"max" json:"max"`
+}
+
+// Validate checks the bounds, target and durations
+func (c Config) Validate() error {
+	if c.Interval < 0 || c.Window < 0 || c.MaxQueueTime < 0 || c.ScaleUpAfter < 0 || c.ScaleDownAfter < 0 || c.Cooldown < 0 {
+		return fmt.Errorf("negative interval, window, queue time or delay")
+	}
+	if c.TargetRate < 0 {
+		return fmt.Errorf("negative target rate")
+	}
+	if c.ScaleDownRatio < 0 || c.ScaleDownRatio >= 1 {
+		return fmt.Errorf("scale down ratio must be in [0, 1)")
+	}
+
+	d := c.withDefaults()
+	if d.MinReplicas < 1 || d.MaxReplicas < d.MinReplicas {
+		return fmt.Errorf("replicas need 1 <= min <= max, got %d and %d", d.MinReplicas, d.MaxReplicas)
+	}
+	for model, bounds := range c.Models {
+		if bounds.Min < 0 || bounds.Max < 0 {
+			return fmt.Errorf("negative replica bounds for %s", model)
+		}
+		if lo, hi := d.bounds(model); hi < lo {
+			return fmt.Errorf("replicas of %s need min <= max, got %d and %d", model, lo, hi)
+		}
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset fields defaulted
+func (c Config) withDefaults() Config {
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	if c.MinReplicas == 0 {
+		c.MinReplicas = DefaultMinReplicas
+	}
+	if c.MaxReplicas == 0 {
+		c.MaxReplicas = max(DefaultMaxReplicas, c.MinReplicas)
+	}
+	if c.TargetRate == 0 {
+		c.TargetRate = DefaultTargetRate
+	}
+	if c.MaxQueueTime == 0 {
+		c.MaxQueueTime = DefaultMaxQueueTime
+	}
+	if c.ScaleDownRatio == 0 {
+		c.ScaleDownRatio = DefaultScaleDownRatio
+	}
+	if c.ScaleUpAfter == 0 {
+		c.ScaleUpAfter = DefaultScaleUpAfter
+	}
+	if c.ScaleDownAfter == 0 {
+		c.ScaleDownAfter = DefaultScaleDownAfter
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = DefaultCooldown
+	}
+	return c
+}
+
+// bounds returns the replica bounds of a model
+func (c Config) bounds(model string) (int, int) {
+	lo, hi := c.MinReplicas, c.MaxReplicas
+	if b, ok := c.Models[model]; ok {
+		if b.Min > 0 {
+			lo = b.Min
+		}
+		if b.Max > 0 {
+			hi = b.Max
+		}
+	}
+	return lo, hi
+}
+
+// Cluster is where the replicas of models are placed
+type Cluster interface {
+	// Replicas returns the number of replicas of each placed model
+	Replicas() map[string]int
+	// Scale adds or removes replicas of a model until it has the given
+	// number, returning an error if it could not
+	Scale(ctx context.Context, model string, replicas int) error
+}
+
+// Decision is a change of the replicas of a model
+type Decision struct {
+	Time        time.Time     `json:"time"`
+	Model       string        `json:"model"`
+	From        int           `json:"from"`
+	To          int           `json:"to"`
+	Reason      string        `json:"reason"`
+	RequestRate float64       `json:"request_rate"`
+	QueueTime   time.Duration `json:"queue_time"`
+	// Error is why the change could not be made in full
+	Error string `json:"error,omitempty"`
+}
+
+// Status is the usage and replicas of a model
+type Status struct {
+	Model       string        `json:"model"`
+	Replicas    int           `json:"replicas"`
+	Min         int           `json:"min_replicas"`
+	Max         int           `json:"max_replicas"`
+	RequestRate float64       `json:"request_rate"`
+	QueueTime   time.Duration `json:"queue_time"`
+	HotSince    time.Time     `json:"hot_since,omitempty"`
+	ColdSince   time.Time     `json:"cold_since,omitempty"`
+	LastScaled  time.Time     `json:"last_scaled,omitempty"`
+}
+
+// bucket counts the requests of one second
+type bucket struct {
+	second    int64
+	requests  int64
+	queueTime time.Duration
+}
+
+// modelState is what the controller tracks for a model
+type modelState struct {
+	buckets    []bucket
+	hotSince   time.Time
+	coldSince  time.Time
+	lastScaled time.Time
+}
+
+// Controller scales the replicas of models with their usage
+type Controller struct {
+	cfg     Config
+	cluster Cluster
+	now     func() time.Time
+
+	mu          sync.Mutex
+	models      map[string]*modelState
+	decisions   []Decision
+	listener    func(Decision)
+	leaderCheck func() bool
+}
+
+// NewController creates a controller scaling the replicas of models in
+// cluster
+func NewController(cfg Config, cluster Cluster) *Controller {
+	return &Controller{
+		cfg:     cfg.withDefaults(),
+		cluster: cluster,
+		now:     time.Now,
+		models:  make(map[string]*modelState),
+	}
+}
+
+// Config returns the config with its defaults applied
+func (c *Controller) Config() Config {
+	return c.cfg
+}
+
+// SetListener sets a function told every decision, e.g. to record it as an
+// event
+func (c *Controller) SetListener(listener func(Decision)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listener = listener
+}
+
+// SetLeaderCheck restricts scaling to the cluster leader so that only one
+// node changes replica counts. Usage is tracked either way.
+func (c *Controller) SetLeaderCheck(isLeader func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leaderCheck = isLeader
+}
+
+// Observe records a request for a model that waited queueTime in the queue
+func (c *Controller) Observe(model string, queueTime time.Duration) {
+	second := c.now().Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.state(model)
+	if n := len(state.buckets); n > 0 && state.buckets[n-1].second == second {
+		state.buckets[n-1].requests++
+		state.buckets[n-1].queueTime += queueTime
+		return
+	}
+	state.buckets = append(state.buckets, bucket{second: second, requests: 1, queueTime: queueTime})
+}
+
+// state returns the state of a model. The caller holds mu.
+func (c *Controller) state(model string) *modelState {
+	state, ok := c.models[model]
+	if !ok {
+		state = &modelState{}
+		c.models[model] = state
+	}
+	return state
+}
+
+// usage returns the request rate and average queue time of a model over
+// the window, dropping older requests. The caller holds mu.
+func (c *Controller) usage(state *modelState, now time.Time) (float64, time.Duration) {
+	since := now.Add(-c.cfg.Window).Unix()
+	i := sort.Search(len(state.buckets), func(i int) bool { return state.buckets[i].second > since })
+	state.buckets = state.buckets[i:]
+
+	var requests int64
+	var queueTime time.Duration
+	for _, b := range state.buckets {
+		requests += b.requests
+		queueTime += b.queueTime
+	}
+	if requests == 0 {
+		return 0, 0
+	}
+	return float64(requests) / c.cfg.Window.Seconds(), queueTime / time.Duration(requests)
+}
+
+// Run evaluates the replica counts every interval until ctx is done
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate scales the models whose usage calls for it and returns the
+// decisions made
+func (c *Controller) Evaluate(ctx context.Context) []Decision {
+	c.mu.Lock()
+	leaderCheck := c.leaderCheck
+	c.mu.Unlock()
+	if leaderCheck != nil && !leaderCheck() {
+		return nil
+	}
+
+	replicas := c.cluster.Replicas()
+	planned := c.plan(replicas)
+
+	decisions := make([]Decision, 0, len(planned))
+	for _, d := range planned {
+		if err := c.cluster.Scale(ctx, d.Model, d.To); err != nil {
+			d.Error = err.Error()
+			slog.Warn("Failed to scale model replicas", "model", d.Model, "from", d.From, "to", d.To, "error", err)
+		} else {
+			slog.Info("Scaled model replicas", "model", d.Model, "from", d.From, "to", d.To, "reason", d.Reason)
+		}
+		decisions = append(decisions, d)
+	}
+
+	c.mu.Lock()
+	c.decisions = append(c.decisions, decisions...)
+	if n := len(c.decisions); n > maxDecisions {
+		c.decisions = append([]Decision(nil), c.decisions[n-maxDecisions:]...)
+	}
+	listener := c.listener
+	c.mu.Unlock()
+
+	if listener != nil {
+		for _, d := range decisions {
+			listener(d)
+		}
+	}
+	return decisions
+}
+
+// plan decides the new replica counts of the placed models. Failed
+// changes wait out the cooldown like the others rather than being retried
+// on every evaluation.
+func (c *Controller) plan(replicas map[string]int) []Decision {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for model := range c.models {
+		if _, placed := replicas[model]; !placed {
+			delete(c.models, model)
+		}
+	}
+
+	models := make([]string, 0, len(replicas))
+	for model := range replicas {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	var decisions []Decision
+	for _, model := range models {
+		current := replicas[model]
+		if current <= 0 {
+			continue
+		}
+		state := c.state(model)
+		rate, queueTime := c.usage(state, now)
+		to, reason := c.desired(model, state, current, rate, queueTime, now)
+		if to == current {
+			continue
+		}
+
+		state.lastScaled = now
+		state.hotSince, state.coldSince = time.Time{}, time.Time{}
+		decisions = append(decisions, Decision{
+			Time:        now,
+			Model:       model,
+			From:        current,
+			To:          to,
+			Reason:      reason,
+			RequestRate: rate,
+			QueueTime:   queueTime,
+		})
+	}
+	return decisions
+}
+
+// desired returns the replicas a model should have and why, tracking how
+// long it has been hot or cold. The caller holds mu.
+func (c *Controller) desired(model string, state *modelState, current int, rate float64, queueTime time.Duration, now time.Time) (int, string) {
+	lo, hi := c.cfg.bounds(model)
+	needed := int(math.Ceil(rate / c.cfg.TargetRate))
+	hot := needed > current || queueTime > c.cfg.MaxQueueTime
+	cold := current > lo && queueTime <= c.cfg.MaxQueueTime &&
+		rate < float64(current-1)*c.cfg.TargetRate*c.cfg.ScaleDownRatio
+
+	if !hot {
+		state.hotSince = time.Time{}
+	} else if state.hotSince.IsZero() {
+		state.hotSince = now
+	}
+	if !cold {
+		state.coldSince = time.Time{}
+	} else if state.coldSince.IsZero() {
+		state.coldSince = now
+	}
+
+	if now.Sub(state.lastScaled) < c.cfg.Cooldown {
+		return current, ""
+	}
+	switch {
+	case current < lo:
+		return lo, ReasonBelowMin
+	case current > hi:
+		return hi, ReasonAboveMax
+	case hot && current < hi && now.Sub(state.hotSince) >= c.cfg.ScaleUpAfter:
+		if needed > current {
+			return min(needed, hi), ReasonRequestRate
+		}
+		return current + 1, ReasonQueueTime
+	case cold && now.Sub(state.coldSince) >= c.cfg.ScaleDownAfter:
+		return current - 1, ReasonIdle
+	}
+	return current, ""
+}
+
+// Decisions returns the latest decisions, oldest first
+func (c *Controller) Decisions() []Decision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Decision(nil), c.decisions...)
+}
+
+// Status returns the usage and replicas of the placed models
+func (c *Controller) Status() []Status {
+	replicas := c.cluster.Replicas()
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]Status, 0, len(replicas))
+	for model, count := range replicas {
+		lo, hi := c.cfg.bounds(model)
+		status := Status{Model: model, Replicas: count, Min: lo, Max: hi}
+		if state, ok := c.models[model]; ok {
+			status.RequestRate, status.QueueTime = c.usage(state, now)
+			status.HotSince, status.ColdSince, status.LastScaled = state.hotSince, state.coldSince, state.lastScaled
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Model < statuses[j].Model })
+	return statuses
+}
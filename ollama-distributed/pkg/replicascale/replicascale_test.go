@@ -0,0 +1,166 @@
+package replicascale
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCluster struct {
+	replicas map[string]int
+	fail     error
+}
+
+func (f *fakeCluster) Replicas() map[string]int {
+	replicas := make(map[string]int, len(f.replicas))
+	for model, n := range f.replicas {
+		replicas[model] = n
+	}
+	return replicas
+}
+
+func (f *fakeCluster) Scale(ctx context.Context, model string, replicas int) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.replicas[model] = replicas
+	return nil
+}
+
+// clock is a settable time source
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestController(cfg Config, cluster Cluster) (*Controller, *clock) {
+	c := NewController(cfg, cluster)
+	clk := &clock{t: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	c.now = clk.now
+	return c, clk
+}
+
+// load observes rate requests per second, each queued queueTime, for d
+func load(c *Controller, clk *clock, model string, rate int, queueTime, d time.Duration) {
+	for end := clk.t.Add(d); clk.t.Before(end); clk.t = clk.t.Add(time.Second) {
+		for i := 0; i < rate; i++ {
+			c.Observe(model, queueTime)
+		}
+	}
+}
+
+func TestScaleUpHotModel(t *testing.T) {
+	cluster := &fakeCluster{replicas: map[string]int{"llama3": 1, "phi3": 1}}
+	c, clk := newTestController(Config{Window: time.Minute, MaxReplicas: 4, TargetRate: 2}, cluster)
+
+	var heard []Decision
+	c.SetListener(func(d Decision) { heard = append(heard, d) })
+
+	load(c, clk, "llama3", 5, 0, time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()), "hot, but not for long enough yet")
+
+	load(c, clk, "llama3", 5, 0, time.Minute)
+	decisions := c.Evaluate(context.Background())
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "llama3", decisions[0].Model)
+	assert.Equal(t, 1, decisions[0].From)
+	assert.Equal(t, 3, decisions[0].To, "5 requests/s at 2 per replica")
+	assert.Equal(t, ReasonRequestRate, decisions[0].Reason)
+	assert.InDelta(t, 5, decisions[0].RequestRate, 0.1)
+	assert.Equal(t, 3, cluster.replicas["llama3"])
+	assert.Equal(t, 1, cluster.replicas["phi3"])
+	assert.Equal(t, decisions, heard)
+	assert.Equal(t, decisions, c.Decisions())
+
+	load(c, clk, "llama3", 20, 0, 2*time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()), "cooling down")
+	load(c, clk, "llama3", 20, 0, 4*time.Minute)
+	decisions = c.Evaluate(context.Background())
+	require.Len(t, decisions, 1)
+	assert.Equal(t, 4, decisions[0].To, "capped by the max")
+}
+
+func TestScaleUpOnQueueTime(t *testing.T) {
+	cluster := &fakeCluster{replicas: map[string]int{"llama3": 2}}
+	c, clk := newTestController(Config{Window: time.Minute, ScaleUpAfter: time.Second}, cluster)
+
+	load(c, clk, "llama3", 1, 5*time.Second, time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()))
+	load(c, clk, "llama3", 1, 5*time.Second, time.Second)
+	decisions := c.Evaluate(context.Background())
+	require.Len(t, decisions, 1)
+	assert.Equal(t, 3, decisions[0].To)
+	assert.Equal(t, ReasonQueueTime, decisions[0].Reason)
+	assert.Equal(t, 5*time.Second, decisions[0].QueueTime)
+}
+
+func TestScaleDownWithHysteresis(t *testing.T) {
+	cluster := &fakeCluster{replicas: map[string]int{"llama3": 3}}
+	c, clk := newTestController(Config{
+		Window:         time.Minute,
+		TargetRate:     1,
+		ScaleDownAfter: 2 * time.Minute,
+		Cooldown:       time.Minute,
+		Models:         map[string]Bounds{"llama3": {Min: 2}},
+	}, cluster)
+
+	// 1.5 requests/s is below the 3 replicas serve but above half of what
+	// 2 would, so nothing changes
+	for i := 0; i < 5; i++ {
+		load(c, clk, "llama3", 3, 0, 30*time.Second)
+		load(c, clk, "llama3", 0, 0, 30*time.Second)
+		assert.Empty(t, c.Evaluate(context.Background()))
+	}
+
+	load(c, clk, "llama3", 0, 0, time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()), "cold, but not for long enough yet")
+	load(c, clk, "llama3", 0, 0, 2*time.Minute)
+	decisions := c.Evaluate(context.Background())
+	require.Len(t, decisions, 1)
+	assert.Equal(t, 2, decisions[0].To)
+	assert.Equal(t, ReasonIdle, decisions[0].Reason)
+
+	load(c, clk, "llama3", 0, 0, 10*time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()), "at the model's min")
+}
+
+func TestBoundsEnforcedAndFailures(t *testing.T) {
+	cluster := &fakeCluster{replicas: map[string]int{"llama3": 6, "phi3": 1, "unplaced": 0}, fail: errors.New("no nodes left")}
+	c, clk := newTestController(Config{MaxReplicas: 4, Models: map[string]Bounds{"phi3": {Min: 2}}}, cluster)
+
+	decisions := c.Evaluate(context.Background())
+	require.Len(t, decisions, 2)
+	assert.Equal(t, Decision{Time: decisions[0].Time, Model: "llama3", From: 6, To: 4, Reason: ReasonAboveMax, Error: "no nodes left"}, decisions[0])
+	assert.Equal(t, "phi3", decisions[1].Model)
+	assert.Equal(t, ReasonBelowMin, decisions[1].Reason)
+
+	statuses := c.Status()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, Status{Model: "phi3", Replicas: 1, Min: 2, Max: 4, LastScaled: decisions[1].Time}, statuses[1])
+
+	clk.t = clk.t.Add(time.Minute)
+	assert.Empty(t, c.Evaluate(context.Background()), "failed changes are retried after the cooldown")
+	clk.t = clk.t.Add(DefaultCooldown)
+	assert.Len(t, c.Evaluate(context.Background()), 2)
+}
+
+func TestLeaderCheck(t *testing.T) {
+	cluster := &fakeCluster{replicas: map[string]int{"llama3": 5}}
+	c, _ := newTestController(Config{}, cluster)
+	c.SetLeaderCheck(func() bool { return false })
+	assert.Empty(t, c.Evaluate(context.Background()))
+	assert.Equal(t, 5, cluster.replicas["llama3"])
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{MinReplicas: 5}.Validate(), "the default max follows the min")
+	assert.Error(t, Config{MinReplicas: 3, MaxReplicas: 2}.Validate())
+	assert.Error(t, Config{Models: map[string]Bounds{"llama3": {Min: 4}}}.Validate())
+	assert.Error(t, Config{ScaleDownRatio: 1}.Validate())
+	assert.Error(t, Config{TargetRate: -1}.Validate())
+	assert.Error(t, Config{Cooldown: -time.Second}.Validate())
+}
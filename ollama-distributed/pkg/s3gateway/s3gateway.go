@@ -0,0 +1,517 @@
+// Package s3gateway serves the model blob store of a node through a
+// read-only subset of the S3 API, so that model scanners, backup systems
+// and CI can fetch model artifacts with existing S3 tooling. Requests are
+// authenticated with Signature Version 4, in the Authorization header or
+// as presigned URLs.
+package s3gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the gateway
+const (
+	DefaultListen  = "0.0.0.0:9300"
+	DefaultBucket  = "models"
+	DefaultRegion  = "us-east-1"
+	defaultMaxKeys = 1000
+)
+
+// xmlns is the namespace of S3 responses
+const xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Config configures the S3 gateway
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+	// Bucket is the name the blob store is served as
+	Bucket string `yaml:"bucket"`
+	// Root is the directory served; the model directory when unset
+	Root string `yaml:"root"`
+	// Region is the region clients sign requests for
+	Region      string       `yaml:"region"`
+	Credentials []Credential `yaml:"credentials"`
+	// Anonymous serves unsigned requests too
+	Anonymous bool `yaml:"anonymous"`
+	TLS       TLS  `yaml:"tls"`
+}
+
+// Credential is an access key clients sign requests with
+type Credential struct {
+	AccessKey string `yaml:"access_key" mapstructure:"access_key"`
+	SecretKey string `yaml:"secret_key" mapstructure:"secret_key"`
+}
+
+// TLS configures the server certificate
+type TLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file" mapstructure:"cert_file"`
+	KeyFile  string `yaml:"key_file" mapstructure:"key_file"`
+}
+
+// Validate checks the address, bucket name and credentials
+func (c Config) Validate() error {
+	if c.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", c.Listen, err)
+		}
+	}
+	if c.Bucket != "" && !validBucket(c.Bucket) {
+		return fmt.Errorf("invalid bucket name %q", c.Bucket)
+	}
+	if len(c.Credentials) == 0 && !c.Anonymous {
+		return fmt.Errorf("no credentials configured and anonymous access disabled")
+	}
+	seen := make(map[string]bool)
+	for _, cred := range c.Credentials {
+		if cred.AccessKey == "" || cred.SecretKey == "" {
+			return fmt.Errorf("credentials need an access_key and a secret_key")
+		}
+		if seen[cred.AccessKey] {
+			return fmt.Errorf("duplicate access key %s", cred.AccessKey)
+		}
+		seen[cred.AccessKey] = true
+	}
+	if c.TLS.Enabled && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS enabled but cert_file or key_file not specified")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.Listen == "" {
+		c.Listen = DefaultListen
+	}
+	if c.Bucket == "" {
+		c.Bucket = DefaultBucket
+	}
+	if c.Region == "" {
+		c.Region = DefaultRegion
+	}
+	return c
+}
+
+// validBucket reports whether name follows the S3 bucket naming rules
+func validBucket(name string) bool {
+	if len(name) < 3 || len(name) > 63 || name[0] == '-' || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Gateway serves a directory as a read-only S3 bucket
+type Gateway struct {
+	cfg     Config
+	root    *os.Root
+	secrets map[string]string
+	now     func() time.Time
+
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewGateway creates a gateway serving the root directory of cfg
+func NewGateway(cfg Config) (*Gateway, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	if cfg.Root == "" {
+		return nil, fmt.Errorf("no root directory to serve")
+	}
+	root, err := os.OpenRoot(cfg.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", cfg.Root, err)
+	}
+
+	g := &Gateway{cfg: cfg, root: root, secrets: make(map[string]string), now: time.Now}
+	for _, cred := range cfg.Credentials {
+		g.secrets[cred.AccessKey] = cred.SecretKey
+	}
+	g.server = &http.Server{Handler: g, ReadHeaderTimeout: 10 * time.Second}
+	return g, nil
+}
+
+// Config returns the configuration of the gateway, defaults applied
+func (g *Gateway) Config() Config {
+	return g.cfg
+}
+
+// Start listens on the configured address and serves in the background
+func (g *Gateway) Start() error {
+	listener, err := net.Listen("tcp", g.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", g.cfg.Listen, err)
+	}
+	if g.cfg.TLS.Enabled {
+		cert, err := tls.LoadX509KeyPair(g.cfg.TLS.CertFile, g.cfg.TLS.KeyFile)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	}
+	g.listener = listener
+	go func() {
+		if err := g.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("S3 gateway stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the gateway listens on once started
+func (g *Gateway) Addr() net.Addr {
+	if g.listener == nil {
+		return nil
+	}
+	return g.listener.Addr()
+}
+
+// Stop lets the downloads in progress finish for up to timeout, then
+// closes the remaining ones
+func (g *Gateway) Stop(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := g.server.Shutdown(ctx); err != nil {
+		g.server.Close()
+	}
+	g.root.Close()
+}
+
+// ServeHTTP serves path-style requests: / lists the bucket, /bucket lists
+// objects and /bucket/key reads an object
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Path
+	if err := g.authenticate(r); err != nil {
+		code := "AccessDenied"
+		switch {
+		case errors.Is(err, errUnknownAccessKey):
+			code = "InvalidAccessKeyId"
+		case errors.Is(err, errSignature):
+			code = "SignatureDoesNotMatch"
+		}
+		writeError(w, http.StatusForbidden, code, err.Error(), resource)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "the gateway is read-only", resource)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(resource, "/"), "/")
+	switch {
+	case bucket == "":
+		g.listBuckets(w)
+	case bucket != g.cfg.Bucket:
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "the bucket does not exist", resource)
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "":
+		g.listObjects(w, r)
+	default:
+		g.getObject(w, r, key)
+	}
+}
+
+// authenticate verifies the signature of a request, letting unsigned ones
+// through when anonymous access is enabled
+func (g *Gateway) authenticate(r *http.Request) error {
+	sig, err := parseSignature(r)
+	if errors.Is(err, errUnsigned) && g.cfg.Anonymous {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	secret, ok := g.secrets[sig.accessKey]
+	if !ok {
+		return errUnknownAccessKey
+	}
+	return sig.verify(r, secret, g.cfg.Region, g.now())
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   owner    `xml:"Owner"`
+	Buckets []bucket `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+func (g *Gateway) listBuckets(w http.ResponseWriter) {
+	created := time.Now()
+	if info, err := g.root.Stat("."); err == nil {
+		created = info.ModTime()
+	}
+	writeXML(w, http.StatusOK, listAllMyBucketsResult{
+		Xmlns:   xmlns,
+		Owner:   owner{ID: "ollama-distributed", DisplayName: "ollama-distributed"},
+		Buckets: []bucket{{Name: g.cfg.Bucket, CreationDate: created.UTC().Format(time.RFC3339)}},
+	})
+}
+
+// object is a file of the blob store
+type object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name       `xml:"ListBucketResult"`
+	Xmlns          string         `xml:"xmlns,attr"`
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []object       `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+
+	// ListObjectsV2
+	KeyCount              *int   `xml:"KeyCount,omitempty"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+
+	// ListObjects
+	Marker     *string `xml:"Marker,omitempty"`
+	NextMarker string  `xml:"NextMarker,omitempty"`
+}
+
+// listObjects answers ListObjectsV2 and, without list-type=2, ListObjects
+func (g *Gateway) listObjects(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	v2 := q.Get("list-type") == "2"
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	encoding := q.Get("encoding-type")
+	if encoding != "" && encoding != "url" {
+		writeError(w, http.StatusBadRequest, "InvalidArgument", "invalid encoding-type", r.URL.Path)
+		return
+	}
+	maxKeys := defaultMaxKeys
+	if s := q.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "InvalidArgument", "invalid max-keys", r.URL.Path)
+			return
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+
+	// Keys after start are listed
+	start := q.Get("marker")
+	if v2 {
+		start = q.Get("start-after")
+		if token := q.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "InvalidArgument", "invalid continuation-token", r.URL.Path)
+				return
+			}
+			start = string(decoded)
+		}
+	}
+
+	objects, err := g.objects(prefix)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalError", err.Error(), r.URL.Path)
+		return
+	}
+
+	encode := func(s string) string { return s }
+	if encoding == "url" {
+		encode = func(s string) string { return strings.ReplaceAll(url.PathEscape(s), "%2F", "/") }
+	}
+	result := listBucketResult{
+		Xmlns:        xmlns,
+		Name:         g.cfg.Bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		MaxKeys:      maxKeys,
+		EncodingType: encoding,
+	}
+	last := ""
+	for _, obj := range objects {
+		key := obj.Key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				key = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if key <= start || key == last {
+			continue
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		last = key
+		if key != obj.Key {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(key)})
+			continue
+		}
+		obj.Key = encode(obj.Key)
+		result.Contents = append(result.Contents, obj)
+	}
+
+	if v2 {
+		count := len(result.Contents) + len(result.CommonPrefixes)
+		result.KeyCount = &count
+		result.StartAfter = encode(q.Get("start-after"))
+		result.ContinuationToken = q.Get("continuation-token")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker := encode(q.Get("marker"))
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = encode(last)
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// objects returns the files under prefix in key order. Dot files, such as
+// partial downloads, are left out.
+func (g *Gateway) objects(prefix string) ([]object, error) {
+	var objects []object
+	err := fs.WalkDir(g.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// Skip directories that cannot hold keys with the prefix
+			if path != "." && !strings.HasPrefix(path+"/", prefix) && !strings.HasPrefix(prefix, path+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, object{
+			Key:          path,
+			LastModified: info.ModTime().UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         etag(info),
+			Size:         info.Size(),
+			StorageClass: "STANDARD",
+		})
+		return nil
+	})
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, err
+}
+
+// getObject answers GetObject and HeadObject, with ranges and conditional
+// requests
+func (g *Gateway) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	if !fs.ValidPath(key) || hidden(key) {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "the key does not exist", r.URL.Path)
+		return
+	}
+	f, err := g.root.Open(key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "the key does not exist", r.URL.Path)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "the key does not exist", r.URL.Path)
+		return
+	}
+
+	w.Header().Set("ETag", etag(info))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// hidden reports whether a key names a dot file or lies in a dot directory
+func hidden(key string) bool {
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// etag identifies the version of a file by its size and modification
+// time; hashing multi-gigabyte blobs on every listing would be too slow
+func etag(info fs.FileInfo) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", info.Name(), info.Size(), info.ModTime().UnixNano())))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message, resource string) {
+	writeXML(w, status, errorResponse{Code: code, Message: message, Resource: resource})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write S3 response", "error", err)
+	}
+}
@@ -0,0 +1,259 @@
+package s3gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature Version 4 constants
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	service         = "s3"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// maxClockSkew is how far the time of a signed request may be off
+	maxClockSkew = 15 * time.Minute
+	// MaxPresignExpiry is the longest a presigned URL is valid, as in S3
+	MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// Errors authenticating a request
+var (
+	errUnsigned         = errors.New("request is not signed")
+	errUnknownAccessKey = errors.New("unknown access key")
+	errSignature        = errors.New("signature does not match")
+	errExpired          = errors.New("request has expired")
+)
+
+// signature holds the parts of a Signature Version 4 signed request
+type signature struct {
+	accessKey     string
+	date          string // yyyymmdd of the scope
+	region        string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	expires       time.Duration // presigned URLs only
+	payloadHash   string
+	presigned     bool
+}
+
+// parseSignature extracts the signature of a request from its
+// Authorization header or, for presigned URLs, its query
+func parseSignature(r *http.Request) (signature, error) {
+	if q := r.URL.Query(); q.Get("X-Amz-Algorithm") != "" {
+		if q.Get("X-Amz-Algorithm") != algorithm {
+			return signature{}, fmt.Errorf("unsupported algorithm %s", q.Get("X-Amz-Algorithm"))
+		}
+		sig := signature{presigned: true, signature: q.Get("X-Amz-Signature"), payloadHash: unsignedPayload}
+		if err := sig.parseCredential(q.Get("X-Amz-Credential")); err != nil {
+			return signature{}, err
+		}
+		sig.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+		seconds, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > MaxPresignExpiry {
+			return signature{}, fmt.Errorf("invalid X-Amz-Expires")
+		}
+		sig.expires = time.Duration(seconds) * time.Second
+		if sig.amzDate, err = time.Parse(amzDateFormat, q.Get("X-Amz-Date")); err != nil {
+			return signature{}, fmt.Errorf("invalid X-Amz-Date")
+		}
+		if hash := q.Get("X-Amz-Content-Sha256"); hash != "" {
+			sig.payloadHash = hash
+		}
+		return sig, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return signature{}, errUnsigned
+	}
+	if !strings.HasPrefix(auth, algorithm+" ") {
+		return signature{}, fmt.Errorf("unsupported authorization, use %s", algorithm)
+	}
+	var sig signature
+	for _, part := range strings.Split(strings.TrimPrefix(auth, algorithm+" "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			if err := sig.parseCredential(value); err != nil {
+				return signature{}, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+	var err error
+	if sig.amzDate, err = time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date")); err != nil {
+		return signature{}, fmt.Errorf("invalid X-Amz-Date")
+	}
+	sig.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if sig.payloadHash == "" {
+		return signature{}, fmt.Errorf("missing X-Amz-Content-Sha256")
+	}
+	return sig, nil
+}
+
+// parseCredential parses access-key/yyyymmdd/region/s3/aws4_request
+func (s *signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != service || parts[4] != "aws4_request" {
+		return fmt.Errorf("malformed credential %q", credential)
+	}
+	s.accessKey, s.date, s.region = parts[0], parts[1], parts[2]
+	return nil
+}
+
+// verify checks a signed request against the secret of its access key at
+// now
+func (s signature) verify(r *http.Request, secret, region string, now time.Time) error {
+	if s.region != region {
+		return fmt.Errorf("credential scope region %s, expected %s", s.region, region)
+	}
+	if s.date != s.amzDate.Format("20060102") {
+		return fmt.Errorf("credential date does not match X-Amz-Date")
+	}
+	if s.presigned {
+		if now.Before(s.amzDate.Add(-maxClockSkew)) || !now.Before(s.amzDate.Add(s.expires)) {
+			return errExpired
+		}
+	} else if d := now.Sub(s.amzDate); d > maxClockSkew || d < -maxClockSkew {
+		return fmt.Errorf("request time too skewed")
+	}
+
+	want := sign(secret, s.amzDate, region, canonicalRequest(r, s.signedHeaders, s.payloadHash, s.presigned))
+	if !hmac.Equal([]byte(want), []byte(s.signature)) {
+		return errSignature
+	}
+	return nil
+}
+
+// canonicalRequest returns the canonical form of a request that is signed
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(canonicalURI(r.URL.Path))
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL.Query(), presigned))
+	b.WriteByte('\n')
+	for _, name := range signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		if name == "host" {
+			b.WriteString(r.Host)
+		} else {
+			values := r.Header.Values(name)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			b.WriteString(strings.Join(values, ","))
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+// canonicalURI encodes each segment of a path once, as S3 clients do
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery encodes and sorts the query parameters, leaving out the
+// signature of a presigned URL
+func canonicalQuery(query url.Values, presigned bool) string {
+	var pairs []string
+	for name, values := range query {
+		if presigned && name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes all but the unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sign returns the signature of a canonical request
+func sign(secret string, at time.Time, region, canonical string) string {
+	date := at.Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := algorithm + "\n" + at.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Presign returns a URL for a GET of rawURL, e.g.
+// https://node:9300/models/blobs/sha256-..., valid for expires from at and
+// signed with a credential, as `aws s3 presign` does
+func Presign(cred Credential, region, rawURL string, at time.Time, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > MaxPresignExpiry {
+		return "", fmt.Errorf("presigned URLs expire after up to %s", MaxPresignExpiry)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	at = at.UTC()
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", cred.AccessKey+"/"+at.Format("20060102")+"/"+region+"/"+service+"/aws4_request")
+	query.Set("X-Amz-Date", at.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(query, true)
+
+	r := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: http.Header{}}
+	signature := sign(cred.SecretKey, at, region, canonicalRequest(r, []string{"host"}, unsignedPayload, true))
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/timesync"
+)
+
+// SetClockSkewThreshold sets the clock skew of a node beyond which a
+// warning is logged
+func (e *Engine) SetClockSkewThreshold(threshold time.Duration) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+	e.skewThreshold = threshold
+}
+
+// ClockSkews returns the estimated clock skew of every node
+func (e *Engine) ClockSkews() map[string]time.Duration {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	skews := make(map[string]time.Duration, len(e.nodes))
+	for id, node := range e.nodes {
+		skews[id] = node.ClockSkew
+	}
+	return skews
+}
+
+// updateClockSkew estimates the clock skew of node from the timestamp of a
+// health check answer, warning when it crosses the threshold. The caller
+// holds nodesMu.
+func (e *Engine) updateClockSkew(node *NodeInfo, sent, received time.Time, timestamp interface{}) {
+	remote, ok := healthTimestamp(timestamp)
+	if !ok {
+		return
+	}
+
+	skew := timesync.EstimateOffset(sent, received, remote)
+	exceeded := func(d time.Duration) bool {
+		return e.skewThreshold > 0 && (d > e.skewThreshold || d < -e.skewThreshold)
+	}
+	if exceeded(skew) && !exceeded(node.ClockSkew) {
+		slog.Warn("node clock skew beyond threshold", "node_id", node.ID, "skew", skew, "threshold", e.skewThreshold)
+	}
+	node.ClockSkew = skew
+}
+
+// healthTimestamp reads the timestamp of a health check answer, a time or,
+// once it went through JSON, an RFC 3339 string
+func healthTimestamp(v interface{}) (time.Time, bool) {
+	switch ts := v.(type) {
+	case time.Time:
+		return ts, !ts.IsZero()
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
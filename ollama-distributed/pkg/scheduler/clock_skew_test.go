@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateClockSkew(t *testing.T) {
+	e := &Engine{nodes: make(map[string]*NodeInfo), skewThreshold: time.Second}
+	node := &NodeInfo{ID: "node-1"}
+	e.nodes[node.ID] = node
+
+	sent := time.Now()
+	received := sent.Add(20 * time.Millisecond)
+	e.updateClockSkew(node, sent, received, sent.Add(10*time.Millisecond-2*time.Second))
+	assert.Equal(t, -2*time.Second, node.ClockSkew)
+
+	// Timestamps decoded from JSON arrive as strings
+	remote := sent.Add(10*time.Millisecond + 300*time.Millisecond).Format(time.RFC3339Nano)
+	e.updateClockSkew(node, sent, received, remote)
+	assert.Equal(t, 300*time.Millisecond, e.ClockSkews()["node-1"])
+
+	// Answers without a timestamp keep the last estimate
+	e.updateClockSkew(node, sent, received, nil)
+	assert.Equal(t, 300*time.Millisecond, node.ClockSkew)
+}
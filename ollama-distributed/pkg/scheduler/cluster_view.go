@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+var _ types.ClusterView = (*Engine)(nil)
+
+// ListNodes returns copies of all registered nodes, ordered by ID. A
+// node's active requests are at least those this scheduler has in flight
+// to it.
+func (e *Engine) ListNodes() []*NodeInfo {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	nodes := make([]*NodeInfo, 0, len(e.nodes))
+	for _, node := range e.nodes {
+		copied := *node
+		copied.ActiveRequests = max(copied.ActiveRequests, e.inFlight[node.ID])
+		nodes = append(nodes, &copied)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// GetNode returns a copy of a registered node
+func (e *Engine) GetNode(nodeID string) (*NodeInfo, bool) {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	node, exists := e.nodes[nodeID]
+	if !exists {
+		return nil, false
+	}
+	copied := *node
+	return &copied, true
+}
+
+// Subscribe calls fn on every node joining the registry or changing status
+// or resources, until cancel is called. fn is called with the registry
+// locked: it must return quickly and not call back into the engine.
+func (e *Engine) Subscribe(fn func(types.NodeEvent)) (cancel func()) {
+	return e.nodeEvents.Subscribe(fn)
+}
+
+// publishNode tells the subscribers about a change to a node. nodesMu must
+// be held.
+func (e *Engine) publishNode(kind types.NodeEventType, node *NodeInfo) {
+	e.nodeEvents.Publish(kind, node)
+}
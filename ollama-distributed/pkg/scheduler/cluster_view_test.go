@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineClusterView(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+
+	nodes := e.ListNodes()
+	require.Len(t, nodes, 4)
+	assert.Equal(t, "a", nodes[0].ID)
+	nodes[0].Status = NodeStatusOffline
+	node, ok := e.GetNode("a")
+	require.True(t, ok)
+	assert.Equal(t, NodeStatusOnline, node.Status, "the view hands out copies")
+	_, ok = e.GetNode("z")
+	assert.False(t, ok)
+
+	e.trackInFlight("c", 1)
+	e.trackInFlight("c", 1)
+	e.trackInFlight("d", 1)
+	e.trackInFlight("d", -1)
+	nodes = e.ListNodes()
+	assert.Equal(t, 2, nodes[2].ActiveRequests)
+	assert.Zero(t, nodes[3].ActiveRequests)
+
+	var events []types.NodeEvent
+	cancel := e.Subscribe(func(event types.NodeEvent) { events = append(events, event) })
+	e.DrainNode("b")
+	e.AddTestNode(&NodeInfo{ID: "e", Status: NodeStatusOnline})
+	cancel()
+	e.UndrainNode("b")
+
+	require.Len(t, events, 2)
+	assert.Equal(t, types.NodeUpdated, events[0].Type)
+	assert.Equal(t, NodeStatusDraining, events[0].Node.Status)
+	assert.Equal(t, types.NodeJoined, events[1].Type)
+	assert.Equal(t, "e", events[1].Node.ID)
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Outcomes of context negotiation
+const (
+	// ContextBiggerNode moved the request to a node with room for its
+	// context
+	ContextBiggerNode = "bigger_node"
+	// ContextSplit split the request across nodes by tensor parallelism
+	ContextSplit = "split"
+	// ContextReduced serves the request with the largest context that fits
+	ContextReduced = "reduced"
+)
+
+const (
+	defaultMinContext = 2048
+	// contextGranularity is what reduced contexts are rounded down to
+	contextGranularity = 256
+	// minKVBytesPerToken bounds the estimate of the memory a token of
+	// context takes from below
+	minKVBytesPerToken = 32 << 10
+	// kvTokensPerModelSize estimates the memory a token of context takes
+	// as the model size over this, about 128KiB for a 4GB 8B model
+	kvTokensPerModelSize = 32768
+)
+
+// ErrContextTooLarge is returned when no node, or set of nodes, can serve a
+// request with even the minimum context
+var ErrContextTooLarge = errors.New("requested context does not fit on any node")
+
+// ContextNegotiation records how a request asking for more context than
+// its node had memory for was accommodated
+type ContextNegotiation struct {
+	Requested int    `json:"requested"`
+	Granted   int    `json:"granted"`
+	Outcome   string `json:"outcome"`
+	// Nodes are the nodes a split request runs across, the one it is
+	// sent to first
+	Nodes   []string `json:"nodes,omitempty"`
+	Warning string   `json:"warning,omitempty"`
+}
+
+// requestedContext returns the num_ctx option of a request, or 0
+func requestedContext(req *Request) int {
+	options, ok := req.Payload["options"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch v := options["num_ctx"].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return 0
+}
+
+// contextCost returns the size of a model and the memory a token of its
+// context takes
+func (e *Engine) contextCost(modelName string) (int64, int64) {
+	var size int64
+	var perToken int64
+	if model, ok := e.GetModel(modelName); ok {
+		size = model.Size
+		if v, err := strconv.ParseInt(model.Metadata["kv_bytes_per_token"], 10, 64); err == nil && v > 0 {
+			perToken = v
+		}
+	}
+	if perToken == 0 {
+		perToken = e.config.ContextNegotiation.KVBytesPerToken
+	}
+	if perToken == 0 {
+		perToken = size / kvTokensPerModelSize
+	}
+	if perToken < minKVBytesPerToken {
+		perToken = minKVBytesPerToken
+	}
+	return size, perToken
+}
+
+// freeMemory returns the memory a node has free, or -1 when it has not
+// reported its capacity
+func freeMemory(node *NodeInfo) int64 {
+	if node.Capacity.Memory <= 0 {
+		return -1
+	}
+	return int64(float64(node.Capacity.Memory) * (100 - node.Usage.Memory) / 100)
+}
+
+// weightsOn returns the memory a model's weights still need on a node:
+// none when the node holds the model, as it is counted in its usage
+func weightsOn(node *NodeInfo, modelName string, size int64) int64 {
+	if contains(node.Models, modelName) {
+		return 0
+	}
+	return size
+}
+
+// negotiateContext narrows the candidates of a request to the nodes with
+// memory for the context it asks for. When none has, the request is moved
+// to another placeable node that has, split across several, or reduced to
+// the largest context that fits, in that order, and the outcome is
+// recorded on the request.
+func (e *Engine) negotiateContext(req *Request, candidates, placeable []*NodeInfo) ([]*NodeInfo, error) {
+	cfg := e.config.ContextNegotiation
+	requested := requestedContext(req)
+	if !cfg.Enabled || requested <= 0 || len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	size, perToken := e.contextCost(req.ModelName)
+	fitting := func(nodes []*NodeInfo) []*NodeInfo {
+		var fit []*NodeInfo
+		for _, node := range nodes {
+			free := freeMemory(node)
+			if free < 0 || free >= weightsOn(node, req.ModelName, size)+int64(requested)*perToken {
+				fit = append(fit, node)
+			}
+		}
+		return fit
+	}
+
+	if fit := fitting(candidates); len(fit) == len(candidates) {
+		return candidates, nil
+	} else if len(fit) > 0 {
+		req.Context = &ContextNegotiation{Requested: requested, Granted: requested, Outcome: ContextBiggerNode}
+		return fit, nil
+	}
+	if fit := fitting(placeable); len(fit) > 0 {
+		req.Context = &ContextNegotiation{Requested: requested, Granted: requested, Outcome: ContextBiggerNode}
+		return fit, nil
+	}
+
+	// Nodes that reported their capacity, most free memory first
+	var known []*NodeInfo
+	for _, node := range placeable {
+		if freeMemory(node) >= 0 {
+			known = append(known, node)
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool { return freeMemory(known[i]) > freeMemory(known[j]) })
+
+	if cfg.AllowSplit {
+		need := size + int64(requested)*perToken
+		var free int64
+		for i, node := range known {
+			free += freeMemory(node)
+			if i > 0 && free >= need {
+				nodes := make([]string, i+1)
+				for j := range nodes {
+					nodes[j] = known[j].ID
+				}
+				req.Context = &ContextNegotiation{Requested: requested, Granted: requested, Outcome: ContextSplit, Nodes: nodes}
+				return known[:1], nil
+			}
+		}
+	}
+
+	minContext := cfg.MinContext
+	if minContext == 0 {
+		minContext = defaultMinContext
+	}
+	var best *NodeInfo
+	granted := 0
+	for _, node := range known {
+		tokens := int((freeMemory(node) - weightsOn(node, req.ModelName, size)) / perToken)
+		tokens -= tokens % contextGranularity
+		if tokens > granted {
+			best, granted = node, tokens
+		}
+	}
+	if best == nil || granted < minContext {
+		return nil, fmt.Errorf("%w: %d tokens of %s need about %d bytes, the most any node fits is %d tokens",
+			ErrContextTooLarge, requested, req.ModelName, int64(requested)*perToken, granted)
+	}
+
+	req.Payload["options"].(map[string]interface{})["num_ctx"] = granted
+	req.Context = &ContextNegotiation{
+		Requested: requested,
+		Granted:   granted,
+		Outcome:   ContextReduced,
+		Warning: fmt.Sprintf("requested context of %d tokens does not fit in the memory of any node, reduced to %d",
+			requested, granted),
+	}
+	return []*NodeInfo{best}, nil
+}
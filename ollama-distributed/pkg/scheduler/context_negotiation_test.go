@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gib = int64(1) << 30
+
+// newContextEngine returns an engine with a 4GiB model taking 128KiB per
+// token of context, on a small node holding it and a larger one that
+// does not
+func newContextEngine(t *testing.T, cn config.ContextNegotiationConfig) *Engine {
+	cn.Enabled = true
+	e, err := NewEngine(&config.SchedulerConfig{LoadBalancing: "round_robin", QueueSize: 10, WorkerCount: 1,
+		Deterministic: true, ContextNegotiation: cn}, nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, e.RegisterModel("llama3", 4*gib, "", "small"))
+	e.AddTestNode(&NodeInfo{ID: "small", Status: NodeStatusOnline, Models: []string{"llama3"},
+		Capacity: NodeCapacity{Memory: 8 * gib}, Usage: NodeUsage{Memory: 75}})
+	e.AddTestNode(&NodeInfo{ID: "large", Status: NodeStatusOnline,
+		Capacity: NodeCapacity{Memory: 16 * gib}, Usage: NodeUsage{Memory: 50}})
+	return e
+}
+
+func contextRequest(numCtx int) *Request {
+	return &Request{ModelName: "llama3", Payload: map[string]interface{}{
+		"options": map[string]interface{}{"num_ctx": float64(numCtx)},
+	}}
+}
+
+func TestContextFitsSelectedNode(t *testing.T) {
+	e := newContextEngine(t, config.ContextNegotiationConfig{})
+
+	// 2GiB free on small holds 8192 tokens
+	req := contextRequest(8192)
+	node, err := e.loadBalancer.SelectNode(req)
+	require.NoError(t, err)
+	assert.Equal(t, "small", node.ID)
+	assert.Nil(t, req.Context)
+
+	// Requests without num_ctx are not negotiated
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "small", node.ID)
+}
+
+func TestContextMovesToBiggerNode(t *testing.T) {
+	e := newContextEngine(t, config.ContextNegotiationConfig{})
+
+	// large has 8GiB free: 4GiB for the weights and 4GiB for 32768 tokens
+	req := contextRequest(32768)
+	node, err := e.loadBalancer.SelectNode(req)
+	require.NoError(t, err)
+	assert.Equal(t, "large", node.ID)
+	require.NotNil(t, req.Context)
+	assert.Equal(t, ContextBiggerNode, req.Context.Outcome)
+	assert.Equal(t, 32768, req.Context.Granted)
+}
+
+func TestContextSplitsAcrossNodes(t *testing.T) {
+	e := newContextEngine(t, config.ContextNegotiationConfig{AllowSplit: true})
+
+	// 10GiB free in all: 4GiB of weights and 6GiB for 49152 tokens
+	req := contextRequest(49152)
+	node, err := e.loadBalancer.SelectNode(req)
+	require.NoError(t, err)
+	assert.Equal(t, "large", node.ID)
+	require.NotNil(t, req.Context)
+	assert.Equal(t, ContextSplit, req.Context.Outcome)
+	assert.Equal(t, []string{"large", "small"}, req.Context.Nodes)
+}
+
+func TestContextReducedWithWarning(t *testing.T) {
+	e := newContextEngine(t, config.ContextNegotiationConfig{})
+
+	req := contextRequest(131072)
+	node, err := e.loadBalancer.SelectNode(req)
+	require.NoError(t, err)
+	assert.Equal(t, "large", node.ID)
+	require.NotNil(t, req.Context)
+	assert.Equal(t, ContextReduced, req.Context.Outcome)
+	assert.Equal(t, 131072, req.Context.Requested)
+	assert.Equal(t, 32768, req.Context.Granted)
+	assert.NotEmpty(t, req.Context.Warning)
+	assert.Equal(t, 32768, req.Payload["options"].(map[string]interface{})["num_ctx"])
+}
+
+func TestContextTooLarge(t *testing.T) {
+	e := newContextEngine(t, config.ContextNegotiationConfig{MinContext: 65536})
+
+	_, err := e.loadBalancer.SelectNode(contextRequest(131072))
+	assert.ErrorIs(t, err, ErrContextTooLarge)
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// SetDiskScheduler sets the disk scheduler model loads are placed by.
+// Without one, disks are not considered.
+func (e *Engine) SetDiskScheduler(disk *placement.DiskScheduler) {
+	e.placementMu.Lock()
+	defer e.placementMu.Unlock()
+	e.disk = disk
+}
+
+// DiskScheduler returns the disk scheduler, or nil when disks are not
+// considered
+func (e *Engine) DiskScheduler() *placement.DiskScheduler {
+	e.placementMu.RLock()
+	defer e.placementMu.RUnlock()
+	return e.disk
+}
+
+// diskCandidates narrows the nodes a request would load its model on to
+// those whose disks suit the load. Candidates already holding the model
+// are returned unchanged, as no load is needed.
+func (e *Engine) diskCandidates(modelName string, nodes []*NodeInfo) []*NodeInfo {
+	disk := e.DiskScheduler()
+	if disk == nil {
+		return nodes
+	}
+	for _, node := range nodes {
+		if contains(node.Models, modelName) {
+			return nodes
+		}
+	}
+
+	byID := make(map[string]*NodeInfo, len(nodes))
+	views := make([]placement.Node, 0, len(nodes))
+	for _, node := range nodes {
+		view := e.labels.Node(node.ID, node.Models)
+		view.Disk = node.Disk
+		byID[node.ID] = node
+		views = append(views, view)
+	}
+
+	preferred, _ := disk.Prefer(modelName, e.modelSize(modelName), views)
+	candidates := make([]*NodeInfo, len(preferred))
+	for i, view := range preferred {
+		candidates[i] = byID[view.ID]
+	}
+	return candidates
+}
+
+// recordLoad tells the disk scheduler that the model starts loading on the
+// node selected for a request, unless the node holds it already
+func (e *Engine) recordLoad(modelName string, node *NodeInfo) {
+	disk := e.DiskScheduler()
+	if disk == nil || contains(node.Models, modelName) {
+		return
+	}
+	disk.LoadStarted(node.ID, modelName, e.modelSize(modelName))
+}
+
+// modelSize returns the size of a registered model, or 0 if it is unknown
+func (e *Engine) modelSize(modelName string) int64 {
+	if model, ok := e.GetModel(modelName); ok {
+		return model.Size
+	}
+	return 0
+}
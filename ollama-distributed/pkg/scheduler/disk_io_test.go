@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDiskEngine(t *testing.T) *Engine {
+	e, err := NewEngine(&config.SchedulerConfig{LoadBalancing: "least_connections", QueueSize: 10, WorkerCount: 1, Deterministic: true}, nil, nil)
+	require.NoError(t, err)
+
+	e.AddTestNode(&NodeInfo{ID: "hdd", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 10}})
+	e.AddTestNode(&NodeInfo{ID: "fast", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 30}, Disk: placement.DiskStats{Latency: time.Millisecond}})
+	e.AddTestNode(&NodeInfo{ID: "nvme", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 50}})
+	require.NoError(t, e.SetNodeLabels("hdd", placement.NodeLabels{Labels: map[string]string{placement.LabelDiskType: placement.DiskTypeHDD}}))
+	require.NoError(t, e.SetNodeLabels("nvme", placement.NodeLabels{Labels: map[string]string{placement.LabelDiskType: placement.DiskTypeNVMe}}))
+	require.NoError(t, e.RegisterModel("llama3:70b", 40<<30, "", ""))
+	require.NoError(t, e.RegisterModel("qwen:72b", 40<<30, "", ""))
+	return e
+}
+
+func TestSelectNodeStaggersLargeLoadsOnSlowDisks(t *testing.T) {
+	e := newDiskEngine(t)
+	e.SetDiskScheduler(placement.NewDiskScheduler(placement.DiskConfig{Enabled: true}))
+
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3:70b"})
+	require.NoError(t, err)
+	assert.Equal(t, "hdd", node.ID)
+
+	// hdd is the least loaded but its disk is busy loading llama3:70b
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "qwen:72b"})
+	require.NoError(t, err)
+	assert.Equal(t, "fast", node.ID)
+
+	// Small models still go to the least loaded node
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "phi3"})
+	require.NoError(t, err)
+	assert.Equal(t, "hdd", node.ID)
+}
+
+func TestSelectNodePutsSwappedModelsOnNVMe(t *testing.T) {
+	e := newDiskEngine(t)
+	e.SetDiskScheduler(placement.NewDiskScheduler(placement.DiskConfig{Enabled: true, SwapThreshold: 2}))
+
+	for i := 0; i < 2; i++ {
+		node, err := e.loadBalancer.SelectNode(&Request{ModelName: "phi3"})
+		require.NoError(t, err)
+		assert.Equal(t, "hdd", node.ID)
+	}
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "phi3"})
+	require.NoError(t, err)
+	assert.Equal(t, "nvme", node.ID)
+
+	// Nodes holding the model need no load
+	e.AddTestNode(&NodeInfo{ID: "hdd", Status: NodeStatusOnline, Models: []string{"phi3"}, Usage: NodeUsage{CPU: 10}})
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "phi3"})
+	require.NoError(t, err)
+	assert.Equal(t, "hdd", node.ID)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/routing"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 )
 
 // Start starts the cluster manager
@@ -48,6 +49,7 @@ func (cm *ClusterManager) registerLocalNode() error {
 
 	cm.nodesMu.Lock()
 	cm.nodes[localNode.ID] = localNode
+	cm.publishNode(types.NodeJoined, localNode)
 	cm.nodesMu.Unlock()
 
 	// Announce to cluster
@@ -143,6 +145,7 @@ func (cm *ClusterManager) handleHeartbeat(heartbeat *HeartbeatMessage) {
 		node.Models = heartbeat.Models
 		node.LastSeen = heartbeat.Timestamp
 		node.Metadata = heartbeat.Metadata
+		cm.publishNode(types.NodeUpdated, node)
 	} else {
 		// Create new node from heartbeat
 		cm.nodes[heartbeat.NodeID] = &NodeInfo{
@@ -154,6 +157,7 @@ func (cm *ClusterManager) handleHeartbeat(heartbeat *HeartbeatMessage) {
 			LastSeen: heartbeat.Timestamp,
 			Metadata: heartbeat.Metadata,
 		}
+		cm.publishNode(types.NodeJoined, cm.nodes[heartbeat.NodeID])
 	}
 }
 
@@ -169,6 +173,10 @@ func (cm *ClusterManager) GetAvailableNodes() []*NodeInfo {
 		}
 	}
 
+	if cm.scheduler != nil && cm.scheduler.config.Deterministic {
+		sortNodesByID(available)
+	}
+
 	return available
 }
 
@@ -255,6 +263,7 @@ func (cm *ClusterManager) UpdateNodeStatus(nodeID string, status NodeStatus) err
 	if node, exists := cm.nodes[nodeID]; exists {
 		node.Status = status
 		node.LastSeen = time.Now()
+		cm.publishNode(types.NodeUpdated, node)
 		return nil
 	}
 
@@ -377,10 +386,19 @@ func (nd *NodeDiscovery) handleAnnouncement(announcement *NodeAnnouncement) {
 
 	// Update cluster manager
 	nd.manager.nodesMu.Lock()
-	if announcement.Action == "leave" {
+	_, known := nd.manager.nodes[announcement.Node.ID]
+	switch {
+	case announcement.Action == "leave":
 		delete(nd.manager.nodes, announcement.Node.ID)
-	} else {
+		if known {
+			nd.manager.publishNode(types.NodeLeft, announcement.Node)
+		}
+	case known:
+		nd.manager.nodes[announcement.Node.ID] = announcement.Node
+		nd.manager.publishNode(types.NodeUpdated, announcement.Node)
+	default:
 		nd.manager.nodes[announcement.Node.ID] = announcement.Node
+		nd.manager.publishNode(types.NodeJoined, announcement.Node)
 	}
 	nd.manager.nodesMu.Unlock()
 }
@@ -0,0 +1,85 @@
+package distributed
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Info returns the shared view of the node. Utilizations become
+// percentages.
+func (n *NodeInfo) Info() *types.NodeInfo {
+	info := &types.NodeInfo{
+		ID:       n.ID,
+		Address:  n.Address,
+		Status:   types.NodeStatus(n.Status),
+		Models:   append([]string(nil), n.Models...),
+		LastSeen: n.LastSeen,
+		Metadata: make(map[string]string, len(n.Metadata)),
+	}
+	if c := n.Capacity; c != nil {
+		info.Capacity = types.NodeCapacity{
+			CPU:    c.CPUCores,
+			Memory: c.MemoryBytes,
+			GPU:    int64(c.GPUCount),
+			Disk:   c.DiskBytes,
+		}
+	}
+	if u := n.Usage; u != nil {
+		info.Usage = types.NodeUsage{
+			CPU:    u.CPUUtilization * 100,
+			Memory: u.MemoryUtilization * 100,
+			GPU:    u.GPUUtilization * 100,
+			Disk:   u.DiskUtilization * 100,
+		}
+	}
+	for k, v := range n.Metadata {
+		info.Metadata[k] = fmt.Sprint(v)
+	}
+	return info
+}
+
+// clusterView is the ClusterView of the cluster manager
+type clusterView struct {
+	cm *ClusterManager
+}
+
+// View returns the typed view of the cluster's nodes the fault tolerance
+// components work from
+func (cm *ClusterManager) View() types.ClusterView {
+	return clusterView{cm: cm}
+}
+
+func (v clusterView) ListNodes() []*types.NodeInfo {
+	v.cm.nodesMu.RLock()
+	defer v.cm.nodesMu.RUnlock()
+
+	infos := make([]*types.NodeInfo, 0, len(v.cm.nodes))
+	for _, node := range v.cm.nodes {
+		infos = append(infos, node.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+func (v clusterView) GetNode(id string) (*types.NodeInfo, bool) {
+	v.cm.nodesMu.RLock()
+	defer v.cm.nodesMu.RUnlock()
+
+	node, exists := v.cm.nodes[id]
+	if !exists {
+		return nil, false
+	}
+	return node.Info(), true
+}
+
+func (v clusterView) Subscribe(fn func(types.NodeEvent)) (cancel func()) {
+	return v.cm.events.Subscribe(fn)
+}
+
+// publishNode tells the view's subscribers about a change to a node.
+// nodesMu must be held.
+func (cm *ClusterManager) publishNode(kind types.NodeEventType, node *NodeInfo) {
+	cm.events.Publish(kind, node.Info())
+}
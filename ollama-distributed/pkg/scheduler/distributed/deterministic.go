@@ -0,0 +1,52 @@
+package distributed
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Environment variables enabling deterministic scheduling
+const (
+	envDeterministic = "OLLAMA_SCHEDULER_DETERMINISTIC"
+	envSeed          = "OLLAMA_SCHEDULER_SEED"
+)
+
+// applyDeterminismEnv enables deterministic scheduling from the environment
+func applyDeterminismEnv(config *DistributedConfig) {
+	if value := os.Getenv(envDeterministic); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			slog.Warn("ignoring invalid scheduler determinism flag", "env", envDeterministic, "value", value)
+		} else {
+			config.Deterministic = enabled
+		}
+	}
+	if value := os.Getenv(envSeed); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			slog.Warn("ignoring invalid scheduler seed", "env", envSeed, "value", value)
+		} else {
+			config.Seed = seed
+		}
+	}
+}
+
+// nextTaskID returns the ID of a new task, taken from a counter in
+// deterministic mode so runs produce the same IDs
+func (ds *DistributedScheduler) nextTaskID() string {
+	if ds.config.Deterministic {
+		return fmt.Sprintf("task_%d", ds.taskSeq.Add(1))
+	}
+	return fmt.Sprintf("task_%d", time.Now().UnixNano())
+}
+
+// sortNodesByID orders nodes by ID in place
+func sortNodesByID(nodes []*NodeInfo) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+}
@@ -0,0 +1,188 @@
+package distributed
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SchedulingBudget is the target time for a scheduling decision, from the
+// task entering the scheduler until its nodes are chosen. The fast path is
+// expected to stay well within it; BenchmarkFastPathDecision enforces this.
+const SchedulingBudget = time.Millisecond
+
+// PartitionStrategySingleNode marks tasks placed by the fast path
+const PartitionStrategySingleNode = "single_node"
+
+// fastPathHeadroom is the share of a node's free memory a model may take and
+// still be considered to fit comfortably, leaving room for the KV cache and
+// activations
+const fastPathHeadroom = 0.7
+
+// overheadSamples is the number of recent decisions kept for percentiles
+const overheadSamples = 1024
+
+// Reasons for taking the fast path, reported in the task metadata
+const (
+	FastPathReasonSingleNode = "single_capable_node"
+	FastPathReasonModelFits  = "model_fits_node"
+)
+
+// SchedulingOverheadStats summarises the time spent deciding where tasks run
+type SchedulingOverheadStats struct {
+	Decisions         int64         `json:"decisions"`
+	FastPathDecisions int64         `json:"fast_path_decisions"`
+	OverBudget        int64         `json:"over_budget"`
+	Budget            time.Duration `json:"budget"`
+	P50               time.Duration `json:"p50"`
+	P99               time.Duration `json:"p99"`
+	Max               time.Duration `json:"max"`
+}
+
+// overheadTracker keeps a window of recent scheduling decision latencies
+type overheadTracker struct {
+	mu         sync.Mutex
+	samples    [overheadSamples]time.Duration
+	next       int
+	count      int
+	decisions  int64
+	fastPath   int64
+	overBudget int64
+}
+
+// record adds the latency of one decision
+func (t *overheadTracker) record(d time.Duration, fastPath bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % overheadSamples
+	if t.count < overheadSamples {
+		t.count++
+	}
+
+	t.decisions++
+	if fastPath {
+		t.fastPath++
+	}
+	if d > SchedulingBudget {
+		t.overBudget++
+	}
+}
+
+// stats returns the counters and percentiles over the current window
+func (t *overheadTracker) stats() SchedulingOverheadStats {
+	t.mu.Lock()
+	window := make([]time.Duration, t.count)
+	copy(window, t.samples[:t.count])
+	stats := SchedulingOverheadStats{
+		Decisions:         t.decisions,
+		FastPathDecisions: t.fastPath,
+		OverBudget:        t.overBudget,
+		Budget:            SchedulingBudget,
+	}
+	t.mu.Unlock()
+
+	if len(window) == 0 {
+		return stats
+	}
+	sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+	stats.P50 = window[(len(window)-1)*50/100]
+	stats.P99 = window[(len(window)-1)*99/100]
+	stats.Max = window[len(window)-1]
+	return stats
+}
+
+// selectFastPathNode decides whether a model can run on one node without
+// partitioning. It returns that node when it is the only node able to run
+// the model, or when the model fits comfortably into a node's free memory,
+// preferring nodes that already hold the model and then the least loaded.
+// A model of unknown size only takes the fast path with a single node.
+func selectFastPathNode(model *ModelInfo, nodes []*NodeInfo) (*NodeInfo, string, bool) {
+	var capable []*NodeInfo
+	for _, node := range nodes {
+		if node.Status == NodeStatusOnline && canHoldModel(node, model) {
+			capable = append(capable, node)
+		}
+	}
+
+	if len(capable) == 1 {
+		return capable[0], FastPathReasonSingleNode, true
+	}
+	if model == nil || model.Size <= 0 {
+		return nil, "", false
+	}
+
+	var best *NodeInfo
+	for _, node := range capable {
+		if !fitsComfortably(node, model.Size) {
+			continue
+		}
+		if best == nil || betterFastPathNode(node, best, model.Name) {
+			best = node
+		}
+	}
+	if best == nil {
+		return nil, "", false
+	}
+	return best, FastPathReasonModelFits, true
+}
+
+// canHoldModel reports whether a node has the model or enough memory for it
+func canHoldModel(node *NodeInfo, model *ModelInfo) bool {
+	if model == nil || model.Size <= 0 {
+		return true
+	}
+	if containsString(node.Models, model.Name) {
+		return true
+	}
+	return freeMemory(node) >= model.Size
+}
+
+// fitsComfortably reports whether a model of the given size fits into the
+// node's free memory with headroom to spare
+func fitsComfortably(node *NodeInfo, size int64) bool {
+	return float64(size) <= float64(freeMemory(node))*fastPathHeadroom
+}
+
+// freeMemory returns the memory a node has left for a model: its GPU memory
+// when it has any, as heartbeats carry no VRAM usage, else the unused share
+// of system memory
+func freeMemory(node *NodeInfo) int64 {
+	if node.Capacity == nil {
+		return 0
+	}
+	if node.Capacity.GPUMemoryBytes > 0 {
+		return node.Capacity.GPUMemoryBytes
+	}
+
+	utilization := 0.0
+	if node.Usage != nil {
+		utilization = node.Usage.MemoryUtilization
+	}
+	if utilization >= 1 {
+		return 0
+	}
+	return int64(float64(node.Capacity.MemoryBytes) * (1 - utilization))
+}
+
+// betterFastPathNode reports whether a is a better fast-path target than b
+func betterFastPathNode(a, b *NodeInfo, modelName string) bool {
+	aHas, bHas := containsString(a.Models, modelName), containsString(b.Models, modelName)
+	if aHas != bHas {
+		return aHas
+	}
+	return activeRequests(a) < activeRequests(b)
+}
+
+func activeRequests(node *NodeInfo) int {
+	if node.Usage == nil {
+		return 0
+	}
+	return node.Usage.ActiveRequests + node.Usage.QueuedRequests
+}
+
+// GetSchedulingOverhead returns the measured scheduling decision latency
+func (ds *DistributedScheduler) GetSchedulingOverhead() SchedulingOverheadStats {
+	return ds.engine.overhead.stats()
+}
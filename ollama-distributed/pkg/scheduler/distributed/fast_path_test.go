@@ -0,0 +1,136 @@
+package distributed
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gib = int64(1 << 30)
+
+func testNode(id string, memory int64, models ...string) *NodeInfo {
+	return &NodeInfo{
+		ID:       id,
+		Status:   NodeStatusOnline,
+		Capacity: &ResourceCapacity{MemoryBytes: memory},
+		Usage:    &ResourceUsage{},
+		Models:   models,
+	}
+}
+
+func TestFastPathSingleCapableNode(t *testing.T) {
+	model := &ModelInfo{Name: "llama3:70b", Size: 40 * gib}
+	nodes := []*NodeInfo{
+		testNode("small", 16*gib),
+		testNode("large", 48*gib),
+	}
+
+	node, reason, ok := selectFastPathNode(model, nodes)
+	require.True(t, ok)
+	assert.Equal(t, "large", node.ID)
+	assert.Equal(t, FastPathReasonSingleNode, reason)
+}
+
+func TestFastPathModelFits(t *testing.T) {
+	model := &ModelInfo{Name: "llama3:8b", Size: 5 * gib}
+	nodes := []*NodeInfo{
+		testNode("a", 32*gib),
+		testNode("b", 32*gib, "llama3:8b"),
+		testNode("c", 32*gib),
+	}
+	nodes[0].Usage.ActiveRequests = 2
+
+	node, reason, ok := selectFastPathNode(model, nodes)
+	require.True(t, ok)
+	assert.Equal(t, "b", node.ID, "nodes holding the model are preferred")
+	assert.Equal(t, FastPathReasonModelFits, reason)
+}
+
+func TestFastPathDeclinedWhenModelIsTight(t *testing.T) {
+	// Fits on both nodes, but only with less than the required headroom
+	model := &ModelInfo{Name: "mixtral", Size: 26 * gib}
+	nodes := []*NodeInfo{
+		testNode("a", 32*gib),
+		testNode("b", 32*gib),
+	}
+
+	_, _, ok := selectFastPathNode(model, nodes)
+	assert.False(t, ok)
+
+	// Memory in use counts against the headroom
+	nodes = []*NodeInfo{testNode("a", 64*gib), testNode("b", 64*gib)}
+	nodes[0].Usage.MemoryUtilization = 0.5
+	nodes[1].Usage.MemoryUtilization = 0.5
+	_, _, ok = selectFastPathNode(model, nodes)
+	assert.False(t, ok)
+}
+
+func TestFastPathUnknownModel(t *testing.T) {
+	nodes := []*NodeInfo{testNode("a", 32*gib)}
+	node, _, ok := selectFastPathNode(nil, nodes)
+	require.True(t, ok)
+	assert.Equal(t, "a", node.ID)
+
+	nodes = append(nodes, testNode("b", 32*gib))
+	_, _, ok = selectFastPathNode(nil, nodes)
+	assert.False(t, ok)
+}
+
+func TestOverheadTracker(t *testing.T) {
+	var tracker overheadTracker
+	for i := 1; i <= 100; i++ {
+		tracker.record(time.Duration(i)*time.Microsecond, i%2 == 0)
+	}
+	tracker.record(2*SchedulingBudget, false)
+
+	stats := tracker.stats()
+	assert.Equal(t, int64(101), stats.Decisions)
+	assert.Equal(t, int64(50), stats.FastPathDecisions)
+	assert.Equal(t, int64(1), stats.OverBudget)
+	assert.Equal(t, 51*time.Microsecond, stats.P50)
+	assert.Equal(t, 100*time.Microsecond, stats.P99)
+	assert.Equal(t, 2*SchedulingBudget, stats.Max)
+}
+
+func benchmarkNodes(n int) []*NodeInfo {
+	nodes := make([]*NodeInfo, n)
+	for i := range nodes {
+		nodes[i] = testNode(fmt.Sprintf("node-%d", i), 64*gib, "llama3:8b")
+		nodes[i].Usage.ActiveRequests = i % 7
+	}
+	return nodes
+}
+
+// BenchmarkFastPathDecision measures a fast-path scheduling decision over a
+// 64 node cluster, including recording its overhead
+func BenchmarkFastPathDecision(b *testing.B) {
+	model := &ModelInfo{Name: "llama3:8b", Size: 5 * gib}
+	nodes := benchmarkNodes(64)
+	var tracker overheadTracker
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, _, ok := selectFastPathNode(model, nodes); !ok {
+			b.Fatal("fast path not taken")
+		}
+		tracker.record(time.Since(start), true)
+	}
+}
+
+// TestFastPathDecisionWithinBudget fails when the fast path benchmark
+// exceeds the scheduling budget
+func TestFastPathDecisionWithinBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a benchmark")
+	}
+
+	result := testing.Benchmark(BenchmarkFastPathDecision)
+	perOp := time.Duration(result.NsPerOp())
+	t.Logf("fast path decision: %v/op, %d allocs/op", perOp, result.AllocsPerOp())
+	assert.Less(t, perOp, SchedulingBudget)
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+
+// DrainNode stops new requests from being scheduled on a node until it is
+// undrained. Requests already running on it are not interrupted. A node may
+// be drained before the scheduler has discovered it.
+func (e *Engine) DrainNode(nodeID string) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	if e.drained == nil {
+		e.drained = make(map[string]bool)
+	}
+	e.drained[nodeID] = true
+	if node, exists := e.nodes[nodeID]; exists && node.Status == NodeStatusOnline {
+		node.Status = NodeStatusDraining
+		e.publishNode(types.NodeUpdated, node)
+	}
+}
+
+// UndrainNode lets requests be scheduled on a drained node again
+func (e *Engine) UndrainNode(nodeID string) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	if !e.drained[nodeID] {
+		return
+	}
+	delete(e.drained, nodeID)
+	if node, exists := e.nodes[nodeID]; exists && node.Status == NodeStatusDraining {
+		node.Status = NodeStatusOnline
+		e.publishNode(types.NodeUpdated, node)
+	}
+}
+
+// IsDrained reports whether a node has been drained
+func (e *Engine) IsDrained(nodeID string) bool {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	return e.drained[nodeID]
+}
+
+// onlineStatus is the status of a reachable node: quarantined if it was
+// quarantined, draining if it was drained, online otherwise. The caller
+// holds nodesMu.
+func (e *Engine) onlineStatus(nodeID string) NodeStatus {
+	if st := e.flaps[nodeID]; st != nil && st.quarantined {
+		return NodeStatusQuarantined
+	}
+	if e.drained[nodeID] {
+		return NodeStatusDraining
+	}
+	return NodeStatusOnline
+}
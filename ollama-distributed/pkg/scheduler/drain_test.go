@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainNodeStopsScheduling(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+
+	e.DrainNode("b")
+	assert.True(t, e.IsDrained("b"))
+
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", node.ID)
+
+	// A successful health check keeps the node draining
+	e.nodesMu.Lock()
+	e.nodes["b"].Status = e.onlineStatus("b")
+	e.nodesMu.Unlock()
+	assert.Equal(t, NodeStatusDraining, e.GetNodes()["b"].Status)
+
+	e.UndrainNode("b")
+	assert.False(t, e.IsDrained("b"))
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", node.ID)
+}
+
+func TestUndrainLeavesUnhealthyNodesAlone(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+
+	// d is draining because its health checks fail, not because it was drained
+	e.UndrainNode("d")
+	assert.Equal(t, NodeStatusDraining, e.GetNodes()["d"].Status)
+}
@@ -3,12 +3,20 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/consensus"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/p2p/resources"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/queue"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/timesync"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -24,10 +32,41 @@ type Engine struct {
 
 	// Node registry
 	nodes   map[string]*NodeInfo
-	nodesMu sync.RWMutex
-
-	// Request queue
-	requests chan *Request
+	drained map[string]bool // nodes taking no new requests, guarded by nodesMu
+	// inFlight counts the requests sent to each node and not answered
+	// yet, guarded by nodesMu
+	inFlight map[string]int
+	nodesMu  sync.RWMutex
+
+	// Failures and recoveries of nodes, and the quarantine of flapping
+	// ones, guarded by nodesMu
+	flaps              map[string]*flapState
+	quarantineObserver func(FlapStatus)
+
+	// detector judges nodes by their heartbeats instead of health checks,
+	// guarded by nodesMu
+	detector types.FailureDetector
+
+	// nodeEvents tells ClusterView subscribers about node changes
+	nodeEvents types.NodeEvents
+
+	// skewThreshold is the clock skew of a node warned about, guarded by
+	// nodesMu
+	skewThreshold time.Duration
+
+	// Node labels and taints, and the placement rules applied to them
+	labels      *placement.Registry
+	placement   *placement.Policy
+	disk        *placement.DiskScheduler
+	quarantined func(nodeID string) bool // misbehaving nodes, deprioritized
+	placementMu sync.RWMutex
+
+	// Request queue slots; requests wait in the per-worker queues, or in
+	// the fair queue when fair-share scheduling is enabled
+	slots      chan struct{}
+	nextWorker atomic.Uint64
+	fair       *fairQueue
+	shared     *sharedQueue // requests wait in Redis or NATS instead
 
 	// Workers
 	workers   []*Worker
@@ -44,6 +83,10 @@ type Engine struct {
 	statsMu   sync.RWMutex
 	startTime time.Time
 
+	// usageObserver is told the model and queue time of each request,
+	// guarded by statsMu
+	usageObserver func(model string, queueTime time.Duration)
+
 	started bool
 	mu      sync.RWMutex
 
@@ -51,55 +94,24 @@ type Engine struct {
 	cancel context.CancelFunc
 }
 
-// ModelInfo represents information about a model
-type ModelInfo struct {
-	Name         string            `json:"name"`
-	Size         int64             `json:"size"`
-	Checksum     string            `json:"checksum"`
-	Locations    []string          `json:"locations"` // Node IDs that have this model
-	AccessCount  int64             `json:"access_count"`
-	LastAccessed time.Time         `json:"last_accessed"`
-	Metadata     map[string]string `json:"metadata"`
-}
-
-// NodeInfo represents information about a node
-type NodeInfo struct {
-	ID       string            `json:"id"`
-	Address  string            `json:"address"`
-	Status   NodeStatus        `json:"status"`
-	Capacity NodeCapacity      `json:"capacity"`
-	Usage    NodeUsage         `json:"usage"`
-	Models   []string          `json:"models"`
-	LastSeen time.Time         `json:"last_seen"`
-	Metadata map[string]string `json:"metadata"`
-}
-
-// NodeStatus represents the status of a node
-type NodeStatus string
+// Node and model information, shared with the inference engine and the
+// model manager
+type (
+	ModelInfo    = types.ModelInfo
+	NodeInfo     = types.NodeInfo
+	NodeStatus   = types.NodeStatus
+	NodeCapacity = types.NodeCapacity
+	NodeUsage    = types.NodeUsage
+)
 
 const (
-	NodeStatusOnline      NodeStatus = "online"
-	NodeStatusOffline     NodeStatus = "offline"
-	NodeStatusDraining    NodeStatus = "draining"
-	NodeStatusMaintenance NodeStatus = "maintenance"
+	NodeStatusOnline      = types.NodeStatusOnline
+	NodeStatusOffline     = types.NodeStatusOffline
+	NodeStatusDraining    = types.NodeStatusDraining
+	NodeStatusMaintenance = types.NodeStatusMaintenance
+	NodeStatusQuarantined = types.NodeStatusQuarantined
 )
 
-// NodeCapacity represents the capacity of a node
-type NodeCapacity struct {
-	CPU    int64 `json:"cpu"`    // CPU cores
-	Memory int64 `json:"memory"` // Memory in bytes
-	Disk   int64 `json:"disk"`   // Disk space in bytes
-	GPU    int64 `json:"gpu"`    // GPU count
-}
-
-// NodeUsage represents the current usage of a node
-type NodeUsage struct {
-	CPU    float64 `json:"cpu"`    // CPU usage percentage
-	Memory float64 `json:"memory"` // Memory usage percentage
-	Disk   float64 `json:"disk"`   // Disk usage percentage
-	GPU    float64 `json:"gpu"`    // GPU usage percentage
-}
-
 // Request represents a request for model inference
 type Request struct {
 	ID        string                 `json:"id"`
@@ -110,8 +122,24 @@ type Request struct {
 	Metadata  map[string]string      `json:"metadata"`
 	Payload   map[string]interface{} `json:"payload"`
 
+	// Tolerations let the request onto nodes with matching taints
+	Tolerations []placement.Toleration `json:"tolerations,omitempty"`
+	// NodeSelector confines the request to nodes with all of these labels
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// Tenant the request is scheduled for by fair-share scheduling
+	Tenant string `json:"tenant,omitempty"`
+	// Strategy overrides the configured load balancing algorithm
+	Strategy string `json:"strategy,omitempty"`
+	// Context records how the context the request asked for was
+	// negotiated, when its node could not hold it
+	Context *ContextNegotiation `json:"context,omitempty"`
+
 	// Response channel
-	ResponseCh chan *Response
+	ResponseCh chan *Response `json:"-"`
+
+	// delivery is acknowledged once a request taken from the shared
+	// queue has been processed
+	delivery *queue.Delivery
 
 	// Timing
 	CreatedAt   time.Time `json:"created_at"`
@@ -119,6 +147,15 @@ type Request struct {
 	CompletedAt time.Time `json:"completed_at"`
 }
 
+// CorrelationID returns the ID the request was received with at the
+// gateway, or its scheduler ID when it has none
+func (r *Request) CorrelationID() string {
+	if id := r.Metadata["request_id"]; id != "" {
+		return id
+	}
+	return r.ID
+}
+
 // Response represents a response to a request
 type Response struct {
 	RequestID string        `json:"request_id"`
@@ -127,22 +164,28 @@ type Response struct {
 	Error     string        `json:"error,omitempty"`
 	Data      interface{}   `json:"data,omitempty"`
 	Duration  time.Duration `json:"duration"`
+
+	// Context is the context negotiation of the request, if any
+	Context *ContextNegotiation `json:"context,omitempty"`
 }
 
 // Stats represents scheduler statistics
 type Stats struct {
-	TotalRequests     int64         `json:"total_requests"`
-	CompletedRequests int64         `json:"completed_requests"`
-	FailedRequests    int64         `json:"failed_requests"`
-	QueuedRequests    int64         `json:"queued_requests"`
-	AverageLatency    time.Duration `json:"average_latency"`
-	NodesTotal        int           `json:"nodes_total"`
-	NodesOnline       int           `json:"nodes_online"`
-	NodesOffline      int           `json:"nodes_offline"`
-	ModelsTotal       int           `json:"models_total"`
-	WorkersActive     int           `json:"workers_active"`
-	Uptime            time.Duration `json:"uptime"`
-	LastUpdated       time.Time     `json:"last_updated"`
+	TotalRequests     int64              `json:"total_requests"`
+	CompletedRequests int64              `json:"completed_requests"`
+	FailedRequests    int64              `json:"failed_requests"`
+	QueuedRequests    int64              `json:"queued_requests"`
+	AverageLatency    time.Duration      `json:"average_latency"`
+	NodesTotal        int                `json:"nodes_total"`
+	NodesOnline       int                `json:"nodes_online"`
+	NodesOffline      int                `json:"nodes_offline"`
+	ModelsTotal       int                `json:"models_total"`
+	WorkersActive     int                `json:"workers_active"`
+	StolenRequests    int64              `json:"stolen_requests"`
+	WorkerQueues      []WorkerQueueStats `json:"worker_queues"`
+	FairShares        []TenantShare      `json:"fair_shares,omitempty"`
+	Uptime            time.Duration      `json:"uptime"`
+	LastUpdated       time.Time          `json:"last_updated"`
 }
 
 // Worker represents a worker that processes requests
@@ -150,6 +193,14 @@ type Worker struct {
 	ID     int
 	engine *Engine
 	stopCh chan struct{}
+
+	// Work stealing
+	queue      workDeque
+	notify     chan struct{}
+	idle       atomic.Bool
+	processed  atomic.Int64
+	stolen     atomic.Int64
+	stolenFrom atomic.Int64
 }
 
 // HealthChecker monitors node health
@@ -163,6 +214,9 @@ type HealthChecker struct {
 type LoadBalancer struct {
 	algorithm string
 	engine    *Engine
+	rrState   RoundRobinState
+	rng       *rand.Rand // seeded in deterministic mode
+	rngMu     sync.Mutex
 }
 
 // NewEngine creates a new scheduling engine
@@ -175,11 +229,16 @@ func NewEngine(config *config.SchedulerConfig, p2pNode *p2p.Node, consensusEngin
 		consensus: consensusEngine,
 		models:    make(map[string]*ModelInfo),
 		nodes:     make(map[string]*NodeInfo),
-		requests:  make(chan *Request, config.QueueSize),
+		drained:   make(map[string]bool),
+		inFlight:  make(map[string]int),
+		labels:    placement.NewRegistry(),
+		slots:     make(chan struct{}, config.QueueSize),
 		stats:     &Stats{LastUpdated: time.Now()},
 		startTime: time.Now(),
 		ctx:       ctx,
 		cancel:    cancel,
+
+		skewThreshold: timesync.DefaultWarnThreshold,
 	}
 
 	// Initialize health checker
@@ -194,6 +253,24 @@ func NewEngine(config *config.SchedulerConfig, p2pNode *p2p.Node, consensusEngin
 		algorithm: config.LoadBalancing,
 		engine:    engine,
 	}
+	if config.Deterministic {
+		engine.loadBalancer.rng = rand.New(rand.NewSource(config.Seed))
+	}
+
+	if config.FairShare.Enabled {
+		engine.fair = newFairQueue(config.FairShare)
+	}
+
+	var nodeID string
+	if p2pNode != nil {
+		nodeID = p2pNode.ID().String()
+	}
+	shared, err := newSharedQueue(config, queueConsumer(nodeID))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	engine.shared = shared
 
 	// Create workers
 	engine.workers = make([]*Worker, config.WorkerCount)
@@ -202,6 +279,7 @@ func NewEngine(config *config.SchedulerConfig, p2pNode *p2p.Node, consensusEngin
 			ID:     i,
 			engine: engine,
 			stopCh: make(chan struct{}),
+			notify: make(chan struct{}, 1),
 		}
 	}
 
@@ -221,6 +299,9 @@ func (e *Engine) Start() error {
 	for _, worker := range e.workers {
 		go worker.start()
 	}
+	if e.shared != nil {
+		go e.consumeSharedQueue()
+	}
 
 	// Start health checker
 	go e.healthChecker.start()
@@ -228,6 +309,11 @@ func (e *Engine) Start() error {
 	// Start node discovery
 	go e.discoverNodes()
 
+	// Track the resource usage peers advertise
+	if e.p2p != nil {
+		e.p2p.SubscribeResourceMetrics(e.updateNodeResources)
+	}
+
 	// Start model registry sync
 	go e.syncModelRegistry()
 
@@ -235,6 +321,43 @@ func (e *Engine) Start() error {
 	return nil
 }
 
+// updateNodeResources applies resource metrics advertised by a peer to its
+// node entry
+func (e *Engine) updateNodeResources(nodeID string, metrics *resources.ResourceMetrics) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	node, exists := e.nodes[nodeID]
+	if !exists {
+		return
+	}
+
+	node.Usage.CPU = metrics.CPUUsage
+	if metrics.MemoryTotal > 0 {
+		node.Capacity.Memory = metrics.MemoryTotal
+		node.Usage.Memory = float64(metrics.MemoryUsage) / float64(metrics.MemoryTotal) * 100
+	}
+	if metrics.DiskTotal > 0 {
+		node.Capacity.Disk = metrics.DiskTotal
+		node.Usage.Disk = float64(metrics.DiskUsage) / float64(metrics.DiskTotal) * 100
+	}
+	if len(metrics.GPUUsage) > 0 {
+		var total float64
+		for _, usage := range metrics.GPUUsage {
+			total += usage
+		}
+		node.Capacity.GPU = int64(len(metrics.GPUUsage))
+		node.Usage.GPU = total / float64(len(metrics.GPUUsage))
+	}
+	node.Disk = placement.DiskStats{
+		ReadRate:  metrics.DiskReadRate,
+		WriteRate: metrics.DiskWriteRate,
+		Latency:   metrics.DiskLatency,
+	}
+	node.LastSeen = time.Now()
+	e.publishNode(types.NodeUpdated, node)
+}
+
 // discoverNodes discovers nodes in the network
 func (e *Engine) discoverNodes() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -245,6 +368,7 @@ func (e *Engine) discoverNodes() {
 		case <-e.ctx.Done():
 			return
 		case <-ticker.C:
+			e.releaseQuarantines(time.Now())
 			e.updateNodeRegistry()
 		}
 	}
@@ -278,9 +402,19 @@ func (e *Engine) updateNodeRegistry() {
 		nodeID := peerID.String()
 
 		if node, exists := e.nodes[nodeID]; exists {
-			// Update existing node
-			node.Status = NodeStatusOnline
+			// Update existing node; suspected nodes stay failed until
+			// their heartbeats resume
 			node.LastSeen = time.Now()
+			if node.Suspected {
+				continue
+			}
+			e.nodeUp(nodeID, time.Now())
+			status := e.onlineStatus(nodeID)
+			changed := node.Status != status
+			node.Status = status
+			if changed {
+				e.publishNode(types.NodeUpdated, node)
+			}
 		} else {
 			// Add new node with safe address handling
 			var address string
@@ -308,20 +442,23 @@ func (e *Engine) updateNodeRegistry() {
 			e.nodes[nodeID] = &NodeInfo{
 				ID:       nodeID,
 				Address:  address,
-				Status:   NodeStatusOnline,
+				Status:   e.onlineStatus(nodeID),
 				Capacity: capacity,
 				Usage:    usage,
 				Models:   []string{},
 				LastSeen: time.Now(),
 				Metadata: make(map[string]string),
 			}
+			e.publishNode(types.NodeJoined, e.nodes[nodeID])
 		}
 	}
 
 	// Mark offline nodes
 	for _, node := range e.nodes {
-		if time.Since(node.LastSeen) > 5*time.Minute {
+		if time.Since(node.LastSeen) > 5*time.Minute && node.Status != NodeStatusOffline {
+			e.nodeDown(node.ID)
 			node.Status = NodeStatusOffline
+			e.publishNode(types.NodeUpdated, node)
 		}
 	}
 }
@@ -344,7 +481,8 @@ func (e *Engine) syncModelRegistry() {
 // syncModels syncs model information with consensus
 func (e *Engine) syncModels() {
 	// Get model registry from consensus
-	if registry, exists := e.consensus.Get("model_registry"); exists {
+	registry, exists := e.consensus.Get("model_registry")
+	if exists {
 		if models, ok := registry.(map[string]*ModelInfo); ok {
 			e.modelsMu.Lock()
 			e.models = models
@@ -361,16 +499,34 @@ func (e *Engine) syncModels() {
 		}
 		e.modelsMu.RUnlock()
 
-		e.consensus.Apply("model_registry", models, nil)
+		// Replace only the registry read above, so that a change applied in
+		// between is picked up by the next sync instead of overwritten
+		if !exists {
+			registry = nil
+		}
+		e.consensus.CompareAndSwap("model_registry", registry, models)
 	}
+
+	e.syncNodeLabels()
 }
 
 // Schedule schedules a request for execution
 func (e *Engine) Schedule(req *Request) error {
 	req.CreatedAt = time.Now()
 
+	if len(e.workers) == 0 {
+		return fmt.Errorf("no scheduler workers")
+	}
+
+	if e.shared != nil {
+		ctx, cancel := context.WithTimeout(e.ctx, 5*time.Second)
+		defer cancel()
+		return e.shared.push(ctx, req)
+	}
+
 	select {
-	case e.requests <- req:
+	case e.slots <- struct{}{}:
+		e.enqueue(req)
 		return nil
 	case <-time.After(5 * time.Second):
 		return fmt.Errorf("request queue full")
@@ -483,6 +639,7 @@ func (e *Engine) AddTestNode(node *NodeInfo) {
 		e.nodes = make(map[string]*NodeInfo)
 	}
 	e.nodes[node.ID] = node
+	e.publishNode(types.NodeJoined, node)
 }
 
 // GetAvailableNodes returns nodes that are online and available
@@ -497,6 +654,12 @@ func (e *Engine) GetAvailableNodes() []*NodeInfo {
 		}
 	}
 
+	if e.config.Deterministic {
+		sort.SliceStable(available, func(i, j int) bool {
+			return available[i].ID < available[j].ID
+		})
+	}
+
 	return available
 }
 
@@ -539,7 +702,14 @@ func (e *Engine) GetStats() *Stats {
 	e.stats.NodesOffline = nodesTotal - nodesOnline
 	e.stats.ModelsTotal = modelsTotal
 	e.stats.WorkersActive = len(e.workers)
-	e.stats.QueuedRequests = int64(len(e.requests))
+	e.stats.QueuedRequests = int64(len(e.slots))
+	e.stats.StolenRequests = 0
+	e.stats.WorkerQueues = make([]WorkerQueueStats, len(e.workers))
+	for i, worker := range e.workers {
+		e.stats.WorkerQueues[i] = worker.queueStats()
+		e.stats.StolenRequests += e.stats.WorkerQueues[i].Stolen
+	}
+	e.stats.FairShares = e.FairShares()
 	e.stats.Uptime = time.Since(e.startTime)
 	e.stats.LastUpdated = time.Now()
 
@@ -555,6 +725,9 @@ func (e *Engine) GetStats() *Stats {
 		NodesOffline:      e.stats.NodesOffline,
 		ModelsTotal:       e.stats.ModelsTotal,
 		WorkersActive:     e.stats.WorkersActive,
+		StolenRequests:    e.stats.StolenRequests,
+		WorkerQueues:      e.stats.WorkerQueues,
+		FairShares:        e.stats.FairShares,
 		Uptime:            e.stats.Uptime,
 		LastUpdated:       e.stats.LastUpdated,
 	}
@@ -581,7 +754,7 @@ func (e *Engine) IsHealthy() bool {
 	}
 
 	// Check if request queue is not completely full
-	if len(e.requests) >= cap(e.requests) {
+	if len(e.slots) >= cap(e.slots) {
 		return false
 	}
 
@@ -608,6 +781,10 @@ func (e *Engine) Shutdown(ctx context.Context) error {
 	// Cancel context
 	e.cancel()
 
+	if e.shared != nil {
+		e.shared.backend.Close()
+	}
+
 	e.started = false
 	return nil
 }
@@ -617,11 +794,36 @@ func (e *Engine) Shutdown(ctx context.Context) error {
 // start starts the worker
 func (w *Worker) start() {
 	for {
+		req := w.next()
+		if req == nil {
+			// Mark the worker idle before looking again so a request
+			// enqueued in between either is found or wakes it
+			w.idle.Store(true)
+			if req = w.next(); req == nil {
+				select {
+				case <-w.stopCh:
+					return
+				case <-w.notify:
+				}
+			}
+			w.idle.Store(false)
+			if req == nil {
+				continue
+			}
+		}
+
+		<-w.engine.slots
 		select {
 		case <-w.stopCh:
 			return
-		case req := <-w.engine.requests:
-			w.processRequest(req)
+		default:
+		}
+
+		w.processed.Add(1)
+		w.processRequest(req)
+		req.ack()
+		if w.engine.fair != nil {
+			w.engine.fair.done(req)
 		}
 	}
 }
@@ -629,6 +831,7 @@ func (w *Worker) start() {
 // processRequest processes a single request
 func (w *Worker) processRequest(req *Request) {
 	req.ScheduledAt = time.Now()
+	w.engine.observeUsage(req)
 
 	// Find the best node for this request
 	node, err := w.engine.loadBalancer.SelectNode(req)
@@ -637,7 +840,7 @@ func (w *Worker) processRequest(req *Request) {
 			RequestID: req.ID,
 			Success:   false,
 			Error:     fmt.Sprintf("failed to select node: %v", err),
-			Duration:  time.Since(req.CreatedAt),
+			Duration:  timesync.Since(req.CreatedAt),
 		})
 		return
 	}
@@ -650,6 +853,8 @@ func (w *Worker) processRequest(req *Request) {
 // executeRequest executes a request on a specific node
 func (w *Worker) executeRequest(req *Request, node *NodeInfo) *Response {
 	start := time.Now()
+	w.engine.trackInFlight(node.ID, 1)
+	defer w.engine.trackInFlight(node.ID, -1)
 
 	// Execute request via P2P communication
 	ctx, cancel := context.WithTimeout(context.Background(), req.Timeout)
@@ -658,12 +863,16 @@ func (w *Worker) executeRequest(req *Request, node *NodeInfo) *Response {
 	// Prepare request payload
 	payload := map[string]interface{}{
 		"id":         req.ID,
+		"request_id": req.CorrelationID(),
 		"model_name": req.ModelName,
 		"type":       req.Type,
 		"priority":   req.Priority,
 		"payload":    req.Payload,
 		"created_at": req.CreatedAt,
 	}
+	if req.Context != nil {
+		payload["context"] = req.Context
+	}
 
 	// Send request to node via P2P
 	responseData, err := w.sendP2PRequest(ctx, node, payload)
@@ -712,9 +921,24 @@ func (w *Worker) executeRequest(req *Request, node *NodeInfo) *Response {
 	}
 }
 
+// trackInFlight adds delta to the requests in flight to a node
+func (e *Engine) trackInFlight(nodeID string, delta int) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	if e.inFlight == nil {
+		e.inFlight = make(map[string]int)
+	}
+	e.inFlight[nodeID] += delta
+	if e.inFlight[nodeID] <= 0 {
+		delete(e.inFlight, nodeID)
+	}
+}
+
 // sendResponse sends a response back to the requester
 func (w *Worker) sendResponse(req *Request, response *Response) {
 	req.CompletedAt = time.Now()
+	response.Context = req.Context
 
 	// Update statistics
 	w.engine.statsMu.Lock()
@@ -734,6 +958,12 @@ func (w *Worker) sendResponse(req *Request, response *Response) {
 	}
 	w.engine.statsMu.Unlock()
 
+	// Requests scheduled on another node and taken from the shared queue
+	// have no one waiting here
+	if req.ResponseCh == nil {
+		return
+	}
+
 	select {
 	case req.ResponseCh <- response:
 	case <-time.After(5 * time.Second):
@@ -758,8 +988,12 @@ func (h *HealthChecker) start() {
 	}
 }
 
-// checkHealth checks the health of all nodes
+// checkHealth checks the health of all nodes, by their suspicion when a
+// failure detector is set and by pinging them otherwise
 func (h *HealthChecker) checkHealth() {
+	if h.engine.suspectNodes(time.Now()) {
+		return
+	}
 	nodes := h.engine.GetAvailableNodes()
 
 	for _, node := range nodes {
@@ -782,17 +1016,20 @@ func (h *HealthChecker) checkNodeHealth(node *NodeInfo) {
 
 	// Attempt to send ping via P2P
 	response, err := h.sendHealthPing(ctx, node, ping)
+	received := time.Now()
 
 	h.engine.nodesMu.Lock()
 	defer h.engine.nodesMu.Unlock()
 
 	if err != nil {
 		// Health check failed
+		h.engine.nodeDown(node.ID)
 		if time.Since(node.LastSeen) > 2*time.Minute {
 			node.Status = NodeStatusOffline
 		} else {
 			node.Status = NodeStatusDraining
 		}
+		h.engine.publishNode(types.NodeUpdated, node)
 		return
 	}
 
@@ -811,6 +1048,8 @@ func (h *HealthChecker) checkNodeHealth(node *NodeInfo) {
 			}
 		}
 
+		h.engine.updateClockSkew(node, start, received, healthData["timestamp"])
+
 		if models, exists := healthData["models"]; exists {
 			if modelSlice, ok := models.([]interface{}); ok {
 				node.Models = make([]string, len(modelSlice))
@@ -824,8 +1063,10 @@ func (h *HealthChecker) checkNodeHealth(node *NodeInfo) {
 	}
 
 	// Health check successful
-	node.Status = NodeStatusOnline
+	h.engine.nodeUp(node.ID, time.Now())
+	node.Status = h.engine.onlineStatus(node.ID)
 	node.LastSeen = time.Now()
+	h.engine.publishNode(types.NodeUpdated, node)
 }
 
 // LoadBalancer methods
@@ -838,30 +1079,57 @@ func (lb *LoadBalancer) SelectNode(req *Request) (*NodeInfo, error) {
 		return nil, fmt.Errorf("no available nodes")
 	}
 
-	// Check if any nodes have the required model
-	var candidateNodes []*NodeInfo
-	for _, node := range nodes {
-		if contains(node.Models, req.ModelName) {
-			candidateNodes = append(candidateNodes, node)
-		}
+	nodes, _ = lb.engine.placementCandidates(req.ModelName, req.Tolerations, req.NodeSelector, nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node satisfies the placement constraints of %s", req.ModelName)
 	}
+	nodes = lb.engine.trustedCandidates(nodes)
 
-	// If no nodes have the model, use all available nodes
-	if len(candidateNodes) == 0 {
-		candidateNodes = nodes
+	candidateNodes, err := lb.engine.negotiateContext(req, candidatesFor(nodes, req.ModelName), nodes)
+	if err != nil {
+		return nil, err
+	}
+	candidateNodes = lb.engine.diskCandidates(req.ModelName, candidateNodes)
+
+	algorithm := lb.algorithm
+	if req.Strategy != "" {
+		algorithm = req.Strategy
 	}
 
 	// Apply load balancing algorithm
-	switch lb.algorithm {
+	var selected *NodeInfo
+	switch algorithm {
 	case "round_robin":
-		return lb.roundRobin(candidateNodes)
+		selected, err = lb.roundRobin(candidateNodes)
 	case "least_connections":
-		return lb.leastConnections(candidateNodes)
+		selected, err = lb.leastConnections(candidateNodes)
 	case "random":
-		return lb.random(candidateNodes)
+		selected, err = lb.random(candidateNodes)
 	default:
-		return lb.roundRobin(candidateNodes)
+		selected, err = lb.roundRobin(candidateNodes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lb.engine.recordLoad(req.ModelName, selected)
+	return selected, nil
+}
+
+// candidatesFor returns the nodes that hold the model, or all nodes when
+// none does
+func candidatesFor(nodes []*NodeInfo, modelName string) []*NodeInfo {
+	var candidateNodes []*NodeInfo
+	for _, node := range nodes {
+		if contains(node.Models, modelName) {
+			candidateNodes = append(candidateNodes, node)
+		}
 	}
+
+	// If no nodes have the model, use all available nodes
+	if len(candidateNodes) == 0 {
+		candidateNodes = nodes
+	}
+	return candidateNodes
 }
 
 // roundRobin implements round-robin load balancing
@@ -872,9 +1140,11 @@ func (lb *LoadBalancer) roundRobin(nodes []*NodeInfo) (*NodeInfo, error) {
 
 	// Get or create round-robin state
 	state := lb.getRoundRobinState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
 
-	// Select next node in rotation
-	currentIndex := state.currentIndex
+	// Select next node in rotation; the node count may have shrunk
+	currentIndex := state.currentIndex % len(nodes)
 	selectedNode := nodes[currentIndex]
 
 	// Update state for next request
@@ -918,9 +1188,16 @@ func (lb *LoadBalancer) random(nodes []*NodeInfo) (*NodeInfo, error) {
 		return nil, fmt.Errorf("no nodes available")
 	}
 
-	// Use current time as seed for randomness
-	seed := time.Now().UnixNano()
-	randomIndex := int(seed % int64(len(nodes)))
+	// Use the seeded source in deterministic mode, else the current time
+	var randomIndex int
+	if lb.rng != nil {
+		lb.rngMu.Lock()
+		randomIndex = lb.rng.Intn(len(nodes))
+		lb.rngMu.Unlock()
+	} else {
+		seed := time.Now().UnixNano()
+		randomIndex = int(seed % int64(len(nodes)))
+	}
 
 	// Ensure index is within bounds
 	if randomIndex < 0 {
@@ -951,11 +1228,10 @@ type RoundRobinState struct {
 	mu           sync.Mutex
 }
 
-var globalRoundRobinState = &RoundRobinState{}
-
-// getRoundRobinState returns the global round-robin state
+// getRoundRobinState returns the round-robin state of this balancer, kept
+// per engine so that one engine's rotation does not depend on another's
 func (lb *LoadBalancer) getRoundRobinState() *RoundRobinState {
-	return globalRoundRobinState
+	return &lb.rrState
 }
 
 // sendP2PRequest sends a request to a node via P2P
@@ -1023,7 +1299,8 @@ func (h *HealthChecker) sendHealthPing(ctx context.Context, node *NodeInfo, ping
 			"disk":   25.8,
 			"gpu":    0.0,
 		},
-		"models": []string{"llama2", "codellama"},
+		"models":    []string{"llama2", "codellama"},
+		"timestamp": time.Now(),
 	}
 
 	return response, nil
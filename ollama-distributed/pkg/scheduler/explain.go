@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// NodeScore is how the load balancer rates one node for a request
+type NodeScore struct {
+	NodeID    string     `json:"node_id"`
+	Address   string     `json:"address"`
+	Status    NodeStatus `json:"status"`
+	HasModel  bool       `json:"has_model"`
+	Load      float64    `json:"load"` // mean CPU and memory usage, in percent
+	Candidate bool       `json:"candidate"`
+	Selected  bool       `json:"selected"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// SelectionExplanation describes the node selection a request would get
+type SelectionExplanation struct {
+	Model        string      `json:"model"`
+	Algorithm    string      `json:"algorithm"`
+	Nodes        []NodeScore `json:"nodes"`
+	SelectedNode string      `json:"selected_node,omitempty"`
+	Note         string      `json:"note,omitempty"`
+}
+
+// ExplainSelection reports which nodes a request for the model would be
+// considered for, how each is rated, and which one the load balancer would
+// pick, without scheduling anything or advancing balancer state
+func (e *Engine) ExplainSelection(modelName string) *SelectionExplanation {
+	return e.ExplainSelectionFor(modelName, nil)
+}
+
+// ExplainSelectionFor is ExplainSelection for a request carrying tolerations
+func (e *Engine) ExplainSelectionFor(modelName string, tolerations []placement.Toleration) *SelectionExplanation {
+	return e.ExplainSelectionIn(modelName, tolerations, nil)
+}
+
+// ExplainSelectionIn is ExplainSelectionFor for a request confined to the
+// nodes with the selector's labels
+func (e *Engine) ExplainSelectionIn(modelName string, tolerations []placement.Toleration, selector map[string]string) *SelectionExplanation {
+	lb := e.loadBalancer
+	explanation := &SelectionExplanation{
+		Model:     modelName,
+		Algorithm: lb.algorithm,
+		Nodes:     make([]NodeScore, 0),
+	}
+	switch explanation.Algorithm {
+	case "round_robin", "least_connections", "random":
+	default:
+		explanation.Algorithm = "round_robin"
+	}
+
+	available := e.GetAvailableNodes()
+	placeable, rejected := e.placementCandidates(modelName, tolerations, selector, available)
+	candidates := candidatesFor(placeable, modelName)
+
+	var selected *NodeInfo
+	switch {
+	case len(candidates) == 0:
+		explanation.Note = "no available nodes"
+	case explanation.Algorithm == "least_connections":
+		selected, _ = lb.leastConnections(candidates)
+	case explanation.Algorithm == "random":
+		explanation.Note = "random selection picks any candidate"
+	default:
+		state := lb.getRoundRobinState()
+		state.mu.Lock()
+		selected = candidates[state.currentIndex%len(candidates)]
+		state.mu.Unlock()
+	}
+	if selected != nil {
+		explanation.SelectedNode = selected.ID
+	}
+
+	isCandidate := make(map[string]bool, len(candidates))
+	for _, node := range candidates {
+		isCandidate[node.ID] = true
+	}
+	anyHasModel := len(candidates) > 0 && contains(candidates[0].Models, modelName)
+
+	nodes := e.GetNodes()
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		node := nodes[id]
+		score := NodeScore{
+			NodeID:    node.ID,
+			Address:   node.Address,
+			Status:    node.Status,
+			HasModel:  contains(node.Models, modelName),
+			Load:      (node.Usage.CPU + node.Usage.Memory) / 2,
+			Candidate: isCandidate[node.ID],
+			Selected:  selected != nil && node.ID == selected.ID,
+		}
+		switch {
+		case node.Status != NodeStatusOnline:
+			score.Reason = "node is " + string(node.Status)
+		case rejected[node.ID] != "":
+			score.Reason = rejected[node.ID]
+		case !score.Candidate && !containsNode(placeable, node.ID):
+			score.Reason = "not preferred by placement rules"
+		case !score.Candidate && anyHasModel:
+			score.Reason = "model not on node"
+		}
+		explanation.Nodes = append(explanation.Nodes, score)
+	}
+
+	return explanation
+}
+
+func containsNode(nodes []*NodeInfo, id string) bool {
+	for _, node := range nodes {
+		if node.ID == id {
+			return true
+		}
+	}
+	return false
+}
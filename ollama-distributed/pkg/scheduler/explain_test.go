@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExplainEngine(t *testing.T, algorithm string) *Engine {
+	e, err := NewEngine(&config.SchedulerConfig{LoadBalancing: algorithm, QueueSize: 10, WorkerCount: 1, Deterministic: true}, nil, nil)
+	require.NoError(t, err)
+
+	e.AddTestNode(&NodeInfo{ID: "a", Status: NodeStatusOnline, Models: []string{"llama3"}, Usage: NodeUsage{CPU: 80, Memory: 60}})
+	e.AddTestNode(&NodeInfo{ID: "b", Status: NodeStatusOnline, Models: []string{"llama3"}, Usage: NodeUsage{CPU: 20, Memory: 40}})
+	e.AddTestNode(&NodeInfo{ID: "c", Status: NodeStatusOnline})
+	e.AddTestNode(&NodeInfo{ID: "d", Status: NodeStatusDraining, Models: []string{"llama3"}})
+	return e
+}
+
+func TestExplainSelectionLeastConnections(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+
+	explanation := e.ExplainSelection("llama3")
+	assert.Equal(t, "least_connections", explanation.Algorithm)
+	assert.Equal(t, "b", explanation.SelectedNode)
+	require.Len(t, explanation.Nodes, 4)
+
+	a, b, c, d := explanation.Nodes[0], explanation.Nodes[1], explanation.Nodes[2], explanation.Nodes[3]
+	assert.True(t, a.Candidate)
+	assert.Equal(t, 70.0, a.Load)
+	assert.True(t, b.Selected)
+	assert.False(t, c.Candidate)
+	assert.Equal(t, "model not on node", c.Reason)
+	assert.False(t, d.Candidate)
+	assert.Equal(t, "node is draining", d.Reason)
+}
+
+func TestExplainSelectionDoesNotAdvanceRoundRobin(t *testing.T) {
+	e := newExplainEngine(t, "round_robin")
+
+	first := e.ExplainSelection("llama3").SelectedNode
+	assert.Equal(t, "a", first)
+	assert.Equal(t, first, e.ExplainSelection("llama3").SelectedNode)
+
+	// Unknown models may run on any available node
+	explanation := e.ExplainSelection("mistral")
+	for _, node := range explanation.Nodes {
+		assert.Equal(t, node.Status == NodeStatusOnline, node.Candidate, node.NodeID)
+	}
+}
+
+func TestSelectNodeStrategyOverride(t *testing.T) {
+	e := newExplainEngine(t, "round_robin")
+
+	for i := 0; i < 2; i++ {
+		node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3", Strategy: "least_connections"})
+		require.NoError(t, err)
+		assert.Equal(t, "b", node.ID, "the request's strategy replaces round robin")
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// SetFailureDetector makes the health checker judge nodes every interval by
+// the suspicion the detector has of them, from their heartbeats, instead of
+// pinging them at the health check interval. Call it before Start.
+func (e *Engine) SetFailureDetector(detector types.FailureDetector, interval time.Duration) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	e.detector = detector
+	if interval > 0 {
+		e.healthChecker.interval = interval
+	}
+}
+
+// suspectNodes updates the suspicion of every node from the failure
+// detector. Suspected nodes fail as nodes failing their health checks do,
+// and recover once their heartbeats resume. It reports false when no
+// detector is set.
+func (e *Engine) suspectNodes(now time.Time) bool {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	if e.detector == nil {
+		return false
+	}
+	for _, node := range e.nodes {
+		suspected := e.detector.Suspected(node.ID)
+		changed := suspected != node.Suspected
+		node.Suspicion = e.detector.Suspicion(node.ID)
+		node.Suspected = suspected
+
+		switch {
+		case suspected && node.Status != NodeStatusOffline:
+			e.nodeDown(node.ID)
+			status := NodeStatusDraining
+			if now.Sub(node.LastSeen) > 2*time.Minute {
+				status = NodeStatusOffline
+			}
+			changed = changed || node.Status != status
+			node.Status = status
+		case !suspected && changed:
+			e.nodeUp(node.ID, now)
+			node.Status = e.onlineStatus(node.ID)
+			node.LastSeen = now
+		}
+		if changed {
+			e.publishNode(types.NodeUpdated, node)
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDetector suspects the listed nodes
+type fakeDetector map[string]float64
+
+func (d fakeDetector) Suspicion(nodeID string) float64 { return d[nodeID] }
+func (d fakeDetector) Suspected(nodeID string) bool    { return d[nodeID] >= 8 }
+
+func TestSuspectedNodesNotScheduled(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	detector := fakeDetector{"a": 0.5, "b": 12}
+	e.SetFailureDetector(detector, 200*time.Millisecond)
+	assert.Equal(t, 200*time.Millisecond, e.healthChecker.interval)
+
+	var events []types.NodeEvent
+	cancel := e.Subscribe(func(event types.NodeEvent) { events = append(events, event) })
+	defer cancel()
+
+	now := time.Now()
+	e.nodesMu.Lock()
+	e.nodes["b"].LastSeen = now
+	e.nodesMu.Unlock()
+	require.True(t, e.suspectNodes(now))
+	b := e.GetNodes()["b"]
+	assert.Equal(t, NodeStatusDraining, b.Status)
+	assert.True(t, b.Suspected)
+	assert.Equal(t, 12.0, b.Suspicion)
+	assert.Equal(t, 0.5, e.GetNodes()["a"].Suspicion)
+	require.Len(t, events, 1)
+	assert.Equal(t, "b", events[0].Node.ID)
+
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", node.ID)
+
+	// Still suspected: nothing changes
+	e.suspectNodes(now.Add(time.Second))
+	assert.Len(t, events, 1)
+
+	// Heartbeats resumed
+	detector["b"] = 0.2
+	e.suspectNodes(now.Add(2 * time.Second))
+	b = e.GetNodes()["b"]
+	assert.Equal(t, NodeStatusOnline, b.Status)
+	assert.False(t, b.Suspected)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(1), e.NodeFlaps("b").TotalFlaps)
+}
+
+func TestSuspicionWithoutDetector(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	assert.False(t, e.suspectNodes(time.Now()))
+}
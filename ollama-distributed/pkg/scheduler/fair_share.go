@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// TenantShare describes a tenant's share of the scheduler
+type TenantShare struct {
+	Tenant     string  `json:"tenant"`
+	Weight     float64 `json:"weight"`
+	Queued     int     `json:"queued"`
+	Running    int64   `json:"running"`
+	Dispatched int64   `json:"dispatched"`
+	// Share is the tenant's fraction of the running requests, and
+	// FairShare the fraction its weight entitles it to among the tenants
+	// with queued or running requests
+	Share     float64 `json:"share"`
+	FairShare float64 `json:"fair_share"`
+}
+
+// fairQueue orders requests by weighted fair queuing across tenants. Each
+// request is tagged with a virtual finish time that advances by 1/weight
+// per request of its tenant, starting no earlier than the current virtual
+// time, so a tenant sending a burst queues behind its own requests while
+// other tenants keep their share.
+type fairQueue struct {
+	mu            sync.Mutex
+	defaultWeight float64
+	weights       map[string]float64
+	virtualTime   float64
+	tenants       map[string]*tenantQueue
+	queued        int
+}
+
+type tenantQueue struct {
+	items      []fairItem
+	lastFinish float64
+	running    int64
+	dispatched int64
+}
+
+type fairItem struct {
+	req           *Request
+	start, finish float64
+}
+
+func newFairQueue(cfg config.FairShareConfig) *fairQueue {
+	q := &fairQueue{
+		defaultWeight: cfg.DefaultWeight,
+		tenants:       make(map[string]*tenantQueue),
+	}
+	if q.defaultWeight <= 0 {
+		q.defaultWeight = 1
+	}
+	q.setWeights(cfg.Weights)
+	return q
+}
+
+// setWeights replaces the tenant weights; tenants without a positive
+// weight get the default one
+func (q *fairQueue) setWeights(weights map[string]float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.weights = make(map[string]float64, len(weights))
+	for tenant, weight := range weights {
+		if weight > 0 {
+			q.weights[tenant] = weight
+		}
+	}
+}
+
+// weight returns a tenant's weight; callers hold q.mu
+func (q *fairQueue) weight(tenant string) float64 {
+	if w, ok := q.weights[tenant]; ok {
+		return w
+	}
+	return q.defaultWeight
+}
+
+// push queues a request behind the earlier requests of its tenant
+func (q *fairQueue) push(req *Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, ok := q.tenants[req.Tenant]
+	if !ok {
+		t = &tenantQueue{}
+		q.tenants[req.Tenant] = t
+	}
+	start := max(q.virtualTime, t.lastFinish)
+	finish := start + 1/q.weight(req.Tenant)
+	t.lastFinish = finish
+	t.items = append(t.items, fairItem{req: req, start: start, finish: finish})
+	q.queued++
+}
+
+// pop removes the request with the earliest virtual finish time, or
+// returns nil when empty. Ties go to the tenant that sorts first.
+func (q *fairQueue) pop() *Request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var next *tenantQueue
+	var nextTenant string
+	for tenant, t := range q.tenants {
+		if len(t.items) == 0 {
+			continue
+		}
+		if next == nil || t.items[0].finish < next.items[0].finish ||
+			(t.items[0].finish == next.items[0].finish && tenant < nextTenant) {
+			next, nextTenant = t, tenant
+		}
+	}
+	if next == nil {
+		return nil
+	}
+
+	item := next.items[0]
+	next.items[0] = fairItem{}
+	next.items = next.items[1:]
+	if len(next.items) == 0 {
+		next.items = nil
+	}
+	q.virtualTime = max(q.virtualTime, item.start)
+	q.queued--
+	next.running++
+	next.dispatched++
+	return item.req
+}
+
+// done records that a request taken from the queue has completed
+func (q *fairQueue) done(req *Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if t, ok := q.tenants[req.Tenant]; ok && t.running > 0 {
+		t.running--
+	}
+}
+
+// len returns the number of queued requests
+func (q *fairQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queued
+}
+
+// shares returns the share of every tenant seen, sorted by tenant
+func (q *fairQueue) shares() []TenantShare {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var running int64
+	var activeWeight float64
+	for tenant, t := range q.tenants {
+		running += t.running
+		if len(t.items) > 0 || t.running > 0 {
+			activeWeight += q.weight(tenant)
+		}
+	}
+
+	shares := make([]TenantShare, 0, len(q.tenants))
+	for tenant, t := range q.tenants {
+		share := TenantShare{
+			Tenant:     tenant,
+			Weight:     q.weight(tenant),
+			Queued:     len(t.items),
+			Running:    t.running,
+			Dispatched: t.dispatched,
+		}
+		if running > 0 {
+			share.Share = float64(t.running) / float64(running)
+		}
+		if activeWeight > 0 && (len(t.items) > 0 || t.running > 0) {
+			share.FairShare = share.Weight / activeWeight
+		}
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Tenant < shares[j].Tenant })
+	return shares
+}
+
+// FairShares returns each tenant's current share of the scheduler, or nil
+// when fair-share scheduling is disabled
+func (e *Engine) FairShares() []TenantShare {
+	if e.fair == nil {
+		return nil
+	}
+	return e.fair.shares()
+}
+
+// SetFairShareWeights replaces the tenant weights of fair-share scheduling.
+// It reports false when fair-share scheduling is disabled.
+func (e *Engine) SetFairShareWeights(weights map[string]float64) bool {
+	if e.fair == nil {
+		return false
+	}
+	e.fair.setWeights(weights)
+	return true
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairQueueInterleavesTenants(t *testing.T) {
+	q := newFairQueue(config.FairShareConfig{Weights: map[string]float64{"gold": 2}})
+
+	// A burst from one tenant queued before anyone else
+	for i := 0; i < 6; i++ {
+		q.push(&Request{ID: fmt.Sprintf("burst-%d", i), Tenant: "bulk"})
+	}
+	for i := 0; i < 2; i++ {
+		q.push(&Request{ID: fmt.Sprintf("small-%d", i), Tenant: "small"})
+	}
+	for i := 0; i < 4; i++ {
+		q.push(&Request{ID: fmt.Sprintf("gold-%d", i), Tenant: "gold"})
+	}
+	assert.Equal(t, 12, q.len())
+
+	var order []string
+	for req := q.pop(); req != nil; req = q.pop() {
+		order = append(order, req.Tenant)
+	}
+	assert.Equal(t, []string{
+		"gold", "bulk", "gold", "small",
+		"gold", "bulk", "gold", "small",
+		"bulk", "bulk", "bulk", "bulk",
+	}, order, "gold gets two requests for every one of the others")
+}
+
+func TestFairQueueNoCreditForIdleTenants(t *testing.T) {
+	q := newFairQueue(config.FairShareConfig{})
+
+	for i := 0; i < 4; i++ {
+		q.push(&Request{Tenant: "busy"})
+	}
+	for i := 0; i < 3; i++ {
+		q.done(q.pop())
+	}
+
+	// A tenant arriving late gets its share from now on, but no credit for
+	// the time it was idle that would let it run a backlog ahead of busy
+	q.push(&Request{Tenant: "late"})
+	q.push(&Request{Tenant: "late"})
+	assert.Equal(t, "late", q.pop().Tenant)
+	assert.Equal(t, "busy", q.pop().Tenant)
+	assert.Equal(t, "late", q.pop().Tenant)
+}
+
+func TestFairShares(t *testing.T) {
+	e, err := NewEngine(&config.SchedulerConfig{
+		QueueSize:   10,
+		WorkerCount: 1,
+		FairShare:   config.FairShareConfig{Enabled: true, Weights: map[string]float64{"a": 3}},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	e.enqueue(&Request{Tenant: "a"})
+	e.enqueue(&Request{Tenant: "b"})
+	e.enqueue(&Request{Tenant: "b"})
+	req := e.workers[0].next()
+	require.Equal(t, "a", req.Tenant)
+
+	shares := e.FairShares()
+	require.Len(t, shares, 2)
+	assert.Equal(t, TenantShare{Tenant: "a", Weight: 3, Running: 1, Dispatched: 1, Share: 1, FairShare: 0.75}, shares[0])
+	assert.Equal(t, TenantShare{Tenant: "b", Weight: 1, Queued: 2, FairShare: 0.25}, shares[1])
+
+	require.True(t, e.SetFairShareWeights(map[string]float64{"b": 1}))
+	assert.Equal(t, 1.0, e.FairShares()[0].Weight)
+}
@@ -212,7 +212,8 @@ func (res *RedundantExecutionStrategy) Recover(ctx context.Context, fault *Fault
 	start := time.Now()
 
 	// For critical faults, execute critical tasks on backup nodes
-	if err := res.executeOnBackupNodes(fault); err != nil {
+	verdict, err := res.executeOnBackupNodes(ctx, fault)
+	if err != nil {
 		return nil, fmt.Errorf("failed to execute on backup nodes: %v", err)
 	}
 
@@ -238,16 +239,32 @@ func (res *RedundantExecutionStrategy) Recover(ctx context.Context, fault *Fault
 		Metadata:   map[string]interface{}{"backup_nodes_used": 2},
 		Timestamp:  time.Now(),
 	}
+	if verdict != nil {
+		result.Metadata["backup_nodes_used"] = verdict.Total
+		result.Metadata["agreeing_nodes"] = verdict.Agreeing
+		result.Metadata["divergent_nodes"] = verdict.Divergent
+	}
 
 	return result, nil
 }
 
-// executeOnBackupNodes executes critical tasks on backup nodes
-func (res *RedundantExecutionStrategy) executeOnBackupNodes(fault *FaultDetection) error {
-	// In a real implementation, this would execute tasks on backup nodes
-	// For now, we'll just log the action
-	fmt.Printf("Executing critical tasks on backup nodes for fault: %s\n", fault.ID)
-	return nil
+// executeOnBackupNodes executes critical tasks on backup nodes and, when
+// the manager has a redundant executor, compares their results
+func (res *RedundantExecutionStrategy) executeOnBackupNodes(ctx context.Context, fault *FaultDetection) (*IntegrityVerdict, error) {
+	res.manager.mu.RLock()
+	executor := res.manager.redundantExecutor
+	res.manager.mu.RUnlock()
+
+	if executor == nil {
+		fmt.Printf("Executing critical tasks on backup nodes for fault: %s\n", fault.ID)
+		return nil, nil
+	}
+
+	results, err := executor(ctx, fault)
+	if err != nil {
+		return nil, err
+	}
+	return res.manager.VerifyResults(fault.Target, results)
 }
 
 // GracefulDegradationStrategy implements graceful degradation for non-critical faults
@@ -0,0 +1,191 @@
+package fault_tolerance
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// SetClusterView gives the fault tolerance, redundancy and self-healing
+// components the scheduler's view of the cluster's nodes. Nodes leaving the
+// cluster or going offline are detected as node failures.
+func (eftm *EnhancedFaultToleranceManager) SetClusterView(view types.ClusterView) {
+	eftm.mu.Lock()
+	defer eftm.mu.Unlock()
+
+	if eftm.cancelClusterEvent != nil {
+		eftm.cancelClusterEvent()
+		eftm.cancelClusterEvent = nil
+	}
+	eftm.cluster = view
+	if view != nil {
+		eftm.cancelClusterEvent = view.Subscribe(eftm.onNodeEvent)
+	}
+	if eftm.redundancyManager != nil {
+		eftm.redundancyManager.setClusterView(view)
+	}
+	if eftm.selfHealer != nil {
+		eftm.selfHealer.SetClusterView(view)
+	}
+}
+
+// ClusterView returns the view of the cluster's nodes, nil if unset
+func (eftm *EnhancedFaultToleranceManager) ClusterView() types.ClusterView {
+	eftm.mu.RLock()
+	defer eftm.mu.RUnlock()
+	return eftm.cluster
+}
+
+// GetAvailableNodes returns the online nodes of the cluster view
+func (eftm *EnhancedFaultToleranceManager) GetAvailableNodes() []*types.NodeInfo {
+	return onlineNodes(eftm.ClusterView())
+}
+
+// onNodeEvent detects the failure of nodes leaving, going offline or
+// suspected by the failure detector
+func (eftm *EnhancedFaultToleranceManager) onNodeEvent(event types.NodeEvent) {
+	if eftm.FaultToleranceManager == nil {
+		return
+	}
+	eftm.FaultToleranceManager.onNodeEvent(event)
+}
+
+// WatchNodes detects the failure of the view's nodes leaving, going offline
+// or suspected by the failure detector, until cancel is called
+func (ftm *FaultToleranceManager) WatchNodes(view types.ClusterView) (cancel func()) {
+	return view.Subscribe(ftm.onNodeEvent)
+}
+
+// onNodeEvent detects node failures. It runs under the view's lock, so the
+// fault is detected asynchronously.
+func (ftm *FaultToleranceManager) onNodeEvent(event types.NodeEvent) {
+	metadata := map[string]interface{}{"last_seen": event.Node.LastSeen}
+	var description string
+	switch {
+	case event.Type == types.NodeLeft:
+		ftm.setSuspected(event.Node.ID, false)
+		description = "node left the cluster"
+	case event.Node.Status == types.NodeStatusOffline:
+		description = "node went offline"
+	case !ftm.setSuspected(event.Node.ID, event.Node.Suspected) && event.Node.Suspected:
+		description = fmt.Sprintf("node suspected to have failed (phi %.1f)", event.Node.Suspicion)
+		metadata["suspicion"] = event.Node.Suspicion
+	default:
+		return
+	}
+	go ftm.DetectFault(FaultTypeNodeFailure, event.Node.ID, description, metadata)
+}
+
+// setSuspected records whether a node is suspected, reporting whether it
+// already was
+func (ftm *FaultToleranceManager) setSuspected(nodeID string, suspected bool) bool {
+	ftm.suspectedMu.Lock()
+	defer ftm.suspectedMu.Unlock()
+
+	was := ftm.suspected[nodeID]
+	switch {
+	case suspected && ftm.suspected == nil:
+		ftm.suspected = map[string]bool{nodeID: true}
+	case suspected:
+		ftm.suspected[nodeID] = true
+	default:
+		delete(ftm.suspected, nodeID)
+	}
+	return was
+}
+
+// setClusterView sets the view replicas are checked against
+func (rm *RedundancyManager) setClusterView(view types.ClusterView) {
+	rm.replicasMu.Lock()
+	defer rm.replicasMu.Unlock()
+	rm.cluster = view
+}
+
+// countReplicas returns the number of active and failed replicas. Replicas
+// on nodes the view does not list online count as failed.
+func (rm *RedundancyManager) countReplicas() (active, failed int) {
+	rm.replicasMu.RLock()
+	defer rm.replicasMu.RUnlock()
+
+	online := make(map[string]bool)
+	for _, node := range onlineNodes(rm.cluster) {
+		online[node.ID] = true
+	}
+	for _, replicas := range rm.replicas {
+		for _, replica := range replicas {
+			switch {
+			case replica.Status == ReplicaStatusFailed:
+				failed++
+			case rm.cluster != nil && !online[replica.NodeID]:
+				failed++
+			case replica.Status == ReplicaStatusActive:
+				active++
+			}
+		}
+	}
+	return active, failed
+}
+
+// SetClusterView makes the engine assess resource usage from the cluster's
+// nodes
+func (she *SelfHealingEngine) SetClusterView(view types.ClusterView) {
+	she.clusterMu.Lock()
+	defer she.clusterMu.Unlock()
+	she.cluster = view
+}
+
+// clusterUsage returns the average cpu, memory, disk and gpu usage ratios of
+// the online nodes, or false without a view or online nodes
+func (she *SelfHealingEngine) clusterUsage() (map[string]float64, bool) {
+	she.clusterMu.RLock()
+	view := she.cluster
+	she.clusterMu.RUnlock()
+
+	nodes := onlineNodes(view)
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	usage := make(map[string]float64)
+	for _, node := range nodes {
+		usage["cpu"] += node.Usage.CPU / 100
+		usage["memory"] += node.Usage.Memory / 100
+		usage["disk"] += node.Usage.Disk / 100
+		usage["gpu"] += node.Usage.GPU / 100
+	}
+	for k := range usage {
+		usage[k] /= float64(len(nodes))
+	}
+	return usage, true
+}
+
+// extractNodeMetrics returns the utilization ratios of a node, and the
+// metadata values that are numbers
+func (fp *FaultPredictorImpl) extractNodeMetrics(node *types.NodeInfo) map[string]float64 {
+	metrics := map[string]float64{
+		"cpu_utilization":    node.Usage.CPU / 100,
+		"memory_utilization": node.Usage.Memory / 100,
+		"disk_utilization":   node.Usage.Disk / 100,
+		"gpu_utilization":    node.Usage.GPU / 100,
+	}
+	for k, v := range node.Metadata {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			metrics[k] = f
+		}
+	}
+	return metrics
+}
+
+// onlineNodes returns the online nodes of a view, none without one
+func onlineNodes(view types.ClusterView) []*types.NodeInfo {
+	if view == nil {
+		return []*types.NodeInfo{}
+	}
+	nodes := []*types.NodeInfo{}
+	for _, node := range view.ListNodes() {
+		if node.Online() {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
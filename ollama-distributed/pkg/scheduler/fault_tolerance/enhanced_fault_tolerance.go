@@ -38,8 +38,10 @@ type EnhancedFaultToleranceManager struct {
 	// Metrics
 	enhancedMetrics *EnhancedFaultToleranceMetrics
 
-	// Node provider callback for accessing cluster nodes without import cycles
-	getNodesFn func() []interface{}
+	// The scheduler's view of the cluster's nodes, and the cancel of the
+	// subscription to its changes
+	cluster            types.ClusterView
+	cancelClusterEvent func()
 
 	// Lifecycle
 	mu      sync.RWMutex
@@ -177,6 +179,7 @@ type RedundancyManager struct {
 	replicationMu    sync.RWMutex
 	learning         bool
 	efficiency       float64
+	cluster          types.ClusterView
 }
 
 // ReplicaInfo represents information about a replica
@@ -685,6 +688,11 @@ func (eftm *EnhancedFaultToleranceManager) Shutdown(ctx context.Context) error {
 	eftm.mu.Lock()
 	defer eftm.mu.Unlock()
 
+	if eftm.cancelClusterEvent != nil {
+		eftm.cancelClusterEvent()
+		eftm.cancelClusterEvent = nil
+	}
+
 	if !eftm.started {
 		return nil
 	}
@@ -822,33 +830,18 @@ func (ca *ConfigAdaptor) adaptConfiguration(fault *FaultDetection) error {
 	return nil
 }
 
-// Additional missing methods for RedundancyManager
+// getActiveReplicaCount returns the number of active replicas on nodes
+// online in the cluster view
 func (rm *RedundancyManager) getActiveReplicaCount() int {
-	return 0
+	active, _ := rm.countReplicas()
+	return active
 }
 
+// getFailedReplicaCount returns the number of failed replicas, and of those
+// on nodes the cluster view has lost or taken offline
 func (rm *RedundancyManager) getFailedReplicaCount() int {
-	return 0
-}
-
-// SetNodeProvider sets a callback used to retrieve available nodes from the scheduler/cluster manager
-func (eftm *EnhancedFaultToleranceManager) SetNodeProvider(getNodes func() []interface{}) {
-	eftm.mu.Lock()
-	defer eftm.mu.Unlock()
-	eftm.getNodesFn = getNodes
-}
-
-// GetAvailableNodes returns available nodes using the configured provider; falls back to empty slice
-func (eftm *EnhancedFaultToleranceManager) GetAvailableNodes() []interface{} {
-	eftm.mu.RLock()
-	provider := eftm.getNodesFn
-	eftm.mu.RUnlock()
-	if provider != nil {
-		if nodes := provider(); nodes != nil {
-			return nodes
-		}
-	}
-	return []interface{}{}
+	_, failed := rm.countReplicas()
+	return failed
 }
 
 // GetFaultDetections returns current detected faults from the base manager
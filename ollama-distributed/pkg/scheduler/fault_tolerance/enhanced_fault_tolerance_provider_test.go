@@ -4,36 +4,121 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 )
 
-// mockNode simulates a minimal node structure compatible with predictive_detection's NodeInfo
-type mockNode struct {
-	ID      string
-	Metrics map[string]interface{}
+// fakeClusterView is a ClusterView over a fixed set of nodes
+type fakeClusterView struct {
+	nodes  []*types.NodeInfo
+	events types.NodeEvents
+}
+
+func (v *fakeClusterView) ListNodes() []*types.NodeInfo { return v.nodes }
+
+func (v *fakeClusterView) GetNode(id string) (*types.NodeInfo, bool) {
+	for _, node := range v.nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return nil, false
 }
 
-// TestEnhancedFTM_NodeProvider_Wiring ensures SetNodeProvider is used by GetAvailableNodes
-func TestEnhancedFTM_NodeProvider_Wiring(t *testing.T) {
+func (v *fakeClusterView) Subscribe(fn func(types.NodeEvent)) func() {
+	return v.events.Subscribe(fn)
+}
+
+// TestEnhancedFTM_ClusterView_Wiring ensures GetAvailableNodes lists the online nodes of the view
+func TestEnhancedFTM_ClusterView_Wiring(t *testing.T) {
 	base := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second})
 	cfg := NewEnhancedFaultToleranceConfig(&Config{HealthCheckInterval: time.Second})
 	eftm := NewEnhancedFaultToleranceManager(cfg, base)
 
-	// Provide two mock nodes via provider
-	provided := []interface{}{
-		&NodeInfo{ID: "node-A", Metrics: map[string]interface{}{"cpu_utilization": 0.5}},
-		map[string]interface{}{"id": "node-B", "cpu_utilization": 0.7},
+	if got := eftm.GetAvailableNodes(); len(got) != 0 {
+		t.Fatalf("expected no nodes without a view, got %d", len(got))
 	}
 
-	eftm.SetNodeProvider(func() []interface{} { return provided })
+	view := &fakeClusterView{nodes: []*types.NodeInfo{
+		{ID: "node-A", Status: types.NodeStatusOnline, Usage: types.NodeUsage{CPU: 50}},
+		{ID: "node-B", Status: types.NodeStatusOnline, Usage: types.NodeUsage{CPU: 70}},
+		{ID: "node-C", Status: types.NodeStatusOffline},
+	}}
+	eftm.SetClusterView(view)
 
 	got := eftm.GetAvailableNodes()
-	if len(got) != len(provided) {
-		t.Fatalf("expected %d nodes from provider, got %d", len(provided), len(got))
+	if len(got) != 2 {
+		t.Fatalf("expected 2 online nodes from the view, got %d", len(got))
+	}
+	if eftm.selfHealer != nil {
+		usage := eftm.selfHealer.getResourceUsage()
+		if usage["cpu"] < 0.59 || usage["cpu"] > 0.61 {
+			t.Fatalf("expected average cpu usage of 0.6, got %v", usage["cpu"])
+		}
+	}
+}
+
+// TestEnhancedFTM_ClusterView_NodeLeft ensures a node leaving the view is detected as a node failure
+func TestEnhancedFTM_ClusterView_NodeLeft(t *testing.T) {
+	base := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second})
+	cfg := NewEnhancedFaultToleranceConfig(&Config{HealthCheckInterval: time.Second})
+	eftm := NewEnhancedFaultToleranceManager(cfg, base)
+
+	view := &fakeClusterView{}
+	eftm.SetClusterView(view)
+
+	view.events.Publish(types.NodeLeft, &types.NodeInfo{ID: "gone", Status: types.NodeStatusOffline})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, fault := range base.GetFaultDetections() {
+			if fault.Target == "gone" && fault.Type == FaultTypeNodeFailure {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected a node failure to be detected for the node that left")
+}
+
+// TestWatchNodes_Suspected ensures a node suspected by the failure detector is detected as a node failure once
+func TestWatchNodes_Suspected(t *testing.T) {
+	ftm := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second})
+	view := &fakeClusterView{}
+	cancel := ftm.WatchNodes(view)
+	defer cancel()
+
+	suspect := &types.NodeInfo{ID: "slow", Status: types.NodeStatusDraining, Suspected: true, Suspicion: 9.5}
+	view.events.Publish(types.NodeUpdated, suspect)
+	view.events.Publish(types.NodeUpdated, suspect)
+
+	deadline := time.Now().Add(time.Second)
+	for len(ftm.GetFaultDetections()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	faults := ftm.GetFaultDetections()
+	if len(faults) != 1 {
+		t.Fatalf("expected one node failure for the suspected node, got %d", len(faults))
+	}
+	if faults[0].Target != "slow" || faults[0].Metadata["suspicion"] != 9.5 {
+		t.Fatalf("expected the suspicion in the fault, got %+v", faults[0])
+	}
+
+	// Once the suspicion clears, a new suspicion is a new fault
+	view.events.Publish(types.NodeUpdated, &types.NodeInfo{ID: "slow", Status: types.NodeStatusOnline})
+	view.events.Publish(types.NodeUpdated, suspect)
+	deadline = time.Now().Add(time.Second)
+	for len(ftm.GetFaultDetections()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(ftm.GetFaultDetections()); got != 2 {
+		t.Fatalf("expected a second node failure, got %d faults", got)
 	}
 }
 
-// TestFaultPredictor_UsesNodeProvider validates predictive path consumes nodes from provider and runs without panic
-func TestFaultPredictor_UsesNodeProvider(t *testing.T) {
+// TestFaultPredictor_UsesClusterView validates predictive path consumes nodes from the view and runs without panic
+func TestFaultPredictor_UsesClusterView(t *testing.T) {
 	base := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Millisecond * 10})
 	cfg := NewEnhancedFaultToleranceConfig(&Config{HealthCheckInterval: time.Millisecond * 10})
 
@@ -41,38 +126,40 @@ func TestFaultPredictor_UsesNodeProvider(t *testing.T) {
 	eftm := NewEnhancedFaultToleranceManager(cfg, base)
 
 	// Inject deterministic nodes with metrics that should trigger some predictions
-	provided := []interface{}{
-		&NodeInfo{ID: "n1", Metrics: map[string]interface{}{
-			"cpu_utilization":     0.95,
-			"memory_utilization":  0.90,
-			"disk_utilization":    0.80,
-			"network_utilization": 0.70,
-			"temperature":         85.0,
-			"error_rate":          0.10,
-			"latency":             250.0,
-			"throughput":          10.0,
-			"active_requests":     150.0,
-			"queued_requests":     100.0,
-			"gpu_utilization":     0.80,
-			"active_processes":    200.0,
-		}},
-		map[string]interface{}{
-			"id":                  "n2",
-			"cpu_utilization":     0.80,
-			"memory_utilization":  0.85,
-			"disk_utilization":    0.70,
-			"network_utilization": 0.60,
-			"temperature":         75.0,
-			"error_rate":          0.05,
-			"latency":             200.0,
-			"throughput":          12.0,
-			"active_requests":     120.0,
-			"queued_requests":     80.0,
-			"gpu_utilization":     0.50,
-			"active_processes":    150.0,
+	view := &fakeClusterView{nodes: []*types.NodeInfo{
+		{
+			ID:     "n1",
+			Status: types.NodeStatusOnline,
+			Usage:  types.NodeUsage{CPU: 95, Memory: 90, Disk: 80, GPU: 80},
+			Metadata: map[string]string{
+				"network_utilization": "0.70",
+				"temperature":         "85",
+				"error_rate":          "0.10",
+				"latency":             "250",
+				"throughput":          "10",
+				"active_requests":     "150",
+				"queued_requests":     "100",
+				"active_processes":    "200",
+			},
 		},
-	}
-	eftm.SetNodeProvider(func() []interface{} { return provided })
+		{
+			ID:     "n2",
+			Status: types.NodeStatusOnline,
+			Usage:  types.NodeUsage{CPU: 80, Memory: 85, Disk: 70, GPU: 50},
+			Metadata: map[string]string{
+				"network_utilization": "0.60",
+				"temperature":         "75",
+				"error_rate":          "0.05",
+				"latency":             "200",
+				"throughput":          "12",
+				"active_requests":     "120",
+				"queued_requests":     "80",
+				"active_processes":    "150",
+				"region":              "us-east",
+			},
+		},
+	}}
+	eftm.SetClusterView(view)
 
 	// Access the predictor via public API on EFTM: Start -> it will spawn predictor loop if learning enabled
 	if err := eftm.Start(); err != nil {
@@ -81,9 +168,8 @@ func TestFaultPredictor_UsesNodeProvider(t *testing.T) {
 	defer eftm.Shutdown(context.Background())
 
 	// Manually invoke a single prediction tick by calling predictFaults through the predictor
-	// We can't call unexported methods directly; instead, simulate by creating a new predictor that uses eftm
 	pred := NewFaultPredictor(cfg, eftm.FaultToleranceManager)
-	// Make sure the predictor points to our eftm so GetAvailableNodes uses our provider
+	// Make sure the predictor points to our eftm so GetAvailableNodes uses our view
 	pred.manager = eftm
 
 	// Lower threshold to ensure we register at least one prediction
@@ -98,3 +184,23 @@ func TestFaultPredictor_UsesNodeProvider(t *testing.T) {
 		t.Fatalf("expected predictions to increase, before=%d after=%d", before, after)
 	}
 }
+
+// TestFaultPredictor_ExtractNodeMetrics ensures usage percentages become ratios and only numeric metadata is kept
+func TestFaultPredictor_ExtractNodeMetrics(t *testing.T) {
+	cfg := NewEnhancedFaultToleranceConfig(&Config{HealthCheckInterval: time.Second})
+	pred := NewFaultPredictor(cfg, NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second}))
+
+	metrics := pred.extractNodeMetrics(&types.NodeInfo{
+		Usage:    types.NodeUsage{CPU: 40, Memory: 60},
+		Metadata: map[string]string{"temperature": "70.5", "region": "eu"},
+	})
+	if metrics["cpu_utilization"] != 0.4 || metrics["memory_utilization"] != 0.6 {
+		t.Fatalf("expected utilization ratios, got %v", metrics)
+	}
+	if metrics["temperature"] != 70.5 {
+		t.Fatalf("expected numeric metadata to be kept, got %v", metrics)
+	}
+	if _, ok := metrics["region"]; ok {
+		t.Fatalf("expected non-numeric metadata to be dropped, got %v", metrics)
+	}
+}
@@ -17,3 +17,8 @@ func (fd *FaultDetector) UpdateFaultStatus(faultID string, status FaultStatus) {
 		}
 	}
 }
+
+// ResolveFault marks a detected fault resolved
+func (ftm *FaultToleranceManager) ResolveFault(faultID string) {
+	ftm.detectionSystem.UpdateFaultStatus(faultID, FaultStatusResolved)
+}
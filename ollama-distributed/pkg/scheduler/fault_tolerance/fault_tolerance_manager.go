@@ -21,6 +21,15 @@ type FaultToleranceManager struct {
 	ctx             context.Context
 	cancel          context.CancelFunc
 	started         bool
+
+	// redundantExecutor runs the redundant executions compared by the
+	// redundant execution strategy
+	redundantExecutor RedundantExecutor
+
+	// suspected are the nodes whose suspicion by the failure detector was
+	// detected as a fault, until it clears
+	suspected   map[string]bool
+	suspectedMu sync.Mutex
 }
 
 // Config holds fault tolerance configuration
@@ -32,6 +41,12 @@ type Config struct {
 	CheckpointInterval    time.Duration `json:"checkpoint_interval"`
 	MaxRetries            int           `json:"max_retries"`
 	RetryBackoff          time.Duration `json:"retry_backoff"`
+	// LogitTolerance is the largest difference between the logits of two
+	// redundant executions still counted as the same result
+	LogitTolerance float64 `json:"logit_tolerance"`
+	// RecoveryApproval is the blast radius from which recoveries wait for
+	// an operator's approval; empty executes them at once
+	RecoveryApproval BlastRadius `json:"recovery_approval"`
 }
 
 // FaultDetector monitors system health and detects faults
@@ -101,6 +116,7 @@ const (
 	FaultTypeResourceExhaustion FaultType = "resource_exhaustion"
 	FaultTypePerformanceAnomaly FaultType = "performance_anomaly"
 	FaultTypeServiceUnavailable FaultType = "service_unavailable"
+	FaultTypeDataIntegrity      FaultType = "data_integrity"
 )
 
 // FaultSeverity represents the severity of a fault
@@ -160,6 +176,12 @@ type RecoveryEngine struct {
 	recoveryQueue   chan *RecoveryRequest
 	recoveryHistory []*RecoveryAttempt
 	historyMu       sync.RWMutex
+
+	// decisions explains the recoveries of faults, kept in decisionOrder
+	// for review and approval
+	decisions     map[string]*plannedRecovery
+	decisionOrder []string
+	decisionsMu   sync.RWMutex
 }
 
 // RecoveryStrategy interface for different recovery strategies
@@ -374,6 +396,13 @@ type FaultToleranceMetrics struct {
 	Uptime               time.Duration `json:"uptime"`
 	LastFault            *time.Time    `json:"last_fault"`
 	LastRecovery         *time.Time    `json:"last_recovery"`
+
+	// Comparisons of redundant executions, those where a node returned a
+	// different result, and those without a majority result
+	IntegrityChecks       int64            `json:"integrity_checks"`
+	ResultDivergences     int64            `json:"result_divergences"`
+	UnresolvedDivergences int64            `json:"unresolved_divergences"`
+	DivergencesByNode     map[string]int64 `json:"divergences_by_node,omitempty"`
 }
 
 // NewFaultToleranceManager creates a new fault tolerance manager
@@ -422,6 +451,7 @@ func (ftm *FaultToleranceManager) initializeComponents() {
 		strategies:      make(map[FaultType][]RecoveryStrategy),
 		recoveryQueue:   make(chan *RecoveryRequest, 100),
 		recoveryHistory: make([]*RecoveryAttempt, 0),
+		decisions:       make(map[string]*plannedRecovery),
 	}
 
 	// Initialize replication manager
@@ -537,10 +567,9 @@ func (fd *FaultDetector) Start(ctx context.Context) error {
 	return nil
 }
 
-// Start method for RecoveryEngine
+// Start processes recovery requests until ctx is done
 func (re *RecoveryEngine) Start(ctx context.Context) error {
-	// Implementation for starting recovery engine
-	slog.Info("recovery engine started")
+	re.start(ctx)
 	return nil
 }
 
@@ -628,9 +657,11 @@ func (ftm *FaultToleranceManager) DetectFault(faultType FaultType, target, descr
 	ftm.detectionSystem.detectionsMu.Unlock()
 
 	// Update metrics
-	ftm.metrics.FaultsDetected++
 	now := time.Now()
+	ftm.mu.Lock()
+	ftm.metrics.FaultsDetected++
 	ftm.metrics.LastFault = &now
+	ftm.mu.Unlock()
 
 	// Create alert
 	alert := &FaultAlert{
@@ -671,6 +702,8 @@ func (ftm *FaultToleranceManager) determineSeverity(faultType FaultType, metadat
 		return FaultSeverityMedium
 	case FaultTypeServiceUnavailable:
 		return FaultSeverityHigh
+	case FaultTypeDataIntegrity:
+		return FaultSeverityHigh
 	default:
 		return FaultSeverityMedium
 	}
@@ -687,6 +720,8 @@ func (ftm *FaultToleranceManager) triggerRecovery(fault *FaultDetection) {
 	select {
 	case ftm.recoveryEngine.recoveryQueue <- recoveryRequest:
 		slog.Debug("recovery request queued", "fault_id", fault.ID)
+	case <-ftm.ctx.Done():
+		slog.Debug("fault tolerance manager shut down, dropping recovery request", "fault_id", fault.ID)
 	case <-time.After(5 * time.Second):
 		slog.Warn("recovery queue full, dropping request", "fault_id", fault.ID)
 	}
@@ -710,8 +745,8 @@ func (ftm *FaultToleranceManager) getPriority(severity FaultSeverity) int {
 
 // GetMetrics returns fault tolerance metrics
 func (ftm *FaultToleranceManager) GetMetrics() *FaultToleranceMetrics {
-	ftm.mu.RLock()
-	defer ftm.mu.RUnlock()
+	ftm.mu.Lock()
+	defer ftm.mu.Unlock()
 
 	// Calculate uptime
 	if ftm.started {
@@ -796,8 +831,7 @@ func (ftm *FaultToleranceManager) shutdownComponents(ctx context.Context) error
 		}
 	}
 
-	// Close recovery queue
-	close(ftm.recoveryEngine.recoveryQueue)
-
+	// The recovery queue is left open: faults detected during shutdown may
+	// still be queued, and the engine stops with the context
 	return nil
 }
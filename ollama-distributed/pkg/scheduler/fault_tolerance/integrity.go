@@ -0,0 +1,166 @@
+package fault_tolerance
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+)
+
+// defaultLogitTolerance is used when the config leaves LogitTolerance unset
+const defaultLogitTolerance = 1e-3
+
+// ErrNoMajority is returned when redundant executions diverge and no result
+// was returned by more than half of them
+var ErrNoMajority = errors.New("redundant executions diverged without a majority result")
+
+// ExecutionResult is the output of one redundant execution of a task
+type ExecutionResult struct {
+	NodeID string    `json:"node_id"`
+	Output []byte    `json:"output"`
+	Logits []float32 `json:"logits,omitempty"`
+}
+
+// RedundantExecutor runs the task affected by a fault on several nodes and
+// returns the result of each execution
+type RedundantExecutor func(ctx context.Context, fault *FaultDetection) ([]ExecutionResult, error)
+
+// IntegrityVerdict is the outcome of comparing redundant executions
+type IntegrityVerdict struct {
+	// Result is the majority result, nil when there is none
+	Result    *ExecutionResult `json:"result,omitempty"`
+	Agreeing  []string         `json:"agreeing"`
+	Divergent []string         `json:"divergent,omitempty"`
+	Total     int              `json:"total"`
+}
+
+// Diverged reports whether any execution returned a different result
+func (v *IntegrityVerdict) Diverged() bool {
+	return len(v.Divergent) > 0
+}
+
+// CompareResults groups the results of redundant executions into those
+// that agree and picks the result returned by more than half of them.
+// Results with logits of the same length agree when no logit differs by
+// more than tolerance; others agree when their outputs hash the same.
+func CompareResults(results []ExecutionResult, tolerance float64) *IntegrityVerdict {
+	type group struct {
+		result ExecutionResult
+		hash   [sha256.Size]byte
+		nodes  []string
+	}
+
+	var groups []*group
+	for _, r := range results {
+		hash := sha256.Sum256(r.Output)
+		var match *group
+		for _, g := range groups {
+			if sameResult(g.result, g.hash, r, hash, tolerance) {
+				match = g
+				break
+			}
+		}
+		if match == nil {
+			match = &group{result: r, hash: hash}
+			groups = append(groups, match)
+		}
+		match.nodes = append(match.nodes, r.NodeID)
+	}
+
+	// Largest group first; ties keep the order results arrived in
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].nodes) > len(groups[j].nodes)
+	})
+
+	verdict := &IntegrityVerdict{Total: len(results)}
+	if len(groups) == 0 {
+		return verdict
+	}
+	if 2*len(groups[0].nodes) > len(results) {
+		result := groups[0].result
+		verdict.Result = &result
+		verdict.Agreeing = groups[0].nodes
+		groups = groups[1:]
+	}
+	for _, g := range groups {
+		verdict.Divergent = append(verdict.Divergent, g.nodes...)
+	}
+	return verdict
+}
+
+// sameResult reports whether two execution results agree
+func sameResult(a ExecutionResult, aHash [sha256.Size]byte, b ExecutionResult, bHash [sha256.Size]byte, tolerance float64) bool {
+	if len(a.Logits) > 0 && len(a.Logits) == len(b.Logits) {
+		for i := range a.Logits {
+			if math.Abs(float64(a.Logits[i])-float64(b.Logits[i])) > tolerance {
+				return false
+			}
+		}
+		return true
+	}
+	return aHash == bHash
+}
+
+// VerifyResults compares the results of redundant executions of a task on
+// target, returning the majority result. Divergent results are reported as
+// a data integrity fault and counted in the metrics; without a majority
+// ErrNoMajority is returned.
+func (ftm *FaultToleranceManager) VerifyResults(target string, results []ExecutionResult) (*IntegrityVerdict, error) {
+	tolerance := ftm.config.LogitTolerance
+	if tolerance <= 0 {
+		tolerance = defaultLogitTolerance
+	}
+	verdict := CompareResults(results, tolerance)
+
+	ftm.mu.Lock()
+	ftm.metrics.IntegrityChecks++
+	if verdict.Diverged() {
+		ftm.metrics.ResultDivergences++
+		if verdict.Result == nil {
+			ftm.metrics.UnresolvedDivergences++
+		}
+		if ftm.metrics.DivergencesByNode == nil {
+			ftm.metrics.DivergencesByNode = make(map[string]int64)
+		}
+		for _, node := range verdict.Divergent {
+			ftm.metrics.DivergencesByNode[node]++
+		}
+	}
+	ftm.mu.Unlock()
+
+	if !verdict.Diverged() {
+		return verdict, nil
+	}
+
+	ftm.DetectFault(FaultTypeDataIntegrity, target,
+		fmt.Sprintf("%d of %d redundant executions returned a different result", len(verdict.Divergent), verdict.Total),
+		map[string]interface{}{
+			"divergent_nodes": verdict.Divergent,
+			"agreeing_nodes":  verdict.Agreeing,
+			"total":           verdict.Total,
+		})
+	if verdict.Result == nil {
+		return verdict, ErrNoMajority
+	}
+	slog.Warn("redundant executions diverged, using the majority result",
+		"target", target,
+		"majority", len(verdict.Agreeing),
+		"divergent_nodes", verdict.Divergent)
+	return verdict, nil
+}
+
+// SetRedundantExecutor sets how the redundant execution strategy runs the
+// tasks of a faulty target on backup nodes. Without one the strategy does
+// not compare results.
+//
+// This is a hook for programs embedding the manager. The node does not set
+// an executor, since its scheduler does not yet run a task on more than one
+// instance, so its redundant execution recoveries compare nothing.
+func (ftm *FaultToleranceManager) SetRedundantExecutor(executor RedundantExecutor) {
+	ftm.mu.Lock()
+	defer ftm.mu.Unlock()
+	ftm.redundantExecutor = executor
+}
@@ -0,0 +1,88 @@
+package fault_tolerance
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCompareResults checks majority selection by output hash and by logits
+func TestCompareResults(t *testing.T) {
+	verdict := CompareResults([]ExecutionResult{
+		{NodeID: "a", Output: []byte("paris")},
+		{NodeID: "b", Output: []byte("lyon")},
+		{NodeID: "c", Output: []byte("paris")},
+	}, 0.01)
+	if verdict.Result == nil || string(verdict.Result.Output) != "paris" {
+		t.Fatalf("expected the majority output, got %+v", verdict.Result)
+	}
+	if !verdict.Diverged() || len(verdict.Divergent) != 1 || verdict.Divergent[0] != "b" {
+		t.Fatalf("expected b to diverge, got %v", verdict.Divergent)
+	}
+
+	// Logits within the tolerance agree even when the sampled text differs
+	verdict = CompareResults([]ExecutionResult{
+		{NodeID: "a", Output: []byte("x"), Logits: []float32{0.1, 0.2}},
+		{NodeID: "b", Output: []byte("y"), Logits: []float32{0.105, 0.2}},
+	}, 0.01)
+	if verdict.Diverged() || len(verdict.Agreeing) != 2 {
+		t.Fatalf("expected logits within tolerance to agree, got %+v", verdict)
+	}
+
+	verdict = CompareResults([]ExecutionResult{
+		{NodeID: "a", Output: []byte("x")},
+		{NodeID: "b", Output: []byte("y")},
+	}, 0.01)
+	if verdict.Result != nil || len(verdict.Divergent) != 2 {
+		t.Fatalf("expected no majority for a tie, got %+v", verdict)
+	}
+}
+
+// TestVerifyResultsReportsDivergence checks the fault and counters recorded
+// for divergent executions
+func TestVerifyResultsReportsDivergence(t *testing.T) {
+	ftm := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second})
+
+	verdict, err := ftm.VerifyResults("task-1", []ExecutionResult{
+		{NodeID: "a", Output: []byte("ok")},
+		{NodeID: "b", Output: []byte("ok")},
+	})
+	if err != nil || verdict.Diverged() {
+		t.Fatalf("expected agreeing results, got %+v, %v", verdict, err)
+	}
+
+	verdict, err = ftm.VerifyResults("task-2", []ExecutionResult{
+		{NodeID: "a", Output: []byte("ok")},
+		{NodeID: "b", Output: []byte("corrupt")},
+		{NodeID: "c", Output: []byte("ok")},
+	})
+	if err != nil || string(verdict.Result.Output) != "ok" {
+		t.Fatalf("expected the majority result, got %+v, %v", verdict, err)
+	}
+
+	_, err = ftm.VerifyResults("task-3", []ExecutionResult{
+		{NodeID: "a", Output: []byte("one")},
+		{NodeID: "b", Output: []byte("two")},
+	})
+	if !errors.Is(err, ErrNoMajority) {
+		t.Fatalf("expected ErrNoMajority, got %v", err)
+	}
+
+	metrics := ftm.GetMetrics()
+	if metrics.IntegrityChecks != 3 || metrics.ResultDivergences != 2 || metrics.UnresolvedDivergences != 1 {
+		t.Fatalf("unexpected integrity counters: %+v", metrics)
+	}
+	if metrics.DivergencesByNode["b"] != 2 || metrics.DivergencesByNode["a"] != 1 {
+		t.Fatalf("unexpected divergences by node: %v", metrics.DivergencesByNode)
+	}
+
+	faults := 0
+	for _, fault := range ftm.GetFaultDetections() {
+		if fault.Type == FaultTypeDataIntegrity {
+			faults++
+		}
+	}
+	if faults != 2 {
+		t.Fatalf("expected 2 data integrity faults, got %d", faults)
+	}
+}
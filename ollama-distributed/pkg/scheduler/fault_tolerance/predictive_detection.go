@@ -12,14 +12,6 @@ import (
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/types"
 )
 
-// NodeInfo represents information about a node (local definition to avoid import conflicts)
-type NodeInfo struct {
-	ID       string                 `json:"id"`
-	Status   string                 `json:"status"`
-	Metrics  map[string]interface{} `json:"metrics"`
-	Metadata map[string]interface{} `json:"metadata"`
-}
-
 // FaultPredictorImpl predicts faults based on system metrics and patterns
 type FaultPredictorImpl struct {
 	manager          *EnhancedFaultToleranceManager
@@ -181,33 +173,11 @@ func (fp *FaultPredictorImpl) predictFaults() {
 	var predictions []*PredictionSampleImpl
 
 	for _, node := range nodes {
-		// Type assert node to get ID and metrics map if available
-		var nodeID string
-		var baseMetrics map[string]float64
-		switch n := node.(type) {
-		case *NodeInfo:
-			nodeID = n.ID
-			baseMetrics = fp.extractNodeMetrics(n)
-		case map[string]interface{}:
-			if idVal, ok := n["id"].(string); ok {
-				nodeID = idVal
-			}
-			baseMetrics = make(map[string]float64)
-			for k, v := range n {
-				if fv, ok := v.(float64); ok {
-					baseMetrics[k] = fv
-				}
-			}
-		default:
-			nodeID = fmt.Sprintf("unknown_%d", time.Now().UnixNano())
-			baseMetrics = fp.extractNodeMetrics(n)
-		}
-
 		// Create prediction sample
 		sample := &PredictionSampleImpl{
 			Timestamp: time.Now(),
-			NodeID:    nodeID,
-			Metrics:   baseMetrics,
+			NodeID:    node.ID,
+			Metrics:   fp.extractNodeMetrics(node),
 			Metadata:  make(map[string]interface{}),
 		}
 
@@ -228,14 +198,14 @@ func (fp *FaultPredictorImpl) predictFaults() {
 				}
 
 				// Detect predicted fault
-				fp.manager.DetectFault(FaultType(prediction), nodeID, description, metadata)
+				fp.manager.DetectFault(FaultType(prediction), node.ID, description, metadata)
 
 				// Add to predictions
 				predictions = append(predictions, sample)
 
 				// Log prediction
 				log.Printf("fault predicted: node_id=%s, fault_type=%s, confidence=%f, timestamp=%v",
-					nodeID, prediction, confidence, sample.Timestamp)
+					node.ID, prediction, confidence, sample.Timestamp)
 			}
 		}
 	}
@@ -355,22 +325,6 @@ func (fp *FaultPredictorImpl) getCurrentSystemState() *types.SystemState {
 	}
 }
 
-// extractNodeMetrics extracts metrics from a node (stub implementation)
-func (fp *FaultPredictorImpl) extractNodeMetrics(node interface{}) map[string]float64 {
-	metrics := make(map[string]float64)
-
-	// Stub implementation - return default metrics
-	metrics["cpu_utilization"] = 0.5
-	metrics["memory_utilization"] = 0.6
-	metrics["disk_utilization"] = 0.3
-	metrics["network_utilization"] = 0.4
-	metrics["performance_score"] = 0.8
-	metrics["health_score"] = 0.9
-	metrics["load_average"] = 1.0
-
-	return metrics
-}
-
 // updateMetrics updates prediction metrics
 func (fp *FaultPredictorImpl) updateMetrics(predictions []*PredictionSampleImpl, duration time.Duration) {
 	fp.mu.Lock()
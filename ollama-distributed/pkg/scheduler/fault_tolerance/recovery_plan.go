@@ -0,0 +1,404 @@
+package fault_tolerance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// BlastRadius is how much of the cluster a recovery action can disrupt
+type BlastRadius string
+
+const (
+	BlastRadiusLow    BlastRadius = "low"
+	BlastRadiusMedium BlastRadius = "medium"
+	BlastRadiusHigh   BlastRadius = "high"
+)
+
+// level orders blast radii, 0 for unknown ones
+func (b BlastRadius) level() int {
+	switch b {
+	case BlastRadiusLow:
+		return 1
+	case BlastRadiusMedium:
+		return 2
+	case BlastRadiusHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ParseBlastRadius parses a blast radius; empty parses as no radius
+func ParseBlastRadius(s string) (BlastRadius, error) {
+	b := BlastRadius(s)
+	if s != "" && b.level() == 0 {
+		return "", fmt.Errorf("unknown blast radius %q, want low, medium or high", s)
+	}
+	return b, nil
+}
+
+// RecoveryImpact is the estimated impact of a recovery strategy
+type RecoveryImpact struct {
+	BlastRadius BlastRadius `json:"blast_radius"`
+	Description string      `json:"description"`
+}
+
+// ImpactEstimator is implemented by recovery strategies estimating their
+// own impact on a fault. Others are estimated from defaultImpacts.
+type ImpactEstimator interface {
+	EstimateImpact(fault *FaultDetection) RecoveryImpact
+}
+
+// defaultImpacts are the impacts of the built-in strategies
+var defaultImpacts = map[string]RecoveryImpact{
+	"request_migration":   {BlastRadiusLow, "moves in-flight requests off the faulty node"},
+	"load_balancing":      {BlastRadiusLow, "shifts load weights between nodes"},
+	"performance_tuning":  {BlastRadiusLow, "retunes the faulty node"},
+	"resource_scaling":    {BlastRadiusLow, "adds resources to the faulty node"},
+	"model_replication":   {BlastRadiusMedium, "copies models to other nodes, using bandwidth and disk"},
+	"partition_tolerance": {BlastRadiusMedium, "splits serving between network partitions"},
+	"redundant_execution": {BlastRadiusMedium, "runs requests on several nodes at once"},
+	"graceful_degradation": {
+		BlastRadiusMedium, "serves reduced functionality until the fault is resolved",
+	},
+	"load_shedding":    {BlastRadiusHigh, "rejects requests to relieve the cluster"},
+	"fast_recovery":    {BlastRadiusHigh, "restores cluster state from the latest checkpoint"},
+	"checkpoint_based": {BlastRadiusHigh, "rolls cluster state back to a checkpoint"},
+}
+
+// estimateImpact returns the impact of recovering from fault with strategy
+func estimateImpact(strategy RecoveryStrategy, fault *FaultDetection) RecoveryImpact {
+	if estimator, ok := strategy.(ImpactEstimator); ok {
+		return estimator.EstimateImpact(fault)
+	}
+	if impact, ok := defaultImpacts[strategy.GetName()]; ok {
+		return impact
+	}
+	return RecoveryImpact{BlastRadius: BlastRadiusMedium, Description: "impact unknown"}
+}
+
+// RecoveryDecisionStatus is where a recovery decision stands
+type RecoveryDecisionStatus string
+
+const (
+	RecoveryDryRun          RecoveryDecisionStatus = "dry_run"
+	RecoveryPendingApproval RecoveryDecisionStatus = "pending_approval"
+	RecoveryApproved        RecoveryDecisionStatus = "approved"
+	RecoveryRejected        RecoveryDecisionStatus = "rejected"
+	RecoveryExecuting       RecoveryDecisionStatus = "executing"
+	RecoverySucceeded       RecoveryDecisionStatus = "succeeded"
+	RecoveryFailed          RecoveryDecisionStatus = "failed"
+	RecoveryNoStrategy      RecoveryDecisionStatus = "no_strategy"
+)
+
+var (
+	// ErrFaultNotFound is returned for faults that were never detected
+	ErrFaultNotFound = errors.New("fault not found")
+	// ErrNotPendingApproval is returned when deciding on a recovery that is
+	// not waiting for approval
+	ErrNotPendingApproval = errors.New("recovery not pending approval")
+)
+
+// maxRecoveryDecisions bounds the decisions kept for review
+const maxRecoveryDecisions = 1000
+
+// RecoveryCandidate is a strategy considered for a fault. Candidates able
+// to handle the fault are ranked from 1, the one tried first.
+type RecoveryCandidate struct {
+	Strategy  string         `json:"strategy"`
+	CanHandle bool           `json:"can_handle"`
+	Rank      int            `json:"rank,omitempty"`
+	Impact    RecoveryImpact `json:"impact"`
+	Reason    string         `json:"reason"`
+}
+
+// RecoveryDecision explains how a fault is recovered from: the candidate
+// strategies, their ranking and why the first was chosen. Decisions on
+// high-blast-radius recoveries may wait for an operator's approval.
+type RecoveryDecision struct {
+	FaultID          string                 `json:"fault_id"`
+	FaultType        FaultType              `json:"fault_type"`
+	Target           string                 `json:"target"`
+	Candidates       []RecoveryCandidate    `json:"candidates"`
+	Chosen           string                 `json:"chosen,omitempty"`
+	Reason           string                 `json:"reason"`
+	RequiresApproval bool                   `json:"requires_approval"`
+	Status           RecoveryDecisionStatus `json:"status"`
+	CreatedAt        time.Time              `json:"created_at"`
+	DecidedBy        string                 `json:"decided_by,omitempty"`
+	DecidedAt        *time.Time             `json:"decided_at,omitempty"`
+	// Executed is the strategy that recovered the fault, or the last one
+	// tried if all failed
+	Executed string          `json:"executed,omitempty"`
+	Result   *RecoveryResult `json:"result,omitempty"`
+}
+
+// plannedRecovery is a recorded decision and the request it is for
+type plannedRecovery struct {
+	decision   *RecoveryDecision
+	request    *RecoveryRequest
+	strategies []RecoveryStrategy
+}
+
+// plan ranks the strategies for a fault. Strategies able to handle it are
+// ranked by blast radius, the least disruptive first, then in the order
+// they were registered.
+func (re *RecoveryEngine) plan(fault *FaultDetection) (*RecoveryDecision, []RecoveryStrategy) {
+	decision := &RecoveryDecision{
+		FaultID:    fault.ID,
+		FaultType:  fault.Type,
+		Target:     fault.Target,
+		Candidates: []RecoveryCandidate{},
+		CreatedAt:  time.Now(),
+	}
+
+	type ranked struct {
+		strategy RecoveryStrategy
+		impact   RecoveryImpact
+	}
+	var handling []ranked
+	for _, strategy := range re.strategies[fault.Type] {
+		impact := estimateImpact(strategy, fault)
+		if strategy.CanHandle(fault) {
+			handling = append(handling, ranked{strategy: strategy, impact: impact})
+			continue
+		}
+		decision.Candidates = append(decision.Candidates, RecoveryCandidate{
+			Strategy: strategy.GetName(),
+			Impact:   impact,
+			Reason:   fmt.Sprintf("cannot handle fault %s on %s", fault.Type, fault.Target),
+		})
+	}
+	sort.SliceStable(handling, func(i, j int) bool {
+		return handling[i].impact.BlastRadius.level() < handling[j].impact.BlastRadius.level()
+	})
+
+	strategies := make([]RecoveryStrategy, 0, len(handling))
+	ranking := make([]RecoveryCandidate, 0, len(handling))
+	for i, h := range handling {
+		candidate := RecoveryCandidate{
+			Strategy:  h.strategy.GetName(),
+			CanHandle: true,
+			Rank:      i + 1,
+			Impact:    h.impact,
+		}
+		if i == 0 {
+			candidate.Reason = "chosen"
+		} else {
+			candidate.Reason = fmt.Sprintf("fallback if %s fails", handling[i-1].strategy.GetName())
+		}
+		strategies = append(strategies, h.strategy)
+		ranking = append(ranking, candidate)
+	}
+	decision.Candidates = append(ranking, decision.Candidates...)
+
+	if len(handling) == 0 {
+		decision.Status = RecoveryNoStrategy
+		decision.Reason = fmt.Sprintf("no strategy can handle %s faults", fault.Type)
+		return decision, nil
+	}
+
+	chosen := handling[0]
+	decision.Chosen = chosen.strategy.GetName()
+	switch {
+	case len(handling) == 1:
+		decision.Reason = fmt.Sprintf("only strategy able to handle %s faults", fault.Type)
+	case chosen.impact.BlastRadius.level() < handling[1].impact.BlastRadius.level():
+		decision.Reason = fmt.Sprintf("lowest blast radius (%s) of %d strategies able to handle %s faults",
+			chosen.impact.BlastRadius, len(handling), fault.Type)
+	default:
+		decision.Reason = fmt.Sprintf("registered first of the strategies with the lowest blast radius (%s) able to handle %s faults",
+			chosen.impact.BlastRadius, fault.Type)
+	}
+
+	threshold := re.manager.recoveryApproval()
+	if threshold != "" && chosen.impact.BlastRadius.level() >= threshold.level() {
+		decision.RequiresApproval = true
+	}
+	return decision, strategies
+}
+
+// record keeps a decision for review, dropping the oldest beyond
+// maxRecoveryDecisions
+func (re *RecoveryEngine) record(planned *plannedRecovery) {
+	re.decisionsMu.Lock()
+	defer re.decisionsMu.Unlock()
+
+	if re.decisions == nil {
+		re.decisions = make(map[string]*plannedRecovery)
+	}
+	if _, exists := re.decisions[planned.decision.FaultID]; !exists {
+		re.decisionOrder = append(re.decisionOrder, planned.decision.FaultID)
+	}
+	re.decisions[planned.decision.FaultID] = planned
+	for len(re.decisionOrder) > maxRecoveryDecisions {
+		delete(re.decisions, re.decisionOrder[0])
+		re.decisionOrder = re.decisionOrder[1:]
+	}
+}
+
+// updateDecision changes a recorded decision under decisionsMu
+func (re *RecoveryEngine) updateDecision(decision *RecoveryDecision, update func(*RecoveryDecision)) {
+	re.decisionsMu.Lock()
+	defer re.decisionsMu.Unlock()
+	update(decision)
+}
+
+// SetRecoveryApproval makes recoveries whose chosen strategy has at least
+// the given blast radius wait for approval. Empty executes every recovery
+// at once.
+func (ftm *FaultToleranceManager) SetRecoveryApproval(radius BlastRadius) {
+	ftm.mu.Lock()
+	defer ftm.mu.Unlock()
+	ftm.config.RecoveryApproval = radius
+}
+
+func (ftm *FaultToleranceManager) recoveryApproval() BlastRadius {
+	ftm.mu.RLock()
+	defer ftm.mu.RUnlock()
+	return ftm.config.RecoveryApproval
+}
+
+// ExplainRecovery returns the decision recorded for a fault, or plans its
+// recovery as a dry run without executing it if none was recorded
+func (ftm *FaultToleranceManager) ExplainRecovery(faultID string) (*RecoveryDecision, error) {
+	re := ftm.recoveryEngine
+	re.decisionsMu.RLock()
+	planned, ok := re.decisions[faultID]
+	if ok {
+		decision := *planned.decision
+		re.decisionsMu.RUnlock()
+		return &decision, nil
+	}
+	re.decisionsMu.RUnlock()
+
+	ftm.detectionSystem.detectionsMu.RLock()
+	fault, ok := ftm.detectionSystem.detections[faultID]
+	var snapshot FaultDetection
+	if ok {
+		snapshot = *fault
+	}
+	ftm.detectionSystem.detectionsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFaultNotFound, faultID)
+	}
+
+	decision, _ := re.plan(&snapshot)
+	if decision.Status == "" {
+		decision.Status = RecoveryDryRun
+	}
+	return decision, nil
+}
+
+// RecoveryDecisions returns the recorded decisions, newest first
+func (ftm *FaultToleranceManager) RecoveryDecisions() []*RecoveryDecision {
+	re := ftm.recoveryEngine
+	re.decisionsMu.RLock()
+	defer re.decisionsMu.RUnlock()
+
+	decisions := make([]*RecoveryDecision, 0, len(re.decisionOrder))
+	for i := len(re.decisionOrder) - 1; i >= 0; i-- {
+		decision := *re.decisions[re.decisionOrder[i]].decision
+		decisions = append(decisions, &decision)
+	}
+	return decisions
+}
+
+// ApproveRecovery executes a recovery waiting for approval
+func (ftm *FaultToleranceManager) ApproveRecovery(faultID, approver string) (*RecoveryDecision, error) {
+	planned, err := ftm.decideRecovery(faultID, approver, RecoveryApproved)
+	if err != nil {
+		return nil, err
+	}
+	go ftm.recoveryEngine.execute(ftm.ctx, planned)
+	return ftm.ExplainRecovery(faultID)
+}
+
+// RejectRecovery drops a recovery waiting for approval, leaving the fault
+// to be handled by hand
+func (ftm *FaultToleranceManager) RejectRecovery(faultID, approver string) (*RecoveryDecision, error) {
+	if _, err := ftm.decideRecovery(faultID, approver, RecoveryRejected); err != nil {
+		return nil, err
+	}
+	return ftm.ExplainRecovery(faultID)
+}
+
+func (ftm *FaultToleranceManager) decideRecovery(faultID, approver string, status RecoveryDecisionStatus) (*plannedRecovery, error) {
+	re := ftm.recoveryEngine
+	re.decisionsMu.Lock()
+	defer re.decisionsMu.Unlock()
+
+	planned, ok := re.decisions[faultID]
+	if !ok {
+		ftm.detectionSystem.detectionsMu.RLock()
+		_, detected := ftm.detectionSystem.detections[faultID]
+		ftm.detectionSystem.detectionsMu.RUnlock()
+		if detected {
+			return nil, fmt.Errorf("%w: %s has no recovery decision", ErrNotPendingApproval, faultID)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrFaultNotFound, faultID)
+	}
+	if planned.decision.Status != RecoveryPendingApproval {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotPendingApproval, faultID, planned.decision.Status)
+	}
+	now := time.Now()
+	planned.decision.Status = status
+	planned.decision.DecidedBy = approver
+	planned.decision.DecidedAt = &now
+
+	slog.Info("recovery decided", "fault_id", faultID, "status", status, "by", approver)
+	return planned, nil
+}
+
+// execute tries the planned strategies in rank order until one succeeds
+func (re *RecoveryEngine) execute(ctx context.Context, planned *plannedRecovery) {
+	re.updateDecision(planned.decision, func(p *RecoveryDecision) { p.Status = RecoveryExecuting })
+	attemptID := fmt.Sprintf("attempt_%d", time.Now().UnixNano())
+	fault := planned.request.Fault
+
+	for _, strategy := range planned.strategies {
+		result, err := strategy.Recover(ctx, fault)
+		if err != nil {
+			slog.Warn("recovery strategy failed",
+				"strategy", strategy.GetName(),
+				"fault_id", fault.ID,
+				"error", err)
+			re.updateDecision(planned.decision, func(p *RecoveryDecision) { p.Executed = strategy.GetName() })
+			continue
+		}
+
+		re.recordAttempt(&RecoveryAttempt{
+			ID:        attemptID,
+			FaultID:   fault.ID,
+			Strategy:  strategy.GetName(),
+			Result:    result,
+			Timestamp: time.Now(),
+		})
+		re.updateDecision(planned.decision, func(p *RecoveryDecision) {
+			p.Executed = strategy.GetName()
+			p.Result = result
+		})
+
+		if result.Successful {
+			re.resolved(fault, result)
+			re.updateDecision(planned.decision, func(p *RecoveryDecision) { p.Status = RecoverySucceeded })
+			slog.Info("recovery successful",
+				"attempt_id", attemptID,
+				"strategy", strategy.GetName(),
+				"fault_id", fault.ID,
+				"duration", result.Duration)
+			return
+		}
+	}
+
+	// All strategies failed
+	slog.Error("all recovery strategies failed", "fault_id", fault.ID)
+	re.updateDecision(planned.decision, func(p *RecoveryDecision) { p.Status = RecoveryFailed })
+
+	// Mark fault as persistent
+	re.manager.detectionSystem.UpdateFaultStatus(fault.ID, FaultStatusPersistent)
+}
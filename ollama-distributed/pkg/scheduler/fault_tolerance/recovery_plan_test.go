@@ -0,0 +1,127 @@
+package fault_tolerance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// drasticStrategy handles every fault, with a high blast radius
+type drasticStrategy struct {
+	recovered chan string
+}
+
+func (d *drasticStrategy) GetName() string                      { return "drastic" }
+func (d *drasticStrategy) CanHandle(fault *FaultDetection) bool { return true }
+func (d *drasticStrategy) EstimateImpact(fault *FaultDetection) RecoveryImpact {
+	return RecoveryImpact{BlastRadius: BlastRadiusHigh, Description: "restarts the cluster"}
+}
+func (d *drasticStrategy) Recover(ctx context.Context, fault *FaultDetection) (*RecoveryResult, error) {
+	d.recovered <- fault.ID
+	return &RecoveryResult{FaultID: fault.ID, Strategy: d.GetName(), Successful: true, Timestamp: time.Now()}, nil
+}
+
+// TestExplainRecovery checks the dry run ranks the node failure strategies by blast radius
+func TestExplainRecovery(t *testing.T) {
+	ftm := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second})
+	fault := ftm.DetectFault(FaultTypeNodeFailure, "node-1", "node unreachable", nil)
+
+	decision, err := ftm.ExplainRecovery(fault.ID)
+	if err != nil {
+		t.Fatalf("ExplainRecovery: %v", err)
+	}
+	if decision.Status != RecoveryDryRun {
+		t.Fatalf("expected a dry run, got %s", decision.Status)
+	}
+	if decision.Chosen != "request_migration" {
+		t.Fatalf("expected the low blast radius request migration to be chosen, got %q (%s)", decision.Chosen, decision.Reason)
+	}
+
+	lastRank := 0
+	for _, candidate := range decision.Candidates {
+		switch {
+		case candidate.Strategy == "graceful_degradation":
+			if candidate.CanHandle || candidate.Rank != 0 {
+				t.Fatalf("graceful degradation only handles medium and low severity faults: %+v", candidate)
+			}
+		case candidate.CanHandle:
+			if candidate.Rank != lastRank+1 {
+				t.Fatalf("expected rank %d, got %+v", lastRank+1, candidate)
+			}
+			lastRank = candidate.Rank
+		}
+	}
+	if lastRank < 3 {
+		t.Fatalf("expected the node failure strategies to be ranked, got %+v", decision.Candidates)
+	}
+
+	if _, err := ftm.ExplainRecovery("missing"); !errors.Is(err, ErrFaultNotFound) {
+		t.Fatalf("expected ErrFaultNotFound, got %v", err)
+	}
+}
+
+// TestRecoveryApproval checks high blast radius recoveries wait for approval
+func TestRecoveryApproval(t *testing.T) {
+	ftm := NewFaultToleranceManager(&Config{HealthCheckInterval: time.Second, RecoveryApproval: BlastRadiusHigh})
+	drastic := &drasticStrategy{recovered: make(chan string, 2)}
+	ftm.recoveryEngine.strategies[FaultTypeDataIntegrity] = []RecoveryStrategy{drastic}
+	ctx := context.Background()
+
+	approved := &FaultDetection{ID: "fault-approved", Type: FaultTypeDataIntegrity, Target: "node-1"}
+	rejected := &FaultDetection{ID: "fault-rejected", Type: FaultTypeDataIntegrity, Target: "node-2"}
+	ftm.recoveryEngine.processRecoveryRequest(ctx, &RecoveryRequest{Fault: approved})
+	ftm.recoveryEngine.processRecoveryRequest(ctx, &RecoveryRequest{Fault: rejected})
+
+	decision, err := ftm.ExplainRecovery(approved.ID)
+	if err != nil {
+		t.Fatalf("ExplainRecovery: %v", err)
+	}
+	if !decision.RequiresApproval || decision.Status != RecoveryPendingApproval {
+		t.Fatalf("expected the recovery to wait for approval, got %+v", decision)
+	}
+	select {
+	case id := <-drastic.recovered:
+		t.Fatalf("recovered %s before approval", id)
+	default:
+	}
+
+	if _, err := ftm.RejectRecovery(rejected.ID, "alice"); err != nil {
+		t.Fatalf("RejectRecovery: %v", err)
+	}
+	if _, err := ftm.ApproveRecovery(rejected.ID, "alice"); !errors.Is(err, ErrNotPendingApproval) {
+		t.Fatalf("expected a rejected recovery not to be approvable, got %v", err)
+	}
+
+	if _, err := ftm.ApproveRecovery(approved.ID, "alice"); err != nil {
+		t.Fatalf("ApproveRecovery: %v", err)
+	}
+	select {
+	case id := <-drastic.recovered:
+		if id != approved.ID {
+			t.Fatalf("recovered %s, expected %s", id, approved.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("approved recovery did not run")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		decision, _ = ftm.ExplainRecovery(approved.ID)
+		if decision.Status == RecoverySucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the recovery to succeed, got %s", decision.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if decision.DecidedBy != "alice" || decision.Executed != "drastic" {
+		t.Fatalf("expected the decision and execution to be recorded, got %+v", decision)
+	}
+
+	decisions := ftm.RecoveryDecisions()
+	if len(decisions) != 2 || decisions[0].FaultID != rejected.ID || decisions[0].Status != RecoveryRejected {
+		t.Fatalf("expected both decisions, newest first, got %+v", decisions)
+	}
+}
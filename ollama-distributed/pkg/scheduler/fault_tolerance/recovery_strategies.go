@@ -2,7 +2,6 @@ package fault_tolerance
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"time"
 )
@@ -320,92 +319,62 @@ func (re *RecoveryEngine) start(ctx context.Context) {
 	}
 }
 
-// processRecoveryRequest processes a recovery request
+// processRecoveryRequest plans the recovery of a fault and executes it,
+// unless its blast radius calls for approval first
 func (re *RecoveryEngine) processRecoveryRequest(ctx context.Context, request *RecoveryRequest) {
-	attemptID := fmt.Sprintf("attempt_%d", time.Now().UnixNano())
-
 	slog.Info("processing recovery request",
-		"attempt_id", attemptID,
 		"fault_id", request.Fault.ID,
 		"fault_type", request.Fault.Type,
 		"priority", request.Priority)
 
-	// Get strategies for this fault type
-	strategies, exists := re.strategies[request.Fault.Type]
-	if !exists {
+	decision, strategies := re.plan(request.Fault)
+	planned := &plannedRecovery{decision: decision, request: request, strategies: strategies}
+	switch {
+	case len(strategies) == 0:
 		slog.Warn("no recovery strategies available", "fault_type", request.Fault.Type)
-		return
+		re.record(planned)
+	case decision.RequiresApproval:
+		decision.Status = RecoveryPendingApproval
+		re.record(planned)
+		slog.Warn("recovery waiting for approval",
+			"fault_id", request.Fault.ID,
+			"strategy", decision.Chosen,
+			"blast_radius", decision.Candidates[0].Impact.BlastRadius)
+	default:
+		decision.Status = RecoveryApproved
+		re.record(planned)
+		re.execute(ctx, planned)
 	}
+}
 
-	// Try each strategy until one succeeds
-	for _, strategy := range strategies {
-		if !strategy.CanHandle(request.Fault) {
-			continue
-		}
-
-		// Attempt recovery
-		result, err := strategy.Recover(ctx, request.Fault)
-		if err != nil {
-			slog.Warn("recovery strategy failed",
-				"strategy", strategy.GetName(),
-				"fault_id", request.Fault.ID,
-				"error", err)
-			continue
-		}
-
-		// Record attempt
-		attempt := &RecoveryAttempt{
-			ID:        attemptID,
-			FaultID:   request.Fault.ID,
-			Strategy:  strategy.GetName(),
-			Result:    result,
-			Timestamp: time.Now(),
-		}
-
-		re.historyMu.Lock()
-		re.recoveryHistory = append(re.recoveryHistory, attempt)
-
-		// Keep only last 1000 attempts
-		if len(re.recoveryHistory) > 1000 {
-			re.recoveryHistory = re.recoveryHistory[len(re.recoveryHistory)-1000:]
-		}
-		re.historyMu.Unlock()
-
-		// Update metrics
-		re.manager.metrics.RecoveryAttempts++
-		if result.Successful {
-			re.manager.metrics.SuccessfulRecoveries++
-			re.manager.metrics.FaultsResolved++
-			now := time.Now()
-			re.manager.metrics.LastRecovery = &now
-
-			// Mark fault as resolved
-			re.manager.detectionSystem.detectionsMu.Lock()
-			if fault, exists := re.manager.detectionSystem.detections[request.Fault.ID]; exists {
-				fault.Status = FaultStatusResolved
-				resolvedAt := time.Now()
-				fault.ResolvedAt = &resolvedAt
-			}
-			re.manager.detectionSystem.detectionsMu.Unlock()
+// recordAttempt adds an attempt to the history and metrics
+func (re *RecoveryEngine) recordAttempt(attempt *RecoveryAttempt) {
+	re.historyMu.Lock()
+	re.recoveryHistory = append(re.recoveryHistory, attempt)
 
-			slog.Info("recovery successful",
-				"attempt_id", attemptID,
-				"strategy", strategy.GetName(),
-				"fault_id", request.Fault.ID,
-				"duration", result.Duration)
-			return
-		}
+	// Keep only last 1000 attempts
+	if len(re.recoveryHistory) > 1000 {
+		re.recoveryHistory = re.recoveryHistory[len(re.recoveryHistory)-1000:]
 	}
+	re.historyMu.Unlock()
+
+	// Update metrics
+	re.manager.mu.Lock()
+	re.manager.metrics.RecoveryAttempts++
+	re.manager.mu.Unlock()
+}
 
-	// All strategies failed
-	slog.Error("all recovery strategies failed", "fault_id", request.Fault.ID)
+// resolved marks a fault recovered from
+func (re *RecoveryEngine) resolved(fault *FaultDetection, result *RecoveryResult) {
+	now := time.Now()
+	re.manager.mu.Lock()
+	re.manager.metrics.SuccessfulRecoveries++
+	re.manager.metrics.FaultsResolved++
+	re.manager.metrics.LastRecovery = &now
+	re.manager.mu.Unlock()
 
-	// Mark fault as persistent
-	re.manager.detectionSystem.detectionsMu.Lock()
-	if fault, exists := re.manager.detectionSystem.detections[request.Fault.ID]; exists {
-		fault.Status = FaultStatusPersistent
-	}
-	re.manager.detectionSystem.detectionsMu.Unlock()
+	// Mark fault as resolved
+	re.manager.detectionSystem.UpdateFaultStatus(fault.ID, FaultStatusResolved)
 }
 
 // AlertingSystem methods
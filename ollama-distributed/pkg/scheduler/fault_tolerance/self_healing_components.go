@@ -239,8 +239,9 @@ func NewAdaptiveThresholds(config *AdaptiveThresholdsConfig) *AdaptiveThresholds
 
 // SelectStrategy selects the best strategy for a fault
 func (ss *StrategySelector) SelectStrategy(fault *FaultDetection, systemState *SystemState, strategies map[string]HealingStrategy) (HealingStrategy, error) {
-	ss.mu.RLock()
-	defer ss.mu.RUnlock()
+	// Selecting records the strategy's usage, so take the write lock
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 
 	var bestStrategy HealingStrategy
 	bestScore := -1.0
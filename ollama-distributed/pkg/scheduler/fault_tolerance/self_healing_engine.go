@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 )
 
 // SelfHealingEngine provides automated system healing capabilities
@@ -31,6 +33,10 @@ type SelfHealingEngine struct {
 	healingHistory  []*HealingAttempt
 	healingMu       sync.RWMutex
 
+	// Cluster nodes
+	cluster   types.ClusterView
+	clusterMu sync.RWMutex
+
 	// Configuration
 	config *SelfHealingConfig
 
@@ -833,8 +839,10 @@ func (she *SelfHealingEngine) getComponentHealth() map[string]float64 {
 
 // getResourceUsage gets current resource usage
 func (she *SelfHealingEngine) getResourceUsage() map[string]float64 {
-	// This would integrate with actual resource monitors
-	// For now, return mock data
+	if usage, ok := she.clusterUsage(); ok {
+		return usage
+	}
+	// Without a cluster view, return mock data
 	return map[string]float64{
 		"cpu":     0.65,
 		"memory":  0.70,
@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Defaults of the flap quarantine
+const (
+	DefaultFlapWindow    = 10 * time.Minute
+	DefaultFlapThreshold = 3
+)
+
+// FlapStatus is how often a node failed and recovered, and whether it is
+// quarantined for it
+type FlapStatus struct {
+	NodeID string `json:"node_id"`
+	// Flaps counts the recoveries within the flap window, TotalFlaps all
+	// of them
+	Flaps         int        `json:"flaps"`
+	TotalFlaps    int64      `json:"total_flaps"`
+	Down          bool       `json:"down"`
+	Quarantined   bool       `json:"quarantined"`
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+	// ReleaseAt is when the quarantine ends by itself; nil until released
+	// by hand
+	ReleaseAt *time.Time `json:"release_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// flapState is the flapping record of a node, guarded by nodesMu
+type flapState struct {
+	down          bool
+	recoveries    []time.Time // within the flap window
+	total         int64
+	quarantined   bool
+	quarantinedAt time.Time
+	releaseAt     time.Time
+	reason        string
+}
+
+// SetQuarantineObserver sets a function told about every node quarantined
+// or released. It is called with the registry locked: it must return
+// quickly and not call back into the engine.
+func (e *Engine) SetQuarantineObserver(fn func(FlapStatus)) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+	e.quarantineObserver = fn
+}
+
+// flapSettings returns the flap window and threshold, defaulted
+func (e *Engine) flapSettings() (window time.Duration, threshold int) {
+	window, threshold = e.config.FlapQuarantine.Window, e.config.FlapQuarantine.Threshold
+	if window == 0 {
+		window = DefaultFlapWindow
+	}
+	if threshold == 0 {
+		threshold = DefaultFlapThreshold
+	}
+	return window, threshold
+}
+
+// flapState returns the record of a node, creating it. The caller holds
+// nodesMu.
+func (e *Engine) flapState(nodeID string) *flapState {
+	if e.flaps == nil {
+		e.flaps = make(map[string]*flapState)
+	}
+	st, exists := e.flaps[nodeID]
+	if !exists {
+		st = &flapState{}
+		e.flaps[nodeID] = st
+	}
+	return st
+}
+
+// nodeDown records a failure of a node. The caller holds nodesMu.
+func (e *Engine) nodeDown(nodeID string) {
+	e.flapState(nodeID).down = true
+}
+
+// nodeUp records a node as reachable. A node recovering from a failure has
+// flapped, and is quarantined once it flapped too often within the window.
+// The caller holds nodesMu and sets the node's status afterwards.
+func (e *Engine) nodeUp(nodeID string, now time.Time) {
+	st := e.flaps[nodeID]
+	if st == nil || !st.down {
+		return
+	}
+	st.down = false
+	st.total++
+
+	window, threshold := e.flapSettings()
+	st.recoveries = append(recentFlaps(st.recoveries, now, window), now)
+	if !e.config.FlapQuarantine.Enabled || st.quarantined || len(st.recoveries) < threshold {
+		return
+	}
+
+	var releaseAt time.Time
+	if e.config.FlapQuarantine.Duration > 0 {
+		releaseAt = now.Add(e.config.FlapQuarantine.Duration)
+	}
+	e.quarantine(nodeID, st, now, releaseAt, fmt.Sprintf("flapped %d times within %s", len(st.recoveries), window))
+}
+
+// recentFlaps drops the recoveries older than the window, in place
+func recentFlaps(recoveries []time.Time, now time.Time, window time.Duration) []time.Time {
+	recent := recoveries[:0]
+	for _, at := range recoveries {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// quarantine marks a node quarantined. The caller holds nodesMu.
+func (e *Engine) quarantine(nodeID string, st *flapState, now, releaseAt time.Time, reason string) {
+	st.quarantined = true
+	st.quarantinedAt = now
+	st.releaseAt = releaseAt
+	st.reason = reason
+	if e.quarantineObserver != nil {
+		e.quarantineObserver(e.flapStatus(nodeID, st, now))
+	}
+}
+
+// QuarantineNode takes a node out of scheduling until it is released by
+// hand. A node may be quarantined before the scheduler has discovered it.
+func (e *Engine) QuarantineNode(nodeID, reason string) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	st := e.flapState(nodeID)
+	if st.quarantined {
+		st.releaseAt = time.Time{}
+		st.reason = reason
+		return
+	}
+	e.quarantine(nodeID, st, time.Now(), time.Time{}, reason)
+	if node, exists := e.nodes[nodeID]; exists && (node.Status == NodeStatusOnline || node.Status == NodeStatusDraining) && !st.down {
+		node.Status = NodeStatusQuarantined
+		e.publishNode(types.NodeUpdated, node)
+	}
+}
+
+// UnquarantineNode lets requests be scheduled on a quarantined node again,
+// forgetting its recent flaps. It reports whether the node was quarantined.
+func (e *Engine) UnquarantineNode(nodeID string) bool {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	return e.release(nodeID, time.Now())
+}
+
+// release ends the quarantine of a node. The caller holds nodesMu.
+func (e *Engine) release(nodeID string, now time.Time) bool {
+	st := e.flaps[nodeID]
+	if st == nil || !st.quarantined {
+		return false
+	}
+	st.quarantined = false
+	st.quarantinedAt, st.releaseAt = time.Time{}, time.Time{}
+	st.reason = ""
+	st.recoveries = nil
+
+	if node, exists := e.nodes[nodeID]; exists && node.Status == NodeStatusQuarantined {
+		node.Status = e.onlineStatus(nodeID)
+		e.publishNode(types.NodeUpdated, node)
+	}
+	if e.quarantineObserver != nil {
+		e.quarantineObserver(e.flapStatus(nodeID, st, now))
+	}
+	return true
+}
+
+// releaseQuarantines releases the nodes whose quarantine has run its
+// course
+func (e *Engine) releaseQuarantines(now time.Time) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+
+	for nodeID, st := range e.flaps {
+		if st.quarantined && !st.releaseAt.IsZero() && !now.Before(st.releaseAt) {
+			e.release(nodeID, now)
+		}
+	}
+}
+
+// IsNodeQuarantined reports whether a node is quarantined for flapping or
+// by hand
+func (e *Engine) IsNodeQuarantined(nodeID string) bool {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	st := e.flaps[nodeID]
+	return st != nil && st.quarantined
+}
+
+// NodeFlaps returns the flapping record of a node
+func (e *Engine) NodeFlaps(nodeID string) FlapStatus {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	st := e.flaps[nodeID]
+	if st == nil {
+		return FlapStatus{NodeID: nodeID}
+	}
+	return e.flapStatus(nodeID, st, time.Now())
+}
+
+// FlapStatuses returns the records of the nodes that flapped or were
+// quarantined, ordered by node ID
+func (e *Engine) FlapStatuses() []FlapStatus {
+	e.nodesMu.RLock()
+	defer e.nodesMu.RUnlock()
+
+	now := time.Now()
+	statuses := make([]FlapStatus, 0, len(e.flaps))
+	for nodeID, st := range e.flaps {
+		if st.total > 0 || st.quarantined {
+			statuses = append(statuses, e.flapStatus(nodeID, st, now))
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].NodeID < statuses[j].NodeID })
+	return statuses
+}
+
+// flapStatus reports a record as of now. The caller holds nodesMu.
+func (e *Engine) flapStatus(nodeID string, st *flapState, now time.Time) FlapStatus {
+	window, _ := e.flapSettings()
+	status := FlapStatus{
+		NodeID:      nodeID,
+		TotalFlaps:  st.total,
+		Down:        st.down,
+		Quarantined: st.quarantined,
+		Reason:      st.reason,
+	}
+	for _, at := range st.recoveries {
+		if now.Sub(at) < window {
+			status.Flaps++
+		}
+	}
+	if st.quarantined {
+		quarantinedAt := st.quarantinedAt
+		status.QuarantinedAt = &quarantinedAt
+		if !st.releaseAt.IsZero() {
+			releaseAt := st.releaseAt
+			status.ReleaseAt = &releaseAt
+		}
+	}
+	return status
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flap fails a node and brings it back at the given time, as the health
+// checker and node discovery do
+func flap(e *Engine, nodeID string, at time.Time) {
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+	e.nodeDown(nodeID)
+	e.nodes[nodeID].Status = NodeStatusDraining
+	e.nodeUp(nodeID, at)
+	e.nodes[nodeID].Status = e.onlineStatus(nodeID)
+}
+
+func TestFlappingNodeQuarantined(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	e.config.FlapQuarantine = config.FlapQuarantineConfig{Enabled: true, Window: time.Minute, Threshold: 3, Duration: time.Hour}
+	var observed []FlapStatus
+	e.SetQuarantineObserver(func(status FlapStatus) { observed = append(observed, status) })
+
+	start := time.Now()
+	flap(e, "b", start)
+	flap(e, "b", start.Add(50*time.Second))
+	// The first flap has left the window
+	flap(e, "b", start.Add(70*time.Second))
+	assert.False(t, e.IsNodeQuarantined("b"))
+	flap(e, "b", start.Add(80*time.Second))
+	require.True(t, e.IsNodeQuarantined("b"))
+	assert.Equal(t, NodeStatusQuarantined, e.GetNodes()["b"].Status)
+
+	// Quarantined nodes are not scheduled on, not even as a last resort
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", node.ID)
+
+	require.Len(t, observed, 1)
+	assert.True(t, observed[0].Quarantined)
+	assert.Equal(t, int64(4), observed[0].TotalFlaps)
+	require.NotNil(t, observed[0].ReleaseAt)
+	assert.Equal(t, start.Add(80*time.Second+time.Hour), *observed[0].ReleaseAt)
+
+	// Node discovery keeps it quarantined until the quarantine runs out
+	flap(e, "b", start.Add(90*time.Second))
+	assert.Equal(t, NodeStatusQuarantined, e.GetNodes()["b"].Status)
+	e.releaseQuarantines(start.Add(30 * time.Minute))
+	assert.True(t, e.IsNodeQuarantined("b"))
+	e.releaseQuarantines(start.Add(80*time.Second + time.Hour))
+	assert.False(t, e.IsNodeQuarantined("b"))
+	assert.Equal(t, NodeStatusOnline, e.GetNodes()["b"].Status)
+
+	require.Len(t, observed, 2)
+	assert.False(t, observed[1].Quarantined)
+	assert.Equal(t, int64(5), e.NodeFlaps("b").TotalFlaps)
+}
+
+func TestFlapsCountedWithoutQuarantine(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		flap(e, "a", now)
+	}
+	assert.False(t, e.IsNodeQuarantined("a"))
+	statuses := e.FlapStatuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "a", statuses[0].NodeID)
+	assert.Equal(t, 5, statuses[0].Flaps)
+}
+
+func TestQuarantineNodeByHand(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	e.config.FlapQuarantine = config.FlapQuarantineConfig{Enabled: true, Duration: time.Minute}
+
+	e.QuarantineNode("b", "bad GPU")
+	assert.Equal(t, NodeStatusQuarantined, e.GetNodes()["b"].Status)
+	status := e.NodeFlaps("b")
+	assert.Equal(t, "bad GPU", status.Reason)
+	assert.Nil(t, status.ReleaseAt, "quarantined by hand until released by hand")
+	e.releaseQuarantines(time.Now().Add(time.Hour))
+	assert.True(t, e.IsNodeQuarantined("b"))
+
+	// A drained node returns to draining when released
+	e.DrainNode("b")
+	assert.True(t, e.UnquarantineNode("b"))
+	assert.Equal(t, NodeStatusDraining, e.GetNodes()["b"].Status)
+	assert.False(t, e.UnquarantineNode("b"))
+}
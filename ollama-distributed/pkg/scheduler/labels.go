@@ -0,0 +1,117 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// nodeLabelsKey is the consensus key holding the labels and taints of every
+// node, so that each node's scheduler places requests by the same labels
+const nodeLabelsKey = "node_labels"
+
+// SetPlacementPolicy sets the affinity, anti-affinity and toleration rules
+// requests are placed by. Node taints apply with or without a policy.
+func (e *Engine) SetPlacementPolicy(policy *placement.Policy) {
+	e.placementMu.Lock()
+	defer e.placementMu.Unlock()
+	e.placement = policy
+}
+
+// NodeLabels returns the registry of node labels and taints
+func (e *Engine) NodeLabels() *placement.Registry {
+	return e.labels
+}
+
+// SetNodeLabels replaces the labels and taints of a node and, on the leader,
+// shares them with the cluster
+func (e *Engine) SetNodeLabels(nodeID string, labels placement.NodeLabels) error {
+	if err := e.labels.Set(nodeID, labels); err != nil {
+		return err
+	}
+
+	if e.consensus != nil && e.consensus.IsLeader() {
+		if err := e.consensus.Apply(nodeLabelsKey, e.labels.All(), nil); err != nil {
+			return fmt.Errorf("failed to share node labels: %w", err)
+		}
+	}
+	return nil
+}
+
+// syncNodeLabels merges the node labels agreed through consensus. Values
+// arrive decoded from the replicated log, so they are converted via JSON.
+func (e *Engine) syncNodeLabels() {
+	value, exists := e.consensus.Get(nodeLabelsKey)
+	if !exists {
+		return
+	}
+
+	all, ok := value.(map[string]placement.NodeLabels)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &all); err != nil {
+			return
+		}
+	}
+	e.labels.Merge(all)
+}
+
+// placementCandidates returns the nodes with the selector's labels the
+// model may run on that the placement rules rank best, and the reasons the
+// others were rejected
+func (e *Engine) placementCandidates(modelName string, tolerations []placement.Toleration, selector map[string]string, nodes []*NodeInfo) ([]*NodeInfo, map[string]string) {
+	e.placementMu.RLock()
+	policy := e.placement
+	e.placementMu.RUnlock()
+
+	var reasons map[string]string
+	reject := func(nodeID, reason string) {
+		if reasons == nil {
+			reasons = make(map[string]string)
+		}
+		reasons[nodeID] = reason
+	}
+
+	byID := make(map[string]*NodeInfo, len(nodes))
+	views := make([]placement.Node, 0, len(nodes))
+	for _, node := range nodes {
+		view := e.labels.Node(node.ID, node.Models)
+		if key, ok := missingLabel(view.Labels, selector); ok {
+			reject(node.ID, fmt.Sprintf("node lacks selected label %s=%s", key, selector[key]))
+			continue
+		}
+		byID[node.ID] = node
+		views = append(views, view)
+	}
+
+	preferred, rejected := policy.PreferredFor(modelName, tolerations, views)
+	candidates := make([]*NodeInfo, len(preferred))
+	for i, view := range preferred {
+		candidates[i] = byID[view.ID]
+	}
+	for _, r := range rejected {
+		reject(r.NodeID, r.Reason)
+	}
+	return candidates, reasons
+}
+
+// missingLabel returns the first key, in sorted order, of a selector label
+// the node lacks
+func missingLabel(labels, selector map[string]string) (string, bool) {
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := labels[key]; !ok || value != selector[key] {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectNodeHonoursTaints(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	require.NoError(t, e.SetNodeLabels("b", placement.NodeLabels{
+		Labels: map[string]string{"gpu": "h100"},
+		Taints: []placement.Taint{{Key: "dedicated", Value: "training", Effect: placement.TaintNoSchedule}},
+	}))
+
+	// b is the least loaded but tainted
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", node.ID)
+
+	node, err = e.loadBalancer.SelectNode(&Request{
+		ModelName:   "llama3",
+		Tolerations: []placement.Toleration{{Key: "dedicated", Operator: placement.TolerationOpExists}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "b", node.ID)
+
+	explanation := e.ExplainSelection("llama3")
+	assert.Equal(t, "untolerated taint dedicated=training:NoSchedule", explanation.Nodes[1].Reason)
+}
+
+func TestSelectNodeHonoursPlacementPolicy(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	require.NoError(t, e.SetNodeLabels("a", placement.NodeLabels{Labels: map[string]string{"gpu": "a100"}}))
+
+	policy, err := placement.NewPolicy(placement.Constraints{
+		Affinity:     []placement.AffinityRule{{Model: "llama3", Labels: map[string]string{"gpu": "a100"}}},
+		AntiAffinity: []placement.AntiAffinityRule{{Models: []string{"llama3", "mixtral"}}},
+	})
+	require.NoError(t, err)
+	e.SetPlacementPolicy(policy)
+
+	// a is preferred by affinity even though b is less loaded
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "a", node.ID)
+
+	// mixtral may not join llama3 on a or b
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "mixtral"})
+	require.NoError(t, err)
+	assert.Equal(t, "c", node.ID)
+
+	require.NoError(t, e.SetNodeLabels("c", placement.NodeLabels{
+		Taints: []placement.Taint{{Key: "maintenance", Effect: placement.TaintNoSchedule}},
+	}))
+	_, err = e.loadBalancer.SelectNode(&Request{ModelName: "mixtral"})
+	assert.Error(t, err)
+}
+
+func TestSelectNodeHonoursNodeSelector(t *testing.T) {
+	e := newExplainEngine(t, "least_connections")
+	require.NoError(t, e.SetNodeLabels("c", placement.NodeLabels{Labels: map[string]string{"pool": "acme"}}))
+
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3", NodeSelector: map[string]string{"pool": "acme"}})
+	require.NoError(t, err)
+	assert.Equal(t, "c", node.ID)
+
+	_, err = e.loadBalancer.SelectNode(&Request{ModelName: "llama3", NodeSelector: map[string]string{"pool": "globex"}})
+	assert.Error(t, err)
+
+	explanation := e.ExplainSelectionIn("llama3", nil, map[string]string{"pool": "acme"})
+	assert.Equal(t, "c", explanation.SelectedNode)
+	assert.Equal(t, "node lacks selected label pool=acme", explanation.Nodes[0].Reason)
+}
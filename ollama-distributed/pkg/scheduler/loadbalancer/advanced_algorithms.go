@@ -107,7 +107,7 @@ func (plba *PredictiveLoadBalancingAlgorithm) SelectNodes(task interface{}, node
 	}
 
 	// Sort nodes by score (higher is better)
-	sort.Slice(nodeScores, func(i, j int) bool {
+	sort.SliceStable(nodeScores, func(i, j int) bool {
 		return nodeScores[i].Score > nodeScores[j].Score
 	})
 
@@ -258,7 +258,7 @@ func (alba *AdaptiveLoadBalancingAlgorithm) SelectNodes(task interface{}, nodes
 	}
 
 	// Sort nodes by score (higher is better)
-	sort.Slice(nodeScores, func(i, j int) bool {
+	sort.SliceStable(nodeScores, func(i, j int) bool {
 		return nodeScores[i].Score > nodeScores[j].Score
 	})
 
@@ -411,7 +411,7 @@ func (ralba *ResourceAwareLoadBalancingAlgorithm) SelectNodes(task interface{},
 	}
 
 	// Sort nodes by score (higher is better)
-	sort.Slice(nodeScores, func(i, j int) bool {
+	sort.SliceStable(nodeScores, func(i, j int) bool {
 		return nodeScores[i].Score > nodeScores[j].Score
 	})
 
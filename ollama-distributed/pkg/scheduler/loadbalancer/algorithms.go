@@ -14,6 +14,7 @@ type WeightedRoundRobinAlgorithm struct {
 	metrics *AlgorithmMetrics
 	counter int
 	weights map[string]float64
+	rng     *rand.Rand
 }
 
 // NewWeightedRoundRobinAlgorithm creates a new weighted round-robin algorithm
@@ -46,8 +47,8 @@ func (wrr *WeightedRoundRobinAlgorithm) SelectNodes(task interface{}, nodes []*N
 
 	// Select node using weighted round-robin
 	totalWeight := 0.0
-	for _, weight := range wrr.weights {
-		totalWeight += weight
+	for _, node := range nodes {
+		totalWeight += wrr.weights[node.ID]
 	}
 
 	if totalWeight == 0 {
@@ -58,7 +59,7 @@ func (wrr *WeightedRoundRobinAlgorithm) SelectNodes(task interface{}, nodes []*N
 	}
 
 	// Weighted selection
-	target := rand.Float64() * totalWeight
+	target := wrr.float64() * totalWeight
 	currentSum := 0.0
 
 	for _, node := range nodes {
@@ -72,6 +73,18 @@ func (wrr *WeightedRoundRobinAlgorithm) SelectNodes(task interface{}, nodes []*N
 	return []*NodeInfo{nodes[0]}, nil
 }
 
+// setRand makes the weighted selection draw from a seeded source
+func (wrr *WeightedRoundRobinAlgorithm) setRand(rng *rand.Rand) {
+	wrr.rng = rng
+}
+
+func (wrr *WeightedRoundRobinAlgorithm) float64() float64 {
+	if wrr.rng != nil {
+		return wrr.rng.Float64()
+	}
+	return rand.Float64()
+}
+
 func (wrr *WeightedRoundRobinAlgorithm) calculateWeight(node *NodeInfo) float64 {
 	// Weight based on capacity and inverse of utilization
 	capacityScore := node.PerformanceScore
@@ -135,7 +148,7 @@ func (lel *LeastEffectiveLoadAlgorithm) SelectNodes(task interface{}, nodes []*N
 	}
 
 	// Sort by effective load (ascending)
-	sort.Slice(scores, func(i, j int) bool {
+	sort.SliceStable(scores, func(i, j int) bool {
 		return scores[i].effectiveLoad < scores[j].effectiveLoad
 	})
 
@@ -235,7 +248,7 @@ func (laa *LocalityAwareAlgorithm) SelectNodes(task interface{}, nodes []*NodeIn
 	}
 
 	// Sort by locality score (descending)
-	sort.Slice(scores, func(i, j int) bool {
+	sort.SliceStable(scores, func(i, j int) bool {
 		return scores[i].localityScore > scores[j].localityScore
 	})
 
@@ -375,7 +388,7 @@ func (pa *PredictiveAlgorithm) SelectNodes(task interface{}, nodes []*NodeInfo)
 	}
 
 	// Sort by prediction score (descending)
-	sort.Slice(predictions, func(i, j int) bool {
+	sort.SliceStable(predictions, func(i, j int) bool {
 		return predictions[i].predictionScore > predictions[j].predictionScore
 	})
 
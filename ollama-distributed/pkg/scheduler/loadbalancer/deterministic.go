@@ -0,0 +1,22 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// randomizedAlgorithm is implemented by algorithms that make random choices,
+// so deterministic mode can hand them a seeded source
+type randomizedAlgorithm interface {
+	setRand(rng *rand.Rand)
+}
+
+// sortNodesByID returns the nodes ordered by ID, leaving the input as is
+func sortNodesByID(nodes []*NodeInfo) []*NodeInfo {
+	sorted := make([]*NodeInfo, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
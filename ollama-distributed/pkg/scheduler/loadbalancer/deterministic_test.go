@@ -0,0 +1,55 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNodes(order []int) []*NodeInfo {
+	nodes := make([]*NodeInfo, len(order))
+	for i, n := range order {
+		nodes[i] = &NodeInfo{
+			ID:               fmt.Sprintf("node-%d", n),
+			Capacity:         &ResourceCapacity{CPUCores: 8},
+			Usage:            &ResourceUsage{CPUUtilization: 0.1 * float64(n%3)},
+			HealthScore:      1,
+			PerformanceScore: 1,
+		}
+	}
+	return nodes
+}
+
+func selections(t *testing.T, config *Config, order []int) []string {
+	ilb := NewIntelligentLoadBalancer(config)
+	var picked []string
+	for i := 0; i < 20; i++ {
+		nodes, err := ilb.SelectNodes(nil, testNodes(order))
+		require.NoError(t, err)
+		picked = append(picked, nodes[0].ID)
+	}
+	return picked
+}
+
+func TestDeterministicSelection(t *testing.T) {
+	for _, algorithm := range []string{"weighted_round_robin", "least_effective_load"} {
+		t.Run(algorithm, func(t *testing.T) {
+			config := &Config{Algorithm: algorithm, Deterministic: true, Seed: 42}
+
+			first := selections(t, config, []int{0, 1, 2, 3, 4, 5})
+			// The same seed gives the same picks whatever order nodes arrive in
+			assert.Equal(t, first, selections(t, config, []int{5, 3, 1, 4, 0, 2}))
+		})
+	}
+}
+
+func TestSortNodesByIDLeavesInput(t *testing.T) {
+	nodes := testNodes([]int{2, 0, 1})
+	sorted := sortNodesByID(nodes)
+
+	assert.Equal(t, "node-0", sorted[0].ID)
+	assert.Equal(t, "node-2", sorted[2].ID)
+	assert.Equal(t, "node-2", nodes[0].ID)
+}
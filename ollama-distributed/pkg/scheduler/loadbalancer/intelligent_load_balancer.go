@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"math/rand"
 	"sort"
 	"sync"
 	"time"
@@ -28,6 +29,11 @@ type Config struct {
 	Adaptive          bool               `json:"adaptive"`
 	PredictionEnabled bool               `json:"prediction_enabled"`
 	HistorySize       int                `json:"history_size"`
+
+	// Deterministic orders nodes by ID and seeds random choices with Seed,
+	// so the same cluster state always yields the same selection
+	Deterministic bool  `json:"deterministic"`
+	Seed          int64 `json:"seed"`
 }
 
 // LoadBalancingAlgorithm defines the interface for load balancing algorithms
@@ -221,6 +227,9 @@ func (ilb *IntelligentLoadBalancer) RegisterAlgorithm(algorithm LoadBalancingAlg
 	ilb.mu.Lock()
 	defer ilb.mu.Unlock()
 
+	if r, ok := algorithm.(randomizedAlgorithm); ok && ilb.config.Deterministic {
+		r.setRand(rand.New(rand.NewSource(ilb.config.Seed)))
+	}
 	ilb.algorithms[algorithm.GetName()] = algorithm
 	ilb.metrics.AlgorithmMetrics[algorithm.GetName()] = &AlgorithmMetrics{
 		LastUsed: time.Now(),
@@ -234,6 +243,10 @@ func (ilb *IntelligentLoadBalancer) SelectNodes(task interface{}, availableNodes
 	// Update metrics
 	ilb.metrics.TotalRequests++
 
+	if ilb.config.Deterministic {
+		availableNodes = sortNodesByID(availableNodes)
+	}
+
 	// Apply constraints
 	constrainedNodes := ilb.applyConstraints(availableNodes)
 	if len(constrainedNodes) == 0 {
@@ -0,0 +1,214 @@
+package orchestration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+)
+
+// Progress event types, emitted for tasks and for their partitions
+const (
+	EventQueued      = "queued"
+	EventDispatched  = "dispatched"
+	EventExecuting   = "executing"
+	EventAggregating = "aggregating" // tasks only
+	EventRetrying    = "retrying"    // tasks only
+	EventDone        = "done"
+	EventFailed      = "failed"
+)
+
+// ProgressEvent is a step in the lifecycle of a task or, when PartitionID
+// is set, of one of its partitions
+type ProgressEvent struct {
+	Type        string        `json:"type"`
+	TaskID      string        `json:"task_id"`
+	TaskType    string        `json:"task_type,omitempty"`
+	PartitionID string        `json:"partition_id,omitempty"`
+	NodeID      string        `json:"node_id,omitempty"`
+	Attempt     int           `json:"attempt"`          // retries of the task so far
+	Reason      string        `json:"reason,omitempty"` // why a task failed or is retried
+	Elapsed     time.Duration `json:"elapsed"`          // since the task or partition started
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// EventFilter selects progress events. Empty fields match everything.
+type EventFilter struct {
+	TaskID string
+	Types  []string
+}
+
+// Matches reports whether ev is selected by the filter
+func (f EventFilter) Matches(ev *ProgressEvent) bool {
+	if f.TaskID != "" && ev.TaskID != f.TaskID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if ev.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// EventBus fans progress events out to subscribers such as the WebSocket
+// hub, webhooks and tracing. Publishing never blocks: events are dropped
+// for subscribers that fall behind.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan ProgressEvent]EventFilter
+	dropped     atomic.Int64
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[chan ProgressEvent]EventFilter)}
+}
+
+// Publish sends ev to the subscribers whose filter selects it
+func (b *EventBus) Publish(ev ProgressEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch, filter := range b.subscribers {
+		if !filter.Matches(&ev) {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe streams the events selected by filter until cancel is called
+func (b *EventBus) Subscribe(filter EventFilter) (<-chan ProgressEvent, func()) {
+	ch := make(chan ProgressEvent, 256)
+
+	b.mu.Lock()
+	b.subscribers[ch] = filter
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Dropped returns the number of events subscribers missed because their
+// buffer was full
+func (b *EventBus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// PostEvents posts every event from events as JSON to url until events is
+// closed or ctx ends. Failed deliveries are logged and not retried.
+func PostEvents(ctx context.Context, events <-chan ProgressEvent, url string, client *http.Client) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := postEvent(ctx, client, url, ev); err != nil {
+				slog.Warn("failed to post progress event", "url", url, "task_id", ev.TaskID, "type", ev.Type, "error", err)
+			}
+		}
+	}
+}
+
+func postEvent(ctx context.Context, client *http.Client, url string, ev ProgressEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Events returns the bus the engine publishes task and partition progress
+// on
+func (oe *OrchestrationEngine) Events() *EventBus {
+	return oe.events
+}
+
+// emitTask publishes a progress event of task and logs it on the span
+// traced in ctx, if any
+func (oe *OrchestrationEngine) emitTask(ctx context.Context, task *OrchestrationTask, eventType, reason string) {
+	oe.emit(ctx, ProgressEvent{
+		Type:    eventType,
+		TaskID:  task.ID,
+		Reason:  reason,
+		Elapsed: time.Since(task.StartedAt),
+	}, task)
+}
+
+// emitPartition publishes a progress event of a partition of task that
+// has been running for elapsed
+func (oe *OrchestrationEngine) emitPartition(ctx context.Context, task *OrchestrationTask, partition *TaskPartition, eventType, reason string, elapsed time.Duration) {
+	oe.emit(ctx, ProgressEvent{
+		Type:        eventType,
+		TaskID:      task.ID,
+		PartitionID: partition.ID,
+		NodeID:      partition.NodeID,
+		Reason:      reason,
+		Elapsed:     elapsed,
+	}, task)
+}
+
+func (oe *OrchestrationEngine) emit(ctx context.Context, ev ProgressEvent, task *OrchestrationTask) {
+	ev.TaskType = task.Type
+	ev.Attempt = task.RetryCount
+	ev.Timestamp = time.Now()
+
+	if span := observability.SpanFromContext(ctx); span != nil {
+		fields := map[string]interface{}{
+			"event":   "orchestration." + ev.Type,
+			"task_id": ev.TaskID,
+			"attempt": ev.Attempt,
+		}
+		if ev.PartitionID != "" {
+			fields["partition_id"] = ev.PartitionID
+			fields["node_id"] = ev.NodeID
+		}
+		if ev.Reason != "" {
+			fields["reason"] = ev.Reason
+		}
+		span.LogFields(fields)
+	}
+	oe.events.Publish(ev)
+}
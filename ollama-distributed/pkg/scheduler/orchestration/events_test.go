@@ -0,0 +1,93 @@
+package orchestration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteTaskEmitsProgress(t *testing.T) {
+	oe := NewOrchestrationEngine(&Config{MaxConcurrentTasks: 1, TaskTimeout: time.Minute})
+	events, cancel := oe.Events().Subscribe(EventFilter{})
+	defer cancel()
+
+	span := &observability.Span{}
+	ctx := observability.ContextWithSpan(context.Background(), span)
+	require.NoError(t, oe.ExecuteTask(ctx, "payload"))
+
+	var taskEvents []string
+	partitions := make(map[string][]string)
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev := <-events:
+			if ev.PartitionID == "" {
+				taskEvents = append(taskEvents, ev.Type)
+				done = ev.Type == EventDone || ev.Type == EventFailed
+			} else {
+				partitions[ev.PartitionID] = append(partitions[ev.PartitionID], ev.Type)
+				assert.NotEmpty(t, ev.NodeID)
+			}
+			assert.Equal(t, "distributed_inference", ev.TaskType)
+		case <-timeout:
+			t.Fatalf("task did not finish, got %v", taskEvents)
+		}
+	}
+
+	assert.Equal(t, []string{EventQueued, EventDispatched, EventExecuting, EventAggregating, EventDone}, taskEvents)
+	require.NotEmpty(t, partitions)
+	for id, steps := range partitions {
+		assert.Equal(t, []string{EventQueued, EventDispatched, EventExecuting, EventDone}, steps, id)
+	}
+	assert.Len(t, span.Logs, len(taskEvents)+4*len(partitions))
+}
+
+func TestEventBusFilterAndDrops(t *testing.T) {
+	bus := NewEventBus()
+	failures, cancel := bus.Subscribe(EventFilter{TaskID: "a", Types: []string{EventFailed}})
+	defer cancel()
+
+	bus.Publish(ProgressEvent{Type: EventQueued, TaskID: "a"})
+	bus.Publish(ProgressEvent{Type: EventFailed, TaskID: "b"})
+	bus.Publish(ProgressEvent{Type: EventFailed, TaskID: "a", Reason: "boom"})
+
+	ev := <-failures
+	assert.Equal(t, "boom", ev.Reason)
+	assert.Empty(t, failures)
+
+	// Subscribers that fall behind miss events instead of blocking
+	for i := 0; i < 300; i++ {
+		bus.Publish(ProgressEvent{Type: EventFailed, TaskID: "a"})
+	}
+	assert.Equal(t, int64(300-cap(failures)), bus.Dropped())
+
+	cancel()
+	cancel()
+	bus.Publish(ProgressEvent{Type: EventFailed, TaskID: "a"})
+}
+
+func TestPostEvents(t *testing.T) {
+	received := make(chan ProgressEvent, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev ProgressEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		received <- ev
+	}))
+	defer server.Close()
+
+	events := make(chan ProgressEvent, 2)
+	events <- ProgressEvent{Type: EventQueued, TaskID: "a"}
+	events <- ProgressEvent{Type: EventDone, TaskID: "a"}
+	close(events)
+	PostEvents(context.Background(), events, server.URL, nil)
+
+	assert.Equal(t, EventQueued, (<-received).Type)
+	assert.Equal(t, EventDone, (<-received).Type)
+}
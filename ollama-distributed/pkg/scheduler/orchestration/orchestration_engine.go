@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
 )
 
 // OrchestrationEngine manages distributed task orchestration
@@ -18,6 +20,8 @@ type OrchestrationEngine struct {
 	activeTasks   map[string]*OrchestrationTask
 	activeTasksMu sync.RWMutex
 	metrics       *OrchestrationMetrics
+	events        *EventBus
+	speculative   *speculative.Service
 	mu            sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
@@ -288,6 +292,8 @@ type OrchestrationTask struct {
 	Metadata         map[string]interface{} `json:"metadata"`
 	RetryCount       int                    `json:"retry_count"`
 	LastError        string                 `json:"last_error"`
+
+	resultsMu sync.Mutex // guards PartialResults while partitions execute
 }
 
 // TaskStatus represents task status
@@ -323,6 +329,7 @@ func NewOrchestrationEngine(config *Config) *OrchestrationEngine {
 		config:      config,
 		activeTasks: make(map[string]*OrchestrationTask),
 		metrics:     &OrchestrationMetrics{LastUpdated: time.Now()},
+		events:      NewEventBus(),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
@@ -463,6 +470,7 @@ func (oe *OrchestrationEngine) ExecuteTask(ctx context.Context, task interface{}
 	// Update metrics
 	oe.metrics.TotalTasks++
 	oe.metrics.ActiveTasks++
+	oe.emitTask(ctx, orchTask, EventQueued, "")
 
 	// Execute task asynchronously
 	go oe.executeTaskAsync(ctx, orchTask)
@@ -506,28 +514,33 @@ func (oe *OrchestrationEngine) executeTaskAsync(ctx context.Context, task *Orche
 		case TaskStatusPending:
 			if err := oe.partitionTask(ctx, task); err != nil {
 				if oe.shouldRetry(task, err) {
-					oe.retryTask(task, err)
+					oe.retryTask(ctx, task, err)
 					continue
 				}
-				oe.failTask(task, err)
+				oe.failTask(ctx, task, err)
 				return
 			}
 			task.Status = TaskStatusPartitioned
+			for _, partition := range task.PartitionPlan.Partitions {
+				oe.emitPartition(ctx, task, partition, EventQueued, "", 0)
+			}
 
 		case TaskStatusPartitioned:
 			if err := oe.executePartitions(ctx, task); err != nil {
 				if oe.shouldRetry(task, err) {
-					oe.retryTask(task, err)
+					oe.retryTask(ctx, task, err)
 					continue
 				}
-				oe.failTask(task, err)
+				oe.failTask(ctx, task, err)
 				return
 			}
 			task.Status = TaskStatusExecuting
+			oe.emitTask(ctx, task, EventExecuting, "")
 
 		case TaskStatusExecuting:
 			if oe.arePartitionsComplete(task) {
 				task.Status = TaskStatusAggregating
+				oe.emitTask(ctx, task, EventAggregating, "")
 			} else {
 				// Wait for partitions to complete
 				time.Sleep(100 * time.Millisecond)
@@ -536,15 +549,16 @@ func (oe *OrchestrationEngine) executeTaskAsync(ctx context.Context, task *Orche
 		case TaskStatusAggregating:
 			if err := oe.aggregateResults(ctx, task); err != nil {
 				if oe.shouldRetry(task, err) {
-					oe.retryTask(task, err)
+					oe.retryTask(ctx, task, err)
 					continue
 				}
-				oe.failTask(task, err)
+				oe.failTask(ctx, task, err)
 				return
 			}
 			task.Status = TaskStatusCompleted
 			completedAt := time.Now()
 			task.CompletedAt = &completedAt
+			oe.emitTask(ctx, task, EventDone, "")
 
 		case TaskStatusCompleted:
 			slog.Info("task completed", "task_id", task.ID, "duration", time.Since(task.StartedAt))
@@ -558,6 +572,9 @@ func (oe *OrchestrationEngine) executeTaskAsync(ctx context.Context, task *Orche
 			// Wait before retrying
 			delay := oe.calculateRetryDelay(task.RetryCount)
 			time.Sleep(delay)
+			task.resultsMu.Lock()
+			task.PartialResults = nil
+			task.resultsMu.Unlock()
 			task.Status = TaskStatusPending
 
 		default:
@@ -602,8 +619,10 @@ func (oe *OrchestrationEngine) selectPartitioningStrategy(task *OrchestrationTas
 func (oe *OrchestrationEngine) executePartitions(ctx context.Context, task *OrchestrationTask) error {
 	// Execute partitions in parallel
 	for _, partition := range task.PartitionPlan.Partitions {
+		oe.emitPartition(ctx, task, partition, EventDispatched, "", 0)
 		go oe.executePartition(ctx, task, partition)
 	}
+	oe.emitTask(ctx, task, EventDispatched, "")
 
 	return nil
 }
@@ -611,6 +630,7 @@ func (oe *OrchestrationEngine) executePartitions(ctx context.Context, task *Orch
 // executePartition executes a single partition
 func (oe *OrchestrationEngine) executePartition(ctx context.Context, task *OrchestrationTask, partition *TaskPartition) {
 	start := time.Now()
+	oe.emitPartition(ctx, task, partition, EventExecuting, "", 0)
 
 	// Simulate partition execution
 	time.Sleep(100 * time.Millisecond)
@@ -625,13 +645,22 @@ func (oe *OrchestrationEngine) executePartition(ctx context.Context, task *Orche
 	}
 
 	// Store partial result
+	task.resultsMu.Lock()
 	task.PartialResults = append(task.PartialResults, result)
+	task.resultsMu.Unlock()
+	if result.Error != "" {
+		oe.emitPartition(ctx, task, partition, EventFailed, result.Error, time.Since(start))
+	} else {
+		oe.emitPartition(ctx, task, partition, EventDone, "", time.Since(start))
+	}
 
 	slog.Debug("partition executed", "task_id", task.ID, "partition_id", partition.ID, "duration", time.Since(start))
 }
 
 // arePartitionsComplete checks if all partitions are complete
 func (oe *OrchestrationEngine) arePartitionsComplete(task *OrchestrationTask) bool {
+	task.resultsMu.Lock()
+	defer task.resultsMu.Unlock()
 	return len(task.PartialResults) >= len(task.PartitionPlan.Partitions)
 }
 
@@ -672,20 +701,22 @@ func (oe *OrchestrationEngine) shouldRetry(task *OrchestrationTask, err error) b
 }
 
 // retryTask prepares a task for retry
-func (oe *OrchestrationEngine) retryTask(task *OrchestrationTask, err error) {
+func (oe *OrchestrationEngine) retryTask(ctx context.Context, task *OrchestrationTask, err error) {
 	task.RetryCount++
 	task.LastError = err.Error()
 	task.Status = TaskStatusRetrying
+	oe.emitTask(ctx, task, EventRetrying, task.LastError)
 
 	slog.Warn("retrying task", "task_id", task.ID, "retry_count", task.RetryCount, "error", err)
 }
 
 // failTask marks a task as failed
-func (oe *OrchestrationEngine) failTask(task *OrchestrationTask, err error) {
+func (oe *OrchestrationEngine) failTask(ctx context.Context, task *OrchestrationTask, err error) {
 	task.Status = TaskStatusFailed
 	task.LastError = err.Error()
 	completedAt := time.Now()
 	task.CompletedAt = &completedAt
+	oe.emitTask(ctx, task, EventFailed, task.LastError)
 
 	slog.Error("task failed", "task_id", task.ID, "error", err)
 }
@@ -0,0 +1,76 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+)
+
+// TaskTypeSpeculative is the type of speculative decoding tasks
+const TaskTypeSpeculative = "speculative_decoding"
+
+// SetSpeculative lets the engine coordinate speculative decoding between
+// the nodes serving draft and target models
+func (oe *OrchestrationEngine) SetSpeculative(service *speculative.Service) {
+	oe.speculative = service
+}
+
+// Speculative returns the speculative decoding service, or nil
+func (oe *OrchestrationEngine) Speculative() *speculative.Service {
+	return oe.speculative
+}
+
+// Speculate generates a continuation of prompt with a target model and its
+// draft model as a task, whose progress is published on the event bus with
+// a partition for each of the two nodes involved
+func (oe *OrchestrationEngine) Speculate(ctx context.Context, model, prompt string, maxTokens int) (speculative.Result, error) {
+	if oe.speculative == nil {
+		return speculative.Result{}, fmt.Errorf("speculative decoding not enabled")
+	}
+
+	task := &OrchestrationTask{
+		ID:        fmt.Sprintf("spec_%d", time.Now().UnixNano()),
+		Type:      TaskTypeSpeculative,
+		Status:    TaskStatusExecuting,
+		StartedAt: time.Now(),
+		Metadata:  map[string]interface{}{"model": model},
+	}
+	oe.activeTasksMu.Lock()
+	oe.activeTasks[task.ID] = task
+	oe.activeTasksMu.Unlock()
+	defer func() {
+		oe.activeTasksMu.Lock()
+		delete(oe.activeTasks, task.ID)
+		oe.activeTasksMu.Unlock()
+	}()
+
+	oe.emitTask(ctx, task, EventExecuting, "")
+	result, err := oe.speculative.Generate(ctx, model, prompt, maxTokens)
+
+	partitions := []*TaskPartition{{ID: task.ID + "_target", NodeID: result.TargetEndpoint, Type: "target"}}
+	if result.Speculative {
+		partitions = append(partitions, &TaskPartition{ID: task.ID + "_draft", NodeID: result.DraftEndpoint, Type: "draft"})
+	}
+	completed := time.Now()
+	task.CompletedAt = &completed
+	if err != nil {
+		task.Status = TaskStatusFailed
+		task.LastError = err.Error()
+		for _, partition := range partitions {
+			if partition.NodeID != "" {
+				oe.emitPartition(ctx, task, partition, EventFailed, err.Error(), result.Duration)
+			}
+		}
+		oe.emitTask(ctx, task, EventFailed, err.Error())
+		return result, err
+	}
+
+	task.Status = TaskStatusCompleted
+	for _, partition := range partitions {
+		oe.emitPartition(ctx, task, partition, EventDone, "", result.Duration)
+	}
+	oe.emitTask(ctx, task, EventDone, "")
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package orchestration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/speculative"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeculateEmitsProgress(t *testing.T) {
+	// Both models stream "ok" and end the text
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"response": "ok", "done": false}`)
+		fmt.Fprintln(w, `{"response": "", "done": true, "done_reason": "stop"}`)
+	}))
+	defer ollama.Close()
+
+	oe := NewOrchestrationEngine(&Config{MaxConcurrentTasks: 1, TaskTimeout: time.Minute})
+	_, err := oe.Speculate(context.Background(), "big", "hi", 8)
+	assert.Error(t, err)
+
+	oe.SetSpeculative(speculative.NewService(speculative.Config{
+		Enabled: true,
+		Pairs:   []speculative.Pair{{Target: "big", Draft: "small"}},
+	}, func(model string) []string { return []string{ollama.URL} }))
+	events, cancel := oe.Events().Subscribe(EventFilter{})
+	defer cancel()
+
+	result, err := oe.Speculate(context.Background(), "big", "hi", 8)
+	require.NoError(t, err)
+	assert.True(t, result.Speculative)
+	assert.Equal(t, "ok", result.Text)
+	assert.True(t, result.Done)
+
+	var types []string
+	for i := 0; i < 4; i++ {
+		ev := <-events
+		assert.Equal(t, TaskTypeSpeculative, ev.TaskType)
+		types = append(types, ev.Type)
+	}
+	assert.Equal(t, []string{EventExecuting, EventDone, EventDone, EventDone}, types)
+}
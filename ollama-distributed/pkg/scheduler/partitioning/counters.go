@@ -0,0 +1,82 @@
+package partitioning
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// failureCategories are the categories partitionCounters count failures
+// under, in index order
+var failureCategories = [...]string{
+	FailureNoNodes,
+	FailureLayerDetection,
+	FailureNodeRejected,
+	FailureTimeout,
+	FailureOther,
+}
+
+// categoryIndex returns the counter index of a category; unknown
+// categories count as FailureOther
+func categoryIndex(category string) int {
+	for i, c := range failureCategories {
+		if c == category {
+			return i
+		}
+	}
+	return len(failureCategories) - 1
+}
+
+// partitionCounters counts partition outcomes with atomics, so recording on
+// the partitioning path and taking snapshots never block each other
+type partitionCounters struct {
+	usage    atomic.Int64
+	failed   atomic.Int64
+	latency  atomic.Int64 // total nanoseconds
+	lastUsed atomic.Int64 // unix nanoseconds
+	failures [len(failureCategories)]atomic.Int64
+}
+
+// record counts a partition that took d and failed with err, if not nil,
+// and returns the failure category
+func (c *partitionCounters) record(d time.Duration, err error) string {
+	// usage is incremented before failed, and snapshot loads them in the
+	// opposite order, so a snapshot never sees more failures than uses
+	c.usage.Add(1)
+	c.latency.Add(int64(d))
+	c.lastUsed.Store(time.Now().UnixNano())
+	if err == nil {
+		return ""
+	}
+	category := FailureCategory(err)
+	c.failures[categoryIndex(category)].Add(1)
+	c.failed.Add(1)
+	return category
+}
+
+// snapshot returns the counts as metrics of the named strategy
+func (c *partitionCounters) snapshot(name string) *StrategyMetrics {
+	failed := c.failed.Load()
+	usage := c.usage.Load()
+	metrics := &StrategyMetrics{
+		Name:             name,
+		UsageCount:       usage,
+		SuccessRate:      1.0,
+		FailedPartitions: failed,
+	}
+	if usage > 0 {
+		metrics.SuccessRate = float64(usage-failed) / float64(usage)
+		metrics.AverageLatency = time.Duration(c.latency.Load() / usage)
+	}
+	if last := c.lastUsed.Load(); last > 0 {
+		metrics.LastUsed = time.Unix(0, last)
+	}
+	for i := range c.failures {
+		if n := c.failures[i].Load(); n > 0 {
+			if metrics.Failures == nil {
+				metrics.Failures = make(map[string]int64)
+			}
+			metrics.Failures[failureCategories[i]] = n
+		}
+	}
+	return metrics
+}
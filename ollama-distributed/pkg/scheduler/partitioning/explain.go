@@ -0,0 +1,35 @@
+package partitioning
+
+import "sort"
+
+// StrategyCandidate describes a registered strategy considered for a task
+type StrategyCandidate struct {
+	Name      string `json:"name"`
+	CanHandle bool   `json:"can_handle"`
+	Selected  bool   `json:"selected"`
+}
+
+// ExplainStrategy returns the strategy SelectStrategy picks for a task and
+// every registered strategy with whether it could handle the task
+func (pm *PartitionManager) ExplainStrategy(task *PartitionTask) (string, []StrategyCandidate, error) {
+	selected, err := pm.SelectStrategy(task, task.Model, task.Options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := make([]string, 0, len(pm.strategies))
+	for name := range pm.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	candidates := make([]StrategyCandidate, 0, len(names))
+	for _, name := range names {
+		candidates = append(candidates, StrategyCandidate{
+			Name:      name,
+			CanHandle: pm.strategies[name].CanHandle(task),
+			Selected:  name == selected,
+		})
+	}
+	return selected, candidates, nil
+}
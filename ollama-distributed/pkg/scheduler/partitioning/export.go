@@ -0,0 +1,153 @@
+package partitioning
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plan export formats
+const (
+	ExportFormatJSON = "json"
+	ExportFormatDOT  = "dot"
+)
+
+// PlanExport is the JSON view of a partition plan, grouping partitions by
+// the node they are assigned to and listing the dependency edges
+type PlanExport struct {
+	ID               string                 `json:"id"`
+	TaskID           string                 `json:"task_id"`
+	Strategy         string                 `json:"strategy"`
+	CreatedAt        time.Time              `json:"created_at"`
+	EstimatedLatency time.Duration          `json:"estimated_latency"`
+	EstimatedCost    float64                `json:"estimated_cost"`
+	TotalMemory      int64                  `json:"total_memory"`
+	Nodes            []NodeAssignment       `json:"nodes"`
+	Partitions       []Partition            `json:"partitions"`
+	Edges            []PlanEdge             `json:"edges"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// NodeAssignment lists the partitions placed on one node
+type NodeAssignment struct {
+	NodeID           string        `json:"node_id"`
+	Partitions       []string      `json:"partitions"`
+	EstimatedMemory  int64         `json:"estimated_memory"`
+	EstimatedLatency time.Duration `json:"estimated_latency"`
+}
+
+// PlanEdge is a dependency between two partitions: To runs after From
+type PlanEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Export returns the JSON view of the plan
+func (p *PartitionPlan) Export() *PlanExport {
+	export := &PlanExport{
+		ID:               p.ID,
+		TaskID:           p.TaskID,
+		Strategy:         p.Strategy,
+		CreatedAt:        p.CreatedAt,
+		EstimatedLatency: p.EstimatedLatency,
+		EstimatedCost:    p.EstimatedCost,
+		Partitions:       p.Partitions,
+		Nodes:            make([]NodeAssignment, 0),
+		Edges:            make([]PlanEdge, 0),
+		Metadata:         p.Metadata,
+	}
+
+	byNode := make(map[string]*NodeAssignment)
+	for _, partition := range p.Partitions {
+		export.TotalMemory += partition.EstimatedMemory
+
+		assignment, ok := byNode[partition.NodeID]
+		if !ok {
+			assignment = &NodeAssignment{NodeID: partition.NodeID}
+			byNode[partition.NodeID] = assignment
+		}
+		assignment.Partitions = append(assignment.Partitions, partition.ID)
+		assignment.EstimatedMemory += partition.EstimatedMemory
+		assignment.EstimatedLatency += partition.EstimatedLatency
+
+		for _, dep := range partition.Dependencies {
+			export.Edges = append(export.Edges, PlanEdge{From: dep, To: partition.ID})
+		}
+	}
+
+	for _, nodeID := range sortedKeys(byNode) {
+		export.Nodes = append(export.Nodes, *byNode[nodeID])
+	}
+	return export
+}
+
+// WriteDOT writes the plan as a Graphviz digraph: one cluster per node
+// holding its partitions, labelled with their estimated latency and memory,
+// and an edge for every dependency. Render with `dot -Tsvg`.
+func (p *PartitionPlan) WriteDOT(w io.Writer) error {
+	export := p.Export()
+	partitions := make(map[string]Partition, len(p.Partitions))
+	for _, partition := range p.Partitions {
+		partitions[partition.ID] = partition
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(p.ID))
+	fmt.Fprintf(bw, "  label=%s;\n", dotQuote(fmt.Sprintf("%s (%s)\\ntask %s, est. %s, %s",
+		p.ID, p.Strategy, p.TaskID, p.EstimatedLatency, formatBytes(export.TotalMemory))))
+	bw.WriteString("  labelloc=t;\n  rankdir=LR;\n  node [shape=box, style=rounded];\n")
+
+	for i, node := range export.Nodes {
+		fmt.Fprintf(bw, "\n  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "    label=%s;\n", dotQuote(fmt.Sprintf("%s\\n%s, %s",
+			node.NodeID, node.EstimatedLatency, formatBytes(node.EstimatedMemory))))
+		for _, id := range node.Partitions {
+			partition := partitions[id]
+			fmt.Fprintf(bw, "    %s [label=%s];\n", dotQuote(id), dotQuote(fmt.Sprintf("%s\\n%s\\n%s, %s",
+				id, partition.Type, partition.EstimatedLatency, formatBytes(partition.EstimatedMemory))))
+		}
+		bw.WriteString("  }\n")
+	}
+
+	if len(export.Edges) > 0 {
+		bw.WriteString("\n")
+	}
+	for _, edge := range export.Edges {
+		fmt.Fprintf(bw, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+	}
+	bw.WriteString("}\n")
+
+	return bw.Flush()
+}
+
+// dotQuote quotes an identifier or label for DOT. Label line breaks are
+// written as \n by the callers and kept as is.
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func sortedKeys(m map[string]*NodeAssignment) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+package partitioning
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPlan() *PartitionPlan {
+	return &PartitionPlan{
+		ID:               "plan-1",
+		TaskID:           "task-1",
+		Strategy:         "layerwise",
+		EstimatedLatency: 120 * time.Millisecond,
+		Partitions: []Partition{
+			{ID: "layers-0-15", NodeID: "node-b", Type: "layers", EstimatedLatency: 50 * time.Millisecond, EstimatedMemory: 4 << 30},
+			{ID: "layers-16-31", NodeID: "node-a", Type: "layers", EstimatedLatency: 60 * time.Millisecond, EstimatedMemory: 4 << 30, Dependencies: []string{"layers-0-15"}},
+			{ID: "head", NodeID: "node-b", Type: "output", EstimatedLatency: 10 * time.Millisecond, EstimatedMemory: 512 << 20, Dependencies: []string{"layers-16-31"}},
+		},
+	}
+}
+
+func TestPlanExport(t *testing.T) {
+	export := testPlan().Export()
+
+	assert.Equal(t, int64(8<<30+512<<20), export.TotalMemory)
+	require.Len(t, export.Nodes, 2)
+	assert.Equal(t, "node-a", export.Nodes[0].NodeID)
+	assert.Equal(t, []string{"layers-0-15", "head"}, export.Nodes[1].Partitions)
+	assert.Equal(t, 60*time.Millisecond, export.Nodes[1].EstimatedLatency)
+	assert.Equal(t, []PlanEdge{
+		{From: "layers-0-15", To: "layers-16-31"},
+		{From: "layers-16-31", To: "head"},
+	}, export.Edges)
+}
+
+func TestPlanWriteDOT(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, testPlan().WriteDOT(&b))
+	dot := b.String()
+
+	assert.True(t, strings.HasPrefix(dot, `digraph "plan-1" {`))
+	assert.Contains(t, dot, `label="node-a\n60ms, 4.0 GiB";`)
+	assert.Contains(t, dot, `"head" [label="head\noutput\n10ms, 512.0 MiB"];`)
+	assert.Contains(t, dot, `"layers-0-15" -> "layers-16-31";`)
+	assert.Equal(t, 2, strings.Count(dot, "subgraph cluster_"))
+}
+
+func TestPartitionManagerKeepsRecentPlans(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise", Deterministic: true})
+	for i := 0; i < maxRecentPlans+5; i++ {
+		pm.recordPlan(&PartitionPlan{ID: strings.Repeat("x", i)})
+	}
+
+	plans := pm.RecentPlans()
+	require.Len(t, plans, maxRecentPlans)
+	assert.Len(t, plans[0].ID, maxRecentPlans+4)
+
+	_, ok := pm.GetPlan("xxxx")
+	assert.False(t, ok, "evicted")
+	plan, ok := pm.GetPlan(strings.Repeat("x", 10))
+	require.True(t, ok)
+	assert.Len(t, plan.ID, 10)
+}
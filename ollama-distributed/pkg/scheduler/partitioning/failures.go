@@ -0,0 +1,90 @@
+package partitioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Failure categories partition errors are counted under
+const (
+	FailureNoNodes        = "no_nodes"
+	FailureLayerDetection = "layer_detection_failed"
+	FailureNodeRejected   = "node_rejected"
+	FailureTimeout        = "timeout"
+	FailureOther          = "other"
+)
+
+// PartitionError is a partitioning failure with its category. Strategies
+// return it so failures are counted under the right category; other errors
+// count as FailureOther, or FailureTimeout when the context expired.
+type PartitionError struct {
+	Category string
+	Err      error
+}
+
+func (e *PartitionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartitionError) Unwrap() error {
+	return e.Err
+}
+
+// Failf returns a PartitionError of category with a formatted message
+func Failf(category, format string, args ...interface{}) error {
+	return &PartitionError{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// FailureCategory returns the category err is counted under
+func FailureCategory(err error) string {
+	var partitionErr *PartitionError
+	switch {
+	case errors.As(err, &partitionErr):
+		return partitionErr.Category
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	default:
+		return FailureOther
+	}
+}
+
+// FailureObserver is notified of every failed partition
+type FailureObserver func(strategy, category string)
+
+// SetFailureObserver sets the function failed partitions are reported to,
+// e.g. to count them in Prometheus
+func (pm *PartitionManager) SetFailureObserver(fn FailureObserver) {
+	pm.failureFn.Store(&fn)
+}
+
+// account records the outcome of partitioning a task with a strategy. It
+// only touches atomic counters, so partitioning never waits on readers of
+// StrategyMetrics.
+func (pm *PartitionManager) account(strategy string, d time.Duration, err error) {
+	counters, ok := pm.stats.Load(strategy)
+	if !ok {
+		counters, _ = pm.stats.LoadOrStore(strategy, &partitionCounters{})
+	}
+	category := counters.(*partitionCounters).record(d, err)
+
+	if fn := pm.failureFn.Load(); err != nil && fn != nil {
+		(*fn)(strategy, category)
+	}
+}
+
+// StrategyMetrics returns the usage and failures by category of every
+// strategy the manager partitioned tasks with, ordered by name. Unlike
+// GetMetrics of the strategies, the counts include tasks that failed before
+// reaching the strategy, such as when placement rejected every node.
+func (pm *PartitionManager) StrategyMetrics() []*StrategyMetrics {
+	metrics := make([]*StrategyMetrics, 0)
+	pm.stats.Range(func(name, counters interface{}) bool {
+		metrics = append(metrics, counters.(*partitionCounters).snapshot(name.(string)))
+		return true
+	})
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
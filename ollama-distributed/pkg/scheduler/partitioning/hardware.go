@@ -0,0 +1,97 @@
+package partitioning
+
+import (
+	"sort"
+	"time"
+)
+
+// HardwareSummary aggregates the hardware of the nodes a task may be
+// partitioned across. Zero memory and bandwidth are unknown.
+type HardwareSummary struct {
+	Nodes    int `json:"nodes"`
+	GPUs     int `json:"gpus"`
+	GPUNodes int `json:"gpu_nodes"`
+	// MinMemory and TotalMemory are the free memory of the smallest node
+	// and of all nodes, GPU memory on GPU nodes
+	MinMemory   int64 `json:"min_memory"`
+	TotalMemory int64 `json:"total_memory"`
+	// MinBandwidth is the slowest interconnect of a node, in bytes/s
+	MinBandwidth int64         `json:"min_bandwidth"`
+	MaxLatency   time.Duration `json:"max_latency"`
+	// Capabilities are those every node has
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// SummarizeHardware aggregates the hardware of nodes
+func SummarizeHardware(nodes []*NodeInfo) HardwareSummary {
+	var hw HardwareSummary
+	common := map[string]int{}
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		hw.Nodes++
+		hw.MaxLatency = max(hw.MaxLatency, node.Latency)
+
+		if node.Capacity != nil {
+			hw.GPUs += node.Capacity.GPUCount
+			if node.Capacity.GPUCount > 0 {
+				hw.GPUNodes++
+			}
+			if free := freeMemory(node); free > 0 {
+				hw.TotalMemory += free
+				if hw.MinMemory == 0 || free < hw.MinMemory {
+					hw.MinMemory = free
+				}
+			}
+		}
+
+		bandwidth := node.Bandwidth
+		if bandwidth <= 0 && node.Capacity != nil {
+			bandwidth = node.Capacity.NetworkBandwidth
+		}
+		if bandwidth > 0 && (hw.MinBandwidth == 0 || bandwidth < hw.MinBandwidth) {
+			hw.MinBandwidth = bandwidth
+		}
+
+		seen := map[string]bool{}
+		for _, capability := range node.Capabilities {
+			if !seen[capability] {
+				seen[capability] = true
+				common[capability]++
+			}
+		}
+	}
+	for capability, n := range common {
+		if n == hw.Nodes {
+			hw.Capabilities = append(hw.Capabilities, capability)
+		}
+	}
+	sort.Strings(hw.Capabilities)
+	return hw
+}
+
+// freeMemory returns the memory a node has left for model weights: GPU
+// memory on GPU nodes, system memory otherwise
+func freeMemory(node *NodeInfo) int64 {
+	capacity, used := node.Capacity.MemoryBytes, int64(0)
+	if node.Usage != nil {
+		used = node.Usage.MemoryUsage
+	}
+	if node.Capacity.GPUMemoryBytes > 0 {
+		capacity, used = node.Capacity.GPUMemoryBytes, 0
+		if node.Usage != nil {
+			used = node.Usage.GPUMemoryUsage
+		}
+	}
+	return max(capacity-used, 0)
+}
+
+// HardwareSummary returns the task's Hardware, or the summary of its nodes
+// when unset
+func (pt *PartitionTask) HardwareSummary() HardwareSummary {
+	if pt.Hardware != nil {
+		return *pt.Hardware
+	}
+	return SummarizeHardware(pt.Nodes)
+}
@@ -0,0 +1,76 @@
+package partitioning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeHardware(t *testing.T) {
+	hw := SummarizeHardware([]*NodeInfo{
+		{
+			ID:           "gpu-1",
+			Capacity:     &ResourceCapacity{MemoryBytes: 64 << 30, GPUCount: 2, GPUMemoryBytes: 48 << 30},
+			Usage:        &ResourceUsage{MemoryUsage: 60 << 30, GPUMemoryUsage: 8 << 30},
+			Bandwidth:    10_000_000_000,
+			Latency:      2 * time.Millisecond,
+			Capabilities: []string{"cuda", "nvlink"},
+		},
+		{
+			ID:           "cpu-1",
+			Capacity:     &ResourceCapacity{MemoryBytes: 32 << 30, NetworkBandwidth: 125_000_000},
+			Usage:        &ResourceUsage{MemoryUsage: 8 << 30},
+			Latency:      15 * time.Millisecond,
+			Capabilities: []string{"cuda", "avx512"},
+		},
+		{ID: "unknown", Capabilities: []string{"cuda"}},
+		nil,
+	})
+
+	assert.Equal(t, HardwareSummary{
+		Nodes:        3,
+		GPUs:         2,
+		GPUNodes:     1,
+		MinMemory:    24 << 30,
+		TotalMemory:  64 << 30,
+		MinBandwidth: 125_000_000,
+		MaxLatency:   15 * time.Millisecond,
+		Capabilities: []string{"cuda"},
+	}, hw)
+	assert.Equal(t, HardwareSummary{}, SummarizeHardware(nil))
+
+	task := &PartitionTask{Hardware: &HardwareSummary{Nodes: 4}}
+	assert.Equal(t, 4, task.HardwareSummary().Nodes, "given hardware wins over the nodes")
+}
+
+func TestCanHandleConsidersHardware(t *testing.T) {
+	cfg := StrategiesConfig{}.WithDefaults()
+	node := func(id string, memory, bandwidth int64) *NodeInfo {
+		return &NodeInfo{ID: id, Capacity: &ResourceCapacity{MemoryBytes: memory}, Bandwidth: bandwidth}
+	}
+	longContext := map[string]interface{}{"num_ctx": 32768}
+	model := &types.OllamaModel{Name: "llama3:70b", Size: 40 << 30}
+
+	single := &PartitionTask{Nodes: []*NodeInfo{node("a", 64<<30, 0)}, Options: longContext, Model: model}
+	assert.False(t, cfg.canHandle("attention_parallelism", single), "a single node")
+	assert.False(t, cfg.canHandle("sequence_parallelism", single))
+
+	ethernet := &PartitionTask{Nodes: []*NodeInfo{node("a", 32<<30, 1_250_000_000), node("b", 32<<30, 125_000_000)}, Options: longContext, Model: model}
+	assert.False(t, cfg.canHandle("attention_parallelism", ethernet), "1Gbit/s is too slow for tensor parallelism")
+	assert.True(t, cfg.canHandle("sequence_parallelism", ethernet))
+	assert.True(t, cfg.canHandle("layerwise", ethernet), "the two nodes hold the model between them")
+	assert.False(t, cfg.canHandle("data_split", ethernet), "neither node holds a whole replica")
+
+	fabric := &PartitionTask{Nodes: []*NodeInfo{node("a", 16<<30, 25_000_000_000), node("b", 16<<30, 25_000_000_000)}, Options: longContext, Model: model}
+	assert.True(t, cfg.canHandle("attention_parallelism", fabric))
+	assert.False(t, cfg.canHandle("layerwise", fabric), "32GiB cannot hold the model")
+
+	unknown := &PartitionTask{Options: longContext, Model: model}
+	for _, name := range []string{"layerwise", "data_split", "sequence_parallelism", "attention_parallelism"} {
+		assert.True(t, cfg.canHandle(name, unknown), "%s on unknown hardware", name)
+	}
+	unknown.Hardware = &HardwareSummary{Nodes: 1}
+	assert.False(t, cfg.canHandle("attention_parallelism", unknown))
+}
@@ -0,0 +1,173 @@
+package partitioning
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Partition data keys of the pipeline stage a hybrid partition belongs to
+// and its rank among the stage's tensor parallel nodes
+const (
+	DataStage      = "stage"
+	DataTensorRank = "tensor_rank"
+	DataTensorSize = "tensor_size"
+)
+
+// unknownLatency is the latency assumed between nodes never measured, so
+// that measured neighbours are grouped first
+const unknownLatency = time.Second
+
+// hybridStrategy combines pipeline and tensor parallelism
+type hybridStrategy struct {
+	*stubStrategy
+}
+
+// NewHybridParallelismStrategy splits a model's layers into the layerwise
+// strategy's pipeline stages and runs each stage tensor parallel across a
+// group of nodes. The nodes of a stage exchange activations at every
+// layer, so they are grouped by the latencies measured between them, and
+// each stage is followed by the nearest remaining group.
+func NewHybridParallelismStrategy() PartitionStrategy {
+	return &hybridStrategy{stubStrategy: newStubStrategy("hybrid")}
+}
+
+func (s *hybridStrategy) Partition(ctx context.Context, task *PartitionTask) (*PartitionPlan, error) {
+	start := time.Now()
+	plan, err := s.partition(ctx, task)
+	s.counters.record(time.Since(start), err)
+	return plan, err
+}
+
+func (s *hybridStrategy) partition(ctx context.Context, task *PartitionTask) (*PartitionPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &PartitionError{Category: FailureTimeout, Err: err}
+	}
+
+	nodes := make([]string, 0, len(task.Nodes))
+	for _, node := range task.Nodes {
+		if node != nil {
+			nodes = append(nodes, node.ID)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, Failf(FailureNoNodes, "task %s has no nodes to partition across", task.ID)
+	}
+
+	stages := s.tunables().Layerwise.Stages(len(nodes))
+	layers := task.GetNumLayers()
+	if layers > 0 {
+		stages = min(stages, layers)
+	}
+	groups := proximityGroups(nodes, stages, task.distance)
+
+	now := time.Now()
+	plan := &PartitionPlan{
+		ID:        fmt.Sprintf("plan_%s_%d", s.name, now.Unix()),
+		TaskID:    task.ID,
+		Strategy:  s.name,
+		CreatedAt: now,
+	}
+	var previous []string
+	for stage, group := range groups {
+		ids := make([]string, 0, len(group))
+		for rank, nodeID := range group {
+			data := map[string]interface{}{DataStage: stage, DataTensorRank: rank, DataTensorSize: len(group)}
+			if layers > 0 {
+				data[DataLayerStart] = stage * layers / len(groups)
+				data[DataLayerEnd] = (stage+1)*layers/len(groups) - 1
+			}
+			id := fmt.Sprintf("%s_partition_%d", plan.ID, len(plan.Partitions))
+			plan.Partitions = append(plan.Partitions, Partition{
+				ID:           id,
+				NodeID:       nodeID,
+				Type:         "pipeline_stage",
+				Data:         data,
+				Dependencies: append([]string(nil), previous...),
+			})
+			ids = append(ids, id)
+		}
+		if stage > 0 {
+			plan.EstimatedLatency += task.hopLatency(groups[stage-1], group)
+		}
+		previous = ids
+	}
+	plan.Metadata = map[string]interface{}{"stages": len(groups), "stage_nodes": groups}
+	return plan, nil
+}
+
+// proximityGroups splits nodes into stages groups of sizes differing by at
+// most one. The first group is seeded with the first node, each later one
+// with the node nearest to the group before it, and groups grow by the
+// node nearest to all their members; ties go to the earlier node.
+func proximityGroups(nodes []string, stages int, distance func(a, b string) time.Duration) [][]string {
+	remaining := append([]string(nil), nodes...)
+	groups := make([][]string, 0, stages)
+	for i := 0; i < stages; i++ {
+		size := len(nodes) / stages
+		if i < len(nodes)%stages {
+			size++
+		}
+
+		seed := 0
+		if i > 0 {
+			seed = nearest(remaining, groups[i-1], distance)
+		}
+		group := []string{remaining[seed]}
+		remaining = append(remaining[:seed], remaining[seed+1:]...)
+		for len(group) < size {
+			next := nearest(remaining, group, distance)
+			group = append(group, remaining[next])
+			remaining = append(remaining[:next], remaining[next+1:]...)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// nearest returns the index of the candidate whose farthest member of the
+// group is the closest
+func nearest(candidates, group []string, distance func(a, b string) time.Duration) int {
+	best, bestDistance := 0, time.Duration(-1)
+	for i, candidate := range candidates {
+		var farthest time.Duration
+		for _, member := range group {
+			farthest = max(farthest, distance(candidate, member))
+		}
+		if bestDistance < 0 || farthest < bestDistance {
+			best, bestDistance = i, farthest
+		}
+	}
+	return best
+}
+
+// latency returns the measured RTT between two of the task's nodes
+func (pt *PartitionTask) latency(a, b string) (time.Duration, bool) {
+	if rtt, ok := pt.Latencies[a][b]; ok {
+		return rtt, true
+	}
+	rtt, ok := pt.Latencies[b][a]
+	return rtt, ok
+}
+
+// distance is latency with unmeasured pairs assumed far apart
+func (pt *PartitionTask) distance(a, b string) time.Duration {
+	if rtt, ok := pt.latency(a, b); ok {
+		return rtt
+	}
+	return unknownLatency
+}
+
+// hopLatency estimates the time activations take from one stage to the
+// next: the largest measured RTT between their nodes
+func (pt *PartitionTask) hopLatency(from, to []string) time.Duration {
+	var hop time.Duration
+	for _, a := range from {
+		for _, b := range to {
+			if rtt, ok := pt.latency(a, b); ok {
+				hop = max(hop, rtt)
+			}
+		}
+	}
+	return hop
+}
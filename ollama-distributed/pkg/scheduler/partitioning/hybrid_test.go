@@ -0,0 +1,59 @@
+package partitioning
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHybridGroupsStagesByProximity(t *testing.T) {
+	rtts := map[[2]string]time.Duration{
+		{"a", "c"}: time.Millisecond,
+		{"b", "d"}: time.Millisecond,
+		{"c", "d"}: 10 * time.Millisecond,
+		{"a", "b"}: 50 * time.Millisecond,
+		{"a", "d"}: 50 * time.Millisecond,
+		{"b", "c"}: 50 * time.Millisecond,
+	}
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise", Deterministic: true})
+	pm.RegisterStrategy(NewHybridParallelismStrategy())
+	pm.SetTopology(func(a, b string) (time.Duration, bool) {
+		if rtt, ok := rtts[[2]string{a, b}]; ok {
+			return rtt, true
+		}
+		rtt, ok := rtts[[2]string{b, a}]
+		return rtt, ok
+	})
+
+	task := &PartitionTask{
+		ID:      "task-1",
+		Options: map[string]interface{}{"num_layers": 32},
+		Nodes:   []*NodeInfo{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}},
+	}
+	plan, err := pm.Partition(context.Background(), task, "hybrid")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, task.Latencies["c"]["d"])
+
+	assert.Equal(t, [][]string{{"a", "c"}, {"b", "d"}}, plan.Metadata["stage_nodes"])
+	assert.Equal(t, 50*time.Millisecond, plan.EstimatedLatency)
+	require.Len(t, plan.Partitions, 4)
+	first, last, ok := plan.Stage("c")
+	require.True(t, ok)
+	assert.Equal(t, []int{0, 15}, []int{first, last})
+	first, last, _ = plan.Stage("d")
+	assert.Equal(t, []int{16, 31}, []int{first, last})
+	assert.Equal(t, 1, plan.Partitions[3].Data[DataTensorRank])
+
+	// Dependencies survive the deterministic renaming of partitions
+	assert.Empty(t, plan.Partitions[0].Dependencies)
+	assert.Equal(t, []string{"plan_hybrid_task-1_partition_0", "plan_hybrid_task-1_partition_1"}, plan.Partitions[2].Dependencies)
+}
+
+func TestProximityGroupsWithoutLatencies(t *testing.T) {
+	task := &PartitionTask{}
+	groups := proximityGroups([]string{"a", "b", "c", "d", "e"}, 2, task.distance)
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d", "e"}}, groups, "unmeasured nodes keep their order")
+}
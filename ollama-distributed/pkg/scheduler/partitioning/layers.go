@@ -0,0 +1,59 @@
+package partitioning
+
+import "math"
+
+// Partition data keys of the inclusive range of model layers a layerwise
+// partition runs
+const (
+	DataLayerStart = "layer_start"
+	DataLayerEnd   = "layer_end"
+)
+
+// LayerRange returns the first and last layer the partition runs, if it is
+// a layer range
+func (p Partition) LayerRange() (int, int, bool) {
+	start, ok := dataInt(p.Data, DataLayerStart)
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok := dataInt(p.Data, DataLayerEnd)
+	if !ok || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// Stage returns the layers a node runs in the plan: from the first to the
+// last layer of its layer range partitions
+func (plan *PartitionPlan) Stage(nodeID string) (int, int, bool) {
+	first, last, found := 0, 0, false
+	for _, p := range plan.Partitions {
+		if p.NodeID != nodeID {
+			continue
+		}
+		start, end, ok := p.LayerRange()
+		if !ok {
+			continue
+		}
+		if !found {
+			first, last, found = start, end, true
+			continue
+		}
+		first, last = min(first, start), max(last, end)
+	}
+	return first, last, found
+}
+
+// dataInt reads a non-negative integer from partition data, which holds
+// float64 once decoded from JSON
+func dataInt(data map[string]interface{}, key string) (int, bool) {
+	switch v := data[key].(type) {
+	case int:
+		return v, v >= 0
+	case int64:
+		return int(v), v >= 0 && v <= math.MaxInt32
+	case float64:
+		return int(v), v >= 0 && v <= math.MaxInt32 && v == math.Trunc(v)
+	}
+	return 0, false
+}
@@ -0,0 +1,36 @@
+package partitioning
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanStage(t *testing.T) {
+	plan := testPlan()
+	plan.Partitions[0].Data = map[string]interface{}{DataLayerStart: 0, DataLayerEnd: 15}
+	plan.Partitions[1].Data = map[string]interface{}{DataLayerStart: 16, DataLayerEnd: 31}
+
+	first, last, ok := plan.Stage("node-a")
+	require.True(t, ok)
+	assert.Equal(t, [2]int{16, 31}, [2]int{first, last})
+
+	// Decoded from JSON, the layers are float64
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	var decoded PartitionPlan
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	first, last, ok = decoded.Stage("node-b")
+	require.True(t, ok, "the output partition has no layers and is skipped")
+	assert.Equal(t, [2]int{0, 15}, [2]int{first, last})
+
+	_, _, ok = plan.Stage("node-c")
+	assert.False(t, ok)
+
+	_, _, ok = Partition{Data: map[string]interface{}{DataLayerStart: 4, DataLayerEnd: 2}}.LayerRange()
+	assert.False(t, ok)
+	_, _, ok = Partition{Data: map[string]interface{}{DataLayerStart: 1.5, DataLayerEnd: 2}}.LayerRange()
+	assert.False(t, ok)
+}
@@ -0,0 +1,31 @@
+package partitioning
+
+import "github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+
+// NodeFromInfo returns the partitioning view of a node the scheduler or the
+// inference engine knows of. Usage percentages become byte counts.
+func NodeFromInfo(node *types.NodeInfo) *NodeInfo {
+	metadata := make(map[string]interface{}, len(node.Metadata))
+	for k, v := range node.Metadata {
+		metadata[k] = v
+	}
+	return &NodeInfo{
+		ID:      node.ID,
+		Address: node.Address,
+		Models:  node.Models,
+		Capacity: &ResourceCapacity{
+			CPUCores:     node.Capacity.CPU,
+			MemoryBytes:  node.Capacity.Memory,
+			GPUCount:     int(node.Capacity.GPU),
+			StorageBytes: node.Capacity.Disk,
+		},
+		Usage: &ResourceUsage{
+			CPUUsage:     node.Usage.CPU,
+			MemoryUsage:  node.Capacity.Memory - node.FreeMemory(),
+			GPUUsage:     node.Usage.GPU,
+			StorageUsage: int64(float64(node.Capacity.Disk) * node.Usage.Disk / 100),
+			LastUpdated:  node.LastSeen,
+		},
+		Metadata: metadata,
+	}
+}
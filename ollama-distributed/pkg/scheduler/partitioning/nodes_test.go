@@ -0,0 +1,24 @@
+package partitioning
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeFromInfo(t *testing.T) {
+	node := NodeFromInfo(&types.NodeInfo{
+		ID:       "node-1",
+		Address:  "10.0.0.1:11434",
+		Models:   []string{"llama3"},
+		Capacity: types.NodeCapacity{CPU: 8, Memory: 32 << 30, GPU: 2},
+		Usage:    types.NodeUsage{CPU: 40, Memory: 75},
+		Metadata: map[string]string{"zone": "eu-west-1a"},
+	})
+
+	assert.Equal(t, &ResourceCapacity{CPUCores: 8, MemoryBytes: 32 << 30, GPUCount: 2}, node.Capacity)
+	assert.Equal(t, int64(24<<30), node.Usage.MemoryUsage)
+	assert.Equal(t, "eu-west-1a", node.Metadata["zone"])
+	assert.Equal(t, int64(8<<30), SummarizeHardware([]*NodeInfo{node}).MinMemory)
+}
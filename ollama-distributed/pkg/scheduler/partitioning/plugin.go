@@ -0,0 +1,38 @@
+//go:build partitionplugins
+
+package partitioning
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// PluginsSupported reports whether this build can load strategy plugins
+const PluginsSupported = true
+
+// LoadStrategyPlugin opens a Go plugin and registers the strategy returned
+// by its exported NewStrategy function. The plugin must be built with
+// -buildmode=plugin against the same version of this module.
+func (pm *PartitionManager) LoadStrategyPlugin(path string) (string, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open strategy plugin %s: %w", path, err)
+	}
+
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return "", fmt.Errorf("strategy plugin %s: %w", path, err)
+	}
+
+	var strategy PartitionStrategy
+	switch newStrategy := sym.(type) {
+	case func() PartitionStrategy:
+		strategy = newStrategy()
+	case *func() PartitionStrategy:
+		strategy = (*newStrategy)()
+	default:
+		return "", fmt.Errorf("strategy plugin %s: %s has type %T, want func() partitioning.PartitionStrategy", path, PluginSymbol, sym)
+	}
+
+	return pm.registerPluginStrategy(path, strategy)
+}
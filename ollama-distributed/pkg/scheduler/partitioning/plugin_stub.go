@@ -0,0 +1,17 @@
+//go:build !partitionplugins
+
+package partitioning
+
+import "errors"
+
+// PluginsSupported reports whether this build can load strategy plugins
+const PluginsSupported = false
+
+// ErrPluginsUnsupported is returned when loading a strategy plugin in a
+// build without the partitionplugins tag
+var ErrPluginsUnsupported = errors.New("strategy plugins require a build with -tags partitionplugins")
+
+// LoadStrategyPlugin fails: this build cannot load Go plugins
+func (pm *PartitionManager) LoadStrategyPlugin(path string) (string, error) {
+	return "", ErrPluginsUnsupported
+}
@@ -0,0 +1,287 @@
+package partitioning
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFile is the YAML document declaring weighted-rules strategies:
+//
+//	strategies:
+//	  - name: big_models
+//	    fallback: layerwise
+//	    rules:
+//	      - strategy: layerwise
+//	        weight: 2
+//	        when: {min_model_size: 21474836480, min_nodes: 2}
+//	      - strategy: data_split
+//	        when: {max_context: 4096}
+type RulesFile struct {
+	Strategies []RulesStrategyConfig `yaml:"strategies" json:"strategies"`
+}
+
+// RulesStrategyConfig declares a strategy that picks one of the registered
+// strategies for each task by summing the weights of the rules it matches
+type RulesStrategyConfig struct {
+	Name     string         `yaml:"name" json:"name"`
+	Rules    []WeightedRule `yaml:"rules" json:"rules"`
+	Fallback string         `yaml:"fallback" json:"fallback"` // used when no rule matches
+}
+
+// WeightedRule adds Weight to Strategy when the task matches When. A
+// missing weight counts as 1.
+type WeightedRule struct {
+	Strategy string        `yaml:"strategy" json:"strategy"`
+	Weight   float64       `yaml:"weight" json:"weight"`
+	When     RuleCondition `yaml:"when" json:"when"`
+}
+
+// RuleCondition matches task properties. Zero fields match any task.
+type RuleCondition struct {
+	MinModelSize int64  `yaml:"min_model_size" json:"min_model_size,omitempty"`
+	MaxModelSize int64  `yaml:"max_model_size" json:"max_model_size,omitempty"`
+	MinNodes     int    `yaml:"min_nodes" json:"min_nodes,omitempty"`
+	MaxNodes     int    `yaml:"max_nodes" json:"max_nodes,omitempty"`
+	MinContext   int    `yaml:"min_context" json:"min_context,omitempty"`
+	MaxContext   int    `yaml:"max_context" json:"max_context,omitempty"`
+	MinPriority  int    `yaml:"min_priority" json:"min_priority,omitempty"`
+	TaskType     string `yaml:"task_type" json:"task_type,omitempty"`
+	Capability   string `yaml:"capability" json:"capability,omitempty"` // every node must have it
+}
+
+// Matches reports whether the task satisfies every set field
+func (c RuleCondition) Matches(task *PartitionTask) bool {
+	var modelSize int64
+	if task.Model != nil {
+		modelSize = task.Model.Size
+	}
+	if c.MinModelSize > 0 && modelSize < c.MinModelSize {
+		return false
+	}
+	if c.MaxModelSize > 0 && modelSize > c.MaxModelSize {
+		return false
+	}
+	if c.MinNodes > 0 && len(task.Nodes) < c.MinNodes {
+		return false
+	}
+	if c.MaxNodes > 0 && len(task.Nodes) > c.MaxNodes {
+		return false
+	}
+	if c.MinContext > 0 && task.GetNumCtx() < c.MinContext {
+		return false
+	}
+	if c.MaxContext > 0 && task.GetNumCtx() > c.MaxContext {
+		return false
+	}
+	if c.MinPriority > 0 && task.Priority < c.MinPriority {
+		return false
+	}
+	if c.TaskType != "" && task.Type != c.TaskType {
+		return false
+	}
+	if c.Capability != "" {
+		if len(task.Nodes) == 0 {
+			return false
+		}
+		for _, node := range task.Nodes {
+			if node == nil || !hasCapability(node, c.Capability) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasCapability(node *NodeInfo, capability string) bool {
+	for _, c := range node.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// RulesStrategy is a PartitionStrategy composed from the strategies already
+// registered with a PartitionManager
+type RulesStrategy struct {
+	config  RulesStrategyConfig
+	resolve func(name string) (PartitionStrategy, bool)
+
+	counters partitionCounters
+}
+
+// GetName returns the declared strategy name
+func (s *RulesStrategy) GetName() string {
+	return s.config.Name
+}
+
+// CanHandle reports whether the rules choose a strategy able to handle the task
+func (s *RulesStrategy) CanHandle(task *PartitionTask) bool {
+	_, err := s.choose(task)
+	return err == nil
+}
+
+// Partition delegates the task to the strategy with the highest total
+// weight among the matching rules, ties going to the first declared
+func (s *RulesStrategy) Partition(ctx context.Context, task *PartitionTask) (*PartitionPlan, error) {
+	start := time.Now()
+	target, err := s.choose(task)
+	if err == nil {
+		var plan *PartitionPlan
+		plan, err = target.Partition(ctx, task)
+		if err == nil {
+			if plan.Metadata == nil {
+				plan.Metadata = make(map[string]interface{})
+			}
+			plan.Metadata["rules_strategy"] = s.config.Name
+			plan.Metadata["delegated_to"] = target.GetName()
+			s.counters.record(time.Since(start), nil)
+			return plan, nil
+		}
+	}
+
+	s.counters.record(time.Since(start), err)
+	return nil, err
+}
+
+// Choose returns the name of the strategy the rules pick for a task
+func (s *RulesStrategy) Choose(task *PartitionTask) (string, error) {
+	target, err := s.choose(task)
+	if err != nil {
+		return "", err
+	}
+	return target.GetName(), nil
+}
+
+func (s *RulesStrategy) choose(task *PartitionTask) (PartitionStrategy, error) {
+	scores := make(map[string]float64)
+	var order []string
+	for _, rule := range s.config.Rules {
+		if !rule.When.Matches(task) {
+			continue
+		}
+		if _, seen := scores[rule.Strategy]; !seen {
+			order = append(order, rule.Strategy)
+		}
+		scores[rule.Strategy] += ruleWeight(rule)
+	}
+
+	// Highest weight first; the stable sort keeps declaration order on ties.
+	// Negative weights can veto a strategy other rules chose.
+	candidates := order[:0]
+	for _, name := range order {
+		if scores[name] > 0 {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+	if s.config.Fallback != "" {
+		candidates = append(candidates, s.config.Fallback)
+	}
+
+	for _, name := range candidates {
+		strategy, ok := s.resolve(name)
+		if ok && strategy.CanHandle(task) {
+			return strategy, nil
+		}
+	}
+	return nil, fmt.Errorf("rules strategy %s: no strategy matches task %s", s.config.Name, task.ID)
+}
+
+func ruleWeight(rule WeightedRule) float64 {
+	if rule.Weight == 0 {
+		return 1
+	}
+	return rule.Weight
+}
+
+// GetMetrics returns the usage of the strategy
+func (s *RulesStrategy) GetMetrics() *StrategyMetrics {
+	return s.counters.snapshot(s.config.Name)
+}
+
+// RegisterRulesStrategy validates a declared strategy against the registered
+// strategies and registers it. Rules may only refer to strategies registered
+// before it, which rules out cycles.
+func (pm *PartitionManager) RegisterRulesStrategy(config RulesStrategyConfig) (*RulesStrategy, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("rules strategy has no name")
+	}
+	if _, exists := pm.strategies[config.Name]; exists {
+		return nil, fmt.Errorf("strategy %s is already registered", config.Name)
+	}
+	if len(config.Rules) == 0 && config.Fallback == "" {
+		return nil, fmt.Errorf("rules strategy %s has neither rules nor a fallback", config.Name)
+	}
+
+	for i, rule := range config.Rules {
+		if _, ok := pm.strategies[rule.Strategy]; !ok {
+			return nil, fmt.Errorf("rules strategy %s: rule %d refers to unknown strategy %q", config.Name, i, rule.Strategy)
+		}
+	}
+	if config.Fallback != "" {
+		if _, ok := pm.strategies[config.Fallback]; !ok {
+			return nil, fmt.Errorf("rules strategy %s: unknown fallback strategy %q", config.Name, config.Fallback)
+		}
+	}
+
+	strategy := &RulesStrategy{
+		config: config,
+		resolve: func(name string) (PartitionStrategy, bool) {
+			s, ok := pm.strategies[name]
+			return s, ok
+		},
+	}
+	pm.RegisterStrategy(strategy)
+	return strategy, nil
+}
+
+// LoadRulesFile registers every strategy declared in a YAML rules file, in
+// order, and returns their names
+func (pm *PartitionManager) LoadRulesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read strategy rules: %w", err)
+	}
+
+	var file RulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse strategy rules %s: %w", path, err)
+	}
+
+	names := make([]string, 0, len(file.Strategies))
+	for _, config := range file.Strategies {
+		if _, err := pm.RegisterRulesStrategy(config); err != nil {
+			return names, err
+		}
+		names = append(names, config.Name)
+	}
+	return names, nil
+}
+
+// PluginSymbol is the function a strategy plugin exports:
+//
+//	func NewStrategy() partitioning.PartitionStrategy
+const PluginSymbol = "NewStrategy"
+
+// registerPluginStrategy registers a strategy loaded from a plugin, refusing
+// to replace one already registered
+func (pm *PartitionManager) registerPluginStrategy(path string, strategy PartitionStrategy) (string, error) {
+	if strategy == nil || strategy.GetName() == "" {
+		return "", fmt.Errorf("strategy plugin %s returned no named strategy", path)
+	}
+	name := strategy.GetName()
+	if _, exists := pm.strategies[name]; exists {
+		return "", fmt.Errorf("strategy plugin %s: strategy %s is already registered", path, name)
+	}
+
+	pm.RegisterStrategy(strategy)
+	return name, nil
+}
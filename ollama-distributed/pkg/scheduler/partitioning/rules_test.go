@@ -0,0 +1,136 @@
+package partitioning
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gib = int64(1 << 30)
+
+func newRulesManager() *PartitionManager {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	pm.RegisterStrategy(NewDataSplitStrategy())
+	pm.RegisterStrategy(NewTaskParallelismStrategy())
+	return pm
+}
+
+func rulesTask(modelSize int64, nodes int, numCtx int) *PartitionTask {
+	task := &PartitionTask{
+		ID:      "task-1",
+		Model:   &types.OllamaModel{Name: "m", Size: modelSize},
+		Options: map[string]interface{}{"num_ctx": numCtx},
+	}
+	for i := 0; i < nodes; i++ {
+		task.Nodes = append(task.Nodes, &NodeInfo{ID: string(rune('a' + i))})
+	}
+	return task
+}
+
+func TestRulesStrategyWeights(t *testing.T) {
+	pm := newRulesManager()
+	strategy, err := pm.RegisterRulesStrategy(RulesStrategyConfig{
+		Name:     "weighted",
+		Fallback: "task_parallelism",
+		Rules: []WeightedRule{
+			{Strategy: "layerwise", Weight: 2, When: RuleCondition{MinModelSize: 20 * gib}},
+			{Strategy: "data_split", Weight: 1.5, When: RuleCondition{MinNodes: 2}},
+			{Strategy: "data_split", When: RuleCondition{MaxContext: 4096}},
+			{Strategy: "layerwise", Weight: -5, When: RuleCondition{MinContext: 32768}},
+		},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name string
+		task *PartitionTask
+		want string
+	}{
+		{"big model", rulesTask(40*gib, 1, 8192), "layerwise"},
+		{"data split outweighs", rulesTask(40*gib, 3, 2048), "data_split"},
+		{"model size outweighs nodes", rulesTask(40*gib, 3, 8192), "layerwise"},
+		{"vetoed", rulesTask(40*gib, 1, 65536), "task_parallelism"},
+		{"fallback", rulesTask(gib, 1, 8192), "task_parallelism"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := strategy.Choose(tc.task)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	plan, err := pm.Partition(context.Background(), rulesTask(40*gib, 1, 8192), "weighted")
+	require.NoError(t, err)
+	assert.Equal(t, "layerwise", plan.Strategy)
+	assert.Equal(t, "weighted", plan.Metadata["rules_strategy"])
+	assert.Equal(t, int64(1), strategy.GetMetrics().UsageCount)
+}
+
+func TestRulesStrategyValidation(t *testing.T) {
+	pm := newRulesManager()
+
+	_, err := pm.RegisterRulesStrategy(RulesStrategyConfig{Name: "layerwise", Fallback: "data_split"})
+	assert.Error(t, err, "names must not clash")
+
+	_, err = pm.RegisterRulesStrategy(RulesStrategyConfig{
+		Name:  "bad",
+		Rules: []WeightedRule{{Strategy: "missing"}},
+	})
+	assert.Error(t, err)
+
+	_, err = pm.RegisterRulesStrategy(RulesStrategyConfig{Name: "empty"})
+	assert.Error(t, err)
+
+	// Without a fallback a task no rule matches cannot be handled
+	strategy, err := pm.RegisterRulesStrategy(RulesStrategyConfig{
+		Name:  "gpu_only",
+		Rules: []WeightedRule{{Strategy: "layerwise", When: RuleCondition{Capability: "gpu"}}},
+	})
+	require.NoError(t, err)
+	task := rulesTask(gib, 2, 2048)
+	assert.False(t, strategy.CanHandle(task))
+	for _, node := range task.Nodes {
+		node.Capabilities = []string{"gpu"}
+	}
+	assert.True(t, strategy.CanHandle(task))
+}
+
+func TestLoadRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+strategies:
+  - name: big_models
+    fallback: data_split
+    rules:
+      - strategy: layerwise
+        weight: 2
+        when: {min_model_size: 21474836480, min_nodes: 2}
+  - name: composed
+    rules:
+      - strategy: big_models
+`), 0o644))
+
+	pm := newRulesManager()
+	names, err := pm.LoadRulesFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"big_models", "composed"}, names)
+
+	plan, err := pm.Partition(context.Background(), rulesTask(40*gib, 2, 2048), "composed")
+	require.NoError(t, err)
+	assert.Equal(t, "layerwise", plan.Strategy)
+}
+
+func TestLoadStrategyPluginUnsupported(t *testing.T) {
+	if PluginsSupported {
+		t.Skip("built with strategy plugin support")
+	}
+	_, err := newRulesManager().LoadStrategyPlugin("strategy.so")
+	assert.ErrorIs(t, err, ErrPluginsUnsupported)
+}
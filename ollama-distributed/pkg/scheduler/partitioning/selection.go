@@ -0,0 +1,310 @@
+package partitioning
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrSelectionNotFound is returned when completing an unknown selection
+var ErrSelectionNotFound = errors.New("selection not found")
+
+// defaultSelectionHistorySize is the number of selections kept for
+// inspection when Config.SelectionHistorySize is not set
+const defaultSelectionHistorySize = 1000
+
+// Selection outcomes reported to the SelectionObserver
+const (
+	SelectionSelected  = "selected"
+	SelectionSucceeded = "succeeded"
+	SelectionFailed    = "failed"
+)
+
+// SelectionObserver is notified when a strategy is selected and when the
+// selection completes, e.g. to count selections in Prometheus
+type SelectionObserver func(strategy, outcome string)
+
+// SetSelectionObserver sets the function selections are reported to. It is
+// called with the selection lock held and must not call back into the
+// manager.
+func (pm *PartitionManager) SetSelectionObserver(fn SelectionObserver) {
+	pm.selectionsMu.Lock()
+	defer pm.selectionsMu.Unlock()
+	pm.selectionFn = fn
+}
+
+// selectionRing holds the most recent selections in a fixed-size ring,
+// overwriting the oldest once full
+type selectionRing struct {
+	items []*StrategySelection
+	start int // index of the oldest selection
+	n     int
+}
+
+func newSelectionRing(size int) *selectionRing {
+	if size <= 0 {
+		size = defaultSelectionHistorySize
+	}
+	return &selectionRing{items: make([]*StrategySelection, size)}
+}
+
+// push adds a selection and returns the one it overwrote, if any
+func (r *selectionRing) push(selection *StrategySelection) *StrategySelection {
+	if r.n < len(r.items) {
+		r.items[(r.start+r.n)%len(r.items)] = selection
+		r.n++
+		return nil
+	}
+	evicted := r.items[r.start]
+	r.items[r.start] = selection
+	r.start = (r.start + 1) % len(r.items)
+	return evicted
+}
+
+// oldest returns the oldest selection, or nil when empty
+func (r *selectionRing) oldest() *StrategySelection {
+	if r.n == 0 {
+		return nil
+	}
+	return r.items[r.start]
+}
+
+// pop removes the oldest selection
+func (r *selectionRing) pop() {
+	r.items[r.start] = nil
+	r.start = (r.start + 1) % len(r.items)
+	r.n--
+}
+
+// newestFirst copies the selections, newest first
+func (r *selectionRing) newestFirst() []StrategySelection {
+	history := make([]StrategySelection, r.n)
+	for i := 0; i < r.n; i++ {
+		history[i] = *r.items[(r.start+r.n-1-i)%len(r.items)]
+	}
+	return history
+}
+
+// Selection feedback tuning
+const (
+	// selectionEWMAAlpha is the weight of the newest outcome in the latency
+	// and throughput averages
+	selectionEWMAAlpha = 0.2
+	// selectionFailureStreak is the number of consecutive failures after
+	// which a strategy is only chosen when no other can handle the task
+	selectionFailureStreak = 3
+)
+
+// StrategySelection is a strategy SelectBestStrategy chose for a task and,
+// once the caller completes it, how executing the plan went
+type StrategySelection struct {
+	ID         string        `json:"id"`
+	TaskID     string        `json:"task_id"`
+	Model      string        `json:"model,omitempty"`
+	Strategy   string        `json:"strategy"`
+	SelectedAt time.Time     `json:"selected_at"`
+	Completed  bool          `json:"completed"`
+	Success    bool          `json:"success"`
+	Latency    time.Duration `json:"latency,omitempty"`
+	Throughput float64       `json:"throughput,omitempty"` // e.g. tokens per second
+}
+
+// selectionStats aggregates the completed selections of a strategy
+type selectionStats struct {
+	completed  int64
+	succeeded  int64
+	failStreak int
+	latency    time.Duration // moving average
+	throughput float64       // moving average
+}
+
+// score estimates the success probability of a strategy. The +1/+2
+// smoothing ranks a strategy that has never run at 0.5, above one that
+// keeps failing.
+func (s *selectionStats) score() float64 {
+	if s == nil {
+		return 0.5
+	}
+	return float64(s.succeeded+1) / float64(s.completed+2)
+}
+
+// SelectBestStrategy chooses among the registered strategies able to handle
+// the task by the outcomes of their earlier selections and records the
+// selection. Callers report the outcome of executing the plan with
+// CompleteSelection.
+func (pm *PartitionManager) SelectBestStrategy(task *PartitionTask) (*StrategySelection, error) {
+	var candidates []string
+	for name, strategy := range pm.strategies {
+		if strategy.CanHandle(task) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no strategy can handle task %s", task.ID)
+	}
+
+	pm.selectionsMu.Lock()
+	defer pm.selectionsMu.Unlock()
+
+	pm.selectionSeq++
+	selection := &StrategySelection{
+		ID:         fmt.Sprintf("selection_%d_%d", time.Now().UnixNano(), pm.selectionSeq),
+		TaskID:     task.ID,
+		Strategy:   pm.selectStrategyByPerformance(candidates),
+		SelectedAt: time.Now(),
+	}
+	if task.Model != nil {
+		selection.Model = task.Model.Name
+	}
+
+	pm.expireSelections(selection.SelectedAt)
+	if evicted := pm.selections.push(selection); evicted != nil {
+		delete(pm.pending, evicted.ID)
+	}
+	pm.pending[selection.ID] = selection
+	if pm.selectionFn != nil {
+		pm.selectionFn(selection.Strategy, SelectionSelected)
+	}
+
+	copied := *selection
+	return &copied, nil
+}
+
+// expireSelections drops the selections older than the configured
+// retention. Callers hold selectionsMu.
+func (pm *PartitionManager) expireSelections(now time.Time) {
+	if pm.config.SelectionRetention <= 0 {
+		return
+	}
+	cutoff := now.Add(-pm.config.SelectionRetention)
+	for oldest := pm.selections.oldest(); oldest != nil && oldest.SelectedAt.Before(cutoff); oldest = pm.selections.oldest() {
+		delete(pm.pending, oldest.ID)
+		pm.selections.pop()
+	}
+}
+
+// selectStrategyByPerformance orders candidates by their smoothed success
+// rate, then by average latency and throughput, and returns the best.
+// Strategies on a failure streak go last; the default strategy wins ties
+// between strategies without outcomes. Callers hold selectionsMu.
+func (pm *PartitionManager) selectStrategyByPerformance(candidates []string) string {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := pm.selectionStats[candidates[i]], pm.selectionStats[candidates[j]]
+		if aStreak, bStreak := failing(a), failing(b); aStreak != bStreak {
+			return bStreak
+		}
+		if sa, sb := a.score(), b.score(); sa != sb {
+			return sa > sb
+		}
+		if a != nil && b != nil && a.latency != b.latency {
+			return a.latency < b.latency
+		}
+		if a != nil && b != nil && a.throughput != b.throughput {
+			return a.throughput > b.throughput
+		}
+		if isDefault, other := candidates[i] == pm.config.DefaultStrategy, candidates[j] == pm.config.DefaultStrategy; isDefault != other {
+			return isDefault
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates[0]
+}
+
+func failing(s *selectionStats) bool {
+	return s != nil && s.failStreak >= selectionFailureStreak
+}
+
+// CompleteSelection records the outcome of executing the plan of a
+// selection, which later selections are based on. Latency is how long the
+// execution took and throughput its rate, e.g. in tokens per second; zero
+// values are left out of the averages.
+func (pm *PartitionManager) CompleteSelection(selectionID string, success bool, latency time.Duration, throughput float64) error {
+	pm.selectionsMu.Lock()
+	defer pm.selectionsMu.Unlock()
+
+	selection, ok := pm.pending[selectionID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSelectionNotFound, selectionID)
+	}
+	delete(pm.pending, selectionID)
+	selection.Completed = true
+	selection.Success = success
+	selection.Latency = latency
+	selection.Throughput = throughput
+
+	stats, ok := pm.selectionStats[selection.Strategy]
+	if !ok {
+		stats = &selectionStats{}
+		pm.selectionStats[selection.Strategy] = stats
+	}
+	stats.completed++
+	outcome := SelectionSucceeded
+	if success {
+		stats.succeeded++
+		stats.failStreak = 0
+	} else {
+		stats.failStreak++
+		outcome = SelectionFailed
+	}
+	if pm.selectionFn != nil {
+		pm.selectionFn(selection.Strategy, outcome)
+	}
+	if latency > 0 {
+		if stats.latency == 0 {
+			stats.latency = latency
+		} else {
+			stats.latency = time.Duration(selectionEWMAAlpha*float64(latency) + (1-selectionEWMAAlpha)*float64(stats.latency))
+		}
+	}
+	if throughput > 0 {
+		if stats.throughput == 0 {
+			stats.throughput = throughput
+		} else {
+			stats.throughput = selectionEWMAAlpha*throughput + (1-selectionEWMAAlpha)*stats.throughput
+		}
+	}
+	return nil
+}
+
+// SelectionHistory returns the selections within the configured history
+// size and retention, newest first
+func (pm *PartitionManager) SelectionHistory() []StrategySelection {
+	pm.selectionsMu.Lock()
+	defer pm.selectionsMu.Unlock()
+
+	pm.expireSelections(time.Now())
+	return pm.selections.newestFirst()
+}
+
+// selectionCSVHeader names the columns WriteSelectionsCSV writes
+var selectionCSVHeader = []string{"id", "task_id", "model", "strategy", "selected_at", "completed", "success", "latency_ms", "throughput"}
+
+// WriteSelectionsCSV writes selections as CSV with a header row, for
+// offline analysis of strategy choices
+func WriteSelectionsCSV(w io.Writer, selections []StrategySelection) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(selectionCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range selections {
+		if err := cw.Write([]string{
+			s.ID,
+			s.TaskID,
+			s.Model,
+			s.Strategy,
+			s.SelectedAt.UTC().Format(time.RFC3339Nano),
+			strconv.FormatBool(s.Completed),
+			strconv.FormatBool(s.Success),
+			strconv.FormatFloat(float64(s.Latency)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.FormatFloat(s.Throughput, 'f', 3, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
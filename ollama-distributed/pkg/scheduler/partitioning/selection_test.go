@@ -0,0 +1,109 @@
+package partitioning
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectBestStrategyLearnsFromOutcomes(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	pm.RegisterStrategy(NewDataSplitStrategy())
+	task := &PartitionTask{ID: "task-1", Nodes: []*NodeInfo{{ID: "a"}}}
+
+	selectAndComplete := func(success bool, latency time.Duration) string {
+		selection, err := pm.SelectBestStrategy(task)
+		require.NoError(t, err)
+		require.NoError(t, pm.CompleteSelection(selection.ID, success, latency, 0))
+		return selection.Strategy
+	}
+
+	// Without outcomes the default strategy is chosen; once it fails, the
+	// untried strategy ranks above it
+	assert.Equal(t, "layerwise", selectAndComplete(false, time.Second))
+	assert.Equal(t, "data_split", selectAndComplete(true, time.Second))
+	assert.Equal(t, "data_split", selectAndComplete(true, time.Second))
+
+	// A failure streak sends a strategy to the back despite its history
+	for i := 0; i < selectionFailureStreak; i++ {
+		assert.Equal(t, "data_split", selectAndComplete(false, time.Second))
+	}
+	assert.Equal(t, "layerwise", selectAndComplete(true, 2*time.Second))
+
+	history := pm.SelectionHistory()
+	require.Len(t, history, 7)
+	assert.Equal(t, "layerwise", history[0].Strategy)
+	assert.True(t, history[0].Completed)
+	assert.True(t, history[0].Success)
+	assert.Equal(t, 2*time.Second, history[0].Latency)
+}
+
+func TestCompleteSelectionErrors(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	_, err := pm.SelectBestStrategy(&PartitionTask{ID: "task-1"})
+	assert.Error(t, err)
+
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	selection, err := pm.SelectBestStrategy(&PartitionTask{ID: "task-1"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, pm.CompleteSelection("missing", true, 0, 0), ErrSelectionNotFound)
+	require.NoError(t, pm.CompleteSelection(selection.ID, true, 0, 0))
+	assert.ErrorIs(t, pm.CompleteSelection(selection.ID, true, 0, 0), ErrSelectionNotFound)
+}
+
+func TestSelectionHistoryRing(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise", SelectionHistorySize: 3})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	var outcomes []string
+	pm.SetSelectionObserver(func(strategy, outcome string) {
+		outcomes = append(outcomes, strategy+"/"+outcome)
+	})
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		selection, err := pm.SelectBestStrategy(&PartitionTask{ID: fmt.Sprintf("task-%d", i)})
+		require.NoError(t, err)
+		ids = append(ids, selection.ID)
+	}
+	history := pm.SelectionHistory()
+	require.Len(t, history, 3)
+	assert.Equal(t, "task-4", history[0].TaskID)
+	assert.Equal(t, "task-2", history[2].TaskID)
+
+	// Evicted selections can no longer be completed
+	assert.ErrorIs(t, pm.CompleteSelection(ids[0], true, 0, 0), ErrSelectionNotFound)
+	require.NoError(t, pm.CompleteSelection(ids[4], false, 1500*time.Millisecond, 2))
+	assert.Equal(t, "layerwise/failed", outcomes[len(outcomes)-1])
+	assert.Len(t, outcomes, 6)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSelectionsCSV(&buf, pm.SelectionHistory()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "id,task_id,model,strategy,selected_at,completed,success,latency_ms,throughput", lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], ",true,false,1500.000,2.000"), lines[1])
+}
+
+func TestSelectionRetention(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise", SelectionRetention: time.Hour})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+
+	old, err := pm.SelectBestStrategy(&PartitionTask{ID: "old"})
+	require.NoError(t, err)
+	pm.selectionsMu.Lock()
+	pm.selections.oldest().SelectedAt = time.Now().Add(-2 * time.Hour)
+	pm.selectionsMu.Unlock()
+
+	_, err = pm.SelectBestStrategy(&PartitionTask{ID: "new"})
+	require.NoError(t, err)
+	history := pm.SelectionHistory()
+	require.Len(t, history, 1)
+	assert.Equal(t, "new", history[0].TaskID)
+	assert.ErrorIs(t, pm.CompleteSelection(old.ID, true, 0, 0), ErrSelectionNotFound)
+}
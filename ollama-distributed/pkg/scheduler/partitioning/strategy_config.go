@@ -0,0 +1,246 @@
+package partitioning
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stage heuristics of the layerwise strategy
+const (
+	// StagesSqrt splits the layers into ceil(sqrt(nodes)) pipeline stages,
+	// leaving the other nodes to replicate stages
+	StagesSqrt = "sqrt"
+	// StagesPerNode gives each node a stage of its own
+	StagesPerNode = "per_node"
+)
+
+// Strategy tuning defaults
+const (
+	DefaultLayerThreshold   = 10
+	DefaultMaxBatchSize     = 1024
+	DefaultSequenceContext  = 8192
+	DefaultAttentionContext = 4096
+
+	// DefaultSequenceBandwidth (1Gbit/s) and DefaultAttentionBandwidth
+	// (10Gbit/s) are the slowest interconnects, in bytes/s, splitting
+	// sequences and attention heads across nodes is worth it over
+	DefaultSequenceBandwidth  = 125_000_000
+	DefaultAttentionBandwidth = 1_250_000_000
+)
+
+// StrategiesConfig holds the tunables of the built-in strategies, by
+// strategy name. Zero fields take their defaults.
+type StrategiesConfig struct {
+	Layerwise            LayerwiseConfig            `yaml:"layerwise" json:"layerwise"`
+	DataSplit            DataSplitConfig            `yaml:"data_split" json:"data_split" mapstructure:"data_split"`
+	TaskParallelism      TaskParallelismConfig      `yaml:"task_parallelism" json:"task_parallelism" mapstructure:"task_parallelism"`
+	SequenceParallelism  SequenceParallelismConfig  `yaml:"sequence_parallelism" json:"sequence_parallelism" mapstructure:"sequence_parallelism"`
+	AttentionParallelism AttentionParallelismConfig `yaml:"attention_parallelism" json:"attention_parallelism" mapstructure:"attention_parallelism"`
+}
+
+// LayerwiseConfig tunes the split of a model's layers into pipeline stages
+type LayerwiseConfig struct {
+	// LayerThreshold is the fewest layers, as given by the task's
+	// num_layers option, worth splitting
+	LayerThreshold int `yaml:"layer_threshold" json:"layer_threshold" mapstructure:"layer_threshold"`
+	MinNodes       int `yaml:"min_nodes" json:"min_nodes" mapstructure:"min_nodes"`
+	// StageHeuristic picks the number of stages: sqrt (the default) or
+	// per_node. MaxStages caps it when set.
+	StageHeuristic string `yaml:"stage_heuristic" json:"stage_heuristic" mapstructure:"stage_heuristic"`
+	MaxStages      int    `yaml:"max_stages" json:"max_stages" mapstructure:"max_stages"`
+}
+
+// DataSplitConfig tunes the split of a batch across model replicas
+type DataSplitConfig struct {
+	// MaxBatchSize is the largest num_batch a task may ask for
+	MaxBatchSize int `yaml:"max_batch_size" json:"max_batch_size" mapstructure:"max_batch_size"`
+	MinNodes     int `yaml:"min_nodes" json:"min_nodes" mapstructure:"min_nodes"`
+}
+
+// TaskParallelismConfig tunes running independent tasks on separate nodes
+type TaskParallelismConfig struct {
+	MinNodes int `yaml:"min_nodes" json:"min_nodes" mapstructure:"min_nodes"`
+}
+
+// SequenceParallelismConfig tunes the split of long sequences across nodes
+type SequenceParallelismConfig struct {
+	// MinContext is the context cutoff below which sequences are not split
+	MinContext int `yaml:"min_context" json:"min_context" mapstructure:"min_context"`
+	MinNodes   int `yaml:"min_nodes" json:"min_nodes" mapstructure:"min_nodes"`
+	// MinBandwidth is the slowest interconnect, in bytes/s, allowed
+	MinBandwidth int64 `yaml:"min_bandwidth" json:"min_bandwidth" mapstructure:"min_bandwidth"`
+}
+
+// AttentionParallelismConfig tunes the split of attention heads across
+// nodes, which exchange activations at every layer
+type AttentionParallelismConfig struct {
+	// MinContext is the context cutoff below which attention is not split
+	MinContext int `yaml:"min_context" json:"min_context" mapstructure:"min_context"`
+	MinNodes   int `yaml:"min_nodes" json:"min_nodes" mapstructure:"min_nodes"`
+	// MinBandwidth is the slowest interconnect, in bytes/s, allowed
+	MinBandwidth int64 `yaml:"min_bandwidth" json:"min_bandwidth" mapstructure:"min_bandwidth"`
+}
+
+// Validate checks the tunables of every strategy
+func (c StrategiesConfig) Validate() error {
+	l := c.Layerwise
+	if l.LayerThreshold < 0 || l.MinNodes < 0 || l.MaxStages < 0 {
+		return fmt.Errorf("layerwise settings must not be negative")
+	}
+	switch l.StageHeuristic {
+	case "", StagesSqrt, StagesPerNode:
+	default:
+		return fmt.Errorf("unknown layerwise stage heuristic %q", l.StageHeuristic)
+	}
+	if c.DataSplit.MaxBatchSize < 0 || c.DataSplit.MinNodes < 0 {
+		return fmt.Errorf("data_split settings must not be negative")
+	}
+	if c.TaskParallelism.MinNodes < 0 {
+		return fmt.Errorf("task_parallelism settings must not be negative")
+	}
+	if sp := c.SequenceParallelism; sp.MinContext < 0 || sp.MinNodes < 0 || sp.MinBandwidth < 0 {
+		return fmt.Errorf("sequence_parallelism settings must not be negative")
+	}
+	if ap := c.AttentionParallelism; ap.MinContext < 0 || ap.MinNodes < 0 || ap.MinBandwidth < 0 {
+		return fmt.Errorf("attention_parallelism settings must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its zero fields set to their
+// defaults
+func (c StrategiesConfig) WithDefaults() StrategiesConfig {
+	if c.Layerwise.LayerThreshold == 0 {
+		c.Layerwise.LayerThreshold = DefaultLayerThreshold
+	}
+	if c.Layerwise.MinNodes == 0 {
+		c.Layerwise.MinNodes = 1
+	}
+	if c.Layerwise.StageHeuristic == "" {
+		c.Layerwise.StageHeuristic = StagesSqrt
+	}
+	if c.DataSplit.MaxBatchSize == 0 {
+		c.DataSplit.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if c.DataSplit.MinNodes == 0 {
+		c.DataSplit.MinNodes = 1
+	}
+	if c.TaskParallelism.MinNodes == 0 {
+		c.TaskParallelism.MinNodes = 1
+	}
+	if c.SequenceParallelism.MinContext == 0 {
+		c.SequenceParallelism.MinContext = DefaultSequenceContext
+	}
+	if c.SequenceParallelism.MinNodes == 0 {
+		c.SequenceParallelism.MinNodes = 2
+	}
+	if c.SequenceParallelism.MinBandwidth == 0 {
+		c.SequenceParallelism.MinBandwidth = DefaultSequenceBandwidth
+	}
+	if c.AttentionParallelism.MinContext == 0 {
+		c.AttentionParallelism.MinContext = DefaultAttentionContext
+	}
+	if c.AttentionParallelism.MinNodes == 0 {
+		c.AttentionParallelism.MinNodes = 2
+	}
+	if c.AttentionParallelism.MinBandwidth == 0 {
+		c.AttentionParallelism.MinBandwidth = DefaultAttentionBandwidth
+	}
+	return c
+}
+
+// Stages returns the number of pipeline stages the layers are split into
+// across nodes
+func (c LayerwiseConfig) Stages(nodes int) int {
+	if nodes <= 0 {
+		return 0
+	}
+	stages := nodes
+	if c.StageHeuristic != StagesPerNode {
+		stages = int(math.Ceil(math.Sqrt(float64(nodes))))
+	}
+	if c.MaxStages > 0 {
+		stages = min(stages, c.MaxStages)
+	}
+	return stages
+}
+
+// canHandle reports whether the named built-in strategy's tunables admit
+// the task, and the hardware it may run on can carry the strategy out.
+// Hardware checks apply to what is known of it: node minimums to tasks
+// listing their nodes, memory and bandwidth to nodes reporting them.
+func (c StrategiesConfig) canHandle(name string, task *PartitionTask) bool {
+	hw := task.HardwareSummary()
+	enough := func(minNodes int) bool {
+		return hw.Nodes == 0 || hw.Nodes >= minNodes
+	}
+	fastEnough := func(minBandwidth int64) bool {
+		return hw.MinBandwidth == 0 || hw.MinBandwidth >= minBandwidth
+	}
+	var modelSize int64
+	if task.Model != nil {
+		modelSize = task.Model.Size
+	}
+
+	switch name {
+	case "layerwise", "hybrid":
+		// The stages hold the model between them
+		layers := task.GetNumLayers()
+		return enough(c.Layerwise.MinNodes) && (layers == 0 || layers >= c.Layerwise.LayerThreshold) &&
+			(hw.TotalMemory == 0 || hw.TotalMemory >= modelSize)
+	case "data_split":
+		// Every node holds a replica of the model
+		return enough(c.DataSplit.MinNodes) && task.GetNumBatch() <= c.DataSplit.MaxBatchSize &&
+			(hw.MinMemory == 0 || hw.MinMemory >= modelSize)
+	case "task_parallelism":
+		return enough(c.TaskParallelism.MinNodes)
+	case "sequence_parallelism":
+		sp := c.SequenceParallelism
+		return enough(sp.MinNodes) && task.GetNumCtx() >= sp.MinContext && fastEnough(sp.MinBandwidth)
+	case "attention_parallelism":
+		ap := c.AttentionParallelism
+		return enough(ap.MinNodes) && task.GetNumCtx() >= ap.MinContext && fastEnough(ap.MinBandwidth)
+	}
+	return true
+}
+
+// ConfigureStrategies validates the tunables of the built-in strategies and
+// applies them to the registered ones and those registered later. It may be
+// called again at any time to reload them.
+func (pm *PartitionManager) ConfigureStrategies(cfg StrategiesConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg = cfg.WithDefaults()
+	pm.strategyConfig.Store(&cfg)
+	return nil
+}
+
+// StrategiesConfig returns the tunables of the built-in strategies,
+// defaults applied
+func (pm *PartitionManager) StrategiesConfig() StrategiesConfig {
+	if cfg := pm.strategyConfig.Load(); cfg != nil {
+		return *cfg
+	}
+	return StrategiesConfig{}.WithDefaults()
+}
+
+// GetNumLayers returns the num_layers option of the task, 0 if unknown
+func (pt *PartitionTask) GetNumLayers() int {
+	return optionInt(pt.Options, "num_layers")
+}
+
+// GetNumBatch returns the num_batch option of the task, 0 if unset
+func (pt *PartitionTask) GetNumBatch() int {
+	return optionInt(pt.Options, "num_batch")
+}
+
+func optionInt(options map[string]interface{}, key string) int {
+	switch v := options[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package partitioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategiesConfigValidate(t *testing.T) {
+	assert.NoError(t, StrategiesConfig{}.Validate())
+	assert.Error(t, StrategiesConfig{Layerwise: LayerwiseConfig{LayerThreshold: -1}}.Validate())
+	assert.Error(t, StrategiesConfig{Layerwise: LayerwiseConfig{StageHeuristic: "cube"}}.Validate())
+	assert.Error(t, StrategiesConfig{DataSplit: DataSplitConfig{MaxBatchSize: -1}}.Validate())
+	assert.Error(t, StrategiesConfig{SequenceParallelism: SequenceParallelismConfig{MinContext: -1}}.Validate())
+	assert.Error(t, StrategiesConfig{AttentionParallelism: AttentionParallelismConfig{MinNodes: -2}}.Validate())
+}
+
+func TestLayerwiseStages(t *testing.T) {
+	cfg := StrategiesConfig{}.WithDefaults().Layerwise
+	assert.Equal(t, 0, cfg.Stages(0))
+	assert.Equal(t, 1, cfg.Stages(1))
+	assert.Equal(t, 3, cfg.Stages(7))
+	assert.Equal(t, 3, cfg.Stages(9))
+	cfg.StageHeuristic = StagesPerNode
+	assert.Equal(t, 7, cfg.Stages(7))
+	cfg.MaxStages = 4
+	assert.Equal(t, 4, cfg.Stages(7))
+}
+
+func TestConfigureStrategiesReloads(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	pm.RegisterStrategy(NewDataSplitStrategy())
+	pm.RegisterStrategy(NewSequenceParallelismStrategy())
+
+	nodes := []*NodeInfo{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}, {ID: "e"}}
+	task := &PartitionTask{ID: "task-1", Nodes: nodes, Options: map[string]interface{}{
+		"num_layers": 8,
+		"num_batch":  2048.0,
+		"num_ctx":    16384,
+	}}
+	_, candidates, err := pm.ExplainStrategy(task)
+	require.NoError(t, err)
+	assert.Equal(t, []StrategyCandidate{
+		{Name: "data_split", CanHandle: false},
+		{Name: "layerwise", CanHandle: false, Selected: true},
+		{Name: "sequence_parallelism", CanHandle: true},
+	}, candidates, "8 layers are under the threshold and the batch over the limit")
+
+	require.NoError(t, pm.ConfigureStrategies(StrategiesConfig{
+		Layerwise:           LayerwiseConfig{LayerThreshold: 4, StageHeuristic: StagesPerNode, MaxStages: 4},
+		DataSplit:           DataSplitConfig{MaxBatchSize: 4096},
+		SequenceParallelism: SequenceParallelismConfig{MinNodes: 8},
+	}))
+	_, candidates, err = pm.ExplainStrategy(task)
+	require.NoError(t, err)
+	assert.True(t, candidates[0].CanHandle)
+	assert.True(t, candidates[1].CanHandle)
+	assert.False(t, candidates[2].CanHandle, "5 nodes are fewer than 8")
+	assert.Equal(t, DefaultSequenceContext, pm.StrategiesConfig().SequenceParallelism.MinContext)
+
+	plan, err := pm.Partition(context.Background(), task, "layerwise")
+	require.NoError(t, err)
+	assert.Equal(t, 4, plan.Metadata["stages"])
+
+	assert.Error(t, pm.ConfigureStrategies(StrategiesConfig{Layerwise: LayerwiseConfig{MinNodes: -1}}))
+	assert.Equal(t, 4, pm.StrategiesConfig().Layerwise.LayerThreshold, "an invalid config is not applied")
+}
@@ -3,8 +3,12 @@ package partitioning
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
 )
 
@@ -12,13 +16,55 @@ import (
 type PartitionManager struct {
 	config     *Config
 	strategies map[string]PartitionStrategy
+	latencyFn  LatencyProvider
+	topologyFn PairLatencyProvider
+	placement  *placement.Policy
+	gate       StrategyGate
+
+	// Tunables of the built-in strategies, reloadable at runtime
+	strategyConfig atomic.Pointer[StrategiesConfig]
+
+	// Recently produced plans, oldest first
+	plans   []*PartitionPlan
+	plansMu sync.RWMutex
+
+	// Outcomes of Partition by strategy name, as *partitionCounters
+	stats     sync.Map
+	failureFn atomic.Pointer[FailureObserver]
+
+	// Recent strategy selections and the outcomes selections are based on
+	selections     *selectionRing
+	pending        map[string]*StrategySelection
+	selectionStats map[string]*selectionStats
+	selectionSeq   int64
+	selectionFn    SelectionObserver
+	selectionsMu   sync.Mutex
 }
 
+// maxRecentPlans bounds the plans kept for inspection
+const maxRecentPlans = 100
+
+// LatencyProvider returns the measured round-trip time to a node, if known
+type LatencyProvider func(nodeID string) (time.Duration, bool)
+
+// PairLatencyProvider returns the measured round-trip time between two
+// nodes, if known
+type PairLatencyProvider func(a, b string) (time.Duration, bool)
+
 // Config holds partitioning configuration
 type Config struct {
 	DefaultStrategy string `json:"default_strategy"`
 	LayerThreshold  int    `json:"layer_threshold"`
 	BatchSizeLimit  int    `json:"batch_size_limit"`
+
+	// Deterministic orders task nodes by ID and derives plan identifiers
+	// from the task, so the same task always produces the same plan
+	Deterministic bool `json:"deterministic"`
+
+	// SelectionHistorySize bounds the strategy selections kept, 1000 if
+	// unset; selections older than SelectionRetention are dropped too
+	SelectionHistorySize int           `json:"selection_history_size"`
+	SelectionRetention   time.Duration `json:"selection_retention"`
 }
 
 // PartitionStrategy defines the interface for partitioning strategies
@@ -40,6 +86,17 @@ type PartitionTask struct {
 	Priority  int                    `json:"priority"`
 	Timeout   time.Duration          `json:"timeout"`
 	CreatedAt time.Time              `json:"created_at"`
+
+	// Hardware aggregates the hardware of the nodes the task may run on,
+	// for tasks not listing them; it is derived from Nodes otherwise
+	Hardware *HardwareSummary `json:"hardware,omitempty"`
+
+	// Tolerations let the task onto nodes with matching taints
+	Tolerations []placement.Toleration `json:"tolerations,omitempty"`
+
+	// Latencies holds the measured RTTs between the task's nodes, by node
+	// ID, filled in from the manager's topology when not given
+	Latencies map[string]map[string]time.Duration `json:"latencies,omitempty"`
 }
 
 // Helper functions for safe options access
@@ -65,6 +122,9 @@ type NodeInfo struct {
 	Latency      time.Duration          `json:"latency"`
 	Bandwidth    int64                  `json:"bandwidth"`
 	Capabilities []string               `json:"capabilities"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	Taints       []placement.Taint      `json:"taints,omitempty"`
+	Models       []string               `json:"models,omitempty"` // models loaded on the node
 	Metadata     map[string]interface{} `json:"metadata"`
 }
 
@@ -132,6 +192,10 @@ type StrategyMetrics struct {
 	SuccessRate    float64       `json:"success_rate"`
 	AverageLatency time.Duration `json:"average_latency"`
 	LastUsed       time.Time     `json:"last_used"`
+
+	FailedPartitions int64 `json:"failed_partitions"`
+	// Failures counts failed partitions by category, e.g. no_nodes
+	Failures map[string]int64 `json:"failures,omitempty"`
 }
 
 // NewPartitionManager creates a new partition manager
@@ -139,55 +203,300 @@ func NewPartitionManager(config *Config) *PartitionManager {
 	return &PartitionManager{
 		config:     config,
 		strategies: make(map[string]PartitionStrategy),
+
+		selections:     newSelectionRing(config.SelectionHistorySize),
+		pending:        make(map[string]*StrategySelection),
+		selectionStats: make(map[string]*selectionStats),
 	}
 }
 
-// RegisterStrategy registers a partitioning strategy
+// RegisterStrategy registers a partitioning strategy. Configurable
+// strategies read their tunables from the manager from then on.
 func (pm *PartitionManager) RegisterStrategy(strategy PartitionStrategy) {
+	if configurable, ok := strategy.(ConfigurableStrategy); ok {
+		configurable.UseConfig(pm.StrategiesConfig)
+	}
 	pm.strategies[strategy.GetName()] = strategy
 }
 
+// SetLatencyProvider sets the source of measured node latencies used to
+// fill in NodeInfo.Latency for topology-aware partitioning
+func (pm *PartitionManager) SetLatencyProvider(fn LatencyProvider) {
+	pm.latencyFn = fn
+}
+
+// SetTopology sets the source of measured latencies between nodes used to
+// fill in PartitionTask.Latencies, by which the hybrid strategy groups
+// pipeline stages
+func (pm *PartitionManager) SetTopology(fn PairLatencyProvider) {
+	pm.topologyFn = fn
+}
+
+// SetPlacementPolicy sets the affinity and anti-affinity constraints task
+// nodes are filtered and ordered by before partitioning
+func (pm *PartitionManager) SetPlacementPolicy(policy *placement.Policy) {
+	pm.placement = policy
+}
+
+// StrategyGate reports whether a strategy may partition a task, e.g. by a
+// feature flag rolling a new strategy out to part of the traffic
+type StrategyGate func(strategy string, task *PartitionTask) bool
+
+// SetStrategyGate sets the gate strategies other than the default must pass;
+// tasks it turns away are partitioned with the default strategy
+func (pm *PartitionManager) SetStrategyGate(gate StrategyGate) {
+	pm.gate = gate
+}
+
+// gated returns the strategy to partition a task with instead of the one
+// requested
+func (pm *PartitionManager) gated(strategyName string, task *PartitionTask) string {
+	if pm.gate == nil || strategyName == pm.config.DefaultStrategy || pm.gate(strategyName, task) {
+		return strategyName
+	}
+	return pm.config.DefaultStrategy
+}
+
 // SelectStrategy selects the best partitioning strategy for a task
 func (pm *PartitionManager) SelectStrategy(task interface{}, model *types.OllamaModel, opts map[string]interface{}) (string, error) {
 	return pm.config.DefaultStrategy, nil
 }
 
-// Partition partitions a task using the specified strategy
+// Partition partitions a task using the specified strategy. Failures are
+// counted by strategy and category in StrategyMetrics.
 func (pm *PartitionManager) Partition(ctx context.Context, task *PartitionTask, strategyName string) (*PartitionPlan, error) {
+	start := time.Now()
+	strategyName = pm.gated(strategyName, task)
+	plan, err := pm.partition(ctx, task, strategyName)
+	pm.account(strategyName, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.recordPlan(plan)
+	return plan, nil
+}
+
+// DryRun partitions a task like Partition without keeping the plan
+func (pm *PartitionManager) DryRun(ctx context.Context, task *PartitionTask, strategyName string) (*PartitionPlan, error) {
+	return pm.partition(ctx, task, pm.gated(strategyName, task))
+}
+
+func (pm *PartitionManager) partition(ctx context.Context, task *PartitionTask, strategyName string) (*PartitionPlan, error) {
 	// Use specified strategy or default
 	strategy, exists := pm.strategies[strategyName]
 	if !exists {
 		// Create a default stub strategy
-		strategy = &stubStrategy{name: strategyName}
+		stub := newStubStrategy(strategyName)
+		stub.UseConfig(pm.StrategiesConfig)
+		strategy = stub
+	}
+
+	if pm.latencyFn != nil {
+		for _, node := range task.Nodes {
+			if node == nil || node.Latency > 0 {
+				continue
+			}
+			if rtt, ok := pm.latencyFn(node.ID); ok {
+				node.Latency = rtt
+			}
+		}
+	}
+
+	if pm.topologyFn != nil && task.Latencies == nil {
+		task.Latencies = make(map[string]map[string]time.Duration)
+		for _, a := range task.Nodes {
+			for _, b := range task.Nodes {
+				if a == nil || b == nil || a.ID == b.ID {
+					continue
+				}
+				if rtt, ok := pm.topologyFn(a.ID, b.ID); ok {
+					if task.Latencies[a.ID] == nil {
+						task.Latencies[a.ID] = make(map[string]time.Duration)
+					}
+					task.Latencies[a.ID][b.ID] = rtt
+				}
+			}
+		}
+	}
+
+	if pm.config.Deterministic {
+		// Sort a copy, leaving the caller's slice in its order
+		nodes := append([]*NodeInfo(nil), task.Nodes...)
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodeID(nodes[i]) < nodeID(nodes[j])
+		})
+		task.Nodes = nodes
+	}
+
+	rejected, err := pm.applyPlacement(task)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := strategy.Partition(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	if len(rejected) > 0 {
+		if plan.Metadata == nil {
+			plan.Metadata = make(map[string]interface{})
+		}
+		plan.Metadata["placement_rejected"] = rejected
+	}
+	if pm.config.Deterministic {
+		normalizePlan(plan, task)
 	}
+	return plan, nil
+}
+
+// applyPlacement removes the task nodes the model may not be placed on,
+// including tainted nodes the task does not tolerate, and moves the nodes
+// its affinity rules prefer to the front
+func (pm *PartitionManager) applyPlacement(task *PartitionTask) ([]placement.Rejection, error) {
+	if task.Model == nil || len(task.Nodes) == 0 {
+		return nil, nil
+	}
+
+	byID := make(map[string]*NodeInfo, len(task.Nodes))
+	views := make([]placement.Node, 0, len(task.Nodes))
+	for _, node := range task.Nodes {
+		if node == nil {
+			continue
+		}
+		byID[node.ID] = node
+		views = append(views, placement.Node{ID: node.ID, Labels: node.Labels, Taints: node.Taints, Models: node.Models})
+	}
+
+	allowed, rejected := pm.placement.RankFor(task.Model.Name, task.Tolerations, views)
+	if len(allowed) == 0 {
+		return rejected, Failf(FailureNodeRejected, "no node satisfies the placement constraints of %s", task.Model.Name)
+	}
+
+	nodes := make([]*NodeInfo, 0, len(allowed))
+	for _, view := range allowed {
+		nodes = append(nodes, byID[view.ID])
+	}
+	task.Nodes = nodes
+	return rejected, nil
+}
+
+// recordPlan keeps a plan for later inspection, dropping the oldest once
+// maxRecentPlans are held
+func (pm *PartitionManager) recordPlan(plan *PartitionPlan) {
+	pm.plansMu.Lock()
+	defer pm.plansMu.Unlock()
+
+	if len(pm.plans) >= maxRecentPlans {
+		copy(pm.plans, pm.plans[1:])
+		pm.plans = pm.plans[:len(pm.plans)-1]
+	}
+	pm.plans = append(pm.plans, plan)
+}
+
+// GetPlan returns a recently produced plan by ID
+func (pm *PartitionManager) GetPlan(id string) (*PartitionPlan, bool) {
+	pm.plansMu.RLock()
+	defer pm.plansMu.RUnlock()
+
+	for i := len(pm.plans) - 1; i >= 0; i-- {
+		if pm.plans[i].ID == id {
+			return pm.plans[i], true
+		}
+	}
+	return nil, false
+}
+
+// RecentPlans returns the recently produced plans, newest first
+func (pm *PartitionManager) RecentPlans() []*PartitionPlan {
+	pm.plansMu.RLock()
+	defer pm.plansMu.RUnlock()
+
+	plans := make([]*PartitionPlan, len(pm.plans))
+	for i, plan := range pm.plans {
+		plans[len(pm.plans)-1-i] = plan
+	}
+	return plans
+}
 
-	return strategy.Partition(ctx, task)
+// normalizePlan replaces the time-derived identifiers and timestamps of a
+// plan with ones derived from its task, keeping the dependencies between
+// partitions
+func normalizePlan(plan *PartitionPlan, task *PartitionTask) {
+	plan.ID = fmt.Sprintf("plan_%s_%s", plan.Strategy, task.ID)
+	plan.CreatedAt = task.CreatedAt
+	ids := make(map[string]string, len(plan.Partitions))
+	for i := range plan.Partitions {
+		id := fmt.Sprintf("%s_partition_%d", plan.ID, i)
+		ids[plan.Partitions[i].ID] = id
+		plan.Partitions[i].ID = id
+	}
+	for i := range plan.Partitions {
+		for j, dep := range plan.Partitions[i].Dependencies {
+			if id, ok := ids[dep]; ok {
+				plan.Partitions[i].Dependencies[j] = id
+			}
+		}
+	}
+}
+
+func nodeID(node *NodeInfo) string {
+	if node == nil {
+		return ""
+	}
+	return node.ID
 }
 
-// Stub strategy implementations
+// Stub strategy implementations, tuned by the default StrategiesConfig
+// until registered with a manager
 func NewLayerwiseStrategy() PartitionStrategy {
-	return &stubStrategy{name: "layerwise"}
+	return newStubStrategy("layerwise")
 }
 
 func NewDataSplitStrategy() PartitionStrategy {
-	return &stubStrategy{name: "data_split"}
+	return newStubStrategy("data_split")
 }
 
 func NewTaskParallelismStrategy() PartitionStrategy {
-	return &stubStrategy{name: "task_parallelism"}
+	return newStubStrategy("task_parallelism")
 }
 
 func NewSequenceParallelismStrategy() PartitionStrategy {
-	return &stubStrategy{name: "sequence_parallelism"}
+	return newStubStrategy("sequence_parallelism")
 }
 
 func NewAttentionParallelismStrategy() PartitionStrategy {
-	return &stubStrategy{name: "attention_parallelism"}
+	return newStubStrategy("attention_parallelism")
+}
+
+// ConfigurableStrategy is a strategy tuned by a StrategiesConfig, which it
+// reads through config on every use so reloads apply immediately
+type ConfigurableStrategy interface {
+	PartitionStrategy
+	UseConfig(config func() StrategiesConfig)
 }
 
 // stubStrategy is a simple stub implementation
 type stubStrategy struct {
-	name string
+	name   string
+	config atomic.Pointer[func() StrategiesConfig]
+
+	counters partitionCounters
+}
+
+func newStubStrategy(name string) *stubStrategy {
+	s := &stubStrategy{name: name}
+	s.UseConfig(StrategiesConfig{}.WithDefaults)
+	return s
+}
+
+// UseConfig sets where the strategy reads its tunables from
+func (s *stubStrategy) UseConfig(config func() StrategiesConfig) {
+	s.config.Store(&config)
+}
+
+func (s *stubStrategy) tunables() StrategiesConfig {
+	return (*s.config.Load())()
 }
 
 func (s *stubStrategy) GetName() string {
@@ -195,31 +504,46 @@ func (s *stubStrategy) GetName() string {
 }
 
 func (s *stubStrategy) Partition(ctx context.Context, task *PartitionTask) (*PartitionPlan, error) {
-	return &PartitionPlan{
+	start := time.Now()
+	plan, err := s.partition(ctx, task)
+	s.counters.record(time.Since(start), err)
+	return plan, err
+}
+
+func (s *stubStrategy) partition(ctx context.Context, task *PartitionTask) (*PartitionPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &PartitionError{Category: FailureTimeout, Err: err}
+	}
+
+	if len(task.Nodes) == 0 || task.Nodes[0] == nil {
+		return nil, Failf(FailureNoNodes, "task %s has no nodes to partition across", task.ID)
+	}
+	nodeID := task.Nodes[0].ID
+
+	plan := &PartitionPlan{
 		ID:       fmt.Sprintf("plan_%s_%d", s.name, time.Now().Unix()),
 		TaskID:   task.ID,
 		Strategy: s.name,
 		Partitions: []Partition{
 			{
 				ID:     fmt.Sprintf("partition_%d", time.Now().Unix()),
-				NodeID: "default-node",
+				NodeID: nodeID,
 				Type:   "inference",
 				Data:   make(map[string]interface{}),
 			},
 		},
 		CreatedAt: time.Now(),
-	}, nil
+	}
+	if s.name == "layerwise" {
+		plan.Metadata = map[string]interface{}{"stages": s.tunables().Layerwise.Stages(len(task.Nodes))}
+	}
+	return plan, nil
 }
 
 func (s *stubStrategy) GetMetrics() *StrategyMetrics {
-	return &StrategyMetrics{
-		Name:        s.name,
-		UsageCount:  0,
-		SuccessRate: 1.0,
-		LastUsed:    time.Now(),
-	}
+	return s.counters.snapshot(s.name)
 }
 
 func (s *stubStrategy) CanHandle(task *PartitionTask) bool {
-	return true
+	return s.tunables().canHandle(s.name, task)
 }
@@ -0,0 +1,210 @@
+package partitioning
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicPartitionPlan(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise", Deterministic: true})
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	plan := func(nodeIDs ...string) *PartitionPlan {
+		task := &PartitionTask{ID: "task-1", CreatedAt: createdAt}
+		for _, id := range nodeIDs {
+			task.Nodes = append(task.Nodes, &NodeInfo{ID: id})
+		}
+		p, err := pm.Partition(context.Background(), task, "layerwise")
+		require.NoError(t, err)
+		assert.Equal(t, "a", task.Nodes[0].ID)
+		return p
+	}
+
+	nodes := []*NodeInfo{{ID: "b"}, {ID: "a"}}
+	_, err := pm.Partition(context.Background(), &PartitionTask{ID: "task-2", Nodes: nodes}, "layerwise")
+	require.NoError(t, err)
+	assert.Equal(t, "b", nodes[0].ID, "the caller's nodes are not reordered")
+
+	first := plan("c", "a", "b")
+	assert.Equal(t, "plan_layerwise_task-1", first.ID)
+	assert.Equal(t, createdAt, first.CreatedAt)
+	assert.Equal(t, "plan_layerwise_task-1_partition_0", first.Partitions[0].ID)
+	assert.Equal(t, first, plan("b", "c", "a"))
+}
+
+func TestPartitionAppliesPlacement(t *testing.T) {
+	policy, err := placement.NewPolicy(placement.Constraints{
+		AntiAffinity: []placement.AntiAffinityRule{{Models: []string{"llama3:70b", "mixtral"}}},
+		Affinity: []placement.AffinityRule{
+			{Model: "llama3:70b", Labels: map[string]string{"gpu": "a100"}},
+		},
+	})
+	require.NoError(t, err)
+
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.SetPlacementPolicy(policy)
+
+	nodes := []*NodeInfo{
+		{ID: "busy", Models: []string{"mixtral"}, Labels: map[string]string{"gpu": "a100"}},
+		{ID: "plain"},
+		{ID: "a100", Labels: map[string]string{"gpu": "a100"}},
+	}
+	task := &PartitionTask{
+		ID:    "task-1",
+		Model: &types.OllamaModel{Name: "llama3:70b"},
+		Nodes: nodes,
+	}
+	plan, err := pm.Partition(context.Background(), task, "layerwise")
+	require.NoError(t, err)
+	assert.Equal(t, "busy", nodes[0].ID, "the caller's nodes are left as they are")
+	assert.Equal(t, "a100", plan.Partitions[0].NodeID)
+	require.Len(t, task.Nodes, 2)
+	assert.Equal(t, "plain", task.Nodes[1].ID)
+	assert.Equal(t, []placement.Rejection{{NodeID: "busy", Reason: "anti-affinity with mixtral"}},
+		plan.Metadata["placement_rejected"])
+
+	task.Nodes = task.Nodes[:0]
+	task.Nodes = append(task.Nodes, &NodeInfo{ID: "busy", Models: []string{"mixtral"}})
+	_, err = pm.Partition(context.Background(), task, "layerwise")
+	assert.Error(t, err)
+}
+
+func TestPartitionSkipsTaintedNodes(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	taint := placement.Taint{Key: "dedicated", Value: "training", Effect: placement.TaintNoSchedule}
+
+	task := func() *PartitionTask {
+		return &PartitionTask{
+			ID:    "task-1",
+			Model: &types.OllamaModel{Name: "llama3"},
+			Nodes: []*NodeInfo{{ID: "tainted", Taints: []placement.Taint{taint}}, {ID: "free"}},
+		}
+	}
+
+	plan, err := pm.Partition(context.Background(), task(), "layerwise")
+	require.NoError(t, err)
+	assert.Equal(t, "free", plan.Partitions[0].NodeID)
+
+	tolerant := task()
+	tolerant.Tolerations = []placement.Toleration{{Key: "dedicated", Value: "training"}}
+	plan, err = pm.Partition(context.Background(), tolerant, "layerwise")
+	require.NoError(t, err)
+	assert.Equal(t, "tainted", plan.Partitions[0].NodeID)
+}
+
+func TestPartitionFailureAccounting(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	layerwise := NewLayerwiseStrategy()
+	pm.RegisterStrategy(layerwise)
+	policy, err := placement.NewPolicy(placement.Constraints{
+		AntiAffinity: []placement.AntiAffinityRule{{Models: []string{"llama3", "mixtral"}}},
+	})
+	require.NoError(t, err)
+	pm.SetPlacementPolicy(policy)
+
+	var observed []string
+	pm.SetFailureObserver(func(strategy, category string) {
+		observed = append(observed, strategy+"/"+category)
+	})
+
+	ctx := context.Background()
+	_, err = pm.Partition(ctx, &PartitionTask{ID: "ok", Nodes: []*NodeInfo{{ID: "a"}}}, "layerwise")
+	require.NoError(t, err)
+
+	_, err = pm.Partition(ctx, &PartitionTask{ID: "empty"}, "layerwise")
+	assert.Equal(t, FailureNoNodes, FailureCategory(err))
+
+	_, err = pm.Partition(ctx, &PartitionTask{
+		ID:    "rejected",
+		Model: &types.OllamaModel{Name: "llama3"},
+		Nodes: []*NodeInfo{{ID: "busy", Models: []string{"mixtral"}}},
+	}, "layerwise")
+	assert.Equal(t, FailureNodeRejected, FailureCategory(err))
+
+	expired, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	_, err = pm.Partition(expired, &PartitionTask{ID: "late", Nodes: []*NodeInfo{{ID: "a"}}}, "layerwise")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, FailureTimeout, FailureCategory(err))
+
+	assert.Equal(t, []string{"layerwise/no_nodes", "layerwise/node_rejected", "layerwise/timeout"}, observed)
+
+	metrics := pm.StrategyMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(4), metrics[0].UsageCount)
+	assert.Equal(t, int64(3), metrics[0].FailedPartitions)
+	assert.Equal(t, map[string]int64{FailureNoNodes: 1, FailureNodeRejected: 1, FailureTimeout: 1}, metrics[0].Failures)
+	assert.InDelta(t, 0.25, metrics[0].SuccessRate, 1e-9)
+
+	// The strategy itself never saw the task placement rejected
+	own := layerwise.GetMetrics()
+	assert.Equal(t, int64(3), own.UsageCount)
+	assert.Equal(t, int64(2), own.FailedPartitions)
+	assert.Equal(t, map[string]int64{FailureNoNodes: 1, FailureTimeout: 1}, own.Failures)
+}
+
+func TestStrategyGate(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+	pm.RegisterStrategy(NewDataSplitStrategy())
+	pm.SetStrategyGate(func(strategy string, task *PartitionTask) bool {
+		return task.ID == "canary"
+	})
+
+	ctx := context.Background()
+	plan, err := pm.Partition(ctx, &PartitionTask{ID: "canary", Nodes: []*NodeInfo{{ID: "a"}}}, "data_split")
+	require.NoError(t, err)
+	assert.Equal(t, "data_split", plan.Strategy)
+
+	plan, err = pm.DryRun(ctx, &PartitionTask{ID: "other", Nodes: []*NodeInfo{{ID: "a"}}}, "data_split")
+	require.NoError(t, err)
+	assert.Equal(t, "layerwise", plan.Strategy)
+}
+
+func TestStrategyMetricsConcurrentSnapshots(t *testing.T) {
+	pm := NewPartitionManager(&Config{DefaultStrategy: "layerwise"})
+	pm.RegisterStrategy(NewLayerwiseStrategy())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				task := &PartitionTask{ID: "task"}
+				if i%2 == 0 {
+					task.Nodes = []*NodeInfo{{ID: "a"}}
+				}
+				pm.Partition(context.Background(), task, "layerwise")
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, m := range pm.StrategyMetrics() {
+			require.LessOrEqual(t, m.FailedPartitions, m.UsageCount)
+		}
+	}
+
+	metrics := pm.StrategyMetrics()
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(800), metrics[0].UsageCount)
+	assert.Equal(t, int64(400), metrics[0].Failures[FailureNoNodes])
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+// SetQuarantine sets a check reporting whether a node is quarantined for
+// misbehaving, e.g. by its peer reputation. Without one, no node is.
+func (e *Engine) SetQuarantine(quarantined func(nodeID string) bool) {
+	e.placementMu.Lock()
+	defer e.placementMu.Unlock()
+	e.quarantined = quarantined
+}
+
+// trustedCandidates drops quarantined nodes from the candidates of a
+// request. Quarantined nodes are only deprioritized: when no other node is
+// left, they are all returned.
+func (e *Engine) trustedCandidates(nodes []*NodeInfo) []*NodeInfo {
+	e.placementMu.RLock()
+	quarantined := e.quarantined
+	e.placementMu.RUnlock()
+	if quarantined == nil {
+		return nodes
+	}
+
+	var trusted []*NodeInfo
+	for _, node := range nodes {
+		if !quarantined(node.ID) {
+			trusted = append(trusted, node)
+		}
+	}
+	if len(trusted) == 0 {
+		return nodes
+	}
+	return trusted
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectNodeDeprioritizesQuarantinedNodes(t *testing.T) {
+	e, err := NewEngine(&config.SchedulerConfig{LoadBalancing: "least_connections", QueueSize: 10, WorkerCount: 1, Deterministic: true}, nil, nil)
+	require.NoError(t, err)
+	e.AddTestNode(&NodeInfo{ID: "suspect", Status: NodeStatusOnline, Models: []string{"llama3"}, Usage: NodeUsage{CPU: 10}})
+	e.AddTestNode(&NodeInfo{ID: "honest", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 50}})
+
+	quarantined := map[string]bool{"suspect": true}
+	e.SetQuarantine(func(nodeID string) bool { return quarantined[nodeID] })
+
+	node, err := e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "honest", node.ID, "loading elsewhere beats a quarantined node holding the model")
+
+	quarantined["honest"] = true
+	node, err = e.loadBalancer.SelectNode(&Request{ModelName: "llama3"})
+	require.NoError(t, err)
+	assert.Equal(t, "suspect", node.ID, "with every node quarantined requests still run")
+}
@@ -0,0 +1,434 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// natsPullExpiry is how long a pull request waits for a message before
+// checking for cancellation again
+const natsPullExpiry = time.Second
+
+// NATS is a queue backend on a JetStream work-queue stream consumed
+// through one durable pull consumer shared by all nodes, so each message
+// goes to one node. Messages not acknowledged within the ack timeout are
+// delivered again.
+type NATS struct {
+	cfg  Config
+	conn *natsConn
+}
+
+// NewNATS connects to the NATS server at cfg.URL and creates the stream
+// and durable consumer if they do not exist
+func NewNATS(cfg Config) (*NATS, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "nats" {
+		return nil, fmt.Errorf("invalid nats url %q", cfg.URL)
+	}
+	conn, err := dialNATS(u)
+	if err != nil {
+		return nil, err
+	}
+	n := &NATS{cfg: cfg, conn: conn}
+	if err := n.setup(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+// jsResponse holds the error of a JetStream API response
+type jsResponse struct {
+	Error *struct {
+		Code        int    `json:"code"`
+		ErrCode     int    `json:"err_code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// jsRequest calls the JetStream API and returns its error, if any
+func (n *NATS) jsRequest(ctx context.Context, subject string, payload interface{}) (*jsResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := n.conn.request(ctx, subject, body, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if msg.status == "503" {
+		return nil, fmt.Errorf("jetstream is not enabled on the nats server")
+	}
+	var resp jsResponse
+	if err := json.Unmarshal(msg.data, &resp); err != nil {
+		return nil, fmt.Errorf("invalid jetstream response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (n *NATS) setup() error {
+	ctx := context.Background()
+	info, err := n.jsRequest(ctx, "$JS.API.STREAM.INFO."+n.cfg.Stream, struct{}{})
+	if err != nil {
+		return err
+	}
+	if info.Error != nil {
+		if info.Error.Code != 404 {
+			return fmt.Errorf("failed to look up stream: %s", info.Error.Description)
+		}
+		created, err := n.jsRequest(ctx, "$JS.API.STREAM.CREATE."+n.cfg.Stream, map[string]interface{}{
+			"name":      n.cfg.Stream,
+			"subjects":  []string{n.cfg.Stream},
+			"retention": "workqueue",
+			"storage":   "file",
+		})
+		if err != nil {
+			return err
+		}
+		if created.Error != nil {
+			return fmt.Errorf("failed to create stream: %s", created.Error.Description)
+		}
+	}
+
+	consumer, err := n.jsRequest(ctx, "$JS.API.CONSUMER.CREATE."+n.cfg.Stream+"."+n.cfg.Group, map[string]interface{}{
+		"stream_name": n.cfg.Stream,
+		"config": map[string]interface{}{
+			"durable_name":   n.cfg.Group,
+			"ack_policy":     "explicit",
+			"ack_wait":       n.cfg.AckTimeout.Nanoseconds(),
+			"deliver_policy": "all",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if consumer.Error != nil {
+		return fmt.Errorf("failed to create consumer: %s", consumer.Error.Description)
+	}
+	return nil
+}
+
+// Enqueue publishes the message to the stream and waits for JetStream to
+// store it
+func (n *NATS) Enqueue(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	reply, err := n.conn.request(ctx, n.cfg.Stream, body, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	var ack jsResponse
+	if err := json.Unmarshal(reply.data, &ack); err != nil {
+		return fmt.Errorf("invalid publish acknowledgement: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("failed to store message: %s", ack.Error.Description)
+	}
+	return nil
+}
+
+// Dequeue pulls the next message from the durable consumer
+func (n *NATS) Dequeue(ctx context.Context) (*Delivery, error) {
+	pull, err := json.Marshal(map[string]interface{}{
+		"batch":   1,
+		"expires": natsPullExpiry.Nanoseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	subject := "$JS.API.CONSUMER.MSG.NEXT." + n.cfg.Stream + "." + n.cfg.Group
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reply, err := n.conn.request(ctx, subject, pull, natsPullExpiry+5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		// Status replies such as 404 No Messages and 408 Request Timeout
+		// carry no message
+		if reply.status != "" || reply.reply == "" {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(reply.data, &msg); err != nil {
+			// Not ours; acknowledge it so it is not redelivered forever
+			slog.Warn("dropping undecodable queue message", "stream", n.cfg.Stream, "error", err)
+			n.conn.publish(reply.reply, []byte("+ACK"))
+			continue
+		}
+		ackSubject := reply.reply
+		return &Delivery{
+			Message: msg,
+			Attempt: jsDeliveries(ackSubject),
+			ack: func(ctx context.Context) error {
+				return n.conn.publish(ackSubject, []byte("+ACK"))
+			},
+		}, nil
+	}
+}
+
+// jsDeliveries returns the delivery count from the reply subject of a
+// JetStream message, $JS.ACK.<stream>.<consumer>.<delivered>.<...>, or
+// $JS.ACK.<domain>.<account>.<stream>.<consumer>.<delivered>.<...> on
+// newer servers
+func jsDeliveries(ackSubject string) int {
+	tokens := strings.Split(ackSubject, ".")
+	idx := 4
+	if len(tokens) >= 12 {
+		idx = 6
+	}
+	if len(tokens) <= idx {
+		return 1
+	}
+	n, err := strconv.Atoi(tokens[idx])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Close closes the connection to NATS
+func (n *NATS) Close() error {
+	return n.conn.Close()
+}
+
+// natsMsg is a message received on the inbox
+type natsMsg struct {
+	subject string
+	reply   string
+	status  string // status code of header-only messages, e.g. "404"
+	data    []byte
+}
+
+// natsConn is a NATS client connection that only publishes and receives
+// replies on its own inbox
+type natsConn struct {
+	conn  net.Conn
+	wmu   sync.Mutex
+	w     *bufio.Writer
+	inbox string
+	seq   atomic.Uint64
+
+	mu      sync.Mutex
+	waiting map[string]chan *natsMsg
+	done    chan struct{}
+	err     error // why the connection closed
+}
+
+func dialNATS(u *url.URL) (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &natsConn{
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+		inbox:   "_INBOX." + hex.EncodeToString(token),
+		waiting: make(map[string]chan *natsMsg),
+		done:    make(chan struct{}),
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected nats greeting %q: %v", strings.TrimSpace(line), err)
+	}
+
+	options := map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"headers":       true,
+		"no_responders": true,
+		"lang":          "go",
+		"protocol":      1,
+	}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			options["pass"] = password
+		}
+	}
+	connect, _ := json.Marshal(options)
+	fmt.Fprintf(c.w, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connect, c.inbox)
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "PONG" {
+		conn.Close()
+		return nil, fmt.Errorf("nats connection refused: %s", strings.TrimSpace(line))
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.readLoop(r)
+	return c, nil
+}
+
+// readLoop dispatches inbox messages to the requests waiting for them and
+// answers server pings
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	err := c.read(r)
+
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+	c.conn.Close()
+}
+
+func (c *natsConn) read(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			slog.Warn("nats error", "error", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case strings.HasPrefix(line, "MSG ") || strings.HasPrefix(line, "HMSG "):
+			msg, err := readNATSMsg(line, r)
+			if err != nil {
+				return err
+			}
+			c.mu.Lock()
+			ch := c.waiting[msg.subject]
+			delete(c.waiting, msg.subject)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// readNATSMsg reads the payload of a MSG or HMSG line:
+//
+//	MSG <subject> <sid> [reply-to] <#bytes>
+//	HMSG <subject> <sid> [reply-to] <#header bytes> <#total bytes>
+func readNATSMsg(line string, r *bufio.Reader) (*natsMsg, error) {
+	fields := strings.Fields(line)
+	headers := fields[0] == "HMSG"
+	args := fields[1:]
+	sizes := 1
+	if headers {
+		sizes = 2
+	}
+	if len(args) != 2+sizes && len(args) != 3+sizes {
+		return nil, fmt.Errorf("malformed nats message %q", line)
+	}
+
+	msg := &natsMsg{subject: args[0]}
+	if len(args) == 3+sizes {
+		msg.reply = args[2]
+	}
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed nats message %q", line)
+	}
+	headerLen := 0
+	if headers {
+		if headerLen, err = strconv.Atoi(args[len(args)-2]); err != nil || headerLen > total {
+			return nil, fmt.Errorf("malformed nats message %q", line)
+		}
+	}
+
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if headerLen > 0 {
+		// NATS/1.0 404 No Messages
+		status := strings.Fields(strings.SplitN(string(buf[:headerLen]), "\r\n", 2)[0])
+		if len(status) > 1 {
+			msg.status = status[1]
+		}
+	}
+	msg.data = buf[headerLen:total]
+	return msg, nil
+}
+
+func (c *natsConn) write(s string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if _, err := c.w.WriteString(s); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// publish sends data to subject
+func (c *natsConn) publish(subject string, data []byte) error {
+	return c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data))
+}
+
+// request publishes data to subject and waits up to timeout for the reply
+func (c *natsConn) request(ctx context.Context, subject string, data []byte, timeout time.Duration) (*natsMsg, error) {
+	reply := c.inbox + "." + strconv.FormatUint(c.seq.Add(1), 10)
+	ch := make(chan *natsMsg, 1)
+	c.mu.Lock()
+	c.waiting[reply] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiting, reply)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(fmt.Sprintf("PUB %s %s %d\r\n%s\r\n", subject, reply, len(data), data)); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("nats request to %s timed out", subject)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v", ErrClosed, c.err)
+	}
+}
+
+// Close closes the connection
+func (c *natsConn) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
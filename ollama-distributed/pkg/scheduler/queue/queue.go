@@ -0,0 +1,151 @@
+// Package queue provides the backends scheduler requests wait in before a
+// worker runs them: in memory, or in Redis Streams or NATS JetStream so
+// queued work survives node restarts and any node in the cluster can take
+// it.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backend names accepted by New
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+	BackendNATS   = "nats"
+)
+
+// ErrClosed is returned by backends used after Close
+var ErrClosed = errors.New("queue closed")
+
+// Message is a queued request
+type Message struct {
+	ID   string `json:"id"`
+	Body []byte `json:"body"`
+}
+
+// Delivery is a message handed to a consumer. Messages not acknowledged
+// within the ack timeout, e.g. because the consuming node died, are
+// delivered again.
+type Delivery struct {
+	Message
+	Attempt int // 1 on the first delivery
+
+	ack func(ctx context.Context) error
+}
+
+// Ack removes the message from the queue once it has been processed
+func (d *Delivery) Ack(ctx context.Context) error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack(ctx)
+}
+
+// Backend stores queued messages until a consumer acknowledges them
+type Backend interface {
+	// Enqueue adds a message to the queue
+	Enqueue(ctx context.Context, msg Message) error
+	// Dequeue blocks until a message is available or ctx ends
+	Dequeue(ctx context.Context) (*Delivery, error)
+	// Close releases the connections of the backend
+	Close() error
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend    string        // memory, redis or nats; empty means memory
+	URL        string        // redis://[:password@]host:port[/db] or nats://[user:password@]host:port
+	Stream     string        // Redis stream or JetStream stream and subject
+	Group      string        // consumer group or durable consumer shared by all nodes
+	Consumer   string        // name of this node within the group
+	AckTimeout time.Duration // redelivery delay of unacknowledged messages
+	Size       int           // capacity of the memory backend
+}
+
+// Defaults applied by New to unset fields
+const (
+	DefaultStream     = "ollama-scheduler"
+	DefaultGroup      = "schedulers"
+	DefaultAckTimeout = 5 * time.Minute
+	DefaultSize       = 1024
+)
+
+// New creates the backend cfg selects
+func New(cfg Config) (Backend, error) {
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultStream
+	}
+	if cfg.Group == "" {
+		cfg.Group = DefaultGroup
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = cfg.Group
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = DefaultAckTimeout
+	}
+
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return NewMemory(cfg.Size), nil
+	case BackendRedis:
+		return NewRedis(cfg)
+	case BackendNATS:
+		return NewNATS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown queue backend %q", cfg.Backend)
+	}
+}
+
+// Memory is a queue backend held in a channel, lost on restart
+type Memory struct {
+	messages chan Message
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// NewMemory creates an in-memory backend holding up to size messages
+func NewMemory(size int) *Memory {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Memory{
+		messages: make(chan Message, size),
+		done:     make(chan struct{}),
+	}
+}
+
+// Enqueue adds a message, waiting for room while the queue is full
+func (m *Memory) Enqueue(ctx context.Context, msg Message) error {
+	select {
+	case m.messages <- msg:
+		return nil
+	case <-m.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dequeue returns the oldest message. Acknowledging it is a no-op.
+func (m *Memory) Dequeue(ctx context.Context) (*Delivery, error) {
+	select {
+	case msg := <-m.messages:
+		return &Delivery{Message: msg, Attempt: 1}, nil
+	case <-m.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close wakes blocked callers with ErrClosed
+func (m *Memory) Close() error {
+	m.closed.Do(func() { close(m.done) })
+	return nil
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	backend, err := New(Config{Size: 1})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Enqueue(ctx, Message{ID: "a", Body: []byte("one")}))
+
+	// A full queue waits for room
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, backend.Enqueue(short, Message{ID: "b"}), context.DeadlineExceeded)
+
+	d, err := backend.Dequeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", d.ID)
+	assert.Equal(t, []byte("one"), d.Body)
+	assert.Equal(t, 1, d.Attempt)
+	assert.NoError(t, d.Ack(ctx))
+
+	require.NoError(t, backend.Close())
+	require.NoError(t, backend.Close())
+	_, err = backend.Dequeue(ctx)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestNewRejectsUnknownBackend(t *testing.T) {
+	_, err := New(Config{Backend: "kafka"})
+	assert.Error(t, err)
+	_, err = New(Config{Backend: BackendRedis, URL: "http://localhost"})
+	assert.Error(t, err)
+	_, err = New(Config{Backend: BackendNATS, URL: "redis://localhost"})
+	assert.Error(t, err)
+}
+
+func TestRESPEncoding(t *testing.T) {
+	assert.Equal(t, "*3\r\n$4\r\nXACK\r\n$1\r\ns\r\n$0\r\n\r\n", string(encodeCommand([]string{"XACK", "s", ""})))
+
+	// An XREADGROUP reply: [[stream, [[id, [field, value, ...]]]]]
+	reply := "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*4\r\n$2\r\nid\r\n$1\r\na\r\n$4\r\nbody\r\n$5\r\nhi\r\nx\r\n"
+	v, err := readReply(bufio.NewReader(strings.NewReader(reply)))
+	require.NoError(t, err)
+	stream := v.([]interface{})[0].([]interface{})
+
+	r := &Redis{}
+	d := r.delivery(stream[1], false)
+	require.NotNil(t, d)
+	assert.Equal(t, "a", d.ID)
+	assert.Equal(t, []byte("hi\r\nx"), d.Body)
+
+	v, err = readReply(bufio.NewReader(strings.NewReader("*-1\r\n")))
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	_, err = readReply(bufio.NewReader(strings.NewReader("-BUSYGROUP Consumer Group name already exists\r\n")))
+	assert.EqualError(t, err, "BUSYGROUP Consumer Group name already exists")
+	v, err = readReply(bufio.NewReader(strings.NewReader(":7\r\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), v)
+}
+
+func TestNATSMessages(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("{\"id\":\"a\"}\r\n"))
+	msg, err := readNATSMsg("MSG q 1 $JS.ACK.q.schedulers.3.10.10.1700000000.0 10", r)
+	require.NoError(t, err)
+	assert.Equal(t, "q", msg.subject)
+	assert.Equal(t, `{"id":"a"}`, string(msg.data))
+	assert.Equal(t, 3, jsDeliveries(msg.reply))
+
+	status := "NATS/1.0 404 No Messages\r\n\r\n"
+	r = bufio.NewReader(strings.NewReader(status + "\r\n"))
+	msg, err = readNATSMsg("HMSG _INBOX.x.1 1 28 28", r)
+	require.NoError(t, err)
+	assert.Equal(t, "404", msg.status)
+	assert.Empty(t, msg.data)
+
+	assert.Equal(t, 2, jsDeliveries("$JS.ACK.domain.account.q.schedulers.2.10.10.1700000000.0.token"))
+	assert.Equal(t, 1, jsDeliveries("_INBOX.x"))
+
+	_, err = readNATSMsg("MSG q", bufio.NewReader(strings.NewReader("")))
+	assert.Error(t, err)
+}
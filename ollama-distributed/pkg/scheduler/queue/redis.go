@@ -0,0 +1,310 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisBlock is how long a read waits for new messages before checking
+// for abandoned ones and for cancellation again
+const redisBlock = time.Second
+
+// Redis is a queue backend on a Redis stream read through a consumer
+// group, so each message goes to one node. Messages a node took but did
+// not acknowledge within the ack timeout are claimed by the next reader.
+type Redis struct {
+	cfg    Config
+	cmd    *respConn // enqueues and acknowledgements
+	reader *respConn // blocking reads
+
+	// recovering is set until the entries this consumer took but did not
+	// acknowledge before a restart have been read again
+	recovering bool
+}
+
+// NewRedis connects to the Redis server at cfg.URL and creates the stream
+// and consumer group if they do not exist
+func NewRedis(cfg Config) (*Redis, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis url %q", cfg.URL)
+	}
+	cmd, err := dialRESP(u)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := dialRESP(u)
+	if err != nil {
+		cmd.Close()
+		return nil, err
+	}
+	r := &Redis{cfg: cfg, cmd: cmd, reader: reader, recovering: true}
+
+	_, err = r.cmd.do(time.Time{}, "XGROUP", "CREATE", cfg.Stream, cfg.Group, "0", "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		r.Close()
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return r, nil
+}
+
+// Enqueue appends the message to the stream
+func (r *Redis) Enqueue(ctx context.Context, msg Message) error {
+	deadline, _ := ctx.Deadline()
+	_, err := r.cmd.do(deadline, "XADD", r.cfg.Stream, "*", "id", msg.ID, "body", string(msg.Body))
+	return err
+}
+
+// Dequeue returns a message abandoned by another consumer, or else the
+// next new one
+func (r *Redis) Dequeue(ctx context.Context) (*Delivery, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Entries idle for longer than the ack timeout belong to a
+		// consumer that died or hung
+		reply, err := r.reader.do(time.Time{}, "XAUTOCLAIM", r.cfg.Stream, r.cfg.Group, r.cfg.Consumer,
+			strconv.FormatInt(r.cfg.AckTimeout.Milliseconds(), 10), "0-0", "COUNT", "1")
+		if err != nil {
+			return nil, err
+		}
+		if claimed, ok := reply.([]interface{}); ok && len(claimed) > 1 {
+			if d := r.delivery(claimed[1], true); d != nil {
+				return d, nil
+			}
+		}
+
+		start := ">"
+		if r.recovering {
+			start = "0"
+		}
+		reply, err = r.reader.do(time.Now().Add(redisBlock+5*time.Second), "XREADGROUP", "GROUP", r.cfg.Group, r.cfg.Consumer,
+			"COUNT", "1", "BLOCK", strconv.FormatInt(redisBlock.Milliseconds(), 10), "STREAMS", r.cfg.Stream, start)
+		if err != nil {
+			return nil, err
+		}
+		// The reply is [[stream, entries]], or nil when the block expired
+		streams, _ := reply.([]interface{})
+		if len(streams) == 0 {
+			continue
+		}
+		stream, _ := streams[0].([]interface{})
+		if len(stream) < 2 {
+			continue
+		}
+		if d := r.delivery(stream[1], r.recovering); d != nil {
+			return d, nil
+		}
+		r.recovering = false
+	}
+}
+
+// delivery turns the first of a list of stream entries into a delivery,
+// or returns nil when there is none
+func (r *Redis) delivery(entries interface{}, redelivered bool) *Delivery {
+	list, _ := entries.([]interface{})
+	for _, e := range list {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) < 2 {
+			continue // deleted while pending
+		}
+		id := respString(entry[0])
+		fields, _ := entry[1].([]interface{})
+
+		d := &Delivery{Attempt: 1}
+		for i := 0; i+1 < len(fields); i += 2 {
+			switch respString(fields[i]) {
+			case "id":
+				d.ID = respString(fields[i+1])
+			case "body":
+				d.Body = []byte(respString(fields[i+1]))
+			}
+		}
+		if redelivered {
+			d.Attempt = r.deliveries(id)
+		}
+		d.ack = func(ctx context.Context) error {
+			deadline, _ := ctx.Deadline()
+			if _, err := r.cmd.do(deadline, "XACK", r.cfg.Stream, r.cfg.Group, id); err != nil {
+				return err
+			}
+			_, err := r.cmd.do(deadline, "XDEL", r.cfg.Stream, id)
+			return err
+		}
+		return d
+	}
+	return nil
+}
+
+// deliveries returns how often the entry has been delivered, from the
+// pending entries list
+func (r *Redis) deliveries(id string) int {
+	reply, err := r.reader.do(time.Time{}, "XPENDING", r.cfg.Stream, r.cfg.Group, id, id, "1")
+	if err != nil {
+		return 2
+	}
+	// The reply is [[id, consumer, idle ms, deliveries]]
+	if pending, _ := reply.([]interface{}); len(pending) == 1 {
+		if entry, _ := pending[0].([]interface{}); len(entry) == 4 {
+			if n, ok := entry[3].(int64); ok {
+				return int(n)
+			}
+		}
+	}
+	return 2
+}
+
+// Close closes the connections to Redis
+func (r *Redis) Close() error {
+	err := r.cmd.Close()
+	if rerr := r.reader.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// respConn is a connection speaking the Redis serialization protocol,
+// used for one command at a time
+type respConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply from Redis
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func dialRESP(u *url.URL) (*respConn, error) {
+	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	c := &respConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, password}
+		}
+		if _, err := c.do(time.Time{}, args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := c.do(time.Time{}, "SELECT", db); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to select redis database %s: %w", db, err)
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply, giving up at deadline or, when
+// it is zero, after ten seconds
+func (c *respConn) do(deadline time.Time, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if deadline.IsZero() {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+func (c *respConn) Close() error {
+	return c.conn.Close()
+}
+
+// encodeCommand encodes a command as an array of bulk strings
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readReply reads a reply: a string for simple strings, int64 for
+// integers, []byte for bulk strings, []interface{} for arrays and nil for
+// null replies. Error replies are returned as a redisError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			if err != nil {
+				var replyErr redisError
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+				item = replyErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+}
+
+func respString(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
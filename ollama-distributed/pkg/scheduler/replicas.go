@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// SetUsageObserver sets a function told the model and queue time of every
+// request as a worker takes it, e.g. to scale the replicas of hot models
+func (e *Engine) SetUsageObserver(observe func(model string, queueTime time.Duration)) {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+	e.usageObserver = observe
+}
+
+// observeUsage reports a request taken by a worker to the usage observer
+func (e *Engine) observeUsage(req *Request) {
+	e.statsMu.RLock()
+	observe := e.usageObserver
+	e.statsMu.RUnlock()
+	if observe != nil && req.ModelName != "" {
+		observe(req.ModelName, req.ScheduledAt.Sub(req.CreatedAt))
+	}
+}
+
+// AddModelReplicas places up to count more replicas of a registered model
+// on the least loaded online nodes the placement rules allow that do not
+// hold it yet, and returns those nodes
+func (e *Engine) AddModelReplicas(name string, count int) ([]string, error) {
+	model, ok := e.GetModel(name)
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", name)
+	}
+
+	e.modelsMu.RLock()
+	locations := slices.Clone(model.Locations)
+	e.modelsMu.RUnlock()
+
+	// Least loaded first; the placement rules rank stably, keeping that
+	// order among nodes they rank alike
+	var free []*NodeInfo
+	for _, node := range e.trustedCandidates(e.GetAvailableNodes()) {
+		if !slices.Contains(locations, node.ID) {
+			free = append(free, node)
+		}
+	}
+	sort.SliceStable(free, func(i, j int) bool {
+		return nodeLoad(free[i]) < nodeLoad(free[j])
+	})
+
+	e.placementMu.RLock()
+	policy := e.placement
+	e.placementMu.RUnlock()
+	views := make([]placement.Node, 0, len(free))
+	for _, node := range free {
+		views = append(views, e.labels.Node(node.ID, node.Models))
+	}
+	ranked, _ := policy.RankFor(name, nil, views)
+	if len(ranked) > count {
+		ranked = ranked[:count]
+	}
+
+	added := make([]string, 0, len(ranked))
+	for _, view := range ranked {
+		added = append(added, view.ID)
+	}
+	e.updateReplicas(name, added, nil)
+	return added, nil
+}
+
+// RemoveModelReplicas removes up to count replicas of a model from the most
+// loaded nodes holding it, keeping at least one, and returns those nodes
+func (e *Engine) RemoveModelReplicas(name string, count int) ([]string, error) {
+	model, ok := e.GetModel(name)
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", name)
+	}
+
+	e.modelsMu.RLock()
+	locations := slices.Clone(model.Locations)
+	e.modelsMu.RUnlock()
+
+	e.nodesMu.RLock()
+	holders := make([]*NodeInfo, 0, len(locations))
+	for _, id := range locations {
+		node, exists := e.nodes[id]
+		if !exists {
+			node = &NodeInfo{ID: id}
+		}
+		holders = append(holders, node)
+	}
+	e.nodesMu.RUnlock()
+
+	// Replicas on unreachable nodes serve nothing and go first
+	sort.SliceStable(holders, func(i, j int) bool {
+		online := func(n *NodeInfo) bool { return n.Status == NodeStatusOnline }
+		if online(holders[i]) != online(holders[j]) {
+			return !online(holders[i])
+		}
+		return nodeLoad(holders[i]) > nodeLoad(holders[j])
+	})
+	count = min(count, len(holders)-1)
+	if count <= 0 {
+		return nil, nil
+	}
+
+	removed := make([]string, 0, count)
+	for _, node := range holders[:count] {
+		removed = append(removed, node.ID)
+	}
+	e.updateReplicas(name, nil, removed)
+	return removed, nil
+}
+
+// updateReplicas records replicas of a model added to and removed from
+// nodes in the model and node registries
+func (e *Engine) updateReplicas(name string, added, removed []string) {
+	e.modelsMu.Lock()
+	if model, ok := e.models[name]; ok {
+		model.Locations = slices.DeleteFunc(append(model.Locations, added...), func(id string) bool {
+			return slices.Contains(removed, id)
+		})
+	}
+	e.modelsMu.Unlock()
+
+	e.nodesMu.Lock()
+	defer e.nodesMu.Unlock()
+	for _, id := range added {
+		if node, ok := e.nodes[id]; ok && !slices.Contains(node.Models, name) {
+			node.Models = append(node.Models, name)
+		}
+	}
+	for _, id := range removed {
+		if node, ok := e.nodes[id]; ok {
+			node.Models = slices.DeleteFunc(node.Models, func(m string) bool { return m == name })
+		}
+	}
+}
+
+// nodeLoad orders nodes by how busy they are
+func nodeLoad(node *NodeInfo) float64 {
+	return node.Usage.CPU + node.Usage.GPU + node.Usage.Memory
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelReplicas(t *testing.T) {
+	e, err := NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1, Deterministic: true}, nil, nil)
+	require.NoError(t, err)
+	e.AddTestNode(&NodeInfo{ID: "a", Status: NodeStatusOnline, Models: []string{"llama3"}, Usage: NodeUsage{CPU: 90}})
+	e.AddTestNode(&NodeInfo{ID: "b", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 60}})
+	e.AddTestNode(&NodeInfo{ID: "c", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 10}})
+	e.AddTestNode(&NodeInfo{ID: "d", Status: NodeStatusOnline, Usage: NodeUsage{CPU: 5}})
+	e.AddTestNode(&NodeInfo{ID: "e", Status: NodeStatusOffline})
+	require.NoError(t, e.RegisterModel("llama3", 1<<30, "sha256:abc", "a"))
+
+	policy, err := placement.NewPolicy(placement.Constraints{})
+	require.NoError(t, err)
+	e.SetPlacementPolicy(policy)
+	require.NoError(t, e.SetNodeLabels("d", placement.NodeLabels{
+		Taints: []placement.Taint{{Key: "dedicated", Value: "training", Effect: placement.TaintNoSchedule}},
+	}))
+
+	added, err := e.AddModelReplicas("llama3", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "b"}, added, "least loaded nodes the taints allow")
+	model, _ := e.GetModel("llama3")
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, model.Locations)
+	assert.Contains(t, e.GetNodes()["c"].Models, "llama3")
+
+	added, err = e.AddModelReplicas("llama3", 2)
+	require.NoError(t, err)
+	assert.Empty(t, added, "no node left")
+
+	removed, err := e.RemoveModelReplicas("llama3", 5)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, removed, "busiest first, keeping one")
+	model, _ = e.GetModel("llama3")
+	assert.Equal(t, []string{"c"}, model.Locations)
+	assert.NotContains(t, e.GetNodes()["a"].Models, "llama3")
+
+	_, err = e.AddModelReplicas("mistral", 1)
+	assert.Error(t, err)
+}
+
+func TestUsageObserver(t *testing.T) {
+	e, err := NewEngine(&config.SchedulerConfig{QueueSize: 10, WorkerCount: 1}, nil, nil)
+	require.NoError(t, err)
+
+	var model string
+	var queued time.Duration
+	e.SetUsageObserver(func(m string, queueTime time.Duration) { model, queued = m, queueTime })
+
+	now := time.Now()
+	e.observeUsage(&Request{ModelName: "llama3", CreatedAt: now.Add(-time.Second), ScheduledAt: now})
+	assert.Equal(t, "llama3", model)
+	assert.Equal(t, time.Second, queued)
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/queue"
+)
+
+// sharedQueue holds scheduled requests in a Redis or NATS backend shared by
+// the nodes of the cluster. Requests are taken from it as this node's
+// workers have room, and acknowledged once processed, so requests queued
+// or running on a node that dies are run by another one.
+type sharedQueue struct {
+	backend    queue.Backend
+	ackTimeout time.Duration
+
+	// Response channels of requests scheduled on this node, handed back
+	// when this node takes the request from the shared queue. Requests
+	// taken by other nodes get no response here and are forgotten after
+	// their deadline.
+	mu      sync.Mutex
+	waiting map[string]waitingResponse
+}
+
+type waitingResponse struct {
+	ch       chan *Response
+	deadline time.Time
+}
+
+// newSharedQueue connects to the queue backend cfg selects, or returns nil
+// when requests wait in the in-memory worker queues
+func newSharedQueue(cfg *config.SchedulerConfig, consumer string) (*sharedQueue, error) {
+	if cfg.QueueBackend == "" || cfg.QueueBackend == queue.BackendMemory {
+		return nil, nil
+	}
+	qcfg := queue.Config{
+		Backend:    cfg.QueueBackend,
+		URL:        cfg.Queue.URL,
+		Stream:     cfg.Queue.Stream,
+		Group:      cfg.Queue.Group,
+		Consumer:   consumer,
+		AckTimeout: cfg.Queue.AckTimeout,
+	}
+	if qcfg.AckTimeout <= 0 {
+		qcfg.AckTimeout = queue.DefaultAckTimeout
+	}
+	backend, err := queue.New(qcfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s queue: %w", cfg.QueueBackend, err)
+	}
+	return &sharedQueue{
+		backend:    backend,
+		ackTimeout: qcfg.AckTimeout,
+		waiting:    make(map[string]waitingResponse),
+	}, nil
+}
+
+// queueConsumer names this node within the queue's consumer group
+func queueConsumer(nodeID string) string {
+	if nodeID != "" {
+		return nodeID
+	}
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "scheduler"
+}
+
+// push stores req in the shared queue
+func (q *sharedQueue) push(ctx context.Context, req *Request) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	now := time.Now()
+	q.mu.Lock()
+	for id, w := range q.waiting {
+		if now.After(w.deadline) {
+			delete(q.waiting, id)
+		}
+	}
+	if req.ResponseCh != nil {
+		q.waiting[req.ID] = waitingResponse{ch: req.ResponseCh, deadline: now.Add(req.Timeout + q.ackTimeout)}
+	}
+	q.mu.Unlock()
+
+	if err := q.backend.Enqueue(ctx, queue.Message{ID: req.ID, Body: body}); err != nil {
+		q.mu.Lock()
+		delete(q.waiting, req.ID)
+		q.mu.Unlock()
+		return fmt.Errorf("failed to queue request: %w", err)
+	}
+	return nil
+}
+
+// pop takes the next request from the shared queue, attaching the
+// response channel when it was scheduled on this node
+func (q *sharedQueue) pop(ctx context.Context) (*Request, error) {
+	for {
+		d, err := q.backend.Dequeue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var req Request
+		if err := json.Unmarshal(d.Body, &req); err != nil {
+			slog.Warn("dropping undecodable queued request", "id", d.ID, "error", err)
+			d.Ack(ctx)
+			continue
+		}
+		req.delivery = d
+
+		q.mu.Lock()
+		if w, ok := q.waiting[req.ID]; ok {
+			req.ResponseCh = w.ch
+			delete(q.waiting, req.ID)
+		}
+		q.mu.Unlock()
+		if d.Attempt > 1 {
+			slog.Info("running request abandoned by another node", "request_id", req.CorrelationID(), "attempt", d.Attempt)
+		}
+		return &req, nil
+	}
+}
+
+// consumeSharedQueue hands requests from the shared queue to the workers,
+// taking one whenever a queue slot is free
+func (e *Engine) consumeSharedQueue() {
+	for {
+		select {
+		case e.slots <- struct{}{}:
+		case <-e.ctx.Done():
+			return
+		}
+
+		req, err := e.shared.pop(e.ctx)
+		if err != nil {
+			<-e.slots
+			if e.ctx.Err() != nil {
+				return
+			}
+			slog.Warn("failed to read the scheduler queue", "backend", e.config.QueueBackend, "error", err)
+			select {
+			case <-time.After(time.Second):
+			case <-e.ctx.Done():
+				return
+			}
+			continue
+		}
+		e.enqueue(req)
+	}
+}
+
+// ack removes a request taken from the shared queue once it was processed
+func (r *Request) ack() {
+	if r.delivery == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.delivery.Ack(ctx); err != nil {
+		slog.Warn("failed to acknowledge queued request", "request_id", r.CorrelationID(), "error", err)
+	}
+}
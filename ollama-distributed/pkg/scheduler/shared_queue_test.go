@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedQueueRoundTrip(t *testing.T) {
+	e := newStealingEngine(1)
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	defer e.cancel()
+	e.config = &config.SchedulerConfig{QueueBackend: queue.BackendRedis}
+	e.shared = &sharedQueue{
+		backend:    queue.NewMemory(8),
+		ackTimeout: time.Minute,
+		waiting:    make(map[string]waitingResponse),
+	}
+	go e.consumeSharedQueue()
+
+	// A request scheduled here gets its response channel back; one
+	// scheduled by another node arrives without one
+	local := &Request{ID: "local", ModelName: "llama3", Timeout: time.Second, ResponseCh: make(chan *Response, 1)}
+	require.NoError(t, e.Schedule(local))
+	require.NoError(t, e.shared.backend.Enqueue(context.Background(), queue.Message{ID: "remote", Body: []byte(`{"id":"remote","model_name":"llama3"}`)}))
+
+	got := make(map[string]*Request)
+	for len(got) < 2 {
+		req := e.workers[0].next()
+		if req == nil {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		got[req.ID] = req
+		<-e.slots
+	}
+	assert.Equal(t, "llama3", got["local"].ModelName)
+	assert.NotNil(t, got["local"].ResponseCh)
+	assert.NotNil(t, got["local"].delivery)
+	assert.Nil(t, got["remote"].ResponseCh)
+	assert.Empty(t, e.shared.waiting)
+}
+
+func TestNewSharedQueueMemory(t *testing.T) {
+	shared, err := newSharedQueue(&config.SchedulerConfig{}, "node")
+	require.NoError(t, err)
+	assert.Nil(t, shared)
+
+	_, err = newSharedQueue(&config.SchedulerConfig{QueueBackend: "kafka"}, "node")
+	assert.Error(t, err)
+}
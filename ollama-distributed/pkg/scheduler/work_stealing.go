@@ -0,0 +1,174 @@
+package scheduler
+
+import "sync"
+
+// WorkerQueueStats describes the queue of one scheduler worker
+type WorkerQueueStats struct {
+	WorkerID   int   `json:"worker_id"`
+	Depth      int   `json:"depth"`
+	MaxDepth   int   `json:"max_depth"`
+	Processed  int64 `json:"processed"`
+	Stolen     int64 `json:"stolen"`      // requests this worker took from others
+	StolenFrom int64 `json:"stolen_from"` // requests other workers took from this one
+}
+
+// workDeque is a worker's request queue. The owner takes requests from the
+// front in arrival order; idle workers steal from the back, where a request
+// in a backed-up queue would otherwise wait the longest.
+type workDeque struct {
+	mu       sync.Mutex
+	items    []*Request
+	head     int
+	maxDepth int
+}
+
+// pushBack appends a request to the queue
+func (d *workDeque) pushBack(req *Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.items = append(d.items, req)
+	if depth := len(d.items) - d.head; depth > d.maxDepth {
+		d.maxDepth = depth
+	}
+}
+
+// popFront removes the oldest request, or returns nil when empty
+func (d *workDeque) popFront() *Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.head == len(d.items) {
+		return nil
+	}
+	req := d.items[d.head]
+	d.items[d.head] = nil
+	d.head++
+	d.compact()
+	return req
+}
+
+// popBack removes the newest request, or returns nil when empty
+func (d *workDeque) popBack() *Request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.head == len(d.items) {
+		return nil
+	}
+	last := len(d.items) - 1
+	req := d.items[last]
+	d.items[last] = nil
+	d.items = d.items[:last]
+	d.compact()
+	return req
+}
+
+// compact reclaims the consumed front of the slice; callers hold d.mu
+func (d *workDeque) compact() {
+	if d.head == len(d.items) {
+		d.items = d.items[:0]
+		d.head = 0
+	} else if d.head > 32 && d.head*2 >= len(d.items) {
+		n := copy(d.items, d.items[d.head:])
+		clear(d.items[n:])
+		d.items = d.items[:n]
+		d.head = 0
+	}
+}
+
+// len returns the number of queued requests
+func (d *workDeque) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items) - d.head
+}
+
+// enqueue places a request on the next worker's queue in rotation, or in
+// the fair queue, and wakes a worker to run it. The caller must hold a
+// queue slot.
+func (e *Engine) enqueue(req *Request) {
+	if e.fair != nil {
+		e.fair.push(req)
+		e.wakeIdleWorker(nil)
+		return
+	}
+
+	idx := int(e.nextWorker.Add(1)-1) % len(e.workers)
+	owner := e.workers[idx]
+	owner.queue.pushBack(req)
+	owner.wake()
+
+	// A busy owner would leave the request waiting behind its current one;
+	// let an idle worker steal it instead
+	if !owner.idle.Load() {
+		e.wakeIdleWorker(owner)
+	}
+}
+
+// wakeIdleWorker wakes an idle worker other than except, if there is one
+func (e *Engine) wakeIdleWorker(except *Worker) {
+	for _, w := range e.workers {
+		if w != except && w.idle.Load() {
+			w.wake()
+			return
+		}
+	}
+}
+
+// wake signals the worker that there may be work, without blocking
+func (w *Worker) wake() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the worker's next request, stealing from the deepest other
+// queue when its own is empty. With fair-share scheduling all workers take
+// from the fair queue instead.
+func (w *Worker) next() *Request {
+	if w.engine.fair != nil {
+		return w.engine.fair.pop()
+	}
+	if req := w.queue.popFront(); req != nil {
+		return req
+	}
+
+	var victim *Worker
+	deepest := 0
+	for _, other := range w.engine.workers {
+		if other == w {
+			continue
+		}
+		if depth := other.queue.len(); depth > deepest {
+			victim, deepest = other, depth
+		}
+	}
+	if victim == nil {
+		return nil
+	}
+
+	req := victim.queue.popBack()
+	if req != nil {
+		w.stolen.Add(1)
+		victim.stolenFrom.Add(1)
+	}
+	return req
+}
+
+// queueStats returns the queue statistics of the worker
+func (w *Worker) queueStats() WorkerQueueStats {
+	w.queue.mu.Lock()
+	depth, maxDepth := len(w.queue.items)-w.queue.head, w.queue.maxDepth
+	w.queue.mu.Unlock()
+
+	return WorkerQueueStats{
+		WorkerID:   w.ID,
+		Depth:      depth,
+		MaxDepth:   maxDepth,
+		Processed:  w.processed.Load(),
+		Stolen:     w.stolen.Load(),
+		StolenFrom: w.stolenFrom.Load(),
+	}
+}
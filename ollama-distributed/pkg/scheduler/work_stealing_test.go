@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStealingEngine(workers int) *Engine {
+	e := &Engine{slots: make(chan struct{}, 64)}
+	for i := 0; i < workers; i++ {
+		e.workers = append(e.workers, &Worker{
+			ID:     i,
+			engine: e,
+			stopCh: make(chan struct{}),
+			notify: make(chan struct{}, 1),
+		})
+	}
+	return e
+}
+
+func TestWorkDequeOrder(t *testing.T) {
+	var d workDeque
+	for i := 0; i < 100; i++ {
+		d.pushBack(&Request{ID: fmt.Sprint(i)})
+	}
+	assert.Equal(t, 100, d.len())
+	assert.Equal(t, 100, d.maxDepth)
+
+	assert.Equal(t, "0", d.popFront().ID)
+	assert.Equal(t, "99", d.popBack().ID)
+	for i := 1; i < 60; i++ {
+		require.Equal(t, fmt.Sprint(i), d.popFront().ID)
+	}
+	assert.Equal(t, 39, d.len())
+	assert.Equal(t, "60", d.popFront().ID)
+
+	for d.popBack() != nil {
+	}
+	assert.Nil(t, d.popFront())
+	assert.Equal(t, 0, d.len())
+}
+
+func TestWorkerStealsFromDeepestQueue(t *testing.T) {
+	e := newStealingEngine(3)
+	for i := 0; i < 3; i++ {
+		e.workers[1].queue.pushBack(&Request{ID: fmt.Sprintf("busy-%d", i)})
+	}
+	e.workers[2].queue.pushBack(&Request{ID: "other"})
+
+	req := e.workers[0].next()
+	require.NotNil(t, req)
+	assert.Equal(t, "busy-2", req.ID, "the newest request of the deepest queue is stolen")
+
+	// Its own queue comes first
+	e.workers[0].queue.pushBack(&Request{ID: "own"})
+	assert.Equal(t, "own", e.workers[0].next().ID)
+
+	stats := e.workers[0].queueStats()
+	assert.Equal(t, int64(1), stats.Stolen)
+	assert.Equal(t, int64(1), e.workers[1].queueStats().StolenFrom)
+	assert.Equal(t, 2, e.workers[1].queueStats().Depth)
+	assert.Equal(t, 3, e.workers[1].queueStats().MaxDepth)
+}
+
+func TestEnqueueWakesIdleWorker(t *testing.T) {
+	e := newStealingEngine(2)
+	e.workers[1].idle.Store(true)
+
+	// Worker 0 is busy, so idle worker 1 is woken to steal
+	e.enqueue(&Request{ID: "a"})
+	assert.Len(t, e.workers[0].notify, 1)
+	assert.Len(t, e.workers[1].notify, 1)
+	assert.Equal(t, "a", e.workers[1].next().ID)
+
+	// Requests rotate over the workers' queues
+	e.enqueue(&Request{ID: "b"})
+	assert.Equal(t, 1, e.workers[1].queue.len())
+}
@@ -0,0 +1,188 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager. Requests are signed
+// with Signature Version 4 using the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+type AWSProvider struct {
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+	now          func() time.Time
+}
+
+// NewAWSProvider creates a Secrets Manager provider for region. endpoint
+// overrides the regional endpoint, e.g. for a VPC endpoint.
+func NewAWSProvider(region, endpoint string) (*AWSProvider, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("aws region is required")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+
+	p := &AWSProvider{
+		region:       region,
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	return p, nil
+}
+
+// Name returns the reference prefix
+func (a *AWSProvider) Name() string {
+	return ProviderAWS
+}
+
+// Fetch reads the secret named path. When key is set the secret string is
+// parsed as a JSON object and the key's value returned.
+func (a *AWSProvider) Fetch(ctx context.Context, path, key string) (*Secret, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	a.sign(req, body)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if strings.HasSuffix(out.Type, "ResourceNotFoundException") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("secrets manager returned %d: %s %s", resp.StatusCode, out.Type, out.Message)
+	}
+
+	value := out.SecretString
+	if key != "" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+		}
+		raw, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: key %q", ErrNotFound, key)
+		}
+		value = fmt.Sprint(raw)
+	}
+
+	return &Secret{Value: value, FetchedAt: time.Now()}, nil
+}
+
+// sign adds a Signature Version 4 Authorization header to req
+func (a *AWSProvider) sign(req *http.Request, body []byte) {
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if a.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.sessionToken)
+	}
+
+	u, _ := url.Parse(a.endpoint)
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date", "x-amz-target"}
+	headers := map[string]string{
+		"content-type":         req.Header.Get("Content-Type"),
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+		"x-amz-target":         req.Header.Get("X-Amz-Target"),
+	}
+	if a.sessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+		headers["x-amz-security-token"] = a.sessionToken
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"", // no query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + a.region + "/secretsmanager/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.secretKey), date)
+	key = hmacSHA256(key, a.region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
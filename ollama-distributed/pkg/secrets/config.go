@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+)
+
+// CredentialField is a config field that may hold a secret reference
+type CredentialField struct {
+	Name  string
+	Value *string
+}
+
+// CredentialFields lists the config fields holding credentials
+func CredentialFields(cfg *config.Config) []CredentialField {
+	fields := []CredentialField{
+		{Name: "security.auth.secret_key", Value: &cfg.Security.Auth.SecretKey},
+		{Name: "security.auth.oidc.client_secret", Value: &cfg.Security.Auth.OIDC.ClientSecret},
+		{Name: "p2p.private_key", Value: &cfg.P2P.PrivateKey},
+	}
+	for i := range cfg.Federation.Peers {
+		fields = append(fields, CredentialField{
+			Name:  fmt.Sprintf("federation.peers[%d].token", i),
+			Value: &cfg.Federation.Peers[i].Token,
+		})
+	}
+	for i := range cfg.Tenancy.Tenants {
+		for j := range cfg.Tenancy.Tenants[i].APIKeys {
+			fields = append(fields, CredentialField{
+				Name:  fmt.Sprintf("tenancy.tenants[%d].api_keys[%d]", i, j),
+				Value: &cfg.Tenancy.Tenants[i].APIKeys[j],
+			})
+		}
+	}
+	for i := range cfg.Capture.APIKeys {
+		fields = append(fields, CredentialField{
+			Name:  fmt.Sprintf("capture.api_keys[%d]", i),
+			Value: &cfg.Capture.APIKeys[i],
+		})
+	}
+	fields = append(fields, CredentialField{Name: "capture.database.password", Value: &cfg.Capture.Database.Password})
+	return fields
+}
+
+// FieldReference is a credential field together with the secret it refers to
+type FieldReference struct {
+	Field string
+	Ref   Reference
+}
+
+// References returns the secret references found in credential fields
+func References(cfg *config.Config) []FieldReference {
+	var refs []FieldReference
+	for _, field := range CredentialFields(cfg) {
+		if ref, ok := ParseReference(*field.Value); ok {
+			refs = append(refs, FieldReference{Field: field.Name, Ref: ref})
+		}
+	}
+	return refs
+}
+
+// NewManagerFromConfig creates a manager with the providers needed by the
+// references in cfg. Providers nobody refers to are not set up, so e.g. a
+// missing Vault token only matters when Vault is actually used.
+func NewManagerFromConfig(cfg *config.Config, logger *slog.Logger) (*Manager, error) {
+	sc := cfg.Security.Secrets
+	needed := make(map[string]bool)
+	for _, fr := range References(cfg) {
+		needed[fr.Ref.Provider] = true
+	}
+
+	var providers []Provider
+	if needed[ProviderVault] {
+		vault, err := NewVaultProvider(sc.Vault.Address, sc.Vault.Token, sc.Vault.TokenFile, sc.Vault.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, vault)
+	}
+	if needed[ProviderAWS] {
+		aws, err := NewAWSProvider(sc.AWS.Region, sc.AWS.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, aws)
+	}
+	providers = append(providers, NewFileProvider(sc.FilesDir))
+
+	return NewManager(Options{CacheTTL: sc.CacheTTL, Logger: logger}, providers...), nil
+}
+
+// ResolveConfig replaces the secret references in cfg's credential fields
+// with their values
+func ResolveConfig(ctx context.Context, m *Manager, cfg *config.Config) error {
+	for _, field := range CredentialFields(cfg) {
+		if err := m.ResolveFields(ctx, field.Value); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultSecretsDir is where Kubernetes secrets are expected to be mounted
+const DefaultSecretsDir = "/var/run/secrets/ollama"
+
+// FileProvider reads secrets from files, such as Kubernetes secrets
+// mounted as a volume where every key becomes a file. Kubernetes updates
+// the files in place when the secret changes, so they are re-read once
+// the cache TTL expires.
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider creates a provider reading relative paths below dir
+func NewFileProvider(dir string) *FileProvider {
+	if dir == "" {
+		dir = DefaultSecretsDir
+	}
+	return &FileProvider{dir: dir}
+}
+
+// Name returns the reference prefix
+func (f *FileProvider) Name() string {
+	return ProviderFile
+}
+
+// Fetch reads path, or path/key when key is set. Relative paths are
+// resolved against the provider directory and may not escape it.
+func (f *FileProvider) Fetch(ctx context.Context, path, key string) (*Secret, error) {
+	if key != "" {
+		path = filepath.Join(path, key)
+	}
+	if !filepath.IsAbs(path) {
+		clean := filepath.Clean(path)
+		if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("secret path %q escapes %s", path, f.dir)
+		}
+		path = filepath.Join(f.dir, clean)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Secret{
+		Value:     strings.TrimRight(string(data), "\r\n"),
+		FetchedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,309 @@
+// Package secrets resolves credentials referenced from configuration
+// against external secret stores such as HashiCorp Vault, AWS Secrets
+// Manager or Kubernetes secrets mounted as files.
+//
+// A config value is treated as a reference when it has the form
+// "<provider>:<path>[#<key>]", for example
+//
+//	vault:database/creds/ollama#password
+//	aws-sm:prod/ollama/jwt#signing_key
+//	file:registry/token
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider names used as reference prefixes
+const (
+	ProviderVault = "vault"
+	ProviderAWS   = "aws-sm"
+	ProviderFile  = "file"
+)
+
+// ErrNotFound is returned when a secret or a key within it does not exist
+var ErrNotFound = errors.New("secret not found")
+
+// Provider fetches secrets from a backing store
+type Provider interface {
+	// Name is the reference prefix handled by the provider, e.g. "vault"
+	Name() string
+	// Fetch reads the secret at path. The key selects a field of a
+	// structured secret and may be empty.
+	Fetch(ctx context.Context, path, key string) (*Secret, error)
+}
+
+// Renewer is implemented by providers whose secrets carry renewable leases
+type Renewer interface {
+	// Renew extends the lease and returns its new duration
+	Renew(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error)
+}
+
+// Secret is a resolved secret value together with its lease, if any
+type Secret struct {
+	Value         string
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+	FetchedAt     time.Time
+}
+
+// Reference identifies a secret in a provider
+type Reference struct {
+	Provider string
+	Path     string
+	Key      string
+}
+
+// String returns the reference in config syntax
+func (r Reference) String() string {
+	if r.Key == "" {
+		return r.Provider + ":" + r.Path
+	}
+	return r.Provider + ":" + r.Path + "#" + r.Key
+}
+
+// ParseReference parses a "<provider>:<path>[#<key>]" value. ok is false
+// when the prefix does not name one of the known providers, in which case
+// the value is a literal.
+func ParseReference(value string) (ref Reference, ok bool) {
+	name, rest, found := strings.Cut(value, ":")
+	if !found || rest == "" {
+		return Reference{}, false
+	}
+	switch name {
+	case ProviderVault, ProviderAWS, ProviderFile:
+	default:
+		return Reference{}, false
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	return Reference{Provider: name, Path: path, Key: key}, true
+}
+
+// IsReference reports whether value refers to a secret store
+func IsReference(value string) bool {
+	_, ok := ParseReference(value)
+	return ok
+}
+
+// Options configures a Manager
+type Options struct {
+	// CacheTTL bounds how long secrets without a lease are cached
+	CacheTTL time.Duration
+	// RenewFraction is the portion of a lease after which it is renewed
+	RenewFraction float64
+	Logger        *slog.Logger
+}
+
+// DefaultOptions returns the default manager options
+func DefaultOptions() Options {
+	return Options{
+		CacheTTL:      5 * time.Minute,
+		RenewFraction: 2.0 / 3.0,
+		Logger:        slog.Default(),
+	}
+}
+
+type cacheEntry struct {
+	ref    Reference
+	secret *Secret
+	// refreshAt is when the entry is renewed or fetched again
+	refreshAt time.Time
+}
+
+// Manager resolves references through the registered providers, caching
+// results and renewing leases in the background
+type Manager struct {
+	providers map[string]Provider
+	opts      Options
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+// NewManager creates a manager for the given providers
+func NewManager(opts Options, providers ...Provider) *Manager {
+	defaults := DefaultOptions()
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = defaults.CacheTTL
+	}
+	if opts.RenewFraction <= 0 || opts.RenewFraction >= 1 {
+		opts.RenewFraction = defaults.RenewFraction
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaults.Logger
+	}
+
+	m := &Manager{
+		providers: make(map[string]Provider),
+		opts:      opts,
+		cache:     make(map[string]*cacheEntry),
+	}
+	for _, p := range providers {
+		m.providers[p.Name()] = p
+	}
+	return m
+}
+
+// Resolve returns the secret value for a reference, or value itself when
+// it is a literal
+func (m *Manager) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := m.Get(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return secret.Value, nil
+}
+
+// ResolveFields replaces every reference among the given fields with its
+// secret value in place
+func (m *Manager) ResolveFields(ctx context.Context, fields ...*string) error {
+	for _, field := range fields {
+		if field == nil || *field == "" {
+			continue
+		}
+		value, err := m.Resolve(ctx, *field)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+	return nil
+}
+
+// Get returns the secret for ref, using the cache while it is fresh
+func (m *Manager) Get(ctx context.Context, ref Reference) (*Secret, error) {
+	cacheKey := ref.String()
+
+	m.mu.Lock()
+	entry, ok := m.cache[cacheKey]
+	m.mu.Unlock()
+	if ok && time.Now().Before(entry.refreshAt) {
+		return entry.secret, nil
+	}
+
+	secret, err := m.fetch(ctx, ref)
+	if err != nil && ok {
+		// Keep serving the last known value while the store is unreachable
+		m.opts.Logger.Warn("Failed to refresh secret, using cached value", "secret", cacheKey, "error", err)
+		return entry.secret, nil
+	}
+	return secret, err
+}
+
+func (m *Manager) fetch(ctx context.Context, ref Reference) (*Secret, error) {
+	provider, ok := m.providers[ref.Provider]
+	if !ok {
+		return nil, fmt.Errorf("secret %s: provider %q is not configured", ref, ref.Provider)
+	}
+
+	secret, err := provider.Fetch(ctx, ref.Path, ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", ref, err)
+	}
+	if secret.FetchedAt.IsZero() {
+		secret.FetchedAt = time.Now()
+	}
+
+	m.mu.Lock()
+	m.cache[ref.String()] = &cacheEntry{ref: ref, secret: secret, refreshAt: m.refreshTime(secret)}
+	m.mu.Unlock()
+
+	return secret, nil
+}
+
+// refreshTime schedules renewal part-way through a lease, or a refetch
+// after the cache TTL for secrets without one
+func (m *Manager) refreshTime(secret *Secret) time.Time {
+	ttl := m.opts.CacheTTL
+	if secret.LeaseDuration > 0 {
+		ttl = time.Duration(float64(secret.LeaseDuration) * m.opts.RenewFraction)
+	}
+	return secret.FetchedAt.Add(ttl)
+}
+
+// Run renews leases and refreshes cached secrets until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.refreshDue(ctx)
+		}
+	}
+}
+
+// refreshDue renews or refetches every cache entry whose refresh time has
+// passed. Failures keep the old value so that a store outage does not
+// break already configured components.
+func (m *Manager) refreshDue(ctx context.Context) {
+	now := time.Now()
+
+	m.mu.Lock()
+	var due []*cacheEntry
+	for _, entry := range m.cache {
+		if !now.Before(entry.refreshAt) {
+			due = append(due, entry)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, entry := range due {
+		if renewer, ok := m.providers[entry.ref.Provider].(Renewer); ok && entry.secret.Renewable && entry.secret.LeaseID != "" {
+			err := m.renew(ctx, renewer, entry)
+			if err == nil {
+				continue
+			}
+			m.opts.Logger.Warn("Failed to renew secret lease, refetching", "secret", entry.ref.String(), "error", err)
+		}
+		if _, err := m.fetch(ctx, entry.ref); err != nil {
+			m.opts.Logger.Error("Failed to refresh secret", "secret", entry.ref.String(), "error", err)
+		}
+	}
+}
+
+func (m *Manager) renew(ctx context.Context, renewer Renewer, entry *cacheEntry) error {
+	secret := entry.secret
+	duration, err := renewer.Renew(ctx, secret.LeaseID, secret.LeaseDuration)
+	if err != nil {
+		return err
+	}
+
+	renewed := *secret
+	renewed.LeaseDuration = duration
+	renewed.FetchedAt = time.Now()
+
+	m.mu.Lock()
+	m.cache[entry.ref.String()] = &cacheEntry{ref: entry.ref, secret: &renewed, refreshAt: m.refreshTime(&renewed)}
+	m.mu.Unlock()
+	return nil
+}
+
+// Check fetches every reference, bypassing the cache, and returns the
+// error for each one that could not be read
+func (m *Manager) Check(ctx context.Context, refs []Reference) map[string]error {
+	results := make(map[string]error, len(refs))
+	for _, ref := range refs {
+		_, err := m.fetch(ctx, ref)
+		results[ref.String()] = err
+	}
+	return results
+}
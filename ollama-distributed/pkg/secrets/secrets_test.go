@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	ref, ok := ParseReference("vault:secret/data/ollama#jwt_key")
+	require.True(t, ok)
+	assert.Equal(t, Reference{Provider: ProviderVault, Path: "secret/data/ollama", Key: "jwt_key"}, ref)
+	assert.Equal(t, "vault:secret/data/ollama#jwt_key", ref.String())
+
+	ref, ok = ParseReference("file:registry/token")
+	require.True(t, ok)
+	assert.Equal(t, "", ref.Key)
+
+	for _, literal := range []string{"", "plain-secret", "https://example.com", "vault:"} {
+		assert.False(t, IsReference(literal), literal)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "db"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db", "password"), []byte("hunter2\n"), 0o600))
+
+	p := NewFileProvider(dir)
+	secret, err := p.Fetch(context.Background(), "db", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", secret.Value)
+
+	_, err = p.Fetch(context.Background(), "db/missing", "")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = p.Fetch(context.Background(), "../etc/passwd", "")
+	assert.Error(t, err)
+}
+
+func TestVaultProviderLeaseRenewal(t *testing.T) {
+	var renewals, fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "root-token", r.Header.Get("X-Vault-Token"))
+		switch r.URL.Path {
+		case "/v1/secret/data/ollama":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     map[string]string{"jwt_key": "kv-value"},
+					"metadata": map[string]interface{}{"version": 3},
+				},
+			})
+		case "/v1/database/creds/ollama":
+			fetches.Add(1)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_id":       "database/creds/ollama/abc",
+				"lease_duration": 60,
+				"renewable":      true,
+				"data":           map[string]string{"username": "v-ollama", "password": "p1"},
+			})
+		case "/v1/sys/leases/renew":
+			renewals.Add(1)
+			json.NewEncoder(w).Encode(map[string]interface{}{"lease_duration": 60})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vault, err := NewVaultProvider(server.URL, "root-token", "", "")
+	require.NoError(t, err)
+	m := NewManager(Options{}, vault)
+	ctx := context.Background()
+
+	value, err := m.Resolve(ctx, "vault:secret/data/ollama#jwt_key")
+	require.NoError(t, err)
+	assert.Equal(t, "kv-value", value)
+
+	value, err = m.Resolve(ctx, "vault:database/creds/ollama#password")
+	require.NoError(t, err)
+	assert.Equal(t, "p1", value)
+
+	// Force the lease to be due and check it is renewed, not refetched
+	m.mu.Lock()
+	m.cache["vault:database/creds/ollama#password"].refreshAt = time.Now().Add(-time.Second)
+	m.mu.Unlock()
+	m.refreshDue(ctx)
+	assert.Equal(t, int32(1), renewals.Load())
+	assert.Equal(t, int32(1), fetches.Load())
+
+	_, err = m.Resolve(ctx, "vault:secret/data/missing#x")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+type flakyProvider struct {
+	fail  atomic.Bool
+	value string
+}
+
+func (f *flakyProvider) Name() string { return ProviderFile }
+
+func (f *flakyProvider) Fetch(ctx context.Context, path, key string) (*Secret, error) {
+	if f.fail.Load() {
+		return nil, errors.New("store unavailable")
+	}
+	return &Secret{Value: f.value}, nil
+}
+
+func TestManagerServesCachedValueOnOutage(t *testing.T) {
+	p := &flakyProvider{value: "cached"}
+	m := NewManager(Options{CacheTTL: time.Millisecond}, p)
+	ctx := context.Background()
+
+	_, err := m.Resolve(ctx, "file:x")
+	require.NoError(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+	p.fail.Store(true)
+	value, err := m.Resolve(ctx, "file:x")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", value)
+
+	results := m.Check(ctx, []Reference{{Provider: ProviderFile, Path: "x"}})
+	assert.Error(t, results["file:x"])
+}
+
+func TestAWSProviderSignsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request"), auth)
+		assert.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-target")
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["SecretId"] != "prod/ollama" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "ResourceNotFoundException"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"signing_key":"from-aws"}`})
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	p, err := NewAWSProvider("eu-west-1", server.URL)
+	require.NoError(t, err)
+	p.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	secret, err := p.Fetch(context.Background(), "prod/ollama", "signing_key")
+	require.NoError(t, err)
+	assert.Equal(t, "from-aws", secret.Value)
+
+	_, err = p.Fetch(context.Background(), "other", "")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolveConfig(t *testing.T) {
+	dir := t.TempDir()
+	key := strings.Repeat("k", 40)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jwt"), []byte(key), 0o600))
+
+	cfg := config.DefaultConfig()
+	cfg.Security.Secrets.FilesDir = dir
+	cfg.Security.Auth.SecretKey = "file:jwt"
+	cfg.Security.Auth.OIDC.ClientSecret = "literal"
+
+	refs := References(cfg)
+	require.Len(t, refs, 1)
+	assert.Equal(t, "security.auth.secret_key", refs[0].Field)
+
+	m, err := NewManagerFromConfig(cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, ResolveConfig(context.Background(), m, cfg))
+	assert.Equal(t, key, cfg.Security.Auth.SecretKey)
+	assert.Equal(t, "literal", cfg.Security.Auth.OIDC.ClientSecret)
+
+	// Vault is only required once something refers to it
+	cfg.P2P.PrivateKey = "vault:secret/data/p2p#key"
+	t.Setenv("VAULT_ADDR", "")
+	_, err = NewManagerFromConfig(cfg, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider reads secrets from HashiCorp Vault over its HTTP API. Both
+// KV v2 paths ("secret/data/app") and dynamic secret engines
+// ("database/creds/app") are supported; leases of the latter are renewed
+// by the Manager.
+type VaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider creates a Vault provider. The token is taken from
+// tokenFile when set, e.g. a Vault agent sink, and otherwise from token or
+// the VAULT_TOKEN environment variable.
+func NewVaultProvider(address, token, tokenFile, namespace string) (*VaultProvider, error) {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+
+	return &VaultProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name returns the reference prefix
+func (v *VaultProvider) Name() string {
+	return ProviderVault
+}
+
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// Fetch reads the secret at path and returns the value of key. KV v2
+// responses nest the fields under data.data, which is unwrapped.
+func (v *VaultProvider) Fetch(ctx context.Context, path, key string) (*Secret, error) {
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	data := resp.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, isKV2 := data["metadata"]; isKV2 {
+			data = inner
+		}
+	}
+
+	if key == "" {
+		key = "value"
+	}
+	raw, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: key %q", ErrNotFound, key)
+	}
+	value, ok := raw.(string)
+	if !ok {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		value = string(encoded)
+	}
+
+	return &Secret{
+		Value:         value,
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+		FetchedAt:     time.Now(),
+	}, nil
+}
+
+// Renew extends a lease by increment
+func (v *VaultProvider) Renew(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error) {
+	body := map[string]interface{}{
+		"lease_id":  leaseID,
+		"increment": int(increment.Seconds()),
+	}
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodPut, "/v1/sys/leases/renew", body, &resp); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+func (v *VaultProvider) do(ctx context.Context, method, path string, body interface{}, out *vaultResponse) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.address+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(out.Errors, "; "))
+	}
+	return nil
+}
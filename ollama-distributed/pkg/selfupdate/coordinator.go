@@ -0,0 +1,163 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// leaseKey is the consensus key holding the restart lease
+const leaseKey = "upgrade_lease"
+
+// Errors of the coordinator
+var (
+	// ErrNotLeader is returned when the lease is changed on a node other
+	// than the consensus leader
+	ErrNotLeader = errors.New("the upgrade lease is managed by the leader")
+	// ErrBusy is returned while another node holds the lease
+	ErrBusy = errors.New("another node is restarting")
+	// ErrUnsafe is returned while the cluster could not lose a node
+	ErrUnsafe = errors.New("cluster is not healthy enough to restart a node")
+)
+
+// Store is the replicated state the lease is shared through, i.e. the
+// consensus engine
+type Store interface {
+	Apply(key string, value interface{}, metadata map[string]interface{}) error
+	Get(key string) (interface{}, bool)
+	IsLeader() bool
+}
+
+// Lease allows a node to restart into a new version
+type Lease struct {
+	NodeID    string    `json:"node_id"`
+	Version   string    `json:"version,omitempty"`
+	GrantedAt time.Time `json:"granted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Coordinator is the rolling upgrade controller: it grants the nodes of a
+// cluster, one at a time and only while the rest of the cluster is
+// healthy, a lease to restart into a new version. A lease expires if its
+// node does not release it, so a node that never comes back does not stall
+// the rollout.
+type Coordinator struct {
+	ttl   time.Duration
+	store Store
+	now   func() time.Time
+
+	mu    sync.Mutex
+	local Lease // without a store
+}
+
+// NewCoordinator returns a coordinator granting leases for ttl, or the
+// default TTL when zero. Without a store, the lease is local to the node.
+func NewCoordinator(ttl time.Duration, store Store) *Coordinator {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &Coordinator{ttl: ttl, store: store, now: time.Now}
+}
+
+// Acquire grants a node the lease, or extends the lease it holds. safe, if
+// set, says why the cluster cannot lose the node now; it is not asked when
+// the node already holds the lease, as the node may be restarting.
+func (c *Coordinator) Acquire(nodeID, version string, safe func() error) (Lease, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil && !c.store.IsLeader() {
+		return Lease{}, ErrNotLeader
+	}
+	current, err := c.load()
+	if err != nil {
+		return Lease{}, err
+	}
+	now := c.now()
+	held := current != nil && now.Before(current.ExpiresAt)
+	if held && current.NodeID != nodeID {
+		return Lease{}, fmt.Errorf("%w: %s holds the lease until %s", ErrBusy, current.NodeID, current.ExpiresAt.Format(time.RFC3339))
+	}
+	if !held && safe != nil {
+		if err := safe(); err != nil {
+			return Lease{}, fmt.Errorf("%w: %v", ErrUnsafe, err)
+		}
+	}
+
+	lease := Lease{NodeID: nodeID, Version: version, GrantedAt: now, ExpiresAt: now.Add(c.ttl)}
+	if held {
+		lease.GrantedAt = current.GrantedAt
+	}
+	return lease, c.save(lease)
+}
+
+// Release ends the lease of a node, and reports whether it held it
+func (c *Coordinator) Release(nodeID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil && !c.store.IsLeader() {
+		return false, ErrNotLeader
+	}
+	current, err := c.load()
+	if err != nil || current == nil || current.NodeID != nodeID {
+		return false, err
+	}
+	return true, c.save(Lease{})
+}
+
+// Current returns the lease in force, if any
+func (c *Coordinator) Current() (*Lease, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, err := c.load()
+	if err != nil || current == nil || !c.now().Before(current.ExpiresAt) {
+		return nil, err
+	}
+	return current, nil
+}
+
+// save keeps the lease, or its absence as the zero lease; c.mu must be
+// held
+func (c *Coordinator) save(lease Lease) error {
+	if c.store == nil {
+		c.local = lease
+		return nil
+	}
+	if err := c.store.Apply(leaseKey, lease, nil); err != nil {
+		return fmt.Errorf("failed to share the upgrade lease: %w", err)
+	}
+	return nil
+}
+
+// load returns the lease last saved, expired or not, or nil; c.mu must be
+// held
+func (c *Coordinator) load() (*Lease, error) {
+	lease := c.local
+	if c.store != nil {
+		value, exists := c.store.Get(leaseKey)
+		if !exists {
+			return nil, nil
+		}
+		stored, ok := value.(Lease)
+		if !ok {
+			// Values arrive decoded from the replicated log, so they are
+			// converted via JSON
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the upgrade lease: %w", err)
+			}
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return nil, fmt.Errorf("failed to decode the upgrade lease: %w", err)
+			}
+		}
+		lease = stored
+	}
+	if lease.NodeID == "" {
+		return nil, nil
+	}
+	return &lease, nil
+}
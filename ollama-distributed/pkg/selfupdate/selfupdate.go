@@ -0,0 +1,358 @@
+// Package selfupdate replaces the running binary with a newer release. The
+// latest release is read from a release endpoint serving GitHub's release
+// JSON, GitHub's own API by default. Its binary is verified against the
+// release's checksum file, whose Ed25519 signature is checked first when a
+// public key is configured, and swapped in atomically. The Coordinator lets
+// the nodes of a cluster restart into the new binary one at a time.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the self-update
+const (
+	DefaultRepository = "KhryptorGraphics/OllamaMax"
+	DefaultLeaseTTL   = 10 * time.Minute
+)
+
+// Names of the release assets besides the binaries, which are named
+// ollama-distributed_<os>_<arch>
+const (
+	ChecksumsAsset = "checksums.txt"
+	SignatureAsset = "checksums.txt.sig"
+)
+
+// maxBinarySize bounds the download of a binary
+const maxBinarySize = 1 << 30
+
+// Errors of the self-update
+var (
+	ErrNoAsset           = errors.New("release has no binary for this platform")
+	ErrChecksumMismatch  = errors.New("binary does not match its checksum")
+	ErrInvalidSignature  = errors.New("invalid signature of the release checksums")
+	ErrUnsignedChecksums = errors.New("release checksums are not signed")
+)
+
+// Config configures where releases come from and how they are verified
+type Config struct {
+	// ReleaseURL serves the latest release as GitHub's release JSON. It
+	// defaults to the GitHub API's latest release of Repository.
+	ReleaseURL string `yaml:"release_url" mapstructure:"release_url"`
+	// Repository is the GitHub repository releases come from,
+	// KhryptorGraphics/OllamaMax by default
+	Repository string `yaml:"repository"`
+	// PublicKey is the base64 Ed25519 key the checksum files of releases
+	// are signed with. Without it only checksums are verified.
+	PublicKey string `yaml:"public_key" mapstructure:"public_key"`
+	// LeaseTTL is how long a node may take to restart into a new version
+	// in a coordinated update before another node may, 10m by default
+	LeaseTTL time.Duration `yaml:"lease_ttl" mapstructure:"lease_ttl"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.PublicKey != "" {
+		if _, err := parsePublicKey(c.PublicKey); err != nil {
+			return err
+		}
+	}
+	if c.LeaseTTL < 0 {
+		return fmt.Errorf("lease TTL must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.Repository == "" {
+		c.Repository = DefaultRepository
+	}
+	if c.ReleaseURL == "" {
+		c.ReleaseURL = "https://api.github.com/repos/" + c.Repository + "/releases/latest"
+	}
+	if c.LeaseTTL == 0 {
+		c.LeaseTTL = DefaultLeaseTTL
+	}
+	return c
+}
+
+// Asset is a file of a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Release is a release as GitHub describes it
+type Release struct {
+	Version     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// asset returns the asset with the given name
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// BinaryName returns the name of the release binary for a platform
+func BinaryName(goos, goarch string) string {
+	name := "ollama-distributed_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Updater fetches, verifies and installs releases
+type Updater struct {
+	cfg       Config
+	publicKey ed25519.PublicKey
+	client    *http.Client
+	binary    string
+}
+
+// NewUpdater returns an updater for the binary of this platform. A nil
+// client uses one with a 10 minute timeout.
+func NewUpdater(cfg Config, client *http.Client) (*Updater, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	u := &Updater{
+		cfg:    cfg.WithDefaults(),
+		client: client,
+		binary: BinaryName(runtime.GOOS, runtime.GOARCH),
+	}
+	if u.client == nil {
+		u.client = &http.Client{Timeout: 10 * time.Minute}
+	}
+	if cfg.PublicKey != "" {
+		u.publicKey, _ = parsePublicKey(cfg.PublicKey)
+	}
+	return u, nil
+}
+
+// Signed reports whether releases must have a valid signature
+func (u *Updater) Signed() bool {
+	return u.publicKey != nil
+}
+
+// Latest returns the latest release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	body, err := u.get(ctx, u.cfg.ReleaseURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the latest release: %w", err)
+	}
+	var release Release
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse the latest release: %w", err)
+	}
+	if release.Version == "" {
+		return nil, fmt.Errorf("release endpoint returned no version")
+	}
+	return &release, nil
+}
+
+// Download downloads the release's binary for this platform into dir,
+// which should be the directory of the binary it replaces so that it can
+// be swapped in atomically, and verifies it. It returns the path of the
+// verified, executable file.
+func (u *Updater) Download(ctx context.Context, release *Release, dir string) (string, error) {
+	binary, ok := release.asset(u.binary)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoAsset, u.binary)
+	}
+	want, err := u.checksum(ctx, release)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, binary.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", binary.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: server returned %s", binary.Name, resp.Status)
+	}
+
+	file, err := os.CreateTemp(dir, ".ollama-distributed-update-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to stage the new binary: %w", err)
+	}
+	staged := file.Name()
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), io.LimitReader(resp.Body, maxBinarySize))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(hash.Sum(nil)) != want {
+		err = fmt.Errorf("%w: %s", ErrChecksumMismatch, binary.Name)
+	}
+	if err == nil {
+		err = os.Chmod(staged, 0755)
+	}
+	if err != nil {
+		os.Remove(staged)
+		return "", err
+	}
+	return staged, nil
+}
+
+// checksum returns the expected SHA-256 of the binary from the release's
+// checksum file, after checking the file's signature
+func (u *Updater) checksum(ctx context.Context, release *Release) (string, error) {
+	asset, ok := release.asset(ChecksumsAsset)
+	if !ok {
+		return "", fmt.Errorf("release has no %s", ChecksumsAsset)
+	}
+	checksums, err := u.get(ctx, asset.URL, 1<<20)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", ChecksumsAsset, err)
+	}
+
+	if u.publicKey != nil {
+		asset, ok := release.asset(SignatureAsset)
+		if !ok {
+			return "", ErrUnsignedChecksums
+		}
+		encoded, err := u.get(ctx, asset.URL, 4096)
+		if err != nil {
+			return "", fmt.Errorf("failed to download %s: %w", SignatureAsset, err)
+		}
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || !ed25519.Verify(u.publicKey, checksums, signature) {
+			return "", ErrInvalidSignature
+		}
+	}
+
+	// Lines are "<sha256>  <name>", as sha256sum writes them
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == u.binary {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", ChecksumsAsset, u.binary)
+}
+
+// get returns the body of a successful GET of url, of at most limit bytes
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// Install swaps the staged binary in place of target with a rename, which
+// is atomic, keeping the replaced binary as target.old
+func Install(staged, target string) error {
+	backup := target + ".old"
+	os.Remove(backup)
+	if err := os.Link(target, backup); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", target, err)
+	}
+	if err := os.Rename(staged, target); err != nil {
+		os.Remove(backup)
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	return nil
+}
+
+// Executable returns the path of the running binary, with symlinks
+// resolved so that the binary rather than the link is replaced
+func Executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Newer reports whether version latest is newer than current. Versions
+// that are not semantic versions, like dev, are never newer or older.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses the major, minor and patch number of versions like
+// v1.4.2 and 1.4.2-rc.1
+func parseVersion(s string) ([3]int, bool) {
+	var version [3]int
+	s = strings.TrimPrefix(s, "v")
+	if end := strings.IndexAny(s, "-+"); end >= 0 {
+		s = s[:end]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// parsePublicKey decodes a base64 Ed25519 public key
+func parsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be a base64 Ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
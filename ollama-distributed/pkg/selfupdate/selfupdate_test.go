@@ -0,0 +1,152 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRelease serves a release of binary signed with key, whose served
+// binary can be swapped for another after signing
+type testRelease struct {
+	server *httptest.Server
+	served []byte
+}
+
+func newTestRelease(t *testing.T, binary []byte, key ed25519.PrivateKey) *testRelease {
+	sum := sha256.Sum256(binary)
+	name := BinaryName(runtime.GOOS, runtime.GOARCH)
+	checksums := fmt.Sprintf("%s  other_binary\n%s  %s\n", hex.EncodeToString(make([]byte, 32)), hex.EncodeToString(sum[:]), name)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(checksums)))
+
+	r := &testRelease{served: binary}
+	mux := http.NewServeMux()
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(Release{Version: "v1.5.0", Assets: []Asset{
+			{Name: name, URL: r.server.URL + "/binary"},
+			{Name: ChecksumsAsset, URL: r.server.URL + "/checksums"},
+			{Name: SignatureAsset, URL: r.server.URL + "/signature"},
+		}})
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, _ *http.Request) { w.Write(r.served) })
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte(checksums)) })
+	mux.HandleFunc("/signature", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte(signature + "\n")) })
+	return r
+}
+
+func TestUpdate(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	release := newTestRelease(t, []byte("new binary"), private)
+
+	updater, err := NewUpdater(Config{
+		ReleaseURL: release.server.URL + "/latest",
+		PublicKey:  base64.StdEncoding.EncodeToString(public),
+	}, nil)
+	require.NoError(t, err)
+	assert.True(t, updater.Signed())
+
+	ctx := context.Background()
+	latest, err := updater.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.5.0", latest.Version)
+	assert.True(t, Newer(latest.Version, "v1.4.9"))
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "ollama-distributed")
+	require.NoError(t, os.WriteFile(target, []byte("old binary"), 0755))
+
+	staged, err := updater.Download(ctx, latest, dir)
+	require.NoError(t, err)
+	require.NoError(t, Install(staged, target))
+	installed, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(installed))
+	backup, err := os.ReadFile(target + ".old")
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(backup))
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0100, "the new binary is executable")
+
+	// A tampered binary is refused and leaves nothing behind
+	release.served = []byte("tampered binary")
+	_, err = updater.Download(ctx, latest, dir)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// Checksums signed with another key are refused
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	untrusting, err := NewUpdater(Config{
+		ReleaseURL: release.server.URL + "/latest",
+		PublicKey:  base64.StdEncoding.EncodeToString(otherPublic),
+	}, nil)
+	require.NoError(t, err)
+	_, err = untrusting.Download(ctx, latest, dir)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestNewer(t *testing.T) {
+	assert.True(t, Newer("v1.10.0", "v1.9.3"))
+	assert.True(t, Newer("2.0.0", "v1.99.99"))
+	assert.False(t, Newer("v1.4.2", "v1.4.2"))
+	assert.False(t, Newer("v1.4.1", "v1.4.2"))
+	assert.False(t, Newer("v1.5.0", "dev"), "development builds are not compared")
+}
+
+func TestCoordinator(t *testing.T) {
+	now := time.Now()
+	c := NewCoordinator(time.Minute, nil)
+	c.now = func() time.Time { return now }
+	healthy := func() error { return nil }
+
+	lease, err := c.Acquire("node-a", "v1.5.0", healthy)
+	require.NoError(t, err)
+	assert.Equal(t, "node-a", lease.NodeID)
+
+	_, err = c.Acquire("node-b", "v1.5.0", healthy)
+	assert.ErrorIs(t, err, ErrBusy, "nodes restart one at a time")
+
+	now = now.Add(30 * time.Second)
+	renewed, err := c.Acquire("node-a", "v1.5.0", func() error { return errors.New("node-a is down") })
+	require.NoError(t, err, "the holder renews even while it is down")
+	assert.Equal(t, lease.GrantedAt, renewed.GrantedAt)
+	assert.Equal(t, now.Add(time.Minute), renewed.ExpiresAt)
+
+	released, err := c.Release("node-a")
+	require.NoError(t, err)
+	assert.True(t, released)
+	current, err := c.Current()
+	require.NoError(t, err)
+	assert.Nil(t, current)
+
+	_, err = c.Acquire("node-b", "v1.5.0", func() error { return errors.New("node-c is offline") })
+	assert.ErrorIs(t, err, ErrUnsafe)
+
+	_, err = c.Acquire("node-b", "v1.5.0", healthy)
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	_, err = c.Acquire("node-c", "v1.5.0", healthy)
+	assert.NoError(t, err, "an expired lease does not stall the rollout")
+}
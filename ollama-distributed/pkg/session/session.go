@@ -0,0 +1,544 @@
+// Package session keeps the login sessions of the web dashboard. Sessions
+// are opaque random tokens held in a secure cookie, of which only hashes
+// are kept; they end when idle for too long or past their maximum age, and
+// can be listed and revoked per user. Accounts may enroll a TOTP second
+// factor, and repeated failed logins lock the account and the client
+// address out for a while.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CookieName is the cookie holding the session token
+const CookieName = "ollama_session"
+
+// Defaults of the session settings
+const (
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultMaxAge          = 12 * time.Hour
+	DefaultMaxFailedLogins = 5
+	DefaultLockoutDuration = 15 * time.Minute
+	DefaultIssuer          = "OllamaMax"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown users and wrong
+	// passwords alike
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrTwoFactorRequired is returned when the password is right but the
+	// account needs a TOTP code to log in
+	ErrTwoFactorRequired = errors.New("two-factor code required")
+	ErrInvalidCode       = errors.New("invalid two-factor code")
+	ErrNotEnrolling      = errors.New("no two-factor enrollment in progress")
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+)
+
+// LockedOutError is returned while logins are locked out after repeated
+// failures
+type LockedOutError struct {
+	Until time.Time
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("too many failed logins, locked out until %s", e.Until.Format(time.RFC3339))
+}
+
+// Config configures dashboard sessions
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// IdleTimeout ends sessions not used for this long, 30m by default
+	IdleTimeout time.Duration `yaml:"idle_timeout" mapstructure:"idle_timeout"`
+	// MaxAge ends sessions this long after login, 12h by default
+	MaxAge time.Duration `yaml:"max_age" mapstructure:"max_age"`
+	// MaxFailedLogins in a row lock the account, and the address they came
+	// from, out for LockoutDuration; 5 and 15m by default
+	MaxFailedLogins int           `yaml:"max_failed_logins" mapstructure:"max_failed_logins"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" mapstructure:"lockout_duration"`
+	// Issuer names the cluster in authenticator apps
+	Issuer string `yaml:"issuer"`
+	// StateFile keeps the two-factor enrollments across restarts; they are
+	// only kept in memory when unset
+	StateFile string `yaml:"state_file" mapstructure:"state_file"`
+	// Users are the local accounts that may log in
+	Users []User `yaml:"users"`
+}
+
+// User is a local account, with its password as a bcrypt hash
+type User struct {
+	Username     string   `yaml:"username"`
+	PasswordHash string   `yaml:"password_hash" mapstructure:"password_hash"`
+	Roles        []string `yaml:"roles"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.IdleTimeout < 0 || c.MaxAge < 0 || c.LockoutDuration < 0 {
+		return fmt.Errorf("session durations must not be negative")
+	}
+	if c.MaxFailedLogins < 0 {
+		return fmt.Errorf("session max failed logins must not be negative")
+	}
+	seen := make(map[string]bool, len(c.Users))
+	for _, user := range c.Users {
+		if user.Username == "" {
+			return fmt.Errorf("session users need a username")
+		}
+		if seen[user.Username] {
+			return fmt.Errorf("duplicate session user %s", user.Username)
+		}
+		seen[user.Username] = true
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return fmt.Errorf("session user %s: password_hash is not a bcrypt hash", user.Username)
+		}
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = DefaultIdleTimeout
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = DefaultMaxAge
+	}
+	if c.MaxFailedLogins == 0 {
+		c.MaxFailedLogins = DefaultMaxFailedLogins
+	}
+	if c.LockoutDuration == 0 {
+		c.LockoutDuration = DefaultLockoutDuration
+	}
+	if c.Issuer == "" {
+		c.Issuer = DefaultIssuer
+	}
+	return c
+}
+
+// Authenticator checks the password of an account and returns its RBAC
+// roles
+type Authenticator interface {
+	Authenticate(username, password string) (roles []string, err error)
+}
+
+// StaticUsers authenticates the accounts of the config
+type StaticUsers []User
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// Authenticate compares the password with the user's hash. Unknown users
+// are compared against a dummy hash, so they take as long to reject.
+func (users StaticUsers) Authenticate(username, password string) ([]string, error) {
+	for _, user := range users {
+		if user.Username != username {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+			return nil, ErrInvalidCredentials
+		}
+		return user.Roles, nil
+	}
+
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	return nil, ErrInvalidCredentials
+}
+
+// Session is a login session as listed; its token is never exposed
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Roles      []string  `json:"roles"`
+	TwoFactor  bool      `json:"two_factor"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// attempts are the failed logins in a row of an account or address
+type attempts struct {
+	failures int
+	until    time.Time
+}
+
+// totpState is a confirmed TOTP enrollment
+type totpState struct {
+	Secret   string `json:"secret"`
+	LastStep int64  `json:"last_step"`
+}
+
+// state is what StateFile holds
+type state struct {
+	TOTP map[string]totpState `json:"totp"`
+}
+
+// Manager logs users in and keeps their sessions. It is safe for
+// concurrent use.
+type Manager struct {
+	cfg  Config
+	auth Authenticator
+	now  func() time.Time
+
+	mu        sync.Mutex
+	sessions  map[string]*Session // by token hash
+	attempts  map[string]*attempts
+	totp      map[string]totpState
+	enrolling map[string]string // pending TOTP secrets by username
+}
+
+// NewManager returns a manager logging users in with auth, loading the
+// two-factor enrollments from the state file if there is one
+func NewManager(cfg Config, auth Authenticator) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		cfg:       cfg.WithDefaults(),
+		auth:      auth,
+		now:       time.Now,
+		sessions:  make(map[string]*Session),
+		attempts:  make(map[string]*attempts),
+		totp:      make(map[string]totpState),
+		enrolling: make(map[string]string),
+	}
+	if m.cfg.StateFile != "" {
+		data, err := os.ReadFile(m.cfg.StateFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read session state: %w", err)
+		default:
+			var s state
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, fmt.Errorf("failed to decode session state: %w", err)
+			}
+			for username, enrollment := range s.TOTP {
+				m.totp[username] = enrollment
+			}
+		}
+	}
+	return m, nil
+}
+
+// Config returns the session settings, defaults applied
+func (m *Manager) Config() Config {
+	return m.cfg
+}
+
+// Login checks a user's password, and TOTP code when enrolled, and starts
+// a session. It returns the session token for the cookie.
+func (m *Manager) Login(username, password, code, ipAddress, userAgent string) (string, Session, error) {
+	keys := []string{"user:" + strings.ToLower(username), "ip:" + ipAddress}
+	m.mu.Lock()
+	now := m.now()
+	for _, key := range keys {
+		if a := m.attempts[key]; a != nil && now.Before(a.until) {
+			m.mu.Unlock()
+			return "", Session{}, &LockedOutError{Until: a.until}
+		}
+	}
+	m.mu.Unlock()
+
+	// Hashing is slow; it runs unlocked
+	roles, err := m.auth.Authenticate(username, password)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.fail(keys, now)
+		return "", Session{}, ErrInvalidCredentials
+	}
+
+	enrollment, twoFactor := m.totp[username]
+	if twoFactor {
+		if code == "" {
+			return "", Session{}, ErrTwoFactorRequired
+		}
+		step, ok := verifyTOTP(enrollment.Secret, code, now, enrollment.LastStep)
+		if !ok {
+			m.fail(keys, now)
+			return "", Session{}, ErrInvalidCode
+		}
+		enrollment.LastStep = step
+		m.totp[username] = enrollment
+		if err := m.save(); err != nil {
+			return "", Session{}, err
+		}
+	}
+	for _, key := range keys {
+		delete(m.attempts, key)
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+	m.prune(now)
+	s := &Session{
+		ID:         hash[:16],
+		Username:   username,
+		Roles:      roles,
+		TwoFactor:  twoFactor,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	s.ExpiresAt = m.expiry(s)
+	m.sessions[hash] = s
+	return token, *s, nil
+}
+
+// fail counts a failed login against the account and address, locking
+// them out once MaxFailedLogins are reached. The caller holds mu.
+func (m *Manager) fail(keys []string, now time.Time) {
+	for _, key := range keys {
+		a := m.attempts[key]
+		if a == nil || (!a.until.IsZero() && !now.Before(a.until)) {
+			a = &attempts{}
+			m.attempts[key] = a
+		}
+		a.failures++
+		if a.failures >= m.cfg.MaxFailedLogins {
+			a.until = now.Add(m.cfg.LockoutDuration)
+		}
+	}
+}
+
+// Unlock clears the failed logins of an account
+func (m *Manager) Unlock(username string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, "user:"+strings.ToLower(username))
+}
+
+// Validate returns the session of a token, keeping it alive
+func (m *Manager) Validate(token string) (Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := hashToken(token)
+	s, exists := m.sessions[hash]
+	if !exists {
+		return Session{}, false
+	}
+	now := m.now()
+	if !now.Before(s.ExpiresAt) {
+		delete(m.sessions, hash)
+		return Session{}, false
+	}
+	s.LastSeenAt = now
+	s.ExpiresAt = m.expiry(s)
+	return *s, true
+}
+
+// Logout ends the session of a token
+func (m *Manager) Logout(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, hashToken(token))
+}
+
+// Sessions returns the live sessions of a user, or of every user when
+// username is empty, newest first
+func (m *Manager) Sessions(username string) []Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune(m.now())
+	sessions := []Session{}
+	for _, s := range m.sessions {
+		if username == "" || s.Username == username {
+			sessions = append(sessions, *s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions
+}
+
+// Revoke ends a session by ID, if it belongs to the user or username is
+// empty
+func (m *Manager) Revoke(username, id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, s := range m.sessions {
+		if s.ID == id && (username == "" || s.Username == username) {
+			delete(m.sessions, hash)
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeAll ends every session of a user and returns how many there were
+func (m *Manager) RevokeAll(username string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revoked := 0
+	for hash, s := range m.sessions {
+		if s.Username == username {
+			delete(m.sessions, hash)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// expiry returns when a session ends if not used again
+func (m *Manager) expiry(s *Session) time.Time {
+	idle := s.LastSeenAt.Add(m.cfg.IdleTimeout)
+	if absolute := s.CreatedAt.Add(m.cfg.MaxAge); absolute.Before(idle) {
+		return absolute
+	}
+	return idle
+}
+
+// prune drops the expired sessions. The caller holds mu.
+func (m *Manager) prune(now time.Time) {
+	for hash, s := range m.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(m.sessions, hash)
+		}
+	}
+}
+
+// TwoFactorEnabled reports whether a user has a confirmed TOTP enrollment
+func (m *Manager) TwoFactorEnabled(username string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, enabled := m.totp[username]
+	return enabled
+}
+
+// EnrollTOTP starts the TOTP enrollment of a user with a new secret, which
+// takes effect once confirmed with a code from it
+func (m *Manager) EnrollTOTP(username string) (Enrollment, error) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enrolling[username] = secret
+	return Enrollment{Secret: secret, URL: totpURL(m.cfg.Issuer, username, secret)}, nil
+}
+
+// ConfirmTOTP enables the secret being enrolled once the user proves, with
+// a code from it, that their authenticator app holds it
+func (m *Manager) ConfirmTOTP(username, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secret, exists := m.enrolling[username]
+	if !exists {
+		return ErrNotEnrolling
+	}
+	step, ok := verifyTOTP(secret, code, m.now(), 0)
+	if !ok {
+		return ErrInvalidCode
+	}
+	previous, hadPrevious := m.totp[username]
+	m.totp[username] = totpState{Secret: secret, LastStep: step}
+	if err := m.save(); err != nil {
+		if hadPrevious {
+			m.totp[username] = previous
+		} else {
+			delete(m.totp, username)
+		}
+		return err
+	}
+	delete(m.enrolling, username)
+	return nil
+}
+
+// DisableTOTP removes the TOTP enrollment of a user, given a current code
+func (m *Manager) DisableTOTP(username, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, exists := m.totp[username]
+	if !exists {
+		return ErrTwoFactorDisabled
+	}
+	if _, ok := verifyTOTP(enrollment.Secret, code, m.now(), enrollment.LastStep); !ok {
+		return ErrInvalidCode
+	}
+	delete(m.totp, username)
+	if err := m.save(); err != nil {
+		m.totp[username] = enrollment
+		return err
+	}
+	return nil
+}
+
+// save writes the TOTP enrollments to the state file, if there is one.
+// The caller holds mu.
+func (m *Manager) save() error {
+	if m.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{TOTP: m.totp})
+	if err != nil {
+		return fmt.Errorf("failed to encode session state: %w", err)
+	}
+
+	dir := filepath.Dir(m.cfg.StateFile)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.cfg.StateFile); err != nil {
+		return fmt.Errorf("failed to save session state: %w", err)
+	}
+	return nil
+}
+
+// newToken returns a random session token and the hash it is kept by
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
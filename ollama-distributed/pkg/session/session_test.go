@@ -0,0 +1,156 @@
+package session
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testUsers(t *testing.T) StaticUsers {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	return StaticUsers{
+		{Username: "alice", PasswordHash: string(hash), Roles: []string{"admin"}},
+		{Username: "bob", PasswordHash: string(hash), Roles: []string{"user"}},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{MaxAge: -time.Second}.Validate())
+	assert.Error(t, Config{Users: []User{{Username: "alice", PasswordHash: "plain"}}}.Validate())
+	users := testUsers(t)
+	assert.Error(t, Config{Users: []User{users[0], users[0]}}.Validate())
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vector for the SHA1 secret "12345678901234567890" at
+	// 59s, truncated to 6 digits
+	code, err := totpCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	m, err := NewManager(Config{IdleTimeout: time.Minute, MaxAge: 3 * time.Minute}, testUsers(t))
+	require.NoError(t, err)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	m.now = func() time.Time { return now }
+
+	_, _, err = m.Login("alice", "wrong", "", "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	token, s, err := m.Login("alice", "s3cret", "", "10.0.0.1", "firefox")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, s.Roles)
+	assert.Equal(t, start.Add(time.Minute), s.ExpiresAt)
+
+	// Use keeps the session alive up to its maximum age
+	for i := 0; i < 4; i++ {
+		now = now.Add(40 * time.Second)
+		_, ok := m.Validate(token)
+		require.True(t, ok)
+	}
+	now = start.Add(3 * time.Minute)
+	_, ok := m.Validate(token)
+	assert.False(t, ok, "past the maximum age")
+
+	token, _, err = m.Login("alice", "s3cret", "", "10.0.0.1", "")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	_, ok = m.Validate(token)
+	assert.False(t, ok, "idle")
+
+	// Listing and revocation per user
+	aliceToken, alice, _ := m.Login("alice", "s3cret", "", "10.0.0.1", "")
+	now = now.Add(time.Second)
+	_, bob, _ := m.Login("bob", "s3cret", "", "10.0.0.2", "")
+	assert.Len(t, m.Sessions(""), 2)
+	require.Len(t, m.Sessions("bob"), 1)
+	assert.Equal(t, bob.ID, m.Sessions("bob")[0].ID)
+	assert.False(t, m.Revoke("bob", alice.ID), "not bob's session")
+	assert.True(t, m.Revoke("alice", alice.ID))
+	_, ok = m.Validate(aliceToken)
+	assert.False(t, ok)
+	assert.Equal(t, 1, m.RevokeAll("bob"))
+	assert.Empty(t, m.Sessions(""))
+
+	m.Logout(token)
+}
+
+func TestLockout(t *testing.T) {
+	m, err := NewManager(Config{MaxFailedLogins: 3, LockoutDuration: time.Minute}, testUsers(t))
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, _, err = m.Login("alice", "guess", "", "10.0.0.1", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = m.Login("alice", "s3cret", "", "10.0.0.9", "")
+	var locked *LockedOutError
+	require.True(t, errors.As(err, &locked), "the account is locked from any address")
+	assert.Equal(t, now.Add(time.Minute), locked.Until)
+	_, _, err = m.Login("bob", "s3cret", "", "10.0.0.1", "")
+	assert.True(t, errors.As(err, &locked), "and the address for any account")
+
+	_, _, err = m.Login("bob", "s3cret", "", "10.0.0.2", "")
+	assert.NoError(t, err)
+
+	m.Unlock("alice")
+	_, _, err = m.Login("alice", "s3cret", "", "10.0.0.9", "")
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, _, err = m.Login("bob", "s3cret", "", "10.0.0.1", "")
+	assert.NoError(t, err, "the lockout expired")
+}
+
+func TestTwoFactor(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "sessions.json")
+	m, err := NewManager(Config{StateFile: stateFile}, testUsers(t))
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	codeAt := func(secret string, at time.Time) string {
+		code, err := totpCode(secret, totpStepAt(at))
+		require.NoError(t, err)
+		return code
+	}
+
+	assert.ErrorIs(t, m.ConfirmTOTP("alice", "123456"), ErrNotEnrolling)
+	enrollment, err := m.EnrollTOTP("alice")
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URL, "otpauth://totp/OllamaMax:alice?")
+	assert.False(t, m.TwoFactorEnabled("alice"), "not before confirmation")
+	assert.ErrorIs(t, m.ConfirmTOTP("alice", "000000"), ErrInvalidCode)
+	require.NoError(t, m.ConfirmTOTP("alice", codeAt(enrollment.Secret, now)))
+	assert.True(t, m.TwoFactorEnabled("alice"))
+
+	_, _, err = m.Login("alice", "s3cret", "", "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrTwoFactorRequired)
+	_, _, err = m.Login("alice", "s3cret", codeAt(enrollment.Secret, now), "10.0.0.1", "")
+	assert.ErrorIs(t, err, ErrInvalidCode, "the confirmation code cannot be replayed")
+
+	now = now.Add(totpStep)
+	_, s, err := m.Login("alice", "s3cret", codeAt(enrollment.Secret, now), "10.0.0.1", "")
+	require.NoError(t, err)
+	assert.True(t, s.TwoFactor)
+
+	// Enrollments survive restarts
+	restarted, err := NewManager(Config{StateFile: stateFile}, testUsers(t))
+	require.NoError(t, err)
+	restarted.now = m.now
+	assert.True(t, restarted.TwoFactorEnabled("alice"))
+	now = now.Add(totpStep)
+	assert.ErrorIs(t, restarted.DisableTOTP("alice", "000000"), ErrInvalidCode)
+	require.NoError(t, restarted.DisableTOTP("alice", codeAt(enrollment.Secret, now)))
+	assert.False(t, restarted.TwoFactorEnabled("alice"))
+	assert.ErrorIs(t, restarted.DisableTOTP("alice", "000000"), ErrTwoFactorDisabled)
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, the ones authenticator apps assume by default
+const (
+	totpStep   = 30 * time.Second
+	totpDigits = 6
+	// totpSkew is how many steps a code may be off by, for clock drift
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Enrollment is a TOTP secret offered to a user, to add to an
+// authenticator app by scanning URL as a QR code
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+// newTOTPSecret returns a random 160 bit secret, base32 encoded
+func newTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpURL returns the otpauth URL authenticator apps enroll secret from
+func totpURL(issuer, username, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+username) + "?" + v.Encode()
+}
+
+// totpCode returns the RFC 6238 code of a secret for a time step
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000), nil
+}
+
+// totpStepAt returns the time step of t
+func totpStepAt(t time.Time) int64 {
+	return t.Unix() / int64(totpStep/time.Second)
+}
+
+// verifyTOTP returns the time step a code is valid for at now, within the
+// allowed skew, and only if later than the step last used so that codes
+// cannot be replayed
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStepAt(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,570 @@
+package shardfetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// shard is a file of a model being downloaded
+type shard struct {
+	source string
+	path   string
+	size   int64
+	header *Header
+	// prefix is the start of the file read to parse the header
+	prefix []byte
+	file   *os.File
+}
+
+// partial is where the shard is written until it is complete, hidden from
+// listings of the model directory
+func (s *shard) partial() string {
+	return filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".partial")
+}
+
+// group is the tensors needed together: the shared tensors, the input or
+// output tensors, or the tensors of a layer
+type group struct {
+	role      string
+	layer     int
+	ranges    []byteRange
+	remaining int
+	done      chan struct{}
+	added     bool
+}
+
+type byteRange struct {
+	shard  *shard
+	off, n int64
+}
+
+// chunk is a range request
+type chunk struct {
+	byteRange
+	group *group
+}
+
+// Download is the download of the shards of a model, in the order its
+// stage needs them
+type Download struct {
+	model   string
+	stage   Stage
+	layers  int
+	started time.Time
+	shards  []*shard
+	// groups are in fetch order; the stage can be served once the groups
+	// up to servable are complete and is whole once those up to stageReady
+	// are
+	groups      []*group
+	layerGroups map[int]*group
+	servable    int
+	stageReady  int
+	chunks      []chunk
+	bytes       int64
+	cancel      context.CancelFunc
+	done        chan struct{}
+	logger      *slog.Logger
+
+	mu           sync.Mutex
+	next         int
+	bytesDone    int64
+	layersReady  int
+	servableAt   time.Time
+	stageReadyAt time.Time
+	finishedAt   time.Time
+	err          error
+}
+
+// Progress is the state of a download
+type Progress struct {
+	Model       string   `json:"model"`
+	Shards      []string `json:"shards"`
+	Stage       Stage    `json:"stage"`
+	Layers      int      `json:"layers"`
+	LayersReady int      `json:"layers_ready"`
+	Bytes       int64    `json:"bytes"`
+	BytesDone   int64    `json:"bytes_done"`
+	// Servable is set once the stage can start serving, and StageReady
+	// once all its layers are downloaded
+	Servable   bool      `json:"servable"`
+	StageReady bool      `json:"stage_ready"`
+	Done       bool      `json:"done"`
+	Error      string    `json:"error,omitempty"`
+	Started    time.Time `json:"started"`
+	// ServableAfter and StageReadyAfter are how long after the start the
+	// stage became servable and whole
+	ServableAfter   time.Duration `json:"servable_after,omitempty"`
+	StageReadyAfter time.Duration `json:"stage_ready_after,omitempty"`
+	Duration        time.Duration `json:"duration,omitempty"`
+}
+
+// newDownload plans the download of the shards: their tensors are grouped
+// by what needs them, the groups ordered by when the stage needs them, and
+// the groups split into chunks
+func newDownload(req Request, shards []*shard, chunkSize int64) (*Download, error) {
+	d := &Download{
+		model:       req.Model,
+		started:     time.Now(),
+		shards:      shards,
+		layerGroups: make(map[int]*group),
+		done:        make(chan struct{}),
+		logger:      slog.Default(),
+	}
+
+	var shared, input, output *group
+	groupFor := func(t Tensor) *group {
+		switch t.role() {
+		case roleLayer:
+			g, ok := d.layerGroups[t.Layer]
+			if !ok {
+				g = &group{role: roleLayer, layer: t.Layer}
+				d.layerGroups[t.Layer] = g
+			}
+			return g
+		case roleInput:
+			if input == nil {
+				input = &group{role: roleInput, layer: -1}
+			}
+			return input
+		case roleOutput:
+			if output == nil {
+				output = &group{role: roleOutput, layer: -1}
+			}
+			return output
+		default:
+			if shared == nil {
+				shared = &group{role: roleShared, layer: -1}
+			}
+			return shared
+		}
+	}
+	for _, s := range shards {
+		d.layers = max(d.layers, s.header.BlockCount)
+		for _, t := range s.header.Tensors {
+			if t.Layer >= 0 {
+				d.layers = max(d.layers, t.Layer+1)
+			}
+			// The header read already holds the start of the data
+			off, end := max(t.Offset, int64(len(s.prefix))), t.Offset+t.Size
+			if off < end {
+				g := groupFor(t)
+				g.ranges = append(g.ranges, byteRange{shard: s, off: off, n: end - off})
+			} else {
+				groupFor(t)
+			}
+		}
+	}
+	if d.layers == 0 {
+		return nil, fmt.Errorf("%s has no layers", req.Model)
+	}
+
+	d.stage = Stage{First: 0, Last: d.layers - 1}
+	if req.Stage != nil {
+		if req.Stage.First >= d.layers {
+			return nil, fmt.Errorf("stage starts at layer %d but %s has %d layers", req.Stage.First, req.Model, d.layers)
+		}
+		d.stage = Stage{First: req.Stage.First, Last: min(req.Stage.Last, d.layers-1)}
+	}
+
+	add := func(g *group) {
+		if g != nil && !g.added {
+			g.added = true
+			d.groups = append(d.groups, g)
+		}
+	}
+	add(shared)
+	if d.stage.First == 0 {
+		add(input)
+	}
+	add(d.layerGroups[d.stage.First])
+	d.servable = len(d.groups) - 1
+	for layer := d.stage.First + 1; layer <= d.stage.Last; layer++ {
+		add(d.layerGroups[layer])
+	}
+	if d.stage.Last == d.layers-1 {
+		add(output)
+	}
+	d.stageReady = len(d.groups) - 1
+	// Then the layers after the stage, which it may be extended to, and
+	// the rest of the model
+	for layer := d.stage.Last + 1; layer < d.layers; layer++ {
+		add(d.layerGroups[layer])
+	}
+	add(output)
+	for layer := 0; layer < d.stage.First; layer++ {
+		add(d.layerGroups[layer])
+	}
+	add(input)
+
+	index := make(map[*shard]int, len(shards))
+	for i, s := range shards {
+		index[s] = i
+		d.bytes += int64(len(s.prefix))
+	}
+	for _, g := range d.groups {
+		g.done = make(chan struct{})
+		sort.Slice(g.ranges, func(i, j int) bool {
+			a, b := g.ranges[i], g.ranges[j]
+			if a.shard != b.shard {
+				return index[a.shard] < index[b.shard]
+			}
+			return a.off < b.off
+		})
+		for _, r := range mergeRanges(g.ranges) {
+			for off := r.off; off < r.off+r.n; off += chunkSize {
+				n := min(chunkSize, r.off+r.n-off)
+				d.chunks = append(d.chunks, chunk{byteRange: byteRange{shard: r.shard, off: off, n: n}, group: g})
+				g.remaining++
+				d.bytes += n
+			}
+		}
+	}
+	d.bytesDone = d.bytes
+	for _, c := range d.chunks {
+		d.bytesDone -= c.n
+	}
+	for _, g := range d.groups {
+		if g.remaining == 0 {
+			d.completeGroup(g)
+		}
+	}
+	d.checkReady()
+	return d, nil
+}
+
+// mergeRanges joins sorted adjacent ranges
+func mergeRanges(ranges []byteRange) []byteRange {
+	var merged []byteRange
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && merged[last].shard == r.shard && merged[last].off+merged[last].n == r.off {
+			merged[last].n += r.n
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// create opens the partial files, sized to the shards, and writes the
+// header reads to them
+func (d *Download) create() error {
+	for _, s := range d.shards {
+		file, err := os.OpenFile(s.partial(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		s.file = file
+		if err := file.Truncate(s.size); err != nil {
+			return err
+		}
+		if _, err := file.WriteAt(s.prefix, 0); err != nil {
+			return err
+		}
+		s.prefix = nil
+	}
+	return nil
+}
+
+// run downloads the chunks with concurrent workers, then moves the
+// complete shards into place
+func (d *Download) run(ctx context.Context, source Source, concurrency, retries int) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c, ok := d.take(ctx)
+				if !ok {
+					return
+				}
+				if err := d.fetch(ctx, source, c, retries); err != nil {
+					d.fail(err)
+					return
+				}
+				d.complete(c)
+			}
+		}()
+	}
+	wg.Wait()
+	d.finish()
+}
+
+// take returns the next chunk in fetch order
+func (d *Download) take(ctx context.Context) (chunk, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil || ctx.Err() != nil || d.next >= len(d.chunks) {
+		return chunk{}, false
+	}
+	c := d.chunks[d.next]
+	d.next++
+	return c, true
+}
+
+// fetch writes a chunk to its shard, retrying failed reads
+func (d *Download) fetch(ctx context.Context, source Source, c chunk, retries int) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * retryBackoff):
+			}
+		}
+		if err = d.fetchOnce(ctx, source, c); err == nil || ctx.Err() != nil {
+			return err
+		}
+		d.logger.Warn("shard range request failed", "shard", c.shard.source, "offset", c.off, "attempt", attempt+1, "error", err)
+	}
+	return err
+}
+
+func (d *Download) fetchOnce(ctx context.Context, source Source, c chunk) error {
+	rc, err := source.ReadRange(ctx, c.shard.source, c.off, c.n)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	n, err := io.Copy(io.NewOffsetWriter(c.shard.file, c.off), io.LimitReader(rc, c.n))
+	if err != nil {
+		return err
+	}
+	if n != c.n {
+		return fmt.Errorf("short read of %s at %d: %d of %d bytes", c.shard.source, c.off, n, c.n)
+	}
+	return nil
+}
+
+// complete records a fetched chunk
+func (d *Download) complete(c chunk) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bytesDone += c.n
+	c.group.remaining--
+	if c.group.remaining == 0 {
+		d.completeGroup(c.group)
+	}
+}
+
+// completeGroup marks the tensors of a group as downloaded. It is called
+// with mu held.
+func (d *Download) completeGroup(g *group) {
+	close(g.done)
+	if g.role == roleLayer {
+		d.layersReady++
+	}
+	d.checkReady()
+}
+
+// checkReady notes when the stage became servable and whole. It is called
+// with mu held.
+func (d *Download) checkReady() {
+	if d.servableAt.IsZero() && d.prefixDone(d.servable) {
+		d.servableAt = time.Now()
+		d.logger.Info("model stage servable", "first_layer", d.stage.First, "after", d.servableAt.Sub(d.started))
+	}
+	if d.stageReadyAt.IsZero() && d.prefixDone(d.stageReady) {
+		d.stageReadyAt = time.Now()
+	}
+}
+
+// prefixDone reports whether the groups up to i are complete
+func (d *Download) prefixDone(i int) bool {
+	for _, g := range d.groups[:i+1] {
+		if g.remaining > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Download) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = err
+		d.cancel()
+	}
+}
+
+// finish closes the shards, renaming them into place when complete
+func (d *Download) finish() {
+	d.mu.Lock()
+	if d.err == nil && d.next < len(d.chunks) {
+		d.err = context.Canceled
+	}
+	err := d.err
+	d.mu.Unlock()
+
+	for _, s := range d.shards {
+		if cerr := s.file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		for _, s := range d.shards {
+			if err = os.Rename(s.partial(), s.path); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		d.removePartials()
+		d.logger.Warn("model fetch failed", "error", err)
+	} else {
+		d.logger.Info("model fetched", "bytes", d.bytes, "duration", time.Since(d.started))
+	}
+
+	d.mu.Lock()
+	d.err = err
+	d.finishedAt = time.Now()
+	d.mu.Unlock()
+	d.cancel()
+	close(d.done)
+}
+
+func (d *Download) removePartials() {
+	for _, s := range d.shards {
+		if s.file != nil {
+			s.file.Close()
+		}
+		os.Remove(s.partial())
+	}
+}
+
+// finished reports whether the download has ended; a nil download is one
+// whose headers are still being read
+func (d *Download) finished() bool {
+	if d == nil {
+		return false
+	}
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel stops the download, discarding what was fetched
+func (d *Download) Cancel() {
+	d.cancel()
+}
+
+// Stage returns the stage the download is ordered for
+func (d *Download) Stage() Stage {
+	return d.stage
+}
+
+// Paths returns where the shards are written once the download completes
+func (d *Download) Paths() []string {
+	paths := make([]string, len(d.shards))
+	for i, s := range d.shards {
+		paths[i] = s.path
+	}
+	return paths
+}
+
+// WaitLayer waits until the tensors of a layer, and those every layer
+// shares, are downloaded
+func (d *Download) WaitLayer(ctx context.Context, layer int) error {
+	g, ok := d.layerGroups[layer]
+	if !ok {
+		return fmt.Errorf("%s has no layer %d", d.model, layer)
+	}
+	if d.groups[0].role == roleShared {
+		if err := d.wait(ctx, d.groups[0]); err != nil {
+			return err
+		}
+	}
+	return d.wait(ctx, g)
+}
+
+// WaitServable waits until the stage can start serving: the shared
+// tensors, the input tensors when it starts the pipeline, and its first
+// layer are downloaded
+func (d *Download) WaitServable(ctx context.Context) error {
+	for _, g := range d.groups[:d.servable+1] {
+		if err := d.wait(ctx, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Wait waits until the download ends, returning its error
+func (d *Download) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Download) wait(ctx context.Context, g *group) error {
+	select {
+	case <-g.done:
+		return nil
+	case <-d.done:
+		// The group may have completed just before the download failed
+		select {
+		case <-g.done:
+			return nil
+		default:
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.err != nil {
+			return d.err
+		}
+		return fmt.Errorf("download of %s ended", d.model)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Progress returns the state of the download
+func (d *Download) Progress() Progress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := Progress{
+		Model:       d.model,
+		Stage:       d.stage,
+		Layers:      d.layers,
+		LayersReady: d.layersReady,
+		Bytes:       d.bytes,
+		BytesDone:   d.bytesDone,
+		Servable:    !d.servableAt.IsZero(),
+		StageReady:  !d.stageReadyAt.IsZero(),
+		Done:        !d.finishedAt.IsZero(),
+		Started:     d.started,
+	}
+	for _, s := range d.shards {
+		p.Shards = append(p.Shards, filepath.Base(s.path))
+	}
+	if d.err != nil {
+		p.Error = d.err.Error()
+	}
+	if p.Servable {
+		p.ServableAfter = d.servableAt.Sub(d.started)
+	}
+	if p.StageReady {
+		p.StageReadyAfter = d.stageReadyAt.Sub(d.started)
+	}
+	if p.Done {
+		p.Duration = d.finishedAt.Sub(d.started)
+	}
+	return p
+}
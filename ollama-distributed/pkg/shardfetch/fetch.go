@@ -0,0 +1,343 @@
+// Package shardfetch downloads the GGUF shards of a model placed on a node
+// in the order its pipeline stage needs them. The headers of the shards are
+// read first to locate the tensors of every layer; the tensors the stage
+// runs first are fetched first, so the node can start serving while the
+// layers it needs later are still downloading.
+package shardfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults
+const (
+	DefaultChunkSize      = 8 << 20
+	DefaultConcurrency    = 4
+	DefaultRetries        = 3
+	DefaultMaxHeaderBytes = 64 << 20
+)
+
+// initialHeaderBytes is the first read of a shard's header, doubled until
+// the header parses
+const initialHeaderBytes = 1 << 20
+
+// retryBackoff is the delay before the first retry of a chunk, growing
+// with each attempt
+const retryBackoff = 200 * time.Millisecond
+
+// Config configures shard fetching
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Dir is where fetched shards are written; defaults to the model
+	// directory
+	Dir string `yaml:"dir"`
+	// ChunkSize is the size of each range request
+	ChunkSize int64 `yaml:"chunk_size" mapstructure:"chunk_size"`
+	// Concurrency is the number of range requests in flight per download
+	Concurrency int `yaml:"concurrency"`
+	// Retries is how often a failed range request is retried before the
+	// download fails
+	Retries int `yaml:"retries"`
+	// MaxHeaderBytes bounds how much of a shard is read to parse its header
+	MaxHeaderBytes int64 `yaml:"max_header_bytes" mapstructure:"max_header_bytes"`
+}
+
+// Validate checks the sizes and limits
+func (c Config) Validate() error {
+	if c.ChunkSize < 0 {
+		return fmt.Errorf("chunk size cannot be negative")
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency cannot be negative")
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries cannot be negative")
+	}
+	if c.MaxHeaderBytes < 0 {
+		return fmt.Errorf("max header bytes cannot be negative")
+	}
+	return nil
+}
+
+func (c Config) withDefaults() Config {
+	if c.ChunkSize == 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+	if c.Concurrency == 0 {
+		c.Concurrency = DefaultConcurrency
+	}
+	if c.Retries == 0 {
+		c.Retries = DefaultRetries
+	}
+	if c.MaxHeaderBytes == 0 {
+		c.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	return c
+}
+
+// Source serves byte ranges of shards
+type Source interface {
+	Size(ctx context.Context, shard string) (int64, error)
+	ReadRange(ctx context.Context, shard string, off, n int64) (io.ReadCloser, error)
+}
+
+// HTTPSource fetches shards by URL with range requests, e.g. from the S3
+// gateway of a node already holding the model
+type HTTPSource struct {
+	Client *http.Client
+}
+
+func (s HTTPSource) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+// Size returns the Content-Length of a HEAD of the shard
+func (s HTTPSource) Size(ctx context.Context, shard string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, shard, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD %s: %s", shard, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("HEAD %s: unknown size", shard)
+	}
+	return resp.ContentLength, nil
+}
+
+// ReadRange requests n bytes of the shard from off; the server must honour
+// the range
+func (s HTTPSource) ReadRange(ctx context.Context, shard string, off, n int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shard, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s, range requests not supported", shard, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Stage is the layers, first to last inclusive, a node runs in a pipeline
+type Stage struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+// Request asks for the shards of a model
+type Request struct {
+	Model string
+	// Shards are the sources of the files of the model
+	Shards []string
+	// Stage is fetched first, starting with its first layer; all layers
+	// in order when unset
+	Stage *Stage
+}
+
+// Fetcher downloads model shards from a source into a directory
+type Fetcher struct {
+	cfg    Config
+	source Source
+	logger *slog.Logger
+	// headerBytes is the first read of each header
+	headerBytes int64
+
+	mu        sync.Mutex
+	downloads map[string]*Download
+}
+
+// NewFetcher creates a fetcher reading from source
+func NewFetcher(cfg Config, source Source) *Fetcher {
+	return &Fetcher{
+		cfg:         cfg.withDefaults(),
+		source:      source,
+		logger:      slog.Default(),
+		headerBytes: initialHeaderBytes,
+		downloads:   make(map[string]*Download),
+	}
+}
+
+// Config returns the fetcher's configuration, defaults applied
+func (f *Fetcher) Config() Config {
+	return f.cfg
+}
+
+// Fetch reads the headers of the shards within ctx and starts downloading
+// their tensors in the order the stage needs them. The download runs in
+// the background until it completes or is cancelled.
+func (f *Fetcher) Fetch(ctx context.Context, req Request) (*Download, error) {
+	if req.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if len(req.Shards) == 0 {
+		return nil, fmt.Errorf("no shards to fetch")
+	}
+	if req.Stage != nil && (req.Stage.First < 0 || req.Stage.Last < req.Stage.First) {
+		return nil, fmt.Errorf("invalid stage %d-%d", req.Stage.First, req.Stage.Last)
+	}
+
+	f.mu.Lock()
+	if d, ok := f.downloads[req.Model]; ok && !d.finished() {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("%s is already being fetched", req.Model)
+	}
+	// Reserve the model while its headers are read
+	f.downloads[req.Model] = nil
+	f.mu.Unlock()
+
+	d, err := f.start(ctx, req)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		delete(f.downloads, req.Model)
+		return nil, err
+	}
+	f.downloads[req.Model] = d
+	return d, nil
+}
+
+func (f *Fetcher) start(ctx context.Context, req Request) (*Download, error) {
+	if err := os.MkdirAll(f.cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	shards := make([]*shard, 0, len(req.Shards))
+	names := make(map[string]bool, len(req.Shards))
+	for _, source := range req.Shards {
+		name, err := shardName(source)
+		if err != nil {
+			return nil, err
+		}
+		if names[name] {
+			return nil, fmt.Errorf("shard %s is listed twice", name)
+		}
+		names[name] = true
+		s, err := f.readHeader(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		s.path = filepath.Join(f.cfg.Dir, name)
+		shards = append(shards, s)
+	}
+
+	d, err := newDownload(req, shards, f.cfg.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.create(); err != nil {
+		d.removePartials()
+		return nil, err
+	}
+	d.logger = f.logger.With("model", req.Model)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(runCtx, f.source, f.cfg.Concurrency, f.cfg.Retries)
+	return d, nil
+}
+
+// readHeader reads the start of a shard until its GGUF header parses
+func (f *Fetcher) readHeader(ctx context.Context, source string) (*shard, error) {
+	size, err := f.source.Size(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	for n := min(f.headerBytes, size, f.cfg.MaxHeaderBytes); ; n = min(2*n, size, f.cfg.MaxHeaderBytes) {
+		data, err := f.read(ctx, source, 0, n)
+		if err != nil {
+			return nil, err
+		}
+		header, err := ParseHeader(data, size)
+		if err == nil {
+			return &shard{source: source, size: size, header: header, prefix: data}, nil
+		}
+		if !errors.Is(err, errShortHeader) {
+			return nil, err
+		}
+		if n >= size || n >= f.cfg.MaxHeaderBytes {
+			return nil, fmt.Errorf("GGUF header larger than %d bytes", n)
+		}
+	}
+}
+
+func (f *Fetcher) read(ctx context.Context, source string, off, n int64) ([]byte, error) {
+	rc, err := f.source.ReadRange(ctx, source, off, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, n)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Download returns the latest download of a model
+func (f *Fetcher) Download(model string) (*Download, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.downloads[model]
+	return d, ok && d != nil
+}
+
+// Downloads returns the progress of the latest download of every model
+func (f *Fetcher) Downloads() []Progress {
+	f.mu.Lock()
+	downloads := make([]*Download, 0, len(f.downloads))
+	for _, d := range f.downloads {
+		if d != nil {
+			downloads = append(downloads, d)
+		}
+	}
+	f.mu.Unlock()
+
+	progress := make([]Progress, 0, len(downloads))
+	for _, d := range downloads {
+		progress = append(progress, d.Progress())
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Model < progress[j].Model })
+	return progress
+}
+
+// shardName returns the file name of a shard source
+func shardName(source string) (string, error) {
+	p := source
+	if u, err := url.Parse(source); err == nil && u.Path != "" {
+		p = u.Path
+	}
+	name := path.Base(p)
+	if name == "." || name == "/" || name == ".." || name == "" {
+		return "", fmt.Errorf("shard %q has no file name", source)
+	}
+	return name, nil
+}
@@ -0,0 +1,362 @@
+package shardfetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildGGUF returns a GGUF file with the tensors of a model of n layers,
+// each tensor of size bytes, laid out input first and shared tensors last
+func buildGGUF(t *testing.T, layers int, size uint64) []byte {
+	t.Helper()
+	names := []string{"token_embd.weight"}
+	for i := 0; i < layers; i++ {
+		names = append(names, fmt.Sprintf("blk.%d.attn_q.weight", i), fmt.Sprintf("blk.%d.ffn_up.weight", i))
+	}
+	names = append(names, "output_norm.weight", "output.weight", "rope_freqs.weight")
+
+	var b bytes.Buffer
+	w := func(v interface{}) { require.NoError(t, binary.Write(&b, binary.LittleEndian, v)) }
+	str := func(s string) { w(uint64(len(s))); b.WriteString(s) }
+	b.WriteString(ggufMagic)
+	w(uint32(3))
+	w(uint64(len(names)))
+	w(uint64(3))
+	str("general.architecture")
+	w(ggufString)
+	str("llama")
+	str("llama.block_count")
+	w(ggufUint32)
+	w(uint32(layers))
+	str("tokenizer.ggml.scores")
+	w(ggufArray)
+	w(ggufFloat32)
+	w(uint64(4))
+	b.Write(make([]byte, 16))
+	for i, name := range names {
+		str(name)
+		w(uint32(1))
+		w(size / 4)
+		w(uint32(0))
+		w(uint64(i) * size)
+	}
+	b.Write(make([]byte, (defaultAlignment-b.Len()%defaultAlignment)%defaultAlignment))
+	for i := range names {
+		b.Write(bytes.Repeat([]byte{byte(i + 1)}, int(size)))
+	}
+	return b.Bytes()
+}
+
+// memSource serves files from memory, recording the offsets read and
+// holding reads matching gate until it is released
+type memSource struct {
+	files map[string][]byte
+	gate  func(off int64) bool
+	open  chan struct{}
+	fail  func(off int64) bool
+
+	mu    sync.Mutex
+	reads []int64
+}
+
+func (s *memSource) Size(ctx context.Context, shard string) (int64, error) {
+	data, ok := s.files[shard]
+	if !ok {
+		return 0, fmt.Errorf("%s not found", shard)
+	}
+	return int64(len(data)), nil
+}
+
+func (s *memSource) ReadRange(ctx context.Context, shard string, off, n int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	s.reads = append(s.reads, off)
+	s.mu.Unlock()
+	if s.gate != nil && s.gate(off) {
+		select {
+		case <-s.open:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if s.fail != nil && s.fail(off) {
+		return nil, errors.New("connection reset")
+	}
+	return io.NopCloser(bytes.NewReader(s.files[shard][off : off+n])), nil
+}
+
+// groupAt names the group of the tensor holding off
+func groupAt(h *Header, off int64) string {
+	for _, t := range h.Tensors {
+		if off >= t.Offset && off < t.Offset+t.Size {
+			if t.Layer >= 0 {
+				return fmt.Sprint(t.Layer)
+			}
+			return t.role()
+		}
+	}
+	return "header"
+}
+
+func newTestFetcher(t *testing.T, cfg Config, source Source) *Fetcher {
+	cfg.Dir = t.TempDir()
+	f := NewFetcher(cfg, source)
+	f.headerBytes = 256
+	return f
+}
+
+func TestParseHeader(t *testing.T) {
+	data := buildGGUF(t, 4, 1024)
+	h, err := ParseHeader(data, int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "llama", h.Architecture)
+	assert.Equal(t, 4, h.BlockCount)
+	require.Len(t, h.Tensors, 12)
+	assert.Equal(t, int64(0), h.DataOffset%defaultAlignment)
+	assert.Equal(t, Tensor{Name: "token_embd.weight", Offset: h.DataOffset, Size: 1024, Layer: -1}, h.Tensors[0])
+	assert.Equal(t, 3, h.Tensors[8].Layer)
+	assert.Equal(t, roleOutput, h.Tensors[10].role())
+	assert.Equal(t, roleShared, h.Tensors[11].role())
+	assert.Equal(t, int64(len(data)), h.Tensors[11].Offset+h.Tensors[11].Size)
+
+	_, err = ParseHeader(data[:100], int64(len(data)))
+	assert.ErrorIs(t, err, errShortHeader)
+	_, err = ParseHeader([]byte("not a model file"), 16)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errShortHeader)
+}
+
+func TestFetchOrdersStage(t *testing.T) {
+	data := buildGGUF(t, 8, 2048)
+	source := &memSource{files: map[string][]byte{"http://node-1/models/llama3.gguf": data}}
+	f := newTestFetcher(t, Config{ChunkSize: 4096, Concurrency: 1}, source)
+
+	d, err := f.Fetch(context.Background(), Request{
+		Model:  "llama3",
+		Shards: []string{"http://node-1/models/llama3.gguf"},
+		Stage:  &Stage{First: 3, Last: 5},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Wait(ctx))
+
+	h, err := ParseHeader(data, int64(len(data)))
+	require.NoError(t, err)
+	var order []string
+	for _, off := range source.reads {
+		group := groupAt(h, off)
+		if group != "header" && (len(order) == 0 || order[len(order)-1] != group) {
+			order = append(order, group)
+		}
+	}
+	assert.Equal(t, []string{"shared", "3", "4", "5", "6", "7", "output", "0", "1", "2", "input"}, order)
+
+	fetched, err := os.ReadFile(filepath.Join(f.Config().Dir, "llama3.gguf"))
+	require.NoError(t, err)
+	assert.Equal(t, data, fetched)
+	entries, err := os.ReadDir(f.Config().Dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no partial file left")
+
+	p := d.Progress()
+	assert.True(t, p.Done)
+	assert.True(t, p.Servable)
+	assert.True(t, p.StageReady)
+	assert.Equal(t, Stage{First: 3, Last: 5}, p.Stage)
+	assert.Equal(t, 8, p.Layers)
+	assert.Equal(t, 8, p.LayersReady)
+	assert.Equal(t, p.Bytes, p.BytesDone)
+	assert.Equal(t, []string{"llama3.gguf"}, p.Shards)
+	assert.Empty(t, p.Error)
+	assert.Equal(t, []Progress{p}, f.Downloads())
+}
+
+func TestServableBeforeLaterLayers(t *testing.T) {
+	data := buildGGUF(t, 8, 2048)
+	h, err := ParseHeader(data, int64(len(data)))
+	require.NoError(t, err)
+	var layer4 Tensor
+	for _, tensor := range h.Tensors {
+		if tensor.Layer == 4 {
+			layer4 = tensor
+			break
+		}
+	}
+	source := &memSource{
+		files: map[string][]byte{"llama3.gguf": data},
+		gate:  func(off int64) bool { return off >= layer4.Offset && off < layer4.Offset+2*layer4.Size },
+		open:  make(chan struct{}),
+	}
+	f := newTestFetcher(t, Config{ChunkSize: 4096, Concurrency: 2}, source)
+
+	d, err := f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{"llama3.gguf"}, Stage: &Stage{First: 3, Last: 5}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, d.WaitServable(ctx))
+	require.NoError(t, d.WaitLayer(ctx, 5))
+	p := d.Progress()
+	assert.True(t, p.Servable)
+	assert.False(t, p.StageReady, "layer 4 is still downloading")
+	assert.False(t, p.Done)
+	assert.Less(t, p.BytesDone, p.Bytes)
+
+	_, err = f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{"llama3.gguf"}})
+	assert.Error(t, err, "already being fetched")
+
+	close(source.open)
+	require.NoError(t, d.Wait(ctx))
+	p = d.Progress()
+	assert.True(t, p.StageReady)
+	assert.True(t, p.Done)
+	assert.GreaterOrEqual(t, p.StageReadyAfter, p.ServableAfter)
+}
+
+func TestFetchFailure(t *testing.T) {
+	data := buildGGUF(t, 4, 2048)
+	h, err := ParseHeader(data, int64(len(data)))
+	require.NoError(t, err)
+	source := &memSource{
+		files: map[string][]byte{"llama3.gguf": data},
+		fail:  func(off int64) bool { return off == h.Tensors[5].Offset },
+	}
+	f := newTestFetcher(t, Config{ChunkSize: 2048, Concurrency: 1, Retries: 1}, source)
+
+	d, err := f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{"llama3.gguf"}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = d.Wait(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	assert.Error(t, d.WaitLayer(ctx, 3))
+	assert.NoError(t, d.WaitLayer(ctx, 0), "fetched before the failure")
+
+	entries, err := os.ReadDir(f.Config().Dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the partial file is removed")
+	assert.Contains(t, d.Progress().Error, "connection reset")
+
+	// A failed download can be retried
+	source.fail = nil
+	d, err = f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{"llama3.gguf"}})
+	require.NoError(t, err)
+	assert.NoError(t, d.Wait(ctx))
+}
+
+func TestCancel(t *testing.T) {
+	data := buildGGUF(t, 4, 2048)
+	source := &memSource{
+		files: map[string][]byte{"llama3.gguf": data},
+		gate:  func(off int64) bool { return off > 1024 },
+		open:  make(chan struct{}),
+	}
+	f := newTestFetcher(t, Config{}, source)
+
+	d, err := f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{"llama3.gguf"}})
+	require.NoError(t, err)
+	d.Cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.ErrorIs(t, d.Wait(ctx), context.Canceled)
+	assert.True(t, d.Progress().Done)
+}
+
+func TestFetchOverHTTP(t *testing.T) {
+	files := map[string][]byte{
+		"/models/phi3-00001-of-00002.gguf": buildGGUF(t, 3, 1024),
+		"/models/phi3-00002-of-00002.gguf": buildGGUF(t, 3, 4096),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	f := newTestFetcher(t, Config{ChunkSize: 1000}, HTTPSource{Client: srv.Client()})
+	d, err := f.Fetch(context.Background(), Request{
+		Model:  "phi3",
+		Shards: []string{srv.URL + "/models/phi3-00001-of-00002.gguf", srv.URL + "/models/phi3-00002-of-00002.gguf"},
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Wait(ctx))
+	for name, data := range files {
+		fetched, err := os.ReadFile(filepath.Join(f.Config().Dir, filepath.Base(name)))
+		require.NoError(t, err)
+		assert.Equal(t, data, fetched)
+	}
+
+	_, err = f.Fetch(context.Background(), Request{Model: "missing", Shards: []string{srv.URL + "/models/missing.gguf"}})
+	assert.Error(t, err)
+}
+
+func TestFetchRequiresRangeRequests(t *testing.T) {
+	data := buildGGUF(t, 2, 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	f := newTestFetcher(t, Config{}, HTTPSource{Client: srv.Client()})
+	_, err := f.Fetch(context.Background(), Request{Model: "llama3", Shards: []string{srv.URL + "/llama3.gguf"}})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "range requests not supported"))
+}
+
+func TestFetchValidation(t *testing.T) {
+	data := buildGGUF(t, 4, 1024)
+	source := &memSource{files: map[string][]byte{"llama3.gguf": data, "dir/llama3.gguf": data, "notes.txt": []byte("hello, this is not a model")}}
+	f := newTestFetcher(t, Config{}, source)
+	ctx := context.Background()
+
+	_, err := f.Fetch(ctx, Request{Shards: []string{"llama3.gguf"}})
+	assert.Error(t, err, "model is required")
+	_, err = f.Fetch(ctx, Request{Model: "llama3"})
+	assert.Error(t, err, "no shards")
+	_, err = f.Fetch(ctx, Request{Model: "llama3", Shards: []string{"llama3.gguf"}, Stage: &Stage{First: 2, Last: 1}})
+	assert.Error(t, err)
+	_, err = f.Fetch(ctx, Request{Model: "llama3", Shards: []string{"llama3.gguf"}, Stage: &Stage{First: 4, Last: 6}})
+	assert.Error(t, err, "the model has 4 layers")
+	_, err = f.Fetch(ctx, Request{Model: "llama3", Shards: []string{"llama3.gguf", "dir/llama3.gguf"}})
+	assert.Error(t, err, "shards with the same name")
+	_, err = f.Fetch(ctx, Request{Model: "notes", Shards: []string{"notes.txt"}})
+	assert.Error(t, err)
+	assert.Empty(t, f.Downloads())
+
+	d, err := f.Fetch(ctx, Request{Model: "llama3", Shards: []string{"llama3.gguf"}, Stage: &Stage{First: 2, Last: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, Stage{First: 2, Last: 3}, d.Stage(), "clamped to the model")
+	assert.NoError(t, d.Wait(ctx))
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{ChunkSize: -1}.Validate())
+	assert.Error(t, Config{Concurrency: -1}.Validate())
+	assert.Error(t, Config{Retries: -1}.Validate())
+	assert.Error(t, Config{MaxHeaderBytes: -1}.Validate())
+	assert.Equal(t, int64(DefaultChunkSize), NewFetcher(Config{}, nil).Config().ChunkSize)
+}
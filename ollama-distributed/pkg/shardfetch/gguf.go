@@ -0,0 +1,310 @@
+package shardfetch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ggufMagic starts every GGUF file
+const ggufMagic = "GGUF"
+
+// defaultAlignment aligns tensor data when general.alignment is unset
+const defaultAlignment = 32
+
+// GGUF metadata value types
+const (
+	ggufUint8 uint32 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+// errShortHeader is returned while more of the file is needed to parse its
+// header
+var errShortHeader = errors.New("gguf header truncated")
+
+// Tensor roles besides the blocks of layers
+const (
+	roleLayer  = "layer"
+	roleInput  = "input"  // token embeddings, needed by the first layer
+	roleOutput = "output" // output norm and projection, after the last layer
+	roleShared = "shared" // e.g. rope frequencies, needed by every layer
+)
+
+// Header is what fetching needs from the header of a GGUF file
+type Header struct {
+	Version      uint32
+	Architecture string
+	// BlockCount is the number of layers of the model, as declared in its
+	// metadata; zero when not declared
+	BlockCount int
+	// Split numbers the file among the shards of a split model
+	Split, SplitCount int
+	// DataOffset is where the tensor data starts
+	DataOffset int64
+	Tensors    []Tensor
+}
+
+// Tensor is a tensor of a GGUF file and where its data lies in the file
+type Tensor struct {
+	Name   string
+	Offset int64
+	Size   int64
+	// Layer is the layer of a blk.N tensor, -1 for the others
+	Layer int
+}
+
+// role returns what a tensor is needed for
+func (t Tensor) role() string {
+	switch {
+	case t.Layer >= 0:
+		return roleLayer
+	case strings.HasPrefix(t.Name, "token_embd"):
+		return roleInput
+	case strings.HasPrefix(t.Name, "output"):
+		return roleOutput
+	default:
+		return roleShared
+	}
+}
+
+// ParseHeader parses the header of a GGUF file of fileSize bytes from its
+// first bytes. Tensor sizes are derived from the offsets of the tensors.
+func ParseHeader(data []byte, fileSize int64) (*Header, error) {
+	r := &ggufReader{data: data}
+	if string(r.bytes(4)) != ggufMagic {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+
+	h := &Header{Version: r.u32()}
+	if r.err == nil && (h.Version < 2 || h.Version > 3) {
+		return nil, fmt.Errorf("unsupported GGUF version %d", h.Version)
+	}
+	tensorCount := r.u64()
+	kvCount := r.u64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if tensorCount > uint64(len(data)) || kvCount > uint64(len(data)) {
+		if uint64(len(data)) >= uint64(fileSize) {
+			return nil, fmt.Errorf("implausible GGUF tensor or metadata count")
+		}
+		return nil, errShortHeader
+	}
+
+	alignment := int64(defaultAlignment)
+	blockCounts := make(map[string]int)
+	for i := uint64(0); i < kvCount && r.err == nil; i++ {
+		key := r.str()
+		typ := r.u32()
+		value := r.value(typ)
+		switch {
+		case key == "general.alignment":
+			if n, ok := asInt(value); ok && n > 0 {
+				alignment = int64(n)
+			}
+		case key == "general.architecture":
+			h.Architecture, _ = value.(string)
+		case key == "split.no":
+			h.Split, _ = asInt(value)
+		case key == "split.count":
+			h.SplitCount, _ = asInt(value)
+		case strings.HasSuffix(key, ".block_count"):
+			if n, ok := asInt(value); ok {
+				blockCounts[strings.TrimSuffix(key, ".block_count")] = n
+			}
+		}
+	}
+
+	h.Tensors = make([]Tensor, 0, min(tensorCount, 1<<16))
+	for i := uint64(0); i < tensorCount && r.err == nil; i++ {
+		name := r.str()
+		dims := r.u32()
+		for d := uint32(0); d < dims; d++ {
+			r.u64()
+		}
+		r.u32() // type
+		offset := r.u64()
+		if r.err == nil && offset > uint64(fileSize) {
+			return nil, fmt.Errorf("tensor %s lies outside the file", name)
+		}
+		h.Tensors = append(h.Tensors, Tensor{Name: name, Offset: int64(offset), Layer: tensorLayer(name)})
+	}
+	if r.err != nil {
+		if errors.Is(r.err, errShortHeader) && int64(len(data)) >= fileSize {
+			return nil, fmt.Errorf("GGUF header runs past the end of the file")
+		}
+		return nil, r.err
+	}
+	h.BlockCount = blockCounts[h.Architecture]
+
+	h.DataOffset = (int64(r.off) + alignment - 1) / alignment * alignment
+	sort.SliceStable(h.Tensors, func(i, j int) bool { return h.Tensors[i].Offset < h.Tensors[j].Offset })
+	for i := range h.Tensors {
+		end := fileSize
+		if i+1 < len(h.Tensors) {
+			end = h.DataOffset + h.Tensors[i+1].Offset
+		}
+		h.Tensors[i].Offset += h.DataOffset
+		h.Tensors[i].Size = end - h.Tensors[i].Offset
+		if h.Tensors[i].Size < 0 || end > fileSize {
+			return nil, fmt.Errorf("tensor %s lies outside the file", h.Tensors[i].Name)
+		}
+	}
+	return h, nil
+}
+
+// tensorLayer returns the layer of a blk.N tensor, -1 for the others
+func tensorLayer(name string) int {
+	rest, ok := strings.CutPrefix(name, "blk.")
+	if !ok {
+		return -1
+	}
+	n, _, _ := strings.Cut(rest, ".")
+	layer, err := strconv.Atoi(n)
+	if err != nil || layer < 0 {
+		return -1
+	}
+	return layer
+}
+
+func asInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case uint64:
+		if n > math.MaxInt32 {
+			return 0, false
+		}
+		return int(n), true
+	case int64:
+		return int(n), n >= 0 && n <= math.MaxInt32
+	}
+	return 0, false
+}
+
+// ggufReader reads the little-endian values of a GGUF header, recording
+// the first error
+type ggufReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *ggufReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)-r.off) {
+		r.err = errShortHeader
+		return nil
+	}
+	b := r.data[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b
+}
+
+func (r *ggufReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) str() string {
+	return string(r.bytes(r.u64()))
+}
+
+// value reads a metadata value, returning integers as uint64 or int64 and
+// skipping arrays and floats
+func (r *ggufReader) value(typ uint32) interface{} {
+	switch typ {
+	case ggufUint8, ggufBool:
+		if b := r.bytes(1); b != nil {
+			return uint64(b[0])
+		}
+	case ggufInt8:
+		if b := r.bytes(1); b != nil {
+			return int64(int8(b[0]))
+		}
+	case ggufUint16:
+		if b := r.bytes(2); b != nil {
+			return uint64(binary.LittleEndian.Uint16(b))
+		}
+	case ggufInt16:
+		if b := r.bytes(2); b != nil {
+			return int64(int16(binary.LittleEndian.Uint16(b)))
+		}
+	case ggufUint32:
+		return uint64(r.u32())
+	case ggufInt32:
+		return int64(int32(r.u32()))
+	case ggufUint64:
+		return r.u64()
+	case ggufInt64:
+		return int64(r.u64())
+	case ggufFloat32:
+		r.bytes(4)
+	case ggufFloat64:
+		r.bytes(8)
+	case ggufString:
+		return r.str()
+	case ggufArray:
+		elem := r.u32()
+		count := r.u64()
+		// Every element takes at least a byte
+		if r.err == nil && count > uint64(len(r.data)-r.off) {
+			r.err = errShortHeader
+			return nil
+		}
+		if size, fixed := fixedSize(elem); fixed {
+			r.bytes(count * size)
+			return nil
+		}
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			r.value(elem)
+		}
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown GGUF metadata type %d", typ)
+		}
+	}
+	return nil
+}
+
+// fixedSize returns the size of values of a fixed-size type
+func fixedSize(typ uint32) (uint64, bool) {
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		return 1, true
+	case ggufUint16, ggufInt16:
+		return 2, true
+	case ggufUint32, ggufInt32, ggufFloat32:
+		return 4, true
+	case ggufUint64, ggufInt64, ggufFloat64:
+		return 8, true
+	}
+	return 0, false
+}
@@ -0,0 +1,166 @@
+// Package simulation predicts how a hypothetical cluster would serve a
+// workload. It replays a recorded or synthetic trace offline through the
+// scheduler's load balancer, placement rules and partitioner against
+// simulated nodes, and reports the predicted latencies, utilization and
+// bottlenecks, so capacity can be planned before nodes are bought.
+//
+// The nodes are modeled simply: a node runs up to Slots requests at once at
+// a fixed token rate, queues the rest in arrival order, and loads models
+// from disk into memory on first use, evicting the least recently used.
+package simulation
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the node and cluster specs
+const (
+	DefaultAlgorithm       = "least_effective_load"
+	DefaultStrategy        = "layerwise"
+	defaultSlots           = 4
+	defaultTokensPerSecond = 30
+	defaultPromptPerSecond = 500
+	defaultLoadMBPerSecond = 500
+	bytesPerGB             = 1 << 30
+	bytesPerMB             = 1 << 20
+)
+
+// Cluster describes a hypothetical cluster
+type Cluster struct {
+	Nodes  []NodeSpec  `yaml:"nodes" json:"nodes"`
+	Models []ModelSpec `yaml:"models" json:"models"`
+	// Placement are the affinity, anti-affinity and toleration rules
+	Placement placement.Constraints `yaml:"placement" json:"placement"`
+	// Algorithm is the load balancing algorithm choosing among the nodes
+	Algorithm string `yaml:"algorithm" json:"algorithm,omitempty"`
+	// Strategy is the partitioning strategy of every request
+	Strategy string `yaml:"strategy" json:"strategy,omitempty"`
+	// Seed seeds the random choices of the load balancer
+	Seed int64 `yaml:"seed" json:"seed,omitempty"`
+}
+
+// NodeSpec describes Count identical nodes, named Name-1 to Name-Count
+type NodeSpec struct {
+	Name  string `yaml:"name" json:"name"`
+	Count int    `yaml:"count" json:"count"`
+	// Slots is how many requests a node runs at once
+	Slots    int     `yaml:"slots" json:"slots"`
+	CPUCores int64   `yaml:"cpu_cores" json:"cpu_cores"`
+	GPUs     int     `yaml:"gpus" json:"gpus"`
+	MemoryGB float64 `yaml:"memory_gb" json:"memory_gb"`
+	// TokensPerSecond is the generation rate of one request, and
+	// PromptTokensPerSecond its prompt processing rate
+	TokensPerSecond       float64 `yaml:"tokens_per_second" json:"tokens_per_second"`
+	PromptTokensPerSecond float64 `yaml:"prompt_tokens_per_second" json:"prompt_tokens_per_second"`
+	// LoadMBPerSecond is how fast models are read into memory
+	LoadMBPerSecond float64 `yaml:"load_mb_per_second" json:"load_mb_per_second"`
+	// LatencyMS is the network round trip to the node
+	LatencyMS float64           `yaml:"latency_ms" json:"latency_ms"`
+	Labels    map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Taints    []placement.Taint `yaml:"taints" json:"taints,omitempty"`
+	// Models are loaded on the nodes when the simulation starts
+	Models []string `yaml:"models" json:"models,omitempty"`
+}
+
+// ModelSpec describes a model of the workload
+type ModelSpec struct {
+	Name   string  `yaml:"name" json:"name"`
+	SizeGB float64 `yaml:"size_gb" json:"size_gb"`
+	// Speed scales the token rates of the nodes for this model, e.g. 0.5
+	// for a model twice as slow as the one the rates were measured with
+	Speed float64 `yaml:"speed" json:"speed,omitempty"`
+}
+
+// LoadCluster reads a cluster description from a YAML file
+func LoadCluster(file string) (*Cluster, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster description: %w", err)
+	}
+
+	var cluster Cluster
+	if err := yaml.Unmarshal(data, &cluster); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster description %s: %w", file, err)
+	}
+	if err := cluster.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cluster description %s: %w", file, err)
+	}
+	return &cluster, nil
+}
+
+// Validate checks the cluster has nodes with positive resources and known
+// models
+func (c *Cluster) Validate() error {
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("cluster has no nodes")
+	}
+	models := make(map[string]bool)
+	for _, m := range c.Models {
+		if m.Name == "" || m.SizeGB <= 0 {
+			return fmt.Errorf("models need a name and a positive size")
+		}
+		if models[m.Name] {
+			return fmt.Errorf("duplicate model %s", m.Name)
+		}
+		if m.Speed < 0 {
+			return fmt.Errorf("model %s has a negative speed", m.Name)
+		}
+		models[m.Name] = true
+	}
+	names := make(map[string]bool)
+	for _, n := range c.Nodes {
+		if n.Name == "" || n.Count <= 0 || n.MemoryGB <= 0 {
+			return fmt.Errorf("nodes need a name, a positive count and memory")
+		}
+		if names[n.Name] {
+			return fmt.Errorf("duplicate node spec %s", n.Name)
+		}
+		names[n.Name] = true
+		if n.Slots < 0 || n.TokensPerSecond < 0 || n.PromptTokensPerSecond < 0 || n.LoadMBPerSecond < 0 || n.LatencyMS < 0 {
+			return fmt.Errorf("node spec %s has negative values", n.Name)
+		}
+		for _, model := range n.Models {
+			if !models[model] {
+				return fmt.Errorf("node spec %s preloads unknown model %s", n.Name, model)
+			}
+		}
+		if err := (placement.NodeLabels{Labels: n.Labels, Taints: n.Taints}).Validate(); err != nil {
+			return fmt.Errorf("node spec %s: %w", n.Name, err)
+		}
+	}
+	return c.Placement.Validate()
+}
+
+// model returns the spec of a model
+func (c *Cluster) model(name string) (ModelSpec, bool) {
+	for _, m := range c.Models {
+		if m.Name == name {
+			if m.Speed == 0 {
+				m.Speed = 1
+			}
+			return m, true
+		}
+	}
+	return ModelSpec{}, false
+}
+
+// withDefaults returns the spec with its unset rates defaulted
+func (n NodeSpec) withDefaults() NodeSpec {
+	if n.Slots == 0 {
+		n.Slots = defaultSlots
+	}
+	if n.TokensPerSecond == 0 {
+		n.TokensPerSecond = defaultTokensPerSecond
+	}
+	if n.PromptTokensPerSecond == 0 {
+		n.PromptTokensPerSecond = defaultPromptPerSecond
+	}
+	if n.LoadMBPerSecond == 0 {
+		n.LoadMBPerSecond = defaultLoadMBPerSecond
+	}
+	return n
+}
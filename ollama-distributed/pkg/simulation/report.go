@@ -0,0 +1,219 @@
+package simulation
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Thresholds of the bottleneck findings
+const (
+	saturatedUtilization = 0.85
+	thrashingLoadRatio   = 0.2
+	queueDominatedRatio  = 0.5
+)
+
+// Report is the predicted outcome of a simulation
+type Report struct {
+	Requests  int `json:"requests"`
+	Completed int `json:"completed"`
+	Rejected  int `json:"rejected"`
+	// Makespan is when the last request finished
+	Makespan time.Duration `json:"makespan"`
+	// Throughput is in completed requests per second of makespan
+	Throughput      float64       `json:"throughput"`
+	TokensPerSecond float64       `json:"tokens_per_second"`
+	Latency         LatencyStats  `json:"latency"`
+	QueueWait       LatencyStats  `json:"queue_wait"`
+	Nodes           []NodeReport  `json:"nodes"`
+	Models          []ModelReport `json:"models"`
+	// Rejections counts the rejected requests by reason
+	Rejections  map[string]int `json:"rejections,omitempty"`
+	Bottlenecks []string       `json:"bottlenecks,omitempty"`
+}
+
+// LatencyStats summarizes a distribution of durations
+type LatencyStats struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// NodeReport is the predicted load of a node
+type NodeReport struct {
+	ID       string `json:"id"`
+	Requests int    `json:"requests"`
+	// Utilization is the share of the makespan its slots were busy
+	Utilization float64      `json:"utilization"`
+	ModelLoads  int          `json:"model_loads"`
+	Evictions   int          `json:"evictions"`
+	PeakQueue   int          `json:"peak_queue"`
+	QueueWait   LatencyStats `json:"queue_wait"`
+}
+
+// ModelReport is the predicted service of a model
+type ModelReport struct {
+	Name      string       `json:"name"`
+	Requests  int          `json:"requests"`
+	Completed int          `json:"completed"`
+	Latency   LatencyStats `json:"latency"`
+}
+
+// newReport summarizes the results of a simulation
+func newReport(cluster *Cluster, trace []Request, results []result, nodes []*node) *Report {
+	report := &Report{Requests: len(trace), Rejections: make(map[string]int)}
+
+	var latencies, waits []time.Duration
+	perModel := make(map[string][]time.Duration)
+	requests := make(map[string]int)
+	tokens := 0
+	for i, res := range results {
+		requests[res.model]++
+		if res.rejected != "" {
+			report.Rejected++
+			report.Rejections[res.rejected]++
+			continue
+		}
+		report.Completed++
+		report.Makespan = max(report.Makespan, trace[i].At+res.latency)
+		tokens += trace[i].OutputTokens
+		latencies = append(latencies, res.latency)
+		waits = append(waits, res.wait)
+		perModel[res.model] = append(perModel[res.model], res.latency)
+	}
+	report.Latency = latencyStats(latencies)
+	report.QueueWait = latencyStats(waits)
+	if report.Makespan > 0 {
+		report.Throughput = float64(report.Completed) / report.Makespan.Seconds()
+		report.TokensPerSecond = float64(tokens) / report.Makespan.Seconds()
+	}
+
+	for _, n := range nodes {
+		nr := NodeReport{
+			ID:         n.id,
+			Requests:   n.stats.requests,
+			ModelLoads: n.stats.loads,
+			Evictions:  n.stats.evictions,
+			PeakQueue:  n.stats.peakQueue,
+			QueueWait:  latencyStats(n.stats.waits),
+		}
+		if report.Makespan > 0 {
+			nr.Utilization = n.stats.busy.Seconds() / (report.Makespan.Seconds() * float64(len(n.slots)))
+		}
+		report.Nodes = append(report.Nodes, nr)
+	}
+
+	for _, m := range cluster.Models {
+		if requests[m.Name] == 0 {
+			continue
+		}
+		report.Models = append(report.Models, ModelReport{
+			Name:      m.Name,
+			Requests:  requests[m.Name],
+			Completed: len(perModel[m.Name]),
+			Latency:   latencyStats(perModel[m.Name]),
+		})
+	}
+
+	report.Bottlenecks = bottlenecks(report)
+	return report
+}
+
+// bottlenecks explains what limits the cluster, most severe first
+func bottlenecks(r *Report) []string {
+	var found []string
+	if r.Rejected > 0 {
+		reasons := make([]string, 0, len(r.Rejections))
+		for reason, count := range r.Rejections {
+			reasons = append(reasons, fmt.Sprintf("%s: %d", reason, count))
+		}
+		sort.Strings(reasons)
+		found = append(found, fmt.Sprintf("%d of %d requests could not be placed (%s)",
+			r.Rejected, r.Requests, strings.Join(reasons, ", ")))
+	}
+	for _, n := range r.Nodes {
+		if n.Utilization > saturatedUtilization {
+			found = append(found, fmt.Sprintf("node %s is saturated at %.0f%% utilization with up to %d requests queued",
+				n.ID, n.Utilization*100, n.PeakQueue))
+		}
+	}
+	for _, n := range r.Nodes {
+		if n.Evictions > 0 && float64(n.ModelLoads) > thrashingLoadRatio*float64(n.Requests) {
+			found = append(found, fmt.Sprintf("node %s loaded models %d times for %d requests; it lacks the memory for its mix of models",
+				n.ID, n.ModelLoads, n.Requests))
+		}
+	}
+	if r.Latency.P95 > 0 && float64(r.QueueWait.P95) > queueDominatedRatio*float64(r.Latency.P95) {
+		found = append(found, fmt.Sprintf("requests spend most of their time queued (p95 wait %s of p95 latency %s); the cluster needs more slots",
+			r.QueueWait.P95.Round(time.Millisecond), r.Latency.P95.Round(time.Millisecond)))
+	}
+	return found
+}
+
+// latencyStats summarizes durations
+func latencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p float64) time.Duration {
+		return sorted[min(int(p*float64(len(sorted))), len(sorted)-1)]
+	}
+	return LatencyStats{
+		Mean: total / time.Duration(len(sorted)),
+		P50:  percentile(0.50),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// WriteText writes the report for people to read
+func (r *Report) WriteText(w io.Writer) error {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	fmt.Fprintf(w, "Requests:    %d (%d completed, %d rejected)\n", r.Requests, r.Completed, r.Rejected)
+	fmt.Fprintf(w, "Makespan:    %s\n", round(r.Makespan))
+	fmt.Fprintf(w, "Throughput:  %.2f req/s, %.1f tokens/s\n", r.Throughput, r.TokensPerSecond)
+	fmt.Fprintf(w, "Latency:     mean %s  p50 %s  p95 %s  p99 %s  max %s\n",
+		round(r.Latency.Mean), round(r.Latency.P50), round(r.Latency.P95), round(r.Latency.P99), round(r.Latency.Max))
+	fmt.Fprintf(w, "Queue wait:  mean %s  p95 %s  max %s\n\n",
+		round(r.QueueWait.Mean), round(r.QueueWait.P95), round(r.QueueWait.Max))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tREQUESTS\tUTILIZATION\tLOADS\tEVICTIONS\tPEAK QUEUE\tP95 WAIT")
+	for _, n := range r.Nodes {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%d\t%d\t%d\t%s\n",
+			n.ID, n.Requests, n.Utilization*100, n.ModelLoads, n.Evictions, n.PeakQueue, round(n.QueueWait.P95))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "MODEL\tREQUESTS\tCOMPLETED\tP50\tP95\tP99")
+	for _, m := range r.Models {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n",
+			m.Name, m.Requests, m.Completed, round(m.Latency.P50), round(m.Latency.P95), round(m.Latency.P99))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Bottlenecks) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo bottlenecks found")
+		return err
+	}
+	fmt.Fprintln(w, "\nBottlenecks:")
+	for _, b := range r.Bottlenecks {
+		fmt.Fprintf(w, "  - %s\n", b)
+	}
+	return nil
+}
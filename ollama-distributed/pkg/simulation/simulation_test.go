@@ -0,0 +1,201 @@
+package simulation
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCluster(count int) *Cluster {
+	return &Cluster{
+		Nodes: []NodeSpec{{
+			Name:            "gpu",
+			Count:           count,
+			Slots:           2,
+			CPUCores:        16,
+			GPUs:            1,
+			MemoryGB:        24,
+			TokensPerSecond: 50,
+			LatencyMS:       2,
+			Models:          []string{"llama3"},
+		}},
+		Models: []ModelSpec{{Name: "llama3", SizeGB: 5}, {Name: "mixtral", SizeGB: 30}},
+	}
+}
+
+func TestLoadCluster(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cluster.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+nodes:
+  - name: cpu
+    count: 3
+    memory_gb: 64
+    labels: {zone: a}
+models:
+  - name: llama3
+    size_gb: 5
+`), 0o644))
+
+	cluster, err := LoadCluster(file)
+	require.NoError(t, err)
+	require.Len(t, cluster.Nodes, 1)
+	assert.Equal(t, 3, cluster.Nodes[0].Count)
+	assert.Equal(t, defaultSlots, cluster.Nodes[0].withDefaults().Slots)
+
+	require.NoError(t, os.WriteFile(file, []byte("nodes:\n  - name: cpu\n    count: 1\n    memory_gb: 8\n    models: [phi]\n"), 0o644))
+	_, err = LoadCluster(file)
+	assert.ErrorContains(t, err, "unknown model phi")
+}
+
+func TestSynthetic(t *testing.T) {
+	workload := Synthetic{
+		Rate:         10,
+		Duration:     time.Minute,
+		Models:       map[string]float64{"llama3": 3, "mixtral": 1},
+		PromptTokens: 200,
+		OutputTokens: 100,
+		Seed:         7,
+	}
+	trace, err := workload.Generate()
+	require.NoError(t, err)
+	assert.InDelta(t, 600, len(trace), 100)
+
+	counts := make(map[string]int)
+	for i, r := range trace {
+		counts[r.Model]++
+		assert.Less(t, r.At, time.Minute)
+		if i > 0 {
+			assert.GreaterOrEqual(t, r.At, trace[i-1].At)
+		}
+		assert.GreaterOrEqual(t, r.OutputTokens, 50)
+		assert.LessOrEqual(t, r.OutputTokens, 150)
+	}
+	assert.Greater(t, counts["llama3"], 2*counts["mixtral"])
+
+	again, err := workload.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, trace, again)
+
+	_, err = Synthetic{Rate: 1, Duration: time.Second}.Generate()
+	assert.Error(t, err)
+}
+
+func TestFromCaptures(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	trace := FromCaptures([]*capture.Record{
+		{Time: start.Add(2 * time.Second), Model: "llama3", Request: `{"prompt": "hi"}`,
+			Response: "{\"response\": \"a\"}\n{\"done\": true, \"prompt_eval_count\": 12, \"eval_count\": 40}\n"},
+		{Time: start, Model: "llama3", Request: `{"prompt": "a longer prompt without counts"}`, Response: `{"response": "abcdefgh"}`},
+		{Time: start.Add(time.Second), Path: "/api/tags"},
+	})
+	require.Len(t, trace, 2)
+	assert.Equal(t, Request{At: 0, Model: "llama3", PromptTokens: 11, OutputTokens: 2}, trace[0])
+	assert.Equal(t, Request{At: 2 * time.Second, Model: "llama3", PromptTokens: 12, OutputTokens: 40}, trace[1])
+}
+
+func TestRun(t *testing.T) {
+	steady := make([]Request, 0, 20)
+	for i := range 20 {
+		steady = append(steady, Request{At: time.Duration(i) * time.Second, Model: "llama3", PromptTokens: 100, OutputTokens: 50})
+	}
+
+	t.Run("light load", func(t *testing.T) {
+		sim, err := New(testCluster(2))
+		require.NoError(t, err)
+		report, err := sim.Run(context.Background(), steady)
+		require.NoError(t, err)
+
+		assert.Equal(t, 20, report.Completed)
+		assert.Zero(t, report.Rejected)
+		// One second of decoding, a fifth of prefill and the round trip
+		assert.InDelta(t, 1202*time.Millisecond, report.Latency.P50, float64(time.Millisecond))
+		assert.Zero(t, report.QueueWait.Max)
+		assert.Empty(t, report.Bottlenecks)
+		for _, n := range report.Nodes {
+			assert.Zero(t, n.ModelLoads, "llama3 is preloaded")
+		}
+	})
+
+	t.Run("overload", func(t *testing.T) {
+		burst := make([]Request, 0, 40)
+		for range 40 {
+			burst = append(burst, Request{Model: "llama3", PromptTokens: 100, OutputTokens: 500})
+		}
+		sim, err := New(testCluster(1))
+		require.NoError(t, err)
+		report, err := sim.Run(context.Background(), burst)
+		require.NoError(t, err)
+
+		assert.Equal(t, 40, report.Completed)
+		assert.Greater(t, report.QueueWait.P95, report.Latency.P95/2)
+		require.Len(t, report.Nodes, 1)
+		assert.Greater(t, report.Nodes[0].Utilization, 0.95)
+		assert.Equal(t, 38, report.Nodes[0].PeakQueue)
+		assert.Len(t, report.Bottlenecks, 2)
+	})
+
+	t.Run("rejections and model loads", func(t *testing.T) {
+		cluster := testCluster(1)
+		cluster.Models = append(cluster.Models, ModelSpec{Name: "qwen", SizeGB: 15})
+		sim, err := New(cluster)
+		require.NoError(t, err)
+		report, err := sim.Run(context.Background(), []Request{
+			{At: 0, Model: "mixtral", PromptTokens: 10, OutputTokens: 10},
+			{At: time.Second, Model: "qwen", PromptTokens: 10, OutputTokens: 10},
+			{At: time.Minute, Model: "llama3", PromptTokens: 10, OutputTokens: 10},
+			{At: 2 * time.Minute, Model: "qwen", PromptTokens: 10, OutputTokens: 10},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int{RejectModelTooLarge: 1}, report.Rejections)
+		assert.Equal(t, 3, report.Completed)
+		// qwen fits next to llama3, so it is loaded once
+		assert.Equal(t, 1, report.Nodes[0].ModelLoads)
+		assert.Zero(t, report.Nodes[0].Evictions)
+		require.Len(t, report.Models, 3)
+		assert.Equal(t, "llama3", report.Models[0].Name)
+		assert.Equal(t, 0, report.Models[1].Completed)
+
+		var out bytes.Buffer
+		require.NoError(t, report.WriteText(&out))
+		assert.Contains(t, out.String(), "1 of 4 requests could not be placed (model_too_large: 1)")
+	})
+
+	t.Run("placement", func(t *testing.T) {
+		cluster := testCluster(2)
+		cluster.Nodes = append(cluster.Nodes, NodeSpec{Name: "edge", Count: 1, MemoryGB: 8, Labels: map[string]string{"zone": "edge"}})
+		cluster.Placement.Affinity = []placement.AffinityRule{{Model: "llama3", Labels: map[string]string{"zone": "edge"}, Required: true}}
+		sim, err := New(cluster)
+		require.NoError(t, err)
+		report, err := sim.Run(context.Background(), steady)
+		require.NoError(t, err)
+
+		for _, n := range report.Nodes {
+			if n.ID == "edge-1" {
+				assert.Equal(t, 20, n.Requests)
+			} else {
+				assert.Zero(t, n.Requests)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		sim, err := New(testCluster(1))
+		require.NoError(t, err)
+		_, err = sim.Run(context.Background(), []Request{{Model: "phi"}})
+		assert.ErrorContains(t, err, "lacks the models phi")
+
+		cluster := testCluster(1)
+		cluster.Algorithm = "coin_flip"
+		_, err = New(cluster)
+		assert.ErrorContains(t, err, "unknown load balancing algorithm")
+	})
+}
@@ -0,0 +1,370 @@
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/loadbalancer"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/scheduler/partitioning"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/types"
+)
+
+// Rejection reasons besides the partitioning failure categories
+const (
+	RejectModelTooLarge = "model_too_large"
+)
+
+// node is a simulated node
+type node struct {
+	id     string
+	spec   NodeSpec
+	memory int64
+
+	// slots holds when each slot is free again
+	slots []time.Duration
+	// work holds the start and finish of the requests not finished yet
+	work [][2]time.Duration
+	// loaded maps the models in memory to when they were last used
+	loaded map[string]time.Duration
+
+	stats nodeStats
+}
+
+type nodeStats struct {
+	requests  int
+	busy      time.Duration
+	loads     int
+	evictions int
+	peakQueue int
+	waits     []time.Duration
+}
+
+// load returns the running and queued requests at t, forgetting the
+// finished ones
+func (n *node) load(t time.Duration) (running, queued int) {
+	n.work = slices.DeleteFunc(n.work, func(w [2]time.Duration) bool { return w[1] <= t })
+	for _, w := range n.work {
+		if w[0] <= t {
+			running++
+		} else {
+			queued++
+		}
+	}
+	return running, queued
+}
+
+// loadedBytes returns the memory taken by loaded models
+func (n *node) loadedBytes(c *Cluster) int64 {
+	var total int64
+	for name := range n.loaded {
+		if m, ok := c.model(name); ok {
+			total += int64(m.SizeGB * bytesPerGB)
+		}
+	}
+	return total
+}
+
+// ensureLoaded loads a model at start unless it is in memory, evicting the
+// least recently used models to make room, and returns the time loading
+// takes
+func (n *node) ensureLoaded(c *Cluster, m ModelSpec, start time.Duration) time.Duration {
+	defer func() { n.loaded[m.Name] = start }()
+	if _, ok := n.loaded[m.Name]; ok {
+		return 0
+	}
+
+	size := int64(m.SizeGB * bytesPerGB)
+	for n.loadedBytes(c)+size > n.memory && len(n.loaded) > 0 {
+		oldest := ""
+		for name, used := range n.loaded {
+			if oldest == "" || used < n.loaded[oldest] || (used == n.loaded[oldest] && name < oldest) {
+				oldest = name
+			}
+		}
+		delete(n.loaded, oldest)
+		n.stats.evictions++
+	}
+	n.stats.loads++
+	return time.Duration(m.SizeGB * bytesPerGB / bytesPerMB / n.spec.LoadMBPerSecond * float64(time.Second))
+}
+
+// Simulator replays traces against a hypothetical cluster
+type Simulator struct {
+	cluster    *Cluster
+	balancer   *loadbalancer.IntelligentLoadBalancer
+	partitions *partitioning.PartitionManager
+	strategy   string
+}
+
+// New creates a simulator of the cluster, with the scheduler's load
+// balancer, placement rules and partitioner
+func New(cluster *Cluster) (*Simulator, error) {
+	if err := cluster.Validate(); err != nil {
+		return nil, err
+	}
+
+	algorithm := cluster.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	balancer := loadbalancer.NewIntelligentLoadBalancer(&loadbalancer.Config{
+		Algorithm:     algorithm,
+		Deterministic: true,
+		Seed:          cluster.Seed,
+	})
+	if !slices.Contains(balancer.GetAvailableAlgorithms(), algorithm) {
+		return nil, fmt.Errorf("unknown load balancing algorithm %q", algorithm)
+	}
+
+	strategy := cluster.Strategy
+	if strategy == "" {
+		strategy = DefaultStrategy
+	}
+	policy, err := placement.NewPolicy(cluster.Placement)
+	if err != nil {
+		return nil, err
+	}
+	partitions := partitioning.NewPartitionManager(&partitioning.Config{DefaultStrategy: strategy})
+	known := false
+	for _, st := range []partitioning.PartitionStrategy{
+		partitioning.NewLayerwiseStrategy(),
+		partitioning.NewDataSplitStrategy(),
+		partitioning.NewTaskParallelismStrategy(),
+	} {
+		partitions.RegisterStrategy(st)
+		known = known || st.GetName() == strategy
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown partitioning strategy %q", strategy)
+	}
+	partitions.SetPlacementPolicy(policy)
+
+	return &Simulator{cluster: cluster, balancer: balancer, partitions: partitions, strategy: strategy}, nil
+}
+
+// Run replays a trace, ordered by arrival, and reports how the cluster
+// served it. Each request goes to the node the load balancer picks among
+// those with the memory for its model, then through placement and
+// partitioning, which may move it elsewhere or split it across nodes.
+func (s *Simulator) Run(ctx context.Context, trace []Request) (*Report, error) {
+	var unknown []string
+	for _, r := range trace {
+		if _, ok := s.cluster.model(r.Model); !ok && !slices.Contains(unknown, r.Model) {
+			unknown = append(unknown, r.Model)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("the cluster description lacks the models %s of the trace", strings.Join(unknown, ", "))
+	}
+
+	nodes := s.nodes()
+	results := make([]result, 0, len(trace))
+	for i, r := range trace {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results = append(results, s.serve(ctx, i, r, nodes))
+	}
+	return newReport(s.cluster, trace, results, nodes), nil
+}
+
+// nodes creates the simulated nodes, with their preloaded models
+func (s *Simulator) nodes() []*node {
+	var nodes []*node
+	for _, spec := range s.cluster.Nodes {
+		spec = spec.withDefaults()
+		for i := 1; i <= spec.Count; i++ {
+			n := &node{
+				id:     fmt.Sprintf("%s-%d", spec.Name, i),
+				spec:   spec,
+				memory: int64(spec.MemoryGB * bytesPerGB),
+				slots:  make([]time.Duration, spec.Slots),
+				loaded: make(map[string]time.Duration),
+			}
+			for _, name := range spec.Models {
+				m, _ := s.cluster.model(name)
+				if n.loadedBytes(s.cluster)+int64(m.SizeGB*bytesPerGB) <= n.memory {
+					n.loaded[name] = 0
+				}
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// result is how one request was served
+type result struct {
+	model    string
+	rejected string
+	latency  time.Duration
+	wait     time.Duration
+	nodes    []string
+}
+
+// serve places a request arriving at r.At and simulates running it
+func (s *Simulator) serve(ctx context.Context, i int, r Request, nodes []*node) result {
+	m, _ := s.cluster.model(r.Model)
+	size := int64(m.SizeGB * bytesPerGB)
+	res := result{model: r.Model}
+
+	type candidate struct {
+		node *node
+		load float64
+	}
+	var candidates []candidate
+	var views []*loadbalancer.NodeInfo
+	byID := make(map[string]*node)
+	for _, n := range nodes {
+		if n.memory < size {
+			continue
+		}
+		running, queued := n.load(r.At)
+		load := float64(running+queued) / float64(len(n.slots))
+		candidates = append(candidates, candidate{node: n, load: load})
+		byID[n.id] = n
+		views = append(views, s.balancerView(n, running, queued))
+	}
+	if len(candidates) == 0 {
+		res.rejected = RejectModelTooLarge
+		return res
+	}
+
+	// The load balancer's choice first, then the least loaded nodes
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].load != candidates[b].load {
+			return candidates[a].load < candidates[b].load
+		}
+		return candidates[a].node.id < candidates[b].node.id
+	})
+	var chosen string
+	if selected, err := s.balancer.SelectNodes(r, views); err == nil && len(selected) > 0 {
+		chosen = selected[0].ID
+	}
+	task := &partitioning.PartitionTask{
+		ID:        fmt.Sprintf("sim-%d", i),
+		Type:      "inference",
+		Model:     &types.OllamaModel{Name: r.Model, Size: size},
+		Options:   map[string]interface{}{},
+		Priority:  1,
+		CreatedAt: time.Now(),
+	}
+	if chosen != "" {
+		task.Nodes = append(task.Nodes, s.partitionView(byID[chosen]))
+	}
+	for _, c := range candidates {
+		if c.node.id != chosen {
+			task.Nodes = append(task.Nodes, s.partitionView(c.node))
+		}
+	}
+
+	plan, err := s.partitions.DryRun(ctx, task, s.strategy)
+	if err != nil {
+		res.rejected = partitioning.FailureCategory(err)
+		return res
+	}
+
+	// Each node of the plan runs an equal share of the request
+	var planNodes []*node
+	for _, p := range plan.Partitions {
+		if n, ok := byID[p.NodeID]; ok && !slices.Contains(planNodes, n) {
+			planNodes = append(planNodes, n)
+		}
+	}
+	if len(planNodes) == 0 {
+		res.rejected = partitioning.FailureNoNodes
+		return res
+	}
+	share := 1 / float64(len(planNodes))
+
+	var finish time.Duration
+	for _, n := range planNodes {
+		hop := time.Duration(n.spec.LatencyMS * float64(time.Millisecond) / 2)
+		arrival := r.At + hop
+
+		slot := 0
+		for j := range n.slots {
+			if n.slots[j] < n.slots[slot] {
+				slot = j
+			}
+		}
+		start := max(arrival, n.slots[slot])
+		loading := n.ensureLoaded(s.cluster, m, start)
+		prompt := float64(r.PromptTokens) * share / (n.spec.PromptTokensPerSecond * m.Speed)
+		output := float64(r.OutputTokens) * share / (n.spec.TokensPerSecond * m.Speed)
+		done := start + loading + time.Duration((prompt+output)*float64(time.Second))
+
+		n.slots[slot] = done
+		n.work = append(n.work, [2]time.Duration{start, done})
+		_, queued := n.load(arrival)
+		n.stats.peakQueue = max(n.stats.peakQueue, queued)
+		n.stats.requests++
+		n.stats.busy += done - start
+		n.stats.waits = append(n.stats.waits, start-arrival)
+
+		res.nodes = append(res.nodes, n.id)
+		res.wait = max(res.wait, start-arrival)
+		finish = max(finish, done+hop)
+	}
+	res.latency = finish - r.At
+	return res
+}
+
+// balancerView describes a node to the load balancer
+func (s *Simulator) balancerView(n *node, running, queued int) *loadbalancer.NodeInfo {
+	busy := min(float64(running)/float64(len(n.slots)), 1)
+	view := &loadbalancer.NodeInfo{
+		ID: n.id,
+		Capacity: &loadbalancer.ResourceCapacity{
+			CPUCores:       n.spec.CPUCores,
+			MemoryBytes:    n.memory,
+			GPUCount:       n.spec.GPUs,
+			GPUMemoryBytes: n.memory,
+			ComputeScore:   n.spec.TokensPerSecond,
+		},
+		Usage: &loadbalancer.ResourceUsage{
+			CPUUtilization:    busy,
+			MemoryUtilization: float64(n.loadedBytes(s.cluster)) / float64(n.memory),
+			ActiveRequests:    running,
+			QueuedRequests:    queued,
+		},
+		Latency:          time.Duration(n.spec.LatencyMS * float64(time.Millisecond)),
+		HealthScore:      1,
+		LoadScore:        busy,
+		PerformanceScore: n.spec.TokensPerSecond,
+		Metadata:         map[string]interface{}{},
+	}
+	if n.spec.GPUs > 0 {
+		view.Usage.GPUUtilization = busy
+	}
+	return view
+}
+
+// partitionView describes a node to the partitioner
+func (s *Simulator) partitionView(n *node) *partitioning.NodeInfo {
+	models := make([]string, 0, len(n.loaded))
+	for name := range n.loaded {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+	return &partitioning.NodeInfo{
+		ID: n.id,
+		Capacity: &partitioning.ResourceCapacity{
+			CPUCores:    n.spec.CPUCores,
+			MemoryBytes: n.memory,
+			GPUCount:    n.spec.GPUs,
+		},
+		Usage:    &partitioning.ResourceUsage{ActiveTasks: len(n.work)},
+		Latency:  time.Duration(n.spec.LatencyMS * float64(time.Millisecond)),
+		Labels:   n.spec.Labels,
+		Taints:   n.spec.Taints,
+		Models:   models,
+		Metadata: map[string]interface{}{},
+	}
+}
@@ -0,0 +1,141 @@
+package simulation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/capture"
+)
+
+// charsPerToken estimates token counts from text lengths when a capture
+// lacks Ollama's counts
+const charsPerToken = 4
+
+// Request is a request of a workload trace
+type Request struct {
+	// At is when the request arrives, from the start of the trace
+	At           time.Duration `json:"at"`
+	Model        string        `json:"model"`
+	PromptTokens int           `json:"prompt_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+}
+
+// FromCaptures turns captured inference requests into a trace, keeping
+// their arrival times and token counts. Captures without a model are
+// skipped.
+func FromCaptures(records []*capture.Record) []Request {
+	var trace []Request
+	var start time.Time
+	for _, rec := range records {
+		if rec.Model == "" {
+			continue
+		}
+		if start.IsZero() || rec.Time.Before(start) {
+			start = rec.Time
+		}
+	}
+	for _, rec := range records {
+		if rec.Model == "" {
+			continue
+		}
+		prompt, output := tokenCounts(rec)
+		trace = append(trace, Request{At: rec.Time.Sub(start), Model: rec.Model, PromptTokens: prompt, OutputTokens: output})
+	}
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].At < trace[j].At })
+	return trace
+}
+
+// tokenCounts reads the prompt and output token counts Ollama reports in
+// the last line of a response, or estimates them from the text lengths
+func tokenCounts(rec *capture.Record) (int, int) {
+	var counts struct {
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+		Response        string `json:"response"`
+		Message         struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	var generated int
+	scanner := bufio.NewScanner(strings.NewReader(rec.Response))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		counts.Response, counts.Message.Content = "", ""
+		if json.Unmarshal(scanner.Bytes(), &counts) != nil {
+			continue
+		}
+		generated += len(counts.Response) + len(counts.Message.Content)
+	}
+
+	prompt, output := counts.PromptEvalCount, counts.EvalCount
+	if prompt == 0 {
+		prompt = max(len(rec.Request)/charsPerToken, 1)
+	}
+	if output == 0 {
+		output = max(generated/charsPerToken, 1)
+	}
+	return prompt, output
+}
+
+// Synthetic describes a synthetic workload: requests arriving at random at
+// an average Rate per second for Duration, for models picked by weight,
+// with token counts varying up to half around the given means
+type Synthetic struct {
+	Rate         float64            `json:"rate"`
+	Duration     time.Duration      `json:"duration"`
+	Models       map[string]float64 `json:"models"`
+	PromptTokens int                `json:"prompt_tokens"`
+	OutputTokens int                `json:"output_tokens"`
+	Seed         int64              `json:"seed"`
+}
+
+// Generate returns the trace of the synthetic workload
+func (s Synthetic) Generate() ([]Request, error) {
+	if s.Rate <= 0 || s.Duration <= 0 {
+		return nil, fmt.Errorf("synthetic workload needs a positive rate and duration")
+	}
+	if len(s.Models) == 0 {
+		return nil, fmt.Errorf("synthetic workload needs models")
+	}
+	if s.PromptTokens <= 0 || s.OutputTokens <= 0 {
+		return nil, fmt.Errorf("synthetic workload needs positive token counts")
+	}
+
+	names := make([]string, 0, len(s.Models))
+	total := 0.0
+	for name, weight := range s.Models {
+		if weight <= 0 {
+			return nil, fmt.Errorf("model %s has no positive weight", name)
+		}
+		names = append(names, name)
+		total += weight
+	}
+	sort.Strings(names)
+
+	rng := rand.New(rand.NewSource(s.Seed))
+	vary := func(mean int) int {
+		return max(int(float64(mean)*(0.5+rng.Float64())), 1)
+	}
+	var trace []Request
+	for at := time.Duration(0); ; {
+		// Poisson arrivals
+		at += time.Duration(rng.ExpFloat64() / s.Rate * float64(time.Second))
+		if at >= s.Duration {
+			return trace, nil
+		}
+		pick := rng.Float64() * total
+		model := names[len(names)-1]
+		for _, name := range names {
+			if pick -= s.Models[name]; pick < 0 {
+				model = name
+				break
+			}
+		}
+		trace = append(trace, Request{At: at, Model: model, PromptTokens: vary(s.PromptTokens), OutputTokens: vary(s.OutputTokens)})
+	}
+}
@@ -0,0 +1,81 @@
+package speculative
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OllamaModel is a model served by an Ollama endpoint. Responses are
+// streamed, so each chunk is one token.
+type OllamaModel struct {
+	Client   *http.Client
+	Endpoint string
+	Name     string
+}
+
+// Generate streams up to n tokens of greedy, raw continuation of prompt
+func (m OllamaModel) Generate(ctx context.Context, prompt string, n int) ([]string, bool, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":  m.Name,
+		"prompt": prompt,
+		"raw":    true,
+		"stream": true,
+		"options": map[string]interface{}{
+			"temperature": 0,
+			"num_predict": n,
+		},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Endpoint+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, false, fmt.Errorf("%s on %s failed with %s: %s", m.Name, m.Endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var tokens []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var chunk struct {
+			Response   string `json:"response"`
+			Done       bool   `json:"done"`
+			DoneReason string `json:"done_reason"`
+			Error      string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return nil, false, fmt.Errorf("failed to decode %s response: %w", m.Name, err)
+		}
+		if chunk.Error != "" {
+			return nil, false, fmt.Errorf("%s on %s failed: %s", m.Name, m.Endpoint, chunk.Error)
+		}
+		if chunk.Response != "" {
+			tokens = append(tokens, chunk.Response)
+		}
+		if chunk.Done {
+			// Generation stopping at num_predict does not end the text
+			ended := chunk.DoneReason == "stop" || (chunk.DoneReason == "" && len(tokens) < n)
+			return tokens, ended, nil
+		}
+	}
+	return tokens, false, scanner.Err()
+}
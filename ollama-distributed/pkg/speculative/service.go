@@ -0,0 +1,287 @@
+package speculative
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result is a generation, speculative or by the target model alone
+type Result struct {
+	Model string `json:"model"`
+	Draft string `json:"draft,omitempty"`
+	Text  string `json:"text"`
+	// Tokens is how many tokens were generated
+	Tokens int  `json:"tokens"`
+	Done   bool `json:"done"`
+	// Speculative reports whether the draft model was used
+	Speculative bool `json:"speculative"`
+	// Rounds, Proposed and Accepted count the verification rounds and the
+	// drafted tokens proposed and accepted
+	Rounds         int           `json:"rounds,omitempty"`
+	Proposed       int           `json:"proposed,omitempty"`
+	Accepted       int           `json:"accepted,omitempty"`
+	TargetEndpoint string        `json:"target_endpoint"`
+	DraftEndpoint  string        `json:"draft_endpoint,omitempty"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// Stats describes how speculation does for a pair
+type Stats struct {
+	Target string `json:"target"`
+	Draft  string `json:"draft"`
+	// Generations, Proposed and Accepted are totals since start
+	Generations int64 `json:"generations"`
+	Proposed    int64 `json:"proposed"`
+	Accepted    int64 `json:"accepted"`
+	// AcceptanceRate is the share of proposed tokens accepted over the
+	// current window
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	// SpeculativeTokenLatency and BaselineTokenLatency are the mean time
+	// per token with and without speculation over the current window
+	SpeculativeTokenLatency time.Duration `json:"speculative_token_latency"`
+	BaselineTokenLatency    time.Duration `json:"baseline_token_latency"`
+	Disabled                bool          `json:"disabled"`
+	DisabledReason          string        `json:"disabled_reason,omitempty"`
+	DisabledUntil           time.Time     `json:"disabled_until,omitempty"`
+}
+
+// window accumulates the generations a pair is judged over
+type window struct {
+	generations int
+	proposed    int
+	accepted    int
+	specTokens  int
+	specTime    time.Duration
+	baseTokens  int
+	baseTime    time.Duration
+}
+
+func (w window) acceptance() float64 {
+	if w.proposed == 0 {
+		return 0
+	}
+	return float64(w.accepted) / float64(w.proposed)
+}
+
+func perToken(d time.Duration, tokens int) time.Duration {
+	if tokens == 0 {
+		return 0
+	}
+	return d / time.Duration(tokens)
+}
+
+// pairState is the state of a pair, guarded by the service mutex
+type pairState struct {
+	stats   Stats
+	current window
+}
+
+// Service runs speculative generations on the nodes serving the models of
+// each pair
+type Service struct {
+	cfg       Config
+	endpoints func(model string) []string
+	client    *http.Client
+
+	// newModel opens a model on an endpoint; Ollama models by default
+	newModel func(endpoint, model string) Model
+
+	mu    sync.Mutex
+	pairs map[string]*pairState // by target model
+	now   func() time.Time
+}
+
+// NewService creates a service for the configured pairs. Endpoints returns
+// the Ollama endpoints serving a model, best first.
+func NewService(cfg Config, endpoints func(model string) []string) *Service {
+	s := &Service{
+		cfg:       cfg.withDefaults(),
+		endpoints: endpoints,
+		client:    &http.Client{},
+		pairs:     make(map[string]*pairState, len(cfg.Pairs)),
+		now:       time.Now,
+	}
+	s.newModel = func(endpoint, model string) Model {
+		return OllamaModel{Client: s.client, Endpoint: endpoint, Name: model}
+	}
+	for _, pair := range cfg.Pairs {
+		s.pairs[pair.Target] = &pairState{stats: Stats{Target: pair.Target, Draft: pair.Draft}}
+	}
+	return s
+}
+
+// Stats returns the stats of every pair, by target model
+func (s *Service) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]Stats, 0, len(s.pairs))
+	for _, state := range s.pairs {
+		stats = append(stats, state.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Target < stats[j].Target })
+	return stats
+}
+
+// Generate generates up to maxTokens tokens continuing prompt with a target
+// model, speculating with its draft model unless speculation is off for the
+// pair or the draft model is not served
+func (s *Service) Generate(ctx context.Context, model, prompt string, maxTokens int) (Result, error) {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	s.mu.Lock()
+	state, ok := s.pairs[model]
+	if !ok {
+		s.mu.Unlock()
+		return Result{}, fmt.Errorf("%w %s", ErrNoPair, model)
+	}
+	draftModel := state.stats.Draft
+	if state.stats.Disabled && !s.now().Before(state.stats.DisabledUntil) {
+		state.stats.Disabled = false
+		state.stats.DisabledReason = ""
+		state.stats.DisabledUntil = time.Time{}
+		state.current = window{}
+		slog.Info("Re-enabled speculative decoding", "model", model, "draft", draftModel)
+	}
+	// Every Window-th generation measures the target alone
+	speculate := !state.stats.Disabled && state.current.generations%s.cfg.Window != s.cfg.Window-1
+	s.mu.Unlock()
+
+	targets := s.endpoints(model)
+	if len(targets) == 0 {
+		return Result{}, fmt.Errorf("no node serves model %s", model)
+	}
+	result := Result{Model: model, TargetEndpoint: targets[0]}
+	if speculate {
+		result.DraftEndpoint = draftEndpoint(s.endpoints(draftModel), result.TargetEndpoint)
+		speculate = result.DraftEndpoint != ""
+	}
+
+	start := s.now()
+	target := s.newModel(result.TargetEndpoint, model)
+	var tokens []string
+	var err error
+	if speculate {
+		result.Draft = draftModel
+		result.Speculative = true
+		tokens, err = s.speculate(ctx, target, s.newModel(result.DraftEndpoint, draftModel), prompt, maxTokens, &result)
+	} else {
+		tokens, result.Done, err = target.Generate(ctx, prompt, maxTokens)
+	}
+	result.Duration = s.now().Sub(start)
+	result.Text = strings.Join(tokens, "")
+	result.Tokens = len(tokens)
+	if err != nil {
+		return result, err
+	}
+
+	s.record(state, result)
+	return result, nil
+}
+
+// draftEndpoint returns an endpoint serving the draft model, preferring one
+// other than the target's so the two do not compete for the same GPU
+func draftEndpoint(endpoints []string, target string) string {
+	for _, endpoint := range endpoints {
+		if endpoint != target {
+			return endpoint
+		}
+	}
+	if len(endpoints) > 0 {
+		return endpoints[0]
+	}
+	return ""
+}
+
+// speculate runs rounds of drafting and verification until maxTokens
+// tokens are generated or the target model ends the text
+func (s *Service) speculate(ctx context.Context, target, draft Model, prompt string, maxTokens int, result *Result) ([]string, error) {
+	var tokens []string
+	text := prompt
+	for len(tokens) < maxTokens {
+		n := s.cfg.DraftTokens
+		if rest := maxTokens - len(tokens) - 1; rest < n {
+			n = rest
+		}
+
+		var proposed []string
+		if n > 0 {
+			var err error
+			if proposed, _, err = draft.Generate(ctx, text, n); err != nil {
+				return tokens, fmt.Errorf("draft model failed: %w", err)
+			}
+		}
+		accepted, next, done, err := verify(ctx, target, text, proposed)
+		if err != nil {
+			return tokens, fmt.Errorf("target model failed: %w", err)
+		}
+
+		result.Rounds++
+		result.Proposed += len(proposed)
+		result.Accepted += accepted
+		round := append(proposed[:accepted:accepted], next)
+		if next == "" {
+			round = round[:accepted]
+		}
+		tokens = append(tokens, round...)
+		text += strings.Join(round, "")
+
+		if done || len(round) == 0 {
+			result.Done = done
+			break
+		}
+	}
+	return tokens, nil
+}
+
+// record adds a generation to the window of its pair, and judges the pair
+// once the window is full
+func (s *Service) record(state *pairState, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := &state.current
+	w.generations++
+	state.stats.Generations++
+	if result.Speculative {
+		w.proposed += result.Proposed
+		w.accepted += result.Accepted
+		w.specTokens += result.Tokens
+		w.specTime += result.Duration
+		state.stats.Proposed += int64(result.Proposed)
+		state.stats.Accepted += int64(result.Accepted)
+	} else {
+		w.baseTokens += result.Tokens
+		w.baseTime += result.Duration
+	}
+	state.stats.AcceptanceRate = w.acceptance()
+	state.stats.SpeculativeTokenLatency = perToken(w.specTime, w.specTokens)
+	state.stats.BaselineTokenLatency = perToken(w.baseTime, w.baseTokens)
+
+	if state.stats.Disabled || w.generations < s.cfg.Window {
+		return
+	}
+	reason := ""
+	switch {
+	case w.proposed > 0 && w.acceptance() < s.cfg.MinAcceptance:
+		reason = fmt.Sprintf("acceptance rate %.2f below %.2f", w.acceptance(), s.cfg.MinAcceptance)
+	case w.specTokens > 0 && w.baseTokens > 0 && state.stats.SpeculativeTokenLatency > state.stats.BaselineTokenLatency:
+		reason = fmt.Sprintf("%s per token speculating, %s without", state.stats.SpeculativeTokenLatency, state.stats.BaselineTokenLatency)
+	}
+	if reason != "" {
+		state.stats.Disabled = true
+		state.stats.DisabledReason = reason
+		state.stats.DisabledUntil = s.now().Add(s.cfg.RetryAfter)
+		slog.Warn("Disabled speculative decoding", "model", state.stats.Target, "draft", state.stats.Draft,
+			"reason", reason, "until", state.stats.DisabledUntil)
+	}
+	*w = window{}
+}
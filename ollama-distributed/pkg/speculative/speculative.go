@@ -0,0 +1,131 @@
+// Package speculative implements speculative decoding across nodes. A
+// small draft model proposes a few tokens, the large target model verifies
+// them, and the tokens it agrees with are kept along with its own next
+// token, so each round of the target yields up to DraftTokens+1 tokens.
+//
+// The acceptance rate and the latency per token of every pair are tracked,
+// and speculation is turned off for a while for pairs it does not pay off
+// for: those whose drafts are mostly rejected, or which generate slower
+// than the target model alone.
+package speculative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults of speculative decoding
+const (
+	DefaultDraftTokens   = 4
+	DefaultMinAcceptance = 0.3
+	DefaultWindow        = 20
+	DefaultRetryAfter    = 5 * time.Minute
+	DefaultMaxTokens     = 256
+)
+
+// ErrNoPair is returned for target models without a draft model
+var ErrNoPair = errors.New("no draft model configured for model")
+
+// Pair is a target model and the draft model speculating for it. The draft
+// must share the target's tokenizer for its tokens to be accepted.
+type Pair struct {
+	Target string `yaml:"target"`
+	Draft  string `yaml:"draft"`
+}
+
+// Config configures speculative decoding
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Pairs   []Pair `yaml:"pairs"`
+	// DraftTokens is how many tokens the draft model proposes per round
+	DraftTokens int `yaml:"draft_tokens" mapstructure:"draft_tokens"`
+	// MinAcceptance is the share of proposed tokens below which
+	// speculation is turned off for a pair
+	MinAcceptance float64 `yaml:"min_acceptance" mapstructure:"min_acceptance"`
+	// Window is how many generations a pair is judged over; every
+	// Window-th generation runs on the target alone to measure the
+	// baseline latency
+	Window int `yaml:"window"`
+	// RetryAfter is how long speculation stays off for a pair
+	RetryAfter time.Duration `yaml:"retry_after" mapstructure:"retry_after"`
+}
+
+// Validate checks the pairs and settings
+func (c Config) Validate() error {
+	targets := make(map[string]bool, len(c.Pairs))
+	for _, pair := range c.Pairs {
+		if pair.Target == "" || pair.Draft == "" {
+			return fmt.Errorf("speculative pairs need a target and a draft model")
+		}
+		if pair.Target == pair.Draft {
+			return fmt.Errorf("model %s cannot be its own draft model", pair.Target)
+		}
+		if targets[pair.Target] {
+			return fmt.Errorf("model %s has several draft models", pair.Target)
+		}
+		targets[pair.Target] = true
+	}
+	if c.DraftTokens < 0 || c.Window < 0 || c.RetryAfter < 0 {
+		return fmt.Errorf("speculative settings must not be negative")
+	}
+	if c.MinAcceptance < 0 || c.MinAcceptance > 1 {
+		return fmt.Errorf("min_acceptance must be between 0 and 1")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.DraftTokens == 0 {
+		c.DraftTokens = DefaultDraftTokens
+	}
+	if c.MinAcceptance == 0 {
+		c.MinAcceptance = DefaultMinAcceptance
+	}
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	if c.RetryAfter == 0 {
+		c.RetryAfter = DefaultRetryAfter
+	}
+	return c
+}
+
+// Model generates greedy continuations of raw prompts
+type Model interface {
+	// Generate returns up to n tokens continuing prompt, and whether the
+	// model ended the text
+	Generate(ctx context.Context, prompt string, n int) ([]string, bool, error)
+}
+
+// Verifier is implemented by target models that verify drafts natively,
+// in a single forward pass over the drafted tokens
+type Verifier interface {
+	// Verify returns how many of the drafted tokens the model agrees with,
+	// its own token following them, and whether it ended the text
+	Verify(ctx context.Context, prompt string, draft []string) (int, string, bool, error)
+}
+
+// verify checks a draft against the target model. Targets that are not
+// Verifiers generate the draft's length plus one tokens greedily and the
+// draft is compared with them.
+func verify(ctx context.Context, target Model, prompt string, draft []string) (int, string, bool, error) {
+	if v, ok := target.(Verifier); ok {
+		return v.Verify(ctx, prompt, draft)
+	}
+
+	tokens, done, err := target.Generate(ctx, prompt, len(draft)+1)
+	if err != nil {
+		return 0, "", false, err
+	}
+	accepted := 0
+	for accepted < len(draft) && accepted < len(tokens) && draft[accepted] == tokens[accepted] {
+		accepted++
+	}
+	if accepted == len(tokens) {
+		return accepted, "", done, nil
+	}
+	return accepted, tokens[accepted], done && accepted+1 == len(tokens), nil
+}
@@ -0,0 +1,165 @@
+package speculative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const prompt = ">"
+
+// fakeModel continues the prompt with its text, one character per token,
+// advancing a fake clock by cost per call
+type fakeModel struct {
+	text  string
+	clock *time.Time
+	cost  time.Duration
+	calls int
+}
+
+func (m *fakeModel) Generate(ctx context.Context, p string, n int) ([]string, bool, error) {
+	m.calls++
+	*m.clock = m.clock.Add(m.cost)
+	pos := len(p) - len(prompt)
+	var tokens []string
+	for i := pos; i < len(m.text) && len(tokens) < n; i++ {
+		tokens = append(tokens, m.text[i:i+1])
+	}
+	return tokens, pos+len(tokens) >= len(m.text), nil
+}
+
+func newTestService(cfg Config, target, draft *fakeModel, clock *time.Time) *Service {
+	s := NewService(cfg, func(model string) []string { return []string{"http://" + model} })
+	s.now = func() time.Time { return *clock }
+	s.newModel = func(endpoint, model string) Model {
+		if model == "big" {
+			return target
+		}
+		return draft
+	}
+	return s
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Pairs: []Pair{{Target: "big", Draft: "small"}}}.Validate())
+	assert.Error(t, Config{Pairs: []Pair{{Target: "big"}}}.Validate())
+	assert.Error(t, Config{Pairs: []Pair{{Target: "big", Draft: "big"}}}.Validate())
+	assert.Error(t, Config{Pairs: []Pair{{Target: "big", Draft: "a"}, {Target: "big", Draft: "b"}}}.Validate())
+	assert.Error(t, Config{MinAcceptance: 2}.Validate())
+}
+
+func TestSpeculativeGenerationMatchesTarget(t *testing.T) {
+	clock := time.Unix(0, 0)
+	target := &fakeModel{text: "the quick brown fox", clock: &clock}
+	draft := &fakeModel{text: "the quack brown fix", clock: &clock}
+	s := newTestService(Config{Enabled: true, Pairs: []Pair{{Target: "big", Draft: "small"}}}, target, draft, &clock)
+
+	result, err := s.Generate(context.Background(), "big", prompt, 100)
+	require.NoError(t, err)
+	assert.True(t, result.Speculative)
+	assert.Equal(t, "the quick brown fox", result.Text)
+	assert.True(t, result.Done)
+	assert.Equal(t, "small", result.Draft)
+	assert.Less(t, result.Rounds, len(result.Text), "rounds yield several tokens")
+	assert.Less(t, result.Accepted, result.Proposed)
+	assert.Equal(t, result.Rounds, target.calls)
+
+	stats := s.Stats()
+	require.Len(t, stats, 1)
+	assert.InDelta(t, float64(result.Accepted)/float64(result.Proposed), stats[0].AcceptanceRate, 1e-9)
+
+	_, err = s.Generate(context.Background(), "small", prompt, 10)
+	assert.ErrorIs(t, err, ErrNoPair)
+}
+
+func TestMaxTokens(t *testing.T) {
+	clock := time.Unix(0, 0)
+	target := &fakeModel{text: "abcdefghij", clock: &clock}
+	s := newTestService(Config{Pairs: []Pair{{Target: "big", Draft: "small"}}}, target, &fakeModel{text: "abcdefghij", clock: &clock}, &clock)
+
+	result, err := s.Generate(context.Background(), "big", prompt, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefg", result.Text)
+	assert.False(t, result.Done)
+	assert.Equal(t, 2, result.Rounds, "4 drafted tokens and the target's, then 1 and the target's")
+}
+
+func TestDisabledOnLowAcceptance(t *testing.T) {
+	clock := time.Unix(0, 0)
+	target := &fakeModel{text: "abcdefgh", clock: &clock}
+	draft := &fakeModel{text: "zzzzzzzz", clock: &clock}
+	s := newTestService(Config{Pairs: []Pair{{Target: "big", Draft: "small"}}, Window: 3, RetryAfter: time.Minute}, target, draft, &clock)
+
+	for i := 0; i < 3; i++ {
+		result, err := s.Generate(context.Background(), "big", prompt, 8)
+		require.NoError(t, err)
+		assert.Equal(t, "abcdefgh", result.Text)
+	}
+	stats := s.Stats()[0]
+	assert.True(t, stats.Disabled)
+	assert.Contains(t, stats.DisabledReason, "acceptance rate")
+
+	result, err := s.Generate(context.Background(), "big", prompt, 8)
+	require.NoError(t, err)
+	assert.False(t, result.Speculative)
+
+	// Speculation is retried once the pair has been off for RetryAfter
+	clock = clock.Add(time.Minute)
+	result, err = s.Generate(context.Background(), "big", prompt, 8)
+	require.NoError(t, err)
+	assert.True(t, result.Speculative)
+	assert.False(t, s.Stats()[0].Disabled)
+}
+
+func TestDisabledWhenSlowerThanBaseline(t *testing.T) {
+	clock := time.Unix(0, 0)
+	target := &fakeModel{text: "abcdefgh", clock: &clock, cost: 10 * time.Millisecond}
+	draft := &fakeModel{text: "abcdefgh", clock: &clock, cost: 100 * time.Millisecond}
+	s := newTestService(Config{Pairs: []Pair{{Target: "big", Draft: "small"}}, Window: 2}, target, draft, &clock)
+
+	// The second generation of each window measures the baseline
+	first, err := s.Generate(context.Background(), "big", prompt, 8)
+	require.NoError(t, err)
+	assert.True(t, first.Speculative)
+	second, err := s.Generate(context.Background(), "big", prompt, 8)
+	require.NoError(t, err)
+	assert.False(t, second.Speculative)
+
+	stats := s.Stats()[0]
+	assert.True(t, stats.Disabled)
+	assert.Contains(t, stats.DisabledReason, "per token")
+	assert.Equal(t, 1.0, stats.AcceptanceRate)
+}
+
+func TestOllamaModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Raw     bool               `json:"raw"`
+			Stream  bool               `json:"stream"`
+			Options map[string]float64 `json:"options"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Raw)
+		assert.True(t, req.Stream)
+		assert.Zero(t, req.Options["temperature"])
+		n := int(req.Options["num_predict"])
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, `{"response": "t%d", "done": false}`+"\n", i)
+		}
+		fmt.Fprintln(w, `{"response": "", "done": true, "done_reason": "length"}`)
+	}))
+	defer server.Close()
+
+	tokens, done, err := OllamaModel{Endpoint: server.URL, Name: "big"}.Generate(context.Background(), "hi", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "t0t1t2", strings.Join(tokens, ""))
+	assert.False(t, done)
+}
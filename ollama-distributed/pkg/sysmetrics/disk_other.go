@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+
+package sysmetrics
+
+import "errors"
+
+func diskUsage(path string) (used, total int64, err error) {
+	return 0, 0, errors.New("disk usage not supported on this platform")
+}
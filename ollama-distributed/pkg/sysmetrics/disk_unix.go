@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package sysmetrics
+
+import "syscall"
+
+// diskUsage reports the filesystem holding path. Blocks reserved for root
+// count as used, matching df.
+func diskUsage(path string) (used, total int64, err error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, 0, err
+	}
+	bsize := uint64(fs.Bsize)
+	total = int64(uint64(fs.Blocks) * bsize)
+	used = total - int64(uint64(fs.Bavail)*bsize)
+	return used, total, nil
+}
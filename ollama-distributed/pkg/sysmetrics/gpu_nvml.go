@@ -0,0 +1,60 @@
+//go:build nvml
+
+package sysmetrics
+
+// Building with -tags nvml links github.com/NVIDIA/go-nvml, which loads
+// libnvidia-ml.so at runtime.
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+var (
+	nvmlOnce sync.Once
+	nvmlErr  error
+)
+
+// collectGPUs reads utilization, memory and temperature from NVML. Hosts
+// where the library cannot be loaded report no GPUs.
+func collectGPUs() ([]GPU, error) {
+	nvmlOnce.Do(func() {
+		if ret := nvml.Init(); ret != nvml.SUCCESS {
+			nvmlErr = fmt.Errorf("nvml init: %s", nvml.ErrorString(ret))
+		}
+	})
+	if nvmlErr != nil {
+		return nil, nil
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("nvml device count: %s", nvml.ErrorString(ret))
+	}
+
+	gpus := make([]GPU, 0, count)
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("nvml device %d: %s", i, nvml.ErrorString(ret))
+		}
+
+		gpu := GPU{Index: i}
+		gpu.UUID, _ = device.GetUUID()
+		gpu.Name, _ = device.GetName()
+		if util, ret := device.GetUtilizationRates(); ret == nvml.SUCCESS {
+			gpu.Utilization = float64(util.Gpu)
+		}
+		if mem, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+			gpu.MemoryUsed = int64(mem.Used)
+			gpu.MemoryTotal = int64(mem.Total)
+		}
+		if temp, ret := device.GetTemperature(nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+			gpu.Temperature = float64(temp)
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
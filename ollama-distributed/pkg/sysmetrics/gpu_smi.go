@@ -0,0 +1,61 @@
+//go:build !nvml
+
+package sysmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// nvidiaSMIQuery lists the fields requested from nvidia-smi, in the order
+// parseNvidiaSMI expects them
+const nvidiaSMIQuery = "index,uuid,name,utilization.gpu,memory.used,memory.total,temperature.gpu"
+
+// collectGPUs queries nvidia-smi. Building with -tags nvml reads NVML
+// directly instead, which avoids forking a process per sample. Hosts
+// without NVIDIA drivers report no GPUs.
+func collectGPUs() ([]GPU, error) {
+	path, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return nil, nil
+	}
+	output, err := exec.Command(path, "--query-gpu="+nvidiaSMIQuery, "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return parseNvidiaSMI(strings.NewReader(string(output)))
+}
+
+func parseNvidiaSMI(r io.Reader) ([]GPU, error) {
+	var gpus []GPU
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		gpu := GPU{UUID: fields[1], Name: fields[2]}
+		gpu.Index, _ = strconv.Atoi(fields[0])
+		// Unsupported fields are reported as "[N/A]" and left at zero
+		gpu.Utilization, _ = strconv.ParseFloat(fields[3], 64)
+		usedMiB, _ := strconv.ParseInt(fields[4], 10, 64)
+		totalMiB, _ := strconv.ParseInt(fields[5], 10, 64)
+		gpu.MemoryUsed = usedMiB << 20
+		gpu.MemoryTotal = totalMiB << 20
+		gpu.Temperature, _ = strconv.ParseFloat(fields[6], 64)
+		gpus = append(gpus, gpu)
+	}
+	return gpus, scanner.Err()
+}
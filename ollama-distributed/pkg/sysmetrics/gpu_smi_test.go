@@ -0,0 +1,33 @@
+//go:build !nvml
+
+package sysmetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNvidiaSMI(t *testing.T) {
+	output := "0, GPU-1111, NVIDIA A100-SXM4-80GB, 87, 40960, 81920, 61\n" +
+		"1, GPU-2222, NVIDIA A100-SXM4-80GB, [N/A], 0, 81920, 35\n"
+	gpus, err := parseNvidiaSMI(strings.NewReader(output))
+	require.NoError(t, err)
+	require.Len(t, gpus, 2)
+
+	assert.Equal(t, GPU{
+		Index:       0,
+		UUID:        "GPU-1111",
+		Name:        "NVIDIA A100-SXM4-80GB",
+		Utilization: 87,
+		MemoryUsed:  40960 << 20,
+		MemoryTotal: 81920 << 20,
+		Temperature: 61,
+	}, gpus[0])
+	assert.Zero(t, gpus[1].Utilization)
+
+	_, err = parseNvidiaSMI(strings.NewReader("garbage\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,411 @@
+// Package sysmetrics samples host resource usage from the operating system
+// for scheduling decisions and the node status command. CPU, memory,
+// network and disk I/O counters are read from procfs, so on other platforms
+// only disk usage and GPU metrics are reported.
+package sysmetrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GPU is the usage of a single GPU
+type GPU struct {
+	Index       int     `json:"index"`
+	UUID        string  `json:"uuid"`
+	Name        string  `json:"name"`
+	Utilization float64 `json:"utilization"` // percent
+	MemoryUsed  int64   `json:"memory_used"`
+	MemoryTotal int64   `json:"memory_total"`
+	Temperature float64 `json:"temperature"` // degrees Celsius
+}
+
+// Sample is a point-in-time view of host resource usage
+type Sample struct {
+	CPUPercent  float64 `json:"cpu_percent"`
+	CPUCores    int     `json:"cpu_cores"`
+	MemoryUsed  int64   `json:"memory_used"`
+	MemoryTotal int64   `json:"memory_total"`
+	DiskUsed    int64   `json:"disk_used"` // filesystem holding the data dir
+	DiskTotal   int64   `json:"disk_total"`
+	NetRxRate   int64   `json:"net_rx_rate"` // bytes/sec over all non-loopback interfaces
+	NetTxRate   int64   `json:"net_tx_rate"`
+	GPUs        []GPU   `json:"gpus"`
+
+	// Disk I/O over all physical disks: throughput in bytes/sec and the
+	// average time a completed read or write took
+	DiskReadRate  int64         `json:"disk_read_rate"`
+	DiskWriteRate int64         `json:"disk_write_rate"`
+	DiskLatency   time.Duration `json:"disk_latency"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Options configures a Collector
+type Options struct {
+	// DataDir selects the filesystem disk usage is reported for
+	DataDir string
+	// DisableGPU skips GPU sampling, e.g. on hosts without drivers
+	DisableGPU bool
+}
+
+// Collector samples resource usage. CPU, network and disk I/O figures are
+// rates, so they are computed from the difference to the previous sample;
+// the first sample reports CPU usage and disk latency since boot and no
+// network or disk traffic.
+type Collector struct {
+	dataDir  string
+	procRoot string // empty where procfs is unavailable
+	gpus     func() ([]GPU, error)
+
+	mu       sync.Mutex
+	prevCPU  *cpuTimes
+	prevNet  *netCounters
+	prevDisk *diskCounters
+	prevAt   time.Time
+}
+
+// NewCollector creates a collector
+func NewCollector(opts Options) *Collector {
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		dataDir = "."
+	}
+	c := &Collector{
+		dataDir: dataDir,
+		gpus:    collectGPUs,
+	}
+	if runtime.GOOS == "linux" {
+		c.procRoot = "/proc"
+	}
+	if opts.DisableGPU {
+		c.gpus = func() ([]GPU, error) { return nil, nil }
+	}
+	return c
+}
+
+// Collect takes a sample. Sources that fail are left at zero and their
+// errors returned together with the partial sample.
+func (c *Collector) Collect() (*Sample, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	sample := &Sample{
+		CPUCores:  runtime.NumCPU(),
+		Timestamp: now,
+	}
+	var errs []error
+
+	if c.procRoot != "" {
+		errs = append(errs, c.collectProc(sample, now)...)
+	}
+
+	if used, total, err := diskUsage(c.dataDir); err != nil {
+		errs = append(errs, fmt.Errorf("disk: %w", err))
+	} else {
+		sample.DiskUsed, sample.DiskTotal = used, total
+	}
+
+	if gpus, err := c.gpus(); err != nil {
+		errs = append(errs, fmt.Errorf("gpu: %w", err))
+	} else {
+		sample.GPUs = gpus
+	}
+
+	return sample, errors.Join(errs...)
+}
+
+// collectProc fills in the procfs based CPU, memory, network and disk I/O
+// figures
+func (c *Collector) collectProc(sample *Sample, now time.Time) []error {
+	var errs []error
+
+	if cpu, err := c.readCPUTimes(); err != nil {
+		errs = append(errs, fmt.Errorf("cpu: %w", err))
+	} else {
+		sample.CPUPercent = cpu.percentSince(c.prevCPU)
+		c.prevCPU = cpu
+	}
+
+	if used, total, err := c.readMemory(); err != nil {
+		errs = append(errs, fmt.Errorf("memory: %w", err))
+	} else {
+		sample.MemoryUsed, sample.MemoryTotal = used, total
+	}
+
+	if net, err := c.readNetCounters(); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	} else {
+		if c.prevNet != nil {
+			elapsed := now.Sub(c.prevAt).Seconds()
+			sample.NetRxRate = rate(c.prevNet.rx, net.rx, elapsed)
+			sample.NetTxRate = rate(c.prevNet.tx, net.tx, elapsed)
+		}
+		c.prevNet = net
+	}
+
+	if disk, err := c.readDiskCounters(); err != nil {
+		errs = append(errs, fmt.Errorf("disk io: %w", err))
+	} else {
+		sample.DiskLatency = disk.latencySince(c.prevDisk)
+		if c.prevDisk != nil {
+			elapsed := now.Sub(c.prevAt).Seconds()
+			sample.DiskReadRate = rate(c.prevDisk.read, disk.read, elapsed)
+			sample.DiskWriteRate = rate(c.prevDisk.written, disk.written, elapsed)
+		}
+		c.prevDisk = disk
+	}
+	c.prevAt = now
+
+	return errs
+}
+
+// rate returns the per-second increase of a counter, treating a reset as
+// no traffic
+func rate(prev, cur uint64, elapsed float64) int64 {
+	if cur < prev || elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(cur-prev) / elapsed)
+}
+
+// cpuTimes are the aggregate jiffies from /proc/stat
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// percentSince returns the busy percentage since prev, or since boot
+func (t *cpuTimes) percentSince(prev *cpuTimes) float64 {
+	busy, total := t.busy, t.total
+	if prev != nil && t.total > prev.total {
+		busy, total = t.busy-prev.busy, t.total-prev.total
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(busy) / float64(total) * 100
+}
+
+func (c *Collector) readCPUTimes() (*cpuTimes, error) {
+	f, err := os.Open(filepath.Join(c.procRoot, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCPUTimes(f)
+}
+
+func parseCPUTimes(r io.Reader) (*cpuTimes, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal; guest time is
+		// already included in user
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu field %q", field)
+			}
+			times.total += v
+			if i != 3 && i != 4 { // idle, iowait
+				times.busy += v
+			}
+		}
+		return &times, nil
+	}
+	return nil, errors.New("no cpu line in stat")
+}
+
+func (c *Collector) readMemory() (used, total int64, err error) {
+	f, err := os.Open(filepath.Join(c.procRoot, "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+func parseMeminfo(r io.Reader) (used, total int64, err error) {
+	var available int64 = -1
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	if total == 0 || available < 0 {
+		return 0, 0, errors.New("MemTotal or MemAvailable missing from meminfo")
+	}
+	return total - available, total, nil
+}
+
+// netCounters are cumulative byte counters over all non-loopback
+// interfaces
+type netCounters struct {
+	rx, tx uint64
+}
+
+func (c *Collector) readNetCounters() (*netCounters, error) {
+	f, err := os.Open(filepath.Join(c.procRoot, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+func parseNetDev(r io.Reader) (*netCounters, error) {
+	var counters netCounters
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		iface, stats, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue // header lines
+		}
+		if strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		// 8 receive fields followed by 8 transmit fields, bytes first
+		fields := strings.Fields(stats)
+		if len(fields) < 16 {
+			continue
+		}
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rx bytes %q", fields[0])
+		}
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx bytes %q", fields[8])
+		}
+		counters.rx += rx
+		counters.tx += tx
+	}
+	return &counters, scanner.Err()
+}
+
+// diskCounters are cumulative I/O counters over all physical disks
+type diskCounters struct {
+	read, written uint64 // bytes
+	ios           uint64 // completed reads and writes
+	ioTime        uint64 // milliseconds spent on them
+}
+
+// latencySince returns the average time per I/O completed since prev, or
+// since boot
+func (d *diskCounters) latencySince(prev *diskCounters) time.Duration {
+	ios, ioTime := d.ios, d.ioTime
+	if prev != nil && d.ios >= prev.ios && d.ioTime >= prev.ioTime {
+		ios, ioTime = d.ios-prev.ios, d.ioTime-prev.ioTime
+	}
+	if ios == 0 {
+		return 0
+	}
+	return time.Duration(float64(ioTime) / float64(ios) * float64(time.Millisecond))
+}
+
+// sectorSize is the unit of the sector counts in diskstats, whatever the
+// device's own sector size
+const sectorSize = 512
+
+// virtualDisks are the name prefixes of devices that are not physical
+// disks, or that stack on disks already counted
+var virtualDisks = []string{"loop", "ram", "zram", "dm-", "md", "sr", "fd"}
+
+func (c *Collector) readDiskCounters() (*diskCounters, error) {
+	f, err := os.Open(filepath.Join(c.procRoot, "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDiskStats(f)
+}
+
+func parseDiskStats(r io.Reader) (*diskCounters, error) {
+	devices := make(map[string][]uint64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// major minor name, then reads, merged, sectors and ms reading,
+		// writes, merged, sectors and ms writing
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 11 || isVirtualDisk(fields[2]) {
+			continue
+		}
+		values := make([]uint64, 8)
+		for i := range values {
+			v, err := strconv.ParseUint(fields[3+i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s field %q", fields[2], fields[3+i])
+			}
+			values[i] = v
+		}
+		devices[fields[2]] = values
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var counters diskCounters
+	for name, values := range devices {
+		if isPartition(name, devices) {
+			continue // counted with its disk
+		}
+		counters.read += values[2] * sectorSize
+		counters.written += values[6] * sectorSize
+		counters.ios += values[0] + values[4]
+		counters.ioTime += values[3] + values[7]
+	}
+	return &counters, nil
+}
+
+func isVirtualDisk(name string) bool {
+	for _, prefix := range virtualDisks {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPartition reports whether a device is a partition of another listed
+// device, e.g. sda1 of sda or nvme0n1p1 of nvme0n1
+func isPartition(name string, devices map[string][]uint64) bool {
+	for disk := range devices {
+		if disk == name || !strings.HasPrefix(name, disk) {
+			continue
+		}
+		suffix := strings.TrimPrefix(strings.TrimPrefix(name, disk), "p")
+		if _, err := strconv.Atoi(suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
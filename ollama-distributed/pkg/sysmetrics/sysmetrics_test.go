@@ -0,0 +1,104 @@
+package sysmetrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 5000000    1000    0    0    0     0          0         0  5000000    1000    0    0    0     0       0          0
+  eth0: %d   2000    0    0    0     0          0         0  %d   1500    0    0    0     0       0          0
+`
+
+// testDiskStats lists a disk with two partitions, an NVMe disk with one
+// and devices that are not physical disks
+const testDiskStats = `   7       0 loop0 500 0 1000 50 0 0 0 0 0 10 50
+   8       0 sda %d 0 %d %d %d 0 %d %d 0 100 1000
+   8       1 sda1 50 0 100 20 10 0 20 5 0 20 25
+   8       2 sda2 50 0 100 20 10 0 20 5 0 20 25
+ 259       0 nvme0n1 100 0 200 10 100 0 200 10 0 20 20
+ 259       1 nvme0n1p1 100 0 200 10 100 0 200 10 0 20 20
+ 253       0 dm-0 900 0 1800 90 900 0 1800 90 0 180 180
+`
+
+func writeProc(t *testing.T, root, stat string, rx, tx int) {
+	writeDiskStats(t, root, 100, 100, 100, 100, 100)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "net"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "stat"), []byte(stat), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "meminfo"), []byte(
+		"MemTotal:       16384000 kB\nMemFree:         1000000 kB\nMemAvailable:    4096000 kB\n"), 0644))
+	netDev := fmt.Sprintf(testNetDev, rx, tx)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "net", "dev"), []byte(netDev), 0644))
+}
+
+func TestCollectorRates(t *testing.T) {
+	root := t.TempDir()
+	c := NewCollector(Options{DataDir: root, DisableGPU: true})
+	c.procRoot = root
+
+	// 100 busy of 400 jiffies since boot
+	writeProc(t, root, "cpu  60 0 40 250 50 0 0 0 0 0\ncpu0 60 0 40 250 50 0 0 0 0 0\n", 1000, 500)
+	first, err := c.Collect()
+	require.NoError(t, err)
+	assert.InDelta(t, 25.0, first.CPUPercent, 0.01)
+	assert.Equal(t, int64(16384000*1024), first.MemoryTotal)
+	assert.Equal(t, int64((16384000-4096000)*1024), first.MemoryUsed)
+	assert.Zero(t, first.NetRxRate, "no rate without a previous sample")
+	assert.Positive(t, first.DiskTotal)
+
+	// A further 300 busy of 400 jiffies
+	c.prevAt = time.Now().Add(-2 * time.Second)
+	writeProc(t, root, "cpu  260 0 140 300 100 0 0 0 0 0\n", 1000+4000, 500+2000)
+	second, err := c.Collect()
+	require.NoError(t, err)
+	assert.InDelta(t, 75.0, second.CPUPercent, 0.01)
+	assert.InDelta(t, 2000, second.NetRxRate, 50, "loopback traffic is excluded")
+	assert.InDelta(t, 1000, second.NetTxRate, 50)
+}
+
+// writeDiskStats writes diskstats in which sda completed ios reads and
+// writes, reading and writing the given sectors, in ms milliseconds each
+func writeDiskStats(t *testing.T, root string, ios, sectorsRead, sectorsWritten, msRead, msWritten int) {
+	stats := fmt.Sprintf(testDiskStats, ios, sectorsRead, msRead, ios, sectorsWritten, msWritten)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "diskstats"), []byte(stats), 0644))
+}
+
+func TestCollectorDiskIO(t *testing.T) {
+	root := t.TempDir()
+	c := NewCollector(Options{DataDir: root, DisableGPU: true})
+	c.procRoot = root
+
+	// 400 ios since boot taking 2000ms in all, nvme0n1 included
+	writeProc(t, root, "cpu  60 0 40 250 50 0 0 0 0 0\n", 0, 0)
+	writeDiskStats(t, root, 100, 100, 100, 800, 1180)
+	first, err := c.Collect()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Millisecond, first.DiskLatency, "latency since boot")
+	assert.Zero(t, first.DiskReadRate, "no rate without a previous sample")
+
+	// A further 200 ios taking 40ms each, reading 8MiB and writing 4MiB
+	c.prevAt = time.Now().Add(-2 * time.Second)
+	writeDiskStats(t, root, 200, 100+16384, 100+8192, 800+4000, 1180+4000)
+	second, err := c.Collect()
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Millisecond, second.DiskLatency)
+	assert.InDelta(t, 4<<20, second.DiskReadRate, 100<<10, "partitions and virtual devices are excluded")
+	assert.InDelta(t, 2<<20, second.DiskWriteRate, 50<<10)
+}
+
+func TestCollectorPartialFailure(t *testing.T) {
+	c := NewCollector(Options{DataDir: t.TempDir(), DisableGPU: true})
+	c.procRoot = filepath.Join(t.TempDir(), "missing")
+
+	sample, err := c.Collect()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cpu:")
+	assert.Positive(t, sample.DiskTotal, "disk is still reported")
+}
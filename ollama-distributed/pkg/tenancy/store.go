@@ -0,0 +1,119 @@
+package tenancy
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for an item a tenant does not have
+var ErrNotFound = errors.New("not found")
+
+// Template is a named prompt template
+type Template struct {
+	Name      string    `json:"name"`
+	Model     string    `json:"model,omitempty"`
+	System    string    `json:"system,omitempty"`
+	Template  string    `json:"template"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Message is a turn of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Conversation is a stored chat history
+type Conversation struct {
+	ID        string    `json:"id"`
+	Model     string    `json:"model"`
+	Title     string    `json:"title,omitempty"`
+	Messages  []Message `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps items by tenant, so that no tenant can read or overwrite
+// another tenant's items even when their keys collide
+type Store[T any] struct {
+	mu    sync.RWMutex
+	items map[string]map[string]T
+}
+
+// NewStore creates an empty store
+func NewStore[T any]() *Store[T] {
+	return &Store[T]{items: make(map[string]map[string]T)}
+}
+
+// Put stores an item of a tenant, replacing any item with the same key
+func (s *Store[T]) Put(tenant, key string, item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, ok := s.items[tenant]
+	if !ok {
+		items = make(map[string]T)
+		s.items[tenant] = items
+	}
+	items[key] = item
+}
+
+// Get returns an item of a tenant
+func (s *Store[T]) Get(tenant, key string) (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[tenant][key]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return item, nil
+}
+
+// List returns the items of a tenant sorted by key
+func (s *Store[T]) List(tenant string) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.items[tenant]))
+	for key := range s.items[tenant] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]T, len(keys))
+	for i, key := range keys {
+		items[i] = s.items[tenant][key]
+	}
+	return items
+}
+
+// Update replaces an item of a tenant with the result of update, atomically
+func (s *Store[T]) Update(tenant, key string, update func(T) T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[tenant][key]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	item = update(item)
+	s.items[tenant][key] = item
+	return item, nil
+}
+
+// Delete removes an item of a tenant
+func (s *Store[T]) Delete(tenant, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[tenant][key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.items[tenant], key)
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package tenancy isolates the tenants sharing a cluster. API keys bind
+// callers to a tenant; each tenant sees only its own models, conversations,
+// prompt templates and usage, plus the cluster-wide models it is allowed to
+// use, and its workloads can be confined to a dedicated node pool.
+package tenancy
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/outputlimit"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+)
+
+// PoolLabel is the node label naming the pool a node belongs to. Nodes of a
+// dedicated pool should also carry a NoSchedule taint with this key and the
+// pool as value, which only the pool's tenants tolerate.
+const PoolLabel = "tenant.pool"
+
+// modelSeparator separates the tenant from the model in scoped model names
+const modelSeparator = "/"
+
+// ErrUnknownTenant is returned for a tenant that is not configured
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Config configures multitenancy
+type Config struct {
+	Enabled bool     `yaml:"enabled"`
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+// Tenant is an isolated user of the cluster
+type Tenant struct {
+	ID   string `yaml:"id" json:"id"`
+	Name string `yaml:"name" json:"name,omitempty"`
+
+	// APIKeys authenticate the tenant's callers
+	APIKeys []string `yaml:"api_keys" mapstructure:"api_keys" json:"-"`
+	// Roles granted to callers using the tenant's API keys; empty grants
+	// the user role
+	Roles []string `yaml:"roles" json:"roles,omitempty"`
+
+	// NodePool confines the tenant's workloads to the nodes labelled with
+	// this pool; empty schedules them anywhere
+	NodePool string `yaml:"node_pool" mapstructure:"node_pool" json:"node_pool,omitempty"`
+
+	// SharedModels are the cluster-wide models the tenant may use, as
+	// path.Match patterns; empty allows all of them
+	SharedModels []string `yaml:"shared_models" mapstructure:"shared_models" json:"shared_models,omitempty"`
+
+	// Groups the tenant belongs to, which policies such as license rules
+	// may target instead of individual tenants
+	Groups []string `yaml:"groups" json:"groups,omitempty"`
+
+	// OutputLimits caps the output tokens and streaming duration of the
+	// tenant's generations
+	OutputLimits outputlimit.Limits `yaml:"output_limits" mapstructure:"output_limits" json:"output_limits,omitempty"`
+	// APIKeyLimits override the output limits of some of the tenant's keys
+	APIKeyLimits []APIKeyLimits `yaml:"api_key_limits" mapstructure:"api_key_limits" json:"-"`
+}
+
+// APIKeyLimits are the output limits of one of a tenant's API keys. Limits
+// left unset fall back to the tenant's.
+type APIKeyLimits struct {
+	APIKey       string             `yaml:"api_key" mapstructure:"api_key"`
+	OutputLimits outputlimit.Limits `yaml:"output_limits" mapstructure:"output_limits"`
+}
+
+// Validate checks the tenant's ID, pool and model patterns
+func (t Tenant) Validate() error {
+	if t.ID == "" || strings.Contains(t.ID, modelSeparator) {
+		return fmt.Errorf("invalid tenant id %q", t.ID)
+	}
+	if strings.ContainsAny(t.NodePool, " =,") {
+		return fmt.Errorf("tenant %s: invalid node pool %q", t.ID, t.NodePool)
+	}
+	for _, pattern := range t.SharedModels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("tenant %s: invalid model pattern %q", t.ID, pattern)
+		}
+	}
+	if err := t.OutputLimits.Validate(); err != nil {
+		return fmt.Errorf("tenant %s: %w", t.ID, err)
+	}
+	for _, keyLimits := range t.APIKeyLimits {
+		if !slices.Contains(t.APIKeys, keyLimits.APIKey) {
+			return fmt.Errorf("tenant %s: output limits for an API key of another tenant", t.ID)
+		}
+		if err := keyLimits.OutputLimits.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// Placement returns the node selector and tolerations that confine the
+// tenant's workloads to its node pool, or nothing without a pool
+func (t Tenant) Placement() (map[string]string, []placement.Toleration) {
+	if t.NodePool == "" {
+		return nil, nil
+	}
+	return map[string]string{PoolLabel: t.NodePool},
+		[]placement.Toleration{{Key: PoolLabel, Operator: placement.TolerationOpEqual, Value: t.NodePool}}
+}
+
+// sharesModel reports whether the tenant may use a cluster-wide model
+func (t Tenant) sharesModel(model string) bool {
+	if len(t.SharedModels) == 0 {
+		return true
+	}
+	for _, pattern := range t.SharedModels {
+		if matched, _ := path.Match(pattern, model); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage counts the inference requests and output tokens of a tenant
+type Usage struct {
+	Tenant       string           `json:"tenant"`
+	Requests     int64            `json:"requests"`
+	Failures     int64            `json:"failures"`
+	Models       map[string]int64 `json:"models"` // requests per model
+	OutputTokens int64            `json:"output_tokens"`
+	// CutOff counts the generations cut off at an output limit, whose
+	// partial tokens are included in OutputTokens
+	CutOff      int64     `json:"cut_off"`
+	LastRequest time.Time `json:"last_request"`
+}
+
+// Registry holds the tenants and their usage
+type Registry struct {
+	tenants map[string]Tenant
+	keys    map[[sha256.Size]byte]string // API key hash to tenant
+	limits  map[[sha256.Size]byte]outputlimit.Limits
+
+	mu    sync.Mutex
+	usage map[string]*Usage
+}
+
+// NewRegistry creates a registry of the configured tenants
+func NewRegistry(config Config) (*Registry, error) {
+	r := &Registry{
+		tenants: make(map[string]Tenant),
+		keys:    make(map[[sha256.Size]byte]string),
+		limits:  make(map[[sha256.Size]byte]outputlimit.Limits),
+		usage:   make(map[string]*Usage),
+	}
+	for _, t := range config.Tenants {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := r.tenants[t.ID]; exists {
+			return nil, fmt.Errorf("duplicate tenant %s", t.ID)
+		}
+		for _, key := range t.APIKeys {
+			if key == "" {
+				return nil, fmt.Errorf("tenant %s has an empty API key", t.ID)
+			}
+			hash := sha256.Sum256([]byte(key))
+			if _, exists := r.keys[hash]; exists {
+				return nil, fmt.Errorf("tenant %s reuses an API key", t.ID)
+			}
+			r.keys[hash] = t.ID
+			r.limits[hash] = t.OutputLimits
+		}
+		for _, keyLimits := range t.APIKeyLimits {
+			r.limits[sha256.Sum256([]byte(keyLimits.APIKey))] = keyLimits.OutputLimits.Or(t.OutputLimits)
+		}
+		r.tenants[t.ID] = t
+	}
+	return r, nil
+}
+
+// Authenticate returns the tenant an API key belongs to. Keys are looked up
+// by their hash, so the comparison does not leak the key through timing.
+func (r *Registry) Authenticate(key string) (Tenant, bool) {
+	id, ok := r.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Tenant{}, false
+	}
+	return r.tenants[id], true
+}
+
+// OutputLimits returns the output limits of an API key
+func (r *Registry) OutputLimits(key string) outputlimit.Limits {
+	return r.limits[sha256.Sum256([]byte(key))]
+}
+
+// Get returns a tenant
+func (r *Registry) Get(id string) (Tenant, error) {
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// List returns the tenants sorted by ID
+func (r *Registry) List() []Tenant {
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// ScopedModel returns the name a tenant's own model is registered under in
+// the cluster, e.g. "acme/llama3"
+func ScopedModel(tenant, model string) string {
+	return tenant + modelSeparator + model
+}
+
+// ModelView returns the name a tenant sees a registered model under, and
+// whether the tenant sees it at all: its own models without the tenant
+// prefix, and the cluster-wide models it shares. Models of other tenants
+// are hidden.
+func (r *Registry) ModelView(tenant, registered string) (string, bool) {
+	if owner, name, ok := strings.Cut(registered, modelSeparator); ok {
+		if owner == tenant {
+			return name, true
+		}
+		if _, isTenant := r.tenants[owner]; isTenant {
+			return "", false
+		}
+	}
+	t, ok := r.tenants[tenant]
+	if !ok || !t.sharesModel(registered) {
+		return "", false
+	}
+	return registered, true
+}
+
+// ResolveModel returns the candidates a model name a tenant uses may be
+// registered under: its own model first, then the cluster-wide one if the
+// tenant shares it
+func (r *Registry) ResolveModel(tenant, model string) []string {
+	candidates := []string{ScopedModel(tenant, model)}
+	if _, visible := r.ModelView(tenant, model); visible {
+		candidates = append(candidates, model)
+	}
+	return candidates
+}
+
+// RecordUsage counts an inference request of a tenant
+func (r *Registry) RecordUsage(tenant, model string, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := r.usageOf(tenant)
+	u.Requests++
+	if failed {
+		u.Failures++
+	}
+	u.Models[model]++
+	u.LastRequest = time.Now()
+}
+
+// RecordOutput counts the output tokens of a tenant's generation, and
+// whether it was cut off at an output limit
+func (r *Registry) RecordOutput(tenant string, tokens int, cutOff bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := r.usageOf(tenant)
+	u.OutputTokens += int64(tokens)
+	if cutOff {
+		u.CutOff++
+	}
+}
+
+// usageOf returns the usage of a tenant, creating it; r.mu must be held
+func (r *Registry) usageOf(tenant string) *Usage {
+	u, ok := r.usage[tenant]
+	if !ok {
+		u = &Usage{Tenant: tenant, Models: make(map[string]int64)}
+		r.usage[tenant] = u
+	}
+	return u
+}
+
+// Usage returns the usage of a tenant
+func (r *Registry) Usage(tenant string) Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.usage[tenant]
+	if !ok {
+		return Usage{Tenant: tenant, Models: map[string]int64{}}
+	}
+	usage := *u
+	usage.Models = make(map[string]int64, len(u.Models))
+	for model, n := range u.Models {
+		usage.Models[model] = n
+	}
+	return usage
+}
@@ -0,0 +1,124 @@
+package tenancy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/outputlimit"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/placement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	r, err := NewRegistry(Config{Enabled: true, Tenants: []Tenant{
+		{ID: "acme", APIKeys: []string{"acme-key"}, NodePool: "gpu-a"},
+		{ID: "globex", APIKeys: []string{"globex-key"}, SharedModels: []string{"llama3*"}},
+	}})
+	require.NoError(t, err)
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	r := newTestRegistry(t)
+
+	tenant, ok := r.Authenticate("globex-key")
+	require.True(t, ok)
+	assert.Equal(t, "globex", tenant.ID)
+
+	_, ok = r.Authenticate("guess")
+	assert.False(t, ok)
+
+	_, err := NewRegistry(Config{Tenants: []Tenant{
+		{ID: "a", APIKeys: []string{"same"}},
+		{ID: "b", APIKeys: []string{"same"}},
+	}})
+	assert.Error(t, err, "keys bind to a single tenant")
+
+	_, err = NewRegistry(Config{Tenants: []Tenant{{ID: "a/b"}}})
+	assert.Error(t, err)
+}
+
+func TestModelView(t *testing.T) {
+	r := newTestRegistry(t)
+
+	name, ok := r.ModelView("acme", "acme/custom")
+	assert.True(t, ok)
+	assert.Equal(t, "custom", name)
+
+	_, ok = r.ModelView("globex", "acme/custom")
+	assert.False(t, ok, "other tenants' models are hidden")
+
+	_, ok = r.ModelView("globex", "mistral")
+	assert.False(t, ok, "globex only shares llama3 models")
+	_, ok = r.ModelView("acme", "library/mistral")
+	assert.True(t, ok, "prefixes that are not tenants are cluster-wide models")
+
+	assert.Equal(t, []string{"globex/llama3", "llama3"}, r.ResolveModel("globex", "llama3"))
+	assert.Equal(t, []string{"globex/mistral"}, r.ResolveModel("globex", "mistral"))
+}
+
+func TestPlacementAndUsage(t *testing.T) {
+	r := newTestRegistry(t)
+
+	acme, err := r.Get("acme")
+	require.NoError(t, err)
+	selector, tolerations := acme.Placement()
+	assert.Equal(t, map[string]string{PoolLabel: "gpu-a"}, selector)
+	assert.True(t, tolerations[0].Tolerates(placement.Taint{Key: PoolLabel, Value: "gpu-a", Effect: placement.TaintNoSchedule}))
+	assert.False(t, tolerations[0].Tolerates(placement.Taint{Key: PoolLabel, Value: "gpu-b", Effect: placement.TaintNoSchedule}))
+
+	_, err = r.Get("initech")
+	assert.ErrorIs(t, err, ErrUnknownTenant)
+
+	r.RecordUsage("acme", "llama3", false)
+	r.RecordUsage("acme", "llama3", true)
+	usage := r.Usage("acme")
+	assert.Equal(t, int64(2), usage.Requests)
+	assert.Equal(t, int64(1), usage.Failures)
+	assert.Equal(t, map[string]int64{"llama3": 2}, usage.Models)
+	assert.Zero(t, r.Usage("globex").Requests)
+}
+
+func TestStoreIsolatesTenants(t *testing.T) {
+	store := NewStore[Template]()
+	store.Put("acme", "summary", Template{Name: "summary", Template: "Summarize: {{.Prompt}}"})
+	store.Put("globex", "summary", Template{Name: "summary", Template: "TL;DR {{.Prompt}}"})
+
+	tmpl, err := store.Get("acme", "summary")
+	require.NoError(t, err)
+	assert.Equal(t, "Summarize: {{.Prompt}}", tmpl.Template)
+
+	assert.ErrorIs(t, store.Delete("initech", "summary"), ErrNotFound)
+	require.NoError(t, store.Delete("globex", "summary"))
+	assert.Empty(t, store.List("globex"))
+	assert.Len(t, store.List("acme"), 1)
+}
+
+func TestOutputLimits(t *testing.T) {
+	r, err := NewRegistry(Config{Tenants: []Tenant{{
+		ID:           "acme",
+		APIKeys:      []string{"batch-key", "chat-key"},
+		OutputLimits: outputlimit.Limits{MaxOutputTokens: 512, MaxStreamDuration: time.Minute},
+		APIKeyLimits: []APIKeyLimits{{APIKey: "batch-key", OutputLimits: outputlimit.Limits{MaxOutputTokens: 4096}}},
+	}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, outputlimit.Limits{MaxOutputTokens: 512, MaxStreamDuration: time.Minute}, r.OutputLimits("chat-key"))
+	assert.Equal(t, outputlimit.Limits{MaxOutputTokens: 4096, MaxStreamDuration: time.Minute}, r.OutputLimits("batch-key"),
+		"unset key limits fall back to the tenant's")
+	assert.True(t, r.OutputLimits("guess").IsZero())
+
+	_, err = NewRegistry(Config{Tenants: []Tenant{{
+		ID:           "acme",
+		APIKeys:      []string{"acme-key"},
+		APIKeyLimits: []APIKeyLimits{{APIKey: "other-key"}},
+	}}})
+	assert.Error(t, err, "limits only apply to the tenant's own keys")
+
+	r.RecordOutput("acme", 300, false)
+	r.RecordOutput("acme", 512, true)
+	usage := r.Usage("acme")
+	assert.Equal(t, int64(812), usage.OutputTokens)
+	assert.Equal(t, int64(1), usage.CutOff)
+}
@@ -0,0 +1,287 @@
+// Package textstream rewrites inference responses as they are written.
+// Responses are handled line by line, as Ollama streams NDJSON and
+// OpenAI-style APIs stream server-sent events; a response that is not
+// streamed is a single line. Handlers look at the JSON chunks of a
+// response and may change them, hold them back or end the response.
+package textstream
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Result is what a Handler makes of a chunk
+type Result struct {
+	// Changed is set when the handler changed the chunk
+	Changed bool
+	// Hold holds the chunk back, with the lines after it, until a chunk is
+	// written or the response ended
+	Hold bool
+	// End, when set, is written in place of the chunk and those held back
+	// and ends the response; later writes are discarded
+	End interface{}
+}
+
+// Handler looks at the JSON chunks of a response
+type Handler interface {
+	// Chunk may change a chunk in place; last is set for the chunk ending
+	// the response
+	Chunk(chunk map[string]interface{}, last bool) Result
+}
+
+// Writer passes a response through a handler before writing it to w.
+// Lines held back when the response breaks off before its last chunk are
+// dropped.
+type Writer struct {
+	w       io.Writer
+	handler Handler
+
+	mu      sync.Mutex
+	pending []byte
+	held    [][]byte
+	done    bool
+	ended   bool
+}
+
+// NewWriter creates a writer passing what is written through handler to w
+func NewWriter(w io.Writer, handler Handler) *Writer {
+	return &Writer{w: w, handler: handler}
+}
+
+// Write passes on the complete lines of b, holding back a trailing partial
+// line
+func (w *Writer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, b...)
+	for !w.ended {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.pending[:i+1]
+		w.pending = w.pending[i+1:]
+		if err := w.writeLine(line, false); err != nil {
+			return len(b), err
+		}
+	}
+	if w.ended {
+		w.pending = nil
+	}
+	return len(b), nil
+}
+
+// WriteString writes s
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Finish writes the held back partial line, which is the whole body of a
+// response that is not streamed, as the last chunk. It must be called once
+// the response is complete.
+func (w *Writer) Finish() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := w.pending
+	w.pending = nil
+	if len(line) > 0 && !w.ended {
+		if err := w.writeLine(line, true); err != nil {
+			return err
+		}
+	}
+	w.held = nil
+	return nil
+}
+
+// writeLine passes a line through the handler and writes it; w.mu must be
+// held
+func (w *Writer) writeLine(line []byte, last bool) error {
+	payload := bytes.TrimSpace(line)
+	sse := bytes.HasPrefix(payload, []byte("data:"))
+	payload = bytes.TrimSpace(bytes.TrimPrefix(payload, []byte("data:")))
+	var chunk map[string]interface{}
+	if w.done || len(payload) == 0 || payload[0] != '{' || json.Unmarshal(payload, &chunk) != nil {
+		return w.write(line, len(w.held) > 0)
+	}
+
+	last = last || Final(chunk)
+	if last {
+		w.done = true
+	}
+	result := w.handler.Chunk(chunk, last)
+	if result.End != nil {
+		w.held = nil
+		w.ended = true
+		body, err := frame(result.End, line, sse)
+		if err != nil {
+			return err
+		}
+		if sse {
+			// The blank line ending the event is discarded with the rest
+			body = append(bytes.TrimRight(body, "\r\n"), '\n', '\n')
+		}
+		if _, err := w.w.Write(body); err != nil {
+			return err
+		}
+		if f, ok := w.w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+		return nil
+	}
+	if result.Changed {
+		body, err := frame(chunk, line, sse)
+		if err != nil {
+			return err
+		}
+		line = body
+	}
+	return w.write(line, result.Hold && !last)
+}
+
+// write writes a line after the lines held back, or holds it back too;
+// w.mu must be held
+func (w *Writer) write(line []byte, hold bool) error {
+	if hold {
+		w.held = append(w.held, append([]byte(nil), line...))
+		return nil
+	}
+	for _, held := range w.held {
+		if _, err := w.w.Write(held); err != nil {
+			return err
+		}
+	}
+	w.held = nil
+	_, err := w.w.Write(line)
+	return err
+}
+
+// frame marshals v as a line in the format of line: with the data: prefix
+// of server-sent events, and its line ending
+func frame(v interface{}, line []byte, sse bool) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if sse {
+		body = append([]byte("data: "), body...)
+	}
+	return append(body, line[len(bytes.TrimRight(line, "\r\n")):]...), nil
+}
+
+// ResponseWriter passes the response of a gin handler through a Writer
+type ResponseWriter struct {
+	gin.ResponseWriter
+	stream *Writer
+}
+
+// NewResponseWriter wraps w, passing what handlers write through handler
+func NewResponseWriter(w gin.ResponseWriter, handler Handler) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, stream: NewWriter(w, handler)}
+}
+
+// Write passes b through the handler
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	return w.stream.Write(b)
+}
+
+// WriteString passes s through the handler
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	return w.stream.Write([]byte(s))
+}
+
+// Finish writes what is held back. It must be called once the handler
+// returns.
+func (w *ResponseWriter) Finish() error {
+	return w.stream.Finish()
+}
+
+// Texts replaces each text field of a chunk with what fn returns for it,
+// given the path of the field: response of Ollama generate chunks,
+// message.content of chat chunks, and choices.N.text,
+// choices.N.delta.content and choices.N.message.content of OpenAI-style
+// choices
+func Texts(chunk map[string]interface{}, fn func(field, text string) string) {
+	replace := func(holder map[string]interface{}, key, field string) {
+		if text, ok := holder[key].(string); ok {
+			holder[key] = fn(field, text)
+		}
+	}
+
+	replace(chunk, "response", "response")
+	if message, ok := chunk["message"].(map[string]interface{}); ok {
+		replace(message, "content", "message.content")
+	}
+	choices, _ := chunk["choices"].([]interface{})
+	for i, c := range choices {
+		choice, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		prefix := "choices." + strconv.Itoa(i) + "."
+		replace(choice, "text", prefix+"text")
+		for _, key := range []string{"delta", "message"} {
+			if holder, ok := choice[key].(map[string]interface{}); ok {
+				replace(holder, "content", prefix+key+".content")
+			}
+		}
+	}
+}
+
+// Text returns the generated text of a chunk, that of all its text fields
+func Text(chunk map[string]interface{}) string {
+	var text strings.Builder
+	Texts(chunk, func(_, t string) string {
+		text.WriteString(t)
+		return t
+	})
+	return text.String()
+}
+
+// SetText sets a text field of a chunk by the path Texts gives it, adding
+// the objects holding it when the chunk lacks them
+func SetText(chunk map[string]interface{}, field, text string) {
+	set(chunk, strings.Split(field, "."), text)
+}
+
+func set(v interface{}, path []string, text string) interface{} {
+	if len(path) == 0 {
+		return text
+	}
+	if i, err := strconv.Atoi(path[0]); err == nil {
+		list, _ := v.([]interface{})
+		for len(list) <= i {
+			list = append(list, map[string]interface{}{})
+		}
+		list[i] = set(list[i], path[1:], text)
+		return list
+	}
+	holder, ok := v.(map[string]interface{})
+	if !ok {
+		holder = make(map[string]interface{})
+	}
+	holder[path[0]] = set(holder[path[0]], path[1:], text)
+	return holder
+}
+
+// Final reports whether a chunk ends the response
+func Final(chunk map[string]interface{}) bool {
+	if done, _ := chunk["done"].(bool); done {
+		return true
+	}
+	choices, _ := chunk["choices"].([]interface{})
+	for _, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		if reason, _ := choice["finish_reason"].(string); reason != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package textstream
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperHandler upper-cases the text of chunks, holding them back while
+// hold is set and ending the response at the word stop
+type upperHandler struct {
+	hold bool
+	last []bool
+}
+
+func (h *upperHandler) Chunk(chunk map[string]interface{}, last bool) Result {
+	h.last = append(h.last, last)
+	if strings.Contains(Text(chunk), "stop") {
+		return Result{End: map[string]interface{}{"done": true, "error": "stopped"}}
+	}
+	Texts(chunk, func(_, text string) string { return strings.ToUpper(text) })
+	return Result{Changed: Text(chunk) != "", Hold: h.hold}
+}
+
+func TestWriter(t *testing.T) {
+	serve := func(handler Handler, writes ...string) string {
+		var out bytes.Buffer
+		w := NewWriter(&out, handler)
+		for _, b := range writes {
+			_, err := w.Write([]byte(b))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Finish())
+		return out.String()
+	}
+
+	handler := &upperHandler{}
+	body := serve(handler, `{"response":"hi"}`+"\n"+`{"respo`, `nse":"","done":true}`+"\n")
+	assert.Equal(t, `{"response":"HI"}`+"\n"+`{"response":"","done":true}`+"\n", body, "chunks split across writes are joined")
+	assert.Equal(t, []bool{false, true}, handler.last)
+
+	body = serve(&upperHandler{}, `{"choices":[{"text":"a"}]}`)
+	assert.Equal(t, `{"choices":[{"text":"A"}]}`, body, "a response that is not streamed is the last chunk")
+
+	sse := "data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" + "data: [DONE]\n\n"
+	assert.Equal(t, strings.Replace(sse, `"a"`, `"A"`, 1), serve(&upperHandler{}, sse))
+
+	body = serve(&upperHandler{hold: true}, `{"response":"a"}`+"\n", "\n", `{"response":"stop"}`+"\n", `{"response":"b","done":true}`+"\n")
+	assert.Equal(t, `{"done":true,"error":"stopped"}`+"\n", body, "held chunks are dropped when the response ends")
+
+	body = serve(&upperHandler{hold: true}, `{"response":"a"}`+"\n", "\n", `{"response":"b","done":true}`+"\n")
+	assert.Equal(t, `{"response":"A"}`+"\n\n"+`{"done":true,"response":"B"}`+"\n", body, "held lines are written in order")
+
+	body = serve(&upperHandler{hold: true}, `{"response":"a"}`+"\n")
+	assert.Empty(t, body, "held lines of a response broken off are dropped")
+
+	body = serve(&upperHandler{}, "data: {\"response\":\"stop\"}\n", "\n", "data: {\"response\":\"more\"}\n\n")
+	assert.Equal(t, "data: {\"done\":true,\"error\":\"stopped\"}\n\n", body, "writes after the end are discarded")
+}
+
+func TestSetText(t *testing.T) {
+	chunk := map[string]interface{}{"done": true}
+	SetText(chunk, "response", "a")
+	SetText(chunk, "message.content", "b")
+	SetText(chunk, "choices.1.delta.content", "c")
+	assert.Equal(t, "abc", Text(chunk))
+
+	var fields []string
+	Texts(chunk, func(field, text string) string {
+		fields = append(fields, field)
+		return text
+	})
+	assert.Equal(t, []string{"response", "message.content", "choices.1.delta.content"}, fields)
+}
@@ -0,0 +1,271 @@
+// Package timesync checks how far this node's clock is from NTP time and
+// estimates the clock skew of peers. Raft and metrics timestamps compare
+// clocks of different nodes, so skew beyond a threshold is warned about;
+// durations between timestamps from different clocks go through Since and
+// Between, which never return negative values.
+package timesync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// Defaults for an unset configuration
+const (
+	DefaultInterval      = 10 * time.Minute
+	DefaultWarnThreshold = 500 * time.Millisecond
+	DefaultServer        = "pool.ntp.org"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// Config configures the clock skew check
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Servers are the NTP servers queried, as host or host:port; the
+	// answer with the shortest round trip is used
+	Servers []string `yaml:"servers"`
+	// Interval between checks after the one at startup
+	Interval time.Duration `yaml:"interval"`
+	// WarnThreshold is the skew, of this node or of a peer, beyond which a
+	// warning is logged
+	WarnThreshold time.Duration `yaml:"warn_threshold" mapstructure:"warn_threshold"`
+}
+
+// Validate checks the interval and threshold
+func (c Config) Validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("negative interval %v", c.Interval)
+	}
+	if c.WarnThreshold < 0 {
+		return fmt.Errorf("negative warn threshold %v", c.WarnThreshold)
+	}
+	return nil
+}
+
+// Sample is the answer of one NTP server
+type Sample struct {
+	Server string `json:"server"`
+	// Offset is how far the server's clock is ahead of this node's
+	Offset time.Duration `json:"offset"`
+	RTT    time.Duration `json:"rtt"`
+}
+
+// Status is the outcome of the latest check
+type Status struct {
+	Offset    time.Duration `json:"offset"`
+	RTT       time.Duration `json:"rtt"`
+	Server    string        `json:"server,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+	// Exceeded is set when the offset is beyond the warn threshold
+	Exceeded bool   `json:"exceeded"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Checker periodically measures the offset of this node's clock
+type Checker struct {
+	cfg   Config
+	query func(ctx context.Context, server string) (Sample, error)
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewChecker creates a checker, filling in defaults for unset values
+func NewChecker(cfg Config) *Checker {
+	if len(cfg.Servers) == 0 {
+		cfg.Servers = []string{DefaultServer}
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.WarnThreshold <= 0 {
+		cfg.WarnThreshold = DefaultWarnThreshold
+	}
+	return &Checker{cfg: cfg, query: Query}
+}
+
+// WarnThreshold returns the skew beyond which warnings are logged
+func (c *Checker) WarnThreshold() time.Duration {
+	return c.cfg.WarnThreshold
+}
+
+// Start checks the clock now and then every interval until ctx is done
+func (c *Checker) Start(ctx context.Context) {
+	c.Check(ctx)
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Check(ctx)
+		}
+	}
+}
+
+// Check queries the NTP servers and records the offset of the answer with
+// the shortest round trip
+func (c *Checker) Check(ctx context.Context) Status {
+	var best *Sample
+	var errs []error
+	for _, server := range c.cfg.Servers {
+		sample, err := c.query(ctx, server)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if best == nil || sample.RTT < best.RTT {
+			best = &sample
+		}
+	}
+
+	status := Status{CheckedAt: time.Now()}
+	if best == nil {
+		status.Error = errors.Join(errs...).Error()
+		c.mu.Lock()
+		failedBefore := c.status.Error != ""
+		// Keep the last measured offset
+		status.Offset, status.RTT, status.Server, status.Exceeded = c.status.Offset, c.status.RTT, c.status.Server, c.status.Exceeded
+		c.status = status
+		c.mu.Unlock()
+		if !failedBefore {
+			slog.Warn("clock skew check failed", "error", status.Error)
+		}
+		return status
+	}
+
+	status.Offset, status.RTT, status.Server = best.Offset, best.RTT, best.Server
+	status.Exceeded = abs(best.Offset) > c.cfg.WarnThreshold
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+
+	if status.Exceeded {
+		slog.Warn("clock skew beyond threshold; raft and metrics timestamps may be unreliable",
+			"offset", status.Offset, "threshold", c.cfg.WarnThreshold, "server", status.Server)
+	} else {
+		slog.Debug("clock skew checked", "offset", status.Offset, "server", status.Server)
+	}
+	return status
+}
+
+// Status returns the outcome of the latest check
+func (c *Checker) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Query asks an NTP server for the time using SNTP (RFC 4330)
+func Query(ctx context.Context, server string) (Sample, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, "123")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return Sample{}, fmt.Errorf("ntp %s: %w", server, err)
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return Sample{}, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // no leap warning, version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTP(sent))
+	if _, err := conn.Write(req); err != nil {
+		return Sample{}, fmt.Errorf("ntp %s: %w", server, err)
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return Sample{}, fmt.Errorf("ntp %s: %w", server, err)
+	}
+	// The monotonic clock gives the round trip even if the wall clock
+	// stepped meanwhile
+	received := sent.Add(time.Since(sent))
+	if n < 48 {
+		return Sample{}, fmt.Errorf("ntp %s: short response", server)
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return Sample{}, fmt.Errorf("ntp %s: unexpected mode %d", server, mode)
+	}
+	if resp[1] == 0 {
+		return Sample{}, fmt.Errorf("ntp %s: kiss-of-death %q", server, resp[12:16])
+	}
+	if binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+		return Sample{}, fmt.Errorf("ntp %s: response does not match the request", server)
+	}
+
+	serverReceived := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	rtt := received.Sub(sent) - serverSent.Sub(serverReceived)
+	if rtt < 0 {
+		rtt = 0
+	}
+	return Sample{Server: server, Offset: offset, RTT: rtt}, nil
+}
+
+// EstimateOffset estimates how far a peer's clock is ahead of this node's
+// from a timestamp the peer took while answering a request sent and
+// answered at the given local times, assuming a symmetric round trip
+func EstimateOffset(sent, received, remote time.Time) time.Duration {
+	midpoint := sent.Add(Between(sent, received) / 2)
+	return remote.Sub(midpoint)
+}
+
+// Since is time.Since for timestamps that may come from another node's
+// clock or before a wall clock step, returning zero instead of a negative
+// duration
+func Since(t time.Time) time.Duration {
+	return Between(t, time.Now())
+}
+
+// Between returns end - start, or zero when end is before start
+func Between(start, end time.Time) time.Duration {
+	if d := end.Sub(start); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// toNTP converts a time to the 64-bit NTP timestamp format
+func toNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return secs<<32 | frac
+}
+
+// fromNTP converts a 64-bit NTP timestamp
+func fromNTP(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(secs, nanos)
+}
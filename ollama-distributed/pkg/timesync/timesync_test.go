@@ -0,0 +1,91 @@
+package timesync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveNTP answers SNTP requests with a clock running ahead by skew
+func serveNTP(t *testing.T, skew time.Duration) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0x24 // version 4, server mode
+			resp[1] = 2    // stratum
+			copy(resp[24:32], buf[40:48])
+			now := time.Now().Add(skew)
+			binary.BigEndian.PutUint64(resp[32:], toNTP(now))
+			binary.BigEndian.PutUint64(resp[40:], toNTP(now))
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestQuery(t *testing.T) {
+	server := serveNTP(t, 2*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	sample, err := Query(ctx, server)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(2*time.Second), float64(sample.Offset), float64(50*time.Millisecond))
+	assert.Less(t, sample.RTT, time.Second)
+}
+
+func TestCheckerStatus(t *testing.T) {
+	c := NewChecker(Config{Servers: []string{"slow", "fast"}, WarnThreshold: time.Second})
+	c.query = func(ctx context.Context, server string) (Sample, error) {
+		if server == "slow" {
+			return Sample{Server: server, Offset: 5 * time.Second, RTT: time.Second}, nil
+		}
+		return Sample{Server: server, Offset: -2 * time.Second, RTT: time.Millisecond}, nil
+	}
+
+	status := c.Check(context.Background())
+	assert.Equal(t, "fast", status.Server)
+	assert.Equal(t, -2*time.Second, status.Offset)
+	assert.True(t, status.Exceeded)
+
+	// A failed check keeps the last offset
+	c.query = func(ctx context.Context, server string) (Sample, error) {
+		return Sample{}, errors.New("unreachable")
+	}
+	status = c.Check(context.Background())
+	assert.Equal(t, -2*time.Second, status.Offset)
+	assert.Contains(t, status.Error, "unreachable")
+	assert.Equal(t, status, c.Status())
+}
+
+func TestRelativeDurations(t *testing.T) {
+	now := time.Now()
+	assert.Zero(t, Since(now.Add(time.Hour)))
+	assert.Equal(t, time.Second, Between(now, now.Add(time.Second)))
+	assert.Zero(t, Between(now, now.Add(-time.Second)))
+
+	// The peer answered 10ms into a 20ms round trip with its clock 3s ahead
+	remote := now.Add(10*time.Millisecond + 3*time.Second)
+	assert.Equal(t, 3*time.Second, EstimateOffset(now, now.Add(20*time.Millisecond), remote))
+
+	ts := time.Unix(1700000000, 500000000)
+	assert.WithinDuration(t, ts, fromNTP(toNTP(ts)), time.Microsecond)
+}
@@ -0,0 +1,257 @@
+// Package topology keeps the matrix of round-trip times between the
+// cluster's nodes. Each node measures the RTT to its peers itself, timing
+// the echo of the probes it piggybacks on its heartbeats, so no clocks
+// need to agree; the rows of the other nodes arrive with their heartbeats.
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of the latency matrix
+const (
+	DefaultSmoothing = 0.2
+	DefaultMaxAge    = time.Minute
+)
+
+// Config configures the measurement of latencies between nodes
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Smoothing is the weight of a new RTT sample against the average of
+	// the earlier ones, 0.2 by default
+	Smoothing float64 `yaml:"smoothing"`
+	// MaxAge drops the latencies not measured for this long, 1m by default
+	MaxAge time.Duration `yaml:"max_age" mapstructure:"max_age"`
+}
+
+// Validate checks the settings
+func (c Config) Validate() error {
+	if c.Smoothing < 0 || c.Smoothing > 1 {
+		return fmt.Errorf("topology smoothing must be between 0 and 1")
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("topology max age must not be negative")
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.Smoothing == 0 {
+		c.Smoothing = DefaultSmoothing
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = DefaultMaxAge
+	}
+	return c
+}
+
+// Link is the latency measured from one node to another
+type Link struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	RTT       time.Duration `json:"rtt"`
+	Samples   int64         `json:"samples,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Topology is a snapshot of the latency matrix. Latencies holds the RTT in
+// milliseconds between every pair of nodes measured in either direction.
+type Topology struct {
+	Self      string                        `json:"self"`
+	Nodes     []string                      `json:"nodes"`
+	Links     []Link                        `json:"links"`
+	Latencies map[string]map[string]float64 `json:"latencies_ms"`
+}
+
+type link struct {
+	rtt     time.Duration
+	samples int64
+	updated time.Time
+}
+
+// Matrix holds the RTTs between the cluster's nodes: the ones this node
+// measured and the ones its peers reported. It is safe for concurrent use.
+type Matrix struct {
+	self string
+	cfg  Config
+	now  func() time.Time
+
+	mu    sync.RWMutex
+	links map[string]map[string]*link // from, to
+}
+
+// NewMatrix returns an empty matrix of the node self
+func NewMatrix(self string, cfg Config) *Matrix {
+	return &Matrix{
+		self:  self,
+		cfg:   cfg.WithDefaults(),
+		now:   time.Now,
+		links: make(map[string]map[string]*link),
+	}
+}
+
+// Observe records an RTT this node measured to a peer, smoothed into the
+// earlier measurements
+func (m *Matrix) Observe(peer string, rtt time.Duration) {
+	if peer == m.self || rtt <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	l := m.link(m.self, peer)
+	if l.samples == 0 || now.Sub(l.updated) > m.cfg.MaxAge {
+		l.rtt, l.samples = rtt, 0
+	} else {
+		l.rtt = time.Duration(m.cfg.Smoothing*float64(rtt) + (1-m.cfg.Smoothing)*float64(l.rtt))
+	}
+	l.samples++
+	l.updated = now
+}
+
+// Merge replaces the row of a peer with the RTTs it reported. Reports
+// about this node's own row are ignored.
+func (m *Matrix) Merge(from string, row map[string]time.Duration) {
+	if from == m.self {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	links := make(map[string]*link, len(row))
+	for to, rtt := range row {
+		if to != from && rtt > 0 {
+			links[to] = &link{rtt: rtt, updated: now}
+		}
+	}
+	m.links[from] = links
+}
+
+// link returns the link between two nodes, creating it. The caller holds
+// mu.
+func (m *Matrix) link(from, to string) *link {
+	row, exists := m.links[from]
+	if !exists {
+		row = make(map[string]*link)
+		m.links[from] = row
+	}
+	l, exists := row[to]
+	if !exists {
+		l = &link{}
+		row[to] = l
+	}
+	return l
+}
+
+// Row returns the fresh RTTs measured from a node, the row its heartbeats
+// carry when it is this node
+func (m *Matrix) Row(from string) map[string]time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	row := make(map[string]time.Duration, len(m.links[from]))
+	for to, l := range m.links[from] {
+		if m.fresh(l, now) {
+			row[to] = l.rtt
+		}
+	}
+	return row
+}
+
+// Latency returns the RTT between two nodes, averaged over both directions
+// when both were measured, and 0 between a node and itself
+func (m *Matrix) Latency(a, b string) (time.Duration, bool) {
+	if a == b {
+		return 0, true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latency(a, b, m.now())
+}
+
+// latency is Latency for distinct nodes. The caller holds mu.
+func (m *Matrix) latency(a, b string, now time.Time) (time.Duration, bool) {
+	var sum time.Duration
+	var n int
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if l, exists := m.links[pair[0]][pair[1]]; exists && m.fresh(l, now) {
+			sum += l.rtt
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / time.Duration(n), true
+}
+
+func (m *Matrix) fresh(l *link, now time.Time) bool {
+	return l.rtt > 0 && now.Sub(l.updated) <= m.cfg.MaxAge
+}
+
+// Remove forgets a node's row and the latencies to it, e.g. once it left
+// the cluster
+func (m *Matrix) Remove(nodeID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.links, nodeID)
+	for _, row := range m.links {
+		delete(row, nodeID)
+	}
+}
+
+// Snapshot returns the fresh latencies between the cluster's nodes, with
+// nodes and links ordered by node ID
+func (m *Matrix) Snapshot() Topology {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	t := Topology{Self: m.self, Links: []Link{}, Latencies: make(map[string]map[string]float64)}
+	nodes := map[string]bool{m.self: true}
+	for from, row := range m.links {
+		for to, l := range row {
+			if !m.fresh(l, now) {
+				continue
+			}
+			nodes[from], nodes[to] = true, true
+			t.Links = append(t.Links, Link{From: from, To: to, RTT: l.rtt, Samples: l.samples, UpdatedAt: l.updated})
+		}
+	}
+	for nodeID := range nodes {
+		t.Nodes = append(t.Nodes, nodeID)
+	}
+	sort.Strings(t.Nodes)
+	sort.Slice(t.Links, func(i, j int) bool {
+		if t.Links[i].From != t.Links[j].From {
+			return t.Links[i].From < t.Links[j].From
+		}
+		return t.Links[i].To < t.Links[j].To
+	})
+
+	for _, a := range t.Nodes {
+		for _, b := range t.Nodes {
+			if a == b {
+				continue
+			}
+			if rtt, ok := m.latency(a, b, now); ok {
+				if t.Latencies[a] == nil {
+					t.Latencies[a] = make(map[string]float64)
+				}
+				t.Latencies[a][b] = float64(rtt) / float64(time.Millisecond)
+			}
+		}
+	}
+	return t
+}
@@ -0,0 +1,76 @@
+package topology
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{Smoothing: 1.5}.Validate())
+	assert.Error(t, Config{MaxAge: -time.Second}.Validate())
+}
+
+func TestObserveSmoothsRTT(t *testing.T) {
+	m := NewMatrix("a", Config{Smoothing: 0.5})
+	m.Observe("b", 10*time.Millisecond)
+	m.Observe("b", 20*time.Millisecond)
+	m.Observe("a", time.Millisecond)
+
+	rtt, ok := m.Latency("a", "b")
+	require.True(t, ok)
+	assert.Equal(t, 15*time.Millisecond, rtt)
+	assert.Equal(t, map[string]time.Duration{"b": 15 * time.Millisecond}, m.Row("a"))
+
+	rtt, ok = m.Latency("a", "a")
+	assert.True(t, ok)
+	assert.Zero(t, rtt)
+	_, ok = m.Latency("a", "c")
+	assert.False(t, ok)
+}
+
+func TestMergeAndSnapshot(t *testing.T) {
+	m := NewMatrix("a", Config{})
+	m.Observe("b", 10*time.Millisecond)
+	m.Merge("b", map[string]time.Duration{"a": 20 * time.Millisecond, "c": 40 * time.Millisecond, "b": time.Millisecond})
+	m.Merge("a", map[string]time.Duration{"b": time.Hour})
+
+	rtt, ok := m.Latency("b", "a")
+	require.True(t, ok)
+	assert.Equal(t, 15*time.Millisecond, rtt, "averaged over both directions")
+	rtt, _ = m.Latency("c", "b")
+	assert.Equal(t, 40*time.Millisecond, rtt)
+
+	snapshot := m.Snapshot()
+	assert.Equal(t, "a", snapshot.Self)
+	assert.Equal(t, []string{"a", "b", "c"}, snapshot.Nodes)
+	require.Len(t, snapshot.Links, 3)
+	assert.Equal(t, Link{From: "a", To: "b"}, Link{From: snapshot.Links[0].From, To: snapshot.Links[0].To})
+	assert.Equal(t, 15.0, snapshot.Latencies["a"]["b"])
+	assert.Equal(t, 40.0, snapshot.Latencies["c"]["b"])
+	assert.NotContains(t, snapshot.Latencies["a"], "c")
+
+	m.Remove("c")
+	assert.Equal(t, []string{"a", "b"}, m.Snapshot().Nodes)
+}
+
+func TestStaleLatencies(t *testing.T) {
+	m := NewMatrix("a", Config{MaxAge: time.Minute})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return start }
+	m.Observe("b", 10*time.Millisecond)
+
+	m.now = func() time.Time { return start.Add(2 * time.Minute) }
+	_, ok := m.Latency("a", "b")
+	assert.False(t, ok)
+	assert.Empty(t, m.Row("a"))
+	assert.Empty(t, m.Snapshot().Links)
+
+	// A stale average is not smoothed into
+	m.Observe("b", 30*time.Millisecond)
+	rtt, _ := m.Latency("a", "b")
+	assert.Equal(t, 30*time.Millisecond, rtt)
+}
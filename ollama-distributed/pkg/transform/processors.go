@@ -0,0 +1,158 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/redaction"
+)
+
+// systemPrompt injects text, such as an organization's guidelines, into
+// the system prompt of requests: the system field of generate requests and
+// the leading system message of chat requests
+type systemPrompt struct {
+	text   string
+	append bool
+}
+
+// newSystemPrompt takes the text option, and the position option prepend
+// (default) or append placing it before or after an existing system prompt
+func newSystemPrompt(options map[string]string) (Processor, error) {
+	p := &systemPrompt{text: options["text"]}
+	if p.text == "" {
+		return nil, fmt.Errorf("text option is required")
+	}
+	switch options["position"] {
+	case "", "prepend":
+	case "append":
+		p.append = true
+	default:
+		return nil, fmt.Errorf("position must be prepend or append")
+	}
+	return p, nil
+}
+
+func (p *systemPrompt) TransformRequest(ctx context.Context, req *Request) error {
+	messages, ok := req.Body["messages"].([]interface{})
+	if !ok {
+		existing, _ := req.Body["system"].(string)
+		req.Body["system"] = p.combine(existing)
+		return nil
+	}
+	if len(messages) > 0 {
+		if first, ok := messages[0].(map[string]interface{}); ok && first["role"] == "system" {
+			existing, _ := first["content"].(string)
+			first["content"] = p.combine(existing)
+			return nil
+		}
+	}
+	system := map[string]interface{}{"role": "system", "content": p.text}
+	req.Body["messages"] = append([]interface{}{system}, messages...)
+	return nil
+}
+
+func (p *systemPrompt) TransformResponse(ctx context.Context, resp *Response) error {
+	return nil
+}
+
+// combine places the text before or after an existing system prompt
+func (p *systemPrompt) combine(existing string) string {
+	switch {
+	case existing == "":
+		return p.text
+	case p.append:
+		return existing + "\n\n" + p.text
+	default:
+		return p.text + "\n\n" + existing
+	}
+}
+
+// redact scrubs PII and secrets from prompts or generated text with the
+// recognizers of the redaction package
+type redact struct {
+	engine *redaction.Engine
+}
+
+// newRedact takes the entities option, a comma separated list of built-in
+// entities defaulting to every one, and the mode option label (default),
+// mask or hash
+func newRedact(options map[string]string) (Processor, error) {
+	policy := redaction.Policy{Mode: options["mode"]}
+	for _, entity := range strings.Split(options["entities"], ",") {
+		if entity = strings.TrimSpace(entity); entity != "" {
+			policy.Entities = append(policy.Entities, entity)
+		}
+	}
+	engine, err := redaction.NewEngine(redaction.Config{Default: policy})
+	if err != nil {
+		return nil, err
+	}
+	return &redact{engine: engine}, nil
+}
+
+func (r *redact) TransformRequest(ctx context.Context, req *Request) error {
+	return req.Texts(func(text string) (string, error) {
+		return r.engine.Redact("", text), nil
+	})
+}
+
+func (r *redact) TransformResponse(ctx context.Context, resp *Response) error {
+	resp.Text = r.engine.Redact("", resp.Text)
+	return nil
+}
+
+// wordFilter masks or rejects words, such as profanity, in prompts or
+// generated text
+type wordFilter struct {
+	re     *regexp.Regexp
+	reject bool
+}
+
+// newWordFilter takes the words option, a comma separated list of words
+// matched whole and regardless of case, and the action option mask
+// (default) or reject
+func newWordFilter(options map[string]string) (Processor, error) {
+	var words []string
+	for _, word := range strings.Split(options["words"], ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("words option is required")
+	}
+	f := &wordFilter{re: regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)}
+	switch options["action"] {
+	case "", "mask":
+	case "reject":
+		f.reject = true
+	default:
+		return nil, fmt.Errorf("action must be mask or reject")
+	}
+	return f, nil
+}
+
+func (f *wordFilter) TransformRequest(ctx context.Context, req *Request) error {
+	return req.Texts(f.filter)
+}
+
+func (f *wordFilter) TransformResponse(ctx context.Context, resp *Response) error {
+	text, err := f.filter(resp.Text)
+	resp.Text = text
+	return err
+}
+
+// filter masks the words in text, or rejects it if it has any
+func (f *wordFilter) filter(text string) (string, error) {
+	if f.reject {
+		if f.re.MatchString(text) {
+			return text, fmt.Errorf("%w: filtered word", ErrRejected)
+		}
+		return text, nil
+	}
+	return f.re.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	}), nil
+}
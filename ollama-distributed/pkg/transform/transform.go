@@ -0,0 +1,378 @@
+// Package transform runs configurable hooks over inference traffic at the
+// gateway. Request hooks modify a request before it is scheduled, e.g. to
+// inject an organization's guidelines into the prompt, and response hooks
+// modify the generated text before it is returned, e.g. to scrub PII. Hooks
+// are built from processors registered by name, apply to the tenants and
+// models they are configured for, run in order, and either skip or reject
+// the request when they fail.
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stages hooks run at
+const (
+	StageRequest  = "request"
+	StageResponse = "response"
+)
+
+// Policies on a hook failing
+const (
+	// FailReject fails the request, or ends the response, with the error
+	FailReject = "reject"
+	// FailSkip carries on as if the hook had not run
+	FailSkip = "skip"
+)
+
+// ErrRejected is wrapped by processors refusing a request or response
+// outright; it rejects whatever the hook's failure policy
+var ErrRejected = errors.New("rejected by transformation hook")
+
+// Config configures the hooks
+type Config struct {
+	Enabled bool         `yaml:"enabled"`
+	Hooks   []HookConfig `yaml:"hooks"`
+}
+
+// HookConfig configures a hook
+type HookConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// Processor is the registered processor the hook runs
+	Processor string `yaml:"processor" json:"processor"`
+	// Stage is request or response
+	Stage string `yaml:"stage" json:"stage"`
+	// Order sorts the hooks of a stage, lowest first; hooks of the same
+	// order run in the order they are configured
+	Order int `yaml:"order" json:"order"`
+	// Tenants the hook applies to, every one when empty
+	Tenants []string `yaml:"tenants" json:"tenants,omitempty"`
+	// Models the hook applies to as requested, with * and ? wildcards;
+	// every one when empty
+	Models []string `yaml:"models" json:"models,omitempty"`
+	// OnFailure is reject (default) or skip
+	OnFailure string `yaml:"on_failure" mapstructure:"on_failure" json:"on_failure"`
+	// Timeout bounds a run of the hook, unbounded when zero
+	Timeout time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+	// Options configure the processor
+	Options map[string]string `yaml:"options" json:"options,omitempty"`
+}
+
+// Validate checks the hooks and builds their processors
+func (c Config) Validate() error {
+	names := make(map[string]bool)
+	for i, hook := range c.Hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("hook %d has no name", i)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("hook %s is configured twice", hook.Name)
+		}
+		names[hook.Name] = true
+		if _, err := newHook(hook); err != nil {
+			return fmt.Errorf("hook %s: %w", hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// Scope is the request a hook runs for
+type Scope struct {
+	Tenant string
+	// Model as requested
+	Model string
+	// Endpoint is the inference endpoint, e.g. generate or chat
+	Endpoint string
+}
+
+// Request is an inference request being transformed
+type Request struct {
+	Scope
+	// Body is the decoded JSON body
+	Body map[string]interface{}
+}
+
+// Texts replaces the prompt texts of the request, its prompt and system
+// prompt and the content of its messages, by what fn returns for them
+func (r *Request) Texts(fn func(string) (string, error)) error {
+	for _, field := range []string{"prompt", "system"} {
+		if text, ok := r.Body[field].(string); ok && text != "" {
+			replaced, err := fn(text)
+			if err != nil {
+				return err
+			}
+			r.Body[field] = replaced
+		}
+	}
+	messages, _ := r.Body["messages"].([]interface{})
+	for _, m := range messages {
+		message, _ := m.(map[string]interface{})
+		if text, ok := message["content"].(string); ok && text != "" {
+			replaced, err := fn(text)
+			if err != nil {
+				return err
+			}
+			message["content"] = replaced
+		}
+	}
+	return nil
+}
+
+// Response is generated text being transformed: the whole text of a
+// response, or the text of one chunk of a streamed response
+type Response struct {
+	Scope
+	Text string
+}
+
+// Processor transforms requests and responses for a hook; a hook only
+// calls the method of its stage
+type Processor interface {
+	// TransformRequest modifies a request before it is scheduled
+	TransformRequest(ctx context.Context, req *Request) error
+	// TransformResponse modifies generated text before it is returned
+	TransformResponse(ctx context.Context, resp *Response) error
+}
+
+// Factory builds a processor from the options of a hook
+type Factory func(options map[string]string) (Processor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"system_prompt": newSystemPrompt,
+		"redact":        newRedact,
+		"word_filter":   newWordFilter,
+	}
+)
+
+// Register makes a processor available to hooks by name, replacing any
+// registered under the name. Processors are registered before the
+// configuration is loaded, typically from an init function.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Processors returns the names of the registered processors
+func Processors() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hook is a configured processor with its counters
+type hook struct {
+	cfg       HookConfig
+	processor Processor
+
+	runs     atomic.Int64
+	failures atomic.Int64
+	rejected atomic.Int64
+	nanos    atomic.Int64
+}
+
+// newHook builds the processor of a hook after checking its settings
+func newHook(cfg HookConfig) (*hook, error) {
+	if cfg.Stage != StageRequest && cfg.Stage != StageResponse {
+		return nil, fmt.Errorf("stage must be %s or %s", StageRequest, StageResponse)
+	}
+	if cfg.OnFailure == "" {
+		cfg.OnFailure = FailReject
+	}
+	if cfg.OnFailure != FailReject && cfg.OnFailure != FailSkip {
+		return nil, fmt.Errorf("on_failure must be %s or %s", FailReject, FailSkip)
+	}
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+	for _, pattern := range cfg.Models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid model pattern %q", pattern)
+		}
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[cfg.Processor]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor %q", cfg.Processor)
+	}
+	processor, err := factory(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("processor %s: %w", cfg.Processor, err)
+	}
+	return &hook{cfg: cfg, processor: processor}, nil
+}
+
+// applies reports whether the hook runs for a request in scope
+func (h *hook) applies(scope Scope) bool {
+	if len(h.cfg.Tenants) > 0 && !contains(h.cfg.Tenants, scope.Tenant) {
+		return false
+	}
+	if len(h.cfg.Models) == 0 {
+		return true
+	}
+	for _, pattern := range h.cfg.Models {
+		if ok, _ := path.Match(pattern, scope.Model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs fn as the hook, returning the error that stops the request: a
+// rejection, or a failure of a hook rejecting on failure
+func (h *hook) run(ctx context.Context, fn func(context.Context) error) error {
+	if h.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := fn(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("did not finish in time: %w", ctx.Err())
+	}
+	h.runs.Add(1)
+	h.nanos.Add(int64(time.Since(start)))
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrRejected):
+		h.rejected.Add(1)
+		return fmt.Errorf("hook %s: %w", h.cfg.Name, err)
+	default:
+		h.failures.Add(1)
+		if h.cfg.OnFailure == FailSkip {
+			return nil
+		}
+		return fmt.Errorf("hook %s failed: %w", h.cfg.Name, err)
+	}
+}
+
+// HookStatus is a hook with its counters
+type HookStatus struct {
+	HookConfig
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+	Rejected int64 `json:"rejected"`
+	// MeanLatency is the mean duration of a run
+	MeanLatency string `json:"mean_latency"`
+}
+
+// Engine runs the configured hooks
+type Engine struct {
+	request  []*hook
+	response []*hook
+}
+
+// NewEngine builds the hooks of cfg
+func NewEngine(cfg Config) (*Engine, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	e := &Engine{}
+	for _, hc := range cfg.Hooks {
+		h, _ := newHook(hc)
+		if h.cfg.Stage == StageRequest {
+			e.request = append(e.request, h)
+		} else {
+			e.response = append(e.response, h)
+		}
+	}
+	for _, hooks := range [][]*hook{e.request, e.response} {
+		sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].cfg.Order < hooks[j].cfg.Order })
+	}
+	return e, nil
+}
+
+// TransformRequest runs the request hooks applying to scope over a JSON
+// request body, returning the transformed body. Bodies that are not JSON
+// objects are returned unchanged.
+func (e *Engine) TransformRequest(ctx context.Context, scope Scope, body []byte) ([]byte, error) {
+	hooks := applying(e.request, scope)
+	if len(hooks) == 0 {
+		return body, nil
+	}
+	req := &Request{Scope: scope}
+	if json.Unmarshal(body, &req.Body) != nil || req.Body == nil {
+		return body, nil
+	}
+	for _, h := range hooks {
+		if err := h.run(ctx, func(ctx context.Context) error { return h.processor.TransformRequest(ctx, req) }); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(req.Body)
+}
+
+// TransformsResponses reports whether response hooks apply to scope
+func (e *Engine) TransformsResponses(scope Scope) bool {
+	return len(applying(e.response, scope)) > 0
+}
+
+// TransformResponse runs the response hooks applying to scope over
+// generated text
+func (e *Engine) TransformResponse(ctx context.Context, scope Scope, text string) (string, error) {
+	resp := &Response{Scope: scope, Text: text}
+	for _, h := range applying(e.response, scope) {
+		if err := h.run(ctx, func(ctx context.Context) error { return h.processor.TransformResponse(ctx, resp) }); err != nil {
+			return "", err
+		}
+	}
+	return resp.Text, nil
+}
+
+// Hooks returns the hooks of both stages, in the order they run
+func (e *Engine) Hooks() []HookStatus {
+	statuses := make([]HookStatus, 0, len(e.request)+len(e.response))
+	for _, h := range append(append([]*hook{}, e.request...), e.response...) {
+		status := HookStatus{
+			HookConfig: h.cfg,
+			Runs:       h.runs.Load(),
+			Failures:   h.failures.Load(),
+			Rejected:   h.rejected.Load(),
+		}
+		if status.Runs > 0 {
+			status.MeanLatency = (time.Duration(h.nanos.Load() / status.Runs)).String()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// applying returns the hooks applying to scope
+func applying(hooks []*hook, scope Scope) []*hook {
+	var matched []*hook
+	for _, h := range hooks {
+		if h.applies(scope) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
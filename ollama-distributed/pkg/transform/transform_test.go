@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failing is a processor that always fails
+type failing struct{}
+
+func (failing) TransformRequest(ctx context.Context, req *Request) error {
+	return errors.New("backend unreachable")
+}
+
+func (failing) TransformResponse(ctx context.Context, resp *Response) error {
+	return errors.New("backend unreachable")
+}
+
+func init() {
+	Register("failing", func(map[string]string) (Processor, error) { return failing{}, nil })
+}
+
+func TestTransformRequest(t *testing.T) {
+	engine, err := NewEngine(Config{Enabled: true, Hooks: []HookConfig{
+		{Name: "scrub", Processor: "redact", Stage: StageRequest, Order: 2, Options: map[string]string{"entities": "email"}},
+		{Name: "guidelines", Processor: "system_prompt", Stage: StageRequest, Order: 1, Tenants: []string{"acme"},
+			Options: map[string]string{"text": "Follow the ACME style guide."}},
+		{Name: "llama-only", Processor: "failing", Stage: StageRequest, Models: []string{"llama3*"}, OnFailure: FailSkip},
+	}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	body, err := engine.TransformRequest(ctx, Scope{Tenant: "acme", Model: "llama3:8b", Endpoint: "chat"},
+		[]byte(`{"model":"llama3:8b","messages":[{"role":"user","content":"Mail jane@example.com"}]}`))
+	require.NoError(t, err, "a hook skipped on failure does not fail the request")
+	var chat struct {
+		Messages []map[string]string `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(body, &chat))
+	require.Len(t, chat.Messages, 2)
+	assert.Equal(t, "system", chat.Messages[0]["role"])
+	assert.Equal(t, "Follow the ACME style guide.", chat.Messages[0]["content"])
+	assert.NotContains(t, chat.Messages[1]["content"], "jane@example.com")
+
+	body, err = engine.TransformRequest(ctx, Scope{Tenant: "globex", Model: "mistral", Endpoint: "generate"},
+		[]byte(`{"model":"mistral","prompt":"hi","system":"Be brief."}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"model":"mistral","prompt":"hi","system":"Be brief."}`, string(body), "hooks of other tenants do not run")
+
+	statuses := engine.Hooks()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "llama-only", statuses[0].Name, "hooks run by order")
+	assert.Equal(t, int64(1), statuses[0].Failures)
+	assert.Equal(t, int64(2), statuses[2].Runs)
+}
+
+func TestTransformFailurePolicies(t *testing.T) {
+	engine, err := NewEngine(Config{Enabled: true, Hooks: []HookConfig{
+		{Name: "enrich", Processor: "failing", Stage: StageRequest},
+	}})
+	require.NoError(t, err)
+	_, err = engine.TransformRequest(context.Background(), Scope{}, []byte(`{"prompt":"hi"}`))
+	assert.ErrorContains(t, err, "hook enrich failed", "hooks reject on failure by default")
+
+	engine, err = NewEngine(Config{Enabled: true, Hooks: []HookConfig{
+		{Name: "profanity", Processor: "word_filter", Stage: StageRequest, OnFailure: FailSkip,
+			Options: map[string]string{"words": "darn", "action": "reject"}},
+	}})
+	require.NoError(t, err)
+	_, err = engine.TransformRequest(context.Background(), Scope{}, []byte(`{"prompt":"Darn it"}`))
+	assert.ErrorIs(t, err, ErrRejected, "rejections reject whatever the failure policy")
+
+	err = Config{Hooks: []HookConfig{{Name: "x", Processor: "system_prompt", Stage: StageRequest}}}.Validate()
+	assert.ErrorContains(t, err, "text option is required")
+	err = Config{Hooks: []HookConfig{{Name: "x", Processor: "translate", Stage: StageRequest}}}.Validate()
+	assert.ErrorContains(t, err, "unknown processor")
+}
+
+func TestWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := NewEngine(Config{Enabled: true, Hooks: []HookConfig{
+		{Name: "profanity", Processor: "word_filter", Stage: StageResponse, Options: map[string]string{"words": "darn"}},
+		{Name: "block", Processor: "word_filter", Stage: StageResponse, Options: map[string]string{"words": "forbidden", "action": "reject"}},
+	}})
+	require.NoError(t, err)
+	scope := Scope{Model: "llama3", Endpoint: "generate"}
+	assert.True(t, engine.TransformsResponses(scope))
+
+	serve := func(chunks ...string) (string, bool) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		cancelled := false
+		w := NewWriter(context.Background(), c.Writer, engine, scope, func() { cancelled = true })
+		for _, chunk := range chunks {
+			_, err := w.Write([]byte(chunk))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Finish())
+		return recorder.Body.String(), cancelled
+	}
+
+	body, cancelled := serve(`{"response":"Oh darn\n","done":false}`+"\n", `{"response":"","done":true,"eval_count":2}`+"\n")
+	assert.False(t, cancelled)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"response":"Oh ****\n","done":false}`, lines[0], "complete lines are passed on")
+	assert.Equal(t, `{"response":"","done":true,"eval_count":2}`, lines[1], "chunks without text pass unchanged")
+
+	body, _ = serve(`{"response":"Oh d","done":false}`+"\n", `{"response":"arn it\nWell","done":false}`+"\n", `{"response":" darn","done":true}`+"\n")
+	lines = strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"response":"","done":false}`, lines[0], "partial lines are held back")
+	assert.JSONEq(t, `{"response":"Oh **** it\n","done":false}`, lines[1], "words split across chunks are matched")
+	assert.JSONEq(t, `{"response":"Well ****","done":true}`, lines[2], "the last chunk carries the rest")
+
+	body, _ = serve(`{"message":{"role":"assistant","content":"Darn"}}`+"\n", `{"message":{"role":"assistant"},"done":true}`+"\n")
+	lines = strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"message":{"role":"assistant","content":"****"},"done":true}`, lines[1], "held text goes into a last chunk lacking it")
+
+	body, _ = serve(`{"choices":[{"message":{"role":"assistant","content":"Darn."}}]}`)
+	assert.JSONEq(t, `{"choices":[{"message":{"role":"assistant","content":"****."}}]}`, body, "whole responses are transformed")
+
+	body, cancelled = serve(`{"response":"It is ","done":false}`+"\n", `{"response":"forbidden","done":false}`+"\n", `{"response":"!","done":true}`+"\n")
+	assert.True(t, cancelled, "the generation is stopped")
+	lines = strings.Split(strings.TrimSpace(body), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"response":"","done":false}`, lines[0], "rejected text is never passed on")
+	var end Termination
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &end))
+	assert.Equal(t, DoneReason, end.DoneReason)
+	assert.Contains(t, end.Error, "hook block")
+}
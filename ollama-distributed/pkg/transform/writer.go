@@ -0,0 +1,135 @@
+package transform
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/textstream"
+)
+
+// DoneReason is the done_reason of a response ended by a hook
+const DoneReason = "transform_rejected"
+
+// heldTextLimit bounds the text held back waiting for the end of its line;
+// past it the text is released up to its last space
+const heldTextLimit = 4096
+
+// Termination is the last message of a response ended by a hook, in the
+// format of the final chunk of an Ollama stream
+type Termination struct {
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason"`
+	Error      string `json:"error"`
+}
+
+// ResponseHandler runs the response hooks over the generated text of a
+// response as a textstream.Writer passes it on. The text of a streamed
+// response is held back until its line is complete, so the hooks see whole
+// lines however the text is split into chunks: each chunk is passed on
+// with the text completed so far, and the last one with the rest.
+type ResponseHandler struct {
+	engine *Engine
+	scope  Scope
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	held  map[string]string
+	ended error
+}
+
+// ResponseHandler creates a handler for a response in scope, calling
+// cancel to stop the generation once a hook ends the response
+func (e *Engine) ResponseHandler(ctx context.Context, scope Scope, cancel context.CancelFunc) *ResponseHandler {
+	return &ResponseHandler{engine: e, scope: scope, ctx: ctx, cancel: cancel, held: make(map[string]string)}
+}
+
+// Chunk transforms the text of a chunk that is complete, or ends the
+// response if a hook rejects it
+func (h *ResponseHandler) Chunk(chunk map[string]interface{}, last bool) textstream.Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changed := false
+	var err error
+	release := func(field, text string) string {
+		if err != nil {
+			return text
+		}
+		held := h.held[field] + text
+		n := len(held)
+		if !last {
+			n = releasePoint(held)
+		}
+		h.held[field] = held[n:]
+		transformed := ""
+		if n > 0 {
+			transformed, err = h.engine.TransformResponse(h.ctx, h.scope, held[:n])
+		}
+		changed = changed || transformed != text
+		return transformed
+	}
+	visited := make(map[string]bool)
+	textstream.Texts(chunk, func(field, text string) string {
+		visited[field] = true
+		return release(field, text)
+	})
+	if last {
+		// Text held back for fields the last chunk lacks goes into it
+		for field, held := range h.held {
+			if !visited[field] && held != "" {
+				textstream.SetText(chunk, field, release(field, ""))
+			}
+		}
+	}
+
+	if err != nil {
+		h.ended = err
+		h.cancel()
+		return textstream.Result{End: Termination{Done: true, DoneReason: DoneReason, Error: err.Error()}}
+	}
+	return textstream.Result{Changed: changed}
+}
+
+// Ended returns the error of the hook that ended the response, if any
+func (h *ResponseHandler) Ended() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ended
+}
+
+// releasePoint returns how much of held text can be transformed: the
+// complete lines, or up to the last space once too much is held
+func releasePoint(text string) int {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return i + 1
+	}
+	if len(text) <= heldTextLimit {
+		return 0
+	}
+	if i := strings.LastIndexAny(text, " \t"); i >= 0 {
+		return i + 1
+	}
+	return len(text)
+}
+
+// Writer runs the response hooks over the generated text of a gin
+// handler's response before passing it on
+type Writer struct {
+	*textstream.ResponseWriter
+	handler *ResponseHandler
+}
+
+// NewWriter wraps w for a request in scope, calling cancel to stop the
+// generation once a hook ends the response
+func NewWriter(ctx context.Context, w gin.ResponseWriter, engine *Engine, scope Scope, cancel context.CancelFunc) *Writer {
+	handler := engine.ResponseHandler(ctx, scope, cancel)
+	return &Writer{ResponseWriter: textstream.NewResponseWriter(w, handler), handler: handler}
+}
+
+// Ended returns the error of the hook that ended the response, if any
+func (w *Writer) Ended() error {
+	return w.handler.Ended()
+}
@@ -0,0 +1,79 @@
+package types
+
+import "sync"
+
+// NodeEventType is the kind of change to a node of the cluster
+type NodeEventType string
+
+const (
+	NodeJoined  NodeEventType = "joined"
+	NodeUpdated NodeEventType = "updated"
+	NodeLeft    NodeEventType = "left"
+)
+
+// NodeEvent is a change to a node of the cluster. Node is a copy of the
+// node as of the change.
+type NodeEvent struct {
+	Type NodeEventType `json:"type"`
+	Node *NodeInfo     `json:"node"`
+}
+
+// ClusterView is the typed view of the cluster's nodes the scheduler and its
+// cluster manager give the fault tolerance, redundancy and self-healing
+// components
+type ClusterView interface {
+	// ListNodes returns all nodes the view knows of, whatever their status
+	ListNodes() []*NodeInfo
+	GetNode(id string) (*NodeInfo, bool)
+	// Subscribe calls fn on every change to the nodes until cancel is
+	// called. fn must return quickly and not call back into the view.
+	Subscribe(fn func(NodeEvent)) (cancel func())
+}
+
+// FailureDetector tells how suspect the nodes of the cluster are of having
+// failed, from the heartbeats they send
+type FailureDetector interface {
+	// Suspicion is the suspicion level of a node, 0 for nodes never heard
+	// from
+	Suspicion(nodeID string) float64
+	// Suspected reports whether the suspicion of a node has reached the
+	// detector's threshold
+	Suspected(nodeID string) bool
+}
+
+// NodeEvents fans node events out to the subscribers of a ClusterView. The
+// zero value is ready to use.
+type NodeEvents struct {
+	mu          sync.RWMutex
+	next        int
+	subscribers map[int]func(NodeEvent)
+}
+
+// Subscribe calls fn on every event published until cancel is called
+func (e *NodeEvents) Subscribe(fn func(NodeEvent)) (cancel func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(NodeEvent))
+	}
+	id := e.next
+	e.next++
+	e.subscribers[id] = fn
+	return func() {
+		e.mu.Lock()
+		delete(e.subscribers, id)
+		e.mu.Unlock()
+	}
+}
+
+// Publish calls the subscribers with a copy of the node
+func (e *NodeEvents) Publish(kind NodeEventType, node *NodeInfo) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, fn := range e.subscribers {
+		copied := *node
+		fn(NodeEvent{Type: kind, Node: &copied})
+	}
+}
@@ -38,6 +38,7 @@ const (
 	NodeStatusOffline     NodeStatus = "offline"
 	NodeStatusDraining    NodeStatus = "draining"
 	NodeStatusMaintenance NodeStatus = "maintenance"
+	NodeStatusQuarantined NodeStatus = "quarantined"
 )
 
 // NodeCapabilities represents the capabilities of a node
@@ -237,33 +238,3 @@ type ConsensusEngine interface {
 	ProposeChange(ctx context.Context, change interface{}) error
 	GetState() *ClusterState
 }
-
-// Legacy compatibility types for API compatibility
-
-// NodeInfo represents simplified node information for API compatibility
-type NodeInfo struct {
-	ID       string            `json:"id"`
-	Address  string            `json:"address"`
-	Status   NodeStatus        `json:"status"`
-	Capacity NodeCapacity      `json:"capacity"`
-	Usage    NodeUsage         `json:"usage"`
-	Models   []string          `json:"models"`
-	LastSeen time.Time         `json:"last_seen"`
-	Metadata map[string]string `json:"metadata"`
-}
-
-// NodeCapacity represents simplified node resource capacity
-type NodeCapacity struct {
-	CPU    int64 `json:"cpu"`
-	Memory int64 `json:"memory"`
-	GPU    int64 `json:"gpu,omitempty"`
-	Disk   int64 `json:"disk"`
-}
-
-// NodeUsage represents simplified current node resource usage
-type NodeUsage struct {
-	CPU    float64 `json:"cpu"`
-	Memory float64 `json:"memory"`
-	GPU    float64 `json:"gpu,omitempty"`
-	Disk   float64 `json:"disk"`
-}
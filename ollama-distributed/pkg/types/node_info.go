@@ -0,0 +1,143 @@
+package types
+
+import "time"
+
+// NodeInfo is the information about a node the scheduler, the inference
+// engine and the model manager share
+type NodeInfo struct {
+	ID       string            `json:"id"`
+	Address  string            `json:"address"`
+	Status   NodeStatus        `json:"status"`
+	Capacity NodeCapacity      `json:"capacity"`
+	Usage    NodeUsage         `json:"usage"`
+	Models   []string          `json:"models"`
+	LastSeen time.Time         `json:"last_seen"`
+	Metadata map[string]string `json:"metadata"`
+
+	// ClockSkew is how far the node's clock is ahead of this node's, as
+	// estimated from its health check answers
+	ClockSkew time.Duration `json:"clock_skew"`
+
+	// Disk is the disk I/O the node advertises
+	Disk DiskStats `json:"disk"`
+
+	// Suspicion is how suspect the node is of having failed, as judged by
+	// the failure detector from its heartbeats. Suspected nodes have
+	// reached the detector's threshold.
+	Suspicion float64 `json:"suspicion,omitempty"`
+	Suspected bool    `json:"suspected,omitempty"`
+
+	// ActiveRequests and QueuedRequests are the requests the node is
+	// serving and has waiting
+	ActiveRequests int `json:"active_requests"`
+	QueuedRequests int `json:"queued_requests"`
+}
+
+// NodeCapacity represents the capacity of a node
+type NodeCapacity struct {
+	CPU    int64 `json:"cpu"`           // CPU cores
+	Memory int64 `json:"memory"`        // Memory in bytes
+	GPU    int64 `json:"gpu,omitempty"` // GPU count
+	Disk   int64 `json:"disk"`          // Disk space in bytes
+}
+
+// NodeUsage represents the current usage of a node
+type NodeUsage struct {
+	CPU    float64 `json:"cpu"`           // CPU usage percentage
+	Memory float64 `json:"memory"`        // Memory usage percentage
+	GPU    float64 `json:"gpu,omitempty"` // GPU usage percentage
+	Disk   float64 `json:"disk"`          // Disk usage percentage
+}
+
+// DiskStats is the disk I/O a node reports: throughput in bytes/sec and the
+// average time per read or write
+type DiskStats struct {
+	ReadRate  int64         `json:"read_rate"`
+	WriteRate int64         `json:"write_rate"`
+	Latency   time.Duration `json:"latency"`
+}
+
+// ModelInfo is the information about a model the scheduler, the inference
+// engine and the model manager share
+type ModelInfo struct {
+	Name         string            `json:"name"`
+	Size         int64             `json:"size"`
+	Checksum     string            `json:"checksum"`
+	Locations    []string          `json:"locations"` // Node IDs that have this model
+	AccessCount  int64             `json:"access_count"`
+	LastAccessed time.Time         `json:"last_accessed"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+// Online reports whether the node takes work
+func (n *NodeInfo) Online() bool {
+	return n.Status == NodeStatusOnline
+}
+
+// Load returns the highest of the node's CPU, memory and GPU usage
+// percentages
+func (n *NodeInfo) Load() float64 {
+	return max(n.Usage.CPU, n.Usage.Memory, n.Usage.GPU)
+}
+
+// FreeMemory returns the memory in bytes the node has left
+func (n *NodeInfo) FreeMemory() int64 {
+	used := min(max(n.Usage.Memory, 0), 100)
+	return int64(float64(n.Capacity.Memory) * (100 - used) / 100)
+}
+
+// HasModel reports whether the node holds the named model
+func (n *NodeInfo) HasModel(name string) bool {
+	for _, model := range n.Models {
+		if model == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasReplicaOn reports whether the model is located on the node
+func (m *ModelInfo) HasReplicaOn(nodeID string) bool {
+	for _, location := range m.Locations {
+		if location == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeInfoFromNode returns the NodeInfo of a cluster node. Usage ratios
+// become percentages.
+func NodeInfoFromNode(node *Node) *NodeInfo {
+	info := &NodeInfo{
+		ID:       string(node.ID),
+		Address:  node.Address,
+		Status:   node.Status,
+		LastSeen: node.LastSeen,
+		Metadata: make(map[string]string, len(node.Metadata)),
+	}
+	if c := node.Capabilities; c != nil {
+		info.Capacity = NodeCapacity{
+			CPU:    int64(c.Hardware.CPU),
+			Memory: c.Hardware.Memory,
+			GPU:    int64(c.Hardware.GPU),
+			Disk:   c.Hardware.Storage,
+		}
+	}
+	if m := node.Metrics; m != nil {
+		info.Usage = NodeUsage{
+			CPU:    m.CPUUsage * 100,
+			Memory: m.MemoryUsage * 100,
+			GPU:    m.GPUUsage * 100,
+			Disk:   m.StorageUsage * 100,
+		}
+		info.ActiveRequests = m.ActiveTasks
+		info.QueuedRequests = m.QueueLength
+	}
+	for k, v := range node.Metadata {
+		if s, ok := v.(string); ok {
+			info.Metadata[k] = s
+		}
+	}
+	return info
+}
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeInfoFromNode(t *testing.T) {
+	info := NodeInfoFromNode(&Node{
+		ID:      "node-1",
+		Address: "10.0.0.1:11434",
+		Status:  NodeStatusOnline,
+		Capabilities: &NodeCapabilities{
+			Hardware: HardwareCapabilities{CPU: 8, Memory: 32 << 30, GPU: 1, Storage: 1 << 40},
+		},
+		Metrics:  &NodeMetrics{CPUUsage: 0.5, MemoryUsage: 0.25, GPUUsage: 0.75},
+		Metadata: map[string]interface{}{"zone": "eu-west-1a", "weight": 3},
+	})
+
+	assert.Equal(t, NodeCapacity{CPU: 8, Memory: 32 << 30, GPU: 1, Disk: 1 << 40}, info.Capacity)
+	assert.Equal(t, NodeUsage{CPU: 50, Memory: 25, GPU: 75}, info.Usage)
+	assert.Equal(t, map[string]string{"zone": "eu-west-1a"}, info.Metadata)
+	assert.True(t, info.Online())
+	assert.Equal(t, 75.0, info.Load())
+	assert.Equal(t, int64(24<<30), info.FreeMemory())
+
+	model := &ModelInfo{Name: "llama3", Locations: []string{"node-1"}}
+	assert.True(t, model.HasReplicaOn(info.ID))
+	assert.False(t, info.HasModel(model.Name))
+}
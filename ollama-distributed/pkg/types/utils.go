@@ -75,7 +75,7 @@ func validateID(id, prefix string) bool {
 // ValidateNodeStatus validates a node status
 func ValidateNodeStatus(status NodeStatus) bool {
 	switch status {
-	case NodeStatusOnline, NodeStatusOffline, NodeStatusDraining, NodeStatusMaintenance:
+	case NodeStatusOnline, NodeStatusOffline, NodeStatusDraining, NodeStatusMaintenance, NodeStatusQuarantined:
 		return true
 	default:
 		return false
@@ -0,0 +1,162 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+)
+
+// Querier reads the sampled metrics back; the history Recorder is one
+type Querier interface {
+	Query(ctx context.Context, q history.Query) (*history.Result, error)
+	// Interval is how often the metrics are sampled
+	Interval() time.Duration
+}
+
+// TrendPoint is the usage of a model over a history bucket
+type TrendPoint struct {
+	Time              time.Time `json:"time"`
+	RequestsPerSecond float64   `json:"requests_per_second"`
+	ErrorsPerSecond   float64   `json:"errors_per_second"`
+	TokensPerSecond   float64   `json:"tokens_per_second"`
+	LatencyMs         float64   `json:"latency_ms,omitempty"`
+	LatencyP95Ms      float64   `json:"latency_p95_ms,omitempty"`
+}
+
+// ModelUsage is the usage of a model over a window
+type ModelUsage struct {
+	Model         string  `json:"model"`
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	UniqueTenants int     `json:"unique_tenants"`
+	// AvgLatencyMs and P95LatencyMs weigh the buckets of the window by
+	// their requests; the p95 is that of the buckets, not of the requests
+	// of the whole window
+	AvgLatencyMs    float64      `json:"avg_latency_ms"`
+	P95LatencyMs    float64      `json:"p95_latency_ms"`
+	Tokens          int64        `json:"tokens"`
+	TokensPerSecond float64      `json:"tokens_per_second"`
+	Trend           []TrendPoint `json:"trend"`
+}
+
+// Report is the usage of every model over a window, most requested first
+type Report struct {
+	Since      time.Time     `json:"since"`
+	Until      time.Time     `json:"until"`
+	Resolution time.Duration `json:"resolution"`
+	Models     []ModelUsage  `json:"models"`
+}
+
+// Summarize reports the usage of every model over [since, until) from the
+// metrics a Tracker sampled, with at most maxPoints trend points per model
+func Summarize(ctx context.Context, q Querier, since, until time.Time, maxPoints int) (*Report, error) {
+	metrics := []string{MetricRequests, MetricErrors, MetricTokens, MetricLatency, MetricLatencyP95, MetricTenantRequests}
+	results := make(map[string]*history.Result, len(metrics))
+	for _, metric := range metrics {
+		result, err := q.Query(ctx, history.Query{Metric: metric, Since: since, Until: until, MaxPoints: maxPoints})
+		if err != nil {
+			return nil, err
+		}
+		results[metric] = result
+	}
+	interval := q.Interval().Seconds()
+
+	tenants := make(map[string]int)
+	for _, series := range results[MetricTenantRequests].Series {
+		for _, p := range series.Points {
+			if p.Mean > 0 {
+				model, _ := splitKey(series.Key)
+				tenants[model]++
+				break
+			}
+		}
+	}
+
+	errorPoints := pointsByTime(results[MetricErrors])
+	tokenPoints := pointsByTime(results[MetricTokens])
+	latencyPoints := pointsByTime(results[MetricLatency])
+	p95Points := pointsByTime(results[MetricLatencyP95])
+
+	requests := results[MetricRequests]
+	report := &Report{
+		Since:      requests.Since,
+		Until:      requests.Until,
+		Resolution: requests.Resolution,
+		Models:     make([]ModelUsage, 0, len(requests.Series)),
+	}
+	for _, series := range requests.Series {
+		model := series.Key
+		usage := ModelUsage{Model: model, UniqueTenants: tenants[model], Trend: make([]TrendPoint, 0, len(series.Points))}
+
+		var requestCount, errorCount, tokenCount, seconds, latencySum, p95Sum, latencyWeight float64
+		for _, p := range series.Points {
+			point := TrendPoint{Time: p.Time, RequestsPerSecond: p.Mean}
+			bucketRequests := total(p, interval)
+			requestCount += bucketRequests
+			seconds += float64(p.Count) * interval
+
+			if e, ok := errorPoints[model][p.Time.UnixNano()]; ok {
+				point.ErrorsPerSecond = e.Mean
+				errorCount += total(e, interval)
+			}
+			if t, ok := tokenPoints[model][p.Time.UnixNano()]; ok {
+				point.TokensPerSecond = t.Mean
+				tokenCount += total(t, interval)
+			}
+			if l, ok := latencyPoints[model][p.Time.UnixNano()]; ok {
+				point.LatencyMs = l.Mean
+				latencySum += l.Mean * bucketRequests
+				latencyWeight += bucketRequests
+				if h, ok := p95Points[model][p.Time.UnixNano()]; ok {
+					point.LatencyP95Ms = h.Mean
+					p95Sum += h.Mean * bucketRequests
+				}
+			}
+			usage.Trend = append(usage.Trend, point)
+		}
+
+		usage.Requests = int64(math.Round(requestCount))
+		usage.Errors = int64(math.Round(errorCount))
+		usage.Tokens = int64(math.Round(tokenCount))
+		if usage.Requests > 0 {
+			usage.ErrorRate = float64(usage.Errors) / float64(usage.Requests)
+		}
+		if latencyWeight > 0 {
+			usage.AvgLatencyMs = latencySum / latencyWeight
+			usage.P95LatencyMs = p95Sum / latencyWeight
+		}
+		if seconds > 0 {
+			usage.TokensPerSecond = tokenCount / seconds
+		}
+		report.Models = append(report.Models, usage)
+	}
+
+	sort.SliceStable(report.Models, func(i, j int) bool {
+		return report.Models[i].Requests > report.Models[j].Requests
+	})
+	return report, nil
+}
+
+// total returns the number of events in the bucket of a rate point: its
+// mean rate over the seconds it was sampled
+func total(p history.Point, interval float64) float64 {
+	return p.Mean * float64(p.Count) * interval
+}
+
+// pointsByTime indexes the points of a result by key and time, in Unix
+// nanoseconds as stores may return times in other locations
+func pointsByTime(result *history.Result) map[string]map[int64]history.Point {
+	points := make(map[string]map[int64]history.Point, len(result.Series))
+	for _, series := range result.Series {
+		byTime := make(map[int64]history.Point, len(series.Points))
+		for _, p := range series.Points {
+			byTime[p.Time.UnixNano()] = p
+		}
+		points[series.Key] = byTime
+	}
+	return points
+}
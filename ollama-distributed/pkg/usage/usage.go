@@ -0,0 +1,174 @@
+// Package usage measures how each model is used: the requests it serves,
+// the tenants sending them, their latency, the tokens generated and the
+// errors. A Tracker samples these measures into the metrics history, and
+// Summarize reads them back as per-model analytics over any window the
+// history retains.
+package usage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+)
+
+// Metrics sampled into the history, keyed by model
+const (
+	MetricRequests   = "model_requests_per_second"
+	MetricErrors     = "model_errors_per_second"
+	MetricTokens     = "model_tokens_per_second"
+	MetricLatency    = "model_latency_ms"
+	MetricLatencyP95 = "model_latency_p95_ms"
+	// MetricTenantRequests is keyed by the model and tenant, joined by
+	// KeySeparator
+	MetricTenantRequests = "model_tenant_requests_per_second"
+)
+
+// KeySeparator joins the model and tenant of a MetricTenantRequests key
+const KeySeparator = "|"
+
+// maxLatencies bounds the latencies kept per model between samples; the
+// most recent ones are kept
+const maxLatencies = 10000
+
+// Request is a served inference request
+type Request struct {
+	Model   string
+	Tenant  string
+	Latency time.Duration
+	// Tokens is the number of tokens generated
+	Tokens int
+	// Failed is set for requests the server failed to serve
+	Failed bool
+}
+
+// window accumulates the requests of a model between samples
+type window struct {
+	requests, errors, tokens int
+	latencies                []float64
+	next                     int
+	tenants                  map[string]int
+}
+
+func (w *window) add(r Request) {
+	w.requests++
+	w.tokens += r.Tokens
+	if r.Failed {
+		w.errors++
+	}
+	latency := float64(r.Latency) / float64(time.Millisecond)
+	if len(w.latencies) < maxLatencies {
+		w.latencies = append(w.latencies, latency)
+	} else {
+		w.latencies[w.next] = latency
+		w.next = (w.next + 1) % maxLatencies
+	}
+	if r.Tenant != "" {
+		if w.tenants == nil {
+			w.tenants = make(map[string]int)
+		}
+		w.tenants[r.Tenant]++
+	}
+}
+
+// Tracker accumulates the requests of every model until the history
+// samples them
+type Tracker struct {
+	mu     sync.Mutex
+	models map[string]*window
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTracker creates a tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		models: make(map[string]*window),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Observe records a served request. Requests without a model are ignored.
+func (t *Tracker) Observe(r Request) {
+	if r.Model == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w := t.models[r.Model]
+	if w == nil {
+		w = &window{}
+		t.models[r.Model] = w
+	}
+	w.add(r)
+}
+
+// Source returns the history source of the tracker. Every sample holds
+// the rates of each model seen so far since the previous sample, which
+// drop to zero rather than stopping once a model goes idle, the mean and
+// 95th percentile latency of its requests since, if any, and the rate of
+// every tenant that sent some.
+func (t *Tracker) Source() history.Source {
+	return func() []history.Sample {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		now := t.now()
+		elapsed := now.Sub(t.last).Seconds()
+		t.last = now
+		if elapsed <= 0 {
+			return nil
+		}
+
+		var samples []history.Sample
+		for model, w := range t.models {
+			samples = append(samples,
+				history.Sample{Metric: MetricRequests, Key: model, Value: float64(w.requests) / elapsed},
+				history.Sample{Metric: MetricErrors, Key: model, Value: float64(w.errors) / elapsed},
+				history.Sample{Metric: MetricTokens, Key: model, Value: float64(w.tokens) / elapsed},
+			)
+			if len(w.latencies) > 0 {
+				mean, p95 := latencyStats(w.latencies)
+				samples = append(samples,
+					history.Sample{Metric: MetricLatency, Key: model, Value: mean},
+					history.Sample{Metric: MetricLatencyP95, Key: model, Value: p95},
+				)
+			}
+			for tenant, requests := range w.tenants {
+				samples = append(samples, history.Sample{
+					Metric: MetricTenantRequests,
+					Key:    model + KeySeparator + tenant,
+					Value:  float64(requests) / elapsed,
+				})
+			}
+			t.models[model] = &window{}
+		}
+		return samples
+	}
+}
+
+// latencyStats returns the mean and 95th percentile of latencies, which it
+// sorts
+func latencyStats(latencies []float64) (mean, p95 float64) {
+	sort.Float64s(latencies)
+	sum := 0.0
+	for _, l := range latencies {
+		sum += l
+	}
+	rank := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+	return sum / float64(len(latencies)), latencies[max(rank, 0)]
+}
+
+// splitKey splits a MetricTenantRequests key into its model and tenant
+func splitKey(key string) (model, tenant string) {
+	i := strings.LastIndex(key, KeySeparator)
+	if i < 0 {
+		return key, ""
+	}
+	return key[:i], key[i+len(KeySeparator):]
+}
@@ -0,0 +1,97 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerSource(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.last = clock
+	tracker.now = func() time.Time { return clock }
+	source := tracker.Source()
+
+	for i := 1; i <= 20; i++ {
+		tracker.Observe(Request{Model: "llama3", Tenant: "acme", Latency: time.Duration(i) * 10 * time.Millisecond, Tokens: 5, Failed: i == 20})
+	}
+	tracker.Observe(Request{Model: "llama3", Tenant: "globex", Latency: 100 * time.Millisecond})
+	tracker.Observe(Request{Latency: time.Second})
+	clock = clock.Add(10 * time.Second)
+
+	values := sampleValues(source())
+	assert.InDelta(t, 2.1, values[MetricRequests+"/llama3"], 1e-9)
+	assert.InDelta(t, 0.1, values[MetricErrors+"/llama3"], 1e-9)
+	assert.InDelta(t, 10.0, values[MetricTokens+"/llama3"], 1e-9)
+	assert.InDelta(t, 2200.0/21, values[MetricLatency+"/llama3"], 1e-9)
+	assert.Equal(t, 190.0, values[MetricLatencyP95+"/llama3"])
+	assert.InDelta(t, 2.0, values[MetricTenantRequests+"/llama3|acme"], 1e-9)
+	assert.InDelta(t, 0.1, values[MetricTenantRequests+"/llama3|globex"], 1e-9)
+	assert.Len(t, values, 7, "requests without a model are ignored")
+
+	// An idle model reports zero rates and no latency
+	clock = clock.Add(10 * time.Second)
+	values = sampleValues(source())
+	assert.Equal(t, map[string]float64{
+		MetricRequests + "/llama3": 0,
+		MetricErrors + "/llama3":   0,
+		MetricTokens + "/llama3":   0,
+	}, values)
+}
+
+func sampleValues(samples []history.Sample) map[string]float64 {
+	values := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		values[s.Metric+"/"+s.Key] = s.Value
+	}
+	return values
+}
+
+func TestSummarize(t *testing.T) {
+	ctx := context.Background()
+	recorder := history.NewRecorder(history.Config{Interval: time.Minute, Tiers: []history.Tier{
+		{Resolution: time.Minute, Retention: 24 * time.Hour},
+	}}, history.NewMemoryStore())
+	tracker := NewTracker()
+	tracker.last = time.Now().Add(-time.Minute)
+	recorder.AddSource(tracker.Source())
+
+	for i := 0; i < 60; i++ {
+		tracker.Observe(Request{Model: "llama3", Tenant: "acme", Latency: 200 * time.Millisecond, Tokens: 10, Failed: i < 6})
+	}
+	for i := 0; i < 30; i++ {
+		tracker.Observe(Request{Model: "mistral", Tenant: []string{"acme", "globex", "initech"}[i%3], Latency: 50 * time.Millisecond, Tokens: 2})
+	}
+	tracker.Observe(Request{Model: "phi3", Latency: 10 * time.Millisecond})
+	tracker.now = func() time.Time { return tracker.last.Add(time.Minute) }
+	require.NoError(t, recorder.Sample(ctx))
+
+	report, err := Summarize(ctx, recorder, time.Now().Add(-time.Hour), time.Now().Add(time.Minute), 0)
+	require.NoError(t, err)
+	require.Len(t, report.Models, 3)
+
+	llama := report.Models[0]
+	assert.Equal(t, "llama3", llama.Model)
+	assert.Equal(t, int64(60), llama.Requests)
+	assert.Equal(t, int64(6), llama.Errors)
+	assert.InDelta(t, 0.1, llama.ErrorRate, 1e-9)
+	assert.Equal(t, 1, llama.UniqueTenants)
+	assert.InDelta(t, 200.0, llama.AvgLatencyMs, 1e-9)
+	assert.InDelta(t, 200.0, llama.P95LatencyMs, 1e-9)
+	assert.Equal(t, int64(600), llama.Tokens)
+	assert.InDelta(t, 10.0, llama.TokensPerSecond, 1e-9)
+	require.Len(t, llama.Trend, 1)
+	assert.InDelta(t, 1.0, llama.Trend[0].RequestsPerSecond, 1e-9)
+
+	mistral := report.Models[1]
+	assert.Equal(t, "mistral", mistral.Model)
+	assert.Equal(t, int64(30), mistral.Requests)
+	assert.Equal(t, 3, mistral.UniqueTenants)
+	assert.Equal(t, "phi3", report.Models[2].Model)
+	assert.Equal(t, 0, report.Models[2].UniqueTenants)
+}
@@ -0,0 +1,239 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// Store keeps user accounts by username
+type Store interface {
+	Create(ctx context.Context, user *User) error
+	Get(ctx context.Context, username string) (*User, error)
+	// List returns the users ordered by username
+	List(ctx context.Context) ([]*User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, username string) error
+	Close() error
+}
+
+// OpenStore opens the store configured by cfg, migrating the database of a
+// database store
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.Store {
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	case "", StoreDatabase:
+		db := cfg.Database
+		manager, err := database.NewManager(&db)
+		if err != nil {
+			return nil, err
+		}
+		if err := manager.RunMigrations(context.Background()); err != nil {
+			manager.Close()
+			return nil, err
+		}
+		return NewDatabaseStore(manager), nil
+	default:
+		return nil, fmt.Errorf("unknown user store %q", cfg.Store)
+	}
+}
+
+// MemoryStore keeps users in memory, for single-node and test setups
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[string]*User)}
+}
+
+// Create adds a user
+func (s *MemoryStore) Create(_ context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.Username]; exists {
+		return ErrExists
+	}
+	s.users[user.Username] = user.clone()
+	return nil
+}
+
+// Get returns a user
+func (s *MemoryStore) Get(_ context.Context, username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return user.clone(), nil
+}
+
+// List returns the users ordered by username
+func (s *MemoryStore) List(_ context.Context) ([]*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.clone())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// Update replaces a user
+func (s *MemoryStore) Update(_ context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; !ok {
+		return ErrNotFound
+	}
+	s.users[user.Username] = user.clone()
+	return nil
+}
+
+// Delete removes a user
+func (s *MemoryStore) Delete(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, username)
+	return nil
+}
+
+// Close does nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// DatabaseStore keeps users in the users table
+type DatabaseStore struct {
+	db *database.Manager
+}
+
+// NewDatabaseStore creates a store on db, whose migrations must have run
+func NewDatabaseStore(db *database.Manager) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Create inserts a user
+func (s *DatabaseStore) Create(ctx context.Context, user *User) error {
+	row, err := s.db.CreateUser(ctx, toRow(user))
+	if errors.Is(err, database.ErrUserExists) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	user.ID = row.ID
+	return nil
+}
+
+// Get returns a user
+func (s *DatabaseStore) Get(ctx context.Context, username string) (*User, error) {
+	row, err := s.db.GetUserByUsername(ctx, username)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromRow(row), nil
+}
+
+// List returns the users ordered by username
+func (s *DatabaseStore) List(ctx context.Context) ([]*User, error) {
+	rows, err := s.db.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*User, len(rows))
+	for i, row := range rows {
+		users[i] = fromRow(row)
+	}
+	return users, nil
+}
+
+// Update stores a user's changes
+func (s *DatabaseStore) Update(ctx context.Context, user *User) error {
+	err := s.db.UpdateUser(ctx, toRow(user))
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		return ErrNotFound
+	case errors.Is(err, database.ErrUserExists):
+		return ErrExists
+	}
+	return err
+}
+
+// Delete removes a user
+func (s *DatabaseStore) Delete(ctx context.Context, username string) error {
+	user, err := s.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteUser(ctx, user.ID); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *DatabaseStore) Close() error {
+	return s.db.Close()
+}
+
+func toRow(user *User) *database.User {
+	return &database.User{
+		ID:           user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		PasswordHash: user.PasswordHash,
+		Role:         legacyRole(user.Roles),
+		Roles:        user.Roles,
+		IsActive:     !user.Disabled,
+		LastLogin:    user.LastLogin,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}
+}
+
+func fromRow(row *database.User) *User {
+	return &User{
+		ID:           row.ID,
+		Username:     row.Username,
+		Email:        row.Email,
+		Roles:        row.Roles,
+		Disabled:     !row.IsActive,
+		PasswordHash: row.PasswordHash,
+		LastLogin:    row.LastLogin,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+	}
+}
+
+// legacyRole returns the value of the single role column, which only allows
+// a few roles: admin for admins, user for everyone else
+func legacyRole(roles []string) string {
+	if hasRole(roles, RoleAdmin) {
+		return RoleAdmin
+	}
+	return "user"
+}
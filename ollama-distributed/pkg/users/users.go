@@ -0,0 +1,403 @@
+// Package users manages the local accounts that log in to the dashboard and
+// the API: creating them, assigning their RBAC roles, disabling them and
+// resetting their passwords. Passwords are kept as bcrypt or argon2id
+// hashes, in memory or in the database.
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// Store kinds
+const (
+	StoreMemory   = "memory"
+	StoreDatabase = "database"
+)
+
+// Password hash algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// RoleAdmin is the role allowed to manage users
+const RoleAdmin = "admin"
+
+// Defaults of the account settings
+const (
+	DefaultMinPasswordLength = 12
+	DefaultAdminUsername     = "admin"
+)
+
+// AdminPasswordEnv names the environment variable holding the password of
+// the admin created in an empty store
+const AdminPasswordEnv = "OLLAMA_ADMIN_PASSWORD"
+
+var (
+	ErrNotFound = errors.New("user not found")
+	ErrExists   = errors.New("user already exists")
+	// ErrInvalid wraps the reasons a user or password is rejected
+	ErrInvalid = errors.New("invalid user")
+	// ErrLastAdmin is returned for changes that would leave no enabled
+	// admin to manage the others
+	ErrLastAdmin = errors.New("cannot remove the last enabled admin")
+	// ErrInvalidCredentials is returned for unknown and disabled users and
+	// wrong passwords alike
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,49}$`)
+	rolePattern     = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+)
+
+// Config configures local accounts
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is where accounts are kept: database, the default, or memory,
+	// which loses the accounts and their passwords on restart
+	Store    string          `yaml:"store"`
+	Database database.Config `yaml:"database"`
+	// Hash is the algorithm new passwords are hashed with: bcrypt or
+	// argon2id. Existing hashes of either kind keep working.
+	Hash              string `yaml:"hash"`
+	MinPasswordLength int    `yaml:"min_password_length" mapstructure:"min_password_length"`
+	// AdminUsername is the admin created when the store has no users. Its
+	// password is read from OLLAMA_ADMIN_PASSWORD, or generated and saved
+	// to a file only the node's user can read.
+	AdminUsername string `yaml:"admin_username" mapstructure:"admin_username"`
+}
+
+// Validate checks the store, hash and password length
+func (c Config) Validate() error {
+	switch c.Store {
+	case "", StoreMemory, StoreDatabase:
+	default:
+		return fmt.Errorf("unknown user store %q", c.Store)
+	}
+	switch c.Hash {
+	case "", HashBcrypt, HashArgon2id:
+	default:
+		return fmt.Errorf("unknown password hash %q", c.Hash)
+	}
+	if c.MinPasswordLength < 0 {
+		return fmt.Errorf("min password length must not be negative")
+	}
+	if c.AdminUsername != "" && !usernamePattern.MatchString(c.AdminUsername) {
+		return fmt.Errorf("invalid admin username %q", c.AdminUsername)
+	}
+	return nil
+}
+
+// WithDefaults returns the config with its unset values defaulted
+func (c Config) WithDefaults() Config {
+	if c.Store == "" {
+		c.Store = StoreDatabase
+	}
+	if c.Hash == "" {
+		c.Hash = HashBcrypt
+	}
+	if c.MinPasswordLength == 0 {
+		c.MinPasswordLength = DefaultMinPasswordLength
+	}
+	if c.AdminUsername == "" {
+		c.AdminUsername = DefaultAdminUsername
+	}
+	return c
+}
+
+// User is a local account
+type User struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	Email        string     `json:"email,omitempty"`
+	Roles        []string   `json:"roles"`
+	Disabled     bool       `json:"disabled"`
+	PasswordHash string     `json:"-"`
+	LastLogin    *time.Time `json:"last_login,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (u *User) clone() *User {
+	c := *u
+	c.Roles = append([]string(nil), u.Roles...)
+	if u.LastLogin != nil {
+		at := *u.LastLogin
+		c.LastLogin = &at
+	}
+	return &c
+}
+
+func (u *User) isEnabledAdmin() bool {
+	return !u.Disabled && hasRole(u.Roles, RoleAdmin)
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// NewUser describes an account to create
+type NewUser struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Email    string   `json:"email"`
+	Roles    []string `json:"roles"`
+}
+
+// Change describes changes to an account; nil fields are left as they are
+type Change struct {
+	Email    *string  `json:"email"`
+	Roles    []string `json:"roles"`
+	Disabled *bool    `json:"disabled"`
+}
+
+// Directory manages the accounts of a store. It authenticates dashboard
+// logins as a session.Authenticator.
+type Directory struct {
+	store Store
+	cfg   Config
+	now   func() time.Time
+
+	// mu serializes changes, so that two of them cannot both remove an
+	// admin believing the other one remains
+	mu sync.Mutex
+
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+// NewDirectory creates a directory of the accounts in store
+func NewDirectory(store Store, cfg Config) *Directory {
+	return &Directory{store: store, cfg: cfg.WithDefaults(), now: time.Now}
+}
+
+// Close closes the store
+func (d *Directory) Close() error {
+	return d.store.Close()
+}
+
+// Bootstrap creates the admin account when the store has no users, so
+// that someone can log in to create the others. It returns the password
+// when it was generated rather than read from OLLAMA_ADMIN_PASSWORD.
+func (d *Directory) Bootstrap(ctx context.Context) (string, error) {
+	existing, err := d.store.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(existing) > 0 {
+		return "", nil
+	}
+
+	password, generated := os.Getenv(AdminPasswordEnv), ""
+	if password == "" {
+		if password, err = generatePassword(); err != nil {
+			return "", err
+		}
+		generated = password
+	}
+	_, err = d.Create(ctx, NewUser{Username: d.cfg.AdminUsername, Password: password, Roles: []string{RoleAdmin}})
+	if err != nil {
+		return "", fmt.Errorf("failed to create admin %s: %w", d.cfg.AdminUsername, err)
+	}
+	return generated, nil
+}
+
+// List returns the accounts ordered by username
+func (d *Directory) List(ctx context.Context) ([]*User, error) {
+	return d.store.List(ctx)
+}
+
+// Get returns an account
+func (d *Directory) Get(ctx context.Context, username string) (*User, error) {
+	return d.store.Get(ctx, username)
+}
+
+// Create adds an account. Users without roles get the user role.
+func (d *Directory) Create(ctx context.Context, req NewUser) (*User, error) {
+	if !usernamePattern.MatchString(req.Username) {
+		return nil, fmt.Errorf("%w: usernames are up to 50 letters, digits and . _ @ -", ErrInvalid)
+	}
+	roles, err := normalizeRoles(req.Roles)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := d.hash(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	user := &User{
+		ID:           uuid.New().String(),
+		Username:     req.Username,
+		Email:        req.Email,
+		Roles:        roles,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := d.store.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user.clone(), nil
+}
+
+// Update changes the email, roles or disabled flag of an account
+func (d *Directory) Update(ctx context.Context, username string, change Change) (*User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, err := d.store.Get(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	wasAdmin := user.isEnabledAdmin()
+	if change.Email != nil {
+		user.Email = *change.Email
+	}
+	if change.Roles != nil {
+		if user.Roles, err = normalizeRoles(change.Roles); err != nil {
+			return nil, err
+		}
+	}
+	if change.Disabled != nil {
+		user.Disabled = *change.Disabled
+	}
+	if wasAdmin && !user.isEnabledAdmin() {
+		if err := d.checkOtherAdmin(ctx, username); err != nil {
+			return nil, err
+		}
+	}
+
+	user.UpdatedAt = d.now()
+	if err := d.store.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResetPassword replaces the password of an account
+func (d *Directory) ResetPassword(ctx context.Context, username, password string) error {
+	hash, err := d.hash(password)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	user, err := d.store.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = d.now()
+	return d.store.Update(ctx, user)
+}
+
+// Delete removes an account
+func (d *Directory) Delete(ctx context.Context, username string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, err := d.store.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user.isEnabledAdmin() {
+		if err := d.checkOtherAdmin(ctx, username); err != nil {
+			return err
+		}
+	}
+	return d.store.Delete(ctx, username)
+}
+
+// checkOtherAdmin returns ErrLastAdmin unless an enabled admin other than
+// username exists. The caller holds mu.
+func (d *Directory) checkOtherAdmin(ctx context.Context, username string) error {
+	users, err := d.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Username != username && user.isEnabledAdmin() {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+// Authenticate checks the password of an enabled account and returns its
+// roles. Unknown users are compared against a dummy hash, so they take as
+// long to reject.
+func (d *Directory) Authenticate(username, password string) ([]string, error) {
+	ctx := context.Background()
+	user, err := d.store.Get(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		d.dummyHashOnce.Do(func() {
+			d.dummyHash, _ = hashPassword(d.cfg.Hash, "dummy")
+		})
+		checkPassword(d.dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !checkPassword(user.PasswordHash, password) || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Recording the login is best effort; it must not lock users out
+	d.mu.Lock()
+	if current, err := d.store.Get(ctx, username); err == nil {
+		now := d.now()
+		current.LastLogin = &now
+		d.store.Update(ctx, current)
+	}
+	d.mu.Unlock()
+	return user.Roles, nil
+}
+
+// hash checks a new password's length and hashes it
+func (d *Directory) hash(password string) (string, error) {
+	if len(password) < d.cfg.MinPasswordLength {
+		return "", fmt.Errorf("%w: passwords need at least %d characters", ErrInvalid, d.cfg.MinPasswordLength)
+	}
+	if len(password) > 72 && d.cfg.Hash == HashBcrypt {
+		return "", fmt.Errorf("%w: bcrypt passwords are at most 72 bytes", ErrInvalid)
+	}
+	return hashPassword(d.cfg.Hash, password)
+}
+
+// normalizeRoles checks role names and drops duplicates
+func normalizeRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{"user"}, nil
+	}
+	seen := make(map[string]bool, len(roles))
+	result := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !rolePattern.MatchString(role) {
+			return nil, fmt.Errorf("%w: invalid role %q", ErrInvalid, role)
+		}
+		if !seen[role] {
+			seen[role] = true
+			result = append(result, role)
+		}
+	}
+	return result, nil
+}
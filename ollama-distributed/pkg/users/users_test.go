@@ -0,0 +1,100 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordHashes(t *testing.T) {
+	for _, algorithm := range []string{HashBcrypt, HashArgon2id} {
+		hash, err := hashPassword(algorithm, "correct horse")
+		require.NoError(t, err)
+		assert.True(t, checkPassword(hash, "correct horse"), algorithm)
+		assert.False(t, checkPassword(hash, "wrong horse"), algorithm)
+	}
+	assert.False(t, checkPassword("$argon2id$v=19$garbage", "correct horse"))
+}
+
+func TestDirectory(t *testing.T) {
+	ctx := context.Background()
+	d := NewDirectory(NewMemoryStore(), Config{MinPasswordLength: 8})
+	t.Setenv(AdminPasswordEnv, "bootstrap-pass")
+
+	generated, err := d.Bootstrap(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, generated, "the password came from the environment")
+	roles, err := d.Authenticate("admin", "bootstrap-pass")
+	require.NoError(t, err)
+	assert.Equal(t, []string{RoleAdmin}, roles)
+	admin, err := d.Get(ctx, "admin")
+	require.NoError(t, err)
+	assert.NotNil(t, admin.LastLogin)
+
+	_, err = d.Create(ctx, NewUser{Username: "bob", Password: "short"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = d.Create(ctx, NewUser{Username: "bad name", Password: "long enough"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	bob, err := d.Create(ctx, NewUser{Username: "bob", Password: "long enough", Roles: []string{"operator", "operator"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"operator"}, bob.Roles)
+	assert.NotEmpty(t, bob.ID)
+	_, err = d.Create(ctx, NewUser{Username: "bob", Password: "long enough"})
+	assert.ErrorIs(t, err, ErrExists)
+
+	_, err = d.Authenticate("bob", "wrong password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = d.Authenticate("nobody", "long enough")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	disabled := true
+	_, err = d.Update(ctx, "bob", Change{Disabled: &disabled})
+	require.NoError(t, err)
+	_, err = d.Authenticate("bob", "long enough")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "disabled")
+
+	require.NoError(t, d.ResetPassword(ctx, "admin", "new admin pass"))
+	_, err = d.Authenticate("admin", "bootstrap-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = d.Authenticate("admin", "new admin pass")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, d.ResetPassword(ctx, "nobody", "long enough"), ErrNotFound)
+}
+
+func TestLastAdmin(t *testing.T) {
+	ctx := context.Background()
+	d := NewDirectory(NewMemoryStore(), Config{Hash: HashArgon2id})
+	_, err := d.Create(ctx, NewUser{Username: "alice", Password: "alice-password", Roles: []string{RoleAdmin}})
+	require.NoError(t, err)
+
+	disabled := true
+	_, err = d.Update(ctx, "alice", Change{Disabled: &disabled})
+	assert.ErrorIs(t, err, ErrLastAdmin)
+	_, err = d.Update(ctx, "alice", Change{Roles: []string{"user"}})
+	assert.ErrorIs(t, err, ErrLastAdmin)
+	assert.ErrorIs(t, d.Delete(ctx, "alice"), ErrLastAdmin)
+
+	_, err = d.Create(ctx, NewUser{Username: "carol", Password: "carol-password", Roles: []string{RoleAdmin}})
+	require.NoError(t, err)
+	require.NoError(t, d.Delete(ctx, "alice"))
+	assert.ErrorIs(t, d.Delete(ctx, "alice"), ErrNotFound)
+
+	users, err := d.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Username)
+
+	generated, err := d.Bootstrap(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, generated, "the store has users")
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{Store: "redis"}.Validate())
+	assert.Error(t, Config{Hash: "md5"}.Validate())
+	assert.Error(t, Config{AdminUsername: "has space"}.Validate())
+	assert.Equal(t, StoreDatabase, Config{}.WithDefaults().Store, "accounts persist unless memory is asked for")
+}
@@ -0,0 +1,115 @@
+package vectorindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+)
+
+// ErrNoEndpoint is returned when no Ollama instance serves the model
+var ErrNoEndpoint = errors.New("no instance serves the embedding model")
+
+// Embedder turns texts into embeddings with a model
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// ClusterEmbedder embeds texts on the Ollama instances of the cluster that
+// serve the model, spreading successive calls over them and failing over
+// to the next instance when one fails
+type ClusterEmbedder struct {
+	HTTP *http.Client
+	// Endpoints returns the base URLs of the instances serving a model
+	Endpoints func(model string) []string
+	// Hedger, when set, also sends slow calls to the next instance and
+	// takes the first answer
+	Hedger *hedging.Hedger
+
+	next atomic.Uint64
+}
+
+// NewClusterEmbedder creates an embedder; timeouts come from the request
+// context
+func NewClusterEmbedder(endpoints func(model string) []string) *ClusterEmbedder {
+	return &ClusterEmbedder{HTTP: &http.Client{}, Endpoints: endpoints}
+}
+
+// Embed returns the embedding of each text, in order
+func (e *ClusterEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	endpoints := e.Endpoints(model)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoEndpoint, model)
+	}
+
+	start := int(e.next.Add(1) % uint64(len(endpoints)))
+	var errs []error
+	first := 0
+	if e.Hedger != nil && len(endpoints) > 1 {
+		embeddings, err := hedging.Do(ctx, e.Hedger, "embed", 2, func(ctx context.Context, target int) ([][]float32, error) {
+			return e.embed(ctx, endpoints[(start+target)%len(endpoints)], model, texts)
+		})
+		if err == nil || ctx.Err() != nil {
+			return embeddings, err
+		}
+		errs = append(errs, err)
+		first = 1
+	}
+	for i := first; i < len(endpoints); i++ {
+		endpoint := endpoints[(start+i)%len(endpoints)]
+		embeddings, err := e.embed(ctx, endpoint, model, texts)
+		if err == nil {
+			return embeddings, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// embed calls the batch embedding API of one Ollama instance
+func (e *ClusterEmbedder) embed(ctx context.Context, endpoint, model string, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(endpoint, "/") + "/api/embed"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d texts", url, len(result.Embeddings), len(texts))
+	}
+	return result.Embeddings, nil
+}
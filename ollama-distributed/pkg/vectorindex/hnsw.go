@@ -0,0 +1,454 @@
+package vectorindex
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HNSWIndex keeps a hierarchical navigable small world graph per collection
+// in memory. Deleted and replaced documents stay in the graph, skipped by
+// queries, until they make up half of it and the graph is rebuilt.
+type HNSWIndex struct {
+	path           string
+	m              int
+	efConstruction int
+	efSearch       int
+
+	mu     sync.RWMutex
+	graphs map[string]*graph
+}
+
+// snapshot is the file format of a saved HNSW index
+type snapshot struct {
+	Collections map[string][]Document `json:"collections"`
+}
+
+// OpenHNSW creates an HNSW index, loading the snapshot at cfg.Path if there
+// is one
+func OpenHNSW(cfg Config) (*HNSWIndex, error) {
+	if cfg.M <= 0 {
+		cfg.M = DefaultM
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = DefaultEfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = DefaultEfSearch
+	}
+	x := &HNSWIndex{
+		path:           cfg.Path,
+		m:              cfg.M,
+		efConstruction: cfg.EfConstruction,
+		efSearch:       cfg.EfSearch,
+		graphs:         make(map[string]*graph),
+	}
+	if cfg.Path == "" {
+		return x, nil
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return x, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid vector index snapshot %s: %w", cfg.Path, err)
+	}
+	for name, docs := range snap.Collections {
+		if err := x.Upsert(context.Background(), name, docs); err != nil {
+			return nil, fmt.Errorf("vector index snapshot %s: %w", cfg.Path, err)
+		}
+	}
+	return x, nil
+}
+
+// Upsert adds documents, replacing those with the same ID
+func (x *HNSWIndex) Upsert(_ context.Context, collection string, docs []Document) error {
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+	dims, err := validateDocuments(docs)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	g, ok := x.graphs[collection]
+	if !ok {
+		g = newGraph(dims, x.m, x.efConstruction)
+		x.graphs[collection] = g
+	} else if g.dims != dims {
+		return ErrDimensionMismatch
+	}
+	for _, doc := range docs {
+		g.insert(doc)
+	}
+	x.graphs[collection] = g.compacted()
+	return nil
+}
+
+// Query returns the k documents most similar to vector matching filter
+func (x *HNSWIndex) Query(_ context.Context, collection string, vector []float32, k int, filter map[string]string) ([]Match, error) {
+	if k <= 0 {
+		k = DefaultK
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	g, ok := x.graphs[collection]
+	if !ok {
+		return nil, ErrCollectionNotFound
+	}
+	if len(vector) != g.dims {
+		return nil, ErrDimensionMismatch
+	}
+	return g.search(normalize(vector), k, x.efSearch, filter), nil
+}
+
+// Delete removes documents and returns how many existed
+func (x *HNSWIndex) Delete(_ context.Context, collection string, ids []string) (int, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	g, ok := x.graphs[collection]
+	if !ok {
+		return 0, ErrCollectionNotFound
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if g.remove(id) {
+			deleted++
+		}
+	}
+	if len(g.ids) == 0 {
+		delete(x.graphs, collection)
+	} else {
+		x.graphs[collection] = g.compacted()
+	}
+	return deleted, nil
+}
+
+// Drop removes a collection
+func (x *HNSWIndex) Drop(_ context.Context, collection string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.graphs[collection]; !ok {
+		return ErrCollectionNotFound
+	}
+	delete(x.graphs, collection)
+	return nil
+}
+
+// Collections lists the collections sorted by name
+func (x *HNSWIndex) Collections(_ context.Context) ([]Collection, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	collections := make([]Collection, 0, len(x.graphs))
+	for name, g := range x.graphs {
+		collections = append(collections, Collection{Name: name, Dimensions: g.dims, Documents: len(g.ids)})
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+// Save writes the documents of every collection to the snapshot file,
+// replacing it atomically
+func (x *HNSWIndex) Save() error {
+	if x.path == "" {
+		return nil
+	}
+
+	x.mu.RLock()
+	snap := snapshot{Collections: make(map[string][]Document, len(x.graphs))}
+	for name, g := range x.graphs {
+		snap.Collections[name] = g.documents()
+	}
+	data, err := json.Marshal(snap)
+	x.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(x.path), filepath.Base(x.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), x.path)
+}
+
+// Close saves the snapshot
+func (x *HNSWIndex) Close() error {
+	return x.Save()
+}
+
+// graph is the HNSW graph of one collection
+type graph struct {
+	dims           int
+	m              int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+
+	nodes    []*node
+	ids      map[string]int32 // live documents
+	entry    int32
+	maxLevel int
+	deleted  int
+}
+
+// node is a document in the graph with its neighbors on each level
+type node struct {
+	doc     Document
+	vec     []float32 // normalized
+	friends [][]int32
+	deleted bool
+}
+
+func newGraph(dims, m, efConstruction int) *graph {
+	return &graph{
+		dims:           dims,
+		m:              m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		rng:            rand.New(rand.NewSource(1)),
+		ids:            make(map[string]int32),
+		entry:          -1,
+	}
+}
+
+// maxFriends is the number of neighbors kept per node on a level; the
+// bottom level, holding every node, keeps twice as many
+func (g *graph) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * g.m
+	}
+	return g.m
+}
+
+func (g *graph) distance(a []float32, id int32) float64 {
+	return 1 - dot(a, g.nodes[id].vec)
+}
+
+// insert adds a document, marking the one it replaces as deleted
+func (g *graph) insert(doc Document) {
+	g.remove(doc.ID)
+
+	n := &node{doc: doc, vec: normalize(doc.Vector)}
+	level := int(-math.Log(1-g.rng.Float64()) * g.levelMult)
+	n.friends = make([][]int32, level+1)
+	id := int32(len(g.nodes))
+	g.nodes = append(g.nodes, n)
+	g.ids[doc.ID] = id
+
+	if g.entry < 0 {
+		g.entry, g.maxLevel = id, level
+		return
+	}
+
+	entries := []int32{g.entry}
+	for l := g.maxLevel; l > level; l-- {
+		entries = []int32{g.searchLayer(n.vec, entries, 1, l)[0].id}
+	}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(n.vec, entries, g.efConstruction, l)
+		entries = entries[:0]
+		for _, c := range candidates {
+			entries = append(entries, c.id)
+		}
+
+		neighbors := entries
+		if len(neighbors) > g.m {
+			neighbors = neighbors[:g.m]
+		}
+		n.friends[l] = append([]int32(nil), neighbors...)
+		for _, nb := range neighbors {
+			friend := g.nodes[nb]
+			friend.friends[l] = append(friend.friends[l], id)
+			if len(friend.friends[l]) > g.maxFriends(l) {
+				g.prune(nb, l)
+			}
+		}
+	}
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = id, level
+	}
+}
+
+// prune keeps the closest neighbors of a node on a level
+func (g *graph) prune(id int32, level int) {
+	n := g.nodes[id]
+	friends := n.friends[level]
+	sort.Slice(friends, func(i, j int) bool {
+		return g.distance(n.vec, friends[i]) < g.distance(n.vec, friends[j])
+	})
+	n.friends[level] = friends[:g.maxFriends(level)]
+}
+
+// remove marks a live document as deleted
+func (g *graph) remove(docID string) bool {
+	id, ok := g.ids[docID]
+	if !ok {
+		return false
+	}
+	g.nodes[id].deleted = true
+	g.deleted++
+	delete(g.ids, docID)
+	return true
+}
+
+// compacted returns the graph rebuilt from its live documents once deleted
+// ones make up half of it, or the graph itself
+func (g *graph) compacted() *graph {
+	if g.deleted < 64 || 2*g.deleted < len(g.nodes) {
+		return g
+	}
+	rebuilt := newGraph(g.dims, g.m, g.efConstruction)
+	for _, doc := range g.documents() {
+		rebuilt.insert(doc)
+	}
+	return rebuilt
+}
+
+// documents returns the live documents in insertion order
+func (g *graph) documents() []Document {
+	docs := make([]Document, 0, len(g.ids))
+	for _, n := range g.nodes {
+		if !n.deleted {
+			docs = append(docs, n.doc)
+		}
+	}
+	return docs
+}
+
+// search returns the k live documents closest to the normalized query that
+// match filter. When the graph search finds too few, as with a selective
+// filter, the live documents are scanned instead.
+func (g *graph) search(q []float32, k, ef int, filter map[string]string) []Match {
+	if g.entry < 0 {
+		return nil
+	}
+
+	entries := []int32{g.entry}
+	for l := g.maxLevel; l > 0; l-- {
+		entries = []int32{g.searchLayer(q, entries, 1, l)[0].id}
+	}
+	matches := g.collect(g.searchLayer(q, entries, max(ef, k), 0), k, filter)
+	if len(matches) >= k || len(matches) >= len(g.ids) {
+		return matches
+	}
+
+	all := make([]candidate, 0, len(g.ids))
+	for _, id := range g.ids {
+		all = append(all, candidate{id: id, dist: g.distance(q, id)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].dist < all[j].dist })
+	return g.collect(all, k, filter)
+}
+
+// collect turns the closest live candidates matching filter into matches
+func (g *graph) collect(candidates []candidate, k int, filter map[string]string) []Match {
+	var matches []Match
+	for _, c := range candidates {
+		n := g.nodes[c.id]
+		if n.deleted || !matchesFilter(n.doc.Metadata, filter) {
+			continue
+		}
+		matches = append(matches, Match{ID: n.doc.ID, Score: 1 - c.dist, Text: n.doc.Text, Metadata: n.doc.Metadata})
+		if len(matches) == k {
+			break
+		}
+	}
+	return matches
+}
+
+// searchLayer returns up to ef nodes of a level closest to q, closest
+// first, searching greedily from the entry nodes
+func (g *graph) searchLayer(q []float32, entries []int32, ef, level int) []candidate {
+	visited := make(map[int32]bool, ef*4)
+	pending := &candidateHeap{}
+	found := &candidateHeap{farthest: true}
+	for _, id := range entries {
+		c := candidate{id: id, dist: g.distance(q, id)}
+		visited[id] = true
+		heap.Push(pending, c)
+		heap.Push(found, c)
+	}
+
+	for pending.Len() > 0 {
+		c := heap.Pop(pending).(candidate)
+		if found.Len() >= ef && c.dist > found.items[0].dist {
+			break
+		}
+		friends := g.nodes[c.id].friends
+		if level >= len(friends) {
+			continue
+		}
+		for _, nb := range friends[level] {
+			if visited[nb] {
+				continue
+			}
+			visited[nb] = true
+			d := g.distance(q, nb)
+			if found.Len() < ef || d < found.items[0].dist {
+				heap.Push(pending, candidate{id: nb, dist: d})
+				heap.Push(found, candidate{id: nb, dist: d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	result := found.items
+	sort.Slice(result, func(i, j int) bool { return result[i].dist < result[j].dist })
+	return result
+}
+
+// candidate is a node and its distance to a query
+type candidate struct {
+	id   int32
+	dist float64
+}
+
+// candidateHeap orders candidates closest first, or farthest first
+type candidateHeap struct {
+	items    []candidate
+	farthest bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].dist > h.items[j].dist
+	}
+	return h.items[i].dist < h.items[j].dist
+}
+func (h *candidateHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	old := h.items
+	c := old[len(old)-1]
+	h.items = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,210 @@
+package vectorindex
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+	"github.com/lib/pq"
+)
+
+// pgvectorSchema creates the documents table. It is kept out of the
+// database migrations because it needs the vector extension, which the
+// other users of the database do not.
+const pgvectorSchema = `
+	CREATE EXTENSION IF NOT EXISTS vector;
+
+	CREATE TABLE IF NOT EXISTS vector_documents (
+		collection VARCHAR(128) NOT NULL,
+		id TEXT NOT NULL,
+		embedding vector NOT NULL,
+		dimensions INTEGER NOT NULL,
+		text TEXT NOT NULL DEFAULT '',
+		metadata JSONB NOT NULL DEFAULT '{}',
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (collection, id)
+	);
+`
+
+// PgvectorIndex stores documents in Postgres with the pgvector extension.
+// Queries scan the collection exactly; an approximate index can be added
+// per collection once its dimensions are fixed.
+type PgvectorIndex struct {
+	db *database.Manager
+}
+
+// NewPgvectorIndex creates an index in db, creating its table if needed
+func NewPgvectorIndex(ctx context.Context, db *database.Manager) (*PgvectorIndex, error) {
+	if _, err := db.GetDB().ExecContext(ctx, pgvectorSchema); err != nil {
+		return nil, fmt.Errorf("failed to create vector_documents table: %w", err)
+	}
+	return &PgvectorIndex{db: db}, nil
+}
+
+// Upsert adds documents, replacing those with the same ID
+func (x *PgvectorIndex) Upsert(ctx context.Context, collection string, docs []Document) error {
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+	dims, err := validateDocuments(docs)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+
+	return x.db.ExecuteInTransaction(ctx, func(tx *sql.Tx) error {
+		var existing int
+		err := tx.QueryRowContext(ctx,
+			`SELECT dimensions FROM vector_documents WHERE collection = $1 LIMIT 1 FOR UPDATE`, collection).Scan(&existing)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && existing != dims {
+			return ErrDimensionMismatch
+		}
+
+		for _, doc := range docs {
+			metadata, err := json.Marshal(metadataOrEmpty(doc.Metadata))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO vector_documents (collection, id, embedding, dimensions, text, metadata)
+				VALUES ($1, $2, $3::vector, $4, $5, $6)
+				ON CONFLICT (collection, id) DO UPDATE SET
+					embedding = EXCLUDED.embedding, dimensions = EXCLUDED.dimensions,
+					text = EXCLUDED.text, metadata = EXCLUDED.metadata, updated_at = CURRENT_TIMESTAMP`,
+				collection, doc.ID, vectorLiteral(doc.Vector), dims, doc.Text, metadata); err != nil {
+				return fmt.Errorf("failed to upsert document %s: %w", doc.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Query returns the k documents most similar to vector matching filter
+func (x *PgvectorIndex) Query(ctx context.Context, collection string, vector []float32, k int, filter map[string]string) ([]Match, error) {
+	if k <= 0 {
+		k = DefaultK
+	}
+	var dims int
+	err := x.db.GetDB().QueryRowContext(ctx,
+		`SELECT dimensions FROM vector_documents WHERE collection = $1 LIMIT 1`, collection).Scan(&dims)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dims != len(vector) {
+		return nil, ErrDimensionMismatch
+	}
+
+	filterJSON, err := json.Marshal(metadataOrEmpty(filter))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := x.db.GetDB().QueryContext(ctx, `
+		SELECT id, text, metadata, 1 - (embedding <=> $2::vector) AS score
+		FROM vector_documents
+		WHERE collection = $1 AND metadata @> $3::jsonb
+		ORDER BY embedding <=> $2::vector
+		LIMIT $4`,
+		collection, vectorLiteral(vector), filterJSON, k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vector_documents: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		var metadata []byte
+		if err := rows.Scan(&m.ID, &m.Text, &metadata, &m.Score); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &m.Metadata); err != nil {
+			return nil, err
+		}
+		if len(m.Metadata) == 0 {
+			m.Metadata = nil
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// Delete removes documents and returns how many existed
+func (x *PgvectorIndex) Delete(ctx context.Context, collection string, ids []string) (int, error) {
+	result, err := x.db.GetDB().ExecContext(ctx,
+		`DELETE FROM vector_documents WHERE collection = $1 AND id = ANY($2)`, collection, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Drop removes a collection
+func (x *PgvectorIndex) Drop(ctx context.Context, collection string) error {
+	result, err := x.db.GetDB().ExecContext(ctx, `DELETE FROM vector_documents WHERE collection = $1`, collection)
+	if err != nil {
+		return fmt.Errorf("failed to drop collection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+// Collections lists the collections sorted by name
+func (x *PgvectorIndex) Collections(ctx context.Context) ([]Collection, error) {
+	rows, err := x.db.GetDB().QueryContext(ctx, `
+		SELECT collection, MAX(dimensions), COUNT(*)
+		FROM vector_documents
+		GROUP BY collection
+		ORDER BY collection`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.Name, &c.Dimensions, &c.Documents); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// Close closes the database connection
+func (x *PgvectorIndex) Close() error {
+	return x.db.Close()
+}
+
+// vectorLiteral formats a vector as pgvector's text input, e.g. [1,2.5,3]
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+func metadataOrEmpty(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
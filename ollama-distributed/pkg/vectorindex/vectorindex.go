@@ -0,0 +1,207 @@
+// Package vectorindex stores embeddings produced by the cluster and finds
+// the nearest ones to a query, so retrieval workflows do not need a
+// separate vector database. Two backends are available: an embedded HNSW
+// graph, snapshotted to a file, and pgvector in the cluster's Postgres
+// database. Similarity is cosine in both.
+package vectorindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/database"
+)
+
+// Backends
+const (
+	BackendHNSW     = "hnsw"
+	BackendPgvector = "pgvector"
+)
+
+// Defaults for unset HNSW parameters and query sizes
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 200
+	DefaultEfSearch       = 64
+	DefaultK              = 10
+)
+
+// maxCollectionName bounds collection names
+const maxCollectionName = 128
+
+var (
+	// ErrDimensionMismatch is returned for a vector whose length differs
+	// from the vectors already in its collection
+	ErrDimensionMismatch = errors.New("vector dimensions do not match the collection")
+	// ErrCollectionNotFound is returned for a collection without documents
+	ErrCollectionNotFound = errors.New("collection not found")
+)
+
+// Config configures the vector index
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Backend is hnsw (embedded, the default) or pgvector
+	Backend string `yaml:"backend"`
+	// Path is the file the HNSW index is loaded from and snapshotted to;
+	// empty keeps it in memory only
+	Path string `yaml:"path"`
+	// M is the number of neighbors of each HNSW node, EfConstruction and
+	// EfSearch the candidate list sizes when inserting and querying
+	M              int `yaml:"m"`
+	EfConstruction int `yaml:"ef_construction" mapstructure:"ef_construction"`
+	EfSearch       int `yaml:"ef_search" mapstructure:"ef_search"`
+
+	// Database is the Postgres database of the pgvector backend, which
+	// must have the vector extension available
+	Database database.Config `yaml:"database"`
+
+	// EmbeddingModel embeds documents and queries given as text when the
+	// request names no model
+	EmbeddingModel string `yaml:"embedding_model" mapstructure:"embedding_model"`
+}
+
+// Validate checks the backend and HNSW parameters
+func (c Config) Validate() error {
+	switch c.Backend {
+	case "", BackendHNSW, BackendPgvector:
+	default:
+		return fmt.Errorf("unknown vector index backend %q", c.Backend)
+	}
+	if c.M < 0 || c.EfConstruction < 0 || c.EfSearch < 0 {
+		return fmt.Errorf("negative HNSW parameter")
+	}
+	return nil
+}
+
+// Document is an embedded piece of text
+type Document struct {
+	ID       string            `json:"id"`
+	Vector   []float32         `json:"vector,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Match is a document found by a query, with its cosine similarity
+type Match struct {
+	ID       string            `json:"id"`
+	Score    float64           `json:"score"`
+	Text     string            `json:"text,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Collection describes a set of documents queried together
+type Collection struct {
+	Name       string `json:"name"`
+	Dimensions int    `json:"dimensions"`
+	Documents  int    `json:"documents"`
+}
+
+// Index stores documents in collections and finds the nearest ones
+type Index interface {
+	// Upsert adds documents, replacing those with the same ID
+	Upsert(ctx context.Context, collection string, docs []Document) error
+	// Query returns the k documents most similar to vector whose metadata
+	// contains every key and value of filter, most similar first
+	Query(ctx context.Context, collection string, vector []float32, k int, filter map[string]string) ([]Match, error)
+	// Delete removes documents and returns how many existed
+	Delete(ctx context.Context, collection string, ids []string) (int, error)
+	// Drop removes a collection
+	Drop(ctx context.Context, collection string) error
+	// Collections lists the collections sorted by name
+	Collections(ctx context.Context) ([]Collection, error)
+	Close() error
+}
+
+// Open opens the index configured by cfg
+func Open(ctx context.Context, cfg Config) (Index, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Backend {
+	case BackendPgvector:
+		db := cfg.Database
+		manager, err := database.NewManager(&db)
+		if err != nil {
+			return nil, err
+		}
+		index, err := NewPgvectorIndex(ctx, manager)
+		if err != nil {
+			manager.Close()
+			return nil, err
+		}
+		return index, nil
+	default:
+		return OpenHNSW(cfg)
+	}
+}
+
+// ValidateCollection checks a collection name
+func ValidateCollection(name string) error {
+	if name == "" || len(name) > maxCollectionName {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	return nil
+}
+
+// validateDocuments checks that the documents have IDs and vectors of the
+// same dimensions, and returns those dimensions
+func validateDocuments(docs []Document) (int, error) {
+	dims := 0
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return 0, fmt.Errorf("document without an id")
+		}
+		if len(doc.Vector) == 0 {
+			return 0, fmt.Errorf("document %s has no vector", doc.ID)
+		}
+		if dims == 0 {
+			dims = len(doc.Vector)
+		} else if len(doc.Vector) != dims {
+			return 0, fmt.Errorf("document %s: %w", doc.ID, ErrDimensionMismatch)
+		}
+	}
+	return dims, nil
+}
+
+// matchesFilter reports whether metadata contains every entry of filter
+func matchesFilter(metadata, filter map[string]string) bool {
+	for k, v := range filter {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns v scaled to unit length, so the dot product of two
+// normalized vectors is their cosine similarity
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if sum == 0 {
+		return out
+	}
+	norm := math.Sqrt(sum)
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
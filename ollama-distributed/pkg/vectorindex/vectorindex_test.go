@@ -0,0 +1,199 @@
+package vectorindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/hedging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomDocs(rng *rand.Rand, n, dims int) []Document {
+	docs := make([]Document, n)
+	for i := range docs {
+		vec := make([]float32, dims)
+		for j := range vec {
+			vec[j] = float32(rng.NormFloat64())
+		}
+		docs[i] = Document{ID: fmt.Sprint(i), Vector: vec, Metadata: map[string]string{"parity": fmt.Sprint(i % 2)}}
+	}
+	return docs
+}
+
+func TestHNSWRecall(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(7))
+	docs := randomDocs(rng, 2000, 16)
+	index, err := OpenHNSW(Config{})
+	require.NoError(t, err)
+	require.NoError(t, index.Upsert(ctx, "docs", docs))
+
+	// The graph search finds nearly all the exact nearest neighbors
+	hits, total := 0, 0
+	for q := 0; q < 20; q++ {
+		query := randomDocs(rng, 1, 16)[0].Vector
+		exact := make([]Match, len(docs))
+		for i, doc := range docs {
+			exact[i] = Match{ID: doc.ID, Score: dot(normalize(query), normalize(doc.Vector))}
+		}
+		sort.Slice(exact, func(i, j int) bool { return exact[i].Score > exact[j].Score })
+
+		matches, err := index.Query(ctx, "docs", query, 10, nil)
+		require.NoError(t, err)
+		require.Len(t, matches, 10)
+		found := make(map[string]bool)
+		for _, m := range matches {
+			found[m.ID] = true
+		}
+		for _, m := range exact[:10] {
+			if found[m.ID] {
+				hits++
+			}
+			total++
+		}
+	}
+	assert.Greater(t, float64(hits)/float64(total), 0.9)
+}
+
+func TestHNSWUpsertDeleteFilter(t *testing.T) {
+	ctx := context.Background()
+	index, err := OpenHNSW(Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, index.Upsert(ctx, "docs", []Document{
+		{ID: "a", Vector: []float32{1, 0}, Text: "east"},
+		{ID: "b", Vector: []float32{0, 1}, Text: "north", Metadata: map[string]string{"lang": "en"}},
+	}))
+	assert.ErrorIs(t, index.Upsert(ctx, "docs", []Document{{ID: "c", Vector: []float32{1, 0, 0}}}), ErrDimensionMismatch)
+
+	matches, err := index.Query(ctx, "docs", []float32{1, 0.1}, 1, nil)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "a", matches[0].ID)
+	assert.InDelta(t, 0.995, matches[0].Score, 0.001)
+
+	// The filter skips the closer document
+	matches, err = index.Query(ctx, "docs", []float32{1, 0.1}, 1, map[string]string{"lang": "en"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "b", matches[0].ID)
+
+	// Replacing a document moves it
+	require.NoError(t, index.Upsert(ctx, "docs", []Document{{ID: "a", Vector: []float32{-1, 0}}}))
+	matches, err = index.Query(ctx, "docs", []float32{1, 0.1}, 5, nil)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "b", matches[0].ID)
+
+	n, err := index.Delete(ctx, "docs", []string{"a", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	collections, err := index.Collections(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Collection{{Name: "docs", Dimensions: 2, Documents: 1}}, collections)
+
+	require.NoError(t, index.Drop(ctx, "docs"))
+	_, err = index.Query(ctx, "docs", []float32{1, 0}, 1, nil)
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+}
+
+func TestHNSWSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "index.json")
+	index, err := OpenHNSW(Config{Path: path})
+	require.NoError(t, err)
+	docs := randomDocs(rand.New(rand.NewSource(1)), 300, 8)
+	require.NoError(t, index.Upsert(ctx, "docs", docs))
+	_, err = index.Delete(ctx, "docs", []string{"0"})
+	require.NoError(t, err)
+	require.NoError(t, index.Close())
+
+	reopened, err := OpenHNSW(Config{Path: path})
+	require.NoError(t, err)
+	collections, err := reopened.Collections(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Collection{{Name: "docs", Dimensions: 8, Documents: 299}}, collections)
+
+	matches, err := reopened.Query(ctx, "docs", docs[5].Vector, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "5", matches[0].ID)
+}
+
+func TestClusterEmbedderFailover(t *testing.T) {
+	var calls int
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		embeddings := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			embeddings[i] = []float32{float32(len(text)), 1}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	embedder := NewClusterEmbedder(func(model string) []string {
+		return []string{broken.URL, healthy.URL}
+	})
+	for i := 0; i < 2; i++ {
+		embeddings, err := embedder.Embed(context.Background(), "nomic-embed-text", []string{"a", "abc"})
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{1, 1}, {3, 1}}, embeddings)
+	}
+	assert.Equal(t, 2, calls)
+
+	_, err := NewClusterEmbedder(func(string) []string { return nil }).Embed(context.Background(), "m", []string{"a"})
+	assert.ErrorIs(t, err, ErrNoEndpoint)
+}
+
+func TestClusterEmbedderHedging(t *testing.T) {
+	answer := func(w http.ResponseWriter) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": [][]float32{{1, 2}}})
+	}
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		answer(w)
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { answer(w) }))
+	defer fast.Close()
+
+	embedder := NewClusterEmbedder(func(model string) []string { return []string{slow.URL, fast.URL} })
+	embedder.Hedger = hedging.NewHedger(hedging.Config{MinDelay: time.Millisecond, MaxRate: 1})
+	// Successive calls start on alternate instances; the first starts on
+	// the fast one, the second is hedged to it
+	for i := 0; i < 2; i++ {
+		embeddings, err := embedder.Embed(context.Background(), "nomic-embed-text", []string{"a"})
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{1, 2}}, embeddings)
+	}
+	assert.Equal(t, int64(1), embedder.Hedger.Stats()[0].HedgeWins)
+}
+
+func TestVectorLiteral(t *testing.T) {
+	assert.Equal(t, "[1,-2.5,0.1]", vectorLiteral([]float32{1, -2.5, 0.1}))
+}
@@ -0,0 +1,78 @@
+package verification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaClient runs golden prompts against the Ollama API of a node
+type OllamaClient struct {
+	HTTP *http.Client
+}
+
+// NewOllamaClient creates a client; timeouts come from the request context
+func NewOllamaClient() *OllamaClient {
+	return &OllamaClient{HTTP: &http.Client{}}
+}
+
+// Generate runs the case's prompt without streaming and returns the answer
+func (c *OllamaClient) Generate(ctx context.Context, endpoint, model string, gc Case) (string, error) {
+	var resp struct {
+		Response string `json:"response"`
+	}
+	err := c.post(ctx, endpoint+"/api/generate", map[string]interface{}{
+		"model":   model,
+		"prompt":  gc.Prompt,
+		"system":  gc.System,
+		"format":  gc.Format,
+		"options": gc.Options,
+		"stream":  false,
+	}, &resp)
+	return resp.Response, err
+}
+
+// Embed returns the embedding of text
+func (c *OllamaClient) Embed(ctx context.Context, endpoint, model, text string) ([]float64, error) {
+	var resp struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := c.post(ctx, endpoint+"/api/embeddings", map[string]interface{}{
+		"model":  model,
+		"prompt": text,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embedding) == 0 {
+		return nil, fmt.Errorf("model %s returned no embedding", model)
+	}
+	return resp.Embedding, nil
+}
+
+func (c *OllamaClient) post(ctx context.Context, url string, body, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,136 @@
+package verification
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// validateSchema checks value against the subset of JSON schema golden
+// prompts need: type, enum, required, properties, additionalProperties,
+// items, minItems, maxItems, minimum and maximum. at is the JSON path of
+// value, used in errors.
+func validateSchema(schema map[string]interface{}, value interface{}, at string) error {
+	if want, ok := schema["type"].(string); ok && !hasType(value, want) {
+		return fmt.Errorf("%s: expected %s, got %s", at, want, typeName(value))
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if equalJSON(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, value, enum)
+		}
+	}
+
+	if n, ok := value.(float64); ok {
+		if min, ok := number(schema["minimum"]); ok && n < min {
+			return fmt.Errorf("%s: %v is less than %v", at, n, min)
+		}
+		if max, ok := number(schema["maximum"]); ok && n > max {
+			return fmt.Errorf("%s: %v is more than %v", at, n, max)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, field := range required {
+				if name, ok := field.(string); ok {
+					if _, present := v[name]; !present {
+						return fmt.Errorf("%s: missing required field %s", at, name)
+					}
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if sub, ok := properties[key].(map[string]interface{}); ok {
+				if err := validateSchema(sub, v[key], at+"."+key); err != nil {
+					return err
+				}
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				return fmt.Errorf("%s: unexpected field %s", at, key)
+			}
+		}
+	case []interface{}:
+		if min, ok := number(schema["minItems"]); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: %d items, fewer than %v", at, len(v), min)
+		}
+		if max, ok := number(schema["maxItems"]); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: %d items, more than %v", at, len(v), max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(value interface{}, want string) bool {
+	switch want {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeName(value) == want
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// number converts a schema number, which is an int when the schema comes
+// from YAML
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// equalJSON compares a schema value with a decoded JSON value
+func equalJSON(schemaValue, value interface{}) bool {
+	if n, ok := number(schemaValue); ok {
+		v, isNumber := value.(float64)
+		return isNumber && n == v
+	}
+	return reflect.DeepEqual(schemaValue, value)
+}
@@ -0,0 +1,419 @@
+// Package verification checks that models still answer golden prompts as
+// expected after they are upgraded or re-quantized. A suite declares, per
+// model, prompts with the characteristics their output must have (regular
+// expressions, a JSON schema, embedding similarity to a reference answer);
+// the verifier runs the suite on every node serving the model and reports
+// the deviations, so a new version can be held back before it is promoted.
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxReportedOutput caps the output kept in a result
+const maxReportedOutput = 2048
+
+// Config configures model verification
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Suite is the YAML file declaring the golden prompts
+	Suite string `yaml:"suite"`
+	// Concurrency is the number of prompts run at once across the cluster
+	Concurrency int `yaml:"concurrency"`
+	// Timeout bounds each prompt
+	Timeout time.Duration `yaml:"timeout"`
+	// CheckInterval is how often models are checked for a new version,
+	// which is then verified; zero only verifies on request
+	CheckInterval time.Duration `yaml:"check_interval" mapstructure:"check_interval"`
+}
+
+// Suite is the YAML document declaring golden prompts:
+//
+//	cases:
+//	  - name: capital
+//	    model: "llama3*"
+//	    prompt: "What is the capital of France? Answer in one word."
+//	    expect:
+//	      matches: ["(?i)paris"]
+//	      similarity: {reference: "Paris", threshold: 0.8}
+//	  - name: json
+//	    model: llama3
+//	    prompt: "Return {\"ok\": true} as JSON"
+//	    format: json
+//	    expect:
+//	      json_schema: {type: object, required: [ok]}
+type Suite struct {
+	Cases []Case `yaml:"cases" json:"cases"`
+}
+
+// Case is a golden prompt and the expected characteristics of its output
+type Case struct {
+	Name string `yaml:"name" json:"name"`
+	// Model is a path.Match pattern of the models the case applies to
+	Model   string                 `yaml:"model" json:"model"`
+	Prompt  string                 `yaml:"prompt" json:"prompt"`
+	System  string                 `yaml:"system" json:"system,omitempty"`
+	Format  string                 `yaml:"format" json:"format,omitempty"`
+	Options map[string]interface{} `yaml:"options" json:"options,omitempty"`
+	Expect  Expectation            `yaml:"expect" json:"expect"`
+}
+
+// Expectation describes the output of a golden prompt. Every set field must
+// hold.
+type Expectation struct {
+	// Matches are regular expressions the output must match, and
+	// NotMatches ones it must not
+	Matches    []string `yaml:"matches" json:"matches,omitempty"`
+	NotMatches []string `yaml:"not_matches" json:"not_matches,omitempty"`
+	// JSONSchema is a JSON schema the output, parsed as JSON, must satisfy
+	JSONSchema map[string]interface{} `yaml:"json_schema" json:"json_schema,omitempty"`
+	// Similarity bounds how far the output may drift from a reference
+	// answer
+	Similarity *Similarity `yaml:"similarity" json:"similarity,omitempty"`
+	// MaxLatency bounds how long the prompt may take
+	MaxLatency time.Duration `yaml:"max_latency" json:"max_latency,omitempty"`
+}
+
+// Similarity requires the cosine similarity between the embeddings of the
+// output and of Reference to be at least Threshold
+type Similarity struct {
+	Reference string  `yaml:"reference" json:"reference"`
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+	// Model computes the embeddings; empty uses the verified model
+	Model string `yaml:"model" json:"model,omitempty"`
+}
+
+// LoadSuite reads a suite from a YAML file
+func LoadSuite(file string) (*Suite, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification suite: %w", err)
+	}
+
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse verification suite %s: %w", file, err)
+	}
+	if err := suite.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid verification suite %s: %w", file, err)
+	}
+	return &suite, nil
+}
+
+// Validate checks the names, model patterns, expressions and thresholds of
+// every case
+func (s *Suite) Validate() error {
+	names := make(map[string]bool)
+	for _, c := range s.Cases {
+		if c.Name == "" || c.Model == "" || c.Prompt == "" {
+			return fmt.Errorf("cases need a name, model and prompt")
+		}
+		key := c.Model + "\x00" + c.Name
+		if names[key] {
+			return fmt.Errorf("duplicate case %s for model %s", c.Name, c.Model)
+		}
+		names[key] = true
+
+		if _, err := path.Match(c.Model, ""); err != nil {
+			return fmt.Errorf("case %s: invalid model pattern %q", c.Name, c.Model)
+		}
+		for _, expr := range append(append([]string(nil), c.Expect.Matches...), c.Expect.NotMatches...) {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("case %s: invalid expression %q: %w", c.Name, expr, err)
+			}
+		}
+		if sim := c.Expect.Similarity; sim != nil && (sim.Reference == "" || sim.Threshold <= 0 || sim.Threshold > 1) {
+			return fmt.Errorf("case %s: similarity needs a reference and a threshold in (0, 1]", c.Name)
+		}
+	}
+	return nil
+}
+
+// CasesFor returns the cases that apply to a model
+func (s *Suite) CasesFor(model string) []Case {
+	var cases []Case
+	for _, c := range s.Cases {
+		if ok, _ := path.Match(c.Model, model); ok {
+			cases = append(cases, c)
+		}
+	}
+	return cases
+}
+
+// Target is a node serving the verified model
+type Target struct {
+	NodeID   string `json:"node_id"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Client runs prompts on a node
+type Client interface {
+	Generate(ctx context.Context, endpoint, model string, c Case) (string, error)
+	Embed(ctx context.Context, endpoint, model, text string) ([]float64, error)
+}
+
+// Result is the outcome of a case on a node
+type Result struct {
+	Case       string        `json:"case"`
+	NodeID     string        `json:"node_id"`
+	Passed     bool          `json:"passed"`
+	Output     string        `json:"output,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	Similarity float64       `json:"similarity,omitempty"`
+	// Deviations are the expectations the output failed
+	Deviations []string `json:"deviations,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Report is the outcome of verifying a model across the cluster
+type Report struct {
+	Model string `json:"model"`
+	// Version identifies the verified model version, e.g. its checksum
+	Version   string        `json:"version,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Passed    bool          `json:"passed"`
+	Cases     int           `json:"cases"`
+	Nodes     int           `json:"nodes"`
+	Failed    int           `json:"failed"`
+	Results   []Result      `json:"results"`
+}
+
+// Verifier runs a suite on the nodes serving a model and keeps the latest
+// report of each model
+type Verifier struct {
+	client      Client
+	concurrency int
+	timeout     time.Duration
+
+	mu      sync.RWMutex
+	suite   *Suite
+	reports map[string]*Report
+}
+
+// NewVerifier creates a verifier running suite through client
+func NewVerifier(cfg Config, suite *Suite, client Client) (*Verifier, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Minute
+	}
+	if suite == nil {
+		suite = &Suite{}
+	}
+	if err := suite.Validate(); err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		client:      client,
+		concurrency: cfg.Concurrency,
+		timeout:     cfg.Timeout,
+		suite:       suite,
+		reports:     make(map[string]*Report),
+	}, nil
+}
+
+// Suite returns the suite being run
+func (v *Verifier) Suite() *Suite {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.suite
+}
+
+// SetSuite replaces the suite after validating it
+func (v *Verifier) SetSuite(suite *Suite) error {
+	if err := suite.Validate(); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.suite = suite
+	return nil
+}
+
+// Report returns the latest report of a model
+func (v *Verifier) Report(model string) (*Report, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	report, ok := v.reports[model]
+	return report, ok
+}
+
+// Verify runs the cases of the model's version on every target. A model
+// without cases passes trivially.
+func (v *Verifier) Verify(ctx context.Context, model, version string, targets []Target) *Report {
+	cases := v.Suite().CasesFor(model)
+	report := &Report{
+		Model:     model,
+		Version:   version,
+		StartedAt: time.Now(),
+		Cases:     len(cases),
+		Nodes:     len(targets),
+		Results:   make([]Result, len(cases)*len(targets)),
+	}
+
+	sem := make(chan struct{}, v.concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		for j, c := range cases {
+			wg.Add(1)
+			go func(slot int, target Target, c Case) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				report.Results[slot] = v.run(ctx, model, target, c)
+			}(i*len(cases)+j, target, c)
+		}
+	}
+	wg.Wait()
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		if report.Results[i].Case != report.Results[j].Case {
+			return report.Results[i].Case < report.Results[j].Case
+		}
+		return report.Results[i].NodeID < report.Results[j].NodeID
+	})
+	for _, result := range report.Results {
+		if !result.Passed {
+			report.Failed++
+		}
+	}
+	report.Passed = report.Failed == 0
+	report.Duration = time.Since(report.StartedAt)
+
+	v.mu.Lock()
+	v.reports[model] = report
+	v.mu.Unlock()
+	return report
+}
+
+// run checks a case on a target
+func (v *Verifier) run(ctx context.Context, model string, target Target, c Case) Result {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	result := Result{Case: c.Name, NodeID: target.NodeID}
+	start := time.Now()
+	output, err := v.client.Generate(ctx, target.Endpoint, model, c)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Output = output
+	if len(result.Output) > maxReportedOutput {
+		result.Output = result.Output[:maxReportedOutput] + "..."
+	}
+
+	expect := c.Expect
+	for _, expr := range expect.Matches {
+		if !regexp.MustCompile(expr).MatchString(output) {
+			result.Deviations = append(result.Deviations, fmt.Sprintf("output does not match %q", expr))
+		}
+	}
+	for _, expr := range expect.NotMatches {
+		if regexp.MustCompile(expr).MatchString(output) {
+			result.Deviations = append(result.Deviations, fmt.Sprintf("output matches %q", expr))
+		}
+	}
+	if expect.JSONSchema != nil {
+		var value interface{}
+		if err := json.Unmarshal([]byte(output), &value); err != nil {
+			result.Deviations = append(result.Deviations, "output is not JSON: "+err.Error())
+		} else if err := validateSchema(expect.JSONSchema, value, "$"); err != nil {
+			result.Deviations = append(result.Deviations, "output violates the JSON schema: "+err.Error())
+		}
+	}
+	if expect.MaxLatency > 0 && result.Latency > expect.MaxLatency {
+		result.Deviations = append(result.Deviations, fmt.Sprintf("took %s, more than %s", result.Latency.Round(time.Millisecond), expect.MaxLatency))
+	}
+	if sim := expect.Similarity; sim != nil {
+		score, err := v.similarity(ctx, target, model, sim, output)
+		if err != nil {
+			result.Error = "similarity: " + err.Error()
+			return result
+		}
+		result.Similarity = score
+		if score < sim.Threshold {
+			result.Deviations = append(result.Deviations, fmt.Sprintf("similarity %.3f below %.3f", score, sim.Threshold))
+		}
+	}
+
+	result.Passed = len(result.Deviations) == 0
+	return result
+}
+
+// similarity embeds the output and the reference answer and returns their
+// cosine similarity
+func (v *Verifier) similarity(ctx context.Context, target Target, model string, sim *Similarity, output string) (float64, error) {
+	if sim.Model != "" {
+		model = sim.Model
+	}
+	got, err := v.client.Embed(ctx, target.Endpoint, model, output)
+	if err != nil {
+		return 0, err
+	}
+	want, err := v.client.Embed(ctx, target.Endpoint, model, sim.Reference)
+	if err != nil {
+		return 0, err
+	}
+	return Cosine(got, want), nil
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 when they
+// differ in length or either is zero
+func Cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Watch verifies every model with cases whose version changes, checking
+// every interval until ctx is done. versions returns the current version of
+// each model, targets the nodes serving a model, and report receives each
+// report. The versions seen on the first check are the baseline.
+func (v *Verifier) Watch(ctx context.Context, interval time.Duration, versions func() map[string]string,
+	targets func(model string) []Target, report func(*Report)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := versions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for model, version := range versions() {
+			previous, known := seen[model]
+			seen[model] = version
+			if !known || previous == version || len(v.Suite().CasesFor(model)) == 0 {
+				continue
+			}
+			report(v.Verify(ctx, model, version, targets(model)))
+		}
+	}
+}
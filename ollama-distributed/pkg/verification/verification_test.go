@@ -0,0 +1,210 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient answers each endpoint with fixed outputs and embeds text as
+// letter counts
+type fakeClient struct {
+	outputs map[string]string // endpoint -> output
+}
+
+func (f *fakeClient) Generate(_ context.Context, endpoint, _ string, c Case) (string, error) {
+	output, ok := f.outputs[endpoint]
+	if !ok {
+		return "", errors.New("node unreachable")
+	}
+	return output, nil
+}
+
+func (f *fakeClient) Embed(_ context.Context, _, _, text string) ([]float64, error) {
+	vec := make([]float64, 26)
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' {
+			vec[r-'a']++
+		}
+	}
+	return vec, nil
+}
+
+const testSuite = `
+cases:
+  - name: capital
+    model: "llama3*"
+    prompt: What is the capital of France?
+    expect:
+      matches: ["(?i)paris"]
+      not_matches: ["(?i)london"]
+      similarity: {reference: "Paris", threshold: 0.9}
+  - name: json
+    model: llama3
+    prompt: Return a JSON object
+    format: json
+    expect:
+      json_schema:
+        type: object
+        required: [ok]
+        properties:
+          ok: {type: boolean}
+          tags: {type: array, items: {type: string}, maxItems: 2}
+  - name: other
+    model: mistral
+    prompt: Hi
+`
+
+func loadTestSuite(t *testing.T) *Suite {
+	file := filepath.Join(t.TempDir(), "suite.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(testSuite), 0o644))
+	suite, err := LoadSuite(file)
+	require.NoError(t, err)
+	return suite
+}
+
+func TestSuiteCasesFor(t *testing.T) {
+	suite := loadTestSuite(t)
+	assert.Len(t, suite.CasesFor("llama3"), 2)
+	assert.Len(t, suite.CasesFor("llama3-q4"), 1)
+	assert.Empty(t, suite.CasesFor("phi"))
+
+	bad := &Suite{Cases: []Case{{Name: "x", Model: "m", Prompt: "p", Expect: Expectation{Matches: []string{"("}}}}}
+	assert.Error(t, bad.Validate())
+	bad = &Suite{Cases: []Case{{Name: "x", Model: "m", Prompt: "p", Expect: Expectation{Similarity: &Similarity{Reference: "r", Threshold: 2}}}}}
+	assert.Error(t, bad.Validate())
+}
+
+func TestVerifyReportsDeviationsPerNode(t *testing.T) {
+	suite := &Suite{Cases: loadTestSuite(t).CasesFor("llama3")[:1]}
+	client := &fakeClient{outputs: map[string]string{
+		"http://a": "Paris",
+		"http://b": "London, I think",
+	}}
+	v, err := NewVerifier(Config{}, suite, client)
+	require.NoError(t, err)
+
+	report := v.Verify(context.Background(), "llama3", "sha256:new", []Target{
+		{NodeID: "a", Endpoint: "http://a"},
+		{NodeID: "b", Endpoint: "http://b"},
+		{NodeID: "c", Endpoint: "http://c"},
+	})
+	assert.False(t, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	require.Len(t, report.Results, 3)
+
+	a, b, c := report.Results[0], report.Results[1], report.Results[2]
+	assert.True(t, a.Passed)
+	assert.InDelta(t, 1.0, a.Similarity, 1e-9)
+	assert.False(t, b.Passed)
+	assert.Contains(t, b.Deviations, `output does not match "(?i)paris"`)
+	assert.Contains(t, b.Deviations, `output matches "(?i)london"`)
+	assert.Equal(t, "node unreachable", c.Error)
+
+	latest, ok := v.Report("llama3")
+	require.True(t, ok)
+	assert.Equal(t, "sha256:new", latest.Version)
+}
+
+func TestJSONSchemaExpectation(t *testing.T) {
+	suite := &Suite{Cases: loadTestSuite(t).CasesFor("llama3")[1:]}
+	for output, deviation := range map[string]string{
+		`{"ok": true, "tags": ["a"]}`:           "",
+		`{"tags": []}`:                          "missing required field ok",
+		`{"ok": "yes"}`:                         "$.ok: expected boolean, got string",
+		`{"ok": true, "tags": ["a", "b", "c"]}`: "3 items, more than 2",
+		`{"ok": true, "tags": [1]}`:             "$.tags[0]: expected string, got number",
+		`not json`:                              "output is not JSON",
+	} {
+		v, err := NewVerifier(Config{}, suite, &fakeClient{outputs: map[string]string{"http://a": output}})
+		require.NoError(t, err)
+		report := v.Verify(context.Background(), "llama3", "", []Target{{NodeID: "a", Endpoint: "http://a"}})
+		require.Len(t, report.Results, 1)
+		if deviation == "" {
+			assert.True(t, report.Passed, output)
+			continue
+		}
+		require.Len(t, report.Results[0].Deviations, 1, output)
+		assert.Contains(t, report.Results[0].Deviations[0], deviation, output)
+	}
+}
+
+func TestWatchVerifiesNewVersions(t *testing.T) {
+	v, err := NewVerifier(Config{}, loadTestSuite(t), &fakeClient{outputs: map[string]string{"http://a": "Paris"}})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	versions := map[string]string{"llama3-q4": "v1", "phi": "v1"}
+	reports := make(chan *Report, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go v.Watch(ctx, 5*time.Millisecond, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		result := make(map[string]string)
+		for k, val := range versions {
+			result[k] = val
+		}
+		return result
+	}, func(string) []Target {
+		return []Target{{NodeID: "a", Endpoint: "http://a"}}
+	}, func(r *Report) { reports <- r })
+
+	// Models are only verified once their version changes, and only if a
+	// case applies to them
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, reports)
+	mu.Lock()
+	versions["llama3-q4"] = "v2"
+	versions["phi"] = "v2"
+	mu.Unlock()
+
+	select {
+	case r := <-reports:
+		assert.Equal(t, "llama3-q4", r.Model)
+		assert.Equal(t, "v2", r.Version)
+		assert.True(t, r.Passed)
+	case <-time.After(time.Second):
+		t.Fatal("new version was not verified")
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, reports)
+}
+
+func TestOllamaClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/api/generate":
+			assert.Equal(t, false, body["stream"])
+			assert.Equal(t, "json", body["format"])
+			json.NewEncoder(w).Encode(map[string]string{"response": "hi " + body["model"].(string)})
+		case "/api/embeddings":
+			json.NewEncoder(w).Encode(map[string][]float64{"embedding": {1, 2}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient()
+	output, err := client.Generate(context.Background(), server.URL, "llama3", Case{Prompt: "hi", Format: "json"})
+	require.NoError(t, err)
+	assert.Equal(t, "hi llama3", output)
+
+	embedding, err := client.Embed(context.Background(), server.URL, "llama3", "hi")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, embedding)
+}
@@ -0,0 +1,182 @@
+package warmup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sharedDir is the directory inside the cache directory holding the
+// archives served to peers
+const sharedDir = ".shared"
+
+// ErrCacheNotFound is returned for caches this node does not have
+var ErrCacheNotFound = errors.New("compilation cache not found")
+
+// CacheEnv returns the environment pointing the compilation caches of the
+// CUDA and ROCm backends at dir
+func CacheEnv(dir string) []string {
+	return []string{
+		"CUDA_CACHE_PATH=" + filepath.Join(dir, "cuda"),
+		"CUDA_CACHE_MAXSIZE=4294967296",
+		"MIOPEN_USER_DB_PATH=" + filepath.Join(dir, "miopen"),
+		"MIOPEN_CUSTOM_CACHE_DIR=" + filepath.Join(dir, "miopen"),
+	}
+}
+
+// cacheFile is a file of a cache directory
+type cacheFile struct {
+	path string // relative, with forward slashes
+	size int64
+}
+
+// cacheFiles lists the regular files of a cache directory, without the
+// shared archives and files being written, and returns a digest of their names and sizes
+func cacheFiles(dir string) ([]cacheFile, string, error) {
+	var files []cacheFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == sharedDir {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, cacheFile{path: filepath.ToSlash(rel), size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s %d\n", f.path, f.size)
+	}
+	return files, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packCache writes the files of a cache directory to a gzipped tar archive,
+// failing if they exceed max bytes
+func packCache(dir string, files []cacheFile, w io.Writer, max int64) error {
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	if total > max {
+		return fmt.Errorf("compilation cache of %d bytes exceeds the limit of %d", total, max)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		if err := addFile(tw, dir, f); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addFile(tw *tar.Writer, dir string, f cacheFile) error {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(f.path)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tw.WriteHeader(&tar.Header{Name: f.path, Mode: 0o644, Size: f.size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, file, f.size)
+	return err
+}
+
+// mergeCache extracts the files of an archive missing from a cache
+// directory, reading at most max bytes of content, and returns how many
+// were added
+func mergeCache(r io.Reader, dir string, max int64) (int, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache archive: %w", err)
+	}
+	defer gz.Close()
+
+	added := 0
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return added, nil
+		}
+		if err != nil {
+			return added, fmt.Errorf("invalid cache archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) || strings.HasPrefix(name, sharedDir) {
+			return added, fmt.Errorf("invalid cache archive entry %q", header.Name)
+		}
+		if total += header.Size; total > max {
+			return added, fmt.Errorf("compilation cache archive exceeds the limit of %d bytes", max)
+		}
+
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := writeFile(path, tr); err != nil {
+			return added, err
+		}
+		added++
+	}
+}
+
+// writeFile writes a file atomically, creating its directory
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,68 @@
+package warmup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Profile describes the hardware and software compilation caches depend
+// on. Caches are only shared between nodes with the same profile.
+type Profile struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// GPUs are the models and compute capabilities of the GPUs, sorted
+	GPUs   []string `json:"gpus,omitempty"`
+	Driver string   `json:"driver,omitempty"`
+	// Backend is the version of the inference server
+	Backend string `json:"backend,omitempty"`
+}
+
+// Fingerprint identifies the profile
+func (p Profile) Fingerprint() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// DetectProfile describes this host and the inference server at endpoint.
+// GPUs are listed with nvidia-smi when it is installed.
+func DetectProfile(ctx context.Context, endpoint string) Profile {
+	profile := Profile{OS: runtime.GOOS, Arch: runtime.GOARCH}
+
+	output, err := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=name,compute_cap,driver_version", "--format=csv,noheader").Output()
+	if err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Split(line, ",")
+			if len(fields) != 3 {
+				continue
+			}
+			profile.GPUs = append(profile.GPUs, strings.TrimSpace(fields[0])+" "+strings.TrimSpace(fields[1]))
+			profile.Driver = strings.TrimSpace(fields[2])
+		}
+		sort.Strings(profile.GPUs)
+	}
+
+	if endpoint != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/api/version", nil)
+		if err == nil {
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				var version struct {
+					Version string `json:"version"`
+				}
+				if json.NewDecoder(resp.Body).Decode(&version) == nil {
+					profile.Backend = version.Version
+				}
+				resp.Body.Close()
+			}
+		}
+	}
+	return profile
+}
@@ -0,0 +1,257 @@
+package warmup
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxResults is how many warm-up results are kept
+const maxResults = 50
+
+// Peers fetches blobs from the other nodes
+type Peers interface {
+	FetchBlob(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Result describes a warm-up
+type Result struct {
+	Model    string        `json:"model"`
+	Endpoint string        `json:"endpoint"`
+	Tokens   int           `json:"tokens"`
+	Duration time.Duration `json:"duration"`
+	// CacheFiles is how many compilation cache files were fetched from
+	// peers before warming up
+	CacheFiles int `json:"cache_files,omitempty"`
+	// CachePublished reports whether the warm-up changed the local cache
+	// and it was published to peers
+	CachePublished bool      `json:"cache_published,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// Service warms models up and shares compilation caches
+type Service struct {
+	cfg     Config
+	profile Profile
+	peers   Peers
+	client  *http.Client
+
+	mu sync.Mutex
+	// warmed are the models warmed on each endpoint while loaded
+	warmed map[string]map[string]bool
+	// published is the digest of the last published cache
+	published string
+	results   []Result
+}
+
+// NewService creates a warm-up service for a hardware profile. Peers may be
+// nil when caches are not shared.
+func NewService(cfg Config, profile Profile, peers Peers) *Service {
+	return &Service{
+		cfg:     cfg.withDefaults(),
+		profile: profile,
+		peers:   peers,
+		client:  &http.Client{},
+		warmed:  make(map[string]map[string]bool),
+	}
+}
+
+// Profile returns the hardware profile caches are shared for
+func (s *Service) Profile() Profile {
+	return s.profile
+}
+
+// Results returns the recent warm-ups, oldest first
+func (s *Service) Results() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Result(nil), s.results...)
+}
+
+// WarmUp warms a model up on an Ollama endpoint, loading it if needed.
+// When caches are shared, the cache of the profile is fetched from peers
+// first and the local cache is published afterwards if it grew.
+func (s *Service) WarmUp(ctx context.Context, endpoint, model string) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	result := Result{Model: model, Endpoint: endpoint, At: time.Now()}
+	if s.sharing() {
+		result.CacheFiles = s.fetchCache(ctx)
+	}
+
+	start := time.Now()
+	tokens, err := generate(ctx, s.client, endpoint, model, s.cfg.Prompt, s.cfg.Tokens)
+	result.Duration = time.Since(start)
+	result.Tokens = tokens
+	if err != nil {
+		result.Error = err.Error()
+	} else if s.sharing() {
+		published, err := s.publishCache()
+		if err != nil {
+			slog.Warn("Failed to publish compilation cache", "error", err)
+		}
+		result.CachePublished = published
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		if s.warmed[endpoint] == nil {
+			s.warmed[endpoint] = make(map[string]bool)
+		}
+		s.warmed[endpoint][model] = true
+	}
+	s.results = append(s.results, result)
+	if len(s.results) > maxResults {
+		s.results = s.results[len(s.results)-maxResults:]
+	}
+	s.mu.Unlock()
+	return result, err
+}
+
+// Run warms up the models newly loaded on the endpoints, keyed by instance
+// name, every watch interval until the context is done
+func (s *Service) Run(ctx context.Context, endpoints func() map[string]string) {
+	ticker := time.NewTicker(s.cfg.WatchInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, endpoint := range endpoints() {
+			s.watch(ctx, endpoint)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watch warms up the models loaded on an endpoint that were not warmed
+// since they were loaded
+func (s *Service) watch(ctx context.Context, endpoint string) {
+	models, err := loadedModels(ctx, s.client, endpoint)
+	if err != nil {
+		slog.Debug("Failed to list loaded models", "endpoint", endpoint, "error", err)
+		return
+	}
+
+	loaded := make(map[string]bool, len(models))
+	for _, model := range models {
+		loaded[model.Name] = true
+	}
+	s.mu.Lock()
+	// Forget unloaded models so that they are warmed again when reloaded
+	for model := range s.warmed[endpoint] {
+		if !loaded[model] {
+			delete(s.warmed[endpoint], model)
+		}
+	}
+	var pending []string
+	for _, model := range models {
+		if !s.warmed[endpoint][model.Name] {
+			pending = append(pending, model.Name)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, model := range pending {
+		result, err := s.WarmUp(ctx, endpoint, model)
+		if err != nil {
+			slog.Warn("Failed to warm up model", "model", model, "endpoint", endpoint, "error", err)
+			continue
+		}
+		slog.Info("Warmed up model", "model", model, "endpoint", endpoint,
+			"duration", result.Duration, "cache_files", result.CacheFiles)
+	}
+}
+
+func (s *Service) sharing() bool {
+	return s.cfg.ShareCaches && s.cfg.CacheDir != "" && s.peers != nil
+}
+
+// blobKey is the key the cache of the profile is shared under
+func (s *Service) blobKey() string {
+	return "compile-cache/" + s.profile.Fingerprint()
+}
+
+func (s *Service) archivePath() string {
+	return filepath.Join(s.cfg.CacheDir, sharedDir, s.profile.Fingerprint()+".tar.gz")
+}
+
+// OpenBlob opens the cache archive of the profile for a peer; other keys
+// return ErrCacheNotFound
+func (s *Service) OpenBlob(key string) (io.ReadCloser, error) {
+	if s.cfg.CacheDir == "" || key != s.blobKey() {
+		return nil, ErrCacheNotFound
+	}
+	file, err := os.Open(s.archivePath())
+	if os.IsNotExist(err) {
+		return nil, ErrCacheNotFound
+	}
+	return file, err
+}
+
+// fetchCache merges the cache of the profile from a peer into the local
+// cache and returns how many files were added. A cache no peer has yet is
+// not an error.
+func (s *Service) fetchCache(ctx context.Context) int {
+	blob, err := s.peers.FetchBlob(ctx, s.blobKey())
+	if err != nil {
+		slog.Debug("No compilation cache fetched from peers", "error", err)
+		return 0
+	}
+	defer blob.Close()
+
+	added, err := mergeCache(blob, s.cfg.CacheDir, s.cfg.MaxCacheSize)
+	if err != nil {
+		slog.Warn("Failed to merge compilation cache", "error", err)
+	}
+	return added
+}
+
+// publishCache packs the local cache into the archive served to peers if it
+// changed since it was last packed
+func (s *Service) publishCache() (bool, error) {
+	files, digest, err := cacheFiles(s.cfg.CacheDir)
+	if err != nil || len(files) == 0 {
+		return false, err
+	}
+	s.mu.Lock()
+	unchanged := digest == s.published
+	s.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	path := s.archivePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := packCache(s.cfg.CacheDir, files, tmp, s.cfg.MaxCacheSize); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.published = digest
+	s.mu.Unlock()
+	return true, nil
+}
@@ -0,0 +1,145 @@
+// Package warmup warms models up after they are loaded, so that the first
+// real requests do not pay for building compute graphs and compiling
+// kernels, and shares the compilation caches backends leave on disk with
+// the other nodes of the same hardware profile over P2P, so that each
+// profile compiles once for the whole cluster.
+package warmup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Defaults of the warm-up
+const (
+	DefaultTokens        = 16
+	DefaultPrompt        = "Hello"
+	DefaultTimeout       = 2 * time.Minute
+	DefaultWatchInterval = 10 * time.Second
+	DefaultMaxCacheSize  = 1 << 30
+)
+
+// Config configures model warm-up and compilation cache sharing
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Tokens is how many tokens the warm-up request generates
+	Tokens  int           `yaml:"tokens"`
+	Prompt  string        `yaml:"prompt"`
+	Timeout time.Duration `yaml:"timeout"`
+	// WatchInterval is how often the local instances are checked for
+	// newly loaded models
+	WatchInterval time.Duration `yaml:"watch_interval" mapstructure:"watch_interval"`
+
+	// CacheDir is where the spawned instances keep their compilation
+	// caches. Setting it lets them survive restarts; ShareCaches also
+	// exchanges them with nodes of the same hardware profile.
+	CacheDir    string `yaml:"cache_dir" mapstructure:"cache_dir"`
+	ShareCaches bool   `yaml:"share_caches" mapstructure:"share_caches"`
+	// MaxCacheSize bounds the bytes of a shared cache archive
+	MaxCacheSize int64 `yaml:"max_cache_size" mapstructure:"max_cache_size"`
+}
+
+// Validate checks the values and that sharing has a cache directory
+func (c Config) Validate() error {
+	if c.Tokens < 0 || c.Timeout < 0 || c.WatchInterval < 0 || c.MaxCacheSize < 0 {
+		return fmt.Errorf("negative warm-up settings")
+	}
+	if c.ShareCaches && c.CacheDir == "" {
+		return fmt.Errorf("sharing compilation caches needs a cache_dir")
+	}
+	return nil
+}
+
+// withDefaults returns the config with its unset values defaulted
+func (c Config) withDefaults() Config {
+	if c.Tokens == 0 {
+		c.Tokens = DefaultTokens
+	}
+	if c.Prompt == "" {
+		c.Prompt = DefaultPrompt
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.WatchInterval == 0 {
+		c.WatchInterval = DefaultWatchInterval
+	}
+	if c.MaxCacheSize == 0 {
+		c.MaxCacheSize = DefaultMaxCacheSize
+	}
+	return c
+}
+
+// generate sends a non-streaming generate request for a model to an Ollama
+// endpoint, loading the model if needed, and returns how many tokens were
+// generated
+func generate(ctx context.Context, client *http.Client, endpoint, model, prompt string, tokens int) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":   model,
+		"prompt":  prompt,
+		"stream":  false,
+		"options": map[string]interface{}{"num_predict": tokens},
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("warm-up request failed with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		EvalCount int `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode warm-up response: %w", err)
+	}
+	return result.EvalCount, nil
+}
+
+// loadedModel is a model Ollama reports in memory
+type loadedModel struct {
+	Name      string    `json:"name"`
+	Digest    string    `json:"digest"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// loadedModels lists the models loaded by an Ollama endpoint
+func loadedModels(ctx context.Context, client *http.Client, endpoint string) ([]loadedModel, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/api/ps", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing loaded models failed with %s", resp.Status)
+	}
+
+	var result struct {
+		Models []loadedModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode loaded models: %w", err)
+	}
+	return result.Models, nil
+}
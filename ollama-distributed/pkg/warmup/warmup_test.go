@@ -0,0 +1,180 @@
+package warmup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOllama serves /api/generate and /api/ps, writing a compilation cache
+// file for each model it generates with
+type fakeOllama struct {
+	mu       sync.Mutex
+	loaded   []string
+	requests map[string]int
+	cacheDir string
+}
+
+func (f *fakeOllama) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model   string         `json:"model"`
+			Stream  bool           `json:"stream"`
+			Options map[string]int `json:"options"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.False(t, req.Stream)
+
+		f.mu.Lock()
+		f.requests[req.Model]++
+		f.mu.Unlock()
+		if f.cacheDir != "" {
+			require.NoError(t, writeFile(filepath.Join(f.cacheDir, "cuda", req.Model+".bin"), bytes.NewReader([]byte("kernels"))))
+		}
+		json.NewEncoder(w).Encode(map[string]int{"eval_count": req.Options["num_predict"]})
+	})
+	mux.HandleFunc("/api/ps", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		models := []loadedModel{}
+		for _, name := range f.loaded {
+			models = append(models, loadedModel{Name: name})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"models": models})
+	})
+	return mux
+}
+
+// fakePeers serves blobs from another service, if any
+type fakePeers struct {
+	service *Service
+}
+
+func (p fakePeers) FetchBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	if p.service == nil {
+		return nil, ErrCacheNotFound
+	}
+	return p.service.OpenBlob(key)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{Tokens: -1}.Validate())
+	assert.Error(t, Config{ShareCaches: true}.Validate())
+	assert.NoError(t, Config{ShareCaches: true, CacheDir: "/tmp"}.Validate())
+}
+
+func TestProfileFingerprint(t *testing.T) {
+	a := Profile{OS: "linux", Arch: "amd64", GPUs: []string{"NVIDIA A100 8.0"}}
+	b := a
+	b.Driver = "550.54"
+	assert.Equal(t, a.Fingerprint(), Profile{OS: "linux", Arch: "amd64", GPUs: []string{"NVIDIA A100 8.0"}}.Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), b.Fingerprint())
+}
+
+func TestWarmUp(t *testing.T) {
+	ollama := &fakeOllama{requests: map[string]int{}}
+	server := httptest.NewServer(ollama.handler(t))
+	defer server.Close()
+
+	service := NewService(Config{Enabled: true, Tokens: 8}, Profile{}, nil)
+	result, err := service.WarmUp(context.Background(), server.URL, "llama3")
+	require.NoError(t, err)
+	assert.Equal(t, 8, result.Tokens)
+	assert.Equal(t, 1, ollama.requests["llama3"])
+	assert.False(t, result.CachePublished)
+	require.Len(t, service.Results(), 1)
+
+	_, err = service.WarmUp(context.Background(), server.URL+"/missing", "llama3")
+	assert.Error(t, err)
+	results := service.Results()
+	require.Len(t, results, 2)
+	assert.NotEmpty(t, results[1].Error)
+}
+
+func TestWatchWarmsNewlyLoadedModels(t *testing.T) {
+	ollama := &fakeOllama{requests: map[string]int{}, loaded: []string{"llama3"}}
+	server := httptest.NewServer(ollama.handler(t))
+	defer server.Close()
+
+	service := NewService(Config{Enabled: true}, Profile{}, nil)
+	service.watch(context.Background(), server.URL)
+	service.watch(context.Background(), server.URL)
+	assert.Equal(t, 1, ollama.requests["llama3"])
+
+	// Unloading and reloading warms the model again
+	ollama.loaded = nil
+	service.watch(context.Background(), server.URL)
+	ollama.loaded = []string{"llama3", "mistral"}
+	service.watch(context.Background(), server.URL)
+	assert.Equal(t, 2, ollama.requests["llama3"])
+	assert.Equal(t, 1, ollama.requests["mistral"])
+}
+
+func TestCacheSharing(t *testing.T) {
+	profile := Profile{OS: "linux", Arch: "amd64", GPUs: []string{"NVIDIA L4 8.9"}}
+
+	// The first node compiles and publishes its cache
+	firstDir := t.TempDir()
+	first := &fakeOllama{requests: map[string]int{}, cacheDir: firstDir}
+	firstServer := httptest.NewServer(first.handler(t))
+	defer firstServer.Close()
+	firstService := NewService(Config{Enabled: true, CacheDir: firstDir, ShareCaches: true}, profile, fakePeers{})
+
+	result, err := firstService.WarmUp(context.Background(), firstServer.URL, "llama3")
+	require.NoError(t, err)
+	assert.True(t, result.CachePublished)
+	result, err = firstService.WarmUp(context.Background(), firstServer.URL, "llama3")
+	require.NoError(t, err)
+	assert.False(t, result.CachePublished, "an unchanged cache is not republished")
+
+	// A node of the same profile fetches it before warming up
+	secondDir := t.TempDir()
+	second := &fakeOllama{requests: map[string]int{}}
+	secondServer := httptest.NewServer(second.handler(t))
+	defer secondServer.Close()
+	secondService := NewService(Config{Enabled: true, CacheDir: secondDir, ShareCaches: true}, profile, fakePeers{firstService})
+
+	result, err = secondService.WarmUp(context.Background(), secondServer.URL, "llama3")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.CacheFiles)
+	data, err := os.ReadFile(filepath.Join(secondDir, "cuda", "llama3.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "kernels", string(data))
+
+	// A node of another profile finds nothing
+	other := NewService(Config{Enabled: true, CacheDir: t.TempDir(), ShareCaches: true}, Profile{OS: "linux", Arch: "arm64"}, fakePeers{firstService})
+	result, err = other.WarmUp(context.Background(), secondServer.URL, "llama3")
+	require.NoError(t, err)
+	assert.Zero(t, result.CacheFiles)
+}
+
+func TestMergeCacheRejectsEscapingPaths(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../evil.bin", Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	_, err = mergeCache(bytes.NewReader(archive.Bytes()), dir, DefaultMaxCacheSize)
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "evil.bin"))
+	assert.True(t, os.IsNotExist(err))
+}
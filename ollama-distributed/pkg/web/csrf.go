@@ -0,0 +1,139 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	csrfCookieName = "ollama_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+	csrfTokenBytes = 32
+)
+
+// csrfProtector issues and verifies double-submit CSRF tokens. Tokens are
+// signed with a per-process key so that a cookie planted by a sibling
+// subdomain cannot be used to forge a matching header.
+type csrfProtector struct {
+	key         []byte
+	secure      bool
+	crossOrigin bool
+}
+
+func newCSRFProtector(secure, crossOrigin bool) *csrfProtector {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("csrf: failed to generate signing key: " + err.Error())
+	}
+	return &csrfProtector{key: key, secure: secure, crossOrigin: crossOrigin}
+}
+
+func (p *csrfProtector) newToken() (string, error) {
+	nonce := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	n := hex.EncodeToString(nonce)
+	return n + "." + p.sign(n), nil
+}
+
+func (p *csrfProtector) sign(nonce string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *csrfProtector) valid(token string) bool {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(p.sign(nonce)))
+}
+
+// token returns the request's CSRF token, issuing a new cookie if the
+// client has none or an invalid one
+func (p *csrfProtector) token(c *gin.Context) (string, error) {
+	if issued := c.GetString(csrfCookieName); issued != "" {
+		return issued, nil
+	}
+	if cookie, err := c.Cookie(csrfCookieName); err == nil && p.valid(cookie) {
+		return cookie, nil
+	}
+
+	token, err := p.newToken()
+	if err != nil {
+		return "", err
+	}
+
+	// A dashboard served from another origin needs SameSite=None, which
+	// browsers only accept on Secure cookies
+	sameSite := http.SameSiteStrictMode
+	secure := p.secure
+	if p.crossOrigin {
+		sameSite = http.SameSiteNoneMode
+		secure = true
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   secure,
+		HttpOnly: false, // read by the dashboard to echo in the header
+		SameSite: sameSite,
+	})
+	c.Set(csrfCookieName, token)
+	return token, nil
+}
+
+// middleware ensures every client has a CSRF cookie and rejects
+// state-changing requests whose X-CSRF-Token header does not match it.
+// Requests authenticated with a bearer token are exempt because browsers
+// never attach those automatically.
+func (p *csrfProtector) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			if _, err := p.token(c); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue CSRF token"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+			c.Next()
+			return
+		}
+
+		cookie, err := c.Cookie(csrfCookieName)
+		header := c.GetHeader(csrfHeaderName)
+		if err != nil || header == "" || !p.valid(cookie) ||
+			subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// handleCSRFToken returns the CSRF token so a dashboard hosted on another
+// origin, which cannot read this origin's cookies, can still send it
+func (p *csrfProtector) handleCSRFToken(c *gin.Context) {
+	token, err := p.token(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue CSRF token"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"csrf_token": token, "header": csrfHeaderName})
+}
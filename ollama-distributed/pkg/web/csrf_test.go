@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCSRFRouter(p *csrfProtector) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(p.middleware())
+	router.GET("/api/csrf-token", p.handleCSRFToken)
+	router.POST("/api/v1/models/pull", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	p := newCSRFProtector(false, false)
+	router := newCSRFRouter(p)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf-token", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	token := cookies[0].Value
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+
+	// Missing header
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/models/pull", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Matching header
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/models/pull", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set(csrfHeaderName, token)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Bearer-authenticated requests are exempt
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/models/pull", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCSRFRejectsForgedToken(t *testing.T) {
+	p := newCSRFProtector(false, false)
+	other := newCSRFProtector(false, false)
+	router := newCSRFRouter(p)
+
+	forged, err := other.newToken()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/models/pull", nil)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: forged})
+	req.Header.Set(csrfHeaderName, forged)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestCSRFCrossOriginCookie(t *testing.T) {
+	p := newCSRFProtector(false, true)
+	router := newCSRFRouter(p)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf-token", nil))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, http.SameSiteNoneMode, cookies[0].SameSite)
+	assert.True(t, cookies[0].Secure)
+}
+
+func TestDashboardWritesCarryCSRFToken(t *testing.T) {
+	var proxied int
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer apiServer.Close()
+
+	cfg := DefaultConfig()
+	cfg.APIBaseURL = apiServer.URL
+	ws := NewWebServer(cfg, nil)
+
+	rec := httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf-token", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	post := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/models/pull", nil)
+		req.AddCookie(cookies[0])
+		if token != "" {
+			req.Header.Set(csrfHeaderName, token)
+		}
+		rec := httptest.NewRecorder()
+		ws.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, post(""))
+	assert.Equal(t, http.StatusOK, post(cookies[0].Value))
+	assert.Equal(t, 1, proxied, "only the write with the token reaches the API")
+
+	// The dashboard fetches the token and sends it on its writes
+	dashboard, err := os.ReadFile("../../web/index.html")
+	require.NoError(t, err)
+	assert.Contains(t, string(dashboard), "fetch('/api/csrf-token'")
+	assert.Contains(t, string(dashboard), "options.headers['"+csrfHeaderName+"']")
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth/sso"
+)
+
+const (
+	oidcFlowCookie  = "ollama_oidc_flow"
+	authTokenCookie = "auth_token" // read by the API server's AuthMiddleware
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+// EnableOIDC registers the single sign-on login, callback and logout routes
+// for the dashboard. After a successful code flow the verified ID token is
+// stored in the auth_token cookie, which the proxied API validates against
+// the issuer's JWKS.
+func (ws *WebServer) EnableOIDC(provider *sso.OIDCProvider) {
+	ws.oidc = provider
+
+	group := ws.router.Group("/auth/oidc")
+	group.GET("/login", ws.oidcLogin)
+	group.GET("/callback", ws.oidcCallback)
+	group.GET("/logout", ws.oidcLogout)
+}
+
+func (ws *WebServer) oidcLogin(c *gin.Context) {
+	state, err1 := randomToken()
+	nonce, err2 := randomToken()
+	verifier, err3 := randomToken()
+	if err1 != nil || err2 != nil || err3 != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+		return
+	}
+
+	ws.setAuthCookie(c, oidcFlowCookie, strings.Join([]string{state, nonce, verifier}, "."), int(oidcFlowTTL.Seconds()), http.SameSiteLaxMode)
+	c.Redirect(http.StatusFound, ws.oidc.AuthCodeURL(state, nonce, verifier))
+}
+
+func (ws *WebServer) oidcCallback(c *gin.Context) {
+	flow, err := c.Cookie(oidcFlowCookie)
+	ws.setAuthCookie(c, oidcFlowCookie, "", -1, http.SameSiteLaxMode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session expired"})
+		return
+	}
+
+	parts := strings.Split(flow, ".")
+	if len(parts) != 3 || c.Query("state") != parts[0] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login state"})
+		return
+	}
+	if idpErr := c.Query("error"); idpErr != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider rejected login", "details": idpErr})
+		return
+	}
+
+	tokens, err := ws.oidc.Exchange(c.Request.Context(), c.Query("code"), parts[2])
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	claims, err := ws.oidc.Verify(c.Request.Context(), tokens.IDToken)
+	if err != nil || claims.Nonce != parts[1] {
+		log.Printf("OIDC ID token rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	if _, err := ws.oidc.RoleFor(claims); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is not authorized for this cluster"})
+		return
+	}
+
+	maxAge := int(time.Until(claims.Expiry).Seconds())
+	ws.setAuthCookie(c, authTokenCookie, tokens.IDToken, maxAge, http.SameSiteLaxMode)
+	c.Redirect(http.StatusFound, "/")
+}
+
+func (ws *WebServer) oidcLogout(c *gin.Context) {
+	ws.setAuthCookie(c, authTokenCookie, "", -1, http.SameSiteLaxMode)
+	if endSession := ws.oidc.EndSessionURL(); endSession != "" {
+		c.Redirect(http.StatusFound, endSession)
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func (ws *WebServer) setAuthCookie(c *gin.Context, name, value string, maxAge int, sameSite http.SameSite) {
+	secure := ws.config.EnableTLS
+	if len(ws.config.AllowedOrigins) > 0 {
+		sameSite = http.SameSiteNoneMode
+		secure = true
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
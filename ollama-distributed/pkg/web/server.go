@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -16,9 +17,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/internal/config"
 	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/api"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/auth/sso"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/logging"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
 )
 
+// The embedded dashboard is a copy of web/index.html, served when no static
+// path is configured
+//
+//go:generate cp ../../web/index.html static/index.html
 //go:embed static/*
 var staticFiles embed.FS
 
@@ -35,6 +44,9 @@ type WebServer struct {
 	register   chan *websocket.Conn
 	unregister chan *websocket.Conn
 	httpClient *http.Client
+	csrf       *csrfProtector
+	oidc       *sso.OIDCProvider
+	sessions   *session.Manager
 }
 
 // Config holds web server configuration
@@ -46,6 +58,13 @@ type Config struct {
 	StaticPath    string `yaml:"static_path" json:"static_path"`
 	EnableAuth    bool   `yaml:"enable_auth" json:"enable_auth"`
 	APIBaseURL    string `yaml:"api_base_url" json:"api_base_url"`
+
+	// AllowedOrigins lists other origins allowed to call this server with
+	// credentials, e.g. when the dashboard is hosted separately
+	AllowedOrigins []string `yaml:"allowed_origins" json:"allowed_origins"`
+	EnableCSRF     bool     `yaml:"enable_csrf" json:"enable_csrf"`
+
+	AccessLog logging.AccessLogOptions `yaml:"access_log" json:"access_log"`
 }
 
 // DefaultConfig returns default web server configuration
@@ -56,6 +75,12 @@ func DefaultConfig() *Config {
 		StaticPath:    "./web",
 		EnableAuth:    true,
 		APIBaseURL:    "http://localhost:8080",
+		EnableCSRF:    true,
+		AccessLog: logging.AccessLogOptions{
+			SampleRate:    0.01,
+			SampledRoutes: []string{"/static/", "/assets/", "/ws"},
+			SlowThreshold: 5 * time.Second,
+		},
 	}
 }
 
@@ -89,6 +114,10 @@ func NewWebServer(config *Config, apiServer *api.Server) *WebServer {
 		},
 	}
 
+	if config.EnableCSRF {
+		ws.csrf = newCSRFProtector(config.EnableTLS, len(config.AllowedOrigins) > 0)
+	}
+
 	ws.setupRouter()
 	return ws
 }
@@ -101,18 +130,24 @@ func (ws *WebServer) setupRouter() {
 	ws.router = gin.New()
 
 	// Add middleware
-	ws.router.Use(gin.Logger())
+	ws.router.Use(logging.AccessLog(slog.Default(), ws.config.AccessLog))
 	ws.router.Use(gin.Recovery())
 	ws.router.Use(ws.corsMiddleware())
 
 	// Add security headers
 	ws.router.Use(ws.securityHeadersMiddleware())
 
+	// CSRF protection for state-changing dashboard actions
+	if ws.csrf != nil {
+		ws.router.Use(ws.csrf.middleware())
+		ws.router.GET("/api/csrf-token", ws.csrf.handleCSRFToken)
+	}
+
 	// Add metrics middleware
 	// ws.router.Use(observability.GinMetricsMiddleware()) // Temporarily disabled
 
 	// WebSocket endpoint
-	ws.router.GET("/ws", ws.handleWebSocket)
+	ws.router.GET("/ws", ws.sessionMiddleware(), ws.handleWebSocket)
 
 	// API proxy endpoints
 	api := ws.router.Group("/api")
@@ -128,12 +163,17 @@ func (ws *WebServer) setupRouter() {
 		// Core API endpoints
 		api.GET("v1/health", ws.proxyToAPI)
 		api.GET("v1/version", ws.proxyToAPI)
+
+		// The other endpoints need a session, once sessions are enabled
+		api.Use(ws.sessionMiddleware())
 		api.GET("v1/nodes", ws.proxyToAPI)
 		api.GET("v1/models", ws.proxyToAPI)
 		api.POST("v1/models/pull", ws.proxyToAPI)
 		api.DELETE("v1/models/:name", ws.proxyToAPI)
 		api.GET("v1/cluster/status", ws.proxyToAPI)
 		api.GET("v1/cluster/leader", ws.proxyToAPI)
+		api.GET("v1/federation/peers", ws.proxyToAPI)
+		api.GET("v1/federation/models", ws.proxyToAPI)
 		api.GET("v1/tasks", ws.proxyToAPI)
 		api.GET("v1/tasks/queue", ws.proxyToAPI)
 		api.POST("v1/inference", ws.proxyToAPI)
@@ -142,6 +182,7 @@ func (ws *WebServer) setupRouter() {
 		api.GET("v1/metrics", ws.proxyToAPI)
 		api.GET("v1/metrics/resources", ws.proxyToAPI)
 		api.GET("v1/metrics/performance", ws.proxyToAPI)
+		api.GET("v1/analytics/models", ws.proxyToAPI)
 
 		// Security endpoints
 		api.GET("v1/security/status", ws.proxyToAPI)
@@ -163,6 +204,14 @@ func (ws *WebServer) setupRouter() {
 		api.GET("v1/transfers", ws.proxyToAPI)
 		api.POST("v1/transfers", ws.proxyToAPI)
 		api.DELETE("v1/transfers/:id", ws.proxyToAPI)
+
+		// Local account endpoints, for admins
+		api.GET("v1/admin/users", ws.proxyToAPI)
+		api.POST("v1/admin/users", ws.proxyToAPI)
+		api.GET("v1/admin/users/:username", ws.proxyToAPI)
+		api.PATCH("v1/admin/users/:username", ws.proxyToAPI)
+		api.POST("v1/admin/users/:username/password", ws.proxyToAPI)
+		api.DELETE("v1/admin/users/:username", ws.proxyToAPI)
 	}
 
 	// Health check endpoint
@@ -233,8 +282,9 @@ func (ws *WebServer) setupStaticFiles() {
 	// Serve web application for all other routes (SPA routing)
 	// Only serve index for non-API routes
 	ws.router.NoRoute(func(c *gin.Context) {
-		// Don't serve index for API routes
-		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		// Don't serve index for API routes, or for the session routes,
+		// which the dashboard probes to learn whether login is needed
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") || strings.HasPrefix(c.Request.URL.Path, "/auth/") {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "API endpoint not found",
 				"path":  c.Request.URL.Path,
@@ -284,20 +334,15 @@ func (ws *WebServer) serveTest(c *gin.Context) {
 	c.Data(http.StatusOK, "text/html; charset=utf-8", testContent)
 }
 
-// corsMiddleware adds CORS headers
+// corsMiddleware allows credentialed requests from the configured origins
 func (ws *WebServer) corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	}
+	return api.CORS(config.CorsConfig{
+		Enabled:          true,
+		AllowedOrigins:   ws.config.AllowedOrigins,
+		AllowCredentials: true,
+		ExposedHeaders:   []string{"X-Request-ID"},
+		MaxAge:           3600,
+	})
 }
 
 // securityHeadersMiddleware adds security headers
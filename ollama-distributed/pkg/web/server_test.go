@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardRoutesReachTheAPI(t *testing.T) {
+	var proxied []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer apiServer.Close()
+
+	cfg := DefaultConfig()
+	cfg.APIBaseURL = apiServer.URL
+	ws := NewWebServer(cfg, nil)
+
+	rec := httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf-token", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookie := rec.Result().Cookies()[0]
+
+	// The routes the Analytics and Users pages call
+	routes := []string{
+		"GET /api/v1/analytics/models",
+		"GET /api/v1/admin/users",
+		"POST /api/v1/admin/users",
+		"GET /api/v1/admin/users/bob",
+		"PATCH /api/v1/admin/users/bob",
+		"POST /api/v1/admin/users/bob/password",
+		"DELETE /api/v1/admin/users/bob",
+	}
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(cookie)
+		req.Header.Set(csrfHeaderName, cookie.Value)
+		rec := httptest.NewRecorder()
+		ws.router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, route)
+	}
+	assert.Equal(t, routes, proxied)
+}
+
+func TestEmbeddedDashboardIsCurrent(t *testing.T) {
+	dashboard, err := os.ReadFile("../../web/index.html")
+	require.NoError(t, err)
+	embedded, err := staticFiles.ReadFile("static/index.html")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(dashboard, embedded), "static/index.html is stale, run go generate ./pkg/web")
+
+	cfg := DefaultConfig()
+	cfg.StaticPath = ""
+	ws := NewWebServer(cfg, nil)
+	rec := httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, dashboard, rec.Body.Bytes())
+}
@@ -0,0 +1,247 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+)
+
+// sessionKey is the gin context key of the caller's session
+const sessionKey = "session"
+
+// EnableSessions registers the login, logout, two-factor and session
+// routes of the dashboard, and requires a session for the API proxy and
+// live updates from then on. The session cookie is accepted by the API
+// server too, given the same manager.
+func (ws *WebServer) EnableSessions(manager *session.Manager) {
+	ws.sessions = manager
+
+	group := ws.router.Group("/auth")
+	group.POST("/login", ws.login)
+	group.POST("/logout", ws.logout)
+
+	authed := group.Group("", ws.sessionMiddleware())
+	authed.GET("/session", ws.currentSession)
+	authed.GET("/sessions", ws.listSessions)
+	authed.DELETE("/sessions/:id", ws.revokeSession)
+	authed.POST("/2fa/enroll", ws.enrollTwoFactor)
+	authed.POST("/2fa/confirm", ws.confirmTwoFactor)
+	authed.POST("/2fa/disable", ws.disableTwoFactor)
+	authed.DELETE("/users/:username/sessions", ws.requireAdmin, ws.revokeUserSessions)
+	authed.POST("/users/:username/unlock", ws.requireAdmin, ws.unlockUser)
+}
+
+// sessionMiddleware rejects requests without a live session when sessions
+// are enabled. Requests with an Authorization header are left to the API
+// server to authenticate.
+func (ws *WebServer) sessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ws.sessions == nil || !ws.config.EnableAuth || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+
+		token, err := c.Cookie(session.CookieName)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+			return
+		}
+		s, ok := ws.sessions.Validate(token)
+		if !ok {
+			ws.setAuthCookie(c, session.CookieName, "", -1, http.SameSiteStrictMode)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+			return
+		}
+		c.Set(sessionKey, s)
+		c.Next()
+	}
+}
+
+// callerSession returns the session sessionMiddleware found
+func callerSession(c *gin.Context) (session.Session, bool) {
+	value, exists := c.Get(sessionKey)
+	if !exists {
+		return session.Session{}, false
+	}
+	s, ok := value.(session.Session)
+	return s, ok
+}
+
+func isAdmin(s session.Session) bool {
+	for _, role := range s.Roles {
+		if role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+func (ws *WebServer) requireAdmin(c *gin.Context) {
+	if s, ok := callerSession(c); !ok || !isAdmin(s) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	c.Next()
+}
+
+func (ws *WebServer) login(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username and password are required"})
+		return
+	}
+
+	token, s, err := ws.sessions.Login(req.Username, req.Password, req.Code, c.ClientIP(), c.Request.UserAgent())
+	var locked *session.LockedOutError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed logins", "locked_until": locked.Until})
+		return
+	case errors.Is(err, session.ErrTwoFactorRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "two_factor_required": true})
+		return
+	case errors.Is(err, session.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code", "two_factor_required": true})
+		return
+	case err != nil:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+
+	ws.setAuthCookie(c, session.CookieName, token, int(ws.sessions.Config().MaxAge.Seconds()), http.SameSiteStrictMode)
+	c.JSON(http.StatusOK, gin.H{"session": s})
+}
+
+func (ws *WebServer) logout(c *gin.Context) {
+	if token, err := c.Cookie(session.CookieName); err == nil {
+		ws.sessions.Logout(token)
+	}
+	ws.setAuthCookie(c, session.CookieName, "", -1, http.SameSiteStrictMode)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func (ws *WebServer) currentSession(c *gin.Context) {
+	s, ok := callerSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": s, "two_factor_enabled": ws.sessions.TwoFactorEnabled(s.Username)})
+}
+
+// listSessions returns the caller's sessions; admins may list another
+// user's with ?user=, or everyone's with ?user=*
+func (ws *WebServer) listSessions(c *gin.Context) {
+	s, ok := callerSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+
+	username := s.Username
+	if user := c.Query("user"); user != "" && user != s.Username {
+		if !isAdmin(s) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		username = user
+		if user == "*" {
+			username = ""
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"current": s.ID, "sessions": ws.sessions.Sessions(username)})
+}
+
+// revokeSession ends one of the caller's sessions, or anyone's for admins
+func (ws *WebServer) revokeSession(c *gin.Context) {
+	s, ok := callerSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+
+	username := s.Username
+	if isAdmin(s) {
+		username = ""
+	}
+	if !ws.sessions.Revoke(username, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if c.Param("id") == s.ID {
+		ws.setAuthCookie(c, session.CookieName, "", -1, http.SameSiteStrictMode)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+func (ws *WebServer) revokeUserSessions(c *gin.Context) {
+	revoked := ws.sessions.RevokeAll(c.Param("username"))
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+func (ws *WebServer) unlockUser(c *gin.Context) {
+	ws.sessions.Unlock(c.Param("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+func (ws *WebServer) enrollTwoFactor(c *gin.Context) {
+	s, ok := callerSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+
+	enrollment, err := ws.sessions.EnrollTOTP(s.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (ws *WebServer) confirmTwoFactor(c *gin.Context) {
+	ws.changeTwoFactor(c, (*session.Manager).ConfirmTOTP, "Two-factor authentication enabled")
+}
+
+func (ws *WebServer) disableTwoFactor(c *gin.Context) {
+	ws.changeTwoFactor(c, (*session.Manager).DisableTOTP, "Two-factor authentication disabled")
+}
+
+// changeTwoFactor confirms or disables the caller's TOTP enrollment with a
+// code from it
+func (ws *WebServer) changeTwoFactor(c *gin.Context, change func(*session.Manager, string, string) error, message string) {
+	s, ok := callerSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A two-factor code is required"})
+		return
+	}
+
+	err := change(ws.sessions, s.Username, req.Code)
+	switch {
+	case errors.Is(err, session.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+	case errors.Is(err, session.ErrNotEnrolling), errors.Is(err, session.ErrTwoFactorDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor authentication"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": message})
+	}
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khryptorgraphics/ollamamax/ollama-distributed/pkg/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newSessionServer(t *testing.T) *WebServer {
+	gin.SetMode(gin.TestMode)
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	manager, err := session.NewManager(session.Config{MaxFailedLogins: 2}, session.StaticUsers{
+		{Username: "alice", PasswordHash: string(hash), Roles: []string{"admin"}},
+		{Username: "bob", PasswordHash: string(hash), Roles: []string{"user"}},
+	})
+	require.NoError(t, err)
+
+	ws := &WebServer{config: DefaultConfig(), router: gin.New()}
+	ws.EnableSessions(manager)
+	ws.router.GET("/api/v1/nodes", ws.sessionMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return ws
+}
+
+func TestSessionLogin(t *testing.T) {
+	ws := newSessionServer(t)
+	do := func(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		ws.router.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(username string) *http.Cookie {
+		rec := do(http.MethodPost, "/auth/login", `{"username": "`+username+`", "password": "s3cret"}`, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+		return cookies[0]
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/nodes", "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/auth/login", `{}`, nil).Code)
+
+	bob := login("bob")
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/nodes", "", bob).Code)
+	rec := do(http.MethodGet, "/auth/session", "", bob)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"username":"bob"`)
+
+	// Users see their own sessions; only admins see others'
+	alice := login("alice")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/auth/sessions?user=alice", "", bob).Code)
+	rec = do(http.MethodGet, "/auth/sessions?user=*", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed struct {
+		Sessions []session.Session `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed.Sessions, 2)
+	aliceID := listed.Sessions[0].ID
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/auth/sessions/"+aliceID, "", bob).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/auth/users/alice/sessions", "", bob).Code)
+
+	rec = do(http.MethodDelete, "/auth/users/bob/sessions", "", alice)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"revoked": 1}`, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/v1/nodes", "", bob).Code)
+
+	// Brute force lockout, lifted by an admin
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/auth/login", `{"username": "bob", "password": "guess"}`, nil).Code)
+	}
+	rec = do(http.MethodPost, "/auth/login", `{"username": "bob", "password": "s3cret"}`, nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/auth/logout", "", alice).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/auth/session", "", alice).Code)
+}
+
+func TestSessionTwoFactorEnrollment(t *testing.T) {
+	ws := newSessionServer(t)
+	rec := httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username": "bob", "password": "s3cret"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookie := rec.Result().Cookies()[0]
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		ws.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusConflict, do("/auth/2fa/confirm", `{"code": "123456"}`).Code)
+	rec = do("/auth/2fa/enroll", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	var enrollment session.Enrollment
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &enrollment))
+	assert.NotEmpty(t, enrollment.Secret)
+	assert.Equal(t, http.StatusBadRequest, do("/auth/2fa/confirm", `{}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("/auth/2fa/confirm", `{"code": "abc"}`).Code)
+	assert.Equal(t, http.StatusConflict, do("/auth/2fa/disable", `{"code": "123456"}`).Code)
+
+	// Logging in now asks for a code only once enrollment is confirmed
+	rec = httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username": "bob", "password": "s3cret"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDashboardLogin(t *testing.T) {
+	// Without sessions, the dashboard learns that no login is needed
+	ws := NewWebServer(DefaultConfig(), nil)
+	rec := httptest.NewRecorder()
+	ws.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/session", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// With them, it logs in, asks for two-factor codes and manages them
+	dashboard, err := os.ReadFile("../../web/index.html")
+	require.NoError(t, err)
+	for _, call := range []string{"'/auth/session'", "'/auth/login'", "'/auth/logout'", "'/auth/2fa/enroll'", "'/auth/2fa/confirm'", "'/auth/2fa/disable'", "two_factor_required"} {
+		assert.Contains(t, string(dashboard), call)
+	}
+}
@@ -233,8 +233,8 @@ func TestAPIClusterEndpoints(t *testing.T) {
 
 		server.ServeHTTP(w, req)
 
-		// May succeed or fail depending on consensus state
-		assert.True(t, w.Code == http.StatusOK || w.Code == http.StatusInternalServerError)
+		// Joining requires a join token
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
 	t.Run("LeaveCluster", func(t *testing.T) {
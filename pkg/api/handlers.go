@@ -1,12 +1,14 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/khryptorgraphics/ollamamax/pkg/auth"
 	"github.com/khryptorgraphics/ollamamax/pkg/database"
 )
 
@@ -82,7 +84,7 @@ func (s *Server) loginHandler(c *gin.Context) {
 	session := &database.UserSession{
 		UserID:           user.ID,
 		TokenID:          accessToken[:32], // Use first 32 chars as token ID
-		ExpiresAt:        time.Now().Add(s.config.Auth.TokenExpiry),
+		ExpiresAt:        time.Now().Add(s.jwtSvc.AccessTokenExpiry()),
 		IPAddress:        &c.ClientIP,
 		UserAgent:        &c.Request.UserAgent,
 		CreatedAt:        time.Now(),
@@ -97,7 +99,7 @@ func (s *Server) loginHandler(c *gin.Context) {
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 		"token_type":    "Bearer",
-		"expires_in":    int(s.config.Auth.TokenExpiry.Seconds()),
+		"expires_in":    int(s.jwtSvc.AccessTokenExpiry().Seconds()),
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
@@ -168,9 +170,12 @@ func (s *Server) refreshTokenHandler(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token and get user info
-	claims, err := s.jwtSvc.ValidateRefreshToken(req.RefreshToken)
+	// Rotate the refresh token so it cannot be exchanged again
+	claims, err := s.jwtSvc.RotateRefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			s.logger.Warn("Refresh token reuse detected, revoked all user tokens", "ip", c.ClientIP())
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "invalid_refresh_token",
 			"message": "Refresh token is invalid or expired",
@@ -203,7 +208,7 @@ func (s *Server) refreshTokenHandler(c *gin.Context) {
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 		"token_type":    "Bearer",
-		"expires_in":    int(s.config.Auth.TokenExpiry.Seconds()),
+		"expires_in":    int(s.jwtSvc.AccessTokenExpiry().Seconds()),
 	})
 }
 
@@ -217,9 +222,52 @@ func (s *Server) logoutHandler(c *gin.Context) {
 		return
 	}
 
+	// The refresh token is optional; without it only the access token is
+	// revoked and the refresh token stays usable until it expires
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+		All          bool   `json:"all"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_request",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if claims, ok := auth.GetCurrentClaims(c); ok {
+		if err := s.jwtSvc.RevokeClaims(ctx, claims); err != nil {
+			s.logger.Error("Failed to revoke access token", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "logout_failed",
+				"message": "Failed to revoke token",
+			})
+			return
+		}
+	}
+	if req.RefreshToken != "" {
+		if err := s.jwtSvc.RevokeToken(ctx, req.RefreshToken); err != nil {
+			s.logger.Warn("Failed to revoke refresh token", "error", err)
+		}
+	}
+	if req.All {
+		if err := s.jwtSvc.RevokeAllUserTokens(ctx, userID.(string)); err != nil {
+			s.logger.Error("Failed to revoke user tokens", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "logout_failed",
+				"message": "Failed to revoke tokens",
+			})
+			return
+		}
+	}
+
 	// Revoke all user sessions
 	uid, _ := uuid.Parse(userID.(string))
-	if err := s.db.Sessions.RevokeUserSessions(c.Request.Context(), uid); err != nil {
+	if err := s.db.Sessions.RevokeUserSessions(ctx, uid); err != nil {
 		s.logger.Error("Failed to revoke user sessions", "error", err)
 	}
 
@@ -32,6 +32,13 @@ func NewServer(cfg *config.Config, db *database.DatabaseManager, logger *slog.Lo
 		return nil, fmt.Errorf("failed to create JWT service: %w", err)
 	}
 
+	// Share revoked tokens between replicas when Redis is available
+	if db != nil && db.Redis != nil {
+		jwtSvc.SetRevocationList(auth.NewRedisRevocationList(db.Redis))
+	} else {
+		logger.Warn("Redis not configured, token revocations are local to this instance")
+	}
+
 	// Initialize WebSocket hub
 	websocketHub := NewWebSocketHub(logger)
 
@@ -118,11 +125,12 @@ func (s *Server) setupRouter() *gin.Engine {
 	v1 := router.Group("/api/v1")
 	{
 		// Public endpoints
-		auth := v1.Group("/auth")
+		authRoutes := v1.Group("/auth")
 		{
-			auth.POST("/login", s.loginHandler)
-			auth.POST("/register", s.registerHandler)
-			auth.POST("/refresh", s.refreshTokenHandler)
+			authRoutes.POST("/login", s.loginHandler)
+			authRoutes.POST("/register", s.registerHandler)
+			authRoutes.POST("/refresh", s.refreshTokenHandler)
+			authRoutes.POST("/logout", auth.JWTAuthMiddleware(s.jwtSvc), s.logoutHandler)
 		}
 
 		// Protected endpoints (require authentication)
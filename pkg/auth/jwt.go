@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"errors"
@@ -8,9 +9,20 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/khryptorgraphics/ollamamax/internal/config"
 )
 
+const (
+	accessTokenAudience  = "ollamamax"
+	refreshTokenAudience = "ollamamax-refresh"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// rotated is presented again. This usually means the token was stolen, so
+// every token of the user is revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
 // JWTService handles JWT token operations
 type JWTService struct {
 	privateKey    *rsa.PrivateKey
@@ -18,6 +30,7 @@ type JWTService struct {
 	issuer        string
 	expiration    time.Duration
 	refreshExpiry time.Duration
+	revocations   RevocationList
 }
 
 // Claims represents JWT claims structure
@@ -27,9 +40,24 @@ type Claims struct {
 	Role        string            `json:"role"`
 	Permissions []string          `json:"permissions"`
 	Metadata    map[string]string `json:"metadata"`
+	// IssuedAtNano is the issue time in Unix nanoseconds; iat only has
+	// second precision, too coarse to tell a token issued right after a
+	// user-wide revocation from one issued right before
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// issuedAt returns the issue time of the token, and whether it is precise
+func (c *Claims) issuedAt() (time.Time, bool) {
+	if c.IssuedAtNano != 0 {
+		return time.Unix(0, c.IssuedAtNano), true
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.Time, false
+	}
+	return time.Time{}, false
+}
+
 // IsAdmin checks if the user has admin role
 func (c *Claims) IsAdmin() bool {
 	return c.Role == RoleAdmin
@@ -60,8 +88,9 @@ func NewJWTService(config *config.AuthConfig) (*JWTService, error) {
 		privateKey:    privateKey,
 		publicKey:     &privateKey.PublicKey,
 		issuer:        "ollamamax",
-		expiration:    24 * time.Hour, // Default 24 hours
+		expiration:    15 * time.Minute,   // Default 15 minutes
 		refreshExpiry: 7 * 24 * time.Hour, // Default 7 days
+		revocations:   NewMemoryRevocationList(),
 	}
 
 	// Override with config values if provided
@@ -72,11 +101,25 @@ func NewJWTService(config *config.AuthConfig) (*JWTService, error) {
 		if config.TokenExpiry > 0 {
 			service.expiration = config.TokenExpiry
 		}
+		if config.RefreshTime > 0 {
+			service.refreshExpiry = config.RefreshTime
+		}
 	}
 
 	return service, nil
 }
 
+// SetRevocationList replaces the default in-memory revocation list, e.g.
+// with a RedisRevocationList shared by all API replicas
+func (j *JWTService) SetRevocationList(list RevocationList) {
+	j.revocations = list
+}
+
+// AccessTokenExpiry returns the lifetime of issued access tokens
+func (j *JWTService) AccessTokenExpiry() time.Duration {
+	return j.expiration
+}
+
 // GenerateTokens creates access and refresh tokens (API compatibility method)
 func (j *JWTService) GenerateTokens(userID, username string, roles []string) (accessToken, refreshToken string, err error) {
 	role := "user" // default role
@@ -100,19 +143,20 @@ func (j *JWTService) GenerateToken(userID, username, role string, permissions []
 
 	// Create access token claims
 	claims := &Claims{
-		UserID:      userID,
-		Username:    username,
-		Role:        role,
-		Permissions: permissions,
-		Metadata:    make(map[string]string),
+		UserID:       userID,
+		Username:     username,
+		Role:         role,
+		Permissions:  permissions,
+		Metadata:     make(map[string]string),
+		IssuedAtNano: now.UnixNano(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
 			Subject:   userID,
-			Audience:  []string{"ollamamax"},
+			Audience:  []string{accessTokenAudience},
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
-			ID:        fmt.Sprintf("%s_%d", userID, now.Unix()),
+			ID:        uuid.New().String(),
 		},
 	}
 
@@ -125,17 +169,18 @@ func (j *JWTService) GenerateToken(userID, username, role string, permissions []
 
 	// Create refresh token claims
 	refreshClaims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:       userID,
+		Username:     username,
+		Role:         role,
+		IssuedAtNano: now.UnixNano(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.issuer,
 			Subject:   userID,
-			Audience:  []string{"ollamamax-refresh"},
+			Audience:  []string{refreshTokenAudience},
 			ExpiresAt: jwt.NewNumericDate(refreshExpiresAt),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
-			ID:        fmt.Sprintf("%s_refresh_%d", userID, now.Unix()),
+			ID:        uuid.New().String(),
 		},
 	}
 
@@ -154,23 +199,26 @@ func (j *JWTService) GenerateToken(userID, username, role string, permissions []
 	}, nil
 }
 
-// ValidateToken validates and parses a JWT token
+// ValidateToken validates and parses a JWT access token
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.publicKey, nil
-	})
+	return j.ValidateTokenContext(context.Background(), tokenString)
+}
 
+// ValidateTokenContext validates an access token and checks that it has not
+// been revoked
+func (j *JWTService) ValidateTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := j.parseToken(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, errors.New("invalid token claims")
+	// Refresh tokens live much longer and must never authorize requests
+	if len(claims.Audience) == 0 || claims.Audience[0] != accessTokenAudience {
+		return nil, errors.New("token is not a valid access token")
+	}
+
+	if err := j.checkRevoked(ctx, claims); err != nil {
+		return nil, err
 	}
 
 	return claims, nil
@@ -178,22 +226,53 @@ func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 
 // ValidateRefreshToken validates a refresh token and returns user info
 func (j *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
-	claims, err := j.ValidateToken(tokenString)
+	claims, err := j.parseRefreshToken(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("invalid refresh token: %w", err)
+		return nil, err
 	}
 
-	// Additional validation for refresh tokens
-	if len(claims.Audience) == 0 || claims.Audience[0] != "ollamamax-refresh" {
-		return nil, errors.New("token is not a valid refresh token")
+	if err := j.checkRevoked(context.Background(), claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// RotateRefreshToken validates a refresh token and revokes it so that it
+// can only be exchanged once. Revoking it claims it atomically, so of
+// concurrent exchanges of the same token only one succeeds. Presenting an
+// already rotated token revokes every token of the user and returns
+// ErrRefreshTokenReused.
+func (j *JWTService) RotateRefreshToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := j.parseRefreshToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("token has no ID or expiry")
+	}
+
+	if err := j.checkUserRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	claimed, err := j.revocations.RevokeOnce(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if !claimed {
+		if err := j.RevokeAllUserTokens(ctx, claims.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
 	}
 
 	return claims, nil
 }
 
-// RefreshTokens creates new tokens from a valid refresh token
+// RefreshTokens rotates a valid refresh token and creates a new token pair
 func (j *JWTService) RefreshTokens(refreshToken string) (string, string, error) {
-	claims, err := j.ValidateRefreshToken(refreshToken)
+	claims, err := j.RotateRefreshToken(context.Background(), refreshToken)
 	if err != nil {
 		return "", "", err
 	}
@@ -202,7 +281,110 @@ func (j *JWTService) RefreshTokens(refreshToken string) (string, string, error)
 	return j.GenerateTokens(claims.UserID, claims.Username, []string{claims.Role})
 }
 
+// RevokeToken revokes an access or refresh token until it expires.
+// Tokens that are already expired are accepted and ignored.
+func (j *JWTService) RevokeToken(ctx context.Context, tokenString string) error {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, j.keyFunc)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil
+		}
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return j.RevokeClaims(ctx, claims)
+}
+
+// RevokeClaims revokes the token described by already validated claims
+func (j *JWTService) RevokeClaims(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return errors.New("token has no ID or expiry")
+	}
+	if err := j.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllUserTokens invalidates every access and refresh token issued to
+// the user so far
+func (j *JWTService) RevokeAllUserTokens(ctx context.Context, userID string) error {
+	if err := j.revocations.RevokeUser(ctx, userID, j.refreshExpiry); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+	return nil
+}
+
 // GetUserFromToken extracts user information from a valid token
 func (j *JWTService) GetUserFromToken(tokenString string) (*Claims, error) {
 	return j.ValidateToken(tokenString)
-}
\ No newline at end of file
+}
+
+func (j *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Verify signing method
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return j.publicKey, nil
+}
+
+// parseToken verifies the signature and standard claims of a token
+func (j *JWTService) parseToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+func (j *JWTService) parseRefreshToken(tokenString string) (*Claims, error) {
+	claims, err := j.parseToken(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token: %w", err)
+	}
+
+	// Additional validation for refresh tokens
+	if len(claims.Audience) == 0 || claims.Audience[0] != refreshTokenAudience {
+		return nil, errors.New("token is not a valid refresh token")
+	}
+
+	return claims, nil
+}
+
+// checkRevoked rejects tokens that were revoked individually or by a
+// user-wide revocation issued after them
+func (j *JWTService) checkRevoked(ctx context.Context, claims *Claims) error {
+	revoked, err := j.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check revocation list: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return j.checkUserRevoked(ctx, claims)
+}
+
+// checkUserRevoked rejects tokens issued before the user's revocation
+// cut-off. Tokens without a precise issue time only have second precision,
+// so those from the same second as the cut-off are rejected too.
+func (j *JWTService) checkUserRevoked(ctx context.Context, claims *Claims) error {
+	revokedAt, err := j.revocations.UserRevokedAt(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check revocation list: %w", err)
+	}
+	issuedAt, precise := claims.issuedAt()
+	if revokedAt.IsZero() || issuedAt.IsZero() {
+		return nil
+	}
+	if precise && issuedAt.Before(revokedAt) || !precise && !issuedAt.After(revokedAt.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
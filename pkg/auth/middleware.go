@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -34,7 +35,7 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := am.jwtService.ValidateToken(token)
+		claims, err := am.jwtService.ValidateTokenContext(c.Request.Context(), token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid or expired token",
@@ -218,7 +219,7 @@ func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := am.jwtService.ValidateToken(token)
+		claims, err := am.jwtService.ValidateTokenContext(c.Request.Context(), token)
 		if err != nil {
 			// Invalid token, but we don't abort for optional auth
 			c.Next()
@@ -239,8 +240,48 @@ func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	}
 }
 
+// JWTAuthMiddleware authenticates requests with a bearer access token,
+// rejecting revoked tokens. It stores the claims, the user ID and the raw
+// token in the context for handlers such as logout.
+func JWTAuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Authorization token required",
+				"code":  "AUTH_TOKEN_MISSING",
+			})
+			c.Abort()
+			return
+		}
+
+		claims, err := jwtService.ValidateTokenContext(c.Request.Context(), token)
+		if err != nil {
+			code := "AUTH_TOKEN_INVALID"
+			if errors.Is(err, ErrTokenRevoked) {
+				code = "AUTH_TOKEN_REVOKED"
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or expired token",
+				"code":  code,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("claims", claims)
+		c.Set("user_id", claims.UserID)
+		c.Set("access_token", token)
+		c.Next()
+	}
+}
+
 // extractToken extracts JWT token from Authorization header
 func (am *AuthMiddleware) extractToken(c *gin.Context) string {
+	return bearerToken(c)
+}
+
+func bearerToken(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
 		return ""
@@ -26,10 +26,56 @@ const (
 	PermissionUserRead       = "user:read"
 	PermissionInferenceWrite = "inference:write"
 	PermissionInferenceRead  = "inference:read"
+	PermissionInferenceRun   = "inference:run"
+	PermissionMetricsRead    = "metrics:read"
 	PermissionSystemManage   = "system:manage"
 	PermissionSystemRead     = "system:read"
 )
 
+// rolePermissions holds the permissions of the default roles
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionModelManage,
+		PermissionModelRead,
+		PermissionClusterManage,
+		PermissionClusterRead,
+		PermissionNodeManage,
+		PermissionNodeRead,
+		PermissionInferenceRun,
+		PermissionMetricsRead,
+		PermissionSystemManage,
+	},
+	RoleOperator: {
+		PermissionModelManage,
+		PermissionModelRead,
+		PermissionClusterRead,
+		PermissionNodeManage,
+		PermissionNodeRead,
+		PermissionInferenceRun,
+		PermissionMetricsRead,
+	},
+	RoleUser: {
+		PermissionModelRead,
+		PermissionInferenceRun,
+	},
+	RoleReadonly: {
+		PermissionModelRead,
+		PermissionClusterRead,
+		PermissionNodeRead,
+		PermissionMetricsRead,
+	},
+}
+
+// GetRolePermissions returns the permissions of a default role, nil for an
+// unknown role
+func GetRolePermissions(role string) []string {
+	perms, ok := rolePermissions[role]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), perms...)
+}
+
 // RBAC implements Role-Based Access Control
 type RBAC struct {
 	roles       map[string]*Role
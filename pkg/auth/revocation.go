@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrTokenRevoked is returned when a token has been revoked by logout,
+// refresh token rotation or a user-wide revocation
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationList records revoked token IDs until the tokens would have
+// expired anyway, and user-wide revocation cut-off times
+type RevocationList interface {
+	// Revoke marks the token ID as revoked until expiresAt
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// RevokeOnce atomically marks the token ID as revoked until expiresAt,
+	// reporting false if it already was
+	RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+	// IsRevoked reports whether the token ID has been revoked
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	// RevokeUser invalidates every token issued to the user before now. The
+	// record is kept for ttl, which should cover the longest token lifetime.
+	RevokeUser(ctx context.Context, userID string, ttl time.Duration) error
+	// UserRevokedAt returns the user's revocation cut-off, or the zero time
+	UserRevokedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+const (
+	redisRevokedTokenPrefix = "auth:revoked:token:"
+	redisRevokedUserPrefix  = "auth:revoked:user:"
+)
+
+// RedisRevocationList stores revocations in Redis so that every API
+// replica sees a logout immediately
+type RedisRevocationList struct {
+	client *redis.Client
+}
+
+// NewRedisRevocationList creates a revocation list backed by Redis
+func NewRedisRevocationList(client *redis.Client) *RedisRevocationList {
+	return &RedisRevocationList{client: client}
+}
+
+// Revoke marks the token ID as revoked until expiresAt
+func (r *RedisRevocationList) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil // already expired, nothing to remember
+	}
+	return r.client.Set(ctx, redisRevokedTokenPrefix+tokenID, 1, ttl).Err()
+}
+
+// RevokeOnce atomically marks the token ID as revoked until expiresAt with
+// SETNX, reporting false if it already was
+func (r *RedisRevocationList) RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return true, nil
+	}
+	return r.client.SetNX(ctx, redisRevokedTokenPrefix+tokenID, 1, ttl).Result()
+}
+
+// IsRevoked reports whether the token ID has been revoked
+func (r *RedisRevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := r.client.Exists(ctx, redisRevokedTokenPrefix+tokenID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeUser invalidates every token issued to the user before now
+func (r *RedisRevocationList) RevokeUser(ctx context.Context, userID string, ttl time.Duration) error {
+	return r.client.Set(ctx, redisRevokedUserPrefix+userID, time.Now().UnixNano(), ttl).Err()
+}
+
+// UserRevokedAt returns the user's revocation cut-off, or the zero time
+func (r *RedisRevocationList) UserRevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	val, err := r.client.Get(ctx, redisRevokedUserPrefix+userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Cut-offs were once recorded in seconds
+	if unix < 1e12 {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Unix(0, unix), nil
+}
+
+// MemoryRevocationList keeps revocations in process memory. It is used
+// when Redis is not configured and is only correct for a single replica.
+type MemoryRevocationList struct {
+	tokens map[string]time.Time
+	users  map[string]memoryUserRevocation
+	mutex  sync.Mutex
+}
+
+type memoryUserRevocation struct {
+	at      time.Time
+	expires time.Time
+}
+
+// NewMemoryRevocationList creates an in-memory revocation list
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]memoryUserRevocation),
+	}
+}
+
+// Revoke marks the token ID as revoked until expiresAt
+func (m *MemoryRevocationList) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pruneLocked(time.Now())
+	m.tokens[tokenID] = expiresAt
+	return nil
+}
+
+// RevokeOnce marks the token ID as revoked until expiresAt, reporting false
+// if it already was; the check and the update happen under one lock
+func (m *MemoryRevocationList) RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.tokens[tokenID]; ok && now.Before(existing) {
+		return false, nil
+	}
+	m.pruneLocked(now)
+	m.tokens[tokenID] = expiresAt
+	return true, nil
+}
+
+// IsRevoked reports whether the token ID has been revoked
+func (m *MemoryRevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	expiresAt, ok := m.tokens[tokenID]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// RevokeUser invalidates every token issued to the user before now
+func (m *MemoryRevocationList) RevokeUser(ctx context.Context, userID string, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	m.users[userID] = memoryUserRevocation{at: now, expires: now.Add(ttl)}
+	return nil
+}
+
+// UserRevokedAt returns the user's revocation cut-off, or the zero time
+func (m *MemoryRevocationList) UserRevokedAt(ctx context.Context, userID string) (time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rev, ok := m.users[userID]
+	if !ok || time.Now().After(rev.expires) {
+		return time.Time{}, nil
+	}
+	return rev.at, nil
+}
+
+// pruneLocked drops entries for tokens that have expired on their own
+func (m *MemoryRevocationList) pruneLocked(now time.Time) {
+	for id, expiresAt := range m.tokens {
+		if now.After(expiresAt) {
+			delete(m.tokens, id)
+		}
+	}
+	for id, rev := range m.users {
+		if now.After(rev.expires) {
+			delete(m.users, id)
+		}
+	}
+}
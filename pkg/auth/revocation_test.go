@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khryptorgraphics/ollamamax/internal/config"
+)
+
+func newTestJWTService(t *testing.T) *JWTService {
+	t.Helper()
+	svc, err := NewJWTService(&config.AuthConfig{
+		Enabled:     true,
+		Method:      "jwt",
+		TokenExpiry: time.Minute,
+		RefreshTime: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+	return svc
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	svc := newTestJWTService(t)
+	ctx := context.Background()
+
+	tokens, err := svc.GenerateToken("user-1", "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+
+	if _, err := svc.ValidateToken(tokens.RefreshToken); err == nil {
+		t.Error("Refresh token must not be accepted as an access token")
+	}
+
+	claims, err := svc.RotateRefreshToken(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("First rotation should succeed: %v", err)
+	}
+	if claims.UserID != "user-1" {
+		t.Errorf("Expected user-1, got %s", claims.UserID)
+	}
+
+	// Presenting the rotated token again revokes everything issued so far
+	_, err = svc.RotateRefreshToken(ctx, tokens.RefreshToken)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := svc.ValidateToken(tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Access token should be revoked after reuse, got %v", err)
+	}
+}
+
+func TestConcurrentRefreshTokenRotation(t *testing.T) {
+	svc := newTestJWTService(t)
+	ctx := context.Background()
+
+	tokens, err := svc.GenerateToken("user-1", "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+
+	const attempts = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.RotateRefreshToken(ctx, tokens.RefreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrRefreshTokenReused) && !errors.Is(err, ErrTokenRevoked):
+			t.Errorf("Unexpected rotation error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one rotation to succeed, got %d", succeeded)
+	}
+}
+
+func TestLoginRightAfterRevokingAllTokens(t *testing.T) {
+	svc := newTestJWTService(t)
+	ctx := context.Background()
+
+	old, err := svc.GenerateToken("user-1", "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+	if err := svc.RevokeAllUserTokens(ctx, "user-1"); err != nil {
+		t.Fatalf("Failed to revoke user tokens: %v", err)
+	}
+	fresh, err := svc.GenerateToken("user-1", "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+
+	if _, err := svc.ValidateTokenContext(ctx, old.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Tokens issued before the revocation should be revoked, got %v", err)
+	}
+	if _, err := svc.ValidateTokenContext(ctx, fresh.AccessToken); err != nil {
+		t.Errorf("Tokens issued right after the revocation should be valid: %v", err)
+	}
+	if _, err := svc.RotateRefreshToken(ctx, fresh.RefreshToken); err != nil {
+		t.Errorf("Refresh tokens issued right after the revocation should rotate: %v", err)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	svc := newTestJWTService(t)
+	ctx := context.Background()
+
+	tokens, err := svc.GenerateToken("user-1", "alice", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+	other, err := svc.GenerateToken("user-2", "bob", RoleUser, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate tokens: %v", err)
+	}
+
+	if err := svc.RevokeToken(ctx, tokens.AccessToken); err != nil {
+		t.Fatalf("Failed to revoke token: %v", err)
+	}
+	if _, err := svc.ValidateTokenContext(ctx, tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.ValidateTokenContext(ctx, other.AccessToken); err != nil {
+		t.Errorf("Other users' tokens should stay valid: %v", err)
+	}
+	if _, err := svc.ValidateRefreshToken(tokens.RefreshToken); err != nil {
+		t.Errorf("Refresh token should stay valid: %v", err)
+	}
+}
+
+func TestMemoryRevocationListExpiry(t *testing.T) {
+	list := NewMemoryRevocationList()
+	ctx := context.Background()
+
+	list.Revoke(ctx, "expired", time.Now().Add(-time.Second))
+	list.Revoke(ctx, "active", time.Now().Add(time.Hour))
+
+	if revoked, _ := list.IsRevoked(ctx, "expired"); revoked {
+		t.Error("Entries past their expiry should be forgotten")
+	}
+	if revoked, _ := list.IsRevoked(ctx, "active"); !revoked {
+		t.Error("Expected active entry to be revoked")
+	}
+	if at, _ := list.UserRevokedAt(ctx, "user-1"); !at.IsZero() {
+		t.Error("Expected no user revocation")
+	}
+}
+
+func TestMemoryRevocationListRevokeOnce(t *testing.T) {
+	list := NewMemoryRevocationList()
+	ctx := context.Background()
+
+	if first, _ := list.RevokeOnce(ctx, "token", time.Now().Add(time.Hour)); !first {
+		t.Error("Expected the first revocation to claim the token")
+	}
+	if again, _ := list.RevokeOnce(ctx, "token", time.Now().Add(time.Hour)); again {
+		t.Error("Expected a second revocation to report the token as already revoked")
+	}
+	list.Revoke(ctx, "expired", time.Now().Add(-time.Second))
+	if first, _ := list.RevokeOnce(ctx, "expired", time.Now().Add(time.Hour)); !first {
+		t.Error("Expired entries should not count as revoked")
+	}
+}